@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/monitor"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/record"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/mainlist"
+	"github.com/tsupplis/pvec/pkg/ui/permissions"
+	"github.com/tsupplis/pvec/pkg/update"
+)
+
+// wakeTimeout bounds the wake-on-LAN request of the wake subcommand
+const wakeTimeout = 30 * time.Second
+
+// permissionsTimeout bounds the requests of the permissions subcommand
+const permissionsTimeout = 30 * time.Second
+
+// snapshotsTimeout bounds the listing of the snapshots subcommand, and
+// then each deletion
+const snapshotsTimeout = 5 * time.Minute
+
+// nodeWaker sends wake-on-LAN packets for a node
+type nodeWaker interface {
+	WakeNode(ctx context.Context, node string) (string, error)
+}
+
+// guestLister lists the guests the token can see
+type guestLister interface {
+	GetNodes(ctx context.Context) ([]*models.VMStatus, error)
+}
+
+// backend is what the subcommands use; a nil member means the backend
+// doesn't support the subcommands that need it
+type backend struct {
+	waker       nodeWaker
+	guests      guestLister
+	power       proxmox.PowerController
+	permissions proxmox.PermissionReader
+	snapshots   proxmox.SnapshotManager
+}
+
+// newBackend picks the optional capabilities of a client
+func newBackend(client proxmox.Client) backend {
+	b := backend{guests: client, power: client}
+	if nodePower, ok := client.(proxmox.NodePowerController); ok {
+		b.waker = nodePower
+	}
+	if reader, ok := client.(proxmox.PermissionReader); ok {
+		b.permissions = reader
+	}
+	if snapshots, ok := client.(proxmox.SnapshotManager); ok {
+		b.snapshots = snapshots
+	}
+	return b
+}
+
+// runCommand runs the subcommand given on the command line instead of
+// the TUI
+func runCommand(w io.Writer, b backend, opts options) error {
+	args := opts.args
+	switch args[0] {
+	case "wake":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: pvec wake <node>")
+		}
+		if b.waker == nil {
+			return fmt.Errorf("wake is not supported by this backend")
+		}
+		return runWake(w, b.waker, args[1])
+	case "permissions":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: pvec permissions")
+		}
+		if b.permissions == nil {
+			return fmt.Errorf("permissions are not supported by this backend")
+		}
+		return runPermissions(w, b.permissions, b.guests)
+	case "start", "shutdown", "reboot", "stop", "resume":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: pvec %s <guest>", args[0])
+		}
+		if b.power == nil {
+			return fmt.Errorf("%s is not supported by this backend", args[0])
+		}
+		return runGuestAction(w, b.power, b.guests, args[0], args[1])
+	case "snapshots":
+		if b.snapshots == nil {
+			return fmt.Errorf("snapshots are not supported by this backend")
+		}
+		return runSnapshots(w, b.snapshots, b.guests, opts.olderThan, opts.deleteSnapshots, time.Now())
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// guestActions build the power actions of the guest subcommands
+var guestActions = map[string]func(actions.Executor, *models.VMStatus) actions.Action{
+	"start":    func(e actions.Executor, g *models.VMStatus) actions.Action { return actions.NewStartAction(e, g) },
+	"shutdown": func(e actions.Executor, g *models.VMStatus) actions.Action { return actions.NewShutdownAction(e, g) },
+	"reboot":   func(e actions.Executor, g *models.VMStatus) actions.Action { return actions.NewRebootAction(e, g) },
+	"stop":     func(e actions.Executor, g *models.VMStatus) actions.Action { return actions.NewStopAction(e, g) },
+	"resume":   func(e actions.Executor, g *models.VMStatus) actions.Action { return actions.NewResumeAction(e, g) },
+}
+
+// runGuestAction runs a power action on the guest ref names: its VMID,
+// its name, or the start of a single guest's name, which is noted. A
+// name several guests match is refused with the list of them.
+func runGuestAction(w io.Writer, power proxmox.PowerController, lister guestLister, action, ref string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mainlist.DefaultActionTimeout)
+	defer cancel()
+
+	guests, err := lister.GetNodes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list guests: %w", err)
+	}
+	guest, byPrefix, err := models.ResolveGuest(guests, ref)
+	if err != nil {
+		return err
+	}
+	if byPrefix {
+		fmt.Fprintf(w, "Taking %s (%s), the only guest whose name starts with %q\n", guest.Name, guest.Key(), ref)
+	}
+
+	list := models.NewNodeList()
+	list.ReplaceAll(guests)
+	executor := proxmox.NewActionExecutor(power, list)
+	if err := guestActions[action](executor, guest).Execute(ctx); err != nil {
+		return fmt.Errorf("failed to %s %s (%s): %w", action, guest.Name, guest.Key(), err)
+	}
+	fmt.Fprintf(w, "Sent %s to %s (%s) on %s\n", action, guest.Name, guest.Key(), guest.Node)
+	return nil
+}
+
+// runMonitor logs the guests' state changes and the refresh errors to w
+// on the refresh interval until ctx is cancelled. getenv reads the
+// variables systemd sets for a service.
+func runMonitor(ctx context.Context, w io.Writer, lister guestLister, cfg *config.Config, onChanges func([]models.StateChange), getenv func(string) string) error {
+	m := &monitor.Monitor{
+		Lister:         lister,
+		Interval:       cfg.RefreshInterval,
+		Timeout:        cfg.RefreshTimeout,
+		Logger:         monitor.NewLogger(w, getenv("JOURNAL_STREAM") != ""),
+		OnStateChanges: onChanges,
+		Notifier:       monitor.NewNotifier(getenv("NOTIFY_SOCKET")),
+		Watchdog:       monitor.WatchdogInterval(getenv("WATCHDOG_USEC"), getenv("WATCHDOG_PID")),
+	}
+	return m.Run(ctx)
+}
+
+// runRecord appends a sample of the guests to the --out file on every
+// --interval until --duration has passed or ctx is cancelled, then closes
+// the file, so a Ctrl+C loses nothing written
+func runRecord(ctx context.Context, w io.Writer, lister guestLister, opts options, timeout time.Duration) error {
+	out, err := record.OpenWriter(opts.recordOut, int64(opts.recordMaxSize)<<20)
+	if err != nil {
+		return fmt.Errorf("failed to open the recording: %w", err)
+	}
+	r := &record.Recorder{
+		Lister:   lister,
+		Writer:   out,
+		Interval: opts.recordInterval,
+		Duration: opts.recordDuration,
+		Timeout:  timeout,
+		Logf:     func(format string, args ...interface{}) { fmt.Fprintf(w, format+"\n", args...) },
+	}
+	written, err := r.Run(ctx)
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close the recording: %w", closeErr)
+	}
+	fmt.Fprintf(w, "Recorded %d samples to %s\n", written, opts.recordOut)
+	return err
+}
+
+// runReport prints the CPU and memory each guest used over a recording:
+// the least, the mean and the most, counting only the samples where it
+// was running
+func runReport(w io.Writer, r io.Reader) error {
+	report, err := record.Aggregate(r)
+	if err != nil {
+		return fmt.Errorf("failed to read the recording: %w", err)
+	}
+	if report.Samples == 0 {
+		return fmt.Errorf("the recording holds no samples")
+	}
+	fmt.Fprintf(w, "%d samples from %s to %s\n", report.Samples,
+		report.From.Local().Format("2006-01-02 15:04"), report.To.Local().Format("2006-01-02 15:04"))
+	if report.Skipped > 0 {
+		fmt.Fprintf(w, "Skipped %d lines that aren't samples\n", report.Skipped)
+	}
+	fmt.Fprintf(w, "\n%-10s %-20s %7s  %-20s  %s\n", "VMID", "NAME", "SAMPLES", "CPU MIN/AVG/MAX", "MEM MIN/AVG/MAX")
+	for _, g := range report.Guests {
+		cpu, mem := format.Unknown, format.Unknown
+		if g.CPU.Count > 0 {
+			cpu = fmt.Sprintf("%.1f/%.1f/%.1f%%", g.CPU.Min, g.CPU.Avg(), g.CPU.Max)
+			mem = fmt.Sprintf("%s / %s / %s", format.Bytes(int64(g.Mem.Min)), format.Bytes(int64(g.Mem.Avg())), format.Bytes(int64(g.Mem.Max)))
+		}
+		fmt.Fprintf(w, "%-10s %-20s %7d  %-20s  %s\n", g.Key, g.Name, g.Samples, cpu, mem)
+	}
+	return nil
+}
+
+// runWake asks a node's cluster peers to send it a wake-on-LAN packet
+func runWake(w io.Writer, client nodeWaker, node string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), wakeTimeout)
+	defer cancel()
+
+	mac, err := client.WakeNode(ctx, node)
+	if errors.Is(err, proxmox.ErrNoWakeOnLAN) {
+		return fmt.Errorf("cannot wake %s: %s", node, proxmox.WakeOnLANHint(node))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to wake %s: %w", node, err)
+	}
+	fmt.Fprintf(w, "Sent wake-on-LAN to %s (%s)\n", node, mac)
+	return nil
+}
+
+// runPermissions prints the token's privileges, flagging the ones pvec
+// uses that are missing. It fails when the token can't list guests.
+func runPermissions(w io.Writer, reader proxmox.PermissionReader, lister guestLister) error {
+	ctx, cancel := context.WithTimeout(context.Background(), permissionsTimeout)
+	defer cancel()
+
+	perms, err := reader.GetPermissions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read permissions: %w", err)
+	}
+	// The guest paths are a bonus: the report stands without them
+	var guests []*models.VMStatus
+	if lister != nil {
+		guests, _ = lister.GetNodes(ctx)
+	}
+
+	state := permissions.New(guests)
+	for _, row := range permissions.Rows(perms, state.Guests) {
+		fmt.Fprintln(w, row.Text)
+	}
+	for _, r := range proxmox.MissingRequirements(perms) {
+		if r.Essential {
+			return fmt.Errorf("the token lacks %s on %s: pvec will list nothing", r.Privilege, r.Path)
+		}
+	}
+	return nil
+}
+
+// autoSnapshot is a snapshot pvec took before an action, and its guest
+type autoSnapshot struct {
+	guest    *models.VMStatus
+	snapshot models.Snapshot
+}
+
+// runSnapshots lists the snapshots pvec took before actions, only those
+// older than olderThan days unless it is 0, and deletes them if asked,
+// one at a time as Proxmox locks the guest during a deletion. It goes on
+// past a guest it can't read or a snapshot it can't delete, and fails at
+// the end if any deletion failed.
+func runSnapshots(w io.Writer, manager proxmox.SnapshotManager, lister guestLister, olderThan int, del bool, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotsTimeout)
+	defer cancel()
+
+	guests, err := lister.GetNodes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list guests: %w", err)
+	}
+	cutoff := now.Add(-time.Duration(olderThan) * 24 * time.Hour)
+	var found []autoSnapshot
+	for _, guest := range guests {
+		snapshots, err := manager.ListSnapshots(ctx, guest.Node, guest.TypeString(), guest.VMID)
+		if err != nil {
+			fmt.Fprintf(w, "Skipped %s %s: %v\n", guest.VMID, guest.Name, err)
+			continue
+		}
+		for _, s := range snapshots {
+			if s.Auto() && (olderThan == 0 || s.Created.Before(cutoff)) {
+				found = append(found, autoSnapshot{guest: guest, snapshot: s})
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		if olderThan > 0 {
+			fmt.Fprintf(w, "No pvec-auto snapshots older than %d days\n", olderThan)
+		} else {
+			fmt.Fprintln(w, "No pvec-auto snapshots")
+		}
+		return nil
+	}
+	for _, f := range found {
+		fmt.Fprintf(w, "%-6s %-20s %-26s %s old\n", f.guest.VMID, f.guest.Name, f.snapshot.Name, snapshotAge(now.Sub(f.snapshot.Created)))
+	}
+	if !del {
+		if olderThan > 0 {
+			fmt.Fprintf(w, "Add --delete to delete these %d snapshots\n", len(found))
+		}
+		return nil
+	}
+
+	failed := 0
+	for _, f := range found {
+		if err := deleteSnapshot(manager, f); err != nil {
+			fmt.Fprintf(w, "Failed to delete %s of %s: %v\n", f.snapshot.Name, f.guest.VMID, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "Deleted %s of %s\n", f.snapshot.Name, f.guest.VMID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d snapshots could not be deleted", failed, len(found))
+	}
+	return nil
+}
+
+// deleteSnapshot deletes a snapshot and waits for the task to end
+func deleteSnapshot(manager proxmox.SnapshotManager, f autoSnapshot) error {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotsTimeout)
+	defer cancel()
+
+	node := f.guest.Node
+	upid, err := manager.DeleteSnapshot(ctx, node, f.guest.TypeString(), f.guest.VMID, f.snapshot.Name)
+	if err != nil {
+		return err
+	}
+	return proxmox.WaitTask(ctx, manager, node, upid, 0)
+}
+
+// snapshotAge renders the age of a snapshot in days, or hours under a day
+func snapshotAge(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// runUpdateCheck prints whether a release newer than the running build
+// is out, for pvec update --check
+func runUpdateCheck(w io.Writer, checker *update.Checker) error {
+	latest, err := checker.Latest(context.Background())
+	if err != nil {
+		return fmt.Errorf("checking for updates: %w", err)
+	}
+	newer, err := update.Newer(latest.Version, checker.Current)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "pvec %s is not a release build; the latest release is %s: %s\n", checker.Current, latest.Version, latest.URL)
+	case newer:
+		fmt.Fprintf(w, "pvec %s available (running %s): %s\n", latest.Version, checker.Current, latest.URL)
+	default:
+		fmt.Fprintf(w, "pvec %s is up to date\n", checker.Current)
+	}
+	return nil
+}
+
+// releaseCheck adapts a checker to the startup check of the list
+func releaseCheck(checker *update.Checker) mainlist.ReleaseCheck {
+	return func(ctx context.Context) (string, error) {
+		release, err := checker.Check(ctx)
+		if err != nil || release == nil {
+			return "", err
+		}
+		return release.Version, nil
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/update"
+)
+
+// fakeWaker returns a fixed MAC or error
+type fakeWaker struct {
+	mac   string
+	err   error
+	nodes []string
+}
+
+func (f *fakeWaker) WakeNode(ctx context.Context, node string) (string, error) {
+	f.nodes = append(f.nodes, node)
+	return f.mac, f.err
+}
+
+func TestRunCommand_Wake(t *testing.T) {
+	waker := &fakeWaker{mac: "bc:24:11:7f:3a:02"}
+	var out bytes.Buffer
+
+	if err := runCommand(&out, backend{waker: waker}, options{args: []string{"wake", "pve2"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(waker.nodes) != 1 || waker.nodes[0] != "pve2" {
+		t.Errorf("Expected a wake of pve2, got %v", waker.nodes)
+	}
+	if got, want := out.String(), "Sent wake-on-LAN to pve2 (bc:24:11:7f:3a:02)\n"; got != want {
+		t.Errorf("Expected output %q, got %q", want, got)
+	}
+}
+
+func TestRunCommand_WakeErrors(t *testing.T) {
+	var out bytes.Buffer
+
+	noMAC := &fakeWaker{err: fmt.Errorf("%w: status 501", proxmox.ErrNoWakeOnLAN)}
+	err := runCommand(&out, backend{waker: noMAC}, options{args: []string{"wake", "pve3"}})
+	if err == nil || !strings.Contains(err.Error(), "pvenode config set --wakeonlan") {
+		t.Errorf("Expected a hint about the wakeonlan option, got %v", err)
+	}
+
+	failing := &fakeWaker{err: errors.New("status 403")}
+	err = runCommand(&out, backend{waker: failing}, options{args: []string{"wake", "pve3"}})
+	if err == nil || err.Error() != "failed to wake pve3: status 403" {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+
+	if err := runCommand(&out, backend{waker: failing}, options{args: []string{"wake"}}); err == nil {
+		t.Error("A missing node name should be an error")
+	}
+	if err := runCommand(&out, backend{}, options{args: []string{"wake", "pve3"}}); err == nil {
+		t.Error("A backend without wake support should be an error")
+	}
+	if err := runCommand(&out, backend{waker: failing}, options{args: []string{"bogus"}}); err == nil {
+		t.Error("An unknown command should be an error")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Nothing should be printed on failure, got %q", out.String())
+	}
+}
+
+// fakePermissions returns fixed privileges and guests
+type fakePermissions struct {
+	perms  models.Permissions
+	guests []*models.VMStatus
+	err    error
+}
+
+func (f *fakePermissions) GetPermissions(ctx context.Context) (models.Permissions, error) {
+	return f.perms, f.err
+}
+
+func (f *fakePermissions) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	return f.guests, nil
+}
+
+// stoppingLister lists fixed guests, then stops the monitor
+type stoppingLister struct {
+	guests []*models.VMStatus
+	calls  int
+	stop   context.CancelFunc
+}
+
+func (f *stoppingLister) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	f.calls++
+	if f.calls > 1 {
+		f.stop()
+		return nil, ctx.Err()
+	}
+	return f.guests, nil
+}
+
+func TestRunMonitor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lister := &stoppingLister{guests: []*models.VMStatus{{VMID: "100", Name: "web"}}, stop: cancel}
+	cfg := &config.Config{RefreshInterval: time.Millisecond}
+	env := map[string]string{"JOURNAL_STREAM": "8:12345"}
+	var out bytes.Buffer
+
+	if err := runMonitor(ctx, &out, lister, cfg, nil, func(k string) string { return env[k] }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := "level=INFO msg=\"monitor started\" interval=1ms\n" +
+		"level=INFO msg=\"guests listed\" count=1\n" +
+		"level=INFO msg=\"monitor stopped\"\n"
+	if got := out.String(); got != want {
+		t.Errorf("Expected under journald lines without time:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestRunRecordAndReport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lister := &stoppingLister{stop: cancel, guests: []*models.VMStatus{
+		{VMID: "100", Name: "web", Status: models.StateRunning, CPUUsage: 12.5, MemoryUsage: 50, MaxMem: 2 << 30},
+		{VMID: "101", Name: "db", Status: models.StateStopped},
+	}}
+	path := filepath.Join(t.TempDir(), "cluster.jsonl")
+	var out bytes.Buffer
+
+	opts := options{recordOut: path, recordInterval: time.Millisecond, args: []string{"record"}}
+	if err := runRecord(ctx, &out, lister, opts, 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, want := out.String(), "Recorded 1 samples to "+path+"\n"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := runReport(&out, bytes.NewReader(data)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "1 samples from ") {
+		t.Fatalf("Expected a heading and two guests, got:\n%s", out.String())
+	}
+	for i, want := range []string{
+		"100        web                        1  12.5/12.5/12.5%       1.0 GB / 1.0 GB / 1.0 GB",
+		"101        db                         1  -                     -",
+	} {
+		if lines[3+i] != want {
+			t.Errorf("Expected row %q, got %q", want, lines[3+i])
+		}
+	}
+
+	if err := runReport(&out, strings.NewReader("")); err == nil {
+		t.Error("Expected an error for an empty recording")
+	}
+}
+
+func TestRunCommand_Permissions(t *testing.T) {
+	fake := &fakePermissions{
+		perms:  models.Permissions{"/vms": {"VM.Audit": true}},
+		guests: []*models.VMStatus{{VMID: "100", VMIDNum: 100, Name: "web"}},
+	}
+	var out bytes.Buffer
+
+	if err := runCommand(&out, backend{permissions: fake, guests: fake}, options{args: []string{"permissions"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"ok      VM.Audit", "MISSING VM.PowerMgmt", "/vms/100   web              MISSING VM.PowerMgmt"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+
+	// A token that can't see guests fails the command
+	fake.perms = models.Permissions{}
+	err := runCommand(&out, backend{permissions: fake}, options{args: []string{"permissions"}})
+	if err == nil || !strings.Contains(err.Error(), "lacks VM.Audit on /vms") {
+		t.Errorf("Expected the missing VM.Audit, got %v", err)
+	}
+
+	fake.err = errors.New("status 401")
+	if err := runCommand(&out, backend{permissions: fake}, options{args: []string{"permissions"}}); err == nil {
+		t.Error("A failed read should be an error")
+	}
+	if err := runCommand(&out, backend{}, options{args: []string{"permissions"}}); err == nil {
+		t.Error("A backend without permissions should be an error")
+	}
+}
+
+// fakeSnapshots lists fixed snapshots for every guest and records the
+// deletions; their tasks end at once
+type fakeSnapshots struct {
+	snapshots []models.Snapshot
+	listErr   error // Returned for guest 101
+	deleteErr error // Returned for snapshots of guest 102
+	deleted   []string
+}
+
+func (f *fakeSnapshots) SnapshotSupported(ctx context.Context, node, vmType, vmid string) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeSnapshots) ListSnapshots(ctx context.Context, node, vmType, vmid string) ([]models.Snapshot, error) {
+	if vmid == "101" {
+		return nil, f.listErr
+	}
+	return f.snapshots, nil
+}
+
+func (f *fakeSnapshots) CreateSnapshot(ctx context.Context, node, vmType, vmid, name, description string) (string, error) {
+	return "", errors.New("not expected")
+}
+
+func (f *fakeSnapshots) DeleteSnapshot(ctx context.Context, node, vmType, vmid, name string) (string, error) {
+	if vmid == "102" && f.deleteErr != nil {
+		return "", f.deleteErr
+	}
+	f.deleted = append(f.deleted, node+"/"+vmType+"/"+vmid+"/"+name)
+	return "UPID:" + node + ":delsnapshot", nil
+}
+
+func (f *fakeSnapshots) GetTaskStatus(ctx context.Context, node, upid string) (models.TaskStatus, error) {
+	return models.TaskStatus{ExitStatus: "OK"}, nil
+}
+
+func TestRunSnapshots(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	fake := &fakeSnapshots{snapshots: []models.Snapshot{
+		{Name: "before-upgrade", Created: now.Add(-90 * 24 * time.Hour)},
+		{Name: "pvec-auto-20260917-120000", Created: now.Add(-30 * 24 * time.Hour)},
+		{Name: "pvec-auto-20261017-090000", Created: now.Add(-3 * time.Hour)},
+	}, listErr: errors.New("status 403")}
+	guests := &fakePermissions{guests: []*models.VMStatus{
+		{VMID: "100", Name: "web", Node: "pve1", Type: models.TypeVM},
+		{VMID: "101", Name: "db", Node: "pve1", Type: models.TypeVM},
+	}}
+
+	var out bytes.Buffer
+	if err := runSnapshots(&out, fake, guests, 0, false, now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"Skipped 101 db: status 403",
+		"100    web                  pvec-auto-20260917-120000  30d old",
+		"100    web                  pvec-auto-20261017-090000  3h old",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "before-upgrade") {
+		t.Errorf("Snapshots taken by hand are not pvec's to list:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runSnapshots(&out, fake, guests, 7, false, now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(out.String(), "3h old") || !strings.Contains(out.String(), "Add --delete to delete these 1 snapshots") {
+		t.Errorf("Expected only the snapshot older than 7 days:\n%s", out.String())
+	}
+	if len(fake.deleted) != 0 {
+		t.Errorf("Nothing should be deleted without --delete, got %v", fake.deleted)
+	}
+
+	out.Reset()
+	if err := runSnapshots(&out, fake, guests, 7, true, now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []string{"pve1/qemu/100/pvec-auto-20260917-120000"}; !reflect.DeepEqual(fake.deleted, want) {
+		t.Errorf("Expected %v deleted, got %v", want, fake.deleted)
+	}
+	if !strings.Contains(out.String(), "Deleted pvec-auto-20260917-120000 of 100") {
+		t.Errorf("Expected the deletion reported:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runSnapshots(&out, fake, guests, 365, true, now); err != nil || !strings.Contains(out.String(), "No pvec-auto snapshots older than 365 days") {
+		t.Errorf("Expected nothing to delete, got %v:\n%s", err, out.String())
+	}
+}
+
+func TestRunSnapshots_DeleteFailed(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	fake := &fakeSnapshots{
+		snapshots: []models.Snapshot{{Name: "pvec-auto-20260917-120000", Created: now.Add(-30 * 24 * time.Hour)}},
+		deleteErr: errors.New("snapshot is locked"),
+	}
+	guests := &fakePermissions{guests: []*models.VMStatus{
+		{VMID: "100", Name: "web", Node: "pve1", Type: models.TypeVM},
+		{VMID: "102", Name: "cache", Node: "pve2", Type: models.TypeContainer},
+	}}
+
+	var out bytes.Buffer
+	err := runCommand(&out, backend{snapshots: fake, guests: guests}, options{args: []string{"snapshots"}, olderThan: 7, deleteSnapshots: true})
+	if err == nil || err.Error() != "1 of 2 snapshots could not be deleted" {
+		t.Errorf("Expected the failed deletion to fail the command, got %v", err)
+	}
+	if len(fake.deleted) != 1 || !strings.Contains(out.String(), "Failed to delete pvec-auto-20260917-120000 of 102: snapshot is locked") {
+		t.Errorf("The other snapshots should still be deleted, got %v:\n%s", fake.deleted, out.String())
+	}
+
+	if err := runCommand(&out, backend{guests: guests}, options{args: []string{"snapshots"}}); err == nil {
+		t.Error("A backend without snapshots should be an error")
+	}
+}
+
+// releaseServer answers the latest release endpoint with tag
+func releaseServer(t *testing.T, tag string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name":%q,"html_url":"https://example.test/releases/%s"}`, tag, tag)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunUpdateCheck(t *testing.T) {
+	server := releaseServer(t, "v1.5.0")
+	tests := []struct {
+		current string
+		want    string
+	}{
+		{"v1.4.0", "pvec 1.5.0 available (running v1.4.0): https://example.test/releases/v1.5.0\n"},
+		{"v1.5.0", "pvec v1.5.0 is up to date\n"},
+		{"dev", "pvec dev is not a release build; the latest release is 1.5.0: https://example.test/releases/v1.5.0\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := runUpdateCheck(&out, &update.Checker{URL: server.URL, Current: tt.current})
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", tt.current, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("Expected %q, got %q", tt.want, out.String())
+		}
+	}
+}
+
+func TestRunUpdateCheck_MalformedTag(t *testing.T) {
+	server := releaseServer(t, "nightly")
+	var out bytes.Buffer
+	err := runUpdateCheck(&out, &update.Checker{URL: server.URL, Current: "v1.4.0"})
+	if err == nil || !strings.Contains(err.Error(), `invalid version "nightly"`) {
+		t.Errorf("Expected an invalid version error, got %v", err)
+	}
+}
+
+// fakePower records the power actions sent
+type fakePower struct {
+	proxmox.PowerController
+	sent []string
+	err  error
+}
+
+func (f *fakePower) Shutdown(ctx context.Context, node, vmType, vmid string) error {
+	f.sent = append(f.sent, "shutdown "+node+" "+vmType+" "+vmid)
+	return f.err
+}
+
+func (f *fakePower) Start(ctx context.Context, node, vmType, vmid string) error {
+	f.sent = append(f.sent, "start "+node+" "+vmType+" "+vmid)
+	return f.err
+}
+
+func guestBackend(power *fakePower) backend {
+	return backend{power: power, guests: &fakePermissions{guests: []*models.VMStatus{
+		{VMID: "100", Name: "web-1", Node: "pve1", Type: models.TypeVM},
+		{VMID: "101", Name: "web-2", Node: "pve1", Type: models.TypeVM},
+		{VMID: "102", Name: "db", Node: "pve2", Type: models.TypeVM},
+		{VMID: "103", Name: "db-replica", Node: "pve2", Type: models.TypeContainer},
+	}}}
+}
+
+func TestRunCommand_GuestAction(t *testing.T) {
+	for _, tt := range []struct {
+		name, action, ref string
+		sent              string
+		out               string
+	}{
+		{"VMID", "shutdown", "102", "shutdown pve2 qemu 102", "Sent shutdown to db (102) on pve2\n"},
+		{"exact name", "shutdown", "db", "shutdown pve2 qemu 102", "Sent shutdown to db (102) on pve2\n"},
+		{"name ignoring case", "start", "WEB-2", "start pve1 qemu 101", "Sent start to web-2 (101) on pve1\n"},
+		{"unique prefix", "start", "db-r", "start pve2 lxc 103",
+			"Taking db-replica (103), the only guest whose name starts with \"db-r\"\nSent start to db-replica (103) on pve2\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			power := &fakePower{}
+			var out bytes.Buffer
+			if err := runCommand(&out, guestBackend(power), options{args: []string{tt.action, tt.ref}}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(power.sent) != 1 || power.sent[0] != tt.sent {
+				t.Errorf("Expected %q, got %v", tt.sent, power.sent)
+			}
+			if out.String() != tt.out {
+				t.Errorf("Expected output %q, got %q", tt.out, out.String())
+			}
+		})
+	}
+}
+
+func TestRunCommand_GuestActionErrors(t *testing.T) {
+	power := &fakePower{}
+	var out bytes.Buffer
+
+	err := runCommand(&out, guestBackend(power), options{args: []string{"shutdown", "web"}})
+	want := `"web" starts the names of 2 guests: 100 (web-1) on pve1, 101 (web-2) on pve1; use the VMID or more of the name`
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected an ambiguous prefix to be refused with %q, got %v", want, err)
+	}
+	err = runCommand(&out, guestBackend(power), options{args: []string{"shutdown", "mail"}})
+	if err == nil || err.Error() != `no guest is named "mail" or has that VMID` {
+		t.Errorf("Expected a missing guest to be reported, got %v", err)
+	}
+	if len(power.sent) != 0 || out.Len() != 0 {
+		t.Errorf("Nothing should be sent or printed, got %v %q", power.sent, out.String())
+	}
+
+	power.err = errors.New("status 500")
+	err = runCommand(&out, guestBackend(power), options{args: []string{"shutdown", "db"}})
+	if err == nil || err.Error() != "failed to shutdown db (102): status 500" {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+	if err := runCommand(&out, backend{}, options{args: []string{"start", "db"}}); err == nil {
+		t.Error("A backend without power actions should be an error")
+	}
+}
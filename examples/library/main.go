@@ -0,0 +1,89 @@
+// Command library shows the stable pvecclient API: it lists the guests of
+// a cluster, prints the configuration of one and optionally runs an action
+// on it.
+//
+//	PVE_URL=https://pve.example.com:8006 PVE_TOKEN_ID='root@pam!pvec' \
+//	PVE_TOKEN_SECRET=... go run ./examples/library [vmid [start|shutdown|reboot|stop]]
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/pvecclient"
+)
+
+func main() {
+	client, err := pvecclient.New(pvecclient.Options{
+		BaseURL:       os.Getenv("PVE_URL"),
+		TokenID:       os.Getenv("PVE_TOKEN_ID"),
+		TokenSecret:   os.Getenv("PVE_TOKEN_SECRET"),
+		SkipTLSVerify: os.Getenv("PVE_SKIP_TLS_VERIFY") == "1",
+	})
+	if err != nil {
+		log.Fatalf("Invalid settings: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// List every guest
+	guests, err := client.Guests(ctx)
+	if pvecclient.IsUnauthorized(err) {
+		log.Fatalf("The token was rejected: %v", err)
+	}
+	if err != nil {
+		log.Fatalf("Failed to list guests: %v", err)
+	}
+	sort.Slice(guests, func(i, j int) bool { return guests[i].VMIDNum < guests[j].VMIDNum })
+	for _, g := range guests {
+		kind := "VM"
+		if g.Type == pvecclient.TypeContainer {
+			kind = "CT"
+		}
+		fmt.Printf("%-6s %s %-20s %-8s %s\n", g.VMID, kind, g.Name, g.Status, g.Node)
+	}
+
+	args := os.Args[1:]
+	if len(args) == 0 {
+		return
+	}
+
+	// Fetch the configuration of the chosen guest
+	guest, err := client.Guest(ctx, args[0])
+	if err != nil {
+		log.Fatalf("Failed to find guest: %v", err)
+	}
+	config, err := client.Config(ctx, guest)
+	if err != nil {
+		log.Fatalf("Failed to read the configuration of %s: %v", guest.VMID, err)
+	}
+	fmt.Printf("\n%s: %v cores, %v MiB\n", guest.Name, config["cores"], config["memory"])
+
+	if len(args) < 2 {
+		return
+	}
+
+	// Run the action
+	actions := map[string]func(context.Context, *pvecclient.Guest) error{
+		"start":    client.Start,
+		"shutdown": client.Shutdown,
+		"reboot":   client.Reboot,
+		"stop":     client.Stop,
+	}
+	action, ok := actions[args[1]]
+	if !ok {
+		log.Fatalf("Unknown action %q", args[1])
+	}
+	if err := action(ctx, guest); err != nil {
+		if pvecclient.IsForbidden(err) {
+			log.Fatalf("The token may not %s %s: %v", args[1], guest.VMID, err)
+		}
+		log.Fatalf("Failed to %s %s: %v", args[1], guest.VMID, err)
+	}
+	fmt.Printf("Requested %s of %s\n", args[1], guest.VMID)
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flagSpec describes one command-line flag. Parsing and the usage text
+// both read cliFlags, so a flag is only ever declared once.
+type flagSpec struct {
+	long  string // Name after --
+	short byte   // Letter after -, or 0 for none
+	value string // Name of the value in the usage text; empty for a switch
+	help  string // Usage text; further lines are indented under the first
+	set   func(o *options, value string)
+}
+
+var cliFlags = []flagSpec{
+	{long: "config", short: 'c', value: "file",
+		help: "Use exactly this configuration file, instead of merging\nthe files listed below",
+		set:  func(o *options, v string) { o.configPath = v }},
+	{long: "no-color", help: "Disable colors (also set by NO_COLOR)",
+		set: func(o *options, _ string) { o.noColor = true }},
+	{long: "demo", help: "Run offline against built-in sample data",
+		set: func(o *options, _ string) { o.demo = true }},
+	{long: "fixture", value: "file",
+		help: "Demo data file, a saved /cluster/resources response\n(implies --demo)",
+		set:  func(o *options, v string) { o.fixture, o.demo = v, true }},
+	{long: "node", value: "name", help: "Only show guests on this node (overrides default_node_filter)",
+		set: func(o *options, v string) { o.node = v }},
+	{long: "filter", value: "text",
+		help: "Only show guests whose name or VMID contains this text\n(overrides default_text_filter)",
+		set:  func(o *options, v string) { o.filter = v }},
+	{long: "fail-fast",
+		help: "Exit with an error if the first refresh fails, instead\nof showing it and retrying",
+		set:  func(o *options, _ string) { o.failFast = true }},
+	{long: "once", help: "Print one line summing up the guests and exit, for a\nshell prompt or tmux; fails after 3s without an answer",
+		set: func(o *options, _ string) { o.once = true }},
+	{long: "format", value: "name", help: "With --once: short (default), tmux or json",
+		set: func(o *options, v string) { o.onceFormat = v }},
+	{long: "version", short: 'v', help: "Show version information",
+		set: func(o *options, _ string) { o.showVersion = true }},
+	{long: "json", help: "With -v, print the build information as JSON",
+		set: func(o *options, _ string) { o.jsonVersion = true }},
+	{long: "help", short: 'h', help: "Print this help",
+		set: func(o *options, _ string) { o.showHelp = true }},
+}
+
+// commandSpec describes a subcommand run instead of the TUI
+type commandSpec struct {
+	name  string
+	args  []string   // Names of its arguments, all required
+	flags []flagSpec // Long flags only this command takes
+	help  string
+}
+
+var cliCommands = []commandSpec{
+	{name: "start", args: []string{"guest"}, help: "Start a guest, given by VMID, name or the start of\na name only one guest has, as for the commands below"},
+	{name: "shutdown", args: []string{"guest"}, help: "Shut a guest down gracefully"},
+	{name: "reboot", args: []string{"guest"}, help: "Reboot a guest"},
+	{name: "stop", args: []string{"guest"}, help: "Stop a guest at once"},
+	{name: "resume", args: []string{"guest"}, help: "Resume a paused guest"},
+	{name: "wake", args: []string{"node"}, help: "Send wake-on-LAN to a powered-off node"},
+	{name: "permissions", help: "List the token's privileges and the missing ones"},
+	{name: "monitor", help: "Log guest state changes and refresh errors to stdout,\nwithout the TUI, until stopped; for a systemd service"},
+	{name: "doctor", help: "Check the config, network, TLS, token and privileges"},
+	{name: "update", flags: []flagSpec{{long: "check", set: func(o *options, _ string) { o.updateCheck = true }}},
+		help: "With --check, report whether a newer release is out"},
+	{name: "snapshots", flags: []flagSpec{
+		{long: "older-than", value: "days", set: func(o *options, v string) { o.olderThan = parseDays(v) }},
+		{long: "delete", set: func(o *options, _ string) { o.deleteSnapshots = true }},
+	}, help: "List the snapshots pvec took before actions, those older\nthan --older-than days only; --delete removes them"},
+	{name: "record", flags: []flagSpec{
+		{long: "out", value: "file", set: func(o *options, v string) { o.recordOut = v }},
+		{long: "interval", value: "duration", set: func(o *options, v string) { o.recordInterval = parseDuration(v) }},
+		{long: "duration", value: "duration", set: func(o *options, v string) { o.recordDuration = parseDuration(v) }},
+		{long: "max-size", value: "MB", set: func(o *options, v string) { o.recordMaxSize = parseMegabytes(v) }},
+	}, help: "Append the guests' usage to a file as a JSON line every\n--interval (1m), for --duration or until stopped; the\nfile is rotated past --max-size MB (100)"},
+	{name: "report", args: []string{"file"}, help: "Print each guest's min/avg/max CPU and memory over a\nrecording"},
+}
+
+// parseDays reads the value of --older-than, -1 when it is not a whole
+// number of days
+func parseDays(v string) int {
+	days, err := strconv.Atoi(v)
+	if err != nil || days < 1 {
+		return -1
+	}
+	return days
+}
+
+// parseDuration reads the value of a duration flag, -1 when it is not a
+// positive duration such as 90s or 24h
+func parseDuration(v string) time.Duration {
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return -1
+	}
+	return d
+}
+
+// parseMegabytes reads the value of --max-size, -1 when it is not a
+// whole number of megabytes
+func parseMegabytes(v string) int {
+	mb, err := strconv.Atoi(v)
+	if err != nil || mb < 1 {
+		return -1
+	}
+	return mb
+}
+
+// lookupLong returns the flag called name after --, and the command it
+// belongs to when only one command takes it
+func lookupLong(name string) (flagSpec, string, bool) {
+	for _, f := range cliFlags {
+		if f.long == name {
+			return f, "", true
+		}
+	}
+	for _, c := range cliCommands {
+		for _, f := range c.flags {
+			if f.long == name {
+				return f, c.name, true
+			}
+		}
+	}
+	return flagSpec{}, "", false
+}
+
+// lookupShort returns the flag called c after -
+func lookupShort(c byte) (flagSpec, bool) {
+	for _, f := range cliFlags {
+		if f.short != 0 && f.short == c {
+			return f, true
+		}
+	}
+	return flagSpec{}, false
+}
+
+// parseArgs parses the command line, without the program name. Flags may
+// come before or after the command, in any of these forms:
+//
+//	--config file, --config=file, -c file, -cfile
+//	-vh (several short switches at once)
+//
+// A short flag taking a value takes the rest of its group, so -cv reads
+// the file "v", as getopt does. A single dash before a long name, as in
+// -no-color, is still accepted. "--" ends the flags.
+func parseArgs(args []string) (options, error) {
+	var opts options
+	var positional []string
+	commandFlags := make(map[string]string) // Command flag given -> its command
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// value returns the argument of flag f, inline or the next one
+		value := func(f flagSpec, inline string, hasInline bool) (string, error) {
+			if hasInline {
+				return inline, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("flag %s needs a %s", flagName(f), f.value)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch {
+		case arg == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(arg, "--") || (strings.HasPrefix(arg, "-") && len(arg) > 2 && isLongFlag(arg[1:])):
+			name, inline, hasInline := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			f, command, ok := lookupLong(name)
+			if !ok {
+				return options{}, fmt.Errorf("unknown flag %s", arg)
+			}
+			if command != "" {
+				commandFlags[f.long] = command
+			}
+			if f.value == "" {
+				if hasInline {
+					return options{}, fmt.Errorf("flag --%s takes no value", f.long)
+				}
+				f.set(&opts, "")
+				continue
+			}
+			v, err := value(f, inline, hasInline)
+			if err != nil {
+				return options{}, err
+			}
+			f.set(&opts, v)
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			group := arg[1:]
+			for j := 0; j < len(group); j++ {
+				f, ok := lookupShort(group[j])
+				if !ok {
+					return options{}, fmt.Errorf("unknown flag -%c in %s", group[j], arg)
+				}
+				if f.value == "" {
+					f.set(&opts, "")
+					continue
+				}
+				rest := group[j+1:]
+				v, err := value(f, rest, rest != "")
+				if err != nil {
+					return options{}, err
+				}
+				f.set(&opts, v)
+				break
+			}
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if opts.jsonVersion && !opts.showVersion {
+		return options{}, fmt.Errorf("flag --json only applies to --version")
+	}
+	if opts.onceFormat != "" && !opts.once {
+		return options{}, fmt.Errorf("flag --format only applies to --once")
+	}
+	if opts.onceFormat != "" && !slices.Contains(onceFormats, opts.onceFormat) {
+		return options{}, fmt.Errorf("flag --format takes one of %s", strings.Join(onceFormats, ", "))
+	}
+	if opts.showHelp || opts.showVersion {
+		opts.args = positional
+		return opts, nil
+	}
+	for flag, command := range commandFlags {
+		if len(positional) == 0 || positional[0] != command {
+			return options{}, fmt.Errorf("flag --%s only applies to %s", flag, command)
+		}
+	}
+	if opts.once && len(positional) > 0 {
+		return options{}, fmt.Errorf("flag --once runs no command")
+	}
+	if len(positional) > 0 {
+		if err := checkCommand(positional); err != nil {
+			return options{}, err
+		}
+		// update can't replace the binary, so it only checks
+		if positional[0] == "update" && !opts.updateCheck {
+			return options{}, fmt.Errorf("usage: pvec update --check")
+		}
+		if opts.olderThan < 0 {
+			return options{}, fmt.Errorf("flag --older-than needs a number of days, 1 or more")
+		}
+		// Deleting every snapshot pvec ever took is too easy a mistake
+		if opts.deleteSnapshots && opts.olderThan == 0 {
+			return options{}, fmt.Errorf("flag --delete needs --older-than")
+		}
+		if opts.recordInterval < 0 || opts.recordDuration < 0 {
+			return options{}, fmt.Errorf("flags --interval and --duration need a duration such as 90s or 24h")
+		}
+		if opts.recordMaxSize < 0 {
+			return options{}, fmt.Errorf("flag --max-size needs a number of MB, 1 or more")
+		}
+		if positional[0] == "record" && opts.recordOut == "" {
+			return options{}, fmt.Errorf("usage: pvec record --out <file> [--interval <duration>] [--duration <duration>] [--max-size <MB>]")
+		}
+	}
+	opts.args = positional
+	return opts, nil
+}
+
+// isLongFlag reports whether name, with any =value, names a long flag
+func isLongFlag(name string) bool {
+	name, _, _ = strings.Cut(name, "=")
+	_, _, ok := lookupLong(name)
+	return ok
+}
+
+// checkCommand checks that args start with a known command and give it
+// the arguments it takes
+func checkCommand(args []string) error {
+	for _, c := range cliCommands {
+		if c.name != args[0] {
+			continue
+		}
+		if len(args)-1 != len(c.args) {
+			return fmt.Errorf("usage: pvec %s", commandUsage(c))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// flagName returns how a flag is written in messages
+func flagName(f flagSpec) string {
+	if f.short != 0 {
+		return fmt.Sprintf("-%c/--%s", f.short, f.long)
+	}
+	return "--" + f.long
+}
+
+// commandUsage returns a command with its arguments and flags, e.g.
+// "wake <node>"
+func commandUsage(c commandSpec) string {
+	usage := c.name
+	for _, arg := range c.args {
+		usage += " <" + arg + ">"
+	}
+	for _, f := range c.flags {
+		usage += " --" + f.long
+		if f.value != "" {
+			usage += " <" + f.value + ">"
+		}
+	}
+	return usage
+}
+
+// usageColumn is the width of the flag and command names in the usage
+const usageColumn = 22
+
+// usage writes the help text: the flags, the commands and the files
+// merged without -c
+func usage(w io.Writer, layers []string) {
+	fmt.Fprintf(w, "Usage: pvec [options] [command]\n")
+	fmt.Fprintf(w, "A terminal-based interface for managing Proxmox VMs and Containers\n\n")
+	fmt.Fprintf(w, "Options:\n")
+	for _, f := range cliFlags {
+		name := "    --" + f.long
+		if f.short != 0 {
+			name = fmt.Sprintf("-%c, --%s", f.short, f.long)
+		}
+		if f.value != "" {
+			name += " <" + f.value + ">"
+		}
+		writeUsageEntry(w, name, f.help)
+	}
+	fmt.Fprintf(w, "\nCommands:\n")
+	for _, c := range cliCommands {
+		writeUsageEntry(w, commandUsage(c), c.help)
+	}
+	if len(layers) > 0 {
+		fmt.Fprintf(w, "\nWithout -c, these configuration files are merged, the last winning:\n")
+		for _, layer := range layers {
+			fmt.Fprintf(w, "  %s\n", layer)
+		}
+	}
+}
+
+// writeUsageEntry writes a name and its help, indenting the help's
+// further lines under the first
+func writeUsageEntry(w io.Writer, name, help string) {
+	lines := strings.Split(help, "\n")
+	// A name too long for the column gets the help under it
+	if len(name) > usageColumn {
+		fmt.Fprintf(w, "  %s\n", name)
+		for _, line := range lines {
+			fmt.Fprintf(w, "  %-*s %s\n", usageColumn, "", line)
+		}
+		return
+	}
+	fmt.Fprintf(w, "  %-*s %s\n", usageColumn, name, lines[0])
+	for _, line := range lines[1:] {
+		fmt.Fprintf(w, "  %-*s %s\n", usageColumn, "", line)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/version"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want options
+	}{
+		{"no arguments", nil, options{}},
+		{"short flag with value", []string{"-c", "foo"}, options{configPath: "foo"}},
+		{"long flag with value", []string{"--config", "foo"}, options{configPath: "foo"}},
+		{"long flag with inline value", []string{"--config=foo"}, options{configPath: "foo"}},
+		{"short flag with attached value", []string{"-cfoo"}, options{configPath: "foo"}},
+		{"value flag takes the rest of the group", []string{"-cv"}, options{configPath: "v"}},
+		{"combined switches", []string{"-vh"}, options{showVersion: true, showHelp: true}},
+		{"switches before a value flag", []string{"-hc", "foo"}, options{showHelp: true, configPath: "foo"}},
+		{"single dash long flag", []string{"-no-color", "-config=foo"}, options{noColor: true, configPath: "foo"}},
+		{"fixture implies demo", []string{"--fixture", "f.json"}, options{fixture: "f.json", demo: true}},
+		{"filters", []string{"--node", "pve1", "--filter=web", "--fail-fast"},
+			options{node: "pve1", filter: "web", failFast: true}},
+		{"command", []string{"wake", "pve1"}, options{args: []string{"wake", "pve1"}}},
+		{"guest command", []string{"shutdown", "web-1"}, options{args: []string{"shutdown", "web-1"}}},
+		{"flags after the command", []string{"permissions", "-c", "foo"},
+			options{configPath: "foo", args: []string{"permissions"}}},
+		{"command flag", []string{"update", "--check"}, options{updateCheck: true, args: []string{"update"}}},
+		{"command flag with a value", []string{"snapshots", "--older-than=7", "--delete"},
+			options{olderThan: 7, deleteSnapshots: true, args: []string{"snapshots"}}},
+		{"record", []string{"record", "--out", "c.jsonl", "--interval=90s", "--duration", "24h", "--max-size", "10"},
+			options{recordOut: "c.jsonl", recordInterval: 90 * time.Second, recordDuration: 24 * time.Hour,
+				recordMaxSize: 10, args: []string{"record"}}},
+		{"report", []string{"report", "c.jsonl"}, options{args: []string{"report", "c.jsonl"}}},
+		{"double dash ends the flags", []string{"--", "wake", "-x"}, options{args: []string{"wake", "-x"}}},
+		{"once", []string{"--once", "--format=tmux"}, options{once: true, onceFormat: "tmux"}},
+		{"version", []string{"--version"}, options{showVersion: true}},
+		{"version as JSON", []string{"-v", "--json"}, options{showVersion: true, jsonVersion: true}},
+		{"help skips the command check", []string{"-h", "nope"}, options{showHelp: true, args: []string{"nope"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseArgs(tt.args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseArgs_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"unknown long flag", []string{"--colour"}, "unknown flag --colour"},
+		{"unknown short flag", []string{"-x"}, "unknown flag -x in -x"},
+		{"unknown flag in a group", []string{"-vq"}, "unknown flag -q in -vq"},
+		{"missing value", []string{"-c"}, "flag -c/--config needs a file"},
+		{"missing long value", []string{"--node"}, "flag --node needs a name"},
+		{"value on a switch", []string{"--demo=yes"}, "flag --demo takes no value"},
+		{"json without version", []string{"--json"}, "flag --json only applies to --version"},
+		{"format without once", []string{"--format", "json"}, "flag --format only applies to --once"},
+		{"unknown format", []string{"--once", "--format", "xml"}, "flag --format takes one of short, tmux, json"},
+		{"once with a command", []string{"--once", "monitor"}, "flag --once runs no command"},
+		{"command flag without its command", []string{"--check"}, "flag --check only applies to update"},
+		{"command flag on another command", []string{"doctor", "--check"}, "flag --check only applies to update"},
+		{"update without check", []string{"update"}, "usage: pvec update --check"},
+		{"days not a number", []string{"snapshots", "--older-than", "1w"}, "flag --older-than needs a number of days, 1 or more"},
+		{"delete every snapshot", []string{"snapshots", "--delete"}, "flag --delete needs --older-than"},
+		{"record without a file", []string{"record", "--interval", "1m"},
+			"usage: pvec record --out <file> [--interval <duration>] [--duration <duration>] [--max-size <MB>]"},
+		{"interval not a duration", []string{"record", "--out", "c.jsonl", "--interval", "60"},
+			"flags --interval and --duration need a duration such as 90s or 24h"},
+		{"negative duration", []string{"record", "--out", "c.jsonl", "--duration=-1h"},
+			"flags --interval and --duration need a duration such as 90s or 24h"},
+		{"size not a number", []string{"record", "--out", "c.jsonl", "--max-size", "1G"},
+			"flag --max-size needs a number of MB, 1 or more"},
+		{"report without a file", []string{"report"}, "usage: pvec report <file>"},
+		{"unknown command", []string{"frobnicate"}, `unknown command "frobnicate"`},
+		{"missing command argument", []string{"wake"}, "usage: pvec wake <node>"},
+		{"missing guest", []string{"stop"}, "usage: pvec stop <guest>"},
+		{"extra command argument", []string{"doctor", "now"}, "usage: pvec doctor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseArgs(tt.args)
+			if err == nil {
+				t.Fatalf("Expected an error for %v", tt.args)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Expected error %q, got %q", tt.want, err.Error())
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	var buf bytes.Buffer
+	usage(&buf, []string{"/etc/pvec/config.json", "/home/u/.pvecrc"})
+	out := buf.String()
+
+	for _, want := range []string{
+		"-c, --config <file>",
+		"--fixture <file>",
+		"-v, --version",
+		"wake <node>",
+		"shutdown <guest>",
+		"doctor",
+		"monitor",
+		"/home/u/.pvecrc",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in the usage:\n%s", want, out)
+		}
+	}
+	// Every flag is documented once, from the same table parseArgs reads
+	for _, f := range cliFlags {
+		if n := strings.Count(out, "--"+f.long+" ") + strings.Count(out, "--"+f.long+"\n"); n != 1 {
+			t.Errorf("Expected --%s once in the usage, found %d", f.long, n)
+		}
+	}
+}
+
+func TestPrintVersion(t *testing.T) {
+	info := version.Info{Version: "v1.4.0", Commit: "abc123", GoVersion: "go1.24.1", Platform: "linux/amd64"}
+
+	var buf bytes.Buffer
+	if err := printVersion(&buf, info, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "pvec version v1.4.0 (commit abc123, go1.24.1, linux/amd64)\n"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	buf.Reset()
+	if err := printVersion(&buf, info, true); err != nil {
+		t.Fatal(err)
+	}
+	var decoded version.Info
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected JSON, got %q: %v", buf.String(), err)
+	}
+	if decoded != info {
+		t.Errorf("Expected %+v, got %+v", info, decoded)
+	}
+}
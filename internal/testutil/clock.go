@@ -0,0 +1,196 @@
+// Package testutil holds what the tests of several packages share. It is
+// internal and only imported from tests.
+package testutil
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/clock"
+)
+
+// FakeClock is a clock.Clock that only moves when told to. Advance fires
+// the timers and ticks falling due in order, each at its own time, so the
+// code under test sees the same sequence as with the wall clock.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // Signalled when a timer or ticker is added
+	now     time.Time
+	timers  []*fakeTimer
+	tickers []*FakeTicker
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFakeClock returns a fake clock reading now
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time the clock was moved to
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel receiving the time once the clock is moved d
+// forward, at once when d isn't positive
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, &fakeTimer{at: c.now.Add(d), ch: ch})
+	c.cond.Broadcast()
+	return ch
+}
+
+// NewTicker returns a ticker ticking every d of fake time. Like
+// time.NewTicker, it panics when d isn't positive.
+func (c *FakeClock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &FakeTicker{clock: c, ch: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock d forward, firing what falls due on the way
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	target := c.now.Add(d)
+	for {
+		at, fire := c.nextEvent(target)
+		if fire == nil {
+			break
+		}
+		c.now = at
+		fire()
+	}
+	c.now = target
+}
+
+// Set moves the clock to t. Moving it forward is an Advance; moving it
+// back fires nothing, and pending timers wait for the clock to catch up.
+func (c *FakeClock) Set(t time.Time) {
+	if d := t.Sub(c.Now()); d > 0 {
+		c.Advance(d)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// Waiters returns the number of pending timers and running tickers
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers) + len(c.tickers)
+}
+
+// BlockUntil waits until n timers and tickers are pending, for a test to
+// move the clock only once the code under test waits on it
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers)+len(c.tickers) < n {
+		c.cond.Wait()
+	}
+}
+
+// nextEvent returns the earliest timer or tick due by target, and how to
+// fire it, or a nil func when none is. Must be called with mu held.
+func (c *FakeClock) nextEvent(target time.Time) (time.Time, func()) {
+	var at time.Time
+	var fire func()
+	for i, t := range c.timers {
+		if !t.at.After(target) && (fire == nil || t.at.Before(at)) {
+			at, fire = t.at, func() {
+				t.ch <- t.at
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			}
+		}
+	}
+	for _, t := range c.tickers {
+		if !t.next.After(target) && (fire == nil || t.next.Before(at)) {
+			at, fire = t.next, t.tick
+		}
+	}
+	return at, fire
+}
+
+// FakeTicker is the ticker of a FakeClock
+type FakeTicker struct {
+	clock  *FakeClock
+	ch     chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+// C returns the channel of the ticks
+func (t *FakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+// Reset restarts the ticker with the period d from the clock's time
+func (t *FakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.period = d
+	t.next = t.clock.now.Add(d)
+	if !t.running() {
+		t.clock.tickers = append(t.clock.tickers, t)
+	}
+}
+
+// Stop stops the ticks; a tick already sent stays in the channel
+func (t *FakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, other := range t.clock.tickers {
+		if other == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+// running reports whether the ticker ticks. Must be called with the
+// clock's mu held.
+func (t *FakeTicker) running() bool {
+	for _, other := range t.clock.tickers {
+		if other == t {
+			return true
+		}
+	}
+	return false
+}
+
+// tick sends a tick unless the last one is still unread, as a real
+// ticker drops ticks for slow receivers. Must be called with the clock's
+// mu held.
+func (t *FakeTicker) tick() {
+	select {
+	case t.ch <- t.next:
+	default:
+	}
+	t.next = t.next.Add(t.period)
+}
@@ -0,0 +1,114 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+// received returns what ch holds without waiting
+func received(ch <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-ch:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeClock_After(t *testing.T) {
+	c := NewFakeClock(start)
+	ch := c.After(time.Minute)
+	assert.Equal(t, 1, c.Waiters())
+
+	c.Advance(59 * time.Second)
+	_, ok := received(ch)
+	assert.False(t, ok, "Not due yet")
+
+	c.Advance(time.Second)
+	at, ok := received(ch)
+	require.True(t, ok)
+	assert.Equal(t, start.Add(time.Minute), at)
+	assert.Zero(t, c.Waiters())
+
+	at, ok = received(c.After(0))
+	assert.True(t, ok, "A zero wait fires at once")
+	assert.Equal(t, start.Add(time.Minute), at)
+}
+
+func TestFakeClock_Ticker(t *testing.T) {
+	c := NewFakeClock(start)
+	ticker := c.NewTicker(10 * time.Second)
+
+	c.Advance(35 * time.Second)
+	at, ok := received(ticker.C())
+	require.True(t, ok)
+	assert.Equal(t, start.Add(10*time.Second), at, "Unread ticks are dropped, as with a real ticker")
+	_, ok = received(ticker.C())
+	assert.False(t, ok)
+
+	ticker.Reset(time.Minute)
+	c.Advance(30 * time.Second)
+	_, ok = received(ticker.C())
+	assert.False(t, ok, "Reset starts a new period from now")
+	c.Advance(30 * time.Second)
+	at, ok = received(ticker.C())
+	require.True(t, ok)
+	assert.Equal(t, start.Add(95*time.Second), at)
+
+	ticker.Stop()
+	c.Advance(time.Hour)
+	_, ok = received(ticker.C())
+	assert.False(t, ok)
+	assert.Zero(t, c.Waiters())
+}
+
+func TestFakeClock_FiresInOrder(t *testing.T) {
+	c := NewFakeClock(start)
+	late := c.After(time.Minute)
+	ticker := c.NewTicker(45 * time.Second)
+	early := c.After(30 * time.Second)
+
+	c.Advance(2 * time.Minute)
+	for _, want := range []struct {
+		ch <-chan time.Time
+		at time.Duration
+	}{{early, 30 * time.Second}, {ticker.C(), 45 * time.Second}, {late, time.Minute}} {
+		at, ok := received(want.ch)
+		require.True(t, ok)
+		assert.Equal(t, start.Add(want.at), at)
+	}
+	assert.Equal(t, start.Add(2*time.Minute), c.Now())
+}
+
+func TestFakeClock_Set(t *testing.T) {
+	c := NewFakeClock(start)
+	ch := c.After(time.Minute)
+	c.Set(start.Add(-time.Hour))
+	assert.Equal(t, start.Add(-time.Hour), c.Now())
+	_, ok := received(ch)
+	assert.False(t, ok, "Moving back fires nothing")
+
+	c.Set(start.Add(time.Hour))
+	_, ok = received(ch)
+	assert.True(t, ok)
+}
+
+func TestFakeClock_BlockUntil(t *testing.T) {
+	c := NewFakeClock(start)
+	done := make(chan time.Time)
+	go func() { done <- <-c.After(time.Second) }()
+
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	select {
+	case at := <-done:
+		assert.Equal(t, start.Add(time.Second), at)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The waiting goroutine should be woken")
+	}
+}
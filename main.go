@@ -1,44 +1,77 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/tsupplis/pvec/pkg/config"
-	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/crash"
+	"github.com/tsupplis/pvec/pkg/demo"
+	"github.com/tsupplis/pvec/pkg/doctor"
+	"github.com/tsupplis/pvec/pkg/hooks"
+	"github.com/tsupplis/pvec/pkg/probe"
 	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/state"
+	"github.com/tsupplis/pvec/pkg/ui/format"
 	"github.com/tsupplis/pvec/pkg/ui/mainlist"
+	"github.com/tsupplis/pvec/pkg/update"
+	"github.com/tsupplis/pvec/pkg/version"
 )
 
-// parseFlags handles command-line flags and returns the config file path
-func parseFlags() string {
-	var showVersion bool
-	flag.BoolVar(&showVersion, "v", false, "Show version information")
-	flag.BoolVar(&showVersion, "version", false, "Show version information")
-
-	configPath := flag.String("c", "", "Path to configuration file")
-	flag.StringVar(configPath, "config", "", "Path to configuration file")
+// options holds the parsed command-line flags
+type options struct {
+	configPath      string // Empty: merge the system, user and project files
+	noColor         bool
+	demo            bool          // Use the offline demo backend instead of a server
+	fixture         string        // Demo fixture file; empty for the built-in one
+	node            string        // Node filter, overriding default_node_filter
+	filter          string        // Text filter, overriding default_text_filter
+	failFast        bool          // Exit if the first refresh fails
+	showHelp        bool          // Print the usage and exit
+	showVersion     bool          // Print the version and exit
+	jsonVersion     bool          // Print the version as JSON
+	updateCheck     bool          // pvec update --check
+	olderThan       int           // pvec snapshots --older-than, in days; 0 for any age, -1 if invalid
+	deleteSnapshots bool          // pvec snapshots --delete
+	recordOut       string        // pvec record --out
+	recordInterval  time.Duration // pvec record --interval; 0 for the default, -1 if invalid
+	recordDuration  time.Duration // pvec record --duration; 0 until stopped, -1 if invalid
+	recordMaxSize   int           // pvec record --max-size, in MB; 0 for the default, -1 if invalid
+	once            bool          // Print a summary line and exit
+	onceFormat      string        // --format of --once; empty for short
+	args            []string      // Subcommand and its arguments; empty to run the TUI
+}
 
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: pvec [options]\n")
-		fmt.Fprintf(os.Stderr, "A terminal-based interface for managing Proxmox VMs and Containers\n\n")
-		fmt.Fprintf(os.Stderr, "Options:\n")
-		fmt.Fprintf(os.Stderr, "  -c, --config   Path to configuration file (default: ~/.pvecrc)\n")
-		fmt.Fprintf(os.Stderr, "  -v, --version  Show version information\n")
-		fmt.Fprintf(os.Stderr, "  -h, --help     Print this help\n")
+// layerPaths names the configuration files merged without -c
+func layerPaths() []string {
+	paths := make([]string, 0, 3)
+	for _, layer := range config.DefaultLayers() {
+		paths = append(paths, layer.Path)
 	}
+	return paths
+}
 
-	flag.Parse()
-
-	if showVersion {
-		fmt.Println("pvec version 1.0.0")
-		os.Exit(0)
+// printVersion writes the build information for pvec -v, as one line or
+// as JSON for scripts
+func printVersion(w io.Writer, info version.Info, asJSON bool) error {
+	if !asJSON {
+		_, err := fmt.Fprintln(w, info)
+		return err
 	}
-
-	return getConfigPath(*configPath)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(info)
 }
 
 // getConfigPath returns the configuration file path, using default if not provided
@@ -47,48 +80,345 @@ func getConfigPath(configPath string) string {
 		return configPath
 	}
 
-	home, err := os.UserHomeDir()
+	configPath = config.DefaultPath()
+	if configPath == "" {
+		log.Fatalf("Failed to determine the default configuration path; use -c to specify one")
+	}
+	return configPath
+}
+
+// settingSource returns the file a setting was read from, or fallback
+// when the loader doesn't tell or the setting is a default
+func settingSource(loader config.Loader, key, fallback string) string {
+	if sources, ok := loader.(config.Sources); ok {
+		if source := sources.Source(key); source != "" {
+			return source
+		}
+	}
+	return fallback
+}
+
+// colorEnabled applies the NO_COLOR convention (https://no-color.org) and
+// the --no-color flag on top of the color config option
+func colorEnabled(configured, noColorFlag bool, noColorEnv string) bool {
+	return configured && !noColorFlag && noColorEnv == ""
+}
+
+// startupFilter combines the default filters of the configuration with
+// the --node and --filter flags, which take precedence
+func startupFilter(cfg *config.Config, opts options) mainlist.Filter {
+	filter := mainlist.Filter{
+		Node:   cfg.DefaultNodeFilter,
+		Status: cfg.DefaultStatusFilter,
+		Text:   cfg.DefaultTextFilter,
+	}
+	if opts.node != "" {
+		filter.Node = opts.node
+	}
+	if opts.filter != "" {
+		filter.Text = opts.filter
+	}
+	return filter
+}
+
+// demoConfig is used in demo mode when no configuration file can be loaded
+func demoConfig() *config.Config {
+	return &config.Config{
+		RefreshInterval: 5 * time.Second,
+		UseUnicode:      true,
+		Color:           true,
+		ConfigSweep:     true,
+
+		OvercommitCPUWarning: config.DefaultOvercommitCPUWarning,
+		OvercommitMemWarning: config.DefaultOvercommitMemWarning,
+	}
+}
+
+// getLogPath returns the log file path inside the user cache directory
+func getLogPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cacheDir, "pvec")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pvec.log"), nil
+}
+
+// openState opens the state file in the user cache directory. The demo
+// keeps its state in memory, and so does a run whose state file can't be
+// read: actions can still be scheduled, for that session only.
+func openState(demo bool) *state.File {
+	memory, _ := state.Open("")
+	if demo {
+		return memory
+	}
+	path, err := state.DefaultPath()
+	if err != nil {
+		log.Printf("no state file: %v", err)
+		return memory
+	}
+	f, err := state.Open(path)
 	if err != nil {
-		log.Fatalf("Failed to get home directory: %v", err)
+		log.Printf("ignoring the state file: %v", err)
+		return memory
 	}
-	return filepath.Join(home, ".pvecrc")
+	return f
+}
+
+// setupLogging sends log output to a file so it never draws over the TUI,
+// with secrets masked
+func setupLogging() func() {
+	path, err := getLogPath()
+	if err == nil {
+		if f, err := tea.LogToFile(path, "pvec"); err == nil {
+			log.SetOutput(redact.Writer(f))
+			return func() { _ = f.Close() }
+		}
+	}
+	log.SetOutput(io.Discard)
+	return func() {}
 }
 
 func main() {
-	cfgPath := parseFlags()
+	// Errors quoting a config file or a server response must not show the
+	// token secret, on the terminal or in the log
+	log.SetOutput(redact.Writer(os.Stderr))
+	stdout, stderr := redact.Writer(os.Stdout), redact.Writer(os.Stderr)
 
-	// Load configuration
+	opts, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(stderr, "pvec: %v\nRun 'pvec --help' for usage.\n", err)
+		os.Exit(2)
+	}
+	if opts.showHelp {
+		usage(stdout, layerPaths())
+		return
+	}
+	if opts.showVersion {
+		if err := printVersion(stdout, version.Get(), opts.jsonVersion); err != nil {
+			log.Fatalf("Failed to print the version: %v", err)
+		}
+		return
+	}
+	layered := opts.configPath == ""
+	cfgPath := getConfigPath(opts.configPath)
+
+	// Checking for a release needs no configuration
+	if len(opts.args) > 0 && opts.args[0] == "update" {
+		if err := runUpdateCheck(stdout, update.NewChecker(version.Get().Version)); err != nil {
+			fmt.Fprintf(stderr, "pvec: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// A report only reads the recording
+	if len(opts.args) > 0 && opts.args[0] == "report" {
+		err := func() error {
+			f, err := os.Open(opts.args[1])
+			if err != nil {
+				return err
+			}
+			defer func() { _ = f.Close() }()
+			return runReport(stdout, f)
+		}()
+		if err != nil {
+			fmt.Fprintf(stderr, "pvec: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The doctor checks the config file itself, so it runs before loading it
+	if len(opts.args) > 0 && opts.args[0] == "doctor" {
+		if !doctor.Run(context.Background(), stdout, cfgPath) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Load configuration: -c names exactly one file, otherwise the
+	// system, user and project files are merged
 	loader := config.NewLoader(cfgPath)
+	if layered {
+		loader = config.NewLoader("")
+	}
 	cfg, err := loader.Load()
+	if err != nil && opts.demo {
+		// The demo needs no server, only the display settings
+		cfg, err = demoConfig(), nil
+	}
 	if err != nil {
-		log.Fatalf("Failed to load configuration from %s: %v\nPlease create a .pvecrc file in your home directory or specify one with -c flag.", cfgPath, err)
+		log.Fatalf("Failed to load configuration from %s: %v\nPlease create it or specify another file with the -c flag.", cfgPath, err)
+	}
+
+	format.SetUnicode(cfg.UseUnicode)
+	format.SetColor(colorEnabled(cfg.Color, opts.noColor, os.Getenv("NO_COLOR")))
+
+	// A certificate the system doesn't trust must be decided on before
+	// connecting, by the user when there is one to ask
+	if !opts.demo {
+		headless := opts.once || (len(opts.args) > 0 && (opts.args[0] == "monitor" || opts.args[0] == "record"))
+		trust := tlsTrust{
+			in:          os.Stdin,
+			out:         stdout,
+			interactive: !headless && isTerminal(os.Stdin) && isTerminal(os.Stdout),
+			saver:       loader,
+			path:        cfgPath,
+		}
+		if err := trust.check(context.Background(), cfg); err != nil {
+			fmt.Fprintf(stderr, "pvec: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Create Proxmox client
-	client := proxmox.NewClient(cfg.APIUrl, cfg.GetAuthToken(), cfg.SkipTLSVerify)
+	var client proxmox.Client
+	var saver config.Saver = loader
+	var requests *proxmox.RequestLog // Kept for the debug screen, Ctrl+D
+	if opts.demo {
+		fc, err := demo.NewFileClient(opts.fixture)
+		if err != nil {
+			log.Fatalf("Failed to load demo data: %v", err)
+		}
+		client = fc
+		saver = nil // Saving would point the list back at the real server
+	} else {
+		var observer proxmox.RequestObserver
+		if cfg.RequestLogSize > 0 {
+			requests = proxmox.NewRequestLog(cfg.RequestLogSize)
+			observer = requests
+		}
+		client, err = mainlist.NewConfiguredClient(cfg, observer)
+		if err != nil {
+			log.Fatalf("Invalid configuration in %s: %v", settingSource(loader, "clusters", cfgPath), err)
+		}
+	}
+
+	// A status line for a prompt or tmux: one quick listing, no TUI
+	if opts.once {
+		if err := runOnce(stdout, client, onceLabel(cfg), opts.onceFormat, onceTimeout, time.Now); err != nil {
+			fmt.Fprintf(stderr, "pvec: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Create state change hook (no-op when on_state_change_cmd is unset)
+	stateHook, err := hooks.NewStateChangeRunner(cfg.OnStateChangeCmd, cfg.StateChangeFilter, nil)
+	if err != nil {
+		log.Fatalf("Invalid configuration in %s: %v", settingSource(loader, "state_change_filter", cfgPath), err)
+	}
+
+	// The monitor and the recorder run until systemd, or Ctrl+C, stops them
+	if len(opts.args) > 0 && (opts.args[0] == "monitor" || opts.args[0] == "record") {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		if opts.args[0] == "monitor" {
+			err = runMonitor(ctx, stdout, client, cfg, stateHook.Notify, os.Getenv)
+		} else {
+			err = runRecord(ctx, stdout, client, opts, cfg.RefreshTimeout)
+		}
+		stop()
+		if err != nil {
+			fmt.Fprintf(stderr, "pvec: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Create action executor
-	executor := proxmox.NewActionExecutor(client)
+	if len(opts.args) > 0 {
+		if err := runCommand(stdout, newBackend(client), opts); err != nil {
+			fmt.Fprintf(stderr, "pvec: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	closeLog := setupLogging()
+	defer closeLog()
+
+	// Colors degrade to the detected profile (plain text on consoles without
+	// ANSI support, such as legacy Windows conhost); log it to help triage
+	log.Printf("terminal color profile: %s", lipgloss.ColorProfile().Name())
 
 	// Create main list with refresh interval from config
 	listCfg := mainlist.Config{
-		RefreshInterval: cfg.RefreshInterval,
-		Provider:        client,
-		Client:          client,
+		RefreshInterval: cfg.IdleRefreshInterval(),
+		RefreshActive:   cfg.RefreshIntervalActive,
+		ActiveWindow:    cfg.RefreshActiveWindow,
+		RefreshTimeout:  cfg.RefreshTimeout,
+		FailFast:        opts.failFast,
+		Provider:        mainlist.NewConfiguredProvider(cfg, client),
+		Reader:          client,
+		Power:           client,
 		AppConfig:       cfg,
-		ConfigLoader:    loader,
-		OnNodesUpdated: func(nodes []*models.VMStatus) {
-			// Update executor cache when nodes are refreshed
-			if ae, ok := executor.(*proxmox.ActionExecutor); ok {
-				ae.UpdateNodes(nodes)
-			}
-		},
+		ConfigSaver:     saver,
+		OnStateChanges:  stateHook.Notify,
+		Filter:          startupFilter(cfg, opts),
+		ConfigSweep:     cfg.ConfigSweep,
+		State:           openState(opts.demo),
+		Requests:        requests,
+		Prober:          probe.New(cfg.NodeProbes),
+	}
+	if dir, err := crash.Dir(); err == nil {
+		listCfg.CrashDir = dir
+	}
+	// Opt-in, and never from the offline demo
+	if cfg.UpdateCheck && !opts.demo {
+		listCfg.UpdateCheck = releaseCheck(update.NewChecker(version.Get().Version))
+	}
+	// The demo backend has no nodes to power off
+	if nodePower, ok := client.(proxmox.NodePowerController); ok {
+		listCfg.NodePower = nodePower
+	}
+	if taskManager, ok := client.(proxmox.TaskManager); ok {
+		listCfg.Tasks = taskManager
+	}
+	if storageManager, ok := client.(proxmox.StorageManager); ok {
+		listCfg.Storage = storageManager
+	}
+	if permissionReader, ok := client.(proxmox.PermissionReader); ok {
+		listCfg.Permissions = permissionReader
+	}
+	if cloudInit, ok := client.(proxmox.CloudInitManager); ok {
+		listCfg.CloudInit = cloudInit
+	}
+	if locks, ok := client.(proxmox.LockManager); ok {
+		listCfg.Locks = locks
+	}
+	if ha, ok := client.(proxmox.HAManager); ok {
+		listCfg.HA = ha
+	}
+	if server, ok := client.(proxmox.ServerInspector); ok {
+		listCfg.Server = server
+	}
+	if serial, ok := client.(proxmox.SerialConsole); ok {
+		listCfg.Serial = serial
+	}
+	if snapshots, ok := client.(proxmox.SnapshotManager); ok {
+		listCfg.Snapshots = snapshots
+	}
+	if clones, ok := client.(proxmox.CloneManager); ok {
+		listCfg.Clones = clones
+	}
+	if organizer, ok := client.(proxmox.GuestOrganizer); ok {
+		listCfg.Organizer = organizer
+	}
+	// Several clusters merged into one list
+	if health, ok := client.(proxmox.ClusterHealth); ok {
+		listCfg.ClusterHealth = health
 	}
 	ml := mainlist.NewMainList(listCfg)
 	defer ml.Stop()
 
-	// Run Bubble Tea implementation (initial refresh happens in Init)
+	// Run Bubble Tea implementation (initial refresh happens in Init).
+	// The log goes to a file by now, so report a failure on stderr too.
 	if err := ml.Run(); err != nil {
-		log.Fatalf("Error running application: %v", err)
+		log.Printf("Error running application: %v", err)
+		fmt.Fprintf(stderr, "pvec: %v\n", err)
+		os.Exit(1)
 	}
 }
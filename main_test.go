@@ -3,7 +3,11 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/ui/mainlist"
 )
 
 func TestGetConfigPath_WithFlagProvided(t *testing.T) {
@@ -17,20 +21,45 @@ func TestGetConfigPath_WithFlagProvided(t *testing.T) {
 }
 
 func TestGetConfigPath_WithEmptyFlag(t *testing.T) {
-	// Test that when no flag is provided, it defaults to ~/.pvecrc
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		t.Fatalf("Failed to get user home directory: %v", err)
-	}
+	// Test that when no flag is provided, it defaults to ~/.pvecrc, or to
+	// the user config directory on Windows
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(homeDir, ".config"))
+	t.Setenv("USERPROFILE", homeDir)
+	t.Setenv("AppData", filepath.Join(homeDir, "AppData"))
 
 	result := getConfigPath("")
 	expected := filepath.Join(homeDir, ".pvecrc")
+	if runtime.GOOS == "windows" {
+		expected = filepath.Join(homeDir, "AppData", "pvec", "config.json")
+	}
 
 	if result != expected {
 		t.Errorf("Expected config path %s, got %s", expected, result)
 	}
 }
 
+func TestSettingSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pvec.json")
+	content := `{"api_url": "https://pve:8006", "token_id": "u@pam!t", "token_secret": "s", "state_change_filter": ["*->stopped"]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loader := config.NewLoader(path)
+	if _, err := loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := settingSource(loader, "state_change_filter", "fallback"); got != path {
+		t.Errorf("Expected %s, got %s", path, got)
+	}
+	if got := settingSource(loader, "on_state_change_cmd", "fallback"); got != "fallback" {
+		t.Errorf("An unset setting should use the fallback, got %s", got)
+	}
+}
+
 func TestGetConfigPath_WithRelativePath(t *testing.T) {
 	// Test that relative paths are accepted as-is
 	result := getConfigPath("./config/test.json")
@@ -40,3 +69,54 @@ func TestGetConfigPath_WithRelativePath(t *testing.T) {
 		t.Errorf("Expected config path %s, got %s", expected, result)
 	}
 }
+
+func TestColorEnabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		configured  bool
+		noColorFlag bool
+		noColorEnv  string
+		expected    bool
+	}{
+		{"default", true, false, "", true},
+		{"config off", false, false, "", false},
+		{"flag", true, true, "", false},
+		{"NO_COLOR set", true, false, "1", false},
+		{"NO_COLOR any value", true, false, "false", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := colorEnabled(tt.configured, tt.noColorFlag, tt.noColorEnv); got != tt.expected {
+				t.Errorf("colorEnabled() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDemoConfig(t *testing.T) {
+	cfg := demoConfig()
+	if cfg.RefreshInterval <= 0 {
+		t.Errorf("Demo refresh interval should be positive, got %v", cfg.RefreshInterval)
+	}
+	if !cfg.UseUnicode || !cfg.Color {
+		t.Error("Demo should use the default display settings")
+	}
+}
+
+func TestStartupFilter(t *testing.T) {
+	cfg := &config.Config{DefaultNodeFilter: "pve1", DefaultStatusFilter: "running", DefaultTextFilter: "web"}
+
+	got := startupFilter(cfg, options{})
+	want := mainlist.Filter{Node: "pve1", Status: "running", Text: "web"}
+	if got != want {
+		t.Errorf("Expected the configured filters %+v, got %+v", want, got)
+	}
+
+	// Flags override the configuration for one launch
+	got = startupFilter(cfg, options{node: "pve2", filter: "db"})
+	want = mainlist.Filter{Node: "pve2", Status: "running", Text: "db"}
+	if got != want {
+		t.Errorf("Expected the flags to take precedence %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// onceTimeout bounds --once as a whole: a status line that hangs stalls
+// the shell prompt or tmux status bar waiting on it
+const onceTimeout = 3 * time.Second
+
+// onceFormats are the values --format takes
+var onceFormats = []string{"short", "tmux", "json"}
+
+// onceCluster counts the guests of one cluster for --once
+type onceCluster struct {
+	Name    string  `json:"name"`
+	Running int     `json:"running"`
+	Total   int     `json:"total"`
+	Stale   int     `json:"stale"` // On an offline node or an unreachable cluster
+	CPU     float64 `json:"cpu"`   // Percent, over the running guests, weighted by their vCPUs
+
+	cpuWeight float64
+}
+
+// onceSummary is what --once prints, with the time the list was read so
+// a consumer caching the output can tell how fresh it is
+type onceSummary struct {
+	Time     time.Time     `json:"time"`
+	Running  int           `json:"running"`
+	Total    int           `json:"total"`
+	Stale    int           `json:"stale"`
+	CPU      float64       `json:"cpu"`
+	Clusters []onceCluster `json:"clusters"`
+}
+
+// runOnce lists the guests a single time and prints one line summing
+// them up in the given format, for shell prompts and status bars. It
+// gives up after timeout even if the request doesn't, and prints nothing
+// when it fails, so the exit status tells the consumer whether there is
+// a current line to show. label names a single cluster; several are
+// named by their own names.
+func runOnce(w io.Writer, lister guestLister, label, format string, timeout time.Duration, now func() time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type result struct {
+		guests []*models.VMStatus
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		guests, err := lister.GetNodes(ctx)
+		done <- result{guests, err}
+	}()
+	var guests []*models.VMStatus
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("no answer within %s", timeout)
+	case r := <-done:
+		if r.err != nil {
+			return fmt.Errorf("failed to list guests: %w", r.err)
+		}
+		guests = r.guests
+	}
+
+	summary := summarizeOnce(guests, label)
+	summary.Time = now().UTC()
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(summary)
+	case "tmux":
+		lines := make([]string, len(summary.Clusters))
+		for i, c := range summary.Clusters {
+			// tmux reads #[...] and #(...) in a status line; ## is a plain #
+			lines[i] = strings.ReplaceAll(c.Name+": "+onceCounts(c.Running, c.Total, c.Stale, c.CPU), "#", "##")
+		}
+		_, err := fmt.Fprintln(w, strings.Join(lines, " | "))
+		return err
+	default:
+		_, err := fmt.Fprintln(w, onceCounts(summary.Running, summary.Total, summary.Stale, summary.CPU))
+		return err
+	}
+}
+
+// onceCounts renders the counts of a summary line, e.g.
+// "18/23 up, cpu 34%"
+func onceCounts(running, total, stale int, cpu float64) string {
+	text := fmt.Sprintf("%d/%d up, cpu %.0f%%", running, total, cpu)
+	if stale > 0 {
+		text += fmt.Sprintf(", %d stale", stale)
+	}
+	return text
+}
+
+// summarizeOnce counts the guests per cluster, in the order the clusters
+// first appear, and over all of them
+func summarizeOnce(guests []*models.VMStatus, label string) onceSummary {
+	var summary onceSummary
+	index := make(map[string]int)
+	var weight float64
+	for _, g := range guests {
+		name := g.Cluster
+		if name == "" {
+			name = label
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(summary.Clusters)
+			index[name] = i
+			summary.Clusters = append(summary.Clusters, onceCluster{Name: name})
+		}
+		c := &summary.Clusters[i]
+		c.Total++
+		summary.Total++
+		if g.NodeOffline || g.Unreachable {
+			c.Stale++
+			summary.Stale++
+			continue
+		}
+		if !g.IsRunning() {
+			continue
+		}
+		c.Running++
+		summary.Running++
+		vcpus := 1.0
+		if g.IsKnown(models.MetricMaxCPU) && g.MaxCPU > 0 {
+			vcpus = float64(g.MaxCPU)
+		}
+		c.CPU += g.CPUUsage * vcpus
+		c.cpuWeight += vcpus
+		summary.CPU += g.CPUUsage * vcpus
+		weight += vcpus
+	}
+	for i := range summary.Clusters {
+		c := &summary.Clusters[i]
+		if c.cpuWeight > 0 {
+			c.CPU = roundPercent(c.CPU / c.cpuWeight)
+		}
+	}
+	if weight > 0 {
+		summary.CPU = roundPercent(summary.CPU / weight)
+	}
+	if summary.Clusters == nil {
+		summary.Clusters = []onceCluster{{Name: label}}
+	}
+	return summary
+}
+
+// roundPercent keeps one decimal of a percentage
+func roundPercent(p float64) float64 {
+	return math.Round(p*10) / 10
+}
+
+// onceLabel names a single cluster in --once output: the first label of
+// its API host name, such as pve for https://pve.example.com:8006, or
+// "pve" when there is no host, as in the demo
+func onceLabel(cfg *config.Config) string {
+	u, err := url.Parse(cfg.APIUrl)
+	if err != nil || u.Hostname() == "" {
+		return "pve"
+	}
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	name, _, _ := strings.Cut(host, ".")
+	return name
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// onceServer answers cluster/resources with four guests, three running
+func onceServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api2/json/cluster/resources" {
+			t.Errorf("Unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[
+			{"type":"qemu","vmid":100,"name":"web","node":"pve1","status":"running","cpu":0.5,"maxcpu":4},
+			{"type":"qemu","vmid":101,"name":"db","node":"pve1","status":"running","cpu":0.1,"maxcpu":2},
+			{"type":"lxc","vmid":200,"name":"dns","node":"pve1","status":"running","cpu":0.1,"maxcpu":2},
+			{"type":"lxc","vmid":201,"name":"old","node":"pve1","status":"stopped","cpu":0,"maxcpu":1}
+		]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+var onceTime = time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+
+func TestRunOnce(t *testing.T) {
+	client := proxmox.NewClient(onceServer(t).URL, "token", true)
+	now := func() time.Time { return onceTime }
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"short", "3/4 up, cpu 30%\n"},
+		{"tmux", "pve: 3/4 up, cpu 30%\n"},
+		{"json", `{"time":"2026-10-17T09:30:00Z","running":3,"total":4,"stale":0,"cpu":30,` +
+			`"clusters":[{"name":"pve","running":3,"total":4,"stale":0,"cpu":30}]}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := runOnce(&out, client, "pve", tt.format, time.Second, now); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunOnce_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	var out bytes.Buffer
+	start := time.Now()
+	err := runOnce(&out, proxmox.NewClient(server.URL, "token", true), "pve", "tmux", 50*time.Millisecond, time.Now)
+	if err == nil || err.Error() != "no answer within 50ms" {
+		t.Errorf("Expected the timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected to give up after 50ms, took %s", elapsed)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing printed, got %q", out.String())
+	}
+}
+
+// hangingLister ignores its context, as a stuck request could
+type hangingLister struct{ release chan struct{} }
+
+func (h *hangingLister) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	<-h.release
+	return nil, nil
+}
+
+func TestRunOnce_TimeoutWithoutContext(t *testing.T) {
+	lister := &hangingLister{release: make(chan struct{})}
+	defer close(lister.release)
+
+	err := runOnce(&bytes.Buffer{}, lister, "pve", "short", 20*time.Millisecond, time.Now)
+	if err == nil || !strings.Contains(err.Error(), "no answer within") {
+		t.Errorf("Expected the timeout even though the lister ignores it, got %v", err)
+	}
+}
+
+func TestRunOnce_Failed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"data":null}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	err := runOnce(&out, proxmox.NewClient(server.URL, "token", true), "pve", "json", time.Second, time.Now)
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to list guests: ") {
+		t.Errorf("Expected the listing error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing printed, got %q", out.String())
+	}
+}
+
+func TestRunOnce_Clusters(t *testing.T) {
+	guests := []*models.VMStatus{
+		{VMID: "100", Cluster: "lab#1", Status: models.StateRunning, CPUUsage: 40},
+		{VMID: "100", Cluster: "prod", Status: models.StateRunning, CPUUsage: 10, Unreachable: true},
+		{VMID: "101", Cluster: "prod", Status: models.StateStopped},
+	}
+	lister := &fakePermissions{guests: guests}
+	var out bytes.Buffer
+	if err := runOnce(&out, lister, "pve", "tmux", time.Second, time.Now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, want := out.String(), "lab##1: 1/1 up, cpu 40% | prod: 0/2 up, cpu 0%, 1 stale\n"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	out.Reset()
+	if err := runOnce(&out, lister, "pve", "short", time.Second, time.Now); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, want := out.String(), "1/3 up, cpu 40%, 1 stale\n"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if got := summarizeOnce(nil, "pve"); len(got.Clusters) != 1 || got.Clusters[0].Name != "pve" {
+		t.Errorf("Expected the label for an empty list, got %+v", got.Clusters)
+	}
+}
+
+func TestOnceLabel(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://pve.example.com:8006/api2/json": "pve",
+		"https://10.0.0.5:8006":                  "10.0.0.5",
+		"":                                       "pve",
+	} {
+		if got := onceLabel(&config.Config{APIUrl: url}); got != want {
+			t.Errorf("Expected %q for %q, got %q", want, url, got)
+		}
+	}
+}
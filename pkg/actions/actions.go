@@ -2,6 +2,7 @@ package actions
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/tsupplis/pvec/pkg/models"
@@ -17,33 +18,47 @@ type Action interface {
 	Description() string
 }
 
-// Executor is the interface for executing actions on Proxmox nodes
+// Executor is the interface for executing actions on Proxmox nodes. The
+// vmid is the guest's models.VMStatus.Key, its VMID for a single cluster.
 type Executor interface {
 	Start(ctx context.Context, vmid string) error
 	Shutdown(ctx context.Context, vmid string) error
 	Reboot(ctx context.Context, vmid string) error
 	Stop(ctx context.Context, vmid string) error
+	Resume(ctx context.Context, vmid string) error
 }
 
+// Hibernator is implemented by executors that can suspend a VM to disk
+type Hibernator interface {
+	Hibernate(ctx context.Context, vmid string) error
+}
+
+// ErrHibernateUnsupported is returned when hibernating through an
+// executor that can't
+var ErrHibernateUnsupported = errors.New("hibernation is not supported")
+
 // BaseAction provides common functionality for all actions
 type BaseAction struct {
-	VMID     string
+	VMID     string // The guest's Key, which names its cluster when several are listed
 	VMName   string
 	Executor Executor
 }
 
-// StartAction starts a stopped VM or container
+// StartAction starts a stopped VM or container, or resumes a hibernated
+// VM from disk
 type StartAction struct {
 	BaseAction
+	FromDisk bool // The VM is hibernated
 }
 
 func NewStartAction(executor Executor, node *models.VMStatus) *StartAction {
 	return &StartAction{
 		BaseAction: BaseAction{
-			VMID:     node.VMID,
+			VMID:     node.Key(),
 			VMName:   node.Name,
 			Executor: executor,
 		},
+		FromDisk: node.Status == models.StateHibernated,
 	}
 }
 
@@ -52,10 +67,16 @@ func (a *StartAction) Execute(ctx context.Context) error {
 }
 
 func (a *StartAction) Name() string {
+	if a.FromDisk {
+		return "Resume from disk"
+	}
 	return "Start"
 }
 
 func (a *StartAction) Description() string {
+	if a.FromDisk {
+		return fmt.Sprintf("Resuming %s (%s) from disk", a.VMName, a.VMID)
+	}
 	return fmt.Sprintf("Starting %s (%s)", a.VMName, a.VMID)
 }
 
@@ -67,7 +88,7 @@ type ShutdownAction struct {
 func NewShutdownAction(executor Executor, node *models.VMStatus) *ShutdownAction {
 	return &ShutdownAction{
 		BaseAction: BaseAction{
-			VMID:     node.VMID,
+			VMID:     node.Key(),
 			VMName:   node.Name,
 			Executor: executor,
 		},
@@ -94,7 +115,7 @@ type RebootAction struct {
 func NewRebootAction(executor Executor, node *models.VMStatus) *RebootAction {
 	return &RebootAction{
 		BaseAction: BaseAction{
-			VMID:     node.VMID,
+			VMID:     node.Key(),
 			VMName:   node.Name,
 			Executor: executor,
 		},
@@ -121,7 +142,7 @@ type StopAction struct {
 func NewStopAction(executor Executor, node *models.VMStatus) *StopAction {
 	return &StopAction{
 		BaseAction: BaseAction{
-			VMID:     node.VMID,
+			VMID:     node.Key(),
 			VMName:   node.Name,
 			Executor: executor,
 		},
@@ -139,3 +160,62 @@ func (a *StopAction) Name() string {
 func (a *StopAction) Description() string {
 	return fmt.Sprintf("Force stopping %s (%s)", a.VMName, a.VMID)
 }
+
+// ResumeAction resumes a paused VM or container
+type ResumeAction struct {
+	BaseAction
+}
+
+func NewResumeAction(executor Executor, node *models.VMStatus) *ResumeAction {
+	return &ResumeAction{
+		BaseAction: BaseAction{
+			VMID:     node.Key(),
+			VMName:   node.Name,
+			Executor: executor,
+		},
+	}
+}
+
+func (a *ResumeAction) Execute(ctx context.Context) error {
+	return a.Executor.Resume(ctx, a.VMID)
+}
+
+func (a *ResumeAction) Name() string {
+	return "Resume"
+}
+
+func (a *ResumeAction) Description() string {
+	return fmt.Sprintf("Resuming %s (%s)", a.VMName, a.VMID)
+}
+
+// HibernateAction suspends a running VM to disk; starting it again
+// resumes it
+type HibernateAction struct {
+	BaseAction
+}
+
+func NewHibernateAction(executor Executor, node *models.VMStatus) *HibernateAction {
+	return &HibernateAction{
+		BaseAction: BaseAction{
+			VMID:     node.Key(),
+			VMName:   node.Name,
+			Executor: executor,
+		},
+	}
+}
+
+func (a *HibernateAction) Execute(ctx context.Context) error {
+	hibernator, ok := a.Executor.(Hibernator)
+	if !ok {
+		return ErrHibernateUnsupported
+	}
+	return hibernator.Hibernate(ctx, a.VMID)
+}
+
+func (a *HibernateAction) Name() string {
+	return "Hibernate"
+}
+
+func (a *HibernateAction) Description() string {
+	return fmt.Sprintf("Hibernating %s (%s)", a.VMName, a.VMID)
+}
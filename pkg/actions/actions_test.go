@@ -15,6 +15,7 @@ type MockExecutor struct {
 	ShutdownCalled bool
 	RebootCalled   bool
 	StopCalled     bool
+	ResumeCalled   bool
 	LastVMID       string
 	ReturnError    error
 }
@@ -43,6 +44,12 @@ func (m *MockExecutor) Stop(ctx context.Context, vmid string) error {
 	return m.ReturnError
 }
 
+func (m *MockExecutor) Resume(ctx context.Context, vmid string) error {
+	m.ResumeCalled = true
+	m.LastVMID = vmid
+	return m.ReturnError
+}
+
 func TestStartAction(t *testing.T) {
 	mock := &MockExecutor{}
 	node := &models.VMStatus{VMID: "100", Name: "test-vm"}
@@ -58,6 +65,14 @@ func TestStartAction(t *testing.T) {
 	assert.Equal(t, "100", mock.LastVMID)
 }
 
+func TestStartAction_FromDisk(t *testing.T) {
+	node := &models.VMStatus{VMID: "100", Name: "test-vm", Status: models.StateHibernated}
+	action := NewStartAction(&MockExecutor{}, node)
+
+	assert.Equal(t, "Resume from disk", action.Name())
+	assert.Equal(t, "Resuming test-vm (100) from disk", action.Description())
+}
+
 func TestStartAction_Error(t *testing.T) {
 	mock := &MockExecutor{ReturnError: errors.New("start failed")}
 	node := &models.VMStatus{VMID: "100", Name: "test-vm"}
@@ -142,3 +157,60 @@ func TestStopAction_Error(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "stop failed", err.Error())
 }
+
+func TestResumeAction(t *testing.T) {
+	mock := &MockExecutor{}
+	node := &models.VMStatus{VMID: "104", Name: "test-vm3", Status: models.StatePaused}
+	action := NewResumeAction(mock, node)
+
+	assert.Equal(t, "Resume", action.Name())
+	assert.Contains(t, action.Description(), "test-vm3")
+	assert.Contains(t, action.Description(), "104")
+
+	err := action.Execute(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, mock.ResumeCalled)
+	assert.Equal(t, "104", mock.LastVMID)
+}
+
+func TestResumeAction_Error(t *testing.T) {
+	mock := &MockExecutor{ReturnError: errors.New("resume failed")}
+	node := &models.VMStatus{VMID: "104", Name: "test-vm3"}
+	action := NewResumeAction(mock, node)
+
+	err := action.Execute(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, "resume failed", err.Error())
+}
+
+// hibernatingExecutor is a MockExecutor that can hibernate
+type hibernatingExecutor struct {
+	MockExecutor
+	HibernateCalled bool
+}
+
+func (m *hibernatingExecutor) Hibernate(ctx context.Context, vmid string) error {
+	m.HibernateCalled = true
+	m.LastVMID = vmid
+	return m.ReturnError
+}
+
+func TestHibernateAction(t *testing.T) {
+	mock := &hibernatingExecutor{}
+	node := &models.VMStatus{VMID: "100", Name: "test-vm"}
+	action := NewHibernateAction(mock, node)
+
+	assert.Equal(t, "Hibernate", action.Name())
+	assert.Equal(t, "Hibernating test-vm (100)", action.Description())
+
+	err := action.Execute(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, mock.HibernateCalled)
+	assert.Equal(t, "100", mock.LastVMID)
+}
+
+func TestHibernateAction_Unsupported(t *testing.T) {
+	node := &models.VMStatus{VMID: "100", Name: "test-vm"}
+	err := NewHibernateAction(&MockExecutor{}, node).Execute(context.Background())
+	assert.ErrorIs(t, err, ErrHibernateUnsupported)
+}
@@ -0,0 +1,182 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// TagMode says how a tag edit merges with the tags a guest already has
+type TagMode int
+
+const (
+	TagAdd     TagMode = iota // Add the tags, keeping the others
+	TagRemove                 // Remove the tags, keeping the others
+	TagReplace                // Make the tags the only ones
+)
+
+// String names the mode, as the prompt of an edit shows it
+func (m TagMode) String() string {
+	switch m {
+	case TagRemove:
+		return "remove"
+	case TagReplace:
+		return "replace"
+	default:
+		return "add"
+	}
+}
+
+// TagEdit is a change to the tags of guests
+type TagEdit struct {
+	Mode TagMode
+	Tags []string
+}
+
+// Apply returns the tags a guest with current ends up with
+func (e TagEdit) Apply(current []string) []string {
+	var tags []string
+	switch e.Mode {
+	case TagAdd:
+		tags = append(tags, current...)
+		for _, tag := range e.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	case TagRemove:
+		for _, tag := range current {
+			if !slices.Contains(e.Tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	case TagReplace:
+		tags = append(tags, e.Tags...)
+	}
+	return tags
+}
+
+// Tagger reads and sets the tags of guests. The vmid is the guest's
+// models.VMStatus.Key, as for Executor.
+type Tagger interface {
+	// Tags returns the tags of a guest, read from its config
+	Tags(ctx context.Context, vmid string) ([]string, error)
+	// SetTags replaces the tags of a guest
+	SetTags(ctx context.Context, vmid string, tags []string) error
+}
+
+// PoolMover adds guests to a resource pool. The vmids are the guests'
+// models.VMStatus.Key.
+type PoolMover interface {
+	AddToPool(ctx context.Context, pool string, vmids []string) error
+}
+
+// BatchResult is the outcome of a batch change for one guest
+type BatchResult struct {
+	Guest     *models.VMStatus
+	Before    []string // Tags before the edit
+	After     []string // Tags after the edit
+	Unchanged bool     // The edit left the tags as they were, so nothing was sent
+	Err       error
+}
+
+// PreviewTags reads the tags of every guest and works out what an edit
+// would make of them, without changing anything
+func PreviewTags(ctx context.Context, guests []*models.VMStatus, edit TagEdit, tagger Tagger) []BatchResult {
+	results := make([]BatchResult, 0, len(guests))
+	for _, vm := range guests {
+		result := BatchResult{Guest: vm}
+		result.Before, result.Err = tagger.Tags(ctx, vm.Key())
+		if result.Err == nil {
+			result.After = edit.Apply(result.Before)
+			result.Unchanged = slices.Equal(result.Before, result.After)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// ApplyTags edits the tags of guests one at a time, calling progress after
+// each; a guest that fails doesn't stop the others, its error is kept in
+// its result. Guests the edit leaves as they were are not written.
+func ApplyTags(ctx context.Context, guests []*models.VMStatus, edit TagEdit, tagger Tagger, progress func(done, total int)) []BatchResult {
+	results := make([]BatchResult, 0, len(guests))
+	for i, vm := range guests {
+		result := PreviewTags(ctx, []*models.VMStatus{vm}, edit, tagger)[0]
+		switch {
+		case ctx.Err() != nil:
+			result.Err = ctx.Err()
+		case result.Err == nil && !result.Unchanged:
+			result.Err = tagger.SetTags(ctx, vm.Key(), result.After)
+		}
+		results = append(results, result)
+		if progress != nil {
+			progress(i+1, len(guests))
+		}
+	}
+	return results
+}
+
+// PreviewPool works out what moving guests to a pool would change: the
+// pool of each one, as the last refresh listed it, before and after
+func PreviewPool(guests []*models.VMStatus, pool string) []BatchResult {
+	results := make([]BatchResult, len(guests))
+	for i, vm := range guests {
+		results[i] = BatchResult{Guest: vm, After: []string{pool}, Unchanged: vm.Pool == pool}
+		if vm.Pool != "" {
+			results[i].Before = []string{vm.Pool}
+		}
+	}
+	return results
+}
+
+// MoveToPool adds guests to a resource pool in one request, so they all
+// share its outcome. Guests already in the pool are left out of it.
+func MoveToPool(ctx context.Context, pool string, guests []*models.VMStatus, mover PoolMover) []BatchResult {
+	results := PreviewPool(guests, pool)
+	var vmids []string
+	for _, r := range results {
+		if !r.Unchanged {
+			vmids = append(vmids, r.Guest.Key())
+		}
+	}
+	if len(vmids) == 0 {
+		return results
+	}
+	err := mover.AddToPool(ctx, pool, vmids)
+	for i := range results {
+		if !results[i].Unchanged {
+			results[i].Err = err
+		}
+	}
+	return results
+}
+
+// SummarizeBatch counts the guests a batch changed, left as they were and
+// failed on, naming the failures, e.g. "2 changed, 1 failed: web (100): status 403"
+func SummarizeBatch(results []BatchResult) string {
+	var changed, unchanged int
+	var failures []string
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failures = append(failures, fmt.Sprintf("%s (%s): %v", r.Guest.Name, r.Guest.Key(), r.Err))
+		case r.Unchanged:
+			unchanged++
+		default:
+			changed++
+		}
+	}
+	parts := []string{fmt.Sprintf("%d changed", changed)}
+	if unchanged > 0 {
+		parts = append(parts, fmt.Sprintf("%d unchanged", unchanged))
+	}
+	summary := strings.Join(parts, ", ")
+	if len(failures) > 0 {
+		summary += fmt.Sprintf(", %d failed: %s", len(failures), strings.Join(failures, "; "))
+	}
+	return summary
+}
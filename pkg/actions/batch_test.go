@@ -0,0 +1,128 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// fakeTagger keeps tags by guest key and fails on the keys of errs
+type fakeTagger struct {
+	tags map[string][]string
+	errs map[string]error
+	sets []string
+}
+
+func (f *fakeTagger) Tags(ctx context.Context, vmid string) ([]string, error) {
+	return f.tags[vmid], nil
+}
+
+func (f *fakeTagger) SetTags(ctx context.Context, vmid string, tags []string) error {
+	f.sets = append(f.sets, vmid)
+	if err := f.errs[vmid]; err != nil {
+		return err
+	}
+	f.tags[vmid] = tags
+	return nil
+}
+
+type fakePoolMover struct {
+	pool  string
+	vmids []string
+	err   error
+}
+
+func (f *fakePoolMover) AddToPool(ctx context.Context, pool string, vmids []string) error {
+	f.pool, f.vmids = pool, vmids
+	return f.err
+}
+
+func TestTagEdit_Apply(t *testing.T) {
+	current := []string{"prod", "web"}
+	assert.Equal(t, []string{"prod", "web", "eu"}, TagEdit{Mode: TagAdd, Tags: []string{"web", "eu"}}.Apply(current))
+	assert.Equal(t, []string{"web"}, TagEdit{Mode: TagRemove, Tags: []string{"prod", "eu"}}.Apply(current))
+	assert.Equal(t, []string{"eu"}, TagEdit{Mode: TagReplace, Tags: []string{"eu"}}.Apply(current))
+	assert.Equal(t, []string{"prod", "web"}, current, "the tags given are left alone")
+	assert.Equal(t, "replace", TagReplace.String())
+}
+
+func TestPreviewTags(t *testing.T) {
+	tagger := &fakeTagger{tags: map[string][]string{"100": {"prod"}, "101": {"prod", "web"}}}
+	guests := []*models.VMStatus{stoppedGuest("100"), stoppedGuest("101")}
+
+	results := PreviewTags(context.Background(), guests, TagEdit{Mode: TagAdd, Tags: []string{"web"}}, tagger)
+
+	require.Len(t, results, 2)
+	assert.Equal(t, []string{"prod", "web"}, results[0].After)
+	assert.False(t, results[0].Unchanged)
+	assert.True(t, results[1].Unchanged)
+	assert.Empty(t, tagger.sets, "a preview changes nothing")
+}
+
+func TestApplyTags(t *testing.T) {
+	tagger := &fakeTagger{
+		tags: map[string][]string{"100": {"prod"}, "101": {"web"}, "102": {"prod"}},
+		errs: map[string]error{"102": errors.New("status 403")},
+	}
+	guests := []*models.VMStatus{stoppedGuest("100"), stoppedGuest("101"), stoppedGuest("102")}
+	var progress []int
+
+	results := ApplyTags(context.Background(), guests, TagEdit{Mode: TagRemove, Tags: []string{"prod"}}, tagger,
+		func(done, total int) { progress = append(progress, done, total) })
+
+	assert.Equal(t, []string{"100", "102"}, tagger.sets, "101 has no prod tag to remove")
+	assert.Empty(t, tagger.tags["100"])
+	assert.Equal(t, []int{1, 3, 2, 3, 3, 3}, progress)
+	require.Len(t, results, 3)
+	assert.EqualError(t, results[2].Err, "status 403")
+	assert.Equal(t, "1 changed, 1 unchanged, 1 failed: guest102 (102): status 403", SummarizeBatch(results))
+}
+
+func TestApplyTags_Cancelled(t *testing.T) {
+	tagger := &fakeTagger{tags: map[string][]string{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := ApplyTags(ctx, []*models.VMStatus{stoppedGuest("100")}, TagEdit{Tags: []string{"web"}}, tagger, nil)
+
+	assert.ErrorIs(t, results[0].Err, context.Canceled)
+	assert.Empty(t, tagger.sets)
+}
+
+func TestMoveToPool(t *testing.T) {
+	mover := &fakePoolMover{}
+	guests := []*models.VMStatus{stoppedGuest("100"), stoppedGuest("101")}
+
+	results := MoveToPool(context.Background(), "web", guests, mover)
+	assert.Equal(t, "web", mover.pool)
+	assert.Equal(t, []string{"100", "101"}, mover.vmids)
+	assert.Equal(t, "2 changed", SummarizeBatch(results))
+
+	mover.err = errors.New("pool 'web' does not exist")
+	results = MoveToPool(context.Background(), "web", guests, mover)
+	assert.Contains(t, SummarizeBatch(results), "0 changed, 2 failed")
+}
+
+func TestMoveToPool_AlreadyThere(t *testing.T) {
+	mover := &fakePoolMover{}
+	inWeb := stoppedGuest("101")
+	inWeb.Pool = "web"
+	guests := []*models.VMStatus{stoppedGuest("100"), inWeb}
+
+	preview := PreviewPool(guests, "web")
+	assert.Empty(t, preview[0].Before)
+	assert.Equal(t, []string{"web"}, preview[1].Before)
+	assert.True(t, preview[1].Unchanged)
+
+	results := MoveToPool(context.Background(), "web", guests, mover)
+	assert.Equal(t, []string{"100"}, mover.vmids, "A guest already in the pool is left out")
+	assert.Equal(t, "1 changed, 1 unchanged", SummarizeBatch(results))
+
+	mover.vmids = nil
+	MoveToPool(context.Background(), "web", []*models.VMStatus{inWeb}, mover)
+	assert.Nil(t, mover.vmids, "Nothing is sent when every guest is in the pool")
+}
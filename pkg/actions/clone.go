@@ -0,0 +1,89 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+)
+
+// Cloner clones guests for CloneAction. The source is the guest's
+// models.VMStatus.Key, as for Executor; the clone lands on its node.
+type Cloner interface {
+	// NextVMID returns a VMID free in the cluster
+	NextVMID(ctx context.Context) (string, error)
+	// Clone asks for a clone of the source and returns the ID of the
+	// clone task
+	Clone(ctx context.Context, source string, spec CloneSpec) (string, error)
+	// WaitTask waits for a task of the source's node to end, and fails
+	// unless it ended OK
+	WaitTask(ctx context.Context, source, task string) error
+	// StartClone starts the clone newid of the source
+	StartClone(ctx context.Context, source, newid string) error
+}
+
+// CloneSpec describes the clone to make
+type CloneSpec struct {
+	NewID   string
+	Name    string
+	Storage string // Storage of a full clone's disks; "" keeps the source's
+	Full    bool   // Copy the disks rather than link them to a template
+}
+
+// The stages of a clone, named by a CloneError
+const (
+	CloneStageVMID    = "allocating a VMID"
+	CloneStageRequest = "requesting the clone"
+	CloneStageTask    = "cloning"
+	CloneStageStart   = "starting the clone"
+)
+
+// CloneError tells at which stage a clone failed
+type CloneError struct {
+	Stage string
+	Err   error
+}
+
+func (e *CloneError) Error() string {
+	return fmt.Sprintf("failed %s: %v", e.Stage, e.Err)
+}
+
+func (e *CloneError) Unwrap() error {
+	return e.Err
+}
+
+// CloneAction clones a guest under a new VMID, waits for the clone to be
+// made and optionally starts it
+type CloneAction struct {
+	Cloner Cloner
+	Source string    // Key of the guest cloned
+	Spec   CloneSpec // The clone to make; its NewID is allocated
+	Start  bool      // Start the clone once made
+}
+
+func (a *CloneAction) Execute(ctx context.Context) error {
+	newid, err := a.Cloner.NextVMID(ctx)
+	if err != nil {
+		return &CloneError{Stage: CloneStageVMID, Err: err}
+	}
+	a.Spec.NewID = newid
+	task, err := a.Cloner.Clone(ctx, a.Source, a.Spec)
+	if err != nil {
+		return &CloneError{Stage: CloneStageRequest, Err: err}
+	}
+	if err := a.Cloner.WaitTask(ctx, a.Source, task); err != nil {
+		return &CloneError{Stage: CloneStageTask, Err: err}
+	}
+	if a.Start {
+		if err := a.Cloner.StartClone(ctx, a.Source, newid); err != nil {
+			return &CloneError{Stage: CloneStageStart, Err: err}
+		}
+	}
+	return nil
+}
+
+func (a *CloneAction) Name() string {
+	return "Clone"
+}
+
+func (a *CloneAction) Description() string {
+	return fmt.Sprintf("Clone %s as %s", a.Source, a.Spec.Name)
+}
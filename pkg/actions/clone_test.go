@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockCloner records the calls of a clone, failing at the stage set
+type mockCloner struct {
+	failAt string // Stage to fail at, "" for none
+	calls  []string
+}
+
+func (m *mockCloner) fail(stage string) error {
+	if m.failAt == stage {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func (m *mockCloner) NextVMID(ctx context.Context) (string, error) {
+	m.calls = append(m.calls, "nextid")
+	return "105", m.fail(CloneStageVMID)
+}
+
+func (m *mockCloner) Clone(ctx context.Context, source string, spec CloneSpec) (string, error) {
+	m.calls = append(m.calls, "clone "+source+" "+spec.NewID+" "+spec.Name)
+	return "UPID:pve1:1", m.fail(CloneStageRequest)
+}
+
+func (m *mockCloner) WaitTask(ctx context.Context, source, task string) error {
+	m.calls = append(m.calls, "wait "+task)
+	return m.fail(CloneStageTask)
+}
+
+func (m *mockCloner) StartClone(ctx context.Context, source, newid string) error {
+	m.calls = append(m.calls, "start "+newid)
+	return m.fail(CloneStageStart)
+}
+
+func TestCloneAction(t *testing.T) {
+	cloner := &mockCloner{}
+	action := &CloneAction{Cloner: cloner, Source: "9000", Spec: CloneSpec{Name: "dev-web-3"}, Start: true}
+	require.NoError(t, action.Execute(context.Background()))
+	assert.Equal(t, "105", action.Spec.NewID)
+	assert.Equal(t, []string{"nextid", "clone 9000 105 dev-web-3", "wait UPID:pve1:1", "start 105"}, cloner.calls)
+	assert.Equal(t, "Clone", action.Name())
+	assert.Equal(t, "Clone 9000 as dev-web-3", action.Description())
+
+	cloner = &mockCloner{}
+	action = &CloneAction{Cloner: cloner, Source: "9000", Spec: CloneSpec{Name: "dev-web-3"}}
+	require.NoError(t, action.Execute(context.Background()))
+	assert.NotContains(t, cloner.calls, "start 105", "The clone is left stopped unless asked")
+}
+
+func TestCloneAction_Stages(t *testing.T) {
+	for _, stage := range []string{CloneStageVMID, CloneStageRequest, CloneStageTask, CloneStageStart} {
+		t.Run(stage, func(t *testing.T) {
+			cloner := &mockCloner{failAt: stage}
+			action := &CloneAction{Cloner: cloner, Source: "9000", Spec: CloneSpec{Name: "dev-web-3"}, Start: true}
+			err := action.Execute(context.Background())
+
+			var cloneErr *CloneError
+			require.ErrorAs(t, err, &cloneErr)
+			assert.Equal(t, stage, cloneErr.Stage)
+			assert.EqualError(t, err, "failed "+stage+": boom")
+		})
+	}
+}
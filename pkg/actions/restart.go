@@ -0,0 +1,269 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/clock"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// DefaultRestartTimeout is how long a restart waits for the guest to shut
+// down before asking whether to force it off
+const DefaultRestartTimeout = 2 * time.Minute
+
+// DefaultRestartPoll is how often Execute checks whether the guest stopped
+const DefaultRestartPoll = 2 * time.Second
+
+// ErrRestartCancelled is returned by Execute when the restart was cancelled
+var ErrRestartCancelled = errors.New("restart cancelled")
+
+// RestartPhase is how far a restart got
+type RestartPhase int
+
+const (
+	RestartShutdown  RestartPhase = iota // The shutdown is next
+	RestartWaiting                       // Waiting for the guest to stop
+	RestartTimedOut                      // Still running after the timeout: Escalate or KeepWaiting
+	RestartStarting                      // Stopped; the start is next
+	RestartDone                          // Started again
+	RestartCancelled                     // Cancelled before a phase
+	RestartFailed                        // A request failed; see Err
+)
+
+// StatusFunc returns the current status of a guest, e.g. models.StateStopped
+type StatusFunc func(ctx context.Context, vmid string) (models.NodeState, error)
+
+// RestartAction shuts a guest down, waits until it is stopped and starts
+// it again. Unlike a reboot the guest really stops, so pending config
+// changes apply and containers on older Proxmox versions come back clean.
+//
+// Step advances it one phase at a time so a UI can drive it and ask what
+// to do when the shutdown times out; Execute runs it to the end. Cancel
+// may be called at any time: a request in flight still completes, but no
+// further phase begins.
+type RestartAction struct {
+	BaseAction
+	Status  StatusFunc
+	Timeout time.Duration                  // Wait for the guest to stop before timing out; DefaultRestartTimeout if zero
+	Poll    time.Duration                  // Status check interval of Execute; DefaultRestartPoll if zero
+	Confirm func(ctx context.Context) bool // Asked by Execute on timeout whether to force-stop; nil gives up
+	Clock   clock.Clock                    // Clock of the deadline and the polls; clock.Real if nil
+
+	mu       sync.Mutex
+	phase    RestartPhase
+	forced   bool      // Force-stopped after timing out
+	deadline time.Time // End of the wait for the guest to stop
+	err      error
+}
+
+func NewRestartAction(executor Executor, status StatusFunc, node *models.VMStatus) *RestartAction {
+	return &RestartAction{
+		BaseAction: BaseAction{
+			VMID:     node.Key(),
+			VMName:   node.Name,
+			Executor: executor,
+		},
+		Status: status,
+	}
+}
+
+// Phase returns the current phase
+func (a *RestartAction) Phase() RestartPhase {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.phase
+}
+
+// Err returns why the restart failed, nil unless in RestartFailed
+func (a *RestartAction) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// Forced reports whether the guest was force-stopped
+func (a *RestartAction) Forced() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.forced
+}
+
+// Finished reports whether the restart is over, whichever way it ended
+func (a *RestartAction) Finished() bool {
+	phase := a.Phase()
+	return phase == RestartDone || phase == RestartCancelled || phase == RestartFailed
+}
+
+// Step carries out the next phase: sends the shutdown, checks once
+// whether the guest stopped, or sends the start. It returns the phase
+// reached, and does nothing when timed out or finished.
+func (a *RestartAction) Step(ctx context.Context) RestartPhase {
+	switch phase := a.Phase(); phase {
+	case RestartShutdown:
+		err := a.Executor.Shutdown(ctx, a.VMID)
+		return a.advance(phase, RestartWaiting, wrap("shutdown", err))
+	case RestartWaiting:
+		status, err := a.Status(ctx, a.VMID)
+		if err != nil {
+			return a.advance(phase, phase, wrap("status", err))
+		}
+		switch {
+		case status == models.StateStopped:
+			return a.advance(phase, RestartStarting, nil)
+		case !a.now().Before(a.waitDeadline()):
+			if a.Forced() {
+				return a.advance(phase, phase, fmt.Errorf("%s still %s after a forced stop", a.VMID, status))
+			}
+			return a.advance(phase, RestartTimedOut, nil)
+		}
+		return phase
+	case RestartStarting:
+		err := a.Executor.Start(ctx, a.VMID)
+		return a.advance(phase, RestartDone, wrap("start", err))
+	default:
+		return phase
+	}
+}
+
+// Escalate force-stops a guest whose shutdown timed out, then waits for
+// it to stop as before
+func (a *RestartAction) Escalate(ctx context.Context) RestartPhase {
+	if a.Phase() != RestartTimedOut {
+		return a.Phase()
+	}
+	err := a.Executor.Stop(ctx, a.VMID)
+	a.mu.Lock()
+	a.forced = err == nil
+	a.mu.Unlock()
+	return a.advance(RestartTimedOut, RestartWaiting, wrap("stop", err))
+}
+
+// KeepWaiting gives a shutdown that timed out another Timeout
+func (a *RestartAction) KeepWaiting() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.phase == RestartTimedOut {
+		a.phase = RestartWaiting
+		a.deadline = a.now().Add(a.timeout())
+	}
+}
+
+// Cancel stops the restart before its next phase
+func (a *RestartAction) Cancel() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch a.phase {
+	case RestartDone, RestartCancelled, RestartFailed:
+	default:
+		a.phase = RestartCancelled
+	}
+}
+
+// advance moves from phase from to phase to, or to RestartFailed when err
+// is set. A restart cancelled while the request ran stays cancelled.
+func (a *RestartAction) advance(from, to RestartPhase, err error) RestartPhase {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.phase != from {
+		return a.phase
+	}
+	if err != nil {
+		a.phase = RestartFailed
+		a.err = err
+		return a.phase
+	}
+	if to == RestartWaiting {
+		a.deadline = a.now().Add(a.timeout())
+	}
+	a.phase = to
+	return a.phase
+}
+
+// wrap names the request that failed, nil when err is nil
+func wrap(request string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", request, err)
+}
+
+func (a *RestartAction) waitDeadline() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.deadline
+}
+
+func (a *RestartAction) now() time.Time {
+	return clock.OrReal(a.Clock).Now()
+}
+
+func (a *RestartAction) timeout() time.Duration {
+	if a.Timeout > 0 {
+		return a.Timeout
+	}
+	return DefaultRestartTimeout
+}
+
+// Execute runs the restart to the end, checking every Poll whether the
+// guest stopped. Cancelling ctx cancels the restart.
+func (a *RestartAction) Execute(ctx context.Context) error {
+	poll := a.Poll
+	if poll <= 0 {
+		poll = DefaultRestartPoll
+	}
+	for {
+		if ctx.Err() != nil {
+			a.Cancel()
+		}
+		switch a.Step(ctx) {
+		case RestartDone:
+			return nil
+		case RestartFailed:
+			return a.Err()
+		case RestartCancelled:
+			return ErrRestartCancelled
+		case RestartTimedOut:
+			if a.Confirm == nil || !a.Confirm(ctx) {
+				a.advance(RestartTimedOut, RestartTimedOut,
+					fmt.Errorf("%s did not shut down within %s", a.VMID, a.timeout()))
+				return a.Err()
+			}
+			a.Escalate(ctx)
+		case RestartWaiting:
+			select {
+			case <-ctx.Done():
+			case <-clock.OrReal(a.Clock).After(poll):
+			}
+		}
+	}
+}
+
+func (a *RestartAction) Name() string {
+	return "Restart"
+}
+
+func (a *RestartAction) Description() string {
+	guest := fmt.Sprintf("%s (%s)", a.VMName, a.VMID)
+	switch a.Phase() {
+	case RestartShutdown:
+		return "Shutting down " + guest
+	case RestartWaiting:
+		if a.Forced() {
+			return fmt.Sprintf("Waiting for %s to stop after forcing it", guest)
+		}
+		return fmt.Sprintf("Waiting for %s to shut down", guest)
+	case RestartTimedOut:
+		return fmt.Sprintf("%s still running after %s", guest, a.timeout())
+	case RestartStarting:
+		return "Starting " + guest
+	case RestartDone:
+		return "Restarted " + guest
+	case RestartCancelled:
+		return fmt.Sprintf("Restart of %s cancelled", guest)
+	}
+	return fmt.Sprintf("Restart of %s failed: %v", guest, a.Err())
+}
@@ -0,0 +1,268 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/internal/testutil"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// scriptedExecutor records the requests made and fails those in errs
+type scriptedExecutor struct {
+	MockExecutor
+	calls []string
+	errs  map[string]error
+}
+
+func (s *scriptedExecutor) call(name string) error {
+	s.calls = append(s.calls, name)
+	return s.errs[name]
+}
+
+func (s *scriptedExecutor) Start(ctx context.Context, vmid string) error {
+	return s.call("start")
+}
+
+func (s *scriptedExecutor) Shutdown(ctx context.Context, vmid string) error {
+	return s.call("shutdown")
+}
+
+func (s *scriptedExecutor) Stop(ctx context.Context, vmid string) error {
+	return s.call("stop")
+}
+
+// scriptedStatus returns the statuses in turn, repeating the last one
+func scriptedStatus(statuses ...models.NodeState) StatusFunc {
+	return func(ctx context.Context, vmid string) (models.NodeState, error) {
+		status := statuses[0]
+		if len(statuses) > 1 {
+			statuses = statuses[1:]
+		}
+		return status, nil
+	}
+}
+
+func newFakeClock() *testutil.FakeClock {
+	return testutil.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func newRestart(exec *scriptedExecutor, status StatusFunc, clock *testutil.FakeClock) *RestartAction {
+	action := NewRestartAction(exec, status, &models.VMStatus{VMID: "100", Name: "web"})
+	action.Timeout = time.Minute
+	action.Poll = 10 * time.Second
+	action.Clock = clock
+	return action
+}
+
+// execute runs the action to the end, moving clock a poll forward
+// whenever the action waits on it
+func execute(ctx context.Context, action *RestartAction, clock *testutil.FakeClock) error {
+	done := make(chan error, 1)
+	go func() { done <- action.Execute(ctx) }()
+	for {
+		select {
+		case err := <-done:
+			return err
+		default:
+		}
+		if clock.Waiters() > 0 {
+			clock.Advance(action.Poll)
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestRestartAction_Steps(t *testing.T) {
+	exec := &scriptedExecutor{}
+	clock := newFakeClock()
+	action := newRestart(exec, scriptedStatus(models.StateRunning, models.StateRunning, models.StateStopped), clock)
+	ctx := context.Background()
+
+	assert.Equal(t, "Restart", action.Name())
+	assert.Equal(t, "Shutting down web (100)", action.Description())
+
+	assert.Equal(t, RestartWaiting, action.Step(ctx))
+	assert.Equal(t, "Waiting for web (100) to shut down", action.Description())
+	assert.Equal(t, RestartWaiting, action.Step(ctx), "still running")
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, RestartWaiting, action.Step(ctx), "still running, within the timeout")
+	assert.Equal(t, RestartStarting, action.Step(ctx), "stopped")
+	assert.Equal(t, "Starting web (100)", action.Description())
+	assert.Equal(t, RestartDone, action.Step(ctx))
+	assert.Equal(t, RestartDone, action.Step(ctx), "a finished restart stays done")
+
+	assert.Equal(t, []string{"shutdown", "start"}, exec.calls)
+	assert.True(t, action.Finished())
+	assert.False(t, action.Forced())
+	assert.NoError(t, action.Err())
+}
+
+func TestRestartAction_Escalate(t *testing.T) {
+	exec := &scriptedExecutor{}
+	clock := newFakeClock()
+	action := newRestart(exec, scriptedStatus(models.StateRunning, models.StateStopped), clock)
+	ctx := context.Background()
+
+	action.Step(ctx)
+	clock.Advance(time.Minute)
+	require.Equal(t, RestartTimedOut, action.Step(ctx))
+	assert.Equal(t, "web (100) still running after 1m0s", action.Description())
+	assert.Equal(t, RestartTimedOut, action.Step(ctx), "Step waits for a decision")
+
+	assert.Equal(t, RestartWaiting, action.Escalate(ctx))
+	assert.True(t, action.Forced())
+	assert.Equal(t, "Waiting for web (100) to stop after forcing it", action.Description())
+	assert.Equal(t, RestartStarting, action.Step(ctx))
+	assert.Equal(t, RestartDone, action.Step(ctx))
+	assert.Equal(t, []string{"shutdown", "stop", "start"}, exec.calls)
+}
+
+func TestRestartAction_KeepWaiting(t *testing.T) {
+	exec := &scriptedExecutor{}
+	clock := newFakeClock()
+	action := newRestart(exec, scriptedStatus(models.StateRunning, models.StateStopped), clock)
+	ctx := context.Background()
+
+	action.Step(ctx)
+	clock.Advance(time.Minute)
+	require.Equal(t, RestartTimedOut, action.Step(ctx))
+
+	action.KeepWaiting()
+	assert.Equal(t, RestartWaiting, action.Phase())
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, RestartStarting, action.Step(ctx), "the new wait has its own timeout")
+	assert.Equal(t, []string{"shutdown"}, exec.calls)
+}
+
+func TestRestartAction_StillRunningAfterForcedStop(t *testing.T) {
+	exec := &scriptedExecutor{}
+	clock := newFakeClock()
+	action := newRestart(exec, scriptedStatus(models.StateRunning), clock)
+	ctx := context.Background()
+
+	action.Step(ctx)
+	clock.Advance(time.Minute)
+	action.Step(ctx)
+	action.Escalate(ctx)
+	clock.Advance(time.Minute)
+
+	assert.Equal(t, RestartFailed, action.Step(ctx))
+	assert.EqualError(t, action.Err(), "100 still running after a forced stop")
+	assert.Equal(t, []string{"shutdown", "stop"}, exec.calls, "never started while running")
+}
+
+func TestRestartAction_Failures(t *testing.T) {
+	boom := errors.New("boom")
+	for _, request := range []string{"shutdown", "start"} {
+		t.Run(request, func(t *testing.T) {
+			exec := &scriptedExecutor{errs: map[string]error{request: boom}}
+			action := newRestart(exec, scriptedStatus(models.StateStopped), newFakeClock())
+
+			for !action.Finished() {
+				action.Step(context.Background())
+			}
+
+			assert.Equal(t, RestartFailed, action.Phase())
+			assert.ErrorIs(t, action.Err(), boom)
+			assert.Equal(t, "Restart of web (100) failed: "+request+": boom", action.Description())
+		})
+	}
+
+	exec := &scriptedExecutor{}
+	action := newRestart(exec, func(ctx context.Context, vmid string) (models.NodeState, error) { return "", boom }, newFakeClock())
+	action.Step(context.Background())
+	assert.Equal(t, RestartFailed, action.Step(context.Background()))
+	assert.ErrorIs(t, action.Err(), boom)
+	assert.Equal(t, []string{"shutdown"}, exec.calls)
+}
+
+func TestRestartAction_Cancel(t *testing.T) {
+	exec := &scriptedExecutor{}
+	action := newRestart(exec, scriptedStatus(models.StateStopped), newFakeClock())
+	ctx := context.Background()
+
+	action.Step(ctx)
+	action.Cancel()
+
+	assert.Equal(t, RestartCancelled, action.Step(ctx))
+	assert.Equal(t, "Restart of web (100) cancelled", action.Description())
+	assert.Equal(t, []string{"shutdown"}, exec.calls, "nothing after the cancel")
+
+	action.Cancel()
+	assert.Equal(t, RestartCancelled, action.Phase())
+}
+
+// cancellingExecutor cancels the restart while its shutdown is in flight
+type cancellingExecutor struct {
+	scriptedExecutor
+	action *RestartAction
+}
+
+func (c *cancellingExecutor) Shutdown(ctx context.Context, vmid string) error {
+	c.action.Cancel()
+	return c.call("shutdown")
+}
+
+func TestRestartAction_CancelDuringRequest(t *testing.T) {
+	exec := &cancellingExecutor{}
+	action := NewRestartAction(exec, scriptedStatus(models.StateStopped), &models.VMStatus{VMID: "100", Name: "web"})
+	exec.action = action
+
+	assert.Equal(t, RestartCancelled, action.Step(context.Background()), "the request completes, the restart stops there")
+	assert.Equal(t, RestartCancelled, action.Step(context.Background()))
+	assert.Equal(t, []string{"shutdown"}, exec.calls)
+}
+
+func TestRestartAction_Execute(t *testing.T) {
+	exec := &scriptedExecutor{}
+	clock := newFakeClock()
+	action := newRestart(exec, scriptedStatus(models.StateRunning, models.StateStopped), clock)
+
+	require.NoError(t, execute(context.Background(), action, clock))
+	assert.Equal(t, []string{"shutdown", "start"}, exec.calls)
+}
+
+func TestRestartAction_Execute_TimedOut(t *testing.T) {
+	clock := newFakeClock()
+	status := func(ctx context.Context, vmid string) (models.NodeState, error) {
+		clock.Advance(time.Minute)
+		return models.StateRunning, nil
+	}
+
+	exec := &scriptedExecutor{}
+	action := newRestart(exec, status, clock)
+	err := execute(context.Background(), action, clock)
+	assert.EqualError(t, err, "100 did not shut down within 1m0s")
+	assert.Equal(t, []string{"shutdown"}, exec.calls)
+
+	exec = &scriptedExecutor{}
+	action = newRestart(exec, status, clock)
+	asked := 0
+	action.Confirm = func(ctx context.Context) bool { asked++; return true }
+	err = execute(context.Background(), action, clock)
+	assert.EqualError(t, err, "100 still running after a forced stop")
+	assert.Equal(t, 1, asked)
+	assert.Equal(t, []string{"shutdown", "stop"}, exec.calls)
+}
+
+func TestRestartAction_Execute_Cancelled(t *testing.T) {
+	exec := &scriptedExecutor{}
+	action := newRestart(exec, scriptedStatus(models.StateRunning), newFakeClock())
+	ctx, cancel := context.WithCancel(context.Background())
+	action.Poll = time.Hour
+	go func() {
+		for action.Phase() != RestartWaiting {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	assert.ErrorIs(t, action.Execute(ctx), ErrRestartCancelled)
+	assert.Equal(t, []string{"shutdown"}, exec.calls)
+}
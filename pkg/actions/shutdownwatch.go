@@ -0,0 +1,100 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/clock"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// ErrShutDown is returned by ShutdownWatch.Escalate when the guest is no
+// longer running: the shutdown completed after all
+var ErrShutDown = errors.New("it shut down in the meantime")
+
+// ErrStartedAgain is returned by ShutdownWatch.Escalate when the guest
+// runs with an uptime shorter than the time since the shutdown: it
+// stopped and was started again, by someone else or by HA
+var ErrStartedAgain = errors.New("it was started again since the shutdown")
+
+// GuestFunc returns the current status of a guest, uptime included
+type GuestFunc func(ctx context.Context, key string) (*models.VMStatus, error)
+
+// WatchState is what a ShutdownWatch found the guest doing
+type WatchState int
+
+const (
+	WatchRunning   WatchState = iota // Still running since before the shutdown
+	WatchStopped                     // No longer running
+	WatchRestarted                   // Running again, started after the shutdown
+)
+
+// ShutdownWatch follows a guest after a shutdown was sent to it, so that
+// one ignoring the shutdown can be forced off once Timeout has passed.
+// The watch doesn't poll by itself: the caller calls Check as often as it
+// likes, and Escalate once the user agreed.
+type ShutdownWatch struct {
+	Key      string    // models.VMStatus.Key of the guest
+	Name     string    // Name of the guest, for messages
+	Sent     time.Time // When the shutdown was sent
+	Timeout  time.Duration
+	Guest    GuestFunc
+	Executor Executor
+	Clock    clock.Clock // Clock of Sent; clock.Real if nil
+}
+
+func NewShutdownWatch(executor Executor, guest GuestFunc, vm *models.VMStatus, timeout time.Duration, c clock.Clock) *ShutdownWatch {
+	return &ShutdownWatch{
+		Key:      vm.Key(),
+		Name:     vm.Name,
+		Sent:     clock.OrReal(c).Now(),
+		Timeout:  timeout,
+		Guest:    guest,
+		Executor: executor,
+		Clock:    c,
+	}
+}
+
+// Overdue reports whether the guest has had Timeout to shut down
+func (w *ShutdownWatch) Overdue() bool {
+	return !w.now().Before(w.Sent.Add(w.Timeout))
+}
+
+// Check reads the guest's status and tells what it is doing
+func (w *ShutdownWatch) Check(ctx context.Context) (WatchState, error) {
+	vm, err := w.Guest(ctx, w.Key)
+	if err != nil {
+		return WatchRunning, err
+	}
+	if !vm.IsRunning() {
+		return WatchStopped, nil
+	}
+	if time.Duration(vm.Uptime)*time.Second < w.now().Sub(w.Sent) {
+		return WatchRestarted, nil
+	}
+	return WatchRunning, nil
+}
+
+// Escalate reads the guest's status once more and force-stops it only if
+// it has been running since before the shutdown. A guest that stopped, or
+// was started again since, is left alone with ErrShutDown or
+// ErrStartedAgain.
+func (w *ShutdownWatch) Escalate(ctx context.Context) error {
+	state, err := w.Check(ctx)
+	if err != nil {
+		return fmt.Errorf("status: %w", err)
+	}
+	switch state {
+	case WatchStopped:
+		return ErrShutDown
+	case WatchRestarted:
+		return ErrStartedAgain
+	}
+	return wrap("stop", w.Executor.Stop(ctx, w.Key))
+}
+
+func (w *ShutdownWatch) now() time.Time {
+	return clock.OrReal(w.Clock).Now()
+}
@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// guestAt returns a GuestFunc reading *vm, so that a test can change it
+func guestAt(vm *models.VMStatus, err *error) GuestFunc {
+	return func(ctx context.Context, key string) (*models.VMStatus, error) {
+		if *err != nil {
+			return nil, *err
+		}
+		current := *vm
+		return &current, nil
+	}
+}
+
+func TestShutdownWatch(t *testing.T) {
+	clock := newFakeClock()
+	vm := &models.VMStatus{VMID: "100", Name: "web", Status: models.StateRunning, Uptime: 3600}
+	var readErr error
+	exec := &scriptedExecutor{}
+	w := NewShutdownWatch(exec, guestAt(vm, &readErr), vm, 2*time.Minute, clock)
+	assert.Equal(t, "100", w.Key)
+	assert.False(t, w.Overdue())
+
+	clock.Advance(2 * time.Minute)
+	vm.Uptime += 120
+	assert.True(t, w.Overdue())
+	state, err := w.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, WatchRunning, state)
+
+	require.NoError(t, w.Escalate(context.Background()))
+	assert.Equal(t, []string{"stop"}, exec.calls)
+
+	readErr = errors.New("timeout")
+	assert.EqualError(t, w.Escalate(context.Background()), "status: timeout")
+	assert.Len(t, exec.calls, 1, "a guest whose status is unknown is left alone")
+}
+
+func TestShutdownWatch_LeavesAlone(t *testing.T) {
+	clock := newFakeClock()
+	vm := &models.VMStatus{VMID: "100", Name: "web", Status: models.StateRunning, Uptime: 3600}
+	var readErr error
+	exec := &scriptedExecutor{}
+	w := NewShutdownWatch(exec, guestAt(vm, &readErr), vm, 2*time.Minute, clock)
+	clock.Advance(3 * time.Minute)
+
+	vm.Status, vm.Uptime = models.StateStopped, 0
+	state, err := w.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, WatchStopped, state)
+	assert.ErrorIs(t, w.Escalate(context.Background()), ErrShutDown)
+
+	// Up for a minute, three minutes after the shutdown
+	vm.Status, vm.Uptime = models.StateRunning, 60
+	state, err = w.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, WatchRestarted, state)
+	assert.ErrorIs(t, w.Escalate(context.Background()), ErrStartedAgain)
+	assert.Empty(t, exec.calls)
+}
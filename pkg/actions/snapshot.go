@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// Snapshotter snapshots guests before an action. The vmid is the guest's
+// models.VMStatus.Key, as for Executor.
+type Snapshotter interface {
+	// SnapshotSupported reports whether the guest's storage can take a
+	// snapshot
+	SnapshotSupported(ctx context.Context, vmid string) (bool, error)
+	// Snapshot takes a snapshot and waits for it to be done
+	Snapshot(ctx context.Context, vmid, name, description string) error
+}
+
+// SnapshotError aborts an action whose snapshot failed
+type SnapshotError struct {
+	Action string // The action not sent, e.g. "stop"
+	Name   string // Snapshot that failed; "" when its support couldn't be checked
+	Err    error
+}
+
+func (e *SnapshotError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("cannot tell whether the guest can be snapshotted, %s not sent: %v", e.Action, e.Err)
+	}
+	return fmt.Sprintf("snapshot %s failed, %s not sent: %v", e.Name, e.Action, e.Err)
+}
+
+func (e *SnapshotError) Unwrap() error {
+	return e.Err
+}
+
+// SnapshotFirstAction snapshots a guest, then runs an action on it. A
+// guest whose storage can't take snapshots gets the action without one;
+// a snapshot that fails aborts the action, so nothing runs unprotected
+// by surprise.
+type SnapshotFirstAction struct {
+	Action    Action // Runs once the snapshot is taken
+	Snapshots Snapshotter
+	VMID      string // The guest's Key
+	Now       func() time.Time
+
+	taken   string // Name of the snapshot taken
+	skipped bool   // The storage can't take snapshots
+}
+
+func NewSnapshotFirstAction(action Action, snapshots Snapshotter, node *models.VMStatus) *SnapshotFirstAction {
+	return &SnapshotFirstAction{
+		Action:    action,
+		Snapshots: snapshots,
+		VMID:      node.Key(),
+		Now:       time.Now,
+	}
+}
+
+func (a *SnapshotFirstAction) Execute(ctx context.Context) error {
+	action := strings.ToLower(a.Action.Name())
+	supported, err := a.Snapshots.SnapshotSupported(ctx, a.VMID)
+	if err != nil {
+		return &SnapshotError{Action: action, Err: err}
+	}
+	if !supported {
+		a.skipped = true
+		return a.Action.Execute(ctx)
+	}
+
+	name := models.AutoSnapshotName(a.Now())
+	if err := a.Snapshots.Snapshot(ctx, a.VMID, name, "Taken by pvec before "+action); err != nil {
+		return &SnapshotError{Action: action, Name: name, Err: err}
+	}
+	a.taken = name
+	return a.Action.Execute(ctx)
+}
+
+func (a *SnapshotFirstAction) Name() string {
+	return a.Action.Name()
+}
+
+func (a *SnapshotFirstAction) Description() string {
+	return "Snapshot, then " + strings.ToLower(a.Action.Description()[:1]) + a.Action.Description()[1:]
+}
+
+// Taken returns the name of the snapshot taken, "" if none was
+func (a *SnapshotFirstAction) Taken() string {
+	return a.taken
+}
+
+// Skipped reports whether the action ran without a snapshot because the
+// guest's storage can't take one
+func (a *SnapshotFirstAction) Skipped() bool {
+	return a.skipped
+}
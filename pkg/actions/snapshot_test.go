@@ -0,0 +1,75 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// mockSnapshotter records the snapshots taken
+type mockSnapshotter struct {
+	unsupported bool
+	supportErr  error
+	snapshotErr error
+	taken       []string
+}
+
+func (m *mockSnapshotter) SnapshotSupported(ctx context.Context, vmid string) (bool, error) {
+	return !m.unsupported, m.supportErr
+}
+
+func (m *mockSnapshotter) Snapshot(ctx context.Context, vmid, name, description string) error {
+	m.taken = append(m.taken, vmid+" "+name+" "+description)
+	return m.snapshotErr
+}
+
+func snapshotFirst(exec *MockExecutor, snaps *mockSnapshotter) *SnapshotFirstAction {
+	node := &models.VMStatus{VMID: "100", Name: "web"}
+	action := NewSnapshotFirstAction(NewStopAction(exec, node), snaps, node)
+	action.Now = func() time.Time { return time.Date(2026, 10, 17, 18, 30, 0, 0, time.UTC) }
+	return action
+}
+
+func TestSnapshotFirstAction(t *testing.T) {
+	exec, snaps := &MockExecutor{}, &mockSnapshotter{}
+	action := snapshotFirst(exec, snaps)
+
+	assert.Equal(t, "Stop", action.Name())
+	assert.Equal(t, "Snapshot, then force stopping web (100)", action.Description())
+
+	assert.NoError(t, action.Execute(context.Background()))
+	assert.Equal(t, []string{"100 pvec-auto-20261017-183000 Taken by pvec before stop"}, snaps.taken)
+	assert.True(t, exec.StopCalled)
+	assert.Equal(t, "pvec-auto-20261017-183000", action.Taken())
+	assert.False(t, action.Skipped())
+}
+
+func TestSnapshotFirstAction_Unsupported(t *testing.T) {
+	exec, snaps := &MockExecutor{}, &mockSnapshotter{unsupported: true}
+	action := snapshotFirst(exec, snaps)
+
+	assert.NoError(t, action.Execute(context.Background()))
+	assert.Empty(t, snaps.taken)
+	assert.True(t, exec.StopCalled, "a guest that can't be snapshotted is acted on without one")
+	assert.True(t, action.Skipped())
+	assert.Empty(t, action.Taken())
+}
+
+func TestSnapshotFirstAction_Failed(t *testing.T) {
+	exec, snaps := &MockExecutor{}, &mockSnapshotter{snapshotErr: errors.New("task failed: out of space")}
+	action := snapshotFirst(exec, snaps)
+
+	err := action.Execute(context.Background())
+	assert.EqualError(t, err, "snapshot pvec-auto-20261017-183000 failed, stop not sent: task failed: out of space")
+	assert.ErrorAs(t, err, new(*SnapshotError))
+	assert.False(t, exec.StopCalled, "a failed snapshot aborts the action")
+
+	exec, snaps = &MockExecutor{}, &mockSnapshotter{supportErr: errors.New("permission denied")}
+	err = snapshotFirst(exec, snaps).Execute(context.Background())
+	assert.EqualError(t, err, "cannot tell whether the guest can be snapshotted, stop not sent: permission denied")
+	assert.False(t, exec.StopCalled)
+}
@@ -0,0 +1,49 @@
+package actions
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+)
+
+// StartStep is one guest of an ordered start and how long to wait after
+// starting it before moving on to the next
+type StartStep struct {
+	VM    *models.VMStatus
+	Order int // Startup order, or -1 for guests without one
+	Up    time.Duration
+}
+
+// PlanOrderedStart sorts guests the way Proxmox starts them at boot: by
+// their startup order, then by VMID, with guests that have no order last
+// in VMID order. configs maps guest keys to guest configurations; a missing
+// config or an unparseable startup option counts as no order.
+func PlanOrderedStart(guests []*models.VMStatus, configs map[string]map[string]interface{}) []StartStep {
+	steps := make([]StartStep, 0, len(guests))
+	for _, vm := range guests {
+		step := StartStep{VM: vm, Order: -1}
+		if value, ok := configs[vm.Key()]["startup"].(string); ok {
+			if startup, err := configparse.ParseStartup(value); err == nil {
+				if startup.HasOrder {
+					step.Order = startup.Order
+				}
+				step.Up = time.Duration(startup.Up) * time.Second
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool {
+		a, b := steps[i], steps[j]
+		if (a.Order < 0) != (b.Order < 0) {
+			return a.Order >= 0
+		}
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		return a.VM.VMIDNum < b.VM.VMIDNum
+	})
+	return steps
+}
@@ -0,0 +1,41 @@
+package actions
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestPlanOrderedStart(t *testing.T) {
+	guest := func(vmid int) *models.VMStatus {
+		return &models.VMStatus{VMIDNum: vmid, VMID: strconv.Itoa(vmid)}
+	}
+	guests := []*models.VMStatus{guest(105), guest(101), guest(103), guest(102), guest(104), guest(106)}
+	configs := map[string]map[string]interface{}{
+		"101": {"startup": "order=3"},
+		"102": {"startup": "order=1,up=30"},
+		"103": {"startup": "up=10"},       // No order: goes last
+		"104": {"startup": "order=bogus"}, // Unparseable: goes last
+		"105": {"startup": "order=1"},
+		// 106 has no config
+	}
+
+	steps := PlanOrderedStart(guests, configs)
+
+	var order []string
+	for _, step := range steps {
+		order = append(order, step.VM.VMID)
+	}
+	assert.Equal(t, []string{"102", "105", "101", "103", "104", "106"}, order)
+
+	assert.Equal(t, 1, steps[0].Order)
+	assert.Equal(t, 30*time.Second, steps[0].Up)
+	assert.Equal(t, -1, steps[3].Order)
+	assert.Equal(t, 10*time.Second, steps[3].Up, "an up delay applies without an order")
+	assert.Zero(t, steps[4].Up)
+
+	assert.Empty(t, PlanOrderedStart(nil, nil))
+}
@@ -0,0 +1,204 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// UndoWindow is how long after a stop its undo is offered at once
+const UndoWindow = 60 * time.Second
+
+// MaxUndo is the number of stops an UndoBuffer remembers
+const MaxUndo = 20
+
+// ErrNothingToUndo is returned by Undo for a guest that has no stop left
+// to undo
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ErrNotStopped is returned by Undo when the guest is no longer stopped:
+// someone, or something, already brought it back
+var ErrNotStopped = errors.New("no longer stopped")
+
+// UndoState is how far the undo of a stop got
+type UndoState int
+
+const (
+	UndoAvailable UndoState = iota // The guest can be started again
+	UndoRunning                    // The status check or the start is in flight
+	UndoDone                       // Started again
+	UndoFailed                     // A request failed; see Err. Undo may be tried again
+	UndoSkipped                    // The guest wasn't stopped any more, so it was left alone
+)
+
+// String returns the state as shown in the list of stops
+func (s UndoState) String() string {
+	switch s {
+	case UndoAvailable:
+		return "can undo"
+	case UndoRunning:
+		return "undoing"
+	case UndoDone:
+		return "undone"
+	case UndoFailed:
+		return "undo failed"
+	case UndoSkipped:
+		return "no longer stopped"
+	}
+	return "unknown"
+}
+
+// StoppedGuest is a guest pvec stopped or shut down, and what became of
+// its undo
+type StoppedGuest struct {
+	Guest  *models.VMStatus // As it was when the action was sent
+	Action string           // "stop" or "shutdown"
+	At     time.Time        // When the action succeeded
+	State  UndoState
+	Err    error // Why the last undo failed or was skipped
+}
+
+// CanUndo reports whether the guest can be started again: its undo wasn't
+// tried yet, or failed
+func (s StoppedGuest) CanUndo() bool {
+	return s.State == UndoAvailable || s.State == UndoFailed
+}
+
+// Offered reports whether the undo is still offered at once, at now
+func (s StoppedGuest) Offered(now time.Time) bool {
+	return s.CanUndo() && now.Sub(s.At) < UndoWindow
+}
+
+// UndoBuffer remembers the guests pvec stopped, so that a stop of the
+// wrong guest can be undone by starting it again. It is safe for
+// concurrent use.
+type UndoBuffer struct {
+	mu      sync.Mutex
+	entries []StoppedGuest // Oldest first
+}
+
+// NewUndoBuffer returns a buffer that remembers no stop yet
+func NewUndoBuffer() *UndoBuffer {
+	return &UndoBuffer{}
+}
+
+// Record remembers that action stopped guest at the given time. before
+// is the guest's status when the action was sent: a guest that was
+// already stopped gives nothing to undo, and isn't recorded. A new stop of
+// a guest replaces its older one. Record reports whether it remembered
+// the stop.
+func (b *UndoBuffer) Record(guest *models.VMStatus, action string, before models.NodeState, at time.Time) bool {
+	if b == nil || guest == nil || (action != "stop" && action != "shutdown") {
+		return false
+	}
+	if before != models.StateRunning && before != models.StatePaused {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(guest.Key())
+	b.entries = append(b.entries, StoppedGuest{Guest: guest, Action: action, At: at})
+	if len(b.entries) > MaxUndo {
+		b.entries = append([]StoppedGuest(nil), b.entries[len(b.entries)-MaxUndo:]...)
+	}
+	return true
+}
+
+// remove forgets the stop of the guest key. Must be called with mu held.
+func (b *UndoBuffer) remove(key string) {
+	for i, e := range b.entries {
+		if e.Guest.Key() == key {
+			b.entries = append(b.entries[:i], b.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Entries returns the stops remembered, newest first
+func (b *UndoBuffer) Entries() []StoppedGuest {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entries := make([]StoppedGuest, len(b.entries))
+	for i, e := range b.entries {
+		entries[len(b.entries)-1-i] = e
+	}
+	return entries
+}
+
+// Offer returns the newest stop whose undo is still offered at now
+func (b *UndoBuffer) Offer(now time.Time) (StoppedGuest, bool) {
+	for _, e := range b.Entries() {
+		if e.Offered(now) {
+			return e, true
+		}
+	}
+	return StoppedGuest{}, false
+}
+
+// begin marks the undo of the guest key in flight and returns its stop,
+// refusing a guest with no stop left to undo
+func (b *UndoBuffer) begin(key string) (StoppedGuest, error) {
+	if b == nil {
+		return StoppedGuest{}, ErrNothingToUndo
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, e := range b.entries {
+		if e.Guest.Key() != key {
+			continue
+		}
+		if !e.CanUndo() {
+			break
+		}
+		b.entries[i].State, b.entries[i].Err = UndoRunning, nil
+		return b.entries[i], nil
+	}
+	return StoppedGuest{}, fmt.Errorf("%w for %s", ErrNothingToUndo, key)
+}
+
+// finish records how the undo of the guest key ended
+func (b *UndoBuffer) finish(key string, state UndoState, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, e := range b.entries {
+		if e.Guest.Key() == key {
+			b.entries[i].State, b.entries[i].Err = state, err
+			return
+		}
+	}
+}
+
+// Undo starts the guest key again, once status confirms it is still
+// stopped. A guest that is running again, or anything but stopped, is
+// left alone and its undo ends with ErrNotStopped. A failed request can
+// be retried.
+func (b *UndoBuffer) Undo(ctx context.Context, key string, status StatusFunc, executor Executor) error {
+	entry, err := b.begin(key)
+	if err != nil {
+		return err
+	}
+	current, err := status(ctx, key)
+	if err != nil {
+		err = fmt.Errorf("failed to check %s is stopped: %w", key, err)
+		b.finish(key, UndoFailed, err)
+		return err
+	}
+	if current != models.StateStopped {
+		err = fmt.Errorf("%s is %w: it is %s", entry.Guest.Name, ErrNotStopped, current)
+		b.finish(key, UndoSkipped, err)
+		return err
+	}
+	if err := executor.Start(ctx, key); err != nil {
+		b.finish(key, UndoFailed, err)
+		return err
+	}
+	b.finish(key, UndoDone, nil)
+	return nil
+}
@@ -0,0 +1,159 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func stoppedGuest(vmid string) *models.VMStatus {
+	return &models.VMStatus{VMID: vmid, Name: "guest" + vmid, Node: "pve1", Status: models.StateStopped}
+}
+
+func TestUndoBuffer_Record(t *testing.T) {
+	clock := newFakeClock()
+	b := NewUndoBuffer()
+
+	assert.True(t, b.Record(stoppedGuest("100"), "stop", models.StateRunning, clock.Now()))
+	assert.True(t, b.Record(stoppedGuest("101"), "shutdown", models.StatePaused, clock.Now()))
+	assert.False(t, b.Record(stoppedGuest("102"), "stop", models.StateStopped, clock.Now()), "it was already stopped")
+	assert.False(t, b.Record(stoppedGuest("103"), "reboot", models.StateRunning, clock.Now()))
+
+	// A new stop of a guest replaces the older one
+	clock.Advance(time.Second)
+	assert.True(t, b.Record(stoppedGuest("100"), "shutdown", models.StateRunning, clock.Now()))
+	entries := b.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "100", entries[0].Guest.VMID, "newest first")
+	assert.Equal(t, "shutdown", entries[0].Action)
+	assert.Equal(t, "101", entries[1].Guest.VMID)
+
+	for i := 0; i < MaxUndo+5; i++ {
+		b.Record(stoppedGuest(fmt.Sprint(200+i)), "stop", models.StateRunning, clock.Now())
+	}
+	assert.Len(t, b.Entries(), MaxUndo)
+
+	var none *UndoBuffer
+	assert.False(t, none.Record(stoppedGuest("100"), "stop", models.StateRunning, clock.Now()))
+	assert.Empty(t, none.Entries())
+}
+
+func TestUndoBuffer_Offer(t *testing.T) {
+	clock := newFakeClock()
+	b := NewUndoBuffer()
+	_, ok := b.Offer(clock.Now())
+	assert.False(t, ok)
+
+	b.Record(stoppedGuest("100"), "stop", models.StateRunning, clock.Now())
+	clock.Advance(30 * time.Second)
+	b.Record(stoppedGuest("101"), "stop", models.StateRunning, clock.Now())
+
+	offer, ok := b.Offer(clock.Now())
+	require.True(t, ok)
+	assert.Equal(t, "101", offer.Guest.VMID, "the newest stop is offered")
+
+	// Past the window of 100 but not of 101
+	clock.Advance(45 * time.Second)
+	offer, ok = b.Offer(clock.Now())
+	require.True(t, ok)
+	assert.Equal(t, "101", offer.Guest.VMID)
+
+	clock.Advance(15 * time.Second)
+	_, ok = b.Offer(clock.Now())
+	assert.False(t, ok, "the window is over")
+	assert.Len(t, b.Entries(), 2, "the stops can still be undone from the list")
+}
+
+func TestUndoBuffer_Undo(t *testing.T) {
+	clock := newFakeClock()
+	b := NewUndoBuffer()
+	b.Record(stoppedGuest("100"), "stop", models.StateRunning, clock.Now())
+	exec := &scriptedExecutor{}
+
+	require.NoError(t, b.Undo(context.Background(), "100", scriptedStatus(models.StateStopped), exec))
+	assert.Equal(t, []string{"start"}, exec.calls)
+	assert.Equal(t, UndoDone, b.Entries()[0].State)
+	_, ok := b.Offer(clock.Now())
+	assert.False(t, ok, "an undone stop is no longer offered")
+
+	// Undone once only
+	err := b.Undo(context.Background(), "100", scriptedStatus(models.StateStopped), exec)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+	assert.Equal(t, []string{"start"}, exec.calls)
+
+	err = b.Undo(context.Background(), "999", scriptedStatus(models.StateStopped), exec)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+}
+
+func TestUndoBuffer_UndoNotStopped(t *testing.T) {
+	b := NewUndoBuffer()
+	b.Record(stoppedGuest("100"), "shutdown", models.StateRunning, newFakeClock().Now())
+	exec := &scriptedExecutor{}
+
+	// Someone started it again in the meantime: it is left alone
+	err := b.Undo(context.Background(), "100", scriptedStatus(models.StateRunning), exec)
+	assert.ErrorIs(t, err, ErrNotStopped)
+	assert.Contains(t, err.Error(), "it is running")
+	assert.Empty(t, exec.calls)
+	entry := b.Entries()[0]
+	assert.Equal(t, UndoSkipped, entry.State)
+	assert.ErrorIs(t, entry.Err, ErrNotStopped)
+
+	err = b.Undo(context.Background(), "100", scriptedStatus(models.StateStopped), exec)
+	assert.ErrorIs(t, err, ErrNothingToUndo, "a skipped undo isn't tried again")
+}
+
+func TestUndoBuffer_UndoRetry(t *testing.T) {
+	b := NewUndoBuffer()
+	b.Record(stoppedGuest("100"), "stop", models.StateRunning, newFakeClock().Now())
+
+	// The status check fails: nothing is started
+	failing := func(ctx context.Context, vmid string) (models.NodeState, error) {
+		return "", errors.New("status 500")
+	}
+	exec := &scriptedExecutor{}
+	err := b.Undo(context.Background(), "100", failing, exec)
+	assert.ErrorContains(t, err, "failed to check 100 is stopped")
+	assert.Empty(t, exec.calls)
+	assert.Equal(t, UndoFailed, b.Entries()[0].State)
+	_, ok := b.Offer(newFakeClock().Now())
+	assert.True(t, ok, "a failed undo is offered again")
+
+	// The start fails, then succeeds
+	exec.errs = map[string]error{"start": errors.New("status 403")}
+	assert.Error(t, b.Undo(context.Background(), "100", scriptedStatus(models.StateStopped), exec))
+	assert.Equal(t, UndoFailed, b.Entries()[0].State)
+	exec.errs = nil
+	require.NoError(t, b.Undo(context.Background(), "100", scriptedStatus(models.StateStopped), exec))
+	assert.Equal(t, []string{"start", "start"}, exec.calls)
+	assert.Equal(t, UndoDone, b.Entries()[0].State)
+}
+
+func TestUndoBuffer_UndoInFlight(t *testing.T) {
+	b := NewUndoBuffer()
+	b.Record(stoppedGuest("100"), "stop", models.StateRunning, newFakeClock().Now())
+
+	// A second undo while the first checks the status is refused
+	var second error
+	status := func(ctx context.Context, vmid string) (models.NodeState, error) {
+		assert.Equal(t, UndoRunning, b.Entries()[0].State)
+		second = b.Undo(ctx, "100", scriptedStatus(models.StateStopped), &scriptedExecutor{})
+		return models.StateStopped, nil
+	}
+	exec := &scriptedExecutor{}
+	require.NoError(t, b.Undo(context.Background(), "100", status, exec))
+	assert.ErrorIs(t, second, ErrNothingToUndo)
+	assert.Equal(t, []string{"start"}, exec.calls)
+}
+
+func TestUndoState_String(t *testing.T) {
+	assert.Equal(t, "can undo", UndoAvailable.String())
+	assert.Equal(t, "no longer stopped", UndoSkipped.String())
+	assert.Equal(t, "unknown", UndoState(42).String())
+}
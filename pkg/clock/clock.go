@@ -0,0 +1,54 @@
+// Package clock abstracts the wall clock for the code that reads the time,
+// ticks or waits, so its tests can move time by hand rather than sleep.
+// Real is the clock of the running program.
+package clock
+
+import "time"
+
+// Clock tells the time and makes tickers and timers on it
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a ticker sending the time every d, as
+	// time.NewTicker does; d must be positive
+	NewTicker(d time.Duration) Ticker
+	// After sends the time once d has passed, as time.After does
+	After(d time.Duration) <-chan time.Time
+}
+
+// Ticker is the part of time.Ticker used by pvec, as an interface so a
+// fake clock can drive it
+type Ticker interface {
+	// C returns the channel the ticks are sent on; a tick the receiver
+	// isn't ready for is dropped
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// Real is the wall clock
+var Real Clock = realClock{}
+
+// OrReal returns c, or Real when c is nil, for the optional clocks of
+// structs and configs
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker adapts time.Ticker, whose C is a field, to Ticker
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time   { return r.t.C }
+func (r realTicker) Reset(d time.Duration) { r.t.Reset(d) }
+func (r realTicker) Stop()                 { r.t.Stop() }
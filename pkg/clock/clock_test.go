@@ -0,0 +1,34 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	assert.False(t, Real.Now().Before(before))
+
+	select {
+	case <-Real.After(time.Millisecond):
+	case <-time.After(5 * time.Second):
+		t.Fatal("After should fire")
+	}
+
+	ticker := Real.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	select {
+	case <-ticker.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("The ticker should tick")
+	}
+	ticker.Reset(time.Hour)
+}
+
+func TestOrReal(t *testing.T) {
+	assert.Equal(t, Real, OrReal(nil))
+	var custom Clock = realClock{}
+	assert.Equal(t, custom, OrReal(custom))
+}
@@ -1,14 +1,69 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/viper"
+	"github.com/tsupplis/pvec/pkg/maintenance"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/redact"
 )
 
+// Overcommit ratios, in percent, highlighted by default: memory can't be
+// shared the way idle cores can
+const (
+	DefaultOvercommitCPUWarning = 200.0
+	DefaultOvercommitMemWarning = 100.0
+)
+
+// DefaultCPUPressureWarning is the CPU usage, in percent, from which a
+// guest and its node are both considered busy
+const DefaultCPUPressureWarning = 90.0
+
+// DefaultRequestLogSize is the number of API requests kept for the debug
+// screen
+const DefaultRequestLogSize = 50
+
+// DefaultDownAlertAfter is how long a guest may stay down before the
+// Since column shows it in the alert color
+const DefaultDownAlertAfter = 24 * time.Hour
+
+// DefaultShutdownEscalateAfter is how long a shutdown sent from the list
+// may take before pvec offers to force the guest off
+const DefaultShutdownEscalateAfter = 2 * time.Minute
+
+// DefaultCheatSheetTimeout is how long the cheat-sheet stays over the
+// list
+const DefaultCheatSheetTimeout = 10 * time.Second
+
+// DefaultRefreshActiveWindow is how long the list keeps refreshing at
+// refresh_interval_active after an action, a state change or a key press
+const DefaultRefreshActiveWindow = 5 * time.Minute
+
+// LowBandwidthRefreshInterval is the shortest idle refresh interval with
+// low_bandwidth set
+const LowBandwidthRefreshInterval = 30 * time.Second
+
+// lowBandwidthOff are the features low_bandwidth turns off unless the
+// features block sets them: node_status stays, as it tells offline nodes
+var lowBandwidthOff = []string{"storage", "pools", "ha", "replication", "tasks"}
+
+// DefaultOwnerRegex finds the owner of a guest in a description line such
+// as "owner: alice"
+const DefaultOwnerRegex = `(?i)owner:\s*(\S+)`
+
 // Config holds the application configuration
 type Config struct {
 	APIUrl          string        `mapstructure:"api_url"`
@@ -16,6 +71,170 @@ type Config struct {
 	TokenSecret     string        `mapstructure:"token_secret"`
 	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
 	SkipTLSVerify   bool          `mapstructure:"skip_tls_verify"`
+	// TLSFingerprint pins the server certificate by its SHA-256
+	// fingerprint, e.g. AB:CD:…, trusting it whoever signed it
+	TLSFingerprint string `mapstructure:"tls_fingerprint"`
+	// TLSTrustUnset is set by Load when the files set neither
+	// skip_tls_verify nor tls_fingerprint: a certificate the system
+	// doesn't trust is then put to the user on the first connection
+	TLSTrustUnset bool `mapstructure:"-"`
+	// RefreshIntervalIdle replaces refresh_interval when set, and
+	// RefreshIntervalActive takes over for RefreshActiveWindow after an
+	// action, a state change or a key press; 0 refreshes at the idle
+	// interval throughout
+	RefreshIntervalIdle   time.Duration `mapstructure:"refresh_interval_idle"`
+	RefreshIntervalActive time.Duration `mapstructure:"refresh_interval_active"`
+	RefreshActiveWindow   time.Duration `mapstructure:"refresh_active_window"`
+	// ActionTimeout bounds a power action request; a shutdown of a stuck
+	// guest can take well over a minute
+	ActionTimeout time.Duration `mapstructure:"action_timeout"`
+	// RestartTimeout is how long a restart waits for the guest to shut
+	// down before offering to force it off
+	RestartTimeout time.Duration `mapstructure:"restart_timeout"`
+	// ShutdownEscalateAfter is how long a guest may stay running after a
+	// shutdown sent from the list before pvec offers to force it off; 0
+	// never does
+	ShutdownEscalateAfter time.Duration `mapstructure:"shutdown_escalate_after"`
+	// RefreshTimeout bounds each refresh of the guest list
+	RefreshTimeout time.Duration `mapstructure:"refresh_timeout"`
+	// AllowNodePowerActions enables rebooting and shutting down whole
+	// nodes from the list; it is off unless set in the file
+	AllowNodePowerActions bool `mapstructure:"allow_node_power_actions"`
+	// AllowClearLock enables clearing a guest's lock from its details. A
+	// lock cleared under a task still running can corrupt the guest, so
+	// it is off unless set in the file.
+	AllowClearLock bool `mapstructure:"allow_clear_lock"`
+	// SnapshotBefore lists the power actions, e.g. stop and reboot, before
+	// which pvec snapshots the guest; the action waits for the snapshot
+	// and is not sent if it fails
+	SnapshotBefore []string `mapstructure:"snapshot_before"`
+	// MaintenanceWindows are recurring windows, such as a nightly backup,
+	// in which guests may be locked, e.g. "backup 01:00-04:00 daily"; an
+	// action asked for in one is put to the user with a warning first
+	MaintenanceWindows []string `mapstructure:"maintenance_windows"`
+
+	// DefaultNodeFilter, DefaultStatusFilter and DefaultTextFilter narrow
+	// the list at startup, for instance to the one node being worked on
+	DefaultNodeFilter   string `mapstructure:"default_node_filter"`
+	DefaultStatusFilter string `mapstructure:"default_status_filter"`
+	DefaultTextFilter   string `mapstructure:"default_text_filter"`
+
+	// OnStateChangeCmd is run through the shell whenever a guest changes state
+	OnStateChangeCmd string `mapstructure:"on_state_change_cmd"`
+	// StateChangeFilter restricts the hook to transitions like "running->stopped" or "*->stopped"
+	StateChangeFilter []string `mapstructure:"state_change_filter"`
+
+	// UseUnicode draws box-drawing separators and arrows; disable it for
+	// terminals or fonts that render them as garbage
+	UseUnicode bool `mapstructure:"use_unicode"`
+	// Color enables colored output; NO_COLOR and --no-color override it
+	Color bool `mapstructure:"color"`
+	// ConfigSweep reads every guest's config, and the QEMU state of running
+	// VMs, in the background after each refresh; disable it on large
+	// clusters to only read configs on demand
+	ConfigSweep bool `mapstructure:"config_sweep"`
+	// LowBandwidth saves traffic on a metered link: the idle refresh
+	// interval is stretched to LowBandwidthRefreshInterval, the optional
+	// sources the features block doesn't set aren't read, and a refresh
+	// that read the same guests as the last one is not applied
+	LowBandwidth bool `mapstructure:"low_bandwidth"`
+	// LowBandwidthCoarse, with LowBandwidth, doesn't apply a refresh whose
+	// guests only differ in usage or uptime either
+	LowBandwidthCoarse bool `mapstructure:"low_bandwidth_coarse"`
+
+	// OvercommitCPUWarning and OvercommitMemWarning are the percentages of
+	// a node's cores and memory assigned to guests from which the node
+	// summary highlights the ratio
+	OvercommitCPUWarning float64 `mapstructure:"overcommit_cpu_warning"`
+	OvercommitMemWarning float64 `mapstructure:"overcommit_mem_warning"`
+
+	// CPUPressureWarning is the CPU usage from which a running guest whose
+	// node is at least as busy is shown in the alert color: a busy guest
+	// on an idle node is fine, one on a saturated node is starved
+	CPUPressureWarning float64 `mapstructure:"cpu_pressure_warning"`
+	// ShowNodeLoad follows the CPU of each running guest with the load of
+	// its node, e.g. "87.0% (node 96%)"
+	ShowNodeLoad bool `mapstructure:"show_node_load"`
+
+	// UpdateCheck looks for a newer pvec release on GitHub at startup; it
+	// is off unless set, so pvec never contacts GitHub on its own
+	UpdateCheck bool `mapstructure:"update_check"`
+
+	// RequestLogSize is the number of API requests kept for the debug
+	// screen, Ctrl+D; 0 keeps none
+	RequestLogSize int `mapstructure:"request_log_size"`
+
+	// DownAlertAfter is how long a guest may stay stopped before the
+	// Since column shows it in the alert color; 0 never does, unless HA
+	// is asked to keep the guest started
+	DownAlertAfter time.Duration `mapstructure:"down_alert_after"`
+
+	// CheatSheetTimeout is how long the cheat-sheet, ?, stays over the
+	// list; 0 keeps it until a key other than the navigation ones
+	CheatSheetTimeout time.Duration `mapstructure:"cheat_sheet_timeout"`
+
+	// OwnerRegex extracts the owner of a guest from its description: its
+	// first group, or the whole match without one
+	OwnerRegex string `mapstructure:"owner_regex"`
+
+	// Features turns the optional sources of the refresh on or off by
+	// name (FeatureNames); one left out is probed on first connect and
+	// dropped when the server doesn't have it or the token can't read it
+	Features map[string]bool `mapstructure:"features"`
+
+	// NodeProbes maps node names to a host:port each, dialed on every
+	// refresh to check the node's network apart from the API. Viper
+	// lowercases the names, so they match nodes ignoring case.
+	NodeProbes map[string]string `mapstructure:"node_probes"`
+
+	// ClonePresets are the quick clones offered from the list, each
+	// cloning a guest, usually a template, under the next free name of a
+	// pattern
+	ClonePresets []ClonePreset `mapstructure:"clone_presets"`
+
+	// Clusters lists several clusters merged into one list. When set, the
+	// top-level api_url and token are not used.
+	Clusters []ClusterConfig `mapstructure:"clusters"`
+}
+
+// ClonePreset is a quick clone of a guest
+type ClonePreset struct {
+	Name       string `mapstructure:"name"`
+	SourceVMID int    `mapstructure:"source_vmid"`
+	// NamePattern names the clone, with {n} replaced by the index
+	// following the highest one taken, e.g. "dev-web-{n}"; {n:3} pads it
+	// with zeros to 3 digits
+	NamePattern string `mapstructure:"name_pattern"`
+	// TargetStorage receives the disks of a full clone; "" keeps the
+	// source's
+	TargetStorage string `mapstructure:"target_storage"`
+	// Full copies the disks; a linked clone, which shares them, needs the
+	// source to be a template
+	Full bool `mapstructure:"full"`
+	// Start starts the clone once created
+	Start bool `mapstructure:"start"`
+}
+
+// ClusterConfig is one of the clusters listed together
+type ClusterConfig struct {
+	// Name identifies the cluster in the Cluster column and guest keys
+	Name        string `mapstructure:"name"`
+	APIUrl      string `mapstructure:"api_url"`
+	TokenID     string `mapstructure:"token_id"`
+	TokenSecret string `mapstructure:"token_secret"`
+	// SkipTLSVerify defaults to the top-level skip_tls_verify
+	SkipTLSVerify bool `mapstructure:"skip_tls_verify"`
+	// TLSFingerprint pins the cluster's certificate; it isn't inherited
+	TLSFingerprint string `mapstructure:"tls_fingerprint"`
+	// TLSTrustUnset is set by Load when neither the cluster nor the top
+	// level set skip_tls_verify, and the cluster sets no tls_fingerprint,
+	// as Config.TLSTrustUnset
+	TLSTrustUnset bool `mapstructure:"-"`
+}
+
+// GetAuthToken returns the formatted authentication token of the cluster
+func (c ClusterConfig) GetAuthToken() string {
+	return fmt.Sprintf("PVEAPIToken=%s=%s", c.TokenID, c.TokenSecret)
 }
 
 // Loader is the interface for loading configuration
@@ -23,42 +242,152 @@ type Loader interface {
 	Load() (*Config, error)
 }
 
+// Saver is the interface for persisting configuration
+type Saver interface {
+	Save(cfg *Config) error
+}
+
+// Store loads and saves configuration
+type Store interface {
+	Loader
+	Saver
+}
+
 // ViperLoader loads configuration using Viper
 type ViperLoader struct {
-	configPath string
+	configPath string  // The file Save writes to
+	layers     []Layer // Files Load merges; nil to read configPath alone
+
+	mu        sync.Mutex
+	sources   map[string]Layer       // Layer each effective setting was read from
+	inherited map[string]interface{} // Settings of the layers below the user's
+	own       map[string]interface{} // Settings of the user's file
+	project   map[string]interface{} // Settings of the project's .pvecrc
 }
 
-// NewLoader creates a new configuration loader
-// If configPath is empty, it defaults to ~/.pvecrc
-func NewLoader(configPath string) Loader {
+// NewLoader creates a new configuration loader for exactly configPath.
+// If configPath is empty, it merges DefaultLayers instead and saves to
+// DefaultPath().
+func NewLoader(configPath string) Store {
 	if configPath == "" {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			configPath = filepath.Join(home, ".pvecrc")
-		}
+		return &ViperLoader{configPath: DefaultPath(), layers: DefaultLayers()}
 	}
 	return &ViperLoader{configPath: configPath}
 }
 
-// Load reads and parses the configuration file
+// DefaultPath returns the configuration file used when none is given:
+// pvec/config.json under the user config directory (%AppData% on Windows).
+// Elsewhere ~/.pvecrc is still used unless that newer file exists, so
+// existing setups keep working. It returns "" if no directory is known.
+func DefaultPath() string {
+	configDir, _ := os.UserConfigDir()
+	home, _ := os.UserHomeDir()
+	return defaultPath(runtime.GOOS, configDir, home)
+}
+
+// defaultPath is DefaultPath for the given OS and directories, for tests
+func defaultPath(goos, configDir, home string) string {
+	var configFile string
+	if configDir != "" {
+		configFile = filepath.Join(configDir, "pvec", "config.json")
+	}
+	if goos == "windows" || home == "" {
+		return configFile
+	}
+	if configFile != "" {
+		if _, err := os.Stat(configFile); err == nil {
+			return configFile
+		}
+	}
+	return filepath.Join(home, ".pvecrc")
+}
+
+// Load reads and parses the configuration file. The token secret is
+// registered with redact, and masked in the errors, which may quote a
+// file.
 func (l *ViperLoader) Load() (*Config, error) {
+	cfg, err := l.load()
+	return cfg, redact.Error(err)
+}
+
+func (l *ViperLoader) load() (*Config, error) {
 	v := viper.New()
 
 	// Set defaults
 	v.SetDefault("refresh_interval", "5s")
-	v.SetDefault("skip_tls_verify", true)
+	v.SetDefault("action_timeout", "60s")
+	v.SetDefault("restart_timeout", "120s")
+	v.SetDefault("shutdown_escalate_after", DefaultShutdownEscalateAfter.String())
+	v.SetDefault("cheat_sheet_timeout", DefaultCheatSheetTimeout.String())
+	v.SetDefault("refresh_timeout", "10s")
+	v.SetDefault("use_unicode", true)
+	v.SetDefault("color", true)
+	v.SetDefault("config_sweep", true)
+	v.SetDefault("overcommit_cpu_warning", DefaultOvercommitCPUWarning)
+	v.SetDefault("overcommit_mem_warning", DefaultOvercommitMemWarning)
+	v.SetDefault("cpu_pressure_warning", DefaultCPUPressureWarning)
+	v.SetDefault("request_log_size", DefaultRequestLogSize)
+	v.SetDefault("down_alert_after", DefaultDownAlertAfter.String())
+	v.SetDefault("owner_regex", DefaultOwnerRegex)
+	v.SetDefault("refresh_active_window", DefaultRefreshActiveWindow.String())
 
-	// Set config file path
-	if l.configPath != "" {
-		v.SetConfigFile(l.configPath)
-		v.SetConfigType("json") // Explicitly set JSON type for .pvecrc files
-	} else {
+	if l.configPath == "" {
 		return nil, fmt.Errorf("config path not set")
 	}
 
-	// Read config file
-	if err := v.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+	// Merge the layers, each in whichever format it is written. Only a
+	// file given alone must exist.
+	layers := l.layers
+	if layers == nil {
+		layers = []Layer{{Name: LayerUser, Path: l.configPath}}
+	}
+	sources := make(map[string]Layer)
+	var inherited, own, project map[string]interface{}
+	found := false
+	for _, layer := range layers {
+		settings, err := readLayer(layer.Path)
+		if l.layers != nil && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		if err := v.MergeConfigMap(settings); err != nil {
+			return nil, fmt.Errorf("failed to merge %s: %w", layer.Path, err)
+		}
+		for key := range settings {
+			sources[key] = layer
+		}
+		switch layer.Name {
+		case LayerSystem:
+			inherited = settings
+		case LayerUser:
+			own = settings
+		case LayerProject:
+			project = settings
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("failed to read config file: none of %s exists", layerPaths(layers))
+	}
+	l.mu.Lock()
+	l.sources, l.inherited, l.own, l.project = sources, inherited, own, project
+	l.mu.Unlock()
+	setIn := func(key string) string {
+		if layer, ok := sources[key]; ok {
+			return " (set in " + layer.Path + ")"
+		}
+		return ""
+	}
+
+	// Known before parsing, so that errors quoting them mask them
+	redact.AddSecret(v.GetString("token_secret"))
+	rawClusters, _ := v.Get("clusters").([]interface{})
+	for _, raw := range rawClusters {
+		if cluster, ok := raw.(map[string]interface{}); ok {
+			redact.AddSecret(fmt.Sprint(cluster["token_secret"]))
+		}
 	}
 
 	// Parse into struct
@@ -67,33 +396,479 @@ func (l *ViperLoader) Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
+	cfg.TLSTrustUnset = !v.IsSet("skip_tls_verify") && !v.IsSet("tls_fingerprint")
+
 	// Validate required fields
-	if cfg.APIUrl == "" {
-		return nil, fmt.Errorf("api_url is required")
+	if len(cfg.Clusters) > 0 {
+		if err := validateClusters(cfg.Clusters, rawClusters, cfg.SkipTLSVerify, !v.IsSet("skip_tls_verify")); err != nil {
+			return nil, fmt.Errorf("%w%s", err, setIn("clusters"))
+		}
+	} else {
+		if cfg.APIUrl == "" {
+			return nil, fmt.Errorf("api_url is required")
+		}
+		if cfg.TokenID == "" {
+			return nil, fmt.Errorf("token_id is required")
+		}
+		if cfg.TokenSecret == "" {
+			return nil, fmt.Errorf("token_secret is required")
+		}
+	}
+	if cfg.TLSFingerprint != "" && !validFingerprint(cfg.TLSFingerprint) {
+		return nil, fmt.Errorf("tls_fingerprint must be a SHA-256 in hex, e.g. AB:CD:…, got %q%s", cfg.TLSFingerprint, setIn("tls_fingerprint"))
+	}
+	if cfg.OvercommitCPUWarning <= 0 {
+		return nil, fmt.Errorf("overcommit_cpu_warning must be a positive percentage%s", setIn("overcommit_cpu_warning"))
+	}
+	if cfg.OvercommitMemWarning <= 0 {
+		return nil, fmt.Errorf("overcommit_mem_warning must be a positive percentage%s", setIn("overcommit_mem_warning"))
+	}
+	if cfg.CPUPressureWarning <= 0 || cfg.CPUPressureWarning > 100 {
+		return nil, fmt.Errorf("cpu_pressure_warning must be a percentage between 0 and 100%s", setIn("cpu_pressure_warning"))
+	}
+	if cfg.RequestLogSize < 0 {
+		return nil, fmt.Errorf("request_log_size must not be negative%s", setIn("request_log_size"))
+	}
+	if cfg.ShutdownEscalateAfter < 0 {
+		return nil, fmt.Errorf("shutdown_escalate_after must not be negative%s", setIn("shutdown_escalate_after"))
 	}
-	if cfg.TokenID == "" {
-		return nil, fmt.Errorf("token_id is required")
+	if cfg.DownAlertAfter < 0 {
+		return nil, fmt.Errorf("down_alert_after must not be negative%s", setIn("down_alert_after"))
 	}
-	if cfg.TokenSecret == "" {
-		return nil, fmt.Errorf("token_secret is required")
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"refresh_interval_idle", cfg.RefreshIntervalIdle},
+		{"refresh_interval_active", cfg.RefreshIntervalActive},
+		{"refresh_active_window", cfg.RefreshActiveWindow},
+		{"cheat_sheet_timeout", cfg.CheatSheetTimeout},
+	} {
+		if d.value < 0 {
+			return nil, fmt.Errorf("%s must not be negative%s", d.key, setIn(d.key))
+		}
+	}
+	if _, err := regexp.Compile(cfg.OwnerRegex); err != nil {
+		return nil, fmt.Errorf("owner_regex is not a valid regular expression: %w%s", err, setIn("owner_regex"))
+	}
+	if !validStatusFilter(cfg.DefaultStatusFilter) {
+		return nil, fmt.Errorf("default_status_filter must be one of %s, got %q%s",
+			strings.Join(statusFilters, ", "), cfg.DefaultStatusFilter, setIn("default_status_filter"))
+	}
+	for name := range cfg.Features {
+		if !slices.Contains(FeatureNames, name) {
+			return nil, fmt.Errorf("features lists %q; the features are %s%s",
+				name, strings.Join(FeatureNames, ", "), setIn("features"))
+		}
+	}
+	for node, addr := range cfg.NodeProbes {
+		if host, port, err := net.SplitHostPort(addr); err != nil || host == "" || port == "" {
+			return nil, fmt.Errorf("node_probes gives %s the address %q; expected host:port%s", node, addr, setIn("node_probes"))
+		}
+	}
+	if err := validateClonePresets(cfg.ClonePresets); err != nil {
+		return nil, fmt.Errorf("%w%s", err, setIn("clone_presets"))
+	}
+	for i, action := range cfg.SnapshotBefore {
+		if !slices.Contains(SnapshotActions, strings.ToLower(action)) {
+			return nil, fmt.Errorf("snapshot_before lists %q; the actions are %s%s",
+				action, strings.Join(SnapshotActions, ", "), setIn("snapshot_before"))
+		}
+		cfg.SnapshotBefore[i] = strings.ToLower(action)
+	}
+	if _, err := maintenance.ParseAll(cfg.MaintenanceWindows); err != nil {
+		return nil, fmt.Errorf("maintenance_windows: %w%s", err, setIn("maintenance_windows"))
 	}
 
 	return &cfg, nil
 }
 
-// Save writes the configuration back to file
+// Save writes the configuration back to file, in the format the file
+// already has (JSON for a new file unless its extension says otherwise)
+// and keeping the order of the keys it already holds. Comments are not
+// kept. When layered, only the user's file is written, and settings
+// equal to the system file's are left to it.
 func (l *ViperLoader) Save(cfg *Config) error {
-	v := viper.New()
-	v.SetConfigFile(l.configPath)
-	v.SetConfigType("json")
+	redact.AddSecret(cfg.TokenSecret)
+	for _, cluster := range cfg.Clusters {
+		redact.AddSecret(cluster.TokenSecret)
+	}
+	return redact.Error(l.save(cfg))
+}
+
+func (l *ViperLoader) save(cfg *Config) error {
+	var settings []setting
+	set := func(key string, value interface{}) {
+		settings = append(settings, setting{key: key, value: value})
+	}
 
-	v.Set("api_url", cfg.APIUrl)
-	v.Set("token_id", cfg.TokenID)
-	v.Set("token_secret", cfg.TokenSecret)
-	v.Set("refresh_interval", cfg.RefreshInterval.String())
-	v.Set("skip_tls_verify", cfg.SkipTLSVerify)
+	set("api_url", cfg.APIUrl)
+	set("token_id", cfg.TokenID)
+	set("token_secret", cfg.TokenSecret)
+	set("refresh_interval", cfg.RefreshInterval.String())
+	if cfg.SkipTLSVerify || !cfg.TLSTrustUnset {
+		// Left out until decided, so that an untrusted certificate is
+		// still put to the user
+		set("skip_tls_verify", cfg.SkipTLSVerify)
+	}
+	if cfg.TLSFingerprint != "" {
+		set("tls_fingerprint", cfg.TLSFingerprint)
+	}
+	if cfg.RefreshIntervalIdle > 0 {
+		set("refresh_interval_idle", cfg.RefreshIntervalIdle.String())
+	}
+	if cfg.RefreshIntervalActive > 0 {
+		set("refresh_interval_active", cfg.RefreshIntervalActive.String())
+	}
+	if cfg.RefreshActiveWindow != DefaultRefreshActiveWindow {
+		set("refresh_active_window", cfg.RefreshActiveWindow.String())
+	}
+	if cfg.ActionTimeout > 0 {
+		set("action_timeout", cfg.ActionTimeout.String())
+	}
+	if cfg.RestartTimeout > 0 {
+		set("restart_timeout", cfg.RestartTimeout.String())
+	}
+	if cfg.ShutdownEscalateAfter != DefaultShutdownEscalateAfter {
+		set("shutdown_escalate_after", cfg.ShutdownEscalateAfter.String())
+	}
+	if cfg.RefreshTimeout > 0 {
+		set("refresh_timeout", cfg.RefreshTimeout.String())
+	}
+	if cfg.AllowNodePowerActions {
+		set("allow_node_power_actions", true)
+	}
+	if cfg.AllowClearLock {
+		set("allow_clear_lock", true)
+	}
+	if len(cfg.SnapshotBefore) > 0 {
+		set("snapshot_before", cfg.SnapshotBefore)
+	}
+	if len(cfg.MaintenanceWindows) > 0 {
+		set("maintenance_windows", cfg.MaintenanceWindows)
+	}
+	if cfg.DefaultNodeFilter != "" {
+		set("default_node_filter", cfg.DefaultNodeFilter)
+	}
+	if cfg.DefaultStatusFilter != "" {
+		set("default_status_filter", cfg.DefaultStatusFilter)
+	}
+	if cfg.DefaultTextFilter != "" {
+		set("default_text_filter", cfg.DefaultTextFilter)
+	}
+	if cfg.OnStateChangeCmd != "" {
+		set("on_state_change_cmd", cfg.OnStateChangeCmd)
+	}
+	if len(cfg.StateChangeFilter) > 0 {
+		set("state_change_filter", cfg.StateChangeFilter)
+	}
+	if !cfg.UseUnicode {
+		set("use_unicode", false)
+	}
+	if !cfg.Color {
+		set("color", false)
+	}
+	if !cfg.ConfigSweep {
+		set("config_sweep", false)
+	}
+	if cfg.LowBandwidth {
+		set("low_bandwidth", true)
+	}
+	if cfg.LowBandwidthCoarse {
+		set("low_bandwidth_coarse", true)
+	}
+	if cfg.UpdateCheck {
+		set("update_check", true)
+	}
+	if cfg.OvercommitCPUWarning > 0 {
+		set("overcommit_cpu_warning", cfg.OvercommitCPUWarning)
+	}
+	if cfg.OvercommitMemWarning > 0 {
+		set("overcommit_mem_warning", cfg.OvercommitMemWarning)
+	}
+	if cfg.CPUPressureWarning > 0 && cfg.CPUPressureWarning != DefaultCPUPressureWarning {
+		set("cpu_pressure_warning", cfg.CPUPressureWarning)
+	}
+	if cfg.ShowNodeLoad {
+		set("show_node_load", true)
+	}
+	if cfg.RequestLogSize != DefaultRequestLogSize {
+		set("request_log_size", cfg.RequestLogSize)
+	}
+	if cfg.DownAlertAfter != DefaultDownAlertAfter {
+		set("down_alert_after", cfg.DownAlertAfter.String())
+	}
+	if cfg.CheatSheetTimeout != DefaultCheatSheetTimeout {
+		set("cheat_sheet_timeout", cfg.CheatSheetTimeout.String())
+	}
+	if cfg.OwnerRegex != "" && cfg.OwnerRegex != DefaultOwnerRegex {
+		set("owner_regex", cfg.OwnerRegex)
+	}
+	if len(cfg.Features) > 0 {
+		// A table in TOML, so among the last
+		set("features", cfg.Features)
+	}
+	if len(cfg.NodeProbes) > 0 {
+		// A table in TOML too, so among the last
+		set("node_probes", cfg.NodeProbes)
+	}
+	if len(cfg.ClonePresets) > 0 {
+		set("clone_presets", clonePresetSettings(cfg.ClonePresets))
+	}
+	if len(cfg.Clusters) > 0 {
+		// Last, as TOML writes them as tables, which end the top-level keys
+		set("clusters", clusterSettings(cfg.Clusters, cfg.SkipTLSVerify))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// A project's settings belong to its .pvecrc: unless changed since,
+	// the user's file keeps its own value, if any
+	kept := settings[:0]
+	for _, s := range settings {
+		if value, ok := l.project[s.key]; ok && fmt.Sprint(value) == fmt.Sprint(s.value) {
+			own, ok := l.own[s.key]
+			if !ok {
+				continue
+			}
+			s.value = own
+		}
+		if value, ok := l.inherited[s.key]; ok && fmt.Sprint(value) == fmt.Sprint(s.value) {
+			continue
+		}
+		kept = append(kept, s)
+	}
+	settings = kept
+
+	existing, _ := os.ReadFile(l.configPath)
+	format, err := detectFormat(l.configPath, existing)
+	if err != nil {
+		format = formatJSON // Unreadable anyway; rewrite it as a fresh file
+	}
+	orderSettings(settings, keyOrder(format, existing))
+	data, err := encodeSettings(format, settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if dir := filepath.Dir(l.configPath); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(l.configPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	// What was written now overrides the system file, not a project one
+	if l.sources == nil {
+		l.sources = make(map[string]Layer)
+	}
+	for _, s := range settings {
+		if l.sources[s.key].Name != LayerProject {
+			l.sources[s.key] = Layer{Name: LayerUser, Path: l.configPath}
+		}
+	}
+	return nil
+}
+
+// Source returns the file the effective value of key was read from by
+// the last Load or written by Save, or "" when it is a default
+func (l *ViperLoader) Source(key string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sources[strings.ToLower(key)].Path
+}
+
+// SavePath returns the file Save writes to
+func (l *ViperLoader) SavePath() string {
+	return l.configPath
+}
+
+// validateClusters checks that every cluster is complete and named
+// uniquely. raw holds the clusters as read, to tell an unset
+// skip_tls_verify, which takes skipTLSVerify, from a false one; the trust
+// of a cluster is left unset when skipUnset, the top level's being so.
+func validateClusters(clusters []ClusterConfig, raw []interface{}, skipTLSVerify, skipUnset bool) error {
+	names := make(map[string]bool, len(clusters))
+	for i := range clusters {
+		c := &clusters[i]
+		switch {
+		case c.Name == "":
+			return fmt.Errorf("clusters[%d]: name is required", i)
+		case strings.Contains(c.Name, "/"):
+			return fmt.Errorf("clusters[%d]: name %q must not contain /", i, c.Name)
+		case names[c.Name]:
+			return fmt.Errorf("clusters[%d]: name %q is used twice", i, c.Name)
+		case c.APIUrl == "":
+			return fmt.Errorf("clusters[%d] (%s): api_url is required", i, c.Name)
+		case c.TokenID == "":
+			return fmt.Errorf("clusters[%d] (%s): token_id is required", i, c.Name)
+		case c.TokenSecret == "":
+			return fmt.Errorf("clusters[%d] (%s): token_secret is required", i, c.Name)
+		case c.TLSFingerprint != "" && !validFingerprint(c.TLSFingerprint):
+			return fmt.Errorf("clusters[%d] (%s): tls_fingerprint must be a SHA-256 in hex, got %q", i, c.Name, c.TLSFingerprint)
+		}
+		names[c.Name] = true
+		if i < len(raw) {
+			if settings, ok := raw[i].(map[string]interface{}); ok {
+				if _, set := settings["skip_tls_verify"]; !set {
+					c.SkipTLSVerify = skipTLSVerify
+					c.TLSTrustUnset = skipUnset && c.TLSFingerprint == ""
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateClonePresets checks that every clone preset is complete, with
+// a valid name pattern, and named uniquely
+func validateClonePresets(presets []ClonePreset) error {
+	names := make(map[string]bool, len(presets))
+	for i, p := range presets {
+		switch {
+		case p.Name == "":
+			return fmt.Errorf("clone_presets[%d]: name is required", i)
+		case names[p.Name]:
+			return fmt.Errorf("clone_presets[%d]: name %q is used twice", i, p.Name)
+		case p.SourceVMID <= 0:
+			return fmt.Errorf("clone_presets[%d] (%s): source_vmid is required", i, p.Name)
+		}
+		if _, err := models.ParseNamePattern(p.NamePattern); err != nil {
+			return fmt.Errorf("clone_presets[%d] (%s): name_pattern %w", i, p.Name, err)
+		}
+		names[p.Name] = true
+	}
+	return nil
+}
+
+// clonePresetSettings returns clone presets as written to the file,
+// leaving out the options left to their default
+func clonePresetSettings(presets []ClonePreset) []map[string]interface{} {
+	settings := make([]map[string]interface{}, len(presets))
+	for i, p := range presets {
+		settings[i] = map[string]interface{}{
+			"name":         p.Name,
+			"source_vmid":  p.SourceVMID,
+			"name_pattern": p.NamePattern,
+		}
+		if p.TargetStorage != "" {
+			settings[i]["target_storage"] = p.TargetStorage
+		}
+		if p.Full {
+			settings[i]["full"] = true
+		}
+		if p.Start {
+			settings[i]["start"] = true
+		}
+	}
+	return settings
+}
+
+// clusterSettings returns clusters as written to the file. skip_tls_verify
+// is left out where it is the top-level skipTLSVerify, so that it is still
+// inherited.
+func clusterSettings(clusters []ClusterConfig, skipTLSVerify bool) []map[string]interface{} {
+	settings := make([]map[string]interface{}, len(clusters))
+	for i, c := range clusters {
+		settings[i] = map[string]interface{}{
+			"name":         c.Name,
+			"api_url":      c.APIUrl,
+			"token_id":     c.TokenID,
+			"token_secret": c.TokenSecret,
+		}
+		if c.SkipTLSVerify != skipTLSVerify {
+			settings[i]["skip_tls_verify"] = c.SkipTLSVerify
+		}
+		if c.TLSFingerprint != "" {
+			settings[i]["tls_fingerprint"] = c.TLSFingerprint
+		}
+	}
+	return settings
+}
+
+// FeatureNames are the optional sources the features block turns on or
+// off
+var FeatureNames = []string{"node_status", "storage", "pools", "ha", "replication", "tasks"}
+
+// SnapshotActions are the power actions snapshot_before accepts
+var SnapshotActions = []string{"start", "shutdown", "reboot", "stop", "resume"}
+
+// SnapshotsBefore reports whether snapshot_before lists action
+func (c *Config) SnapshotsBefore(action string) bool {
+	return slices.Contains(c.SnapshotBefore, action)
+}
+
+// Windows returns the maintenance windows; Load has checked they parse
+func (c *Config) Windows() maintenance.Windows {
+	windows, _ := maintenance.ParseAll(c.MaintenanceWindows)
+	return windows
+}
+
+// statusFilters are the guest statuses default_status_filter accepts
+var statusFilters = []string{"running", "stopped", "paused", "hibernated", "unknown"}
+
+// OwnerPattern returns owner_regex compiled, or the default when it is
+// unset; Load already rejected an invalid one
+func (c *Config) OwnerPattern() *regexp.Regexp {
+	if c != nil && c.OwnerRegex != "" {
+		if re, err := regexp.Compile(c.OwnerRegex); err == nil {
+			return re
+		}
+	}
+	return regexp.MustCompile(DefaultOwnerRegex)
+}
+
+// IdleRefreshInterval returns refresh_interval_idle, or refresh_interval
+// when it is unset, stretched to LowBandwidthRefreshInterval with
+// low_bandwidth. Zero, which turns auto-refresh off, stays zero.
+func (c *Config) IdleRefreshInterval() time.Duration {
+	d := c.RefreshInterval
+	if c.RefreshIntervalIdle > 0 {
+		d = c.RefreshIntervalIdle
+	}
+	if c.LowBandwidth && d > 0 {
+		d = max(d, LowBandwidthRefreshInterval)
+	}
+	return d
+}
+
+// FeatureSettings returns the features block, with the optional sources
+// it doesn't set turned off under low_bandwidth
+func (c *Config) FeatureSettings() map[string]bool {
+	if !c.LowBandwidth {
+		return c.Features
+	}
+	settings := maps.Clone(c.Features)
+	if settings == nil {
+		settings = make(map[string]bool, len(lowBandwidthOff))
+	}
+	for _, name := range lowBandwidthOff {
+		if _, ok := settings[name]; !ok {
+			settings[name] = false
+		}
+	}
+	return settings
+}
+
+// validStatusFilter reports whether s is empty or a known guest status
+func validStatusFilter(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, status := range statusFilters {
+		if strings.EqualFold(s, status) {
+			return true
+		}
+	}
+	return false
+}
 
-	return v.WriteConfig()
+// validFingerprint reports whether s is a SHA-256 in hex, ignoring the
+// colons and spaces between bytes
+func validFingerprint(s string) bool {
+	hex := strings.NewReplacer(":", "", " ", "").Replace(s)
+	return len(hex) == 64 && strings.Trim(strings.ToUpper(hex), "0123456789ABCDEF") == ""
 }
 
 // GetAuthToken returns the formatted authentication token
@@ -3,11 +3,13 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/redact"
 )
 
 func TestConfig_GetAuthToken(t *testing.T) {
@@ -65,7 +67,22 @@ func TestViperLoader_Load_Defaults(t *testing.T) {
 
 	require.NoError(t, err)
 	assert.Equal(t, 5*time.Second, cfg.RefreshInterval) // Default value
-	assert.True(t, cfg.SkipTLSVerify)                   // Default value (changed to true)
+	assert.False(t, cfg.SkipTLSVerify)                  // Certificates are verified by default
+	assert.True(t, cfg.TLSTrustUnset)                   // Until the user decides
+	assert.True(t, cfg.UseUnicode)                      // Default value
+	assert.True(t, cfg.ConfigSweep)                     // Default value
+	assert.Equal(t, DefaultOvercommitCPUWarning, cfg.OvercommitCPUWarning)
+	assert.Equal(t, DefaultOvercommitMemWarning, cfg.OvercommitMemWarning)
+	assert.Equal(t, DefaultCPUPressureWarning, cfg.CPUPressureWarning)
+	assert.Equal(t, DefaultRequestLogSize, cfg.RequestLogSize)
+	assert.Equal(t, DefaultOwnerRegex, cfg.OwnerRegex)
+	assert.True(t, cfg.Color)                           // Default value
+	assert.Equal(t, 60*time.Second, cfg.ActionTimeout)  // Default value
+	assert.Equal(t, 2*time.Minute, cfg.RestartTimeout)  // Default value
+	assert.Equal(t, 10*time.Second, cfg.RefreshTimeout) // Default value
+	assert.False(t, cfg.AllowNodePowerActions)          // Default value
+	assert.False(t, cfg.AllowClearLock)                 // Default value
+	assert.False(t, cfg.UpdateCheck)                    // Default value
 }
 
 func TestViperLoader_Load_MissingAPIUrl(t *testing.T) {
@@ -130,6 +147,21 @@ func TestViperLoader_Load_FileNotFound(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to read config file")
 }
 
+func TestViperLoader_Load_MasksSecret(t *testing.T) {
+	// The secret pasted into the wrong setting ends up in the error
+	const secret = "0f6c1e2a-7b3d-4c5e-9f8a-1b2c3d4e5f60"
+	configPath := filepath.Join(t.TempDir(), "test.json")
+	content := `{"api_url": "https://pve:8006", "token_id": "u@pam!t", "token_secret": "` + secret + `", "refresh_interval": "` + secret + `"}`
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0o600))
+
+	_, err := NewLoader(configPath).Load()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refresh_interval")
+	assert.NotContains(t, err.Error(), secret)
+	assert.NotContains(t, redact.String("echoed "+secret), secret, "The secret is registered once read")
+}
+
 func TestViperLoader_Save(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "test.json")
@@ -151,6 +183,7 @@ func TestViperLoader_Save(t *testing.T) {
 		TokenSecret:     "new-secret",
 		RefreshInterval: 15 * time.Second,
 		SkipTLSVerify:   true,
+		ActionTimeout:   2 * time.Minute,
 	}
 
 	err = loader.Save(cfg)
@@ -164,6 +197,712 @@ func TestViperLoader_Save(t *testing.T) {
 	assert.Equal(t, "new-secret", cfg2.TokenSecret)
 	assert.Equal(t, 15*time.Second, cfg2.RefreshInterval)
 	assert.True(t, cfg2.SkipTLSVerify)
+	assert.Equal(t, 2*time.Minute, cfg2.ActionTimeout)
+}
+
+func TestViperLoader_StateChangeHook(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "on_state_change_cmd": "notify.sh",
+  "state_change_filter": ["*->stopped"]
+}`
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.NoError(t, err)
+
+	loader := NewLoader(configPath).(*ViperLoader)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "notify.sh", cfg.OnStateChangeCmd)
+	assert.Equal(t, []string{"*->stopped"}, cfg.StateChangeFilter)
+
+	// Saving from the config panel must not drop the hook settings
+	require.NoError(t, loader.Save(cfg))
+	cfg2, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "notify.sh", cfg2.OnStateChangeCmd)
+	assert.Equal(t, []string{"*->stopped"}, cfg2.StateChangeFilter)
+}
+
+func TestViperLoader_DefaultFilters(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "default_node_filter": "pve2",
+  "default_status_filter": "Stopped"
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath).(*ViperLoader)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "pve2", cfg.DefaultNodeFilter)
+	assert.Equal(t, "Stopped", cfg.DefaultStatusFilter)
+	assert.Empty(t, cfg.DefaultTextFilter)
+
+	// An unknown status would silently hide every guest
+	configContent = strings.Replace(configContent, "Stopped", "halted", 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	_, err = loader.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "default_status_filter")
+}
+
+func TestViperLoader_UseUnicode(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "use_unicode": false
+}`
+	err := os.WriteFile(configPath, []byte(configContent), 0644)
+	require.NoError(t, err)
+
+	loader := NewLoader(configPath).(*ViperLoader)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.UseUnicode)
+
+	// The ASCII fallback must survive a save from the config panel
+	require.NoError(t, loader.Save(cfg))
+	cfg2, err := loader.Load()
+	require.NoError(t, err)
+	assert.False(t, cfg2.UseUnicode)
+}
+
+func TestViperLoader_Color(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "color": false
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath).(*ViperLoader)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Color)
+	assert.True(t, cfg.UseUnicode)
+
+	require.NoError(t, loader.Save(cfg))
+	cfg2, err := loader.Load()
+	require.NoError(t, err)
+	assert.False(t, cfg2.Color)
+}
+
+func TestViperLoader_ConfigSweep(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "config_sweep": false
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath).(*ViperLoader)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.ConfigSweep)
+
+	require.NoError(t, loader.Save(cfg))
+	cfg2, err := loader.Load()
+	require.NoError(t, err)
+	assert.False(t, cfg2.ConfigSweep)
+}
+
+func TestViperLoader_OvercommitWarning(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "overcommit_cpu_warning": 400,
+  "overcommit_mem_warning": 0
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath)
+	_, err := loader.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "overcommit_mem_warning")
+
+	configContent = strings.Replace(configContent, `"overcommit_mem_warning": 0`, `"overcommit_mem_warning": 120.5`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 400.0, cfg.OvercommitCPUWarning)
+	assert.Equal(t, 120.5, cfg.OvercommitMemWarning)
+}
+
+func TestViperLoader_CPUPressure(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "test.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("api_url: https://pve:8006\ntoken_id: u@pam!t\ntoken_secret: s\n"), 0o600))
+	loader := NewLoader(configPath)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCPUPressureWarning, cfg.CPUPressureWarning)
+	assert.False(t, cfg.ShowNodeLoad)
+
+	cfg.CPUPressureWarning, cfg.ShowNodeLoad = 75, true
+	require.NoError(t, loader.Save(cfg))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 75.0, cfg.CPUPressureWarning)
+	assert.True(t, cfg.ShowNodeLoad)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("api_url: https://pve:8006\ntoken_id: u@pam!t\ntoken_secret: s\ncpu_pressure_warning: 120\n"), 0o600))
+	_, err = loader.Load()
+	assert.ErrorContains(t, err, "cpu_pressure_warning must be a percentage")
+}
+
+func TestViperLoader_RequestLogSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "request_log_size": -1
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath)
+	_, err := loader.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "request_log_size")
+
+	// 0 keeps no requests, and survives a save
+	configContent = strings.Replace(configContent, `-1`, `0`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.RequestLogSize)
+	require.NoError(t, loader.Save(cfg))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.RequestLogSize)
+}
+
+func TestViperLoader_DownAlertAfter(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid"
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultDownAlertAfter, cfg.DownAlertAfter)
+
+	configContent = strings.Replace(configContent, `"secret-uuid"`, `"secret-uuid", "down_alert_after": "-1h"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	_, err = loader.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "down_alert_after must not be negative")
+
+	// 0 turns the alert off, and survives a save
+	configContent = strings.Replace(configContent, `"-1h"`, `"0s"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.DownAlertAfter)
+	require.NoError(t, loader.Save(cfg))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.DownAlertAfter)
+}
+
+func TestViperLoader_CheatSheetTimeout(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "test.json")
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid"
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCheatSheetTimeout, cfg.CheatSheetTimeout)
+
+	configContent = strings.Replace(configContent, `"secret-uuid"`, `"secret-uuid", "cheat_sheet_timeout": "-1s"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	_, err = loader.Load()
+	assert.ErrorContains(t, err, "cheat_sheet_timeout must not be negative")
+
+	// 0 keeps the cheat-sheet up, and survives a save
+	configContent = strings.Replace(configContent, `"-1s"`, `"0s"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	require.NoError(t, loader.Save(cfg))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.CheatSheetTimeout)
+}
+
+func TestViperLoader_ShutdownEscalateAfter(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid"
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultShutdownEscalateAfter, cfg.ShutdownEscalateAfter)
+
+	configContent = strings.Replace(configContent, `"secret-uuid"`, `"secret-uuid", "shutdown_escalate_after": "-2m"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	_, err = loader.Load()
+	assert.ErrorContains(t, err, "shutdown_escalate_after must not be negative")
+
+	// 0 turns the offer off, and survives a save
+	configContent = strings.Replace(configContent, `"-2m"`, `"0s"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.ShutdownEscalateAfter)
+	require.NoError(t, loader.Save(cfg))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.ShutdownEscalateAfter)
+}
+
+func TestViperLoader_AdaptiveRefresh(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "refresh_interval": "5s"
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.IdleRefreshInterval(), "The idle interval defaults to refresh_interval")
+	assert.Zero(t, cfg.RefreshIntervalActive)
+	assert.Equal(t, DefaultRefreshActiveWindow, cfg.RefreshActiveWindow)
+
+	configContent = strings.Replace(configContent, `"5s"`,
+		`"5s", "refresh_interval_idle": "1m", "refresh_interval_active": "2s", "refresh_active_window": "10m"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, cfg.IdleRefreshInterval())
+	assert.Equal(t, 2*time.Second, cfg.RefreshIntervalActive)
+	assert.Equal(t, 10*time.Minute, cfg.RefreshActiveWindow)
+
+	configContent = strings.Replace(configContent, `"2s"`, `"-2s"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	_, err = loader.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refresh_interval_active must not be negative")
+}
+
+func TestViperLoader_LowBandwidth(t *testing.T) {
+	configPath := copyFixture(t, "pvecrc.yaml", "pvecrc.yaml")
+	loader := NewLoader(configPath)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.LowBandwidth)
+	assert.Nil(t, cfg.FeatureSettings(), "Every feature is detected by default")
+
+	assert.False(t, cfg.LowBandwidthCoarse)
+
+	cfg.LowBandwidth, cfg.LowBandwidthCoarse = true, true
+	cfg.Features = map[string]bool{"ha": true}
+	require.NoError(t, loader.Save(cfg))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.LowBandwidth)
+	assert.True(t, cfg.LowBandwidthCoarse)
+	assert.Equal(t, map[string]bool{"storage": false, "pools": false, "ha": true, "replication": false, "tasks": false}, cfg.FeatureSettings(),
+		"The features block still decides what it sets")
+	assert.Equal(t, map[string]bool{"ha": true}, cfg.Features)
+
+	cfg.RefreshInterval, cfg.RefreshIntervalIdle = 5*time.Second, 0
+	assert.Equal(t, LowBandwidthRefreshInterval, cfg.IdleRefreshInterval())
+	cfg.RefreshIntervalIdle = 2 * time.Minute
+	assert.Equal(t, 2*time.Minute, cfg.IdleRefreshInterval(), "A longer interval is kept")
+	cfg.RefreshInterval, cfg.RefreshIntervalIdle = 0, 0
+	assert.Zero(t, cfg.IdleRefreshInterval(), "Auto-refresh stays off")
+}
+
+func TestViperLoader_TLSTrust(t *testing.T) {
+	const fingerprint = "AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89"
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("api_url: https://pve.example.com:8006\ntoken_id: root@pam!pvec\ntoken_secret: secret\n"), 0o600))
+	loader := NewLoader(path)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.TLSTrustUnset)
+
+	// Saving an undecided config must leave the decision open
+	require.NoError(t, loader.Save(cfg))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "skip_tls_verify")
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.TLSTrustUnset)
+
+	cfg.TLSFingerprint = fingerprint
+	require.NoError(t, loader.Save(cfg))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, fingerprint, cfg.TLSFingerprint)
+	assert.False(t, cfg.TLSTrustUnset, "A pinned certificate is a decision")
+	assert.False(t, cfg.SkipTLSVerify)
+
+	cfg.TLSFingerprint, cfg.SkipTLSVerify = "", true
+	require.NoError(t, loader.Save(cfg))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.SkipTLSVerify)
+	assert.False(t, cfg.TLSTrustUnset)
+	cfg.SkipTLSVerify = false
+	require.NoError(t, loader.Save(cfg))
+	cfg, err = loader.Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.TLSTrustUnset, "Turning verification back on is a decision too")
+
+	require.NoError(t, os.WriteFile(path, []byte("api_url: https://pve.example.com:8006\ntoken_id: root@pam!pvec\ntoken_secret: secret\ntls_fingerprint: AB:CD\n"), 0o600))
+	_, err = NewLoader(path).Load()
+	assert.ErrorContains(t, err, "tls_fingerprint must be a SHA-256 in hex")
+}
+
+func TestViperLoader_ClonePresets(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.yaml")
+
+	write := func(presets string) {
+		content := "api_url: https://proxmox.example.com:8006\ntoken_id: user@pam!token\ntoken_secret: secret-uuid\nclone_presets:\n" + presets
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+	}
+	write(`  - {name: dev-web, source_vmid: 9000, name_pattern: "dev-web-{n}", target_storage: local-lvm, full: false}
+`)
+	cfg, err := NewLoader(configPath).Load()
+	require.NoError(t, err)
+	assert.Equal(t, []ClonePreset{{Name: "dev-web", SourceVMID: 9000, NamePattern: "dev-web-{n}", TargetStorage: "local-lvm"}}, cfg.ClonePresets)
+
+	for presets, want := range map[string]string{
+		"  - {source_vmid: 9000, name_pattern: \"a-{n}\"}\n":                                                               "clone_presets[0]: name is required",
+		"  - {name: a, name_pattern: \"a-{n}\"}\n":                                                                         "clone_presets[0] (a): source_vmid is required",
+		"  - {name: a, source_vmid: 9000, name_pattern: a}\n":                                                              "clone_presets[0] (a): name_pattern must hold {n}",
+		"  - {name: a, source_vmid: 1, name_pattern: \"a-{n}\"}\n  - {name: a, source_vmid: 2, name_pattern: \"b-{n}\"}\n": "clone_presets[1]: name \"a\" is used twice",
+	} {
+		write(presets)
+		_, err := NewLoader(configPath).Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestViperLoader_OwnerRegex(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "owner_regex": "owner:(\\S+"
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath)
+	_, err := loader.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "owner_regex is not a valid regular expression")
+	assert.Contains(t, err.Error(), "missing closing )")
+
+	configContent = strings.Replace(configContent, `(\\S+"`, `(\\S+)"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, `owner:(\S+)`, cfg.OwnerRegex)
+	assert.Equal(t, []string{"owner:bob", "bob"}, cfg.OwnerPattern().FindStringSubmatch("owner:bob"))
+}
+
+func TestConfig_OwnerPattern(t *testing.T) {
+	var none *Config
+	assert.Equal(t, DefaultOwnerRegex, none.OwnerPattern().String())
+	assert.Equal(t, DefaultOwnerRegex, (&Config{}).OwnerPattern().String())
+	assert.Equal(t, `team=(\w+)`, (&Config{OwnerRegex: `team=(\w+)`}).OwnerPattern().String())
+}
+
+func TestViperLoader_SnapshotBefore(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "snapshot_before": ["stop", "Reboot"]
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stop", "reboot"}, cfg.SnapshotBefore)
+	assert.True(t, cfg.SnapshotsBefore("reboot"))
+	assert.False(t, cfg.SnapshotsBefore("shutdown"))
+
+	require.NoError(t, loader.Save(cfg))
+	cfg2, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, cfg.SnapshotBefore, cfg2.SnapshotBefore)
+
+	configContent = strings.Replace(configContent, `"Reboot"`, `"migrate"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	_, err = loader.Load()
+	assert.ErrorContains(t, err, `snapshot_before lists "migrate"; the actions are start, shutdown, reboot, stop, resume`)
+}
+
+func TestViperLoader_MaintenanceWindows(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.json")
+
+	configContent := `{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "maintenance_windows": ["backup 01:00-04:00 daily", "22:00-02:00 sat"]
+}`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	loader := NewLoader(configPath)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	windows := cfg.Windows()
+	require.Len(t, windows, 2)
+	assert.Equal(t, "backup", windows[0].Name)
+
+	require.NoError(t, loader.Save(cfg))
+	cfg2, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, cfg.MaintenanceWindows, cfg2.MaintenanceWindows)
+
+	configContent = strings.Replace(configContent, `"22:00-02:00 sat"`, `"22:00 sat"`, 1)
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	_, err = loader.Load()
+	assert.ErrorContains(t, err, `maintenance_windows: maintenance window "22:00 sat": expected HH:MM-HH:MM`)
+}
+
+func TestViperLoader_Save_ExtensionAndDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Neither the extension nor the parent directory tells Viper the format
+	configPath := filepath.Join(tmpDir, "pvec", "pvec.conf")
+
+	loader := NewLoader(configPath).(*ViperLoader)
+	cfg := &Config{
+		APIUrl:                "https://proxmox.example.com:8006",
+		TokenID:               "user@pam!token",
+		TokenSecret:           "secret-uuid",
+		RefreshInterval:       10 * time.Second,
+		ActionTimeout:         90 * time.Second,
+		RestartTimeout:        5 * time.Minute,
+		ShutdownEscalateAfter: 5 * time.Minute,
+		RefreshTimeout:        20 * time.Second,
+		UseUnicode:            true,
+		AllowNodePowerActions: true,
+		AllowClearLock:        true,
+		UpdateCheck:           true,
+		DefaultNodeFilter:     "pve1",
+		DefaultStatusFilter:   "running",
+		DefaultTextFilter:     "web",
+		Color:                 true,
+		OvercommitCPUWarning:  300,
+		OvercommitMemWarning:  90,
+		CPUPressureWarning:    DefaultCPUPressureWarning,
+		RequestLogSize:        20,
+		DownAlertAfter:        12 * time.Hour,
+		OwnerRegex:            `team=(\w+)`,
+		RefreshIntervalIdle:   30 * time.Second,
+		RefreshIntervalActive: 2 * time.Second,
+		RefreshActiveWindow:   10 * time.Minute,
+		CheatSheetTimeout:     30 * time.Second,
+		Features:              map[string]bool{"pools": false, "ha": true},
+		ClonePresets: []ClonePreset{
+			{Name: "dev-web", SourceVMID: 9000, NamePattern: "dev-web-{n}"},
+			{Name: "ci", SourceVMID: 9001, NamePattern: "ci-{n:3}", TargetStorage: "local-lvm", Full: true, Start: true},
+		},
+	}
+	require.NoError(t, loader.Save(cfg))
+
+	cfg2, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, cfg, cfg2)
+}
+
+// fixtureConfig is what every testdata/pvecrc.* file holds
+var fixtureConfig = &Config{
+	APIUrl:                "https://proxmox.example.com:8006",
+	TokenID:               "user@pam!token",
+	TokenSecret:           "secret-uuid",
+	RefreshInterval:       10 * time.Second,
+	ActionTimeout:         60 * time.Second,
+	RestartTimeout:        2 * time.Minute,
+	ShutdownEscalateAfter: DefaultShutdownEscalateAfter,
+	RefreshTimeout:        10 * time.Second,
+	DefaultStatusFilter:   "running",
+	StateChangeFilter:     []string{"*->stopped"},
+	UseUnicode:            true,
+	Color:                 true,
+	ConfigSweep:           true,
+	OvercommitCPUWarning:  DefaultOvercommitCPUWarning,
+	OvercommitMemWarning:  DefaultOvercommitMemWarning,
+	CPUPressureWarning:    DefaultCPUPressureWarning,
+	RequestLogSize:        DefaultRequestLogSize,
+	DownAlertAfter:        DefaultDownAlertAfter,
+	OwnerRegex:            DefaultOwnerRegex,
+	RefreshActiveWindow:   DefaultRefreshActiveWindow,
+	CheatSheetTimeout:     DefaultCheatSheetTimeout,
+}
+
+// copyFixture copies a testdata file to name in a temporary directory
+func copyFixture(t *testing.T, fixture, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", fixture))
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestViperLoader_Load_Formats(t *testing.T) {
+	for _, fixture := range []string{"pvecrc.json", "pvecrc.yaml", "pvecrc.toml"} {
+		t.Run(fixture, func(t *testing.T) {
+			// By extension
+			cfg, err := NewLoader(filepath.Join("testdata", fixture)).Load()
+			require.NoError(t, err)
+			assert.Equal(t, fixtureConfig, cfg)
+
+			// By content, as ~/.pvecrc says nothing
+			cfg, err = NewLoader(copyFixture(t, fixture, ".pvecrc")).Load()
+			require.NoError(t, err)
+			assert.Equal(t, fixtureConfig, cfg)
+		})
+	}
+}
+
+func TestViperLoader_Load_InvalidFormat(t *testing.T) {
+	// The extension wins, and the error says which format was expected
+	path := copyFixture(t, "pvecrc.yaml", "config.json")
+	_, err := NewLoader(path).Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), path+" is not valid JSON")
+
+	path = filepath.Join(t.TempDir(), ".pvecrc")
+	require.NoError(t, os.WriteFile(path, []byte("api_url https://pve:8006\n"), 0o600))
+	_, err = NewLoader(path).Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is neither JSON, YAML nor TOML")
+}
+
+func TestViperLoader_Save_KeepsFormat(t *testing.T) {
+	for _, fixture := range []string{"pvecrc.json", "pvecrc.yaml", "pvecrc.toml"} {
+		t.Run(fixture, func(t *testing.T) {
+			path := copyFixture(t, fixture, ".pvecrc")
+			original, err := os.ReadFile(path)
+			require.NoError(t, err)
+			loader := NewLoader(path).(*ViperLoader)
+			cfg, err := loader.Load()
+			require.NoError(t, err)
+
+			cfg.RefreshInterval = 30 * time.Second
+			cfg.AllowClearLock = true
+			require.NoError(t, loader.Save(cfg))
+
+			saved, err := os.ReadFile(path)
+			require.NoError(t, err)
+			format, err := detectFormat(path, saved)
+			require.NoError(t, err)
+			want, _ := detectFormat(fixture, nil)
+			assert.Equal(t, want, format, "Save should keep the file's format")
+
+			// The keys already there keep their order, new ones follow
+			order := keyOrder(format, saved)
+			assert.Equal(t, keyOrder(format, original), order[:7])
+			assert.Contains(t, order[7:], "allow_clear_lock")
+
+			cfg2, err := loader.Load()
+			require.NoError(t, err)
+			assert.Equal(t, cfg, cfg2)
+		})
+	}
+}
+
+func TestViperLoader_Save_TOMLTablesLast(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".pvecrc")
+	content := "api_url = 'https://pve:8006'\ntoken_id = 'u@pam!t'\ntoken_secret = 's'\nfeatures = { ha = false }\nrefresh_interval = '7s'\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	loader := NewLoader(path).(*ViperLoader)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	require.NoError(t, loader.Save(cfg))
+
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, keyOrder(formatTOML, saved), "refresh_interval", "A key after an inline table stays top-level")
+	cfg2, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, cfg, cfg2)
+	assert.Equal(t, 7*time.Second, cfg2.RefreshInterval)
+}
+
+func TestViperLoader_Save_YAMLComments(t *testing.T) {
+	path := copyFixture(t, "pvecrc.yaml", "config.yml")
+	loader := NewLoader(path).(*ViperLoader)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	require.NoError(t, loader.Save(cfg))
+
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// Save rewrites the file from the settings, so comments are lost
+	assert.NotContains(t, string(saved), "#")
+	assert.True(t, strings.HasPrefix(string(saved), "token_id: user@pam!token\ntoken_secret: secret-uuid\napi_url: "),
+		"Expected YAML in the original order:\n%s", saved)
 }
 
 func TestNewLoader_DefaultPath(t *testing.T) {
@@ -171,10 +910,30 @@ func TestNewLoader_DefaultPath(t *testing.T) {
 	viperLoader, ok := loader.(*ViperLoader)
 	require.True(t, ok)
 
-	// Should use home directory
-	home, _ := os.UserHomeDir()
-	expectedPath := filepath.Join(home, ".pvecrc")
-	assert.Equal(t, expectedPath, viperLoader.configPath)
+	assert.Equal(t, DefaultPath(), viperLoader.configPath)
+	assert.NotEmpty(t, viperLoader.configPath)
+}
+
+func TestDefaultPath(t *testing.T) {
+	home := t.TempDir()
+	configDir := filepath.Join(home, "config")
+	newFile := filepath.Join(configDir, "pvec", "config.json")
+	dotFile := filepath.Join(home, ".pvecrc")
+
+	// Windows always uses the config directory
+	assert.Equal(t, newFile, defaultPath("windows", configDir, home))
+
+	// Unix keeps the dot-file until the newer file exists
+	assert.Equal(t, dotFile, defaultPath("linux", configDir, home))
+	require.NoError(t, os.MkdirAll(filepath.Dir(newFile), 0o700))
+	require.NoError(t, os.WriteFile(newFile, []byte("{}"), 0o600))
+	assert.Equal(t, newFile, defaultPath("linux", configDir, home))
+	assert.Equal(t, newFile, defaultPath("darwin", configDir, home))
+
+	// Missing directories
+	assert.Equal(t, dotFile, defaultPath("linux", "", home))
+	assert.Equal(t, newFile, defaultPath("linux", configDir, ""))
+	assert.Equal(t, "", defaultPath("windows", "", home))
 }
 
 func TestNewLoader_CustomPath(t *testing.T) {
@@ -185,3 +944,163 @@ func TestNewLoader_CustomPath(t *testing.T) {
 
 	assert.Equal(t, customPath, viperLoader.configPath)
 }
+
+const clustersYAML = `skip_tls_verify: false
+clusters:
+  - name: lab
+    api_url: https://lab.example.com:8006
+    token_id: lab@pve!pvec
+    token_secret: lab-secret-uuid
+    skip_tls_verify: true
+  - name: prod
+    api_url: https://prod.example.com:8006
+    token_id: prod@pve!pvec
+    token_secret: prod-secret-uuid
+    tls_fingerprint: 00:11:22:33:44:55:66:77:88:99:AA:BB:CC:DD:EE:FF:00:11:22:33:44:55:66:77:88:99:AA:BB:CC:DD:EE:FF
+`
+
+func TestViperLoader_Clusters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(clustersYAML), 0o600))
+
+	cfg, err := NewLoader(path).Load()
+	require.NoError(t, err, "The top-level api_url and token aren't required with clusters")
+
+	assert.Equal(t, []ClusterConfig{
+		{Name: "lab", APIUrl: "https://lab.example.com:8006", TokenID: "lab@pve!pvec", TokenSecret: "lab-secret-uuid", SkipTLSVerify: true},
+		{Name: "prod", APIUrl: "https://prod.example.com:8006", TokenID: "prod@pve!pvec", TokenSecret: "prod-secret-uuid",
+			TLSFingerprint: "00:11:22:33:44:55:66:77:88:99:AA:BB:CC:DD:EE:FF:00:11:22:33:44:55:66:77:88:99:AA:BB:CC:DD:EE:FF"},
+	}, cfg.Clusters)
+	assert.Equal(t, "PVEAPIToken=prod@pve!pvec=prod-secret-uuid", cfg.Clusters[1].GetAuthToken())
+	assert.NotContains(t, redact.String("echoed prod-secret-uuid"), "prod-secret-uuid", "Every cluster secret is registered")
+}
+
+func TestViperLoader_Clusters_InheritTLS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Replace(clustersYAML, "skip_tls_verify: false", "skip_tls_verify: true", 1)), 0o600))
+
+	cfg, err := NewLoader(path).Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Clusters[1].SkipTLSVerify, "An unset skip_tls_verify takes the top-level one")
+}
+
+func TestViperLoader_Clusters_TLSTrustUnset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"clusters": [
+  {"name": "lab", "api_url": "https://lab:8006", "token_id": "a", "token_secret": "s"},
+  {"name": "prod", "api_url": "https://prod:8006", "token_id": "b", "token_secret": "s", "skip_tls_verify": false},
+  {"name": "edge", "api_url": "https://edge:8006", "token_id": "c", "token_secret": "s",
+   "tls_fingerprint": "00:11:22:33:44:55:66:77:88:99:AA:BB:CC:DD:EE:FF:00:11:22:33:44:55:66:77:88:99:AA:BB:CC:DD:EE:FF"}]}`), 0o600))
+
+	cfg, err := NewLoader(path).Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.Clusters[0].TLSTrustUnset, "A cluster setting nothing is asked about")
+	assert.False(t, cfg.Clusters[1].TLSTrustUnset, "skip_tls_verify decides")
+	assert.False(t, cfg.Clusters[2].TLSTrustUnset, "A pinned certificate decides")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"skip_tls_verify": false, "clusters": [
+  {"name": "lab", "api_url": "https://lab:8006", "token_id": "a", "token_secret": "s"}]}`), 0o600))
+	cfg, err = NewLoader(path).Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Clusters[0].TLSTrustUnset, "The top-level skip_tls_verify decides for every cluster")
+}
+
+func TestViperLoader_Clusters_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"name is required":              `{"clusters": [{"api_url": "https://a:8006", "token_id": "a", "token_secret": "s"}]}`,
+		`name "a/b" must not`:           `{"clusters": [{"name": "a/b", "api_url": "https://a:8006", "token_id": "a", "token_secret": "s"}]}`,
+		`name "a" is used twice`:        `{"clusters": [{"name": "a", "api_url": "https://a:8006", "token_id": "a", "token_secret": "s"}, {"name": "a", "api_url": "https://b:8006", "token_id": "b", "token_secret": "s"}]}`,
+		"(b): token_secret is required": `{"clusters": [{"name": "b", "api_url": "https://b:8006", "token_id": "b"}]}`,
+		"(c): tls_fingerprint must be":  `{"clusters": [{"name": "c", "api_url": "https://c:8006", "token_id": "c", "token_secret": "s", "tls_fingerprint": "nope"}]}`,
+	}
+	for want, content := range tests {
+		t.Run(want, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			_, err := NewLoader(path).Load()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), want)
+			assert.Contains(t, err.Error(), "(set in "+path+")")
+		})
+	}
+}
+
+func TestViperLoader_Clusters_Save(t *testing.T) {
+	for _, name := range []string{"config.json", "config.yaml", "config.toml"} {
+		t.Run(name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(src, []byte(clustersYAML), 0o600))
+			cfg, err := NewLoader(src).Load()
+			require.NoError(t, err)
+
+			loader := NewLoader(filepath.Join(t.TempDir(), name))
+			require.NoError(t, loader.Save(cfg))
+			cfg2, err := loader.Load()
+			require.NoError(t, err)
+			assert.Equal(t, cfg.Clusters, cfg2.Clusters)
+		})
+	}
+}
+
+func TestViperLoader_NodeProbes(t *testing.T) {
+	for _, name := range []string{"config.json", "config.yaml", "config.toml"} {
+		t.Run(name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "config.json")
+			require.NoError(t, os.WriteFile(src, []byte(`{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "node_probes": {"pve1": "10.0.0.1:22", "PVE2": "[fd00::2]:8006"}
+}`), 0o600))
+			cfg, err := NewLoader(src).Load()
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"pve1": "10.0.0.1:22", "pve2": "[fd00::2]:8006"}, cfg.NodeProbes)
+
+			loader := NewLoader(filepath.Join(t.TempDir(), name))
+			require.NoError(t, loader.Save(cfg))
+			cfg2, err := loader.Load()
+			require.NoError(t, err)
+			assert.Equal(t, cfg.NodeProbes, cfg2.NodeProbes)
+		})
+	}
+}
+
+func TestViperLoader_Features(t *testing.T) {
+	for _, format := range []string{"json", "yaml", "toml"} {
+		t.Run(format, func(t *testing.T) {
+			configPath := copyFixture(t, "pvecrc."+format, "pvecrc."+format)
+			loader := NewLoader(configPath)
+			cfg, err := loader.Load()
+			require.NoError(t, err)
+			assert.Nil(t, cfg.Features, "every feature is detected by default")
+
+			cfg.Features = map[string]bool{"replication": false, "node_status": true}
+			require.NoError(t, loader.Save(cfg))
+			cfg2, err := loader.Load()
+			require.NoError(t, err)
+			assert.Equal(t, cfg.Features, cfg2.Features)
+		})
+	}
+
+	configPath := filepath.Join(t.TempDir(), "pvecrc.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "features": {"pool": false}
+}`), 0644))
+	_, err := NewLoader(configPath).Load()
+	assert.ErrorContains(t, err, `features lists "pool"; the features are node_status, storage, pools, ha, replication, tasks (set in `+configPath+")")
+}
+
+func TestViperLoader_NodeProbes_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "api_url": "https://proxmox.example.com:8006",
+  "token_id": "user@pam!token",
+  "token_secret": "secret-uuid",
+  "node_probes": {"pve1": "10.0.0.1"}
+}`), 0o600))
+	_, err := NewLoader(path).Load()
+	assert.ErrorContains(t, err, `node_probes gives pve1 the address "10.0.0.1"; expected host:port (set in `+path+")")
+}
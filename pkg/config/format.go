@@ -0,0 +1,204 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// Config file formats, named as Viper knows them
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatTOML = "toml"
+)
+
+// formatByExtension maps the extensions that decide the format outright
+var formatByExtension = map[string]string{
+	".json": formatJSON,
+	".yaml": formatYAML,
+	".yml":  formatYAML,
+	".toml": formatTOML,
+}
+
+// detectFormat returns the format of a config file: the one its extension
+// names, otherwise the one its content parses as. Names like .pvecrc,
+// pvec.conf or .pvecrc.bak say nothing, so "{" means JSON, and anything
+// else is tried as YAML then TOML.
+func detectFormat(path string, data []byte) (string, error) {
+	if format, ok := formatByExtension[strings.ToLower(filepath.Ext(path))]; ok {
+		return format, nil
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] == '{' {
+		return formatJSON, nil
+	}
+	// Both must yield a table of settings: a TOML line like a = "b" is a
+	// valid YAML string, but not a YAML mapping
+	var settings map[string]interface{}
+	if yaml.Unmarshal(trimmed, &settings) == nil && settings != nil {
+		return formatYAML, nil
+	}
+	if toml.Unmarshal(trimmed, &settings) == nil {
+		return formatTOML, nil
+	}
+	return "", fmt.Errorf("%s is neither JSON, YAML nor TOML", path)
+}
+
+// setting is one key written by Save
+type setting struct {
+	key   string
+	value interface{}
+}
+
+// tomlKeyPattern matches a top-level TOML key, before any [table]
+var tomlKeyPattern = regexp.MustCompile(`^\s*([A-Za-z0-9_-]+)\s*=`)
+
+// keyOrder returns the top-level keys of a config file in the order they
+// appear, lowercased as Viper reads them. It returns nil if the file
+// doesn't parse.
+func keyOrder(format string, data []byte) []string {
+	var keys []string
+	switch format {
+	case formatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return nil
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil
+			}
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return nil
+			}
+			keys = append(keys, strings.ToLower(tok.(string)))
+		}
+	case formatYAML:
+		var doc yaml.Node
+		if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			return nil
+		}
+		mapping := doc.Content[0].Content
+		for i := 0; i+1 < len(mapping); i += 2 {
+			keys = append(keys, strings.ToLower(mapping[i].Value))
+		}
+	case formatTOML:
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "[") {
+				break
+			}
+			if m := tomlKeyPattern.FindStringSubmatch(line); m != nil {
+				keys = append(keys, strings.ToLower(m[1]))
+			}
+		}
+	}
+	return keys
+}
+
+// orderSettings sorts settings in the order of keys; the ones missing
+// from it keep their own order after the others
+func orderSettings(settings []setting, keys []string) {
+	rank := func(key string) int {
+		if i := slices.Index(keys, key); i >= 0 {
+			return i
+		}
+		return len(keys)
+	}
+	slices.SortStableFunc(settings, func(a, b setting) int {
+		return rank(a.key) - rank(b.key)
+	})
+}
+
+// encodeSettings writes settings in the given format and order. Viper
+// would sort the keys, so the formats are written here a key at a time.
+// In TOML the tables go last whatever the order, as a [table] takes in
+// every key after it.
+func encodeSettings(format string, settings []setting) ([]byte, error) {
+	if format == formatTOML {
+		settings = slices.Clone(settings)
+		slices.SortStableFunc(settings, func(a, b setting) int {
+			return boolRank(isTOMLTable(a.value)) - boolRank(isTOMLTable(b.value))
+		})
+	}
+	var buf bytes.Buffer
+	if format == formatJSON {
+		buf.WriteString("{\n")
+	}
+	for i, s := range settings {
+		var err error
+		switch format {
+		case formatJSON:
+			err = encodeJSONSetting(&buf, s, i == len(settings)-1)
+		case formatYAML:
+			enc := yaml.NewEncoder(&buf)
+			enc.SetIndent(2)
+			if err = enc.Encode(map[string]interface{}{s.key: s.value}); err == nil {
+				err = enc.Close()
+			}
+		case formatTOML:
+			err = toml.NewEncoder(&buf).Encode(map[string]interface{}{s.key: s.value})
+		default:
+			return nil, fmt.Errorf("unsupported config format %q", format)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.key, err)
+		}
+	}
+	if format == formatJSON {
+		buf.WriteString("}\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// isTOMLTable reports whether TOML writes value as a [table] or an array
+// of [[tables]]
+func isTOMLTable(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Slice && v.Len() > 0 {
+		v = reflect.Indirect(v.Index(0))
+		if v.Kind() == reflect.Interface {
+			v = reflect.Indirect(v.Elem())
+		}
+	}
+	return v.Kind() == reflect.Map || v.Kind() == reflect.Struct
+}
+
+// boolRank sorts false before true
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// encodeJSONSetting writes one member of the top-level JSON object
+func encodeJSONSetting(buf *bytes.Buffer, s setting, last bool) error {
+	key, err := json.Marshal(s.key)
+	if err != nil {
+		return err
+	}
+	var value bytes.Buffer
+	enc := json.NewEncoder(&value)
+	enc.SetEscapeHTML(false) // Keep & in URLs readable
+	enc.SetIndent("  ", "  ")
+	if err := enc.Encode(s.value); err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "  %s: %s", key, bytes.TrimSpace(value.Bytes()))
+	if !last {
+		buf.WriteByte(',')
+	}
+	buf.WriteByte('\n')
+	return nil
+}
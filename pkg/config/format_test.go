@@ -0,0 +1,83 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		want    string
+	}{
+		{"json extension", "config.json", "api_url: x", formatJSON},
+		{"yaml extension", "config.yaml", "{}", formatYAML},
+		{"yml extension", "config.YML", "", formatYAML},
+		{"toml extension", "pvec.toml", "", formatTOML},
+		{"sniffed json", ".pvecrc", "\n  {\"api_url\": \"x\"}", formatJSON},
+		{"sniffed yaml", ".pvecrc", "# pvec\napi_url: https://pve:8006\n", formatYAML},
+		{"sniffed toml", "pvec.conf", "# pvec\napi_url = \"https://pve:8006\"\n", formatTOML},
+		{"sniffed toml table", ".pvecrc.bak", "[filters]\nnode = \"pve1\"\n", formatTOML},
+		{"empty file", ".pvecrc", " \n", formatJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectFormat(tt.path, []byte(tt.content))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDetectFormat_Unknown(t *testing.T) {
+	_, err := detectFormat("/home/alice/.pvecrc", []byte("api_url https://pve:8006\n"))
+	assert.EqualError(t, err, "/home/alice/.pvecrc is neither JSON, YAML nor TOML")
+}
+
+func TestKeyOrder(t *testing.T) {
+	want := []string{"token_id", "api_url", "state_change_filter"}
+
+	assert.Equal(t, want, keyOrder(formatJSON, []byte(`{"Token_ID": "a", "api_url": {"nested": 1}, "state_change_filter": ["x"]}`)))
+	assert.Equal(t, want, keyOrder(formatYAML, []byte("# c\ntoken_id: a\napi_url: b\nstate_change_filter:\n  - x\n")))
+	assert.Equal(t, want, keyOrder(formatTOML, []byte("# c\ntoken_id = 'a'\napi_url = 'b'\nstate_change_filter = ['x']\n[table]\nkey = 1\n")))
+	assert.Nil(t, keyOrder(formatJSON, []byte("not json")))
+}
+
+func TestOrderSettings(t *testing.T) {
+	settings := []setting{{"api_url", "a"}, {"token_id", "b"}, {"color", false}, {"token_secret", "c"}}
+
+	orderSettings(settings, []string{"token_secret", "unknown", "api_url"})
+
+	var keys []string
+	for _, s := range settings {
+		keys = append(keys, s.key)
+	}
+	assert.Equal(t, []string{"token_secret", "api_url", "token_id", "color"}, keys)
+}
+
+func TestEncodeSettings(t *testing.T) {
+	settings := []setting{
+		{"api_url", "https://pve:8006/?a&b"},
+		{"state_change_filter", []string{"*->stopped"}},
+		{"overcommit_cpu_warning", 300.0},
+	}
+
+	tests := map[string]string{
+		formatJSON: "{\n  \"api_url\": \"https://pve:8006/?a&b\",\n  \"state_change_filter\": [\n    \"*->stopped\"\n  ],\n  \"overcommit_cpu_warning\": 300\n}\n",
+		formatYAML: "api_url: https://pve:8006/?a&b\nstate_change_filter:\n  - '*->stopped'\novercommit_cpu_warning: 300\n",
+		formatTOML: "api_url = 'https://pve:8006/?a&b'\nstate_change_filter = ['*->stopped']\novercommit_cpu_warning = 300.0\n",
+	}
+	for format, want := range tests {
+		got, err := encodeSettings(format, settings)
+		require.NoError(t, err, format)
+		assert.Equal(t, want, string(got), format)
+	}
+
+	_, err := encodeSettings("ini", settings)
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Layer names, from the lowest precedence to the highest
+const (
+	LayerSystem  = "system"  // Site defaults, such as the API URL and TLS settings
+	LayerUser    = "user"    // The user's own file, the only one Save writes
+	LayerProject = "project" // .pvecrc in the working directory
+)
+
+// Layer is one file of a layered configuration
+type Layer struct {
+	Name string
+	Path string
+}
+
+// Sources reports which file each effective setting was read from
+type Sources interface {
+	// Source returns the file the effective value of key was read from,
+	// or "" when it is a default
+	Source(key string) string
+	// SavePath returns the file Save writes to
+	SavePath() string
+}
+
+// SystemPath returns the site-wide configuration file: /etc/pvec/config,
+// or pvec\config under %ProgramData% on Windows. It returns "" if there
+// is none.
+func SystemPath() string {
+	return systemPath(runtime.GOOS, os.Getenv("ProgramData"))
+}
+
+func systemPath(goos, programData string) string {
+	if goos != "windows" {
+		return "/etc/pvec/config"
+	}
+	if programData == "" {
+		return ""
+	}
+	return filepath.Join(programData, "pvec", "config")
+}
+
+// DefaultLayers returns the files merged when no file is given: the
+// system file, the user's DefaultPath and .pvecrc in the working
+// directory, lowest precedence first. The project file is left out when
+// it is the user's, as when pvec runs in the home directory.
+func DefaultLayers() []Layer {
+	project, _ := filepath.Abs(".pvecrc")
+	return defaultLayers(SystemPath(), DefaultPath(), project)
+}
+
+func defaultLayers(system, user, project string) []Layer {
+	var layers []Layer
+	if system != "" {
+		layers = append(layers, Layer{Name: LayerSystem, Path: system})
+	}
+	layers = append(layers, Layer{Name: LayerUser, Path: user})
+	if project != "" && project != user {
+		layers = append(layers, Layer{Name: LayerProject, Path: project})
+	}
+	return layers
+}
+
+// readLayer reads the settings of one file, in whichever format it is
+// written. Keys are lowercased, as Viper reads them.
+func readLayer(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	format, err := detectFormat(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %s is not valid %s: %w",
+			path, strings.ToUpper(format), err)
+	}
+	return v.AllSettings(), nil
+}
+
+// layerPaths returns the paths of layers for messages
+func layerPaths(layers []Layer) string {
+	paths := make([]string, len(layers))
+	for i, layer := range layers {
+		paths[i] = layer.Path
+	}
+	return strings.Join(paths, ", ")
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// layeredFiles writes the given layers, skipping empty contents, and
+// returns a loader merging them
+func layeredFiles(t *testing.T, system, user, project string) (*ViperLoader, []Layer) {
+	t.Helper()
+	dir := t.TempDir()
+	layers := []Layer{
+		{Name: LayerSystem, Path: filepath.Join(dir, "etc", "pvec", "config")},
+		{Name: LayerUser, Path: filepath.Join(dir, "home", ".pvecrc")},
+		{Name: LayerProject, Path: filepath.Join(dir, "project", ".pvecrc")},
+	}
+	for i, content := range []string{system, user, project} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(layers[i].Path), 0o700))
+		if content != "" {
+			require.NoError(t, os.WriteFile(layers[i].Path, []byte(content), 0o600))
+		}
+	}
+	return &ViperLoader{configPath: layers[1].Path, layers: layers}, layers
+}
+
+const (
+	systemLayer = `{"api_url": "https://pve.example.com:8006", "skip_tls_verify": false, "refresh_interval": "10s"}`
+	userLayer   = "token_id: alice@pve!pvec\ntoken_secret: secret-uuid\nrefresh_interval: 5s\n"
+)
+
+func TestViperLoader_Layers_Precedence(t *testing.T) {
+	loader, layers := layeredFiles(t, systemLayer, userLayer, `refresh_interval = "2s"`)
+
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://pve.example.com:8006", cfg.APIUrl)
+	assert.False(t, cfg.SkipTLSVerify, "The system file overrides the defaults")
+	assert.Equal(t, "alice@pve!pvec", cfg.TokenID)
+	assert.Equal(t, 2*time.Second, cfg.RefreshInterval, "The project file wins")
+
+	assert.Equal(t, layers[0].Path, loader.Source("api_url"))
+	assert.Equal(t, layers[1].Path, loader.Source("TOKEN_ID"))
+	assert.Equal(t, layers[2].Path, loader.Source("refresh_interval"))
+	assert.Empty(t, loader.Source("action_timeout"), "Defaults come from no file")
+}
+
+func TestViperLoader_Layers_MissingUserFile(t *testing.T) {
+	loader, layers := layeredFiles(t, systemLayer, "", "token_id: ci@pve!pvec\ntoken_secret: ci-secret\n")
+
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "https://pve.example.com:8006", cfg.APIUrl)
+	assert.Equal(t, "ci@pve!pvec", cfg.TokenID)
+	assert.Equal(t, 10*time.Second, cfg.RefreshInterval)
+	assert.Equal(t, layers[2].Path, loader.Source("token_secret"))
+}
+
+func TestViperLoader_Layers_Errors(t *testing.T) {
+	loader, layers := layeredFiles(t, "", "", "")
+	_, err := loader.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "none of "+layers[0].Path+", "+layers[1].Path)
+
+	// A file that exists must parse
+	loader, layers = layeredFiles(t, "{not json", userLayer, "")
+	_, err = loader.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), layers[0].Path+" is not valid JSON")
+
+	// Invalid values name the file that set them
+	loader, layers = layeredFiles(t, systemLayer, userLayer+"default_status_filter: sleeping\n", "")
+	_, err = loader.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `got "sleeping" (set in `+layers[1].Path+")")
+}
+
+func TestViperLoader_Layers_SaveWritesUserFile(t *testing.T) {
+	loader, layers := layeredFiles(t, systemLayer, userLayer, "")
+	system, err := os.ReadFile(layers[0].Path)
+	require.NoError(t, err)
+
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	cfg.TokenSecret = "rotated"
+	cfg.SkipTLSVerify = true
+	require.NoError(t, loader.Save(cfg))
+
+	after, err := os.ReadFile(layers[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, string(system), string(after), "The system file is never written")
+
+	user, err := readLayer(layers[1].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", user["token_secret"])
+	assert.Equal(t, true, user["skip_tls_verify"])
+	assert.NotContains(t, user, "api_url", "Values equal to the system file's are left to it")
+	assert.Equal(t, layers[1].Path, loader.Source("skip_tls_verify"))
+	assert.Equal(t, layers[0].Path, loader.Source("api_url"))
+
+	cfg2, err := loader.Load()
+	require.NoError(t, err)
+	assert.Equal(t, cfg, cfg2)
+}
+
+func TestViperLoader_Layers_SaveLeavesProjectSettings(t *testing.T) {
+	loader, layers := layeredFiles(t, systemLayer, userLayer, "refresh_interval = \"2s\"\naction_timeout = \"30s\"\nrestart_timeout = \"90s\"\n")
+
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	cfg.TokenSecret = "rotated"
+	cfg.RestartTimeout = 3 * time.Minute
+	require.NoError(t, loader.Save(cfg))
+
+	user, err := readLayer(layers[1].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", user["token_secret"])
+	assert.Equal(t, "5s", user["refresh_interval"], "The user's own value is kept under the project's")
+	assert.NotContains(t, user, "action_timeout", "The project's settings stay in its file")
+	assert.Equal(t, "3m0s", user["restart_timeout"], "A project setting changed since is the user's")
+	assert.Equal(t, layers[2].Path, loader.Source("action_timeout"))
+}
+
+func TestDefaultLayers(t *testing.T) {
+	assert.Equal(t, []Layer{
+		{Name: LayerSystem, Path: "/etc/pvec/config"},
+		{Name: LayerUser, Path: "/home/alice/.pvecrc"},
+		{Name: LayerProject, Path: "/src/lab/.pvecrc"},
+	}, defaultLayers("/etc/pvec/config", "/home/alice/.pvecrc", "/src/lab/.pvecrc"))
+
+	// Run from the home directory, the project file is the user's
+	assert.Equal(t, []Layer{{Name: LayerUser, Path: "/home/alice/.pvecrc"}},
+		defaultLayers("", "/home/alice/.pvecrc", "/home/alice/.pvecrc"))
+}
+
+func TestSystemPath(t *testing.T) {
+	assert.Equal(t, "/etc/pvec/config", systemPath("linux", ""))
+	assert.Equal(t, filepath.Join(`C:\ProgramData`, "pvec", "config"), systemPath("windows", `C:\ProgramData`))
+	assert.Empty(t, systemPath("windows", ""))
+}
+
+func TestNewLoader_Layered(t *testing.T) {
+	loader := NewLoader("").(*ViperLoader)
+	assert.NotEmpty(t, loader.layers)
+	assert.Equal(t, loader.configPath, loader.SavePath())
+
+	// -c names exactly one file
+	loader = NewLoader("/tmp/pvec.json").(*ViperLoader)
+	assert.Nil(t, loader.layers)
+}
@@ -0,0 +1,50 @@
+// Package crash keeps pvec's panics from taking the terminal down with
+// them: a recovered panic becomes an error carrying its stack, which is
+// written to a log file the user can attach to a bug report.
+package crash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// Panic is a recovered panic and the stack it was raised on
+type Panic struct {
+	Value any
+	Stack []byte
+}
+
+// New returns the panic r, recovered by the caller, with the current stack
+func New(r any) *Panic {
+	return &Panic{Value: r, Stack: debug.Stack()}
+}
+
+func (p *Panic) Error() string {
+	return fmt.Sprintf("internal error: %v", p.Value)
+}
+
+// Dir returns the directory of the crash logs, e.g. ~/.cache/pvec
+func Dir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "pvec"), nil
+}
+
+// WriteLog writes the panic and its stack to crash-<time>.log in dir and
+// returns the path of the file
+func WriteLog(dir string, p *Panic, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "crash-"+now.UTC().Format("20060102-150405.000")+".log")
+	text := fmt.Sprintf("pvec panicked at %s: %v\n\n%s", now.UTC().Format(time.RFC3339), p.Value, p.Stack)
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
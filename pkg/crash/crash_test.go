@@ -0,0 +1,47 @@
+package crash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recovered(f func()) (p *Panic) {
+	defer func() {
+		if r := recover(); r != nil {
+			p = New(r)
+		}
+	}()
+	f()
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	p := recovered(func() {
+		var rows []string
+		_ = rows[3]
+	})
+	require.NotNil(t, p)
+	assert.Contains(t, p.Error(), "internal error: runtime error: index out of range [3]")
+	assert.Contains(t, string(p.Stack), "crash.TestNew")
+}
+
+func TestWriteLog(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pvec")
+	p := &Panic{Value: "boom", Stack: []byte("goroutine 1 [running]:\n")}
+
+	path, err := WriteLog(dir, p, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "crash-20260301-120000.000.log"), path)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "pvec panicked at 2026-03-01T12:00:00Z: boom\n\ngoroutine 1 [running]:\n", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the stack may hold guest names")
+}
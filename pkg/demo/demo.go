@@ -0,0 +1,332 @@
+// Package demo is an offline backend for demos and UI development. Guests
+// are loaded from a fixture file, their usage drifts a little on every
+// refresh and power actions change them in memory.
+package demo
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// defaultFixture holds 30 guests spread over three nodes
+//
+//go:embed fixtures/default.json
+var defaultFixture []byte
+
+// DefaultActionDelay is how long Start, Shutdown and Reboot take to
+// complete; Stop is immediate like on a real server
+const DefaultActionDelay = 2 * time.Second
+
+// fixture is the fixture file format: a /cluster/resources response body,
+// so a capture from a real cluster can be used as is, plus optional guest
+// configurations keyed by VMID
+type fixture struct {
+	Configs map[string]map[string]interface{} `json:"configs"`
+}
+
+// transition is a pending status change started by an action
+type transition struct {
+	status models.NodeState
+	at     time.Time
+}
+
+// FileClient implements proxmox.Client on top of fixture data
+type FileClient struct {
+	mu          sync.Mutex
+	guests      []*models.VMStatus
+	configs     map[string]map[string]interface{}
+	pending     map[string]transition // VMID -> status change in progress
+	actionDelay time.Duration
+	rng         *rand.Rand
+	now         func() time.Time
+	lastRefresh time.Time
+}
+
+// NewFileClient loads guests from a fixture file, or from the built-in
+// fixture when path is empty
+func NewFileClient(path string) (*FileClient, error) {
+	if path == "" {
+		return Parse(defaultFixture)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse creates a client from fixture data
+func Parse(data []byte) (*FileClient, error) {
+	guests, err := proxmox.ParseClusterResources(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fixture: %w", err)
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid fixture: %w", err)
+	}
+
+	now := time.Now()
+	return &FileClient{
+		guests:      guests,
+		configs:     f.Configs,
+		pending:     make(map[string]transition),
+		actionDelay: DefaultActionDelay,
+		rng:         rand.New(rand.NewSource(now.UnixNano())), // #nosec G404 - jitter only
+		now:         time.Now,
+		lastRefresh: now,
+	}, nil
+}
+
+// GetNodes returns all guests after letting time pass: pending actions
+// complete, uptimes advance and running guests' usage jitters
+func (c *FileClient) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.applyPending(now)
+	elapsed := int64(now.Sub(c.lastRefresh).Seconds())
+	c.lastRefresh = now
+
+	nodes := make([]*models.VMStatus, 0, len(c.guests))
+	for _, g := range c.guests {
+		if g.IsRunning() {
+			g.Uptime += elapsed
+			g.CPUUsage = jitter(c.rng, g.CPUUsage, 3, 0.1, 100)
+			g.MemoryUsage = jitter(c.rng, g.MemoryUsage, 1, 1, 100)
+		}
+		guest := *g
+		nodes = append(nodes, &guest)
+	}
+	return nodes, nil
+}
+
+// jitter nudges value by a normally distributed amount, keeping it in range
+func jitter(rng *rand.Rand, value, stddev, lo, hi float64) float64 {
+	value += rng.NormFloat64() * stddev
+	if value < lo {
+		return lo
+	}
+	if value > hi {
+		return hi
+	}
+	return value
+}
+
+// GetGuestStatus returns the current status of a single guest
+func (c *FileClient) GetGuestStatus(ctx context.Context, node, vmType, vmid string) (*models.VMStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.applyPending(c.now())
+	g := c.find(vmid)
+	if g == nil {
+		return nil, proxmox.ErrNodeNotFound
+	}
+	guest := *g
+	return &guest, nil
+}
+
+// GetVMConfig returns a copy of the fixture's configuration for the
+// guest, or one made up from its resources
+func (c *FileClient) GetVMConfig(ctx context.Context, node, vmType, vmid string) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cfg, ok := c.configs[vmid]; ok {
+		return maps.Clone(cfg), nil
+	}
+	g := c.find(vmid)
+	if g == nil {
+		return nil, proxmox.ErrNodeNotFound
+	}
+	return cannedConfig(g), nil
+}
+
+// cannedConfig builds a plausible configuration for a guest. Numbers are
+// float64, as they are when decoded from the API.
+func cannedConfig(g *models.VMStatus) map[string]interface{} {
+	disk := fmt.Sprintf("local-lvm:vm-%s-disk-0,size=%dG", g.VMID, g.MaxDisk>>30)
+	cfg := map[string]interface{}{
+		"cores":  float64(g.MaxCPU),
+		"memory": float64(g.MaxMem >> 20),
+	}
+	if g.Type == models.TypeContainer {
+		cfg["hostname"] = g.Name
+		cfg["ostype"] = "debian"
+		cfg["rootfs"] = disk
+		cfg["net0"] = "name=eth0,bridge=vmbr0,ip=dhcp,type=veth"
+		return cfg
+	}
+	cfg["name"] = g.Name
+	cfg["ostype"] = "l26"
+	cfg["agent"] = "1"
+	cfg["scsi0"] = disk
+	cfg["net0"] = "virtio=BC:24:11:00:00:01,bridge=vmbr0"
+	cfg["boot"] = "order=scsi0"
+	return cfg
+}
+
+// GetAgentFSInfo reports a single root filesystem for running VMs
+func (c *FileClient) GetAgentFSInfo(ctx context.Context, node, vmid string) ([]models.Filesystem, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := c.find(vmid)
+	if g == nil {
+		return nil, proxmox.ErrNodeNotFound
+	}
+	if !g.IsRunning() {
+		return nil, fmt.Errorf("QEMU guest agent is not running")
+	}
+	return []models.Filesystem{{
+		Name:       "sda1",
+		Mountpoint: "/",
+		Type:       "ext4",
+		UsedBytes:  g.MaxDisk / 100 * int64(30+g.VMIDNum%60),
+		TotalBytes: g.MaxDisk,
+	}}, nil
+}
+
+// GetAgentHostName reports the guest name as the hostname of running VMs
+func (c *FileClient) GetAgentHostName(ctx context.Context, node, vmid string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := c.find(vmid)
+	if g == nil {
+		return "", proxmox.ErrNodeNotFound
+	}
+	if !g.IsRunning() {
+		return "", fmt.Errorf("QEMU guest agent is not running")
+	}
+	return g.Name, nil
+}
+
+// PingAgent answers for running VMs, as their agent does
+func (c *FileClient) PingAgent(ctx context.Context, node, vmid string) error {
+	_, err := c.GetAgentHostName(ctx, node, vmid)
+	return err
+}
+
+// Start starts a stopped or hibernated guest; it is running after the
+// action delay
+func (c *FileClient) Start(ctx context.Context, node, vmType, vmid string) error {
+	return c.schedule(vmid, (*models.VMStatus).CanStart, models.StateRunning, c.actionDelay)
+}
+
+// Shutdown stops a running guest after the action delay
+func (c *FileClient) Shutdown(ctx context.Context, node, vmType, vmid string) error {
+	return c.schedule(vmid, (*models.VMStatus).IsRunning, models.StateStopped, c.actionDelay)
+}
+
+// Reboot restarts a running guest; its uptime resets after the action delay
+func (c *FileClient) Reboot(ctx context.Context, node, vmType, vmid string) error {
+	return c.schedule(vmid, (*models.VMStatus).IsRunning, models.StateRunning, c.actionDelay)
+}
+
+// Hibernate suspends a running VM to disk after the action delay
+func (c *FileClient) Hibernate(ctx context.Context, node, vmid string) error {
+	return c.schedule(vmid, func(g *models.VMStatus) bool {
+		return g.Type == models.TypeVM && g.IsRunning()
+	}, models.StateHibernated, c.actionDelay)
+}
+
+// Stop stops a running or paused guest immediately
+func (c *FileClient) Stop(ctx context.Context, node, vmType, vmid string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := c.find(vmid)
+	if g == nil {
+		return proxmox.ErrNodeNotFound
+	}
+	if !g.CanStop() {
+		return fmt.Errorf("guest %s is not running", vmid)
+	}
+	delete(c.pending, vmid)
+	setStatus(g, models.StateStopped)
+	return nil
+}
+
+// Resume resumes a paused guest immediately, keeping its uptime
+func (c *FileClient) Resume(ctx context.Context, node, vmType, vmid string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := c.find(vmid)
+	if g == nil {
+		return proxmox.ErrNodeNotFound
+	}
+	if !g.CanResume() {
+		return fmt.Errorf("guest %s is not paused", vmid)
+	}
+	g.Status = models.StateRunning
+	return nil
+}
+
+// schedule checks that the action applies to the guest's current state and
+// records a change to the to state that completes after delay
+func (c *FileClient) schedule(vmid string, applies func(*models.VMStatus) bool, to models.NodeState, delay time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := c.find(vmid)
+	if g == nil {
+		return proxmox.ErrNodeNotFound
+	}
+	if !applies(g) {
+		return fmt.Errorf("guest %s is %s", vmid, g.Status)
+	}
+	c.pending[vmid] = transition{status: to, at: c.now().Add(delay)}
+	return nil
+}
+
+// applyPending completes the actions that are due. Must be called with mu held.
+func (c *FileClient) applyPending(now time.Time) {
+	for vmid, t := range c.pending {
+		if now.Before(t.at) {
+			continue
+		}
+		if g := c.find(vmid); g != nil {
+			setStatus(g, t.status)
+		}
+		delete(c.pending, vmid)
+	}
+}
+
+// setStatus changes a guest's status the way the API reports it: stopped
+// guests use no CPU or memory, freshly (re)started ones have no uptime yet
+func setStatus(g *models.VMStatus, status models.NodeState) {
+	g.Status = status
+	g.Uptime = 0
+	switch status {
+	case models.StateRunning:
+		g.CPUUsage = 5
+		g.MemoryUsage = 20
+	case models.StateStopped, models.StateHibernated:
+		g.CPUUsage = 0
+		g.MemoryUsage = 0
+	}
+}
+
+// find returns the guest with the given VMID. Must be called with mu held.
+func (c *FileClient) find(vmid string) *models.VMStatus {
+	for _, g := range c.guests {
+		if g.VMID == vmid {
+			return g
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package demo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// fakeClock is a manually advanced time source
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time { return f.t }
+
+func newTestClient(t *testing.T) (*FileClient, *fakeClock) {
+	t.Helper()
+	c, err := NewFileClient("")
+	require.NoError(t, err)
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	c.now = clock.now
+	c.lastRefresh = clock.t
+	return c, clock
+}
+
+func findGuest(nodes []*models.VMStatus, name string) *models.VMStatus {
+	for _, n := range nodes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+func TestNewFileClient_DefaultFixture(t *testing.T) {
+	var client proxmox.Client
+	client, err := NewFileClient("")
+	require.NoError(t, err)
+
+	nodes, err := client.GetNodes(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, nodes, 30)
+
+	hosts := map[string]bool{}
+	types := map[models.NodeType]bool{}
+	for _, n := range nodes {
+		hosts[n.Node] = true
+		types[n.Type] = true
+	}
+	assert.Len(t, hosts, 3)
+	assert.True(t, types["qemu"] && types["lxc"], "fixture should mix VMs and containers")
+}
+
+func TestNewFileClient_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"data": [
+		{"type": "qemu", "vmid": 100, "name": "only", "node": "pve", "status": "stopped", "maxmem": 1073741824}
+	]}`), 0o600))
+
+	c, err := NewFileClient(path)
+	require.NoError(t, err)
+	nodes, err := c.GetNodes(context.Background())
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "only", nodes[0].Name)
+
+	_, err = NewFileClient(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte("{"))
+	assert.Error(t, err)
+}
+
+func TestGetNodes_Jitter(t *testing.T) {
+	c, clock := newTestClient(t)
+	ctx := context.Background()
+
+	before, err := c.GetNodes(ctx)
+	require.NoError(t, err)
+	clock.t = clock.t.Add(10 * time.Second)
+	after, err := c.GetNodes(ctx)
+	require.NoError(t, err)
+
+	moved := false
+	for i := range after {
+		b, a := before[i], after[i]
+		if !a.IsRunning() {
+			assert.Equal(t, b.CPUUsage, a.CPUUsage, "%s is not running", a.Name)
+			assert.Equal(t, b.Uptime, a.Uptime, "%s is not running", a.Name)
+			continue
+		}
+		assert.Equal(t, b.Uptime+10, a.Uptime, "uptime of %s should follow the clock", a.Name)
+		assert.True(t, a.CPUUsage >= 0 && a.CPUUsage <= 100, "CPU of %s out of range: %v", a.Name, a.CPUUsage)
+		assert.True(t, a.MemoryUsage >= 0 && a.MemoryUsage <= 100, "memory of %s out of range: %v", a.Name, a.MemoryUsage)
+		moved = moved || a.CPUUsage != b.CPUUsage
+	}
+	assert.True(t, moved, "running guests should jitter")
+
+	// Callers get copies
+	after[0].Name = "changed"
+	again, err := c.GetNodes(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, "changed", again[0].Name)
+}
+
+func TestActions(t *testing.T) {
+	c, clock := newTestClient(t)
+	ctx := context.Background()
+
+	nodes, err := c.GetNodes(ctx)
+	require.NoError(t, err)
+	stopped := findGuest(nodes, "web-3")
+	running := findGuest(nodes, "web-1")
+	require.NotNil(t, stopped)
+	require.NotNil(t, running)
+
+	t.Run("start completes after the delay", func(t *testing.T) {
+		require.NoError(t, c.Start(ctx, stopped.Node, stopped.TypeString(), stopped.VMID))
+
+		guest, err := c.GetGuestStatus(ctx, stopped.Node, stopped.TypeString(), stopped.VMID)
+		require.NoError(t, err)
+		assert.Equal(t, models.StateStopped, guest.Status)
+
+		clock.t = clock.t.Add(DefaultActionDelay)
+		guest, err = c.GetGuestStatus(ctx, stopped.Node, stopped.TypeString(), stopped.VMID)
+		require.NoError(t, err)
+		assert.Equal(t, models.StateRunning, guest.Status)
+	})
+
+	t.Run("stop is immediate", func(t *testing.T) {
+		require.NoError(t, c.Stop(ctx, running.Node, running.TypeString(), running.VMID))
+		guest, err := c.GetGuestStatus(ctx, running.Node, running.TypeString(), running.VMID)
+		require.NoError(t, err)
+		assert.Equal(t, models.StateStopped, guest.Status)
+		assert.Zero(t, guest.CPUUsage)
+		assert.Zero(t, guest.Uptime)
+	})
+
+	t.Run("resume is immediate", func(t *testing.T) {
+		paused := findGuest(nodes, "win-desktop")
+		require.NotNil(t, paused)
+		require.Equal(t, models.StatePaused, paused.Status)
+
+		require.NoError(t, c.Resume(ctx, paused.Node, paused.TypeString(), paused.VMID))
+		guest, err := c.GetGuestStatus(ctx, paused.Node, paused.TypeString(), paused.VMID)
+		require.NoError(t, err)
+		assert.Equal(t, models.StateRunning, guest.Status)
+		assert.Equal(t, paused.Uptime, guest.Uptime, "resuming keeps the uptime")
+		assert.Error(t, c.Resume(ctx, paused.Node, paused.TypeString(), paused.VMID), "already running")
+	})
+
+	t.Run("hibernated guests start", func(t *testing.T) {
+		hibernated := findGuest(nodes, "dev-bob")
+		require.NotNil(t, hibernated)
+		require.Equal(t, models.StateHibernated, hibernated.Status)
+
+		require.NoError(t, c.Start(ctx, hibernated.Node, hibernated.TypeString(), hibernated.VMID))
+		clock.t = clock.t.Add(DefaultActionDelay)
+		guest, err := c.GetGuestStatus(ctx, hibernated.Node, hibernated.TypeString(), hibernated.VMID)
+		require.NoError(t, err)
+		assert.Equal(t, models.StateRunning, guest.Status)
+	})
+
+	t.Run("hibernate completes after the delay", func(t *testing.T) {
+		vm := findGuest(nodes, "db-replica")
+		require.NotNil(t, vm)
+		require.NoError(t, c.Hibernate(ctx, vm.Node, vm.VMID))
+		clock.t = clock.t.Add(DefaultActionDelay)
+		guest, err := c.GetGuestStatus(ctx, vm.Node, vm.TypeString(), vm.VMID)
+		require.NoError(t, err)
+		assert.Equal(t, models.StateHibernated, guest.Status)
+		assert.Zero(t, guest.CPUUsage)
+		assert.Error(t, c.Hibernate(ctx, vm.Node, vm.VMID), "already hibernated")
+		ct := findGuest(nodes, "cache-1")
+		require.NotNil(t, ct)
+		assert.Error(t, c.Hibernate(ctx, ct.Node, ct.VMID), "containers can't be hibernated")
+	})
+
+	t.Run("actions check the current state", func(t *testing.T) {
+		assert.Error(t, c.Start(ctx, stopped.Node, stopped.TypeString(), stopped.VMID), "already running")
+		assert.Error(t, c.Shutdown(ctx, running.Node, running.TypeString(), running.VMID), "already stopped")
+		assert.ErrorIs(t, c.Reboot(ctx, "pve1", "qemu", "999"), proxmox.ErrNodeNotFound)
+	})
+}
+
+func TestGetVMConfig(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	fromFixture, err := c.GetVMConfig(ctx, "pve1", "qemu", "103")
+	require.NoError(t, err)
+	assert.Equal(t, "Primary PostgreSQL server", fromFixture["description"])
+	fromFixture["description"] = "changed"
+	again, err := c.GetVMConfig(ctx, "pve1", "qemu", "103")
+	require.NoError(t, err)
+	assert.Equal(t, "Primary PostgreSQL server", again["description"], "Callers get a copy of the fixture")
+
+	canned, err := c.GetVMConfig(ctx, "pve1", "qemu", "100")
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", canned["name"])
+	assert.Contains(t, canned["scsi0"], "size=")
+
+	_, err = c.GetVMConfig(ctx, "pve1", "qemu", "999")
+	assert.ErrorIs(t, err, proxmox.ErrNodeNotFound)
+}
+
+func TestGetAgentFSInfo(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	filesystems, err := c.GetAgentFSInfo(ctx, "pve1", "100")
+	require.NoError(t, err)
+	require.Len(t, filesystems, 1)
+	assert.Equal(t, "/", filesystems[0].Mountpoint)
+	assert.Less(t, filesystems[0].UsedBytes, filesystems[0].TotalBytes)
+
+	_, err = c.GetAgentFSInfo(ctx, "pve3", "102") // web-3 is stopped
+	assert.Error(t, err)
+}
+
+func TestGetAgentHostName(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	hostname, err := c.GetAgentHostName(ctx, "pve1", "100")
+	require.NoError(t, err)
+	assert.NotEmpty(t, hostname)
+
+	_, err = c.GetAgentHostName(ctx, "pve3", "102") // web-3 is stopped
+	assert.Error(t, err)
+}
@@ -0,0 +1,222 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// CheckVersion checks that the URL serves the Proxmox API, warning about
+// what its release lacks and about a release newer than pvec knows
+func CheckVersion(ctx context.Context, client *proxmox.HTTPClient) Result {
+	r := Result{Name: "API version"}
+	version, err := client.GetVersion(ctx)
+	switch {
+	case err == nil:
+		r.Detail = "Proxmox VE " + version
+		caps := proxmox.CapabilitiesFor(version)
+		if caps.NewerThanKnown() {
+			r.Status = Warn
+			r.Detail += fmt.Sprintf(", newer than PVE %d which this pvec knows", proxmox.NewestKnownMajor)
+			r.Remedy = "update pvec; some actions may fail on this release"
+		} else if missing := caps.Missing(); len(missing) > 0 {
+			r.Status = Warn
+			r.Detail += "; " + strings.Join(missing, ", ")
+		}
+	case proxmox.IsUnauthorized(err) || proxmox.IsForbidden(err):
+		r.Detail = "the API answers, but hides its version from this token"
+	default:
+		r.Status, r.Detail = Fail, err.Error()
+		r.Remedy = "api_url must be the Proxmox web interface, e.g. https://pve.example.com:8006"
+	}
+	return r
+}
+
+// CheckToken checks that the token authenticates, returning its privileges
+func CheckToken(ctx context.Context, client *proxmox.HTTPClient) (Result, models.Permissions) {
+	r := Result{Name: "Token"}
+	perms, err := client.GetPermissions(ctx)
+	switch {
+	case proxmox.IsUnauthorized(err):
+		r.Status, r.Detail = Fail, "the server rejects the token"
+		r.Remedy = "check token_id (user@realm!name) and token_secret; the token may have expired or been deleted"
+		return r, nil
+	case err != nil:
+		r.Status, r.Detail = Fail, err.Error()
+		return r, nil
+	}
+	r.Detail = "the token authenticates"
+	return r, perms
+}
+
+// CheckPrivileges checks the token has the privileges pvec uses. Missing
+// VM.Audit fails, since nothing would be listed; anything else only warns.
+func CheckPrivileges(perms models.Permissions) Result {
+	r := Result{Name: "Privileges"}
+	missing := proxmox.MissingRequirements(perms)
+	if len(missing) == 0 {
+		r.Detail = "every privilege pvec uses is granted"
+		return r
+	}
+
+	var names []string
+	for _, req := range missing {
+		names = append(names, fmt.Sprintf("%s on %s", req.Privilege, req.Path))
+		if req.Essential {
+			r.Status = Fail
+		}
+	}
+	if r.Status != Fail {
+		r.Status = Warn
+	}
+	r.Detail = "missing " + strings.Join(names, ", ")
+	r.Remedy = "grant them to the token, or run 'pvec permissions' for details"
+	return r
+}
+
+// CheckResources checks that the cluster lists guests, returning them
+func CheckResources(ctx context.Context, client *proxmox.HTTPClient) (Result, []*models.VMStatus) {
+	r := Result{Name: "Guest list"}
+	guests, err := client.GetNodes(ctx)
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		if hint := proxmox.PermissionHint(err); hint != "" {
+			r.Remedy = "the token " + hint
+		}
+		return r, nil
+	}
+	if len(guests) == 0 {
+		r.Status, r.Detail = Warn, "no guests visible"
+		r.Remedy = "grant VM.Audit on /vms, or on the pools holding the guests"
+		return r, nil
+	}
+
+	nodes := make(map[string]bool)
+	for _, g := range guests {
+		nodes[g.Node] = true
+	}
+	r.Detail = fmt.Sprintf("%s on %s", count(len(guests), "guest"), count(len(nodes), "node"))
+	return r, guests
+}
+
+// CheckGuestAccess probes the first guest: its config must be readable,
+// and powering it should be allowed. The probe is a GET, so nothing
+// changes on the server.
+func CheckGuestAccess(ctx context.Context, client *proxmox.HTTPClient, guests []*models.VMStatus, perms models.Permissions) Result {
+	r := Result{Name: "Guest access"}
+	if len(guests) == 0 {
+		r.Status, r.Detail = Skip, "no guest to probe"
+		return r
+	}
+
+	g := guests[0]
+	if _, err := client.GetVMConfig(ctx, g.Node, g.TypeString(), g.VMID); err != nil {
+		r.Status, r.Detail = Fail, fmt.Sprintf("cannot read the config of %s: %v", g.VMID, err)
+		if hint := proxmox.PermissionHint(err); hint != "" {
+			r.Remedy = "the token " + hint
+		}
+		return r
+	}
+
+	r.Detail = fmt.Sprintf("read the config of %s (%s)", g.VMID, g.Name)
+	if perms != nil && !perms.Has("/vms/"+g.VMID, "VM.PowerMgmt") {
+		r.Status = Warn
+		r.Detail += ", but cannot start or stop it"
+		r.Remedy = "grant VM.PowerMgmt on /vms to use the power actions"
+	}
+	return r
+}
+
+// CheckFeatures probes the optional sources of the refresh the config
+// leaves to detection, as pvec does on first connect, and lists those it
+// reads. One the token may not read warns, since pvec would drop it.
+func CheckFeatures(ctx context.Context, client *proxmox.HTTPClient, settings map[string]bool) Result {
+	r := Result{Name: "Sources"}
+	features := proxmox.NewFeatureSet(proxmox.FeatureSettings(settings))
+	features.Detect(ctx, client)
+
+	var on, off, hints []string
+	for _, c := range features.Capabilities() {
+		if c.Enabled {
+			on = append(on, string(c.Feature))
+		} else {
+			off = append(off, fmt.Sprintf("%s (%s)", c.Feature, c.Reason))
+		}
+		switch {
+		case proxmox.IsForbidden(c.Err):
+			r.Status = Warn
+			if hint := proxmox.PermissionHint(c.Err); hint != "" {
+				hints = append(hints, hint)
+			}
+		case c.Err != nil && c.Enabled:
+			r.Status = Warn // Undecided: pvec probes it again on every refresh
+		}
+	}
+	r.Detail = "reads guests"
+	if len(on) > 0 {
+		r.Detail += ", " + strings.Join(on, ", ")
+	}
+	if len(off) > 0 {
+		r.Detail += "; off: " + strings.Join(off, ", ")
+	}
+	if r.Status == Warn {
+		r.Remedy = "grant the token what it lacks, or turn the source off in the features block"
+		if len(hints) > 0 {
+			r.Remedy = "the token " + strings.Join(hints, ", ") + "; or turn the source off in the features block"
+		}
+	}
+	return r
+}
+
+// count formats n with the noun, plural unless n is 1
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// CheckGuestNames looks for guests sharing a name, which pvec marks with ≡
+// and refuses to resolve by name, and for VMIDs used by several of the
+// merged clusters, which then only tell guests apart with their cluster
+func CheckGuestNames(guests []*models.VMStatus) Result {
+	r := Result{Name: "Guest names"}
+	names := models.DuplicateNames(guests)
+	collisions := models.VMIDCollisions(guests)
+	if len(names) == 0 && len(collisions) == 0 {
+		r.Detail = count(len(guests), "guest") + " with distinct names and VMIDs"
+		return r
+	}
+
+	var found, remedies []string
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		found = append(found, fmt.Sprintf("%q is the name of %s", names[name][0].Name, guestList(names[name])))
+	}
+	if len(names) > 0 {
+		remedies = append(remedies, "rename the guests so no two share a name")
+	}
+	for _, vmid := range slices.Sorted(maps.Keys(collisions)) {
+		found = append(found, fmt.Sprintf("VMID %s is used by %s", vmid, guestList(collisions[vmid])))
+	}
+	if len(collisions) > 0 {
+		remedies = append(remedies, "give each cluster its own VMID range")
+	}
+	r.Status = Warn
+	r.Detail = strings.Join(found, "; ")
+	r.Remedy = strings.Join(remedies, ", and ")
+	return r
+}
+
+// guestList names guests with their node, e.g. "100 on pve1, 205 on pve2"
+func guestList(guests []*models.VMStatus) string {
+	list := make([]string, len(guests))
+	for i, g := range guests {
+		list[i] = g.Key() + " on " + g.Node
+	}
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,189 @@
+package doctor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// fakeReply is a canned API response
+type fakeReply struct {
+	status int
+	body   string
+}
+
+// fakeAPI serves canned replies by path under /api2/json. Paths without a
+// reply answer like a healthy cluster with one guest.
+func fakeAPI(t *testing.T, replies map[string]fakeReply) *httptest.Server {
+	t.Helper()
+	healthy := map[string]fakeReply{
+		"/version":                    {200, `{"data":{"version":"8.2.4","release":"8.2"}}`},
+		"/access/permissions":         {200, `{"data":{"/vms":{"VM.Audit":1,"VM.PowerMgmt":1}}}`},
+		"/cluster/resources":          {200, `{"data":[{"id":"qemu/100","type":"qemu","vmid":100,"name":"web","node":"pve1","status":"running"}]}`},
+		"/nodes/pve1/qemu/100/config": {200, `{"data":{"name":"web","memory":2048}}`},
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path[len("/api2/json"):]
+		reply, ok := replies[path]
+		if !ok {
+			reply, ok = healthy[path]
+		}
+		if !ok {
+			reply = fakeReply{404, ""}
+		}
+		w.WriteHeader(reply.status)
+		_, _ = w.Write([]byte(reply.body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func fakeClient(t *testing.T, server *httptest.Server) *proxmox.HTTPClient {
+	t.Helper()
+	client, err := proxmox.NewHTTPClient(proxmox.ClientOptions{
+		BaseURL:       server.URL,
+		TokenID:       "root@pam!pvec",
+		TokenSecret:   "secret",
+		SkipTLSVerify: true,
+	})
+	require.NoError(t, err)
+	return client
+}
+
+func TestCheckVersion(t *testing.T) {
+	r := CheckVersion(context.Background(), fakeClient(t, fakeAPI(t, nil)))
+	assert.Equal(t, Pass, r.Status)
+	assert.Equal(t, "Proxmox VE 8.2.4", r.Detail)
+
+	r = CheckVersion(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{"/version": {401, ""}})))
+	assert.Equal(t, Pass, r.Status, "the API answered")
+
+	r = CheckVersion(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{"/version": {404, "<html>"}})))
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Remedy, ":8006")
+
+	r = CheckVersion(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{"/version": {200, `{"data":{"version":"7.2-7"}}`}})))
+	assert.Equal(t, Warn, r.Status)
+	assert.Equal(t, "Proxmox VE 7.2-7; editing tags requires PVE ≥ 7.3, disk resize as a task requires PVE ≥ 8.0", r.Detail)
+
+	r = CheckVersion(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{"/version": {200, `{"data":{"version":"10.0.1"}}`}})))
+	assert.Equal(t, Warn, r.Status)
+	assert.Contains(t, r.Detail, "newer than PVE 9")
+	assert.Contains(t, r.Remedy, "update pvec")
+}
+
+func TestCheckToken(t *testing.T) {
+	r, perms := CheckToken(context.Background(), fakeClient(t, fakeAPI(t, nil)))
+	assert.Equal(t, Pass, r.Status)
+	assert.True(t, perms.Has("/vms/100", "VM.Audit"))
+
+	r, perms = CheckToken(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{
+		"/access/permissions": {401, "authentication failure"},
+	})))
+	assert.Nil(t, perms)
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Remedy, "token_secret")
+}
+
+func TestCheckPrivileges(t *testing.T) {
+	all := models.Permissions{"/": {}}
+	for _, req := range proxmox.Requirements {
+		all["/"][req.Privilege] = true
+	}
+	assert.Equal(t, Pass, CheckPrivileges(all).Status)
+
+	r := CheckPrivileges(models.Permissions{"/vms": {"VM.Audit": true}})
+	assert.Equal(t, Warn, r.Status)
+	assert.Contains(t, r.Detail, "missing VM.PowerMgmt on /vms, VM.Monitor on /vms")
+
+	r = CheckPrivileges(models.Permissions{})
+	assert.Equal(t, Fail, r.Status, "without VM.Audit nothing is listed")
+}
+
+func TestCheckResources(t *testing.T) {
+	r, guests := CheckResources(context.Background(), fakeClient(t, fakeAPI(t, nil)))
+	assert.Equal(t, Pass, r.Status)
+	assert.Equal(t, "1 guest on 1 node", r.Detail)
+	require.Len(t, guests, 1)
+
+	r, guests = CheckResources(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{
+		"/cluster/resources": {200, `{"data":[]}`},
+	})))
+	assert.Empty(t, guests)
+	assert.Equal(t, Warn, r.Status)
+
+	r, _ = CheckResources(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{
+		"/cluster/resources": {403, `{"data":null,"message":"Permission check failed (/, Sys.Audit)\n"}`},
+	})))
+	assert.Equal(t, Fail, r.Status)
+	assert.Equal(t, "the token needs Sys.Audit on /", r.Remedy)
+}
+
+func TestCheckGuestAccess(t *testing.T) {
+	guests := []*models.VMStatus{{VMID: "100", Name: "web", Node: "pve1", Type: models.TypeVM}}
+	granted := models.Permissions{"/vms": {"VM.Audit": true, "VM.PowerMgmt": true}}
+
+	r := CheckGuestAccess(context.Background(), fakeClient(t, fakeAPI(t, nil)), guests, granted)
+	assert.Equal(t, Pass, r.Status, r.Detail)
+	assert.Equal(t, "read the config of 100 (web)", r.Detail)
+
+	r = CheckGuestAccess(context.Background(), fakeClient(t, fakeAPI(t, nil)), guests, models.Permissions{"/vms": {"VM.Audit": true}})
+	assert.Equal(t, Warn, r.Status)
+	assert.Contains(t, r.Remedy, "VM.PowerMgmt")
+
+	r = CheckGuestAccess(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{
+		"/nodes/pve1/qemu/100/config": {403, `{"data":null,"message":"Permission check failed (/vms/100, VM.Audit)\n"}`},
+	})), guests, granted)
+	assert.Equal(t, Fail, r.Status)
+	assert.Equal(t, "the token needs VM.Audit on /vms/100", r.Remedy)
+
+	assert.Equal(t, Skip, CheckGuestAccess(context.Background(), nil, nil, granted).Status)
+}
+
+func TestCheckFeatures(t *testing.T) {
+	r := CheckFeatures(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{
+		"/nodes":                {200, `{"data":[]}`},
+		"/pools":                {501, ""},
+		"/cluster/ha/resources": {200, `{"data":[]}`},
+		"/cluster/tasks":        {200, `{"data":[]}`},
+	})), nil)
+	assert.Equal(t, Pass, r.Status, r.Detail)
+	assert.Equal(t, "reads guests, node_status, storage, ha, tasks; off: pools (not implemented by this server), replication (not found on this server)", r.Detail)
+
+	r = CheckFeatures(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{
+		"/pools": {403, `{"data":null,"message":"Permission check failed (/pool, Pool.Audit)\n"}`},
+	})), map[string]bool{"ha": false, "replication": false, "node_status": false, "tasks": false})
+	assert.Equal(t, Warn, r.Status)
+	assert.Equal(t, "reads guests, storage; off: node_status (set in the config), pools (permission denied on /pools), ha (set in the config), replication (set in the config), tasks (set in the config)", r.Detail)
+	assert.Equal(t, "the token needs Pool.Audit on /pool; or turn the source off in the features block", r.Remedy)
+
+	r = CheckFeatures(context.Background(), fakeClient(t, fakeAPI(t, map[string]fakeReply{
+		"/cluster/replication": {500, "internal error"},
+	})), map[string]bool{"pools": false, "ha": false, "node_status": false})
+	assert.Equal(t, Warn, r.Status, "a source that errs is probed on every refresh")
+	assert.Contains(t, r.Detail, "replication")
+}
+
+func TestCheckGuestNames(t *testing.T) {
+	guests := []*models.VMStatus{
+		{VMID: "100", Name: "web", Node: "pve1", Cluster: "lab"},
+		{VMID: "101", Name: "db", Node: "pve1", Cluster: "lab"},
+	}
+	r := CheckGuestNames(guests)
+	assert.Equal(t, Pass, r.Status)
+	assert.Equal(t, "2 guests with distinct names and VMIDs", r.Detail)
+
+	guests = append(guests,
+		&models.VMStatus{VMID: "205", Name: "Web", Node: "pve2", Cluster: "lab"},
+		&models.VMStatus{VMID: "101", Name: "mail", Node: "p1", Cluster: "prod"})
+	r = CheckGuestNames(guests)
+	assert.Equal(t, Warn, r.Status)
+	assert.Equal(t, `"web" is the name of lab/100 on pve1, lab/205 on pve2; VMID 101 is used by lab/101 on pve1, prod/101 on p1`, r.Detail)
+	assert.Equal(t, "rename the guests so no two share a name, and give each cluster its own VMID range", r.Remedy)
+}
@@ -0,0 +1,65 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/config"
+)
+
+// CheckConfigFile checks that the config file exists, can be read, and
+// isn't readable by other users, since it holds the token secret
+func CheckConfigFile(path string) Result {
+	r := Result{Name: "Config file"}
+	info, err := os.Stat(path)
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		r.Remedy = "create it as shown in the README, or point to it with -c"
+		return r
+	}
+	if info.IsDir() {
+		r.Status, r.Detail = Fail, fmt.Sprintf("%s is a directory", path)
+		r.Remedy = "point -c at the config file itself"
+		return r
+	}
+
+	f, err := os.Open(path) // #nosec G304 - the user's own config file
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		r.Remedy = fmt.Sprintf("make it readable by your user: chmod 600 %s", path)
+		return r
+	}
+	_ = f.Close()
+
+	mode := info.Mode().Perm()
+	r.Detail = fmt.Sprintf("%s (mode %04o)", path, mode)
+	// Windows has no Unix modes; Go reports 0666 for any writable file
+	if runtime.GOOS != "windows" && mode&0o077 != 0 {
+		r.Status = Warn
+		r.Detail += ", readable by other users although it holds the token secret"
+		r.Remedy = fmt.Sprintf("chmod 600 %s", path)
+	}
+	return r
+}
+
+// CheckConfig loads the config file and validates its settings
+func CheckConfig(path string) (Result, *config.Config) {
+	r := Result{Name: "Config settings"}
+	cfg, err := config.NewLoader(path).Load()
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		r.Remedy = "the file must be JSON, YAML or TOML with at least api_url, token_id and token_secret, or clusters"
+		return r, nil
+	}
+	r.Detail = fmt.Sprintf("token %s for %s", cfg.TokenID, cfg.APIUrl)
+	if len(cfg.Clusters) > 0 {
+		names := make([]string, len(cfg.Clusters))
+		for i, c := range cfg.Clusters {
+			names[i] = c.Name
+		}
+		r.Detail = fmt.Sprintf("%d clusters: %s", len(names), strings.Join(names, ", "))
+	}
+	return r, cfg
+}
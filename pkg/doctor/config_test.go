@@ -0,0 +1,66 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a config file with the given mode and returns its path
+func writeConfig(t *testing.T, content string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".pvecrc")
+	require.NoError(t, os.WriteFile(path, []byte(content), mode))
+	require.NoError(t, os.Chmod(path, mode))
+	return path
+}
+
+const validConfig = `{"api_url": "https://127.0.0.1:8006", "token_id": "root@pam!pvec", "token_secret": "secret"}`
+
+func TestCheckConfigFile(t *testing.T) {
+	r := CheckConfigFile(writeConfig(t, validConfig, 0o600))
+	assert.Equal(t, Pass, r.Status, r.Detail)
+	assert.Contains(t, r.Detail, "mode 0600")
+
+	r = CheckConfigFile(filepath.Join(t.TempDir(), "missing"))
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Remedy, "-c")
+
+	r = CheckConfigFile(t.TempDir())
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Detail, "is a directory")
+}
+
+func TestCheckConfigFile_Permissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix file modes")
+	}
+	path := writeConfig(t, validConfig, 0o644)
+	r := CheckConfigFile(path)
+	assert.Equal(t, Warn, r.Status)
+	assert.Equal(t, "chmod 600 "+path, r.Remedy)
+
+	if os.Geteuid() != 0 { // root reads anything
+		r = CheckConfigFile(writeConfig(t, validConfig, 0o200))
+		assert.Equal(t, Fail, r.Status)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	r, cfg := CheckConfig(writeConfig(t, validConfig, 0o600))
+	require.NotNil(t, cfg)
+	assert.Equal(t, Pass, r.Status)
+	assert.Equal(t, "token root@pam!pvec for https://127.0.0.1:8006", r.Detail)
+
+	r, cfg = CheckConfig(writeConfig(t, `{"api_url": "https://pve:8006"}`, 0o600))
+	assert.Nil(t, cfg)
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Detail, "token_id is required")
+
+	r, _ = CheckConfig(writeConfig(t, `{not json`, 0o600))
+	assert.Equal(t, Fail, r.Status)
+}
@@ -0,0 +1,219 @@
+// Package doctor checks step by step that pvec can reach and use a Proxmox
+// server: the config file, the URL, the network, TLS, the API, the token,
+// its privileges and the optional sources it can read. Each failed check says how to fix it.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// CheckTimeout bounds each check that talks to the network
+const CheckTimeout = 10 * time.Second
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	// Pass means the check succeeded
+	Pass Status = iota
+	// Warn means pvec works, but something is worth fixing
+	Warn
+	// Fail means pvec won't work until it is fixed
+	Fail
+	// Skip means the check couldn't run because an earlier one failed
+	Skip
+)
+
+// String returns the label printed for the status
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "PASS"
+	case Warn:
+		return "WARN"
+	case Fail:
+		return "FAIL"
+	}
+	return "SKIP"
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name   string
+	Status Status
+	Detail string // What was found
+	Remedy string // How to fix a warning or failure
+}
+
+// skipped returns the result of a check that couldn't run
+func skipped(name string) Result {
+	return Result{Name: name, Status: Skip, Detail: "an earlier check failed"}
+}
+
+// Run runs every check against the config file at path, printing each
+// result to w as it completes. It reports whether no check failed.
+func Run(ctx context.Context, w io.Writer, path string) bool {
+	ok := true
+	report := func(r Result) {
+		fmt.Fprintf(w, "[%s] %-16s %s\n", r.Status, r.Name, r.Detail)
+		if r.Remedy != "" {
+			fmt.Fprintf(w, "       %-16s fix: %s\n", "", r.Remedy)
+		}
+		if r.Status == Fail {
+			ok = false
+		}
+	}
+	var cfg *config.Config
+	res := CheckConfigFile(path)
+	report(res)
+	if res.Status == Fail {
+		report(skipped("Config settings"))
+	} else {
+		res, cfg = CheckConfig(path)
+		report(res)
+	}
+
+	if cfg == nil {
+		checkServer(ctx, report, nil)
+		report(skipped("Guest names"))
+		return ok
+	}
+	// Names and VMIDs are checked across every cluster, as the list
+	// merges them
+	var guests []*models.VMStatus
+	list := servers(cfg)
+	for _, srv := range list {
+		if srv.cluster != "" {
+			fmt.Fprintf(w, "Cluster %s\n", srv.cluster)
+		}
+		for _, g := range checkServer(ctx, report, &srv) {
+			g.Cluster = srv.cluster
+			guests = append(guests, g)
+		}
+	}
+	if len(list) > 1 {
+		fmt.Fprintf(w, "All clusters\n")
+	}
+	if guests == nil {
+		report(skipped("Guest names"))
+	} else {
+		report(CheckGuestNames(guests))
+	}
+	return ok
+}
+
+// server is one Proxmox server the checks run against
+type server struct {
+	cluster       string // Name of the cluster; empty for a single server
+	apiURL        string
+	tokenID       string
+	tokenSecret   string
+	skipTLSVerify bool
+	fingerprint   string          // tls_fingerprint, pinning the certificate
+	features      map[string]bool // The features block, shared by every cluster
+}
+
+// servers returns the servers cfg connects to: each of its clusters, or
+// the single server it names
+func servers(cfg *config.Config) []server {
+	if len(cfg.Clusters) == 0 {
+		return []server{{apiURL: cfg.APIUrl, tokenID: cfg.TokenID, tokenSecret: cfg.TokenSecret, skipTLSVerify: cfg.SkipTLSVerify, fingerprint: cfg.TLSFingerprint, features: cfg.FeatureSettings()}}
+	}
+	list := make([]server, len(cfg.Clusters))
+	for i, c := range cfg.Clusters {
+		list[i] = server{cluster: c.Name, apiURL: c.APIUrl, tokenID: c.TokenID, tokenSecret: c.TokenSecret, skipTLSVerify: c.SkipTLSVerify, fingerprint: c.TLSFingerprint, features: cfg.FeatureSettings()}
+	}
+	return list
+}
+
+// checkServer runs the network, API and token checks against srv,
+// skipping them all when srv is nil because the config couldn't be read.
+// It returns the guests listed, nil if none could be.
+func checkServer(ctx context.Context, report func(Result), srv *server) []*models.VMStatus {
+	bounded := func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(ctx, CheckTimeout)
+	}
+
+	var u *url.URL
+	if srv != nil {
+		c, cancel := bounded()
+		var res Result
+		res, u = CheckURL(c, srv.apiURL)
+		cancel()
+		report(res)
+	} else {
+		report(skipped("API URL"))
+	}
+
+	network := []struct {
+		name  string
+		check func(context.Context) Result
+	}{
+		{"TCP connect", func(c context.Context) Result { return CheckTCP(c, u) }},
+		{"TLS", func(c context.Context) Result { return CheckTLS(c, u, srv.skipTLSVerify, srv.fingerprint, nil) }},
+	}
+	reachable := u != nil
+	for _, step := range network {
+		if !reachable {
+			report(skipped(step.name))
+			continue
+		}
+		c, cancel := bounded()
+		res := step.check(c)
+		cancel()
+		report(res)
+		reachable = res.Status != Fail
+	}
+
+	var client *proxmox.HTTPClient
+	if reachable {
+		var err error
+		client, err = proxmox.NewHTTPClient(proxmox.ClientOptions{
+			BaseURL:        srv.apiURL,
+			TokenID:        srv.tokenID,
+			TokenSecret:    srv.tokenSecret,
+			SkipTLSVerify:  srv.skipTLSVerify,
+			TLSFingerprint: srv.fingerprint,
+		})
+		if err != nil {
+			report(Result{Name: "API client", Status: Fail, Detail: err.Error()})
+			client = nil
+		}
+	}
+
+	var perms models.Permissions
+	var guests []*models.VMStatus
+	steps := []struct {
+		name  string
+		check func(context.Context) Result
+	}{
+		{"API version", func(c context.Context) Result { return CheckVersion(c, client) }},
+		{"Token", func(c context.Context) (r Result) { r, perms = CheckToken(c, client); return r }},
+		{"Privileges", func(context.Context) Result { return CheckPrivileges(perms) }},
+		{"Guest list", func(c context.Context) (r Result) { r, guests = CheckResources(c, client); return r }},
+		{"Guest access", func(c context.Context) Result { return CheckGuestAccess(c, client, guests, perms) }},
+		{"Sources", func(c context.Context) Result { return CheckFeatures(c, client, srv.features) }},
+	}
+	for _, step := range steps {
+		if client == nil {
+			report(skipped(step.name))
+			continue
+		}
+		c, cancel := bounded()
+		res := step.check(c)
+		cancel()
+		report(res)
+		if step.name == "Token" && res.Status == Fail {
+			client = nil // Nothing else can work without authentication
+		}
+	}
+	return guests
+}
@@ -0,0 +1,103 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+func TestStatus_String(t *testing.T) {
+	assert.Equal(t, "PASS", Pass.String())
+	assert.Equal(t, "WARN", Warn.String())
+	assert.Equal(t, "FAIL", Fail.String())
+	assert.Equal(t, "SKIP", Skip.String())
+}
+
+func TestRun_Healthy(t *testing.T) {
+	server := fakeAPI(t, nil)
+	path := writeConfig(t, fmt.Sprintf(`{"api_url": %q, "token_id": "root@pam!pvec", "token_secret": "secret", "skip_tls_verify": true}`, server.URL), 0o600)
+
+	var out bytes.Buffer
+	ok := Run(context.Background(), &out, path)
+	assert.True(t, ok, out.String())
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var names []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "[") {
+			names = append(names, strings.TrimSpace(line[7:23]))
+		}
+	}
+	assert.Equal(t, []string{"Config file", "Config settings", "API URL", "TCP connect", "TLS",
+		"API version", "Token", "Privileges", "Guest list", "Guest access", "Sources", "Guest names"}, names)
+	assert.Contains(t, out.String(), "[WARN] TLS")
+	assert.Contains(t, out.String(), "[PASS] Guest access     read the config of 100 (web)")
+}
+
+func TestRun_Unreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	path := writeConfig(t, fmt.Sprintf(`{"api_url": "https://%s", "token_id": "root@pam!pvec", "token_secret": "secret"}`, addr), 0o600)
+
+	var out bytes.Buffer
+	assert.False(t, Run(context.Background(), &out, path))
+	assert.Contains(t, out.String(), "[FAIL] TCP connect")
+	assert.Contains(t, out.String(), "[SKIP] TLS")
+	assert.Contains(t, out.String(), "[SKIP] Guest access")
+}
+
+func TestRun_BadToken(t *testing.T) {
+	server := fakeAPI(t, map[string]fakeReply{"/access/permissions": {401, ""}})
+	path := writeConfig(t, fmt.Sprintf(`{"api_url": %q, "token_id": "root@pam!pvec", "token_secret": "wrong", "tls_fingerprint": %q}`,
+		server.URL, proxmox.Fingerprint(server.Certificate())), 0o600)
+
+	var out bytes.Buffer
+	assert.False(t, Run(context.Background(), &out, path))
+	assert.Contains(t, out.String(), "[PASS] TLS              certificate pinned by tls_fingerprint")
+	assert.Contains(t, out.String(), "[FAIL] Token")
+	assert.Contains(t, out.String(), "fix: check token_id")
+	assert.Contains(t, out.String(), "[SKIP] Guest list")
+}
+
+func TestRun_Clusters(t *testing.T) {
+	server := fakeAPI(t, nil)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	down := l.Addr().String()
+	require.NoError(t, l.Close())
+	path := writeConfig(t, fmt.Sprintf(`{"skip_tls_verify": true, "clusters": [
+		{"name": "lab", "api_url": %q, "token_id": "root@pam!pvec", "token_secret": "secret"},
+		{"name": "prod", "api_url": "https://%s", "token_id": "root@pam!pvec", "token_secret": "secret"}]}`, server.URL, down), 0o600)
+
+	var out bytes.Buffer
+	assert.False(t, Run(context.Background(), &out, path), "A cluster that fails fails the run")
+
+	report := out.String()
+	assert.Contains(t, report, "[PASS] Config settings  2 clusters: lab, prod")
+	lab, prod, found := strings.Cut(report, "Cluster prod\n")
+	require.True(t, found, report)
+	assert.Contains(t, lab, "Cluster lab\n")
+	assert.Contains(t, lab, "[PASS] Guest access")
+	assert.Contains(t, prod, "[FAIL] TCP connect")
+	_, all, found := strings.Cut(prod, "All clusters\n")
+	require.True(t, found, report)
+	assert.Contains(t, all, "[PASS] Guest names      1 guest with distinct names and VMIDs")
+}
+
+func TestRun_MissingConfig(t *testing.T) {
+	var out bytes.Buffer
+	assert.False(t, Run(context.Background(), &out, t.TempDir()+"/missing"))
+	assert.Contains(t, out.String(), "[FAIL] Config file")
+	assert.Contains(t, out.String(), "[SKIP] Config settings")
+	assert.Contains(t, out.String(), "[SKIP] API URL")
+	assert.Contains(t, out.String(), "[SKIP] Token")
+}
@@ -0,0 +1,134 @@
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// CheckURL checks that the API URL parses and its host resolves
+func CheckURL(ctx context.Context, rawURL string) (Result, *url.URL) {
+	r := Result{Name: "API URL"}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		r.Status, r.Detail = Fail, fmt.Sprintf("%q is not an http(s) URL", rawURL)
+		r.Remedy = "set api_url like https://pve.example.com:8006"
+		return r, nil
+	}
+
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		r.Detail = fmt.Sprintf("%s is an IP address", host)
+		return r, u
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		r.Status, r.Detail = Fail, fmt.Sprintf("cannot resolve %s: %v", host, err)
+		r.Remedy = "check the host name and your DNS, or use the node's IP address"
+		return r, nil
+	}
+	r.Detail = fmt.Sprintf("%s resolves to %s", host, strings.Join(addrs, ", "))
+	return r, u
+}
+
+// hostPort returns the address to dial for u, with the scheme's default
+// port when the URL has none
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// CheckTCP checks that the API port accepts connections
+func CheckTCP(ctx context.Context, u *url.URL) Result {
+	r := Result{Name: "TCP connect"}
+	addr := hostPort(u)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		r.Remedy = "check that pveproxy runs on the node and that no firewall blocks the port (8006 by default)"
+		return r
+	}
+	_ = conn.Close()
+	r.Detail = fmt.Sprintf("%s accepts connections", addr)
+	return r
+}
+
+// CheckTLS checks the server certificate against the fingerprint pin
+// when set, or roots, the system's trusted CAs when nil. An untrusted
+// certificate fails unless skipVerify is set, which only warns. Either way
+// the certificate's SHA-256 fingerprint is shown, to compare with the one
+// in the Proxmox UI.
+func CheckTLS(ctx context.Context, u *url.URL, skipVerify bool, pin string, roots *x509.CertPool) Result {
+	r := Result{Name: "TLS"}
+	if u.Scheme != "https" {
+		r.Status, r.Detail = Warn, "plain HTTP: the token is sent unencrypted"
+		r.Remedy = "use https:// in api_url"
+		return r
+	}
+
+	addr := hostPort(u)
+	verified := tls.Dialer{Config: &tls.Config{ServerName: u.Hostname(), RootCAs: roots, MinVersion: tls.VersionTLS12}}
+	conn, err := verified.DialContext(ctx, "tcp", addr)
+	var verifyErr *tls.CertificateVerificationError
+	if pin != "" && (err == nil || errors.As(err, &verifyErr)) {
+		cert := proxmox.UntrustedCertificate(err)
+		if err == nil {
+			cert = conn.(*tls.Conn).ConnectionState().PeerCertificates[0]
+			_ = conn.Close()
+		}
+		if proxmox.NormalizeFingerprint(proxmox.Fingerprint(cert)) != proxmox.NormalizeFingerprint(pin) {
+			r.Status = Fail
+			r.Detail = fmt.Sprintf("certificate doesn't match tls_fingerprint, %s", fingerprint(cert))
+			r.Remedy = "the certificate changed, or another server answers: compare the fingerprint with the node's certificate in the Proxmox UI before updating tls_fingerprint"
+			return r
+		}
+		r.Detail = "certificate pinned by tls_fingerprint, " + fingerprint(cert)
+		return r
+	}
+	if err == nil {
+		cert := conn.(*tls.Conn).ConnectionState().PeerCertificates[0]
+		_ = conn.Close()
+		r.Detail = "certificate trusted, " + fingerprint(cert)
+		if skipVerify {
+			r.Remedy = "skip_tls_verify isn't needed and can be turned off"
+		}
+		return r
+	}
+
+	if !errors.As(err, &verifyErr) {
+		r.Status, r.Detail = Fail, fmt.Sprintf("handshake failed: %v", err)
+		r.Remedy = "check that api_url points at the HTTPS port of pveproxy"
+		return r
+	}
+
+	cert := verifyErr.UnverifiedCertificates[0]
+	if skipVerify {
+		r.Status = Warn
+		r.Detail = fmt.Sprintf("certificate not trusted, accepted because skip_tls_verify is set, %s", fingerprint(cert))
+		r.Remedy = "compare the fingerprint with the node's certificate in the Proxmox UI; trust its CA to turn skip_tls_verify off"
+		return r
+	}
+	r.Status = Fail
+	r.Detail = fmt.Sprintf("certificate not trusted (%v), %s", verifyErr.Err, fingerprint(cert))
+	r.Remedy = "trust the cluster's CA, set tls_fingerprint to the fingerprint after comparing it with the node's certificate in the Proxmox UI, or skip_tls_verify to true"
+	return r
+}
+
+// fingerprint formats the SHA-256 of a certificate as Proxmox shows it
+func fingerprint(cert *x509.Certificate) string {
+	return "SHA-256 " + proxmox.Fingerprint(cert)
+}
@@ -0,0 +1,124 @@
+package doctor
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	return u
+}
+
+func TestCheckURL(t *testing.T) {
+	r, u := CheckURL(context.Background(), "https://127.0.0.1:8006")
+	require.NotNil(t, u)
+	assert.Equal(t, Pass, r.Status)
+
+	r, u = CheckURL(context.Background(), "https://localhost:8006")
+	require.NotNil(t, u, r.Detail)
+	assert.Contains(t, r.Detail, "localhost resolves to")
+
+	for _, bad := range []string{"pve.example.com:8006", "ftp://pve:21", "https://", "://"} {
+		r, u = CheckURL(context.Background(), bad)
+		assert.Nil(t, u, bad)
+		assert.Equal(t, Fail, r.Status, bad)
+	}
+
+	// .invalid never resolves (RFC 2606)
+	r, u = CheckURL(context.Background(), "https://pve.invalid:8006")
+	assert.Nil(t, u)
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Detail, "cannot resolve pve.invalid")
+}
+
+func TestHostPort(t *testing.T) {
+	assert.Equal(t, "pve:8006", hostPort(mustParse(t, "https://pve:8006")))
+	assert.Equal(t, "pve:443", hostPort(mustParse(t, "https://pve")))
+	assert.Equal(t, "pve:80", hostPort(mustParse(t, "http://pve")))
+	assert.Equal(t, "[::1]:8006", hostPort(mustParse(t, "https://[::1]:8006")))
+}
+
+func TestCheckTCP(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	assert.Equal(t, Pass, CheckTCP(context.Background(), mustParse(t, server.URL)).Status)
+
+	// A port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	r := CheckTCP(context.Background(), mustParse(t, "https://"+addr))
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Remedy, "firewall")
+}
+
+func TestCheckTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	u := mustParse(t, server.URL)
+
+	// httptest's certificate is self-signed
+	r := CheckTLS(context.Background(), u, false, "", nil)
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Detail, "SHA-256 ")
+	assert.Contains(t, r.Remedy, "skip_tls_verify")
+
+	r = CheckTLS(context.Background(), u, true, "", nil)
+	assert.Equal(t, Warn, r.Status)
+	assert.Contains(t, r.Detail, "accepted because skip_tls_verify is set")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	r = CheckTLS(context.Background(), u, false, "", roots)
+	assert.Equal(t, Pass, r.Status, r.Detail)
+	assert.Equal(t, "certificate trusted, "+fingerprint(server.Certificate()), r.Detail)
+	assert.Empty(t, r.Remedy)
+
+	r = CheckTLS(context.Background(), u, true, "", roots)
+	assert.Contains(t, r.Remedy, "skip_tls_verify isn't needed")
+}
+
+func TestCheckTLS_Pinned(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	u := mustParse(t, server.URL)
+
+	r := CheckTLS(context.Background(), u, false, strings.ToLower(proxmox.Fingerprint(server.Certificate())), nil)
+	assert.Equal(t, Pass, r.Status, r.Detail)
+	assert.Equal(t, "certificate pinned by tls_fingerprint, "+fingerprint(server.Certificate()), r.Detail)
+
+	r = CheckTLS(context.Background(), u, false, strings.Repeat("00", 32), nil)
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Detail, "doesn't match tls_fingerprint")
+}
+
+func TestCheckTLS_NotTLS(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	r := CheckTLS(context.Background(), mustParse(t, server.URL), true, "", nil)
+	assert.Equal(t, Warn, r.Status)
+	assert.Contains(t, r.Detail, "plain HTTP")
+
+	// An HTTPS URL pointing at a plain HTTP port
+	u := mustParse(t, server.URL)
+	u.Scheme = "https"
+	r = CheckTLS(context.Background(), u, true, "", nil)
+	assert.Equal(t, Fail, r.Status)
+	assert.Contains(t, r.Detail, "handshake failed")
+}
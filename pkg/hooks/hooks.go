@@ -0,0 +1,157 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// DefaultTimeout bounds how long a single hook invocation may run
+const DefaultTimeout = 30 * time.Second
+
+// Transition is a parsed state_change_filter entry; "*" matches any state
+type Transition struct {
+	From string
+	To   string
+}
+
+// Matches reports whether the transition filter accepts a change
+func (t Transition) Matches(change models.StateChange) bool {
+	return (t.From == "*" || t.From == string(change.OldState)) &&
+		(t.To == "*" || t.To == string(change.NewState))
+}
+
+// ParseFilter parses entries such as "running->stopped", "*->stopped" or "->stopped"
+func ParseFilter(entries []string) ([]Transition, error) {
+	var filter []Transition
+	for _, entry := range entries {
+		entry = strings.ReplaceAll(entry, "→", "->")
+		from, to, found := strings.Cut(entry, "->")
+		if !found {
+			return nil, fmt.Errorf("invalid state change filter %q: expected from->to", entry)
+		}
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if from == "" {
+			from = "*"
+		}
+		if to == "" {
+			to = "*"
+		}
+		filter = append(filter, Transition{From: from, To: to})
+	}
+	return filter, nil
+}
+
+// StateChangeRunner runs a user command for each matching state change
+type StateChangeRunner struct {
+	Command string
+	Filter  []Transition // Empty means every transition triggers the hook
+	Timeout time.Duration
+	Logger  *log.Logger
+}
+
+// NewStateChangeRunner creates a runner for the configured command and filter
+func NewStateChangeRunner(command string, filter []string, logger *log.Logger) (*StateChangeRunner, error) {
+	transitions, err := ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &StateChangeRunner{
+		Command: command,
+		Filter:  transitions,
+		Timeout: DefaultTimeout,
+		Logger:  logger,
+	}, nil
+}
+
+// Accepts reports whether a change passes the configured filter
+func (r *StateChangeRunner) Accepts(change models.StateChange) bool {
+	if len(r.Filter) == 0 {
+		return true
+	}
+	for _, t := range r.Filter {
+		if t.Matches(change) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify starts the hook asynchronously for every accepted change and
+// returns immediately; failures are logged rather than reported.
+func (r *StateChangeRunner) Notify(changes []models.StateChange) {
+	if r == nil || r.Command == "" {
+		return
+	}
+	for _, change := range changes {
+		if r.Accepts(change) {
+			go func(c models.StateChange) {
+				_ = r.Run(context.Background(), c)
+			}(change)
+		}
+	}
+}
+
+// Run executes the hook for one change and waits for it to finish
+func (r *StateChangeRunner) Run(ctx context.Context, change models.StateChange) error {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, r.Command)
+	cmd.Env = append(os.Environ(), Environment(change)...)
+	// Don't wait on grandchildren still holding the output pipe after a kill
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		r.Logger.Printf("state change hook failed for %s: %v: %s",
+			change.VMID, err, strings.TrimSpace(string(output)))
+		return err
+	}
+	return nil
+}
+
+// Environment returns the PVEC_* variables describing a change.
+// PVEC_CLUSTER is only set when several clusters are listed. PVEC_TYPE
+// is qemu, lxc, or node for a node's network probe, which leaves
+// PVEC_VMID empty and has the address probed in PVEC_NAME.
+func Environment(change models.StateChange) []string {
+	env := []string{
+		"PVEC_TYPE=" + string(change.Type),
+		"PVEC_VMID=" + change.VMID,
+		"PVEC_NAME=" + change.Name,
+		"PVEC_OLD_STATE=" + string(change.OldState),
+		"PVEC_NEW_STATE=" + string(change.NewState),
+		"PVEC_NODE=" + change.Node,
+	}
+	if change.Cluster != "" {
+		env = append(env, "PVEC_CLUSTER="+change.Cluster)
+	}
+	return env
+}
+
+// shellCommand runs command through the platform shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	// #nosec G204 - the command comes from the user's own configuration file
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,125 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+var stopped = models.StateChange{
+	VMID:     "104",
+	Name:     "web-1",
+	Node:     "pve1",
+	OldState: "running",
+	NewState: "stopped",
+}
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter([]string{"running->stopped", "*->paused", "→stopped", " stopped -> "})
+	require.NoError(t, err)
+
+	assert.Equal(t, []Transition{
+		{From: "running", To: "stopped"},
+		{From: "*", To: "paused"},
+		{From: "*", To: "stopped"},
+		{From: "stopped", To: "*"},
+	}, filter)
+
+	_, err = ParseFilter([]string{"stopped"})
+	assert.Error(t, err)
+}
+
+func TestStateChangeRunner_Accepts(t *testing.T) {
+	all, err := NewStateChangeRunner("true", nil, nil)
+	require.NoError(t, err)
+	assert.True(t, all.Accepts(stopped))
+
+	onlyStopped, err := NewStateChangeRunner("true", []string{"*->stopped"}, nil)
+	require.NoError(t, err)
+	assert.True(t, onlyStopped.Accepts(stopped))
+	assert.False(t, onlyStopped.Accepts(models.StateChange{OldState: "stopped", NewState: "running"}))
+}
+
+func TestStateChangeRunner_Run(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	runner, err := NewStateChangeRunner(
+		`echo "$PVEC_VMID $PVEC_NAME $PVEC_OLD_STATE $PVEC_NEW_STATE $PVEC_NODE" > `+out, nil, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, runner.Run(context.Background(), stopped))
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "104 web-1 running stopped pve1\n", string(data))
+}
+
+func TestStateChangeRunner_RunFailureIsLogged(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	var buf bytes.Buffer
+	runner, err := NewStateChangeRunner("echo boom; exit 3", nil, log.New(&buf, "", 0))
+	require.NoError(t, err)
+
+	assert.Error(t, runner.Run(context.Background(), stopped))
+	assert.Contains(t, buf.String(), "state change hook failed for 104")
+	assert.Contains(t, buf.String(), "boom")
+}
+
+func TestStateChangeRunner_RunTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	var buf bytes.Buffer
+	runner, err := NewStateChangeRunner("sleep 5", nil, log.New(&buf, "", 0))
+	require.NoError(t, err)
+	runner.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	err = runner.Run(context.Background(), stopped)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Contains(t, buf.String(), "timed out")
+}
+
+func TestStateChangeRunner_NotifyDoesNotBlock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	runner, err := NewStateChangeRunner("sleep 5", nil, log.New(&bytes.Buffer{}, "", 0))
+	require.NoError(t, err)
+	runner.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	runner.Notify([]models.StateChange{stopped, stopped})
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	var nilRunner *StateChangeRunner
+	nilRunner.Notify([]models.StateChange{stopped})
+}
+
+func TestEnvironment(t *testing.T) {
+	env := Environment(stopped)
+	assert.Contains(t, env, "PVEC_VMID=104")
+	assert.Len(t, env, 6, "PVEC_CLUSTER is only set with several clusters")
+
+	change := stopped
+	change.Cluster = "lab"
+	assert.Contains(t, Environment(change), "PVEC_CLUSTER=lab")
+
+	node := models.StateChange{Type: models.TypeNode, Node: "pve2", Name: "10.0.0.2:22",
+		OldState: models.StateReachable, NewState: models.StateUnreachable}
+	assert.Subset(t, Environment(node), []string{"PVEC_TYPE=node", "PVEC_NODE=pve2", "PVEC_NAME=10.0.0.2:22", "PVEC_NEW_STATE=unreachable"})
+}
@@ -0,0 +1,189 @@
+// Package maintenance reads the windows of maintenance_windows, such as a
+// nightly backup that locks the guests, and tells whether one is active.
+//
+// A window is written "[name] HH:MM-HH:MM [days]": "backup 01:00-04:00
+// daily", "22:00-02:00 sat,sun" or "03:00-05:00 mon-fri". The days are
+// those the window starts on, every day when left out; a window ending at
+// or before its start runs past midnight into the next day.
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultName names a window whose spec gives none
+const DefaultName = "maintenance"
+
+// Window is one recurring maintenance window
+type Window struct {
+	Name  string
+	Start time.Duration // From midnight
+	End   time.Duration // From midnight; at or before Start for a window past midnight
+	Days  [7]bool       // Days it starts on, by time.Weekday
+}
+
+// dayNames are the days in specs, by time.Weekday
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// daySets are the names of several days at once
+var daySets = map[string][]time.Weekday{
+	"daily":    {time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// Parse reads a window spec
+func Parse(spec string) (Window, error) {
+	fields := strings.Fields(strings.ToLower(spec))
+	at := -1
+	for i, f := range fields {
+		if strings.Contains(f, ":") {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return Window{}, fmt.Errorf("maintenance window %q has no HH:MM-HH:MM times", spec)
+	}
+
+	w := Window{Name: strings.Join(fields[:at], " ")}
+	if w.Name == "" {
+		w.Name = DefaultName
+	}
+	from, to, ok := strings.Cut(fields[at], "-")
+	var err error
+	if w.Start, err = parseClock(from); !ok || err != nil {
+		return Window{}, fmt.Errorf("maintenance window %q: expected HH:MM-HH:MM, got %q", spec, fields[at])
+	}
+	if w.End, err = parseClock(to); err != nil {
+		return Window{}, fmt.Errorf("maintenance window %q: expected HH:MM-HH:MM, got %q", spec, fields[at])
+	}
+	if w.Start == w.End {
+		return Window{}, fmt.Errorf("maintenance window %q starts and ends at the same time", spec)
+	}
+
+	switch rest := fields[at+1:]; len(rest) {
+	case 0:
+		w.Days = everyDay()
+	case 1:
+		if w.Days, err = parseDays(rest[0]); err != nil {
+			return Window{}, fmt.Errorf("maintenance window %q: %w", spec, err)
+		}
+	default:
+		return Window{}, fmt.Errorf("maintenance window %q: unexpected %q after the days", spec, strings.Join(rest[1:], " "))
+	}
+	return w, nil
+}
+
+// parseClock reads HH:MM as the time from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseDays reads a comma-separated list of days, day ranges such as
+// mon-fri, and day sets such as weekends
+func parseDays(s string) ([7]bool, error) {
+	var days [7]bool
+	for _, part := range strings.Split(s, ",") {
+		if set, ok := daySets[part]; ok {
+			for _, d := range set {
+				days[d] = true
+			}
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := weekday(from)
+		if !ok {
+			return days, fmt.Errorf("unknown day %q; use daily, weekdays, weekends or sun to sat", part)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekday(to); !ok {
+				return days, fmt.Errorf("unknown day %q; use daily, weekdays, weekends or sun to sat", part)
+			}
+		}
+		// A range may wrap past Saturday, as in fri-mon
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func weekday(name string) (time.Weekday, bool) {
+	for i, n := range dayNames {
+		if n == name {
+			return time.Weekday(i), true
+		}
+	}
+	return 0, false
+}
+
+func everyDay() [7]bool {
+	return [7]bool{true, true, true, true, true, true, true}
+}
+
+// length is how long the window lasts
+func (w Window) length() time.Duration {
+	if w.End > w.Start {
+		return w.End - w.Start
+	}
+	return 24*time.Hour - w.Start + w.End
+}
+
+// ActiveAt returns when the window active at t ends, and false when it
+// isn't active. A window past midnight started the day before t.
+func (w Window) ActiveAt(t time.Time) (time.Time, bool) {
+	for back := 0; back <= 1; back++ {
+		day := t.AddDate(0, 0, -back)
+		if !w.Days[day.Weekday()] {
+			continue
+		}
+		midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, t.Location())
+		start := midnight.Add(w.Start)
+		end := start.Add(w.length())
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Windows are the windows of maintenance_windows
+type Windows []Window
+
+// ParseAll reads every spec, failing on the first that doesn't parse
+func ParseAll(specs []string) (Windows, error) {
+	windows := make(Windows, 0, len(specs))
+	for _, spec := range specs {
+		w, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// Active returns the window active at t and when it ends. When windows
+// overlap, the one ending last is returned, as guests may stay locked
+// until then.
+func (ws Windows) Active(t time.Time) (Window, time.Time, bool) {
+	var active Window
+	var until time.Time
+	for _, w := range ws {
+		if end, ok := w.ActiveAt(t); ok && end.After(until) {
+			active, until = w, end
+		}
+	}
+	return active, until, !until.IsZero()
+}
@@ -0,0 +1,113 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// at returns the time on 2026-03-02, a Monday, plus days
+func at(days int, clock string) time.Time {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		panic(err)
+	}
+	return time.Date(2026, 3, 2+days, t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	w, err := Parse("backup 01:00-04:00 daily")
+	require.NoError(t, err)
+	assert.Equal(t, Window{Name: "backup", Start: time.Hour, End: 4 * time.Hour, Days: everyDay()}, w)
+
+	w, err = Parse("22:30-02:00")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultName, w.Name)
+	assert.Equal(t, 22*time.Hour+30*time.Minute, w.Start)
+	assert.Equal(t, everyDay(), w.Days)
+
+	w, err = Parse("Nightly Backup 03:00-05:00 MON-WED,sat")
+	require.NoError(t, err)
+	assert.Equal(t, "nightly backup", w.Name)
+	assert.Equal(t, [7]bool{false, true, true, true, false, false, true}, w.Days)
+
+	w, err = Parse("patching 20:00-23:00 fri-mon")
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{true, true, false, false, false, true, true}, w.Days)
+
+	w, err = Parse("01:00-04:00 weekends")
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{true, false, false, false, false, false, true}, w.Days)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"backup daily",
+		"01:00 daily",
+		"01:00-25:00",
+		"1h-4h",
+		"02:00-02:00",
+		"01:00-04:00 someday",
+		"01:00-04:00 mon-xyz",
+		"01:00-04:00 daily extra",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestWindow_ActiveAt(t *testing.T) {
+	w, err := Parse("01:00-04:00 daily")
+	require.NoError(t, err)
+
+	end, ok := w.ActiveAt(at(0, "01:00"))
+	assert.True(t, ok, "The start is inside the window")
+	assert.Equal(t, at(0, "04:00"), end)
+
+	_, ok = w.ActiveAt(at(0, "04:00"))
+	assert.False(t, ok, "The end is outside the window")
+	_, ok = w.ActiveAt(at(0, "00:59"))
+	assert.False(t, ok)
+}
+
+func TestWindow_ActiveAt_PastMidnight(t *testing.T) {
+	w, err := Parse("22:00-02:00 fri")
+	require.NoError(t, err)
+
+	end, ok := w.ActiveAt(at(5, "01:30")) // Saturday morning
+	assert.True(t, ok, "A Friday window runs into Saturday")
+	assert.Equal(t, at(5, "02:00"), end)
+
+	end, ok = w.ActiveAt(at(4, "23:00"))
+	assert.True(t, ok)
+	assert.Equal(t, at(5, "02:00"), end)
+
+	_, ok = w.ActiveAt(at(4, "01:30")) // Friday morning: Thursday has no window
+	assert.False(t, ok)
+}
+
+func TestWindows_Active(t *testing.T) {
+	ws, err := ParseAll([]string{"backup 01:00-04:00 daily", "replication 03:00-05:00 mon", "patching 20:00-22:00 sat"})
+	require.NoError(t, err)
+
+	w, until, ok := ws.Active(at(0, "03:30"))
+	require.True(t, ok)
+	assert.Equal(t, "replication", w.Name, "The window ending last wins")
+	assert.Equal(t, at(0, "05:00"), until)
+
+	w, until, ok = ws.Active(at(1, "03:30"))
+	require.True(t, ok)
+	assert.Equal(t, "backup", w.Name)
+	assert.Equal(t, at(1, "04:00"), until)
+
+	_, _, ok = ws.Active(at(0, "12:00"))
+	assert.False(t, ok)
+	_, _, ok = Windows(nil).Active(at(0, "02:00"))
+	assert.False(t, ok)
+
+	_, err = ParseAll([]string{"01:00-04:00", "bad"})
+	assert.ErrorContains(t, err, `"bad"`)
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StateChange records a guest status transition observed between two
+// refreshes. One of TypeNode is a node's network probe changing instead:
+// Node names the node and Name the address probed.
+type StateChange struct {
+	Time     time.Time `json:"time" yaml:"time"`                           // When the change was observed
+	VMID     string    `json:"vmid" yaml:"vmid"`                           // Virtual Machine/Container ID
+	Name     string    `json:"name" yaml:"name"`                           // Name of the VM/Container
+	Type     NodeType  `json:"type" yaml:"type"`                           // TypeVM or TypeContainer
+	Node     string    `json:"node" yaml:"node"`                           // Proxmox node name
+	OldState NodeState `json:"old_state" yaml:"old_state"`                 // Status before the change
+	NewState NodeState `json:"new_state" yaml:"new_state"`                 // Status after the change
+	Cluster  string    `json:"cluster,omitempty" yaml:"cluster,omitempty"` // Cluster of the guest when several are listed
+}
+
+// Key identifies the guest across clusters, like VMStatus.Key
+func (c StateChange) Key() string {
+	return GuestKey(c.Cluster, c.VMID)
+}
+
+// String returns the change formatted as an event log line
+func (c StateChange) String() string {
+	typeText := "vm"
+	switch c.Type {
+	case TypeContainer:
+		typeText = "ct"
+	case TypeNode:
+		return fmt.Sprintf("%s node %s %s %s → %s",
+			c.Time.Format("15:04"), c.Node, c.Name, c.OldState, c.NewState)
+	}
+	return fmt.Sprintf("%s %s %s %s %s → %s",
+		c.Time.Format("15:04"), typeText, c.Key(), c.Name, c.OldState, c.NewState)
+}
+
+// DetectStateChanges compares two snapshots keyed by VMStatus.Key and
+// returns the guests whose status differs, ordered by key. Guests that only appear in
+// one of the snapshots are ignored.
+func DetectStateChanges(prev, curr []*VMStatus, at time.Time) []StateChange {
+	previous := make(map[string]*VMStatus, len(prev))
+	for _, vm := range prev {
+		if vm != nil {
+			previous[vm.Key()] = vm
+		}
+	}
+
+	var changes []StateChange
+	for _, vm := range curr {
+		if vm == nil {
+			continue
+		}
+		old, exists := previous[vm.Key()]
+		if !exists || old.Status == vm.Status {
+			continue
+		}
+		changes = append(changes, StateChange{
+			Time:     at,
+			VMID:     vm.VMID,
+			Name:     vm.Name,
+			Type:     vm.Type,
+			Node:     vm.Node,
+			OldState: old.Status,
+			NewState: vm.Status,
+			Cluster:  vm.Cluster,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key() < changes[j].Key()
+	})
+	return changes
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectStateChanges(t *testing.T) {
+	at := time.Date(2025, 1, 1, 14, 2, 0, 0, time.UTC)
+	prev := []*VMStatus{
+		{VMID: "104", Name: "web-1", Type: TypeVM, Status: StateRunning, Node: "pve1"},
+		{VMID: "105", Name: "db-1", Type: TypeVM, Status: StateRunning, Node: "pve1"},
+		{VMID: "200", Name: "ct-1", Type: TypeContainer, Status: StateStopped, Node: "pve2"},
+	}
+	curr := []*VMStatus{
+		{VMID: "200", Name: "ct-1", Type: TypeContainer, Status: StateRunning, Node: "pve2"},
+		{VMID: "105", Name: "db-1", Type: TypeVM, Status: StateRunning, Node: "pve1"},
+		{VMID: "104", Name: "web-1", Type: TypeVM, Status: StateStopped, Node: "pve1"},
+	}
+
+	changes := DetectStateChanges(prev, curr, at)
+
+	require.Len(t, changes, 2)
+	assert.Equal(t, "104", changes[0].VMID)
+	assert.Equal(t, StateRunning, changes[0].OldState)
+	assert.Equal(t, StateStopped, changes[0].NewState)
+	assert.Equal(t, at, changes[0].Time)
+	assert.Equal(t, "200", changes[1].VMID)
+	assert.Equal(t, "pve2", changes[1].Node)
+}
+
+func TestDetectStateChanges_AppearingAndDisappearing(t *testing.T) {
+	prev := []*VMStatus{
+		{VMID: "100", Status: StateRunning},
+		{VMID: "101", Status: StateRunning},
+	}
+	curr := []*VMStatus{
+		{VMID: "101", Status: StateRunning},
+		{VMID: "102", Status: StateStopped},
+		nil,
+	}
+
+	assert.Empty(t, DetectStateChanges(prev, curr, time.Now()))
+	assert.Empty(t, DetectStateChanges(nil, curr, time.Now()))
+	assert.Empty(t, DetectStateChanges(prev, nil, time.Now()))
+}
+
+func TestDetectStateChanges_Clusters(t *testing.T) {
+	prev := []*VMStatus{
+		{VMID: "100", Cluster: "lab", Status: StateRunning},
+		{VMID: "100", Cluster: "prod", Status: StateRunning},
+	}
+	curr := []*VMStatus{
+		{VMID: "100", Cluster: "prod", Status: StateRunning},
+		{VMID: "100", Cluster: "lab", Status: StateStopped},
+	}
+
+	changes := DetectStateChanges(prev, curr, time.Now())
+
+	require.Len(t, changes, 1, "Guests are matched within their cluster")
+	assert.Equal(t, "lab", changes[0].Cluster)
+	assert.Equal(t, "lab/100", changes[0].Key())
+}
+
+func TestStateChange_String(t *testing.T) {
+	at := time.Date(2025, 1, 1, 14, 2, 0, 0, time.UTC)
+	vm := StateChange{Time: at, VMID: "104", Name: "web-1", Type: TypeVM,
+		OldState: "running", NewState: "stopped"}
+	assert.Equal(t, "14:02 vm 104 web-1 running → stopped", vm.String())
+
+	ct := StateChange{Time: at, VMID: "200", Name: "ct-1", Type: TypeContainer,
+		OldState: "stopped", NewState: "running"}
+	assert.Equal(t, "14:02 ct 200 ct-1 stopped → running", ct.String())
+
+	ct.Cluster = "lab"
+	assert.Equal(t, "14:02 ct lab/200 ct-1 stopped → running", ct.String())
+
+	node := StateChange{Time: at, Name: "10.0.0.2:22", Type: TypeNode, Node: "pve2",
+		OldState: StateReachable, NewState: StateUnreachable}
+	assert.Equal(t, "14:02 node pve2 10.0.0.2:22 reachable → unreachable", node.String())
+}
@@ -0,0 +1,168 @@
+package models
+
+import "strings"
+
+// ClusterNode is a member node of the cluster and its load
+type ClusterNode struct {
+	Name     string  `json:"name" yaml:"name"`
+	Online   bool    `json:"online" yaml:"online"`
+	CPUUsage float64 `json:"cpu_usage" yaml:"cpu_usage"` // Percentage of all cores, 0-100
+	MaxCPU   int     `json:"max_cpu" yaml:"max_cpu"`
+	Mem      int64   `json:"mem" yaml:"mem"` // Bytes used
+	MaxMem   int64   `json:"max_mem" yaml:"max_mem"`
+	Uptime   int64   `json:"uptime" yaml:"uptime"` // Seconds
+}
+
+// MemoryPercent returns the memory in use as a percentage, 0 when unknown
+func (n ClusterNode) MemoryPercent() float64 {
+	if n.MaxMem <= 0 {
+		return 0
+	}
+	return float64(n.Mem) / float64(n.MaxMem) * 100
+}
+
+// Allocation is the cores and memory assigned to a set of guests
+type Allocation struct {
+	Cores int   `json:"cores" yaml:"cores"`
+	Mem   int64 `json:"mem" yaml:"mem"` // Bytes
+}
+
+// NodeAllocation is what the guests of a node are assigned: every guest
+// defined there, and only those running (or paused, which keeps memory)
+type NodeAllocation struct {
+	Defined Allocation `json:"defined" yaml:"defined"`
+	Running Allocation `json:"running" yaml:"running"`
+	Unknown int        `json:"unknown,omitempty" yaml:"unknown,omitempty"` // Guests left out, their state unknown
+}
+
+// AllocationByNode sums the MaxCPU and MaxMem of the guests per node.
+// Sizes the API didn't report count as zero; guests in an unknown state
+// are only counted, as the API zeroes what it reports of them.
+func AllocationByNode(guests []*VMStatus) map[string]NodeAllocation {
+	byNode := make(map[string]NodeAllocation)
+	for _, g := range guests {
+		if g.IsUnknown() {
+			n := byNode[g.Node]
+			n.Unknown++
+			byNode[g.Node] = n
+			continue
+		}
+		var a Allocation
+		if g.IsKnown(MetricMaxCPU) {
+			a.Cores = g.MaxCPU
+		}
+		if g.IsKnown(MetricMaxMem) {
+			a.Mem = g.MaxMem
+		}
+		n := byNode[g.Node]
+		n.Defined.Cores += a.Cores
+		n.Defined.Mem += a.Mem
+		if g.CanStop() {
+			n.Running.Cores += a.Cores
+			n.Running.Mem += a.Mem
+		}
+		byNode[g.Node] = n
+	}
+	return byNode
+}
+
+// CPURatio returns the allocated cores as a percentage of the node's, 0
+// when the node's are unknown
+func (a Allocation) CPURatio(n ClusterNode) float64 {
+	if n.MaxCPU <= 0 {
+		return 0
+	}
+	return float64(a.Cores) / float64(n.MaxCPU) * 100
+}
+
+// MemRatio returns the allocated memory as a percentage of the node's, 0
+// when the node's is unknown
+func (a Allocation) MemRatio(n ClusterNode) float64 {
+	if n.MaxMem <= 0 {
+		return 0
+	}
+	return float64(a.Mem) / float64(n.MaxMem) * 100
+}
+
+// StaleMetrics are the metrics the API zeroes for a guest it doesn't know
+// the state of, as for the guests of a node that isn't online
+const StaleMetrics = MetricMem | MetricUptime | MetricDisk
+
+// MarkNodesOffline flags the guests of the nodes listed as not online,
+// and their usage and uptime as unknown. Guests of a node missing from
+// nodes are left as they are.
+func MarkNodesOffline(guests []*VMStatus, nodes []ClusterNode) {
+	offline := make(map[string]bool)
+	for _, n := range nodes {
+		if !n.Online {
+			offline[n.Name] = true
+		}
+	}
+	for _, g := range guests {
+		if offline[g.Node] {
+			g.NodeOffline = true
+			g.Missing |= StaleMetrics
+		}
+	}
+}
+
+// KeepLastKnown gives a guest whose node is offline the status, usage and
+// uptime of prev, the same guest as listed before, so that it shows its
+// last known state rather than an unknown status and zeros
+func (v *VMStatus) KeepLastKnown(prev *VMStatus) {
+	if !v.NodeOffline || prev == nil {
+		return
+	}
+	v.Status = prev.Status
+	v.CPUUsage = prev.CPUUsage
+	v.MemoryUsage = prev.MemoryUsage
+	v.Uptime = prev.Uptime
+	v.Disk = prev.Disk
+	v.Missing = v.Missing&^StaleMetrics | prev.Missing&StaleMetrics
+}
+
+// Pool is a resource pool of the cluster
+type Pool struct {
+	ID      string `json:"id" yaml:"id"`
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+}
+
+// HAResource is a guest managed by the HA stack
+type HAResource struct {
+	SID   string `json:"sid" yaml:"sid"`                         // e.g. vm:100 or ct:200
+	State string `json:"state" yaml:"state"`                     // Requested state: started, stopped, disabled or ignored
+	Group string `json:"group,omitempty" yaml:"group,omitempty"` // HA group, if any
+}
+
+// HASID returns the SID HA knows a guest by, e.g. vm:100 or ct:200
+func HASID(v *VMStatus) string {
+	if v.Type == TypeContainer {
+		return "ct:" + v.VMID
+	}
+	return "vm:" + v.VMID
+}
+
+// HAGroup is a set of nodes HA may run its guests on
+type HAGroup struct {
+	Name    string   `json:"name" yaml:"name"`
+	Nodes   []string `json:"nodes,omitempty" yaml:"nodes,omitempty"`     // As configured, e.g. pve1:2 with a priority
+	Comment string   `json:"comment,omitempty" yaml:"comment,omitempty"` // Free text
+}
+
+// VMID returns the VMID in the resource's SID, "" for a SID of another kind
+func (r HAResource) VMID() string {
+	kind, vmid, ok := strings.Cut(r.SID, ":")
+	if !ok || (kind != "vm" && kind != "ct") {
+		return ""
+	}
+	return vmid
+}
+
+// ReplicationJob is a storage replication job of a guest
+type ReplicationJob struct {
+	ID       string `json:"id" yaml:"id"` // <vmid>-<job number>
+	Guest    string `json:"guest" yaml:"guest"`
+	Target   string `json:"target" yaml:"target"` // Node replicated to
+	Schedule string `json:"schedule" yaml:"schedule"`
+	Disabled bool   `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+}
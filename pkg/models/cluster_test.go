@@ -0,0 +1,120 @@
+package models
+
+import "testing"
+
+func TestClusterNode_MemoryPercent(t *testing.T) {
+	n := ClusterNode{Mem: 16 << 30, MaxMem: 64 << 30}
+	if got := n.MemoryPercent(); got != 25 {
+		t.Errorf("Expected 25%%, got %v", got)
+	}
+	if got := (ClusterNode{Mem: 1}).MemoryPercent(); got != 0 {
+		t.Errorf("Unknown total should give 0, got %v", got)
+	}
+}
+
+func TestAllocationByNode(t *testing.T) {
+	guests := []*VMStatus{
+		{VMID: "100", Node: "pve1", Status: StateRunning, MaxCPU: 16, MaxMem: 32 << 30},
+		{VMID: "101", Node: "pve1", Status: StatePaused, MaxCPU: 8, MaxMem: 16 << 30},
+		{VMID: "102", Node: "pve1", Status: StateStopped, MaxCPU: 24, MaxMem: 48 << 30},
+		{VMID: "103", Node: "pve1", Status: StateRunning, MaxCPU: 4, Missing: MetricMaxMem | MetricMaxCPU},
+		{VMID: "104", Node: "pve1", Status: StateUnknown, MaxCPU: 8, MaxMem: 8 << 30, Missing: StaleMetrics},
+		{VMID: "200", Node: "pve2", Status: StateStopped, MaxCPU: 2, MaxMem: 2 << 30},
+	}
+
+	alloc := AllocationByNode(guests)
+
+	pve1 := alloc["pve1"]
+	if pve1.Defined != (Allocation{Cores: 48, Mem: 96 << 30}) {
+		t.Errorf("Every guest counts as defined, got %+v", pve1.Defined)
+	}
+	if pve1.Running != (Allocation{Cores: 24, Mem: 48 << 30}) {
+		t.Errorf("Only running and paused guests count as running, got %+v", pve1.Running)
+	}
+	if pve1.Unknown != 1 {
+		t.Errorf("The guest in an unknown state should be counted apart, got %d", pve1.Unknown)
+	}
+	if pve2 := alloc["pve2"]; pve2.Running != (Allocation{}) || pve2.Defined.Cores != 2 {
+		t.Errorf("Unexpected pve2 allocation %+v", pve2)
+	}
+
+	node := ClusterNode{Name: "pve1", MaxCPU: 32, MaxMem: 64 << 30}
+	if got := pve1.Defined.CPURatio(node); got != 150 {
+		t.Errorf("Expected 150%% of the cores, got %v", got)
+	}
+	if got := pve1.Defined.MemRatio(node); got != 150 {
+		t.Errorf("Expected 150%% of the memory, got %v", got)
+	}
+	if got := pve1.Defined.CPURatio(ClusterNode{}); got != 0 {
+		t.Errorf("Unknown node size should give 0, got %v", got)
+	}
+}
+
+func TestMarkNodesOffline(t *testing.T) {
+	guests := []*VMStatus{
+		{VMID: "100", Node: "pve1", Status: StateRunning, Uptime: 60},
+		{VMID: "200", Node: "pve2", Status: StateUnknown},
+		{VMID: "300", Node: "pve3", Status: StateUnknown},
+	}
+	MarkNodesOffline(guests, []ClusterNode{{Name: "pve1", Online: true}, {Name: "pve2"}})
+
+	if guests[0].NodeOffline || !guests[0].IsKnown(MetricUptime) {
+		t.Errorf("A guest of an online node should be left alone, got %+v", guests[0])
+	}
+	if !guests[1].NodeOffline || guests[1].IsKnown(MetricMem) || guests[1].IsKnown(MetricUptime) {
+		t.Errorf("Expected 200 offline with unknown metrics, got %+v", guests[1])
+	}
+	if guests[2].NodeOffline {
+		t.Error("A node that isn't listed shouldn't count as offline")
+	}
+}
+
+func TestVMStatus_KeepLastKnown(t *testing.T) {
+	prev := &VMStatus{VMID: "200", Status: StateRunning, CPUUsage: 12.5, MemoryUsage: 40, Uptime: 3600, MaxMem: 4 << 30}
+	stale := &VMStatus{VMID: "200", Status: StateUnknown, MaxMem: 4 << 30, NodeOffline: true, Missing: StaleMetrics}
+	stale.KeepLastKnown(prev)
+	if stale.Status != StateRunning || stale.CPUUsage != 12.5 || stale.Uptime != 3600 || !stale.HasMemoryUsage() {
+		t.Errorf("Expected the last known state, got %+v", stale)
+	}
+	if !stale.NodeOffline {
+		t.Error("The guest should still be marked offline")
+	}
+
+	// Carried on while the node stays offline
+	next := &VMStatus{VMID: "200", Status: StateUnknown, NodeOffline: true, Missing: StaleMetrics}
+	next.KeepLastKnown(stale)
+	if next.Status != StateRunning || next.MemoryUsage != 40 {
+		t.Errorf("Expected the state carried on, got %+v", next)
+	}
+
+	online := &VMStatus{VMID: "200", Status: StateStopped}
+	online.KeepLastKnown(prev)
+	if online.Status != StateStopped {
+		t.Errorf("A guest of an online node keeps its own state, got %s", online.Status)
+	}
+}
+
+func TestHAResource_VMID(t *testing.T) {
+	for sid, want := range map[string]string{
+		"vm:100": "100",
+		"ct:200": "200",
+		"100":    "",
+		"xx:1":   "",
+	} {
+		if got := (HAResource{SID: sid}).VMID(); got != want {
+			t.Errorf("%s: expected %q, got %q", sid, want, got)
+		}
+	}
+}
+
+func TestHASID(t *testing.T) {
+	if got := HASID(&VMStatus{VMID: "100", Type: TypeVM}); got != "vm:100" {
+		t.Errorf("Expected vm:100, got %q", got)
+	}
+	if got := HASID(&VMStatus{VMID: "200", Type: TypeContainer}); got != "ct:200" {
+		t.Errorf("Expected ct:200, got %q", got)
+	}
+	if got := (HAResource{SID: HASID(&VMStatus{VMID: "200", Type: TypeContainer})}).VMID(); got != "200" {
+		t.Errorf("The SID should round-trip, got %q", got)
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+)
+
+// Digest hashes the guests a refresh read, whatever their order, so that
+// two refreshes can be told apart without comparing them field by field.
+// A coarse digest leaves out the usage, uptime and QEMU state, which
+// change on nearly every read of a running guest, so it only tells apart
+// changes of state, size or placement. It returns 0, which matches no
+// digest, when the guests can't be hashed.
+func Digest(guests []*VMStatus, coarse bool) uint64 {
+	sorted := make([]VMStatus, 0, len(guests))
+	for _, g := range guests {
+		v := *g
+		if coarse {
+			v.CPUUsage, v.MemoryUsage, v.Uptime, v.Disk, v.QEMU = 0, 0, 0, 0, nil
+		}
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key() < sorted[j].Key() })
+
+	// A usage the API got wrong, such as NaN, can't be encoded
+	data, err := json.Marshal(sorted)
+	if err != nil {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64()
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func digestGuests() []*VMStatus {
+	return []*VMStatus{
+		{VMID: "100", Name: "web", Status: StateRunning, Node: "pve1", CPUUsage: 12.5, MemoryUsage: 40, Uptime: 3600},
+		{VMID: "101", Name: "db", Status: StateStopped, Node: "pve1"},
+	}
+}
+
+func TestDigest(t *testing.T) {
+	a, b := digestGuests(), digestGuests()
+	assert.Equal(t, Digest(a, false), Digest(b, false))
+	assert.NotZero(t, Digest(a, false))
+	assert.Equal(t, Digest(a, false), Digest([]*VMStatus{b[1], b[0]}, false), "The order doesn't count")
+
+	b[0].CPUUsage = 13
+	b[0].Uptime = 3605
+	assert.NotEqual(t, Digest(a, false), Digest(b, false))
+	assert.Equal(t, Digest(a, true), Digest(b, true), "A coarse digest ignores usage and uptime")
+
+	b[1].Status = StateRunning
+	assert.NotEqual(t, Digest(a, true), Digest(b, true), "A change of state counts")
+	b = digestGuests()
+	b[1].Node = "pve2"
+	assert.NotEqual(t, Digest(a, true), Digest(b, true), "A migration counts")
+	assert.NotEqual(t, Digest(a, true), Digest(a[:1], true), "A guest gone counts")
+}
+
+func TestDigest_Unencodable(t *testing.T) {
+	guests := digestGuests()
+	guests[0].CPUUsage = math.NaN()
+	assert.Zero(t, Digest(guests, false))
+	assert.NotZero(t, Digest(guests, true), "The coarse digest leaves the usage out")
+}
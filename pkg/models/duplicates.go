@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DuplicateNames groups the guests sharing a name, ignoring case, keyed by
+// the lowercase name. Names held by a single guest and empty names are
+// left out; each group is ordered by Key.
+func DuplicateNames(guests []*VMStatus) map[string][]*VMStatus {
+	byName := make(map[string][]*VMStatus)
+	for _, g := range guests {
+		if g.Name == "" {
+			continue
+		}
+		name := strings.ToLower(g.Name)
+		byName[name] = append(byName[name], g)
+	}
+	return duplicatesOnly(byName)
+}
+
+// VMIDCollisions groups the guests of different clusters sharing a VMID,
+// keyed by the VMID. A VMID is unique within a cluster, so only merged
+// clusters can collide; acting on such a guest by VMID alone is
+// ambiguous.
+func VMIDCollisions(guests []*VMStatus) map[string][]*VMStatus {
+	byVMID := make(map[string][]*VMStatus)
+	for _, g := range guests {
+		byVMID[g.VMID] = append(byVMID[g.VMID], g)
+	}
+	return duplicatesOnly(byVMID)
+}
+
+// duplicatesOnly drops the groups of a single guest and orders the others
+// by Key
+func duplicatesOnly(groups map[string][]*VMStatus) map[string][]*VMStatus {
+	for key, group := range groups {
+		if len(group) < 2 {
+			delete(groups, key)
+			continue
+		}
+		slices.SortFunc(group, func(a, b *VMStatus) int { return strings.Compare(a.Key(), b.Key()) })
+	}
+	return groups
+}
+
+// AmbiguousGuestError is returned when a name or VMID matches several
+// guests; pvec refuses to pick one
+type AmbiguousGuestError struct {
+	Ref        string // The name or VMID given
+	Prefix     bool   // Ref starts the candidates' names rather than naming them
+	Candidates []*VMStatus
+}
+
+func (e *AmbiguousGuestError) Error() string {
+	candidates := make([]string, len(e.Candidates))
+	for i, g := range e.Candidates {
+		candidates[i] = g.Key() + " (" + g.Name + ") on " + g.Node
+		if !e.Prefix {
+			candidates[i] = g.Key() + " on " + g.Node
+		}
+	}
+	hint := "use the VMID"
+	if e.Candidates[0].Cluster != "" {
+		hint = "use cluster/VMID"
+	}
+	if e.Prefix {
+		return fmt.Sprintf("%q starts the names of %d guests: %s; %s or more of the name",
+			e.Ref, len(e.Candidates), strings.Join(candidates, ", "), hint)
+	}
+	return fmt.Sprintf("%q names %d guests: %s; %s", e.Ref, len(e.Candidates), strings.Join(candidates, ", "), hint)
+}
+
+// ResolveGuest finds the guest ref designates: its Key, its VMID or its
+// name, ignoring case, or failing those the one guest whose name starts
+// with ref, reporting byPrefix so the caller can say which guest it took.
+// A VMID shared by merged clusters, or a name or prefix matching several
+// guests, is an AmbiguousGuestError listing the candidates. The CLI and
+// the list resolve guests the same way through it.
+func ResolveGuest(guests []*VMStatus, ref string) (guest *VMStatus, byPrefix bool, err error) {
+	var byVMID, byName, byStart []*VMStatus
+	for _, g := range guests {
+		switch {
+		case g.Key() == ref:
+			return g, false, nil
+		case g.VMID == ref:
+			byVMID = append(byVMID, g)
+		case g.Name == "" || ref == "":
+		case strings.EqualFold(g.Name, ref):
+			byName = append(byName, g)
+		case strings.HasPrefix(strings.ToLower(g.Name), strings.ToLower(ref)):
+			byStart = append(byStart, g)
+		}
+	}
+	for i, matches := range [][]*VMStatus{byVMID, byName, byStart} {
+		switch len(matches) {
+		case 0:
+			continue
+		case 1:
+			return matches[0], i == 2, nil
+		}
+		slices.SortFunc(matches, func(a, b *VMStatus) int { return strings.Compare(a.Key(), b.Key()) })
+		return nil, false, &AmbiguousGuestError{Ref: ref, Prefix: i == 2, Candidates: matches}
+	}
+	return nil, false, fmt.Errorf("no guest is named %q or has that VMID", ref)
+}
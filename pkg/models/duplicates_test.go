@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func duplicateGuests() []*VMStatus {
+	return []*VMStatus{
+		{VMID: "100", Name: "web", Node: "pve1"},
+		{VMID: "205", Name: "Web", Node: "pve2"},
+		{VMID: "101", Name: "db", Node: "pve1"},
+		{VMID: "102", Node: "pve1"},
+		{VMID: "103", Node: "pve2"},
+		{VMID: "100", Name: "mail", Node: "b1", Cluster: "backup"},
+	}
+}
+
+func TestDuplicateNames(t *testing.T) {
+	dups := DuplicateNames(duplicateGuests())
+	if len(dups) != 1 {
+		t.Fatalf("Expected only web to be duplicated, got %v", dups)
+	}
+	web := dups["web"]
+	if len(web) != 2 || web[0].VMID != "100" || web[1].VMID != "205" {
+		t.Errorf("Expected 100 and 205 named web, ignoring case, got %v", web)
+	}
+}
+
+func TestVMIDCollisions(t *testing.T) {
+	collisions := VMIDCollisions(duplicateGuests())
+	if len(collisions) != 1 {
+		t.Fatalf("Expected only 100 to collide, got %v", collisions)
+	}
+	if got := collisions["100"]; len(got) != 2 || got[0].Key() != "100" || got[1].Key() != "backup/100" {
+		t.Errorf("Expected 100 and backup/100, got %v", got)
+	}
+}
+
+func TestResolveGuest(t *testing.T) {
+	guests := duplicateGuests()
+
+	// Exact: Key, VMID or name ignoring case, a name beating the names it starts
+	guests = append(guests, &VMStatus{VMID: "104", Name: "db-replica", Node: "pve2"})
+	for ref, want := range map[string]string{"db": "101", "DB": "101", "101": "101", "backup/100": "backup/100", "mail": "backup/100"} {
+		g, prefix, err := ResolveGuest(guests, ref)
+		if err != nil || g.Key() != want || prefix {
+			t.Errorf("Expected %q to resolve exactly to %s, got %v %v %v", ref, want, g, prefix, err)
+		}
+	}
+
+	// Prefix: only when a single name starts with it
+	g, prefix, err := ResolveGuest(guests, "db-r")
+	if err != nil || g.VMID != "104" || !prefix {
+		t.Errorf("Expected db-r to resolve to 104 by prefix, got %v %v %v", g, prefix, err)
+	}
+	var ambiguous *AmbiguousGuestError
+	_, _, err = ResolveGuest(guests, "d")
+	if !errors.As(err, &ambiguous) || !ambiguous.Prefix {
+		t.Fatalf("Expected d to be an ambiguous prefix, got %v", err)
+	}
+	if want := `"d" starts the names of 2 guests: 101 (db) on pve1, 104 (db-replica) on pve2; use the VMID or more of the name`; err.Error() != want {
+		t.Errorf("Expected %q, got %q", want, err.Error())
+	}
+
+	// Ambiguous
+	_, _, err = ResolveGuest(guests, "web")
+	if !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 2 || ambiguous.Prefix {
+		t.Fatalf("Expected web to be ambiguous, got %v", err)
+	}
+	if want := `"web" names 2 guests: 100 on pve1, 205 on pve2; use the VMID`; err.Error() != want {
+		t.Errorf("Expected %q, got %q", want, err.Error())
+	}
+
+	merged := []*VMStatus{
+		{VMID: "100", Name: "web", Node: "pve1", Cluster: "main"},
+		{VMID: "100", Name: "mail", Node: "b1", Cluster: "backup"},
+	}
+	_, _, err = ResolveGuest(merged, "100")
+	if want := `"100" names 2 guests: backup/100 on b1, main/100 on pve1; use cluster/VMID`; err == nil || err.Error() != want {
+		t.Errorf("Expected the colliding VMID to be refused with %q, got %v", want, err)
+	}
+
+	// Missing
+	if _, _, err := ResolveGuest(guests, "nope"); err == nil || errors.As(err, &ambiguous) {
+		t.Errorf("Expected an unknown guest to fail plainly, got %v", err)
+	}
+	if _, _, err := ResolveGuest(guests, ""); err == nil {
+		t.Error("Expected an empty name to match nothing")
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import "strings"
+
+// Filesystem is a mounted filesystem reported by the QEMU guest agent
+type Filesystem struct {
+	Name       string `json:"name" yaml:"name"`             // Device name, e.g. sda1
+	Mountpoint string `json:"mountpoint" yaml:"mountpoint"` // e.g. / or C:\
+	Type       string `json:"type" yaml:"type"`             // e.g. ext4, xfs, NTFS
+	UsedBytes  int64  `json:"used_bytes" yaml:"used_bytes"`
+	TotalBytes int64  `json:"total_bytes" yaml:"total_bytes"`
+}
+
+// pseudoFilesystems are virtual filesystems that don't represent disk space
+var pseudoFilesystems = map[string]bool{
+	"autofs": true, "binfmt_misc": true, "bpf": true, "cgroup": true, "cgroup2": true,
+	"configfs": true, "debugfs": true, "devpts": true, "devtmpfs": true, "efivarfs": true,
+	"fusectl": true, "hugetlbfs": true, "mqueue": true, "nsfs": true, "overlay": true,
+	"proc": true, "pstore": true, "ramfs": true, "rpc_pipefs": true, "securityfs": true,
+	"squashfs": true, "sysfs": true, "tmpfs": true, "tracefs": true,
+}
+
+// IsPseudo reports whether the filesystem is virtual or has no capacity
+func (f Filesystem) IsPseudo() bool {
+	return pseudoFilesystems[strings.ToLower(f.Type)] || f.TotalBytes <= 0
+}
+
+// UsagePercent returns used space as a percentage of the total
+func (f Filesystem) UsagePercent() float64 {
+	if f.TotalBytes <= 0 {
+		return 0
+	}
+	return float64(f.UsedBytes) / float64(f.TotalBytes) * 100
+}
@@ -0,0 +1,34 @@
+package models
+
+import "testing"
+
+func TestFilesystem_IsPseudo(t *testing.T) {
+	tests := []struct {
+		fs       Filesystem
+		expected bool
+	}{
+		{Filesystem{Mountpoint: "/", Type: "ext4", TotalBytes: 100}, false},
+		{Filesystem{Mountpoint: `C:\`, Type: "NTFS", TotalBytes: 100}, false},
+		{Filesystem{Mountpoint: "/run", Type: "tmpfs", TotalBytes: 100}, true},
+		{Filesystem{Mountpoint: "/snap/core", Type: "squashfs", TotalBytes: 100}, true},
+		{Filesystem{Mountpoint: "/boot/efi", Type: "vfat", TotalBytes: 0}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fs.Mountpoint, func(t *testing.T) {
+			if got := tt.fs.IsPseudo(); got != tt.expected {
+				t.Errorf("IsPseudo() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilesystem_UsagePercent(t *testing.T) {
+	fs := Filesystem{UsedBytes: 25, TotalBytes: 100}
+	if got := fs.UsagePercent(); got != 25 {
+		t.Errorf("Expected 25%%, got %v", got)
+	}
+	if got := (Filesystem{UsedBytes: 5}).UsagePercent(); got != 0 {
+		t.Errorf("Unknown total should report 0, got %v", got)
+	}
+}
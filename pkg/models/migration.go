@@ -0,0 +1,71 @@
+package models
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// IsMigration reports whether a task moves a guest to another node: a
+// qmigrate for a VM, a vzmigrate for a container
+func (t Task) IsMigration() bool {
+	return t.Type == "qmigrate" || t.Type == "vzmigrate"
+}
+
+// MigrationProgress is what the log of a migration task tells of it
+type MigrationProgress struct {
+	Target  string  `json:"target" yaml:"target"`   // Node the guest moves to, "" until the log names it
+	Percent float64 `json:"percent" yaml:"percent"` // Share of the current transfer done, -1 until the log tells
+}
+
+// NewMigrationProgress returns the progress of a migration whose log
+// wasn't read yet
+func NewMigrationProgress() MigrationProgress {
+	return MigrationProgress{Percent: -1}
+}
+
+var (
+	// "starting migration of VM 101 to node 'pve2' (10.0.0.2)"
+	migrationTargetRe = regexp.MustCompile(`to node '([^']+)'`)
+	// "drive-scsi0: transferred 3.0 GiB of 32.0 GiB (9.38%) in 12s"
+	migrationPercentRe = regexp.MustCompile(`\((\d+(?:\.\d+)?)%\)`)
+	// "migration active, transferred 1.1 GiB of 4.0 GiB VM-state, 112.3 MiB/s"
+	migrationTransferRe = regexp.MustCompile(`transferred ([\d.]+) ?([KMGTP]?i?B) of ([\d.]+) ?([KMGTP]?i?B)`)
+)
+
+// sizeUnits are the multipliers of the sizes a migration log gives
+var sizeUnits = map[string]float64{
+	"B": 1, "KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30, "TiB": 1 << 40, "PiB": 1 << 50,
+	"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12, "PB": 1e15,
+}
+
+// Update reads further lines of the log: the last target and share
+// transferred they tell win
+func (p *MigrationProgress) Update(lines []TaskLogLine) {
+	for _, line := range lines {
+		if m := migrationTargetRe.FindStringSubmatch(line.Text); m != nil {
+			p.Target = m[1]
+		}
+		if m := migrationPercentRe.FindStringSubmatch(line.Text); m != nil {
+			if v, err := strconv.ParseFloat(m[1], 64); err == nil {
+				p.Percent = min(v, 100)
+			}
+			continue
+		}
+		if m := migrationTransferRe.FindStringSubmatch(line.Text); m != nil {
+			if done, total := transferSize(m[1], m[2]), transferSize(m[3], m[4]); total > 0 && done >= 0 {
+				p.Percent = min(done/total*100, 100)
+			}
+		}
+	}
+}
+
+// transferSize returns a size of a migration log in bytes, -1 when it
+// can't be read
+func transferSize(value, unit string) float64 {
+	v, err := strconv.ParseFloat(value, 64)
+	mult, ok := sizeUnits[unit]
+	if err != nil || !ok {
+		return -1
+	}
+	return v * mult
+}
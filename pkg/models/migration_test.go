@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTask_IsMigration(t *testing.T) {
+	assert.True(t, Task{Type: "qmigrate"}.IsMigration())
+	assert.True(t, Task{Type: "vzmigrate"}.IsMigration())
+	assert.False(t, Task{Type: "vzdump"}.IsMigration())
+}
+
+func TestMigrationProgress_Update(t *testing.T) {
+	p := NewMigrationProgress()
+	p.Update([]TaskLogLine{
+		{N: 1, Text: "2026-10-17 12:00:01 use dedicated network address for sending migration traffic (10.0.0.2)"},
+		{N: 2, Text: "2026-10-17 12:00:01 starting migration of VM 101 to node 'pve2' (10.0.0.2)"},
+	})
+	assert.Equal(t, MigrationProgress{Target: "pve2", Percent: -1}, p)
+
+	p.Update([]TaskLogLine{{N: 3, Text: "drive-scsi0: transferred 3.0 GiB of 32.0 GiB (9.38%) in 12s"}})
+	assert.Equal(t, 9.38, p.Percent)
+
+	// Read in further calls, as the log is read incrementally
+	p.Update([]TaskLogLine{
+		{N: 4, Text: "2026-10-17 12:01:10 migration active, transferred 1.0 GiB of 4.0 GiB VM-state, 112.3 MiB/s"},
+		{N: 5, Text: "2026-10-17 12:01:11 migration status: active (transferred 1.1 GiB)"},
+	})
+	assert.Equal(t, "pve2", p.Target)
+	assert.Equal(t, 25.0, p.Percent)
+
+	p.Update([]TaskLogLine{{N: 6, Text: "2026-10-17 12:01:20 migration active, transferred 2048 MiB of 4.0 GiB VM-state, 98 MiB/s"}})
+	assert.Equal(t, 50.0, p.Percent, "Units may differ on one line")
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// namePlaceholder is the index in a name pattern: {n}, or {n:3} for one
+// padded with zeros to 3 digits
+var namePlaceholder = regexp.MustCompile(`\{n(?::(\d))?\}`)
+
+// NamePattern names the guests cloned from a preset, e.g. "dev-web-{n}"
+// for dev-web-1, dev-web-2 and so on
+type NamePattern struct {
+	prefix, suffix string
+	width          int // Digits the index is padded to with zeros; 0 doesn't pad
+}
+
+// ParseNamePattern parses a pattern holding the placeholder once
+func ParseNamePattern(pattern string) (NamePattern, error) {
+	loc := namePlaceholder.FindAllStringSubmatchIndex(pattern, -1)
+	switch {
+	case len(loc) == 0:
+		return NamePattern{}, errors.New("must hold {n} where the index goes")
+	case len(loc) > 1:
+		return NamePattern{}, errors.New("must hold {n} only once")
+	}
+	p := NamePattern{prefix: pattern[:loc[0][0]], suffix: pattern[loc[0][1]:]}
+	if loc[0][2] >= 0 {
+		p.width, _ = strconv.Atoi(pattern[loc[0][2]:loc[0][3]])
+	}
+	return p, nil
+}
+
+// Expand returns the name with index n
+func (p NamePattern) Expand(n int) string {
+	return fmt.Sprintf("%s%0*d%s", p.prefix, p.width, n, p.suffix)
+}
+
+// Index returns the index of a name the pattern gives, ignoring case and
+// padding, as "dev-web-07" is taken by "dev-web-{n}" too
+func (p NamePattern) Index(name string) (int, bool) {
+	if len(name) <= len(p.prefix)+len(p.suffix) ||
+		!strings.EqualFold(name[:len(p.prefix)], p.prefix) ||
+		!strings.EqualFold(name[len(name)-len(p.suffix):], p.suffix) {
+		return 0, false
+	}
+	digits := name[len(p.prefix) : len(name)-len(p.suffix)]
+	if strings.Trim(digits, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	return n, err == nil
+}
+
+// NextIndex returns the index following the highest one among names, or 1
+// when none is taken, so a clone never reuses the name of one deleted in
+// between out of order
+func (p NamePattern) NextIndex(names []string) int {
+	next := 1
+	for _, name := range names {
+		if n, ok := p.Index(name); ok && n >= next {
+			next = n + 1
+		}
+	}
+	return next
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNamePattern(t *testing.T) {
+	for _, pattern := range []string{"dev-web", "dev-{n}-{n}", "dev-{m}"} {
+		_, err := ParseNamePattern(pattern)
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestNamePattern_Expand(t *testing.T) {
+	tests := []struct {
+		pattern string
+		n       int
+		want    string
+	}{
+		{"dev-web-{n}", 3, "dev-web-3"},
+		{"{n}-scratch", 12, "12-scratch"},
+		{"ci-{n:3}.lab", 7, "ci-007.lab"},
+		{"ci-{n:2}", 123, "ci-123"}, // Padding never truncates
+	}
+	for _, tt := range tests {
+		p, err := ParseNamePattern(tt.pattern)
+		require.NoError(t, err, tt.pattern)
+		assert.Equal(t, tt.want, p.Expand(tt.n), tt.pattern)
+	}
+}
+
+func TestNamePattern_NextIndex(t *testing.T) {
+	p, err := ParseNamePattern("dev-web-{n}")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, p.NextIndex(nil))
+	assert.Equal(t, 1, p.NextIndex([]string{"dev-web", "dev-web-", "dev-db-4", "prod-web-9"}))
+	assert.Equal(t, 4, p.NextIndex([]string{"dev-web-1", "dev-web-3"}), "A gap isn't reused")
+	assert.Equal(t, 8, p.NextIndex([]string{"DEV-WEB-07", "dev-web-2"}), "Case and padding are ignored")
+	assert.Equal(t, 1, p.NextIndex([]string{"dev-web-1a", "dev-web--2", "dev-web-x"}))
+
+	p, err = ParseNamePattern("ci-{n:3}.lab")
+	require.NoError(t, err)
+	assert.Equal(t, 11, p.NextIndex([]string{"ci-010.lab", "ci-9.lab", "ci-011"}))
+}
@@ -1,6 +1,12 @@
 package models
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
 
 // NodeType represents the type of virtual resource
 type NodeType string
@@ -8,6 +14,9 @@ type NodeType string
 const (
 	TypeVM        NodeType = "qemu"
 	TypeContainer NodeType = "lxc"
+	// TypeNode marks a state change of a node itself, such as a network
+	// probe failing, rather than of a guest
+	TypeNode NodeType = "node"
 )
 
 // NodeState represents the current state of the node
@@ -18,20 +27,180 @@ const (
 	StateStopped NodeState = "stopped"
 	StatePaused  NodeState = "paused"
 	StateUnknown NodeState = "unknown"
+	// StateHibernated is a VM suspended to disk: it is off, and the next
+	// start restores the memory it saved
+	StateHibernated NodeState = "hibernated"
+	// StateReachable and StateUnreachable are the states of a node's
+	// network probe, in state changes of TypeNode
+	StateReachable   NodeState = "reachable"
+	StateUnreachable NodeState = "unreachable"
+)
+
+// Metric identifies an optional metric that the API may omit or report as null
+type Metric uint8
+
+const (
+	MetricMaxCPU Metric = 1 << iota
+	MetricMaxMem
+	MetricMem
+	MetricUptime
+	MetricDisk
+	MetricMaxDisk
 )
 
+// metricNames are the names of the metrics in machine-readable output,
+// those of the API fields they come from
+var metricNames = []struct {
+	metric Metric
+	name   string
+}{
+	{MetricMaxCPU, "maxcpu"},
+	{MetricMaxMem, "maxmem"},
+	{MetricMem, "mem"},
+	{MetricUptime, "uptime"},
+	{MetricDisk, "disk"},
+	{MetricMaxDisk, "maxdisk"},
+}
+
+// Names returns the names of the metrics in the set, e.g. ["maxmem",
+// "uptime"]
+func (m Metric) Names() []string {
+	names := []string{}
+	for _, n := range metricNames {
+		if m&n.metric != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+// ParseMetrics is the reverse of Names. Unknown names are an error.
+func ParseMetrics(names []string) (Metric, error) {
+	var m Metric
+	for _, name := range names {
+		found := false
+		for _, n := range metricNames {
+			if n.name == name {
+				m |= n.metric
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown metric %q", name)
+		}
+	}
+	return m, nil
+}
+
+// MarshalJSON writes the set as a list of names, so that the bit values
+// never leak into the output
+func (m Metric) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Names())
+}
+
+// UnmarshalJSON reads a list of names written by MarshalJSON
+func (m *Metric) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	parsed, err := ParseMetrics(names)
+	*m = parsed
+	return err
+}
+
+// MarshalYAML writes the set as a list of names, like MarshalJSON
+func (m Metric) MarshalYAML() (interface{}, error) {
+	return m.Names(), nil
+}
+
+// UnmarshalYAML reads a list of names written by MarshalYAML
+func (m *Metric) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var names []string
+	if err := unmarshal(&names); err != nil {
+		return err
+	}
+	parsed, err := ParseMetrics(names)
+	*m = parsed
+	return err
+}
+
 // VMStatus represents the status of a VM or Container
 type VMStatus struct {
-	VMID        string  // Virtual Machine/Container ID
-	Name        string  // Name of the VM/Container
-	Type        string  // Type: qemu (VM) or lxc (Container)
-	Status      string  // Status: running, stopped, etc.
-	Node        string  // Proxmox node name
-	CPUUsage    float64 // CPU usage percentage
-	MemoryUsage float64 // Memory usage percentage
-	MaxMem      int64   // Maximum memory in bytes
-	MaxCPU      int     // Maximum CPU count
-	Uptime      int64   // Uptime in seconds
+	VMID        string    `json:"vmid" yaml:"vmid"`                           // Virtual Machine/Container ID
+	VMIDNum     int       `json:"vmid_num" yaml:"vmid_num"`                   // VMID parsed as an integer for numeric ordering (0 if unknown)
+	Name        string    `json:"name" yaml:"name"`                           // Name of the VM/Container
+	Type        NodeType  `json:"type" yaml:"type"`                           // TypeVM or TypeContainer
+	Status      NodeState `json:"status" yaml:"status"`                       // StateRunning, StateStopped, etc.
+	Node        string    `json:"node" yaml:"node"`                           // Proxmox node name
+	CPUUsage    float64   `json:"cpu_usage" yaml:"cpu_usage"`                 // CPU usage percentage
+	MemoryUsage float64   `json:"memory_usage" yaml:"memory_usage"`           // Memory usage percentage
+	MaxMem      int64     `json:"max_mem" yaml:"max_mem"`                     // Maximum memory in bytes
+	MaxCPU      int       `json:"max_cpu" yaml:"max_cpu"`                     // Maximum CPU count
+	Uptime      int64     `json:"uptime" yaml:"uptime"`                       // Uptime in seconds
+	Disk        int64     `json:"disk" yaml:"disk"`                           // Used disk space in bytes (containers only; always 0 for VMs)
+	MaxDisk     int64     `json:"max_disk" yaml:"max_disk"`                   // Root disk size in bytes
+	Missing     Metric    `json:"missing,omitempty" yaml:"missing,omitempty"` // Metrics not reported by the API; zero means all are known
+	Lock        string    `json:"lock,omitempty" yaml:"lock,omitempty"`       // Proxmox lock such as backup or migrate; empty when unlocked
+	// HAState is the state HA is asked to keep the guest in, such as
+	// started or stopped; empty when HA doesn't manage it
+	HAState string `json:"ha_state,omitempty" yaml:"ha_state,omitempty"`
+	// Pool is the resource pool the guest is in; empty when in none
+	Pool string `json:"pool,omitempty" yaml:"pool,omitempty"`
+	// Cluster names the cluster the guest belongs to when several are
+	// listed together; empty for a single cluster
+	Cluster string `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	// Unreachable marks a guest whose cluster didn't answer the last
+	// refresh; the other fields are those of the refresh before
+	Unreachable bool `json:"unreachable,omitempty" yaml:"unreachable,omitempty"`
+	// NodeOffline marks a guest whose node isn't online, in maintenance
+	// or without pvestatd: the API reports it unknown with zeroed
+	// metrics, and an action on it would only time out
+	NodeOffline bool `json:"node_offline,omitempty" yaml:"node_offline,omitempty"`
+	// QEMU is the state of a running VM's QEMU process; only
+	// status/current reports it, so it is nil for containers, stopped VMs
+	// and most guests of a list
+	QEMU *QEMUHealth `json:"qemu,omitempty" yaml:"qemu,omitempty"`
+	// Hostname is the guest's own hostname: a container's hostname
+	// setting, or what the QEMU guest agent of a running VM reports. The
+	// list doesn't carry it, so it is empty unless read on its own.
+	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+}
+
+// Key identifies the guest across clusters: its VMID, prefixed with
+// "cluster/" when it has a Cluster. VMIDs are only unique within a
+// cluster, so lists and maps of guests are keyed by Key.
+func (v *VMStatus) Key() string {
+	return GuestKey(v.Cluster, v.VMID)
+}
+
+// GuestKey returns the Key of the guest vmid of cluster
+func GuestKey(cluster, vmid string) string {
+	if cluster == "" {
+		return vmid
+	}
+	return cluster + "/" + vmid
+}
+
+// SplitKey is the reverse of Key: it returns the cluster, empty if none,
+// and the VMID
+func SplitKey(key string) (cluster, vmid string) {
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// TypeString returns Type as the plain string used in API paths, for
+// code written when Type was a string
+func (v *VMStatus) TypeString() string {
+	return string(v.Type)
+}
+
+// StatusString returns Status as a plain string, for code written when
+// Status was a string
+func (v *VMStatus) StatusString() string {
+	return string(v.Status)
 }
 
 // String returns a human-readable representation
@@ -40,39 +209,118 @@ func (v *VMStatus) String() string {
 		v.VMID, v.Name, v.Type, v.Status, v.CPUUsage, v.MemoryUsage)
 }
 
+// IsKnown reports whether the API reported the given metric
+func (v *VMStatus) IsKnown(m Metric) bool {
+	return v.Missing&m == 0
+}
+
+// HasMemoryUsage reports whether MemoryUsage was computed from known values
+func (v *VMStatus) HasMemoryUsage() bool {
+	return v.IsKnown(MetricMem) && v.IsKnown(MetricMaxMem) && v.MaxMem > 0
+}
+
+// HasDiskUsage reports whether Disk reflects real usage. Only containers
+// report it; for VMs the API returns 0 unless a guest agent is queried.
+func (v *VMStatus) HasDiskUsage() bool {
+	return v.Type == TypeContainer &&
+		v.IsKnown(MetricDisk) && v.IsKnown(MetricMaxDisk) && v.MaxDisk > 0
+}
+
+// DiskUsage returns used disk space as a percentage of MaxDisk, or 0 when unknown
+func (v *VMStatus) DiskUsage() float64 {
+	if !v.HasDiskUsage() {
+		return 0
+	}
+	return float64(v.Disk) / float64(v.MaxDisk) * 100
+}
+
 // IsRunning returns true if the node is currently running
 func (v *VMStatus) IsRunning() bool {
-	return v.Status == string(StateRunning)
+	return v.Status == StateRunning
 }
 
-// CanStart returns true if the node can be started
+// IsUnknown reports whether the API didn't report a state pvec knows for
+// the node, its usage and uptime then being zeros rather than readings
+func (v *VMStatus) IsUnknown() bool {
+	return v.Status == StateUnknown
+}
+
+// CanStart returns true if the node can be started; starting a
+// hibernated VM resumes it from disk
 func (v *VMStatus) CanStart() bool {
-	return v.Status == string(StateStopped)
+	return v.Status == StateStopped || v.Status == StateHibernated
 }
 
 // CanStop returns true if the node can be stopped
 func (v *VMStatus) CanStop() bool {
-	return v.Status == string(StateRunning) || v.Status == string(StatePaused)
+	return v.Status == StateRunning || v.Status == StatePaused
+}
+
+// Locked reports whether a lock blocks actions on the node. The
+// suspended lock of a hibernated VM doesn't count, as starting the VM is
+// what releases it.
+func (v *VMStatus) Locked() bool {
+	return v.Lock != "" && !(v.Lock == "suspended" && v.Status == StateHibernated)
 }
 
-// NodeList is an interface for managing a collection of nodes
+// HostnameDiffers reports whether a guest's own hostname, when known,
+// names another machine than its Proxmox name, as for a container cloned
+// from a template and never renamed. Only the first label counts, so
+// web-1.example.com is web-1, and case is ignored.
+func HostnameDiffers(name, hostname string) bool {
+	if hostname == "" {
+		return false
+	}
+	short := func(s string) string {
+		label, _, _ := strings.Cut(s, ".")
+		return label
+	}
+	return !strings.EqualFold(short(name), short(hostname))
+}
+
+// CanResume returns true if the node is paused and can be resumed
+func (v *VMStatus) CanResume() bool {
+	return v.Status == StatePaused
+}
+
+// NodeList is an interface for managing a collection of nodes, keyed by
+// VMStatus.Key, which is the VMID for a single cluster. Implementations are safe for concurrent use, so that one list can
+// be shared by the components that look guests up.
 type NodeList interface {
-	// Add adds a node to the list
+	// Add adds a node to the list, replacing any with the same key
 	Add(node *VMStatus)
-	// Remove removes a node by VMID
-	Remove(vmid string) bool
-	// Get retrieves a node by VMID
-	Get(vmid string) (*VMStatus, bool)
-	// All returns all nodes
+	// Remove removes a node by key
+	Remove(key string) bool
+	// Get retrieves a node by key
+	Get(key string) (*VMStatus, bool)
+	// All returns all nodes, ordered by VMID
 	All() []*VMStatus
+	// Sorted returns all nodes ordered by less
+	Sorted(less func(a, b *VMStatus) bool) []*VMStatus
+	// ReplaceAll swaps the whole content for nodes in one step, so that
+	// readers see either the old list or the new one
+	ReplaceAll(nodes []*VMStatus)
 	// Clear removes all nodes
 	Clear()
 	// Count returns the number of nodes
 	Count() int
 }
 
+// ByVMID orders nodes by numeric VMID, then by VMID text for the ones
+// that don't parse, then by cluster
+func ByVMID(a, b *VMStatus) bool {
+	if a.VMIDNum != b.VMIDNum {
+		return a.VMIDNum < b.VMIDNum
+	}
+	if a.VMID != b.VMID {
+		return a.VMID < b.VMID
+	}
+	return a.Cluster < b.Cluster
+}
+
 // InMemoryNodeList is an in-memory implementation of NodeList
 type InMemoryNodeList struct {
+	mu    sync.RWMutex
 	nodes map[string]*VMStatus
 }
 
@@ -84,36 +332,67 @@ func NewNodeList() NodeList {
 }
 
 func (n *InMemoryNodeList) Add(node *VMStatus) {
-	if node != nil {
-		n.nodes[node.VMID] = node
+	if node == nil {
+		return
 	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nodes[node.Key()] = node
 }
 
-func (n *InMemoryNodeList) Remove(vmid string) bool {
-	if _, exists := n.nodes[vmid]; exists {
-		delete(n.nodes, vmid)
+func (n *InMemoryNodeList) Remove(key string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, exists := n.nodes[key]; exists {
+		delete(n.nodes, key)
 		return true
 	}
 	return false
 }
 
-func (n *InMemoryNodeList) Get(vmid string) (*VMStatus, bool) {
-	node, exists := n.nodes[vmid]
+func (n *InMemoryNodeList) Get(key string) (*VMStatus, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	node, exists := n.nodes[key]
 	return node, exists
 }
 
 func (n *InMemoryNodeList) All() []*VMStatus {
+	return n.Sorted(ByVMID)
+}
+
+func (n *InMemoryNodeList) Sorted(less func(a, b *VMStatus) bool) []*VMStatus {
+	n.mu.RLock()
 	result := make([]*VMStatus, 0, len(n.nodes))
 	for _, node := range n.nodes {
 		result = append(result, node)
 	}
+	n.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return less(result[i], result[j])
+	})
 	return result
 }
 
+func (n *InMemoryNodeList) ReplaceAll(nodes []*VMStatus) {
+	replaced := make(map[string]*VMStatus, len(nodes))
+	for _, node := range nodes {
+		if node != nil {
+			replaced[node.Key()] = node
+		}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nodes = replaced
+}
+
 func (n *InMemoryNodeList) Clear() {
-	n.nodes = make(map[string]*VMStatus)
+	n.ReplaceAll(nil)
 }
 
 func (n *InMemoryNodeList) Count() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	return len(n.nodes)
 }
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -10,8 +13,8 @@ func TestVMStatus_String(t *testing.T) {
 	vm := &VMStatus{
 		VMID:        "100",
 		Name:        "test-vm",
-		Type:        string(TypeVM),
-		Status:      string(StateRunning),
+		Type:        TypeVM,
+		Status:      StateRunning,
 		CPUUsage:    25.5,
 		MemoryUsage: 60.2,
 	}
@@ -31,16 +34,24 @@ func TestVMStatus_IsRunning(t *testing.T) {
 		{"running node", StateRunning, true},
 		{"stopped node", StateStopped, false},
 		{"paused node", StatePaused, false},
+		{"hibernated node", StateHibernated, false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			vm := &VMStatus{Status: string(tt.status)}
+			vm := &VMStatus{Status: tt.status}
 			assert.Equal(t, tt.expected, vm.IsRunning())
 		})
 	}
 }
 
+func TestVMStatus_IsUnknown(t *testing.T) {
+	assert.True(t, (&VMStatus{Status: StateUnknown}).IsUnknown())
+	for _, status := range []NodeState{StateRunning, StateStopped, StatePaused, StateHibernated} {
+		assert.False(t, (&VMStatus{Status: status}).IsUnknown(), status)
+	}
+}
+
 func TestVMStatus_CanStart(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -48,13 +59,14 @@ func TestVMStatus_CanStart(t *testing.T) {
 		expected bool
 	}{
 		{"stopped node", StateStopped, true},
+		{"hibernated node", StateHibernated, true},
 		{"running node", StateRunning, false},
 		{"paused node", StatePaused, false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			vm := &VMStatus{Status: string(tt.status)}
+			vm := &VMStatus{Status: tt.status}
 			assert.Equal(t, tt.expected, vm.CanStart())
 		})
 	}
@@ -69,16 +81,112 @@ func TestVMStatus_CanStop(t *testing.T) {
 		{"running node", StateRunning, true},
 		{"paused node", StatePaused, true},
 		{"stopped node", StateStopped, false},
+		{"hibernated node", StateHibernated, false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			vm := &VMStatus{Status: string(tt.status)}
+			vm := &VMStatus{Status: tt.status}
 			assert.Equal(t, tt.expected, vm.CanStop())
 		})
 	}
 }
 
+func TestVMStatus_Locked(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   NodeState
+		lock     string
+		expected bool
+	}{
+		{"unlocked node", StateRunning, "", false},
+		{"backup lock", StateStopped, "backup", true},
+		{"migrate lock", StateRunning, "migrate", true},
+		{"hibernated node", StateHibernated, "suspended", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := &VMStatus{Status: tt.status, Lock: tt.lock}
+			assert.Equal(t, tt.expected, vm.Locked())
+		})
+	}
+}
+
+func TestHostnameDiffers(t *testing.T) {
+	tests := []struct {
+		name     string
+		guest    string
+		hostname string
+		expected bool
+	}{
+		{"unknown hostname", "web-1", "", false},
+		{"same name", "web-1", "web-1", false},
+		{"case ignored", "Web-1", "web-1", false},
+		{"fully qualified hostname", "web-1", "web-1.example.com", false},
+		{"fully qualified name", "web-1.example.com", "web-1", false},
+		{"clone of a template", "web-2", "debian-template", true},
+		{"same domain, other host", "web-1.example.com", "web-2.example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, HostnameDiffers(tt.guest, tt.hostname))
+		})
+	}
+}
+
+func TestVMStatus_CanResume(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   NodeState
+		expected bool
+	}{
+		{"paused node", StatePaused, true},
+		{"running node", StateRunning, false},
+		{"stopped node", StateStopped, false},
+		{"hibernated node", StateHibernated, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := &VMStatus{Status: tt.status}
+			assert.Equal(t, tt.expected, vm.CanResume())
+		})
+	}
+}
+
+func TestVMStatus_IsKnown(t *testing.T) {
+	vm := &VMStatus{MaxMem: 1024, Missing: MetricUptime | MetricMaxCPU}
+
+	assert.False(t, vm.IsKnown(MetricUptime))
+	assert.False(t, vm.IsKnown(MetricMaxCPU))
+	assert.True(t, vm.IsKnown(MetricMem))
+	assert.True(t, vm.IsKnown(MetricMaxMem))
+	assert.True(t, vm.HasMemoryUsage())
+
+	assert.True(t, (&VMStatus{MaxMem: 1}).IsKnown(MetricUptime), "zero value means known")
+}
+
+func TestVMStatus_HasMemoryUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		vm       VMStatus
+		expected bool
+	}{
+		{"all known", VMStatus{MaxMem: 1024}, true},
+		{"zero maxmem", VMStatus{MaxMem: 0}, false},
+		{"mem missing", VMStatus{MaxMem: 1024, Missing: MetricMem}, false},
+		{"maxmem missing", VMStatus{MaxMem: 1024, Missing: MetricMaxMem}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.vm.HasMemoryUsage())
+		})
+	}
+}
+
 func TestNodeList_Add(t *testing.T) {
 	list := NewNodeList()
 	vm := &VMStatus{VMID: "100", Name: "test"}
@@ -143,3 +251,129 @@ func TestNodeList_AddNil(t *testing.T) {
 	list.Add(nil)
 	assert.Equal(t, 0, list.Count())
 }
+
+func TestNodeList_Order(t *testing.T) {
+	list := NewNodeList()
+	list.ReplaceAll([]*VMStatus{
+		{VMID: "1000", VMIDNum: 1000, Name: "c"},
+		{VMID: "99", VMIDNum: 99, Name: "b"},
+		{VMID: "101", VMIDNum: 101, Name: "a"},
+	})
+
+	var vmids []string
+	for _, vm := range list.All() {
+		vmids = append(vmids, vm.VMID)
+	}
+	assert.Equal(t, []string{"99", "101", "1000"}, vmids, "All orders by numeric VMID")
+
+	byName := list.Sorted(func(a, b *VMStatus) bool { return a.Name < b.Name })
+	assert.Equal(t, "a", byName[0].Name)
+	assert.Equal(t, "c", byName[2].Name)
+}
+
+func TestNodeList_ReplaceAll(t *testing.T) {
+	list := NewNodeList()
+	list.Add(&VMStatus{VMID: "100"})
+
+	list.ReplaceAll([]*VMStatus{{VMID: "200"}, nil, {VMID: "201"}})
+
+	assert.Equal(t, 2, list.Count())
+	_, exists := list.Get("100")
+	assert.False(t, exists, "ReplaceAll drops what the new list lacks")
+
+	list.ReplaceAll(nil)
+	assert.Equal(t, 0, list.Count())
+}
+
+func TestVMStatus_Key(t *testing.T) {
+	single := &VMStatus{VMID: "100"}
+	assert.Equal(t, "100", single.Key())
+
+	multi := &VMStatus{VMID: "100", Cluster: "lab"}
+	assert.Equal(t, "lab/100", multi.Key())
+
+	cluster, vmid := SplitKey(multi.Key())
+	assert.Equal(t, "lab", cluster)
+	assert.Equal(t, "100", vmid)
+	cluster, vmid = SplitKey("100")
+	assert.Empty(t, cluster)
+	assert.Equal(t, "100", vmid)
+}
+
+func TestNodeList_DuplicateVMIDs(t *testing.T) {
+	list := NewNodeList()
+	list.ReplaceAll([]*VMStatus{
+		{VMID: "100", VMIDNum: 100, Name: "prod-web", Cluster: "prod"},
+		{VMID: "100", VMIDNum: 100, Name: "lab-web", Cluster: "lab"},
+	})
+
+	assert.Equal(t, 2, list.Count(), "The same VMID in two clusters is two guests")
+	node, exists := list.Get("prod/100")
+	assert.True(t, exists)
+	assert.Equal(t, "prod-web", node.Name)
+	_, exists = list.Get("100")
+	assert.False(t, exists)
+
+	all := list.All()
+	assert.Equal(t, "lab-web", all[0].Name, "Equal VMIDs are ordered by cluster")
+}
+
+func TestNodeList_Concurrent(t *testing.T) {
+	list := NewNodeList()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				vmid := fmt.Sprint(100 + j)
+				list.ReplaceAll([]*VMStatus{{VMID: vmid, VMIDNum: 100 + j}})
+				list.Add(&VMStatus{VMID: "1", VMIDNum: 1})
+				list.Get(vmid)
+				list.All()
+				list.Count()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, list.Count(), 2)
+}
+
+func TestVMStatus_DiskUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		vm       VMStatus
+		hasUsage bool
+		usage    float64
+	}{
+		{"container", VMStatus{Type: "lxc", Disk: 97, MaxDisk: 100}, true, 97},
+		{"vm", VMStatus{Type: "qemu", Disk: 0, MaxDisk: 100}, false, 0},
+		{"zero max", VMStatus{Type: "lxc", Disk: 10, MaxDisk: 0}, false, 0},
+		{"missing disk", VMStatus{Type: "lxc", Disk: 0, MaxDisk: 100, Missing: MetricDisk}, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.vm.HasDiskUsage(); got != tt.hasUsage {
+				t.Errorf("HasDiskUsage() = %v, want %v", got, tt.hasUsage)
+			}
+			if got := tt.vm.DiskUsage(); got != tt.usage {
+				t.Errorf("DiskUsage() = %v, want %v", got, tt.usage)
+			}
+		})
+	}
+}
+
+func TestMetric_Names(t *testing.T) {
+	m := MetricMaxMem | MetricMaxDisk
+	assert.Equal(t, []string{"maxmem", "maxdisk"}, m.Names())
+	assert.Equal(t, []string{}, Metric(0).Names())
+
+	parsed, err := ParseMetrics(m.Names())
+	assert.NoError(t, err)
+	assert.Equal(t, m, parsed)
+
+	_, err = ParseMetrics([]string{"swap"})
+	assert.Error(t, err, "An unknown metric should be rejected")
+	assert.Error(t, json.Unmarshal([]byte(`["cpu"]`), &m))
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// Permissions are the effective privileges of a user or API token, by ACL
+// path such as /, /vms or /vms/100. Each privilege maps to whether it
+// propagates to the paths below.
+type Permissions map[string]map[string]bool
+
+// Has reports whether privilege applies on path
+func (p Permissions) Has(path, privilege string) bool {
+	for _, priv := range p.Privileges(path) {
+		if priv == privilege {
+			return true
+		}
+	}
+	return false
+}
+
+// Privileges returns the privileges in effect on path, sorted. A listed
+// path holds its full set; any other path inherits the propagating
+// privileges of the closest listed path above it.
+func (p Permissions) Privileges(path string) []string {
+	path = cleanACLPath(path)
+	listed, exact := path, true
+	for {
+		if _, ok := p[listed]; ok || listed == "/" {
+			break
+		}
+		listed, exact = parentACLPath(listed), false
+	}
+
+	var privs []string
+	for priv, propagate := range p[listed] {
+		if exact || propagate {
+			privs = append(privs, priv)
+		}
+	}
+	sort.Strings(privs)
+	return privs
+}
+
+// cleanACLPath drops a trailing slash, keeping the root as /
+func cleanACLPath(path string) string {
+	return "/" + strings.Trim(path, "/")
+}
+
+// parentACLPath returns the path one level up, / being its own parent
+func parentACLPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPermissions_Privileges(t *testing.T) {
+	p := Permissions{
+		"/":        {"Sys.Audit": false},
+		"/vms":     {"VM.Audit": true, "VM.PowerMgmt": true, "VM.Console": false},
+		"/vms/103": {"VM.Audit": true},
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "Sys.Audit"},
+		{"/nodes/pve1", ""}, // Sys.Audit on / doesn't propagate
+		{"/vms", "VM.Audit VM.Console VM.PowerMgmt"},
+		{"/vms/100", "VM.Audit VM.PowerMgmt"},
+		{"/vms/103", "VM.Audit"},
+		{"/vms/103/", "VM.Audit"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(p.Privileges(tt.path), " "); got != tt.want {
+			t.Errorf("Privileges(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if !p.Has("/vms/100", "VM.PowerMgmt") || p.Has("/vms/103", "VM.PowerMgmt") {
+		t.Error("Has should follow Privileges")
+	}
+	if (Permissions{}).Has("/", "Sys.Audit") {
+		t.Error("No permissions should grant nothing")
+	}
+}
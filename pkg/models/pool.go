@@ -0,0 +1,81 @@
+package models
+
+import "sort"
+
+// NoPool is the ID under which PoolRollup groups the guests outside any
+// resource pool
+const NoPool = "(none)"
+
+// PoolStats is the rollup of a resource pool: how many of its guests
+// run, what they are all given and what the running ones use
+type PoolStats struct {
+	ID        string     `json:"id" yaml:"id"`
+	Comment   string     `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Running   int        `json:"running" yaml:"running"` // Running or paused guests
+	Total     int        `json:"total" yaml:"total"`
+	Allocated Allocation `json:"allocated" yaml:"allocated"` // Cores and memory given to every guest
+	CPU       float64    `json:"cpu" yaml:"cpu"`             // Cores the running guests use
+	Mem       int64      `json:"mem" yaml:"mem"`             // Bytes of memory the running guests use
+	Unknown   int        `json:"unknown" yaml:"unknown"`     // Guests in an unknown state, counted in Total only
+}
+
+// PoolRollup sums up the guests of each pool: every pool listed, even
+// empty, and every pool a guest names, then NoPool for the guests in
+// none when there are any. Pools come ordered by ID, NoPool last. Sizes
+// the API didn't report count as zero, and guests in an unknown state
+// are only counted, as the API zeroes what it reports of them.
+func PoolRollup(guests []*VMStatus, pools []Pool) []PoolStats {
+	byID := make(map[string]*PoolStats)
+	for _, p := range pools {
+		byID[p.ID] = &PoolStats{ID: p.ID, Comment: p.Comment}
+	}
+	for _, g := range guests {
+		id := g.Pool
+		if id == "" {
+			id = NoPool
+		}
+		stats, ok := byID[id]
+		if !ok {
+			stats = &PoolStats{ID: id}
+			byID[id] = stats
+		}
+		stats.add(g)
+	}
+
+	rollup := make([]PoolStats, 0, len(byID))
+	for _, stats := range byID {
+		rollup = append(rollup, *stats)
+	}
+	sort.Slice(rollup, func(i, j int) bool {
+		if (rollup[i].ID == NoPool) != (rollup[j].ID == NoPool) {
+			return rollup[j].ID == NoPool
+		}
+		return rollup[i].ID < rollup[j].ID
+	})
+	return rollup
+}
+
+// add counts a guest in the pool
+func (s *PoolStats) add(g *VMStatus) {
+	s.Total++
+	if g.IsUnknown() {
+		s.Unknown++
+		return
+	}
+	if g.IsKnown(MetricMaxCPU) {
+		s.Allocated.Cores += g.MaxCPU
+	}
+	if g.IsKnown(MetricMaxMem) {
+		s.Allocated.Mem += g.MaxMem
+	}
+	if !g.CanStop() {
+		return
+	}
+	s.Running++
+	if g.IsKnown(MetricMaxCPU) {
+		s.CPU += g.CPUUsage / 100 * float64(g.MaxCPU)
+	}
+	if g.HasMemoryUsage() {
+		s.Mem += int64(g.MemoryUsage / 100 * float64(g.MaxMem))
+	}
+}
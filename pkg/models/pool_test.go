@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// poolGuests spreads the pools over both nodes, with VMs and containers
+func poolGuests() []*VMStatus {
+	const gib = 1 << 30
+	return []*VMStatus{
+		{VMID: "100", Type: TypeVM, Node: "pve1", Pool: "prod", Status: StateRunning, MaxCPU: 4, MaxMem: 8 * gib, CPUUsage: 50, MemoryUsage: 25},
+		{VMID: "101", Type: TypeVM, Node: "pve2", Pool: "prod", Status: StateStopped, MaxCPU: 2, MaxMem: 4 * gib},
+		{VMID: "200", Type: TypeContainer, Node: "pve2", Pool: "prod", Status: StateRunning, MaxCPU: 1, MaxMem: 2 * gib, CPUUsage: 100, MemoryUsage: 50},
+		{VMID: "102", Type: TypeVM, Node: "pve1", Pool: "dev", Status: StatePaused, MaxCPU: 2, MaxMem: 2 * gib, MemoryUsage: 100},
+		{VMID: "201", Type: TypeContainer, Node: "pve1", Pool: "dev", Status: StateUnknown},
+		{VMID: "202", Type: TypeContainer, Node: "pve2", Status: StateRunning, MaxCPU: 1, MaxMem: gib, Missing: MetricMem},
+		{VMID: "103", Type: TypeVM, Node: "pve2", Pool: "lab", Status: StateStopped, Missing: MetricMaxCPU | MetricMaxMem},
+	}
+}
+
+func TestPoolRollup(t *testing.T) {
+	const gib = 1 << 30
+	pools := []Pool{{ID: "prod", Comment: "Production"}, {ID: "dev"}, {ID: "archive", Comment: "Empty"}}
+
+	rollup := PoolRollup(poolGuests(), pools)
+
+	require.Len(t, rollup, 5)
+	assert.Equal(t, []string{"archive", "dev", "lab", "prod", NoPool},
+		[]string{rollup[0].ID, rollup[1].ID, rollup[2].ID, rollup[3].ID, rollup[4].ID}, "By ID, guests in no pool last")
+
+	assert.Equal(t, PoolStats{ID: "archive", Comment: "Empty"}, rollup[0], "A listed pool shows even empty")
+	assert.Equal(t, PoolStats{
+		ID: "dev", Running: 1, Total: 2, Unknown: 1,
+		Allocated: Allocation{Cores: 2, Mem: 2 * gib}, Mem: 2 * gib,
+	}, rollup[1], "A paused guest keeps its memory; one in an unknown state is only counted")
+	assert.Equal(t, PoolStats{ID: "lab", Total: 1}, rollup[2], "A pool only the guests name is listed, sizes unknown count as zero")
+	assert.Equal(t, PoolStats{
+		ID: "prod", Comment: "Production", Running: 2, Total: 3,
+		Allocated: Allocation{Cores: 7, Mem: 14 * gib}, CPU: 3, Mem: 3 * gib,
+	}, rollup[3], "A pool sums its guests over every node")
+	assert.Equal(t, PoolStats{
+		ID: NoPool, Running: 1, Total: 1,
+		Allocated: Allocation{Cores: 1, Mem: gib}, CPU: 0,
+	}, rollup[4], "Memory use the API left out counts as zero")
+}
+
+func TestPoolRollup_NoGuestOutsidePools(t *testing.T) {
+	rollup := PoolRollup(poolGuests()[:2], nil)
+	require.Len(t, rollup, 1)
+	assert.Equal(t, "prod", rollup[0].ID)
+}
@@ -0,0 +1,52 @@
+package models
+
+import "fmt"
+
+// BalloonState tells whether a VM's balloon driver reports its memory
+type BalloonState string
+
+const (
+	BalloonUnknown  BalloonState = ""         // Not read
+	BalloonNone     BalloonState = "none"     // The VM has no balloon device
+	BalloonSilent   BalloonState = "silent"   // A device but no report: no driver, or the guest OS isn't up
+	BalloonReported BalloonState = "reported" // The driver in the guest OS reports its memory
+)
+
+// QEMUHealth is what status/current tells of a running VM's QEMU process
+// beyond cluster/resources: Proxmox lists a VM as running as long as the
+// process exists, even when it never started the guest or stopped on an
+// error
+type QEMUHealth struct {
+	QMPStatus string       `json:"qmpstatus" yaml:"qmpstatus"` // QEMU's own run state, e.g. running, prelaunch or internal-error
+	Balloon   BalloonState `json:"balloon,omitempty" yaml:"balloon,omitempty"`
+}
+
+// qmpProblems explains the QEMU run states of a VM that is listed as
+// running but doesn't run its guest. paused and suspended aren't there:
+// the VM is then listed as paused.
+var qmpProblems = map[string]string{
+	"prelaunch":      "QEMU has not started the guest CPUs",
+	"internal-error": "QEMU stopped the guest on an internal error",
+	"io-error":       "QEMU stopped the guest on a disk I/O error",
+	"guest-panicked": "the guest OS panicked",
+	"shutdown":       "the guest OS shut down but QEMU is still running",
+	"watchdog":       "the guest watchdog fired",
+	"debug":          "the guest is stopped in the debugger",
+	"inmigrate":      "QEMU is waiting for an incoming migration",
+	"postmigrate":    "the guest was migrated away and no longer runs here",
+	"finish-migrate": "QEMU is finishing a migration",
+	"save-vm":        "QEMU is saving the guest state",
+	"restore-vm":     "QEMU is restoring the guest state",
+}
+
+// Problem explains why a VM that status lists as running doesn't run its
+// guest, or returns an empty string when QEMU agrees or wasn't read
+func (h *QEMUHealth) Problem(status NodeState) string {
+	if h == nil || status != StateRunning || h.QMPStatus == "" || h.QMPStatus == "running" {
+		return ""
+	}
+	if problem, ok := qmpProblems[h.QMPStatus]; ok {
+		return problem
+	}
+	return fmt.Sprintf("QEMU reports %s rather than running", h.QMPStatus)
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQEMUHealth_Problem(t *testing.T) {
+	tests := []struct {
+		name      string
+		health    *QEMUHealth
+		status    NodeState
+		wantEmpty bool
+		contains  string
+	}{
+		{"not read", nil, StateRunning, true, ""},
+		{"agrees", &QEMUHealth{QMPStatus: "running"}, StateRunning, true, ""},
+		{"unknown", &QEMUHealth{}, StateRunning, true, ""},
+		{"not listed running", &QEMUHealth{QMPStatus: "prelaunch"}, StatePaused, true, ""},
+		{"prelaunch", &QEMUHealth{QMPStatus: "prelaunch"}, StateRunning, false, "not started the guest CPUs"},
+		{"panicked", &QEMUHealth{QMPStatus: "guest-panicked"}, StateRunning, false, "panicked"},
+		{"io error", &QEMUHealth{QMPStatus: "io-error"}, StateRunning, false, "disk I/O error"},
+		{"new state", &QEMUHealth{QMPStatus: "colo"}, StateRunning, false, "QEMU reports colo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := tt.health.Problem(tt.status)
+			if tt.wantEmpty {
+				assert.Empty(t, problem)
+				return
+			}
+			assert.Contains(t, problem, tt.contains)
+		})
+	}
+}
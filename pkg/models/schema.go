@@ -0,0 +1,8 @@
+package models
+
+// SchemaVersion is the version of the machine-readable form of the models,
+// for output such as JSON listings, state files and webhooks. Their json
+// and yaml tags are the compatibility contract: a field may be added
+// without changing the version, but renaming, removing or changing the
+// meaning of a tagged field requires bumping it.
+const SchemaVersion = 1
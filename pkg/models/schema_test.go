@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// sampleModels holds one filled-in value of every model with a
+// machine-readable form
+func sampleModels() []interface{} {
+	at := time.Date(2025, 1, 1, 14, 0, 0, 0, time.UTC)
+	return []interface{}{
+		&VMStatus{VMID: "100", VMIDNum: 100, Name: "web", Type: TypeVM, Status: StateRunning, Node: "pve1",
+			CPUUsage: 12.5, MemoryUsage: 40, MaxMem: 4 << 30, MaxCPU: 2, Uptime: 3600, MaxDisk: 32 << 30,
+			Missing: MetricDisk | MetricUptime},
+		&ClusterNode{Name: "pve1", Online: true, CPUUsage: 3.5, MaxCPU: 32, Mem: 8 << 30, MaxMem: 64 << 30, Uptime: 86400},
+		&NodeAllocation{Defined: Allocation{Cores: 48, Mem: 96 << 30}, Running: Allocation{Cores: 24, Mem: 48 << 30}},
+		&StateChange{Time: at, VMID: "100", Name: "web", Type: TypeVM, Node: "pve1", OldState: StateRunning, NewState: StateStopped},
+		&Task{UPID: "UPID:pve1:0001:vzdump::root@pam:", Node: "pve1", Type: "vzdump", ID: "100", User: "root@pam", StartTime: at},
+		&TaskLogLine{N: 3, Text: "INFO: starting"},
+		&TaskStatus{ExitStatus: "OK"},
+		&Storage{Name: "local", Node: "pve1", Type: "dir", Content: []string{"iso", "vztmpl"}, Used: 1 << 30, Total: 100 << 30},
+		&StorageVolume{VolID: "local:iso/debian.iso", Node: "pve1", Storage: "local", Content: ContentISO, Format: "iso", Size: 600 << 20, Created: at},
+		&Filesystem{Name: "sda1", Mountpoint: "/", Type: "ext4", UsedBytes: 1 << 30, TotalBytes: 8 << 30},
+		&MigrationProgress{Target: "pve2", Percent: 43.5},
+		&PoolStats{ID: "lab", Comment: "Test guests", Running: 2, Total: 3, Allocated: Allocation{Cores: 6, Mem: 12 << 30}, CPU: 1.5, Mem: 6 << 30, Unknown: 1},
+	}
+}
+
+func TestSchema_JSONRoundTrip(t *testing.T) {
+	for _, want := range sampleModels() {
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Fatalf("Marshal %T: %v", want, err)
+		}
+		got := reflect.New(reflect.TypeOf(want).Elem()).Interface()
+		if err := json.Unmarshal(data, got); err != nil {
+			t.Fatalf("Unmarshal %T: %v", want, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%T changed through JSON:\n got %+v\nwant %+v", want, got, want)
+		}
+	}
+}
+
+func TestSchema_YAMLRoundTrip(t *testing.T) {
+	for _, want := range sampleModels() {
+		data, err := yaml.Marshal(want)
+		if err != nil {
+			t.Fatalf("Marshal %T: %v", want, err)
+		}
+		got := reflect.New(reflect.TypeOf(want).Elem()).Interface()
+		if err := yaml.Unmarshal(data, got); err != nil {
+			t.Fatalf("Unmarshal %T: %v", want, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%T changed through YAML:\n got %+v\nwant %+v", want, got, want)
+		}
+	}
+}
+
+// TestSchema_VMStatusKeys pins the keys of the guest listing; changing
+// them breaks consumers and needs a SchemaVersion bump
+func TestSchema_VMStatusKeys(t *testing.T) {
+	data, err := json.Marshal(sampleModels()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"vmid":"100","vmid_num":100,"name":"web","type":"qemu","status":"running","node":"pve1",` +
+		`"cpu_usage":12.5,"memory_usage":40,"max_mem":4294967296,"max_cpu":2,"uptime":3600,"disk":0,` +
+		`"max_disk":34359738368,"missing":["uptime","disk"]}`
+	if string(data) != want {
+		t.Errorf("Unexpected JSON:\n got %s\nwant %s", data, want)
+	}
+
+	data, err = yaml.Marshal(sampleModels()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "max_mem: 4294967296\n") || !strings.Contains(string(data), "missing:\n    - uptime\n    - disk\n") {
+		t.Errorf("Unexpected YAML:\n%s", data)
+	}
+}
+
+func TestSchema_OmitsKnownMetrics(t *testing.T) {
+	data, err := json.Marshal(&VMStatus{VMID: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "missing") {
+		t.Errorf("A guest reporting every metric has no missing key: %s", data)
+	}
+	if strings.Contains(string(data), "lock") {
+		t.Errorf("An unlocked guest has no lock key: %s", data)
+	}
+}
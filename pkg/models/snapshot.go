@@ -0,0 +1,31 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// AutoSnapshotPrefix starts the names of the snapshots pvec takes on its
+// own before an action, so they can be told apart and pruned
+const AutoSnapshotPrefix = "pvec-auto-"
+
+// Snapshot is a snapshot of a guest
+type Snapshot struct {
+	Name        string    `json:"name" yaml:"name"`
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Parent      string    `json:"parent,omitempty" yaml:"parent,omitempty"` // Snapshot this one was taken after
+	Created     time.Time `json:"created" yaml:"created"`
+	VMState     bool      `json:"vmstate" yaml:"vmstate"` // The RAM was saved too
+}
+
+// Auto reports whether pvec took the snapshot before an action
+func (s Snapshot) Auto() bool {
+	return strings.HasPrefix(s.Name, AutoSnapshotPrefix)
+}
+
+// AutoSnapshotName returns the name of a snapshot taken at t before an
+// action, e.g. pvec-auto-20261017-183000. Proxmox names start with a
+// letter and only hold letters, digits, - and _.
+func AutoSnapshotName(t time.Time) string {
+	return AutoSnapshotPrefix + t.UTC().Format("20060102-150405")
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoSnapshotName(t *testing.T) {
+	at := time.Date(2026, 10, 17, 20, 30, 5, 0, time.FixedZone("CEST", 2*3600))
+	name := AutoSnapshotName(at)
+	if name != "pvec-auto-20261017-183005" {
+		t.Errorf("Expected the UTC time in the name, got %q", name)
+	}
+	if !(Snapshot{Name: name}).Auto() {
+		t.Errorf("%s should read as taken by pvec", name)
+	}
+	if (Snapshot{Name: "before-upgrade"}).Auto() {
+		t.Error("A snapshot taken by hand is not pvec's")
+	}
+}
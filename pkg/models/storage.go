@@ -0,0 +1,70 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Content types of storage volumes
+const (
+	ContentISO      = "iso"    // Installation images
+	ContentTemplate = "vztmpl" // Container templates
+)
+
+// Storage is a storage as seen from one node
+type Storage struct {
+	Name    string   `json:"name" yaml:"name"`
+	Node    string   `json:"node" yaml:"node"`
+	Type    string   `json:"type" yaml:"type"`       // Backend, e.g. dir, nfs, cephfs
+	Content []string `json:"content" yaml:"content"` // Content types it accepts
+	Shared  bool     `json:"shared" yaml:"shared"`   // The same volumes are visible from every node
+	Used    int64    `json:"used" yaml:"used"`       // Bytes used; 0 when unknown
+	Total   int64    `json:"total" yaml:"total"`     // Capacity in bytes; 0 when unknown
+}
+
+// Supports reports whether the storage accepts a content type
+func (s Storage) Supports(content string) bool {
+	for _, c := range s.Content {
+		if c == content {
+			return true
+		}
+	}
+	return false
+}
+
+// StorageVolume is a file on a storage, such as an ISO image
+type StorageVolume struct {
+	VolID   string    `json:"volid" yaml:"volid"` // e.g. local:iso/debian-12.iso
+	Node    string    `json:"node" yaml:"node"`
+	Storage string    `json:"storage" yaml:"storage"`
+	Content string    `json:"content" yaml:"content"` // ContentISO or ContentTemplate
+	Format  string    `json:"format" yaml:"format"`
+	Size    int64     `json:"size" yaml:"size"` // Bytes
+	Created time.Time `json:"created" yaml:"created"`
+}
+
+// Name returns the file name part of the volume ID
+func (v StorageVolume) Name() string {
+	name := v.VolID
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// ContentForFile guesses the content type of a file to download from its
+// name: ISO images or container templates. It returns "" for anything else.
+func ContentForFile(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".iso") || strings.HasSuffix(name, ".img"):
+		return ContentISO
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tar.xz") ||
+		strings.HasSuffix(name, ".tar.zst") || strings.HasSuffix(name, ".tgz"):
+		return ContentTemplate
+	}
+	return ""
+}
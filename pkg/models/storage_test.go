@@ -0,0 +1,47 @@
+package models
+
+import "testing"
+
+func TestStorage_Supports(t *testing.T) {
+	s := Storage{Name: "local", Content: []string{"iso", "vztmpl", "backup"}}
+	if !s.Supports(ContentISO) || !s.Supports(ContentTemplate) {
+		t.Error("local should accept ISOs and templates")
+	}
+	if s.Supports("images") {
+		t.Error("local should not accept disk images")
+	}
+}
+
+func TestStorageVolume_Name(t *testing.T) {
+	tests := []struct {
+		volid string
+		want  string
+	}{
+		{"local:iso/debian-12.iso", "debian-12.iso"},
+		{"nfs:vztmpl/debian-12-standard_12.2-1_amd64.tar.zst", "debian-12-standard_12.2-1_amd64.tar.zst"},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		if got := (StorageVolume{VolID: tt.volid}).Name(); got != tt.want {
+			t.Errorf("Name(%q) = %q, want %q", tt.volid, got, tt.want)
+		}
+	}
+}
+
+func TestContentForFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"debian-12.4.0-amd64-netinst.iso", ContentISO},
+		{"Windows.ISO", ContentISO},
+		{"alpine-3.19-default_20240207_amd64.tar.xz", ContentTemplate},
+		{"ubuntu-22.04-standard_22.04-1_amd64.tar.zst", ContentTemplate},
+		{"notes.txt", ""},
+	}
+	for _, tt := range tests {
+		if got := ContentForFile(tt.name); got != tt.want {
+			t.Errorf("ContentForFile(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
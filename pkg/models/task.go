@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Task is a Proxmox worker task, such as a backup or a migration
+type Task struct {
+	UPID      string    `json:"upid" yaml:"upid"` // Unique task ID, used to read and stop the task
+	Node      string    `json:"node" yaml:"node"`
+	Type      string    `json:"type" yaml:"type"`                 // e.g. vzdump, qmigrate, vzstart
+	ID        string    `json:"id,omitempty" yaml:"id,omitempty"` // Object the task works on, usually a VMID; may be empty
+	User      string    `json:"user" yaml:"user"`
+	StartTime time.Time `json:"start_time" yaml:"start_time"`
+}
+
+// Label describes the task as its type and object, e.g. "vzdump 100"
+func (t Task) Label() string {
+	if t.ID == "" {
+		return t.Type
+	}
+	return t.Type + " " + t.ID
+}
+
+// TaskLogLine is one line of a task log, numbered from 1
+type TaskLogLine struct {
+	N    int    `json:"n" yaml:"n"`
+	Text string `json:"text" yaml:"text"`
+}
+
+// TaskStatus tells whether a task is still running and how it ended
+type TaskStatus struct {
+	Running    bool   `json:"running" yaml:"running"`
+	ExitStatus string `json:"exit_status,omitempty" yaml:"exit_status,omitempty"` // "OK" on success, the error otherwise; empty while running
+}
+
+// OK reports whether the task finished successfully
+func (s TaskStatus) OK() bool {
+	return !s.Running && s.ExitStatus == "OK"
+}
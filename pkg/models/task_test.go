@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestTask_Label(t *testing.T) {
+	if got := (Task{Type: "vzdump", ID: "100"}).Label(); got != "vzdump 100" {
+		t.Errorf("Expected \"vzdump 100\", got %q", got)
+	}
+	if got := (Task{Type: "aptupdate"}).Label(); got != "aptupdate" {
+		t.Errorf("A task without an object should show its type alone, got %q", got)
+	}
+}
+
+func TestTaskStatus_OK(t *testing.T) {
+	tests := []struct {
+		status TaskStatus
+		want   bool
+	}{
+		{TaskStatus{Running: true}, false},
+		{TaskStatus{ExitStatus: "OK"}, true},
+		{TaskStatus{ExitStatus: "download failed: 404 Not Found"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.status.OK(); got != tt.want {
+			t.Errorf("%+v.OK() = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
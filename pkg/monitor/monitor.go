@@ -0,0 +1,174 @@
+// Package monitor watches the guests without the TUI: it lists them on the
+// refresh interval and logs their state changes and the refresh errors as
+// structured lines, for pvec running as a service. Under systemd it reports
+// readiness and feeds the watchdog through NOTIFY_SOCKET.
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/clock"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// DefaultInterval is the time between two listings when none is configured
+const DefaultInterval = 5 * time.Second
+
+// DefaultTimeout bounds a listing when no timeout is configured
+const DefaultTimeout = 10 * time.Second
+
+// Lister lists the guests the token can see
+type Lister interface {
+	GetNodes(ctx context.Context) ([]*models.VMStatus, error)
+}
+
+// NewLogger returns a logger writing key=value lines to w. Under
+// journald, which stamps each line itself, the time is left out.
+func NewLogger(w io.Writer, journal bool) *slog.Logger {
+	opts := &slog.HandlerOptions{}
+	if journal {
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		}
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Monitor lists the guests on an interval and reports what changed
+type Monitor struct {
+	Lister         Lister
+	Interval       time.Duration              // Between two listings (default DefaultInterval)
+	Timeout        time.Duration              // Bound of each listing (default DefaultTimeout)
+	Logger         *slog.Logger               // Where the changes and errors go (default slog.Default())
+	OnStateChanges func([]models.StateChange) // Called with the changes of each listing that has some
+	Notifier       *Notifier                  // Told of readiness and the watchdog; nil outside systemd
+	Watchdog       time.Duration              // Watchdog timeout systemd expects pings within; 0 for none
+	Clock          clock.Clock                // Ticks the listings and stamps the changes (default clock.Real)
+
+	guests   []*models.VMStatus // Last listing, nil until one succeeds
+	failures int                // Listings failed in a row
+}
+
+// Run lists the guests until ctx is cancelled, which is a clean stop and
+// returns nil. Systemd is told the service is ready after the first
+// listing, whether it succeeded or not: a server that is down for a while
+// is what the monitor is there to report.
+func (m *Monitor) Run(ctx context.Context) error {
+	if m.Lister == nil {
+		return fmt.Errorf("monitor has no guest lister")
+	}
+	interval := m.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	logger := m.logger()
+	logger.Info("monitor started", "interval", interval)
+
+	m.refresh(ctx)
+	m.notify("READY=1\n" + m.status())
+
+	clk := clock.OrReal(m.Clock)
+	ticker := clk.NewTicker(interval)
+	defer ticker.Stop()
+	// systemd recommends pinging at half the timeout; pinging from this
+	// loop means a stuck monitor stops the pings
+	var watchdog <-chan time.Time
+	if m.Watchdog > 0 {
+		pings := clk.NewTicker(m.Watchdog / 2)
+		defer pings.Stop()
+		watchdog = pings.C()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			m.notify("STOPPING=1")
+			logger.Info("monitor stopped")
+			return nil
+		case <-watchdog:
+			m.notify("WATCHDOG=1")
+		case <-ticker.C():
+			m.refresh(ctx)
+			m.notify(m.status())
+		}
+	}
+}
+
+// refresh lists the guests once and logs what changed since the last
+// listing, or why it failed
+func (m *Monitor) refresh(ctx context.Context) {
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	listCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logger := m.logger()
+	guests, err := m.Lister.GetNodes(listCtx)
+	if err != nil {
+		// Cancelled by the stop, not a failure of the server
+		if ctx.Err() != nil {
+			return
+		}
+		m.failures++
+		logger.Error("refresh failed", "err", err, "failures", m.failures)
+		return
+	}
+	if m.failures > 0 {
+		logger.Info("refresh recovered", "failures", m.failures)
+		m.failures = 0
+	}
+	if m.guests == nil {
+		logger.Info("guests listed", "count", len(guests))
+	}
+
+	changes := models.DetectStateChanges(m.guests, guests, clock.OrReal(m.Clock).Now())
+	for _, c := range changes {
+		attrs := []any{"vmid", c.VMID, "name", c.Name, "type", string(c.Type), "node", c.Node,
+			"old", string(c.OldState), "new", string(c.NewState)}
+		if c.Cluster != "" {
+			attrs = append(attrs, "cluster", c.Cluster)
+		}
+		logger.Info("state change", attrs...)
+	}
+	if len(changes) > 0 && m.OnStateChanges != nil {
+		m.OnStateChanges(changes)
+	}
+	if guests == nil {
+		guests = []*models.VMStatus{}
+	}
+	m.guests = guests
+}
+
+// status returns the STATUS line systemctl status shows for the service
+func (m *Monitor) status() string {
+	switch {
+	case m.failures > 0:
+		return fmt.Sprintf("STATUS=Refresh failing (%d in a row)", m.failures)
+	case m.guests == nil:
+		return "STATUS=Waiting for the first listing"
+	}
+	return fmt.Sprintf("STATUS=Watching %d guests", len(m.guests))
+}
+
+// notify sends state to systemd, logging a failure
+func (m *Monitor) notify(state string) {
+	if err := m.Notifier.Notify(state); err != nil {
+		m.logger().Warn("sd_notify failed", "err", err)
+	}
+}
+
+// logger returns the configured logger or the default one
+func (m *Monitor) logger() *slog.Logger {
+	if m.Logger != nil {
+		return m.Logger
+	}
+	return slog.Default()
+}
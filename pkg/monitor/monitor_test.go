@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/internal/testutil"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// listing is one scripted result of scriptedLister
+type listing struct {
+	guests []*models.VMStatus
+	err    error
+}
+
+// scriptedLister returns its listings in turn, then stops the monitor
+type scriptedLister struct {
+	mu       sync.Mutex
+	listings []listing
+	stop     context.CancelFunc
+}
+
+func (l *scriptedLister) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.listings) == 0 {
+		l.stop()
+		return nil, ctx.Err()
+	}
+	next := l.listings[0]
+	l.listings = l.listings[1:]
+	return next.guests, next.err
+}
+
+func guest(vmid string, status models.NodeState) *models.VMStatus {
+	return &models.VMStatus{VMID: vmid, Name: "guest" + vmid, Type: models.TypeVM, Node: "pve1", Status: status}
+}
+
+// run runs m until it stops, moving clock an interval forward each time
+// the monitor waits for its ticker
+func run(ctx context.Context, m *Monitor, clock *testutil.FakeClock) error {
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+	clock.BlockUntil(1)
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(time.Millisecond):
+			clock.Advance(m.Interval)
+		}
+	}
+}
+
+func TestMonitor_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lister := &scriptedLister{stop: cancel, listings: []listing{
+		{guests: []*models.VMStatus{guest("100", models.StateRunning), guest("101", models.StateStopped)}},
+		{err: errors.New("connection refused")},
+		{err: errors.New("connection refused")},
+		{guests: []*models.VMStatus{guest("100", models.StateStopped), guest("101", models.StateStopped)}},
+	}}
+
+	var out bytes.Buffer
+	var notified [][]models.StateChange
+	clock := testutil.NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	m := &Monitor{
+		Lister:         lister,
+		Interval:       time.Minute,
+		Logger:         NewLogger(&out, true),
+		OnStateChanges: func(c []models.StateChange) { notified = append(notified, c) },
+		Clock:          clock,
+	}
+	require.NoError(t, run(ctx, m, clock))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, []string{
+		`level=INFO msg="monitor started" interval=1m0s`,
+		`level=INFO msg="guests listed" count=2`,
+		`level=ERROR msg="refresh failed" err="connection refused" failures=1`,
+		`level=ERROR msg="refresh failed" err="connection refused" failures=2`,
+		`level=INFO msg="refresh recovered" failures=2`,
+		`level=INFO msg="state change" vmid=100 name=guest100 type=qemu node=pve1 old=running new=stopped`,
+		`level=INFO msg="monitor stopped"`,
+	}, lines)
+
+	require.Len(t, notified, 1)
+	require.Len(t, notified[0], 1)
+	assert.Equal(t, "100", notified[0][0].VMID)
+	// Stamped by the clock, on the fourth listing three ticks in at least
+	assert.False(t, notified[0][0].Time.Before(time.Date(2026, 3, 1, 12, 3, 0, 0, time.UTC)), notified[0][0].Time)
+}
+
+func TestMonitor_FirstListingFails(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lister := &scriptedLister{stop: cancel, listings: []listing{
+		{err: errors.New("status 401")},
+		{guests: []*models.VMStatus{guest("100", models.StateRunning)}},
+	}}
+
+	var out bytes.Buffer
+	m := &Monitor{Lister: lister, Interval: time.Millisecond, Logger: NewLogger(&out, true)}
+	require.NoError(t, m.Run(ctx))
+
+	// No baseline yet, so the first successful listing reports no change
+	assert.Contains(t, out.String(), `msg="refresh recovered" failures=1`)
+	assert.Contains(t, out.String(), `msg="guests listed" count=1`)
+	assert.NotContains(t, out.String(), "state change")
+}
+
+func TestMonitor_Status(t *testing.T) {
+	m := &Monitor{}
+	assert.Equal(t, "STATUS=Waiting for the first listing", m.status())
+	m.guests = []*models.VMStatus{guest("100", models.StateRunning)}
+	assert.Equal(t, "STATUS=Watching 1 guests", m.status())
+	m.failures = 3
+	assert.Equal(t, "STATUS=Refresh failing (3 in a row)", m.status())
+}
+
+func TestMonitor_NoLister(t *testing.T) {
+	assert.Error(t, (&Monitor{}).Run(context.Background()))
+}
+
+func TestNewLogger(t *testing.T) {
+	var out bytes.Buffer
+	NewLogger(&out, false).Info("hello")
+	assert.True(t, strings.HasPrefix(out.String(), "time="), out.String())
+
+	out.Reset()
+	NewLogger(&out, true).Info("hello")
+	assert.Equal(t, "level=INFO msg=hello\n", out.String())
+}
@@ -0,0 +1,61 @@
+package monitor
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// notifyTimeout bounds the sending of a message
+const notifyTimeout = time.Second
+
+// Notifier sends sd_notify messages to the socket systemd names in
+// NOTIFY_SOCKET. A nil Notifier sends nothing, so callers need not check
+// whether they run under systemd.
+type Notifier struct {
+	addr *net.UnixAddr
+}
+
+// NewNotifier returns a notifier for socket, the value of NOTIFY_SOCKET,
+// or nil when it is empty. A name starting with @ is in the abstract
+// namespace, which the net package handles.
+func NewNotifier(socket string) *Notifier {
+	if socket == "" {
+		return nil
+	}
+	return &Notifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+}
+
+// Notify sends state, newline-separated VAR=value assignments such as
+// READY=1, in one datagram
+func (n *Notifier) Notify(state string) error {
+	if n == nil {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	// A systemd too busy to read its queue must not stall the caller
+	if err := conn.SetWriteDeadline(time.Now().Add(notifyTimeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval returns the watchdog timeout systemd set for this
+// process from WATCHDOG_USEC and WATCHDOG_PID, or 0 when there is none or
+// it is meant for another process
+func WatchdogInterval(usec, pid string) time.Duration {
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Microsecond
+}
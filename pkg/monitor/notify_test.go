@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// listenNotify opens a datagram socket standing in for systemd's
+func listenNotify(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("no datagram unix sockets on Windows")
+	}
+	// Socket paths are limited to about 100 bytes, more than t.TempDir takes
+	dir, err := os.MkdirTemp("", "sd")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, path
+}
+
+// receive returns the next datagram sent to conn
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestNotifier(t *testing.T) {
+	conn, path := listenNotify(t)
+
+	n := NewNotifier(path)
+	require.NoError(t, n.Notify("READY=1\nSTATUS=Watching 3 guests"))
+	assert.Equal(t, "READY=1\nSTATUS=Watching 3 guests", receive(t, conn))
+
+	assert.Error(t, NewNotifier(path+".missing").Notify("READY=1"))
+
+	var none *Notifier
+	assert.Nil(t, NewNotifier(""))
+	assert.NoError(t, none.Notify("READY=1"))
+}
+
+func TestMonitor_Notify(t *testing.T) {
+	conn, path := listenNotify(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lister := &scriptedLister{stop: cancel, listings: []listing{
+		{guests: []*models.VMStatus{guest("100", models.StateRunning)}},
+		{err: errors.New("timeout")},
+	}}
+	// Read as the monitor sends, so that its messages don't fill the queue
+	received := make(chan []string)
+	go func() {
+		var got []string
+		buf := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			got = append(got, string(buf[:n]))
+			if string(buf[:n]) == "STOPPING=1" {
+				break
+			}
+		}
+		received <- got
+	}()
+	m := &Monitor{
+		Lister:   lister,
+		Interval: 50 * time.Millisecond,
+		Logger:   NewLogger(&bytes.Buffer{}, true),
+		Notifier: NewNotifier(path),
+		Watchdog: 20 * time.Millisecond,
+	}
+	require.NoError(t, m.Run(ctx))
+
+	got := <-received
+	require.NotEmpty(t, got)
+	assert.Equal(t, "READY=1\nSTATUS=Watching 1 guests", got[0])
+	assert.Equal(t, "STOPPING=1", got[len(got)-1])
+	assert.Contains(t, got, "WATCHDOG=1", "the watchdog is pinged between listings")
+	assert.Contains(t, got, "STATUS=Refresh failing (1 in a row)")
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	self := strconv.Itoa(os.Getpid())
+	assert.Equal(t, 30*time.Second, WatchdogInterval("30000000", ""))
+	assert.Equal(t, 30*time.Second, WatchdogInterval("30000000", self))
+	assert.Zero(t, WatchdogInterval("30000000", "1"), "meant for another process")
+	assert.Zero(t, WatchdogInterval("", ""))
+	assert.Zero(t, WatchdogInterval("soon", ""))
+	assert.Zero(t, WatchdogInterval("0", ""))
+}
@@ -0,0 +1,175 @@
+// Package probe checks that nodes answer on the network, apart from the
+// Proxmox API: a node whose network is degraded can still be listed as
+// online by the pveproxy of another one.
+package probe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// DefaultTimeout bounds each probe
+const DefaultTimeout = time.Second
+
+// Result is the outcome of probing one node
+type Result struct {
+	Node    string
+	Addr    string        // host:port dialed
+	Latency time.Duration // Time to connect; zero when it failed
+	Err     error         // Why the node didn't answer; nil when it did
+	At      time.Time     // When the probe started
+}
+
+// OK reports whether the node answered
+func (r Result) OK() bool {
+	return r.Err == nil
+}
+
+// State is the result as a state of the node, for state changes
+func (r Result) State() models.NodeState {
+	if r.OK() {
+		return models.StateReachable
+	}
+	return models.StateUnreachable
+}
+
+// Dialer opens connections, like net.Dialer.DialContext
+type Dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Prober dials a TCP address of each node, all at once
+type Prober struct {
+	Targets map[string]string // Node name -> host:port
+	Timeout time.Duration     // Bound of each dial; DefaultTimeout when zero
+	Dial    Dialer            // Nil uses a net.Dialer
+	Now     func() time.Time  // Nil uses time.Now
+}
+
+// New returns a prober of the targets, or nil when there are none, so
+// that probing stays off unless configured
+func New(targets map[string]string) *Prober {
+	if len(targets) == 0 {
+		return nil
+	}
+	return &Prober{Targets: targets}
+}
+
+// Run probes every target concurrently and returns the results sorted by
+// node. Cancelling ctx aborts the dials in flight.
+func (p *Prober) Run(ctx context.Context) []Result {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	dial := p.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	now := p.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	results := make([]Result, 0, len(p.Targets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for node, addr := range p.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := probe(ctx, dial, node, addr, timeout, now)
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].Node < results[j].Node })
+	return results
+}
+
+// probe dials one target within timeout
+func probe(ctx context.Context, dial Dialer, node, addr string, timeout time.Duration, now func() time.Time) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r := Result{Node: node, Addr: addr, At: now()}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Latency = max(now().Sub(r.At), time.Microsecond)
+	_ = conn.Close()
+	return r
+}
+
+// Find returns the result of the named node, matched ignoring case as
+// configuration keys are
+func Find(results []Result, node string) (Result, bool) {
+	for _, r := range results {
+		if strings.EqualFold(r.Node, node) {
+			return r, true
+		}
+	}
+	return Result{}, false
+}
+
+// Changes returns the nodes that became reachable or unreachable between
+// two rounds of probes, as state changes of type node. Nodes probed in
+// only one of them are ignored.
+func Changes(prev, curr []Result, at time.Time) []models.StateChange {
+	var changes []models.StateChange
+	for _, r := range curr {
+		old, ok := Find(prev, r.Node)
+		if !ok || old.OK() == r.OK() {
+			continue
+		}
+		changes = append(changes, models.StateChange{
+			Time:     at,
+			Name:     r.Addr,
+			Type:     models.TypeNode,
+			Node:     r.Node,
+			OldState: old.State(),
+			NewState: r.State(),
+		})
+	}
+	return changes
+}
+
+// Text renders a result for display: the latency, such as "12ms", or
+// why the node didn't answer
+func Text(r Result) string {
+	if r.OK() {
+		return latencyText(r.Latency)
+	}
+	return "unreachable: " + reason(r.Err)
+}
+
+// latencyText rounds a latency to what is worth reading
+func latencyText(d time.Duration) string {
+	if d < time.Millisecond {
+		return "<1ms"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// reason shortens a dial error to its cause, such as "connection
+// refused" or "timeout"
+func reason(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	text := err.Error()
+	if i := strings.LastIndex(text, ": "); i >= 0 {
+		text = text[i+2:]
+	}
+	return text
+}
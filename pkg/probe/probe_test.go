@@ -0,0 +1,117 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestNew(t *testing.T) {
+	assert.Nil(t, New(nil), "Probing is off without targets")
+	assert.NotNil(t, New(map[string]string{"pve1": "10.0.0.1:22"}))
+}
+
+func TestProber_Run(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	// A port nothing listens on any more
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	p := New(map[string]string{"pve2": closedAddr, "pve1": listener.Addr().String()})
+	results := p.Run(context.Background())
+
+	require.Len(t, results, 2)
+	assert.Equal(t, "pve1", results[0].Node, "Sorted by node")
+	assert.True(t, results[0].OK())
+	assert.Positive(t, results[0].Latency)
+	assert.Equal(t, "pve2", results[1].Node)
+	assert.False(t, results[1].OK())
+	assert.Equal(t, "unreachable: connection refused", Text(results[1]))
+}
+
+func TestProber_Run_Concurrent(t *testing.T) {
+	// Every dial hangs until its timeout; run one after the other, three
+	// would take three times as long
+	p := &Prober{
+		Targets: map[string]string{"a": "a:22", "b": "b:22", "c": "c:22"},
+		Timeout: 50 * time.Millisecond,
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	start := time.Now()
+	results := p.Run(context.Background())
+	assert.Less(t, time.Since(start), 140*time.Millisecond)
+	for _, r := range results {
+		assert.Equal(t, "unreachable: timeout", Text(r))
+	}
+}
+
+func TestProber_Run_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Prober{
+		Targets: map[string]string{"pve1": "10.0.0.1:22"},
+		Timeout: time.Minute,
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	done := make(chan []Result)
+	go func() { done <- p.Run(ctx) }()
+	select {
+	case results := <-done:
+		assert.ErrorIs(t, results[0].Err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Cancelling should abort the dial")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	at := time.Date(2026, 10, 17, 14, 2, 0, 0, time.UTC)
+	refused := errors.New("dial tcp 10.0.0.2:22: connect: connection refused")
+	prev := []Result{
+		{Node: "pve1", Addr: "10.0.0.1:22"},
+		{Node: "pve2", Addr: "10.0.0.2:22"},
+		{Node: "pve3", Addr: "10.0.0.3:22", Err: refused},
+	}
+	curr := []Result{
+		{Node: "pve1", Addr: "10.0.0.1:22"},
+		{Node: "pve2", Addr: "10.0.0.2:22", Err: refused},
+		{Node: "pve3", Addr: "10.0.0.3:22"},
+		{Node: "pve4", Addr: "10.0.0.4:22", Err: refused},
+	}
+
+	changes := Changes(prev, curr, at)
+	require.Len(t, changes, 2, "Unchanged and newly probed nodes are left out")
+	assert.Equal(t, models.StateChange{Time: at, Name: "10.0.0.2:22", Type: models.TypeNode, Node: "pve2",
+		OldState: models.StateReachable, NewState: models.StateUnreachable}, changes[0])
+	assert.Equal(t, models.StateReachable, changes[1].NewState)
+}
+
+func TestText(t *testing.T) {
+	assert.Equal(t, "12ms", Text(Result{Latency: 12345 * time.Microsecond}))
+	assert.Equal(t, "<1ms", Text(Result{Latency: 300 * time.Microsecond}))
+	assert.Equal(t, "unreachable: no route to host", Text(Result{Err: errors.New("dial tcp 10.0.0.9:22: connect: no route to host")}))
+}
+
+func TestFind(t *testing.T) {
+	results := []Result{{Node: "pve1", Addr: "10.0.0.1:22"}}
+	r, ok := Find(results, "PVE1")
+	assert.True(t, ok, "Node names match ignoring case")
+	assert.Equal(t, "10.0.0.1:22", r.Addr)
+	_, ok = Find(results, "pve2")
+	assert.False(t, ok)
+}
@@ -0,0 +1,251 @@
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"golang.org/x/sync/errgroup"
+)
+
+// Cluster is one of the clusters an Aggregate lists
+type Cluster struct {
+	Name   string // Identifies the cluster in guest keys and the Cluster column
+	Client Client
+}
+
+// ClusterHealth reports the clusters that failed the last refresh of an
+// aggregate list
+type ClusterHealth interface {
+	// ClusterErrors returns the error of each cluster whose last GetNodes
+	// failed, by cluster name; nil when all answered
+	ClusterErrors() map[string]error
+}
+
+// Aggregate merges the guests of several clusters into one list. Each
+// guest gets its cluster's name in Cluster, and every call taking a vmid
+// expects the guest's Key, "cluster/vmid", to pick the client to use.
+//
+// It implements Client only: the node, task, storage, permission,
+// cloud-init and lock features are per cluster and stay unavailable.
+type Aggregate struct {
+	clusters []Cluster
+	byName   map[string]Client
+
+	mu     sync.Mutex
+	last   map[string][]*models.VMStatus // Guests each cluster last reported
+	errors map[string]error              // Errors of the last GetNodes
+}
+
+// NewAggregate creates a client over clusters, whose names must be
+// distinct and free of "/"
+func NewAggregate(clusters []Cluster) (*Aggregate, error) {
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no clusters given")
+	}
+	byName := make(map[string]Client, len(clusters))
+	for _, c := range clusters {
+		if c.Name == "" || strings.Contains(c.Name, "/") {
+			return nil, fmt.Errorf("invalid cluster name %q", c.Name)
+		}
+		if _, exists := byName[c.Name]; exists {
+			return nil, fmt.Errorf("duplicate cluster name %q", c.Name)
+		}
+		byName[c.Name] = c.Client
+	}
+	return &Aggregate{
+		clusters: clusters,
+		byName:   byName,
+		last:     make(map[string][]*models.VMStatus),
+	}, nil
+}
+
+// GetNodes lists the guests of every cluster concurrently. A cluster that
+// fails keeps the guests it last reported, marked Unreachable, and its
+// error is kept for ClusterErrors; GetNodes itself only fails when every
+// cluster does.
+func (a *Aggregate) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	results := make([][]*models.VMStatus, len(a.clusters))
+	errs := make([]error, len(a.clusters))
+	// A plain errgroup, so that one cluster failing doesn't cancel the others
+	var g errgroup.Group
+	for i, c := range a.clusters {
+		g.Go(func() error {
+			results[i], errs[i] = c.Client.GetNodes(ctx)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.errors = nil
+	var all []*models.VMStatus
+	for i, c := range a.clusters {
+		if errs[i] != nil {
+			if a.errors == nil {
+				a.errors = make(map[string]error)
+			}
+			a.errors[c.Name] = errs[i]
+			for _, vm := range a.last[c.Name] {
+				stale := *vm
+				stale.Unreachable = true
+				all = append(all, &stale)
+			}
+			continue
+		}
+		for _, vm := range results[i] {
+			vm.Cluster = c.Name
+		}
+		a.last[c.Name] = results[i]
+		all = append(all, results[i]...)
+	}
+	if len(a.errors) == len(a.clusters) {
+		return nil, a.joinedErrors()
+	}
+	return all, nil
+}
+
+// joinedErrors combines the cluster errors, in cluster order
+func (a *Aggregate) joinedErrors() error {
+	var errs []error
+	for _, c := range a.clusters {
+		if err, ok := a.errors[c.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ClusterErrors returns the error of each cluster whose last GetNodes
+// failed
+func (a *Aggregate) ClusterErrors() map[string]error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.errors) == 0 {
+		return nil
+	}
+	errs := make(map[string]error, len(a.errors))
+	for name, err := range a.errors {
+		errs[name] = err
+	}
+	return errs
+}
+
+// Clients returns the power controller of each cluster by name, for
+// ActionExecutor
+func (a *Aggregate) Clients() map[string]PowerController {
+	clients := make(map[string]PowerController, len(a.byName))
+	for name, client := range a.byName {
+		clients[name] = client
+	}
+	return clients
+}
+
+// route returns the client of the cluster a guest key names, and the
+// guest's VMID within it
+func (a *Aggregate) route(key string) (Client, string, error) {
+	cluster, vmid := models.SplitKey(key)
+	client, ok := a.byName[cluster]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: no cluster %q for guest %s", ErrNodeNotFound, cluster, key)
+	}
+	return client, vmid, nil
+}
+
+// GetGuestStatus retrieves the current status of a guest by its key
+func (a *Aggregate) GetGuestStatus(ctx context.Context, node, vmType, key string) (*models.VMStatus, error) {
+	client, vmid, err := a.route(key)
+	if err != nil {
+		return nil, err
+	}
+	guest, err := client.GetGuestStatus(ctx, node, vmType, vmid)
+	if err != nil {
+		return nil, err
+	}
+	guest.Cluster, _ = models.SplitKey(key)
+	return guest, nil
+}
+
+// GetVMConfig retrieves the configuration of a guest by its key
+func (a *Aggregate) GetVMConfig(ctx context.Context, node, vmType, key string) (map[string]interface{}, error) {
+	client, vmid, err := a.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetVMConfig(ctx, node, vmType, vmid)
+}
+
+// GetAgentFSInfo retrieves filesystem usage of a VM by its key
+func (a *Aggregate) GetAgentFSInfo(ctx context.Context, node, key string) ([]models.Filesystem, error) {
+	client, vmid, err := a.route(key)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetAgentFSInfo(ctx, node, vmid)
+}
+
+// GetAgentHostName retrieves the own hostname of a VM by its key
+func (a *Aggregate) GetAgentHostName(ctx context.Context, node, key string) (string, error) {
+	client, vmid, err := a.route(key)
+	if err != nil {
+		return "", err
+	}
+	return client.GetAgentHostName(ctx, node, vmid)
+}
+
+// PingAgent checks that the guest agent of a VM by its key answers
+func (a *Aggregate) PingAgent(ctx context.Context, node, key string) error {
+	client, vmid, err := a.route(key)
+	if err != nil {
+		return err
+	}
+	return client.PingAgent(ctx, node, vmid)
+}
+
+// power runs a power action on the cluster of the guest key
+func (a *Aggregate) power(key string, action func(client Client, vmid string) error) error {
+	client, vmid, err := a.route(key)
+	if err != nil {
+		return err
+	}
+	return action(client, vmid)
+}
+
+// Start starts a guest by its key
+func (a *Aggregate) Start(ctx context.Context, node, vmType, key string) error {
+	return a.power(key, func(client Client, vmid string) error {
+		return client.Start(ctx, node, vmType, vmid)
+	})
+}
+
+// Shutdown gracefully shuts down a guest by its key
+func (a *Aggregate) Shutdown(ctx context.Context, node, vmType, key string) error {
+	return a.power(key, func(client Client, vmid string) error {
+		return client.Shutdown(ctx, node, vmType, vmid)
+	})
+}
+
+// Reboot reboots a guest by its key
+func (a *Aggregate) Reboot(ctx context.Context, node, vmType, key string) error {
+	return a.power(key, func(client Client, vmid string) error {
+		return client.Reboot(ctx, node, vmType, vmid)
+	})
+}
+
+// Stop forcefully stops a guest by its key
+func (a *Aggregate) Stop(ctx context.Context, node, vmType, key string) error {
+	return a.power(key, func(client Client, vmid string) error {
+		return client.Stop(ctx, node, vmType, vmid)
+	})
+}
+
+// Resume resumes a paused guest by its key
+func (a *Aggregate) Resume(ctx context.Context, node, vmType, key string) error {
+	return a.power(key, func(client Client, vmid string) error {
+		return client.Resume(ctx, node, vmType, vmid)
+	})
+}
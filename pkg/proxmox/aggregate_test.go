@@ -0,0 +1,131 @@
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// clusterClient is a MockClient listing fixed guests, or failing
+type clusterClient struct {
+	MockClient
+	guests  []*models.VMStatus
+	err     error
+	started []string
+}
+
+func (c *clusterClient) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	guests := make([]*models.VMStatus, len(c.guests))
+	for i, g := range c.guests {
+		copied := *g
+		guests[i] = &copied
+	}
+	return guests, nil
+}
+
+func (c *clusterClient) GetGuestStatus(ctx context.Context, node, vmType, vmid string) (*models.VMStatus, error) {
+	return &models.VMStatus{VMID: vmid, Node: node}, nil
+}
+
+func (c *clusterClient) Start(ctx context.Context, node, vmType, vmid string) error {
+	c.started = append(c.started, vmid)
+	return nil
+}
+
+// twoClusters returns an aggregate of two clusters that both have a
+// guest 100
+func twoClusters(t *testing.T) (*Aggregate, *clusterClient, *clusterClient) {
+	t.Helper()
+	lab := &clusterClient{guests: []*models.VMStatus{{VMID: "100", Name: "lab-web", Node: "pve1", Status: models.StateRunning}}}
+	prod := &clusterClient{guests: []*models.VMStatus{
+		{VMID: "100", Name: "prod-web", Node: "node-a", Status: models.StateRunning},
+		{VMID: "101", Name: "prod-db", Node: "node-b", Status: models.StateStopped},
+	}}
+	a, err := NewAggregate([]Cluster{{Name: "lab", Client: lab}, {Name: "prod", Client: prod}})
+	require.NoError(t, err)
+	return a, lab, prod
+}
+
+func TestNewAggregate_Invalid(t *testing.T) {
+	_, err := NewAggregate(nil)
+	assert.Error(t, err)
+	_, err = NewAggregate([]Cluster{{Name: "a/b", Client: &MockClient{}}})
+	assert.ErrorContains(t, err, `invalid cluster name "a/b"`)
+	_, err = NewAggregate([]Cluster{{Name: "a", Client: &MockClient{}}, {Name: "a", Client: &MockClient{}}})
+	assert.ErrorContains(t, err, `duplicate cluster name "a"`)
+}
+
+func TestAggregate_GetNodes(t *testing.T) {
+	a, _, _ := twoClusters(t)
+
+	guests, err := a.GetNodes(context.Background())
+	require.NoError(t, err)
+
+	keys := make([]string, len(guests))
+	for i, g := range guests {
+		keys[i] = g.Key()
+	}
+	assert.Equal(t, []string{"lab/100", "prod/100", "prod/101"}, keys)
+	assert.Nil(t, a.ClusterErrors())
+}
+
+func TestAggregate_GetNodes_ClusterDown(t *testing.T) {
+	a, lab, _ := twoClusters(t)
+	_, err := a.GetNodes(context.Background())
+	require.NoError(t, err)
+
+	down := errors.New("connection refused")
+	lab.err = down
+	guests, err := a.GetNodes(context.Background())
+
+	require.NoError(t, err, "One cluster down doesn't fail the list")
+	require.Len(t, guests, 3)
+	assert.Equal(t, "lab/100", guests[0].Key())
+	assert.True(t, guests[0].Unreachable, "The last known guests of the cluster are kept, marked")
+	assert.Equal(t, models.StateRunning, guests[0].Status)
+	assert.False(t, guests[1].Unreachable)
+	assert.Equal(t, map[string]error{"lab": down}, a.ClusterErrors())
+
+	lab.err = nil
+	guests, err = a.GetNodes(context.Background())
+	require.NoError(t, err)
+	assert.False(t, guests[0].Unreachable)
+	assert.Nil(t, a.ClusterErrors(), "A cluster that answers again is cleared")
+}
+
+func TestAggregate_GetNodes_AllDown(t *testing.T) {
+	a, lab, prod := twoClusters(t)
+	lab.err = errors.New("timeout")
+	prod.err = ErrUnauthorized
+
+	_, err := a.GetNodes(context.Background())
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Contains(t, err.Error(), "lab: timeout")
+}
+
+func TestAggregate_Routing(t *testing.T) {
+	a, lab, prod := twoClusters(t)
+	ctx := context.Background()
+
+	require.NoError(t, a.Start(ctx, "node-a", "qemu", "prod/100"))
+	assert.Equal(t, []string{"100"}, prod.started)
+	assert.Empty(t, lab.started)
+
+	guest, err := a.GetGuestStatus(ctx, "pve1", "qemu", "lab/100")
+	require.NoError(t, err)
+	assert.Equal(t, "lab/100", guest.Key())
+
+	err = a.Start(ctx, "pve1", "qemu", "100")
+	assert.ErrorIs(t, err, ErrNodeNotFound, "A key without a cluster names none")
+	err = a.Start(ctx, "pve1", "qemu", "test/100")
+	assert.ErrorIs(t, err, ErrNodeNotFound)
+}
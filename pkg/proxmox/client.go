@@ -1,24 +1,52 @@
+// Package proxmox is the Proxmox VE API client behind pvec. Programs that
+// only need to list and control guests should use pkg/pvecclient, whose API
+// is kept stable; this package also carries adapters for the UI.
 package proxmox
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/tsupplis/pvec/pkg/actions"
 	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/version"
+	"golang.org/x/sync/errgroup"
 )
 
-// Client is the interface for Proxmox API operations
-type Client interface {
+// StatusReader lists guests and reports their current status
+type StatusReader interface {
 	// GetNodes retrieves all VMs and Containers
 	GetNodes(ctx context.Context) ([]*models.VMStatus, error)
+	// GetGuestStatus retrieves the current status of a single VM or Container
+	GetGuestStatus(ctx context.Context, node, vmType, vmid string) (*models.VMStatus, error)
+}
+
+// ConfigReader fetches the configuration and guest agent data of a guest
+type ConfigReader interface {
 	// GetVMConfig retrieves detailed configuration for a VM or Container
 	GetVMConfig(ctx context.Context, node, vmType, vmid string) (map[string]interface{}, error)
+	// GetAgentFSInfo retrieves filesystem usage from a VM's QEMU guest agent
+	GetAgentFSInfo(ctx context.Context, node, vmid string) ([]models.Filesystem, error)
+	// GetAgentHostName retrieves a VM's own hostname from its QEMU guest agent
+	GetAgentHostName(ctx context.Context, node, vmid string) (string, error)
+	// PingAgent checks that a VM's QEMU guest agent answers
+	PingAgent(ctx context.Context, node, vmid string) error
+}
+
+// PowerController changes the power state of a guest
+type PowerController interface {
 	// Start starts a VM or Container
 	Start(ctx context.Context, node, vmType, vmid string) error
 	// Shutdown gracefully shuts down a VM or Container
@@ -27,33 +55,255 @@ type Client interface {
 	Reboot(ctx context.Context, node, vmType, vmid string) error
 	// Stop forcefully stops a VM or Container
 	Stop(ctx context.Context, node, vmType, vmid string) error
+	// Resume resumes a paused VM or Container
+	Resume(ctx context.Context, node, vmType, vmid string) error
 }
 
+// NodePowerController reboots or shuts down a Proxmox node itself, along
+// with every guest running on it
+type NodePowerController interface {
+	// NodeReboot reboots a node
+	NodeReboot(ctx context.Context, node string) error
+	// NodeShutdown shuts a node down
+	NodeShutdown(ctx context.Context, node string) error
+	// WakeNode has another cluster member send a wake-on-LAN packet to an
+	// offline node and returns the MAC address it targeted
+	WakeNode(ctx context.Context, node string) (string, error)
+}
+
+// TaskManager follows the worker tasks running on a node
+type TaskManager interface {
+	// GetRunningTasks lists the tasks currently running on a node
+	GetRunningTasks(ctx context.Context, node string) ([]models.Task, error)
+	// GetTaskLog returns the lines of a task log from line start on,
+	// counting from 0, so a log can be read incrementally
+	GetTaskLog(ctx context.Context, node, upid string, start int) ([]models.TaskLogLine, error)
+	// StopTask stops a running task
+	StopTask(ctx context.Context, node, upid string) error
+	// GetTaskStatus tells whether a task still runs and how it ended
+	GetTaskStatus(ctx context.Context, node, upid string) (models.TaskStatus, error)
+}
+
+// StorageManager browses and manages the ISO images and container
+// templates kept on the storages of a node
+type StorageManager interface {
+	// ListStorages lists the enabled storages of a node
+	ListStorages(ctx context.Context, node string) ([]models.Storage, error)
+	// GetStorageContent lists the volumes of one content type on a storage
+	GetStorageContent(ctx context.Context, node, storage, contentType string) ([]models.StorageVolume, error)
+	// DeleteVolume removes a volume from a storage
+	DeleteVolume(ctx context.Context, node, storage, volid string) error
+	// DownloadURL has the node download a file onto a storage and returns
+	// the UPID of the download task
+	DownloadURL(ctx context.Context, node, storage, contentType, fileURL, filename string) (string, error)
+}
+
+// PermissionReader reads the privileges of the authenticated token
+type PermissionReader interface {
+	// GetPermissions returns the token's effective privileges by ACL path
+	GetPermissions(ctx context.Context) (models.Permissions, error)
+}
+
+// CloudInitManager regenerates the cloud-init image of a VM
+type CloudInitManager interface {
+	// RegenerateCloudInit rebuilds the image from the VM's cloud-init
+	// settings; drive and storage locate the cloud-init drive
+	RegenerateCloudInit(ctx context.Context, node, vmid, drive, storage string) error
+}
+
+// LockManager clears the lock of a guest, like qm unlock. A lock left by
+// a task that died blocks every action on the guest until it is cleared.
+type LockManager interface {
+	// ClearLock removes the guest's lock, whatever set it
+	ClearLock(ctx context.Context, node, vmType, vmid string) error
+}
+
+// Hibernator suspends VMs to disk. Unlike a pause, a hibernated VM
+// survives its node rebooting; starting it again resumes it from disk.
+type Hibernator interface {
+	// Hibernate saves a VM's memory to disk and powers it off
+	Hibernate(ctx context.Context, node, vmid string) error
+}
+
+// HAManager puts guests under HA and sets the state HA keeps them in.
+// Resources are named by their SID, e.g. vm:100; see models.HASID.
+type HAManager interface {
+	// GetHAGroups lists the HA groups a resource can be put in
+	GetHAGroups(ctx context.Context) ([]models.HAGroup, error)
+	// AddHAResource puts a guest under HA, in group unless it is ""
+	AddHAResource(ctx context.Context, sid, group string) error
+	// RemoveHAResource takes a guest out of HA, leaving it as it is
+	RemoveHAResource(ctx context.Context, sid string) error
+	// SetHARequestedState asks HA to keep a guest started, stopped or
+	// disabled
+	SetHARequestedState(ctx context.Context, sid, state string) error
+}
+
+// GuestOrganizer files guests under tags and resource pools
+type GuestOrganizer interface {
+	// SetTags replaces the tags of a guest
+	SetTags(ctx context.Context, node, vmType, vmid string, tags []string) error
+	// AddToPool adds guests to a resource pool, moving them out of the
+	// pool they were in
+	AddToPool(ctx context.Context, pool string, vmids []string) error
+}
+
+// SerialConsole reads the serial console of a VM, for guests that don't
+// boot far enough to be reached otherwise
+type SerialConsole interface {
+	// OpenSerialConsole connects read-only to a serial port of a VM, such
+	// as serial0, and returns what the guest writes to it from then on;
+	// closing the stream disconnects
+	OpenSerialConsole(ctx context.Context, node, vmid, serial string) (io.ReadCloser, error)
+}
+
+// SnapshotManager takes, lists and removes the snapshots of a guest.
+// Taking or removing one runs a task, which GetTaskStatus follows.
+type SnapshotManager interface {
+	// SnapshotSupported reports whether the guest's disks all sit on
+	// storages that can take snapshots
+	SnapshotSupported(ctx context.Context, node, vmType, vmid string) (bool, error)
+	// ListSnapshots lists the snapshots of a guest, oldest first
+	ListSnapshots(ctx context.Context, node, vmType, vmid string) ([]models.Snapshot, error)
+	// CreateSnapshot snapshots the guest's disks, not its RAM, and
+	// returns the UPID of the snapshot task
+	CreateSnapshot(ctx context.Context, node, vmType, vmid, name, description string) (string, error)
+	// DeleteSnapshot removes a snapshot and returns the UPID of its task
+	DeleteSnapshot(ctx context.Context, node, vmType, vmid, name string) (string, error)
+	// GetTaskStatus tells whether a task still runs and how it ended
+	GetTaskStatus(ctx context.Context, node, upid string) (models.TaskStatus, error)
+}
+
+// CloneManager clones guests under a free VMID and starts the clones.
+// Cloning runs a task, which GetTaskStatus follows.
+type CloneManager interface {
+	// NextVMID returns the lowest VMID free in the cluster
+	NextVMID(ctx context.Context) (string, error)
+	// CloneGuest clones a guest on its node and returns the UPID of the
+	// clone task
+	CloneGuest(ctx context.Context, node, vmType, vmid string, spec actions.CloneSpec) (string, error)
+	// GetTaskStatus tells whether a task still runs and how it ended
+	GetTaskStatus(ctx context.Context, node, upid string) (models.TaskStatus, error)
+	// Start starts a VM or Container
+	Start(ctx context.Context, node, vmType, vmid string) error
+}
+
+// Reader is a read-only backend: everything but power actions
+type Reader interface {
+	StatusReader
+	ConfigReader
+}
+
+// Client is the interface for Proxmox API operations
+type Client interface {
+	Reader
+	PowerController
+}
+
+// defaultRequestTimeout bounds requests whose context has no deadline.
+// Callers that need longer, like slow power actions, set their own.
+const defaultRequestTimeout = 30 * time.Second
+
 // HTTPClient is the HTTP implementation of the Proxmox client
 type HTTPClient struct {
-	baseURL    string
-	authToken  string
-	httpClient *http.Client
+	baseURL        string
+	authToken      string
+	httpClient     *http.Client
+	requestTimeout time.Duration
+	// unfiltered is set once the server rejected the type filter of
+	// cluster/resources, which is then asked for every resource
+	unfiltered atomic.Bool
+	observer   RequestObserver // Told of every request; nil when none
+	// serverCaps is what the server's release offers, once read
+	serverCaps atomic.Pointer[ServerCapabilities]
+}
+
+// ClientOptions configures NewHTTPClient
+type ClientOptions struct {
+	BaseURL     string // Server URL with its port, e.g. https://pve.example.com:8006
+	TokenID     string // API token ID, e.g. root@pam!pvec
+	TokenSecret string // API token secret
+	// SkipTLSVerify accepts any server certificate, such as the
+	// self-signed one Proxmox installs by default
+	SkipTLSVerify bool
+	// TLSFingerprint accepts only the server certificate with this SHA-256
+	// fingerprint, e.g. AB:CD:…, whoever signed it. It takes precedence
+	// over SkipTLSVerify.
+	TLSFingerprint string
+	// Transport replaces the default HTTP transport, for proxies or tests.
+	// SkipTLSVerify and TLSFingerprint do not apply to it.
+	Transport http.RoundTripper
+	// RequestTimeout bounds requests whose context has no deadline
+	// (default 30s)
+	RequestTimeout time.Duration
+	// Observer is told of every request, with its secrets masked
+	Observer RequestObserver
 }
 
-// NewClient creates a new Proxmox HTTP client
+// NewClient creates a new Proxmox HTTP client. It records every exchange
+// when PVEC_RECORD is set; see RecordEnv.
 func NewClient(baseURL, authToken string, skipTLSVerify bool) Client {
-	transport := &http.Transport{
+	transport := DefaultTransport(skipTLSVerify)
+	if dir := os.Getenv(RecordEnv); dir != "" {
+		transport = NewRecordingTransport(transport, dir)
+	}
+	return newHTTPClient(baseURL, authToken, transport, 0)
+}
+
+// NewHTTPClient creates a client from options. Unlike NewClient it returns
+// the concrete type, which also implements NodePowerController and
+// TaskManager.
+func NewHTTPClient(opts ClientOptions) (*HTTPClient, error) {
+	u, err := url.Parse(opts.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", opts.BaseURL)
+	}
+	if opts.TokenID == "" || opts.TokenSecret == "" {
+		return nil, fmt.Errorf("token ID and secret are required")
+	}
+	if opts.RequestTimeout < 0 {
+		return nil, fmt.Errorf("request timeout must not be negative")
+	}
+	if opts.TLSFingerprint != "" && NormalizeFingerprint(opts.TLSFingerprint) == "" {
+		return nil, fmt.Errorf("invalid TLS fingerprint %q: expected a SHA-256 in hex", opts.TLSFingerprint)
+	}
+
+	transport := opts.Transport
+	if transport == nil {
+		transport = DefaultTransport(opts.SkipTLSVerify)
+		if opts.TLSFingerprint != "" {
+			transport = PinnedTransport(opts.TLSFingerprint)
+		}
+	}
+	authToken := fmt.Sprintf("PVEAPIToken=%s=%s", opts.TokenID, opts.TokenSecret)
+	c := newHTTPClient(strings.TrimSuffix(opts.BaseURL, "/"), authToken, transport, opts.RequestTimeout)
+	c.observer = opts.Observer
+	return c, nil
+}
+
+// newHTTPClient creates a client; a zero timeout means defaultRequestTimeout
+func newHTTPClient(baseURL, authToken string, transport http.RoundTripper, timeout time.Duration) *HTTPClient {
+	if timeout == 0 {
+		timeout = defaultRequestTimeout
+	}
+	return &HTTPClient{
+		baseURL:        baseURL,
+		authToken:      authToken,
+		httpClient:     &http.Client{Transport: transport},
+		requestTimeout: timeout,
+	}
+}
+
+// DefaultTransport returns the transport a client uses unless one is
+// given, for wrappers that decorate it
+func DefaultTransport(skipTLSVerify bool) http.RoundTripper {
+	return &http.Transport{
 		TLSClientConfig: &tls.Config{
 			// #nosec G402 - InsecureSkipVerify is intentional for Proxmox self-signed certificates
 			// This is a user-configurable option and users are warned in documentation
 			InsecureSkipVerify: skipTLSVerify,
 		},
 	}
-
-	return &HTTPClient{
-		baseURL:   baseURL,
-		authToken: authToken,
-		httpClient: &http.Client{
-			Timeout:   30 * time.Second,
-			Transport: transport,
-		},
-	}
 }
 
 // doRequest performs an HTTP request with authentication
@@ -67,13 +317,9 @@ func (c *HTTPClient) doRequest(ctx context.Context, method, path string, body io
 
 	req.Header.Set("Authorization", c.authToken)
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-
-	return resp, nil
+	return c.do(req)
 }
 
 // doRequestForm performs an HTTP request with form-encoded content type
@@ -87,71 +333,320 @@ func (c *HTTPClient) doRequestForm(ctx context.Context, method, path string, bod
 
 	req.Header.Set("Authorization", c.authToken)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	return c.do(req)
+}
+
+// do sends a request, applying the request timeout when its context has
+// no deadline. The timeout is released once the response body is closed.
+func (c *HTTPClient) do(req *http.Request) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if _, ok := req.Context().Deadline(); !ok {
+		timeout := c.requestTimeout
+		if timeout == 0 {
+			timeout = defaultRequestTimeout
+		}
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), timeout)
+		req = req.WithContext(ctx)
+	}
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
+	c.observe(req, resp, err, start)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
-
+	resp.Body = &cancelOnClose{ReadCloser: c.countBody(resp.Body), cancel: cancel}
 	return resp, nil
 }
 
+// cancelOnClose releases a request context when the response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 // proxmoxResponse represents a generic Proxmox API response
 type proxmoxResponse struct {
 	Data json.RawMessage `json:"data"`
 }
 
+// flexVMID decodes a vmid sent either as a JSON number or as a quoted string
+type flexVMID string
+
+// UnmarshalJSON accepts 100, "100" and null
+func (v *flexVMID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*v = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		s = strings.TrimSpace(s)
+		if _, err := strconv.Atoi(s); s != "" && err != nil {
+			return fmt.Errorf("invalid vmid %q", s)
+		}
+		*v = flexVMID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid vmid %s", string(data))
+	}
+	*v = flexVMID(n.String())
+	return nil
+}
+
 // clusterResource represents a resource from the cluster/resources endpoint
 type clusterResource struct {
-	ID        string      `json:"id"`
-	VMID      json.Number `json:"vmid"`
-	Name      string      `json:"name"`
-	Type      string      `json:"type"`
-	Status    string      `json:"status"`
-	Node      string      `json:"node"`
-	CPU       float64     `json:"cpu"`
-	Mem       int64       `json:"mem"`
-	MaxMem    int64       `json:"maxmem"`
-	MaxCPU    int         `json:"maxcpu"`
-	Uptime    int64       `json:"uptime"`
-	DiskRead  int64       `json:"diskread"`
-	DiskWrite int64       `json:"diskwrite"`
+	ID        string   `json:"id"`
+	VMID      flexVMID `json:"vmid"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Status    string   `json:"status"`
+	Node      string   `json:"node"`
+	CPU       float64  `json:"cpu"`
+	Mem       *int64   `json:"mem"`     // nil when omitted or null (PVE 6.x)
+	MaxMem    *int64   `json:"maxmem"`  // nil when omitted or null
+	MaxCPU    *int     `json:"maxcpu"`  // nil when omitted or null
+	Uptime    *int64   `json:"uptime"`  // nil when omitted or null
+	Disk      *int64   `json:"disk"`    // Real usage for containers only
+	MaxDisk   *int64   `json:"maxdisk"` // nil when omitted or null
+	DiskRead  int64    `json:"diskread"`
+	DiskWrite int64    `json:"diskwrite"`
+	Lock      string   `json:"lock"`      // e.g. suspended for a hibernated VM
+	QMPStatus string   `json:"qmpstatus"` // QEMU's own state, from status/current only
+	HAState   string   `json:"hastate"`   // Requested HA state; empty when not HA-managed
+	Pool      string   `json:"pool"`      // Resource pool, from cluster/resources only
 }
 
+// guestResourcesPath asks cluster/resources for guests only: on a big
+// cluster the node and storage entries would be most of the response.
+// A server that ignores the filter answers with everything, which
+// decodeClusterResources skips.
+const guestResourcesPath = "/cluster/resources?type=vm"
+
 // GetNodes retrieves all VMs and Containers from all nodes using cluster resources
 func (c *HTTPClient) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
-	// Use cluster/resources endpoint for more accurate data
-	resp, err := c.doRequest(ctx, "GET", "/cluster/resources", nil)
+	path := guestResourcesPath
+	if c.unfiltered.Load() {
+		path = "/cluster/resources"
+	}
+	resp, err := c.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusBadRequest && path == guestResourcesPath {
+		// The filter was rejected rather than ignored: do without it
+		c.unfiltered.Store(true)
+		return c.GetNodes(ctx)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get cluster resources: status %d", resp.StatusCode)
+		// Privileges are granted by path, whatever the query
+		return nil, fmt.Errorf("failed to get cluster resources: %w", newAPIError(resp, "GET", "/cluster/resources"))
 	}
 
-	var resourcesResp proxmoxResponse
-	if err := json.NewDecoder(resp.Body).Decode(&resourcesResp); err != nil {
-		return nil, fmt.Errorf("failed to decode cluster resources response: %w", err)
+	guests, err := c.decodeClusterResources(resp.Body)
+	if err != nil {
+		return nil, err
 	}
+	c.probePaused(ctx, guests)
+	return guests, nil
+}
 
-	var resources []clusterResource
-	if err := json.Unmarshal(resourcesResp.Data, &resources); err != nil {
-		return nil, fmt.Errorf("failed to parse cluster resources data: %w", err)
+// pausedProbeConcurrency is how many status/current reads probePaused
+// makes at once
+const pausedProbeConcurrency = 4
+
+// probePaused tells paused VMs from running ones. cluster/resources
+// reports a paused VM as running and only status/current carries its
+// qmpstatus, so it is read for the running VMs that use no CPU, as paused
+// ones don't, and as ones QEMU never started or stopped on an error don't
+// either; their QEMU state is kept. A failed read leaves the guest as
+// listed.
+func (c *HTTPClient) probePaused(ctx context.Context, guests []*models.VMStatus) {
+	var g errgroup.Group
+	g.SetLimit(pausedProbeConcurrency)
+	for _, guest := range guests {
+		if guest.Type != models.TypeVM || guest.Status != models.StateRunning || guest.CPUUsage > 0 {
+			continue
+		}
+		g.Go(func() error {
+			current, err := c.GetGuestStatus(ctx, guest.Node, guest.TypeString(), guest.VMID)
+			if err != nil {
+				return nil
+			}
+			if current.Status == models.StatePaused {
+				guest.Status = models.StatePaused
+			}
+			guest.QEMU = current.QEMU
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
+
+// ParseClusterResources decodes a /cluster/resources response body into
+// guests, skipping nodes, storage and malformed entries. It lets captured
+// API responses be replayed without a server.
+func ParseClusterResources(body []byte) ([]*models.VMStatus, error) {
+	var c HTTPClient
+	return c.decodeClusterResources(bytes.NewReader(body))
+}
+
+// decodeClusterResources reads the guests out of a /cluster/resources
+// response. The data array is streamed rather than read whole, and its
+// entries decoded one at a time so a single malformed resource doesn't
+// take the whole list down with it.
+func (c *HTTPClient) decodeClusterResources(body io.Reader) ([]*models.VMStatus, error) {
+	dec := json.NewDecoder(body)
+	found, err := openDataArray(dec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cluster resources response: %w", err)
+	}
+	if !found {
+		return nil, nil
 	}
 
 	var allVMs []*models.VMStatus
-	for _, resource := range resources {
+	for dec.More() {
+		var resource clusterResource
+		offset := dec.InputOffset()
+		if err := dec.Decode(&resource); err != nil {
+			// An entry that couldn't even be read leaves the decoder
+			// where it was: the response is cut short or isn't JSON
+			if dec.InputOffset() == offset {
+				return nil, fmt.Errorf("failed to parse cluster resources data: %w", err)
+			}
+			log.Printf("skipping cluster resource %q: %v", resource.ID, err)
+			continue
+		}
 		if resource.Type == "qemu" || resource.Type == "lxc" {
 			vmStatus := c.createVMStatusFromClusterResource(resource)
 			allVMs = append(allVMs, vmStatus)
 		}
 	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster resources data: %w", err)
+	}
 
 	return allVMs, nil
 }
 
+// openDataArray advances dec to the first entry of the data array of an
+// API response. It reports false when data is null.
+func openDataArray(dec *json.Decoder) (bool, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if tok != json.Delim('{') {
+		return false, fmt.Errorf("expected an object, got %v", tok)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return false, err
+		}
+		if key != "data" {
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return false, err
+			}
+			continue
+		}
+		switch tok, err := dec.Token(); {
+		case err != nil:
+			return false, err
+		case tok == nil:
+			return false, nil
+		case tok != json.Delim('['):
+			return false, fmt.Errorf("expected a data array, got %v", tok)
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("no data in response")
+}
+
+// guestStatus represents the response of the status/current endpoint,
+// which reports the CPU count as cpus rather than maxcpu
+type guestStatus struct {
+	clusterResource
+	CPUs        *int            `json:"cpus"`
+	Balloon     *int64          `json:"balloon"`     // Target memory, when the VM has a balloon device
+	BalloonInfo json.RawMessage `json:"ballooninfo"` // Memory statistics, when its driver reports them
+}
+
+// qemuHealth returns what the status of a running VM tells of its QEMU
+// process, nil for containers and stopped VMs
+func (s guestStatus) qemuHealth() *models.QEMUHealth {
+	if s.Type != "qemu" || s.Status != "running" {
+		return nil
+	}
+	health := &models.QEMUHealth{QMPStatus: s.QMPStatus, Balloon: models.BalloonNone}
+	switch {
+	case len(s.BalloonInfo) > 0 && string(s.BalloonInfo) != "null":
+		health.Balloon = models.BalloonReported
+	case s.Balloon != nil:
+		health.Balloon = models.BalloonSilent
+	}
+	return health
+}
+
+// GetGuestStatus retrieves the current status of a single VM or Container
+func (c *HTTPClient) GetGuestStatus(ctx context.Context, node, vmType, vmid string) (*models.VMStatus, error) {
+	path := fmt.Sprintf("/nodes/%s/%s/%s/status/current", node, vmType, vmid)
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get status for %s %s: %w", vmType, vmid, newAPIError(resp, "GET", path))
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var status guestStatus
+	if err := json.Unmarshal(apiResp.Data, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse status for %s %s: %w", vmType, vmid, err)
+	}
+
+	// The endpoint doesn't echo back where the guest lives
+	res := status.clusterResource
+	res.Type = vmType
+	res.Node = node
+	if res.VMID == "" {
+		res.VMID = flexVMID(vmid)
+	}
+	if res.MaxCPU == nil {
+		res.MaxCPU = status.CPUs
+	}
+	status.clusterResource = res
+
+	guest := c.createVMStatusFromClusterResource(res)
+	guest.QEMU = status.qemuHealth()
+	return guest, nil
+}
+
 // GetVMConfig retrieves detailed configuration for a VM or Container
 func (c *HTTPClient) GetVMConfig(ctx context.Context, node, vmType, vmid string) (map[string]interface{}, error) {
 	path := fmt.Sprintf("/nodes/%s/%s/%s/config", node, vmType, vmid)
@@ -162,8 +657,7 @@ func (c *HTTPClient) GetVMConfig(ctx context.Context, node, vmType, vmid string)
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get config for %s %s (status %d): %s", vmType, vmid, resp.StatusCode, string(body))
+		return nil, fmt.Errorf("failed to get config for %s %s: %w", vmType, vmid, newAPIError(resp, "GET", path))
 	}
 
 	var apiResp proxmoxResponse
@@ -179,12 +673,118 @@ func (c *HTTPClient) GetVMConfig(ctx context.Context, node, vmType, vmid string)
 	return config, nil
 }
 
-// mapResourceStatus maps Proxmox status strings to our model states
-func (c *HTTPClient) mapResourceStatus(status string) models.NodeState {
-	switch status {
+// PingAgent checks that a VM's QEMU guest agent answers, failing when it
+// isn't running in the guest
+func (c *HTTPClient) PingAgent(ctx context.Context, node, vmid string) error {
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/agent/ping", node, vmid)
+	resp, err := c.doRequest(ctx, "POST", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to ping the agent of qemu %s: %w", vmid, newAPIError(resp, "POST", path))
+	}
+	return nil
+}
+
+// agentFSInfo represents one entry of the agent/get-fsinfo result
+type agentFSInfo struct {
+	Name       string `json:"name"`
+	Mountpoint string `json:"mountpoint"`
+	Type       string `json:"type"`
+	UsedBytes  int64  `json:"used-bytes"`
+	TotalBytes int64  `json:"total-bytes"`
+}
+
+// GetAgentFSInfo retrieves filesystem usage from a VM's QEMU guest agent.
+// Pseudo-filesystems (tmpfs, proc, ...) are left out.
+func (c *HTTPClient) GetAgentFSInfo(ctx context.Context, node, vmid string) ([]models.Filesystem, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/agent/get-fsinfo", node, vmid)
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get filesystems for qemu %s: %w", vmid, newAPIError(resp, "GET", path))
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var result struct {
+		Result []agentFSInfo `json:"result"`
+	}
+	if err := json.Unmarshal(apiResp.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse filesystems for qemu %s: %w", vmid, err)
+	}
+
+	var filesystems []models.Filesystem
+	for _, info := range result.Result {
+		fs := models.Filesystem{
+			Name:       info.Name,
+			Mountpoint: info.Mountpoint,
+			Type:       info.Type,
+			UsedBytes:  info.UsedBytes,
+			TotalBytes: info.TotalBytes,
+		}
+		if !fs.IsPseudo() {
+			filesystems = append(filesystems, fs)
+		}
+	}
+	return filesystems, nil
+}
+
+// GetAgentHostName retrieves the hostname the guest OS of a VM gives
+// itself, from its QEMU guest agent
+func (c *HTTPClient) GetAgentHostName(ctx context.Context, node, vmid string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/agent/get-host-name", node, vmid)
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get hostname of qemu %s: %w", vmid, newAPIError(resp, "GET", path))
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var result struct {
+		Result struct {
+			HostName string `json:"host-name"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(apiResp.Data, &result); err != nil {
+		return "", fmt.Errorf("failed to parse hostname of qemu %s: %w", vmid, err)
+	}
+	return result.Result.HostName, nil
+}
+
+// mapResourceStatus maps the status of a resource to our model states.
+// A paused VM reports status running with qmpstatus paused, or suspended
+// when the guest put itself to sleep; a hibernated one reports status
+// stopped with the suspended lock.
+func (c *HTTPClient) mapResourceStatus(res clusterResource) models.NodeState {
+	switch res.Status {
 	case "running":
+		if res.QMPStatus == "paused" || res.QMPStatus == "suspended" {
+			return models.StatePaused
+		}
 		return models.StateRunning
 	case "stopped":
+		if res.Lock == "suspended" {
+			return models.StateHibernated
+		}
 		return models.StateStopped
 	case "paused":
 		return models.StatePaused
@@ -193,38 +793,72 @@ func (c *HTTPClient) mapResourceStatus(status string) models.NodeState {
 	}
 }
 
-// calculateMemoryPercentage calculates memory usage percentage
-func (c *HTTPClient) calculateMemoryPercentage(mem, maxMem int64) float64 {
-	if maxMem > 0 {
-		return (float64(mem) / float64(maxMem)) * 100
+// calculateMemoryPercentage calculates memory usage percentage, returning 0
+// when either value is unknown or the maximum is zero
+func (c *HTTPClient) calculateMemoryPercentage(mem, maxMem *int64) float64 {
+	if mem != nil && maxMem != nil && *maxMem > 0 {
+		return (float64(*mem) / float64(*maxMem)) * 100
 	}
 	return 0.0
 }
 
+// derefInt64 returns the pointed-to value, flagging the metric as missing when nil
+func derefInt64(p *int64, m models.Metric, missing *models.Metric) int64 {
+	if p == nil {
+		*missing |= m
+		return 0
+	}
+	return *p
+}
+
 // createVMStatusFromClusterResource creates a VMStatus from a cluster resource
 func (c *HTTPClient) createVMStatusFromClusterResource(res clusterResource) *models.VMStatus {
-	vmid := res.VMID.String()
+	vmid := string(res.VMID)
+	vmidNum, _ := strconv.Atoi(vmid)
 
 	nodeType := models.TypeVM
 	if res.Type == "lxc" {
 		nodeType = models.TypeContainer
 	}
 
-	status := c.mapResourceStatus(res.Status)
+	status := c.mapResourceStatus(res)
 	cpuPercent := res.CPU * 100
 	memPercent := c.calculateMemoryPercentage(res.Mem, res.MaxMem)
 
+	var missing models.Metric
+	derefInt64(res.Mem, models.MetricMem, &missing)
+	maxMem := derefInt64(res.MaxMem, models.MetricMaxMem, &missing)
+	uptime := derefInt64(res.Uptime, models.MetricUptime, &missing)
+	disk := derefInt64(res.Disk, models.MetricDisk, &missing)
+	maxDisk := derefInt64(res.MaxDisk, models.MetricMaxDisk, &missing)
+	maxCPU := 0
+	if res.MaxCPU != nil {
+		maxCPU = *res.MaxCPU
+	} else {
+		missing |= models.MetricMaxCPU
+	}
+	if status == models.StateUnknown {
+		missing |= models.StaleMetrics
+	}
+
 	return &models.VMStatus{
 		VMID:        vmid,
+		VMIDNum:     vmidNum,
 		Name:        res.Name,
-		Type:        string(nodeType),
-		Status:      string(status),
+		Type:        nodeType,
+		Status:      status,
 		Node:        res.Node,
 		CPUUsage:    cpuPercent,
 		MemoryUsage: memPercent,
-		MaxMem:      res.MaxMem,
-		MaxCPU:      res.MaxCPU,
-		Uptime:      res.Uptime,
+		MaxMem:      maxMem,
+		MaxCPU:      maxCPU,
+		Uptime:      uptime,
+		Disk:        disk,
+		MaxDisk:     maxDisk,
+		Missing:     missing,
+		Lock:        res.Lock,
+		HAState:     res.HAState,
+		Pool:        res.Pool,
 	}
 }
 
@@ -241,8 +875,7 @@ func (c *HTTPClient) Start(ctx context.Context, node, vmType, vmid string) error
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to start %s %s (status %d): %s", vmType, vmid, resp.StatusCode, string(body))
+		return fmt.Errorf("failed to start %s %s: %w", vmType, vmid, newAPIError(resp, "POST", path))
 	}
 
 	return nil
@@ -260,8 +893,7 @@ func (c *HTTPClient) Shutdown(ctx context.Context, node, vmType, vmid string) er
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to shutdown %s %s (status %d): %s", vmType, vmid, resp.StatusCode, string(body))
+		return fmt.Errorf("failed to shutdown %s %s: %w", vmType, vmid, newAPIError(resp, "POST", path))
 	}
 
 	return nil
@@ -279,8 +911,7 @@ func (c *HTTPClient) Reboot(ctx context.Context, node, vmType, vmid string) erro
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to reboot %s %s (status %d): %s", vmType, vmid, resp.StatusCode, string(body))
+		return fmt.Errorf("failed to reboot %s %s: %w", vmType, vmid, newAPIError(resp, "POST", path))
 	}
 
 	return nil
@@ -298,8 +929,232 @@ func (c *HTTPClient) Stop(ctx context.Context, node, vmType, vmid string) error
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to stop %s %s (status %d): %s", vmType, vmid, resp.StatusCode, string(body))
+		return fmt.Errorf("failed to stop %s %s: %w", vmType, vmid, newAPIError(resp, "POST", path))
+	}
+
+	return nil
+}
+
+// Resume resumes a paused VM or Container
+func (c *HTTPClient) Resume(ctx context.Context, node, vmType, vmid string) error {
+	path := fmt.Sprintf("/nodes/%s/%s/%s/status/resume", node, vmType, vmid)
+
+	body := strings.NewReader("")
+	resp, err := c.doRequestForm(ctx, "POST", path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to resume %s %s: %w", vmType, vmid, newAPIError(resp, "POST", path))
+	}
+
+	return nil
+}
+
+// NodeReboot reboots a Proxmox node
+func (c *HTTPClient) NodeReboot(ctx context.Context, node string) error {
+	return c.nodeCommand(ctx, node, "reboot")
+}
+
+// NodeShutdown shuts a Proxmox node down
+func (c *HTTPClient) NodeShutdown(ctx context.Context, node string) error {
+	return c.nodeCommand(ctx, node, "shutdown")
+}
+
+// nodeCommand posts a power command to a node's status endpoint
+func (c *HTTPClient) nodeCommand(ctx context.Context, node, command string) error {
+	path := fmt.Sprintf("/nodes/%s/status", node)
+
+	body := strings.NewReader(url.Values{"command": {command}}.Encode())
+	resp, err := c.doRequestForm(ctx, "POST", path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to %s node %s: %w", command, node, newAPIError(resp, "POST", path))
+	}
+
+	return nil
+}
+
+// WakeNode asks the cluster to wake an offline node and returns the MAC
+// address the magic packet was sent to
+func (c *HTTPClient) WakeNode(ctx context.Context, node string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/wakeonlan", node)
+
+	resp, err := c.doRequestForm(ctx, "POST", path, strings.NewReader(""))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := newAPIError(resp, "POST", path)
+		if isNoWakeOnLAN(apiErr) {
+			return "", fmt.Errorf("failed to wake node %s: %w: %w", node, ErrNoWakeOnLAN, apiErr)
+		}
+		return "", fmt.Errorf("failed to wake node %s: %w", node, apiErr)
+	}
+
+	var result proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	var mac string
+	if err := json.Unmarshal(result.Data, &mac); err != nil {
+		return "", fmt.Errorf("failed to decode MAC address: %w", err)
+	}
+	return mac, nil
+}
+
+// isNoWakeOnLAN reports whether a failed wakeonlan call means the node has
+// no MAC configured. Depending on the version Proxmox answers 404, 501 or
+// a 500 that says so.
+func isNoWakeOnLAN(err *APIError) bool {
+	switch err.StatusCode {
+	case http.StatusNotFound, http.StatusNotImplemented:
+		return true
+	}
+	body := strings.ToLower(err.Body)
+	return strings.Contains(body, "wake on lan") || strings.Contains(body, "wakeonlan")
+}
+
+// taskLogLimit is the number of log lines fetched per request. The API
+// returns 50 by default, too few to catch up with a chatty task.
+const taskLogLimit = 500
+
+// nodeTask represents one entry of the node tasks endpoint
+type nodeTask struct {
+	UPID      string `json:"upid"`
+	Node      string `json:"node"`
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	User      string `json:"user"`
+	StartTime int64  `json:"starttime"`
+}
+
+// GetRunningTasks lists the tasks currently running on a node
+func (c *HTTPClient) GetRunningTasks(ctx context.Context, node string) ([]models.Task, error) {
+	path := fmt.Sprintf("/nodes/%s/tasks?source=active", node)
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get tasks of node %s: %w", node, newAPIError(resp, "GET", path))
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var entries []nodeTask
+	if err := json.Unmarshal(apiResp.Data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse tasks of node %s: %w", node, err)
+	}
+
+	tasks := make([]models.Task, 0, len(entries))
+	for _, e := range entries {
+		if e.Node == "" {
+			e.Node = node
+		}
+		tasks = append(tasks, models.Task{
+			UPID:      e.UPID,
+			Node:      e.Node,
+			Type:      e.Type,
+			ID:        e.ID,
+			User:      e.User,
+			StartTime: time.Unix(e.StartTime, 0),
+		})
+	}
+	return tasks, nil
+}
+
+// GetTaskLog returns the lines of a task log from line start on
+func (c *HTTPClient) GetTaskLog(ctx context.Context, node, upid string, start int) ([]models.TaskLogLine, error) {
+	path := fmt.Sprintf("/nodes/%s/tasks/%s/log?start=%d&limit=%d", node, url.PathEscape(upid), start, taskLogLimit)
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get task log: %w", newAPIError(resp, "GET", path))
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var entries []struct {
+		N    int    `json:"n"`
+		Text string `json:"t"`
+	}
+	if err := json.Unmarshal(apiResp.Data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse task log: %w", err)
+	}
+
+	// A task that hasn't logged anything yet reads as a single
+	// "no content" line, which must not count as the first line
+	if start == 0 && len(entries) == 1 && entries[0].Text == "no content" {
+		return nil, nil
+	}
+
+	lines := make([]models.TaskLogLine, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, models.TaskLogLine{N: e.N, Text: e.Text})
+	}
+	return lines, nil
+}
+
+// GetTaskStatus tells whether a task still runs and how it ended
+func (c *HTTPClient) GetTaskStatus(ctx context.Context, node, upid string) (models.TaskStatus, error) {
+	path := fmt.Sprintf("/nodes/%s/tasks/%s/status", node, url.PathEscape(upid))
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return models.TaskStatus{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.TaskStatus{}, fmt.Errorf("failed to get task status: %w", newAPIError(resp, "GET", path))
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return models.TaskStatus{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var status struct {
+		Status     string `json:"status"`
+		ExitStatus string `json:"exitstatus"`
+	}
+	if err := json.Unmarshal(apiResp.Data, &status); err != nil {
+		return models.TaskStatus{}, fmt.Errorf("failed to parse task status: %w", err)
+	}
+	return models.TaskStatus{Running: status.Status == "running", ExitStatus: status.ExitStatus}, nil
+}
+
+// StopTask stops a running task
+func (c *HTTPClient) StopTask(ctx context.Context, node, upid string) error {
+	path := fmt.Sprintf("/nodes/%s/tasks/%s", node, url.PathEscape(upid))
+	resp, err := c.doRequest(ctx, "DELETE", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to stop task: %w", newAPIError(resp, "DELETE", path))
 	}
 
 	return nil
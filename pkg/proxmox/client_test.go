@@ -3,188 +3,767 @@ package proxmox
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/proxmoxtest"
+	"github.com/tsupplis/pvec/pkg/version"
 )
 
-func setupMockServer(_ *testing.T) (*httptest.Server, *HTTPClient) {
-	mux := http.NewServeMux()
+// replayClient returns a client answering from the exchanges in
+// testdata/<fixtures>, written by hand after PVE 8 responses; see
+// RecordEnv to capture real ones
+func replayClient(t *testing.T, fixtures string) *HTTPClient {
+	t.Helper()
+	replay, err := LoadReplayTransport(filepath.Join("testdata", fixtures))
+	require.NoError(t, err)
+	return &HTTPClient{
+		baseURL:    "https://pve.test:8006",
+		authToken:  "PVEAPIToken=root@pam!pvec=00000000-0000-0000-0000-000000000000",
+		httpClient: &http.Client{Transport: replay},
+	}
+}
 
-	// Mock cluster/resources endpoint
-	mux.HandleFunc("/api2/json/cluster/resources", func(w http.ResponseWriter, r *http.Request) {
-		if r.Header.Get("Authorization") != "test-token" {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
+func TestHTTPClient_GetNodes(t *testing.T) {
+	client := replayClient(t, "pve8")
 
-		resp := map[string]interface{}{
-			"data": []map[string]interface{}{
-				{
-					"id":        "qemu/100",
-					"vmid":      100,
-					"name":      "test-vm",
-					"type":      "qemu",
-					"status":    "running",
-					"node":      "pve1",
-					"cpu":       0.25,
-					"mem":       2147483648,
-					"maxmem":    4294967296,
-					"maxcpu":    2,
-					"uptime":    3600,
-					"diskread":  1024,
-					"diskwrite": 2048,
-				},
-				{
-					"id":        "lxc/200",
-					"vmid":      200,
-					"name":      "test-ct",
-					"type":      "lxc",
-					"status":    "stopped",
-					"node":      "pve1",
-					"cpu":       0.0,
-					"mem":       0,
-					"maxmem":    1073741824,
-					"maxcpu":    1,
-					"uptime":    0,
-					"diskread":  0,
-					"diskwrite": 0,
-				},
-			},
-		}
-		_ = json.NewEncoder(w).Encode(resp)
-	})
+	nodes, err := client.GetNodes(context.Background())
+	require.NoError(t, err)
 
-	// Mock start endpoint
-	mux.HandleFunc("/api2/json/nodes/pve1/qemu/100/status/start", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
-		resp := map[string]interface{}{
-			"data": "OK",
-		}
-		_ = json.NewEncoder(w).Encode(resp)
-	})
+	// Should have 2 resources (1 VM + 1 CT)
+	assert.Len(t, nodes, 2)
+
+	// Check VM
+	vm := findNodeByID(nodes, "100")
+	require.NotNil(t, vm)
+	assert.Equal(t, "test-vm", vm.Name)
+	assert.Equal(t, models.TypeVM, vm.Type)
+	assert.Equal(t, models.StateRunning, vm.Status)
+	assert.Equal(t, "pve1", vm.Node)
+	assert.Equal(t, 100, vm.VMIDNum)
+	assert.Equal(t, 25.0, vm.CPUUsage)
+	assert.InDelta(t, 50.0, vm.MemoryUsage, 0.1)
+
+	// Check Container
+	ct := findNodeByID(nodes, "200")
+	require.NotNil(t, ct)
+	assert.Equal(t, "test-ct", ct.Name)
+	assert.Equal(t, models.TypeContainer, ct.Type)
+	assert.Equal(t, models.StateStopped, ct.Status)
+	assert.Equal(t, "pve1", ct.Node)
+}
+
+func TestHTTPClient_GetNodes_MixedVMIDTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[
+			{"id":"qemu/100","vmid":100,"name":"numeric","type":"qemu","status":"running","node":"pve1"},
+			{"id":"lxc/101","vmid":"101","name":"quoted","type":"lxc","status":"stopped","node":"pve1"},
+			{"id":"qemu/102","vmid":"not-a-number","name":"bad-string","type":"qemu","status":"running","node":"pve1"},
+			{"id":"qemu/103","vmid":{"x":1},"name":"bad-object","type":"qemu","status":"running","node":"pve1"},
+			{"id":"qemu/104","vmid":104,"name":"bad-cpu","type":"qemu","status":"running","node":"pve1","cpu":"high"},
+			{"id":"node/pve1","vmid":null,"type":"node","status":"online","node":"pve1"},
+			{"id":"storage/pve1/local","type":"storage","status":"available","node":"pve1"}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-token", true)
+	nodes, err := client.GetNodes(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, nodes, 2)
+	numeric := findNodeByID(nodes, "100")
+	require.NotNil(t, numeric)
+	assert.Equal(t, 100, numeric.VMIDNum)
+	quoted := findNodeByID(nodes, "101")
+	require.NotNil(t, quoted)
+	assert.Equal(t, "quoted", quoted.Name)
+	assert.Equal(t, 101, quoted.VMIDNum)
+}
+
+// serveFixture returns a server answering every request with a testdata file
+func serveFixture(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(data)
+	}))
+}
+
+func TestHTTPClient_GetNodes_PVE64MissingFields(t *testing.T) {
+	server := serveFixture(t, "cluster_resources_pve64.json")
+	defer server.Close()
+
+	nodes, err := NewClient(server.URL, "test-token", true).GetNodes(context.Background())
+	require.NoError(t, err)
+	require.Len(t, nodes, 3)
+
+	running := findNodeByID(nodes, "100")
+	require.NotNil(t, running)
+	assert.Zero(t, running.Missing)
+	assert.True(t, running.HasMemoryUsage())
+	assert.InDelta(t, 70.0, running.MemoryUsage, 0.1)
+
+	// Stopped container: no maxcpu/uptime keys and mem is null
+	ct := findNodeByID(nodes, "101")
+	require.NotNil(t, ct)
+	assert.False(t, ct.IsKnown(models.MetricMaxCPU))
+	assert.False(t, ct.IsKnown(models.MetricUptime))
+	assert.False(t, ct.IsKnown(models.MetricMem))
+	assert.True(t, ct.IsKnown(models.MetricMaxMem))
+	assert.False(t, ct.HasMemoryUsage())
+	assert.Equal(t, 0.0, ct.MemoryUsage)
+
+	// Stopped VM: no maxcpu/uptime keys but mem is reported as 0
+	vm := findNodeByID(nodes, "102")
+	require.NotNil(t, vm)
+	assert.False(t, vm.IsKnown(models.MetricMaxCPU))
+	assert.False(t, vm.IsKnown(models.MetricUptime))
+	assert.True(t, vm.HasMemoryUsage())
+}
+
+func TestParseClusterResources(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "cluster_resources_pve8.json"))
+	require.NoError(t, err)
 
-	// Mock shutdown endpoint
-	mux.HandleFunc("/api2/json/nodes/pve1/qemu/100/status/shutdown", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+	nodes, err := ParseClusterResources(data)
+	require.NoError(t, err)
+	require.Len(t, nodes, 3, "node entries should be skipped")
+	assert.NotNil(t, findNodeByID(nodes, "104"))
+
+	_, err = ParseClusterResources([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestParseClusterResources_Stream(t *testing.T) {
+	// Members around data are skipped, and a null data lists nothing
+	nodes, err := ParseClusterResources([]byte(`{"errors":{"x":[1]},"data":[{"id":"qemu/100","vmid":100,"type":"qemu","status":"running","node":"pve1"}],"total":1}`))
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1)
+	nodes, err = ParseClusterResources([]byte(`{"data":null}`))
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	// A response cut short fails rather than listing what came before
+	_, err = ParseClusterResources([]byte(`{"data":[{"id":"qemu/100","vmid":100,"type":"qemu"},{"id":"qemu/101","vm`))
+	assert.Error(t, err)
+	_, err = ParseClusterResources([]byte(`{"data":{}}`))
+	assert.Error(t, err)
+	_, err = ParseClusterResources([]byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestHTTPClient_GetNodes_TypeFilter(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "cluster_resources_pve8.json"))
+	require.NoError(t, err)
+	var mu sync.Mutex
+	var queries []string
+	rejectFilter := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, r.URL.RawQuery)
+		if rejectFilter && r.URL.RawQuery != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":{"type":"value 'vm' does not have a value in the enumeration"},"data":null}`))
 			return
 		}
-		resp := map[string]interface{}{
-			"data": "OK",
-		}
-		_ = json.NewEncoder(w).Encode(resp)
-	})
+		// Answer with every resource, as a server ignoring the filter would
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
 
-	// Mock reboot endpoint
-	mux.HandleFunc("/api2/json/nodes/pve1/qemu/100/status/reboot", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
+	client := NewClient(server.URL, "test-token", true)
+	nodes, err := client.GetNodes(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, nodes, 3, "node entries should be skipped when the filter is ignored")
+	assert.Equal(t, []string{"type=vm"}, queries)
+
+	// A server rejecting the filter is asked without it, from then on
+	rejectFilter, queries = true, nil
+	client = NewClient(server.URL, "test-token", true)
+	for i := 0; i < 2; i++ {
+		nodes, err = client.GetNodes(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, nodes, 3)
+	}
+	assert.Equal(t, []string{"type=vm", "", ""}, queries)
+}
+
+// generatedResources returns a cluster/resources body listing guests
+// spread over 20 nodes, along with the entries of those nodes and of 20
+// storages each unless guestsOnly is set
+func generatedResources(guests int, guestsOnly bool) []byte {
+	var entries []string
+	for i := 0; i < guests; i++ {
+		kind := "qemu"
+		if i%3 == 0 {
+			kind = "lxc"
 		}
-		resp := map[string]interface{}{
-			"data": "OK",
+		entries = append(entries, fmt.Sprintf(`{"id":"%s/%d","vmid":%d,"name":"guest-%d","type":"%s","status":"running","node":"pve%d",`+
+			`"cpu":0.0125,"maxcpu":4,"mem":2147483648,"maxmem":4294967296,"disk":0,"maxdisk":34359738368,`+
+			`"diskread":123456789,"diskwrite":987654321,"netin":5242880,"netout":1048576,"uptime":86400,"template":0,"tags":"web;prod"}`,
+			kind, 1000+i, 1000+i, i, kind, i%20))
+	}
+	for n := 0; n < 20 && !guestsOnly; n++ {
+		entries = append(entries, fmt.Sprintf(`{"id":"node/pve%d","type":"node","node":"pve%d","status":"online","level":"","cgroup-mode":2,`+
+			`"cpu":0.0612,"maxcpu":64,"mem":21474836480,"maxmem":274877906944,"disk":9663676416,"maxdisk":100861726720,"uptime":1209600}`, n, n))
+		for st := 0; st < 20; st++ {
+			entries = append(entries, fmt.Sprintf(`{"id":"storage/pve%d/store-%d","type":"storage","storage":"store-%d","node":"pve%d",`+
+				`"status":"available","plugintype":"rbd","content":"rootdir,images","shared":1,"disk":107374182400,"maxdisk":858993459200}`, n, st, st, n))
 		}
-		_ = json.NewEncoder(w).Encode(resp)
-	})
+	}
+	return []byte(`{"data":[` + strings.Join(entries, ",") + `]}`)
+}
 
-	// Mock stop endpoint
-	mux.HandleFunc("/api2/json/nodes/pve1/qemu/100/status/stop", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+// benchmarkGetNodes lists 1000 guests from a server that honours the
+// type filter, or from one that answers with every resource
+func benchmarkGetNodes(b *testing.B, filtered bool) {
+	all, guests := generatedResources(1000, false), generatedResources(1000, true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filtered && r.URL.Query().Get("type") == "vm" {
+			_, _ = w.Write(guests)
 			return
 		}
-		resp := map[string]interface{}{
-			"data": "OK",
+		_, _ = w.Write(all)
+	}))
+	b.Cleanup(server.Close)
+	client := NewClient(server.URL, "token", true)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nodes, err := client.GetNodes(context.Background())
+		if err != nil || len(nodes) != 1000 {
+			b.Fatalf("got %d guests: %v", len(nodes), err)
 		}
-		_ = json.NewEncoder(w).Encode(resp)
-	})
+	}
+}
 
-	server := httptest.NewServer(mux)
-	client := NewClient(server.URL, "test-token", true).(*HTTPClient)
+func BenchmarkGetNodes_Filtered(b *testing.B) { benchmarkGetNodes(b, true) }
 
-	return server, client
-}
+func BenchmarkGetNodes_Unfiltered(b *testing.B) { benchmarkGetNodes(b, false) }
 
-func TestHTTPClient_GetNodes(t *testing.T) {
-	server, client := setupMockServer(t)
+func TestHTTPClient_GetNodes_PVE8(t *testing.T) {
+	server := serveFixture(t, "cluster_resources_pve8.json")
 	defer server.Close()
 
-	nodes, err := client.GetNodes(context.Background())
+	nodes, err := NewClient(server.URL, "test-token", true).GetNodes(context.Background())
 	require.NoError(t, err)
+	require.Len(t, nodes, 3)
 
-	// Should have 2 resources (1 VM + 1 CT)
-	assert.Len(t, nodes, 2)
+	for _, node := range nodes {
+		assert.Zero(t, node.Missing, "all metrics should be known for %s", node.VMID)
+	}
 
-	// Check VM
-	vm := findNodeByID(nodes, "100")
+	ct := findNodeByID(nodes, "200")
+	require.NotNil(t, ct)
+	assert.True(t, ct.HasDiskUsage())
+	assert.Equal(t, int64(1073741824), ct.Disk)
+	assert.InDelta(t, 12.5, ct.DiskUsage(), 0.01)
+
+	web := findNodeByID(nodes, "104")
+	require.NotNil(t, web)
+	assert.False(t, web.HasDiskUsage(), "VM disk usage is meaningless without the agent")
+	assert.Equal(t, 4, web.MaxCPU)
+	assert.Equal(t, int64(86400), web.Uptime)
+	assert.InDelta(t, 37.5, web.MemoryUsage, 0.1)
+}
+
+func TestHTTPClient_CalculateMemoryPercentage(t *testing.T) {
+	c := &HTTPClient{}
+	zero, half, full := int64(0), int64(512), int64(1024)
+
+	assert.Equal(t, 50.0, c.calculateMemoryPercentage(&half, &full))
+	assert.Equal(t, 0.0, c.calculateMemoryPercentage(&half, &zero))
+	assert.Equal(t, 0.0, c.calculateMemoryPercentage(nil, &full))
+	assert.Equal(t, 0.0, c.calculateMemoryPercentage(&half, nil))
+}
+
+func TestFlexVMID_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{`100`, "100", false},
+		{`"100"`, "100", false},
+		{`" 100 "`, "100", false},
+		{`null`, "", false},
+		{`""`, "", false},
+		{`"abc"`, "", true},
+		{`true`, "", true},
+		{`[1]`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var v flexVMID
+			err := json.Unmarshal([]byte(tt.input), &v)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(v))
+		})
+	}
+}
+
+func TestHTTPClient_GetGuestStatus(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	vm, err := client.GetGuestStatus(context.Background(), "pve1", "qemu", "100")
+	require.NoError(t, err)
 	require.NotNil(t, vm)
+
+	assert.Equal(t, "100", vm.VMID)
+	assert.Equal(t, 100, vm.VMIDNum)
 	assert.Equal(t, "test-vm", vm.Name)
-	assert.Equal(t, string(models.TypeVM), vm.Type)
-	assert.Equal(t, string(models.StateRunning), vm.Status)
+	assert.Equal(t, models.TypeVM, vm.Type)
 	assert.Equal(t, "pve1", vm.Node)
-	assert.Equal(t, 25.0, vm.CPUUsage)
-	assert.InDelta(t, 50.0, vm.MemoryUsage, 0.1)
+	assert.Equal(t, models.StateRunning, vm.Status)
+	assert.Equal(t, 2, vm.MaxCPU)
+	assert.Equal(t, int64(42), vm.Uptime)
+	assert.Equal(t, 25.0, vm.MemoryUsage)
+	assert.Equal(t, 50.0, vm.CPUUsage)
+	assert.Zero(t, vm.Missing)
+	assert.Equal(t, &models.QEMUHealth{QMPStatus: "running", Balloon: models.BalloonReported}, vm.QEMU)
+}
 
-	// Check Container
-	ct := findNodeByID(nodes, "200")
-	require.NotNil(t, ct)
-	assert.Equal(t, "test-ct", ct.Name)
-	assert.Equal(t, string(models.TypeContainer), ct.Type)
-	assert.Equal(t, string(models.StateStopped), ct.Status)
-	assert.Equal(t, "pve1", ct.Node)
+func TestHTTPClient_GetGuestStatus_NotFound(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	_, err := client.GetGuestStatus(context.Background(), "pve1", "qemu", "999")
+	require.Error(t, err)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Body, "does not exist")
+}
+
+func TestHTTPClient_GetAgentFSInfo(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	filesystems, err := client.GetAgentFSInfo(context.Background(), "pve1", "100")
+	require.NoError(t, err)
+	require.Len(t, filesystems, 2, "tmpfs should be skipped")
+
+	assert.Equal(t, "/", filesystems[0].Mountpoint)
+	assert.Equal(t, "ext4", filesystems[0].Type)
+	assert.Equal(t, int64(5368709120), filesystems[0].UsedBytes)
+	assert.InDelta(t, 25.0, filesystems[0].UsagePercent(), 0.01)
+	assert.Equal(t, "/boot/efi", filesystems[1].Mountpoint)
+}
+
+func TestHTTPClient_GetAgentFSInfo_AgentNotRunning(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	_, err := client.GetAgentFSInfo(context.Background(), "pve1", "101")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "QEMU guest agent is not running")
+}
+
+func TestHTTPClient_GetAgentHostName(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	hostname, err := client.GetAgentHostName(context.Background(), "pve1", "100")
+	require.NoError(t, err)
+	assert.Equal(t, "test-vm.example.com", hostname)
+
+	_, err = client.GetAgentHostName(context.Background(), "pve1", "101")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "QEMU guest agent is not running")
+}
+
+func TestHTTPClient_PingAgent(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	require.NoError(t, client.PingAgent(context.Background(), "pve1", "100"))
+
+	err := client.PingAgent(context.Background(), "pve1", "101")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "QEMU guest agent is not running")
 }
 
 func TestHTTPClient_Start(t *testing.T) {
-	server, client := setupMockServer(t)
-	defer server.Close()
+	client := replayClient(t, "pve8")
 
 	err := client.Start(context.Background(), "pve1", "qemu", "100")
 	assert.NoError(t, err)
 }
 
 func TestHTTPClient_Shutdown(t *testing.T) {
-	server, client := setupMockServer(t)
-	defer server.Close()
+	client := replayClient(t, "pve8")
 
 	err := client.Shutdown(context.Background(), "pve1", "qemu", "100")
 	assert.NoError(t, err)
 }
 
 func TestHTTPClient_Reboot(t *testing.T) {
-	server, client := setupMockServer(t)
-	defer server.Close()
+	client := replayClient(t, "pve8")
 
 	err := client.Reboot(context.Background(), "pve1", "qemu", "100")
 	assert.NoError(t, err)
 }
 
 func TestHTTPClient_Stop(t *testing.T) {
-	server, client := setupMockServer(t)
-	defer server.Close()
+	client := replayClient(t, "pve8")
 
 	err := client.Stop(context.Background(), "pve1", "qemu", "100")
 	assert.NoError(t, err)
 }
 
-func TestHTTPClient_GetNodes_Unauthorized(t *testing.T) {
+func TestHTTPClient_Resume(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	err := client.Resume(context.Background(), "pve1", "qemu", "100")
+	assert.NoError(t, err)
+}
+
+func TestHTTPClient_GetNodes_UnknownStatus(t *testing.T) {
+	server := proxmoxtest.NewServer(t, proxmoxtest.WithGuests(proxmoxtest.VM(100, "", "pve1", "io-error")))
+
+	nodes, err := NewClient(server.URL, "test-token", true).GetNodes(context.Background())
+	require.NoError(t, err)
+	node := findNodeByID(nodes, "100")
+	require.NotNil(t, node)
+	assert.Equal(t, models.StateUnknown, node.Status)
+	assert.False(t, node.IsKnown(models.MetricMem), "The zeros of an unknown guest aren't readings")
+	assert.False(t, node.IsKnown(models.MetricUptime))
+	assert.True(t, node.IsKnown(models.MetricMaxMem), "Its size still is")
+}
+
+func TestHTTPClient_GetNodes_PausedAndHibernated(t *testing.T) {
+	busy := proxmoxtest.VM(100, "busy", "pve1", "running")
+	busy.CPU = 0.12
+	idle := proxmoxtest.VM(102, "idle", "pve1", "running")
+	idle.CPU = 0
+	hibernated := proxmoxtest.VM(103, "hibernated", "pve1", "stopped")
+	hibernated.Lock = "suspended"
+	backup := proxmoxtest.VM(104, "backup", "pve1", "stopped")
+	backup.Lock = "backup"
+	ct := proxmoxtest.CT(200, "ct", "pve1", "running")
+	ct.CPU = 0
+	server := proxmoxtest.NewServer(t, proxmoxtest.WithGuests(
+		busy, proxmoxtest.VM(101, "paused", "pve1", "paused"), idle, hibernated, backup, ct))
+
+	nodes, err := NewClient(server.URL, "test-token", true).GetNodes(context.Background())
+	require.NoError(t, err)
+
+	for vmid, want := range map[string]models.NodeState{
+		"100": models.StateRunning,
+		"101": models.StatePaused,
+		"102": models.StateRunning,
+		"103": models.StateHibernated,
+		"104": models.StateStopped,
+		"200": models.StateRunning,
+	} {
+		node := findNodeByID(nodes, vmid)
+		require.NotNil(t, node, vmid)
+		assert.Equal(t, want, node.Status, vmid)
+	}
+	var probed []string
+	for _, request := range server.Requests() {
+		if strings.HasSuffix(request, "/status/current") {
+			probed = append(probed, request)
+		}
+	}
+	assert.ElementsMatch(t, []string{"GET /nodes/pve1/qemu/101/status/current", "GET /nodes/pve1/qemu/102/status/current"}, probed,
+		"Only idle running VMs should be probed")
+	assert.Equal(t, "running", findNodeByID(nodes, "102").QEMU.QMPStatus, "The QEMU state of probed VMs should be kept")
+	assert.Nil(t, findNodeByID(nodes, "100").QEMU)
+}
+
+func TestHTTPClient_GetNodes_HAState(t *testing.T) {
+	managed := proxmoxtest.VM(100, "web", "pve1", "stopped")
+	managed.HAState = "started"
+	server := proxmoxtest.NewServer(t, proxmoxtest.WithGuests(managed, proxmoxtest.CT(200, "ct", "pve1", "stopped")))
+
+	nodes, err := NewClient(server.URL, "test-token", true).GetNodes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "started", findNodeByID(nodes, "100").HAState)
+	assert.Empty(t, findNodeByID(nodes, "200").HAState, "A guest HA doesn't manage has no HA state")
+}
+
+func TestHTTPClient_GetNodes_Pool(t *testing.T) {
+	pooled := proxmoxtest.VM(100, "web", "pve1", "running")
+	pooled.Pool = "prod"
+	server := proxmoxtest.NewServer(t, proxmoxtest.WithGuests(pooled, proxmoxtest.CT(200, "ct", "pve1", "stopped")))
+
+	nodes, err := NewClient(server.URL, "test-token", true).GetNodes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "prod", findNodeByID(nodes, "100").Pool)
+	assert.Empty(t, findNodeByID(nodes, "200").Pool)
+}
+
+func TestHTTPClient_GetGuestStatus_Paused(t *testing.T) {
+	server := proxmoxtest.NewServer(t, proxmoxtest.WithGuests(proxmoxtest.VM(100, "web", "pve1", "paused")))
+
+	vm, err := NewClient(server.URL, "test-token", true).GetGuestStatus(context.Background(), "pve1", "qemu", "100")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatePaused, vm.Status, "A guest asleep in RAM is paused too")
+	assert.True(t, vm.CanResume())
+}
+
+func TestHTTPClient_GetGuestStatus_QEMUHealth(t *testing.T) {
+	bodies := map[string]string{
+		"100": `{"data":{"vmid":100,"status":"running","qmpstatus":"prelaunch","balloon":1073741824}}`,
+		"101": `{"data":{"vmid":101,"status":"running","qmpstatus":"running"}}`,
+		"102": `{"data":{"vmid":102,"status":"stopped","qmpstatus":"stopped"}}`,
+		"200": `{"data":{"vmid":200,"status":"running"}}`,
+	}
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(bodies[strings.Split(r.URL.Path, "/")[6]]))
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "test-token", true)
+
+	vm, err := client.GetGuestStatus(context.Background(), "pve1", "qemu", "100")
+	require.NoError(t, err)
+	assert.Equal(t, models.StateRunning, vm.Status, "Proxmox lists a VM that never started its CPUs as running")
+	assert.Equal(t, &models.QEMUHealth{QMPStatus: "prelaunch", Balloon: models.BalloonSilent}, vm.QEMU)
+
+	vm, err = client.GetGuestStatus(context.Background(), "pve1", "qemu", "101")
+	require.NoError(t, err)
+	assert.Equal(t, models.BalloonNone, vm.QEMU.Balloon, "Without a balloon device there is nothing to report")
+
+	vm, err = client.GetGuestStatus(context.Background(), "pve1", "qemu", "102")
+	require.NoError(t, err)
+	assert.Nil(t, vm.QEMU, "A stopped VM has no QEMU process")
+
+	ct, err := client.GetGuestStatus(context.Background(), "pve1", "lxc", "200")
+	require.NoError(t, err)
+	assert.Nil(t, ct.QEMU)
+}
+
+func TestHTTPClient_Start_Locked(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	err := client.Start(context.Background(), "pve1", "qemu", "102")
+	require.Error(t, err)
+	assert.False(t, IsForbidden(err))
+	assert.Contains(t, err.Error(), "VM 102 is locked (backup)")
+}
+
+func TestHTTPClient_Start_PermissionCheckFailed(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	err := client.Start(context.Background(), "pve1", "qemu", "103")
+	require.Error(t, err)
+	assert.True(t, IsForbidden(err))
+	assert.Equal(t, "/nodes/pve1/qemu/103/status/start", DeniedPath(err))
+}
+
+func TestHTTPClient_GetVMConfig(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	cfg, err := client.GetVMConfig(context.Background(), "pve1", "qemu", "100")
+	require.NoError(t, err)
+	assert.Equal(t, "test-vm", cfg["name"])
+	assert.Equal(t, float64(2), cfg["cores"])
+	assert.Equal(t, "local-lvm:vm-100-disk-0,iothread=1,size=32G", cfg["scsi0"])
+}
+
+func TestHTTPClient_Replay_Unrecorded(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	_, err := client.GetVMConfig(context.Background(), "pve2", "lxc", "300")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no recorded exchange for GET /nodes/pve2/lxc/300/config")
+}
+
+func TestHTTPClient_NodePower(t *testing.T) {
+	var commands []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api2/json/nodes/pve1/status", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		commands = append(commands, r.PostForm.Get("command"))
+		_, _ = w.Write([]byte(`{"data":null}`))
 	}))
 	defer server.Close()
 
+	client := NewClient(server.URL, "test-token", true).(*HTTPClient)
+	require.NoError(t, client.NodeReboot(context.Background(), "pve1"))
+	require.NoError(t, client.NodeShutdown(context.Background(), "pve1"))
+	assert.Equal(t, []string{"reboot", "shutdown"}, commands)
+}
+
+func TestHTTPClient_NodePower_Replay(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	assert.NoError(t, client.NodeReboot(context.Background(), "pve1"))
+
+	err := client.NodeShutdown(context.Background(), "pve2")
+	require.Error(t, err)
+	assert.True(t, IsForbidden(err))
+	assert.Contains(t, err.Error(), "Sys.PowerMgmt")
+}
+
+func TestHTTPClient_WakeNode(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	mac, err := client.WakeNode(context.Background(), "pve2")
+	require.NoError(t, err)
+	assert.Equal(t, "bc:24:11:7f:3a:02", mac)
+
+	_, err = client.WakeNode(context.Background(), "pve3")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrNoWakeOnLAN)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr, "the API error stays available")
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+}
+
+func TestHTTPClient_WakeNode_StatusCodes(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusNotImplemented, http.StatusForbidden} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		_, err := NewClient(server.URL, "test-token", true).(*HTTPClient).WakeNode(context.Background(), "pve2")
+		server.Close()
+
+		require.Error(t, err)
+		assert.Equal(t, status != http.StatusForbidden, errors.Is(err, ErrNoWakeOnLAN), "status %d", status)
+		assert.Equal(t, status == http.StatusForbidden, IsForbidden(err), "status %d", status)
+	}
+}
+
+func TestHTTPClient_GetRunningTasks(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	tasks, err := client.GetRunningTasks(context.Background(), "pve1")
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "UPID:pve1:0003A1B2:0151C2D3:6710F3A0:vzdump:100:root@pam:", tasks[0].UPID)
+	assert.Equal(t, "pve1", tasks[0].Node)
+	assert.Equal(t, "vzdump 100", tasks[0].Label())
+	assert.Equal(t, "root@pam", tasks[0].User)
+	assert.Equal(t, int64(1729164192), tasks[0].StartTime.Unix())
+
+	_, err = client.GetRunningTasks(context.Background(), "pve2")
+	assert.True(t, IsForbidden(err))
+}
+
+func TestHTTPClient_GetTaskLog(t *testing.T) {
+	client := replayClient(t, "pve8")
+	backup := "UPID:pve1:0003A1B2:0151C2D3:6710F3A0:vzdump:100:root@pam:"
+
+	lines, err := client.GetTaskLog(context.Background(), "pve1", backup, 0)
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+	assert.Equal(t, 1, lines[0].N)
+	assert.Contains(t, lines[0].Text, "starting new backup job")
+
+	// The next read picks up after the lines already seen
+	lines, err = client.GetTaskLog(context.Background(), "pve1", backup, 3)
+	require.NoError(t, err)
+	require.Len(t, lines, 1)
+	assert.Equal(t, 4, lines[0].N)
+
+	// An empty log is not a line of its own
+	migrate := "UPID:pve1:0003A2C4:0151C9E0:6710F412:qmigrate:101:admin@pve:"
+	lines, err = client.GetTaskLog(context.Background(), "pve1", migrate, 0)
+	require.NoError(t, err)
+	assert.Empty(t, lines)
+}
+
+func TestHTTPClient_StopTask(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	err := client.StopTask(context.Background(), "pve1", "UPID:pve1:0003A1B2:0151C2D3:6710F3A0:vzdump:100:root@pam:")
+	assert.NoError(t, err)
+
+	err = client.StopTask(context.Background(), "pve1", "UPID:pve1:00000000:00000000:00000000:vzdump:999:root@pam:")
+	assert.Error(t, err)
+}
+
+func TestHTTPClient_GetTaskStatus(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	status, err := client.GetTaskStatus(context.Background(), "pve1", "UPID:pve1:0003A1B2:0151C2D3:6710F3A0:vzdump:100:root@pam:")
+	require.NoError(t, err)
+	assert.True(t, status.Running)
+	assert.False(t, status.OK())
+
+	status, err = client.GetTaskStatus(context.Background(), "pve1", "UPID:pve1:00041C2A:0152A0B1:6710F9C2:download:alpine-virt-3.19.1-x86_64.iso:root@pam:")
+	require.NoError(t, err)
+	assert.False(t, status.Running)
+	assert.True(t, status.OK())
+}
+
+func TestHTTPClient_GetNodes_Unauthorized(t *testing.T) {
+	server := proxmoxtest.NewServer(t, proxmoxtest.WithAuthToken("root@pam!pvec=valid"))
+
 	client := NewClient(server.URL, "invalid-token", true)
 	_, err := client.GetNodes(context.Background())
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "401")
+	assert.True(t, IsUnauthorized(err))
+	assert.False(t, IsForbidden(err))
+}
+
+func TestHTTPClient_GetNodes_UnauthorizedEcho(t *testing.T) {
+	// Some proxies answer a rejected request by echoing its headers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprintf(w, "rejected Authorization: %s", r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "PVEAPIToken=root@pam!pvec=0f6c1e2a-7b3d-4c5e-9f8a-1b2c3d4e5f60", true)
+	_, err := client.GetNodes(context.Background())
+
+	require.Error(t, err)
+	assert.True(t, IsUnauthorized(err))
+	assert.NotContains(t, err.Error(), "0f6c1e2a-7b3d-4c5e-9f8a-1b2c3d4e5f60")
+	assert.Contains(t, err.Error(), "rejected Authorization: PVEAPIToken=root@pam!pvec=****")
+}
+
+func TestHTTPClient_GetNodes_Forbidden(t *testing.T) {
+	server := proxmoxtest.NewServer(t, proxmoxtest.WithErrorOn("/cluster/resources", http.StatusForbidden))
+
+	client := NewClient(server.URL, "limited-token", true)
+	_, err := client.GetNodes(context.Background())
+
+	require.Error(t, err)
+	assert.True(t, IsForbidden(err))
+	assert.False(t, IsUnauthorized(err))
+	assert.Equal(t, "/cluster/resources", DeniedPath(err))
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.Equal(t, "GET", apiErr.Method)
+	assert.Contains(t, apiErr.Body, `"data":null`)
+}
+
+func TestHTTPClient_Start_Forbidden(t *testing.T) {
+	server := proxmoxtest.NewServer(t,
+		proxmoxtest.WithGuests(proxmoxtest.VM(100, "web", "pve1", "stopped")),
+		proxmoxtest.WithErrorOn("/nodes/pve1/qemu/100/status/start", http.StatusForbidden))
+
+	client := NewClient(server.URL, "limited-token", true)
+	err := client.Start(context.Background(), "pve1", "qemu", "100")
+
+	require.Error(t, err)
+	assert.True(t, IsForbidden(err))
+	assert.Equal(t, "/nodes/pve1/qemu/100/status/start", DeniedPath(err))
 }
 
 func TestHTTPClient_GetNodes_InvalidJSON(t *testing.T) {
@@ -200,8 +779,7 @@ func TestHTTPClient_GetNodes_InvalidJSON(t *testing.T) {
 }
 
 func TestHTTPClient_ContextCancellation(t *testing.T) {
-	server, client := setupMockServer(t)
-	defer server.Close()
+	client := replayClient(t, "pve8")
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // Cancel immediately
@@ -210,6 +788,62 @@ func TestHTTPClient_ContextCancellation(t *testing.T) {
 	assert.Error(t, err)
 }
 
+// deadlineRecorder notes how long requests have left before their deadline
+type deadlineRecorder struct {
+	remaining []time.Duration
+}
+
+func (d *deadlineRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var remaining time.Duration
+	if deadline, ok := req.Context().Deadline(); ok {
+		remaining = time.Until(deadline)
+	}
+	d.remaining = append(d.remaining, remaining)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":"UPID"}`))}, nil
+}
+
+func TestHTTPClient_DefaultRequestTimeout(t *testing.T) {
+	recorder := &deadlineRecorder{}
+	client := &HTTPClient{baseURL: "https://pve.test:8006", httpClient: &http.Client{Transport: recorder}}
+
+	require.NoError(t, client.Shutdown(context.Background(), "pve1", "qemu", "100"))
+
+	// A caller's own deadline is kept, however long
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	require.NoError(t, client.Shutdown(ctx, "pve1", "qemu", "100"))
+
+	require.Len(t, recorder.remaining, 2)
+	assert.InDelta(t, defaultRequestTimeout.Seconds(), recorder.remaining[0].Seconds(), 1)
+	assert.InDelta(t, (5 * time.Minute).Seconds(), recorder.remaining[1].Seconds(), 1)
+	assert.Zero(t, client.httpClient.Timeout, "a client-wide timeout would cut long actions short")
+}
+
+// headerRecorder notes the User-Agent of every request
+type headerRecorder struct {
+	agents []string
+}
+
+func (h *headerRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.agents = append(h.agents, req.Header.Get("User-Agent"))
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":[]}`))}, nil
+}
+
+func TestHTTPClient_UserAgent(t *testing.T) {
+	recorder := &headerRecorder{}
+	client := &HTTPClient{baseURL: "https://pve.test:8006", httpClient: &http.Client{Transport: recorder}}
+
+	_, err := client.GetNodes(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, client.Start(context.Background(), "pve1", "qemu", "100"))
+
+	require.Len(t, recorder.agents, 2, "doRequest and doRequestForm")
+	for _, agent := range recorder.agents {
+		assert.Equal(t, version.UserAgent(), agent)
+		assert.True(t, strings.HasPrefix(agent, "pvec/"), agent)
+	}
+}
+
 func TestNewClient_TLSConfig(t *testing.T) {
 	client := NewClient("https://example.com", "token", true)
 	httpClient := client.(*HTTPClient)
@@ -233,3 +867,45 @@ func findNodeByID(nodes []*models.VMStatus, vmid string) *models.VMStatus {
 	}
 	return nil
 }
+
+func TestNewHTTPClient(t *testing.T) {
+	recorder := &deadlineRecorder{}
+	client, err := NewHTTPClient(ClientOptions{
+		BaseURL:        "https://pve.test:8006/",
+		TokenID:        "root@pam!pvec",
+		TokenSecret:    "secret",
+		Transport:      recorder,
+		RequestTimeout: time.Minute,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://pve.test:8006", client.baseURL)
+	assert.Equal(t, "PVEAPIToken=root@pam!pvec=secret", client.authToken)
+
+	require.NoError(t, client.Shutdown(context.Background(), "pve1", "qemu", "100"))
+	require.Len(t, recorder.remaining, 1)
+	assert.InDelta(t, time.Minute.Seconds(), recorder.remaining[0].Seconds(), 1)
+
+	client, err = NewHTTPClient(ClientOptions{BaseURL: "https://pve.test:8006", TokenID: "id", TokenSecret: "s", SkipTLSVerify: true})
+	require.NoError(t, err)
+	assert.True(t, client.httpClient.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+	assert.Equal(t, defaultRequestTimeout, client.requestTimeout)
+}
+
+func TestNewHTTPClient_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ClientOptions
+	}{
+		{"no URL", ClientOptions{TokenID: "id", TokenSecret: "s"}},
+		{"no scheme", ClientOptions{BaseURL: "pve.test:8006", TokenID: "id", TokenSecret: "s"}},
+		{"no token ID", ClientOptions{BaseURL: "https://pve.test:8006", TokenSecret: "s"}},
+		{"no secret", ClientOptions{BaseURL: "https://pve.test:8006", TokenID: "id"}},
+		{"negative timeout", ClientOptions{BaseURL: "https://pve.test:8006", TokenID: "id", TokenSecret: "s", RequestTimeout: -time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHTTPClient(tt.opts)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// NextVMID returns the lowest VMID free in the cluster
+func (c *HTTPClient) NextVMID(ctx context.Context) (string, error) {
+	// A string, or a number on older releases
+	var id json.RawMessage
+	if err := c.getData(ctx, "/cluster/nextid", &id); err != nil {
+		return "", fmt.Errorf("failed to get the next free VMID: %w", err)
+	}
+	vmid := strings.Trim(string(id), `"`)
+	if vmid == "" || vmid == "null" {
+		return "", fmt.Errorf("failed to get the next free VMID: empty answer")
+	}
+	return vmid, nil
+}
+
+// CloneGuest clones a guest on its node and returns the UPID of the clone
+// task. A container takes the name as its hostname.
+func (c *HTTPClient) CloneGuest(ctx context.Context, node, vmType, vmid string, spec actions.CloneSpec) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/%s/%s/clone", node, vmType, vmid)
+	form := url.Values{"newid": {spec.NewID}}
+	if vmType == "lxc" {
+		form.Set("hostname", spec.Name)
+	} else {
+		form.Set("name", spec.Name)
+	}
+	if spec.Full {
+		form.Set("full", "1")
+	}
+	if spec.Storage != "" {
+		form.Set("storage", spec.Storage)
+	}
+	resp, err := c.doRequestForm(ctx, "POST", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to clone %s %s: %w", vmType, vmid, newAPIError(resp, "POST", path))
+	}
+	return decodeUPID(resp)
+}
+
+// CloneExecutor adapts a CloneManager to actions.Cloner, looking the
+// source guest's node and type up in a shared list as ActionExecutor does
+type CloneExecutor struct {
+	manager CloneManager
+	guests  models.NodeList // Kept current by whoever refreshes it
+	Poll    time.Duration   // Check interval of the clone task; DefaultTaskPoll if zero
+}
+
+// NewCloneExecutor creates a clone executor over the guests list
+func NewCloneExecutor(manager CloneManager, guests models.NodeList) *CloneExecutor {
+	return &CloneExecutor{manager: manager, guests: guests}
+}
+
+// target returns the node and type of the source guest, and its VMID
+func (e *CloneExecutor) target(key string) (node, vmType, vmid string, err error) {
+	vm, found := e.guests.Get(key)
+	if !found {
+		return "", "", "", ErrNodeNotFound
+	}
+	_, vmid = models.SplitKey(key)
+	return vm.Node, vm.TypeString(), vmid, nil
+}
+
+// NextVMID returns a VMID free in the cluster
+func (e *CloneExecutor) NextVMID(ctx context.Context) (string, error) {
+	return e.manager.NextVMID(ctx)
+}
+
+// Clone asks for a clone of the source on its node and returns the task
+func (e *CloneExecutor) Clone(ctx context.Context, source string, spec actions.CloneSpec) (string, error) {
+	node, vmType, vmid, err := e.target(source)
+	if err != nil {
+		return "", err
+	}
+	return e.manager.CloneGuest(ctx, node, vmType, vmid, spec)
+}
+
+// WaitTask waits for a task of the source's node to end
+func (e *CloneExecutor) WaitTask(ctx context.Context, source, task string) error {
+	node, _, _, err := e.target(source)
+	if err != nil {
+		return err
+	}
+	return WaitTask(ctx, e.manager, node, task, e.Poll)
+}
+
+// StartClone starts the clone newid, which sits on the source's node
+func (e *CloneExecutor) StartClone(ctx context.Context, source, newid string) error {
+	node, vmType, _, err := e.target(source)
+	if err != nil {
+		return err
+	}
+	return e.manager.Start(ctx, node, vmType, newid)
+}
@@ -0,0 +1,116 @@
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestHTTPClient_NextVMID(t *testing.T) {
+	for _, body := range []string{`{"data":"105"}`, `{"data":105}`} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api2/json/cluster/nextid", r.URL.Path)
+			_, _ = w.Write([]byte(body))
+		}))
+		client := NewClient(server.URL, "token", true).(*HTTPClient)
+		vmid, err := client.NextVMID(context.Background())
+		require.NoError(t, err, body)
+		assert.Equal(t, "105", vmid, body)
+		server.Close()
+	}
+}
+
+func TestHTTPClient_CloneGuest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		require.NoError(t, r.ParseForm())
+		switch r.URL.Path {
+		case "/api2/json/nodes/pve1/qemu/9000/clone":
+			assert.Equal(t, "105", r.PostForm.Get("newid"))
+			assert.Equal(t, "dev-web-03", r.PostForm.Get("name"))
+			assert.Equal(t, "1", r.PostForm.Get("full"))
+			assert.Equal(t, "local-lvm", r.PostForm.Get("storage"))
+			_, _ = w.Write([]byte(`{"data":"UPID:pve1:clone"}`))
+		case "/api2/json/nodes/pve1/lxc/9001/clone":
+			assert.Equal(t, "ct-2", r.PostForm.Get("hostname"), "a container takes a hostname")
+			assert.Empty(t, r.PostForm.Get("name"))
+			assert.Empty(t, r.PostForm.Get("full"), "linked by default")
+			assert.Empty(t, r.PostForm.Get("storage"))
+			_, _ = w.Write([]byte(`{"data":"UPID:pve1:vzclone"}`))
+		default:
+			http.Error(w, `{"data":null,"message":"unable to find configuration file"}`, http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	upid, err := client.CloneGuest(context.Background(), "pve1", "qemu", "9000",
+		actions.CloneSpec{NewID: "105", Name: "dev-web-03", Storage: "local-lvm", Full: true})
+	require.NoError(t, err)
+	assert.Equal(t, "UPID:pve1:clone", upid)
+
+	upid, err = client.CloneGuest(context.Background(), "pve1", "lxc", "9001", actions.CloneSpec{NewID: "106", Name: "ct-2"})
+	require.NoError(t, err)
+	assert.Equal(t, "UPID:pve1:vzclone", upid)
+
+	_, err = client.CloneGuest(context.Background(), "pve1", "qemu", "9999", actions.CloneSpec{NewID: "107"})
+	assert.ErrorContains(t, err, "failed to clone qemu 9999")
+}
+
+// fakeClones is a CloneManager whose tasks end with exit
+type fakeClones struct {
+	exit    string
+	cloned  []string
+	started []string
+	err     error
+}
+
+func (f *fakeClones) NextVMID(ctx context.Context) (string, error) {
+	return "105", f.err
+}
+
+func (f *fakeClones) CloneGuest(ctx context.Context, node, vmType, vmid string, spec actions.CloneSpec) (string, error) {
+	f.cloned = append(f.cloned, node+"/"+vmType+"/"+vmid+"/"+spec.NewID+"/"+spec.Name)
+	return "UPID:" + node + ":clone", f.err
+}
+
+func (f *fakeClones) GetTaskStatus(ctx context.Context, node, upid string) (models.TaskStatus, error) {
+	return models.TaskStatus{ExitStatus: f.exit}, nil
+}
+
+func (f *fakeClones) Start(ctx context.Context, node, vmType, vmid string) error {
+	f.started = append(f.started, node+"/"+vmType+"/"+vmid)
+	return f.err
+}
+
+func TestCloneExecutor(t *testing.T) {
+	guests := models.NewNodeList()
+	guests.Add(&models.VMStatus{VMID: "9001", Node: "pve2", Type: models.TypeContainer})
+	manager := &fakeClones{exit: "OK"}
+	e := NewCloneExecutor(manager, guests)
+	e.Poll = time.Millisecond
+
+	clone := &actions.CloneAction{Cloner: e, Source: "9001", Spec: actions.CloneSpec{Name: "ct-3"}, Start: true}
+	require.NoError(t, clone.Execute(context.Background()))
+	assert.Equal(t, []string{"pve2/lxc/9001/105/ct-3"}, manager.cloned)
+	assert.Equal(t, []string{"pve2/lxc/105"}, manager.started, "the clone starts on the source's node")
+
+	_, err := e.Clone(context.Background(), "999", actions.CloneSpec{NewID: "106"})
+	assert.ErrorIs(t, err, ErrNodeNotFound)
+
+	manager.exit = "storage 'local' does not support linked clones"
+	err = e.WaitTask(context.Background(), "9001", "UPID:pve2:clone")
+	assert.EqualError(t, err, "task failed: storage 'local' does not support linked clones")
+
+	manager.err = errors.New("permission denied")
+	_, err = e.NextVMID(context.Background())
+	assert.EqualError(t, err, "permission denied")
+}
@@ -0,0 +1,46 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RegenerateCloudInit rebuilds the cloud-init image of a VM from its
+// current settings. Servers without the cloudinit endpoint (before PVE
+// 7.2) answer 501; the drive is then re-added on its storage, which
+// regenerates the image as well.
+func (c *HTTPClient) RegenerateCloudInit(ctx context.Context, node, vmid, drive, storage string) error {
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/cloudinit", node, vmid)
+	resp, err := c.doRequestForm(ctx, "PUT", path, strings.NewReader(""))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotImplemented:
+		return c.readdCloudInitDrive(ctx, node, vmid, drive, storage)
+	}
+	return fmt.Errorf("failed to regenerate cloud-init of %s: %w", vmid, newAPIError(resp, "PUT", path))
+}
+
+// readdCloudInitDrive sets the cloud-init drive again, as qm set does
+func (c *HTTPClient) readdCloudInitDrive(ctx context.Context, node, vmid, drive, storage string) error {
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/config", node, vmid)
+	form := url.Values{drive: {storage + ":cloudinit,media=cdrom"}}
+	resp, err := c.doRequestForm(ctx, "PUT", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to regenerate cloud-init of %s: %w", vmid, newAPIError(resp, "PUT", path))
+	}
+	return nil
+}
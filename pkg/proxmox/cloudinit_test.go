@@ -0,0 +1,59 @@
+package proxmox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient_RegenerateCloudInit(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	err := client.RegenerateCloudInit(context.Background(), "pve1", "100", "ide2", "local-lvm")
+	assert.NoError(t, err)
+
+	err = client.RegenerateCloudInit(context.Background(), "pve1", "999", "ide2", "local-lvm")
+	assert.Error(t, err)
+}
+
+func TestHTTPClient_RegenerateCloudInit_Fallback(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/api2/json/nodes/pve1/qemu/100/cloudinit" {
+			http.Error(w, `{"data":null}`, http.StatusNotImplemented)
+			return
+		}
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "local-lvm:cloudinit,media=cdrom", r.PostForm.Get("ide2"))
+		_, _ = w.Write([]byte(`{"data":null}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	err := client.RegenerateCloudInit(context.Background(), "pve1", "100", "ide2", "local-lvm")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"/api2/json/nodes/pve1/qemu/100/cloudinit",
+		"/api2/json/nodes/pve1/qemu/100/config",
+	}, paths)
+}
+
+func TestHTTPClient_RegenerateCloudInit_Forbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Permission check failed (/vms/100, VM.Config.Cloudinit)", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	err := client.RegenerateCloudInit(context.Background(), "pve1", "100", "ide2", "local-lvm")
+
+	assert.True(t, IsForbidden(err))
+	assert.Equal(t, "needs VM.Config.Cloudinit on /vms/100", PermissionHint(err))
+}
@@ -0,0 +1,208 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// clusterNode represents one entry of the nodes endpoint
+type clusterNode struct {
+	Node   string  `json:"node"`
+	Status string  `json:"status"`
+	CPU    float64 `json:"cpu"` // Fraction of all cores, 0-1
+	MaxCPU int     `json:"maxcpu"`
+	Mem    int64   `json:"mem"`
+	MaxMem int64   `json:"maxmem"`
+	Uptime int64   `json:"uptime"`
+}
+
+// storageResource represents a storage entry of the cluster resources endpoint
+type storageResource struct {
+	Storage    string `json:"storage"`
+	Node       string `json:"node"`
+	PluginType string `json:"plugintype"`
+	Content    string `json:"content"` // Comma-separated content types
+	Shared     int    `json:"shared"`
+	Disk       int64  `json:"disk"`
+	MaxDisk    int64  `json:"maxdisk"`
+}
+
+// poolEntry represents one entry of the pools endpoint
+type poolEntry struct {
+	PoolID  string `json:"poolid"`
+	Comment string `json:"comment"`
+}
+
+// haResource represents one entry of the HA resources endpoint
+type haResource struct {
+	SID   string `json:"sid"`
+	State string `json:"state"`
+	Group string `json:"group"`
+}
+
+// replicationJob represents one entry of the replication endpoint
+type replicationJob struct {
+	ID       string          `json:"id"`
+	Guest    json.RawMessage `json:"guest"` // A number, or a string on some releases
+	Target   string          `json:"target"`
+	Schedule string          `json:"schedule"`
+	Disable  int             `json:"disable"`
+}
+
+// clusterTask represents one entry of the cluster tasks endpoint, which
+// lists the recent tasks of every node; a task still running has no end
+// time nor status
+type clusterTask struct {
+	nodeTask
+	EndTime int64  `json:"endtime"`
+	Status  string `json:"status"`
+}
+
+// GetClusterNodes lists the nodes of the cluster with their load
+func (c *HTTPClient) GetClusterNodes(ctx context.Context) ([]models.ClusterNode, error) {
+	path := "/nodes"
+	var entries []clusterNode
+	if err := c.getData(ctx, path, &entries); err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	nodes := make([]models.ClusterNode, 0, len(entries))
+	for _, e := range entries {
+		nodes = append(nodes, models.ClusterNode{
+			Name:     e.Node,
+			Online:   e.Status == "online",
+			CPUUsage: e.CPU * 100,
+			MaxCPU:   e.MaxCPU,
+			Mem:      e.Mem,
+			MaxMem:   e.MaxMem,
+			Uptime:   e.Uptime,
+		})
+	}
+	return nodes, nil
+}
+
+// GetClusterStorages lists the storages of every node in one request
+func (c *HTTPClient) GetClusterStorages(ctx context.Context) ([]models.Storage, error) {
+	path := "/cluster/resources?type=storage"
+	var entries []storageResource
+	if err := c.getData(ctx, path, &entries); err != nil {
+		return nil, fmt.Errorf("failed to get storages: %w", err)
+	}
+
+	storages := make([]models.Storage, 0, len(entries))
+	for _, e := range entries {
+		var content []string
+		for _, c := range strings.Split(e.Content, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				content = append(content, c)
+			}
+		}
+		storages = append(storages, models.Storage{
+			Name:    e.Storage,
+			Node:    e.Node,
+			Type:    e.PluginType,
+			Content: content,
+			Shared:  e.Shared != 0,
+			Used:    e.Disk,
+			Total:   e.MaxDisk,
+		})
+	}
+	return storages, nil
+}
+
+// GetPools lists the resource pools
+func (c *HTTPClient) GetPools(ctx context.Context) ([]models.Pool, error) {
+	var entries []poolEntry
+	if err := c.getData(ctx, "/pools", &entries); err != nil {
+		return nil, fmt.Errorf("failed to get pools: %w", err)
+	}
+	pools := make([]models.Pool, 0, len(entries))
+	for _, e := range entries {
+		pools = append(pools, models.Pool{ID: e.PoolID, Comment: e.Comment})
+	}
+	return pools, nil
+}
+
+// GetHAResources lists the guests managed by HA
+func (c *HTTPClient) GetHAResources(ctx context.Context) ([]models.HAResource, error) {
+	var entries []haResource
+	if err := c.getData(ctx, "/cluster/ha/resources", &entries); err != nil {
+		return nil, fmt.Errorf("failed to get HA resources: %w", err)
+	}
+	resources := make([]models.HAResource, 0, len(entries))
+	for _, e := range entries {
+		resources = append(resources, models.HAResource{SID: e.SID, State: e.State, Group: e.Group})
+	}
+	return resources, nil
+}
+
+// GetReplicationJobs lists the storage replication jobs of the cluster
+func (c *HTTPClient) GetReplicationJobs(ctx context.Context) ([]models.ReplicationJob, error) {
+	var entries []replicationJob
+	if err := c.getData(ctx, "/cluster/replication", &entries); err != nil {
+		return nil, fmt.Errorf("failed to get replication jobs: %w", err)
+	}
+	jobs := make([]models.ReplicationJob, 0, len(entries))
+	for _, e := range entries {
+		jobs = append(jobs, models.ReplicationJob{
+			ID:       e.ID,
+			Guest:    strings.Trim(string(e.Guest), `"`),
+			Target:   e.Target,
+			Schedule: e.Schedule,
+			Disabled: e.Disable != 0,
+		})
+	}
+	return jobs, nil
+}
+
+// GetClusterTasks lists the tasks running on every node of the cluster,
+// in a single request rather than one per node
+func (c *HTTPClient) GetClusterTasks(ctx context.Context) ([]models.Task, error) {
+	var entries []clusterTask
+	if err := c.getData(ctx, "/cluster/tasks", &entries); err != nil {
+		return nil, fmt.Errorf("failed to get cluster tasks: %w", err)
+	}
+	tasks := make([]models.Task, 0, len(entries))
+	for _, e := range entries {
+		if e.EndTime != 0 || e.Status != "" {
+			continue
+		}
+		tasks = append(tasks, models.Task{
+			UPID:      e.UPID,
+			Node:      e.Node,
+			Type:      e.Type,
+			ID:        e.ID,
+			User:      e.User,
+			StartTime: time.Unix(e.StartTime, 0),
+		})
+	}
+	return tasks, nil
+}
+
+// getData GETs path and decodes the data member of the response into v
+func (c *HTTPClient) getData(ctx context.Context, path string, v interface{}) error {
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp, "GET", path)
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if err := json.Unmarshal(apiResp.Data, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package proxmox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestHTTPClient_GetClusterNodes(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	nodes, err := client.GetClusterNodes(context.Background())
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+
+	assert.Equal(t, "pve1", nodes[0].Name)
+	assert.True(t, nodes[0].Online)
+	assert.InDelta(t, 6.12, nodes[0].CPUUsage, 0.001)
+	assert.Equal(t, 16, nodes[0].MaxCPU)
+	assert.Equal(t, int64(1209600), nodes[0].Uptime)
+	assert.False(t, nodes[1].Online)
+	assert.Zero(t, nodes[1].MaxMem)
+}
+
+func TestHTTPClient_GetClusterStorages(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	storages, err := client.GetClusterStorages(context.Background())
+	require.NoError(t, err)
+	require.Len(t, storages, 2)
+
+	assert.Equal(t, "local", storages[0].Name)
+	assert.Equal(t, "pve1", storages[0].Node)
+	assert.Equal(t, "dir", storages[0].Type)
+	assert.Equal(t, []string{"iso", "vztmpl", "backup"}, storages[0].Content)
+	assert.Equal(t, int64(12884901888), storages[0].Used)
+	assert.Equal(t, int64(858993459200), storages[1].Total)
+}
+
+func TestHTTPClient_GetPools(t *testing.T) {
+	pools, err := replayClient(t, "pve8").GetPools(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.Pool{{ID: "dev", Comment: "Development guests"}, {ID: "prod"}}, pools)
+}
+
+func TestHTTPClient_GetHAResources(t *testing.T) {
+	resources, err := replayClient(t, "pve8").GetHAResources(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.HAResource{
+		{SID: "vm:100", State: "started", Group: "prefer-pve1"},
+		{SID: "ct:200", State: "stopped"},
+	}, resources)
+}
+
+func TestHTTPClient_GetReplicationJobs(t *testing.T) {
+	jobs, err := replayClient(t, "pve8").GetReplicationJobs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []models.ReplicationJob{
+		{ID: "100-0", Guest: "100", Target: "pve2", Schedule: "*/15"},
+		{ID: "101-0", Guest: "101", Target: "pve2", Schedule: "*/30", Disabled: true},
+	}, jobs, "the guest reads the same as a number or a string")
+}
+
+func TestHTTPClient_GetClusterTasks(t *testing.T) {
+	tasks, err := replayClient(t, "pve8").GetClusterTasks(context.Background())
+	require.NoError(t, err)
+	require.Len(t, tasks, 2, "Finished tasks are left out")
+	assert.Equal(t, "qmigrate", tasks[0].Type)
+	assert.Equal(t, "101", tasks[0].ID)
+	assert.Equal(t, "pve1", tasks[0].Node)
+	assert.Equal(t, int64(1729164306), tasks[0].StartTime.Unix())
+	assert.Equal(t, "vzdump 100", tasks[1].Label())
+}
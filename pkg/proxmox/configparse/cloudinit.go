@@ -0,0 +1,143 @@
+package configparse
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	cloudInitKeyPattern = regexp.MustCompile(`^(ciuser|cipassword|citype|ciupgrade|cicustom|nameserver|searchdomain|sshkeys)$|^ipconfig\d+$`)
+	ipConfigKeyPattern  = regexp.MustCompile(`^ipconfig(\d+)$`)
+)
+
+// IsCloudInitKey reports whether a qemu config key is a cloud-init setting
+func IsCloudInitKey(key string) bool {
+	return cloudInitKeyPattern.MatchString(strings.ToLower(key))
+}
+
+// SSHKey is one authorized key; the key material itself is left out
+type SSHKey struct {
+	Type        string // e.g. ssh-ed25519
+	Comment     string // Usually user@host
+	Fingerprint string // SHA256:... as printed by ssh-keygen -l; empty when the key doesn't decode
+}
+
+// CloudInit is the cloud-init setup of a VM. The password is never kept,
+// only whether one is set.
+type CloudInit struct {
+	User         string
+	Password     bool
+	IPConfigs    []Property // ipconfigN entries, in interface order
+	Nameserver   string
+	SearchDomain string
+	SSHKeys      []SSHKey
+	Drive        string // Config key of the cloud-init drive, e.g. ide2
+	Storage      string // Storage holding the cloud-init drive
+}
+
+// ParseCloudInit extracts the cloud-init settings of a qemu config. It
+// returns false when the VM has neither a cloud-init drive nor settings.
+func ParseCloudInit(config map[string]interface{}) (CloudInit, bool) {
+	var ci CloudInit
+	found := false
+	for key, value := range config {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(key)
+		if IsDiskKey(key) {
+			if disk := ParseDisk(str); strings.Contains(disk.Volume, "cloudinit") {
+				ci.Drive, ci.Storage = key, disk.Storage
+				found = true
+			}
+			continue
+		}
+		if !IsCloudInitKey(key) {
+			continue
+		}
+		found = true
+		switch key {
+		case "ciuser":
+			ci.User = str
+		case "cipassword":
+			ci.Password = str != ""
+		case "nameserver":
+			ci.Nameserver = str
+		case "searchdomain":
+			ci.SearchDomain = str
+		case "sshkeys":
+			ci.SSHKeys = ParseSSHKeys(str)
+		default:
+			if ipConfigKeyPattern.MatchString(key) {
+				ci.IPConfigs = append(ci.IPConfigs, Property{Key: key, Value: str})
+			}
+		}
+	}
+	sort.Slice(ci.IPConfigs, func(i, j int) bool {
+		return ipConfigIndex(ci.IPConfigs[i].Key) < ipConfigIndex(ci.IPConfigs[j].Key)
+	})
+	return ci, found
+}
+
+// ipConfigIndex returns N of an ipconfigN key
+func ipConfigIndex(key string) int {
+	n, _ := strconv.Atoi(ipConfigKeyPattern.FindStringSubmatch(key)[1])
+	return n
+}
+
+// ParseSSHKeys parses the sshkeys value, which Proxmox stores
+// percent-encoded, one authorized_keys line per key. Blank lines and
+// comments are skipped.
+func ParseSSHKeys(value string) []SSHKey {
+	decoded, err := url.PathUnescape(value)
+	if err != nil {
+		decoded = value
+	}
+	var keys []SSHKey
+	for _, line := range strings.Split(decoded, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, parseAuthorizedKey(line))
+	}
+	return keys
+}
+
+// parseAuthorizedKey parses an authorized_keys line, skipping any leading
+// options such as from="..."
+func parseAuthorizedKey(line string) SSHKey {
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		if !isKeyType(fields[i]) {
+			continue
+		}
+		blob, err := base64.StdEncoding.DecodeString(fields[i+1])
+		if err != nil {
+			break
+		}
+		sum := sha256.Sum256(blob)
+		return SSHKey{
+			Type:        fields[i],
+			Comment:     strings.Join(fields[i+2:], " "),
+			Fingerprint: "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]),
+		}
+	}
+	return SSHKey{Type: "invalid"}
+}
+
+// isKeyType reports whether an authorized_keys field names a key type
+func isKeyType(field string) bool {
+	for _, prefix := range []string{"ssh-", "ecdsa-", "sk-"} {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package configparse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testKey            = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDPyhInKSZyNL1hZ5EhV6XtKp6c38F3agDaVWgDGoXIg alice@laptop"
+	testKeyFingerprint = "SHA256:KKbfdyaix0Bqkm3oO6PxUbZBEn0I2rq/PPBSD1Z0XhI"
+)
+
+func TestIsCloudInitKey(t *testing.T) {
+	for _, key := range []string{"ciuser", "cipassword", "ipconfig0", "ipconfig12", "nameserver", "searchdomain", "sshkeys", "CIUser"} {
+		assert.True(t, IsCloudInitKey(key), key)
+	}
+	for _, key := range []string{"ipconfig", "net0", "ide2", "description"} {
+		assert.False(t, IsCloudInitKey(key), key)
+	}
+}
+
+func TestParseSSHKeys(t *testing.T) {
+	// Proxmox stores the keys percent-encoded
+	encoded := "ssh-ed25519%20AAAAC3NzaC1lZDI1NTE5AAAAIDPyhInKSZyNL1hZ5EhV6XtKp6c38F3agDaVWgDGoXIg%20alice%40laptop%0A" +
+		"%23%20old%20key%0A" +
+		"from%3D%2210.0.0.0%2F8%22%20ssh-ed25519%20AAAAC3NzaC1lZDI1NTE5AAAAIDPyhInKSZyNL1hZ5EhV6XtKp6c38F3agDaVWgDGoXIg%0A" +
+		"ssh-rsa%20not-base64!%20bob%0A"
+
+	keys := ParseSSHKeys(encoded)
+
+	assert.Equal(t, []SSHKey{
+		{Type: "ssh-ed25519", Comment: "alice@laptop", Fingerprint: testKeyFingerprint},
+		{Type: "ssh-ed25519", Fingerprint: testKeyFingerprint},
+		{Type: "invalid"},
+	}, keys)
+}
+
+func TestParseCloudInit(t *testing.T) {
+	config := map[string]interface{}{
+		"ide2":         "local-lvm:vm-100-cloudinit,media=cdrom",
+		"scsi0":        "local-lvm:vm-100-disk-0,size=32G",
+		"ciuser":       "admin",
+		"cipassword":   "$5$secret",
+		"ipconfig10":   "ip=dhcp",
+		"ipconfig0":    "ip=10.0.0.5/24,gw=10.0.0.1",
+		"ipconfig2":    "ip6=auto",
+		"nameserver":   "10.0.0.1",
+		"searchdomain": "lab.local",
+		"sshkeys":      "ssh-ed25519%20AAAAC3NzaC1lZDI1NTE5AAAAIDPyhInKSZyNL1hZ5EhV6XtKp6c38F3agDaVWgDGoXIg%20alice%40laptop",
+		"cores":        float64(2),
+	}
+
+	ci, ok := ParseCloudInit(config)
+
+	assert.True(t, ok)
+	assert.Equal(t, CloudInit{
+		User:     "admin",
+		Password: true,
+		IPConfigs: []Property{
+			{Key: "ipconfig0", Value: "ip=10.0.0.5/24,gw=10.0.0.1"},
+			{Key: "ipconfig2", Value: "ip6=auto"},
+			{Key: "ipconfig10", Value: "ip=dhcp"},
+		},
+		Nameserver:   "10.0.0.1",
+		SearchDomain: "lab.local",
+		SSHKeys:      []SSHKey{{Type: "ssh-ed25519", Comment: "alice@laptop", Fingerprint: testKeyFingerprint}},
+		Drive:        "ide2",
+		Storage:      "local-lvm",
+	}, ci)
+}
+
+func TestParseCloudInit_None(t *testing.T) {
+	_, ok := ParseCloudInit(map[string]interface{}{
+		"ide2":  "local:iso/debian.iso,media=cdrom",
+		"scsi0": "local-lvm:vm-100-disk-0,size=32G",
+	})
+	assert.False(t, ok)
+
+	ci, ok := ParseCloudInit(map[string]interface{}{"ide0": "local-lvm:vm-104-cloudinit,media=cdrom"})
+	assert.True(t, ok, "A bare cloud-init drive counts")
+	assert.Equal(t, "ide0", ci.Drive)
+	assert.False(t, ci.Password)
+}
@@ -0,0 +1,493 @@
+// Package configparse parses the property strings Proxmox uses for disk,
+// network and mount point entries in guest configurations, e.g.
+// "local-lvm:vm-104-disk-0,iothread=1,size=32G".
+package configparse
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Property is a single key=value entry; flags without "=" have an empty Value
+type Property struct {
+	Key   string
+	Value string
+}
+
+// PropertyString is a parsed comma-separated property list
+type PropertyString struct {
+	Default    string     // Leading value without a key, if any
+	Properties []Property // Remaining entries in their original order
+}
+
+// Get returns the value of the first property with the given key
+func (p PropertyString) Get(key string) (string, bool) {
+	for _, prop := range p.Properties {
+		if prop.Key == key {
+			return prop.Value, true
+		}
+	}
+	return "", false
+}
+
+// Parse splits a property string on commas, honouring double-quoted values
+// (which may contain commas and backslash escapes). A first entry without
+// "=" is returned as Default.
+func Parse(s string) PropertyString {
+	var result PropertyString
+	for i, field := range splitFields(s) {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			if i == 0 {
+				result.Default = unquote(field)
+				continue
+			}
+			result.Properties = append(result.Properties, Property{Key: strings.TrimSpace(field)})
+			continue
+		}
+		result.Properties = append(result.Properties, Property{
+			Key:   strings.TrimSpace(key),
+			Value: unquote(value),
+		})
+	}
+	return result
+}
+
+// splitFields splits on commas outside double quotes, dropping empty fields
+func splitFields(s string) []string {
+	var fields []string
+	var current strings.Builder
+	inQuotes, escaped := false, false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && inQuotes:
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ',' && !inQuotes:
+			if field := strings.TrimSpace(current.String()); field != "" {
+				fields = append(fields, field)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if field := strings.TrimSpace(current.String()); field != "" {
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// unquote strips surrounding double quotes and resolves backslash escapes
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range s[1 : len(s)-1] {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var (
+	diskKeyPattern      = regexp.MustCompile(`^(scsi|virtio|ide|sata|mp|unused)\d+$|^(rootfs|efidisk0|tpmstate0)$`)
+	allocatedKeyPattern = regexp.MustCompile(`^(scsi|virtio|ide|sata|mp)\d+$|^rootfs$`)
+	nicKeyPattern       = regexp.MustCompile(`^net\d+$`)
+)
+
+// IsDiskKey reports whether a config key holds a disk or mount point
+func IsDiskKey(key string) bool {
+	return diskKeyPattern.MatchString(strings.ToLower(key))
+}
+
+// IsNICKey reports whether a config key holds a network interface
+func IsNICKey(key string) bool {
+	return nicKeyPattern.MatchString(strings.ToLower(key))
+}
+
+// Disk is a parsed disk, rootfs or mount point entry
+type Disk struct {
+	Volume     string // e.g. local-lvm:vm-104-disk-0, or "none" for an empty drive
+	Storage    string // Storage part of Volume, empty for paths and "none"
+	Size       string // Raw size as configured, e.g. 32G
+	Media      string // e.g. cdrom; empty for regular disks
+	MountPoint string // mp= for container mount points
+	Options    []Property
+}
+
+// ParseDisk parses a qemu disk or lxc rootfs/mount point string
+func ParseDisk(s string) Disk {
+	ps := Parse(s)
+	disk := Disk{Volume: ps.Default}
+
+	for _, prop := range ps.Properties {
+		switch prop.Key {
+		case "file", "volume":
+			disk.Volume = prop.Value
+		case "size":
+			disk.Size = prop.Value
+		case "media":
+			disk.Media = prop.Value
+		case "mp":
+			disk.MountPoint = prop.Value
+		default:
+			disk.Options = append(disk.Options, prop)
+		}
+	}
+
+	// Paths and passthrough devices have no storage prefix
+	if storage, _, found := strings.Cut(disk.Volume, ":"); found && !strings.HasPrefix(disk.Volume, "/") {
+		disk.Storage = storage
+	}
+	return disk
+}
+
+// SizeBytes returns the configured size in bytes, or false when the disk
+// has no size (cloud-init drives, CD-ROMs) or it can't be parsed
+func (d Disk) SizeBytes() (int64, bool) {
+	if d.Size == "" {
+		return 0, false
+	}
+	size, err := ParseSize(d.Size)
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+// qemuNICModels are the keys qemu uses to carry the NIC model and MAC
+var qemuNICModels = map[string]bool{
+	"virtio": true, "e1000": true, "e1000e": true, "rtl8139": true, "vmxnet3": true,
+	"ne2k_pci": true, "ne2k_isa": true, "pcnet": true, "i82551": true, "i82557b": true,
+	"i82559er": true, "e1000-82540em": true, "e1000-82544gc": true, "e1000-82545em": true,
+}
+
+// NIC is a parsed qemu or lxc network interface entry
+type NIC struct {
+	Model    string // qemu model (virtio, e1000, ...) or lxc type (veth)
+	Name     string // lxc interface name, e.g. eth0
+	MAC      string
+	Bridge   string
+	Tag      string // VLAN tag
+	Firewall bool
+	Options  []Property
+}
+
+// ParseNIC parses a qemu "virtio=MAC,bridge=..." or lxc "name=eth0,hwaddr=..." string
+func ParseNIC(s string) NIC {
+	ps := Parse(s)
+	var nic NIC
+	if ps.Default != "" {
+		nic.Model = ps.Default
+	}
+
+	for _, prop := range ps.Properties {
+		switch {
+		case qemuNICModels[prop.Key]:
+			nic.Model = prop.Key
+			nic.MAC = prop.Value
+		case prop.Key == "model":
+			nic.Model = prop.Value
+		case prop.Key == "macaddr" || prop.Key == "hwaddr":
+			nic.MAC = prop.Value
+		case prop.Key == "type":
+			nic.Model = prop.Value
+		case prop.Key == "name":
+			nic.Name = prop.Value
+		case prop.Key == "bridge":
+			nic.Bridge = prop.Value
+		case prop.Key == "tag":
+			nic.Tag = prop.Value
+		case prop.Key == "firewall":
+			nic.Firewall = prop.Value == "1"
+		default:
+			nic.Options = append(nic.Options, prop)
+		}
+	}
+	return nic
+}
+
+// Summary returns the bridge and VLAN tag of the interface, e.g.
+// vmbr0.30, or just the bridge when untagged
+func (n NIC) Summary() string {
+	if n.Tag == "" {
+		return n.Bridge
+	}
+	return n.Bridge + "." + n.Tag
+}
+
+// ParseNICs returns the network interfaces of a guest config, net0 first
+func ParseNICs(config map[string]interface{}) []NIC {
+	type indexed struct {
+		index int
+		nic   NIC
+	}
+	var found []indexed
+	for key, value := range config {
+		str, ok := value.(string)
+		if !ok || !IsNICKey(key) {
+			continue
+		}
+		index, _ := strconv.Atoi(strings.TrimPrefix(strings.ToLower(key), "net"))
+		found = append(found, indexed{index, ParseNIC(str)})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].index < found[j].index })
+
+	nics := make([]NIC, 0, len(found))
+	for _, f := range found {
+		nics = append(nics, f.nic)
+	}
+	return nics
+}
+
+// Owner returns what re extracts from the description (notes) of a guest
+// config: its first group, or the whole match when it has none. It
+// returns "" when the config has no description or re doesn't match it.
+func Owner(config map[string]interface{}, re *regexp.Regexp) string {
+	description, _ := config["description"].(string)
+	match := re.FindStringSubmatch(description)
+	switch {
+	case match == nil:
+		return ""
+	case len(match) > 1:
+		return strings.TrimSpace(match[1])
+	}
+	return strings.TrimSpace(match[0])
+}
+
+// Tags returns the tags of a guest config, in order and without
+// duplicates. Proxmox stores them separated by semicolons but accepts
+// commas and spaces too.
+func Tags(config map[string]interface{}) []string {
+	value, _ := config["tags"].(string)
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == ',' || r == ' '
+	}) {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// SerialPorts returns the serial ports of a VM config (serial0 to
+// serial3) in order
+func SerialPorts(config map[string]interface{}) []string {
+	var ports []string
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("serial%d", i)
+		if _, ok := config[key]; ok {
+			ports = append(ports, key)
+		}
+	}
+	return ports
+}
+
+// AgentEnabled reports whether an agent config value ("1",
+// "1,fstrim_cloned_disks=1", "enabled=1,type=virtio") turns the QEMU guest agent on
+func AgentEnabled(value string) bool {
+	ps := Parse(value)
+	if enabled, ok := ps.Get("enabled"); ok {
+		return enabled == "1"
+	}
+	return ps.Default == "1"
+}
+
+// Flags are the settings of a guest config that say whether it is looked
+// after: its QEMU guest agent, starting with its node and protection from
+// removal
+type Flags struct {
+	Agent     bool // QEMU guest agent enabled; always false for containers, which have none
+	OnBoot    bool // Started when its node boots
+	Protected bool // Protected from removal and disk changes
+}
+
+// ParseFlags reads the Flags of a guest config. Options left out are off,
+// as Proxmox defaults them; values come as numbers or strings.
+func ParseFlags(config map[string]interface{}) Flags {
+	var flags Flags
+	if agent, ok := config["agent"]; ok {
+		flags.Agent = AgentEnabled(configString(agent))
+	}
+	flags.OnBoot = configString(config["onboot"]) == "1"
+	flags.Protected = configString(config["protection"]) == "1"
+	return flags
+}
+
+// Count returns how many of the flags are set
+func (f Flags) Count() int {
+	n := 0
+	for _, set := range []bool{f.Agent, f.OnBoot, f.Protected} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// HasVMState reports whether a VM config holds the volume its memory was
+// saved to, which it keeps while hibernated
+func HasVMState(config map[string]interface{}) bool {
+	return configString(config["vmstate"]) != ""
+}
+
+// configString returns a decoded config value as the string Proxmox
+// stores, or "" for a value no option takes
+func configString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		if val {
+			return "1"
+		}
+		return "0"
+	}
+	return ""
+}
+
+// AllocatedDiskSize sums the configured sizes of a guest's disks: qemu
+// scsi/virtio/ide/sata drives, or an lxc rootfs plus its mount points.
+// CD-ROMs, unused volumes and entries without a size are skipped.
+func AllocatedDiskSize(config map[string]interface{}) int64 {
+	var total int64
+	for key, value := range config {
+		if !allocatedKeyPattern.MatchString(strings.ToLower(key)) {
+			continue
+		}
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		disk := ParseDisk(str)
+		if disk.Media == "cdrom" {
+			continue
+		}
+		if size, ok := disk.SizeBytes(); ok {
+			total += size
+		}
+	}
+	return total
+}
+
+// FormatSize renders bytes in the compact unit style used by Proxmox
+// (e.g. 32G, 1.5T), the inverse of ParseSize
+func FormatSize(bytes int64) string {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"T", 1 << 40},
+		{"G", 1 << 30},
+		{"M", 1 << 20},
+		{"K", 1 << 10},
+	}
+	for _, unit := range units {
+		if bytes >= unit.size {
+			value := float64(bytes) / float64(unit.size)
+			if bytes%unit.size == 0 {
+				return fmt.Sprintf("%d%s", bytes/unit.size, unit.suffix)
+			}
+			return fmt.Sprintf("%.1f%s", value, unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%d", bytes)
+}
+
+// ParseSize converts a Proxmox size such as 32G, 512M or 1T to bytes;
+// a bare number is taken as bytes
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	multiplier := int64(1)
+	switch s[len(s)-1] {
+	case 'K', 'k':
+		multiplier = 1 << 10
+	case 'M', 'm':
+		multiplier = 1 << 20
+	case 'G', 'g':
+		multiplier = 1 << 30
+	case 'T', 't':
+		multiplier = 1 << 40
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// Startup is a parsed "startup" option, which orders guests started at boot
+type Startup struct {
+	Order    int // Position in the boot sequence, lowest first
+	HasOrder bool
+	Up       int // Seconds to wait after starting this guest
+	Down     int // Seconds to wait after stopping it
+}
+
+// ParseStartup parses a startup option such as "order=2,up=30,down=60". A
+// leading number without a key is the order, as Proxmox accepts it.
+func ParseStartup(s string) (Startup, error) {
+	var startup Startup
+	ps := Parse(s)
+
+	seconds := func(key, value string) (int, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid startup %s %q", key, value)
+		}
+		return n, nil
+	}
+
+	if ps.Default != "" {
+		ps.Properties = append([]Property{{Key: "order", Value: ps.Default}}, ps.Properties...)
+	}
+	for _, prop := range ps.Properties {
+		var err error
+		switch prop.Key {
+		case "order":
+			startup.Order, err = strconv.Atoi(prop.Value)
+			if err != nil {
+				return Startup{}, fmt.Errorf("invalid startup order %q", prop.Value)
+			}
+			startup.HasOrder = true
+		case "up":
+			startup.Up, err = seconds(prop.Key, prop.Value)
+		case "down":
+			startup.Down, err = seconds(prop.Key, prop.Value)
+		default:
+			err = fmt.Errorf("unknown startup option %q", prop.Key)
+		}
+		if err != nil {
+			return Startup{}, err
+		}
+	}
+	return startup, nil
+}
@@ -0,0 +1,474 @@
+package configparse
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected PropertyString
+	}{
+		{
+			name:  "bare first value",
+			input: "local-lvm:vm-104-disk-0,iothread=1,size=32G",
+			expected: PropertyString{
+				Default: "local-lvm:vm-104-disk-0",
+				Properties: []Property{
+					{Key: "iothread", Value: "1"},
+					{Key: "size", Value: "32G"},
+				},
+			},
+		},
+		{
+			name:  "no default",
+			input: "virtio=BC:24:11:2E:6A:01,bridge=vmbr0,firewall=1",
+			expected: PropertyString{
+				Properties: []Property{
+					{Key: "virtio", Value: "BC:24:11:2E:6A:01"},
+					{Key: "bridge", Value: "vmbr0"},
+					{Key: "firewall", Value: "1"},
+				},
+			},
+		},
+		{
+			name:  "quoted value with comma and escape",
+			input: `local:iso/debian.iso,media=cdrom,comment="a, \"b\""`,
+			expected: PropertyString{
+				Default: "local:iso/debian.iso",
+				Properties: []Property{
+					{Key: "media", Value: "cdrom"},
+					{Key: "comment", Value: `a, "b"`},
+				},
+			},
+		},
+		{
+			name:  "bare flag after first value",
+			input: "/dev/disk/by-id/ata-X,backup,size=1T",
+			expected: PropertyString{
+				Default: "/dev/disk/by-id/ata-X",
+				Properties: []Property{
+					{Key: "backup"},
+					{Key: "size", Value: "1T"},
+				},
+			},
+		},
+		{
+			name:     "empty fields and whitespace",
+			input:    " none , ,media=cdrom,",
+			expected: PropertyString{Default: "none", Properties: []Property{{Key: "media", Value: "cdrom"}}},
+		},
+		{
+			name:     "empty string",
+			input:    "",
+			expected: PropertyString{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Parse(tt.input))
+		})
+	}
+}
+
+func TestPropertyString_Get(t *testing.T) {
+	ps := Parse("local-lvm:vm-100-disk-0,size=8G,ssd=1")
+
+	value, ok := ps.Get("size")
+	assert.True(t, ok)
+	assert.Equal(t, "8G", value)
+
+	_, ok = ps.Get("discard")
+	assert.False(t, ok)
+}
+
+func TestParseDisk(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Disk
+	}{
+		{
+			name:  "qemu scsi disk",
+			input: "local-lvm:vm-104-disk-0,iothread=1,size=32G",
+			expected: Disk{
+				Volume:  "local-lvm:vm-104-disk-0",
+				Storage: "local-lvm",
+				Size:    "32G",
+				Options: []Property{{Key: "iothread", Value: "1"}},
+			},
+		},
+		{
+			name:  "cdrom",
+			input: "local:iso/debian-12.iso,media=cdrom,size=628M",
+			expected: Disk{
+				Volume:  "local:iso/debian-12.iso",
+				Storage: "local",
+				Size:    "628M",
+				Media:   "cdrom",
+			},
+		},
+		{
+			name:     "empty cdrom",
+			input:    "none,media=cdrom",
+			expected: Disk{Volume: "none", Media: "cdrom"},
+		},
+		{
+			name:     "cloud-init drive",
+			input:    "local-lvm:vm-104-cloudinit,media=cdrom",
+			expected: Disk{Volume: "local-lvm:vm-104-cloudinit", Storage: "local-lvm", Media: "cdrom"},
+		},
+		{
+			name:     "lxc rootfs",
+			input:    "local-zfs:subvol-200-disk-0,size=8G",
+			expected: Disk{Volume: "local-zfs:subvol-200-disk-0", Storage: "local-zfs", Size: "8G"},
+		},
+		{
+			name:  "lxc mount point",
+			input: "tank:subvol-200-disk-1,mp=/srv/data,backup=1,size=100G",
+			expected: Disk{
+				Volume:     "tank:subvol-200-disk-1",
+				Storage:    "tank",
+				Size:       "100G",
+				MountPoint: "/srv/data",
+				Options:    []Property{{Key: "backup", Value: "1"}},
+			},
+		},
+		{
+			name:     "bind mount",
+			input:    "/mnt/host/media,mp=/media",
+			expected: Disk{Volume: "/mnt/host/media", MountPoint: "/media"},
+		},
+		{
+			name:     "explicit file key",
+			input:    "file=ceph:vm-300-disk-1,size=64G",
+			expected: Disk{Volume: "ceph:vm-300-disk-1", Storage: "ceph", Size: "64G"},
+		},
+		{
+			name:  "efidisk",
+			input: "local-lvm:vm-104-disk-1,efitype=4m,pre-enrolled-keys=1,size=4M",
+			expected: Disk{
+				Volume:  "local-lvm:vm-104-disk-1",
+				Storage: "local-lvm",
+				Size:    "4M",
+				Options: []Property{{Key: "efitype", Value: "4m"}, {Key: "pre-enrolled-keys", Value: "1"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseDisk(tt.input))
+		})
+	}
+}
+
+func TestDisk_SizeBytes(t *testing.T) {
+	size, ok := ParseDisk("local-lvm:vm-104-disk-0,size=32G").SizeBytes()
+	assert.True(t, ok)
+	assert.Equal(t, int64(32<<30), size)
+
+	_, ok = ParseDisk("none,media=cdrom").SizeBytes()
+	assert.False(t, ok)
+
+	_, ok = ParseDisk("local-lvm:vm-104-disk-0,size=huge").SizeBytes()
+	assert.False(t, ok)
+}
+
+func TestParseNIC(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected NIC
+	}{
+		{
+			name:  "qemu virtio",
+			input: "virtio=BC:24:11:2E:6A:01,bridge=vmbr0,firewall=1",
+			expected: NIC{
+				Model:    "virtio",
+				MAC:      "BC:24:11:2E:6A:01",
+				Bridge:   "vmbr0",
+				Firewall: true,
+			},
+		},
+		{
+			name:  "qemu e1000 with vlan and options",
+			input: "e1000=AA:BB:CC:DD:EE:FF,bridge=vmbr1,tag=30,firewall=0,rate=12.5,mtu=1500",
+			expected: NIC{
+				Model:   "e1000",
+				MAC:     "AA:BB:CC:DD:EE:FF",
+				Bridge:  "vmbr1",
+				Tag:     "30",
+				Options: []Property{{Key: "rate", Value: "12.5"}, {Key: "mtu", Value: "1500"}},
+			},
+		},
+		{
+			name:  "lxc veth",
+			input: "name=eth0,bridge=vmbr0,firewall=1,hwaddr=BC:24:11:9F:00:01,ip=dhcp,type=veth",
+			expected: NIC{
+				Model:    "veth",
+				Name:     "eth0",
+				MAC:      "BC:24:11:9F:00:01",
+				Bridge:   "vmbr0",
+				Firewall: true,
+				Options:  []Property{{Key: "ip", Value: "dhcp"}},
+			},
+		},
+		{
+			name:     "explicit model key",
+			input:    "model=virtio,macaddr=AA:BB:CC:00:11:22,bridge=vmbr0",
+			expected: NIC{Model: "virtio", MAC: "AA:BB:CC:00:11:22", Bridge: "vmbr0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseNIC(tt.input))
+		})
+	}
+}
+
+func TestParseNICs(t *testing.T) {
+	nics := ParseNICs(map[string]interface{}{
+		"net10": "virtio=BC:24:11:00:00:03,bridge=vmbr1",
+		"net0":  "virtio=BC:24:11:00:00:01,bridge=vmbr0,tag=30",
+		"net2":  "name=eth1,bridge=vmbr0,hwaddr=BC:24:11:00:00:02,type=veth",
+		"scsi0": "local-lvm:vm-100-disk-0,size=32G",
+	})
+
+	var summaries []string
+	for _, nic := range nics {
+		summaries = append(summaries, nic.Summary())
+	}
+	assert.Equal(t, []string{"vmbr0.30", "vmbr0", "vmbr1"}, summaries)
+	assert.Empty(t, ParseNICs(map[string]interface{}{"cores": 2}))
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{"32G", 32 << 30, false},
+		{"512M", 512 << 20, false},
+		{"1T", 1 << 40, false},
+		{"100K", 100 << 10, false},
+		{"1.5G", 3 << 29, false},
+		{"4096", 4096, false},
+		{"", 0, true},
+		{"G", 0, true},
+		{"abc", 0, true},
+		{"-1G", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			size, err := ParseSize(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, size)
+		})
+	}
+}
+
+func TestIsDiskKey(t *testing.T) {
+	for _, key := range []string{"scsi0", "virtio12", "ide2", "sata5", "rootfs", "mp0", "efidisk0", "tpmstate0", "unused3"} {
+		assert.True(t, IsDiskKey(key), key)
+	}
+	for _, key := range []string{"scsihw", "net0", "memory", "mp", "sockets"} {
+		assert.False(t, IsDiskKey(key), key)
+	}
+}
+
+func TestIsNICKey(t *testing.T) {
+	assert.True(t, IsNICKey("net0"))
+	assert.True(t, IsNICKey("net31"))
+	assert.False(t, IsNICKey("net"))
+	assert.False(t, IsNICKey("nameserver"))
+}
+
+func TestAllocatedDiskSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		expected int64
+	}{
+		{
+			name: "qemu with cdrom and cloud-init",
+			config: map[string]interface{}{
+				"scsi0":    "local-lvm:vm-104-disk-0,iothread=1,size=32G",
+				"virtio1":  "local-lvm:vm-104-disk-1,size=512M",
+				"sata2":    "tank:vm-104-disk-2,size=1T",
+				"ide2":     "local:iso/debian.iso,media=cdrom,size=628M",
+				"ide0":     "local-lvm:vm-104-cloudinit,media=cdrom",
+				"unused0":  "local-lvm:vm-104-disk-9,size=8G",
+				"efidisk0": "local-lvm:vm-104-disk-3,size=4M",
+				"memory":   4096.0,
+				"scsihw":   "virtio-scsi-single",
+			},
+			expected: 32<<30 + 512<<20 + 1<<40,
+		},
+		{
+			name: "lxc rootfs and mount points",
+			config: map[string]interface{}{
+				"rootfs": "local-zfs:subvol-200-disk-0,size=8G",
+				"mp0":    "tank:subvol-200-disk-1,mp=/srv/data,size=100G",
+				"mp1":    "/mnt/host/media,mp=/media",
+			},
+			expected: 108 << 30,
+		},
+		{
+			name:     "no disks",
+			config:   map[string]interface{}{"cores": 2.0},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, AllocatedDiskSize(tt.config))
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{0, "0"},
+		{512, "512"},
+		{4 << 20, "4M"},
+		{32 << 30, "32G"},
+		{3 << 29, "1.5G"},
+		{1<<40 + 32<<30, "1.0T"},
+		{2 << 40, "2T"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, FormatSize(tt.bytes))
+		})
+	}
+}
+
+func TestAgentEnabled(t *testing.T) {
+	for _, value := range []string{"1", "1,fstrim_cloned_disks=1", "enabled=1,type=virtio"} {
+		assert.True(t, AgentEnabled(value), value)
+	}
+	for _, value := range []string{"0", "", "enabled=0", "0,type=isa"} {
+		assert.False(t, AgentEnabled(value), value)
+	}
+}
+
+func TestParseStartup(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Startup
+		wantErr  bool
+	}{
+		{"order=2,up=30,down=60", Startup{Order: 2, HasOrder: true, Up: 30, Down: 60}, false},
+		{"order=1", Startup{Order: 1, HasOrder: true}, false},
+		{"3,up=10", Startup{Order: 3, HasOrder: true, Up: 10}, false},
+		{"up=15", Startup{Up: 15}, false},
+		{" order = 4 , down=5 ", Startup{Order: 4, HasOrder: true, Down: 5}, false},
+		{"", Startup{}, false},
+		{"order=first", Startup{}, true},
+		{"up=-1", Startup{}, true},
+		{"up=soon", Startup{}, true},
+		{"order=1,delay=5", Startup{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			startup, err := ParseStartup(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, startup)
+		})
+	}
+}
+
+func TestOwner(t *testing.T) {
+	re := regexp.MustCompile(`(?i)owner:\s*(\S+)`)
+	tests := []struct {
+		name        string
+		description interface{}
+		want        string
+	}{
+		{"single line", "owner: alice", "alice"},
+		{"later line", "Web front end\n\nOwner: Bob\nticket: OPS-12", "Bob"},
+		{"windows line ends", "Build agent\r\nowner:carol\r\n", "carol"},
+		{"first of several", "owner: dave\nowner: erin", "dave"},
+		{"no owner", "Web front end\nticket: OPS-12", ""},
+		{"no description", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]interface{}{"name": "web"}
+			if tt.description != nil {
+				config["description"] = tt.description
+			}
+			assert.Equal(t, tt.want, Owner(config, re))
+		})
+	}
+
+	// Without a group, the whole match is the owner
+	config := map[string]interface{}{"description": "team-infra\nowner=frank"}
+	assert.Equal(t, "team-infra", Owner(config, regexp.MustCompile(`team-\w+`)))
+}
+
+func TestTags(t *testing.T) {
+	assert.Equal(t, []string{"prod", "web", "db"}, Tags(map[string]interface{}{"tags": "prod;web, db web"}))
+	assert.Empty(t, Tags(map[string]interface{}{"tags": ""}))
+	assert.Empty(t, Tags(map[string]interface{}{"name": "web"}))
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   Flags
+	}{
+		{"all set", map[string]interface{}{"agent": "1,fstrim_cloned_disks=1", "onboot": float64(1), "protection": float64(1)},
+			Flags{Agent: true, OnBoot: true, Protected: true}},
+		{"none set", map[string]interface{}{"name": "web", "memory": float64(2048)}, Flags{}},
+		{"explicitly off", map[string]interface{}{"agent": "enabled=0", "onboot": float64(0), "protection": "0"}, Flags{}},
+		{"numeric agent", map[string]interface{}{"agent": float64(1)}, Flags{Agent: true}},
+		{"string onboot", map[string]interface{}{"onboot": "1"}, Flags{OnBoot: true}},
+		{"container", map[string]interface{}{"hostname": "ct", "onboot": float64(1), "protection": true}, Flags{OnBoot: true, Protected: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseFlags(tt.config))
+		})
+	}
+}
+
+func TestHasVMState(t *testing.T) {
+	assert.True(t, HasVMState(map[string]interface{}{"vmstate": "local-lvm:vm-100-state-suspend-2026-10-17"}))
+	assert.False(t, HasVMState(map[string]interface{}{"name": "web"}))
+}
+
+func TestFlags_Count(t *testing.T) {
+	assert.Zero(t, Flags{}.Count())
+	assert.Equal(t, 2, Flags{Agent: true, Protected: true}.Count())
+	assert.Equal(t, 3, Flags{Agent: true, OnBoot: true, Protected: true}.Count())
+}
+
+func TestSerialPorts(t *testing.T) {
+	assert.Equal(t, []string{"serial0", "serial2"}, SerialPorts(map[string]interface{}{"serial2": "/dev/ttyS2", "serial0": "socket", "net0": "virtio"}))
+	assert.Empty(t, SerialPorts(map[string]interface{}{"name": "web"}))
+}
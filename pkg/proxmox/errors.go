@@ -1,8 +1,85 @@
 package proxmox
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/redact"
+)
 
 var (
 	// ErrNodeNotFound is returned when a VM/CT is not found in the cache
 	ErrNodeNotFound = errors.New("node not found in cache")
+	// ErrUnauthorized is returned when the API rejects the credentials (HTTP 401)
+	ErrUnauthorized = errors.New("authentication failed")
+	// ErrForbidden is returned when the token lacks privileges for an endpoint (HTTP 403)
+	ErrForbidden = errors.New("permission denied")
+	// ErrNoWakeOnLAN is returned by WakeNode when the node has no wake-on-LAN MAC configured
+	ErrNoWakeOnLAN = errors.New("no wake-on-LAN MAC configured")
 )
+
+// APIError describes a non-200 response returned by the Proxmox API
+type APIError struct {
+	StatusCode int    // HTTP status code
+	Method     string // HTTP method of the failed request
+	Path       string // API path relative to /api2/json
+	Body       string // Response body, trimmed
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap maps authentication and authorization failures to their sentinel errors
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	}
+	return nil
+}
+
+// newAPIError builds an APIError from a failed response, consuming its
+// body. Secrets a proxy or server echoes back are masked.
+func newAPIError(resp *http.Response, method, path string) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     method,
+		Path:       path,
+		Body:       redact.String(strings.TrimSpace(string(body))),
+	}
+}
+
+// IsUnauthorized reports whether err was caused by an HTTP 401 response
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsForbidden reports whether err was caused by an HTTP 403 response
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden)
+}
+
+// DeniedPath returns the API path of the request that produced err, if known
+func DeniedPath(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Path
+	}
+	return ""
+}
+
+// WakeOnLANHint tells how to fix ErrNoWakeOnLAN for a node
+func WakeOnLANHint(node string) string {
+	return fmt.Sprintf("set the MAC of %s with 'pvenode config set --wakeonlan <MAC>' on that node", node)
+}
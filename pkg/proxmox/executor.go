@@ -2,80 +2,132 @@ package proxmox
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/tsupplis/pvec/pkg/actions"
 	"github.com/tsupplis/pvec/pkg/models"
 )
 
-// ActionExecutor adapts the Proxmox Client to the actions.Executor interface
+// ActionExecutor adapts a PowerController to the actions.Executor
+// interface, looking each guest's node and type up in a shared list. With
+// several clusters, each guest's action goes to the client of its
+// Cluster.
 type ActionExecutor struct {
-	client Client
-	nodes  map[string]*models.VMStatus // Cache for node lookups
+	clients map[string]PowerController // By cluster name; "" for a single cluster
+	guests  models.NodeList            // Kept current by whoever refreshes it
 }
 
-// NewActionExecutor creates a new action executor
-func NewActionExecutor(client Client) actions.Executor {
-	return &ActionExecutor{
-		client: client,
-		nodes:  make(map[string]*models.VMStatus),
+// clusterClients is implemented by clients spanning several clusters,
+// such as Aggregate
+type clusterClients interface {
+	Clients() map[string]PowerController
+}
+
+// NewActionExecutor creates a new action executor over the guests list.
+// A client spanning several clusters is split into its clusters' clients.
+func NewActionExecutor(client PowerController, guests models.NodeList) actions.Executor {
+	if multi, ok := client.(clusterClients); ok {
+		return NewClusterActionExecutor(multi.Clients(), guests)
 	}
+	return NewClusterActionExecutor(map[string]PowerController{"": client}, guests)
 }
 
-// UpdateNodes updates the internal cache of nodes
-func (e *ActionExecutor) UpdateNodes(nodes []*models.VMStatus) {
-	e.nodes = make(map[string]*models.VMStatus)
-	for _, node := range nodes {
-		e.nodes[node.VMID] = node
+// NewClusterActionExecutor creates an action executor that sends each
+// guest's actions to the client of its Cluster
+func NewClusterActionExecutor(clients map[string]PowerController, guests models.NodeList) actions.Executor {
+	return &ActionExecutor{
+		clients: clients,
+		guests:  guests,
 	}
 }
 
-// getNodeInfo retrieves node information from cache
-func (e *ActionExecutor) getNodeInfo(vmid string) (node, vmType string, found bool) {
-	vm, exists := e.nodes[vmid]
+// getNodeInfo looks the guest up in the list by its key
+func (e *ActionExecutor) getNodeInfo(key string) (node, vmType string, found bool) {
+	vm, exists := e.guests.Get(key)
 	if !exists {
 		return "", "", false
 	}
 
 	typeStr := "qemu"
-	if vm.Type == string(models.TypeContainer) {
+	if vm.Type == models.TypeContainer {
 		typeStr = "lxc"
 	}
 
 	return vm.Node, typeStr, true
 }
 
-// Start starts a VM or Container
-func (e *ActionExecutor) Start(ctx context.Context, vmid string) error {
-	node, vmType, found := e.getNodeInfo(vmid)
+// target returns the client of the guest's cluster, and everything it
+// needs to act on the guest
+func (e *ActionExecutor) target(key string) (client PowerController, node, vmType, vmid string, err error) {
+	node, vmType, found := e.getNodeInfo(key)
 	if !found {
-		return ErrNodeNotFound
+		return nil, "", "", "", ErrNodeNotFound
+	}
+	cluster, vmid := models.SplitKey(key)
+	client, found = e.clients[cluster]
+	if !found {
+		return nil, "", "", "", fmt.Errorf("%w: no client for cluster %q", ErrNodeNotFound, cluster)
+	}
+	return client, node, vmType, vmid, nil
+}
+
+// Start starts a VM or Container
+func (e *ActionExecutor) Start(ctx context.Context, key string) error {
+	client, node, vmType, vmid, err := e.target(key)
+	if err != nil {
+		return err
 	}
-	return e.client.Start(ctx, node, vmType, vmid)
+	return client.Start(ctx, node, vmType, vmid)
 }
 
 // Shutdown gracefully shuts down a VM or Container
-func (e *ActionExecutor) Shutdown(ctx context.Context, vmid string) error {
-	node, vmType, found := e.getNodeInfo(vmid)
-	if !found {
-		return ErrNodeNotFound
+func (e *ActionExecutor) Shutdown(ctx context.Context, key string) error {
+	client, node, vmType, vmid, err := e.target(key)
+	if err != nil {
+		return err
 	}
-	return e.client.Shutdown(ctx, node, vmType, vmid)
+	return client.Shutdown(ctx, node, vmType, vmid)
 }
 
 // Reboot reboots a VM or Container
-func (e *ActionExecutor) Reboot(ctx context.Context, vmid string) error {
-	node, vmType, found := e.getNodeInfo(vmid)
-	if !found {
-		return ErrNodeNotFound
+func (e *ActionExecutor) Reboot(ctx context.Context, key string) error {
+	client, node, vmType, vmid, err := e.target(key)
+	if err != nil {
+		return err
 	}
-	return e.client.Reboot(ctx, node, vmType, vmid)
+	return client.Reboot(ctx, node, vmType, vmid)
 }
 
 // Stop forcefully stops a VM or Container
-func (e *ActionExecutor) Stop(ctx context.Context, vmid string) error {
-	node, vmType, found := e.getNodeInfo(vmid)
-	if !found {
-		return ErrNodeNotFound
+func (e *ActionExecutor) Stop(ctx context.Context, key string) error {
+	client, node, vmType, vmid, err := e.target(key)
+	if err != nil {
+		return err
+	}
+	return client.Stop(ctx, node, vmType, vmid)
+}
+
+// Resume resumes a paused VM or Container
+func (e *ActionExecutor) Resume(ctx context.Context, key string) error {
+	client, node, vmType, vmid, err := e.target(key)
+	if err != nil {
+		return err
+	}
+	return client.Resume(ctx, node, vmType, vmid)
+}
+
+// Hibernate suspends a VM to disk. Containers can't be hibernated.
+func (e *ActionExecutor) Hibernate(ctx context.Context, key string) error {
+	client, node, vmType, vmid, err := e.target(key)
+	if err != nil {
+		return err
+	}
+	hibernator, ok := client.(Hibernator)
+	if !ok {
+		return actions.ErrHibernateUnsupported
+	}
+	if vmType != "qemu" {
+		return fmt.Errorf("%w for containers", actions.ErrHibernateUnsupported)
 	}
-	return e.client.Stop(ctx, node, vmType, vmid)
+	return hibernator.Hibernate(ctx, node, vmid)
 }
@@ -2,82 +2,82 @@ package proxmox
 
 import (
 	"context"
-	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/actions"
 	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/proxmoxtest"
 )
 
-// MockClient for testing executor
-type MockClient struct {
-	StartFunc    func(ctx context.Context, node, vmType, vmid string) error
-	ShutdownFunc func(ctx context.Context, node, vmType, vmid string) error
-	RebootFunc   func(ctx context.Context, node, vmType, vmid string) error
-	StopFunc     func(ctx context.Context, node, vmType, vmid string) error
-}
+// MockClient is a Client doing nothing, for tests that never reach the
+// server
+type MockClient struct{}
 
 func (m *MockClient) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
 	return nil, nil
 }
 
+func (m *MockClient) GetGuestStatus(ctx context.Context, node, vmType, vmid string) (*models.VMStatus, error) {
+	return nil, nil
+}
+
 func (m *MockClient) GetVMConfig(ctx context.Context, node, vmType, vmid string) (map[string]interface{}, error) {
 	return nil, nil
 }
 
-func (m *MockClient) Start(ctx context.Context, node, vmType, vmid string) error {
-	if m.StartFunc != nil {
-		return m.StartFunc(ctx, node, vmType, vmid)
-	}
-	return nil
+func (m *MockClient) GetAgentFSInfo(ctx context.Context, node, vmid string) ([]models.Filesystem, error) {
+	return nil, nil
 }
 
-func (m *MockClient) Shutdown(ctx context.Context, node, vmType, vmid string) error {
-	if m.ShutdownFunc != nil {
-		return m.ShutdownFunc(ctx, node, vmType, vmid)
-	}
-	return nil
+func (m *MockClient) GetAgentHostName(ctx context.Context, node, vmid string) (string, error) {
+	return "", nil
 }
 
-func (m *MockClient) Reboot(ctx context.Context, node, vmType, vmid string) error {
-	if m.RebootFunc != nil {
-		return m.RebootFunc(ctx, node, vmType, vmid)
-	}
+func (m *MockClient) PingAgent(ctx context.Context, node, vmid string) error {
 	return nil
 }
 
-func (m *MockClient) Stop(ctx context.Context, node, vmType, vmid string) error {
-	if m.StopFunc != nil {
-		return m.StopFunc(ctx, node, vmType, vmid)
-	}
-	return nil
+func (m *MockClient) Start(ctx context.Context, node, vmType, vmid string) error    { return nil }
+func (m *MockClient) Shutdown(ctx context.Context, node, vmType, vmid string) error { return nil }
+func (m *MockClient) Reboot(ctx context.Context, node, vmType, vmid string) error   { return nil }
+func (m *MockClient) Stop(ctx context.Context, node, vmType, vmid string) error     { return nil }
+func (m *MockClient) Resume(ctx context.Context, node, vmType, vmid string) error   { return nil }
+
+// fakeCluster returns a fake server holding guests, and an executor on
+// them as a refresh lists them
+func fakeCluster(t *testing.T, guests ...proxmoxtest.Guest) (*proxmoxtest.Server, *ActionExecutor) {
+	t.Helper()
+	server := proxmoxtest.NewServer(t, proxmoxtest.WithGuests(guests...))
+	client := NewClient(server.URL, "test-token", true)
+	nodes, err := client.GetNodes(context.Background())
+	require.NoError(t, err)
+	list := models.NewNodeList()
+	list.ReplaceAll(nodes)
+	return server, NewActionExecutor(client, list).(*ActionExecutor)
+}
+
+// status returns the status of a guest of the fake server
+func status(t *testing.T, server *proxmoxtest.Server, vmid int) string {
+	t.Helper()
+	g, ok := server.Guest(vmid)
+	require.True(t, ok)
+	return g.Status
 }
 
 func TestActionExecutor_Start(t *testing.T) {
-	called := false
-	mock := &MockClient{
-		StartFunc: func(ctx context.Context, node, vmType, vmid string) error {
-			called = true
-			assert.Equal(t, "pve1", node)
-			assert.Equal(t, "qemu", vmType)
-			assert.Equal(t, "100", vmid)
-			return nil
-		},
-	}
-
-	executor := NewActionExecutor(mock).(*ActionExecutor)
-	executor.UpdateNodes([]*models.VMStatus{
-		{VMID: "100", Node: "pve1", Type: string(models.TypeVM)},
-	})
+	server, executor := fakeCluster(t, proxmoxtest.VM(100, "web", "pve1", "stopped"))
 
 	err := executor.Start(context.Background(), "100")
 	assert.NoError(t, err)
-	assert.True(t, called)
+	assert.Contains(t, server.Requests(), "POST /nodes/pve1/qemu/100/status/start")
+	assert.Equal(t, "running", status(t, server, 100))
 }
 
 func TestActionExecutor_Start_NotFound(t *testing.T) {
 	mock := &MockClient{}
-	executor := NewActionExecutor(mock).(*ActionExecutor)
+	executor := NewActionExecutor(mock, models.NewNodeList()).(*ActionExecutor)
 
 	err := executor.Start(context.Background(), "999")
 	assert.Error(t, err)
@@ -85,96 +85,86 @@ func TestActionExecutor_Start_NotFound(t *testing.T) {
 }
 
 func TestActionExecutor_Shutdown(t *testing.T) {
-	called := false
-	mock := &MockClient{
-		ShutdownFunc: func(ctx context.Context, node, vmType, vmid string) error {
-			called = true
-			assert.Equal(t, "pve1", node)
-			assert.Equal(t, "lxc", vmType)
-			assert.Equal(t, "200", vmid)
-			return nil
-		},
-	}
-
-	executor := NewActionExecutor(mock).(*ActionExecutor)
-	executor.UpdateNodes([]*models.VMStatus{
-		{VMID: "200", Node: "pve1", Type: string(models.TypeContainer)},
-	})
+	server, executor := fakeCluster(t, proxmoxtest.CT(200, "cache", "pve1", "running"))
 
 	err := executor.Shutdown(context.Background(), "200")
 	assert.NoError(t, err)
-	assert.True(t, called)
+	assert.Contains(t, server.Requests(), "POST /nodes/pve1/lxc/200/status/shutdown")
+	assert.Equal(t, "stopped", status(t, server, 200))
 }
 
 func TestActionExecutor_Reboot(t *testing.T) {
-	called := false
-	mock := &MockClient{
-		RebootFunc: func(ctx context.Context, node, vmType, vmid string) error {
-			called = true
-			return nil
-		},
-	}
-
-	executor := NewActionExecutor(mock).(*ActionExecutor)
-	executor.UpdateNodes([]*models.VMStatus{
-		{VMID: "100", Node: "pve1", Type: string(models.TypeVM)},
-	})
+	server, executor := fakeCluster(t, proxmoxtest.VM(100, "web", "pve1", "running"))
 
 	err := executor.Reboot(context.Background(), "100")
 	assert.NoError(t, err)
-	assert.True(t, called)
+	g, _ := server.Guest(100)
+	assert.Equal(t, "running", g.Status)
+	assert.Zero(t, g.Uptime, "A rebooted guest starts its uptime over")
 }
 
 func TestActionExecutor_Stop(t *testing.T) {
-	called := false
-	mock := &MockClient{
-		StopFunc: func(ctx context.Context, node, vmType, vmid string) error {
-			called = true
-			return nil
-		},
-	}
-
-	executor := NewActionExecutor(mock).(*ActionExecutor)
-	executor.UpdateNodes([]*models.VMStatus{
-		{VMID: "100", Node: "pve1", Type: string(models.TypeVM)},
-	})
+	server, executor := fakeCluster(t, proxmoxtest.VM(100, "web", "pve1", "running"))
 
 	err := executor.Stop(context.Background(), "100")
 	assert.NoError(t, err)
-	assert.True(t, called)
+	assert.Equal(t, "stopped", status(t, server, 100))
+	assert.ErrorContains(t, executor.Stop(context.Background(), "100"), "VM 100 not running",
+		"A second stop should see the guest stopped")
+}
+
+func TestActionExecutor_Resume(t *testing.T) {
+	server, executor := fakeCluster(t, proxmoxtest.CT(200, "cache", "pve2", "paused"))
+
+	err := executor.Resume(context.Background(), "200")
+	assert.NoError(t, err)
+	assert.Contains(t, server.Requests(), "POST /nodes/pve2/lxc/200/status/resume")
+	assert.Equal(t, "running", status(t, server, 200))
+}
+
+func TestActionExecutor_Hibernate(t *testing.T) {
+	server, executor := fakeCluster(t,
+		proxmoxtest.VM(100, "web", "pve1", "running"), proxmoxtest.CT(200, "cache", "pve2", "running"))
+
+	require.NoError(t, executor.Hibernate(context.Background(), "100"))
+	g, _ := server.Guest(100)
+	assert.Equal(t, "stopped", g.Status)
+	assert.Equal(t, "suspended", g.Lock, "A VM hibernated to disk is locked until resumed")
+	assert.ErrorIs(t, executor.Hibernate(context.Background(), "200"), actions.ErrHibernateUnsupported)
+	assert.ErrorIs(t, executor.Hibernate(context.Background(), "999"), ErrNodeNotFound)
+
+	guests := models.NewNodeList()
+	guests.ReplaceAll([]*models.VMStatus{{VMID: "100", Node: "pve1", Type: models.TypeVM, Status: models.StateRunning}})
+	executor = NewActionExecutor(&MockClient{}, guests).(*ActionExecutor)
+	assert.ErrorIs(t, executor.Hibernate(context.Background(), "100"), actions.ErrHibernateUnsupported)
 }
 
 func TestActionExecutor_ClientError(t *testing.T) {
-	expectedErr := errors.New("client error")
-	mock := &MockClient{
-		StartFunc: func(ctx context.Context, node, vmType, vmid string) error {
-			return expectedErr
-		},
-	}
-
-	executor := NewActionExecutor(mock).(*ActionExecutor)
-	executor.UpdateNodes([]*models.VMStatus{
-		{VMID: "100", Node: "pve1", Type: string(models.TypeVM)},
-	})
+	locked := proxmoxtest.VM(100, "web", "pve1", "stopped")
+	locked.Lock = "backup"
+	server, executor := fakeCluster(t, locked)
 
 	err := executor.Start(context.Background(), "100")
-	assert.Error(t, err)
-	assert.Equal(t, expectedErr, err)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Contains(t, apiErr.Body, "VM 100 is locked (backup)")
+	assert.Equal(t, "stopped", status(t, server, 100))
 }
 
-func TestActionExecutor_UpdateNodes(t *testing.T) {
+func TestActionExecutor_SharedList(t *testing.T) {
 	mock := &MockClient{}
-	executor := NewActionExecutor(mock).(*ActionExecutor)
+	guests := models.NewNodeList()
+	executor := NewActionExecutor(mock, guests).(*ActionExecutor)
 
-	nodes := []*models.VMStatus{
-		{VMID: "100", Node: "pve1", Type: string(models.TypeVM)},
-		{VMID: "200", Node: "pve1", Type: string(models.TypeContainer)},
-		{VMID: "300", Node: "pve2", Type: string(models.TypeVM)},
-	}
-
-	executor.UpdateNodes(nodes)
+	_, _, found := executor.getNodeInfo("100")
+	assert.False(t, found)
 
-	assert.Equal(t, 3, len(executor.nodes))
+	// A refresh of the shared list is seen at once
+	guests.ReplaceAll([]*models.VMStatus{
+		{VMID: "100", Node: "pve1", Type: models.TypeVM},
+		{VMID: "200", Node: "pve1", Type: models.TypeContainer},
+		{VMID: "300", Node: "pve2", Type: models.TypeVM},
+	})
 
 	node, vmType, found := executor.getNodeInfo("100")
 	assert.True(t, found)
@@ -189,3 +179,18 @@ func TestActionExecutor_UpdateNodes(t *testing.T) {
 	_, _, found = executor.getNodeInfo("999")
 	assert.False(t, found)
 }
+
+func TestActionExecutor_Clusters(t *testing.T) {
+	a, lab, prod := twoClusters(t)
+	guests := models.NewNodeList()
+	nodes, err := a.GetNodes(context.Background())
+	require.NoError(t, err)
+	guests.ReplaceAll(nodes)
+
+	executor := NewActionExecutor(a, guests)
+
+	require.NoError(t, executor.Start(context.Background(), "lab/100"))
+	assert.Equal(t, []string{"100"}, lab.started, "The action goes to the guest's cluster with its own VMID")
+	assert.Empty(t, prod.started)
+	assert.ErrorIs(t, executor.Start(context.Background(), "100"), ErrNodeNotFound)
+}
@@ -0,0 +1,222 @@
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// Feature names an optional source of the refresh, read from an endpoint
+// a small setup may not have or let the token read
+type Feature string
+
+// Optional sources of a snapshot, named as in the features config block
+const (
+	FeatureNodeStatus  Feature = "node_status"
+	FeatureStorage     Feature = "storage"
+	FeaturePools       Feature = "pools"
+	FeatureHA          Feature = "ha"
+	FeatureReplication Feature = "replication"
+	FeatureTasks       Feature = "tasks"
+)
+
+// AllFeatures lists the features in the order they are reported
+var AllFeatures = []Feature{FeatureNodeStatus, FeatureStorage, FeaturePools, FeatureHA, FeatureReplication, FeatureTasks}
+
+// Capability is whether a feature is read, and why
+type Capability struct {
+	Feature    Feature
+	Enabled    bool
+	Configured bool   // Set in the config rather than detected
+	Reason     string // How it was decided, or why it is still undecided
+	Err        error  // The error of the probe that turned it off or failed
+}
+
+// featureState is what a FeatureSet knows of one feature
+type featureState struct {
+	enabled    bool
+	decided    bool
+	configured bool
+	reason     string
+	err        error
+}
+
+// FeatureSet tracks which optional sources are read. A feature the
+// config turns on or off stays so; the others are read until their
+// first answer decides them: a 403, 404 or 501 turns them off for the
+// session, anything else read turns them on. Safe for concurrent use.
+type FeatureSet struct {
+	mu       sync.Mutex
+	features map[Feature]*featureState
+}
+
+// FeatureSettings turns the features block of the config, by name, into
+// the settings of NewFeatureSet
+func FeatureSettings(names map[string]bool) map[Feature]bool {
+	settings := make(map[Feature]bool, len(names))
+	for name, enabled := range names {
+		settings[Feature(name)] = enabled
+	}
+	return settings
+}
+
+// NewFeatureSet returns the features set in settings as configured and
+// the others to detect
+func NewFeatureSet(settings map[Feature]bool) *FeatureSet {
+	s := &FeatureSet{features: make(map[Feature]*featureState, len(AllFeatures))}
+	for _, f := range AllFeatures {
+		state := &featureState{reason: "not probed yet"}
+		if enabled, ok := settings[f]; ok {
+			state = &featureState{enabled: enabled, decided: true, configured: true, reason: "set in the config"}
+		}
+		s.features[f] = state
+	}
+	return s
+}
+
+// Enabled reports whether f is read: configured on, detected, or not
+// decided yet. A nil set reads everything.
+func (s *FeatureSet) Enabled(f Feature) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.features[f]
+	return !ok || !state.decided || state.enabled
+}
+
+// Observe decides an undecided feature from the outcome of reading it,
+// and returns the error to report: nil when the feature was just found
+// unsupported, so the first refresh doesn't flag a missing endpoint. A
+// transient error, such as a timeout, leaves it undecided.
+func (s *FeatureSet) Observe(f Feature, err error) error {
+	if s == nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.features[f]
+	if !ok || state.decided {
+		return err
+	}
+	if err == nil {
+		*state = featureState{enabled: true, decided: true, reason: "detected"}
+		return nil
+	}
+	if reason := unsupported(err); reason != "" {
+		*state = featureState{decided: true, reason: reason, err: err}
+		log.Printf("Feature %s disabled: %s (%v)", f, reason, err)
+		return nil
+	}
+	state.reason, state.err = "probe failed: "+err.Error(), err
+	return err
+}
+
+// unsupported returns why err shows the endpoint is unusable for good, ""
+// when it may work on a later try
+func unsupported(err error) string {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	switch apiErr.StatusCode {
+	case http.StatusForbidden:
+		return "permission denied on " + apiErr.Path
+	case http.StatusNotFound:
+		return "not found on this server"
+	case http.StatusNotImplemented:
+		return "not implemented by this server"
+	}
+	return ""
+}
+
+// Detect probes each undecided feature once with its lister in reader,
+// deciding it as Observe does. A feature reader has no lister for is
+// turned off.
+func (s *FeatureSet) Detect(ctx context.Context, reader StatusReader) {
+	for _, f := range AllFeatures {
+		if !s.undecided(f) {
+			continue
+		}
+		probe := featureProbe(reader, f)
+		if probe == nil {
+			s.disable(f, "not provided by the backend")
+			continue
+		}
+		_ = s.Observe(f, probe(ctx))
+	}
+}
+
+// undecided reports whether f waits for a probe
+func (s *FeatureSet) undecided(f Feature) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.features[f]
+	return ok && !state.decided
+}
+
+// disable turns f off for reason, unless it is already decided
+func (s *FeatureSet) disable(f Feature, reason string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.features[f]; ok && !state.decided {
+		*state = featureState{decided: true, reason: reason}
+	}
+}
+
+// Capabilities returns what is known of every feature, in AllFeatures
+// order
+func (s *FeatureSet) Capabilities() []Capability {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	caps := make([]Capability, 0, len(AllFeatures))
+	for _, f := range AllFeatures {
+		state := s.features[f]
+		caps = append(caps, Capability{
+			Feature:    f,
+			Enabled:    !state.decided || state.enabled,
+			Configured: state.configured,
+			Reason:     state.reason,
+			Err:        state.err,
+		})
+	}
+	return caps
+}
+
+// featureProbe returns a call reading f from reader, nil when reader
+// can't read it
+func featureProbe(reader StatusReader, f Feature) func(ctx context.Context) error {
+	switch f {
+	case FeatureNodeStatus:
+		if l, ok := reader.(NodeLister); ok {
+			return func(ctx context.Context) error { _, err := l.GetClusterNodes(ctx); return err }
+		}
+	case FeatureStorage:
+		if l, ok := reader.(StorageLister); ok {
+			return func(ctx context.Context) error { _, err := l.GetClusterStorages(ctx); return err }
+		}
+	case FeaturePools:
+		if l, ok := reader.(PoolLister); ok {
+			return func(ctx context.Context) error { _, err := l.GetPools(ctx); return err }
+		}
+	case FeatureHA:
+		if l, ok := reader.(HALister); ok {
+			return func(ctx context.Context) error { _, err := l.GetHAResources(ctx); return err }
+		}
+	case FeatureReplication:
+		if l, ok := reader.(ReplicationLister); ok {
+			return func(ctx context.Context) error { _, err := l.GetReplicationJobs(ctx); return err }
+		}
+	case FeatureTasks:
+		if l, ok := reader.(TaskLister); ok {
+			return func(ctx context.Context) error { _, err := l.GetClusterTasks(ctx); return err }
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package proxmox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// featureServer serves the pve8 snapshot fixtures, answering the paths
+// in status with that status instead, and counts the requests per path
+func featureServer(t *testing.T, status map[string]int) (*httptest.Server, func(path string) int) {
+	t.Helper()
+	fixtures := delayedServer(t, 0)
+	var mu sync.Mutex
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+		if code, ok := status[r.URL.Path]; ok {
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(`{"data":null}`))
+			return
+		}
+		fixtures.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls["/api2/json"+path]
+	}
+}
+
+// capability returns the capability of f
+func capability(t *testing.T, caps []Capability, f Feature) Capability {
+	t.Helper()
+	for _, c := range caps {
+		if c.Feature == f {
+			return c
+		}
+	}
+	t.Fatalf("no capability for %s", f)
+	return Capability{}
+}
+
+func TestProvider_FeatureDetection(t *testing.T) {
+	for name, tc := range map[string]struct {
+		status int
+		reason string
+	}{
+		"forbidden":       {http.StatusForbidden, "permission denied on /pools"},
+		"not found":       {http.StatusNotFound, "not found on this server"},
+		"not implemented": {http.StatusNotImplemented, "not implemented by this server"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, calls := featureServer(t, map[string]int{"/api2/json/pools": tc.status})
+			p := NewProvider(NewClient(server.URL, "token", true))
+
+			snap := p.Snapshot(context.Background())
+			assert.Empty(t, snap.Errors, "an unsupported source is dropped, not reported")
+			assert.Nil(t, snap.Pools)
+			assert.Len(t, snap.HA, 2)
+
+			pools := capability(t, p.Capabilities(), FeaturePools)
+			assert.False(t, pools.Enabled)
+			assert.False(t, pools.Configured)
+			assert.Equal(t, tc.reason, pools.Reason)
+			assert.True(t, capability(t, p.Capabilities(), FeatureHA).Enabled)
+
+			p.Snapshot(context.Background())
+			assert.Equal(t, 1, calls("/pools"), "the endpoint is probed once")
+			assert.Equal(t, 2, calls("/cluster/ha/resources"))
+		})
+	}
+}
+
+func TestProvider_FeatureTransientError(t *testing.T) {
+	server, calls := featureServer(t, map[string]int{"/api2/json/cluster/replication": http.StatusInternalServerError})
+	p := NewProvider(NewClient(server.URL, "token", true))
+
+	snap := p.Snapshot(context.Background())
+	assert.Error(t, snap.Err(SectionReplication), "a server error is reported")
+	replication := capability(t, p.Capabilities(), FeatureReplication)
+	assert.True(t, replication.Enabled, "and left to the next refresh to decide")
+	assert.Contains(t, replication.Reason, "probe failed")
+
+	p.Snapshot(context.Background())
+	assert.Equal(t, 2, calls("/cluster/replication"))
+}
+
+func TestProvider_FeaturesConfigured(t *testing.T) {
+	server, calls := featureServer(t, map[string]int{"/api2/json/cluster/ha/resources": http.StatusNotFound})
+	p := NewProvider(NewClient(server.URL, "token", true))
+	p.Features = NewFeatureSet(map[Feature]bool{FeaturePools: false, FeatureStorage: false, FeatureHA: true})
+
+	snap := p.Snapshot(context.Background())
+	assert.Zero(t, calls("/pools"))
+	assert.Nil(t, snap.Storages)
+	assert.Error(t, snap.Err(SectionHA), "a source turned on is never dropped")
+	assert.Len(t, snap.Nodes, 2)
+
+	ha := capability(t, p.Capabilities(), FeatureHA)
+	assert.True(t, ha.Enabled)
+	assert.True(t, ha.Configured)
+}
+
+func TestFeatureSet_Detect(t *testing.T) {
+	server, calls := featureServer(t, map[string]int{
+		"/api2/json/pools":                http.StatusForbidden,
+		"/api2/json/cluster/ha/resources": http.StatusNotImplemented,
+		"/api2/json/cluster/replication":  http.StatusInternalServerError,
+	})
+	features := NewFeatureSet(map[Feature]bool{FeatureStorage: false})
+	features.Detect(context.Background(), NewClient(server.URL, "token", true))
+
+	caps := features.Capabilities()
+	require.Len(t, caps, len(AllFeatures))
+	assert.Equal(t, Capability{Feature: FeatureNodeStatus, Enabled: true, Reason: "detected"}, caps[0])
+	assert.Equal(t, Capability{Feature: FeatureStorage, Configured: true, Reason: "set in the config"}, caps[1])
+	assert.False(t, caps[2].Enabled)
+	assert.False(t, caps[3].Enabled)
+	assert.True(t, caps[4].Enabled, "a server error decides nothing")
+	assert.Zero(t, calls("/cluster/resources"), "storage is configured off")
+
+	features.Detect(context.Background(), NewClient(server.URL, "token", true))
+	assert.Equal(t, 1, calls("/pools"), "a decided feature isn't probed again")
+	assert.Equal(t, 2, calls("/cluster/replication"))
+}
+
+func TestFeatureSet_Nil(t *testing.T) {
+	var features *FeatureSet
+	assert.True(t, features.Enabled(FeaturePools))
+	assert.Equal(t, assert.AnError, features.Observe(FeaturePools, assert.AnError))
+}
@@ -0,0 +1,183 @@
+package proxmox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RecordEnv names the environment variable that makes NewClient record
+// every API exchange as a fixture file in the directory it points to
+const RecordEnv = "PVEC_RECORD"
+
+// apiPrefix is stripped from recorded paths so fixtures don't depend on
+// the server they were captured from
+const apiPrefix = "/api2/json"
+
+// Exchange is one recorded API request and its response. Request headers
+// are not kept, so tokens never end up in a fixture.
+type Exchange struct {
+	Method  string          `json:"method"`
+	Path    string          `json:"path"`              // Relative to /api2/json, with the query string
+	Request string          `json:"request,omitempty"` // Form-encoded request body
+	Status  int             `json:"status"`
+	Body    json.RawMessage `json:"body,omitempty"` // Response body when it is JSON
+	Text    string          `json:"text,omitempty"` // Response body otherwise
+}
+
+// exchangePath returns the fixture path of a request
+func exchangePath(req *http.Request) string {
+	path := strings.TrimPrefix(req.URL.Path, apiPrefix)
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+	return path
+}
+
+// fixtureName turns an exchange into a file name such as
+// GET_nodes_pve1_qemu_100_config.json
+func fixtureName(e Exchange) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, strings.Trim(e.Path, "/"))
+	return e.Method + "_" + name + ".json"
+}
+
+// RecordingTransport passes requests through to a real server and writes
+// each exchange to a fixture file. A later exchange with the same method
+// and path overwrites the earlier one.
+type RecordingTransport struct {
+	base http.RoundTripper
+	dir  string
+	mu   sync.Mutex
+}
+
+// NewRecordingTransport records the exchanges made through base into dir
+func NewRecordingTransport(base http.RoundTripper, dir string) *RecordingTransport {
+	return &RecordingTransport{base: base, dir: dir}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	e := Exchange{
+		Method:  req.Method,
+		Path:    exchangePath(req),
+		Request: string(reqBody),
+		Status:  resp.StatusCode,
+	}
+	if trimmed := bytes.TrimSpace(body); json.Valid(trimmed) {
+		e.Body = trimmed
+	} else {
+		e.Text = string(body)
+	}
+	if err := t.save(e); err != nil {
+		log.Printf("failed to record %s %s: %v", e.Method, e.Path, err)
+	}
+	return resp, nil
+}
+
+// save writes an exchange to its fixture file
+func (t *RecordingTransport) save(e Exchange) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(t.dir, fixtureName(e)), append(data, '\n'), 0o600)
+}
+
+// ReplayTransport answers requests from recorded exchanges without
+// touching the network
+type ReplayTransport struct {
+	exchanges map[string]Exchange // "METHOD path" -> exchange
+}
+
+// LoadReplayTransport reads every fixture file in dir
+func LoadReplayTransport(dir string) (*ReplayTransport, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no fixtures in %s", dir)
+	}
+	sort.Strings(files)
+
+	t := &ReplayTransport{exchanges: make(map[string]Exchange)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var e Exchange
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", file, err)
+		}
+		t.exchanges[e.Method+" "+e.Path] = e
+	}
+	return t, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+
+	e, ok := t.exchanges[req.Method+" "+exchangePath(req)]
+	if !ok {
+		return nil, fmt.Errorf("no recorded exchange for %s %s", req.Method, exchangePath(req))
+	}
+	body := []byte(e.Text)
+	if len(e.Body) > 0 {
+		body = e.Body
+	}
+	return &http.Response{
+		StatusCode: e.Status,
+		Status:     fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		Header:     http.Header{"Content-Type": []string{"application/json;charset=UTF-8"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api2/json/cluster/resources":
+			_, _ = w.Write([]byte(`{"data":[{"id":"qemu/100","vmid":100,"name":"vm","type":"qemu","status":"running","node":"pve1","cpu":0.05}]}`))
+		case "/api2/json/nodes/pve1/qemu/100/status/start":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("no access"))
+		}
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "recorded")
+	t.Setenv(RecordEnv, dir)
+	client := NewClient(server.URL, "PVEAPIToken=root@pam!pvec=top-secret", true)
+
+	nodes, err := client.GetNodes(context.Background())
+	require.NoError(t, err, "recording should not change what the client sees")
+	require.Len(t, nodes, 1)
+	assert.True(t, IsForbidden(client.Start(context.Background(), "pve1", "qemu", "100")))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "top-secret", "%s leaks the token", file)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "GET_cluster_resources_type_vm.json"))
+	require.NoError(t, err)
+	var e Exchange
+	require.NoError(t, json.Unmarshal(data, &e))
+	assert.Equal(t, "GET", e.Method)
+	assert.Equal(t, "/cluster/resources?type=vm", e.Path)
+	assert.Equal(t, http.StatusOK, e.Status)
+	assert.Contains(t, string(e.Body), `"vmid": 100`)
+
+	data, err = os.ReadFile(filepath.Join(dir, "POST_nodes_pve1_qemu_100_status_start.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &e))
+	assert.Equal(t, "no access", e.Text, "non-JSON bodies are kept as text")
+
+	// What was recorded replays the same way
+	replay, err := LoadReplayTransport(dir)
+	require.NoError(t, err)
+	replayed := &HTTPClient{baseURL: "https://elsewhere:8006", httpClient: &http.Client{Transport: replay}}
+	nodes, err = replayed.GetNodes(context.Background())
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "vm", nodes[0].Name)
+	err = replayed.Start(context.Background(), "pve1", "qemu", "100")
+	assert.True(t, IsForbidden(err))
+	assert.True(t, strings.HasSuffix(err.Error(), "no access"))
+}
+
+func TestLoadReplayTransport_Errors(t *testing.T) {
+	_, err := LoadReplayTransport(t.TempDir())
+	assert.Error(t, err, "an empty directory is most likely a typo")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0o600))
+	_, err = LoadReplayTransport(dir)
+	assert.Error(t, err)
+}
+
+func TestFixtureName(t *testing.T) {
+	assert.Equal(t, "GET_nodes_pve1_qemu_100_agent_get-fsinfo.json",
+		fixtureName(Exchange{Method: "GET", Path: "/nodes/pve1/qemu/100/agent/get-fsinfo"}))
+	assert.Equal(t, "GET_cluster_resources_type_vm.json",
+		fixtureName(Exchange{Method: "GET", Path: "/cluster/resources?type=vm"}))
+}
@@ -0,0 +1,199 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// DefaultTaskPoll is how often WaitTask checks whether a task ended
+const DefaultTaskPoll = time.Second
+
+// guestSnapshot represents one entry of the guest snapshot endpoint
+type guestSnapshot struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Parent      string `json:"parent"`
+	SnapTime    int64  `json:"snaptime"`
+	VMState     int    `json:"vmstate"`
+}
+
+// SnapshotSupported reports whether the guest's disks all sit on storages
+// that can take snapshots, as the GUI does before offering one
+func (c *HTTPClient) SnapshotSupported(ctx context.Context, node, vmType, vmid string) (bool, error) {
+	path := fmt.Sprintf("/nodes/%s/%s/%s/feature?feature=snapshot", node, vmType, vmid)
+	var feature struct {
+		// 1 or 0 for a VM, true or false for a container
+		HasFeature json.RawMessage `json:"hasFeature"`
+	}
+	if err := c.getData(ctx, path, &feature); err != nil {
+		return false, fmt.Errorf("failed to check snapshot support of %s %s: %w", vmType, vmid, err)
+	}
+	switch string(feature.HasFeature) {
+	case "1", "true":
+		return true, nil
+	}
+	return false, nil
+}
+
+// ListSnapshots lists the snapshots of a guest, oldest first. The entry
+// Proxmox adds for the current state is left out.
+func (c *HTTPClient) ListSnapshots(ctx context.Context, node, vmType, vmid string) ([]models.Snapshot, error) {
+	path := fmt.Sprintf("/nodes/%s/%s/%s/snapshot", node, vmType, vmid)
+	var entries []guestSnapshot
+	if err := c.getData(ctx, path, &entries); err != nil {
+		return nil, fmt.Errorf("failed to get snapshots of %s %s: %w", vmType, vmid, err)
+	}
+
+	snapshots := make([]models.Snapshot, 0, len(entries))
+	for _, e := range entries {
+		if e.Name == "current" {
+			continue
+		}
+		s := models.Snapshot{
+			Name:        e.Name,
+			Description: strings.TrimSpace(e.Description),
+			Parent:      e.Parent,
+			VMState:     e.VMState != 0,
+		}
+		if e.SnapTime > 0 {
+			s.Created = time.Unix(e.SnapTime, 0)
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+	return snapshots, nil
+}
+
+// CreateSnapshot snapshots the guest's disks, not its RAM, and returns the
+// UPID of the snapshot task
+func (c *HTTPClient) CreateSnapshot(ctx context.Context, node, vmType, vmid, name, description string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/%s/%s/snapshot", node, vmType, vmid)
+	form := url.Values{"snapname": {name}}
+	if description != "" {
+		form.Set("description", description)
+	}
+	resp, err := c.doRequestForm(ctx, "POST", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to snapshot %s %s: %w", vmType, vmid, newAPIError(resp, "POST", path))
+	}
+	return decodeUPID(resp)
+}
+
+// DeleteSnapshot removes a snapshot and returns the UPID of its task
+func (c *HTTPClient) DeleteSnapshot(ctx context.Context, node, vmType, vmid, name string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/%s/%s/snapshot/%s", node, vmType, vmid, url.PathEscape(name))
+	resp, err := c.doRequest(ctx, "DELETE", path, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to delete snapshot %s of %s %s: %w", name, vmType, vmid, newAPIError(resp, "DELETE", path))
+	}
+	return decodeUPID(resp)
+}
+
+// decodeUPID reads the UPID of the task a request started
+func decodeUPID(resp *http.Response) (string, error) {
+	var result proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	var upid string
+	if err := json.Unmarshal(result.Data, &upid); err != nil {
+		return "", fmt.Errorf("failed to decode task ID: %w", err)
+	}
+	return upid, nil
+}
+
+// TaskWatcher reads how a task stands, for WaitTask
+type TaskWatcher interface {
+	GetTaskStatus(ctx context.Context, node, upid string) (models.TaskStatus, error)
+}
+
+// WaitTask checks a task every poll until it ends, and fails unless it
+// ended OK. A zero poll is DefaultTaskPoll.
+func WaitTask(ctx context.Context, tasks TaskWatcher, node, upid string, poll time.Duration) error {
+	if poll <= 0 {
+		poll = DefaultTaskPoll
+	}
+	for {
+		status, err := tasks.GetTaskStatus(ctx, node, upid)
+		if err != nil {
+			return err
+		}
+		if !status.Running {
+			if !status.OK() {
+				return fmt.Errorf("task failed: %s", status.ExitStatus)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// SnapshotExecutor adapts a SnapshotManager to actions.Snapshotter,
+// looking each guest's node and type up in a shared list as
+// ActionExecutor does
+type SnapshotExecutor struct {
+	manager SnapshotManager
+	guests  models.NodeList // Kept current by whoever refreshes it
+	Poll    time.Duration   // Check interval of the snapshot task; DefaultTaskPoll if zero
+}
+
+// NewSnapshotExecutor creates a snapshot executor over the guests list
+func NewSnapshotExecutor(manager SnapshotManager, guests models.NodeList) *SnapshotExecutor {
+	return &SnapshotExecutor{manager: manager, guests: guests}
+}
+
+// target returns everything the manager needs to snapshot the guest
+func (e *SnapshotExecutor) target(key string) (node, vmType, vmid string, err error) {
+	vm, found := e.guests.Get(key)
+	if !found {
+		return "", "", "", ErrNodeNotFound
+	}
+	_, vmid = models.SplitKey(key)
+	return vm.Node, vm.TypeString(), vmid, nil
+}
+
+// SnapshotSupported reports whether the guest can be snapshotted
+func (e *SnapshotExecutor) SnapshotSupported(ctx context.Context, key string) (bool, error) {
+	node, vmType, vmid, err := e.target(key)
+	if err != nil {
+		return false, err
+	}
+	return e.manager.SnapshotSupported(ctx, node, vmType, vmid)
+}
+
+// Snapshot snapshots the guest and waits for the task to end
+func (e *SnapshotExecutor) Snapshot(ctx context.Context, key, name, description string) error {
+	node, vmType, vmid, err := e.target(key)
+	if err != nil {
+		return err
+	}
+	upid, err := e.manager.CreateSnapshot(ctx, node, vmType, vmid, name, description)
+	if err != nil {
+		return err
+	}
+	return WaitTask(ctx, e.manager, node, upid, e.Poll)
+}
@@ -0,0 +1,172 @@
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestHTTPClient_SnapshotSupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "snapshot", r.URL.Query().Get("feature"))
+		switch r.URL.Path {
+		case "/api2/json/nodes/pve1/qemu/100/feature":
+			_, _ = w.Write([]byte(`{"data":{"hasFeature":1,"nodes":[]}}`))
+		case "/api2/json/nodes/pve1/lxc/200/feature":
+			_, _ = w.Write([]byte(`{"data":{"hasFeature":false}}`))
+		default:
+			http.Error(w, `{"data":null}`, http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	supported, err := client.SnapshotSupported(context.Background(), "pve1", "qemu", "100")
+	require.NoError(t, err)
+	assert.True(t, supported)
+
+	supported, err = client.SnapshotSupported(context.Background(), "pve1", "lxc", "200")
+	require.NoError(t, err)
+	assert.False(t, supported, "a container on a raw volume can't be snapshotted")
+
+	_, err = client.SnapshotSupported(context.Background(), "pve1", "qemu", "101")
+	assert.ErrorContains(t, err, "failed to check snapshot support of qemu 101")
+}
+
+func TestHTTPClient_ListSnapshots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api2/json/nodes/pve1/qemu/100/snapshot", r.URL.Path)
+		_, _ = w.Write([]byte(`{"data":[
+			{"name":"current","description":"You are here!","parent":"pvec-auto-20261017-183000","running":1},
+			{"name":"pvec-auto-20261017-183000","description":"Taken before stop\n","parent":"before-upgrade","snaptime":1792261800},
+			{"name":"before-upgrade","snaptime":1790000000,"vmstate":1}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	snapshots, err := client.ListSnapshots(context.Background(), "pve1", "qemu", "100")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Snapshot{
+		{Name: "before-upgrade", Created: time.Unix(1790000000, 0), VMState: true},
+		{Name: "pvec-auto-20261017-183000", Description: "Taken before stop", Parent: "before-upgrade", Created: time.Unix(1792261800, 0)},
+	}, snapshots)
+}
+
+func TestHTTPClient_CreateSnapshot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api2/json/nodes/pve2/lxc/200/snapshot", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "pvec-auto-20261017-183000", r.PostForm.Get("snapname"))
+		assert.Equal(t, "Taken by pvec before stop", r.PostForm.Get("description"))
+		assert.Empty(t, r.PostForm.Get("vmstate"), "the RAM is not saved")
+		_, _ = w.Write([]byte(`{"data":"UPID:pve2:1"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	upid, err := client.CreateSnapshot(context.Background(), "pve2", "lxc", "200",
+		"pvec-auto-20261017-183000", "Taken by pvec before stop")
+	require.NoError(t, err)
+	assert.Equal(t, "UPID:pve2:1", upid)
+}
+
+func TestHTTPClient_DeleteSnapshot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		if r.URL.Path != "/api2/json/nodes/pve1/qemu/100/snapshot/pvec-auto-20261017-183000" {
+			http.Error(w, `{"data":null,"message":"snapshot 'x' does not exist"}`, http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":"UPID:pve1:2"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	upid, err := client.DeleteSnapshot(context.Background(), "pve1", "qemu", "100", "pvec-auto-20261017-183000")
+	require.NoError(t, err)
+	assert.Equal(t, "UPID:pve1:2", upid)
+
+	_, err = client.DeleteSnapshot(context.Background(), "pve1", "qemu", "100", "x")
+	assert.ErrorContains(t, err, "failed to delete snapshot x of qemu 100")
+}
+
+// fakeSnapshots is a SnapshotManager whose tasks end after a number of
+// status checks
+type fakeSnapshots struct {
+	supported bool
+	exit      string // Exit status of the tasks
+	checks    int    // Status checks left before a task ends
+	created   []string
+	deleted   []string
+	err       error
+}
+
+func (f *fakeSnapshots) SnapshotSupported(ctx context.Context, node, vmType, vmid string) (bool, error) {
+	return f.supported, f.err
+}
+
+func (f *fakeSnapshots) ListSnapshots(ctx context.Context, node, vmType, vmid string) ([]models.Snapshot, error) {
+	return nil, f.err
+}
+
+func (f *fakeSnapshots) CreateSnapshot(ctx context.Context, node, vmType, vmid, name, description string) (string, error) {
+	f.created = append(f.created, node+"/"+vmType+"/"+vmid+"/"+name)
+	return "UPID:" + node + ":snapshot", f.err
+}
+
+func (f *fakeSnapshots) DeleteSnapshot(ctx context.Context, node, vmType, vmid, name string) (string, error) {
+	f.deleted = append(f.deleted, node+"/"+vmType+"/"+vmid+"/"+name)
+	return "UPID:" + node + ":delsnapshot", f.err
+}
+
+func (f *fakeSnapshots) GetTaskStatus(ctx context.Context, node, upid string) (models.TaskStatus, error) {
+	if f.checks > 0 {
+		f.checks--
+		return models.TaskStatus{Running: true}, nil
+	}
+	return models.TaskStatus{ExitStatus: f.exit}, nil
+}
+
+func TestWaitTask(t *testing.T) {
+	tasks := &fakeSnapshots{exit: "OK", checks: 2}
+	require.NoError(t, WaitTask(context.Background(), tasks, "pve1", "UPID:pve1:1", time.Millisecond))
+	assert.Zero(t, tasks.checks, "checked until the task ended")
+
+	tasks = &fakeSnapshots{exit: "snapshot feature is not available"}
+	err := WaitTask(context.Background(), tasks, "pve1", "UPID:pve1:1", time.Millisecond)
+	assert.EqualError(t, err, "task failed: snapshot feature is not available")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tasks = &fakeSnapshots{checks: 1}
+	assert.ErrorIs(t, WaitTask(ctx, tasks, "pve1", "UPID:pve1:1", time.Hour), context.Canceled)
+}
+
+func TestSnapshotExecutor(t *testing.T) {
+	guests := models.NewNodeList()
+	guests.Add(&models.VMStatus{VMID: "200", Node: "pve2", Type: models.TypeContainer})
+	manager := &fakeSnapshots{supported: true, exit: "OK", checks: 1}
+	e := NewSnapshotExecutor(manager, guests)
+	e.Poll = time.Millisecond
+
+	supported, err := e.SnapshotSupported(context.Background(), "200")
+	require.NoError(t, err)
+	assert.True(t, supported)
+
+	require.NoError(t, e.Snapshot(context.Background(), "200", "pvec-auto-1", ""))
+	assert.Equal(t, []string{"pve2/lxc/200/pvec-auto-1"}, manager.created)
+
+	assert.ErrorIs(t, e.Snapshot(context.Background(), "999", "pvec-auto-1", ""), ErrNodeNotFound)
+
+	manager.err = errors.New("permission denied")
+	assert.EqualError(t, e.Snapshot(context.Background(), "200", "pvec-auto-2", ""), "permission denied")
+}
@@ -0,0 +1,83 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// haGroup represents one entry of the HA groups endpoint
+type haGroup struct {
+	Group   string `json:"group"`
+	Nodes   string `json:"nodes"` // Comma-separated, each with an optional :priority
+	Comment string `json:"comment"`
+}
+
+// GetHAGroups lists the HA groups
+func (c *HTTPClient) GetHAGroups(ctx context.Context) ([]models.HAGroup, error) {
+	var entries []haGroup
+	if err := c.getData(ctx, "/cluster/ha/groups", &entries); err != nil {
+		return nil, fmt.Errorf("failed to get HA groups: %w", err)
+	}
+	groups := make([]models.HAGroup, 0, len(entries))
+	for _, e := range entries {
+		var nodes []string
+		for _, n := range strings.Split(e.Nodes, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				nodes = append(nodes, n)
+			}
+		}
+		groups = append(groups, models.HAGroup{Name: e.Group, Nodes: nodes, Comment: e.Comment})
+	}
+	return groups, nil
+}
+
+// AddHAResource puts a guest under HA, in group unless it is "". HA then
+// keeps it started, its default requested state.
+func (c *HTTPClient) AddHAResource(ctx context.Context, sid, group string) error {
+	form := url.Values{"sid": {sid}}
+	if group != "" {
+		form.Set("group", group)
+	}
+	return c.sendHA(ctx, "POST", "/cluster/ha/resources", form, "put "+sid+" under HA")
+}
+
+// RemoveHAResource takes a guest out of HA; the guest keeps running, or
+// stays stopped, as it is
+func (c *HTTPClient) RemoveHAResource(ctx context.Context, sid string) error {
+	return c.sendHA(ctx, "DELETE", "/cluster/ha/resources/"+url.PathEscape(sid), nil, "remove "+sid+" from HA")
+}
+
+// SetHARequestedState asks HA to keep a guest in state: started, stopped
+// or disabled
+func (c *HTTPClient) SetHARequestedState(ctx context.Context, sid, state string) error {
+	form := url.Values{"state": {state}}
+	return c.sendHA(ctx, "PUT", "/cluster/ha/resources/"+url.PathEscape(sid), form, "request "+state+" for "+sid)
+}
+
+// sendHA sends a change to the HA resources; what describes it in the
+// error
+func (c *HTTPClient) sendHA(ctx context.Context, method, path string, form url.Values, what string) error {
+	var (
+		resp *http.Response
+		err  error
+	)
+	if form == nil {
+		resp, err = c.doRequest(ctx, method, path, nil)
+	} else {
+		resp, err = c.doRequestForm(ctx, method, path, strings.NewReader(form.Encode()))
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to %s: %w", what, newAPIError(resp, method, path))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package proxmox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestHTTPClient_GetHAGroups(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api2/json/cluster/ha/groups", r.URL.Path)
+		_, _ = w.Write([]byte(`{"data":[{"group":"prod","nodes":"pve1:2,pve2","comment":"Front","type":"group"},{"group":"lab","nodes":"pve3"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	groups, err := client.GetHAGroups(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []models.HAGroup{
+		{Name: "prod", Nodes: []string{"pve1:2", "pve2"}, Comment: "Front"},
+		{Name: "lab", Nodes: []string{"pve3"}},
+	}, groups)
+}
+
+func TestHTTPClient_HAResources(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.PostForm.Encode())
+		_, _ = w.Write([]byte(`{"data":null}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	ctx := context.Background()
+	require.NoError(t, client.AddHAResource(ctx, "vm:100", "prod"))
+	require.NoError(t, client.AddHAResource(ctx, "ct:200", ""))
+	require.NoError(t, client.SetHARequestedState(ctx, "vm:100", "stopped"))
+	require.NoError(t, client.RemoveHAResource(ctx, "ct:200"))
+
+	assert.Equal(t, []string{
+		"POST /api2/json/cluster/ha/resources?group=prod&sid=vm%3A100",
+		"POST /api2/json/cluster/ha/resources?sid=ct%3A200",
+		"PUT /api2/json/cluster/ha/resources/vm:100?state=stopped",
+		"DELETE /api2/json/cluster/ha/resources/ct:200?",
+	}, requests)
+}
+
+func TestHTTPClient_HAResources_Refused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":{"sid":"resource 'vm:100' already defined"},"data":null}`, http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	err := client.AddHAResource(context.Background(), "vm:100", "")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to put vm:100 under HA")
+	assert.Contains(t, err.Error(), "already defined")
+
+	err = client.SetHARequestedState(context.Background(), "vm:100", "started")
+	assert.ErrorContains(t, err, "failed to request started for vm:100")
+}
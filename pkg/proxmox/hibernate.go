@@ -0,0 +1,26 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Hibernate suspends a VM to disk. Containers can't be: only QEMU saves
+// the memory of a guest.
+func (c *HTTPClient) Hibernate(ctx context.Context, node, vmid string) error {
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/status/suspend", node, vmid)
+	form := url.Values{"todisk": {"1"}}
+	resp, err := c.doRequestForm(ctx, "POST", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to hibernate qemu %s: %w", vmid, newAPIError(resp, "POST", path))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package proxmox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient_Hibernate(t *testing.T) {
+	var forms []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+		require.NoError(t, r.ParseForm())
+		forms = append(forms, r.URL.Path+"?"+r.PostForm.Encode())
+		_, _ = w.Write([]byte(`{"data":"UPID:pve1:00001234:qmsuspend:100:root@pam:"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	require.NoError(t, client.Hibernate(context.Background(), "pve1", "100"))
+
+	assert.Equal(t, []string{"/api2/json/nodes/pve1/qemu/100/status/suspend?todisk=1"}, forms)
+}
+
+func TestHTTPClient_Hibernate_Refused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":{"todisk":"no storage for the VM state"},"data":null}`, http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	err := client.Hibernate(context.Background(), "pve1", "100")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to hibernate qemu 100")
+}
@@ -0,0 +1,31 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ClearLock removes the lock of a guest by deleting it from its config,
+// as qm unlock and pct unlock do. For a VM the config update must skip
+// the lock check, which Proxmox only allows root@pam; other users and
+// API tokens are refused.
+func (c *HTTPClient) ClearLock(ctx context.Context, node, vmType, vmid string) error {
+	path := fmt.Sprintf("/nodes/%s/%s/%s/config", node, vmType, vmid)
+	form := url.Values{"delete": {"lock"}}
+	if vmType == "qemu" {
+		form.Set("skiplock", "1")
+	}
+	resp, err := c.doRequestForm(ctx, "PUT", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to clear the lock of %s %s: %w", vmType, vmid, newAPIError(resp, "PUT", path))
+	}
+	return nil
+}
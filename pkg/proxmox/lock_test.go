@@ -0,0 +1,58 @@
+package proxmox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient_ClearLock(t *testing.T) {
+	var forms []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		require.NoError(t, r.ParseForm())
+		forms = append(forms, r.URL.Path+"?"+r.PostForm.Encode())
+		_, _ = w.Write([]byte(`{"data":null}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	require.NoError(t, client.ClearLock(context.Background(), "pve1", "qemu", "100"))
+	require.NoError(t, client.ClearLock(context.Background(), "pve2", "lxc", "200"))
+
+	assert.Equal(t, []string{
+		"/api2/json/nodes/pve1/qemu/100/config?delete=lock&skiplock=1",
+		"/api2/json/nodes/pve2/lxc/200/config?delete=lock",
+	}, forms)
+}
+
+func TestHTTPClient_ClearLock_Refused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":{"skiplock":"Only root may use this option."},"data":null}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	err := client.ClearLock(context.Background(), "pve1", "qemu", "100")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to clear the lock of qemu 100")
+	assert.Contains(t, err.Error(), "Only root may use this option.")
+}
+
+func TestHTTPClient_GetNodes_Lock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[{"type":"qemu","vmid":100,"node":"pve1","status":"stopped","lock":"backup"}]}`))
+	}))
+	defer server.Close()
+
+	nodes, err := NewClient(server.URL, "token", true).GetNodes(context.Background())
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "backup", nodes[0].Lock)
+	assert.True(t, nodes[0].Locked())
+}
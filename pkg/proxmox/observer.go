@@ -0,0 +1,187 @@
+package proxmox
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/redact"
+)
+
+// DefaultRequestLogSize is the number of requests a RequestLog keeps
+// unless told otherwise
+const DefaultRequestLogSize = 50
+
+// RequestInfo summarizes one API request. Secrets are masked before an
+// observer sees it, so it can be stored or shown as it is.
+type RequestInfo struct {
+	Time     time.Time     // When the request was sent
+	Method   string        // HTTP method
+	URL      string        // Full request URL, query included
+	Path     string        // API path relative to /api2/json, query included
+	Status   int           // HTTP status code, 0 when no response arrived
+	Duration time.Duration // Until the response headers arrived or the request failed
+	Err      string        // Why no response arrived, "" when one did
+	Request  http.Header   // Request headers, Authorization masked
+	Response http.Header   // Response headers, nil when no response arrived
+}
+
+// Failed reports whether the request got no response or an error status
+func (r RequestInfo) Failed() bool {
+	return r.Err != "" || r.Status >= http.StatusBadRequest
+}
+
+// RequestObserver is told of every request an HTTPClient sends, once its
+// response headers arrive or it fails. It lets embedders plug their own
+// tracing in. It is called from the goroutine that sent the request, so
+// it must be safe for concurrent use and return quickly.
+type RequestObserver interface {
+	ObserveRequest(info RequestInfo)
+}
+
+// TransferObserver is told how many bytes of response bodies a client
+// read, as they are read. A RequestObserver that implements it is told
+// of them too. It must be safe for concurrent use and return quickly.
+type TransferObserver interface {
+	ObserveTransfer(bytes int, at time.Time)
+}
+
+// SetObserver has the client report its requests to observer; nil stops
+// the reports. Set it before the client is shared between goroutines.
+func (c *HTTPClient) SetObserver(observer RequestObserver) {
+	c.observer = observer
+}
+
+// observe reports a request to the observer, masking its secrets
+func (c *HTTPClient) observe(req *http.Request, resp *http.Response, err error, start time.Time) {
+	if c.observer == nil {
+		return
+	}
+	info := RequestInfo{
+		Time:     start,
+		Method:   req.Method,
+		URL:      redact.String(req.URL.String()),
+		Path:     redact.String(exchangePath(req)),
+		Duration: time.Since(start),
+		Request:  redact.Header(req.Header),
+	}
+	if err != nil {
+		info.Err = redact.String(err.Error())
+	}
+	if resp != nil {
+		info.Status = resp.StatusCode
+		info.Response = redact.Header(resp.Header)
+	}
+	c.observer.ObserveRequest(info)
+}
+
+// countingBody reports what is read of a response body to a
+// TransferObserver
+type countingBody struct {
+	io.ReadCloser
+	observer TransferObserver
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.observer.ObserveTransfer(n, time.Now())
+	}
+	return n, err
+}
+
+// countBody has the client's observer told of what is read of body, if
+// it counts transfers
+func (c *HTTPClient) countBody(body io.ReadCloser) io.ReadCloser {
+	if t, ok := c.observer.(TransferObserver); ok {
+		return &countingBody{ReadCloser: body, observer: t}
+	}
+	return body
+}
+
+// RequestLog is a RequestObserver keeping the last requests in memory,
+// for a debug screen, and a TransferObserver counting the bytes received
+// over the last minute. A nil RequestLog keeps nothing.
+type RequestLog struct {
+	mu      sync.Mutex
+	entries []RequestInfo // Ring buffer; next is the oldest once full
+	next    int
+	size    int
+
+	// Bytes received per second of the last minute, by second modulo 60,
+	// and the Unix second each slot counts
+	received   [60]int64
+	receivedAt [60]int64
+	total      int64
+}
+
+// NewRequestLog returns a log of the last size requests, or of the last
+// DefaultRequestLogSize when size is not positive
+func NewRequestLog(size int) *RequestLog {
+	if size <= 0 {
+		size = DefaultRequestLogSize
+	}
+	return &RequestLog{size: size}
+}
+
+// ObserveRequest implements RequestObserver, dropping the oldest request
+// once the log is full
+func (l *RequestLog) ObserveRequest(info RequestInfo) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < l.size {
+		l.entries = append(l.entries, info)
+		return
+	}
+	l.entries[l.next] = info
+	l.next = (l.next + 1) % l.size
+}
+
+// Entries returns the requests kept, oldest first
+func (l *RequestLog) Entries() []RequestInfo {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]RequestInfo, 0, len(l.entries))
+	entries = append(entries, l.entries[l.next:]...)
+	return append(entries, l.entries[:l.next]...)
+}
+
+// ObserveTransfer implements TransferObserver
+func (l *RequestLog) ObserveTransfer(bytes int, at time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sec := at.Unix()
+	i := ((sec % 60) + 60) % 60
+	if l.receivedAt[i] != sec {
+		l.receivedAt[i], l.received[i] = sec, 0
+	}
+	l.received[i] += int64(bytes)
+	l.total += int64(bytes)
+}
+
+// Received returns the bytes of response bodies read in the minute up to
+// now, and since the log was created
+func (l *RequestLog) Received(now time.Time) (lastMinute, total int64) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sec := now.Unix()
+	for i, at := range l.receivedAt {
+		if age := sec - at; age >= 0 && age < 60 {
+			lastMinute += l.received[i]
+		}
+	}
+	return lastMinute, l.total
+}
@@ -0,0 +1,117 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLog_Ring(t *testing.T) {
+	log := NewRequestLog(3)
+	for i := 0; i < 5; i++ {
+		log.ObserveRequest(RequestInfo{Path: fmt.Sprint(i)})
+	}
+	var paths []string
+	for _, e := range log.Entries() {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{"2", "3", "4"}, paths, "the oldest requests are dropped")
+
+	assert.Equal(t, DefaultRequestLogSize, NewRequestLog(0).size)
+
+	var none *RequestLog
+	none.ObserveRequest(RequestInfo{})
+	assert.Empty(t, none.Entries())
+}
+
+func TestHTTPClient_Observer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Echo", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	log := NewRequestLog(10)
+	client, err := NewHTTPClient(ClientOptions{
+		BaseURL: server.URL, TokenID: "root@pam!pvec", TokenSecret: "0f6c1e2a-7b3d-4c5e", Observer: log,
+	})
+	require.NoError(t, err)
+	_ = client.Start(context.Background(), "pve1", "qemu", "100")
+
+	entries := log.Entries()
+	require.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, "POST", e.Method)
+	assert.Equal(t, "/nodes/pve1/qemu/100/status/start", e.Path)
+	assert.Equal(t, http.StatusForbidden, e.Status)
+	assert.True(t, e.Failed())
+	assert.False(t, e.Time.IsZero())
+	assert.NotContains(t, e.Request.Get("Authorization"), "0f6c1e2a")
+	assert.NotContains(t, e.Response.Get("X-Echo"), "0f6c1e2a", "secrets echoed back are masked too")
+
+	// A request that got no response is reported with its error
+	server.Close()
+	_, err = client.GetNodes(context.Background())
+	require.Error(t, err)
+	entries = log.Entries()
+	require.Len(t, entries, 2)
+	assert.Zero(t, entries[1].Status)
+	assert.NotEmpty(t, entries[1].Err)
+	assert.Nil(t, entries[1].Response)
+	assert.Equal(t, "/cluster/resources?type=vm", entries[1].Path)
+
+	client.SetObserver(nil)
+	_, _ = client.GetNodes(context.Background())
+	assert.Len(t, log.Entries(), 2)
+}
+
+func TestRequestLog_Received(t *testing.T) {
+	log := NewRequestLog(10)
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	log.ObserveTransfer(1000, start)
+	log.ObserveTransfer(500, start.Add(30*time.Second))
+	log.ObserveTransfer(200, start.Add(30*time.Second))
+
+	minute, total := log.Received(start.Add(45 * time.Second))
+	assert.Equal(t, int64(1700), minute)
+	assert.Equal(t, int64(1700), total)
+
+	minute, total = log.Received(start.Add(75 * time.Second))
+	assert.Equal(t, int64(700), minute, "Bytes older than a minute drop out")
+	assert.Equal(t, int64(1700), total)
+
+	// The slot of a second a minute later starts afresh
+	log.ObserveTransfer(100, start.Add(90*time.Second))
+	minute, _ = log.Received(start.Add(90 * time.Second))
+	assert.Equal(t, int64(100), minute)
+
+	var none *RequestLog
+	none.ObserveTransfer(10, start)
+	minute, total = none.Received(start)
+	assert.Zero(t, minute+total)
+}
+
+func TestHTTPClient_CountsTransfers(t *testing.T) {
+	body := `{"data":[{"type":"qemu","vmid":100,"node":"pve1","status":"stopped"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	log := NewRequestLog(10)
+	client, err := NewHTTPClient(ClientOptions{
+		BaseURL: server.URL, TokenID: "root@pam!pvec", TokenSecret: "secret", Observer: log,
+	})
+	require.NoError(t, err)
+	_, err = client.GetNodes(context.Background())
+	require.NoError(t, err)
+
+	_, total := log.Received(time.Now())
+	assert.Equal(t, int64(len(body)), total)
+}
@@ -0,0 +1,95 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+)
+
+// SetTags replaces the tags of a guest; no tags removes them all
+func (c *HTTPClient) SetTags(ctx context.Context, node, vmType, vmid string, tags []string) error {
+	path := fmt.Sprintf("/nodes/%s/%s/%s/config", node, vmType, vmid)
+	form := url.Values{"tags": {strings.Join(tags, ";")}}
+	if len(tags) == 0 {
+		form = url.Values{"delete": {"tags"}}
+	}
+	resp, err := c.doRequestForm(ctx, "PUT", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to set the tags of %s %s: %w", vmType, vmid, newAPIError(resp, "PUT", path))
+	}
+	return nil
+}
+
+// AddToPool adds guests to a resource pool in one request. Guests already
+// in another pool are moved out of it, which Proxmox only does when told
+// to.
+func (c *HTTPClient) AddToPool(ctx context.Context, pool string, vmids []string) error {
+	path := "/pools/" + url.PathEscape(pool)
+	form := url.Values{"vms": {strings.Join(vmids, ",")}, "allow-move": {"1"}}
+	resp, err := c.doRequestForm(ctx, "PUT", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to add %s to pool %s: %w", strings.Join(vmids, ", "), pool, newAPIError(resp, "PUT", path))
+	}
+	return nil
+}
+
+// OrganizeExecutor adapts a GuestOrganizer to actions.Tagger and
+// actions.PoolMover, looking each guest's node and type up in a shared
+// list as ActionExecutor does. Tags are read from the guest's config.
+type OrganizeExecutor struct {
+	organizer GuestOrganizer
+	reader    ConfigReader
+	guests    models.NodeList // Kept current by whoever refreshes it
+}
+
+// NewOrganizeExecutor creates an organize executor over the guests list
+func NewOrganizeExecutor(organizer GuestOrganizer, reader ConfigReader, guests models.NodeList) *OrganizeExecutor {
+	return &OrganizeExecutor{organizer: organizer, reader: reader, guests: guests}
+}
+
+// Tags returns the tags of a guest, read from its config
+func (e *OrganizeExecutor) Tags(ctx context.Context, key string) ([]string, error) {
+	vm, found := e.guests.Get(key)
+	if !found {
+		return nil, ErrNodeNotFound
+	}
+	config, err := e.reader.GetVMConfig(ctx, vm.Node, vm.TypeString(), key)
+	if err != nil {
+		return nil, err
+	}
+	return configparse.Tags(config), nil
+}
+
+// SetTags replaces the tags of a guest
+func (e *OrganizeExecutor) SetTags(ctx context.Context, key string, tags []string) error {
+	vm, found := e.guests.Get(key)
+	if !found {
+		return ErrNodeNotFound
+	}
+	_, vmid := models.SplitKey(key)
+	return e.organizer.SetTags(ctx, vm.Node, vm.TypeString(), vmid, tags)
+}
+
+// AddToPool adds guests to a resource pool
+func (e *OrganizeExecutor) AddToPool(ctx context.Context, pool string, keys []string) error {
+	vmids := make([]string, len(keys))
+	for i, key := range keys {
+		_, vmids[i] = models.SplitKey(key)
+	}
+	return e.organizer.AddToPool(ctx, pool, vmids)
+}
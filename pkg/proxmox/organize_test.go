@@ -0,0 +1,86 @@
+package proxmox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/proxmoxtest"
+)
+
+func TestHTTPClient_SetTags(t *testing.T) {
+	var forms []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		require.NoError(t, r.ParseForm())
+		forms = append(forms, r.URL.Path+"?"+r.PostForm.Encode())
+		_, _ = w.Write([]byte(`{"data":null}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	require.NoError(t, client.SetTags(context.Background(), "pve1", "qemu", "100", []string{"prod", "web"}))
+	require.NoError(t, client.SetTags(context.Background(), "pve2", "lxc", "200", nil))
+
+	assert.Equal(t, []string{
+		"/api2/json/nodes/pve1/qemu/100/config?tags=prod%3Bweb",
+		"/api2/json/nodes/pve2/lxc/200/config?delete=tags",
+	}, forms)
+}
+
+func TestHTTPClient_AddToPool(t *testing.T) {
+	var form string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		require.NoError(t, r.ParseForm())
+		form = r.URL.Path + "?" + r.PostForm.Encode()
+		_, _ = w.Write([]byte(`{"data":null}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	require.NoError(t, client.AddToPool(context.Background(), "web", []string{"100", "101"}))
+
+	assert.Equal(t, "/api2/json/pools/web?allow-move=1&vms=100%2C101", form)
+}
+
+func TestHTTPClient_AddToPool_Refused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":{"poolid":"pool 'web' does not exist"},"data":null}`, http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	err := client.AddToPool(context.Background(), "web", []string{"100"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to add 100 to pool web")
+	assert.Contains(t, err.Error(), "does not exist")
+}
+
+func TestOrganizeExecutor_Tags(t *testing.T) {
+	web := proxmoxtest.CT(200, "web", "pve2", "running")
+	web.Tags = "prod;web"
+	server := proxmoxtest.NewServer(t, proxmoxtest.WithGuests(web))
+	client := NewClient(server.URL, "test-token", true).(*HTTPClient)
+	nodes, err := client.GetNodes(context.Background())
+	require.NoError(t, err)
+	list := models.NewNodeList()
+	list.ReplaceAll(nodes)
+	executor := NewOrganizeExecutor(client, client, list)
+
+	tags, err := executor.Tags(context.Background(), "200")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod", "web"}, tags)
+
+	require.NoError(t, executor.SetTags(context.Background(), "200", []string{"prod", "eu"}))
+	g, _ := server.Guest(200)
+	assert.Equal(t, "prod;eu", g.Tags)
+
+	_, err = executor.Tags(context.Background(), "999")
+	assert.ErrorIs(t, err, ErrNodeNotFound)
+}
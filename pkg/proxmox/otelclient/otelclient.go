@@ -0,0 +1,149 @@
+// Package otelclient traces the requests of a Proxmox client with
+// OpenTelemetry: one client span per API call, named after the call's path
+// template so that guests and nodes don't multiply the span names.
+//
+// It is a module of its own, so that pvec and the programs that use
+// pkg/proxmox without tracing don't depend on OpenTelemetry.
+package otelclient
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the spans
+const ScopeName = "github.com/tsupplis/pvec/pkg/proxmox/otelclient"
+
+// apiPrefix is the path of the API root on a Proxmox server
+const apiPrefix = "/api2/json"
+
+// Options configures the tracing
+type Options struct {
+	// TracerProvider creates the tracer (default otel.GetTracerProvider())
+	TracerProvider trace.TracerProvider
+	// Propagator writes the span context into the request headers
+	// (default otel.GetTextMapPropagator())
+	Propagator propagation.TextMapPropagator
+}
+
+// Transport is an http.RoundTripper that traces each request it sends
+type Transport struct {
+	base       http.RoundTripper
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// NewTransport returns a transport tracing the requests it passes on to
+// base, http.DefaultTransport when nil. The span of a request is a child
+// of the span in its context.
+func NewTransport(base http.RoundTripper, opts Options) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	provider := opts.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	propagator := opts.Propagator
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+	return &Transport{
+		base:       base,
+		tracer:     provider.Tracer(ScopeName),
+		propagator: propagator,
+	}
+}
+
+// NewHTTPClient is proxmox.NewHTTPClient with every request traced. The
+// transport in clientOpts, or the default one, sends the requests.
+func NewHTTPClient(clientOpts proxmox.ClientOptions, opts Options) (*proxmox.HTTPClient, error) {
+	base := clientOpts.Transport
+	if base == nil {
+		base = proxmox.DefaultTransport(clientOpts.SkipTLSVerify)
+		if clientOpts.TLSFingerprint != "" {
+			base = proxmox.PinnedTransport(clientOpts.TLSFingerprint)
+		}
+	}
+	clientOpts.Transport = NewTransport(base, opts)
+	return proxmox.NewHTTPClient(clientOpts)
+}
+
+// RoundTrip implements http.RoundTripper. The span ends once the response
+// headers arrive or the request fails.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	template := PathTemplate(req.URL.Path)
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLTemplate(template),
+		semconv.ServerAddress(req.URL.Hostname()),
+	}
+	if port, err := strconv.Atoi(req.URL.Port()); err == nil {
+		attrs = append(attrs, semconv.ServerPort(port))
+	}
+	ctx, span := t.tracer.Start(req.Context(), req.Method+" "+template,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+	defer span.End()
+
+	// A RoundTripper must not modify the request it was given
+	req = req.Clone(ctx)
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetAttributes(semconv.ErrorTypeKey.String(fmt.Sprintf("%T", err)))
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetAttributes(semconv.ErrorTypeKey.String(fmt.Sprint(resp.StatusCode)))
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// parameters names the path segments that follow each fixed one and vary
+// with the guest, node, storage or task
+var parameters = map[string]string{
+	"nodes":    "{node}",
+	"qemu":     "{vmid}",
+	"lxc":      "{vmid}",
+	"storage":  "{storage}",
+	"content":  "{volume}",
+	"tasks":    "{upid}",
+	"snapshot": "{snapname}",
+}
+
+// PathTemplate returns the API path of a request URL path with its
+// variable segments named, e.g. /nodes/{node}/qemu/{vmid}/status/start
+// for /api2/json/nodes/pve1/qemu/100/status/start
+func PathTemplate(path string) string {
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(path, apiPrefix), "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		name, ok := parameters[segments[i-1]]
+		if !ok {
+			continue
+		}
+		segments[i] = name
+		if name == "{volume}" {
+			// Volume IDs may hold slashes, e.g. local:iso/debian.iso
+			segments = segments[:i+1]
+			break
+		}
+		i++ // A parameter is never itself a fixed segment
+	}
+	return "/" + strings.Join(segments, "/")
+}
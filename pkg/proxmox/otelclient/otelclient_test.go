@@ -0,0 +1,115 @@
+package otelclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPathTemplate(t *testing.T) {
+	for path, want := range map[string]string{
+		"/api2/json/cluster/resources":                                         "/cluster/resources",
+		"/api2/json/nodes/pve1/qemu/100/status/start":                          "/nodes/{node}/qemu/{vmid}/status/start",
+		"/api2/json/nodes/pve1/lxc/200/config":                                 "/nodes/{node}/lxc/{vmid}/config",
+		"/api2/json/nodes/qemu/qemu/100/agent/get-fsinfo":                      "/nodes/{node}/qemu/{vmid}/agent/get-fsinfo",
+		"/api2/json/nodes/pve1/qemu/100/snapshot/before-upgrade":               "/nodes/{node}/qemu/{vmid}/snapshot/{snapname}",
+		"/api2/json/nodes/pve1/tasks/UPID:pve1:0003A1B2:0151C2D3:6710F3A0/log": "/nodes/{node}/tasks/{upid}/log",
+		"/api2/json/nodes/pve1/storage/local/content/local:iso/debian.iso":     "/nodes/{node}/storage/{storage}/content/{volume}",
+		"/api2/json/nodes/pve1/storage/local/content":                          "/nodes/{node}/storage/{storage}/content",
+		"/api2/json/nodes": "/nodes",
+	} {
+		assert.Equal(t, want, PathTemplate(path), path)
+	}
+}
+
+// attributes returns the attributes of a span by key
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestNewHTTPClient_Spans(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		if r.URL.Path == "/api2/json/nodes/pve1/qemu/100/status/start" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"qemu/100","vmid":100,"name":"web","type":"qemu","status":"running","node":"pve1","cpu":0.1}]}`))
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client, err := NewHTTPClient(proxmox.ClientOptions{
+		BaseURL: server.URL, TokenID: "root@pam!pvec", TokenSecret: "secret",
+	}, Options{TracerProvider: provider, Propagator: propagation.TraceContext{}})
+	require.NoError(t, err)
+
+	// The spans are children of the caller's
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "list")
+	_, err = client.GetNodes(ctx)
+	require.NoError(t, err)
+	assert.True(t, proxmox.IsForbidden(client.Start(ctx, "pve1", "qemu", "100")))
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	list, start := spans[0], spans[1]
+
+	assert.Equal(t, "GET /cluster/resources", list.Name)
+	assert.Equal(t, trace.SpanKindClient, list.SpanKind)
+	assert.Equal(t, parent.SpanContext().SpanID(), list.Parent.SpanID())
+	attrs := attributes(list)
+	assert.Equal(t, "GET", attrs["http.request.method"].AsString())
+	assert.Equal(t, "/cluster/resources", attrs["url.template"].AsString())
+	assert.Equal(t, int64(200), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, "127.0.0.1", attrs["server.address"].AsString())
+	assert.Equal(t, codes.Unset, list.Status.Code)
+
+	assert.Equal(t, "POST /nodes/{node}/qemu/{vmid}/status/start", start.Name)
+	attrs = attributes(start)
+	assert.Equal(t, int64(403), attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, "403", attrs["error.type"].AsString())
+	assert.Equal(t, codes.Error, start.Status.Code)
+
+	// The server saw the request's own span as its parent
+	assert.Contains(t, traceparent, start.SpanContext.SpanID().String())
+}
+
+func TestTransport_Error(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client := &http.Client{Transport: NewTransport(nil, Options{TracerProvider: provider})}
+
+	_, err := client.Get(server.URL + "/api2/json/nodes/pve1/lxc/200/status/current")
+	require.Error(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /nodes/{node}/lxc/{vmid}/status/current", spans[0].Name)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.NotEmpty(t, attributes(spans[0])["error.type"].AsString())
+	require.Len(t, spans[0].Events, 1, "the error is recorded")
+	assert.Equal(t, "exception", spans[0].Events[0].Name)
+	_, found := attributes(spans[0])["http.response.status_code"]
+	assert.False(t, found)
+}
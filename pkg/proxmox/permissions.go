@@ -0,0 +1,122 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// Requirement is a privilege pvec uses and the feature that fails without it
+type Requirement struct {
+	Privilege string
+	Path      string // ACL path the privilege is checked on; guests and nodes sit below it
+	Feature   string // What doesn't work without the privilege
+	Essential bool   // pvec shows nothing useful without it
+}
+
+// Requirements lists the privileges pvec uses, essential ones first
+var Requirements = []Requirement{
+	{Privilege: "VM.Audit", Path: "/vms", Feature: "guest list, details and configs", Essential: true},
+	{Privilege: "VM.PowerMgmt", Path: "/vms", Feature: "start, shutdown, reboot and stop"},
+	{Privilege: "VM.Monitor", Path: "/vms", Feature: "guest agent filesystems"},
+	{Privilege: "VM.Config.Cloudinit", Path: "/vms", Feature: "regenerating cloud-init"},
+	{Privilege: "VM.Snapshot", Path: "/vms", Feature: "snapshots before actions (snapshot_before)"},
+	{Privilege: "Sys.Audit", Path: "/nodes", Feature: "other users' tasks, downloads"},
+	{Privilege: "Sys.Modify", Path: "/nodes", Feature: "stopping other users' tasks"},
+	{Privilege: "Sys.PowerMgmt", Path: "/nodes", Feature: "node power and wake-on-LAN"},
+	{Privilege: "Datastore.Audit", Path: "/storage", Feature: "ISO and template browser"},
+	{Privilege: "Datastore.Allocate", Path: "/storage", Feature: "deleting ISOs and templates"},
+	{Privilege: "Datastore.AllocateTemplate", Path: "/storage", Feature: "downloading ISOs and templates"},
+}
+
+// MissingRequirements returns the requirements perms doesn't meet
+func MissingRequirements(perms models.Permissions) []Requirement {
+	var missing []Requirement
+	for _, r := range Requirements {
+		if !perms.Has(r.Path, r.Privilege) {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// GetPermissions returns the effective privileges of the token by ACL path
+func (c *HTTPClient) GetPermissions(ctx context.Context) (models.Permissions, error) {
+	path := "/access/permissions"
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get permissions: %w", newAPIError(resp, "GET", path))
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Each privilege maps to 1 when it propagates, 0 otherwise
+	var entries map[string]map[string]int
+	if err := json.Unmarshal(apiResp.Data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse permissions: %w", err)
+	}
+
+	perms := make(models.Permissions, len(entries))
+	for aclPath, privs := range entries {
+		perms[aclPath] = make(map[string]bool, len(privs))
+		for priv, propagate := range privs {
+			perms[aclPath][priv] = propagate != 0
+		}
+	}
+	return perms, nil
+}
+
+// permissionCheckRe matches the reason Proxmox gives for a 403, e.g.
+// "Permission check failed (/vms/103, VM.PowerMgmt)"
+var permissionCheckRe = regexp.MustCompile(`Permission check failed \((/[^,)]*), ([^)]+)\)`)
+
+// MissingPrivilege returns the ACL path and privilege a 403 error was
+// refused for, as reported by the server. Both are empty when err is not a
+// 403 or the server gave no reason.
+func MissingPrivilege(err error) (path, privilege string) {
+	var apiErr *APIError
+	if !IsForbidden(err) || !errors.As(err, &apiErr) {
+		return "", ""
+	}
+	m := permissionCheckRe.FindStringSubmatch(apiErr.Body)
+	if m == nil {
+		return "", ""
+	}
+	return m[1], strings.TrimSpace(m[2])
+}
+
+// PermissionHint explains a 403 error as the privilege the token lacks,
+// e.g. "needs VM.PowerMgmt on /vms/103". It is empty when the server gave
+// no reason.
+func PermissionHint(err error) string {
+	path, privilege := MissingPrivilege(err)
+	if privilege == "" {
+		return ""
+	}
+	return fmt.Sprintf("needs %s on %s", privilege, path)
+}
+
+// RequirementFor returns the requirement of privilege on path, if pvec
+// uses that privilege there
+func RequirementFor(path, privilege string) (Requirement, bool) {
+	for _, r := range Requirements {
+		if r.Privilege == privilege && (path == r.Path || strings.HasPrefix(path, r.Path+"/")) {
+			return r, true
+		}
+	}
+	return Requirement{}, false
+}
@@ -0,0 +1,75 @@
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient_GetPermissions(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	perms, err := client.GetPermissions(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, perms, 5)
+	assert.False(t, perms["/"]["Sys.Audit"], "Sys.Audit on / doesn't propagate")
+	assert.True(t, perms.Has("/vms/100", "VM.PowerMgmt"))
+	assert.False(t, perms.Has("/vms/103", "VM.PowerMgmt"))
+	assert.True(t, perms.Has("/nodes/pve1", "Sys.Audit"))
+	assert.False(t, perms.Has("/storage/local", "Datastore.Allocate"))
+}
+
+func TestHTTPClient_GetPermissions_Unauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "bad-token", true).(*HTTPClient)
+	_, err := client.GetPermissions(context.Background())
+	assert.True(t, IsUnauthorized(err))
+}
+
+func TestMissingPrivilege(t *testing.T) {
+	client := replayClient(t, "pve8")
+	err := client.Start(context.Background(), "pve1", "qemu", "103")
+	require.Error(t, err)
+
+	path, privilege := MissingPrivilege(err)
+	assert.Equal(t, "/vms/103", path)
+	assert.Equal(t, "VM.PowerMgmt", privilege)
+	assert.Equal(t, "needs VM.PowerMgmt on /vms/103", PermissionHint(err))
+
+	// No reason given, or not a 403 at all
+	bare := &APIError{StatusCode: http.StatusForbidden, Body: `{"data":null}`}
+	assert.Empty(t, PermissionHint(bare))
+	assert.Empty(t, PermissionHint(errors.New("Permission check failed (/vms/1, VM.Audit)")))
+}
+
+func TestRequirementFor(t *testing.T) {
+	r, ok := RequirementFor("/vms/103", "VM.PowerMgmt")
+	require.True(t, ok)
+	assert.Equal(t, "start, shutdown, reboot and stop", r.Feature)
+
+	_, ok = RequirementFor("/vmsx", "VM.PowerMgmt")
+	assert.False(t, ok)
+	_, ok = RequirementFor("/vms/100", "VM.Backup")
+	assert.False(t, ok)
+}
+
+func TestMissingRequirements(t *testing.T) {
+	client := replayClient(t, "pve8")
+	perms, err := client.GetPermissions(context.Background())
+	require.NoError(t, err)
+
+	var missing []string
+	for _, r := range MissingRequirements(perms) {
+		missing = append(missing, r.Privilege)
+	}
+	assert.Equal(t, []string{"VM.Config.Cloudinit", "VM.Snapshot", "Sys.Modify", "Sys.PowerMgmt", "Datastore.Allocate", "Datastore.AllocateTemplate"}, missing)
+}
@@ -0,0 +1,458 @@
+// Package proxmoxtest is a fake Proxmox VE API for the tests of code using
+// the proxmox client, pvec's own and that of tools embedding it.
+//
+// A Server serves the guests it is given from cluster/resources, their
+// config and current status, and carries out the power actions on them,
+// so a test that starts a guest then lists the guests sees it running:
+//
+//	server := proxmoxtest.NewServer(t,
+//		proxmoxtest.WithGuests(proxmoxtest.VM(100, "web", "pve1", "stopped")),
+//		proxmoxtest.WithErrorOn("/nodes/pve1/qemu/100/status/stop", http.StatusForbidden))
+//	client := proxmox.NewClient(server.URL, "PVEAPIToken=root@pam!test=secret", true)
+//
+// Actions answer the way Proxmox does: starting a running or locked guest
+// fails with the message Proxmox gives, and the tasks they return end at
+// once. The package only depends on the standard library, so the tests of
+// package proxmox can use it too.
+package proxmoxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// DefaultVersion is the Proxmox VE release the server reports, unless
+// WithVersion sets another
+const DefaultVersion = "8.2.4"
+
+// Guest is a VM or container of the fake cluster. Its status is one of
+// running, stopped and paused; a VM hibernated to disk is stopped with
+// the lock suspended.
+type Guest struct {
+	VMID    int
+	Name    string
+	Node    string
+	Type    string // qemu or lxc
+	Status  string
+	Lock    string // e.g. backup; "" when unlocked
+	HAState string // Requested HA state; "" when HA doesn't manage it
+	Pool    string
+	Tags    string // Semicolon-separated, as Proxmox lists them
+	CPU     float64
+	MaxCPU  int
+	Mem     int64 // Bytes in use while running
+	MaxMem  int64
+	Uptime  int64          // Seconds
+	Config  map[string]any // Extra config keys, beside name, cores and memory
+}
+
+// VM returns a VM fixture with 2 cores and 4 GiB of memory, a tenth of
+// it in use while running
+func VM(vmid int, name, node, status string) Guest {
+	return newGuest("qemu", vmid, name, node, status)
+}
+
+// CT returns a container fixture with 2 cores and 4 GiB of memory, a
+// tenth of it in use while running
+func CT(vmid int, name, node, status string) Guest {
+	return newGuest("lxc", vmid, name, node, status)
+}
+
+func newGuest(vmType string, vmid int, name, node, status string) Guest {
+	g := Guest{VMID: vmid, Name: name, Node: node, Type: vmType, Status: status, MaxCPU: 2, MaxMem: 4 << 30}
+	if status == "running" {
+		g.CPU, g.Mem, g.Uptime = 0.05, g.MaxMem/10, 3600
+	}
+	return g
+}
+
+// running reports whether the guest has a running process, paused or not
+func (g *Guest) running() bool {
+	return g.Status == "running" || g.Status == "paused"
+}
+
+// kind names the guest as Proxmox does in its messages
+func (g *Guest) kind() string {
+	if g.Type == "lxc" {
+		return "CT"
+	}
+	return "VM"
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithGuests adds guests to the cluster. A guest without a type is a VM.
+func WithGuests(guests ...Guest) Option {
+	return func(s *Server) {
+		for _, g := range guests {
+			if g.Type == "" {
+				g.Type = "qemu"
+			}
+			g.Config = maps.Clone(g.Config)
+			s.guests[g.VMID] = &g
+		}
+	}
+}
+
+// WithAuthToken makes the server answer 401 to requests without the token,
+// given as user@realm!name=secret or as the whole Authorization header.
+// Without it any token is accepted.
+func WithAuthToken(token string) Option {
+	return func(s *Server) {
+		s.authHeader = token
+		if !strings.HasPrefix(token, "PVEAPIToken=") {
+			s.authHeader = "PVEAPIToken=" + token
+		}
+	}
+}
+
+// WithLatency delays every answer by d, or until the request is cancelled
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) { s.latency = d }
+}
+
+// WithErrorOn makes requests to path, relative to /api2/json and without
+// its query, fail with the HTTP status code whatever their method
+func WithErrorOn(path string, code int) Option {
+	return func(s *Server) { s.errors[path] = code }
+}
+
+// WithVersion sets the Proxmox VE release /version reports
+func WithVersion(version string) Option {
+	return func(s *Server) { s.version = version }
+}
+
+// Server is a fake Proxmox VE API. It is safe for concurrent requests.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	guests     map[int]*Guest
+	authHeader string
+	latency    time.Duration
+	errors     map[string]int
+	version    string
+	requests   []string
+	tasks      int
+}
+
+// NewServer starts a server, closed when the test ends
+func NewServer(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	s := &Server{guests: map[int]*Guest{}, errors: map[string]int{}, version: DefaultVersion}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Guest returns the current state of a guest, as the actions left it
+func (s *Server) Guest(vmid int) (Guest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.guests[vmid]
+	if !ok {
+		return Guest{}, false
+	}
+	copied := *g
+	copied.Config = maps.Clone(g.Config)
+	return copied, true
+}
+
+// SetGuest adds a guest or replaces it, as a change made outside the
+// client would, e.g. a backup locking it
+func (s *Server) SetGuest(g Guest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	WithGuests(g)(s)
+}
+
+// Requests lists the requests served so far, as "METHOD /path" relative
+// to /api2/json and without their query
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.latency > 0 {
+		select {
+		case <-time.After(s.latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/api2/json")
+	s.requests = append(s.requests, r.Method+" "+path)
+
+	if s.authHeader != "" && r.Header.Get("Authorization") != s.authHeader {
+		writeError(w, http.StatusUnauthorized, "authentication failure")
+		return
+	}
+	if code, ok := s.errors[path]; ok {
+		writeError(w, code, http.StatusText(code))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case path == "/version" && r.Method == http.MethodGet:
+		writeData(w, map[string]string{"version": s.version, "release": release(s.version)})
+	case path == "/cluster/resources" && r.Method == http.MethodGet:
+		writeData(w, s.resources(r.URL.Query().Get("type")))
+	case len(parts) >= 5 && parts[0] == "nodes" && (parts[2] == "qemu" || parts[2] == "lxc"):
+		s.serveGuest(w, r, parts)
+	case len(parts) == 5 && parts[0] == "nodes" && parts[2] == "tasks" && parts[4] == "status":
+		writeData(w, map[string]any{"upid": parts[3], "node": parts[1], "status": "stopped", "exitstatus": "OK"})
+	default:
+		writeError(w, http.StatusNotImplemented, fmt.Sprintf("Method '%s %s' not implemented", r.Method, path))
+	}
+}
+
+// resources lists the guests, sorted by VMID, and unless filtered to vm
+// the nodes they run on
+func (s *Server) resources(filter string) []map[string]any {
+	var list []map[string]any
+	nodes := map[string]bool{}
+	for _, vmid := range slices.Sorted(maps.Keys(s.guests)) {
+		g := s.guests[vmid]
+		nodes[g.Node] = true
+		status := g.Status
+		if status == "paused" {
+			status = "running" // Only status/current tells a paused VM apart
+		}
+		entry := map[string]any{
+			"id": fmt.Sprintf("%s/%d", g.Type, g.VMID), "type": g.Type, "vmid": g.VMID, "name": g.Name,
+			"node": g.Node, "status": status, "template": 0, "cpu": g.CPU, "maxcpu": g.MaxCPU,
+			"mem": g.Mem, "maxmem": g.MaxMem, "uptime": g.Uptime,
+		}
+		for key, value := range map[string]string{"lock": g.Lock, "hastate": g.HAState, "pool": g.Pool, "tags": g.Tags} {
+			if value != "" {
+				entry[key] = value
+			}
+		}
+		list = append(list, entry)
+	}
+	if filter == "vm" {
+		return list
+	}
+	for _, node := range slices.Sorted(maps.Keys(nodes)) {
+		list = append(list, map[string]any{"id": "node/" + node, "type": "node", "node": node, "status": "online"})
+	}
+	return list
+}
+
+// serveGuest serves /nodes/{node}/{type}/{vmid}/...
+func (s *Server) serveGuest(w http.ResponseWriter, r *http.Request, parts []string) {
+	node, vmType := parts[1], parts[2]
+	vmid, _ := strconv.Atoi(parts[3])
+	g, ok := s.guests[vmid]
+	if !ok || g.Node != node || g.Type != vmType {
+		dir := "qemu-server"
+		if vmType == "lxc" {
+			dir = "lxc"
+		}
+		writeError(w, http.StatusInternalServerError,
+			fmt.Sprintf("Configuration file 'nodes/%s/%s/%s.conf' does not exist\n", node, dir, parts[3]))
+		return
+	}
+
+	endpoint := strings.Join(parts[4:], "/")
+	switch {
+	case endpoint == "config" && r.Method == http.MethodGet:
+		writeData(w, s.config(g))
+	case endpoint == "config" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		s.updateConfig(w, r, g)
+	case endpoint == "status/current" && r.Method == http.MethodGet:
+		writeData(w, s.current(g))
+	case strings.HasPrefix(endpoint, "status/") && r.Method == http.MethodPost:
+		s.act(w, r, g, strings.TrimPrefix(endpoint, "status/"))
+	default:
+		writeError(w, http.StatusNotImplemented, fmt.Sprintf("Method '%s %s' not implemented", r.Method, r.URL.Path))
+	}
+}
+
+// config is the guest's config: its extra keys over name, cores, memory
+// and lock
+func (s *Server) config(g *Guest) map[string]any {
+	config := map[string]any{"cores": g.MaxCPU, "memory": g.MaxMem >> 20}
+	if g.Type == "lxc" {
+		config["hostname"] = g.Name
+	} else {
+		config["name"] = g.Name
+	}
+	if g.Lock != "" {
+		config["lock"] = g.Lock
+	}
+	if g.Tags != "" {
+		config["tags"] = g.Tags
+	}
+	for key, value := range g.Config {
+		config[key] = value
+	}
+	return config
+}
+
+// updateConfig sets the keys of the form and deletes those listed in
+// delete; deleting lock unlocks the guest
+func (s *Server) updateConfig(w http.ResponseWriter, r *http.Request, g *Guest) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if g.Lock != "" && r.PostForm.Get("skiplock") != "1" && !slices.Contains(strings.Split(r.PostForm.Get("delete"), ","), "lock") {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("%s %d is locked (%s)\n", g.kind(), g.VMID, g.Lock))
+		return
+	}
+	if g.Config == nil {
+		g.Config = map[string]any{}
+	}
+	for key, values := range r.PostForm {
+		switch key {
+		case "delete", "skiplock", "digest":
+		case "tags":
+			g.Tags = values[0]
+		default:
+			g.Config[key] = values[0]
+		}
+	}
+	for _, key := range strings.Split(r.PostForm.Get("delete"), ",") {
+		switch key {
+		case "lock":
+			g.Lock = ""
+		case "tags":
+			g.Tags = ""
+		}
+		delete(g.Config, key)
+	}
+	writeData(w, nil)
+}
+
+// current is the guest's status/current
+func (s *Server) current(g *Guest) map[string]any {
+	status := g.Status
+	current := map[string]any{
+		"vmid": g.VMID, "name": g.Name, "cpu": g.CPU, "cpus": g.MaxCPU,
+		"mem": g.Mem, "maxmem": g.MaxMem, "uptime": g.Uptime,
+	}
+	if g.Type == "qemu" {
+		qmp := "running"
+		switch g.Status {
+		case "paused":
+			status, qmp = "running", "suspended"
+		case "stopped":
+			qmp = "stopped"
+		}
+		current["qmpstatus"] = qmp
+	}
+	current["status"] = status
+	if g.Lock != "" {
+		current["lock"] = g.Lock
+	}
+	return current
+}
+
+// act carries out a power action, answering with the UPID of a task that
+// has already ended
+func (s *Server) act(w http.ResponseWriter, r *http.Request, g *Guest, action string) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	refuse := func(why string) {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("%s %d %s\n", g.kind(), g.VMID, why))
+	}
+	hibernated := g.Lock == "suspended"
+	if g.Lock != "" && !(hibernated && action == "start") && r.PostForm.Get("skiplock") != "1" {
+		refuse("is locked (" + g.Lock + ")")
+		return
+	}
+
+	switch action {
+	case "start":
+		if g.running() {
+			refuse("already running")
+			return
+		}
+		g.Status, g.Lock, g.Uptime, g.CPU, g.Mem = "running", "", 0, 0.05, g.MaxMem/10
+	case "shutdown", "stop":
+		if !g.running() {
+			refuse("not running")
+			return
+		}
+		g.Status, g.Uptime, g.CPU, g.Mem = "stopped", 0, 0, 0
+	case "reboot":
+		if !g.running() {
+			refuse("not running")
+			return
+		}
+		g.Status, g.Uptime = "running", 0
+	case "suspend":
+		if g.Status != "running" {
+			refuse("not running")
+			return
+		}
+		if r.PostForm.Get("todisk") == "1" {
+			if g.Type != "qemu" {
+				refuse("can't be suspended to disk")
+				return
+			}
+			g.Status, g.Lock, g.Uptime, g.CPU, g.Mem = "stopped", "suspended", 0, 0, 0
+		} else {
+			g.Status, g.CPU = "paused", 0
+		}
+	case "resume":
+		if g.Status != "paused" {
+			refuse("not paused")
+			return
+		}
+		g.Status = "running"
+	default:
+		writeError(w, http.StatusNotImplemented, fmt.Sprintf("Method 'POST %s' not implemented", r.URL.Path))
+		return
+	}
+
+	s.tasks++
+	taskType := map[string]string{"qemu": "qm", "lxc": "vz"}[g.Type] + action
+	writeData(w, fmt.Sprintf("UPID:%s:%08X:%08X:%08X:%s:%d:root@pam:", g.Node, s.tasks, s.tasks, s.tasks, taskType, g.VMID))
+}
+
+// release is the major.minor of a version, as /version reports it
+func release(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	minor, _, _ := strings.Cut(parts[1], "-")
+	return parts[0] + "." + minor
+}
+
+// writeData answers data the way the API does, under "data"
+func writeData(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+// writeError answers an error the way the API does: null data and the
+// message in the body
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": nil, "message": message})
+}
@@ -0,0 +1,136 @@
+package proxmoxtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+const token = "PVEAPIToken=root@pam!test=secret"
+
+func newClient(t *testing.T, opts ...Option) (*Server, *proxmox.HTTPClient) {
+	t.Helper()
+	server := NewServer(t, opts...)
+	return server, proxmox.NewClient(server.URL, token, true).(*proxmox.HTTPClient)
+}
+
+func TestServer_ListsGuests(t *testing.T) {
+	db := VM(102, "db", "pve2", "running")
+	db.Pool, db.HAState, db.Tags = "prod", "started", "sql;prod"
+	_, client := newClient(t, WithGuests(CT(200, "cache", "pve1", "stopped"), VM(100, "web", "pve1", "running"), db))
+
+	guests, err := client.GetNodes(context.Background())
+	require.NoError(t, err)
+	require.Len(t, guests, 3)
+	assert.Equal(t, []string{"100", "102", "200"}, []string{guests[0].VMID, guests[1].VMID, guests[2].VMID})
+	assert.Equal(t, models.TypeContainer, guests[2].Type)
+	assert.Equal(t, models.StateRunning, guests[0].Status)
+	assert.Equal(t, "prod", guests[1].Pool)
+	assert.Equal(t, "started", guests[1].HAState)
+
+	config, err := client.GetVMConfig(context.Background(), "pve1", "lxc", "200")
+	require.NoError(t, err)
+	assert.Equal(t, "cache", config["hostname"])
+	assert.Equal(t, float64(4096), config["memory"])
+
+	version, err := client.GetVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, DefaultVersion, version)
+}
+
+func TestServer_ActionsChangeState(t *testing.T) {
+	server, client := newClient(t, WithGuests(VM(100, "web", "pve1", "running"), CT(200, "cache", "pve1", "stopped")))
+	ctx := context.Background()
+
+	require.NoError(t, client.Start(ctx, "pve1", "lxc", "200"))
+	ct, err := client.GetGuestStatus(ctx, "pve1", "lxc", "200")
+	require.NoError(t, err)
+	assert.Equal(t, models.StateRunning, ct.Status, "The guest should run once started")
+
+	err = client.Start(ctx, "pve1", "lxc", "200")
+	assert.ErrorContains(t, err, "CT 200 already running")
+
+	require.NoError(t, client.Hibernate(ctx, "pve1", "100"))
+	guests, err := client.GetNodes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.StateHibernated, guests[0].Status)
+	require.NoError(t, client.Start(ctx, "pve1", "qemu", "100"), "Starting resumes a hibernated VM")
+	vm, ok := server.Guest(100)
+	require.True(t, ok)
+	assert.Equal(t, "running", vm.Status)
+	assert.Empty(t, vm.Lock)
+
+	require.NoError(t, client.Stop(ctx, "pve1", "qemu", "100"))
+	vm, _ = server.Guest(100)
+	assert.Equal(t, "stopped", vm.Status)
+	assert.Zero(t, vm.Mem)
+	assert.ErrorContains(t, client.Shutdown(ctx, "pve1", "qemu", "100"), "VM 100 not running")
+}
+
+func TestServer_Locked(t *testing.T) {
+	locked := VM(100, "web", "pve1", "stopped")
+	locked.Lock = "backup"
+	server, client := newClient(t, WithGuests(locked))
+	ctx := context.Background()
+
+	assert.ErrorContains(t, client.Start(ctx, "pve1", "qemu", "100"), "VM 100 is locked (backup)")
+	require.NoError(t, client.ClearLock(ctx, "pve1", "qemu", "100"))
+	require.NoError(t, client.Start(ctx, "pve1", "qemu", "100"))
+
+	locked.Lock = "migrate"
+	server.SetGuest(locked)
+	assert.ErrorContains(t, client.Start(ctx, "pve1", "qemu", "100"), "VM 100 is locked (migrate)")
+}
+
+func TestServer_UnknownGuest(t *testing.T) {
+	_, client := newClient(t, WithGuests(VM(100, "web", "pve1", "running")))
+
+	_, err := client.GetGuestStatus(context.Background(), "pve2", "qemu", "100")
+	var apiErr *proxmox.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Body, "Configuration file 'nodes/pve2/qemu-server/100.conf' does not exist")
+}
+
+func TestServer_WithAuthToken(t *testing.T) {
+	server := NewServer(t, WithAuthToken("root@pam!test=other"))
+	_, err := proxmox.NewClient(server.URL, token, true).GetNodes(context.Background())
+	assert.True(t, proxmox.IsUnauthorized(err))
+
+	_, err = proxmox.NewClient(server.URL, "PVEAPIToken=root@pam!test=other", true).GetNodes(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestServer_WithErrorOn(t *testing.T) {
+	server, client := newClient(t, WithGuests(VM(100, "web", "pve1", "stopped")),
+		WithErrorOn("/nodes/pve1/qemu/100/status/start", http.StatusForbidden))
+
+	err := client.Start(context.Background(), "pve1", "qemu", "100")
+	assert.True(t, proxmox.IsForbidden(err))
+	vm, _ := server.Guest(100)
+	assert.Equal(t, "stopped", vm.Status, "A refused action changes nothing")
+	assert.Equal(t, []string{"POST /nodes/pve1/qemu/100/status/start"}, server.Requests())
+}
+
+func TestServer_WithLatency(t *testing.T) {
+	_, client := newClient(t, WithLatency(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.GetNodes(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestServer_WithVersion(t *testing.T) {
+	_, client := newClient(t, WithVersion("7.2-7"))
+
+	caps, err := client.ServerCapabilities(context.Background())
+	require.NoError(t, err)
+	assert.False(t, caps.CanEditTags())
+}
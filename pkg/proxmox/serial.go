@@ -0,0 +1,128 @@
+package proxmox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serialKeepAlive is how often the console connection is pinged, since
+// the terminal proxy drops connections that stay quiet
+const serialKeepAlive = 30 * time.Second
+
+// OpenSerialConsole connects read-only to a serial port of a VM, such as
+// serial0, through a terminal proxy, as the xterm.js console of the web
+// UI does. The stream returns what the guest writes from then on;
+// nothing typed is ever sent. Closing it disconnects.
+func (c *HTTPClient) OpenSerialConsole(ctx context.Context, node, vmid, serial string) (io.ReadCloser, error) {
+	proxy, err := c.termProxy(ctx, node, vmid, serial)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ticket travels twice: in the query, then as the first message
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/vncwebsocket?port=%s&vncticket=%s",
+		node, vmid, proxy.Port, url.QueryEscape(proxy.Ticket))
+	ws, err := c.dialWebSocket(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the serial console of %s: %w", vmid, err)
+	}
+	if err := ws.WriteMessage(wsText, []byte(proxy.User+":"+proxy.Ticket+"\n")); err != nil {
+		ws.Close()
+		return nil, fmt.Errorf("failed to connect to the serial console of %s: %w", vmid, err)
+	}
+	stream := &serialStream{ws: ws, done: make(chan struct{})}
+	go stream.keepAlive()
+	return stream, nil
+}
+
+// termProxy is what Proxmox answers when asked to open a terminal proxy
+type termProxy struct {
+	Port   json.Number `json:"port"`
+	Ticket string      `json:"ticket"`
+	User   string      `json:"user"`
+}
+
+// termProxy starts a terminal proxy on a serial port of a VM
+func (c *HTTPClient) termProxy(ctx context.Context, node, vmid, serial string) (termProxy, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/termproxy", node, vmid)
+	form := url.Values{"serial": {serial}}
+	resp, err := c.doRequestForm(ctx, "POST", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return termProxy{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return termProxy{}, fmt.Errorf("failed to open the serial console of %s: %w", vmid, newAPIError(resp, "POST", path))
+	}
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return termProxy{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	var proxy termProxy
+	if err := json.Unmarshal(apiResp.Data, &proxy); err != nil {
+		return termProxy{}, fmt.Errorf("failed to parse terminal proxy: %w", err)
+	}
+	return proxy, nil
+}
+
+// serialStream is the output of a serial console
+type serialStream struct {
+	ws      *wsConn
+	pending []byte
+	started bool // The proxy's OK, which leads the output, was dropped
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Read returns console output as it comes
+func (s *serialStream) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		message, err := s.ws.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		if !s.started {
+			s.started = true
+			message = bytes.TrimPrefix(message, []byte("OK"))
+		}
+		s.pending = message
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// keepAlive pings the proxy in its own protocol until the stream closes
+func (s *serialStream) keepAlive() {
+	ticker := time.NewTicker(serialKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if s.ws.WriteMessage(wsText, []byte("2")) != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close disconnects from the console
+func (s *serialStream) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		err = s.ws.Close()
+	})
+	return err
+}
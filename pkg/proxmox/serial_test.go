@@ -0,0 +1,122 @@
+package proxmox
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveWebSocket upgrades a request the way a WebSocket server does and
+// hands the connection to handle
+func serveWebSocket(t *testing.T, w http.ResponseWriter, r *http.Request, handle func(rw *bufio.ReadWriter)) {
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	require.NoError(t, err)
+	defer conn.Close()
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+	require.NoError(t, rw.Flush())
+	handle(rw)
+}
+
+// serverFrame builds an unmasked frame, as servers send them
+func serverFrame(fin bool, op byte, payload string) []byte {
+	head := op
+	if fin {
+		head |= 0x80
+	}
+	frame := []byte{head}
+	if len(payload) < 126 {
+		frame = append(frame, byte(len(payload)))
+	} else {
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	return append(frame, payload...)
+}
+
+func TestHTTPClient_OpenSerialConsole(t *testing.T) {
+	auth := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api2/json/nodes/pve1/qemu/100/termproxy":
+			assert.Equal(t, "POST", r.Method)
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "serial0", r.PostForm.Get("serial"))
+			_, _ = w.Write([]byte(`{"data":{"port":5900,"ticket":"PVEVNC:abc","user":"root@pam!pvec","upid":"UPID:pve1"}}`))
+		case "/api2/json/nodes/pve1/qemu/100/vncwebsocket":
+			assert.Equal(t, "5900", r.URL.Query().Get("port"))
+			assert.Equal(t, "PVEVNC:abc", r.URL.Query().Get("vncticket"))
+			assert.Equal(t, "PVEAPIToken=root@pam!pvec=secret", r.Header.Get("Authorization"))
+			serveWebSocket(t, w, r, func(rw *bufio.ReadWriter) {
+				// The client's first message is its ticket, masked
+				client := &wsConn{r: rw.Reader}
+				message, err := client.ReadMessage()
+				require.NoError(t, err)
+				auth <- string(message)
+
+				_, _ = rw.Write(serverFrame(true, wsBinary, "OK"))
+				_, _ = rw.Write(serverFrame(false, wsBinary, "Booting "))
+				_, _ = rw.Write(serverFrame(true, wsContinuation, "from disk\r\n"))
+				_, _ = rw.Write(serverFrame(true, wsBinary, strings.Repeat("x", 200)))
+				_, _ = rw.Write(serverFrame(true, wsClose, ""))
+				_ = rw.Flush()
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(ClientOptions{BaseURL: server.URL, TokenID: "root@pam!pvec", TokenSecret: "secret"})
+	require.NoError(t, err)
+	stream, err := client.OpenSerialConsole(context.Background(), "pve1", "100", "serial0")
+	require.NoError(t, err)
+	defer stream.Close()
+
+	output, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, "root@pam!pvec:PVEVNC:abc\n", <-auth)
+	assert.Equal(t, "Booting from disk\r\n"+strings.Repeat("x", 200), string(output), "the proxy's OK is dropped")
+}
+
+func TestHTTPClient_OpenSerialConsole_Refused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"data":null}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	_, err := client.OpenSerialConsole(context.Background(), "pve1", "100", "serial0")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to open the serial console of 100")
+}
+
+func TestWSConn_AnswersPings(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	conn := &wsConn{conn: client, r: bufio.NewReader(client)}
+	pong := make(chan string, 1)
+	go func() {
+		_, _ = server.Write(serverFrame(true, wsPing, "hi"))
+		_, op, payload, err := (&wsConn{r: bufio.NewReader(server)}).readFrame()
+		if err == nil && op == wsPong {
+			pong <- string(payload)
+		}
+		_, _ = server.Write(serverFrame(true, wsText, "data"))
+	}()
+
+	message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(message))
+	assert.Equal(t, "hi", <-pong)
+}
@@ -0,0 +1,243 @@
+package proxmox
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NewestKnownMajor is the latest Proxmox VE major release pvec knows the
+// API of; a newer server may have changed what pvec relies on
+const NewestKnownMajor = 9
+
+// ServerVersion is a Proxmox VE release, e.g. 8.2.4. The zero value is an
+// unknown version.
+type ServerVersion struct {
+	Major, Minor, Patch int
+	Pre                 string // Pre-release suffix, e.g. beta1 in 8.0.0~beta1
+}
+
+// ParseServerVersion reads a version as /version reports it: 8.2.4 on PVE
+// 8 and later, 7.4-3 with the package revision before, and with a ~
+// before a pre-release suffix, as in 9.0.0~10. Build metadata after a +
+// is ignored.
+func ParseServerVersion(s string) (ServerVersion, error) {
+	var v ServerVersion
+	rest, _, _ := strings.Cut(strings.TrimSpace(s), "+")
+	rest, v.Pre, _ = strings.Cut(rest, "~")
+	rest, revision, hasRevision := strings.Cut(rest, "-")
+
+	parts := strings.Split(rest, ".")
+	if len(parts) < 2 || len(parts) > 3 || (len(parts) == 3 && hasRevision) {
+		return ServerVersion{}, fmt.Errorf("invalid Proxmox VE version %q", s)
+	}
+	if hasRevision {
+		parts = append(parts, revision)
+	}
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			// A revision such as -rc1 marks a pre-release rather than a patch
+			if i == 2 && hasRevision && v.Pre == "" {
+				v.Pre = p
+				continue
+			}
+			return ServerVersion{}, fmt.Errorf("invalid Proxmox VE version %q", s)
+		}
+		*numbers[i] = n
+	}
+	return v, nil
+}
+
+// Known reports whether the version was read
+func (v ServerVersion) Known() bool {
+	return v != ServerVersion{}
+}
+
+// String formats the version as major.minor.patch, with its pre-release
+// suffix
+func (v ServerVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "~" + v.Pre
+	}
+	return s
+}
+
+// Compare orders two versions: a pre-release comes before its release,
+// and pre-releases of the same version compare numerically when they are
+// numbers
+func (v ServerVersion) Compare(o ServerVersion) int {
+	if c := cmp.Compare(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	a, errA := strconv.Atoi(v.Pre)
+	b, errB := strconv.Atoi(o.Pre)
+	if errA == nil && errB == nil {
+		return cmp.Compare(a, b)
+	}
+	return strings.Compare(v.Pre, o.Pre)
+}
+
+// AtLeast reports whether v is release major.minor or later. A
+// pre-release counts as the release it leads to: Proxmox betas ship its
+// API.
+func (v ServerVersion) AtLeast(major, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+// ServerFeature is a part of the API that came, or went, with a Proxmox
+// VE release
+type ServerFeature string
+
+// Server features pvec depends on
+const (
+	// ServerTags is editing the tags of a guest
+	ServerTags ServerFeature = "tags"
+	// ServerLXCReboot is rebooting a container in one call
+	ServerLXCReboot ServerFeature = "lxc-reboot"
+	// ServerResizeTask is the disk resize running as a task, returning
+	// its UPID rather than waiting for the resize
+	ServerResizeTask ServerFeature = "resize-task"
+	// ServerHAGroups is the HA groups, which HA rules replaced
+	ServerHAGroups ServerFeature = "ha-groups"
+)
+
+// serverFeature is an entry of the capability table
+type serverFeature struct {
+	feature     ServerFeature
+	description string // What it allows, for messages
+	since       [2]int // Release it came with, as major and minor
+	until       [2]int // Release it went with; zero while it is there
+}
+
+// serverFeatures is the capability table: which release brought each
+// feature, and removed it
+var serverFeatures = []serverFeature{
+	{feature: ServerTags, description: "editing tags", since: [2]int{7, 3}},
+	{feature: ServerLXCReboot, description: "rebooting containers", since: [2]int{6, 0}},
+	{feature: ServerResizeTask, description: "disk resize as a task", since: [2]int{8, 0}},
+	{feature: ServerHAGroups, description: "HA groups", until: [2]int{9, 0}},
+}
+
+// ServerCapabilities tells what the API of a server's release offers. The
+// zero value, for a server whose version isn't known, assumes it offers
+// everything, so an unknown server is never refused an action.
+type ServerCapabilities struct {
+	Version ServerVersion
+}
+
+// CapabilitiesFor returns the capabilities of a release, as /version
+// reports it; a version that doesn't parse is taken as unknown
+func CapabilitiesFor(version string) ServerCapabilities {
+	v, err := ParseServerVersion(version)
+	if err != nil {
+		return ServerCapabilities{}
+	}
+	return ServerCapabilities{Version: v}
+}
+
+// Has reports whether the server offers f
+func (c ServerCapabilities) Has(f ServerFeature) bool {
+	entry, ok := lookupServerFeature(f)
+	if !ok || !c.Version.Known() {
+		return true
+	}
+	if entry.since != [2]int{} && !c.Version.AtLeast(entry.since[0], entry.since[1]) {
+		return false
+	}
+	return entry.until == [2]int{} || !c.Version.AtLeast(entry.until[0], entry.until[1])
+}
+
+// CanEditTags reports whether the tags of a guest can be edited
+func (c ServerCapabilities) CanEditTags() bool { return c.Has(ServerTags) }
+
+// CanRebootLXC reports whether a container can be rebooted in one call
+func (c ServerCapabilities) CanRebootLXC() bool { return c.Has(ServerLXCReboot) }
+
+// ResizeReturnsTask reports whether a disk resize runs as a task
+func (c ServerCapabilities) ResizeReturnsTask() bool { return c.Has(ServerResizeTask) }
+
+// HasHAGroups reports whether HA groups can be listed and assigned
+func (c ServerCapabilities) HasHAGroups() bool { return c.Has(ServerHAGroups) }
+
+// Requirement explains why the server lacks f, e.g. "editing tags
+// requires PVE ≥ 7.3", or returns "" when it has it
+func (c ServerCapabilities) Requirement(f ServerFeature) string {
+	entry, ok := lookupServerFeature(f)
+	if !ok || c.Has(f) {
+		return ""
+	}
+	if entry.until != [2]int{} && c.Version.AtLeast(entry.until[0], entry.until[1]) {
+		return fmt.Sprintf("%s were removed in PVE %d.%d", entry.description, entry.until[0], entry.until[1])
+	}
+	return fmt.Sprintf("%s requires PVE ≥ %d.%d", entry.description, entry.since[0], entry.since[1])
+}
+
+// Missing lists why the server lacks each feature of the table it doesn't
+// have, in table order
+func (c ServerCapabilities) Missing() []string {
+	var missing []string
+	for _, entry := range serverFeatures {
+		if r := c.Requirement(entry.feature); r != "" {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// NewerThanKnown reports whether the server's major release is newer than
+// any pvec knows, so pvec may be outdated for it
+func (c ServerCapabilities) NewerThanKnown() bool {
+	return c.Version.Major > NewestKnownMajor
+}
+
+// lookupServerFeature returns the table entry of f
+func lookupServerFeature(f ServerFeature) (serverFeature, bool) {
+	for _, entry := range serverFeatures {
+		if entry.feature == f {
+			return entry, true
+		}
+	}
+	return serverFeature{}, false
+}
+
+// ServerInspector tells what the server's release offers
+type ServerInspector interface {
+	// ServerCapabilities reads the server version once, and returns what
+	// its release offers
+	ServerCapabilities(ctx context.Context) (ServerCapabilities, error)
+}
+
+// ServerCapabilities returns what the server's release offers, reading
+// its version on the first call only; a failed read is tried again on the
+// next call
+func (c *HTTPClient) ServerCapabilities(ctx context.Context) (ServerCapabilities, error) {
+	if caps := c.serverCaps.Load(); caps != nil {
+		return *caps, nil
+	}
+	version, err := c.GetVersion(ctx)
+	if err != nil {
+		return ServerCapabilities{}, err
+	}
+	caps := CapabilitiesFor(version)
+	c.serverCaps.Store(&caps)
+	return caps, nil
+}
@@ -0,0 +1,133 @@
+package proxmox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ServerVersion
+	}{
+		{"8.2.4", ServerVersion{Major: 8, Minor: 2, Patch: 4}},
+		{"8.2", ServerVersion{Major: 8, Minor: 2}},
+		{"7.4-3", ServerVersion{Major: 7, Minor: 4, Patch: 3}},
+		{" 6.4-13\n", ServerVersion{Major: 6, Minor: 4, Patch: 13}},
+		{"8.0.0~beta1", ServerVersion{Major: 8, Pre: "beta1"}},
+		{"9.0.0~11", ServerVersion{Major: 9, Pre: "11"}},
+		{"7.0-rc1", ServerVersion{Major: 7, Pre: "rc1"}},
+		{"8.1.3+pve1", ServerVersion{Major: 8, Minor: 1, Patch: 3}},
+	}
+	for _, tt := range tests {
+		got, err := ParseServerVersion(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, bad := range []string{"", "8", "8.x", "8.2.4-1", "8.2.4.1", "-1.0"} {
+		_, err := ParseServerVersion(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestServerVersion_Compare(t *testing.T) {
+	ordered := []string{"6.4-13", "7.0-rc1", "7.0-1", "7.4-3", "8.0.0~beta1", "8.0.0~beta2", "8.0.0", "8.2.4", "9.0.0~2", "9.0.0~10", "9.0.0", "10.0"}
+	for i := range ordered {
+		for j := range ordered {
+			a, err := ParseServerVersion(ordered[i])
+			require.NoError(t, err)
+			b, err := ParseServerVersion(ordered[j])
+			require.NoError(t, err)
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			assert.Equal(t, want, a.Compare(b), "%s vs %s", ordered[i], ordered[j])
+		}
+	}
+}
+
+func TestServerVersion_AtLeast(t *testing.T) {
+	beta, err := ParseServerVersion("8.0.0~beta1")
+	require.NoError(t, err)
+	assert.True(t, beta.AtLeast(8, 0), "A beta ships the API of its release")
+	assert.False(t, beta.AtLeast(8, 1))
+	assert.True(t, beta.AtLeast(7, 4))
+	assert.Equal(t, "8.0.0~beta1", beta.String())
+}
+
+func TestCapabilitiesFor(t *testing.T) {
+	tests := []struct {
+		version    string
+		tags       bool
+		lxcReboot  bool
+		resizeTask bool
+		haGroups   bool
+	}{
+		{"5.4-3", false, false, false, true},
+		{"6.0-4", false, true, false, true},
+		{"7.2-7", false, true, false, true},
+		{"7.3-3", true, true, false, true},
+		{"8.0.0~beta1", true, true, true, true},
+		{"8.4.1", true, true, true, true},
+		{"9.0.3", true, true, true, false},
+		{"garbage", true, true, true, true},
+	}
+	for _, tt := range tests {
+		caps := CapabilitiesFor(tt.version)
+		assert.Equal(t, tt.tags, caps.CanEditTags(), "%s tags", tt.version)
+		assert.Equal(t, tt.lxcReboot, caps.CanRebootLXC(), "%s LXC reboot", tt.version)
+		assert.Equal(t, tt.resizeTask, caps.ResizeReturnsTask(), "%s resize", tt.version)
+		assert.Equal(t, tt.haGroups, caps.HasHAGroups(), "%s HA groups", tt.version)
+	}
+}
+
+func TestServerCapabilities_Requirement(t *testing.T) {
+	old := CapabilitiesFor("7.2-7")
+	assert.Equal(t, "editing tags requires PVE ≥ 7.3", old.Requirement(ServerTags))
+	assert.Empty(t, old.Requirement(ServerLXCReboot))
+	assert.Equal(t, []string{"editing tags requires PVE ≥ 7.3", "disk resize as a task requires PVE ≥ 8.0"}, old.Missing())
+
+	latest := CapabilitiesFor("9.0.3")
+	assert.Equal(t, "HA groups were removed in PVE 9.0", latest.Requirement(ServerHAGroups))
+	assert.False(t, latest.NewerThanKnown())
+	assert.True(t, CapabilitiesFor("10.0.1").NewerThanKnown())
+
+	assert.Empty(t, ServerCapabilities{}.Missing(), "An unknown server is never refused")
+}
+
+func TestHTTPClient_ServerCapabilities(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api2/json/version", r.URL.Path)
+		calls++
+		if calls == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"version":"7.2-7","release":"7.2"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	ctx := context.Background()
+	_, err := client.ServerCapabilities(ctx)
+	require.Error(t, err)
+
+	caps, err := client.ServerCapabilities(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ServerVersion{Major: 7, Minor: 2, Patch: 7}, caps.Version)
+	assert.False(t, caps.CanEditTags())
+
+	_, err = client.ServerCapabilities(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls, "The version is read once it succeeded")
+}
@@ -0,0 +1,228 @@
+package proxmox
+
+import (
+	"context"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultCallTimeout bounds each API call of a snapshot, so one slow
+// endpoint can't hold back the sections that are ready
+const DefaultCallTimeout = 8 * time.Second
+
+// Section names a part of a RefreshSnapshot
+type Section string
+
+// Sections of a snapshot
+const (
+	SectionGuests      Section = "guests"
+	SectionNodes       Section = "nodes"
+	SectionStorage     Section = "storage"
+	SectionPools       Section = "pools"
+	SectionHA          Section = "ha"
+	SectionReplication Section = "replication"
+	SectionTasks       Section = "tasks"
+)
+
+// RefreshSnapshot is everything one refresh read, to be shown as a whole.
+// A section that failed is empty and has its error in Errors; the other
+// sections are still valid. A section the backend doesn't provide, or
+// whose feature is off, is empty without an error.
+type RefreshSnapshot struct {
+	Guests      []*models.VMStatus
+	Nodes       []models.ClusterNode
+	Storages    []models.Storage
+	Pools       []models.Pool
+	HA          []models.HAResource
+	Replication []models.ReplicationJob
+	Tasks       []models.Task // Running tasks, of every node
+	Errors      map[Section]error
+	TakenAt     time.Time
+}
+
+// Err returns the error of a section, nil when it was read
+func (s *RefreshSnapshot) Err(section Section) error {
+	return s.Errors[section]
+}
+
+// NodeLister lists the nodes of the cluster
+type NodeLister interface {
+	GetClusterNodes(ctx context.Context) ([]models.ClusterNode, error)
+}
+
+// StorageLister lists the storages of every node
+type StorageLister interface {
+	GetClusterStorages(ctx context.Context) ([]models.Storage, error)
+}
+
+// PoolLister lists the resource pools
+type PoolLister interface {
+	GetPools(ctx context.Context) ([]models.Pool, error)
+}
+
+// HALister lists the guests managed by HA
+type HALister interface {
+	GetHAResources(ctx context.Context) ([]models.HAResource, error)
+}
+
+// ReplicationLister lists the replication jobs
+type ReplicationLister interface {
+	GetReplicationJobs(ctx context.Context) ([]models.ReplicationJob, error)
+}
+
+// TaskLister lists the tasks running in the cluster
+type TaskLister interface {
+	GetClusterTasks(ctx context.Context) ([]models.Task, error)
+}
+
+// Provider takes snapshots, reading their sections concurrently. It
+// reads the optional sections only when the reader implements their
+// lister and Features has them enabled.
+type Provider struct {
+	reader StatusReader
+	// Features decides the optional sections read; nil reads all of
+	// them. The first snapshot probes the undecided ones.
+	Features *FeatureSet
+	// CallTimeout bounds each call within the snapshot's context
+	// (default DefaultCallTimeout)
+	CallTimeout time.Duration
+	// Concurrency caps the calls in flight; 0 runs them all at once and
+	// 1 runs them one after the other
+	Concurrency int
+}
+
+// NewProvider creates a provider reading from reader, detecting the
+// optional sections it can read
+func NewProvider(reader StatusReader) *Provider {
+	return &Provider{reader: reader, Features: NewFeatureSet(nil), CallTimeout: DefaultCallTimeout}
+}
+
+// Capabilities returns which optional sections are read, and why
+func (p *Provider) Capabilities() []Capability {
+	if p.Features == nil {
+		return nil
+	}
+	return p.Features.Capabilities()
+}
+
+// GetNodes returns the guests alone, so a provider can stand in wherever
+// only the guest list is needed
+func (p *Provider) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	return p.reader.GetNodes(ctx)
+}
+
+// sectionFetch reads one section into a snapshot
+type sectionFetch struct {
+	section Section
+	fetch   func(ctx context.Context) error
+}
+
+// Snapshot reads every section. It waits for all of them: a section that
+// fails or times out only leaves its own part of the snapshot empty. The
+// guests of a node listed as not online are marked NodeOffline.
+func (p *Provider) Snapshot(ctx context.Context) *RefreshSnapshot {
+	snap := &RefreshSnapshot{}
+	fetches := []sectionFetch{
+		{SectionGuests, func(ctx context.Context) (err error) {
+			snap.Guests, err = p.reader.GetNodes(ctx)
+			return err
+		}},
+	}
+	// optional adds the fetch of an optional section when its feature is
+	// on; a nil fetch is a section the backend doesn't provide
+	optional := func(f Feature, section Section, fetch func(ctx context.Context) error) {
+		switch {
+		case fetch == nil:
+			p.Features.disable(f, "not provided by the backend")
+		case p.Features.Enabled(f):
+			fetches = append(fetches, sectionFetch{section, func(ctx context.Context) error {
+				return p.Features.Observe(f, fetch(ctx))
+			}})
+		}
+	}
+	var fetchNodes, fetchStorages, fetchPools, fetchHA, fetchReplication, fetchTasks func(ctx context.Context) error
+	if nodes, ok := p.reader.(NodeLister); ok {
+		fetchNodes = func(ctx context.Context) (err error) {
+			snap.Nodes, err = nodes.GetClusterNodes(ctx)
+			return err
+		}
+	}
+	if storages, ok := p.reader.(StorageLister); ok {
+		fetchStorages = func(ctx context.Context) (err error) {
+			snap.Storages, err = storages.GetClusterStorages(ctx)
+			return err
+		}
+	}
+	if pools, ok := p.reader.(PoolLister); ok {
+		fetchPools = func(ctx context.Context) (err error) {
+			snap.Pools, err = pools.GetPools(ctx)
+			return err
+		}
+	}
+	if ha, ok := p.reader.(HALister); ok {
+		fetchHA = func(ctx context.Context) (err error) {
+			snap.HA, err = ha.GetHAResources(ctx)
+			return err
+		}
+	}
+	if jobs, ok := p.reader.(ReplicationLister); ok {
+		fetchReplication = func(ctx context.Context) (err error) {
+			snap.Replication, err = jobs.GetReplicationJobs(ctx)
+			return err
+		}
+	}
+	if tasks, ok := p.reader.(TaskLister); ok {
+		fetchTasks = func(ctx context.Context) (err error) {
+			snap.Tasks, err = tasks.GetClusterTasks(ctx)
+			return err
+		}
+	}
+	optional(FeatureNodeStatus, SectionNodes, fetchNodes)
+	optional(FeatureStorage, SectionStorage, fetchStorages)
+	optional(FeaturePools, SectionPools, fetchPools)
+	optional(FeatureHA, SectionHA, fetchHA)
+	optional(FeatureReplication, SectionReplication, fetchReplication)
+	optional(FeatureTasks, SectionTasks, fetchTasks)
+
+	// Each call writes only its own section and error slot. The calls
+	// never fail the group: a plain errgroup, not WithContext, so one
+	// error doesn't cancel the others.
+	errs := make([]error, len(fetches))
+	var g errgroup.Group
+	if p.Concurrency > 0 {
+		g.SetLimit(p.Concurrency)
+	}
+	for i, f := range fetches {
+		g.Go(func() error {
+			callCtx, cancel := p.callContext(ctx)
+			defer cancel()
+			errs[i] = f.fetch(callCtx)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	snap.TakenAt = time.Now()
+	// A node in maintenance or without pvestatd lists its guests as
+	// unknown and zeroed: flag them rather than let them read as failed
+	models.MarkNodesOffline(snap.Guests, snap.Nodes)
+	for i, err := range errs {
+		if err != nil {
+			if snap.Errors == nil {
+				snap.Errors = make(map[Section]error)
+			}
+			snap.Errors[fetches[i].section] = err
+		}
+	}
+	return snap
+}
+
+// callContext bounds one call by CallTimeout
+func (p *Provider) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.CallTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.CallTimeout)
+}
@@ -0,0 +1,182 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// delayedServer serves the pve8 fixtures of a snapshot after a delay,
+// answering the paths in fail with a 500
+func delayedServer(tb testing.TB, delay time.Duration, fail ...string) *httptest.Server {
+	tb.Helper()
+	bodies := map[string][]byte{}
+	for path, file := range map[string]string{
+		"/api2/json/cluster/resources":    "GET_cluster_resources.json",
+		"/api2/json/nodes":                "GET_nodes.json",
+		"/api2/json/pools":                "GET_pools.json",
+		"/api2/json/cluster/ha/resources": "GET_cluster_ha_resources.json",
+		"/api2/json/cluster/replication":  "GET_cluster_replication.json",
+		"/api2/json/cluster/tasks":        "GET_cluster_tasks.json",
+	} {
+		bodies[path] = fixtureBody(tb, file)
+	}
+	storages := fixtureBody(tb, "GET_cluster_resources_type_storage.json")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		for _, path := range fail {
+			if r.URL.Path == path {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		body := bodies[r.URL.Path]
+		if r.URL.Query().Get("type") == "storage" {
+			body = storages
+		}
+		_, _ = w.Write(body)
+	}))
+	tb.Cleanup(server.Close)
+	return server
+}
+
+// fixtureBody returns the response body stored in a pve8 fixture
+func fixtureBody(tb testing.TB, file string) []byte {
+	tb.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "pve8", file))
+	require.NoError(tb, err)
+	var fx struct {
+		Body json.RawMessage `json:"body"`
+	}
+	require.NoError(tb, json.Unmarshal(data, &fx))
+	return fx.Body
+}
+
+func TestProvider_Snapshot(t *testing.T) {
+	p := NewProvider(replayClient(t, "pve8"))
+
+	snap := p.Snapshot(context.Background())
+	assert.Empty(t, snap.Errors)
+	assert.Len(t, snap.Guests, 2)
+	assert.Len(t, snap.Nodes, 2)
+	assert.Len(t, snap.Storages, 2)
+	assert.Len(t, snap.Pools, 2)
+	assert.Len(t, snap.HA, 2)
+	assert.Len(t, snap.Replication, 2)
+	assert.Len(t, snap.Tasks, 2, "only the running tasks")
+	assert.False(t, snap.TakenAt.IsZero())
+}
+
+func TestProvider_PartialFailure(t *testing.T) {
+	server := delayedServer(t, 0, "/api2/json/nodes")
+	p := NewProvider(NewClient(server.URL, "token", true))
+
+	snap := p.Snapshot(context.Background())
+	assert.Len(t, snap.Guests, 2, "the guests don't depend on the nodes")
+	assert.Len(t, snap.Storages, 2)
+	assert.Nil(t, snap.Nodes)
+	assert.Error(t, snap.Err(SectionNodes))
+	assert.NoError(t, snap.Err(SectionGuests))
+}
+
+func TestProvider_CallTimeout(t *testing.T) {
+	server := delayedServer(t, 200*time.Millisecond)
+	p := NewProvider(NewClient(server.URL, "token", true))
+	p.CallTimeout = 20 * time.Millisecond
+
+	snap := p.Snapshot(context.Background())
+	for _, section := range []Section{SectionGuests, SectionNodes, SectionStorage} {
+		assert.ErrorIs(t, snap.Err(section), context.DeadlineExceeded, section)
+	}
+}
+
+func TestProvider_Concurrent(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	server := delayedServer(t, delay)
+	p := NewProvider(NewClient(server.URL, "token", true))
+
+	start := time.Now()
+	snap := p.Snapshot(context.Background())
+	require.Empty(t, snap.Errors)
+	assert.Less(t, time.Since(start), 2*delay, "the calls should overlap")
+
+	p.Concurrency = 1
+	start = time.Now()
+	p.Snapshot(context.Background())
+	assert.GreaterOrEqual(t, time.Since(start), 3*delay, "a limit of 1 runs the calls in turn")
+}
+
+// guestsOnly is a backend that lists guests and nothing else
+type guestsOnly struct{ StatusReader }
+
+func (g guestsOnly) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	return []*models.VMStatus{{VMID: "100"}}, nil
+}
+
+// offlineNode is a backend whose node pve2 is in maintenance
+type offlineNode struct{ StatusReader }
+
+func (offlineNode) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	return []*models.VMStatus{
+		{VMID: "100", Node: "pve1", Status: models.StateRunning},
+		{VMID: "200", Node: "pve2", Status: models.StateUnknown},
+	}, nil
+}
+
+func (offlineNode) GetClusterNodes(ctx context.Context) ([]models.ClusterNode, error) {
+	return []models.ClusterNode{{Name: "pve1", Online: true}, {Name: "pve2"}}, nil
+}
+
+func TestProvider_NodeOffline(t *testing.T) {
+	snap := NewProvider(offlineNode{}).Snapshot(context.Background())
+	require.Len(t, snap.Guests, 2)
+	assert.False(t, snap.Guests[0].NodeOffline)
+	assert.True(t, snap.Guests[1].NodeOffline)
+	assert.False(t, snap.Guests[1].IsKnown(models.MetricUptime))
+}
+
+func TestProvider_GuestsOnly(t *testing.T) {
+	p := NewProvider(guestsOnly{})
+
+	snap := p.Snapshot(context.Background())
+	assert.Empty(t, snap.Errors)
+	assert.Len(t, snap.Guests, 1)
+	assert.Nil(t, snap.Nodes, "the backend has no node section")
+	for _, c := range p.Capabilities() {
+		assert.False(t, c.Enabled, c.Feature)
+		assert.Equal(t, "not provided by the backend", c.Reason)
+	}
+
+	guests, err := p.GetNodes(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, guests, 1)
+}
+
+// benchmarkSnapshot takes snapshots from a server answering each call
+// after 5ms, as a nearby cluster would
+func benchmarkSnapshot(b *testing.B, concurrency int) {
+	server := delayedServer(b, 5*time.Millisecond)
+	p := NewProvider(NewClient(server.URL, "token", true))
+	p.Concurrency = concurrency
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if snap := p.Snapshot(context.Background()); len(snap.Errors) > 0 {
+			b.Fatal(snap.Errors)
+		}
+	}
+}
+
+func BenchmarkSnapshot_Serial(b *testing.B) { benchmarkSnapshot(b, 1) }
+
+func BenchmarkSnapshot_Concurrent(b *testing.B) { benchmarkSnapshot(b, 0) }
@@ -0,0 +1,151 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// nodeStorage represents one entry of the node storage endpoint
+type nodeStorage struct {
+	Storage string `json:"storage"`
+	Type    string `json:"type"`
+	Content string `json:"content"` // Comma-separated content types
+	Shared  int    `json:"shared"`
+}
+
+// storageVolume represents one entry of the storage content endpoint
+type storageVolume struct {
+	VolID   string `json:"volid"`
+	Content string `json:"content"`
+	Format  string `json:"format"`
+	Size    int64  `json:"size"`
+	CTime   int64  `json:"ctime"`
+}
+
+// ListStorages lists the enabled storages of a node
+func (c *HTTPClient) ListStorages(ctx context.Context, node string) ([]models.Storage, error) {
+	path := fmt.Sprintf("/nodes/%s/storage?enabled=1", node)
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get storages of node %s: %w", node, newAPIError(resp, "GET", path))
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var entries []nodeStorage
+	if err := json.Unmarshal(apiResp.Data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse storages of node %s: %w", node, err)
+	}
+
+	storages := make([]models.Storage, 0, len(entries))
+	for _, e := range entries {
+		var content []string
+		for _, c := range strings.Split(e.Content, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				content = append(content, c)
+			}
+		}
+		storages = append(storages, models.Storage{
+			Name:    e.Storage,
+			Node:    node,
+			Type:    e.Type,
+			Content: content,
+			Shared:  e.Shared != 0,
+		})
+	}
+	return storages, nil
+}
+
+// GetStorageContent lists the volumes of one content type on a storage
+func (c *HTTPClient) GetStorageContent(ctx context.Context, node, storage, contentType string) ([]models.StorageVolume, error) {
+	path := fmt.Sprintf("/nodes/%s/storage/%s/content?content=%s", node, storage, url.QueryEscape(contentType))
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get content of storage %s: %w", storage, newAPIError(resp, "GET", path))
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var entries []storageVolume
+	if err := json.Unmarshal(apiResp.Data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse content of storage %s: %w", storage, err)
+	}
+
+	volumes := make([]models.StorageVolume, 0, len(entries))
+	for _, e := range entries {
+		v := models.StorageVolume{
+			VolID:   e.VolID,
+			Node:    node,
+			Storage: storage,
+			Content: e.Content,
+			Format:  e.Format,
+			Size:    e.Size,
+		}
+		if e.CTime > 0 {
+			v.Created = time.Unix(e.CTime, 0)
+		}
+		volumes = append(volumes, v)
+	}
+	return volumes, nil
+}
+
+// DeleteVolume removes a volume from a storage
+func (c *HTTPClient) DeleteVolume(ctx context.Context, node, storage, volid string) error {
+	path := fmt.Sprintf("/nodes/%s/storage/%s/content/%s", node, storage, url.PathEscape(volid))
+	resp, err := c.doRequest(ctx, "DELETE", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete %s: %w", volid, newAPIError(resp, "DELETE", path))
+	}
+
+	return nil
+}
+
+// DownloadURL has the node download a file onto a storage and returns the
+// UPID of the download task
+func (c *HTTPClient) DownloadURL(ctx context.Context, node, storage, contentType, fileURL, filename string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/storage/%s/download-url", node, storage)
+
+	form := url.Values{
+		"content":  {contentType},
+		"filename": {filename},
+		"url":      {fileURL},
+	}
+	resp, err := c.doRequestForm(ctx, "POST", path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %w", filename, newAPIError(resp, "POST", path))
+	}
+	return decodeUPID(resp)
+}
@@ -0,0 +1,88 @@
+package proxmox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestHTTPClient_ListStorages(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	storages, err := client.ListStorages(context.Background(), "pve1")
+	require.NoError(t, err)
+	require.Len(t, storages, 3)
+
+	assert.Equal(t, "local", storages[0].Name)
+	assert.Equal(t, "pve1", storages[0].Node)
+	assert.Equal(t, []string{"iso", "vztmpl", "backup"}, storages[0].Content)
+	assert.False(t, storages[0].Shared)
+	assert.False(t, storages[1].Supports(models.ContentISO))
+	assert.True(t, storages[2].Shared)
+
+	_, err = client.ListStorages(context.Background(), "pve2")
+	assert.True(t, IsForbidden(err))
+}
+
+func TestHTTPClient_GetStorageContent(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	isos, err := client.GetStorageContent(context.Background(), "pve1", "local", models.ContentISO)
+	require.NoError(t, err)
+	require.Len(t, isos, 1)
+	assert.Equal(t, "local:iso/debian-12.4.0-amd64-netinst.iso", isos[0].VolID)
+	assert.Equal(t, "debian-12.4.0-amd64-netinst.iso", isos[0].Name())
+	assert.Equal(t, "local", isos[0].Storage)
+	assert.Equal(t, "pve1", isos[0].Node)
+	assert.Equal(t, int64(658505728), isos[0].Size)
+	assert.Equal(t, time.Unix(1702732800, 0), isos[0].Created)
+
+	templates, err := client.GetStorageContent(context.Background(), "pve1", "local", models.ContentTemplate)
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+	assert.Equal(t, models.ContentTemplate, templates[0].Content)
+}
+
+func TestHTTPClient_DeleteVolume(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	err := client.DeleteVolume(context.Background(), "pve1", "local", "local:iso/debian-12.4.0-amd64-netinst.iso")
+	assert.NoError(t, err)
+
+	err = client.DeleteVolume(context.Background(), "pve1", "local", "local:iso/missing.iso")
+	assert.Error(t, err)
+}
+
+func TestHTTPClient_DownloadURL(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	upid, err := client.DownloadURL(context.Background(), "pve1", "local", models.ContentISO,
+		"https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/x86_64/alpine-virt-3.19.1-x86_64.iso", "alpine-virt-3.19.1-x86_64.iso")
+	require.NoError(t, err)
+	assert.Equal(t, "UPID:pve1:00041C2A:0152A0B1:6710F9C2:download:alpine-virt-3.19.1-x86_64.iso:root@pam:", upid)
+}
+
+func TestHTTPClient_DownloadURL_Form(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api2/json/nodes/pve1/storage/local/download-url", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "vztmpl", r.PostForm.Get("content"))
+		assert.Equal(t, "alpine.tar.xz", r.PostForm.Get("filename"))
+		assert.Equal(t, "https://example.com/alpine.tar.xz", r.PostForm.Get("url"))
+		_, _ = w.Write([]byte(`{"data":"UPID:pve1:1"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	upid, err := client.DownloadURL(context.Background(), "pve1", "local", models.ContentTemplate,
+		"https://example.com/alpine.tar.xz", "alpine.tar.xz")
+	require.NoError(t, err)
+	assert.Equal(t, "UPID:pve1:1", upid)
+}
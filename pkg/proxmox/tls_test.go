@@ -0,0 +1,84 @@
+package proxmox
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTLSServer(t *testing.T) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"version": "8.2.4", "release": "8.2", "repoid": "faa83925"}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFingerprint(t *testing.T) {
+	server := newTLSServer(t)
+	fp := Fingerprint(server.Certificate())
+	assert.Len(t, fp, 95)
+	assert.Equal(t, strings.ToUpper(fp), fp)
+
+	assert.Equal(t, strings.ReplaceAll(fp, ":", ""), NormalizeFingerprint(strings.ToLower(fp)))
+	assert.Equal(t, strings.ReplaceAll(fp, ":", ""), NormalizeFingerprint(strings.ReplaceAll(fp, ":", " ")))
+	assert.Empty(t, NormalizeFingerprint("AB:CD"))
+	assert.Empty(t, NormalizeFingerprint(strings.Repeat("ZZ", 32)))
+}
+
+func TestPinnedTransport(t *testing.T) {
+	server := newTLSServer(t)
+	fp := Fingerprint(server.Certificate())
+
+	client := NewPinnedClient(server.URL, "PVEAPIToken=id=secret", strings.ToLower(fp))
+	_, err := client.(*HTTPClient).GetVersion(context.Background())
+	require.NoError(t, err, "The pinned certificate should be accepted without a trusted CA")
+
+	other := strings.Repeat("00:", 31) + "00"
+	client = NewPinnedClient(server.URL, "PVEAPIToken=id=secret", other)
+	_, err = client.(*HTTPClient).GetVersion(context.Background())
+	var mismatch *FingerprintMismatchError
+	require.True(t, errors.As(err, &mismatch), "got %v", err)
+	assert.Equal(t, fp, mismatch.Got)
+	assert.Equal(t, other, mismatch.Want)
+}
+
+func TestNewHTTPClient_TLSFingerprint(t *testing.T) {
+	server := newTLSServer(t)
+	client, err := NewHTTPClient(ClientOptions{BaseURL: server.URL, TokenID: "id", TokenSecret: "s", TLSFingerprint: Fingerprint(server.Certificate())})
+	require.NoError(t, err)
+	_, err = client.GetVersion(context.Background())
+	assert.NoError(t, err)
+
+	_, err = NewHTTPClient(ClientOptions{BaseURL: server.URL, TokenID: "id", TokenSecret: "s", TLSFingerprint: "AB:CD"})
+	assert.ErrorContains(t, err, "invalid TLS fingerprint")
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	server := newTLSServer(t)
+
+	err := VerifyCertificate(context.Background(), server.URL, nil)
+	require.Error(t, err)
+	cert := UntrustedCertificate(err)
+	require.NotNil(t, cert, "A self-signed certificate isn't trusted: %v", err)
+	assert.Equal(t, server.Certificate().Raw, cert.Raw)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	assert.NoError(t, VerifyCertificate(context.Background(), server.URL, roots))
+
+	assert.NoError(t, VerifyCertificate(context.Background(), "http://pve.test:8006", nil), "Plain HTTP has no certificate")
+
+	closed := httptest.NewTLSServer(http.NotFoundHandler())
+	closed.Close()
+	err = VerifyCertificate(context.Background(), closed.URL, nil)
+	require.Error(t, err)
+	assert.Nil(t, UntrustedCertificate(err), "An unreachable server has no certificate")
+}
@@ -0,0 +1,39 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// versionInfo represents the version endpoint
+type versionInfo struct {
+	Version string `json:"version"` // e.g. 8.2.4
+	Release string `json:"release"` // e.g. 8.2
+}
+
+// GetVersion returns the Proxmox VE version of the server, e.g. "8.2.4"
+func (c *HTTPClient) GetVersion(ctx context.Context) (string, error) {
+	path := "/version"
+	resp, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get version: %w", newAPIError(resp, "GET", path))
+	}
+
+	var apiResp proxmoxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var info versionInfo
+	if err := json.Unmarshal(apiResp.Data, &info); err != nil || info.Version == "" {
+		return "", fmt.Errorf("failed to parse version: %s", apiResp.Data)
+	}
+	return info.Version, nil
+}
@@ -0,0 +1,30 @@
+package proxmox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient_GetVersion(t *testing.T) {
+	client := replayClient(t, "pve8")
+
+	version, err := client.GetVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "8.2.4", version)
+}
+
+func TestHTTPClient_GetVersion_NotProxmox(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", true).(*HTTPClient)
+	_, err := client.GetVersion(context.Background())
+	assert.ErrorContains(t, err, "failed to parse version")
+}
@@ -0,0 +1,206 @@
+package proxmox
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 - required by the WebSocket handshake, not used for security
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/version"
+)
+
+// WebSocket opcodes (RFC 6455)
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// wsGUID is appended to the handshake key to compute the server's accept
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWSMessage bounds a message, so a broken server can't exhaust memory
+const maxWSMessage = 1 << 20
+
+// wsConn is a client WebSocket connection, as little as the serial
+// console needs: it reads data messages, answering pings, and writes
+// small messages
+type wsConn struct {
+	conn io.ReadWriteCloser
+	r    *bufio.Reader
+	mu   sync.Mutex // Serializes writes
+}
+
+// dialWebSocket upgrades a request on an API path to a WebSocket. The
+// request goes through the client's transport, so it authenticates and
+// verifies the server as every other request does.
+func (c *HTTPClient) dialWebSocket(ctx context.Context, path string) (*wsConn, error) {
+	url := fmt.Sprintf("%s/api2/json%s", c.baseURL, path)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req.Header.Set("Authorization", c.authToken)
+	req.Header.Set("User-Agent", version.UserAgent())
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Protocol", "binary")
+
+	// Not through do: its timeout would cut the stream
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	c.observe(req, resp, err, start)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp, "GET", path)
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, errors.New("the connection can't be upgraded to a WebSocket")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		conn.Close()
+		return nil, errors.New("the server answered the WebSocket handshake wrongly")
+	}
+	return &wsConn{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// wsAccept returns the accept value a server must answer a key with
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID)) // #nosec G401
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ReadMessage returns the next text or binary message, joining its
+// fragments. It answers pings on the way and returns io.EOF once the
+// server closes the connection.
+func (w *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, op, payload, err := w.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case wsPing:
+			if err := w.WriteMessage(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			_ = w.WriteMessage(wsClose, nil)
+			return nil, io.EOF
+		case wsText, wsBinary, wsContinuation:
+		default:
+			return nil, fmt.Errorf("unexpected WebSocket opcode %#x", op)
+		}
+		if len(message)+len(payload) > maxWSMessage {
+			return nil, fmt.Errorf("WebSocket message over %d bytes", maxWSMessage)
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// readFrame reads one frame, unmasking its payload
+func (w *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(w.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op = head[0]&0x80 != 0, head[0]&0x0f
+	size := uint64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(w.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(w.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if size > maxWSMessage {
+		return false, 0, nil, fmt.Errorf("WebSocket frame over %d bytes", maxWSMessage)
+	}
+	var mask [4]byte
+	masked := head[1]&0x80 != 0
+	if masked {
+		if _, err := io.ReadFull(w.r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, size)
+	if _, err := io.ReadFull(w.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// WriteMessage sends a message in a single frame, masked as a client must
+func (w *wsConn) WriteMessage(op byte, data []byte) error {
+	frame := []byte{0x80 | op}
+	switch size := len(data); {
+	case size < 126:
+		frame = append(frame, 0x80|byte(size))
+	case size <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(size))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(size))
+	}
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range data {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.conn.Write(frame)
+	return err
+}
+
+// Close drops the connection
+func (w *wsConn) Close() error {
+	return w.conn.Close()
+}
@@ -0,0 +1,155 @@
+// Package pvecclient is the stable Go API for listing and controlling
+// Proxmox VE guests, the same way pvec does. Its types and functions only
+// change in backward compatible ways; the other pvec packages, besides
+// pkg/models whose guest types are re-exported here, may change freely.
+//
+//	client, err := pvecclient.New(pvecclient.Options{
+//		BaseURL:     "https://pve.example.com:8006",
+//		TokenID:     "root@pam!pvec",
+//		TokenSecret: secret,
+//	})
+//	guests, err := client.Guests(ctx)
+package pvecclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// Guest is a VM or container with its current status and usage
+type Guest = models.VMStatus
+
+// GuestType tells VMs and containers apart
+type GuestType = models.NodeType
+
+// GuestState is the power state of a guest
+type GuestState = models.NodeState
+
+const (
+	// TypeVM is a QEMU virtual machine
+	TypeVM = models.TypeVM
+	// TypeContainer is an LXC container
+	TypeContainer = models.TypeContainer
+
+	// StateRunning is a running guest
+	StateRunning = models.StateRunning
+	// StateStopped is a stopped guest
+	StateStopped = models.StateStopped
+	// StatePaused is a paused VM
+	StatePaused = models.StatePaused
+	// StateHibernated is a VM suspended to disk; starting it resumes it
+	StateHibernated = models.StateHibernated
+	// StateUnknown is a guest whose node doesn't report it, usually
+	// because the node is offline
+	StateUnknown = models.StateUnknown
+)
+
+// ErrGuestNotFound is returned by Guest when no guest has the VMID
+var ErrGuestNotFound = errors.New("guest not found")
+
+// Options configures a Client
+type Options struct {
+	BaseURL     string // Server URL with its port, e.g. https://pve.example.com:8006
+	TokenID     string // API token ID, e.g. root@pam!pvec
+	TokenSecret string // API token secret
+	// SkipTLSVerify accepts any server certificate, such as the
+	// self-signed one Proxmox installs by default
+	SkipTLSVerify bool
+	// TLSFingerprint accepts only the server certificate with this SHA-256
+	// fingerprint, e.g. AB:CD:…, whoever signed it
+	TLSFingerprint string
+	// Transport replaces the default HTTP transport, for proxies or tests
+	Transport http.RoundTripper
+	// RequestTimeout bounds requests whose context has no deadline
+	// (default 30s)
+	RequestTimeout time.Duration
+}
+
+// Client talks to a Proxmox VE cluster. It is safe for concurrent use.
+type Client struct {
+	api *proxmox.HTTPClient
+}
+
+// New creates a client. It checks the options but doesn't contact the
+// server.
+func New(opts Options) (*Client, error) {
+	api, err := proxmox.NewHTTPClient(proxmox.ClientOptions{
+		BaseURL:        opts.BaseURL,
+		TokenID:        opts.TokenID,
+		TokenSecret:    opts.TokenSecret,
+		SkipTLSVerify:  opts.SkipTLSVerify,
+		TLSFingerprint: opts.TLSFingerprint,
+		Transport:      opts.Transport,
+		RequestTimeout: opts.RequestTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{api: api}, nil
+}
+
+// Guests lists every VM and container of the cluster
+func (c *Client) Guests(ctx context.Context) ([]*Guest, error) {
+	return c.api.GetNodes(ctx)
+}
+
+// Guest returns the guest with the given VMID
+func (c *Client) Guest(ctx context.Context, vmid string) (*Guest, error) {
+	guests, err := c.api.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range guests {
+		if g.VMID == vmid {
+			return g, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrGuestNotFound, vmid)
+}
+
+// Config returns the configuration of a guest, keyed as in the Proxmox
+// API (cores, memory, net0, ...)
+func (c *Client) Config(ctx context.Context, g *Guest) (map[string]interface{}, error) {
+	return c.api.GetVMConfig(ctx, g.Node, string(g.Type), g.VMID)
+}
+
+// Start starts a guest
+func (c *Client) Start(ctx context.Context, g *Guest) error {
+	return c.api.Start(ctx, g.Node, string(g.Type), g.VMID)
+}
+
+// Shutdown asks a guest to shut down cleanly
+func (c *Client) Shutdown(ctx context.Context, g *Guest) error {
+	return c.api.Shutdown(ctx, g.Node, string(g.Type), g.VMID)
+}
+
+// Reboot asks a guest to reboot cleanly
+func (c *Client) Reboot(ctx context.Context, g *Guest) error {
+	return c.api.Reboot(ctx, g.Node, string(g.Type), g.VMID)
+}
+
+// Stop powers a guest off immediately
+func (c *Client) Stop(ctx context.Context, g *Guest) error {
+	return c.api.Stop(ctx, g.Node, string(g.Type), g.VMID)
+}
+
+// Resume resumes a paused guest
+func (c *Client) Resume(ctx context.Context, g *Guest) error {
+	return c.api.Resume(ctx, g.Node, string(g.Type), g.VMID)
+}
+
+// IsUnauthorized reports whether err means the token was rejected
+func IsUnauthorized(err error) bool {
+	return proxmox.IsUnauthorized(err)
+}
+
+// IsForbidden reports whether err means the token lacks a privilege
+func IsForbidden(err error) bool {
+	return proxmox.IsForbidden(err)
+}
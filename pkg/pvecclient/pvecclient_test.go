@@ -0,0 +1,77 @@
+package pvecclient
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// replayClient returns a client answering from the proxmox package fixtures
+func replayClient(t *testing.T) *Client {
+	t.Helper()
+	replay, err := proxmox.LoadReplayTransport(filepath.Join("..", "proxmox", "testdata", "pve8"))
+	require.NoError(t, err)
+	client, err := New(Options{
+		BaseURL:     "https://pve.test:8006",
+		TokenID:     "root@pam!pvec",
+		TokenSecret: "00000000-0000-0000-0000-000000000000",
+		Transport:   replay,
+	})
+	require.NoError(t, err)
+	return client
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(Options{BaseURL: "pve.test", TokenID: "id", TokenSecret: "s"})
+	assert.Error(t, err)
+
+	_, err = New(Options{BaseURL: "https://pve.test:8006"})
+	assert.Error(t, err)
+}
+
+func TestClient_Guests(t *testing.T) {
+	client := replayClient(t)
+	ctx := context.Background()
+
+	guests, err := client.Guests(ctx)
+	require.NoError(t, err)
+	require.Len(t, guests, 2)
+
+	vm, err := client.Guest(ctx, "100")
+	require.NoError(t, err)
+	assert.Equal(t, TypeVM, vm.Type)
+	assert.Equal(t, StateRunning, vm.Status)
+	assert.Equal(t, "pve1", vm.Node)
+
+	ct, err := client.Guest(ctx, "200")
+	require.NoError(t, err)
+	assert.Equal(t, TypeContainer, ct.Type)
+	assert.Equal(t, StateStopped, ct.Status)
+
+	_, err = client.Guest(ctx, "999")
+	assert.True(t, errors.Is(err, ErrGuestNotFound))
+}
+
+func TestClient_ConfigAndActions(t *testing.T) {
+	client := replayClient(t)
+	ctx := context.Background()
+
+	vm, err := client.Guest(ctx, "100")
+	require.NoError(t, err)
+
+	config, err := client.Config(ctx, vm)
+	require.NoError(t, err)
+	assert.Equal(t, "test-vm", config["name"])
+	assert.EqualValues(t, 2, config["cores"])
+
+	assert.NoError(t, client.Start(ctx, vm))
+	assert.NoError(t, client.Shutdown(ctx, vm))
+	assert.NoError(t, client.Reboot(ctx, vm))
+	assert.NoError(t, client.Stop(ctx, vm))
+	assert.NoError(t, client.Resume(ctx, vm))
+}
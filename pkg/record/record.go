@@ -0,0 +1,145 @@
+// Package record writes the guests' usage to a file as the refreshes list
+// it, one JSON line per refresh, and sums such files up, for capacity
+// reviews done offline.
+package record
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// DefaultInterval is the time between two samples unless told otherwise
+const DefaultInterval = time.Minute
+
+// DefaultTimeout bounds the listing of a sample unless told otherwise
+const DefaultTimeout = 10 * time.Second
+
+// Sample is one line of a recording: the guests as one refresh listed them
+type Sample struct {
+	Time   time.Time `json:"time"`
+	Guests []Guest   `json:"guests"`
+}
+
+// Guest is what a sample keeps of a guest. The fields are those of
+// models.VMStatus, under the same keys.
+type Guest struct {
+	VMID        string           `json:"vmid"`
+	Name        string           `json:"name"`
+	Type        models.NodeType  `json:"type"`
+	Status      models.NodeState `json:"status"`
+	Node        string           `json:"node"`
+	CPUUsage    float64          `json:"cpu_usage"`    // Percent
+	MemoryUsage float64          `json:"memory_usage"` // Percent of MaxMem
+	MaxMem      int64            `json:"max_mem"`
+	Uptime      int64            `json:"uptime"`
+	Cluster     string           `json:"cluster,omitempty"`
+}
+
+// Key identifies the guest across clusters, like VMStatus.Key
+func (g Guest) Key() string {
+	return models.GuestKey(g.Cluster, g.VMID)
+}
+
+// Mem returns the memory the guest used, in bytes
+func (g Guest) Mem() int64 {
+	return int64(g.MemoryUsage / 100 * float64(g.MaxMem))
+}
+
+// NewSample returns the sample of guests listed at the given time
+func NewSample(at time.Time, guests []*models.VMStatus) Sample {
+	s := Sample{Time: at, Guests: make([]Guest, 0, len(guests))}
+	for _, vm := range guests {
+		if vm == nil {
+			continue
+		}
+		s.Guests = append(s.Guests, Guest{
+			VMID:        vm.VMID,
+			Name:        vm.Name,
+			Type:        vm.Type,
+			Status:      vm.Status,
+			Node:        vm.Node,
+			CPUUsage:    vm.CPUUsage,
+			MemoryUsage: vm.MemoryUsage,
+			MaxMem:      vm.MaxMem,
+			Uptime:      vm.Uptime,
+			Cluster:     vm.Cluster,
+		})
+	}
+	return s
+}
+
+// Lister lists the guests the token can see
+type Lister interface {
+	GetNodes(ctx context.Context) ([]*models.VMStatus, error)
+}
+
+// Recorder writes a sample of the guests on an interval
+type Recorder struct {
+	Lister   Lister
+	Writer   *Writer
+	Interval time.Duration                // Between two samples (default DefaultInterval)
+	Duration time.Duration                // How long to record; 0 until stopped
+	Timeout  time.Duration                // Bound of each listing (default DefaultTimeout)
+	Logf     func(string, ...interface{}) // Told of the samples skipped; may be nil
+}
+
+// Run samples the guests at once, then on every interval until Duration
+// has passed or ctx is cancelled, and returns the number of samples
+// written. A listing that fails skips its sample; failing to write stops
+// the recording.
+func (r *Recorder) Run(ctx context.Context) (int, error) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if r.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Duration)
+		defer cancel()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	written := 0
+	for {
+		ok, err := r.sample(ctx)
+		if err != nil {
+			return written, err
+		}
+		if ok {
+			written++
+		}
+		select {
+		case <-ctx.Done():
+			return written, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sample lists the guests and writes them, reporting whether it did
+func (r *Recorder) sample(ctx context.Context) (bool, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	listCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	at := time.Now()
+	guests, err := r.Lister.GetNodes(listCtx)
+	if err != nil {
+		// The end of the recording, not a failure of the server
+		if ctx.Err() == nil && r.Logf != nil {
+			r.Logf("Skipped the sample of %s: %v", at.Format("15:04:05"), err)
+		}
+		return false, nil
+	}
+	if err := r.Writer.Write(NewSample(at, guests)); err != nil {
+		return false, fmt.Errorf("failed to write the sample: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,119 @@
+package record
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestNewSample(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := NewSample(at, []*models.VMStatus{
+		{VMID: "100", Name: "web", Type: models.TypeVM, Status: models.StateRunning, Node: "pve1",
+			CPUUsage: 12.5, MemoryUsage: 50, MaxMem: 4 << 30, Uptime: 3600, MaxDisk: 32 << 30},
+		nil,
+	})
+	require.Len(t, s.Guests, 1)
+	assert.Equal(t, int64(2<<30), s.Guests[0].Mem())
+
+	line, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"time":"2026-03-01T12:00:00Z","guests":[{"vmid":"100","name":"web","type":"qemu",
+		"status":"running","node":"pve1","cpu_usage":12.5,"memory_usage":50,"max_mem":4294967296,"uptime":3600}]}`,
+		string(line))
+
+	// The keys are those of the full model
+	var full struct{ Guests []models.VMStatus }
+	require.NoError(t, json.Unmarshal(line, &full))
+	require.Len(t, full.Guests, 1)
+	assert.Equal(t, "web", full.Guests[0].Name)
+	assert.Equal(t, 12.5, full.Guests[0].CPUUsage)
+	assert.Equal(t, int64(3600), full.Guests[0].Uptime)
+}
+
+// countingLister lists one running guest, failing the listings it is
+// told to, and stops the recording after its last one
+type countingLister struct {
+	mu    sync.Mutex
+	calls int
+	fail  map[int]bool
+	last  int
+	stop  context.CancelFunc
+}
+
+func (l *countingLister) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	if l.calls == l.last {
+		l.stop()
+	}
+	if l.fail[l.calls] {
+		return nil, errors.New("connection refused")
+	}
+	return []*models.VMStatus{{VMID: "100", Status: models.StateRunning, CPUUsage: float64(l.calls)}}, nil
+}
+
+func TestRecorder_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.jsonl")
+	w, err := OpenWriter(path, 0)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lister := &countingLister{fail: map[int]bool{2: true}, last: 4, stop: cancel}
+	var logged []string
+	r := &Recorder{
+		Lister: lister, Writer: w, Interval: time.Millisecond,
+		Logf: func(format string, args ...interface{}) { logged = append(logged, fmt.Sprintf(format, args...)) },
+	}
+	written, err := r.Run(ctx)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// The last listing succeeds as the recording stops, and is kept
+	assert.Equal(t, 3, written)
+	require.Len(t, logged, 1)
+	assert.Contains(t, logged[0], "connection refused")
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	report, err := Aggregate(f)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Samples)
+	require.Len(t, report.Guests, 1)
+	assert.Equal(t, Stats{Min: 1, Max: 4, Sum: 8, Count: 3}, report.Guests[0].CPU)
+}
+
+func TestRecorder_Duration(t *testing.T) {
+	w, err := OpenWriter(filepath.Join(t.TempDir(), "cluster.jsonl"), 0)
+	require.NoError(t, err)
+	defer w.Close()
+
+	lister := &countingLister{stop: func() {}}
+	r := &Recorder{Lister: lister, Writer: w, Interval: time.Hour, Duration: 20 * time.Millisecond}
+	written, err := r.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, written, "the first sample is taken at once")
+}
+
+func TestRecorder_WriteError(t *testing.T) {
+	w, err := OpenWriter(filepath.Join(t.TempDir(), "cluster.jsonl"), 0)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r := &Recorder{Lister: &countingLister{stop: func() {}}, Writer: w, Interval: time.Millisecond}
+	_, err = r.Run(context.Background())
+	assert.ErrorContains(t, err, "failed to write the sample")
+}
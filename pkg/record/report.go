@@ -0,0 +1,143 @@
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// maxLine bounds a line of a recording: a sample of several thousand
+// guests
+const maxLine = 16 << 20
+
+// Stats sums up a series of values
+type Stats struct {
+	Min   float64
+	Max   float64
+	Sum   float64
+	Count int
+}
+
+// Add counts a value in
+func (s *Stats) Add(v float64) {
+	if s.Count == 0 || v < s.Min {
+		s.Min = v
+	}
+	if s.Count == 0 || v > s.Max {
+		s.Max = v
+	}
+	s.Sum += v
+	s.Count++
+}
+
+// Avg returns the mean of the values, 0 when there are none
+func (s Stats) Avg() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// GuestReport sums up the samples of one guest. CPU and Mem only count
+// the samples where it was running, so the time it was off doesn't drag
+// the averages down.
+type GuestReport struct {
+	Key     string
+	VMID    string
+	Name    string // As in the guest's last sample
+	Node    string // As in the guest's last sample
+	Samples int    // Samples the guest appears in
+	CPU     Stats  // Percent
+	Mem     Stats  // Bytes
+	MaxMem  int64  // As in the guest's last sample
+}
+
+// Report sums up a recording
+type Report struct {
+	From    time.Time // Time of the first sample
+	To      time.Time // Time of the last sample
+	Samples int
+	Skipped int           // Lines that aren't samples, such as one cut short
+	Guests  []GuestReport // Ordered by key
+}
+
+// Aggregator sums samples up as they come, keeping one entry per guest
+// rather than the samples, so a recording of any length can be reported
+type Aggregator struct {
+	report Report
+	guests map[string]*GuestReport
+}
+
+// NewAggregator returns an aggregator that has seen no sample
+func NewAggregator() *Aggregator {
+	return &Aggregator{guests: make(map[string]*GuestReport)}
+}
+
+// Add counts a sample in
+func (a *Aggregator) Add(s Sample) {
+	if a.report.Samples == 0 || s.Time.Before(a.report.From) {
+		a.report.From = s.Time
+	}
+	if a.report.Samples == 0 || s.Time.After(a.report.To) {
+		a.report.To = s.Time
+	}
+	a.report.Samples++
+	for _, g := range s.Guests {
+		r, ok := a.guests[g.Key()]
+		if !ok {
+			r = &GuestReport{Key: g.Key(), VMID: g.VMID}
+			a.guests[g.Key()] = r
+		}
+		r.Name, r.Node, r.MaxMem = g.Name, g.Node, g.MaxMem
+		r.Samples++
+		if g.Status == models.StateRunning {
+			r.CPU.Add(g.CPUUsage)
+			r.Mem.Add(float64(g.Mem()))
+		}
+	}
+}
+
+// Report returns what the samples added so far sum up to
+func (a *Aggregator) Report() Report {
+	report := a.report
+	report.Guests = make([]GuestReport, 0, len(a.guests))
+	for _, r := range a.guests {
+		report.Guests = append(report.Guests, *r)
+	}
+	sort.Slice(report.Guests, func(i, j int) bool {
+		return report.Guests[i].Key < report.Guests[j].Key
+	})
+	return report
+}
+
+// Aggregate reads a recording line by line and sums it up. Lines that
+// aren't samples are counted as skipped: a recording killed mid-write
+// ends with a partial one.
+func Aggregate(r io.Reader) (Report, error) {
+	a := NewAggregator()
+	skipped := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var s Sample
+		if err := json.Unmarshal(line, &s); err != nil || s.Time.IsZero() {
+			skipped++
+			continue
+		}
+		a.Add(s)
+	}
+	if err := scanner.Err(); err != nil {
+		return Report{}, err
+	}
+	report := a.Report()
+	report.Skipped = skipped
+	return report, nil
+}
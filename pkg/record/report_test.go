@@ -0,0 +1,97 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestStats(t *testing.T) {
+	var s Stats
+	assert.Zero(t, s.Avg())
+	for _, v := range []float64{4, 2, 9} {
+		s.Add(v)
+	}
+	assert.Equal(t, 2.0, s.Min)
+	assert.Equal(t, 9.0, s.Max)
+	assert.Equal(t, 5.0, s.Avg())
+}
+
+func TestAggregate(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	for i, g := range [][]Guest{
+		{{VMID: "100", Name: "web", Status: models.StateRunning, CPUUsage: 10, MemoryUsage: 25, MaxMem: 1000},
+			{VMID: "101", Name: "db", Status: models.StateStopped}},
+		{{VMID: "100", Name: "web", Status: models.StateRunning, CPUUsage: 30, MemoryUsage: 75, MaxMem: 1000},
+			{VMID: "101", Name: "db", Status: models.StateRunning, CPUUsage: 5, MemoryUsage: 10, MaxMem: 2000},
+			{VMID: "100", Name: "web", Cluster: "lab", Status: models.StateRunning, CPUUsage: 1}},
+	} {
+		line, err := json.Marshal(Sample{Time: start.Add(time.Duration(i) * time.Minute), Guests: g})
+		require.NoError(t, err)
+		buf.Write(append(line, '\n'))
+	}
+	buf.WriteString("\n{\"time\":\"2026-03-01T12:02:00Z\",\"gue") // Cut short by a kill
+
+	report, err := Aggregate(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Samples)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, start, report.From)
+	assert.Equal(t, start.Add(time.Minute), report.To)
+
+	require.Len(t, report.Guests, 3)
+	web, db, lab := report.Guests[0], report.Guests[1], report.Guests[2]
+	assert.Equal(t, "100", web.Key)
+	assert.Equal(t, 2, web.Samples)
+	assert.Equal(t, Stats{Min: 10, Max: 30, Sum: 40, Count: 2}, web.CPU)
+	assert.Equal(t, 500.0, web.Mem.Avg())
+	assert.Equal(t, 750.0, web.Mem.Max)
+
+	// The stopped sample counts as seen, not in the usage
+	assert.Equal(t, "101", db.Key)
+	assert.Equal(t, 2, db.Samples)
+	assert.Equal(t, 1, db.CPU.Count)
+	assert.Equal(t, 200.0, db.Mem.Min)
+
+	assert.Equal(t, "lab/100", lab.Key)
+	assert.Equal(t, 1, lab.Samples)
+}
+
+func TestAggregate_Empty(t *testing.T) {
+	report, err := Aggregate(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Zero(t, report.Samples)
+	assert.Empty(t, report.Guests)
+}
+
+// BenchmarkAggregate measures the report of a day of samples of 200
+// guests taken every minute, read as a stream
+func BenchmarkAggregate(b *testing.B) {
+	guests := make([]*models.VMStatus, 200)
+	for i := range guests {
+		guests[i] = &models.VMStatus{VMID: fmt.Sprint(100 + i),
+			Status: models.StateRunning, CPUUsage: float64(i % 100), MemoryUsage: 50, MaxMem: 4 << 30}
+	}
+	var buf bytes.Buffer
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 24*60; i++ {
+		line, _ := json.Marshal(NewSample(start.Add(time.Duration(i)*time.Minute), guests))
+		buf.Write(append(line, '\n'))
+	}
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Aggregate(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// DefaultMaxSize is the size past which a recording is rotated unless
+// told otherwise
+const DefaultMaxSize = 100 << 20
+
+// Backups is the number of rotated files kept: file.1, the newest, to
+// file.5
+const Backups = 5
+
+// Writer appends samples to a file, one JSON line each. Once the file
+// would grow past its size limit it is renamed file.1, the older ones
+// shifting up to file.5, and a new file is started.
+type Writer struct {
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+// OpenWriter opens path for appending, creating it if needed. A maxSize
+// that isn't positive means DefaultMaxSize.
+func OpenWriter(path string, maxSize int64) (*Writer, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	w := &Writer{path: path, maxSize: maxSize}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the file and reads the size it already has
+func (w *Writer) open() error {
+	// #nosec G304 - the path is the one the user gave on the command line
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	w.file, w.size = f, info.Size()
+	return nil
+}
+
+// Write appends a sample, rotating the file first if the line would take
+// it past the limit. A line is written whole, in one call, so a recording
+// cut short ends with at most one partial line.
+func (w *Writer) Write(s Sample) error {
+	line, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", w.path, err)
+		}
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// rotate shifts the backups up, dropping the oldest, and starts a new
+// file
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	for i := Backups - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", w.path, i)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", w.path, i+1)); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// Close writes the file to disk and closes it
+func (w *Writer) Close() error {
+	syncErr := w.file.Sync()
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	return syncErr
+}
@@ -0,0 +1,80 @@
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampleLine returns the line Write writes for s
+func sampleLine(t *testing.T, s Sample) []byte {
+	t.Helper()
+	line, err := json.Marshal(s)
+	require.NoError(t, err)
+	return append(line, '\n')
+}
+
+// readTimes returns the times of the samples in a file
+func readTimes(t *testing.T, path string) []int64 {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var times []int64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s Sample
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &s))
+		times = append(times, s.Time.Unix())
+	}
+	return times
+}
+
+func TestWriter_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.jsonl")
+	for i := int64(1); i <= 2; i++ {
+		w, err := OpenWriter(path, 0)
+		require.NoError(t, err)
+		require.NoError(t, w.Write(Sample{Time: time.Unix(i, 0)}))
+		require.NoError(t, w.Close())
+	}
+	assert.Equal(t, []int64{1, 2}, readTimes(t, path), "a new recording goes on from the old one")
+}
+
+func TestWriter_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.jsonl")
+	// Room for two lines per file
+	size := int64(len(sampleLine(t, Sample{Time: time.Unix(10, 0)})))
+	w, err := OpenWriter(path, 2*size)
+	require.NoError(t, err)
+	for i := int64(10); i < 10+2*(Backups+2); i++ {
+		require.NoError(t, w.Write(Sample{Time: time.Unix(i, 0)}))
+	}
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []int64{22, 23}, readTimes(t, path))
+	assert.Equal(t, []int64{20, 21}, readTimes(t, path+".1"))
+	assert.Equal(t, []int64{12, 13}, readTimes(t, fmt.Sprintf("%s.%d", path, Backups)))
+	_, err = os.Stat(fmt.Sprintf("%s.%d", path, Backups+1))
+	assert.True(t, os.IsNotExist(err), "the oldest file is dropped")
+}
+
+func TestWriter_LineLongerThanLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.jsonl")
+	w, err := OpenWriter(path, 1)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(Sample{Time: time.Unix(1, 0)}))
+	require.NoError(t, w.Write(Sample{Time: time.Unix(2, 0)}))
+	require.NoError(t, w.Close())
+
+	// Every file holds one line rather than none
+	assert.Equal(t, []int64{2}, readTimes(t, path))
+	assert.Equal(t, []int64{1}, readTimes(t, path+".1"))
+}
@@ -0,0 +1,141 @@
+// Package redact masks secrets in text shown to the user or logged: the
+// configured token secret wherever it appears, API tokens as sent in the
+// Authorization header, and token_secret settings quoted from a config
+// file.
+package redact
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// minSecretLength is the shortest secret masked; shorter values would
+// mask ordinary words. Proxmox token secrets are 36-character UUIDs.
+const minSecretLength = 8
+
+// SensitiveHeaders are the HTTP headers whose values are never logged
+var SensitiveHeaders = []string{"Authorization", "Cookie", "CSRFPreventionToken"}
+
+var (
+	mu      sync.RWMutex
+	secrets []string
+
+	// patterns match secrets whether registered or not; the last group
+	// of each is masked
+	patterns = []*regexp.Regexp{
+		regexp.MustCompile(`(PVEAPIToken=[^=\s]+=)([^\s"',;]+)`),
+		regexp.MustCompile(`(?i)(token_secret["']?\s*[:=]\s*["']?)([^"'\s,}]+)`),
+	}
+)
+
+// AddSecret registers a value to mask wherever it appears, such as the
+// token secret once the config is loaded. Values shorter than 8
+// characters are ignored.
+func AddSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// mask returns as many asterisks as s has characters, so that masked
+// text keeps its layout
+func mask(s string) string {
+	return strings.Repeat("*", utf8.RuneCountInString(s))
+}
+
+// String returns s with every secret masked
+func String(s string) string {
+	mu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, mask(secret))
+	}
+	mu.RUnlock()
+	for _, pattern := range patterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			groups := pattern.FindStringSubmatch(match)
+			return groups[1] + mask(groups[2])
+		})
+	}
+	return s
+}
+
+// redactedError is an error whose message has its secrets masked
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// Error returns err with its message masked. errors.Is and errors.As
+// still see the original error. It returns nil for nil.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if masked := String(msg); masked != msg {
+		return &redactedError{err: err, msg: masked}
+	}
+	return err
+}
+
+// Header returns a copy of h with the values of SensitiveHeaders masked
+// and secrets masked in the others, for logging requests
+func Header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for key, values := range h {
+		masked := make([]string, len(values))
+		for i, v := range values {
+			if isSensitiveHeader(key) {
+				masked[i] = mask(v)
+			} else {
+				masked[i] = String(v)
+			}
+		}
+		out[key] = masked
+	}
+	return out
+}
+
+// isSensitiveHeader reports whether the values of header are never logged
+func isSensitiveHeader(header string) bool {
+	for _, h := range SensitiveHeaders {
+		if strings.EqualFold(h, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// writer masks secrets in what is written through it
+type writer struct {
+	w io.Writer
+}
+
+// Writer wraps w so that secrets are masked in everything written to it.
+// Each write is masked on its own, which suits log.Logger as it writes a
+// whole line at a time.
+func Writer(w io.Writer) io.Writer {
+	return writer{w: w}
+}
+
+func (w writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
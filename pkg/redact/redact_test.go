@@ -0,0 +1,96 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "0f6c1e2a-7b3d-4c5e-9f8a-1b2c3d4e5f60"
+
+// withSecrets registers secrets for one test
+func withSecrets(t *testing.T, values ...string) {
+	t.Helper()
+	mu.Lock()
+	saved := secrets
+	secrets = nil
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		secrets = saved
+		mu.Unlock()
+	})
+	for _, v := range values {
+		AddSecret(v)
+	}
+}
+
+func TestString(t *testing.T) {
+	withSecrets(t, testSecret, "short")
+
+	got := String("failed to parse body containing " + testSecret + " twice: " + testSecret)
+	assert.NotContains(t, got, testSecret)
+	assert.Equal(t, "failed to parse body containing ************************************ twice: ************************************", got)
+	assert.Equal(t, "short words stay", String("short words stay"), "Secrets under 8 characters are ignored")
+}
+
+func TestString_Patterns(t *testing.T) {
+	withSecrets(t)
+
+	tests := map[string]string{
+		"Authorization: PVEAPIToken=root@pam!pvec=abcdef12-3456": "Authorization: PVEAPIToken=root@pam!pvec=*************",
+		`{"token_id": "a@pam!t", "token_secret": "abc-123"}`:     `{"token_id": "a@pam!t", "token_secret": "*******"}`,
+		"token_secret: abc-123\nrefresh_interval: 5s":            "token_secret: *******\nrefresh_interval: 5s",
+		"TOKEN_SECRET = 'abc-123'":                               "TOKEN_SECRET = '*******'",
+		"token secret is required":                               "token secret is required",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, String(in), in)
+	}
+}
+
+func TestError(t *testing.T) {
+	withSecrets(t, testSecret)
+	sentinel := errors.New("authentication failed")
+	err := fmt.Errorf("status 401: bad token %s: %w", testSecret, sentinel)
+
+	masked := Error(err)
+	assert.NotContains(t, masked.Error(), testSecret)
+	assert.ErrorIs(t, masked, sentinel)
+
+	clean := errors.New("connection refused")
+	assert.Same(t, clean, Error(clean), "Errors without secrets are returned as they are")
+	assert.NoError(t, Error(nil))
+}
+
+func TestHeader(t *testing.T) {
+	withSecrets(t, testSecret)
+	token := "PVEAPIToken=root@pam!pvec=" + testSecret
+	h := http.Header{}
+	h.Set("Authorization", token)
+	h.Set("Accept", "application/json")
+	h.Set("X-Echo", testSecret)
+
+	masked := Header(h)
+
+	assert.Equal(t, strings.Repeat("*", len(token)), masked.Get("Authorization"))
+	assert.Equal(t, "application/json", masked.Get("Accept"))
+	assert.NotContains(t, masked.Get("X-Echo"), testSecret)
+	assert.Contains(t, h.Get("Authorization"), testSecret, "The request itself is left alone")
+}
+
+func TestWriter(t *testing.T) {
+	withSecrets(t, testSecret)
+	var buf bytes.Buffer
+	logger := log.New(Writer(&buf), "", 0)
+
+	logger.Printf("refresh failed: %v", errors.New("echoed "+testSecret))
+
+	assert.Equal(t, "refresh failed: echoed ************************************\n", buf.String())
+}
@@ -0,0 +1,209 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// ScheduleActions are the power actions that can be scheduled, in the
+// order the schedule prompt offers them
+var ScheduleActions = []string{"shutdown", "stop", "reboot", "start"}
+
+// MaxFinished is the number of finished scheduled actions kept as history
+const MaxFinished = 20
+
+// ScheduledAction is a power action to run on a guest at a given time.
+// Once it ran, Ran and Error record the outcome.
+type ScheduledAction struct {
+	ID      int             `json:"id"`
+	Action  string          `json:"action"` // One of ScheduleActions
+	VMID    string          `json:"vmid"`
+	Cluster string          `json:"cluster,omitempty"`
+	Name    string          `json:"name"`
+	Node    string          `json:"node"`
+	Type    models.NodeType `json:"type"`
+	At      time.Time       `json:"at"`
+	Created time.Time       `json:"created"`
+	Late    bool            `json:"late,omitempty"` // Was overdue when pvec started
+	Ran     time.Time       `json:"ran,omitzero"`   // When it ran; zero while pending
+	Error   string          `json:"error,omitempty"`
+}
+
+// Key identifies the guest across clusters, like VMStatus.Key
+func (a ScheduledAction) Key() string {
+	return models.GuestKey(a.Cluster, a.VMID)
+}
+
+// Pending reports whether the action has yet to run
+func (a ScheduledAction) Pending() bool {
+	return a.Ran.IsZero()
+}
+
+// Schedule adds a pending action and saves the state. The action is
+// scheduled for this session even when saving fails.
+func (f *File) Schedule(a ScheduledAction) (ScheduledAction, error) {
+	if !validAction(a.Action) {
+		return a, fmt.Errorf("cannot schedule %q: not one of %s", a.Action, strings.Join(ScheduleActions, ", "))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data.NextID++
+	a.ID = f.data.NextID
+	a.Ran = time.Time{}
+	a.Error = ""
+	f.data.Scheduled = append(f.data.Scheduled, a)
+	return a, f.save()
+}
+
+// Unschedule cancels the pending action id
+func (f *File) Unschedule(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, a := range f.data.Scheduled {
+		if a.ID == id && a.Pending() {
+			f.data.Scheduled = append(f.data.Scheduled[:i:i], f.data.Scheduled[i+1:]...)
+			return f.save()
+		}
+	}
+	return fmt.Errorf("no pending scheduled action %d", id)
+}
+
+// Finish records that action id ran at at, failing with err if set, and
+// drops the oldest finished actions beyond MaxFinished
+func (f *File) Finish(id int, at time.Time, err error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	found := false
+	for i := range f.data.Scheduled {
+		if a := &f.data.Scheduled[i]; a.ID == id && a.Pending() {
+			a.Ran = at
+			if err != nil {
+				a.Error = err.Error()
+			}
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("no pending scheduled action %d", id)
+	}
+
+	finished := 0
+	for i := len(f.data.Scheduled) - 1; i >= 0; i-- {
+		if f.data.Scheduled[i].Pending() {
+			continue
+		}
+		if finished++; finished > MaxFinished {
+			f.data.Scheduled = append(f.data.Scheduled[:i:i], f.data.Scheduled[i+1:]...)
+		}
+	}
+	return f.save()
+}
+
+// Scheduled returns the pending actions, soonest first, followed by the
+// finished ones, most recent first
+func (f *File) Scheduled() []ScheduledAction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all := append([]ScheduledAction(nil), f.data.Scheduled...)
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.Pending() != b.Pending() {
+			return a.Pending()
+		}
+		if a.Pending() {
+			return a.At.Before(b.At)
+		}
+		return a.Ran.After(b.Ran)
+	})
+	return all
+}
+
+// Next returns the pending action that runs soonest
+func (f *File) Next() (ScheduledAction, bool) {
+	for _, a := range f.Scheduled() {
+		if a.Pending() {
+			return a, true
+		}
+	}
+	return ScheduledAction{}, false
+}
+
+// Due returns the pending actions whose time has come, soonest first
+func (f *File) Due(now time.Time) []ScheduledAction {
+	var due []ScheduledAction
+	for _, a := range f.Scheduled() {
+		if a.Pending() && !a.At.After(now) {
+			due = append(due, a)
+		}
+	}
+	return due
+}
+
+// Rearm marks the pending actions that became due while pvec wasn't
+// running as late, and returns them. They run like any due action; Late
+// only tells the user why they ran past their time.
+func (f *File) Rearm(now time.Time) []ScheduledAction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var late []ScheduledAction
+	for i := range f.data.Scheduled {
+		if a := &f.data.Scheduled[i]; a.Pending() && !a.At.After(now) {
+			a.Late = true
+			late = append(late, *a)
+		}
+	}
+	if len(late) > 0 {
+		_ = f.save() // Late is only informative; the next change saves it again
+	}
+	return late
+}
+
+func validAction(action string) bool {
+	for _, a := range ScheduleActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// whenLayouts are the absolute times ParseWhen accepts besides a time of day
+var whenLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04"}
+
+// ParseWhen reads when a scheduled action should run, relative to now:
+// a delay ("in 2h", "in 1h30m", "45m"), a time of day ("22:00", the next
+// one to come) or a date and time ("2026-10-18 06:00"), in now's location
+func ParseWhen(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	delay := strings.ToLower(s)
+	delay, relative := strings.CutPrefix(delay, "in ")
+	if d, err := time.ParseDuration(strings.ReplaceAll(delay, " ", "")); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("%q is not a delay in the future", s)
+		}
+		return now.Add(d).Truncate(time.Second), nil
+	} else if relative {
+		return time.Time{}, fmt.Errorf("cannot read %q as a delay; use e.g. \"in 2h\" or \"in 1h30m\"", s)
+	}
+
+	if t, err := time.ParseInLocation("15:04", s, now.Location()); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	for _, layout := range whenLayouts {
+		if at, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			if !at.After(now) {
+				return time.Time{}, fmt.Errorf("%s is in the past", s)
+			}
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot read %q as a time; use e.g. \"in 2h\", \"22:00\" or \"2026-10-18 06:00\"", s)
+}
@@ -0,0 +1,136 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2026, 10, 17, 18, 30, 0, 0, time.UTC)
+
+func schedule(t *testing.T, f *File, action string, at time.Time) ScheduledAction {
+	t.Helper()
+	a, err := f.Schedule(ScheduledAction{Action: action, VMID: "100", Name: "web", At: at, Created: now})
+	require.NoError(t, err)
+	return a
+}
+
+func TestSchedule_Order(t *testing.T) {
+	f, _ := Open("")
+	later := schedule(t, f, "start", now.Add(2*time.Hour))
+	sooner := schedule(t, f, "shutdown", now.Add(time.Hour))
+	ran := schedule(t, f, "stop", now.Add(-time.Hour))
+	require.NoError(t, f.Finish(ran.ID, now, nil))
+
+	var ids []int
+	for _, a := range f.Scheduled() {
+		ids = append(ids, a.ID)
+	}
+	assert.Equal(t, []int{sooner.ID, later.ID, ran.ID}, ids, "pending soonest first, then finished")
+
+	next, ok := f.Next()
+	require.True(t, ok)
+	assert.Equal(t, sooner.ID, next.ID)
+}
+
+func TestSchedule_UnknownAction(t *testing.T) {
+	f, _ := Open("")
+	_, err := f.Schedule(ScheduledAction{Action: "migrate", VMID: "100", At: now})
+	assert.EqualError(t, err, `cannot schedule "migrate": not one of shutdown, stop, reboot, start`)
+	assert.Empty(t, f.Scheduled())
+}
+
+func TestDue(t *testing.T) {
+	f, _ := Open("")
+	due := schedule(t, f, "shutdown", now)
+	schedule(t, f, "start", now.Add(time.Second))
+
+	got := f.Due(now)
+	require.Len(t, got, 1)
+	assert.Equal(t, due.ID, got[0].ID)
+
+	require.NoError(t, f.Finish(due.ID, now, errors.New("boom")))
+	assert.Empty(t, f.Due(now), "a finished action is not due again")
+	assert.Equal(t, "boom", f.Scheduled()[1].Error)
+	assert.Error(t, f.Finish(due.ID, now, nil), "an action finishes once")
+}
+
+func TestUnschedule(t *testing.T) {
+	f, _ := Open("")
+	a := schedule(t, f, "shutdown", now.Add(time.Hour))
+	require.NoError(t, f.Unschedule(a.ID))
+	assert.Empty(t, f.Scheduled())
+	assert.EqualError(t, f.Unschedule(a.ID), fmt.Sprintf("no pending scheduled action %d", a.ID))
+
+	ran := schedule(t, f, "stop", now)
+	require.NoError(t, f.Finish(ran.ID, now, nil))
+	assert.Error(t, f.Unschedule(ran.ID), "history can't be cancelled")
+}
+
+func TestFinish_KeepsRecentHistory(t *testing.T) {
+	f, _ := Open("")
+	for i := 0; i < MaxFinished+5; i++ {
+		a := schedule(t, f, "stop", now)
+		require.NoError(t, f.Finish(a.ID, now.Add(time.Duration(i)*time.Minute), nil))
+	}
+	pending := schedule(t, f, "start", now.Add(time.Hour))
+
+	all := f.Scheduled()
+	require.Len(t, all, MaxFinished+1)
+	assert.Equal(t, pending.ID, all[0].ID)
+	assert.Equal(t, MaxFinished+5, all[1].ID, "the newest finished action is kept")
+	assert.Equal(t, 6, all[MaxFinished].ID, "the oldest ones are dropped")
+}
+
+func TestRearm(t *testing.T) {
+	f, _ := Open("")
+	overdue := schedule(t, f, "shutdown", now.Add(-time.Hour))
+	schedule(t, f, "start", now.Add(time.Hour))
+
+	late := f.Rearm(now)
+	require.Len(t, late, 1)
+	assert.Equal(t, overdue.ID, late[0].ID)
+	assert.True(t, late[0].Late)
+	assert.Len(t, f.Due(now), 1, "a late action still runs")
+}
+
+func TestParseWhen(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"in 2h", now.Add(2 * time.Hour)},
+		{"In 1h 30m", now.Add(90 * time.Minute)},
+		{"45m", now.Add(45 * time.Minute)},
+		{"22:00", time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC)},
+		{"06:15", time.Date(2026, 10, 18, 6, 15, 0, 0, time.UTC)},
+		{"18:30", time.Date(2026, 10, 18, 18, 30, 0, 0, time.UTC)},
+		{"2026-10-20 06:00", time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC)},
+		{" 2026-10-20T06:00 ", time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseWhen(tt.in, now)
+		if assert.NoError(t, err, tt.in) {
+			assert.Equal(t, tt.want, got, tt.in)
+		}
+	}
+}
+
+func TestParseWhen_Errors(t *testing.T) {
+	tests := map[string]string{
+		"":                 `cannot read "" as a time`,
+		"tonight":          `cannot read "tonight" as a time`,
+		"in a while":       `cannot read "in a while" as a delay`,
+		"-5m":              `"-5m" is not a delay in the future`,
+		"25:00":            `cannot read "25:00" as a time`,
+		"2026-10-17 06:00": "2026-10-17 06:00 is in the past",
+	}
+	for in, want := range tests {
+		_, err := ParseWhen(in, now)
+		assert.ErrorContains(t, err, want, in)
+	}
+}
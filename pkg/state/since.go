@@ -0,0 +1,104 @@
+package state
+
+import (
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// sinceTolerance is how far the start of a running guest, worked out from
+// its uptime, may move between refreshes before it counts as a restart:
+// uptimes are read at slightly different times
+const sinceTolerance = time.Minute
+
+// seenResolution is how stale the last sighting of an unchanged guest may
+// grow before it is saved again, so a refresh doesn't write the file
+const seenResolution = time.Hour
+
+// MaxObservationAge is how long a guest pvec no longer sees keeps its
+// observation, before it is taken for deleted
+const MaxObservationAge = 30 * 24 * time.Hour
+
+// Observation is the state a guest was last seen in, and since when
+type Observation struct {
+	State models.NodeState `json:"state"`
+	// Since is when the guest entered the state: for a running guest,
+	// when its uptime says it started; otherwise, when pvec first saw it
+	// in that state
+	Since time.Time `json:"since"`
+	Seen  time.Time `json:"seen"` // Last time the guest was seen in a known state
+}
+
+// Reconcile returns the observation of a guest once seen as g at now,
+// given the previous one if known, and whether it changed enough to be
+// saved. A running guest's uptime always wins over what was observed:
+// one up for 5 minutes that was seen running for days restarted in
+// between. A guest whose state isn't known, as on an offline node or an
+// unreachable cluster, teaches nothing and leaves the observation alone.
+func Reconcile(prev Observation, known bool, g *models.VMStatus, now time.Time) (Observation, bool) {
+	if g.NodeOffline || g.Unreachable || g.Status == models.StateUnknown {
+		return prev, false
+	}
+	next := Observation{State: g.Status, Since: now, Seen: now}
+	same := known && prev.State == g.Status
+	switch {
+	case g.IsRunning() && g.Uptime > 0 && g.IsKnown(models.MetricUptime):
+		next.Since = now.Add(-time.Duration(g.Uptime) * time.Second)
+		if same && absDuration(next.Since.Sub(prev.Since)) < sinceTolerance {
+			next.Since = prev.Since
+		}
+	case same:
+		// Without an uptime, the state was entered when first seen in it
+		next.Since = prev.Since
+	}
+	if same && next.Since.Equal(prev.Since) && now.Sub(prev.Seen) < seenResolution {
+		return prev, false
+	}
+	return next, true
+}
+
+// absDuration returns the magnitude of d
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Observe reconciles the observations with the guests of a refresh and
+// saves them when any changed, then returns those of the guests, by
+// guest key. Observations of guests not seen for MaxObservationAge are
+// dropped. The observations are kept for this session even when saving
+// fails.
+func (f *File) Observe(guests []*models.VMStatus, now time.Time) (map[string]Observation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.data.Since == nil {
+		f.data.Since = make(map[string]Observation)
+	}
+
+	changed := false
+	result := make(map[string]Observation, len(guests))
+	for _, g := range guests {
+		key := g.Key()
+		prev, known := f.data.Since[key]
+		next, updated := Reconcile(prev, known, g, now)
+		if updated {
+			f.data.Since[key] = next
+			changed = true
+		}
+		if known || updated {
+			result[key] = next
+		}
+	}
+	for key, o := range f.data.Since {
+		if now.Sub(o.Seen) > MaxObservationAge {
+			delete(f.data.Since, key)
+			changed = true
+		}
+	}
+	if !changed {
+		return result, nil
+	}
+	return result, f.save()
+}
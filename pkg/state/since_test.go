@@ -0,0 +1,118 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+var sinceNow = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+func running(uptime int64) *models.VMStatus {
+	return &models.VMStatus{VMID: "100", Status: models.StateRunning, Uptime: uptime}
+}
+
+func TestReconcile_RunningFollowsUptime(t *testing.T) {
+	o, changed := Reconcile(Observation{}, false, running(300), sinceNow)
+	assert.True(t, changed)
+	assert.Equal(t, Observation{State: models.StateRunning, Since: sinceNow.Add(-5 * time.Minute), Seen: sinceNow}, o)
+}
+
+func TestReconcile_RestartSeenInUptime(t *testing.T) {
+	// Seen running for two days, but up for 5 minutes: it restarted
+	prev := Observation{State: models.StateRunning, Since: sinceNow.Add(-48 * time.Hour), Seen: sinceNow.Add(-time.Minute)}
+	o, changed := Reconcile(prev, true, running(300), sinceNow)
+	assert.True(t, changed)
+	assert.Equal(t, sinceNow.Add(-5*time.Minute), o.Since)
+}
+
+func TestReconcile_UptimeJitterKeepsSince(t *testing.T) {
+	prev := Observation{State: models.StateRunning, Since: sinceNow.Add(-time.Hour), Seen: sinceNow.Add(-5 * time.Second)}
+	o, changed := Reconcile(prev, true, running(3600-20), sinceNow)
+	assert.False(t, changed, "Uptimes read a few seconds apart are the same start")
+	assert.Equal(t, prev, o)
+}
+
+func TestReconcile_UptimeWinsOverStoppedObservation(t *testing.T) {
+	// Marked stopped an hour ago, yet up for three days: the old
+	// observation was wrong, as from a stale list
+	prev := Observation{State: models.StateStopped, Since: sinceNow.Add(-time.Hour), Seen: sinceNow.Add(-time.Hour)}
+	o, _ := Reconcile(prev, true, running(3*86400), sinceNow)
+	assert.Equal(t, models.StateRunning, o.State)
+	assert.Equal(t, sinceNow.Add(-72*time.Hour), o.Since)
+}
+
+func TestReconcile_StoppedSinceFirstSeen(t *testing.T) {
+	stopped := &models.VMStatus{VMID: "100", Status: models.StateStopped}
+	prev := Observation{State: models.StateRunning, Since: sinceNow.Add(-48 * time.Hour), Seen: sinceNow.Add(-5 * time.Second)}
+	o, changed := Reconcile(prev, true, stopped, sinceNow)
+	assert.True(t, changed)
+	assert.Equal(t, Observation{State: models.StateStopped, Since: sinceNow, Seen: sinceNow}, o)
+
+	// Still stopped three days later: since stays, only the sighting moves
+	later := sinceNow.Add(72 * time.Hour)
+	o, changed = Reconcile(o, true, stopped, later)
+	assert.True(t, changed, "The sighting is saved once it is an hour old")
+	assert.Equal(t, sinceNow, o.Since)
+	assert.Equal(t, later, o.Seen)
+
+	o2, changed := Reconcile(o, true, stopped, later.Add(time.Minute))
+	assert.False(t, changed)
+	assert.Equal(t, o, o2)
+}
+
+func TestReconcile_RunningWithoutUptime(t *testing.T) {
+	g := &models.VMStatus{VMID: "100", Status: models.StateRunning, Missing: models.MetricUptime}
+	prev := Observation{State: models.StateRunning, Since: sinceNow.Add(-time.Hour), Seen: sinceNow.Add(-time.Minute)}
+	o, _ := Reconcile(prev, true, g, sinceNow)
+	assert.Equal(t, prev.Since, o.Since, "Without an uptime the observation stands")
+
+	o, _ = Reconcile(Observation{}, false, g, sinceNow)
+	assert.Equal(t, sinceNow, o.Since)
+}
+
+func TestReconcile_UnknownTeachesNothing(t *testing.T) {
+	prev := Observation{State: models.StateStopped, Since: sinceNow.Add(-time.Hour), Seen: sinceNow.Add(-2 * time.Hour)}
+	for _, g := range []*models.VMStatus{
+		{VMID: "100", Status: models.StateUnknown},
+		{VMID: "100", Status: models.StateRunning, Uptime: 60, NodeOffline: true},
+		{VMID: "100", Status: models.StateRunning, Uptime: 60, Unreachable: true},
+	} {
+		o, changed := Reconcile(prev, true, g, sinceNow)
+		assert.False(t, changed)
+		assert.Equal(t, prev, o)
+	}
+}
+
+func TestObserve_SavesAndPrunes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	f, err := Open(path)
+	require.NoError(t, err)
+
+	guests := []*models.VMStatus{
+		running(600),
+		{VMID: "101", Status: models.StateStopped},
+		{VMID: "102", Status: models.StateUnknown},
+	}
+	got, err := f.Observe(guests, sinceNow)
+	require.NoError(t, err)
+	assert.Len(t, got, 2, "A guest never seen in a known state has no observation")
+	assert.Equal(t, sinceNow, got["101"].Since)
+
+	// A restart of pvec keeps how long 101 has been down
+	reopened, err := Open(path)
+	require.NoError(t, err)
+	got, err = reopened.Observe(guests[1:2], sinceNow.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, sinceNow, got["101"].Since)
+
+	// 100, gone for longer than MaxObservationAge, is forgotten
+	_, err = reopened.Observe(guests[1:2], sinceNow.Add(MaxObservationAge+time.Hour))
+	require.NoError(t, err)
+	assert.NotContains(t, reopened.data.Since, "100")
+	assert.Contains(t, reopened.data.Since, "101")
+}
@@ -0,0 +1,97 @@
+// Package state keeps what pvec remembers between runs, such as the
+// scheduled actions and since when each guest is in its state, in a JSON
+// file under the user's cache directory.
+// Unlike the config it is only written by pvec.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Version is the format of the state file. A file written by a newer
+// pvec is still read; fields it doesn't know are dropped on save.
+const Version = 1
+
+// FileName is the name of the state file in the pvec cache directory
+const FileName = "state.json"
+
+// DefaultPath returns the state file in the user cache directory, e.g.
+// ~/.cache/pvec/state.json
+func DefaultPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "pvec", FileName), nil
+}
+
+// contents is the state file as written
+type contents struct {
+	Version   int               `json:"version"`
+	NextID    int               `json:"next_id,omitempty"`
+	Scheduled []ScheduledAction `json:"scheduled,omitempty"`
+	// Since holds the state each guest was last seen in, by guest key
+	Since map[string]Observation `json:"since,omitempty"`
+}
+
+// File is the state of pvec, saved to its path on every change. It is
+// safe for concurrent use.
+type File struct {
+	path string // "" keeps the state in memory only
+	mu   sync.Mutex
+	data contents
+}
+
+// Open reads the state file at path. A missing file gives an empty
+// state; path "" gives one that is never saved, as in the demo.
+func Open(path string) (*File, error) {
+	f := &File{path: path, data: contents{Version: Version}}
+	if path == "" {
+		return f, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &f.data); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	f.data.Version = Version
+	return f, nil
+}
+
+// Path returns where the state is saved, "" when it isn't
+func (f *File) Path() string {
+	return f.path
+}
+
+// save writes the state through a temporary file, so a crash never
+// leaves half of it. Must be called with mu held.
+func (f *File) save() error {
+	if f.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(f.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
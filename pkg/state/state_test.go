@@ -0,0 +1,72 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen_Missing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pvec", FileName)
+	f, err := Open(path)
+	require.NoError(t, err)
+	assert.Empty(t, f.Scheduled())
+	assert.Equal(t, path, f.Path())
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "opening must not create the file")
+}
+
+func TestOpen_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pvec", FileName)
+	f, err := Open(path)
+	require.NoError(t, err)
+
+	at := time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC)
+	_, err = f.Schedule(ScheduledAction{Action: "shutdown", VMID: "100", Name: "web", Node: "pve1", Type: "qemu", At: at})
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the state is private")
+
+	reopened, err := Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, f.Scheduled(), reopened.Scheduled())
+
+	// IDs keep counting across runs
+	second, err := reopened.Schedule(ScheduledAction{Action: "start", VMID: "100", At: at})
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.ID)
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	_, err := Open(path)
+	assert.ErrorContains(t, err, "failed to parse state file")
+}
+
+func TestOpen_InMemory(t *testing.T) {
+	f, err := Open("")
+	require.NoError(t, err)
+	_, err = f.Schedule(ScheduledAction{Action: "stop", VMID: "100", At: time.Now()})
+	assert.NoError(t, err)
+	assert.Len(t, f.Scheduled(), 1)
+}
+
+func TestSave_Unwritable(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "pvec")
+	f, err := Open(filepath.Join(blocker, FileName))
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(blocker, nil, 0o600))
+	_, err = f.Schedule(ScheduledAction{Action: "stop", VMID: "100", At: time.Now()})
+	assert.ErrorContains(t, err, "failed to create state directory")
+	assert.Len(t, f.Scheduled(), 1, "still scheduled for this session")
+}
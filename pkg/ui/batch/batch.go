@@ -0,0 +1,338 @@
+// Package batch is the screen that changes every guest listed at once:
+// it edits their tags or moves them to a resource pool, after a preview
+// of what would change, and sums up how it went.
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Kind is the change made to the guests
+type Kind int
+
+const (
+	Tags Kind = iota // Edit their tags
+	Pool             // Move them to a resource pool
+)
+
+// Phase is where the screen stands
+type Phase int
+
+const (
+	Prompt  Phase = iota // Typing the tags or the pool
+	Loading              // Reading the guests' tags for the preview
+	Preview              // Waiting for y to apply the preview
+	Running              // Applying the change, a guest at a time for tags
+	Done                 // Results hold the outcome
+)
+
+// Previewed means what was typed must be previewed
+const Previewed = screen.Custom
+
+// tagPattern matches a tag Proxmox accepts
+var tagPattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_+.-]*$`)
+
+// poolPattern matches a pool ID Proxmox accepts
+var poolPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// State is the screen of one batch change
+type State struct {
+	Kind    Kind
+	Guests  []*models.VMStatus // Guests listed when the screen was opened
+	Mode    actions.TagMode    // How the tags typed merge with the guests', switched with Tab
+	Typed   string
+	Err     error // Why what was typed can't be used, or the preview failed
+	Phase   Phase
+	Results []actions.BatchResult // The preview, then the outcome
+	Queue   []int                 // Results still to apply while running
+	Applied int                   // Results applied while running
+	Offset  int                   // First result shown
+}
+
+// New opens the screen for guests
+func New(kind Kind, guests []*models.VMStatus) State {
+	return State{Kind: kind, Guests: guests}
+}
+
+// TagEdit returns the edit the tags typed ask for
+func (s State) TagEdit() actions.TagEdit {
+	return actions.TagEdit{Mode: s.Mode, Tags: splitTags(s.Typed)}
+}
+
+// PoolName returns the pool typed
+func (s State) PoolName() string {
+	return strings.TrimSpace(s.Typed)
+}
+
+// splitTags splits the tags typed, separated as Proxmox allows
+func splitTags(typed string) []string {
+	return strings.FieldsFunc(typed, func(r rune) bool {
+		return r == ';' || r == ',' || r == ' '
+	})
+}
+
+// validate checks what was typed before it is previewed
+func (s State) validate() error {
+	if s.Kind == Pool {
+		if !poolPattern.MatchString(s.PoolName()) {
+			return fmt.Errorf("type a pool name: letters, digits, - and _")
+		}
+		return nil
+	}
+	tags := splitTags(s.Typed)
+	if len(tags) == 0 && s.Mode != actions.TagReplace {
+		return fmt.Errorf("type at least one tag")
+	}
+	for _, tag := range tags {
+		if !tagPattern.MatchString(tag) {
+			return fmt.Errorf("%q is not a valid tag: letters, digits, _, -, + and .", tag)
+		}
+	}
+	return nil
+}
+
+// HandleKey updates the screen for a key press; rows is the number of
+// results the screen shows at once
+func (s *State) HandleKey(msg tea.KeyMsg, rows int) screen.Outcome {
+	switch s.Phase {
+	case Prompt:
+		return s.handlePromptKey(msg)
+	case Loading:
+		if msg.String() == "esc" {
+			return screen.Closed
+		}
+		return screen.Pending
+	case Running:
+		if msg.String() == "esc" && s.Kind == Tags {
+			s.abort()
+		}
+		return screen.Pending
+	case Done:
+		if s.scroll(msg.String(), rows) {
+			return screen.Pending
+		}
+		return screen.Closed
+	}
+
+	// Preview
+	switch msg.String() {
+	case "y", "Y":
+		if s.Changes() == 0 {
+			return screen.Pending
+		}
+		s.Phase, s.Queue = Running, nil
+		for i, r := range s.Results {
+			if r.Err == nil && !r.Unchanged {
+				s.Queue = append(s.Queue, i)
+			}
+		}
+		return screen.Confirmed
+	case "n", "N", "esc":
+		s.Phase, s.Results, s.Offset = Prompt, nil, 0
+	default:
+		s.scroll(msg.String(), rows)
+	}
+	return screen.Pending
+}
+
+// handlePromptKey edits what is typed; Tab switches how tags merge
+func (s *State) handlePromptKey(msg tea.KeyMsg) screen.Outcome {
+	switch msg.Type {
+	case tea.KeyEsc:
+		return screen.Closed
+	case tea.KeyEnter:
+		if s.Err = s.validate(); s.Err != nil {
+			return screen.Pending
+		}
+		s.Phase = Loading
+		return Previewed
+	case tea.KeyTab:
+		if s.Kind == Tags {
+			s.Mode = (s.Mode + 1) % 3
+		}
+	case tea.KeyShiftTab:
+		if s.Kind == Tags {
+			s.Mode = (s.Mode + 2) % 3
+		}
+	case tea.KeyBackspace:
+		if s.Typed != "" {
+			runes := []rune(s.Typed)
+			s.Typed = string(runes[:len(runes)-1])
+		}
+	case tea.KeySpace:
+		s.Typed += " "
+	case tea.KeyRunes:
+		s.Typed += string(msg.Runes)
+	}
+	s.Err = nil
+	return screen.Pending
+}
+
+// scroll moves through the results, reporting whether key did
+func (s *State) scroll(key string, rows int) bool {
+	switch key {
+	case "up", "k":
+		s.Offset--
+	case "down", "j":
+		s.Offset++
+	case "pgup":
+		s.Offset -= rows
+	case "pgdown":
+		s.Offset += rows
+	default:
+		return false
+	}
+	s.Offset = format.ClampOffset(s.Offset, len(s.Results), rows)
+	return true
+}
+
+// ShowPreview shows what applying would change, or why it can't be told
+func (s *State) ShowPreview(results []actions.BatchResult, err error) {
+	if err != nil {
+		s.Phase, s.Err = Prompt, err
+		return
+	}
+	s.Phase, s.Results, s.Offset = Preview, results, 0
+}
+
+// errAborted is the outcome of the guests left when a change is aborted
+var errAborted = errors.New("aborted before it was applied")
+
+// abort drops the guests still queued but the one being applied, so the
+// change ends with it
+func (s *State) abort() {
+	if len(s.Queue) < 2 {
+		return
+	}
+	for _, i := range s.Queue[1:] {
+		s.Results[i].Err = errAborted
+	}
+	s.Queue = s.Queue[:1]
+}
+
+// Record keeps the outcome of the result i, applied, and ends the change
+// once none is left to apply
+func (s *State) Record(i int, result actions.BatchResult) {
+	s.Results[i] = result
+	s.Queue = slices.DeleteFunc(s.Queue, func(j int) bool { return j == i })
+	s.Applied++
+	if len(s.Queue) == 0 {
+		s.Phase = Done
+	}
+}
+
+// Changes returns how many guests the preview changes
+func (s State) Changes() int {
+	n := 0
+	for _, r := range s.Results {
+		if r.Err == nil && !r.Unchanged {
+			n++
+		}
+	}
+	return n
+}
+
+// modeText explains how the tags typed merge with the guests'
+func modeText(mode actions.TagMode) string {
+	switch mode {
+	case actions.TagRemove:
+		return "remove them, keeping the other tags"
+	case actions.TagReplace:
+		return "replace every tag with them; none clears the tags"
+	}
+	return "add them, keeping the other tags"
+}
+
+// tagsText renders the tags or pool of a result, - for none
+func tagsText(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ";")
+}
+
+// resultRow renders what a change does, or did, to a guest
+func resultRow(r actions.BatchResult, width int) string {
+	guest := format.Pad(format.Truncate(r.Guest.Key()+" "+r.Guest.Name, 24), 24)
+	var change string
+	switch {
+	case r.Err != nil:
+		change = "failed: " + redact.Error(r.Err).Error()
+	case r.Unchanged:
+		change = tagsText(r.Before) + " (unchanged)"
+	default:
+		change = tagsText(r.Before) + format.Text(" → ") + tagsText(r.After)
+	}
+	return format.Truncate("  "+guest+" "+change, width)
+}
+
+// GetText renders the screen
+func GetText(s State, width, height int) string {
+	what, title := "Tags", "Edit Tags"
+	if s.Kind == Pool {
+		what, title = "Pool", "Move to Pool"
+	}
+	title = fmt.Sprintf("%s - %d listed guests", title, len(s.Guests))
+
+	if s.Phase == Prompt {
+		body := []string{""}
+		if s.Kind == Tags {
+			var choices []string
+			for _, mode := range []actions.TagMode{actions.TagAdd, actions.TagRemove, actions.TagReplace} {
+				label := strings.ToUpper(mode.String()[:1]) + mode.String()[1:]
+				if mode == s.Mode {
+					choices = append(choices, "["+label+"]")
+				} else {
+					choices = append(choices, " "+label+" ")
+				}
+			}
+			body = append(body, "  Mode:  "+strings.Join(choices, " "), "         "+modeText(s.Mode), "")
+		}
+		body = append(body, fmt.Sprintf("  %s:  %s_", what, s.Typed))
+		if s.Err != nil {
+			body = append(body, "         "+redact.Error(s.Err).Error())
+		}
+		body = append(body, "", "  Applies to every guest listed; filter the list first to narrow it")
+		status := "Enter: Preview | ESC: Cancel"
+		if s.Kind == Tags {
+			status = "Tab: Switch mode | " + status
+		}
+		return format.Frame(title, body, format.Text(status), width, height)
+	}
+
+	var rows []string
+	for _, r := range s.Results {
+		rows = append(rows, resultRow(r, width))
+	}
+	var status string
+	switch s.Phase {
+	case Loading:
+		rows = []string{"  Reading the " + strings.ToLower(what) + "s of the guests..."}
+		status = "Reading..."
+	case Preview:
+		status = fmt.Sprintf("Change %d of %d guests? (y/n)", s.Changes(), len(s.Results))
+		if s.Changes() == 0 {
+			status = "Nothing to change - n to go back"
+		}
+	case Running:
+		status = fmt.Sprintf("Applying... %d/%d", s.Applied, s.Applied+len(s.Queue))
+		if s.Kind == Tags {
+			status += " - ESC to abort"
+		}
+	case Done:
+		status = actions.SummarizeBatch(s.Results) + " - Press any key"
+	}
+	offset := format.ClampOffset(s.Offset, len(rows), format.FrameRows(height))
+	return format.FrameAt(title, rows, format.Text(status), width, height, offset)
+}
@@ -0,0 +1,207 @@
+package batch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+func sampleGuests() []*models.VMStatus {
+	return []*models.VMStatus{
+		{VMID: "100", Name: "web-1", Type: "qemu"},
+		{VMID: "101", Name: "web-2", Type: "qemu"},
+		{VMID: "200", Name: "cache", Type: "lxc"},
+	}
+}
+
+// typeText sends each rune of text as a key press
+func typeText(s *State, text string) {
+	for _, r := range text {
+		s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}, 10)
+	}
+}
+
+func TestHandleKey_Prompt(t *testing.T) {
+	s := New(Tags, sampleGuests())
+	if s.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, 10) != screen.Pending || s.Err == nil {
+		t.Error("Expected Enter without tags to be refused")
+	}
+	typeText(&s, "prod;bad tag!")
+	if s.Err != nil {
+		t.Errorf("Expected typing to clear the error, got %v", s.Err)
+	}
+	if s.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, 10) != screen.Pending || s.Err == nil || !strings.Contains(s.Err.Error(), `"tag!"`) {
+		t.Errorf("Expected the invalid tag to be named, got %v", s.Err)
+	}
+	for range len("bad tag!") {
+		s.HandleKey(tea.KeyMsg{Type: tea.KeyBackspace}, 10)
+	}
+	typeText(&s, "web")
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyTab}, 10)
+	if s.Mode != actions.TagRemove {
+		t.Errorf("Expected Tab to switch to remove, got %v", s.Mode)
+	}
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyShiftTab}, 10)
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyShiftTab}, 10)
+	if s.Mode != actions.TagReplace {
+		t.Errorf("Expected Shift+Tab to switch back to replace, got %v", s.Mode)
+	}
+	if s.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, 10) != Previewed || s.Phase != Loading {
+		t.Errorf("Expected Enter to ask for the preview, got phase %v", s.Phase)
+	}
+	edit := s.TagEdit()
+	if edit.Mode != actions.TagReplace || strings.Join(edit.Tags, ",") != "prod,web" {
+		t.Errorf("Expected the edit to replace with prod and web, got %+v", edit)
+	}
+	if s.HandleKey(tea.KeyMsg{Type: tea.KeyEsc}, 10) != screen.Closed {
+		t.Error("Expected ESC to close the screen while reading the tags")
+	}
+}
+
+func TestHandleKey_ReplaceWithNone(t *testing.T) {
+	s := New(Tags, sampleGuests())
+	s.Mode = actions.TagReplace
+	if s.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, 10) != Previewed {
+		t.Errorf("Expected replacing with no tags to clear them, got %v", s.Err)
+	}
+}
+
+func TestHandleKey_Pool(t *testing.T) {
+	s := New(Pool, sampleGuests())
+	typeText(&s, "lab pool")
+	if s.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, 10) != screen.Pending || s.Err == nil {
+		t.Error("Expected a pool name with a space to be refused")
+	}
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyTab}, 10)
+	if s.Mode != actions.TagAdd {
+		t.Errorf("Expected Tab to do nothing on a pool, got %v", s.Mode)
+	}
+	s = New(Pool, sampleGuests())
+	typeText(&s, "lab")
+	if s.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, 10) != Previewed || s.PoolName() != "lab" {
+		t.Errorf("Expected Enter to preview the move to lab, got %q", s.PoolName())
+	}
+}
+
+func TestPreviewAndApply(t *testing.T) {
+	guests := sampleGuests()
+	s := New(Tags, guests)
+	typeText(&s, "prod")
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, 10)
+	s.ShowPreview([]actions.BatchResult{
+		{Guest: guests[0], Before: []string{"web"}, After: []string{"web", "prod"}},
+		{Guest: guests[1], Before: []string{"prod"}, After: []string{"prod"}, Unchanged: true},
+		{Guest: guests[2], Err: errors.New("status 403")},
+	}, nil)
+	if s.Phase != Preview || s.Changes() != 1 {
+		t.Fatalf("Expected a preview changing one guest, got phase %v and %d", s.Phase, s.Changes())
+	}
+
+	if s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}}, 10) != screen.Pending || s.Phase != Prompt || s.Typed != "prod" {
+		t.Errorf("Expected n to go back to the prompt with the tags kept, got phase %v", s.Phase)
+	}
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, 10)
+	s.ShowPreview([]actions.BatchResult{
+		{Guest: guests[0], Before: []string{"web"}, After: []string{"web", "prod"}},
+		{Guest: guests[1], After: []string{"prod"}},
+		{Guest: guests[2], Err: errors.New("status 403")},
+	}, nil)
+	if s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}}, 10) != screen.Confirmed || s.Phase != Running {
+		t.Fatalf("Expected y to apply the preview, got phase %v", s.Phase)
+	}
+	if len(s.Queue) != 2 || s.Queue[0] != 0 || s.Queue[1] != 1 {
+		t.Errorf("Expected the two guests changed to be queued, got %v", s.Queue)
+	}
+
+	s.Record(0, actions.BatchResult{Guest: guests[0], Before: []string{"web"}, After: []string{"web", "prod"}})
+	if s.Phase != Running || s.Applied != 1 {
+		t.Errorf("Expected the change to go on, got phase %v", s.Phase)
+	}
+	s.Record(1, actions.BatchResult{Guest: guests[1], Err: errors.New("status 500")})
+	if s.Phase != Done {
+		t.Errorf("Expected the change to end with the queue, got phase %v", s.Phase)
+	}
+	if s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}}, 10) != screen.Closed {
+		t.Error("Expected any key to close the summary")
+	}
+}
+
+func TestHandleKey_Abort(t *testing.T) {
+	guests := sampleGuests()
+	s := New(Tags, guests)
+	s.Phase = Preview
+	s.Results = []actions.BatchResult{
+		{Guest: guests[0], After: []string{"prod"}},
+		{Guest: guests[1], After: []string{"prod"}},
+		{Guest: guests[2], After: []string{"prod"}},
+	}
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}}, 10)
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyEsc}, 10)
+	if len(s.Queue) != 1 || s.Results[2].Err == nil {
+		t.Fatalf("Expected ESC to keep only the guest being changed, got %v", s.Queue)
+	}
+	s.Record(0, actions.BatchResult{Guest: guests[0], After: []string{"prod"}})
+	if s.Phase != Done {
+		t.Errorf("Expected the change to end after the guest being changed, got phase %v", s.Phase)
+	}
+	if got := actions.SummarizeBatch(s.Results); !strings.HasPrefix(got, "1 changed, 2 failed") {
+		t.Errorf("Expected the guests left out to count as failed, got %q", got)
+	}
+}
+
+func TestGetText(t *testing.T) {
+	format.SetColor(false)
+	defer format.SetColor(true)
+
+	guests := sampleGuests()
+	s := New(Tags, guests)
+	typeText(&s, "prod")
+	view := GetText(s, 80, 16)
+	for _, want := range []string{
+		"Edit Tags - 3 listed guests",
+		"Mode:  [Add]  Remove   Replace",
+		"add them, keeping the other tags",
+		"Tags:  prod_",
+		"filter the list first to narrow it",
+		"Tab: Switch mode | Enter: Preview | ESC: Cancel",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	s.Phase = Preview
+	s.Results = []actions.BatchResult{
+		{Guest: guests[0], Before: []string{"web"}, After: []string{"web", "prod"}},
+		{Guest: guests[1], Before: []string{"prod"}, After: []string{"prod"}, Unchanged: true},
+		{Guest: guests[2], Err: errors.New("status 403")},
+	}
+	view = GetText(s, 80, 16)
+	for _, want := range []string{
+		"100 web-1",
+		"web → web;prod",
+		"prod (unchanged)",
+		"failed: status 403",
+		"Change 1 of 3 guests? (y/n)",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	s.Phase = Done
+	if view = GetText(s, 80, 16); !strings.Contains(view, "1 changed, 1 unchanged, 1 failed: cache (200): status 403 - Press any key") {
+		t.Errorf("Expected the summary:\n%s", view)
+	}
+
+	p := New(Pool, guests)
+	if view = GetText(p, 80, 16); !strings.Contains(view, "Move to Pool - 3 listed guests") || strings.Contains(view, "Mode:") {
+		t.Errorf("Expected the pool prompt without a mode:\n%s", view)
+	}
+}
@@ -0,0 +1,108 @@
+// Package clonepresets is the screen picking one of the clone_presets of
+// the config, each showing the guest it clones and the name the clone
+// would take, so that a new guest is one key press away.
+package clonepresets
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Clone means the selected preset must be cloned
+const Clone = screen.Custom
+
+// Entry is a preset as listed
+type Entry struct {
+	Preset config.ClonePreset
+	Source string // Name of the guest cloned, "" when it isn't listed
+	Next   string // Name the clone would take
+}
+
+// State is the screen of presets
+type State struct {
+	Selected int
+}
+
+// HandleKey updates the screen for a key press; count is the number of
+// presets listed
+func (s *State) HandleKey(key string, count int) screen.Outcome {
+	switch key {
+	case "esc", "q", "+":
+		return screen.Closed
+	case "up", "k":
+		if s.Selected > 0 {
+			s.Selected--
+		}
+	case "down", "j":
+		if s.Selected < count-1 {
+			s.Selected++
+		}
+	case "home", "g":
+		s.Selected = 0
+	case "end", "G":
+		s.Selected = max(count-1, 0)
+	case "enter":
+		if s.Selected < count {
+			return Clone
+		}
+	}
+	return screen.Pending
+}
+
+// Clamp keeps the selection on one of count presets
+func (s *State) Clamp(count int) {
+	s.Selected = max(min(s.Selected, count-1), 0)
+}
+
+// modeText tells how the clone is made
+func modeText(p config.ClonePreset) string {
+	mode := "linked"
+	if p.Full {
+		mode = "full"
+	}
+	if p.TargetStorage != "" {
+		mode += " to " + p.TargetStorage
+	}
+	if p.Start {
+		mode += ", started"
+	}
+	return mode
+}
+
+// GetText renders the presets in the order of the config
+func GetText(s State, entries []Entry, width, height int) string {
+	var rows []string
+	if len(entries) == 0 {
+		rows = append(rows, "  No clone_presets configured")
+	} else {
+		rows = append(rows, fmt.Sprintf("  %-16s %-28s %-20s %s", "PRESET", "SOURCE", "NEXT NAME", "MODE"))
+	}
+	dimStyle := lipgloss.NewStyle().Faint(true)
+	for i, e := range entries {
+		marker := "  "
+		if i == s.Selected {
+			marker = "> "
+		}
+		source := fmt.Sprintf("%s (%d)", e.Source, e.Preset.SourceVMID)
+		if e.Source == "" {
+			source = fmt.Sprintf("%d not listed", e.Preset.SourceVMID)
+		}
+		row := format.Truncate(fmt.Sprintf("%s%s %s %s %s", marker, format.Pad(e.Preset.Name, 16),
+			format.Pad(source, 28), format.Pad(e.Next, 20), modeText(e.Preset)), width)
+		switch {
+		case i == s.Selected && format.Color():
+			row = lipgloss.NewStyle().Reverse(true).Render(format.Pad(row, width))
+		case e.Source == "" && format.Color():
+			row = dimStyle.Render(row)
+		}
+		rows = append(rows, row)
+	}
+
+	title := fmt.Sprintf("Clone presets (%d)", len(entries))
+	status := format.Text("↑↓=Select  Enter=Clone  ESC=Close")
+	return format.FrameAt(title, rows, status, width, height, format.OffsetFor(s.Selected+1, 0, format.FrameRows(height)))
+}
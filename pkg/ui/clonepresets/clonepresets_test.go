@@ -0,0 +1,74 @@
+package clonepresets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+func sampleEntries() []Entry {
+	return []Entry{
+		{Preset: config.ClonePreset{Name: "dev-web", SourceVMID: 9000, NamePattern: "dev-web-{n}", Start: true},
+			Source: "tpl-web", Next: "dev-web-3"},
+		{Preset: config.ClonePreset{Name: "ci", SourceVMID: 9001, NamePattern: "ci-{n:3}", Full: true, TargetStorage: "ceph"},
+			Next: "ci-001"},
+	}
+}
+
+func TestGetText(t *testing.T) {
+	format.SetColor(false)
+
+	view := GetText(State{}, sampleEntries(), 100, 10)
+	for _, want := range []string{
+		"Clone presets (2)",
+		"> dev-web          tpl-web (9000)               dev-web-3            linked, started",
+		"  ci               9001 not listed              ci-001               full to ceph",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	if got := GetText(State{}, nil, 80, 10); !strings.Contains(got, "No clone_presets configured") {
+		t.Errorf("Expected the empty screen:\n%s", got)
+	}
+}
+
+func TestHandleKey(t *testing.T) {
+	var s State
+	if s.HandleKey("down", 2) != screen.Pending || s.Selected != 1 {
+		t.Errorf("Expected down to select the second preset, got %d", s.Selected)
+	}
+	s.HandleKey("down", 2)
+	if s.Selected != 1 {
+		t.Errorf("Expected the selection to stop at the last preset, got %d", s.Selected)
+	}
+	if s.HandleKey("enter", 2) != Clone {
+		t.Error("Expected Enter to clone the selected preset")
+	}
+	s.HandleKey("home", 2)
+	if s.Selected != 0 {
+		t.Errorf("Expected home to select the first preset, got %d", s.Selected)
+	}
+	if s.HandleKey("esc", 2) != screen.Closed || s.HandleKey("+", 2) != screen.Closed {
+		t.Error("Expected ESC and + to close the screen")
+	}
+	if s.HandleKey("enter", 0) != screen.Pending {
+		t.Error("Expected Enter to do nothing without presets")
+	}
+}
+
+func TestClamp(t *testing.T) {
+	s := State{Selected: 5}
+	s.Clamp(2)
+	if s.Selected != 1 {
+		t.Errorf("Expected the last preset, got %d", s.Selected)
+	}
+	s.Clamp(0)
+	if s.Selected != 0 {
+		t.Errorf("Expected 0 without presets, got %d", s.Selected)
+	}
+}
@@ -2,66 +2,125 @@ package configpanel
 
 import (
 	"fmt"
-	"strings"
-	"time"
+	"strconv"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
 )
 
-// Model represents the config panel state
+// Model represents the config panel state. Focus moves through the
+// fields, then the Save and Cancel buttons.
 type Model struct {
 	cfg            *config.Config
-	loader         config.Loader
-	inputs         []textinput.Model
+	saver          config.Saver
+	fields         []Field
+	inputs         []textinput.Model // One per text, password or duration field
+	inputOf        []int             // Field index to inputs index, -1 if none
+	values         []string          // Current value of bool and select fields
+	origins        []string          // " (from file)" for fields set in a file Save doesn't write
 	focusedField   int
-	skipTLSVerify  bool
 	width          int
 	height         int
 	message        string
 	messageIsError bool
 }
 
-// NewModel creates a new config panel model
-func NewModel(cfg *config.Config, loader config.Loader) Model {
-	inputs := make([]textinput.Model, 4)
-
-	// API URL
-	inputs[0] = textinput.New()
-	inputs[0].Placeholder = "https://proxmox.example.com:8006"
-	inputs[0].SetValue(cfg.APIUrl)
-	inputs[0].Width = 50
-	inputs[0].Focus()
-
-	// Token ID
-	inputs[1] = textinput.New()
-	inputs[1].Placeholder = "user@realm!tokenid"
-	inputs[1].SetValue(cfg.TokenID)
-	inputs[1].Width = 50
-
-	// Token Secret
-	inputs[2] = textinput.New()
-	inputs[2].Placeholder = "secret-token-value"
-	inputs[2].SetValue(cfg.TokenSecret)
-	inputs[2].EchoMode = textinput.EchoPassword
-	inputs[2].EchoCharacter = '*'
-	inputs[2].Width = 50
-
-	// Refresh Interval
-	inputs[3] = textinput.New()
-	inputs[3].Placeholder = "5s"
-	inputs[3].SetValue(cfg.RefreshInterval.String())
-	inputs[3].Width = 20
-
-	return Model{
-		cfg:           cfg,
-		loader:        loader,
-		inputs:        inputs,
-		focusedField:  0,
-		skipTLSVerify: cfg.SkipTLSVerify,
+// NewModel creates a new config panel model with the registered fields
+func NewModel(cfg *config.Config, saver config.Saver) Model {
+	return newModel(cfg, saver, Fields())
+}
+
+// newModel creates a config panel editing the given fields of cfg
+func newModel(cfg *config.Config, saver config.Saver, fields []Field) Model {
+	m := Model{
+		cfg:     cfg,
+		saver:   saver,
+		fields:  fields,
+		inputOf: make([]int, len(fields)),
+		values:  make([]string, len(fields)),
+		origins: make([]string, len(fields)),
+	}
+	sources, _ := saver.(config.Sources)
+	for i, f := range fields {
+		m.inputOf[i] = -1
+		if sources != nil && f.Key != "" {
+			if source := sources.Source(f.Key); source != "" && source != sources.SavePath() {
+				m.origins[i] = " (from " + source + ")"
+			}
+		}
+		value := f.get(cfg)
+		if !f.isInput() {
+			if f.Kind == KindSelect && indexOf(f.Options, value) < 0 && len(f.Options) > 0 {
+				value = f.Options[0]
+			}
+			m.values[i] = value
+			continue
+		}
+
+		input := textinput.New()
+		input.Placeholder = f.Placeholder
+		input.SetValue(value)
+		input.Width = f.Width
+		if input.Width == 0 {
+			input.Width = defaultInputWidth
+		}
+		if f.Kind == KindPassword {
+			input.EchoMode = textinput.EchoPassword
+			input.EchoCharacter = '*'
+		}
+		m.inputOf[i] = len(m.inputs)
+		m.inputs = append(m.inputs, input)
+	}
+	m.focus()
+	return m
+}
+
+// saveButton and cancelButton are the focus positions of the buttons
+func (m Model) saveButton() int   { return len(m.fields) }
+func (m Model) cancelButton() int { return len(m.fields) + 1 }
+
+// focusedInput returns the inputs index of the focused field, or -1 if the
+// focus is on a checkbox, a select or a button
+func (m Model) focusedInput() int {
+	if m.focusedField < len(m.fields) {
+		return m.inputOf[m.focusedField]
+	}
+	return -1
+}
+
+// focus gives the focused field's text input the cursor
+func (m *Model) focus() {
+	if i := m.focusedInput(); i >= 0 {
+		m.inputs[i].Focus()
+	}
+}
+
+// blur removes the cursor from the focused field's text input
+func (m *Model) blur() {
+	if i := m.focusedInput(); i >= 0 {
+		m.inputs[i].Blur()
+	}
+}
+
+// value returns the current, unsaved text of field i
+func (m Model) value(i int) string {
+	if in := m.inputOf[i]; in >= 0 {
+		return m.inputs[in].Value()
+	}
+	return m.values[i]
+}
+
+// labelPadding returns the left padding for a text input label; without
+// color the focused field's label gets a ">" marker since the input cursor
+// would be invisible
+func (m Model) labelPadding(field int) string {
+	if !format.Color() && m.focusedField == field {
+		return "  > "
 	}
+	return "    "
 }
 
 // Init implements tea.Model
@@ -72,6 +131,7 @@ func (m Model) Init() tea.Cmd {
 // SaveResultMsg is sent when save completes
 type SaveResultMsg struct {
 	err error
+	cfg *config.Config
 }
 
 // Err returns the error from save operation
@@ -79,6 +139,12 @@ func (m SaveResultMsg) Err() error {
 	return m.err
 }
 
+// Config returns the saved configuration, nil when the save failed. The
+// panel's own config is left alone: the owner swaps it for this one.
+func (m SaveResultMsg) Config() *config.Config {
+	return m.cfg
+}
+
 // CloseMsg is sent when config panel wants to close
 type CloseMsg struct{}
 
@@ -98,9 +164,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	// Update the focused input field
-	if m.focusedField < 4 {
+	if i := m.focusedInput(); i >= 0 {
 		var cmd tea.Cmd
-		m.inputs[m.focusedField], cmd = m.inputs[m.focusedField].Update(msg)
+		m.inputs[i], cmd = m.inputs[i].Update(msg)
 		return m, cmd
 	}
 
@@ -110,7 +176,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 // handleSaveResult processes save operation results
 func (m Model) handleSaveResult(msg SaveResultMsg) (tea.Model, tea.Cmd) {
 	if msg.err != nil {
-		m.message = fmt.Sprintf("Error: %v", msg.err)
+		m.message = fmt.Sprintf("Error: %v", redact.Error(msg.err))
 		m.messageIsError = true
 		return m, nil
 	}
@@ -133,18 +199,28 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleNavigationPrev()
 	case "enter":
 		return m.handleAction()
+	case "left", "right":
+		if m.focusedField < len(m.fields) && m.fields[m.focusedField].Kind == KindSelect {
+			step := 1
+			if msg.String() == "left" {
+				step = -1
+			}
+			m.cycle(m.focusedField, step)
+			return m, nil
+		}
+		fallthrough
 	case " ":
-		// Space only for checkboxes and buttons
-		if m.focusedField >= 4 {
+		// Space only for checkboxes, selects and buttons
+		if m.focusedInput() < 0 && msg.String() == " " {
 			return m.handleAction()
 		}
 		// Otherwise let text input handle it
 		fallthrough
 	default:
 		// For text input fields, let the text input handle the key
-		if m.focusedField < 4 {
+		if i := m.focusedInput(); i >= 0 {
 			var cmd tea.Cmd
-			m.inputs[m.focusedField], cmd = m.inputs[m.focusedField].Update(msg)
+			m.inputs[i], cmd = m.inputs[i].Update(msg)
 			return m, cmd
 		}
 	}
@@ -153,200 +229,153 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 // handleNavigationNext moves focus to the next field
 func (m Model) handleNavigationNext() (tea.Model, tea.Cmd) {
-	if m.focusedField < 4 {
-		m.inputs[m.focusedField].Blur()
-	}
+	m.blur()
 	m.focusedField++
-	if m.focusedField > 6 {
+	if m.focusedField > m.cancelButton() {
 		m.focusedField = 0
 	}
-	if m.focusedField < 4 {
-		m.inputs[m.focusedField].Focus()
-	}
+	m.focus()
 	return m, nil
 }
 
 // handleNavigationPrev moves focus to the previous field
 func (m Model) handleNavigationPrev() (tea.Model, tea.Cmd) {
-	if m.focusedField < 4 {
-		m.inputs[m.focusedField].Blur()
-	}
+	m.blur()
 	m.focusedField--
 	if m.focusedField < 0 {
-		m.focusedField = 6
-	}
-	if m.focusedField < 4 {
-		m.inputs[m.focusedField].Focus()
+		m.focusedField = m.cancelButton()
 	}
+	m.focus()
 	return m, nil
 }
 
 // handleAction handles enter/space key on focused element
 func (m Model) handleAction() (tea.Model, tea.Cmd) {
 	switch m.focusedField {
-	case 4: // Skip TLS checkbox
-		m.skipTLSVerify = !m.skipTLSVerify
-		return m, nil
-	case 5: // Save button
+	case m.saveButton():
 		return m, m.save()
-	case 6: // Cancel button
+	case m.cancelButton():
 		return m, func() tea.Msg { return CloseMsg{} }
 	}
+	switch m.fields[m.focusedField].Kind {
+	case KindBool:
+		m.values[m.focusedField] = strconv.FormatBool(m.values[m.focusedField] != "true")
+	case KindSelect:
+		m.cycle(m.focusedField, 1)
+	}
 	return m, nil
 }
 
-// save validates and saves the configuration
+// cycle moves select field i by step options, wrapping around
+func (m *Model) cycle(i, step int) {
+	options := m.fields[i].Options
+	if len(options) == 0 {
+		return
+	}
+	next := (indexOf(options, m.values[i]) + step + len(options)) % len(options)
+	m.values[i] = options[next]
+}
+
+// save validates the fields and saves them in a copy of the config, as
+// the command runs on its own goroutine while the config may be read
 func (m *Model) save() tea.Cmd {
 	return func() tea.Msg {
-		// Validate and update config
-		m.cfg.APIUrl = m.inputs[0].Value()
-		m.cfg.TokenID = m.inputs[1].Value()
-		m.cfg.TokenSecret = m.inputs[2].Value()
-		m.cfg.SkipTLSVerify = m.skipTLSVerify
-
-		// Parse refresh interval
-		interval, err := time.ParseDuration(m.inputs[3].Value())
-		if err != nil {
-			return SaveResultMsg{err: fmt.Errorf("invalid refresh interval: %v", err)}
-		}
-		m.cfg.RefreshInterval = interval
-
-		// Validate required fields
-		if m.cfg.APIUrl == "" {
-			return SaveResultMsg{err: fmt.Errorf("api URL is required")}
-		}
-		if m.cfg.TokenID == "" {
-			return SaveResultMsg{err: fmt.Errorf("token ID is required")}
+		// Validate every field before touching the config
+		for i, f := range m.fields {
+			// With clusters, the top-level connection settings go unused
+			unused := len(m.cfg.Clusters) > 0 && m.value(i) == ""
+			if err := f.check(m.value(i)); err != nil && !unused {
+				return SaveResultMsg{err: err}
+			}
 		}
-		if m.cfg.TokenSecret == "" {
-			return SaveResultMsg{err: fmt.Errorf("token secret is required")}
+		cfg := *m.cfg
+		for i, f := range m.fields {
+			f.set(&cfg, m.value(i))
 		}
 
 		// Save configuration
-		if vl, ok := m.loader.(*config.ViperLoader); ok {
-			if err := vl.Save(m.cfg); err != nil {
+		if m.saver != nil {
+			if err := m.saver.Save(&cfg); err != nil {
 				return SaveResultMsg{err: fmt.Errorf("failed to save: %v", err)}
 			}
 		}
 
-		return SaveResultMsg{err: nil}
+		return SaveResultMsg{cfg: &cfg}
 	}
 }
 
 // View implements tea.Model
 func (m Model) View() string {
-	var b strings.Builder
-
-	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-	separatorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000"))
-	statusStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-
-	// Title line
-	title := "Configuration"
-	b.WriteString(titleStyle.Render(title))
-	b.WriteString("\n")
-
-	// Separator line
-	b.WriteString(separatorStyle.Render(strings.Repeat("─", m.width)))
-	b.WriteString("\n")
-
-	// Blank line after separator
-	b.WriteString("\n")
-
 	// Fixed left padding
 	padding := "    " // 4 spaces
 
-	// API URL field
-	b.WriteString(padding)
-	b.WriteString("API URL:")
-	b.WriteString("\n")
-	b.WriteString(padding)
-	b.WriteString(m.inputs[0].View())
-	b.WriteString("\n\n")
-
-	// Token ID field
-	b.WriteString(padding)
-	b.WriteString("Token ID:")
-	b.WriteString("\n")
-	b.WriteString(padding)
-	b.WriteString(m.inputs[1].View())
-	b.WriteString("\n\n")
-
-	// Token Secret field
-	b.WriteString(padding)
-	b.WriteString("Token Secret:")
-	b.WriteString("\n")
-	b.WriteString(padding)
-	b.WriteString(m.inputs[2].View())
-	b.WriteString("\n\n")
-
-	// Refresh Interval field
-	b.WriteString(padding)
-	b.WriteString("Refresh Interval:")
-	b.WriteString("\n")
-	b.WriteString(padding)
-	b.WriteString(m.inputs[3].View())
-	b.WriteString("\n\n")
-
-	// Skip TLS Verify checkbox
-	b.WriteString(padding)
-	checkbox := "[ ]"
-	if m.skipTLSVerify {
-		checkbox = "[X]"
-	}
-	if m.focusedField == 4 {
-		b.WriteString("> " + checkbox + " Skip TLS Verify")
-	} else {
-		b.WriteString("  " + checkbox + " Skip TLS Verify")
+	// A blank line under the separator, then the fields. focusTop and
+	// focusBottom bracket the focused field so it can be scrolled into view.
+	body := []string{""}
+	var focusTop, focusBottom int
+	for i, f := range m.fields {
+		if m.focusedField == i {
+			focusTop = len(body)
+		}
+		switch {
+		case f.isInput():
+			body = append(body,
+				m.labelPadding(i)+f.Label+m.origins[i]+":",
+				padding+m.inputs[m.inputOf[i]].View())
+		case f.Kind == KindBool:
+			checkbox := "[ ]"
+			if m.values[i] == "true" {
+				checkbox = "[X]"
+			}
+			body = append(body, padding+focusMarker(m.focusedField == i)+checkbox+" "+f.Label+m.origins[i])
+		case f.Kind == KindSelect:
+			body = append(body, padding+focusMarker(m.focusedField == i)+f.Label+m.origins[i]+": < "+m.values[i]+" >")
+		}
+		if m.focusedField == i {
+			focusBottom = len(body) - 1
+		}
+		body = append(body, "")
 	}
-	b.WriteString("\n\n")
 
 	// Buttons
-	b.WriteString(padding)
-	if m.focusedField == 5 {
-		b.WriteString("> [Save] ")
-	} else {
-		b.WriteString("  [Save] ")
+	if m.focusedField >= m.saveButton() {
+		focusTop, focusBottom = len(body), len(body)
 	}
-	if m.focusedField == 6 {
-		b.WriteString("> [Cancel]")
-	} else {
-		b.WriteString("  [Cancel]")
-	}
-	b.WriteString("\n")
-
-	// Count lines written so far: title + separator + blank + fields
-	// Title(1) + separator(1) + blank(1) +
-	// API label+input+blank(3) + Token label+input+blank(3) +
-	// Secret label+input+blank(3) + Interval label+input+blank(3) +
-	// checkbox+blank(2) + buttons(1) = 18 total
-	usedLines := 18
+	body = append(body, padding+focusMarker(m.focusedField == m.saveButton())+"[Save] "+
+		focusMarker(m.focusedField == m.cancelButton())+"[Cancel]")
 
 	// Add message line if present
 	if m.message != "" {
-		b.WriteString("\n")
-		b.WriteString(padding)
+		hint := " - Press ESC to close"
 		if m.messageIsError {
-			b.WriteString(m.message + " - Press ESC to continue")
-		} else {
-			b.WriteString(m.message + " - Press ESC to close")
+			hint = " - Press ESC to continue"
 		}
-		b.WriteString("\n")
-		usedLines += 2 // blank line(1) + message(1)
+		body = append(body, "", padding+m.message+hint)
+		focusBottom = len(body) - 1
 	}
 
-	// Fill space to push status bar to last line
-	// Total should be: usedLines + fillLines + statusBar(1) = height
-	// So fillLines = height - usedLines - 1
-	fillLines := m.height - usedLines - 1
-	if fillLines > 0 {
-		for i := 0; i < fillLines; i++ {
-			b.WriteString("\n")
-		}
-	}
+	// Scroll on short terminals so the focused field stays visible
+	rows := format.FrameRows(m.height)
+	offset := format.OffsetFor(focusTop, format.OffsetFor(focusBottom, 0, rows), rows)
+
+	status := format.Text("Tab/↑↓: Navigate | Enter/Space/←→: Select | ESC: Close")
+	return format.FrameAt("Configuration", body, status, m.width, m.height, offset)
+}
 
-	// Status bar on the last line (no trailing newline)
-	b.WriteString(statusStyle.Render("Tab/↑↓: Navigate | Enter/Space: Select | ESC: Close"))
+// focusMarker prefixes the focused checkbox or button
+func focusMarker(focused bool) string {
+	if focused {
+		return "> "
+	}
+	return "  "
+}
 
-	return b.String()
+// indexOf returns the position of s in list, or -1
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
 }
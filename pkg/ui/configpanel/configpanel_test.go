@@ -1,15 +1,19 @@
 package configpanel
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
 )
 
-// MockLoader implements config.Loader for testing
+// MockLoader implements config.Store for testing
 type MockLoader struct {
 	SaveError error
 }
@@ -43,13 +47,13 @@ func TestNewModel(t *testing.T) {
 	if model.cfg != cfg {
 		t.Error("Config not set correctly")
 	}
-	if model.loader != loader {
-		t.Error("Loader not set correctly")
+	if model.saver != loader {
+		t.Error("Saver not set correctly")
 	}
-	if len(model.inputs) != 4 {
-		t.Errorf("Expected 4 inputs, got %d", len(model.inputs))
+	if len(model.inputs) != 5 {
+		t.Errorf("Expected 5 inputs, got %d", len(model.inputs))
 	}
-	if !model.skipTLSVerify {
+	if model.value(4) != "true" {
 		t.Error("SkipTLSVerify should be true")
 	}
 	if model.focusedField != 0 {
@@ -80,10 +84,10 @@ func TestModel_NavigationKeys(t *testing.T) {
 		expectedFocus int
 	}{
 		{"Tab from first field", tea.KeyTab, 0, 1},
-		{"Tab from last field", tea.KeyTab, 6, 0},
-		{"Shift+Tab from first", tea.KeyShiftTab, 0, 6},
+		{"Tab from last field", tea.KeyTab, 7, 0},
+		{"Shift+Tab from first", tea.KeyShiftTab, 0, 7},
 		{"Down from first", tea.KeyDown, 0, 1},
-		{"Up from last", tea.KeyUp, 6, 5},
+		{"Up from last", tea.KeyUp, 7, 6},
 	}
 
 	for _, tt := range tests {
@@ -133,7 +137,7 @@ func TestModel_CheckboxToggle(t *testing.T) {
 	updatedModel, _ := model.Update(msg)
 	m := updatedModel.(Model)
 
-	if !m.skipTLSVerify {
+	if m.value(4) != "true" {
 		t.Error("Checkbox should be toggled to true")
 	}
 
@@ -141,7 +145,7 @@ func TestModel_CheckboxToggle(t *testing.T) {
 	updatedModel, _ = m.Update(msg)
 	m = updatedModel.(Model)
 
-	if m.skipTLSVerify {
+	if m.value(4) != "false" {
 		t.Error("Checkbox should be toggled back to false")
 	}
 }
@@ -150,7 +154,7 @@ func TestModel_CancelButton(t *testing.T) {
 	cfg := &config.Config{}
 	loader := &MockLoader{}
 	model := NewModel(cfg, loader)
-	model.focusedField = 6 // Cancel button
+	model.focusedField = 7 // Cancel button
 
 	msg := tea.KeyMsg{Type: tea.KeyEnter}
 	_, cmd := model.Update(msg)
@@ -203,6 +207,90 @@ func TestModel_View(t *testing.T) {
 	}
 }
 
+// layeredLoader reports settings read from other files than the one it saves to
+type layeredLoader struct {
+	MockLoader
+	sources map[string]string
+}
+
+func (l *layeredLoader) Source(key string) string { return l.sources[key] }
+func (l *layeredLoader) SavePath() string         { return "/home/alice/.pvecrc" }
+
+func TestModel_View_Sources(t *testing.T) {
+	cfg := &config.Config{APIUrl: "https://pve.example.com:8006", TokenID: "alice@pve!pvec", TokenSecret: "secret", RefreshInterval: 5 * time.Second}
+	loader := &layeredLoader{sources: map[string]string{
+		"api_url":         "/etc/pvec/config",
+		"skip_tls_verify": "/etc/pvec/config",
+		"token_id":        "/home/alice/.pvecrc",
+	}}
+	model := NewModel(cfg, loader)
+	model.width, model.height = 100, 40
+
+	view := model.View()
+	for _, want := range []string{"API URL (from /etc/pvec/config):", "Skip TLS Verify (from /etc/pvec/config)", "Token ID:"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+	if strings.Count(view, "(from") != 2 {
+		t.Errorf("Only settings from another file than the saved one should be marked:\n%s", view)
+	}
+}
+
+func TestModel_View_NoColorFocusMarker(t *testing.T) {
+	defer format.SetColor(true)
+	format.SetColor(false)
+
+	model := NewModel(&config.Config{APIUrl: "https://test.local:8006"}, &MockLoader{})
+	model.width = 80
+	model.height = 24
+
+	view := model.View()
+	if strings.Contains(view, "\x1b") {
+		t.Error("View should not contain ANSI escape sequences without color")
+	}
+	if !strings.Contains(view, "  > API URL:") {
+		t.Errorf("Focused input should be marked with '>':\n%s", view)
+	}
+	marked := 0
+	for _, line := range strings.Split(view, "\n") {
+		if strings.HasPrefix(line, "  > ") {
+			marked++
+		}
+	}
+	if marked != 1 {
+		t.Errorf("Only the focused input should be marked:\n%s", view)
+	}
+}
+
+func TestModel_View_ShortTerminalScrollsToFocus(t *testing.T) {
+	model := NewModel(&config.Config{APIUrl: "https://test.local:8006"}, &MockLoader{})
+	model.width = 80
+	model.height = 12
+
+	view := model.View()
+	if !strings.Contains(view, "API URL:") {
+		t.Errorf("First field should be visible initially:\n%s", view)
+	}
+
+	// Tab down to Cancel
+	var updated tea.Model = model
+	for i := 0; i < 7; i++ {
+		updated, _ = updated.Update(tea.KeyMsg{Type: tea.KeyTab})
+	}
+	view = updated.View()
+	lines := strings.Split(view, "\n")
+	if len(lines) != 12 {
+		t.Errorf("View should fill exactly 12 lines, got %d", len(lines))
+	}
+	if !strings.Contains(view, "> [Cancel]") {
+		t.Errorf("Focused Cancel button should be scrolled into view:\n%s", view)
+	}
+	if !strings.Contains(lines[len(lines)-1], "ESC: Close") {
+		t.Errorf("Status bar should stay on the last line:\n%s", view)
+	}
+}
+
 func TestModel_WindowResize(t *testing.T) {
 	cfg := &config.Config{}
 	loader := &MockLoader{}
@@ -243,3 +331,218 @@ func TestModel_InputValues(t *testing.T) {
 		t.Errorf("Refresh Interval input should be '5s', got '%s'", model.inputs[3].Value())
 	}
 }
+
+// tenFields returns the default fields plus four more, enough to overflow
+// a short terminal
+func tenFields() []Field {
+	fields := defaultFields()
+	fields = append(fields,
+		TextField("Hook Command", func(c *config.Config) *string { return &c.OnStateChangeCmd }),
+		BoolField("Use Unicode", func(c *config.Config) *bool { return &c.UseUnicode }),
+		BoolField("Color", func(c *config.Config) *bool { return &c.Color }),
+		SelectField("Theme", []string{"dark", "light"}, func(c *config.Config) *string { return new(string) }),
+	)
+	return fields
+}
+
+func TestModel_TenFieldsOnShortTerminal(t *testing.T) {
+	var updated tea.Model = newModel(&config.Config{RefreshInterval: 5 * time.Second}, &MockLoader{}, tenFields())
+	updated, _ = updated.Update(tea.WindowSizeMsg{Width: 80, Height: 15})
+
+	// Walk the whole form: every field and both buttons, then wrap around
+	for i := 0; i <= 12; i++ {
+		m := updated.(Model)
+		view := m.View()
+		lines := strings.Split(view, "\n")
+		if len(lines) != 15 {
+			t.Fatalf("focus %d: view should fill exactly 15 lines, got %d", m.focusedField, len(lines))
+		}
+		if !strings.Contains(lines[len(lines)-1], "ESC: Close") {
+			t.Errorf("focus %d: status bar should stay on the last line:\n%s", m.focusedField, view)
+		}
+
+		want := "[Cancel]"
+		switch {
+		case m.focusedField < len(m.fields):
+			want = m.fields[m.focusedField].Label
+		case m.focusedField == m.saveButton():
+			want = "[Save]"
+		}
+		if !strings.Contains(view, want) {
+			t.Errorf("focus %d: %q should be scrolled into view:\n%s", m.focusedField, want, view)
+		}
+
+		updated, _ = updated.Update(tea.KeyMsg{Type: tea.KeyTab})
+	}
+	if m := updated.(Model); m.focusedField != 1 {
+		t.Errorf("Focus should wrap around to the second field, got %d", m.focusedField)
+	}
+
+	view := updated.View()
+	if strings.Contains(view, "Timeout") {
+		t.Errorf("Last field should scroll out of view at the top of the form:\n%s", view)
+	}
+}
+
+func TestModel_SelectField(t *testing.T) {
+	theme := "light"
+	fields := []Field{SelectField("Theme", []string{"dark", "light", "auto"}, func(c *config.Config) *string { return &theme })}
+	model := newModel(&config.Config{}, &MockLoader{}, fields)
+
+	tests := []struct {
+		key      tea.KeyType
+		expected string
+	}{
+		{tea.KeyRight, "auto"},
+		{tea.KeyRight, "dark"},
+		{tea.KeyLeft, "auto"},
+		{tea.KeyEnter, "dark"},
+		{tea.KeySpace, "light"},
+	}
+	var updated tea.Model = model
+	for _, tt := range tests {
+		updated, _ = updated.Update(tea.KeyMsg{Type: tt.key})
+		if got := updated.(Model).value(0); got != tt.expected {
+			t.Errorf("After %v: expected %q, got %q", tt.key, tt.expected, got)
+		}
+	}
+	if !strings.Contains(updated.View(), "Theme: < light >") {
+		t.Errorf("View should show the selected option:\n%s", updated.View())
+	}
+}
+
+func TestModel_Save(t *testing.T) {
+	cfg := &config.Config{
+		APIUrl:          "https://test.local:8006",
+		TokenID:         "test@pam!token",
+		TokenSecret:     "secret",
+		RefreshInterval: 5 * time.Second,
+	}
+
+	t.Run("applies every field", func(t *testing.T) {
+		model := NewModel(cfg, &MockLoader{})
+		model.inputs[3].SetValue("30s")
+		model.values[4] = "true"
+		msg := model.save()().(SaveResultMsg)
+		if msg.Err() != nil {
+			t.Fatalf("Unexpected error: %v", msg.Err())
+		}
+		if saved := msg.Config(); saved.RefreshInterval != 30*time.Second || !saved.SkipTLSVerify {
+			t.Errorf("Config not updated: %+v", saved)
+		}
+		if cfg.RefreshInterval != 5*time.Second || cfg.SkipTLSVerify {
+			t.Errorf("The config being read should be left for the owner to swap: %+v", cfg)
+		}
+	})
+
+	errorTests := []struct {
+		name     string
+		input    int
+		value    string
+		expected string
+	}{
+		{"Missing API URL", 0, "", "api URL is required"},
+		{"Missing token ID", 1, "", "token ID is required"},
+		{"Missing token secret", 2, "", "token secret is required"},
+		{"Bad interval", 3, "soon", "invalid refresh interval"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := *cfg
+			model := NewModel(cfg, &MockLoader{})
+			model.inputs[tt.input].SetValue(tt.value)
+			msg := model.save()().(SaveResultMsg)
+			if msg.Err() == nil || !strings.Contains(msg.Err().Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, msg.Err())
+			}
+			if fmt.Sprint(*cfg) != fmt.Sprint(before) {
+				t.Error("Config should be left untouched when validation fails")
+			}
+		})
+	}
+}
+
+func TestModel_Save_Clusters(t *testing.T) {
+	cfg := &config.Config{
+		RefreshInterval: 5 * time.Second,
+		Clusters:        []config.ClusterConfig{{Name: "lab", APIUrl: "https://lab:8006", TokenID: "id", TokenSecret: "secret"}},
+	}
+
+	model := NewModel(cfg, &MockLoader{})
+	model.inputs[3].SetValue("10s")
+	msg := model.save()().(SaveResultMsg)
+	if msg.Err() != nil {
+		t.Fatalf("The top-level API URL and token aren't needed with clusters: %v", msg.Err())
+	}
+	if saved := msg.Config(); saved.RefreshInterval != 10*time.Second || len(saved.Clusters) != 1 {
+		t.Errorf("Config not updated: %+v", saved)
+	}
+}
+
+func TestModel_SaveError(t *testing.T) {
+	cfg := &config.Config{APIUrl: "https://test.local:8006", TokenID: "id", TokenSecret: "secret", RefreshInterval: time.Second}
+
+	model := NewModel(cfg, &MockLoader{SaveError: errors.New("disk full")})
+	msg := model.save()().(SaveResultMsg)
+	if msg.Err() == nil || !strings.Contains(msg.Err().Error(), "failed to save: disk full") {
+		t.Errorf("Saver error should be reported, got %v", msg.Err())
+	}
+
+	// Without a saver the panel only updates the config in memory
+	model = NewModel(cfg, nil)
+	if msg := model.save()().(SaveResultMsg); msg.Err() != nil {
+		t.Errorf("Unexpected error: %v", msg.Err())
+	}
+}
+
+func TestModel_SaveError_MasksSecret(t *testing.T) {
+	const secret = "0f6c1e2a-7b3d-4c5e-9f8a-1b2c3d4e5f60"
+	cfg := &config.Config{APIUrl: "https://test.local:8006", TokenID: "id", TokenSecret: secret, RefreshInterval: time.Second}
+	redact.AddSecret(secret)
+
+	model := NewModel(cfg, &MockLoader{SaveError: fmt.Errorf(`cannot write {"token_secret": %q}`, secret)})
+	model.width, model.height = 120, 40
+	updated, _ := model.Update(model.save()())
+
+	view := updated.(Model).View()
+	if strings.Contains(view, secret) || !strings.Contains(view, "Error: failed to save: cannot write") {
+		t.Errorf("The error should show without the secret:\n%s", view)
+	}
+}
+
+func TestField_Validate(t *testing.T) {
+	port := TextField("Port", func(c *config.Config) *string { return &c.OnStateChangeCmd })
+	port.Validate = func(s string) error {
+		if s != "8006" {
+			return errors.New("not the API port")
+		}
+		return nil
+	}
+	if err := port.check("22"); err == nil || err.Error() != "invalid port: not the API port" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := port.check("8006"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer func(saved []Field) { registry = saved }(Fields())
+
+	before := len(Fields())
+	Register(BoolField("Use Unicode", func(c *config.Config) *bool { return &c.UseUnicode }))
+
+	fields := Fields()
+	if len(fields) != before+1 || fields[before].Label != "Use Unicode" {
+		t.Fatalf("Registered field should be appended, got %d fields", len(fields))
+	}
+
+	model := NewModel(&config.Config{UseUnicode: true}, &MockLoader{})
+	model.width, model.height = 80, 40
+	if !strings.Contains(model.View(), "[X] Use Unicode") {
+		t.Errorf("Registered field should be rendered:\n%s", model.View())
+	}
+	if model.cancelButton() != before+2 {
+		t.Errorf("Buttons should follow the registered field, cancel at %d", model.cancelButton())
+	}
+}
@@ -0,0 +1,194 @@
+package configpanel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/config"
+)
+
+// Kind selects how a field is edited and how its text is parsed
+type Kind int
+
+const (
+	// KindText is a free-form string
+	KindText Kind = iota
+	// KindPassword is a string whose input is masked
+	KindPassword
+	// KindDuration is a time.Duration entered as "5s", "1m30s", ...
+	KindDuration
+	// KindBool is a checkbox
+	KindBool
+	// KindSelect cycles through a fixed list of options
+	KindSelect
+)
+
+// Field describes one setting shown in the config panel. Build fields with
+// TextField, PasswordField, DurationField, BoolField or SelectField, then
+// adjust the optional members before registering them.
+type Field struct {
+	// Label is shown above the input ("API URL") or next to the checkbox
+	Label string
+	// Name is used in error messages; it defaults to the lower-cased label
+	Name string
+	// Key is the setting in the config file, to tell which file its value
+	// comes from; optional
+	Key  string
+	Kind Kind
+	// Placeholder and Width apply to text, password and duration inputs
+	Placeholder string
+	Width       int
+	// Options lists the choices of a select field
+	Options []string
+	// Required rejects an empty value on save
+	Required bool
+	// Validate optionally checks the entered text before it is stored
+	Validate func(string) error
+
+	// Exactly one of these points the field into the config being edited
+	str  func(*config.Config) *string
+	dur  func(*config.Config) *time.Duration
+	flag func(*config.Config) *bool
+}
+
+// defaultInputWidth is the input width of text fields that don't set one
+const defaultInputWidth = 50
+
+// TextField edits the string that ptr selects in the config
+func TextField(label string, ptr func(*config.Config) *string) Field {
+	return Field{Label: label, Kind: KindText, str: ptr}
+}
+
+// PasswordField is a TextField whose input is masked
+func PasswordField(label string, ptr func(*config.Config) *string) Field {
+	return Field{Label: label, Kind: KindPassword, str: ptr}
+}
+
+// DurationField edits the duration that ptr selects in the config
+func DurationField(label string, ptr func(*config.Config) *time.Duration) Field {
+	return Field{Label: label, Kind: KindDuration, Width: 20, dur: ptr}
+}
+
+// BoolField is a checkbox for the flag that ptr selects in the config
+func BoolField(label string, ptr func(*config.Config) *bool) Field {
+	return Field{Label: label, Kind: KindBool, flag: ptr}
+}
+
+// SelectField picks one of options for the string that ptr selects in the
+// config. A current value outside options is shown as the first option.
+func SelectField(label string, options []string, ptr func(*config.Config) *string) Field {
+	return Field{Label: label, Kind: KindSelect, Options: options, str: ptr}
+}
+
+// name returns the field name used in error messages
+func (f Field) name() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return strings.ToLower(f.Label)
+}
+
+// isInput reports whether the field is edited with a text input
+func (f Field) isInput() bool {
+	return f.Kind == KindText || f.Kind == KindPassword || f.Kind == KindDuration
+}
+
+// get returns the field's current value in cfg as text
+func (f Field) get(cfg *config.Config) string {
+	switch {
+	case f.flag != nil:
+		return strconv.FormatBool(*f.flag(cfg))
+	case f.dur != nil:
+		return f.dur(cfg).String()
+	case f.str != nil:
+		return *f.str(cfg)
+	}
+	return ""
+}
+
+// check validates text entered for the field
+func (f Field) check(value string) error {
+	if f.Required && value == "" {
+		return fmt.Errorf("%s is required", f.name())
+	}
+	if f.Kind == KindDuration {
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid %s: %v", f.name(), err)
+		}
+	}
+	if f.Validate != nil {
+		if err := f.Validate(value); err != nil {
+			return fmt.Errorf("invalid %s: %v", f.name(), err)
+		}
+	}
+	return nil
+}
+
+// set stores a value that passed check into cfg
+func (f Field) set(cfg *config.Config, value string) {
+	switch {
+	case f.flag != nil:
+		*f.flag(cfg) = value == "true"
+	case f.dur != nil:
+		*f.dur(cfg), _ = time.ParseDuration(value)
+	case f.str != nil:
+		*f.str(cfg) = value
+	}
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = defaultFields()
+)
+
+// defaultFields returns the connection settings every panel starts with
+func defaultFields() []Field {
+	apiURL := TextField("API URL", func(c *config.Config) *string { return &c.APIUrl })
+	apiURL.Name = "api URL"
+	apiURL.Key = "api_url"
+	apiURL.Placeholder = "https://proxmox.example.com:8006"
+	apiURL.Required = true
+
+	tokenID := TextField("Token ID", func(c *config.Config) *string { return &c.TokenID })
+	tokenID.Name = "token ID"
+	tokenID.Key = "token_id"
+	tokenID.Placeholder = "user@realm!tokenid"
+	tokenID.Required = true
+
+	tokenSecret := PasswordField("Token Secret", func(c *config.Config) *string { return &c.TokenSecret })
+	tokenSecret.Key = "token_secret"
+	tokenSecret.Placeholder = "secret-token-value"
+	tokenSecret.Required = true
+
+	refresh := DurationField("Refresh Interval", func(c *config.Config) *time.Duration { return &c.RefreshInterval })
+	refresh.Key = "refresh_interval"
+	refresh.Placeholder = "5s"
+
+	skipTLS := BoolField("Skip TLS Verify", func(c *config.Config) *bool { return &c.SkipTLSVerify })
+	skipTLS.Key = "skip_tls_verify"
+
+	actionTimeout := DurationField("Action Timeout", func(c *config.Config) *time.Duration { return &c.ActionTimeout })
+	actionTimeout.Key = "action_timeout"
+	actionTimeout.Placeholder = "60s"
+
+	return []Field{apiURL, tokenID, tokenSecret, refresh, skipTLS, actionTimeout}
+}
+
+// Register appends fields to the config panel, after the ones already
+// registered. Packages that add config options call it from init so their
+// settings show up without the panel knowing about them.
+func Register(fields ...Field) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, fields...)
+}
+
+// Fields returns the registered fields in display order
+func Fields() []Field {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]Field(nil), registry...)
+}
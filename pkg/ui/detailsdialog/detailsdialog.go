@@ -7,168 +7,416 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
 )
 
-// GetDetailsText generates formatted text showing VM/CT details
-func GetDetailsText(vm *models.VMStatus, config map[string]interface{}, width, height, scrollOffset int) string {
-	var b strings.Builder
-
-	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-	separatorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000"))
-	statusStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-
-	// Title
-	title := fmt.Sprintf("Details: %s (%s)", vm.Name, vm.VMID)
-	b.WriteString(titleStyle.Render(title))
-	b.WriteString("\n")
-	b.WriteString(separatorStyle.Render(strings.Repeat("─", width)))
-	b.WriteString("\n")
+// GetDetailsText generates formatted text showing VM/CT details; fs may be
+// nil when no guest agent report applies
+func GetDetailsText(vm *models.VMStatus, config map[string]interface{}, fs *FilesystemInfo, width, height int, state State) string {
+	cursorStyle := lipgloss.NewStyle().Reverse(true)
 
 	// Build details
-	details := buildDetails(vm, config)
-
-	// Render visible rows with scrolling
-	visibleRows := height - 3 // Title, separator, status bar
-	endIdx := scrollOffset + visibleRows
-	if endIdx > len(details) {
-		endIdx = len(details)
-	}
-
-	for i := scrollOffset; i < endIdx; i++ {
-		detail := details[i]
-		if isSectionSeparator(detail) {
-			// Section header - centered
-			sepPadding := (width - len(detail.Key)) / 2
-			if sepPadding > 0 {
-				b.WriteString(strings.Repeat(" ", sepPadding))
-			}
-			b.WriteString(detail.Key)
+	sections := state.sections(vm, config, fs)
+	lines := flatten(sections, state.Collapsed)
+	state.clamp(len(lines), height)
+
+	body := make([]string, 0, len(lines))
+	for i, l := range lines {
+		var text string
+		if l.isHeader() {
+			text = format.Center(sectionHeader(sections[l.section], state.Collapsed), width)
 		} else {
 			// Property: Value
-			b.WriteString(fmt.Sprintf("  %-18s : %s", detail.Key, detail.Value))
+			detail := sections[l.section].Items[l.item]
+			// Sub-items are indented further but keep the colons aligned
+			indent, key := "  ", format.Pad(detail.Key, 18)
+			if detail.Sub {
+				indent, key = "      ", format.Pad(detail.Key, 14)
+			}
+			value := detail.Value
+			if !format.Color() && detail.Severity != SeverityNone {
+				value += " [!]"
+			}
+			if i != state.Cursor {
+				key = highlightMatch(key, state.Query)
+				value = severityStyle(detail.Severity).Render(value)
+			}
+			text = fmt.Sprintf("%s%s : %s", indent, key, value)
 		}
-		b.WriteString("\n")
-	}
-
-	// Fill remaining space
-	for i := endIdx - scrollOffset; i < visibleRows; i++ {
-		b.WriteString("\n")
+		if i == state.Cursor {
+			text = renderCursor(text, cursorStyle)
+		}
+		body = append(body, text)
 	}
 
-	// Status bar
-	statusText := fmt.Sprintf(" ↑↓/jk=Scroll  ESC/Enter=Close  [%d/%d]", scrollOffset+1, len(details))
-	b.WriteString(statusStyle.Render(statusText))
+	return format.FrameAt(detailsTitle(vm), body, statusText(sections, lines, state), width, height, state.Scroll)
+}
 
-	return b.String()
+// detailsTitle is the title shared by the details, loading and error screens
+func detailsTitle(vm *models.VMStatus) string {
+	return fmt.Sprintf("Details: %s (%s)", vm.Name, vm.VMID)
 }
 
-// GetLoadingText shows a loading message
-func GetLoadingText(vm *models.VMStatus, width, height int) string {
-	var b strings.Builder
+// statusText returns the key hints, the search prompt while typing, or
+// the notice of the last action
+func statusText(sections []Section, lines []line, state State) string {
+	if state.Searching {
+		return fmt.Sprintf(" /%s_  (%d matches)  Enter=Done  ESC=Cancel",
+			state.Query, countMatches(sections, state.Query))
+	}
+	if state.Notice != "" {
+		return " " + format.Text(state.Notice)
+	}
+	hints := " ↑↓/jk=Move  /=Search  Enter=Fold  r=Raw"
+	for _, section := range sections {
+		if section.Title == "cloud-init" {
+			hints += "  C=Cloud-init"
+		}
+	}
+	if len(sections) > 0 && blockingLock(sections[0]) {
+		hints += "  U=Unlock"
+	}
+	hints += "  ESC=Close"
+	text := fmt.Sprintf(format.Text(hints+"  [%d/%d]"), state.Cursor+1, len(lines))
+	if state.Query != "" {
+		text += fmt.Sprintf("  n/N=Next/Prev /%s (%d)", state.Query, countMatches(sections, state.Query))
+	}
+	return text
+}
 
-	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-	separatorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000"))
-	statusStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
+// blockingLock reports whether the section lists a lock that blocks
+// actions, the only kind worth clearing
+func blockingLock(section Section) bool {
+	for _, item := range section.Items {
+		if item.Key == "Lock" && item.Severity != SeverityNone {
+			return true
+		}
+	}
+	return false
+}
 
-	title := fmt.Sprintf("Details: %s (%s)", vm.Name, vm.VMID)
-	b.WriteString(titleStyle.Render(title))
-	b.WriteString("\n")
-	b.WriteString(separatorStyle.Render(strings.Repeat("─", width)))
-	b.WriteString("\n")
+// sectionHeader returns the header label, with the hidden entry count when folded
+func sectionHeader(section Section, collapsed map[string]bool) string {
+	if collapsed[section.Title] {
+		hidden := 0
+		for _, item := range section.Items {
+			if !item.Sub {
+				hidden++
+			}
+		}
+		return fmt.Sprintf("-- %s [+%d] --", section.Title, hidden)
+	}
+	return fmt.Sprintf("-- %s --", section.Title)
+}
 
-	// Center loading message
-	message := "Loading details..."
-	for i := 0; i < (height-4)/2; i++ {
-		b.WriteString("\n")
+// renderCursor marks the cursor line in reverse video, or with a leading
+// ">" when color is off
+func renderCursor(text string, cursorStyle lipgloss.Style) string {
+	if format.Color() {
+		return cursorStyle.Render(text)
 	}
-	msgPadding := (width - len(message)) / 2
-	if msgPadding > 0 {
-		b.WriteString(strings.Repeat(" ", msgPadding))
+	if strings.HasPrefix(text, " ") {
+		return ">" + text[1:]
 	}
-	b.WriteString(message)
-	b.WriteString("\n")
+	return ">" + text
+}
 
-	for i := 0; i < (height-4)/2; i++ {
-		b.WriteString("\n")
+// highlightMatch highlights every case-insensitive occurrence of query in s
+func highlightMatch(s, query string) string {
+	if query == "" || !format.Color() {
+		return s
 	}
+	matchStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#000000")).
+		Background(lipgloss.Color("#FFFF00"))
 
-	b.WriteString(statusStyle.Render("ESC/Enter Close"))
-	return b.String()
+	var b strings.Builder
+	for {
+		start, end := indexFold(s, query)
+		if start < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:start])
+		b.WriteString(matchStyle.Render(s[start:end]))
+		s = s[end:]
+	}
+}
+
+// GetLoadingText shows a loading message
+func GetLoadingText(vm *models.VMStatus, width, height int) string {
+	return format.FrameCentered(detailsTitle(vm), []string{"Loading details..."}, "ESC/Enter Close", width, height)
 }
 
 // GetErrorText shows an error message
 func GetErrorText(vm *models.VMStatus, err error, width, height int) string {
-	var b strings.Builder
-
-	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-	separatorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000"))
-	statusStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-
-	title := fmt.Sprintf("Details: %s (%s)", vm.Name, vm.VMID)
-	b.WriteString(titleStyle.Render(title))
-	b.WriteString("\n")
-	b.WriteString(separatorStyle.Render(strings.Repeat("─", width)))
-	b.WriteString("\n\n")
+	body := []string{
+		"",
+		fmt.Sprintf("Error: %v", redact.Error(err)),
+		"",
+		// Show basic info
+		fmt.Sprintf("  %s : %s", format.Pad("VMID", 18), vm.VMID),
+		fmt.Sprintf("  %s : %s", format.Pad("Name", 18), vm.Name),
+		fmt.Sprintf("  %s : %s", format.Pad("Type", 18), vm.Type),
+		fmt.Sprintf("  %s : %s", format.Pad("Status", 18), vm.Status),
+	}
+	return format.Frame(detailsTitle(vm), body, "ESC/Enter Close", width, height)
+}
 
-	errorMsg := fmt.Sprintf("Error: %v", err)
-	b.WriteString(errorMsg)
-	b.WriteString("\n\n")
+// Severity marks a value that crossed a usage threshold
+type Severity int
 
-	// Show basic info
-	b.WriteString(fmt.Sprintf("  %-18s : %s\n", "VMID", vm.VMID))
-	b.WriteString(fmt.Sprintf("  %-18s : %s\n", "Name", vm.Name))
-	b.WriteString(fmt.Sprintf("  %-18s : %s\n", "Type", vm.Type))
-	b.WriteString(fmt.Sprintf("  %-18s : %s\n", "Status", vm.Status))
+const (
+	SeverityNone Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
 
-	// Fill remaining space
-	currentLines := 7
-	for i := currentLines; i < height-1; i++ {
-		b.WriteString("\n")
+// usageSeverity classifies a usage percentage
+func usageSeverity(percent float64) Severity {
+	switch {
+	case percent >= format.UsageCritical:
+		return SeverityCritical
+	case percent >= format.UsageWarning:
+		return SeverityWarning
 	}
+	return SeverityNone
+}
 
-	b.WriteString(statusStyle.Render("ESC/Enter Close"))
-	return b.String()
+// severityStyle returns the style used to render a value of the given severity
+func severityStyle(severity Severity) lipgloss.Style {
+	switch severity {
+	case SeverityCritical:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Bold(true)
+	case SeverityWarning:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("#FFA500"))
+	}
+	return lipgloss.NewStyle()
 }
 
 // DetailItem represents a key-value pair
 type DetailItem struct {
-	Key   string
-	Value string
+	Key      string
+	Value    string
+	Sub      bool     // Nested under the preceding item, e.g. the bridge of a NIC
+	Severity Severity // Colors the value when a usage threshold is crossed
+}
+
+// FilesystemInfo is the guest agent's filesystem report for a running VM
+type FilesystemInfo struct {
+	Loading     bool
+	Filesystems []models.Filesystem
+	Err         error
 }
 
-// buildDetails creates a list of key-value pairs from the VM status and config
-func buildDetails(vm *models.VMStatus, config map[string]interface{}) []DetailItem {
-	// Start with basic VM details
-	details := buildBasicDetails(vm)
+// Section is a titled group of details; the untitled first section holds
+// the guest status and has no header
+type Section struct {
+	Title string
+	Items []DetailItem
+}
 
-	// Add VM-specific details (like guest agent)
-	details = append(details, buildVMSpecificDetails(vm, config)...)
+// buildDetails creates the sections of key-value pairs from the VM status,
+// config and agent filesystem report; raw shows disk and NIC strings as
+// configured instead of broken down
+func buildDetails(vm *models.VMStatus, config map[string]interface{}, fs *FilesystemInfo, raw bool) []Section {
+	// Start with basic VM details, plus VM-specific ones (like guest agent)
+	general := buildBasicDetails(vm, guestHostname(vm, config))
+	if lock, blocks := guestLock(vm, config); blocks {
+		general = append(general, DetailItem{Key: "Lock", Value: lock, Severity: SeverityWarning})
+	} else if lock != "" {
+		general = append(general, DetailItem{Key: "Lock", Value: lock})
+	}
+	if len(config) > 0 {
+		general = append(general, DetailItem{Key: "Disk Alloc", Value: diskAlloc(config)})
+	}
+	general = append(general, buildVMSpecificDetails(vm, config)...)
 
 	// Add categorized config details
-	details = append(details, buildConfigDetails(config)...)
+	sections := []Section{{Items: general}}
+	if fs != nil {
+		sections = append(sections, Section{Title: "filesystems", Items: buildFilesystemDetails(fs)})
+	}
+	// Containers have nameserver and searchdomain too, without cloud-init
+	cloudInit, hasCloudInit := configparse.ParseCloudInit(config)
+	hasCloudInit = hasCloudInit && vm.Type == models.TypeVM
+	if hasCloudInit {
+		sections = append(sections, Section{Title: "cloud-init", Items: buildCloudInitDetails(cloudInit)})
+	}
+	return append(sections, buildConfigDetails(config, raw, hasCloudInit)...)
+}
+
+// buildCloudInitDetails lists the cloud-init settings. The password is
+// never shown, and SSH keys only by fingerprint.
+func buildCloudInitDetails(ci configparse.CloudInit) []DetailItem {
+	drive := "none, the settings are unused"
+	if ci.Drive != "" {
+		drive = fmt.Sprintf("%s on %s", ci.Drive, ci.Storage)
+	}
+	user := ci.User
+	if user == "" {
+		user = "image default"
+	}
+	password := "not set"
+	if ci.Password {
+		password = "set (hidden)"
+	}
+	details := []DetailItem{
+		{Key: "drive", Value: drive},
+		{Key: "user", Value: user},
+		{Key: "password", Value: password},
+	}
+	for _, ip := range ci.IPConfigs {
+		details = append(details, DetailItem{Key: ip.Key, Value: ip.Value})
+	}
+	if ci.Nameserver != "" {
+		details = append(details, DetailItem{Key: "nameserver", Value: ci.Nameserver})
+	}
+	if ci.SearchDomain != "" {
+		details = append(details, DetailItem{Key: "searchdomain", Value: ci.SearchDomain})
+	}
+
+	keys := fmt.Sprintf("%d keys", len(ci.SSHKeys))
+	switch len(ci.SSHKeys) {
+	case 0:
+		keys = "none"
+	case 1:
+		keys = "1 key"
+	}
+	details = append(details, DetailItem{Key: "ssh keys", Value: keys})
+	for _, key := range ci.SSHKeys {
+		value := strings.TrimSpace(key.Fingerprint + " " + key.Comment)
+		if key.Fingerprint == "" {
+			value = "does not parse"
+		}
+		details = appendSub(details, key.Type, value)
+	}
+	return details
+}
+
+// buildFilesystemDetails lists each filesystem by mountpoint with its usage
+func buildFilesystemDetails(fs *FilesystemInfo) []DetailItem {
+	switch {
+	case fs.Loading:
+		return []DetailItem{{Key: "guest agent", Value: "loading..."}}
+	case fs.Err != nil:
+		return []DetailItem{{Key: "guest agent", Value: "not responding"}}
+	case len(fs.Filesystems) == 0:
+		return []DetailItem{{Key: "guest agent", Value: "no filesystems reported"}}
+	}
 
+	var details []DetailItem
+	for _, f := range fs.Filesystems {
+		details = append(details, DetailItem{
+			Key: f.Mountpoint,
+			Value: fmt.Sprintf("%-6s %s / %s (%.1f%%)", f.Type,
+				format.Bytes(f.UsedBytes), format.Bytes(f.TotalBytes), f.UsagePercent()),
+			Severity: usageSeverity(f.UsagePercent()),
+		})
+	}
 	return details
 }
 
-// isSectionSeparator checks if a detail item is a section separator
-func isSectionSeparator(detail DetailItem) bool {
-	return strings.HasPrefix(detail.Key, "--") && strings.HasSuffix(detail.Key, "--")
+// guestHostname returns the guest's own hostname: a container's from its
+// config, a VM's as its guest agent reported it; empty when unknown
+func guestHostname(vm *models.VMStatus, config map[string]interface{}) string {
+	if hostname, ok := config["hostname"].(string); ok && vm.Type == models.TypeContainer {
+		return hostname
+	}
+	return vm.Hostname
+}
+
+// buildBasicDetails creates the core VM status details; the hostname row
+// is left out while the guest's own hostname is unknown
+func buildBasicDetails(vm *models.VMStatus, hostname string) []DetailItem {
+	memUsage := fmt.Sprintf("%.2f%%", vm.MemoryUsage)
+	if !vm.HasMemoryUsage() {
+		memUsage = format.Unknown
+	}
+	maxMem := format.Bytes(vm.MaxMem)
+	if !vm.IsKnown(models.MetricMaxMem) {
+		maxMem = format.Unknown
+	}
+	maxCPU := fmt.Sprintf("%d cores", vm.MaxCPU)
+	if !vm.IsKnown(models.MetricMaxCPU) {
+		maxCPU = format.Unknown
+	}
+	uptime := format.Uptime(vm.Uptime)
+	if !vm.IsKnown(models.MetricUptime) {
+		uptime = format.Unknown
+	}
+	diskUsage := format.Unknown
+	if vm.HasDiskUsage() {
+		diskUsage = fmt.Sprintf("%s / %s (%.1f%%)",
+			format.Bytes(vm.Disk), format.Bytes(vm.MaxDisk), vm.DiskUsage())
+	}
+
+	details := []DetailItem{
+		{Key: "VMID", Value: vm.VMID},
+		{Key: "Name", Value: vm.Name},
+	}
+	if models.HostnameDiffers(vm.Name, hostname) {
+		details = append(details, DetailItem{Key: "Hostname", Value: hostname + " (differs from the name)", Severity: SeverityWarning})
+	} else if hostname != "" {
+		details = append(details, DetailItem{Key: "Hostname", Value: hostname})
+	}
+	return append(details, []DetailItem{
+		{Key: "Type", Value: string(vm.Type)},
+		{Key: "Status", Value: string(vm.Status)},
+		{Key: "Node", Value: vm.Node},
+		{Key: "CPU Usage", Value: fmt.Sprintf("%.2f%%", vm.CPUUsage)},
+		{Key: "Memory Usage", Value: memUsage},
+		{Key: "Max Memory", Value: maxMem},
+		{Key: "Max CPU", Value: maxCPU},
+		{Key: "Disk Usage", Value: diskUsage},
+		{Key: "Uptime", Value: uptime},
+	}...)
+}
+
+// withNodeLoad adds the node's CPU load after the guest's CPU usage, and
+// flags the guest's usage when both are past the warning threshold
+func withNodeLoad(sections []Section, load *NodeLoad) []Section {
+	if load == nil || len(sections) == 0 {
+		return sections
+	}
+	general := sections[0].Items
+	for i, item := range general {
+		if item.Key != "CPU Usage" {
+			continue
+		}
+		if load.Pressure {
+			general[i].Value += " (node busy too)"
+			general[i].Severity = SeverityCritical
+		}
+		node := DetailItem{Key: "Node Load", Value: fmt.Sprintf("%.2f%% of %d cores", load.CPU, load.Cores), Severity: usageSeverity(load.CPU)}
+		sections[0].Items = append(general[:i+1:i+1], append([]DetailItem{node}, general[i+1:]...)...)
+		break
+	}
+	return sections
 }
 
-// buildBasicDetails creates the core VM status details
-func buildBasicDetails(vm *models.VMStatus) []DetailItem {
-	return []DetailItem{
-		{"VMID", vm.VMID},
-		{"Name", vm.Name},
-		{"Type", string(vm.Type)},
-		{"Status", string(vm.Status)},
-		{"Node", vm.Node},
-		{"CPU Usage", fmt.Sprintf("%.2f%%", vm.CPUUsage)},
-		{"Memory Usage", fmt.Sprintf("%.2f%%", vm.MemoryUsage)},
-		{"Max Memory", formatBytes(vm.MaxMem)},
-		{"Max CPU", fmt.Sprintf("%d cores", vm.MaxCPU)},
-		{"Uptime", formatUptime(vm.Uptime)},
+// diskAlloc returns the total configured size of the guest's disks
+func diskAlloc(config map[string]interface{}) string {
+	total := configparse.AllocatedDiskSize(config)
+	if total <= 0 {
+		return format.Unknown
+	}
+	return format.Bytes(total)
+}
+
+// guestLock returns the reason the guest is locked, from the list or else
+// from its config, and whether the lock blocks actions
+func guestLock(vm *models.VMStatus, config map[string]interface{}) (string, bool) {
+	locked := *vm
+	if locked.Lock == "" {
+		locked.Lock, _ = config["lock"].(string)
+	}
+	if !locked.Locked() {
+		return locked.Lock, false
 	}
+	return locked.Lock + " (actions are blocked until the task ends or the lock is cleared)", true
 }
 
 // buildVMSpecificDetails adds VM-type specific details like guest agent
@@ -176,10 +424,36 @@ func buildVMSpecificDetails(vm *models.VMStatus, config map[string]interface{})
 	var details []DetailItem
 
 	// Add guest agent info for VMs (from config)
-	if vm.Type == string(models.TypeVM) {
-		details = append(details, DetailItem{"Guest Agent", getGuestAgentStatus(config)})
+	if vm.Type == models.TypeVM {
+		details = append(details, DetailItem{Key: "Guest Agent", Value: getGuestAgentStatus(config)})
 	}
+	if vm.QEMU != nil && vm.Status == models.StateRunning {
+		details = append(details, buildQEMUDetails(vm)...)
+	}
+
+	return details
+}
 
+// buildQEMUDetails shows the state of a running VM's QEMU process, which
+// tells a VM that runs its guest from one Proxmox only lists as running
+func buildQEMUDetails(vm *models.VMStatus) []DetailItem {
+	qemu := DetailItem{Key: "QEMU", Value: vm.QEMU.QMPStatus}
+	if problem := vm.QEMU.Problem(vm.Status); problem != "" {
+		qemu.Value += " (" + problem + "; Proxmox still lists the VM as running)"
+		qemu.Severity = SeverityWarning
+	}
+	if qemu.Value == "" {
+		qemu.Value = format.Unknown
+	}
+	details := []DetailItem{qemu}
+	switch vm.QEMU.Balloon {
+	case models.BalloonReported:
+		details = append(details, DetailItem{Key: "Balloon Driver", Value: "reporting, so the guest OS is up"})
+	case models.BalloonSilent:
+		details = append(details, DetailItem{Key: "Balloon Driver", Value: "not reporting: not installed, or the guest OS is not up"})
+	case models.BalloonNone:
+		details = append(details, DetailItem{Key: "Balloon Driver", Value: "no balloon device"})
+	}
 	return details
 }
 
@@ -190,69 +464,135 @@ func getGuestAgentStatus(config map[string]interface{}) string {
 		return "Not Configured"
 	}
 
-	agentStr := fmt.Sprintf("%v", agentValue)
-	// Parse agent configuration (can be "1", "1,type=isa", etc.)
-	if strings.HasPrefix(agentStr, "1") {
+	// Parse agent configuration (can be "1", "1,type=isa", "enabled=1", etc.)
+	if configparse.AgentEnabled(FormatValue(agentValue)) {
 		return "Enabled"
 	}
 	return "Disabled"
 }
 
-// buildConfigDetails organizes additional config fields by category
-func buildConfigDetails(config map[string]interface{}) []DetailItem {
+// buildConfigDetails organizes additional config fields by category,
+// leaving out the cloud-init settings when they have their own section
+func buildConfigDetails(config map[string]interface{}, raw, cloudInit bool) []Section {
 	if len(config) == 0 {
 		return nil
 	}
 
 	// Categorize config keys
-	resourceKeys, optionKeys := categorizeConfigKeys(config)
+	resourceKeys, networkKeys, optionKeys := categorizeConfigKeys(config, cloudInit)
 
-	// Sort both categories
+	// Sort all categories
 	sort.Strings(resourceKeys)
+	sort.Strings(networkKeys)
 	sort.Strings(optionKeys)
 
-	var details []DetailItem
-
-	// Add resource fields first
-	if len(resourceKeys) > 0 {
-		details = append(details, DetailItem{"-- resources --", ""})
-		details = append(details, buildKeyValuePairs(resourceKeys, config)...)
-	}
-
-	// Add option fields
-	if len(optionKeys) > 0 {
-		details = append(details, DetailItem{"-- options --", ""})
-		details = append(details, buildKeyValuePairs(optionKeys, config)...)
+	var sections []Section
+	for _, category := range []struct {
+		title string
+		keys  []string
+	}{
+		{"resources", resourceKeys},
+		{"network", networkKeys},
+		{"options", optionKeys},
+	} {
+		if len(category.keys) > 0 {
+			sections = append(sections, Section{
+				Title: category.title,
+				Items: buildKeyValuePairs(category.keys, config, raw),
+			})
+		}
 	}
 
-	return details
+	return sections
 }
 
-// categorizeConfigKeys separates config keys into resource and option categories
-func categorizeConfigKeys(config map[string]interface{}) ([]string, []string) {
+// categorizeConfigKeys separates config keys into resource, network and
+// option categories, skipping cloud-init settings when cloudInit is set
+func categorizeConfigKeys(config map[string]interface{}, cloudInit bool) ([]string, []string, []string) {
 	var resourceKeys []string
+	var networkKeys []string
 	var optionKeys []string
 
 	for k := range config {
 		// Skip fields we already display
-		if !isDisplayedField(k) {
-			if isResourceField(k) {
-				resourceKeys = append(resourceKeys, k)
-			} else {
-				optionKeys = append(optionKeys, k)
-			}
+		if isDisplayedField(k) || (cloudInit && configparse.IsCloudInitKey(k)) {
+			continue
+		}
+		switch {
+		case configparse.IsNICKey(k):
+			networkKeys = append(networkKeys, k)
+		case isResourceField(k):
+			resourceKeys = append(resourceKeys, k)
+		default:
+			optionKeys = append(optionKeys, k)
 		}
 	}
 
-	return resourceKeys, optionKeys
+	return resourceKeys, networkKeys, optionKeys
 }
 
-// buildKeyValuePairs creates DetailItems from a list of keys and their config values
-func buildKeyValuePairs(keys []string, config map[string]interface{}) []DetailItem {
+// buildKeyValuePairs creates DetailItems from a list of keys and their config
+// values, breaking disk and NIC strings down into sub-items unless raw is set
+func buildKeyValuePairs(keys []string, config map[string]interface{}, raw bool) []DetailItem {
 	var details []DetailItem
 	for _, k := range keys {
-		value := formatValue(config[k])
-		details = append(details, DetailItem{strings.ToLower(k), value})
+		key := strings.ToLower(k)
+		value := FormatValue(config[k])
+		switch {
+		case raw:
+			details = append(details, DetailItem{Key: key, Value: value})
+		case configparse.IsDiskKey(key):
+			details = append(details, diskDetails(key, value)...)
+		case configparse.IsNICKey(key):
+			details = append(details, nicDetails(key, value)...)
+		default:
+			details = append(details, DetailItem{Key: key, Value: value})
+		}
+	}
+	return details
+}
+
+// diskDetails renders a disk or mount point as its volume followed by sub-items
+func diskDetails(key, value string) []DetailItem {
+	disk := configparse.ParseDisk(value)
+	details := []DetailItem{{Key: key, Value: disk.Volume}}
+	details = appendSub(details, "storage", disk.Storage)
+	details = appendSub(details, "size", disk.Size)
+	details = appendSub(details, "media", disk.Media)
+	details = appendSub(details, "mount point", disk.MountPoint)
+	return appendOptions(details, disk.Options)
+}
+
+// nicDetails renders a network interface as its model followed by sub-items
+func nicDetails(key, value string) []DetailItem {
+	nic := configparse.ParseNIC(value)
+	details := []DetailItem{{Key: key, Value: nic.Model}}
+	details = appendSub(details, "name", nic.Name)
+	details = appendSub(details, "MAC", nic.MAC)
+	details = appendSub(details, "bridge", nic.Bridge)
+	details = appendSub(details, "VLAN tag", nic.Tag)
+	if nic.Firewall {
+		details = appendSub(details, "firewall", "on")
+	}
+	return appendOptions(details, nic.Options)
+}
+
+// appendSub adds a sub-item unless the value is empty
+func appendSub(details []DetailItem, key, value string) []DetailItem {
+	if value == "" {
+		return details
+	}
+	return append(details, DetailItem{Key: key, Value: value, Sub: true})
+}
+
+// appendOptions adds the remaining properties as sub-items; bare flags show as "set"
+func appendOptions(details []DetailItem, options []configparse.Property) []DetailItem {
+	for _, opt := range options {
+		value := opt.Value
+		if value == "" {
+			value = "set"
+		}
+		details = appendSub(details, opt.Key, value)
 	}
 	return details
 }
@@ -261,7 +601,7 @@ func buildKeyValuePairs(keys []string, config map[string]interface{}) []DetailIt
 func isDisplayedField(key string) bool {
 	displayed := []string{
 		"vmid", "name", "type", "status", "node",
-		"cpu", "mem", "maxmem", "maxcpu", "uptime", "agent",
+		"cpu", "mem", "maxmem", "maxcpu", "uptime", "agent", "lock",
 	}
 	keyLower := strings.ToLower(key)
 	for _, d := range displayed {
@@ -277,7 +617,6 @@ func isResourceField(key string) bool {
 	resourceFields := []string{
 		"cores", "sockets", "cpu", "vcpus", "cpulimit", "cpuunits",
 		"memory", "balloon", "shares",
-		"net0", "net1", "net2", "net3", "net4", "net5",
 		"scsi0", "scsi1", "scsi2", "scsi3", "ide0", "ide1", "ide2", "ide3",
 		"sata0", "sata1", "sata2", "sata3", "virtio0", "virtio1", "virtio2", "virtio3",
 		"mp0", "mp1", "mp2", "mp3", "mp4", "mp5", "mp6", "mp7", "mp8", "mp9",
@@ -296,8 +635,9 @@ func isResourceField(key string) bool {
 	return false
 }
 
-// formatValue converts an interface{} to a readable string
-func formatValue(v interface{}) string {
+// FormatValue converts a decoded config value to a readable string, as
+// the config shows it
+func FormatValue(v interface{}) string {
 	if v == nil {
 		return "null"
 	}
@@ -338,44 +678,7 @@ func formatBool(val bool) string {
 func formatArray(val []interface{}) string {
 	var items []string
 	for _, item := range val {
-		items = append(items, formatValue(item))
+		items = append(items, FormatValue(item))
 	}
 	return "[" + strings.Join(items, ", ") + "]"
 }
-
-// formatBytes formats bytes into human-readable format
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	units := []string{"KB", "MB", "GB", "TB", "PB", "EB"}
-	if exp >= len(units) {
-		exp = len(units) - 1 // Prevent index out of bounds
-	}
-	return fmt.Sprintf("%.1f %s", float64(bytes)/float64(div), units[exp])
-}
-
-// formatUptime formats uptime seconds into human-readable format
-func formatUptime(seconds int64) string {
-	if seconds <= 0 {
-		return "N/A"
-	}
-
-	days := seconds / 86400
-	hours := (seconds % 86400) / 3600
-	minutes := (seconds % 3600) / 60
-
-	if days > 0 {
-		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
-	}
-	if hours > 0 {
-		return fmt.Sprintf("%dh %dm", hours, minutes)
-	}
-	return fmt.Sprintf("%dm", minutes)
-}
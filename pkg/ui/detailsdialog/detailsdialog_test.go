@@ -5,7 +5,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
 )
 
 func TestGetDetailsText(t *testing.T) {
@@ -22,7 +25,7 @@ func TestGetDetailsText(t *testing.T) {
 		"memory": 4096,
 	}
 
-	result := GetDetailsText(vm, config, 80, 24, 0)
+	result := GetDetailsText(vm, config, nil, 80, 24, State{})
 
 	if result == "" {
 		t.Error("GetDetailsText returned empty string")
@@ -69,3 +72,442 @@ func TestGetErrorText(t *testing.T) {
 		t.Error("Missing error message")
 	}
 }
+
+func TestBuildBasicDetails_UnknownMetrics(t *testing.T) {
+	vm := &models.VMStatus{
+		VMID:    "101",
+		Name:    "legacy-dns",
+		Type:    "lxc",
+		Status:  "stopped",
+		MaxMem:  536870912,
+		Missing: models.MetricMaxCPU | models.MetricMem | models.MetricUptime,
+	}
+
+	values := make(map[string]string)
+	for _, item := range buildBasicDetails(vm, "") {
+		values[item.Key] = item.Value
+	}
+
+	for _, key := range []string{"Max CPU", "Memory Usage", "Uptime"} {
+		if values[key] != "-" {
+			t.Errorf("%s should render as '-', got %q", key, values[key])
+		}
+	}
+	if values["Max Memory"] != "512.0 MB" {
+		t.Errorf("Known max memory should be rendered, got %q", values["Max Memory"])
+	}
+}
+
+func TestState_NodeLoad(t *testing.T) {
+	vm := &models.VMStatus{VMID: "100", Name: "web", Type: "qemu", Status: "running", CPUUsage: 95}
+	items := func(load *NodeLoad) []DetailItem {
+		s := State{NodeLoad: load}
+		return s.sections(vm, nil, nil)[0].Items
+	}
+	indexOf := func(items []DetailItem, key string) int {
+		for i, item := range items {
+			if item.Key == key {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if indexOf(items(nil), "Node Load") != -1 {
+		t.Error("Node Load should be left out when the node is unknown")
+	}
+
+	got := items(&NodeLoad{CPU: 96, Cores: 16, Pressure: true})
+	cpu, node := indexOf(got, "CPU Usage"), indexOf(got, "Node Load")
+	if node != cpu+1 {
+		t.Fatalf("Node Load should follow CPU Usage, got %d after %d", node, cpu)
+	}
+	if got[node].Value != "96.00% of 16 cores" || got[node].Severity != SeverityCritical {
+		t.Errorf("Unexpected node load %+v", got[node])
+	}
+	if got[cpu].Value != "95.00% (node busy too)" || got[cpu].Severity != SeverityCritical {
+		t.Errorf("CPU Usage should be flagged under pressure, got %+v", got[cpu])
+	}
+
+	got = items(&NodeLoad{CPU: 20, Cores: 4})
+	if cpu := got[indexOf(got, "CPU Usage")]; cpu.Value != "95.00%" || cpu.Severity != SeverityNone {
+		t.Errorf("CPU Usage should be left alone without pressure, got %+v", cpu)
+	}
+}
+
+func TestBuildDetails_Hostname(t *testing.T) {
+	hostnameOf := func(vm *models.VMStatus, config map[string]interface{}) *DetailItem {
+		for _, item := range buildDetails(vm, config, nil, false)[0].Items {
+			if item.Key == "Hostname" {
+				return &item
+			}
+		}
+		return nil
+	}
+
+	ct := &models.VMStatus{VMID: "200", Name: "web-2", Type: "lxc"}
+	item := hostnameOf(ct, map[string]interface{}{"hostname": "debian-template"})
+	if item == nil || item.Value != "debian-template (differs from the name)" || item.Severity != SeverityWarning {
+		t.Errorf("Expected the container's differing hostname flagged, got %+v", item)
+	}
+	if item := hostnameOf(ct, map[string]interface{}{"hostname": "web-2"}); item == nil || item.Severity != SeverityNone {
+		t.Errorf("Expected a matching hostname shown plainly, got %+v", item)
+	}
+
+	vm := &models.VMStatus{VMID: "100", Name: "web-1", Type: "qemu", Hostname: "web-1.example.com"}
+	if item := hostnameOf(vm, nil); item == nil || item.Value != "web-1.example.com" || item.Severity != SeverityNone {
+		t.Errorf("Expected the agent's hostname, got %+v", item)
+	}
+	vm.Hostname = ""
+	if item := hostnameOf(vm, nil); item != nil {
+		t.Errorf("An unknown hostname should be left out, got %+v", item)
+	}
+}
+
+func TestBuildDetails_Sections(t *testing.T) {
+	vm := &models.VMStatus{VMID: "100", Name: "test-vm", Type: "qemu"}
+	config := map[string]interface{}{
+		"cores":  2,
+		"scsi0":  "local-lvm:vm-100-disk-0,size=32G",
+		"net0":   "virtio=AA:BB:CC:DD:EE:FF,bridge=vmbr0",
+		"net10":  "virtio=AA:BB:CC:DD:EE:00,bridge=vmbr1",
+		"onboot": 1,
+	}
+
+	sections := buildDetails(vm, config, nil, false)
+
+	var titles []string
+	for _, section := range sections {
+		titles = append(titles, section.Title)
+	}
+	if strings.Join(titles, ",") != ",resources,network,options" {
+		t.Fatalf("Unexpected sections: %q", titles)
+	}
+	if sections[0].Items[0].Key != "VMID" {
+		t.Error("General section should start with the VMID")
+	}
+	var nics []string
+	for _, item := range sections[2].Items {
+		if !item.Sub {
+			nics = append(nics, item.Key)
+		}
+	}
+	if strings.Join(nics, ",") != "net0,net10" {
+		t.Errorf("Network section should hold the NICs, got %v", nics)
+	}
+}
+
+func TestBuildDetails_ParsedDisksAndNICs(t *testing.T) {
+	vm := &models.VMStatus{VMID: "104", Name: "web-1", Type: "qemu"}
+	config := map[string]interface{}{
+		"scsi0": "local-lvm:vm-104-disk-0,iothread=1,size=32G",
+		"net0":  "virtio=BC:24:11:2E:6A:01,bridge=vmbr0,tag=30,firewall=1",
+	}
+
+	items := make(map[string]DetailItem)
+	for _, section := range buildDetails(vm, config, nil, false)[1:] {
+		for _, item := range section.Items {
+			items[item.Key] = item
+		}
+	}
+
+	expected := map[string]string{
+		"scsi0":    "local-lvm:vm-104-disk-0",
+		"storage":  "local-lvm",
+		"size":     "32G",
+		"iothread": "1",
+		"net0":     "virtio",
+		"MAC":      "BC:24:11:2E:6A:01",
+		"bridge":   "vmbr0",
+		"VLAN tag": "30",
+		"firewall": "on",
+	}
+	for key, value := range expected {
+		if items[key].Value != value {
+			t.Errorf("%s: expected %q, got %q", key, value, items[key].Value)
+		}
+	}
+	if items["scsi0"].Sub || !items["bridge"].Sub {
+		t.Error("Entries should be top-level and their parts nested")
+	}
+
+	raw := buildDetails(vm, config, nil, true)
+	for _, section := range raw[1:] {
+		for _, item := range section.Items {
+			if item.Sub {
+				t.Errorf("Raw mode should not break entries down, got %v", item)
+			}
+			if item.Key == "scsi0" && item.Value != config["scsi0"] {
+				t.Errorf("Raw mode should show the configured string, got %q", item.Value)
+			}
+		}
+	}
+}
+
+func TestGetDetailsText_CollapsedAndHighlighted(t *testing.T) {
+	vm := &models.VMStatus{VMID: "100", Name: "test-vm", Type: "qemu"}
+	config := map[string]interface{}{
+		"net0":  "virtio=AA:BB:CC:DD:EE:FF,bridge=vmbr0",
+		"cores": 2,
+	}
+
+	state := State{Collapsed: map[string]bool{"network": true}}
+	result := GetDetailsText(vm, config, nil, 80, 24, state)
+	if !strings.Contains(result, "-- network [+1] --") {
+		t.Error("Collapsed section should show its hidden item count")
+	}
+	if strings.Contains(result, "vmbr0") {
+		t.Error("Collapsed section items should be hidden")
+	}
+
+	state = State{Query: "cor"}
+	result = GetDetailsText(vm, config, nil, 80, 24, state)
+	if !strings.Contains(result, "n/N=Next/Prev /cor (1)") {
+		t.Error("Status bar should show the active search and its match count")
+	}
+}
+
+func TestHighlightMatch_NonASCII(t *testing.T) {
+	original := lipgloss.ColorProfile()
+	defer lipgloss.SetColorProfile(original)
+	lipgloss.SetColorProfile(termenv.TrueColor)
+
+	got := highlightMatch("İmage-x", "x")
+	if !strings.HasPrefix(got, "İmage-\x1b[") || !strings.Contains(got, "mx\x1b[") {
+		t.Errorf("Expected x alone highlighted, got %q", got)
+	}
+}
+
+func TestGetDetailsText_NoColorMarkers(t *testing.T) {
+	defer format.SetColor(true)
+	format.SetColor(false)
+
+	vm := &models.VMStatus{VMID: "100", Name: "vm-1", Type: "qemu", Status: "running"}
+	fs := &FilesystemInfo{Filesystems: []models.Filesystem{
+		{Mountpoint: "/", Type: "ext4", UsedBytes: 97 << 20, TotalBytes: 100 << 20},
+	}}
+	result := GetDetailsText(vm, nil, fs, 80, 24, State{Query: "Name"})
+
+	if strings.Contains(result, "\x1b") {
+		t.Error("Details should not contain ANSI escape sequences without color")
+	}
+	if !strings.Contains(result, "> VMID") {
+		t.Errorf("Cursor line should be marked with '>':\n%s", result)
+	}
+	if !strings.Contains(result, "(97.0%) [!]") {
+		t.Errorf("Critical disk usage should be marked with [!]:\n%s", result)
+	}
+}
+
+func TestBuildDetails_DiskAlloc(t *testing.T) {
+	vm := &models.VMStatus{VMID: "200", Name: "ct-1", Type: "lxc"}
+	config := map[string]interface{}{
+		"rootfs": "local-zfs:subvol-200-disk-0,size=8G",
+		"mp0":    "tank:subvol-200-disk-1,mp=/srv,size=24G",
+	}
+
+	values := make(map[string]string)
+	for _, item := range buildDetails(vm, config, nil, false)[0].Items {
+		values[item.Key] = item.Value
+	}
+	if values["Disk Alloc"] != "32.0 GB" {
+		t.Errorf("Expected 32.0 GB allocated, got %q", values["Disk Alloc"])
+	}
+
+	for _, item := range buildDetails(vm, nil, nil, false)[0].Items {
+		if item.Key == "Disk Alloc" {
+			t.Error("Disk Alloc should be omitted without a config")
+		}
+	}
+}
+
+func TestBuildDetails_Lock(t *testing.T) {
+	lockOf := func(vm *models.VMStatus, config map[string]interface{}) string {
+		for _, item := range buildDetails(vm, config, nil, false)[0].Items {
+			if item.Key == "Lock" {
+				return item.Value
+			}
+		}
+		return ""
+	}
+
+	vm := &models.VMStatus{VMID: "100", Type: "qemu", Status: "stopped", Lock: "backup"}
+	if got := lockOf(vm, nil); !strings.HasPrefix(got, "backup (actions are blocked") {
+		t.Errorf("Expected the lock reason, got %q", got)
+	}
+
+	// Older servers only report the lock in the config
+	vm.Lock = ""
+	config := map[string]interface{}{"lock": "migrate"}
+	if got := lockOf(vm, config); !strings.HasPrefix(got, "migrate") {
+		t.Errorf("Expected the config lock, got %q", got)
+	}
+	for _, section := range buildDetails(vm, config, nil, false)[1:] {
+		for _, item := range section.Items {
+			if item.Key == "lock" {
+				t.Error("The lock should not be listed twice")
+			}
+		}
+	}
+
+	hibernated := &models.VMStatus{VMID: "101", Type: "qemu", Status: models.StateHibernated, Lock: "suspended"}
+	if got := lockOf(hibernated, nil); got != "suspended" {
+		t.Errorf("A hibernated VM's lock doesn't block it, got %q", got)
+	}
+	if got := lockOf(&models.VMStatus{VMID: "102", Type: "qemu"}, nil); got != "" {
+		t.Errorf("An unlocked guest has no lock row, got %q", got)
+	}
+}
+
+func TestBuildDetails_QEMU(t *testing.T) {
+	itemOf := func(vm *models.VMStatus, key string) (DetailItem, bool) {
+		for _, item := range buildDetails(vm, nil, nil, false)[0].Items {
+			if item.Key == key {
+				return item, true
+			}
+		}
+		return DetailItem{}, false
+	}
+
+	vm := &models.VMStatus{VMID: "100", Type: "qemu", Status: "running",
+		QEMU: &models.QEMUHealth{QMPStatus: "prelaunch", Balloon: models.BalloonSilent}}
+	qemu, _ := itemOf(vm, "QEMU")
+	if !strings.HasPrefix(qemu.Value, "prelaunch (QEMU has not started the guest CPUs") || qemu.Severity != SeverityWarning {
+		t.Errorf("Expected the disagreement explained as a warning, got %+v", qemu)
+	}
+	if balloon, _ := itemOf(vm, "Balloon Driver"); !strings.HasPrefix(balloon.Value, "not reporting") {
+		t.Errorf("Expected the silent balloon driver, got %q", balloon.Value)
+	}
+
+	vm.QEMU = &models.QEMUHealth{QMPStatus: "running", Balloon: models.BalloonReported}
+	if qemu, _ := itemOf(vm, "QEMU"); qemu.Value != "running" || qemu.Severity != SeverityNone {
+		t.Errorf("Expected a healthy VM shown plainly, got %+v", qemu)
+	}
+
+	vm.Status = models.StateStopped
+	if _, ok := itemOf(vm, "QEMU"); ok {
+		t.Error("A stopped VM has no QEMU process to show")
+	}
+	if _, ok := itemOf(&models.VMStatus{VMID: "101", Type: "qemu", Status: "running"}, "QEMU"); ok {
+		t.Error("A VM whose QEMU state wasn't read has no QEMU row")
+	}
+}
+
+func TestBuildBasicDetails_DiskUsage(t *testing.T) {
+	ct := &models.VMStatus{VMID: "200", Type: "lxc", Disk: 1 << 30, MaxDisk: 8 << 30}
+	vm := &models.VMStatus{VMID: "100", Type: "qemu", MaxDisk: 32 << 30}
+
+	values := func(v *models.VMStatus) map[string]string {
+		m := make(map[string]string)
+		for _, item := range buildBasicDetails(v, "") {
+			m[item.Key] = item.Value
+		}
+		return m
+	}
+
+	if got := values(ct)["Disk Usage"]; got != "1.0 GB / 8.0 GB (12.5%)" {
+		t.Errorf("Unexpected container disk usage %q", got)
+	}
+	if got := values(vm)["Disk Usage"]; got != "-" {
+		t.Errorf("VM disk usage should be unknown, got %q", got)
+	}
+}
+
+func TestBuildFilesystemDetails(t *testing.T) {
+	fs := &FilesystemInfo{Filesystems: []models.Filesystem{
+		{Mountpoint: "/", Type: "ext4", UsedBytes: 97 << 20, TotalBytes: 100 << 20},
+		{Mountpoint: "/data", Type: "xfs", UsedBytes: 10 << 20, TotalBytes: 100 << 20},
+	}}
+
+	items := buildFilesystemDetails(fs)
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 filesystems, got %d", len(items))
+	}
+	if items[0].Key != "/" || !strings.Contains(items[0].Value, "97.0 MB / 100.0 MB (97.0%)") {
+		t.Errorf("Unexpected root entry %v", items[0])
+	}
+	if items[0].Severity != SeverityCritical || items[1].Severity != SeverityNone {
+		t.Error("Usage severity should follow the thresholds")
+	}
+
+	if got := buildFilesystemDetails(&FilesystemInfo{Loading: true})[0].Value; got != "loading..." {
+		t.Errorf("Expected loading placeholder, got %q", got)
+	}
+	if got := buildFilesystemDetails(&FilesystemInfo{Err: errors.New("timeout")})[0].Value; got != "not responding" {
+		t.Errorf("Expected error placeholder, got %q", got)
+	}
+}
+
+func TestGetGuestAgentStatus(t *testing.T) {
+	tests := []struct {
+		config   map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{}, "Not Configured"},
+		{map[string]interface{}{"agent": "1,type=isa"}, "Enabled"},
+		{map[string]interface{}{"agent": "enabled=1"}, "Enabled"},
+		{map[string]interface{}{"agent": "0"}, "Disabled"},
+	}
+
+	for _, tt := range tests {
+		if got := getGuestAgentStatus(tt.config); got != tt.expected {
+			t.Errorf("getGuestAgentStatus(%v) = %q, want %q", tt.config, got, tt.expected)
+		}
+	}
+}
+
+func TestBuildDetails_CloudInit(t *testing.T) {
+	vm := &models.VMStatus{VMID: "100", Name: "test-vm", Type: models.TypeVM}
+	config := map[string]interface{}{
+		"ide2":       "local-lvm:vm-100-cloudinit,media=cdrom",
+		"cipassword": "$5$secret",
+		"ipconfig0":  "ip=dhcp",
+		"nameserver": "10.0.0.1",
+		"sshkeys":    "ssh-rsa%20broken%0A",
+		"onboot":     1,
+	}
+
+	sections := buildDetails(vm, config, nil, false)
+
+	var titles []string
+	got := make(map[string]string)
+	for _, section := range sections {
+		titles = append(titles, section.Title)
+		for _, item := range section.Items {
+			got[section.Title+"/"+item.Key] = item.Value
+		}
+	}
+	if strings.Join(titles, ",") != ",cloud-init,resources,options" {
+		t.Fatalf("Unexpected sections: %q", titles)
+	}
+	for key, want := range map[string]string{
+		"cloud-init/user":       "image default",
+		"cloud-init/password":   "set (hidden)",
+		"cloud-init/ipconfig0":  "ip=dhcp",
+		"cloud-init/nameserver": "10.0.0.1",
+		"cloud-init/ssh keys":   "1 key",
+		"cloud-init/invalid":    "does not parse",
+	} {
+		if got[key] != want {
+			t.Errorf("%s: expected %q, got %q", key, want, got[key])
+		}
+	}
+	if _, ok := got["options/cipassword"]; ok {
+		t.Error("Cloud-init settings should leave the options")
+	}
+
+	// Container DNS settings are not cloud-init
+	ct := &models.VMStatus{VMID: "200", Name: "ct", Type: models.TypeContainer}
+	sections = buildDetails(ct, map[string]interface{}{"nameserver": "10.0.0.1"}, nil, false)
+	if last := sections[len(sections)-1]; last.Title != "options" || last.Items[0].Key != "nameserver" {
+		t.Errorf("A container keeps nameserver in its options, got %+v", sections)
+	}
+}
+
+func TestGetDetailsText_Notice(t *testing.T) {
+	vm := &models.VMStatus{VMID: "100", Name: "test-vm", Type: models.TypeVM}
+	view := GetDetailsText(vm, nil, nil, 80, 24, State{Notice: "Regenerate? (y/n)"})
+	if !strings.Contains(view, "Regenerate? (y/n)") || strings.Contains(view, "ESC=Close") {
+		t.Errorf("A notice should replace the key hints:\n%s", view)
+	}
+}
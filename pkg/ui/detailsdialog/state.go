@@ -0,0 +1,257 @@
+package detailsdialog
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// State holds the interactive state of the details dialog. The zero value
+// is ready to use; reset it whenever the dialog is opened or closed.
+type State struct {
+	Scroll    int             // First visible line
+	Cursor    int             // Highlighted line
+	Collapsed map[string]bool // Titles of folded sections
+	Searching bool            // Whether the search prompt has focus
+	Query     string          // Current search text, matched against keys
+	Raw       bool            // Show disk and NIC strings unparsed
+	Notice    string          // Replaces the key hints, e.g. with a confirmation prompt
+	NodeLoad  *NodeLoad       // CPU load of the guest's node; nil when unknown
+}
+
+// NodeLoad is the CPU load of the node a guest runs on, so that a busy
+// guest can be told from one starved by a busy host
+type NodeLoad struct {
+	CPU      float64 // Node CPU usage, 0-100
+	Cores    int     // Node CPU count
+	Pressure bool    // Both the guest and its node crossed the warning threshold
+}
+
+// sections builds the details with the node load folded in
+func (s *State) sections(vm *models.VMStatus, config map[string]interface{}, fs *FilesystemInfo) []Section {
+	return withNodeLoad(buildDetails(vm, config, fs, s.Raw), s.NodeLoad)
+}
+
+// line is one rendered row: a section header (item == -1) or a detail item
+type line struct {
+	section int
+	item    int
+}
+
+// isHeader reports whether the line is a section header
+func (l line) isHeader() bool {
+	return l.item < 0
+}
+
+// flatten lays out sections as lines, omitting the items of folded sections
+func flatten(sections []Section, collapsed map[string]bool) []line {
+	var lines []line
+	for s, section := range sections {
+		if section.Title != "" {
+			lines = append(lines, line{section: s, item: -1})
+			if collapsed[section.Title] {
+				continue
+			}
+		}
+		for i := range section.Items {
+			lines = append(lines, line{section: s, item: i})
+		}
+	}
+	return lines
+}
+
+// HandleKey applies a key press to the dialog state and reports whether
+// the dialog should close
+func (s *State) HandleKey(key string, vm *models.VMStatus, config map[string]interface{}, fs *FilesystemInfo, height int) bool {
+	sections := s.sections(vm, config, fs)
+	lines := flatten(sections, s.Collapsed)
+	s.clamp(len(lines), height)
+
+	if s.Searching {
+		s.handleSearchKey(key, sections)
+	} else {
+		switch key {
+		case "esc":
+			return true
+		case "enter":
+			if s.Cursor >= len(lines) || !lines[s.Cursor].isHeader() {
+				return true
+			}
+			s.toggle(sections[lines[s.Cursor].section].Title)
+		case "up", "k":
+			s.Cursor--
+		case "down", "j":
+			s.Cursor++
+		case "/":
+			s.Searching = true
+			s.Query = ""
+		case "r":
+			// The layout changes, so rebuild before clamping
+			s.Raw = !s.Raw
+			sections = s.sections(vm, config, fs)
+		case "n":
+			s.jump(sections, 1, false)
+		case "N":
+			s.jump(sections, -1, false)
+		}
+	}
+
+	s.clamp(len(flatten(sections, s.Collapsed)), height)
+	return false
+}
+
+// Resize keeps the cursor in view once the screen is height lines tall
+func (s *State) Resize(vm *models.VMStatus, config map[string]interface{}, fs *FilesystemInfo, height int) {
+	s.clamp(len(flatten(s.sections(vm, config, fs), s.Collapsed)), height)
+}
+
+// handleSearchKey edits the query while the search prompt has focus,
+// moving to the first match as the user types
+func (s *State) handleSearchKey(key string, sections []Section) {
+	switch key {
+	case "esc":
+		s.Searching = false
+		s.Query = ""
+		return
+	case "enter":
+		s.Searching = false
+		return
+	case "backspace":
+		if s.Query == "" {
+			return
+		}
+		_, size := utf8.DecodeLastRuneInString(s.Query)
+		s.Query = s.Query[:len(s.Query)-size]
+	case "space":
+		s.Query += " "
+	default:
+		if utf8.RuneCountInString(key) != 1 {
+			return
+		}
+		s.Query += key
+	}
+	s.jump(sections, 1, true)
+}
+
+// jump moves the cursor to the next (dir > 0) or previous match, wrapping
+// around and unfolding the section that contains it. When inclusive is set
+// the current line is considered first.
+func (s *State) jump(sections []Section, dir int, inclusive bool) {
+	if s.Query == "" {
+		return
+	}
+	all := flatten(sections, nil)
+	if len(all) == 0 {
+		return
+	}
+
+	// Locate the cursor in the fully expanded layout
+	lines := flatten(sections, s.Collapsed)
+	pos := 0
+	if s.Cursor < len(lines) {
+		pos = indexOf(all, lines[s.Cursor])
+	}
+
+	start := pos + dir
+	if inclusive {
+		start = pos
+	}
+	for n := 0; n < len(all); n++ {
+		idx := ((start+n*dir)%len(all) + len(all)) % len(all)
+		if l := all[idx]; !l.isHeader() && matches(sections[l.section].Items[l.item], s.Query) {
+			delete(s.Collapsed, sections[l.section].Title)
+			s.Cursor = indexOf(flatten(sections, s.Collapsed), l)
+			return
+		}
+	}
+}
+
+// toggle folds or unfolds a section
+func (s *State) toggle(title string) {
+	if s.Collapsed == nil {
+		s.Collapsed = make(map[string]bool)
+	}
+	if s.Collapsed[title] {
+		delete(s.Collapsed, title)
+	} else {
+		s.Collapsed[title] = true
+	}
+}
+
+// clamp keeps the cursor inside the list and the scroll offset around the cursor
+func (s *State) clamp(count, height int) {
+	visibleRows := format.FrameRows(height)
+	if visibleRows < 1 {
+		visibleRows = 1
+	}
+	if s.Cursor >= count {
+		s.Cursor = count - 1
+	}
+	if s.Cursor < 0 {
+		s.Cursor = 0
+	}
+	if s.Scroll > s.Cursor {
+		s.Scroll = s.Cursor
+	}
+	if s.Cursor >= s.Scroll+visibleRows {
+		s.Scroll = s.Cursor - visibleRows + 1
+	}
+}
+
+// indexOf returns the position of l in lines, or 0 if absent
+func indexOf(lines []line, l line) int {
+	for i, candidate := range lines {
+		if candidate == l {
+			return i
+		}
+	}
+	return 0
+}
+
+// matches reports whether the item's key contains the query, ignoring case
+func matches(item DetailItem, query string) bool {
+	start, _ := indexFold(item.Key, query)
+	return start >= 0
+}
+
+// indexFold returns the byte offsets in s of the first match of query,
+// ignoring case, or -1. Runes are compared lowered one by one, so the
+// offsets hold even where lowering changes the length of a rune, as with İ.
+func indexFold(s, query string) (int, int) {
+	if query == "" {
+		return 0, 0
+	}
+	for start := range s {
+		end, rest := start, query
+		for rest != "" && end < len(s) {
+			a, n := utf8.DecodeRuneInString(s[end:])
+			b, m := utf8.DecodeRuneInString(rest)
+			if unicode.ToLower(a) != unicode.ToLower(b) {
+				break
+			}
+			end, rest = end+n, rest[m:]
+		}
+		if rest == "" {
+			return start, end
+		}
+	}
+	return -1, -1
+}
+
+// countMatches counts the items whose key contains the query
+func countMatches(sections []Section, query string) int {
+	if query == "" {
+		return 0
+	}
+	count := 0
+	for _, section := range sections {
+		for _, item := range section.Items {
+			if matches(item, query) {
+				count++
+			}
+		}
+	}
+	return count
+}
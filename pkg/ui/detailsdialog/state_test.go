@@ -0,0 +1,187 @@
+package detailsdialog
+
+import (
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func testDetails() (*models.VMStatus, map[string]interface{}) {
+	vm := &models.VMStatus{VMID: "100", Name: "test-vm", Type: "qemu"}
+	config := map[string]interface{}{
+		"cores":  2,
+		"net0":   "virtio=AA:BB:CC:DD:EE:00,bridge=vmbr0",
+		"net1":   "virtio=AA:BB:CC:DD:EE:01,bridge=vmbr0",
+		"net3":   "virtio=AA:BB:CC:DD:EE:03,bridge=vmbr1",
+		"onboot": 1,
+	}
+	return vm, config
+}
+
+// cursorKey returns the key or header title under the cursor
+func cursorKey(s State, vm *models.VMStatus, config map[string]interface{}) string {
+	sections := buildDetails(vm, config, nil, false)
+	l := flatten(sections, s.Collapsed)[s.Cursor]
+	if l.isHeader() {
+		return "-- " + sections[l.section].Title + " --"
+	}
+	return sections[l.section].Items[l.item].Key
+}
+
+func TestState_Navigation(t *testing.T) {
+	vm, config := testDetails()
+	var s State
+
+	if s.HandleKey("up", vm, config, nil, 24) || s.Cursor != 0 {
+		t.Error("Cursor should not move above the first line")
+	}
+	s.HandleKey("j", vm, config, nil, 24)
+	if s.Cursor != 1 {
+		t.Errorf("Expected cursor 1, got %d", s.Cursor)
+	}
+	for i := 0; i < 50; i++ {
+		s.HandleKey("down", vm, config, nil, 8)
+	}
+	if want := len(flatten(buildDetails(vm, config, nil, false), nil)) - 1; s.Cursor != want {
+		t.Errorf("Cursor should stop at the last line %d, got %d", want, s.Cursor)
+	}
+	if s.Scroll == 0 {
+		t.Error("Scroll should follow the cursor")
+	}
+}
+
+func TestState_Resize(t *testing.T) {
+	vm, config := testDetails()
+	s := State{Cursor: 10}
+
+	s.Resize(vm, config, nil, 6)
+	if s.Cursor != 10 || s.Scroll != 8 {
+		t.Errorf("A shorter screen should scroll to the cursor, got cursor %d offset %d", s.Cursor, s.Scroll)
+	}
+	s.Resize(vm, config, nil, 40)
+	if s.Scroll != 8 {
+		t.Errorf("A taller screen should leave a visible cursor alone, got offset %d", s.Scroll)
+	}
+}
+
+func TestState_EnterFoldsSectionOrCloses(t *testing.T) {
+	vm, config := testDetails()
+	var s State
+
+	// Move to the network header
+	for cursorKey(s, vm, config) != "-- network --" {
+		s.HandleKey("down", vm, config, nil, 24)
+	}
+	if s.HandleKey("enter", vm, config, nil, 24) {
+		t.Fatal("Enter on a header should not close the dialog")
+	}
+	if !s.Collapsed["network"] {
+		t.Fatal("Enter on a header should fold the section")
+	}
+	s.HandleKey("enter", vm, config, nil, 24)
+	if s.Collapsed["network"] {
+		t.Error("Enter again should unfold the section")
+	}
+
+	s.HandleKey("down", vm, config, nil, 24)
+	if !s.HandleKey("enter", vm, config, nil, 24) {
+		t.Error("Enter on an item should close the dialog")
+	}
+	if !s.HandleKey("esc", vm, config, nil, 24) {
+		t.Error("ESC should close the dialog")
+	}
+}
+
+func TestState_Search(t *testing.T) {
+	vm, config := testDetails()
+	s := State{Collapsed: map[string]bool{"network": true}}
+
+	s.HandleKey("/", vm, config, nil, 24)
+	if !s.Searching {
+		t.Fatal("/ should open the search prompt")
+	}
+	for _, key := range []string{"n", "e", "t", "3"} {
+		if s.HandleKey(key, vm, config, nil, 24) {
+			t.Fatal("Typing a query should not close the dialog")
+		}
+	}
+	if s.Query != "net3" || cursorKey(s, vm, config) != "net3" {
+		t.Fatalf("Expected cursor on net3, got %q (query %q)", cursorKey(s, vm, config), s.Query)
+	}
+	if s.Collapsed["network"] {
+		t.Error("Jumping to a match should unfold its section")
+	}
+
+	s.HandleKey("backspace", vm, config, nil, 24)
+	s.HandleKey("enter", vm, config, nil, 24)
+	if s.Searching || s.Query != "net" {
+		t.Fatalf("Enter should keep the query, got searching=%v query=%q", s.Searching, s.Query)
+	}
+	if cursorKey(s, vm, config) != "net3" {
+		t.Errorf("Current match should stay selected, got %q", cursorKey(s, vm, config))
+	}
+
+	s.HandleKey("n", vm, config, nil, 24)
+	if cursorKey(s, vm, config) != "net0" {
+		t.Errorf("n should wrap to net0, got %q", cursorKey(s, vm, config))
+	}
+	s.HandleKey("N", vm, config, nil, 24)
+	if cursorKey(s, vm, config) != "net3" {
+		t.Errorf("N should wrap back to net3, got %q", cursorKey(s, vm, config))
+	}
+
+	s.HandleKey("/", vm, config, nil, 24)
+	s.HandleKey("x", vm, config, nil, 24)
+	s.HandleKey("esc", vm, config, nil, 24)
+	if s.Searching || s.Query != "" {
+		t.Error("ESC should cancel the search")
+	}
+}
+
+func TestCountMatches(t *testing.T) {
+	vm, config := testDetails()
+	sections := buildDetails(vm, config, nil, false)
+
+	if got := countMatches(sections, "NET"); got != 3 {
+		t.Errorf("Expected 3 case-insensitive matches, got %d", got)
+	}
+	if got := countMatches(sections, ""); got != 0 {
+		t.Errorf("Empty query should not match, got %d", got)
+	}
+}
+
+func TestIndexFold(t *testing.T) {
+	tests := []struct {
+		s, query   string
+		start, end int
+	}{
+		{"Net0", "NET", 0, 3},
+		{"İmage-x", "x", 7, 8}, // İ is shorter lowered as a whole string
+		{"İmage", "image", 0, 6},
+		{"Straße", "SSE", -1, -1},
+		{"cores", "", 0, 0},
+	}
+	for _, tt := range tests {
+		if start, end := indexFold(tt.s, tt.query); start != tt.start || end != tt.end {
+			t.Errorf("indexFold(%q, %q) = %d, %d, expected %d, %d", tt.s, tt.query, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestState_RawToggle(t *testing.T) {
+	vm, config := testDetails()
+	var s State
+
+	parsed := len(flatten(buildDetails(vm, config, nil, false), nil))
+	s.HandleKey("r", vm, config, nil, 24)
+	if !s.Raw {
+		t.Fatal("r should switch to raw mode")
+	}
+	if raw := len(flatten(buildDetails(vm, config, nil, true), nil)); raw >= parsed {
+		t.Errorf("Raw mode should be more compact: %d vs %d lines", raw, parsed)
+	}
+	s.HandleKey("r", vm, config, nil, 24)
+	if s.Raw {
+		t.Error("r again should switch back")
+	}
+}
@@ -0,0 +1,41 @@
+package eventlog
+
+import (
+	"fmt"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// MaxEvents is the number of state change events kept in a session
+const MaxEvents = 200
+
+// Append adds events to the log, dropping the oldest beyond MaxEvents
+func Append(events []models.StateChange, added ...models.StateChange) []models.StateChange {
+	events = append(events, added...)
+	if len(events) > MaxEvents {
+		events = append([]models.StateChange(nil), events[len(events)-MaxEvents:]...)
+	}
+	return events
+}
+
+// GetEventsText generates the scrollable state change event list, newest first
+func GetEventsText(events []models.StateChange, width, height, scrollOffset int) string {
+	rows := make([]string, 0, len(events))
+	if len(events) == 0 {
+		rows = append(rows, "  No state changes observed yet")
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		// Newest event on top
+		rows = append(rows, "  "+format.Text(events[i].String()))
+	}
+
+	title := fmt.Sprintf("State Changes (%d)", len(events))
+	status := format.Text(" ↑↓/jk=Scroll  x=Clear  ESC/Enter=Close")
+	return format.FrameAt(title, rows, status, width, height, scrollOffset)
+}
+
+// ClampScroll limits a scroll offset to the range that keeps the last page full
+func ClampScroll(scrollOffset, count, height int) int {
+	return format.ClampOffset(scrollOffset, count, format.FrameRows(height))
+}
@@ -0,0 +1,84 @@
+package eventlog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func makeEvents(n int) []models.StateChange {
+	events := make([]models.StateChange, n)
+	for i := range events {
+		events[i] = models.StateChange{
+			Time:     time.Date(2025, 1, 1, 14, 0, 0, 0, time.UTC),
+			VMID:     fmt.Sprintf("%d", 100+i),
+			Name:     fmt.Sprintf("guest-%d", i),
+			Type:     "qemu",
+			OldState: "running",
+			NewState: "stopped",
+		}
+	}
+	return events
+}
+
+func TestAppend_Caps(t *testing.T) {
+	events := Append(nil, makeEvents(MaxEvents+5)...)
+
+	if len(events) != MaxEvents {
+		t.Fatalf("Expected %d events, got %d", MaxEvents, len(events))
+	}
+	if events[0].VMID != "105" {
+		t.Errorf("Oldest events should be dropped first, got %s", events[0].VMID)
+	}
+}
+
+func TestGetEventsText(t *testing.T) {
+	result := GetEventsText(makeEvents(3), 80, 24, 0)
+
+	if !strings.Contains(result, "State Changes (3)") {
+		t.Error("Missing title with count")
+	}
+	if !strings.Contains(result, "14:00 vm 102 guest-2 running → stopped") {
+		t.Error("Missing event line")
+	}
+	if strings.Index(result, "guest-2") > strings.Index(result, "guest-0") {
+		t.Error("Newest event should be listed first")
+	}
+	if lines := strings.Count(result, "\n") + 1; lines != 24 {
+		t.Errorf("Expected 24 lines, got %d", lines)
+	}
+}
+
+func TestGetEventsText_Empty(t *testing.T) {
+	result := GetEventsText(nil, 80, 24, 0)
+
+	if !strings.Contains(result, "No state changes observed yet") {
+		t.Error("Missing empty state message")
+	}
+}
+
+func TestClampScroll(t *testing.T) {
+	tests := []struct {
+		name     string
+		offset   int
+		count    int
+		height   int
+		expected int
+	}{
+		{"Within range", 5, 100, 24, 5},
+		{"Past end", 200, 100, 24, 79},
+		{"Negative", -3, 100, 24, 0},
+		{"Fewer than a page", 5, 10, 24, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClampScroll(tt.offset, tt.count, tt.height); got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
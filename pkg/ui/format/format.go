@@ -0,0 +1,99 @@
+// Package format holds rendering helpers shared by the UI screens
+package format
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+const (
+	// SeparatorRune is the box-drawing character used for horizontal rules
+	SeparatorRune = '─'
+	// ASCIISeparatorRune replaces SeparatorRune when unicode is disabled
+	ASCIISeparatorRune = '-'
+)
+
+// asciiOnly is set when the terminal can't be trusted with non-ASCII glyphs
+var asciiOnly atomic.Bool
+
+// noColor is set when every view must render without ANSI styling
+var noColor atomic.Bool
+
+var (
+	profileMu       sync.Mutex
+	detectedProfile *termenv.Profile // Profile in effect before color was disabled
+)
+
+// asciiReplacer maps every non-ASCII glyph the UI draws to a plain fallback
+var asciiReplacer = strings.NewReplacer(
+	string(SeparatorRune), string(ASCIISeparatorRune),
+	"↑", "^",
+	"↓", "v",
+	"←", "<-",
+	"→", "->",
+	"—", "-",
+	"❚", "|",
+	"🔒", "#",
+	"≡", "=",
+	"≠", "~",
+	"…", ".",
+)
+
+// SetUnicode switches between box-drawing glyphs (the default) and ASCII
+func SetUnicode(enabled bool) {
+	asciiOnly.Store(!enabled)
+}
+
+// Unicode reports whether non-ASCII glyphs are rendered
+func Unicode() bool {
+	return !asciiOnly.Load()
+}
+
+// SetColor turns colors and all other ANSI styling (bold, reverse video)
+// on or off for every view by switching the lipgloss color profile.
+// Re-enabling restores the profile that was detected for the terminal.
+func SetColor(enabled bool) {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+
+	noColor.Store(!enabled)
+	if !enabled {
+		if detectedProfile == nil {
+			profile := lipgloss.ColorProfile()
+			detectedProfile = &profile
+		}
+		lipgloss.SetColorProfile(termenv.Ascii)
+		return
+	}
+	if detectedProfile != nil {
+		lipgloss.SetColorProfile(*detectedProfile)
+		detectedProfile = nil
+	}
+}
+
+// Color reports whether views are styled; when it is false they must use
+// text markers for anything otherwise conveyed by color or reverse video
+func Color() bool {
+	return !noColor.Load()
+}
+
+// Separator returns a horizontal rule of the given width
+func Separator(width int) string {
+	if asciiOnly.Load() {
+		return Repeat(string(ASCIISeparatorRune), width)
+	}
+	return Repeat(string(SeparatorRune), width)
+}
+
+// Text returns s unchanged, or with arrows, dashes and rules replaced by
+// ASCII equivalents when unicode is disabled
+func Text(s string) string {
+	if asciiOnly.Load() {
+		return asciiReplacer.Replace(s)
+	}
+	return s
+}
@@ -0,0 +1,104 @@
+package format
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+func TestSeparator(t *testing.T) {
+	defer SetUnicode(true)
+
+	tests := []struct {
+		name    string
+		unicode bool
+		want    rune
+	}{
+		{"unicode", true, SeparatorRune},
+		{"ascii", false, ASCIISeparatorRune},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetUnicode(tt.unicode)
+			sep := Separator(80)
+
+			if !utf8.ValidString(sep) {
+				t.Fatalf("Separator(80) is not valid UTF-8: %q", sep)
+			}
+			if got := utf8.RuneCountInString(sep); got != 80 {
+				t.Errorf("Separator(80) has %d runes, want 80", got)
+			}
+			for i, r := range sep {
+				if r != tt.want {
+					t.Fatalf("Separator(80) has %q at byte %d, want only %q", r, i, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSeparator_NonPositiveWidth(t *testing.T) {
+	if got := Separator(0); got != "" {
+		t.Errorf("Separator(0) = %q, want empty", got)
+	}
+	if got := Separator(-5); got != "" {
+		t.Errorf("Separator(-5) = %q, want empty", got)
+	}
+}
+
+func TestText(t *testing.T) {
+	defer SetUnicode(true)
+
+	input := " ↑↓/jk=Move — running → stopped ──"
+	if got := Text(input); got != input {
+		t.Errorf("Text() with unicode = %q, want unchanged", got)
+	}
+
+	SetUnicode(false)
+	if Unicode() {
+		t.Error("Unicode() = true after SetUnicode(false)")
+	}
+	got := Text(input)
+	if want := " ^v/jk=Move - running -> stopped --"; got != want {
+		t.Errorf("Text() with ascii = %q, want %q", got, want)
+	}
+	for _, r := range got {
+		if r >= utf8.RuneSelf {
+			t.Errorf("Text() with ascii kept non-ASCII rune %q", r)
+		}
+	}
+	if strings.ContainsRune(got, SeparatorRune) {
+		t.Error("Text() with ascii kept the separator rune")
+	}
+}
+
+func TestSetColor(t *testing.T) {
+	original := lipgloss.ColorProfile()
+	defer lipgloss.SetColorProfile(original)
+
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true).Reverse(true)
+	if !strings.Contains(style.Render("x"), "\x1b[") {
+		t.Fatal("expected ANSI sequences with a true color profile")
+	}
+
+	SetColor(false)
+	if Color() {
+		t.Error("Color() = true after SetColor(false)")
+	}
+	if got := style.Render("x"); got != "x" {
+		t.Errorf("Render() without color = %q, want plain text", got)
+	}
+
+	SetColor(true)
+	if !Color() {
+		t.Error("Color() = false after SetColor(true)")
+	}
+	if lipgloss.ColorProfile() != termenv.TrueColor {
+		t.Errorf("SetColor(true) did not restore the detected profile, got %v", lipgloss.ColorProfile())
+	}
+}
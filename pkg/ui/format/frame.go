@@ -0,0 +1,70 @@
+package format
+
+// frameChrome is the number of lines a frame draws around its body:
+// title, separator and status bar
+const frameChrome = 3
+
+// FrameRows returns how many body lines fit in a frame of the given height
+func FrameRows(height int) int {
+	if rows := height - frameChrome; rows > 0 {
+		return rows
+	}
+	return 0
+}
+
+// Frame renders a full-screen dialog: the title and a separator, the body,
+// blank lines to fill the screen and the status bar on the last line. A
+// body taller than the screen is cut; use FrameAt to scroll it.
+func Frame(title string, body []string, status string, width, height int) string {
+	return FrameAt(title, body, status, width, height, 0)
+}
+
+// FrameAt is Frame with the body scrolled down by offset lines. The offset
+// is clamped so the last page stays full.
+func FrameAt(title string, body []string, status string, width, height, offset int) string {
+	if height > 0 {
+		offset = ClampOffset(offset, len(body), FrameRows(height))
+		body = body[offset:]
+	}
+	return Screen(TitleBar(title, width), body, StatusStyle().Render(status), height)
+}
+
+// FrameCentered renders a frame whose body is centered on screen, for
+// short messages such as "Loading..."
+func FrameCentered(title string, body []string, status string, width, height int) string {
+	var centered []string
+	for i := 0; i < (FrameRows(height)-len(body))/2; i++ {
+		centered = append(centered, "")
+	}
+	for _, line := range body {
+		centered = append(centered, Center(line, width))
+	}
+	return Frame(title, centered, status, width, height)
+}
+
+// ClampOffset limits a scroll offset to the range that keeps the last page
+// of count lines full when rows of them are visible
+func ClampOffset(offset, count, rows int) int {
+	if maxOffset := count - rows; offset > maxOffset {
+		offset = maxOffset
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return offset
+}
+
+// OffsetFor returns the scroll offset closest to offset that keeps line
+// visible when rows lines fit on screen
+func OffsetFor(line, offset, rows int) int {
+	if rows <= 0 {
+		return line
+	}
+	if line < offset {
+		return line
+	}
+	if line >= offset+rows {
+		return line - rows + 1
+	}
+	return offset
+}
@@ -0,0 +1,76 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFrame(t *testing.T) {
+	got := Frame("Title", []string{"a", "b"}, "status", 5, 6)
+	lines := strings.Split(got, "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Frame() has %d lines, want 6:\n%s", len(lines), got)
+	}
+	if lines[0] != "Title" || lines[1] != Separator(5) || lines[2] != "a" || lines[3] != "b" {
+		t.Errorf("Frame() top lines = %q", lines[:4])
+	}
+	if lines[4] != "" || lines[5] != "status" {
+		t.Errorf("Frame() should fill the gap and end with the status bar, got %q", lines[4:])
+	}
+}
+
+func TestFrameAt(t *testing.T) {
+	body := []string{"0", "1", "2", "3", "4", "5"}
+
+	tests := []struct {
+		name   string
+		offset int
+		want   []string
+	}{
+		{"top", 0, []string{"0", "1", "2"}},
+		{"middle", 2, []string{"2", "3", "4"}},
+		{"past the end is clamped", 10, []string{"3", "4", "5"}},
+		{"negative is clamped", -3, []string{"0", "1", "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := strings.Split(FrameAt("T", body, "S", 10, 6, tt.offset), "\n")
+			if got := lines[2:5]; strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("FrameAt(offset=%d) body = %q, want %q", tt.offset, got, tt.want)
+			}
+			if lines[len(lines)-1] != "S" {
+				t.Errorf("FrameAt() last line = %q, want the status bar", lines[len(lines)-1])
+			}
+		})
+	}
+}
+
+func TestFrameCentered(t *testing.T) {
+	lines := strings.Split(FrameCentered("T", []string{"wait"}, "S", 12, 9), "\n")
+	if len(lines) != 9 {
+		t.Fatalf("FrameCentered() has %d lines, want 9", len(lines))
+	}
+	// 6 body rows: the message goes on the third, indented by (12-4)/2
+	if lines[4] != "    wait" {
+		t.Errorf("FrameCentered() lines = %q", lines)
+	}
+}
+
+func TestOffsetFor(t *testing.T) {
+	tests := []struct {
+		line, offset, rows, expected int
+	}{
+		{2, 0, 5, 0}, // already visible
+		{7, 0, 5, 3}, // below: scroll just enough
+		{1, 4, 5, 1}, // above: scroll up to it
+		{4, 4, 5, 4}, // first visible line
+		{3, 0, 0, 3}, // nothing fits
+	}
+
+	for _, tt := range tests {
+		if got := OffsetFor(tt.line, tt.offset, tt.rows); got != tt.expected {
+			t.Errorf("OffsetFor(%d, %d, %d) = %d, want %d", tt.line, tt.offset, tt.rows, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// accent is the color of titles, separators and status bars
+var accent = lipgloss.Color("#008000")
+
+// TitleStyle is used for screen titles and column headers
+func TitleStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(accent).Bold(true)
+}
+
+// SeparatorStyle is used for horizontal rules
+func SeparatorStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(accent)
+}
+
+// StatusStyle is used for the key hints on the last line
+func StatusStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(accent).Bold(true)
+}
+
+// TitleBar returns the styled title and separator lines that open a screen
+func TitleBar(title string, width int) []string {
+	return []string{
+		TitleStyle().Render(title),
+		SeparatorStyle().Render(Separator(width)),
+	}
+}
+
+// Screen lays out a full-screen view: the header lines, then the body
+// padded with blank lines or cut so the status bar lands on the last of
+// height lines. Lines must not contain newlines. A height of zero or less
+// (before the first resize) renders everything unpadded.
+func Screen(header, body []string, status string, height int) string {
+	lines := append([]string(nil), header...)
+	if height > 0 {
+		rows := height - len(header) - 1
+		if rows < 0 {
+			rows = 0
+		}
+		if len(body) > rows {
+			body = body[:rows]
+		}
+		lines = append(lines, body...)
+		for i := len(body); i < rows; i++ {
+			lines = append(lines, "")
+		}
+	} else {
+		lines = append(lines, body...)
+	}
+	lines = append(lines, status)
+	return FitHeight(strings.Join(lines, "\n"), height)
+}
+
+// FitHeight trims a full-screen view to at most height lines, keeping the
+// last line (the status bar) so it stays visible when the body overflows
+func FitHeight(view string, height int) string {
+	if height <= 0 {
+		return view
+	}
+	lines := strings.Split(view, "\n")
+	if len(lines) <= height {
+		return view
+	}
+	kept := append(lines[:height-1:height-1], lines[len(lines)-1])
+	return strings.Join(kept, "\n")
+}
+
+// FitWidth cuts the lines of a full-screen view to at most width cells,
+// keeping their styling, as Bubble Tea does when it draws them; a width of
+// zero or less (before the first resize) leaves the view alone
+func FitWidth(view string, width int) string {
+	if width <= 0 {
+		return view
+	}
+	lines := strings.Split(view, "\n")
+	for i, line := range lines {
+		if lipgloss.Width(line) > width {
+			lines[i] = ansi.Truncate(line, width, "")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MinWidth and MinHeight are the smallest terminal the screens are laid
+// out for; below it TooSmall is shown instead
+const (
+	MinWidth  = 40
+	MinHeight = 10
+)
+
+// IsTooSmall reports whether a terminal of width by height is below the
+// supported size. Zero sizes, before the first resize, are not.
+func IsTooSmall(width, height int) bool {
+	return width > 0 && height > 0 && (width < MinWidth || height < MinHeight)
+}
+
+// TooSmall renders the screen shown on a terminal below the supported
+// size: a notice in the middle, each line cut to the width
+func TooSmall(width, height int) string {
+	notice := []string{"Terminal too small", fmt.Sprintf("(need %dx%d)", MinWidth, MinHeight)}
+	var lines []string
+	for i := 0; i < (height-len(notice))/2; i++ {
+		lines = append(lines, "")
+	}
+	for _, line := range notice {
+		lines = append(lines, Center(Truncate(line, width), width))
+	}
+	return FitHeight(strings.Join(lines, "\n"), height)
+}
+
+// Repeat returns n copies of s, none when n is negative, so a layout
+// computed for a terminal too narrow for it can't panic
+func Repeat(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat(s, n)
+}
+
+// ellipsis marks truncated text
+const ellipsis = "..."
+
+// Truncate shortens plain text to at most width terminal cells, ending it
+// with "..." when something was cut. Wide characters count as two cells.
+func Truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	if width <= len(ellipsis) {
+		return ellipsis[:width]
+	}
+
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := lipgloss.Width(string(r))
+		if used+w > width-len(ellipsis) {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	return b.String() + ellipsis
+}
+
+// Pad right-pads s with spaces to width terminal cells; unlike %-*s it
+// accounts for wide characters and ANSI styling
+func Pad(s string, width int) string {
+	return s + Repeat(" ", width-lipgloss.Width(s))
+}
+
+// Center left-pads s so that it sits in the middle of width cells
+func Center(s string, width int) string {
+	return Repeat(" ", (width-lipgloss.Width(s))/2) + s
+}
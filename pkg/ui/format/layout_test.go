@@ -0,0 +1,179 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		width    int
+		expected string
+	}{
+		{"Short string", "hello", 10, "hello"},
+		{"Exact length", "hello", 5, "hello"},
+		{"Long string", "hello world", 8, "hello..."},
+		{"Very short max", "hello", 3, "..."},
+		{"Narrower than ellipsis", "hello", 2, ".."},
+		{"Zero width", "hello", 0, ""},
+		{"Multi-byte runes", "résumé-server", 8, "résum..."},
+		{"Wide runes", "日本語サーバー", 7, "日本..."},
+		{"Wide rune straddling the cut", "日本語", 5, "日..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Truncate(tt.input, tt.width); got != tt.expected {
+				t.Errorf("Truncate(%q, %d) = %q, want %q", tt.input, tt.width, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPad(t *testing.T) {
+	tests := []struct {
+		input    string
+		width    int
+		expected string
+	}{
+		{"web", 6, "web   "},
+		{"résumé", 8, "résumé  "},
+		{"日本", 6, "日本  "},
+		{"too long", 3, "too long"},
+	}
+
+	for _, tt := range tests {
+		if got := Pad(tt.input, tt.width); got != tt.expected {
+			t.Errorf("Pad(%q, %d) = %q, want %q", tt.input, tt.width, got, tt.expected)
+		}
+	}
+}
+
+func TestCenter(t *testing.T) {
+	if got := Center("abcd", 10); got != "   abcd" {
+		t.Errorf("Center() = %q, want 3 spaces of padding", got)
+	}
+	if got := Center("日本", 8); got != "  日本" {
+		t.Errorf("Center() = %q, want wide runes counted as two cells", got)
+	}
+	if got := Center("too wide", 4); got != "too wide" {
+		t.Errorf("Center() = %q, want input unchanged", got)
+	}
+}
+
+func TestScreen(t *testing.T) {
+	header := []string{"Title", "-----"}
+
+	t.Run("pads the body", func(t *testing.T) {
+		got := Screen(header, []string{"row"}, "status", 6)
+		if want := "Title\n-----\nrow\n\n\nstatus"; got != want {
+			t.Errorf("Screen() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("cuts the body", func(t *testing.T) {
+		got := Screen(header, []string{"a", "b", "c", "d"}, "status", 5)
+		if want := "Title\n-----\na\nb\nstatus"; got != want {
+			t.Errorf("Screen() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("header taller than the screen", func(t *testing.T) {
+		got := Screen([]string{"1", "2", "3", "4"}, []string{"row"}, "status", 3)
+		if want := "1\n2\nstatus"; got != want {
+			t.Errorf("Screen() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("unknown height", func(t *testing.T) {
+		got := Screen(header, []string{"a", "b"}, "status", 0)
+		if want := "Title\n-----\na\nb\nstatus"; got != want {
+			t.Errorf("Screen() = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestFitHeight(t *testing.T) {
+	view := strings.Join([]string{"title", "a", "b", "c", "status"}, "\n")
+
+	if got := FitHeight(view, 10); got != view {
+		t.Errorf("FitHeight() changed a view that fits: %q", got)
+	}
+	if got := FitHeight(view, 0); got != view {
+		t.Errorf("FitHeight() with unknown height changed the view: %q", got)
+	}
+	if got, want := FitHeight(view, 3), "title\na\nstatus"; got != want {
+		t.Errorf("FitHeight() = %q, want %q", got, want)
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	view := "short\n日本語サーバー\n\x1b[1mbold text\x1b[0m"
+
+	if got := FitWidth(view, 20); got != view {
+		t.Errorf("FitWidth() changed a view that fits: %q", got)
+	}
+	if got := FitWidth(view, 0); got != view {
+		t.Errorf("FitWidth() with unknown width changed the view: %q", got)
+	}
+	got := FitWidth(view, 4)
+	for _, line := range strings.Split(got, "\n") {
+		if w := lipgloss.Width(line); w > 4 {
+			t.Errorf("FitWidth() left a line of %d cells: %q", w, line)
+		}
+	}
+	if !strings.Contains(got, "\x1b[1mbold") {
+		t.Errorf("FitWidth() should keep the styling: %q", got)
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	if got := Repeat("-", 3); got != "---" {
+		t.Errorf("Repeat() = %q, want ---", got)
+	}
+	for _, n := range []int{0, -5} {
+		if got := Repeat("-", n); got != "" {
+			t.Errorf("Repeat(%d) = %q, want nothing", n, got)
+		}
+	}
+	if got := Pad("too long", -1); got != "too long" {
+		t.Errorf("Pad() with a negative width = %q, want input unchanged", got)
+	}
+}
+
+func TestTooSmall(t *testing.T) {
+	tests := []struct {
+		width, height int
+		tooSmall      bool
+	}{
+		{0, 0, false},
+		{MinWidth, MinHeight, false},
+		{MinWidth - 1, MinHeight, true},
+		{MinWidth, MinHeight - 1, true},
+		{1, 1, true},
+	}
+	for _, tt := range tests {
+		if got := IsTooSmall(tt.width, tt.height); got != tt.tooSmall {
+			t.Errorf("IsTooSmall(%d, %d) = %v, want %v", tt.width, tt.height, got, tt.tooSmall)
+		}
+	}
+
+	for _, size := range []struct{ width, height int }{{30, 8}, {5, 2}, {1, 1}} {
+		lines := strings.Split(TooSmall(size.width, size.height), "\n")
+		if len(lines) > size.height {
+			t.Errorf("TooSmall(%d, %d) has %d lines", size.width, size.height, len(lines))
+		}
+		for _, line := range lines {
+			if lipgloss.Width(line) > size.width {
+				t.Errorf("TooSmall(%d, %d) has a line of %d cells", size.width, size.height, lipgloss.Width(line))
+			}
+		}
+	}
+	if got := TooSmall(30, 8); !strings.Contains(got, "Terminal too small") || !strings.Contains(got, "(need 40x10)") {
+		t.Errorf("TooSmall() should say what size is needed:\n%s", got)
+	}
+}
@@ -0,0 +1,61 @@
+package format
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// asciiBorder replaces the box-drawing border when unicode is disabled
+var asciiBorder = lipgloss.Border{
+	Top: "-", Bottom: "-", Left: "|", Right: "|",
+	TopLeft: "+", TopRight: "+", BottomLeft: "+", BottomRight: "+",
+}
+
+// Box draws lines in a border of the accent color, with title set in its
+// top edge, and returns the box line by line. The box is as wide as its
+// widest line or title.
+func Box(title string, lines []string) []string {
+	border := lipgloss.NormalBorder()
+	if !Unicode() {
+		border = asciiBorder
+	}
+	inner := lipgloss.Width(title) + 2
+	for _, line := range lines {
+		inner = max(inner, lipgloss.Width(line))
+	}
+
+	style := SeparatorStyle()
+	top := border.Top + TitleStyle().Render(title) + style.Render(Repeat(border.Top, inner-lipgloss.Width(title)-1))
+	if title == "" {
+		top = style.Render(Repeat(border.Top, inner))
+	}
+	box := []string{style.Render(border.TopLeft) + top + style.Render(border.TopRight)}
+	for _, line := range lines {
+		box = append(box, style.Render(border.Left)+Pad(line, inner)+style.Render(border.Right))
+	}
+	box = append(box, style.Render(border.BottomLeft+Repeat(border.Bottom, inner)+border.BottomRight))
+	return box
+}
+
+// Overlay draws box over view with its top-left corner at column x and
+// line y, keeping what view shows around it. Box lines falling past the
+// end of view are dropped; view lines shorter than x are padded.
+func Overlay(view string, box []string, x, y int) string {
+	lines := strings.Split(view, "\n")
+	for i, boxLine := range box {
+		row := y + i
+		if row < 0 || row >= len(lines) {
+			continue
+		}
+		line := lines[row]
+		left := ansi.Truncate(line, x, "")
+		if strings.Contains(left, "\x1b") {
+			left += ansi.ResetStyle // Don't let a style cut in half bleed into the box
+		}
+		right := ansi.TruncateLeft(line, x+lipgloss.Width(boxLine), "")
+		lines[row] = Pad(left, x) + boxLine + right
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,63 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestBox(t *testing.T) {
+	SetColor(false)
+	defer SetColor(true)
+
+	got := strings.Join(Box("Keys", []string{"s Start", "q Quit"}), "\n")
+	want := "┌─Keys──┐\n" +
+		"│s Start│\n" +
+		"│q Quit │\n" +
+		"└───────┘"
+	if got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	SetUnicode(false)
+	defer SetUnicode(true)
+	got = strings.Join(Box("", []string{"ab"}), "\n")
+	if want := "+--+\n|ab|\n+--+"; got != want {
+		t.Errorf("Expected the ASCII border\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	view := "0123456789\nabcdefghij\nab\nABCDEFGHIJ"
+	got := Overlay(view, []string{"[##]", "[##]", "[##]", "[##]"}, 3, 1)
+	want := "0123456789\nabc[##]hij\nab [##]\nABC[##]HIJ"
+	if got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	// A styled line keeps its width, and its style doesn't reach the box
+	styled := lipgloss.NewStyle().Reverse(true).Render("0123456789")
+	got = Overlay(styled, []string{"##"}, 4, 0)
+	if w := lipgloss.Width(got); w != 10 {
+		t.Errorf("Expected the line to keep 10 cells, got %d: %q", w, got)
+	}
+	if plain := ansiStrip(got); plain != "0123##6789" {
+		t.Errorf("Expected the box spliced in, got %q", plain)
+	}
+}
+
+// ansiStrip removes the styling of s
+func ansiStrip(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\x1b' {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
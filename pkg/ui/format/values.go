@@ -0,0 +1,85 @@
+package format
+
+import (
+	"fmt"
+	"math"
+)
+
+// Unknown is displayed for values the API did not report
+const Unknown = "-"
+
+// Suspect marks a value that can't be right, such as a negative or
+// infinite percentage; it is shown clamped with this suffix, or alone
+const Suspect = "?"
+
+// MaxUptime is the longest uptime taken at face value. Longer ones come
+// from broken reports, like a guest agent returning 2^31-1 seconds.
+const MaxUptime = 20 * 365 * 86400
+
+// Usage thresholds (percent) above which a value is shown as a warning or
+// as critical
+const (
+	UsageWarning  = 80.0
+	UsageCritical = 95.0
+)
+
+// Bytes renders a byte count with binary units, e.g. "1.5 GB"
+func Bytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	units := []string{"KB", "MB", "GB", "TB", "PB", "EB"}
+	if exp >= len(units) {
+		exp = len(units) - 1 // Prevent index out of bounds
+	}
+	return fmt.Sprintf("%.1f %s", float64(bytes)/float64(div), units[exp])
+}
+
+// Uptime renders seconds as its two most significant units ("1y 12d",
+// "2d 5h", "3h 30m", "45m"), or Unknown when the guest is not up. It never
+// takes more than 8 columns: uptimes beyond MaxUptime render as ">20y".
+func Uptime(seconds int64) string {
+	if seconds <= 0 {
+		return Unknown
+	}
+	if seconds > MaxUptime {
+		return ">20y"
+	}
+
+	days := seconds / 86400
+	hours := (seconds % 86400) / 3600
+	minutes := (seconds % 3600) / 60
+
+	if days >= 365 {
+		return fmt.Sprintf("%dy %dd", days/365, days%365)
+	}
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+	if hours > 0 {
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// Percent renders a usage percentage with the given decimals. Values
+// outside 0-100 are clamped and marked Suspect ("100%?", "0.0%?"), and NaN
+// or infinite ones render as Suspect, so a broken report can't widen its
+// column.
+func Percent(value float64, decimals int) string {
+	switch {
+	case math.IsNaN(value) || math.IsInf(value, 0):
+		return Suspect
+	case value < 0:
+		return fmt.Sprintf("%.*f%%", decimals, 0.0) + Suspect
+	case value > 100:
+		return "100%" + Suspect
+	}
+	return fmt.Sprintf("%.*f%%", decimals, value)
+}
@@ -0,0 +1,87 @@
+package format
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{3 << 29, "1.5 GB"},
+		{8 << 30, "8.0 GB"},
+		{1 << 40, "1.0 TB"},
+		{1<<63 - 1, "8.0 EB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := Bytes(tt.bytes); got != tt.expected {
+				t.Errorf("Bytes(%d) = %q, want %q", tt.bytes, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUptime(t *testing.T) {
+	tests := []struct {
+		name     string
+		seconds  int64
+		expected string
+	}{
+		{"Zero uptime", 0, "-"},
+		{"Negative uptime", -5, "-"},
+		{"Under a minute", 30, "0m"},
+		{"Less than hour", 45 * 60, "45m"},
+		{"Hours and minutes", 3*3600 + 30*60, "3h 30m"},
+		{"Days and hours", 2*86400 + 5*3600, "2d 5h"},
+		{"Only days", 3 * 86400, "3d 0h"},
+		{"Years and days", 400 * 86400, "1y 35d"},
+		{"Longest believable", MaxUptime, "20y 0d"},
+		{"Broken report", 2147483647, ">20y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Uptime(tt.seconds); got != tt.expected {
+				t.Errorf("Uptime(%d) = %q, want %q", tt.seconds, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		decimals int
+		expected string
+	}{
+		{"Zero", 0, 1, "0.0%"},
+		{"Typical", 42.3, 1, "42.3%"},
+		{"Full", 100, 1, "100.0%"},
+		{"No decimals", 55.6, 0, "56%"},
+		{"Negative", -1, 1, "0.0%?"},
+		{"Over 100", 400, 1, "100%?"},
+		{"Over 100 without decimals", 250, 0, "100%?"},
+		{"NaN", math.NaN(), 1, "?"},
+		{"Infinite", math.Inf(1), 0, "?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Percent(tt.value, tt.decimals)
+			if got != tt.expected {
+				t.Errorf("Percent(%v, %d) = %q, want %q", tt.value, tt.decimals, got, tt.expected)
+			}
+			if len(got) > 6 {
+				t.Errorf("Percent(%v, %d) is %d columns wide", tt.value, tt.decimals, len(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Package hamenu is the HA submenu of a guest: whether HA manages it, in
+// which group and in which requested state, with the changes that can be
+// made. A change is only sent once confirmed.
+package hamenu
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Kind is what a change does to the guest
+type Kind int
+
+const (
+	// Add puts the guest under HA
+	Add Kind = iota
+	// Request asks HA to keep the guest in a state
+	Request
+	// Remove takes the guest out of HA
+	Remove
+)
+
+// requestStates are the states HA can be asked to keep a guest in
+var requestStates = []string{"started", "stopped", "disabled"}
+
+// Change is one entry of the menu
+type Change struct {
+	Kind  Kind
+	State string // Requested state, for Request
+	Group string // HA group, for Add; "" for none
+}
+
+// String describes the change as a menu entry
+func (c Change) String() string {
+	switch c.Kind {
+	case Request:
+		return "Request " + c.State
+	case Remove:
+		return "Remove from HA"
+	}
+	if c.Group != "" {
+		return "Put under HA in group " + c.Group
+	}
+	return "Put under HA"
+}
+
+// State is the menu of one guest
+type State struct {
+	Guest      string   // Name and VMID, for the title
+	Managed    bool     // HA manages the guest
+	Group      string   // Current HA group; "" for none
+	HAState    string   // Current requested state, while managed
+	Groups     []string // Groups to pick from when adding
+	Loading    bool     // The groups are being listed
+	GroupsErr  error    // Why the groups couldn't be listed; adding without one still works
+	NoGroups   bool     // The server has no HA groups, so adding asks for none
+	Cursor     int      // Highlighted change
+	Picking    bool     // The group picker is open
+	Pick       int      // Highlighted group; 0 is no group
+	Confirming bool     // Waiting for y to send Chosen
+	Chosen     Change
+	Sending    bool // The change is in flight
+	Done       bool // The change returned; any key closes the menu
+	Err        error
+	Blocked    error // Why no change can be made now; any key closes the menu
+}
+
+// New opens the menu of a guest; group and haState are its current HA
+// settings when managed is set
+func New(guest string, managed bool, group, haState string) State {
+	return State{Guest: guest, Managed: managed, Group: group, HAState: haState, Loading: !managed}
+}
+
+// Changes lists what can be done with the guest: put it under HA when it
+// isn't, otherwise request another state or remove it
+func (s State) Changes() []Change {
+	if !s.Managed {
+		return []Change{{Kind: Add}}
+	}
+	var changes []Change
+	for _, state := range requestStates {
+		if state != s.HAState {
+			changes = append(changes, Change{Kind: Request, State: state})
+		}
+	}
+	return append(changes, Change{Kind: Remove})
+}
+
+// HandleKey updates the menu for a key press
+func (s *State) HandleKey(key string) screen.Outcome {
+	switch {
+	case s.Done, s.Blocked != nil:
+		return screen.Closed
+	case s.Sending:
+		return screen.Pending
+	case s.Confirming:
+		switch key {
+		case "y", "Y":
+			s.Confirming = false
+			return screen.Confirmed
+		case "n", "N", "esc":
+			s.Confirming = false
+		}
+		return screen.Pending
+	case s.Picking:
+		return s.handlePickerKey(key)
+	}
+
+	changes := s.Changes()
+	switch key {
+	case "esc", "q", "A":
+		return screen.Closed
+	case "up", "k":
+		s.Cursor = max(s.Cursor-1, 0)
+	case "down", "j":
+		s.Cursor = min(s.Cursor+1, len(changes)-1)
+	case "enter":
+		s.Chosen = changes[s.Cursor]
+		if s.Chosen.Kind == Add && !s.NoGroups {
+			s.Picking, s.Pick = true, 0
+		} else {
+			s.Confirming = true
+		}
+	}
+	return screen.Pending
+}
+
+// handlePickerKey moves through the groups, confirming the one picked
+func (s *State) handlePickerKey(key string) screen.Outcome {
+	switch key {
+	case "esc":
+		s.Picking = false
+	case "up", "k":
+		s.Pick = max(s.Pick-1, 0)
+	case "down", "j":
+		s.Pick = min(s.Pick+1, len(s.Groups))
+	case "enter":
+		s.Chosen = Change{Kind: Add}
+		if s.Pick > 0 {
+			s.Chosen.Group = s.Groups[s.Pick-1]
+		}
+		s.Picking, s.Confirming = false, true
+	}
+	return screen.Pending
+}
+
+// confirmation asks whether to send the chosen change, saying what HA
+// does next
+func (s State) confirmation() string {
+	switch s.Chosen.Kind {
+	case Remove:
+		return "HA stops watching the guest, which stays as it is."
+	case Request:
+		switch s.Chosen.State {
+		case "started":
+			return "HA starts the guest, and starts it again wherever it fails."
+		case "stopped":
+			return "HA shuts the guest down and keeps it down."
+		}
+		return "HA stops the guest and no longer recovers it."
+	}
+	return "HA starts the guest, and starts it again wherever it fails."
+}
+
+// GetText renders the menu
+func GetText(s State, width, height int) string {
+	settings := "not managed"
+	switch {
+	case s.Managed && s.NoGroups:
+		settings = "requested " + s.HAState
+	case s.Managed:
+		group := s.Group
+		if group == "" {
+			group = "none"
+		}
+		settings = fmt.Sprintf("requested %s, group %s", s.HAState, group)
+	}
+	body := []string{"", "  HA: " + settings, ""}
+
+	status := format.Text("↑↓=Select  Enter=Choose  ESC=Close")
+	switch {
+	case s.Blocked != nil:
+		body = append(body, fmt.Sprintf("  Can't change HA: %v", redact.Error(s.Blocked)))
+		status = "Press any key to close"
+	case s.Done && s.Err != nil:
+		body = append(body, fmt.Sprintf("  Failed to %s: %v", lowerFirst(s.Chosen.String()), redact.Error(s.Err)))
+		status = "Press any key to close"
+	case s.Done:
+		body = append(body, fmt.Sprintf("  %s: done. The list shows it from the next refresh.", s.Chosen))
+		status = "Press any key to close"
+	case s.Sending:
+		status = fmt.Sprintf("Sending %s...", lowerFirst(s.Chosen.String()))
+	case s.Confirming:
+		body = append(body, fmt.Sprintf("  %s?", s.Chosen), "  "+s.confirmation())
+		status = "y=Confirm  n/ESC=Back"
+	case s.Picking:
+		body = append(body, "  Group:")
+		body = append(body, s.groupLines(width)...)
+		status = format.Text("↑↓=Select  Enter=Choose  ESC=Back")
+	default:
+		for i, c := range s.Changes() {
+			body = append(body, cursorLine(c.String(), i == s.Cursor, width))
+		}
+	}
+
+	return format.Frame("HA - "+s.Guest, body, status, width, height)
+}
+
+// groupLines lists the groups to pick from, led by no group at all
+func (s State) groupLines(width int) []string {
+	lines := []string{cursorLine("(no group)", s.Pick == 0, width)}
+	for i, g := range s.Groups {
+		lines = append(lines, cursorLine(g, s.Pick == i+1, width))
+	}
+	switch {
+	case s.Loading:
+		lines = append(lines, "", "  Listing the HA groups...")
+	case s.GroupsErr != nil:
+		lines = append(lines, "", fmt.Sprintf("  Failed to list the HA groups: %v", redact.Error(s.GroupsErr)))
+	}
+	return lines
+}
+
+// cursorLine renders an entry, highlighted when it has the cursor
+func cursorLine(text string, selected bool, width int) string {
+	if !selected {
+		return "    " + text
+	}
+	line := format.Truncate("  > "+text, width)
+	if format.Color() {
+		line = lipgloss.NewStyle().Reverse(true).Render(format.Pad(line, width))
+	}
+	return line
+}
+
+// lowerFirst lowers the first letter of a menu entry for use mid-sentence
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]|0x20) + s[1:]
+}
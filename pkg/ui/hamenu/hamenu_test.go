@@ -0,0 +1,137 @@
+package hamenu
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+func TestChanges(t *testing.T) {
+	if got := New("web (100)", false, "", "").Changes(); len(got) != 1 || got[0].Kind != Add {
+		t.Errorf("A guest outside HA can only be added, got %v", got)
+	}
+
+	var entries []string
+	for _, c := range New("db (102)", true, "prod", "started").Changes() {
+		entries = append(entries, c.String())
+	}
+	if got, want := strings.Join(entries, ", "), "Request stopped, Request disabled, Remove from HA"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestHandleKey_Request(t *testing.T) {
+	s := New("db (102)", true, "prod", "started")
+	s.HandleKey("down")
+	if got := s.HandleKey("enter"); got != screen.Pending || !s.Confirming {
+		t.Fatalf("Enter should ask for confirmation, got %v", got)
+	}
+	if s.Chosen != (Change{Kind: Request, State: "disabled"}) {
+		t.Errorf("Unexpected change %+v", s.Chosen)
+	}
+	s.HandleKey("x")
+	if !s.Confirming {
+		t.Error("Other keys should keep the question")
+	}
+	s.HandleKey("esc")
+	if s.Confirming {
+		t.Fatal("ESC should go back to the menu")
+	}
+	s.HandleKey("enter")
+	if got := s.HandleKey("y"); got != screen.Confirmed {
+		t.Errorf("y should confirm, got %v", got)
+	}
+
+	s.Sending = true
+	if got := s.HandleKey("esc"); got != screen.Pending {
+		t.Errorf("Keys are ignored while sending, got %v", got)
+	}
+	s.Sending, s.Done = false, true
+	if got := s.HandleKey("x"); got != screen.Closed {
+		t.Errorf("Any key should close once done, got %v", got)
+	}
+}
+
+func TestHandleKey_AddPicksGroup(t *testing.T) {
+	s := New("web (100)", false, "", "")
+	s.Loading, s.Groups = false, []string{"prod", "lab"}
+
+	s.HandleKey("enter")
+	if !s.Picking {
+		t.Fatal("Adding should open the group picker")
+	}
+	s.HandleKey("down")
+	s.HandleKey("down")
+	s.HandleKey("down")
+	s.HandleKey("enter")
+	if !s.Confirming || s.Chosen != (Change{Kind: Add, Group: "lab"}) {
+		t.Errorf("Expected to confirm adding in lab, got %+v", s.Chosen)
+	}
+
+	s.HandleKey("n")
+	s.HandleKey("enter")
+	s.HandleKey("enter")
+	if s.Chosen != (Change{Kind: Add}) {
+		t.Errorf("The first entry adds without a group, got %+v", s.Chosen)
+	}
+	s.HandleKey("esc")
+	if got := s.HandleKey("esc"); got != screen.Closed {
+		t.Errorf("ESC on the menu should close it, got %v", got)
+	}
+}
+
+func TestHandleKey_AddWithoutGroups(t *testing.T) {
+	s := New("web (100)", false, "", "")
+	s.Loading, s.NoGroups = false, true
+
+	s.HandleKey("enter")
+	if s.Picking || !s.Confirming || s.Chosen != (Change{Kind: Add}) {
+		t.Errorf("Without groups adding should go straight to confirmation, got %+v", s)
+	}
+}
+
+func TestGetText(t *testing.T) {
+	format.SetColor(false)
+	defer format.SetColor(true)
+
+	s := New("db (102)", true, "", "started")
+	view := GetText(s, 60, 16)
+	for _, want := range []string{"HA - db (102)", "HA: requested started, group none", "> Request stopped", "Remove from HA"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	s.NoGroups = true
+	if view := GetText(s, 60, 16); strings.Contains(view, "group none") {
+		t.Errorf("Without groups none should be shown:\n%s", view)
+	}
+	s.NoGroups = false
+
+	s.Chosen, s.Confirming = Change{Kind: Remove}, true
+	if view := GetText(s, 60, 16); !strings.Contains(view, "Remove from HA?") || !strings.Contains(view, "y=Confirm") {
+		t.Errorf("Expected the question:\n%s", view)
+	}
+
+	s.Confirming, s.Done, s.Err = false, true, errors.New("permission denied")
+	if view := GetText(s, 60, 16); !strings.Contains(view, "Failed to remove from HA: permission denied") {
+		t.Errorf("Expected the error:\n%s", view)
+	}
+
+	s.Done, s.Blocked = false, errors.New("node pve1 is offline")
+	if view := GetText(s, 60, 16); !strings.Contains(view, "Can't change HA: node pve1 is offline") {
+		t.Errorf("Expected why nothing can be changed:\n%s", view)
+	}
+	if s.HandleKey("enter") != screen.Closed {
+		t.Error("Any key should close a blocked menu")
+	}
+
+	s = New("web (100)", false, "", "")
+	s.Picking, s.GroupsErr, s.Loading = true, errors.New("403"), false
+	if view := GetText(s, 60, 16); !strings.Contains(view, "> (no group)") || !strings.Contains(view, "Failed to list the HA groups: 403") {
+		t.Errorf("Expected the picker with the error:\n%s", view)
+	}
+}
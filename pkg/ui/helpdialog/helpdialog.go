@@ -4,21 +4,27 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/version"
 )
 
+// sideBySideColWidth is the column width used when sections are laid out
+// horizontally on short terminals
+const sideBySideColWidth = 40
+
 // GetHelpText returns the formatted help text
 func GetHelpText(width, height int) string {
-	var b strings.Builder
-
 	// Help content - organized as key/action pairs
 	type helpItem struct {
 		keys   string
 		action string
 	}
 
+	// Sections in the same column are stacked when laid out side by side
 	sections := []struct {
-		title string
-		items []helpItem
+		title  string
+		column int
+		items  []helpItem
 	}{
 		{
 			title: "Navigation:",
@@ -27,69 +33,120 @@ func GetHelpText(width, height int) string {
 				{"↓ / j", "Move down"},
 				{"PgUp", "Scroll page up"},
 				{"PgDn", "Scroll page down"},
+				{"F8 / S", "Cycle sort mode"},
+				{"< / > / o", "Sort column / reverse"},
+				{"u", "Recently restarted view"},
+				{"a / v / w", "Alloc/bridge/owner column"},
+				{"D", "Toggle since column"},
+				{"F", "Flags: Agent/Onboot/Prot."},
+				{"ESC", "Clear filters"},
 			},
 		},
 		{
-			title: "Actions:",
+			title: "Scheduling:",
 			items: []helpItem{
-				{"F1 / h", "Show this help"},
+				{"@", "Schedule an action"},
+				{"L", "Scheduled actions"},
+			},
+		},
+		{
+			title:  "Undo:",
+			column: 0,
+			items: []helpItem{
+				{"z", "Start last stop again"},
+				{"Z", "Guests stopped by pvec"},
+			},
+		},
+		{
+			title:  "Actions:",
+			column: 1,
+			items: []helpItem{
+				{"F1/h / ?", "Help / cheat-sheet"},
 				{"F2 / c", "Configuration"},
 				{"F3 / i", "Show VM/CT details"},
-				{"F4 / s", "Start VM/CT"},
+				{"F4 / s", "Start/resume VM/CT"},
 				{"F5 / d", "Shutdown VM/CT"},
 				{"F6 / r", "Reboot VM/CT"},
 				{"F7 / t", "Stop VM/CT"},
-				{"F10 / q", "Quit application"},
-				{"Ctrl+C", "Quit application"},
+				{"B / H", "Restart / hibernate VM"},
+				{"O", "Start node in boot order"},
+				{"n / N / p", "Nodes / power / pools"},
+				{"W", "Wake node (WoL)"},
+				{"T / I", "Tasks / ISOs & templates"},
+				{"C / A", "Serial (VM) / HA"},
+				{"+ / # / M", "Clone / tag / pool guests"},
+				{"P", "Token permissions"},
+				{"R", "Refresh now"},
+				{"e", "Show state change events"},
+				{"Ctrl+Z", "Suspend to shell"},
+				{"F10/q/Ctrl+C", "Quit application"},
 			},
 		},
 	}
 
-	// Title and separator first
-	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-	separatorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000"))
-	statusStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-
-	title := "Help - Keyboard Shortcuts"
-	b.WriteString(titleStyle.Render(title))
-	b.WriteString("\n")
-	b.WriteString(separatorStyle.Render(strings.Repeat("─", width)))
-	b.WriteString("\n\n")
-
-	// Build help lines with proper column alignment
-	var helpLines []string
+	// Build one block of lines per column with proper column alignment
 	keyColWidth := 15 // Width for the key column
-
+	var blocks [][]string
 	for _, section := range sections {
-		helpLines = append(helpLines, section.title)
+		if section.column == len(blocks) {
+			blocks = append(blocks, nil)
+		} else {
+			blocks[section.column] = append(blocks[section.column], "") // Empty line between sections
+		}
+		block := append(blocks[section.column], section.title)
 		for _, item := range section.items {
 			// Format: "  key" + padding + "action"
-			line := "  " + item.keys
-			// Pad to keyColWidth using rune count, then add action
-			lineRunes := []rune(line)
-			if len(lineRunes) < keyColWidth {
-				line += strings.Repeat(" ", keyColWidth-len(lineRunes))
-			}
-			line += item.action
-			helpLines = append(helpLines, line)
+			block = append(block, format.Pad("  "+format.Text(item.keys), keyColWidth)+item.action)
 		}
-		helpLines = append(helpLines, "") // Empty line between sections
+		blocks[section.column] = block
 	}
 
-	// Write help lines
-	for _, line := range helpLines {
-		b.WriteString(line)
-		b.WriteString("\n")
+	// Stack the sections, or put them side by side when that is the only
+	// way to fit them on screen
+	var helpLines []string
+	for _, block := range blocks {
+		helpLines = append(helpLines, block...)
+		helpLines = append(helpLines, "") // Empty line between sections
 	}
-
-	// Fill remaining space
-	usedLines := 3 + len(helpLines) // title(1) + separator(1) + blank(1) + helpLines
-	for i := usedLines; i < height-1; i++ {
-		b.WriteString("\n")
+	availableRows := format.FrameRows(height) - 1 // blank line under the separator
+	if len(helpLines) > availableRows && width >= len(blocks)*sideBySideColWidth {
+		columns := make([]string, len(blocks))
+		for i, block := range blocks {
+			columns[i] = lipgloss.NewStyle().Width(sideBySideColWidth).Render(strings.Join(block, "\n"))
+		}
+		helpLines = strings.Split(lipgloss.JoinHorizontal(lipgloss.Top, columns...), "\n")
 	}
 
-	// Status bar
-	b.WriteString(statusStyle.Render("Press ESC or Enter to close"))
+	// A blank line under the separator, then the sections
+	body := append([]string{""}, helpLines...)
+	status := format.Text("Press ESC or Enter to close — pvec " + version.Get().Version)
+	return format.Frame("Help - Keyboard Shortcuts", body, status, width, height)
+}
+
+// cheatSheetKeyWidth is the width of the key column of the cheat-sheet
+const cheatSheetKeyWidth = 10
 
-	return b.String()
+// cheatSheet lists the most common bindings, as key and action
+var cheatSheet = [][2]string{
+	{"↑↓ PgUp/Dn", "Move"},
+	{"F3 / Enter", "Details"},
+	{"F4 / s", "Start"},
+	{"F5 / d", "Shutdown"},
+	{"F6 / r", "Reboot"},
+	{"F7 / t", "Stop"},
+	{"S / o", "Sort / reverse"},
+	{"R", "Refresh now"},
+	{"ESC", "Clear filters"},
+	{"F1 / h", "Full help"},
+	{"q", "Quit"},
+}
+
+// GetCheatSheet returns the compact help drawn over the list, boxed, line
+// by line
+func GetCheatSheet() []string {
+	lines := make([]string, 0, len(cheatSheet))
+	for _, item := range cheatSheet {
+		lines = append(lines, " "+format.Pad(format.Text(item[0]), cheatSheetKeyWidth)+" "+item[1]+" ")
+	}
+	return format.Box(format.Text(" Keys — ? hides "), lines)
 }
@@ -3,6 +3,10 @@ package helpdialog
 import (
 	"strings"
 	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/version"
 )
 
 func TestGetHelpText(t *testing.T) {
@@ -29,6 +33,17 @@ func TestGetHelpText(t *testing.T) {
 	}
 }
 
+func TestGetHelpText_Version(t *testing.T) {
+	saved := version.Version
+	t.Cleanup(func() { version.Version = saved })
+	version.Version = "v1.4.0"
+
+	result := GetHelpText(80, 24)
+	if !strings.Contains(result, "pvec v1.4.0") {
+		t.Errorf("Help footer missing the version:\n%s", result)
+	}
+}
+
 func TestGetHelpText_ContainsKeyBindings(t *testing.T) {
 	result := GetHelpText(80, 24)
 
@@ -40,9 +55,54 @@ func TestGetHelpText_ContainsKeyBindings(t *testing.T) {
 	}
 }
 
+func TestGetHelpText_StacksColumnSections(t *testing.T) {
+	lines := strings.Split(GetHelpText(80, 24), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "Scheduling:") {
+			if !strings.Contains(lines[i+1], "@") || !strings.Contains(lines[i+2], "L") {
+				t.Errorf("Expected the scheduling keys under their title:\n%s", strings.Join(lines, "\n"))
+			}
+			if !strings.Contains(line, "C ") {
+				t.Errorf("Scheduling should sit beside the actions:\n%s", line)
+			}
+			return
+		}
+	}
+	t.Errorf("Missing the scheduling section:\n%s", strings.Join(lines, "\n"))
+}
+
 func TestGetHelpText_MinimalDimensions(t *testing.T) {
 	result := GetHelpText(20, 10)
 	if result == "" {
 		t.Error("Failed with minimal dimensions")
 	}
 }
+
+func TestGetCheatSheet(t *testing.T) {
+	format.SetColor(false)
+	defer format.SetColor(true)
+
+	box := GetCheatSheet()
+	if len(box) != len(cheatSheet)+2 {
+		t.Fatalf("Expected a line per binding and the border, got %d lines", len(box))
+	}
+	for _, line := range box {
+		if lipgloss.Width(line) != lipgloss.Width(box[0]) {
+			t.Errorf("Expected lines as wide as the border:\n%s", strings.Join(box, "\n"))
+			break
+		}
+	}
+	if text := strings.Join(box, "\n"); !strings.Contains(text, "F5 / d     Shutdown") || !strings.Contains(text, "Keys — ? hides") {
+		t.Errorf("Expected the common bindings:\n%s", text)
+	}
+
+	format.SetUnicode(false)
+	defer format.SetUnicode(true)
+	for _, line := range GetCheatSheet() {
+		for _, r := range line {
+			if r > 127 {
+				t.Fatalf("Expected ASCII only, got %q in %q", r, line)
+			}
+		}
+	}
+}
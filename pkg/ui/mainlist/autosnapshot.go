@@ -0,0 +1,101 @@
+package mainlist
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// snapshotAsk asks, before an action listed in snapshot_before, whether
+// to snapshot the guest first. Where snapshots can't be taken it asks
+// whether to go ahead without one instead.
+type snapshotAsk struct {
+	vm          *models.VMStatus
+	action      string
+	unavailable bool // The backend can't take snapshots
+}
+
+// newSnapshotter returns the snapshotter of the list's guests, nil
+// without a snapshot manager
+func newSnapshotter(manager proxmox.SnapshotManager, guests models.NodeList) actions.Snapshotter {
+	if manager == nil {
+		return nil
+	}
+	return proxmox.NewSnapshotExecutor(manager, guests)
+}
+
+// snapshotCandidate returns the selected guest when snapshot_before lists
+// action, nil when the action runs right away. A locked guest is left to
+// executeAction, which explains the lock.
+func (m *listModel) snapshotCandidate(action string) *models.VMStatus {
+	ml := m.parent
+	if ml.appConfig == nil || !ml.appConfig.SnapshotsBefore(action) {
+		return nil
+	}
+	ml.refreshMutex.Lock()
+	vm := ml.selectedGuest()
+	ml.refreshMutex.Unlock()
+	if vm == nil || vm.Locked() {
+		return nil
+	}
+	return vm
+}
+
+// prompt is the question in the status bar
+func (a *snapshotAsk) prompt() string {
+	if a.unavailable {
+		return fmt.Sprintf("Cannot snapshot %s (%s) with this connection: %s it without a snapshot? (y/n)",
+			a.vm.Name, a.vm.Key(), a.action)
+	}
+	return fmt.Sprintf("Snapshot %s (%s) before the %s? (y/Enter: snapshot first, n: %s without, ESC: cancel)",
+		a.vm.Name, a.vm.Key(), a.action, a.action)
+}
+
+// handleSnapshotAskKeys runs the action once answered, with or without a
+// snapshot
+func (m *listModel) handleSnapshotAskKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	a := m.snapshotAsk
+	switch msg.String() {
+	case "y", "Y", "enter":
+		if msg.String() == "enter" && a.unavailable {
+			return true, m, nil
+		}
+		m.snapshotAsk = nil
+		model, cmd := m.executeAction(a.action, !a.unavailable)
+		return true, model, cmd
+	case "n", "N":
+		m.snapshotAsk = nil
+		if a.unavailable {
+			return true, m, nil
+		}
+		model, cmd := m.executeAction(a.action, false)
+		return true, model, cmd
+	case "esc":
+		m.snapshotAsk = nil
+	}
+	return true, m, nil
+}
+
+// snapshotFirst wraps action so the guest is snapshotted before it runs
+func (ml *MainList) snapshotFirst(action actions.Action, vm *models.VMStatus) *actions.SnapshotFirstAction {
+	first := actions.NewSnapshotFirstAction(action, ml.snapshots, vm)
+	first.Now = ml.now
+	return first
+}
+
+// snapshotNote tells what became of the snapshot of a successful action:
+// its name, or that the guest's storage couldn't take one
+func snapshotNote(first *actions.SnapshotFirstAction) string {
+	switch {
+	case first == nil:
+		return ""
+	case first.Taken() != "":
+		return "snapshot " + first.Taken()
+	case first.Skipped():
+		return "no snapshot: its storage can't take one"
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package mainlist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/state"
+)
+
+// snapshotDriver selects web-1 (100, running) with snapshot_before set to
+// stop and reboot, and snapshot tasks that end at once
+func snapshotDriver(t *testing.T, client *MockClient) *driver {
+	t.Helper()
+	client.TaskState = models.TaskStatus{ExitStatus: "OK"}
+	d := newDriver(t, client)
+	d.ml.appConfig = &config.Config{SnapshotBefore: []string{"stop", "reboot"}}
+	d.ml.snapshots = newSnapshotter(client, d.ml.guests)
+	d.send(tea.WindowSizeMsg{Width: 160, Height: 24})
+	selectGuest(t, d, "100")
+	return d
+}
+
+func TestSnapshotBefore_Snapshot(t *testing.T) {
+	client := e2eClient()
+	d := snapshotDriver(t, client)
+
+	d.key("t")
+	if bar := statusBar(d); !strings.Contains(bar, "Snapshot web-1 (100) before the stop? (y/Enter: snapshot first, n: stop without, ESC: cancel)") {
+		t.Fatalf("Expected to be asked about the snapshot:\n%s", bar)
+	}
+	if len(client.Killed) != 0 {
+		t.Fatalf("Nothing should run before the answer, got %v", client.Killed)
+	}
+
+	d.key("y")
+	if len(client.Snapshots) != 1 || client.Snapshots[0] != "100 pvec-auto-20260301-120000" {
+		t.Errorf("Expected a snapshot of 100, got %v", client.Snapshots)
+	}
+	if len(client.Killed) != 1 || client.Killed[0] != "100" {
+		t.Errorf("Expected 100 to be stopped after the snapshot, got %v", client.Killed)
+	}
+	if bar := statusBar(d); !strings.Contains(bar, "Succeeded in stop 100 (snapshot pvec-auto-20260301-120000)") {
+		t.Errorf("Expected the snapshot named in the result:\n%s", bar)
+	}
+}
+
+func TestSnapshotBefore_Declined(t *testing.T) {
+	client := e2eClient()
+	d := snapshotDriver(t, client)
+
+	d.key("t", "n")
+	if len(client.Snapshots) != 0 || len(client.Killed) != 1 {
+		t.Errorf("n should stop without a snapshot, got snapshots %v stops %v", client.Snapshots, client.Killed)
+	}
+
+	d.key("esc", "t", "esc")
+	if len(client.Killed) != 1 || d.ml.model.snapshotAsk != nil {
+		t.Errorf("ESC should cancel the stop, got %v", client.Killed)
+	}
+
+	d.key("d")
+	if len(client.ShutDown) != 1 || len(client.Snapshots) != 0 {
+		t.Errorf("An action not listed runs at once, got %v", client.ShutDown)
+	}
+}
+
+func TestSnapshotBefore_Failed(t *testing.T) {
+	client := e2eClient()
+	client.SnapshotErr = errors.New("snapshot feature is not available")
+	d := snapshotDriver(t, client)
+
+	d.key("t", "enter")
+	if len(client.Killed) != 0 {
+		t.Errorf("A failed snapshot must abort the stop, got %v", client.Killed)
+	}
+	if bar := statusBar(d); !strings.Contains(bar, "Cannot stop 100: snapshot pvec-auto-20260301-120000 failed, stop not sent: snapshot feature is not available") {
+		t.Errorf("Expected the failure to say why:\n%s", bar)
+	}
+}
+
+func TestSnapshotBefore_Unsupported(t *testing.T) {
+	client := e2eClient()
+	client.NoSnapshot = true
+	d := snapshotDriver(t, client)
+
+	d.key("t", "y")
+	if len(client.Snapshots) != 0 || len(client.Killed) != 1 {
+		t.Errorf("A guest that can't be snapshotted is stopped without one, got snapshots %v stops %v", client.Snapshots, client.Killed)
+	}
+	if bar := statusBar(d); !strings.Contains(bar, "Succeeded in stop 100 (no snapshot: its storage can't take one)") {
+		t.Errorf("Expected the missing snapshot to be reported:\n%s", bar)
+	}
+}
+
+func TestSnapshotBefore_Unavailable(t *testing.T) {
+	client := e2eClient()
+	d := snapshotDriver(t, client)
+	d.ml.snapshots = nil
+
+	d.key("t")
+	if bar := statusBar(d); !strings.Contains(bar, "Cannot snapshot web-1 (100) with this connection: stop it without a snapshot? (y/n)") {
+		t.Fatalf("Expected to be warned that no snapshot can be taken:\n%s", bar)
+	}
+	d.key("n")
+	if len(client.Killed) != 0 {
+		t.Errorf("n should cancel the stop, got %v", client.Killed)
+	}
+	d.key("t", "y")
+	if len(client.Killed) != 1 {
+		t.Errorf("y should stop without a snapshot, got %v", client.Killed)
+	}
+}
+
+func TestSnapshotBefore_Scheduled(t *testing.T) {
+	client := e2eClient()
+	d := snapshotDriver(t, client)
+	d.ml.stateFile, _ = state.Open("")
+	_, err := d.ml.stateFile.Schedule(state.ScheduledAction{Action: "reboot", VMID: "100", At: e2eNow.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at(d, time.Minute)
+	d.send(tickMsg{})
+	if len(client.Snapshots) != 1 {
+		t.Errorf("A scheduled reboot should be snapshotted without asking, got %v", client.Snapshots)
+	}
+	if ran := d.ml.stateFile.Scheduled()[0]; ran.Pending() || ran.Error != "" {
+		t.Errorf("Expected the reboot to run, got %+v", ran)
+	}
+}
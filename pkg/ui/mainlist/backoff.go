@@ -0,0 +1,103 @@
+package mainlist
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// backoffSteps are the refresh intervals after one, two and three or more
+// failed refreshes in a row
+var backoffSteps = []time.Duration{10 * time.Second, 30 * time.Second, time.Minute}
+
+// backoff stretches the auto-refresh interval while refreshes keep
+// failing, so an unreachable API isn't polled at the configured pace
+type backoff struct {
+	base     time.Duration // Configured interval
+	failures int           // Failed refreshes in a row
+	retryAt  time.Time     // When the next refresh is due while backing off
+}
+
+// interval returns the time to wait before the next refresh. A configured
+// interval longer than the step is never shortened.
+func (b *backoff) interval() time.Duration {
+	if b.failures == 0 {
+		return b.base
+	}
+	step := backoffSteps[min(b.failures, len(backoffSteps))-1]
+	return max(step, b.base)
+}
+
+// fail records a failed refresh at now and returns the interval to wait
+func (b *backoff) fail(now time.Time) time.Duration {
+	b.failures++
+	d := b.interval()
+	b.retryAt = now.Add(d)
+	return d
+}
+
+// reset returns to the configured interval and reports whether it was
+// backing off
+func (b *backoff) reset() bool {
+	active := b.failures > 0
+	b.failures = 0
+	b.retryAt = time.Time{}
+	return active
+}
+
+// remaining returns the time left until the next refresh, or 0 when not
+// backing off
+func (b *backoff) remaining(now time.Time) time.Duration {
+	if b.failures == 0 || !now.Before(b.retryAt) {
+		return 0
+	}
+	return b.retryAt.Sub(now)
+}
+
+// recordRefresh moves the backoff after a refresh and retimes the ticker.
+// A rejected token pauses the refresh instead, so it doesn't count.
+// Called with refreshMutex held.
+func (ml *MainList) recordRefresh(err error, unauthorized bool) {
+	ml.retimeRefresh(ml.now()) // Back to the idle interval once activity is over
+	ml.backoff.base = ml.refreshInterval
+	switch {
+	case err == nil:
+		if ml.backoff.reset() {
+			ml.resetTicker(ml.refreshInterval)
+		}
+	case !unauthorized:
+		ml.resetTicker(ml.backoff.fail(ml.now()))
+	}
+}
+
+// resetTicker changes the auto-refresh period, if auto-refresh runs
+func (ml *MainList) resetTicker(d time.Duration) {
+	if ml.refreshTicker != nil && d > 0 {
+		ml.refreshTicker.Reset(d)
+	}
+}
+
+// handleRetryKey refreshes at once and drops the backoff
+func (m *listModel) handleRetryKey() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	if m.parent.backoff.reset() {
+		m.parent.resetTicker(m.parent.refreshInterval)
+	}
+	m.parent.refreshMutex.Unlock()
+	return true, m, m.parent.refreshCmd()
+}
+
+// retryText tells when the next refresh runs while backing off, or
+// returns an empty string. Called with refreshMutex held.
+func (ml *MainList) retryText() string {
+	if ml.refreshInterval <= 0 || ml.backoff.failures == 0 {
+		return ""
+	}
+	left := ml.backoff.remaining(ml.now())
+	if left <= 0 {
+		return "retrying now"
+	}
+	left = (left + time.Second - 1).Truncate(time.Second) // Counts down to 1s, not 0s
+	return fmt.Sprintf("retrying in %s, R retries now", left)
+}
@@ -0,0 +1,159 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/internal/testutil"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+func TestBackoff_Steps(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := backoff{base: 5 * time.Second}
+
+	if got := b.interval(); got != 5*time.Second {
+		t.Errorf("Without failures the configured interval applies, got %v", got)
+	}
+	for i, want := range []time.Duration{10 * time.Second, 30 * time.Second, time.Minute, time.Minute} {
+		if got := b.fail(now); got != want {
+			t.Errorf("Failure %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+	if got := b.remaining(now.Add(45 * time.Second)); got != 15*time.Second {
+		t.Errorf("Expected 15s left, got %v", got)
+	}
+	if got := b.remaining(now.Add(2 * time.Minute)); got != 0 {
+		t.Errorf("Nothing is left once the retry is due, got %v", got)
+	}
+
+	if !b.reset() {
+		t.Error("reset should report that it was backing off")
+	}
+	if b.reset() {
+		t.Error("A second reset has nothing to undo")
+	}
+	if got := b.remaining(now); got != 0 || b.interval() != 5*time.Second {
+		t.Errorf("reset should return to the configured interval, got %v left", got)
+	}
+}
+
+func TestBackoff_LongInterval(t *testing.T) {
+	b := backoff{base: 2 * time.Minute}
+	if got := b.fail(time.Now()); got != 2*time.Minute {
+		t.Errorf("A longer configured interval should not be shortened, got %v", got)
+	}
+}
+
+func newBackoffList(provider *MockDataProvider, clock *testutil.FakeClock) *MainList {
+	ml := NewMainList(Config{Provider: provider})
+	ml.refreshInterval = 5 * time.Second // No ticker, so no background refreshes
+	ml.clock = clock
+	return ml
+}
+
+func TestUpdate_RefreshBackoff(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	provider := &MockDataProvider{Err: errors.New("connection refused")}
+	ml := newBackoffList(provider, clock)
+
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if ml.backoff.failures != 2 {
+		t.Fatalf("Expected 2 failures in a row, got %d", ml.backoff.failures)
+	}
+	clock.Advance(5 * time.Second)
+	if view := ml.model.View(); !strings.Contains(view, "Refresh failed — retrying in 25s, R retries now") {
+		t.Errorf("The banner should count down to the next try:\n%s", view)
+	}
+
+	provider.Err = nil
+	provider.Nodes = []*models.VMStatus{{VMID: "100", Name: "vm1", Type: "qemu"}}
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if ml.backoff.failures != 0 {
+		t.Error("A successful refresh should end the backoff")
+	}
+	if strings.Contains(ml.model.View(), "retrying") {
+		t.Error("The countdown should disappear after a successful refresh")
+	}
+}
+
+func TestUpdate_RetryKey(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	provider := &MockDataProvider{Err: errors.New("connection refused")}
+	ml := newBackoffList(provider, clock)
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	provider.Err = nil
+	_, cmd := ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("R")})
+
+	if ml.backoff.failures != 0 {
+		t.Error("R should drop the backoff")
+	}
+	if cmd == nil {
+		t.Fatal("R should refresh at once")
+	}
+	if msg, ok := cmd().(refreshMsg); !ok || msg.err != nil {
+		t.Errorf("Expected a successful refresh, got %#v", msg)
+	}
+}
+
+func TestUpdate_UnauthorizedSkipsBackoff(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	provider := &MockDataProvider{Err: &proxmox.APIError{StatusCode: 401, Path: "/cluster/resources"}}
+	ml := newBackoffList(provider, clock)
+
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if ml.backoff.failures != 0 {
+		t.Error("A rejected token pauses the refresh rather than backing off")
+	}
+	if strings.Contains(ml.model.View(), "retrying") {
+		t.Error("No countdown should show while the refresh is paused")
+	}
+}
+
+// tickedProvider fails every listing and signals each on calls
+type tickedProvider struct {
+	calls chan struct{}
+}
+
+func (p *tickedProvider) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	p.calls <- struct{}{}
+	return nil, errors.New("connection refused")
+}
+
+func TestAutoRefresh_BackoffRetimesTicker(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	provider := &tickedProvider{calls: make(chan struct{}, 10)}
+	ml := NewMainList(Config{Provider: provider, RefreshInterval: 5 * time.Second, Clock: clock})
+	t.Cleanup(func() {
+		ml.program.Kill() // Unblocks the refresh handing its result to the program that never ran
+		ml.Stop()
+	})
+	clock.BlockUntil(1)
+
+	// The first failure stretches the 5s interval to 10s
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	<-provider.calls
+	clock.Advance(5 * time.Second)
+	select {
+	case <-provider.calls:
+		t.Fatal("No refresh should run on the configured interval while backing off")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(5 * time.Second)
+	select {
+	case <-provider.calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a refresh once the backoff interval passed")
+	}
+}
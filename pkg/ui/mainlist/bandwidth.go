@@ -0,0 +1,41 @@
+package mainlist
+
+import (
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/requestlog"
+)
+
+// skipUnchanged reports whether, with low_bandwidth, a successful
+// refresh read the same guests as the last one applied, leaving their
+// usage and uptime out only with low_bandwidth_coarse, and counts it as
+// confirming them. Otherwise it keeps the digest of the refresh, which is
+// applied. Must be called with refreshMutex held.
+func (ml *MainList) skipUnchanged(msg refreshMsg) bool {
+	var digest uint64
+	if msg.err == nil && msg.nodes != nil && ml.lowBandwidth() {
+		digest = models.Digest(msg.nodes, ml.appConfig.LowBandwidthCoarse)
+	}
+	if digest != 0 && digest == ml.guestsDigest {
+		ml.confirmedAt = ml.now()
+		ml.unchanged++
+		return true
+	}
+	ml.guestsDigest = digest
+	return false
+}
+
+// lowBandwidth reports whether low_bandwidth is set
+func (ml *MainList) lowBandwidth() bool {
+	return ml.appConfig != nil && ml.appConfig.LowBandwidth
+}
+
+// traffic returns what the API sent, for the request screen
+func (ml *MainList) traffic() requestlog.Traffic {
+	// Transfers are timed on the wall clock, as they are read
+	lastMinute, total := ml.requests.Received(time.Now())
+	ml.refreshMutex.Lock()
+	defer ml.refreshMutex.Unlock()
+	return requestlog.Traffic{LastMinute: lastMinute, Total: total, Unchanged: ml.unchanged}
+}
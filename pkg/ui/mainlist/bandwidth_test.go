@@ -0,0 +1,106 @@
+package mainlist
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// newBandwidthDriver returns a driver counting the refreshes applied
+func newBandwidthDriver(t *testing.T, client *MockClient, cfg config.Config) (*driver, *int) {
+	t.Helper()
+	d := newDriver(t, client)
+	d.ml.appConfig = &cfg
+	applied := 0
+	d.ml.onNodesUpdated = func([]*models.VMStatus) { applied++ }
+	return d, &applied
+}
+
+// replaceGuest lists a copy of guest i of client changed by change
+func replaceGuest(client *MockClient, i int, change func(*models.VMStatus)) {
+	g := *client.Nodes[i]
+	change(&g)
+	client.Nodes[i] = &g
+}
+
+func TestLowBandwidth_SkipsUnchanged(t *testing.T) {
+	client := e2eClient()
+	d, applied := newBandwidthDriver(t, client, config.Config{LowBandwidth: true})
+
+	d.send(d.ml.fetchNodes(context.Background())) // Digest taken
+	d.clock.Advance(30 * time.Second)
+	d.send(d.ml.fetchNodes(context.Background()))
+	if *applied != 1 || d.ml.unchanged != 1 {
+		t.Fatalf("The same guests should only be applied once, applied %d, skipped %d", *applied, d.ml.unchanged)
+	}
+	if got := d.ml.updatedText(d.ml.now()); got != "updated 30s ago" {
+		t.Errorf("The title should tell when the guests shown were read, got %q", got)
+	}
+
+	// Usage counts unless asked otherwise
+	replaceGuest(client, 0, func(g *models.VMStatus) { g.CPUUsage, g.Uptime = 80, g.Uptime+5 })
+	d.send(d.ml.fetchNodes(context.Background()))
+	if *applied != 2 {
+		t.Errorf("A change of usage should be applied, applied %d", *applied)
+	}
+	if got := d.ml.updatedText(d.ml.now()); got != "updated 0s ago" {
+		t.Errorf("Expected the refresh applied to be dated, got %q", got)
+	}
+}
+
+func TestLowBandwidth_Coarse(t *testing.T) {
+	client := e2eClient()
+	d, applied := newBandwidthDriver(t, client, config.Config{LowBandwidth: true, LowBandwidthCoarse: true})
+	d.send(d.ml.fetchNodes(context.Background()))
+
+	// Usage and uptime alone don't count
+	replaceGuest(client, 0, func(g *models.VMStatus) { g.CPUUsage, g.Uptime = 80, g.Uptime+5 })
+	d.clock.Advance(30 * time.Second)
+	d.send(d.ml.fetchNodes(context.Background()))
+	if *applied != 1 || d.ml.unchanged != 1 {
+		t.Errorf("A change of usage should be skipped, applied %d, skipped %d", *applied, d.ml.unchanged)
+	}
+	if got := d.ml.updatedText(d.ml.now()); got != "updated 30s ago" {
+		t.Errorf("A skipped refresh should leave the guests shown dated as read, got %q", got)
+	}
+
+	replaceGuest(client, 1, func(g *models.VMStatus) { g.Status = models.StateRunning })
+	d.send(d.ml.fetchNodes(context.Background()))
+	if *applied != 2 {
+		t.Errorf("A change of state should be applied, applied %d", *applied)
+	}
+	if vm, _ := d.ml.guests.Get("101"); !vm.IsRunning() {
+		t.Errorf("Expected 101 listed running, got %s", vm.Status)
+	}
+}
+
+func TestLowBandwidth_Off(t *testing.T) {
+	d, applied := newBandwidthDriver(t, e2eClient(), config.Config{})
+	for range 3 {
+		d.send(d.ml.fetchNodes(context.Background()))
+	}
+	if *applied != 3 || d.ml.unchanged != 0 {
+		t.Errorf("Every refresh should be applied without low_bandwidth, applied %d, skipped %d", *applied, d.ml.unchanged)
+	}
+}
+
+func TestLowBandwidth_PatchedGuest(t *testing.T) {
+	client := e2eClient()
+	d, applied := newBandwidthDriver(t, client, config.Config{LowBandwidth: true})
+	d.send(d.ml.fetchNodes(context.Background()))
+
+	// A guest updated on its own since makes the next refresh count
+	started := *client.Nodes[1]
+	started.Status = models.StateRunning
+	d.send(guestUpdateMsg{guest: &started})
+	d.send(d.ml.fetchNodes(context.Background()))
+	if vm, _ := d.ml.guests.Get("101"); vm.IsRunning() {
+		t.Error("The refresh should replace the single update")
+	}
+	if *applied != 3 {
+		t.Errorf("Expected the update and both refreshes applied, got %d", *applied)
+	}
+}
@@ -0,0 +1,176 @@
+package mainlist
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/ui/batch"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// batchPreviewMsg carries what a tag edit would make of the listed guests
+type batchPreviewMsg struct {
+	seq     int
+	results []actions.BatchResult
+}
+
+// batchAppliedMsg reports the outcome of the change on some of the guests,
+// by their index in the preview
+type batchAppliedMsg struct {
+	seq     int
+	indexes []int
+	results []actions.BatchResult
+}
+
+// newOrganizer returns the tag and pool changes over the guests list, both
+// nil when the client can't make them
+func newOrganizer(organizer proxmox.GuestOrganizer, reader proxmox.ConfigReader, guests models.NodeList) (actions.Tagger, actions.PoolMover) {
+	if organizer == nil || reader == nil {
+		return nil, nil
+	}
+	executor := proxmox.NewOrganizeExecutor(organizer, reader, guests)
+	return executor, executor
+}
+
+// handleBatchKey opens the batch change of kind over the guests listed, as
+// filtered. Without a client that can make it the key does nothing.
+func (m *listModel) handleBatchKey(kind batch.Kind) (bool, tea.Model, tea.Cmd) {
+	if m.parent.tagger == nil || m.parent.poolMover == nil {
+		return false, m, nil
+	}
+	m.parent.refreshMutex.Lock()
+	guests := append([]*models.VMStatus(nil), m.parent.sortedNodes...)
+	m.parent.refreshMutex.Unlock()
+	if len(guests) == 0 {
+		return true, m, nil
+	}
+	state := batch.New(kind, guests)
+	state.Err = m.tagLimit(kind)
+	m.batch = &state
+	m.batchSeq++
+	return true, m, nil
+}
+
+// tagLimit returns why the server can't edit tags, nil when it can or the
+// change is a pool move
+func (m *listModel) tagLimit(kind batch.Kind) error {
+	if kind != batch.Tags || m.serverCaps.CanEditTags() {
+		return nil
+	}
+	return &serverLimitError{requirement: m.serverCaps.Requirement(proxmox.ServerTags), version: m.serverCaps.Version}
+}
+
+// handleBatchKeys handles keys while the batch screen is open
+func (m *listModel) handleBatchKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	switch m.batch.HandleKey(msg, format.FrameRows(m.height)) {
+	case screen.Closed:
+		m.batch = nil
+		m.batchSeq++
+	case batch.Previewed:
+		if m.batch.Kind == batch.Pool {
+			m.batch.ShowPreview(actions.PreviewPool(m.batch.Guests, m.batch.PoolName()), nil)
+			return true, m, nil
+		}
+		if err := m.tagLimit(m.batch.Kind); err != nil {
+			m.batch.ShowPreview(nil, err)
+			return true, m, nil
+		}
+		return true, m, m.previewTagsCmd()
+	case screen.Confirmed:
+		if m.batch.Kind == batch.Pool {
+			return true, m, m.moveToPoolCmd()
+		}
+		return true, m, m.applyTagCmd()
+	}
+	return true, m, nil
+}
+
+// blockedResult returns the result of a guest whose tags can't be changed:
+// a locked guest's config is refused, and an offline node doesn't answer
+func blockedResult(vm *models.VMStatus) (actions.BatchResult, bool) {
+	switch {
+	case vm.NodeOffline:
+		return actions.BatchResult{Guest: vm, Err: &nodeOfflineError{node: vm.Node}}, true
+	case vm.Locked():
+		return actions.BatchResult{Guest: vm, Err: &guestLockedError{lock: vm.Lock}}, true
+	}
+	return actions.BatchResult{}, false
+}
+
+// previewTagsCmd reads the tags of every guest listed and works out what
+// the edit makes of them. Each read is bounded on its own, so a guest that
+// doesn't answer doesn't hold up the preview of the others.
+func (m *listModel) previewTagsCmd() tea.Cmd {
+	guests, edit, tagger, seq := m.batch.Guests, m.batch.TagEdit(), m.parent.tagger, m.batchSeq
+	return func() tea.Msg {
+		results := make([]actions.BatchResult, len(guests))
+		for i, vm := range guests {
+			if result, blocked := blockedResult(vm); blocked {
+				results[i] = result
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), startConfigTimeout)
+			results[i] = actions.PreviewTags(ctx, []*models.VMStatus{vm}, edit, tagger)[0]
+			cancel()
+		}
+		return batchPreviewMsg{seq: seq, results: results}
+	}
+}
+
+// handleBatchPreview shows the preview once the tags are read
+func (m *listModel) handleBatchPreview(msg batchPreviewMsg) (tea.Model, tea.Cmd) {
+	if m.batch == nil || m.batchSeq != msg.seq || m.batch.Phase != batch.Loading {
+		return m, nil
+	}
+	m.batch.ShowPreview(msg.results, nil)
+	return m, nil
+}
+
+// applyTagCmd edits the tags of the next guest queued. The tags are read
+// again first, so an edit made since the preview isn't lost.
+func (m *listModel) applyTagCmd() tea.Cmd {
+	i := m.batch.Queue[0]
+	vm, edit, tagger := m.batch.Results[i].Guest, m.batch.TagEdit(), m.parent.tagger
+	timeout, seq := m.parent.actionTimeout(), m.batchSeq
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		result := actions.ApplyTags(ctx, []*models.VMStatus{vm}, edit, tagger, nil)[0]
+		return batchAppliedMsg{seq: seq, indexes: []int{i}, results: []actions.BatchResult{result}}
+	}
+}
+
+// moveToPoolCmd moves every guest queued to the pool in one request
+func (m *listModel) moveToPoolCmd() tea.Cmd {
+	indexes := append([]int(nil), m.batch.Queue...)
+	guests := make([]*models.VMStatus, len(indexes))
+	for j, i := range indexes {
+		guests[j] = m.batch.Results[i].Guest
+	}
+	pool, mover := m.batch.PoolName(), m.parent.poolMover
+	timeout, seq := m.parent.actionTimeout(), m.batchSeq
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return batchAppliedMsg{seq: seq, indexes: indexes, results: actions.MoveToPool(ctx, pool, guests, mover)}
+	}
+}
+
+// handleBatchApplied records the outcome and goes on with the next guest,
+// refreshing the list once every guest is done
+func (m *listModel) handleBatchApplied(msg batchAppliedMsg) (tea.Model, tea.Cmd) {
+	if m.batch == nil || m.batchSeq != msg.seq || m.batch.Phase != batch.Running {
+		return m, nil
+	}
+	for j, i := range msg.indexes {
+		m.batch.Record(i, msg.results[j])
+	}
+	if m.batch.Phase == batch.Done {
+		return m, m.parent.refreshCmd()
+	}
+	return m, m.applyTagCmd()
+}
@@ -0,0 +1,152 @@
+package mainlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/ui/batch"
+)
+
+// fakeOrganizer keeps the tags and pool moves of the guests in memory
+type fakeOrganizer struct {
+	tags  map[string][]string // Key -> tags
+	set   []string            // Keys passed to SetTags, in order
+	moves []string            // "pool key,key" passed to AddToPool
+}
+
+func (f *fakeOrganizer) Tags(ctx context.Context, key string) ([]string, error) {
+	return f.tags[key], nil
+}
+
+func (f *fakeOrganizer) SetTags(ctx context.Context, key string, tags []string) error {
+	f.set = append(f.set, key)
+	f.tags[key] = tags
+	return nil
+}
+
+func (f *fakeOrganizer) AddToPool(ctx context.Context, pool string, keys []string) error {
+	f.moves = append(f.moves, pool+" "+strings.Join(keys, ","))
+	return nil
+}
+
+// batchDriver lists e2eClient's guests with a fake organizer
+func batchDriver(t *testing.T) (*driver, *MockClient, *fakeOrganizer) {
+	t.Helper()
+	client := e2eClient()
+	d := newDriver(t, client)
+	organizer := &fakeOrganizer{tags: map[string][]string{"100": {"web"}, "101": {"web", "prod"}}}
+	d.ml.tagger, d.ml.poolMover = organizer, organizer
+	return d, client, organizer
+}
+
+func TestBatch_Tags(t *testing.T) {
+	d, _, organizer := batchDriver(t)
+	d.ml.filter = listFilter{Filter: Filter{Text: "web"}}
+	d.send(d.ml.fetchNodes(context.Background()))
+	d.key("#", "prod", "enter")
+
+	view := d.ml.model.View()
+	for _, want := range []string{"Edit Tags - 2 listed guests", "web → web;prod", "web;prod (unchanged)", "Change 1 of 2 guests? (y/n)"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	d.key("y")
+	if len(organizer.set) != 1 || organizer.set[0] != "100" {
+		t.Errorf("Expected only web-1's tags to be set, got %v", organizer.set)
+	}
+	if view = d.ml.model.View(); !strings.Contains(view, "1 changed, 1 unchanged - Press any key") {
+		t.Errorf("Expected the summary:\n%s", view)
+	}
+	d.key("x")
+	if d.ml.model.batch != nil {
+		t.Error("Expected any key to close the summary")
+	}
+}
+
+func TestBatch_TagsSkipBlocked(t *testing.T) {
+	d, client, organizer := batchDriver(t)
+	client.Nodes[0].Lock = "backup"
+	takeNodeOffline(client)
+	d.send(d.ml.fetchNodes(context.Background()))
+	d.key("#", "prod", "enter")
+
+	view := d.ml.model.View()
+	for _, want := range []string{"failed: locked (backup)", "failed: node pve2 is offline", "Change 1 of 5 guests? (y/n)"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+	d.key("y")
+	if strings.Join(organizer.set, ",") != "201" {
+		t.Errorf("Expected the locked guest and pve2's guests to be left alone, got %v", organizer.set)
+	}
+}
+
+func TestBatch_Pool(t *testing.T) {
+	d, client, organizer := batchDriver(t)
+	client.Nodes[1].Pool = "lab"
+	d.ml.filter = listFilter{Filter: Filter{Text: "web"}}
+	d.send(d.ml.fetchNodes(context.Background()))
+	d.key("M", "lab", "enter")
+
+	view := d.ml.model.View()
+	for _, want := range []string{"Move to Pool - 2 listed guests", "- → lab", "lab (unchanged)"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+	d.key("y")
+	if len(organizer.moves) != 1 || organizer.moves[0] != "lab 100" {
+		t.Errorf("Expected web-1 alone to be moved to lab, got %v", organizer.moves)
+	}
+}
+
+func TestBatch_Unsupported(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.key("#")
+	if d.ml.model.batch != nil {
+		t.Error("Expected # to do nothing without a client that changes tags")
+	}
+}
+
+func TestBatch_CloseWhileReading(t *testing.T) {
+	d, _, organizer := batchDriver(t)
+	d.key("#", "prod")
+	cmd := func() tea.Cmd {
+		_, _, cmd := d.ml.model.handleBatchKeys(tea.KeyMsg{Type: tea.KeyEnter})
+		return cmd
+	}()
+	d.key("esc")
+	d.send(cmd())
+	if d.ml.model.batch != nil || len(organizer.set) != 0 {
+		t.Error("Expected the preview of a closed screen to be dropped")
+	}
+	d.key("#")
+	d.send(batchPreviewMsg{seq: d.ml.model.batchSeq - 1, results: nil})
+	if d.ml.model.batch == nil || d.ml.model.batch.Phase != batch.Prompt {
+		t.Error("Expected an old preview to leave the new screen alone")
+	}
+}
+
+func TestBatch_TagsNeedServerRelease(t *testing.T) {
+	d, _, organizer := batchDriver(t)
+	withServer(d, "7.2-7")
+	d.key("#")
+	want := "editing tags requires PVE ≥ 7.3 (the server runs 7.2"
+	if view := d.ml.model.View(); !strings.Contains(view, want) {
+		t.Errorf("Expected %q:\n%s", want, view)
+	}
+	d.key("prod", "enter")
+	if d.ml.model.batch.Phase != batch.Prompt || len(organizer.set) != 0 {
+		t.Errorf("Expected the tags left alone on an old release, got phase %v", d.ml.model.batch.Phase)
+	}
+
+	d.key("esc", "M", "lab", "enter")
+	if d.ml.model.batch.Phase != batch.Preview {
+		t.Errorf("Expected pools to move on any release, got phase %v", d.ml.model.batch.Phase)
+	}
+}
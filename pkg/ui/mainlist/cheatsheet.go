@@ -0,0 +1,84 @@
+package mainlist
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/helpdialog"
+)
+
+// cheatSheetHideMsg hides cheat-sheet seq once its time is up
+type cheatSheetHideMsg struct{ seq int }
+
+// cheatSheetTimeout returns how long the cheat-sheet stays up, 0 to keep
+// it until a key hides it
+func (ml *MainList) cheatSheetTimeout() time.Duration {
+	if ml.appConfig == nil {
+		return config.DefaultCheatSheetTimeout
+	}
+	return ml.appConfig.CheatSheetTimeout
+}
+
+// isNavigationKey reports whether key only moves around the list, which
+// leaves the cheat-sheet up
+func isNavigationKey(key string) bool {
+	switch key {
+	case "up", "k", "down", "j", "home", "g", "end", "G", "pgup", "pgdown":
+		return true
+	}
+	return false
+}
+
+// handleCheatSheetKeys toggles the cheat-sheet with ?. Any other key
+// than the navigation ones hides it and goes on to do what it does.
+func (m *listModel) handleCheatSheetKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	key := msg.String()
+	if key == "?" {
+		m.showCheatSheet = !m.showCheatSheet
+		if !m.showCheatSheet {
+			return true, m, nil
+		}
+		m.cheatSheetSeq++
+		timeout := m.parent.cheatSheetTimeout()
+		if timeout <= 0 {
+			return true, m, nil
+		}
+		seq := m.cheatSheetSeq
+		return true, m, tea.Tick(timeout, func(time.Time) tea.Msg {
+			return cheatSheetHideMsg{seq: seq}
+		})
+	}
+	if m.showCheatSheet && !isNavigationKey(key) {
+		m.showCheatSheet = false
+	}
+	return false, m, nil
+}
+
+// handleCheatSheetHide hides the cheat-sheet its timeout was set for,
+// unless it was hidden and shown again since
+func (m *listModel) handleCheatSheetHide(msg cheatSheetHideMsg) (tea.Model, tea.Cmd) {
+	if msg.seq == m.cheatSheetSeq {
+		m.showCheatSheet = false
+	}
+	return m, nil
+}
+
+// withCheatSheet draws the cheat-sheet over the lower-right of the list
+// view, above the status bar, leaving the rest of the list in sight. It
+// is left out when the terminal is too small to hold it.
+func (m *listModel) withCheatSheet(view string) string {
+	if !m.showCheatSheet {
+		return view
+	}
+	box := helpdialog.GetCheatSheet()
+	boxWidth := lipgloss.Width(box[0])
+	x := m.width - boxWidth - 1
+	y := m.height - 1 - len(box) // The status bar stays visible
+	if x < 0 || y < 2 {          // Nor the title and its separator hidden
+		return view
+	}
+	return format.Overlay(view, box, x, y)
+}
@@ -0,0 +1,69 @@
+package mainlist
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/config"
+)
+
+func TestCheatSheet(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.ml.appConfig = &config.Config{} // No timeout, to toggle it without waiting
+	d.key("?")
+	if !d.ml.model.showCheatSheet {
+		t.Fatal("? should show the cheat-sheet")
+	}
+	d.snapshot("cheatsheet")
+
+	view := d.ml.model.View()
+	if !strings.Contains(view, "Keys — ? hides") || !strings.Contains(view, "web-1") {
+		t.Errorf("Expected the cheat-sheet over the list:\n%s", view)
+	}
+	if lines := strings.Split(view, "\n"); len(lines) != 24 || !strings.Contains(lines[23], "F1 Help") {
+		t.Errorf("Expected the status bar left in sight:\n%s", view)
+	}
+
+	// Moving around the list keeps it; the cursor moves behind it
+	d.key("down")
+	if !d.ml.model.showCheatSheet || d.ml.model.cursorPosition != 1 {
+		t.Errorf("Navigation should move the cursor under the cheat-sheet, got %v at %d",
+			d.ml.model.showCheatSheet, d.ml.model.cursorPosition)
+	}
+	d.key("?")
+	if d.ml.model.showCheatSheet {
+		t.Error("? again should hide it")
+	}
+
+	// An action key hides it and does what it does
+	d.key("?", "f1")
+	if d.ml.model.showCheatSheet || !d.ml.model.showHelp {
+		t.Error("F1 should hide the cheat-sheet and open the help")
+	}
+}
+
+func TestCheatSheet_Timeout(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.key("?") // The default timeout ticks past the driver's wait
+	seq := d.ml.model.cheatSheetSeq
+
+	d.key("?", "?")
+	d.send(cheatSheetHideMsg{seq: seq})
+	if !d.ml.model.showCheatSheet {
+		t.Error("The timeout of a cheat-sheet since hidden should not hide the one shown again")
+	}
+	d.send(cheatSheetHideMsg{seq: d.ml.model.cheatSheetSeq})
+	if d.ml.model.showCheatSheet {
+		t.Error("The cheat-sheet should hide once its time is up")
+	}
+}
+
+func TestCheatSheet_TooSmall(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.ml.appConfig = &config.Config{}
+	d.key("?")
+	d.ml.model.width, d.ml.model.height = 80, 12
+	if strings.Contains(d.ml.model.withCheatSheet("x"), "Keys") {
+		t.Error("A list too short for the cheat-sheet should be left alone")
+	}
+}
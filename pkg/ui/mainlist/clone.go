@@ -0,0 +1,209 @@
+package mainlist
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/clonepresets"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// cloneState is a clone from a preset, shown in the status bar
+type cloneState struct {
+	preset config.ClonePreset
+	source *models.VMStatus // Guest cloned, nil when it isn't listed
+	name   string           // Name of the clone
+	newKey string           // Key of the clone, once its VMID is known
+	done   bool
+	err    error
+}
+
+// cloneResultMsg reports the outcome of a clone
+type cloneResultMsg struct {
+	newid string // "" if no VMID was allocated
+	err   error
+}
+
+// newCloner returns the cloner of the clone presets, nil when the client
+// can't clone
+func newCloner(manager proxmox.CloneManager, guests models.NodeList) actions.Cloner {
+	if manager == nil {
+		return nil
+	}
+	return proxmox.NewCloneExecutor(manager, guests)
+}
+
+// clonePresets returns the presets of the config
+func (ml *MainList) clonePresets() []config.ClonePreset {
+	if ml.appConfig == nil {
+		return nil
+	}
+	return ml.appConfig.ClonePresets
+}
+
+// guestNames returns the names of the guests listed. Must be called with
+// refreshMutex held.
+func (ml *MainList) guestNames() []string {
+	all := ml.guests.All()
+	names := make([]string, 0, len(all))
+	for _, vm := range all {
+		names = append(names, vm.Name)
+	}
+	return names
+}
+
+// cloneSource returns the guest a preset clones, if listed. Must be
+// called with refreshMutex held.
+func (ml *MainList) cloneSource(p config.ClonePreset) (*models.VMStatus, bool) {
+	return ml.guests.Get(strconv.Itoa(p.SourceVMID))
+}
+
+// nextCloneName returns the name after the highest index of the listed
+// guests matching the preset's pattern. Must be called with refreshMutex
+// held.
+func (ml *MainList) nextCloneName(p config.ClonePreset) (string, error) {
+	pattern, err := models.ParseNamePattern(p.NamePattern)
+	if err != nil {
+		return "", err
+	}
+	return pattern.Expand(pattern.NextIndex(ml.guestNames())), nil
+}
+
+// cloneEntries lists the presets with their source and next name
+func (m *listModel) cloneEntries() []clonepresets.Entry {
+	ml := m.parent
+	ml.refreshMutex.Lock()
+	defer ml.refreshMutex.Unlock()
+	presets := ml.clonePresets()
+	entries := make([]clonepresets.Entry, 0, len(presets))
+	for _, p := range presets {
+		e := clonepresets.Entry{Preset: p}
+		if source, ok := ml.cloneSource(p); ok {
+			e.Source = source.Name
+		}
+		e.Next, _ = ml.nextCloneName(p)
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// handleCloneKey opens the screen of the clone presets
+func (m *listModel) handleCloneKey() (bool, tea.Model, tea.Cmd) {
+	m.clonePicker = &clonepresets.State{}
+	return true, m, nil
+}
+
+// handleClonePickerKeys handles keys while the screen of presets is open
+func (m *listModel) handleClonePickerKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	presets := m.parent.clonePresets()
+	m.clonePicker.Clamp(len(presets))
+	switch m.clonePicker.HandleKey(msg.String(), len(presets)) {
+	case screen.Closed:
+		m.clonePicker = nil
+	case clonepresets.Clone:
+		preset := presets[m.clonePicker.Selected]
+		// The progress shows in the status bar of the list
+		m.clonePicker = nil
+		return true, m, m.startClone(preset)
+	}
+	return true, m, nil
+}
+
+// startClone clones the source of a preset under the next name of its
+// pattern, and starts the clone if the preset says so
+func (m *listModel) startClone(p config.ClonePreset) tea.Cmd {
+	ml := m.parent
+	m.clone = &cloneState{preset: p}
+	ml.refreshMutex.Lock()
+	source, listed := ml.cloneSource(p)
+	name, err := ml.nextCloneName(p)
+	ml.refreshMutex.Unlock()
+	switch {
+	case ml.cloner == nil:
+		err = fmt.Errorf("client not available")
+	case !listed:
+		err = fmt.Errorf("guest %d is not listed", p.SourceVMID)
+	case source.NodeOffline:
+		err = &nodeOfflineError{node: source.Node}
+	}
+	if listed {
+		m.clone.source = source
+	}
+	m.clone.name = name
+	if err != nil {
+		m.clone.done = true
+		m.clone.err = err
+		return nil
+	}
+
+	action := &actions.CloneAction{
+		Cloner: ml.cloner,
+		Source: source.Key(),
+		Spec:   actions.CloneSpec{Name: name, Storage: p.TargetStorage, Full: p.Full},
+		Start:  p.Start,
+	}
+	// A full clone can take long: it only ends with pvec
+	ctx := ml.ctx
+	ml.emit(ActionStarted{Action: "clone", VMID: source.Key()})
+	return func() tea.Msg {
+		err := guarded(func() error { return action.Execute(ctx) })
+		return cloneResultMsg{newid: action.Spec.NewID, err: err}
+	}
+}
+
+// handleCloneResult records the outcome of the clone and, once the clone
+// exists, refreshes the list with the cursor pinned to it
+func (m *listModel) handleCloneResult(msg cloneResultMsg) (tea.Model, tea.Cmd) {
+	if m.clone == nil {
+		return m, nil
+	}
+	c := m.clone
+	c.done = true
+	c.err = m.parent.logPanic(msg.err)
+	m.parent.emit(ActionCompleted{Action: "clone", VMID: c.source.Key(), Err: c.err})
+	var stage *actions.CloneError
+	if c.err != nil && !(errors.As(c.err, &stage) && stage.Stage == actions.CloneStageStart) {
+		return m, nil
+	}
+	// Made, even if it failed to start
+	c.newKey = models.GuestKey(c.source.Cluster, msg.newid)
+	m.parent.refreshMutex.Lock()
+	m.parent.follow = c.newKey
+	m.parent.refreshMutex.Unlock()
+	return m, m.parent.refreshCmd()
+}
+
+// handleCloneKeys dismisses a finished clone with any key
+func (m *listModel) handleCloneKeys() (bool, tea.Model, tea.Cmd) {
+	if m.clone.done {
+		m.clone = nil
+	}
+	return true, m, nil
+}
+
+// statusText describes the clone for the status bar
+func (c *cloneState) statusText() string {
+	source := strconv.Itoa(c.preset.SourceVMID)
+	if c.source != nil {
+		source = fmt.Sprintf("%s (%s)", c.source.Name, c.source.Key())
+	}
+	switch {
+	case !c.done:
+		return fmt.Sprintf("Cloning %s to %s...", source, c.name)
+	case c.err == nil:
+		return fmt.Sprintf("Cloned %s as %s (%s). - Press any key", source, c.name, c.newKey)
+	case c.newKey != "":
+		return fmt.Sprintf("Cloned %s as %s (%s), %v. - Press any key", source, c.name, c.newKey, redact.Error(c.err))
+	}
+	if hint := proxmox.PermissionHint(c.err); hint != "" {
+		return fmt.Sprintf("Failed to clone %s: the token %s. - Press any key", source, hint)
+	}
+	return fmt.Sprintf("Failed to clone %s (preset %s): %v. - Press any key", source, c.preset.Name, redact.Error(c.err))
+}
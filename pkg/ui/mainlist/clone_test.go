@@ -0,0 +1,129 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// fakeCloner clones into the list of a MockClient, so that the refresh
+// after the clone lists it
+type fakeCloner struct {
+	client   *MockClient
+	cloneErr error
+	startErr error
+	cloned   []actions.CloneSpec
+	started  []string
+}
+
+func (f *fakeCloner) NextVMID(ctx context.Context) (string, error) {
+	return "105", nil
+}
+
+func (f *fakeCloner) Clone(ctx context.Context, source string, spec actions.CloneSpec) (string, error) {
+	if f.cloneErr != nil {
+		return "", f.cloneErr
+	}
+	f.cloned = append(f.cloned, spec)
+	f.client.Nodes = append(f.client.Nodes, &models.VMStatus{VMID: spec.NewID, Name: spec.Name, Type: "qemu", Status: "stopped", Node: "pve1"})
+	return "UPID:pve1:clone", nil
+}
+
+func (f *fakeCloner) WaitTask(ctx context.Context, source, task string) error {
+	return nil
+}
+
+func (f *fakeCloner) StartClone(ctx context.Context, source, newid string) error {
+	f.started = append(f.started, newid)
+	return f.startErr
+}
+
+// cloneDriver lists e2eClient's guests with a preset cloning web-1 and a
+// fake cloner
+func cloneDriver(t *testing.T, presets ...config.ClonePreset) (*driver, *fakeCloner) {
+	t.Helper()
+	client := e2eClient()
+	d := newDriver(t, client)
+	d.send(tea.WindowSizeMsg{Width: 120, Height: 24})
+	if len(presets) == 0 {
+		presets = []config.ClonePreset{{Name: "web", SourceVMID: 100, NamePattern: "web-{n}", Start: true}}
+	}
+	d.ml.appConfig = &config.Config{ClonePresets: presets}
+	cloner := &fakeCloner{client: client}
+	d.ml.cloner = cloner
+	return d, cloner
+}
+
+func TestClone(t *testing.T) {
+	d, cloner := cloneDriver(t)
+	d.key("+")
+	view := d.ml.model.View()
+	if !strings.Contains(view, "Clone presets (1)") || !strings.Contains(view, "web-1 (100)") || !strings.Contains(view, "web-3") {
+		t.Fatalf("Expected the preset with the name after web-1 and web-2:\n%s", view)
+	}
+
+	d.key("enter")
+	if len(cloner.cloned) != 1 || cloner.cloned[0].Name != "web-3" || cloner.cloned[0].NewID != "105" {
+		t.Fatalf("Expected web-3 cloned as 105, got %+v", cloner.cloned)
+	}
+	if len(cloner.started) != 1 {
+		t.Errorf("The preset starts the clone, got %v", cloner.started)
+	}
+	if bar := statusBar(d); !strings.Contains(bar, "Cloned web-1 (100) as web-3 (105). - Press any key") {
+		t.Errorf("Expected the clone reported:\n%s", bar)
+	}
+	if got := d.ml.SelectedVMID(); got != "105" {
+		t.Errorf("Expected the cursor on the clone, got %q", got)
+	}
+
+	d.key("x")
+	if d.ml.model.clone != nil {
+		t.Error("Any key should dismiss the result")
+	}
+}
+
+func TestClone_Failures(t *testing.T) {
+	d, cloner := cloneDriver(t)
+	cloner.cloneErr = errors.New("storage 'local' does not support linked clones")
+	d.key("+", "enter")
+	if bar := statusBar(d); !strings.Contains(bar, "failed requesting the clone: storage 'local' does not support linked clones") {
+		t.Errorf("Expected the failed stage in the status bar:\n%s", bar)
+	}
+
+	// A clone that fails to start still exists
+	d.key("x")
+	cloner.cloneErr = nil
+	cloner.startErr = errors.New("no quorum")
+	d.key("+", "enter")
+	if bar := statusBar(d); !strings.Contains(bar, "Cloned web-1 (100) as web-3 (105), failed starting the clone: no quorum") {
+		t.Errorf("Expected the clone reported with its failed start:\n%s", bar)
+	}
+	if got := d.ml.SelectedVMID(); got != "105" {
+		t.Errorf("Expected the cursor on the clone, got %q", got)
+	}
+
+	d, cloner = cloneDriver(t, config.ClonePreset{Name: "gone", SourceVMID: 999, NamePattern: "x-{n}"})
+	d.key("+", "enter")
+	if bar := statusBar(d); !strings.Contains(bar, "guest 999 is not listed") || len(cloner.cloned) != 0 {
+		t.Errorf("Expected a missing source to be reported:\n%s", bar)
+	}
+}
+
+func TestClone_NoPresets(t *testing.T) {
+	d, cloner := cloneDriver(t)
+	d.ml.appConfig = nil
+	d.key("+")
+	if view := d.ml.model.View(); !strings.Contains(view, "No clone_presets configured") {
+		t.Errorf("Expected the empty screen:\n%s", view)
+	}
+	d.key("enter", "esc")
+	if d.ml.model.clonePicker != nil || len(cloner.cloned) != 0 {
+		t.Error("ESC should close the screen without cloning")
+	}
+}
@@ -0,0 +1,84 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+	"github.com/tsupplis/pvec/pkg/redact"
+)
+
+// cloudInitState is a regeneration of the cloud-init image of the guest
+// shown in the details dialog, asked for and possibly running
+type cloudInitState struct {
+	vm      *models.VMStatus
+	drive   string
+	storage string
+	running bool
+}
+
+// cloudInitMsg reports the outcome of a regeneration
+type cloudInitMsg struct {
+	key string
+	err error
+}
+
+// handleCloudInitKey asks to regenerate the cloud-init image of the VM
+// whose details are open
+func (m *listModel) handleCloudInitKey() (bool, tea.Model, tea.Cmd) {
+	ci, ok := configparse.ParseCloudInit(m.detailsConfig)
+	switch {
+	case m.detailsVM.Type != models.TypeVM || !ok || ci.Drive == "":
+		m.detailsState.Notice = "This VM has no cloud-init drive"
+	case m.parent.cloudInitManager == nil:
+		m.detailsState.Notice = "Cloud-init regeneration is not available"
+	default:
+		m.cloudInit = &cloudInitState{vm: m.detailsVM, drive: ci.Drive, storage: ci.Storage}
+		m.detailsState.Notice = fmt.Sprintf("Regenerate the cloud-init image of %s (%s)? (y/n)",
+			m.detailsVM.Name, m.detailsVM.VMID)
+	}
+	return true, m, nil
+}
+
+// handleCloudInitKeys confirms or cancels the regeneration; keys are
+// ignored while it runs
+func (m *listModel) handleCloudInitKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	if m.cloudInit.running {
+		return true, m, nil
+	}
+	if key := msg.String(); key != "y" && key != "Y" {
+		m.cloudInit = nil
+		m.detailsState.Notice = ""
+		return true, m, nil
+	}
+
+	m.cloudInit.running = true
+	m.detailsState.Notice = "Regenerating the cloud-init image..."
+	client, ci, timeout := m.parent.cloudInitManager, *m.cloudInit, m.parent.actionTimeout()
+	return true, m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		err := client.RegenerateCloudInit(ctx, ci.vm.Node, ci.vm.VMID, ci.drive, ci.storage)
+		return cloudInitMsg{key: ci.vm.Key(), err: err}
+	}
+}
+
+// handleCloudInitResult shows the outcome in the details status bar
+func (m *listModel) handleCloudInitResult(msg cloudInitMsg) (tea.Model, tea.Cmd) {
+	if m.cloudInit == nil || m.cloudInit.vm.Key() != msg.key {
+		return m, nil
+	}
+	m.cloudInit = nil
+	switch {
+	case proxmox.PermissionHint(msg.err) != "":
+		m.detailsState.Notice = fmt.Sprintf("Failed to regenerate cloud-init: the token %s", proxmox.PermissionHint(redact.Error(msg.err)))
+	case msg.err != nil:
+		m.detailsState.Notice = fmt.Sprintf("Failed to regenerate cloud-init: %v", redact.Error(msg.err))
+	default:
+		m.detailsState.Notice = "Cloud-init image regenerated; the VM picks it up at its next boot"
+	}
+	return m, nil
+}
@@ -0,0 +1,132 @@
+package mainlist
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// cloudInitConfig is a VM cloned from a cloud-init template
+var cloudInitConfig = map[string]interface{}{
+	"ide2":       "local-lvm:vm-100-cloudinit,media=cdrom",
+	"scsi0":      "local-lvm:vm-100-disk-0,size=32G",
+	"ciuser":     "admin",
+	"cipassword": "$5$rounds=5000$secret",
+	"ipconfig0":  "ip=10.0.0.5/24,gw=10.0.0.1",
+	"sshkeys":    "ssh-ed25519%20AAAAC3NzaC1lZDI1NTE5AAAAIDPyhInKSZyNL1hZ5EhV6XtKp6c38F3agDaVWgDGoXIg%20alice%40laptop",
+}
+
+// openDetailsOf opens the details dialog of the guest with the given VMID
+func openDetailsOf(t *testing.T, d *driver, vmid string) {
+	t.Helper()
+	d.key("g")
+	for i := 0; i < len(d.ml.sortedNodes); i++ {
+		if d.ml.sortedNodes[d.ml.selectedIdx].VMID == vmid {
+			d.key("enter")
+			return
+		}
+		d.key("down")
+	}
+	t.Fatalf("Guest %s is not listed", vmid)
+}
+
+func newCloudInitDriver(t *testing.T) (*driver, *MockClient) {
+	client := e2eClient()
+	client.Configs = map[string]map[string]interface{}{
+		"100": cloudInitConfig,
+		"200": {"nameserver": "10.0.0.1", "rootfs": "local-lvm:vm-200-disk-0,size=8G"},
+	}
+	d := newDriver(t, client)
+	d.ml.cloudInitManager = client
+	return d, client
+}
+
+func TestCloudInit_Section(t *testing.T) {
+	d, _ := newCloudInitDriver(t)
+	d.send(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	openDetailsOf(t, d, "100")
+	view := d.ml.model.View()
+	for _, want := range []string{
+		"-- cloud-init --",
+		"drive              : ide2 on local-lvm",
+		"user               : admin",
+		"password           : set (hidden)",
+		"ipconfig0          : ip=10.0.0.5/24,gw=10.0.0.1",
+		"ssh keys           : 1 key",
+		"ssh-ed25519    : SHA256:KKbfdyaix0Bqkm3oO6PxUbZBEn0I2rq/PPBSD1Z0XhI alice@laptop",
+		"C=Cloud-init",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+	if strings.Contains(view, "secret") || strings.Contains(view, "AAAAC3Nza") {
+		t.Errorf("Neither the password nor the key material may show:\n%s", view)
+	}
+
+	// Container settings named like cloud-init ones stay in the options
+	d.key("esc")
+	openDetailsOf(t, d, "200")
+	if view := d.ml.model.View(); strings.Contains(view, "cloud-init") || !strings.Contains(view, "nameserver") {
+		t.Errorf("A container has no cloud-init section:\n%s", view)
+	}
+}
+
+func TestCloudInit_Regenerate(t *testing.T) {
+	d, client := newCloudInitDriver(t)
+	d.send(tea.WindowSizeMsg{Width: 120, Height: 24})
+	openDetailsOf(t, d, "100")
+
+	d.key("C")
+	if view := d.ml.model.View(); !strings.Contains(view, "Regenerate the cloud-init image of web-1 (100)? (y/n)") {
+		t.Fatalf("Expected the confirmation:\n%s", view)
+	}
+	d.key("n")
+	if len(client.Regenerated) != 0 || d.ml.model.cloudInit != nil {
+		t.Fatal("Any other key should cancel")
+	}
+
+	d.key("C", "y")
+	if got := strings.Join(client.Regenerated, ","); got != "100 ide2 local-lvm" {
+		t.Errorf("Expected the drive to be passed along, got %q", got)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "Cloud-init image regenerated") {
+		t.Errorf("Expected the outcome:\n%s", view)
+	}
+	d.key("down")
+	if view := d.ml.model.View(); strings.Contains(view, "regenerated") {
+		t.Errorf("The notice should go with the next key:\n%s", view)
+	}
+
+	client.ActionErr = deniedCloudInit
+	d.key("C", "y")
+	if view := d.ml.model.View(); !strings.Contains(view, "Failed to regenerate cloud-init: the token needs VM.Config.Cloudinit on /vms/100") {
+		t.Errorf("Expected the missing privilege:\n%s", view)
+	}
+}
+
+func TestCloudInit_NoDrive(t *testing.T) {
+	d, client := newCloudInitDriver(t)
+	client.Configs["101"] = map[string]interface{}{"scsi0": "local-lvm:vm-101-disk-0,size=32G"}
+	openDetailsOf(t, d, "101")
+
+	d.key("C")
+	if view := d.ml.model.View(); !strings.Contains(view, "This VM has no cloud-init drive") {
+		t.Errorf("Expected the missing drive:\n%s", view)
+	}
+	if d.ml.model.cloudInit != nil {
+		t.Error("Nothing should be asked")
+	}
+}
+
+// deniedCloudInit is the 403 Proxmox returns without VM.Config.Cloudinit
+var deniedCloudInit = &proxmox.APIError{
+	StatusCode: http.StatusForbidden,
+	Method:     "PUT",
+	Path:       "/nodes/pve1/qemu/100/cloudinit",
+	Body:       `{"data":null,"message":"Permission check failed (/vms/100, VM.Config.Cloudinit)\n"}`,
+}
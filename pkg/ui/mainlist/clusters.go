@@ -0,0 +1,78 @@
+package mainlist
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// clusterWidth is the width of the Cluster column shown when several
+// clusters are listed
+const clusterWidth = 8
+
+// unreachableStyle greys out the guests of a cluster that didn't answer
+var unreachableStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#808080"))
+
+// NewConfiguredClient creates the client cfg describes: an aggregate of
+// every cluster when cfg lists clusters, or a single cluster's. The
+// requests of every cluster are reported to observer, which may be nil.
+func NewConfiguredClient(cfg *config.Config, observer proxmox.RequestObserver) (proxmox.Client, error) {
+	newClient := func(apiURL, token string, skipTLSVerify bool, fingerprint string) proxmox.Client {
+		client := proxmox.NewClient(apiURL, token, skipTLSVerify)
+		if fingerprint != "" {
+			client = proxmox.NewPinnedClient(apiURL, token, fingerprint)
+		}
+		if c, ok := client.(*proxmox.HTTPClient); ok && observer != nil {
+			c.SetObserver(observer)
+		}
+		return client
+	}
+	if len(cfg.Clusters) == 0 {
+		return newClient(cfg.APIUrl, cfg.GetAuthToken(), cfg.SkipTLSVerify, cfg.TLSFingerprint), nil
+	}
+	clusters := make([]proxmox.Cluster, len(cfg.Clusters))
+	for i, c := range cfg.Clusters {
+		clusters[i] = proxmox.Cluster{
+			Name:   c.Name,
+			Client: newClient(c.APIUrl, c.GetAuthToken(), c.SkipTLSVerify, c.TLSFingerprint),
+		}
+	}
+	return proxmox.NewAggregate(clusters)
+}
+
+// NewConfiguredProvider creates the provider of a refresh reading from
+// reader, with the optional sections cfg's features block sets, and
+// the others detected
+func NewConfiguredProvider(cfg *config.Config, reader proxmox.StatusReader) *proxmox.Provider {
+	p := proxmox.NewProvider(reader)
+	p.Features = proxmox.NewFeatureSet(proxmox.FeatureSettings(cfg.FeatureSettings()))
+	return p
+}
+
+// clusterNotice describes the clusters that failed the last refresh, or
+// returns "" when all answered or a single cluster is listed
+func (ml *MainList) clusterNotice() string {
+	if ml.clusterHealth == nil {
+		return ""
+	}
+	errs := ml.clusterHealth.ClusterErrors()
+	if len(errs) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("Cluster %s unreachable: %v", name, redact.Error(errs[name]))
+	}
+	return strings.Join(parts, "; ") + format.Text(" — its guests show their last known state")
+}
@@ -0,0 +1,197 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/internal/testutil"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// newClustersDriver lists two clusters that both have a guest 100
+func newClustersDriver(t *testing.T) (*driver, *MockClient, *MockClient) {
+	t.Helper()
+	format.SetColor(false)
+	t.Cleanup(func() { format.SetColor(true) })
+
+	lab := &MockClient{MockDataProvider: MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "100", VMIDNum: 100, Name: "lab-web", Type: "qemu", Status: "stopped", Node: "pve1"},
+	}}}
+	prod := &MockClient{MockDataProvider: MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "100", VMIDNum: 100, Name: "prod-web", Type: "qemu", Status: "stopped", Node: "node-a"},
+		{VMID: "101", VMIDNum: 101, Name: "prod-db", Type: "qemu", Status: "running", Node: "node-b"},
+	}}}
+	aggregate, err := proxmox.NewAggregate([]proxmox.Cluster{{Name: "lab", Client: lab}, {Name: "prod", Client: prod}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ml := NewMainList(Config{Provider: aggregate, Reader: aggregate, Power: aggregate, ClusterHealth: aggregate})
+	clock := testutil.NewFakeClock(e2eNow)
+	ml.clock = clock
+	d := &driver{t: t, ml: ml, clock: clock}
+	d.send(tea.WindowSizeMsg{Width: 100, Height: 24})
+	d.send(ml.fetchNodes(context.Background()))
+	return d, lab, prod
+}
+
+func TestClusters_DuplicateVMIDs(t *testing.T) {
+	d, _, _ := newClustersDriver(t)
+
+	if got := d.ml.guests.Count(); got != 3 {
+		t.Fatalf("Guest 100 of each cluster should both be listed, got %d guests", got)
+	}
+	view := d.ml.model.View()
+	for _, want := range []string{"Cluster", "lab-web", "prod-web"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q in the view:\n%s", want, view)
+		}
+	}
+	selectGuest(t, d, "prod/100")
+	if bar := statusBar(d); !strings.Contains(bar, "prod/100 prod-web") {
+		t.Errorf("The status bar should name the guest's cluster:\n%s", bar)
+	}
+}
+
+func TestClusters_ActionRouting(t *testing.T) {
+	d, lab, prod := newClustersDriver(t)
+
+	selectGuest(t, d, "prod/100")
+	d.key("s")
+
+	if got := strings.Join(prod.Started, ","); got != "100" {
+		t.Errorf("Expected prod to start its guest 100, got %q", got)
+	}
+	if len(lab.Started) != 0 {
+		t.Errorf("Nothing should reach lab, started %v", lab.Started)
+	}
+}
+
+func TestClusters_ClusterDown(t *testing.T) {
+	d, lab, _ := newClustersDriver(t)
+
+	lab.Err = errors.New("connection refused")
+	d.send(d.ml.fetchNodes(context.Background()))
+
+	view := d.ml.model.View()
+	if !strings.Contains(view, "Cluster lab unreachable: connection refused") {
+		t.Errorf("The banner should name the cluster that is down:\n%s", view)
+	}
+	if !strings.Contains(view, "lab-web") {
+		t.Errorf("The guests of a cluster that is down should stay listed:\n%s", view)
+	}
+	if guest, ok := d.ml.guests.Get("lab/100"); !ok || !guest.Unreachable {
+		t.Errorf("lab/100 should be marked unreachable, got %+v", guest)
+	}
+
+	lab.Err = nil
+	d.send(d.ml.fetchNodes(context.Background()))
+	if view := d.ml.model.View(); strings.Contains(view, "unreachable") {
+		t.Errorf("The banner should clear once the cluster answers:\n%s", view)
+	}
+}
+
+func TestClusters_SingleClusterHasNoColumn(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	if view := d.ml.model.View(); strings.Contains(view, "Cluster") {
+		t.Errorf("The Cluster column is only shown for several clusters:\n%s", view)
+	}
+}
+
+func TestNewConfiguredClient(t *testing.T) {
+	client, err := NewConfiguredClient(&config.Config{APIUrl: "https://pve:8006", TokenID: "u@pam!t", TokenSecret: "s"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.(proxmox.ClusterHealth); ok {
+		t.Error("A single cluster should get a plain client")
+	}
+
+	client, err = NewConfiguredClient(&config.Config{Clusters: []config.ClusterConfig{
+		{Name: "lab", APIUrl: "https://lab:8006", TokenID: "u@pam!t", TokenSecret: "s"},
+		{Name: "prod", APIUrl: "https://prod:8006", TokenID: "u@pam!t", TokenSecret: "s"},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.(proxmox.ClusterHealth); !ok {
+		t.Errorf("Clusters should get an aggregate, got %T", client)
+	}
+}
+
+func TestNewConfiguredClient_Observer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	requests := proxmox.NewRequestLog(10)
+	client, err := NewConfiguredClient(&config.Config{Clusters: []config.ClusterConfig{
+		{Name: "lab", APIUrl: server.URL, TokenID: "u@pam!t", TokenSecret: "s"},
+		{Name: "prod", APIUrl: server.URL, TokenID: "u@pam!t", TokenSecret: "s"},
+	}}, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetNodes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(requests.Entries()); got != 2 {
+		t.Errorf("Expected the request to each cluster logged, got %d", got)
+	}
+}
+
+func TestNewConfiguredClient_Pinned(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{APIUrl: server.URL, TokenID: "u@pam!t", TokenSecret: "s", TLSFingerprint: proxmox.Fingerprint(server.Certificate())}
+	client, err := NewConfiguredClient(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetNodes(context.Background()); err != nil {
+		t.Errorf("The pinned certificate should be trusted: %v", err)
+	}
+
+	cfg.TLSFingerprint = strings.Repeat("00", 32)
+	client, _ = NewConfiguredClient(cfg, nil)
+	if _, err := client.GetNodes(context.Background()); !errors.As(err, new(*proxmox.FingerprintMismatchError)) {
+		t.Errorf("Another certificate should be rejected, got %v", err)
+	}
+}
+
+func TestNewConfiguredProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/pools") {
+			t.Errorf("A source turned off should not be read: %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{APIUrl: server.URL, TokenID: "u@pam!t", TokenSecret: "s", Features: map[string]bool{"pools": false}}
+	client, err := NewConfiguredClient(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewConfiguredProvider(cfg, client)
+	if snap := p.Snapshot(context.Background()); len(snap.Errors) > 0 {
+		t.Fatal(snap.Errors)
+	}
+	for _, c := range p.Capabilities() {
+		if want := c.Feature != proxmox.FeaturePools; c.Enabled != want || c.Configured == want {
+			t.Errorf("Expected only pools configured off, got %+v", c)
+		}
+	}
+}
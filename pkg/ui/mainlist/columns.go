@@ -0,0 +1,377 @@
+package mainlist
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// columnID identifies a column of the main list
+type columnID int
+
+const (
+	colStatus columnID = iota
+	colVMID
+	colName
+	colType
+	colNode
+	colCPU
+	colMem
+	colDisk
+	colUptime
+	colCluster   // Only with several clusters
+	colAlloc     // Optional, toggled with a
+	colNet       // Optional, toggled with v
+	colOwner     // Optional, toggled with w
+	colSince     // Optional, toggled with D
+	colFlags     // Optional, toggled with F
+	colMigration // Only while a guest is being migrated
+)
+
+// column describes a column of the main list: the header and every row
+// are built from the same columns, so they always line up
+type column struct {
+	id    columnID
+	title string // Header
+	label string // Sort label shown in the title
+	width int
+	right bool // Right aligned, as numbers are
+}
+
+// columns lists every column in display order; the Name width depends on
+// whether color is on and is filled in by visibleColumns
+var columns = []column{
+	{id: colStatus, title: "Status", label: "status", width: 7},
+	{id: colVMID, title: "VMID", label: "vmid", width: 6},
+	{id: colName, title: "Name", label: "name"},
+	{id: colType, title: "Type", label: "type/name", width: 4},
+	{id: colNode, title: "Node", label: "node", width: nodeWidth},
+	{id: colCPU, title: "CPU%", label: "cpu", width: 6, right: true},
+	{id: colMem, title: "Memory%", label: "memory", width: 7, right: true},
+	{id: colDisk, title: "Disk%", label: "disk", width: 5, right: true},
+	{id: colUptime, title: "Uptime", label: "uptime", width: 8, right: true},
+	{id: colCluster, title: "Cluster", label: "cluster", width: clusterWidth},
+	{id: colAlloc, title: "Alloc", label: "alloc", width: 8, right: true},
+	{id: colNet, title: "Net", label: "net", width: netWidth},
+	{id: colOwner, title: "Owner", label: "owner", width: ownerWidth},
+	{id: colSince, title: "Since", label: "since", width: sinceWidth, right: true},
+	{id: colFlags, title: "Flags", label: "flags", width: flagsWidth},
+	{id: colMigration, title: "Migration", label: "migration", width: migrationWidth},
+}
+
+// columnLabel returns the sort label of a column
+func columnLabel(id columnID) string {
+	for _, c := range columns {
+		if c.id == id {
+			return c.label
+		}
+	}
+	return ""
+}
+
+// visibleColumns returns the columns shown, in display order
+func (ml *MainList) visibleColumns() []column {
+	visible := make([]column, 0, len(columns))
+	for _, c := range columns {
+		switch c.id {
+		case colName:
+			c.width = nameWidth()
+		case colCPU:
+			if ml.showNodeLoad() {
+				c.width += nodeLoadWidth
+			}
+		case colCluster:
+			if ml.clusterHealth == nil {
+				continue
+			}
+		case colAlloc:
+			if !ml.showDiskAlloc {
+				continue
+			}
+		case colNet:
+			if !ml.showNet {
+				continue
+			}
+		case colOwner:
+			if !ml.showOwner {
+				continue
+			}
+		case colSince:
+			if !ml.showSince {
+				continue
+			}
+		case colFlags:
+			if !ml.showFlags {
+				continue
+			}
+		case colMigration:
+			if !ml.showMigration() {
+				continue
+			}
+		}
+		visible = append(visible, c)
+	}
+	return ml.fitColumns(visible)
+}
+
+// fitColumns drops the optional columns, last first, that would push the
+// rows past the terminal; the fixed ones fill 80 columns
+func (ml *MainList) fitColumns(visible []column) []column {
+	if ml.model == nil || ml.model.width <= 0 {
+		return visible
+	}
+	width := ml.model.width
+	used := len(visible) - 1 // Separators
+	if !format.Color() {
+		used += markerWidth
+	}
+	for _, c := range visible {
+		used += c.width
+	}
+	for i := len(visible) - 1; i >= 0 && used > width; i-- {
+		if visible[i].id < colCluster {
+			break
+		}
+		used -= visible[i].width + 1
+		visible = slices.Delete(visible, i, i+1)
+	}
+	return visible
+}
+
+// align fits a cell to the column, truncating it when it is too wide
+func (c column) align(text string) string {
+	text = format.Truncate(text, c.width)
+	if c.right {
+		return format.Repeat(" ", c.width-lipgloss.Width(text)) + text
+	}
+	return format.Pad(text, c.width)
+}
+
+// header returns the column's header, with an arrow when the list is
+// sorted on it; the title gives up its last letters to the arrow when the
+// column is too narrow for both
+func (c column) header(order sortOrder) string {
+	if order.column != c.id {
+		return c.align(c.title)
+	}
+	arrow := format.Text("↑")
+	if order.desc {
+		arrow = format.Text("↓")
+	}
+	title := []rune(c.title)
+	if room := c.width - lipgloss.Width(arrow); len(title) > room {
+		title = title[:max(room, 0)]
+	}
+	return c.align(string(title) + arrow)
+}
+
+// headerText returns the header line of the main list
+func (ml *MainList) headerText() string {
+	order := ml.activeSort()
+	var cells []string
+	for _, c := range ml.visibleColumns() {
+		cells = append(cells, c.header(order))
+	}
+	return strings.Join(cells, " ")
+}
+
+// cellText returns the text of a guest's cell in a column
+func (m *listModel) cellText(id columnID, node *models.VMStatus, duplicate bool) string {
+	ml := m.parent
+	switch id {
+	case colStatus:
+		return statusText(node)
+	case colVMID:
+		return node.VMID
+	case colName:
+		return nameText(node, duplicate, ml.wedgedText(node) != "", ml.hostnameDiffers(node))
+	case colType:
+		if node.Type == models.TypeContainer {
+			return "CT"
+		}
+		return "VM"
+	case colNode:
+		return node.Node
+	case colCPU:
+		// Absurd values are clamped and flagged so a broken report can't
+		// push the other columns off screen
+		if !cpuKnown(node) {
+			return "-" // Never seen while its node was online
+		}
+		return format.Percent(node.CPUUsage, 1) + ml.nodeLoadText(node)
+	case colMem:
+		if !node.HasMemoryUsage() {
+			return "-"
+		}
+		return format.Percent(node.MemoryUsage, 1)
+	case colDisk:
+		// Disk usage is only meaningful for containers
+		if !node.HasDiskUsage() {
+			return "-"
+		}
+		return format.Percent(node.DiskUsage(), 0)
+	case colUptime:
+		if !node.IsKnown(models.MetricUptime) {
+			return "-"
+		}
+		return format.Uptime(ml.liveUptime(node, ml.now()))
+	case colCluster:
+		return node.Cluster
+	case colAlloc:
+		return ml.diskAllocText(node.Key())
+	case colNet:
+		return ml.netText(node.Key())
+	case colOwner:
+		return ml.ownerText(node.Key())
+	case colSince:
+		return ml.sinceText(node, ml.now())
+	case colFlags:
+		return ml.flagsText(node)
+	case colMigration:
+		return ml.migrationText(node)
+	}
+	return ""
+}
+
+// cpuKnown reports whether the CPU usage of a guest is a reading: the API
+// zeroes it for a guest in an unknown state
+func cpuKnown(node *models.VMStatus) bool {
+	return !node.IsUnknown()
+}
+
+// sorter orders guests on a column: compare ranks two guests with a known
+// value, and guests without one, for which known is false, come last
+// whichever the direction
+type sorter struct {
+	compare func(a, b *models.VMStatus) int
+	known   func(node *models.VMStatus) bool
+}
+
+// sorter returns how guests are ordered on a column
+func (ml *MainList) sorter(id columnID) sorter {
+	switch id {
+	case colStatus:
+		return sorter{compare: func(a, b *models.VMStatus) int {
+			return strings.Compare(statusText(a), statusText(b))
+		}}
+	case colVMID:
+		return sorter{compare: compareVMID}
+	case colName:
+		return sorter{compare: func(a, b *models.VMStatus) int { return strings.Compare(a.Name, b.Name) }}
+	case colType:
+		return sorter{compare: func(a, b *models.VMStatus) int { return cmp.Compare(typeRank(a), typeRank(b)) }}
+	case colNode:
+		return sorter{compare: func(a, b *models.VMStatus) int { return strings.Compare(a.Node, b.Node) }}
+	case colCPU:
+		return sorter{
+			compare: func(a, b *models.VMStatus) int { return cmp.Compare(a.CPUUsage, b.CPUUsage) },
+			known:   cpuKnown,
+		}
+	case colMem:
+		return sorter{
+			compare: func(a, b *models.VMStatus) int { return cmp.Compare(a.MemoryUsage, b.MemoryUsage) },
+			known:   (*models.VMStatus).HasMemoryUsage,
+		}
+	case colDisk:
+		return sorter{
+			compare: func(a, b *models.VMStatus) int { return cmp.Compare(a.DiskUsage(), b.DiskUsage()) },
+			known:   (*models.VMStatus).HasDiskUsage,
+		}
+	case colUptime:
+		// Guests that are not running have no uptime
+		return sorter{
+			compare: func(a, b *models.VMStatus) int { return cmp.Compare(a.Uptime, b.Uptime) },
+			known:   (*models.VMStatus).IsRunning,
+		}
+	case colCluster:
+		return sorter{compare: func(a, b *models.VMStatus) int { return strings.Compare(a.Cluster, b.Cluster) }}
+	case colAlloc:
+		return sorter{
+			compare: func(a, b *models.VMStatus) int { return cmp.Compare(ml.diskAlloc[a.Key()], ml.diskAlloc[b.Key()]) },
+			known:   func(node *models.VMStatus) bool { return ml.diskAlloc[node.Key()] > 0 },
+		}
+	case colNet:
+		return sorter{
+			compare: func(a, b *models.VMStatus) int {
+				return strings.Compare(ml.nics[a.Key()][0].Summary(), ml.nics[b.Key()][0].Summary())
+			},
+			known: func(node *models.VMStatus) bool { return len(ml.nics[node.Key()]) > 0 },
+		}
+	case colOwner:
+		return sorter{
+			compare: func(a, b *models.VMStatus) int {
+				return strings.Compare(strings.ToLower(ml.owners[a.Key()]), strings.ToLower(ml.owners[b.Key()]))
+			},
+			known: func(node *models.VMStatus) bool { return ml.owners[node.Key()] != "" },
+		}
+	case colSince:
+		return sorter{
+			compare: ml.compareSince,
+			known: func(node *models.VMStatus) bool {
+				_, ok := ml.sinceAge(node, ml.now())
+				return ok
+			},
+		}
+	case colFlags:
+		return sorter{
+			compare: func(a, b *models.VMStatus) int {
+				return cmp.Compare(ml.flags[a.Key()].Count(), ml.flags[b.Key()].Count())
+			},
+			known: func(node *models.VMStatus) bool {
+				_, ok := ml.flags[node.Key()]
+				return ok
+			},
+		}
+	case colMigration:
+		return ml.migrationSorter()
+	}
+	return sorter{compare: func(a, b *models.VMStatus) int { return 0 }}
+}
+
+// less returns the ordering of the list for a sort order; ties are broken
+// by name then VMID, whichever the direction
+func (ml *MainList) less(order sortOrder) func(a, b *models.VMStatus) bool {
+	s := ml.sorter(order.column)
+	return func(a, b *models.VMStatus) bool {
+		// Guests in an unknown state lead whatever the order, so they're noticed
+		if a.IsUnknown() != b.IsUnknown() {
+			return a.IsUnknown()
+		}
+		aKnown, bKnown := s.known == nil || s.known(a), s.known == nil || s.known(b)
+		if aKnown != bKnown {
+			return aKnown
+		}
+		if aKnown {
+			if c := s.compare(a, b); c != 0 {
+				return (c < 0) != order.desc
+			}
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return lessVMID(a, b)
+	}
+}
+
+// typeRank puts containers before VMs
+func typeRank(node *models.VMStatus) int {
+	if node.Type == models.TypeContainer {
+		return 0
+	}
+	return 1
+}
+
+// compareVMID ranks guests by VMID as lessVMID orders them
+func compareVMID(a, b *models.VMStatus) int {
+	switch {
+	case lessVMID(a, b):
+		return -1
+	case lessVMID(b, a):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+package mainlist
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+func TestSortKeys(t *testing.T) {
+	d := newDriver(t, e2eClient())
+
+	// Node, CPU, then Memory%, too narrow for its arrow
+	d.key(">", ">", ">", "o")
+	lines := strings.Split(d.ml.model.View(), "\n")
+	if !strings.Contains(lines[0], "[sort: memory desc]") {
+		t.Errorf("Expected the sort in the title:\n%s", lines[0])
+	}
+	if !strings.Contains(lines[1], " Memory↓ ") {
+		t.Errorf("Expected the arrow in the header:\n%s", lines[1])
+	}
+	if first := d.ml.sortedNodes[0]; first.VMID != "102" {
+		t.Errorf("Expected db, using the most memory, first, got %s", first.VMID)
+	}
+
+	d.key("o")
+	if got := d.ml.sortOrder; got != (sortOrder{column: colMem}) {
+		t.Errorf("Expected o to reverse the order again, got %v", got)
+	}
+
+	d.key("<", "<", "<")
+	if got := d.ml.sortOrder; got != defaultSort {
+		t.Errorf("Expected < to go back to type/name, got %v", got)
+	}
+	if view := d.ml.model.View(); strings.Contains(view, "[sort:") {
+		t.Errorf("The default sort is not shown in the title:\n%s", view)
+	}
+}
+
+func TestColumnHeader(t *testing.T) {
+	format.SetUnicode(false)
+	defer format.SetUnicode(true)
+
+	disk := column{id: colDisk, title: "Disk%", width: 5, right: true}
+	if got := disk.header(sortOrder{column: colDisk, desc: true}); got != "Diskv" {
+		t.Errorf("Expected the title cut for the arrow, got %q", got)
+	}
+	if got := disk.header(defaultSort); got != "Disk%" {
+		t.Errorf("Expected no arrow off the sort column, got %q", got)
+	}
+	if got := disk.align("7%"); got != "   7%" {
+		t.Errorf("Expected numbers right aligned, got %q", got)
+	}
+}
+
+func TestHeaderMatchesRows(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.send(tea.WindowSizeMsg{Width: 160, Height: 24})
+	d.key("a", "v", "w")
+	lines := strings.Split(d.ml.model.View(), "\n")
+	want := lipgloss.Width(lines[1])
+	for _, line := range lines[3:8] {
+		if got := lipgloss.Width(line); got != want {
+			t.Errorf("Expected rows as wide as the header (%d), got %d:\n%s", want, got, line)
+		}
+	}
+}
+
+func TestVisibleColumns_FitTerminal(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.key("a", "w")
+	header := d.ml.headerText()
+	if strings.Contains(header, "Alloc") || strings.Contains(header, "Owner") {
+		t.Errorf("Optional columns past 80 columns should be dropped:\n%s", header)
+	}
+
+	// Owner is dropped first, being last
+	d.send(tea.WindowSizeMsg{Width: 95, Height: 24})
+	header = d.ml.headerText()
+	if !strings.Contains(header, "Alloc") || strings.Contains(header, "Owner") {
+		t.Errorf("Expected Alloc alone to fit in 95 columns:\n%s", header)
+	}
+	for _, line := range strings.Split(d.ml.model.View(), "\n") {
+		if w := lipgloss.Width(line); w > 95 {
+			t.Errorf("Line %d columns wide:\n%s", w, line)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package mainlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// newConfigClient lists web-1 (100), web-2 (101), scratch (102) and ct-1
+// (200), answering their configs from configs
+func newConfigClient(configs map[string]map[string]interface{}) *configClient {
+	client := &configClient{configs: configs}
+	client.Nodes = []*models.VMStatus{
+		{VMID: "100", Name: "web-1", Type: "qemu", Status: "running"},
+		{VMID: "101", Name: "web-2", Type: "qemu", Status: "running"},
+		{VMID: "102", Name: "scratch", Type: "qemu", Status: "stopped"},
+		{VMID: "200", Name: "ct-1", Type: "lxc", Status: "running"},
+	}
+	return client
+}
+
+// netConfigs give 100 two NICs, 101 one and 200 none
+func netConfigs() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"100": {"net0": "virtio=BC:24:11:00:00:01,bridge=vmbr0,tag=30", "net1": "virtio=BC:24:11:00:00:02,bridge=vmbr1"},
+		"101": {"net0": "virtio=BC:24:11:00:00:03,bridge=vmbr0"},
+		"200": {"rootfs": "local-lvm:vm-200-disk-0,size=8G"},
+	}
+}
+
+// ownerConfigs name an owner in the descriptions of 100 and 200
+func ownerConfigs() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"100": {"description": "Web front end\nOwner: alice\n"},
+		"101": {"description": "Web front end, spare"},
+		"200": {"description": "owner: bob"},
+	}
+}
+
+// flagsConfigs set the agent, onboot and protection flags in every mix
+func flagsConfigs() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"100": {"agent": "1", "onboot": float64(1), "protection": float64(1)},
+		"101": {"agent": "enabled=0", "onboot": float64(1)},
+		"102": {"name": "scratch"},
+		"200": {"hostname": "ct-1", "protection": float64(1)},
+	}
+}
+
+// TestConfigColumns covers the columns read from the guests' configs:
+// each shows a placeholder until the sweep its toggle starts reads them
+func TestConfigColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		toggle  func(m *listModel) tea.Cmd
+		text    func(ml *MainList, vmid string) string
+		header  string
+		configs map[string]map[string]interface{}
+		want    map[string]string
+	}{
+		{
+			name:    "net",
+			toggle:  (*listModel).toggleNet,
+			text:    (*MainList).netText,
+			header:  "Net",
+			configs: netConfigs(),
+			want:    map[string]string{"100": "vmbr0.30", "101": "vmbr0", "102": "-", "200": "-"},
+		},
+		{
+			name:    "owner",
+			toggle:  (*listModel).toggleOwner,
+			text:    (*MainList).ownerText,
+			header:  "Owner",
+			configs: ownerConfigs(),
+			want:    map[string]string{"100": "alice", "101": "-", "102": "-", "200": "bob"},
+		},
+		{
+			name:   "flags",
+			toggle: (*listModel).toggleFlags,
+			text: func(ml *MainList, vmid string) string {
+				g, _ := ml.guests.Get(vmid)
+				return ml.flagsText(g)
+			},
+			header:  "Flags",
+			configs: flagsConfigs(),
+			want:    map[string]string{"100": "AOP", "101": "-O-", "102": "---", "200": " -P"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newConfigClient(tt.configs)
+			ml := NewMainList(Config{Provider: client, Reader: client, Power: client})
+			ml.model.Update(ml.fetchNodes(context.Background()))
+			ml.model.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+			if strings.Contains(ml.model.View(), tt.header) {
+				t.Errorf("The %s column should be hidden by default", tt.header)
+			}
+
+			cmd := tt.toggle(ml.model)
+			if cmd == nil {
+				t.Fatal("Showing the column should start a sweep")
+			}
+			if got := tt.text(ml, "100"); got != "…" {
+				t.Errorf("An unread guest should show an ellipsis, got %q", got)
+			}
+			format.SetUnicode(false)
+			if got := tt.text(ml, "100"); got != "." {
+				t.Errorf("Without unicode an unread guest should show a dot, got %q", got)
+			}
+			format.SetUnicode(true)
+			if view := ml.model.View(); !strings.Contains(view, tt.header) {
+				t.Errorf("Expected the %s header before the sweep ends:\n%s", tt.header, view)
+			}
+
+			ml.model.Update(cmd())
+			for vmid, want := range tt.want {
+				if got := tt.text(ml, vmid); got != want {
+					t.Errorf("%s: expected %q, got %q", vmid, want, got)
+				}
+			}
+
+			// Configs read are not read again
+			calls := client.calls
+			if _, cmd := ml.model.Update(ml.fetchNodes(context.Background())); cmd != nil || client.calls != calls {
+				t.Error("Refresh should not read the configs again")
+			}
+			if tt.toggle(ml.model) != nil || strings.Contains(ml.model.View(), tt.header) {
+				t.Error("Hiding the column should drop the header")
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+)
+
+const (
+	// configSweepTimeout bounds each config fetch made by the sweep
+	configSweepTimeout = 5 * time.Second
+	// configSweepConcurrency is how many configs the sweep reads at once
+	configSweepConcurrency = 4
+	// configSweepTTL is how long a config read by the sweep stays fresh
+	configSweepTTL = 10 * time.Minute
+	// qemuHealthTTL is how long the QEMU state of a running VM read by the
+	// sweep stays fresh; a VM can wedge at any time, so it is read more
+	// often than its config
+	qemuHealthTTL = 2 * time.Minute
+)
+
+// configSweep reads the configs of the guests whose cached entries are
+// missing or expired, a few at a time, so the columns built from them
+// fill in progressively. It reads the QEMU state of running VMs along the
+// way, and their hostname from the guest agent when it is wanted.
+type configSweep struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending []sweepTask // Guests not read yet
+	done    int         // Configs read, or given up on
+	total   int         // Configs to read
+}
+
+// sweepTask is what the sweep reads of a guest
+type sweepTask struct {
+	guest    *models.VMStatus
+	config   bool // Its config
+	health   bool // Its current status, for the QEMU state of a running VM
+	hostname bool // Its guest agent's hostname, if the config read shows one runs
+}
+
+// configSweepMsg carries the configs, QEMU states and agent hostnames of
+// one batch, by guest key. Guests whose config couldn't be read are left
+// out and retried by the next sweep; a QEMU state that couldn't be read
+// is nil, and retried once expired. A hostname the agent didn't give is
+// left out and asked again with the next config read.
+type configSweepMsg struct {
+	sweep     *configSweep // Tells the current sweep's batches from a cancelled one's
+	count     int          // Configs asked for in the batch
+	configs   map[string]map[string]interface{}
+	health    map[string]*models.QEMUHealth
+	hostnames map[string]string
+}
+
+// handleConfigSweep merges a batch into the caches and reads the next one
+func (m *listModel) handleConfigSweep(msg configSweepMsg) (tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+
+	sweep := m.parent.sweep
+	if sweep == nil || msg.sweep != sweep {
+		return m, nil
+	}
+	now := m.parent.now()
+	for key, config := range msg.configs {
+		m.parent.storeConfig(key, config, now)
+	}
+	for key, health := range msg.health {
+		m.parent.storeHealth(key, health, now)
+	}
+	for key, hostname := range msg.hostnames {
+		m.parent.hostnames[key] = hostname
+	}
+	sweep.done += msg.count
+	if len(msg.configs) > 0 {
+		m.rearrange() // The text filter may now match their networks or hostnames
+	}
+	if len(sweep.pending) == 0 {
+		m.parent.cancelSweep()
+		return m, nil
+	}
+	return m, m.parent.sweepBatchCmd()
+}
+
+// storeConfig caches what the list shows of a freshly read config. Must
+// be called with refreshMutex held.
+func (ml *MainList) storeConfig(key string, config map[string]interface{}, now time.Time) {
+	ml.diskAlloc[key] = configparse.AllocatedDiskSize(config)
+	ml.nics[key] = configparse.ParseNICs(config)
+	ml.owners[key] = configparse.Owner(config, ml.ownerPattern)
+	ml.flags[key] = configparse.ParseFlags(config)
+	ml.noteVMState(key, configparse.HasVMState(config))
+	if hostname, ok := config["hostname"].(string); ok {
+		ml.hostnames[key] = hostname
+	}
+	ml.configReadAt[key] = now
+}
+
+// storeHealth caches the QEMU state of a running VM, nil when it
+// couldn't be read. Must be called with refreshMutex held.
+func (ml *MainList) storeHealth(key string, health *models.QEMUHealth, now time.Time) {
+	ml.qemuHealth[key] = health
+	ml.healthReadAt[key] = now
+}
+
+// noteHealth keeps the QEMU states that came with a refresh or a guest
+// update, and drops those of guests that no longer run, so a VM started
+// again isn't judged on its last run. Must be called with refreshMutex
+// held.
+func (ml *MainList) noteHealth(nodes []*models.VMStatus, now time.Time) {
+	for _, node := range nodes {
+		key := node.Key()
+		switch {
+		case node.Type != models.TypeVM || node.Status != models.StateRunning:
+			delete(ml.qemuHealth, key)
+			delete(ml.healthReadAt, key)
+		case node.QEMU != nil:
+			ml.storeHealth(key, node.QEMU, now)
+		}
+	}
+}
+
+// needsHealth reports whether the QEMU state of a guest is worth reading:
+// that of a running VM, when the background sweep is on, and missing or
+// expired. Must be called with refreshMutex held.
+func (ml *MainList) needsHealth(node *models.VMStatus, now time.Time) bool {
+	if !ml.configSweep || node.Type != models.TypeVM || node.Status != models.StateRunning {
+		return false
+	}
+	readAt, ok := ml.healthReadAt[node.Key()]
+	return !ok || now.Sub(readAt) > qemuHealthTTL
+}
+
+// wedgedText explains why a VM listed as running doesn't run its guest,
+// from its last QEMU state, or returns an empty string. Must be called
+// with refreshMutex held.
+func (ml *MainList) wedgedText(node *models.VMStatus) string {
+	if node.Status != models.StateRunning {
+		return ""
+	}
+	return ml.qemuHealth[node.Key()].Problem(node.Status)
+}
+
+// withHealth returns node with the last QEMU state read of it, as a copy
+// so the listed guest is left alone. Must be called with refreshMutex
+// held.
+func (ml *MainList) withHealth(node *models.VMStatus) *models.VMStatus {
+	health := ml.qemuHealth[node.Key()]
+	if node.QEMU != nil || health == nil || node.Status != models.StateRunning {
+		return node
+	}
+	copied := *node
+	copied.QEMU = health
+	return &copied
+}
+
+// wantsNICs reports whether the network of each guest is needed: for the
+// Net column, or for a text filter that may match a bridge or VLAN, or
+// an owner read from the same config
+func (ml *MainList) wantsNICs() bool {
+	return ml.showNet || ml.filter.Text != ""
+}
+
+// wantsHostnames reports whether the sweep should ask the guest agent of
+// running VMs for their hostname: with the background sweep on, to mark
+// those that differ from their name, or for a text filter that may match
+// one. Containers give theirs in their config.
+func (ml *MainList) wantsHostnames() bool {
+	return ml.configSweep || ml.filter.Text != ""
+}
+
+// wantsConfigs reports whether guest configs should be swept: always
+// when the background sweep is on, otherwise only for a column or
+// filter that needs them
+func (ml *MainList) wantsConfigs() bool {
+	return ml.configSweep || ml.showDiskAlloc || ml.showOwner || ml.showFlags || ml.wantsNICs()
+}
+
+// needsConfig reports whether the cached config data of a guest is
+// missing or expired
+func (ml *MainList) needsConfig(key string, now time.Time) bool {
+	readAt, ok := ml.configReadAt[key]
+	if !ok || now.Sub(readAt) > configSweepTTL {
+		return true
+	}
+	_, ok = ml.diskAlloc[key] // Emptied when the Alloc column is shown again
+	return ml.showDiskAlloc && !ok
+}
+
+// fillConfigsCmd starts a sweep of the listed guests whose config data or
+// QEMU state is missing or expired, unless one is running. Must be called
+// with refreshMutex held.
+func (ml *MainList) fillConfigsCmd() tea.Cmd {
+	if ml.sweep != nil || ml.reader == nil {
+		return nil
+	}
+
+	now := ml.now()
+	wantsConfigs, wantsHostnames := ml.wantsConfigs(), ml.wantsHostnames()
+	var tasks []sweepTask
+	total := 0
+	for _, node := range ml.guests.All() {
+		task := sweepTask{
+			guest:  node,
+			config: wantsConfigs && ml.needsConfig(node.Key(), now),
+			health: ml.needsHealth(node, now),
+		}
+		task.hostname = task.config && wantsHostnames && node.Type == models.TypeVM && node.IsRunning()
+		if task.config {
+			total++
+		}
+		if task.config || task.health {
+			tasks = append(tasks, task)
+		}
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ml.ctx)
+	ml.sweep = &configSweep{ctx: ctx, cancel: cancel, pending: tasks, total: total}
+	return ml.sweepBatchCmd()
+}
+
+// sweepBatchCmd reads the next batch of the running sweep concurrently.
+// Must be called with refreshMutex held.
+func (ml *MainList) sweepBatchCmd() tea.Cmd {
+	sweep, reader := ml.sweep, ml.reader
+	batch := sweep.pending[:min(configSweepConcurrency, len(sweep.pending))]
+	sweep.pending = sweep.pending[len(batch):]
+
+	return func() tea.Msg {
+		configs := make([]map[string]interface{}, len(batch))
+		read := make([]bool, len(batch))
+		health := make([]*models.QEMUHealth, len(batch))
+		hostnames := make([]string, len(batch))
+		var wg sync.WaitGroup
+		for i, task := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(sweep.ctx, configSweepTimeout)
+				defer cancel()
+				node := task.guest
+				if task.config {
+					config, err := reader.GetVMConfig(ctx, node.Node, node.TypeString(), node.Key())
+					configs[i], read[i] = config, err == nil
+				}
+				if task.hostname && read[i] && hasRunningAgent(node, configs[i]) {
+					agentCtx, cancel := context.WithTimeout(ctx, agentFSTimeout)
+					hostnames[i], _ = reader.GetAgentHostName(agentCtx, node.Node, node.Key())
+					cancel()
+				}
+				if task.health {
+					if current, err := reader.GetGuestStatus(ctx, node.Node, node.TypeString(), node.Key()); err == nil {
+						health[i] = current.QEMU
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		msg := configSweepMsg{
+			sweep:     sweep,
+			configs:   make(map[string]map[string]interface{}, len(batch)),
+			health:    make(map[string]*models.QEMUHealth),
+			hostnames: make(map[string]string),
+		}
+		for i, task := range batch {
+			key := task.guest.Key()
+			if task.config {
+				msg.count++
+			}
+			if read[i] {
+				msg.configs[key] = configs[i]
+			}
+			if task.health {
+				msg.health[key] = health[i]
+			}
+			if hostnames[i] != "" {
+				msg.hostnames[key] = hostnames[i]
+			}
+		}
+		return msg
+	}
+}
+
+// cancelSweep stops the running sweep, if any; batches in flight are
+// abandoned. Must be called with refreshMutex held.
+func (ml *MainList) cancelSweep() {
+	if ml.sweep != nil {
+		ml.sweep.cancel()
+		ml.sweep = nil
+	}
+}
+
+// sweepText returns the title tag showing the sweep's progress, or an
+// empty string when none runs. Must be called with refreshMutex held.
+func (ml *MainList) sweepText() string {
+	if ml.sweep == nil || ml.sweep.total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[syncing configs %d/%d] ", ml.sweep.done, ml.sweep.total)
+}
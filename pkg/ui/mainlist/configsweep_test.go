@@ -0,0 +1,236 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/internal/testutil"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// slowConfigClient takes a while to answer and records how many config
+// reads overlap
+type slowConfigClient struct {
+	MockClient
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+	calls       atomic.Int32
+}
+
+func (c *slowConfigClient) GetVMConfig(ctx context.Context, node, vmType, vmid string) (map[string]interface{}, error) {
+	c.calls.Add(1)
+	n := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		m := c.maxInFlight.Load()
+		if n <= m || c.maxInFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	select {
+	case <-time.After(10 * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return map[string]interface{}{"net0": "virtio=BC:24:11:00:00:01,bridge=vmbr0,tag=" + vmid}, nil
+}
+
+func newSweepList(t *testing.T, guests int, clock *testutil.FakeClock) (*MainList, *slowConfigClient) {
+	t.Helper()
+	client := &slowConfigClient{}
+	for i := 0; i < guests; i++ {
+		client.Nodes = append(client.Nodes, &models.VMStatus{
+			VMID: fmt.Sprint(100 + i), Name: fmt.Sprintf("vm-%d", i), Node: "pve1", Type: "qemu", Status: "running",
+		})
+	}
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, ConfigSweep: true})
+	ml.clock = clock
+	return ml, client
+}
+
+// runSweep feeds the batches of a sweep back into the model until it ends
+func runSweep(t *testing.T, ml *MainList, cmd tea.Cmd) {
+	t.Helper()
+	for i := 0; cmd != nil; i++ {
+		if i > 100 {
+			t.Fatal("The sweep never ends")
+		}
+		_, cmd = ml.model.Update(cmd())
+	}
+}
+
+func TestConfigSweep_Progress(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	ml, client := newSweepList(t, 6, clock)
+
+	_, cmd := ml.model.Update(ml.fetchNodes(context.Background()))
+	if cmd == nil {
+		t.Fatal("A refresh should start the sweep")
+	}
+	if view := ml.model.View(); !strings.Contains(view, "[syncing configs 0/6]") {
+		t.Errorf("Expected the progress in the title:\n%s", view)
+	}
+
+	_, cmd = ml.model.Update(cmd())
+	if view := ml.model.View(); !strings.Contains(view, "[syncing configs 4/6]") {
+		t.Errorf("Expected the first batch to count:\n%s", view)
+	}
+	if got := len(ml.nics); got != 4 {
+		t.Errorf("The first batch should be stored right away, got %d guests", got)
+	}
+
+	runSweep(t, ml, cmd)
+	if client.calls.Load() != 6 || len(ml.nics) != 6 {
+		t.Errorf("Expected every guest read once, got %d reads", client.calls.Load())
+	}
+	if got := client.maxInFlight.Load(); got > configSweepConcurrency {
+		t.Errorf("At most %d reads may overlap, got %d", configSweepConcurrency, got)
+	}
+	if view := ml.model.View(); strings.Contains(view, "syncing") {
+		t.Errorf("The progress should go once the sweep ends:\n%s", view)
+	}
+}
+
+func TestConfigSweep_TTL(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	ml, client := newSweepList(t, 2, clock)
+	_, cmd := ml.model.Update(ml.fetchNodes(context.Background()))
+	runSweep(t, ml, cmd)
+
+	clock.Advance(time.Minute)
+	if _, cmd := ml.model.Update(ml.fetchNodes(context.Background())); cmd != nil {
+		t.Error("Fresh configs should not be read again")
+	}
+
+	clock.Advance(configSweepTTL)
+	_, cmd = ml.model.Update(ml.fetchNodes(context.Background()))
+	runSweep(t, ml, cmd)
+	if got := client.calls.Load(); got != 4 {
+		t.Errorf("Expired configs should be read again, got %d reads", got)
+	}
+}
+
+func TestConfigSweep_Cancel(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	ml, _ := newSweepList(t, 6, clock)
+	_, cmd := ml.model.Update(ml.fetchNodes(context.Background()))
+	sweep := ml.sweep
+
+	ml.refreshMutex.Lock()
+	ml.cancelSweep()
+	ml.refreshMutex.Unlock()
+
+	if sweep.ctx.Err() == nil {
+		t.Error("Cancelling should abort the reads in flight")
+	}
+	if _, next := ml.model.Update(cmd()); next != nil || len(ml.nics) != 0 {
+		t.Error("A cancelled sweep's batch should be dropped")
+	}
+	if strings.Contains(ml.model.View(), "syncing") {
+		t.Error("No progress should show once cancelled")
+	}
+}
+
+func TestConfigSweep_Off(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	ml, client := newSweepList(t, 2, clock)
+	ml.configSweep = false
+
+	if _, cmd := ml.model.Update(ml.fetchNodes(context.Background())); cmd != nil {
+		t.Fatal("Without the sweep no config should be read")
+	}
+
+	// A column that needs the configs still reads them
+	runSweep(t, ml, ml.model.toggleNet())
+	if got := client.calls.Load(); got != 2 {
+		t.Errorf("Expected the Net column to read both configs, got %d reads", got)
+	}
+	if got := ml.netText("101"); got != "vmbr0.101" {
+		t.Errorf("Expected vmbr0.101, got %q", got)
+	}
+}
+
+// qemuClient reports the QEMU state of its VMs by VMID
+type qemuClient struct {
+	MockClient
+	mu     sync.Mutex
+	qmp    map[string]string
+	probed []string
+}
+
+func (c *qemuClient) GetGuestStatus(ctx context.Context, node, vmType, vmid string) (*models.VMStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.probed = append(c.probed, vmid)
+	return &models.VMStatus{VMID: vmid, Type: models.TypeVM, Status: models.StateRunning, Node: node,
+		QEMU: &models.QEMUHealth{QMPStatus: c.qmp[vmid], Balloon: models.BalloonSilent}}, nil
+}
+
+func (c *qemuClient) takeProbed() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	probed := c.probed
+	c.probed = nil
+	slices.Sort(probed)
+	return probed
+}
+
+func TestConfigSweep_QEMUHealth(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	client := &qemuClient{qmp: map[string]string{"100": "prelaunch", "101": "running"}}
+	client.Nodes = []*models.VMStatus{
+		{VMID: "100", Name: "wedged", Node: "pve1", Type: models.TypeVM, Status: models.StateRunning},
+		{VMID: "101", Name: "fine", Node: "pve1", Type: models.TypeVM, Status: models.StateRunning},
+		{VMID: "102", Name: "off", Node: "pve1", Type: models.TypeVM, Status: models.StateStopped},
+		{VMID: "200", Name: "ct", Node: "pve1", Type: models.TypeContainer, Status: models.StateRunning},
+	}
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, ConfigSweep: true})
+	ml.clock = clock
+	ml.model.Update(tea.WindowSizeMsg{Width: 120, Height: 20})
+
+	_, cmd := ml.model.Update(ml.fetchNodes(context.Background()))
+	runSweep(t, ml, cmd)
+	if got := client.takeProbed(); !slices.Equal(got, []string{"100", "101"}) {
+		t.Errorf("Expected only the running VMs probed, got %v", got)
+	}
+	view := ml.model.View()
+	if !strings.Contains(view, "!wedged") || strings.Contains(view, "!fine") {
+		t.Errorf("Expected only the VM QEMU doesn't run marked:\n%s", view)
+	}
+	ml.refreshMutex.Lock()
+	details := ml.withHealth(ml.sortedNodes[slices.IndexFunc(ml.sortedNodes, func(n *models.VMStatus) bool { return n.VMID == "100" })])
+	ml.refreshMutex.Unlock()
+	if details.QEMU == nil || details.QEMU.QMPStatus != "prelaunch" {
+		t.Errorf("Expected the details to get the QEMU state, got %+v", details.QEMU)
+	}
+
+	// The state is read again once expired, without showing as a config sync
+	clock.Advance(qemuHealthTTL + time.Second)
+	_, cmd = ml.model.Update(ml.fetchNodes(context.Background()))
+	if cmd == nil {
+		t.Fatal("Expected the expired QEMU states to be read again")
+	}
+	if strings.Contains(ml.model.View(), "syncing") {
+		t.Error("Reading QEMU states alone is no config sync")
+	}
+	runSweep(t, ml, cmd)
+	if got := client.takeProbed(); len(got) != 2 {
+		t.Errorf("Expected both running VMs probed again, got %v", got)
+	}
+
+	// A VM that stops loses its state, so its next run isn't judged on it
+	client.Nodes[0].Status = models.StateStopped
+	runSweep(t, ml, func() tea.Msg { return ml.fetchNodes(context.Background()) })
+	client.Nodes[0].Status = models.StateRunning
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	if strings.Contains(ml.model.View(), "!wedged") {
+		t.Error("A restarted VM should not keep the state of its last run")
+	}
+}
@@ -0,0 +1,29 @@
+package mainlist
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+)
+
+// toggleDiskAlloc shows or hides the Alloc column; showing it starts a
+// fresh fill since sizes may have changed while the column was hidden
+func (m *listModel) toggleDiskAlloc() tea.Cmd {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+
+	m.parent.showDiskAlloc = !m.parent.showDiskAlloc
+	if !m.parent.showDiskAlloc {
+		return nil
+	}
+	m.parent.diskAlloc = make(map[string]int64)
+	return m.parent.fillConfigsCmd()
+}
+
+// diskAllocText returns the Alloc column value for a guest
+func (ml *MainList) diskAllocText(key string) string {
+	size, ok := ml.diskAlloc[key]
+	if !ok || size <= 0 {
+		return "-"
+	}
+	return configparse.FormatSize(size)
+}
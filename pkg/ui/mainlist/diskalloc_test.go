@@ -0,0 +1,71 @@
+package mainlist
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// configClient serves a fixed config per VMID
+type configClient struct {
+	MockClient
+	configs map[string]map[string]interface{}
+	mu      sync.Mutex // The sweep reads several configs at once
+	calls   int
+}
+
+func (c *configClient) GetVMConfig(ctx context.Context, node, vmType, vmid string) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	return c.configs[vmid], nil
+}
+
+func TestDiskAllocColumn(t *testing.T) {
+	client := &configClient{configs: map[string]map[string]interface{}{
+		"100": {"scsi0": "local-lvm:vm-100-disk-0,size=32G", "ide2": "none,media=cdrom"},
+		"200": {"rootfs": "local-zfs:subvol-200-disk-0,size=8G"},
+	}}
+	client.Nodes = []*models.VMStatus{
+		{VMID: "100", Name: "web-1", Type: "qemu", Status: "running"},
+		{VMID: "200", Name: "ct-1", Type: "lxc", Status: "running"},
+	}
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	ml.model.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+
+	cmd := ml.model.toggleDiskAlloc()
+	if cmd == nil {
+		t.Fatal("Showing the column should start a fill")
+	}
+	ml.model.Update(cmd())
+
+	if client.calls != 2 {
+		t.Errorf("Expected 2 config fetches, got %d", client.calls)
+	}
+	if got := ml.diskAllocText("100"); got != "32G" {
+		t.Errorf("Expected 32G for 100, got %q", got)
+	}
+	if got := ml.diskAllocText("999"); got != "-" {
+		t.Errorf("Unknown guest should render '-', got %q", got)
+	}
+
+	view := ml.model.View()
+	if !strings.Contains(view, "Alloc") || !strings.Contains(view, "8G") {
+		t.Error("View should show the Alloc column")
+	}
+
+	// Cached sizes are not fetched again on refresh
+	_, cmd = ml.model.Update(ml.fetchNodes(context.Background()))
+	if cmd != nil {
+		t.Error("Refresh should not refetch cached sizes")
+	}
+
+	if ml.model.toggleDiskAlloc() != nil || strings.Contains(ml.model.View(), "Alloc") {
+		t.Error("Hiding the column should not fetch and should drop the header")
+	}
+}
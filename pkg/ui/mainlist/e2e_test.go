@@ -0,0 +1,251 @@
+package mainlist
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/internal/testutil"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// update rewrites the golden snapshots: go test ./pkg/ui/mainlist -run E2E -update
+var update = flag.Bool("update", false, "update the end-to-end golden snapshots")
+
+// cmdTimeout bounds how long the driver waits for a command. Ticks and
+// other timers take longer and are dropped, like a test that ends before
+// they fire.
+const cmdTimeout = 200 * time.Millisecond
+
+// driver runs the list model the way tea.Program does, but synchronously:
+// each message goes through Update and the messages produced by the
+// returned commands are fed back in until none are left
+type driver struct {
+	t     *testing.T
+	ml    *MainList
+	clock *testutil.FakeClock // Reads e2eNow until a test moves it
+	quit  bool
+}
+
+// newDriver boots a list over client at 80x24 with the initial refresh done
+func newDriver(t *testing.T, client *MockClient) *driver {
+	t.Helper()
+	format.SetColor(false) // Plain text keeps the snapshots readable
+	t.Cleanup(func() { format.SetColor(true) })
+
+	clock := testutil.NewFakeClock(e2eNow) // Keeps the uptimes and age in the snapshots fixed
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, Clock: clock})
+	d := &driver{t: t, ml: ml, clock: clock}
+	d.send(tea.WindowSizeMsg{Width: 80, Height: 24})
+	d.send(ml.fetchNodes(context.Background()))
+	return d
+}
+
+// send delivers msg and everything it leads to
+func (d *driver) send(msg tea.Msg) {
+	d.t.Helper()
+	queue := []tea.Msg{msg}
+	for len(queue) > 0 {
+		msg, queue = queue[0], queue[1:]
+		switch msg := msg.(type) {
+		case nil:
+			continue
+		case tea.QuitMsg:
+			d.quit = true
+			continue
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				queue = append(queue, run(cmd))
+			}
+			continue
+		}
+		_, cmd := d.ml.model.Update(msg)
+		queue = append(queue, run(cmd))
+	}
+}
+
+// run executes a command, giving up on slow ones such as ticks
+func run(cmd tea.Cmd) tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+	select {
+	case msg := <-done:
+		return msg
+	case <-time.After(cmdTimeout):
+		return nil
+	}
+}
+
+// key sends a key press, written the way tea.KeyMsg.String() prints it
+func (d *driver) key(keys ...string) {
+	d.t.Helper()
+	special := map[string]tea.KeyType{
+		"up": tea.KeyUp, "down": tea.KeyDown, "enter": tea.KeyEnter, "esc": tea.KeyEsc,
+		"pgdown": tea.KeyPgDown, "pgup": tea.KeyPgUp, "f1": tea.KeyF1, "f3": tea.KeyF3,
+	}
+	for _, k := range keys {
+		if typ, ok := special[k]; ok {
+			d.send(tea.KeyMsg{Type: typ})
+		} else {
+			d.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		}
+	}
+}
+
+// volatile matches the parts of a screen that change from run to run
+var volatile = regexp.MustCompile(`\d{2}:\d{2}:\d{2}`)
+
+// snapshot compares the current screen with testdata/e2e/<name>.golden
+func (d *driver) snapshot(name string) {
+	d.t.Helper()
+	got := volatile.ReplaceAllString(d.ml.model.View(), "hh:mm:ss") + "\n"
+	path := filepath.Join("testdata", "e2e", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			d.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			d.t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		d.t.Fatalf("missing snapshot, run with -update: %v", err)
+	}
+	if got != string(want) {
+		d.t.Errorf("screen %s does not match its snapshot\n--- got ---\n%s--- want ---\n%s", name, got, want)
+	}
+}
+
+// e2eNow is the driver's clock, fixed unless a test moves it
+var e2eNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// e2eClient returns a mock cluster with a mix of guests
+func e2eClient() *MockClient {
+	return &MockClient{MockDataProvider: MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "100", VMIDNum: 100, Name: "web-1", Type: "qemu", Status: "running", Node: "pve1",
+			CPUUsage: 12.5, MemoryUsage: 40, MaxMem: 4 << 30, MaxCPU: 2, Uptime: 2*86400 + 3600, MaxDisk: 32 << 30},
+		{VMID: "101", VMIDNum: 101, Name: "web-2", Type: "qemu", Status: "stopped", Node: "pve1",
+			MaxMem: 4 << 30, MaxCPU: 2, MaxDisk: 32 << 30},
+		{VMID: "102", VMIDNum: 102, Name: "db", Type: "qemu", Status: "running", Node: "pve2",
+			CPUUsage: 55, MemoryUsage: 81, MaxMem: 16 << 30, MaxCPU: 8, Uptime: 7200, MaxDisk: 128 << 30},
+		{VMID: "200", VMIDNum: 200, Name: "cache", Type: "lxc", Status: "running", Node: "pve2",
+			CPUUsage: 1.5, MemoryUsage: 20, MaxMem: 1 << 30, MaxCPU: 1, Uptime: 600, Disk: 2 << 30, MaxDisk: 8 << 30},
+		{VMID: "201", VMIDNum: 201, Name: "backup", Type: "lxc", Status: "stopped", Node: "pve1",
+			MaxMem: 1 << 30, MaxCPU: 1, MaxDisk: 8 << 30},
+	}}}
+}
+
+func TestE2E_BootAndNavigate(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.snapshot("boot")
+
+	view := d.ml.model.View()
+	rows := 0
+	for _, line := range strings.Split(view, "\n") {
+		if strings.Contains(line, "pve") {
+			rows++
+		}
+	}
+	if rows != 5 {
+		t.Errorf("Initial refresh should render 5 rows, got %d:\n%s", rows, view)
+	}
+
+	d.key("down", "down")
+	d.snapshot("navigate")
+	if got := d.ml.GetSelectedNode(); got == nil || got.VMID != "102" {
+		t.Errorf("Expected guest 102 to be selected, got %v", got)
+	}
+
+	// Moving past the end clamps
+	d.key("pgdown", "down", "down")
+	if got := d.ml.GetSelectedNode(); got == nil || got.VMID != "101" {
+		t.Errorf("Cursor should clamp to the last guest, got %v", got)
+	}
+}
+
+func TestE2E_HelpAndDetails(t *testing.T) {
+	d := newDriver(t, e2eClient())
+
+	d.key("f1")
+	d.snapshot("help")
+	d.key("esc")
+	if d.ml.model.showHelp {
+		t.Fatal("ESC should close the help dialog")
+	}
+
+	// Keys pressed in a dialog must not leak into the list
+	d.key("down", "f3")
+	d.key("down", "down", "down")
+	if got := d.ml.GetSelectedNode(); got == nil || got.VMID != "200" {
+		t.Errorf("Navigation in the details dialog moved the list cursor to %v", got)
+	}
+	d.snapshot("details")
+	d.key("esc")
+	if d.ml.model.showDetails {
+		t.Fatal("ESC should close the details dialog")
+	}
+	d.snapshot("details-closed")
+}
+
+func TestE2E_StartAction(t *testing.T) {
+	client := e2eClient()
+	client.Guest = &models.VMStatus{VMID: "101", VMIDNum: 101, Name: "web-2", Type: "qemu", Status: "running",
+		Node: "pve1", CPUUsage: 3, MemoryUsage: 10, MaxMem: 4 << 30, MaxCPU: 2, Uptime: 5, MaxDisk: 32 << 30}
+	d := newDriver(t, client)
+
+	// Containers sort first, so the stopped web-2 is the last row
+	d.key("G", "s")
+	d.snapshot("start-done")
+	if !strings.Contains(d.ml.model.View(), "Succeeded in start 101") {
+		t.Errorf("Expected a success status:\n%s", d.ml.model.View())
+	}
+	if client.GuestCalls != 1 {
+		t.Errorf("The started guest should be refreshed once, got %d", client.GuestCalls)
+	}
+
+	// Any key dismisses the result
+	d.key("x")
+	if strings.Contains(d.ml.model.View(), "Succeeded") {
+		t.Error("The result should be dismissed by a key press")
+	}
+}
+
+func TestE2E_ResizeInDialog(t *testing.T) {
+	d := newDriver(t, e2eClient())
+
+	d.key("f3")
+	d.send(tea.WindowSizeMsg{Width: 50, Height: 12})
+	d.snapshot("details-resized")
+	if lines := strings.Split(d.ml.model.View(), "\n"); len(lines) != 12 {
+		t.Errorf("Resized dialog should fill 12 lines, got %d", len(lines))
+	}
+
+	d.key("esc")
+	d.send(tea.WindowSizeMsg{Width: 100, Height: 30})
+	d.snapshot("list-resized")
+}
+
+func TestE2E_Quit(t *testing.T) {
+	d := newDriver(t, e2eClient())
+
+	d.key("f1", "q")
+	if d.quit {
+		t.Error("q in the help dialog should not quit")
+	}
+	d.key("esc", "q")
+	if !d.quit {
+		t.Error("q should quit from the list")
+	}
+}
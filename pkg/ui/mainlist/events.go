@@ -0,0 +1,89 @@
+package mainlist
+
+import "time"
+
+// Event is what the list reports to a program embedding it through
+// Config.Events: a RefreshSucceeded, RefreshFailed, ActionStarted,
+// ActionCompleted or SelectionChanged.
+//
+// Events are sent from the update loop in the order the list handles
+// what caused them: a refresh reports after the guests are replaced, so
+// GetAllNodes already returns them; an action's ActionCompleted follows
+// its ActionStarted; and a SelectionChanged caused by a refresh or a key
+// comes after that refresh's or that action's own event. Sending never
+// blocks the list: an event that finds the channel full is dropped, so
+// give the channel room and drain it.
+type Event interface {
+	event()
+}
+
+// RefreshSucceeded reports a refresh of the guest list
+type RefreshSucceeded struct {
+	Count    int           // Guests listed
+	Duration time.Duration // Time the refresh took
+}
+
+// RefreshFailed reports a refresh that couldn't list the guests
+type RefreshFailed struct {
+	Err error
+}
+
+// ActionStarted reports a power action sent to a guest: one of the action
+// keys (start, shutdown, reboot, stop, resume), a restart, the undo of a
+// stop, or a scheduled action once due
+type ActionStarted struct {
+	Action    string // e.g. "shutdown"
+	VMID      string // The guest's Key, which names its cluster when several are listed
+	Scheduled bool   // Run by the schedule rather than a key
+}
+
+// ActionCompleted reports the end of an action, Err nil if it succeeded.
+// An action cancelled with ESC completes with an error.
+type ActionCompleted struct {
+	Action    string
+	VMID      string
+	Scheduled bool
+	Err       error
+}
+
+// SelectionChanged reports that another guest is selected, VMID being
+// its Key; "" when the list is left empty
+type SelectionChanged struct {
+	VMID string
+}
+
+func (RefreshSucceeded) event() {}
+func (RefreshFailed) event()    {}
+func (ActionStarted) event()    {}
+func (ActionCompleted) event()  {}
+func (SelectionChanged) event() {}
+
+// emit sends e without blocking, dropping it when the channel is full
+func (ml *MainList) emit(e Event) {
+	if ml.eventSink == nil {
+		return
+	}
+	select {
+	case ml.eventSink <- e:
+	default:
+	}
+}
+
+// emitSelection reports a change of the selected guest since the last
+// report
+func (ml *MainList) emitSelection() {
+	if ml.eventSink == nil {
+		return
+	}
+	ml.refreshMutex.Lock()
+	var key string
+	if vm := ml.selectedGuest(); vm != nil {
+		key = vm.Key()
+	}
+	ml.refreshMutex.Unlock()
+	if key == ml.selectedKey {
+		return
+	}
+	ml.selectedKey = key
+	ml.emit(SelectionChanged{VMID: key})
+}
@@ -0,0 +1,96 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/internal/testutil"
+)
+
+// eventDriver is newDriver with the events sent to a channel
+func eventDriver(t *testing.T, client *MockClient, events chan Event) *driver {
+	t.Helper()
+	clock := testutil.NewFakeClock(e2eNow)
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, Events: events, Clock: clock})
+	d := &driver{t: t, ml: ml, clock: clock}
+	d.send(tea.WindowSizeMsg{Width: 80, Height: 24})
+	d.send(ml.fetchNodes(context.Background()))
+	return d
+}
+
+// drain returns the events sent so far, refresh durations zeroed
+func drain(events chan Event) []Event {
+	var got []Event
+	for {
+		select {
+		case e := <-events:
+			if r, ok := e.(RefreshSucceeded); ok {
+				r.Duration = 0
+				e = r
+			}
+			got = append(got, e)
+		default:
+			return got
+		}
+	}
+}
+
+func TestEvents_Sequence(t *testing.T) {
+	client := e2eClient()
+	events := make(chan Event, 32)
+	d := eventDriver(t, client, events)
+
+	// The list is sorted by name: backup (201) comes first
+	want := []Event{RefreshSucceeded{Count: 5}, SelectionChanged{VMID: "201"}}
+	if got := drain(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the first refresh and selection %v, got %v", want, got)
+	}
+
+	d.key("down", "up")
+	want = []Event{SelectionChanged{VMID: "200"}, SelectionChanged{VMID: "201"}}
+	if got := drain(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected a selection per move %v, got %v", want, got)
+	}
+
+	selectGuest(t, d, "100")
+	drain(events)
+
+	d.key("d")
+	want = []Event{ActionStarted{Action: "shutdown", VMID: "100"}, ActionCompleted{Action: "shutdown", VMID: "100"}}
+	if got := drain(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the shutdown to start then complete %v, got %v", want, got)
+	}
+
+	d.key("esc")
+	client.ActionErr = errors.New("status 500")
+	d.key("t")
+	got := drain(events)
+	if len(got) != 2 || got[1].(ActionCompleted).Err == nil {
+		t.Errorf("Expected the stop to complete with its error, got %v", got)
+	}
+
+	client.Err = errors.New("connection refused")
+	d.send(d.ml.fetchNodes(context.Background()))
+	got = drain(events)
+	if len(got) == 0 || !errors.Is(got[0].(RefreshFailed).Err, client.Err) {
+		t.Errorf("Expected the refresh to fail, got %v", got)
+	}
+}
+
+func TestEvents_NonBlocking(t *testing.T) {
+	events := make(chan Event, 1)
+	d := eventDriver(t, e2eClient(), events)
+
+	// Nobody reads: the list goes on, and the events past the first are dropped
+	d.key("down", "down", "down")
+	d.send(d.ml.fetchNodes(context.Background()))
+	if got := drain(events); len(got) != 1 || !reflect.DeepEqual(got[0], RefreshSucceeded{Count: 5}) {
+		t.Errorf("Expected only the first event kept, got %v", got)
+	}
+	if vm := d.ml.GetSelectedNode(); vm == nil || vm.VMID != "100" {
+		t.Errorf("Expected the keys to move the selection regardless, got %v", vm)
+	}
+}
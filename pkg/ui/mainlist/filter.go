@@ -0,0 +1,122 @@
+package mainlist
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+)
+
+// Filter narrows the list to the guests matching every field that is set
+type Filter struct {
+	Node   string // Node name
+	Status string // Guest status: running, stopped, paused, hibernated or unknown
+	Pool   string // Resource pool, models.NoPool for the guests in none
+	Text   string // Part of the name, VMID, bridge, VLAN or owner, ignoring case; tag:N or bridge:NAME match a NIC exactly, owner:NAME the owner, flag:-o the config flags
+}
+
+// listFilter is the filter applied to the list: the one set at startup
+// and the preset toggled at runtime
+type listFilter struct {
+	Filter
+	preset filterPreset
+}
+
+// active reports whether any guest can be hidden
+func (f listFilter) active() bool {
+	return f.Filter != Filter{} || f.preset != filterNone
+}
+
+// matches reports whether a guest passes every criterion; nics are the
+// guest's network interfaces, nil while unknown, hostname its own
+// hostname and owner the owner found in its description, empty while
+// unknown, and flags its config flags, nil while unknown
+func (f listFilter) matches(node *models.VMStatus, nics []configparse.NIC, hostname, owner string, flags *configparse.Flags) bool {
+	if f.Node != "" && node.Node != f.Node {
+		return false
+	}
+	if f.Status != "" && !strings.EqualFold(node.StatusString(), f.Status) {
+		return false
+	}
+	if f.Pool != "" && !matchesPool(f.Pool, node) {
+		return false
+	}
+	if f.Text != "" && !matchesText(strings.ToLower(f.Text), node, nics, hostname, owner, flags) {
+		return false
+	}
+	return f.preset.matches(node)
+}
+
+// matchesPool reports whether the guest is in the pool, or in none for
+// models.NoPool
+func matchesPool(pool string, node *models.VMStatus) bool {
+	if pool == models.NoPool {
+		return node.Pool == ""
+	}
+	return node.Pool == pool
+}
+
+// matchesText reports whether the lowercase text is part of the guest's
+// name, own hostname, VMID, owner or the bridge/VLAN of a NIC. "tag:30"
+// and "bridge:vmbr1" only match a NIC with that exact VLAN tag or bridge,
+// "owner:alice" a guest with that exact owner, and "flag:-o" a guest
+// whose config flags fit the spec (see matchesFlags).
+func matchesText(text string, node *models.VMStatus, nics []configparse.NIC, hostname, owner string, flags *configparse.Flags) bool {
+	if tag, ok := strings.CutPrefix(text, "tag:"); ok {
+		return slices.ContainsFunc(nics, func(nic configparse.NIC) bool { return nic.Tag == tag })
+	}
+	if bridge, ok := strings.CutPrefix(text, "bridge:"); ok {
+		return slices.ContainsFunc(nics, func(nic configparse.NIC) bool { return strings.EqualFold(nic.Bridge, bridge) })
+	}
+	if name, ok := strings.CutPrefix(text, "owner:"); ok {
+		return owner != "" && strings.EqualFold(owner, name)
+	}
+	if spec, ok := strings.CutPrefix(text, "flag:"); ok {
+		return flags != nil && matchesFlags(spec, node, *flags)
+	}
+	if strings.Contains(strings.ToLower(node.Name), text) || strings.Contains(strings.ToLower(hostname), text) ||
+		strings.Contains(node.VMID, text) || strings.Contains(strings.ToLower(owner), text) {
+		return true
+	}
+	return slices.ContainsFunc(nics, func(nic configparse.NIC) bool {
+		return strings.Contains(strings.ToLower(nic.Summary()), text)
+	})
+}
+
+// labels returns the title tags describing the filter
+func (f listFilter) labels() []string {
+	var labels []string
+	if f.Node != "" {
+		labels = append(labels, "node: "+f.Node)
+	}
+	if f.Status != "" {
+		labels = append(labels, "status: "+f.Status)
+	}
+	if f.Pool != "" {
+		labels = append(labels, "pool: "+f.Pool)
+	}
+	if f.Text != "" {
+		labels = append(labels, fmt.Sprintf("text: %q", f.Text))
+	}
+	if f.preset != filterNone {
+		labels = append(labels, f.preset.String())
+	}
+	return labels
+}
+
+// ambiguousFilterText warns, when the text filter is exactly the name of
+// several guests, that it names no single guest, listing the candidates.
+// Must be called with refreshMutex held.
+func (m *listModel) ambiguousFilterText() string {
+	text := m.parent.filter.Text
+	if text == "" || len(m.parent.duplicates[strings.ToLower(text)]) < 2 {
+		return ""
+	}
+	_, _, err := models.ResolveGuest(m.parent.guests.All(), text)
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
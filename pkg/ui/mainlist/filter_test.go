@@ -0,0 +1,170 @@
+package mainlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+)
+
+func TestListFilter_Matches(t *testing.T) {
+	vm := &models.VMStatus{VMID: "102", Name: "DB-primary", Status: "running", Node: "pve2", Uptime: 7200}
+
+	tests := []struct {
+		name   string
+		filter listFilter
+		want   bool
+	}{
+		{"empty", listFilter{}, true},
+		{"node", listFilter{Filter: Filter{Node: "pve2"}}, true},
+		{"other node", listFilter{Filter: Filter{Node: "pve1"}}, false},
+		{"status ignores case", listFilter{Filter: Filter{Status: "Running"}}, true},
+		{"other status", listFilter{Filter: Filter{Status: "stopped"}}, false},
+		{"name ignores case", listFilter{Filter: Filter{Text: "db-"}}, true},
+		{"vmid", listFilter{Filter: Filter{Text: "10"}}, true},
+		{"no text match", listFilter{Filter: Filter{Text: "web"}}, false},
+		{"all must match", listFilter{Filter: Filter{Node: "pve2", Text: "web"}}, false},
+		{"preset", listFilter{Filter: Filter{Node: "pve2"}, preset: filterRecent}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(vm, nil, "", "", nil); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+			if got := tt.filter.active(); got != (tt.name != "empty") {
+				t.Errorf("active() = %v", got)
+			}
+		})
+	}
+}
+
+func TestListFilter_MatchesHostname(t *testing.T) {
+	vm := &models.VMStatus{VMID: "102", Name: "debian-template", Status: "running"}
+
+	tests := []struct {
+		text     string
+		hostname string
+		want     bool
+	}{
+		{"db-primary", "db-primary.example.com", true},
+		{"EXAMPLE.COM", "db-primary.example.com", true},
+		{"debian", "db-primary", true}, // The Proxmox name still matches
+		{"db-primary", "", false},      // Not fetched yet
+	}
+	for _, tt := range tests {
+		f := listFilter{Filter: Filter{Text: tt.text}}
+		if got := f.matches(vm, nil, tt.hostname, "", nil); got != tt.want {
+			t.Errorf("%q with hostname %q: got %v, want %v", tt.text, tt.hostname, got, tt.want)
+		}
+	}
+}
+
+func TestListFilter_MatchesNetwork(t *testing.T) {
+	vm := &models.VMStatus{VMID: "102", Name: "db", Status: "running"}
+	nics := []configparse.NIC{{Bridge: "vmbr0", Tag: "30"}, {Bridge: "vmbr1"}}
+
+	tests := []struct {
+		text string
+		nics []configparse.NIC
+		want bool
+	}{
+		{"30", nics, true},
+		{"vmbr1", nics, true},
+		{"VMBR0.30", nics, true},
+		{"tag:30", nics, true},
+		{"tag:3", nics, false},
+		{"bridge:vmbr1", nics, true},
+		{"bridge:vmbr", nics, false},
+		{"40", nics, false},
+		{"30", nil, false}, // Not fetched yet
+		{"tag:30", nil, false},
+	}
+	for _, tt := range tests {
+		f := listFilter{Filter: Filter{Text: tt.text}}
+		if got := f.matches(vm, tt.nics, "", "", nil); got != tt.want {
+			t.Errorf("%q with %v: got %v, want %v", tt.text, tt.nics, got, tt.want)
+		}
+	}
+}
+
+func TestListFilter_MatchesOwner(t *testing.T) {
+	vm := &models.VMStatus{VMID: "102", Name: "db", Status: "running"}
+
+	tests := []struct {
+		text  string
+		owner string
+		want  bool
+	}{
+		{"owner:alice", "alice", true},
+		{"owner:ALICE", "Alice", true},
+		{"owner:ali", "alice", false},
+		{"owner:alice", "", false}, // Not read yet, or none
+		{"ali", "alice", true},
+		{"bob", "alice", false},
+	}
+	for _, tt := range tests {
+		f := listFilter{Filter: Filter{Text: tt.text}}
+		if got := f.matches(vm, nil, "", tt.owner, nil); got != tt.want {
+			t.Errorf("%q with owner %q: got %v, want %v", tt.text, tt.owner, got, tt.want)
+		}
+	}
+}
+
+func TestListFilter_Labels(t *testing.T) {
+	f := listFilter{Filter: Filter{Node: "pve1", Status: "running", Text: "web"}, preset: filterRecent}
+	got := strings.Join(f.labels(), "|")
+	if want := `node: pve1|status: running|text: "web"|recently restarted`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestE2E_StartupFilter(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.ml.filter = listFilter{Filter: Filter{Node: "pve2"}}
+	d.send(d.ml.fetchNodes(context.Background()))
+	d.snapshot("startup_filter")
+
+	if got := len(d.ml.sortedNodes); got != 2 {
+		t.Fatalf("Expected the 2 guests of pve2, got %d", got)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "[node: pve2]") {
+		t.Errorf("The title should show the filter:\n%s", view)
+	}
+
+	d.key("esc")
+	if got := len(d.ml.sortedNodes); got != 5 {
+		t.Errorf("ESC should clear the filter, got %d guests", got)
+	}
+	if d.ml.filter.active() {
+		t.Error("No filter should be left")
+	}
+
+	// Nothing matches: say why the list is empty
+	d.ml.filter = listFilter{Filter: Filter{Text: "nothing"}}
+	d.send(d.ml.fetchNodes(context.Background()))
+	if view := d.ml.model.View(); !strings.Contains(view, "No guests match the filter (5 hidden) - ESC to clear it") {
+		t.Errorf("Expected a hint for the empty list:\n%s", view)
+	}
+}
+
+func TestE2E_AmbiguousFilter(t *testing.T) {
+	client := e2eClient()
+	client.Nodes[2].Name = "web-1"
+	d := newDriver(t, client)
+	d.send(tea.WindowSizeMsg{Width: 160, Height: 24})
+
+	d.ml.filter = listFilter{Filter: Filter{Text: "WEB-1"}}
+	d.send(d.ml.fetchNodes(context.Background()))
+	if bar := statusBar(d); !strings.Contains(bar, `"WEB-1" names 2 guests: 100 on pve1, 102 on pve2; use the VMID`) {
+		t.Errorf("Expected a name shared by two guests to list them:\n%s", bar)
+	}
+
+	d.ml.filter = listFilter{Filter: Filter{Text: "web-2"}}
+	d.send(d.ml.fetchNodes(context.Background()))
+	if bar := statusBar(d); strings.Contains(bar, "names") {
+		t.Errorf("A unique name is not ambiguous:\n%s", bar)
+	}
+}
@@ -0,0 +1,128 @@
+package mainlist
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// flagsWidth is the width of the Flags column, one letter per flag
+const flagsWidth = 5
+
+// absentFlagStyle dims the letter of a flag a guest doesn't have
+var absentFlagStyle = lipgloss.NewStyle().Faint(true)
+
+// toggleFlags shows or hides the Flags column, filling in the guests
+// whose config hasn't been read yet
+func (m *listModel) toggleFlags() tea.Cmd {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+
+	m.parent.showFlags = !m.parent.showFlags
+	if !m.parent.showFlags {
+		return nil
+	}
+	return m.parent.fillConfigsCmd()
+}
+
+// flagLetter is a letter of the Flags column and whether the guest has
+// the flag
+type flagLetter struct {
+	letter string
+	set    bool
+}
+
+// flagLetters returns the letters of a guest's flags, in column order
+// (Agent, Onboot, Protected), or false until its config is read. A
+// container has no guest agent, so its A slot is blank.
+func (ml *MainList) flagLetters(node *models.VMStatus) ([]flagLetter, bool) {
+	flags, ok := ml.flags[node.Key()]
+	if !ok {
+		return nil, false
+	}
+	agent := flagLetter{"A", flags.Agent}
+	if node.Type == models.TypeContainer {
+		agent = flagLetter{" ", true}
+	}
+	return []flagLetter{agent, {"O", flags.OnBoot}, {"P", flags.Protected}}, true
+}
+
+// flagsText returns the Flags column value for a guest: the letter of
+// each flag it has and "-" for those it lacks, or an ellipsis until its
+// config is read
+func (ml *MainList) flagsText(node *models.VMStatus) string {
+	letters, ok := ml.flagLetters(node)
+	if !ok {
+		return format.Text("…")
+	}
+	var b strings.Builder
+	for _, l := range letters {
+		if l.set {
+			b.WriteString(l.letter)
+		} else {
+			b.WriteString("-")
+		}
+	}
+	return b.String()
+}
+
+// flagsStyled returns the Flags column value for a row drawn in color,
+// where every letter is shown and those of absent flags are dimmed
+func (ml *MainList) flagsStyled(node *models.VMStatus) string {
+	letters, ok := ml.flagLetters(node)
+	if !ok {
+		return ml.flagsText(node)
+	}
+	var b strings.Builder
+	for _, l := range letters {
+		if l.set {
+			b.WriteString(l.letter)
+		} else {
+			b.WriteString(absentFlagStyle.Render(l.letter))
+		}
+	}
+	return b.String()
+}
+
+// matchesFlags reports whether a guest's flags fit a lowercase spec of
+// flag letters, each wanted set unless preceded by "-": "-o" matches the
+// guests that don't start on boot, "a-p" those with an agent and no
+// protection. Containers have no agent, so "a" and "-a" only match VMs.
+// A spec with another letter matches nothing.
+func matchesFlags(spec string, node *models.VMStatus, flags configparse.Flags) bool {
+	if spec == "" {
+		return false
+	}
+	want := true
+	for _, r := range spec {
+		var set bool
+		switch r {
+		case '+':
+			want = true
+			continue
+		case '-':
+			want = false
+			continue
+		case 'a':
+			if node.Type != models.TypeVM {
+				return false
+			}
+			set = flags.Agent
+		case 'o':
+			set = flags.OnBoot
+		case 'p':
+			set = flags.Protected
+		default:
+			return false
+		}
+		if set != want {
+			return false
+		}
+		want = true
+	}
+	return true
+}
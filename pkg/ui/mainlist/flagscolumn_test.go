@@ -0,0 +1,75 @@
+package mainlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+)
+
+func TestFlagsColumn_Sort(t *testing.T) {
+	client := newConfigClient(flagsConfigs())
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	ml.model.Update(ml.model.toggleFlags()())
+
+	less := ml.less(sortOrder{column: colFlags, desc: true})
+	if !less(sinceGuest(t, ml, "100"), sinceGuest(t, ml, "101")) {
+		t.Error("Three flags should sort before one")
+	}
+	delete(ml.flags, "100")
+	if less(sinceGuest(t, ml, "100"), sinceGuest(t, ml, "102")) {
+		t.Error("A guest whose config isn't read should come last")
+	}
+}
+
+func TestFlagsFilter(t *testing.T) {
+	client := newConfigClient(flagsConfigs())
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, Filter: Filter{Text: "flag:-o"}})
+	_, cmd := ml.model.Update(ml.fetchNodes(context.Background()))
+	if cmd == nil {
+		t.Fatal("The text filter should read the configs")
+	}
+	if len(ml.sortedNodes) != 0 {
+		t.Errorf("Guests whose config isn't read shouldn't match, got %v", ml.sortedNodes)
+	}
+	ml.model.Update(cmd())
+
+	var vmids []string
+	for _, node := range ml.sortedNodes {
+		vmids = append(vmids, node.VMID)
+	}
+	if got := strings.Join(vmids, ","); got != "200,102" {
+		t.Errorf("Expected the guests that don't start on boot, got %s", got)
+	}
+}
+
+func TestMatchesFlags(t *testing.T) {
+	vm := &models.VMStatus{VMID: "100", Type: models.TypeVM}
+	ct := &models.VMStatus{VMID: "200", Type: models.TypeContainer}
+	tests := []struct {
+		spec  string
+		node  *models.VMStatus
+		flags configparse.Flags
+		want  bool
+	}{
+		{"o", vm, configparse.Flags{OnBoot: true}, true},
+		{"+o", vm, configparse.Flags{OnBoot: true}, true},
+		{"-o", vm, configparse.Flags{OnBoot: true}, false},
+		{"-o", ct, configparse.Flags{}, true},
+		{"a-p", vm, configparse.Flags{Agent: true}, true},
+		{"a-p", vm, configparse.Flags{Agent: true, Protected: true}, false},
+		{"-a", vm, configparse.Flags{}, true},
+		{"-a", ct, configparse.Flags{}, false}, // Containers have no agent
+		{"aop", vm, configparse.Flags{Agent: true, OnBoot: true, Protected: true}, true},
+		{"x", vm, configparse.Flags{}, false},
+		{"", vm, configparse.Flags{}, false},
+	}
+	for _, tt := range tests {
+		if got := matchesFlags(tt.spec, tt.node, tt.flags); got != tt.want {
+			t.Errorf("%q on %s with %+v: got %v, want %v", tt.spec, tt.node.VMID, tt.flags, got, tt.want)
+		}
+	}
+}
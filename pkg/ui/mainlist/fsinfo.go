@@ -0,0 +1,93 @@
+package mainlist
+
+import (
+	"context"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+	"github.com/tsupplis/pvec/pkg/ui/detailsdialog"
+)
+
+// agentFSTimeout keeps a wedged guest agent from stalling the details
+// dialog; the ping and the report are each given that long
+const agentFSTimeout = 3 * time.Second
+
+// fsCacheEntry is a cached agent filesystem report
+type fsCacheEntry struct {
+	filesystems []models.Filesystem
+	fetched     time.Time
+}
+
+// fsInfoLoadedMsg carries the agent filesystem report for a VM
+type fsInfoLoadedMsg struct {
+	key         string
+	filesystems []models.Filesystem
+	err         error
+}
+
+// hasRunningAgent reports whether the guest agent of a VM may be asked
+// for its filesystems or hostname: it must be a running VM with the agent
+// enabled. Whether the agent answers is only known by pinging it.
+func hasRunningAgent(vm *models.VMStatus, config map[string]interface{}) bool {
+	if vm == nil || vm.Type != models.TypeVM || !vm.IsRunning() {
+		return false
+	}
+	agent, ok := config["agent"]
+	return ok && configparse.AgentEnabled(detailsdialog.FormatValue(agent))
+}
+
+// loadFilesystems serves the details dialog's filesystem section from the
+// cache, kept as long as a config, or starts a fetch; it does nothing for
+// guests without a running agent. The report is only asked for once the
+// agent answered a ping, so an agent enabled in the config but not
+// running in the guest costs a quick ping rather than a timeout.
+func (m *listModel) loadFilesystems() tea.Cmd {
+	vm := m.detailsVM
+	if m.detailsError != nil || !hasRunningAgent(vm, m.detailsConfig) {
+		return nil
+	}
+
+	m.parent.refreshMutex.Lock()
+	entry, ok := m.parent.fsCache[vm.Key()]
+	m.parent.refreshMutex.Unlock()
+	if ok && m.parent.now().Sub(entry.fetched) < configSweepTTL {
+		m.detailsFS = &detailsdialog.FilesystemInfo{Filesystems: entry.filesystems}
+		return nil
+	}
+
+	reader := m.parent.reader
+	if reader == nil {
+		return nil
+	}
+	m.detailsFS = &detailsdialog.FilesystemInfo{Loading: true}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), agentFSTimeout)
+		err := reader.PingAgent(ctx, vm.Node, vm.Key())
+		cancel()
+		if err != nil {
+			return fsInfoLoadedMsg{key: vm.Key(), err: err}
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), agentFSTimeout)
+		defer cancel()
+		filesystems, err := reader.GetAgentFSInfo(ctx, vm.Node, vm.Key())
+		return fsInfoLoadedMsg{key: vm.Key(), filesystems: filesystems, err: err}
+	}
+}
+
+// handleFSInfoLoaded caches a successful report and shows it if the
+// dialog is still open on the same guest
+func (m *listModel) handleFSInfoLoaded(msg fsInfoLoadedMsg) (tea.Model, tea.Cmd) {
+	if msg.err == nil {
+		m.parent.refreshMutex.Lock()
+		m.parent.fsCache[msg.key] = fsCacheEntry{filesystems: msg.filesystems, fetched: m.parent.now()}
+		m.parent.refreshMutex.Unlock()
+	}
+
+	if m.showDetails && m.detailsVM != nil && m.detailsVM.Key() == msg.key {
+		m.detailsFS = &detailsdialog.FilesystemInfo{Filesystems: msg.filesystems, Err: msg.err}
+	}
+	return m, nil
+}
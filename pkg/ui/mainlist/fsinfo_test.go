@@ -0,0 +1,163 @@
+package mainlist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/internal/testutil"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestWantsFilesystems(t *testing.T) {
+	running := &models.VMStatus{VMID: "100", Type: "qemu", Status: "running"}
+	tests := []struct {
+		name     string
+		vm       *models.VMStatus
+		config   map[string]interface{}
+		expected bool
+	}{
+		{"agent enabled", running, map[string]interface{}{"agent": "1,fstrim_cloned_disks=1"}, true},
+		{"agent numeric", running, map[string]interface{}{"agent": 1.0}, true},
+		{"agent disabled", running, map[string]interface{}{"agent": "0"}, false},
+		{"no agent", running, map[string]interface{}{}, false},
+		{"stopped", &models.VMStatus{Type: "qemu", Status: "stopped"}, map[string]interface{}{"agent": "1"}, false},
+		{"container", &models.VMStatus{Type: "lxc", Status: "running"}, map[string]interface{}{"agent": "1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasRunningAgent(tt.vm, tt.config); got != tt.expected {
+				t.Errorf("hasRunningAgent() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// openDetails simulates opening the details dialog for a VM with the
+// given config, running the agent requests it starts
+func openDetails(ml *MainList, vm *models.VMStatus, config map[string]interface{}) {
+	m := ml.model
+	m.showDetails = true
+	m.detailsVM = vm
+	m.detailsFS = nil
+	_, cmd := m.Update(configLoadedMsg{key: vm.Key(), config: config})
+	if cmd == nil {
+		return
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		for _, cmd := range batch {
+			if cmd != nil {
+				m.Update(cmd())
+			}
+		}
+		return
+	}
+	m.Update(msg)
+}
+
+func TestDetails_AgentFilesystems(t *testing.T) {
+	client := &MockClient{Filesystems: []models.Filesystem{
+		{Mountpoint: "/", Type: "ext4", UsedBytes: 97, TotalBytes: 100},
+	}}
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client})
+	ml.model.height = 40
+	vm := &models.VMStatus{VMID: "100", Name: "web-1", Type: "qemu", Status: "running"}
+	config := map[string]interface{}{"agent": "1"}
+
+	openDetails(ml, vm, config)
+	if client.FSCalls != 1 {
+		t.Fatalf("Expected one agent call, got %d", client.FSCalls)
+	}
+	view := ml.model.View()
+	if !strings.Contains(view, "-- filesystems --") || !strings.Contains(view, "(97.0%)") {
+		t.Error("Details should list the agent filesystems")
+	}
+
+	// Reopening within the TTL is served from the cache
+	openDetails(ml, vm, config)
+	if client.FSCalls != 1 || client.Pings != 1 {
+		t.Errorf("Cached report should be reused, got %d calls and %d pings", client.FSCalls, client.Pings)
+	}
+}
+
+func TestDetails_AgentFilesystemsCacheTTL(t *testing.T) {
+	client := &MockClient{Filesystems: []models.Filesystem{{Mountpoint: "/", Type: "ext4", UsedBytes: 1, TotalBytes: 2}}}
+	clock := testutil.NewFakeClock(e2eNow)
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, Clock: clock})
+	vm := &models.VMStatus{VMID: "100", Name: "web-1", Type: "qemu", Status: "running"}
+	config := map[string]interface{}{"agent": "1"}
+
+	openDetails(ml, vm, config)
+	clock.Advance(configSweepTTL - time.Second)
+	openDetails(ml, vm, config)
+	if client.FSCalls != 1 {
+		t.Errorf("The report should be kept as long as a config, got %d calls", client.FSCalls)
+	}
+	clock.Advance(2 * time.Second)
+	openDetails(ml, vm, config)
+	if client.FSCalls != 2 {
+		t.Errorf("An expired report should be read again, got %d calls", client.FSCalls)
+	}
+}
+
+func TestDetails_AgentNotAnsweringPing(t *testing.T) {
+	client := &MockClient{AgentDown: true, Filesystems: []models.Filesystem{{Mountpoint: "/"}}}
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client})
+	ml.model.height = 40
+	vm := &models.VMStatus{VMID: "100", Name: "web-1", Type: "qemu", Status: "running"}
+
+	openDetails(ml, vm, map[string]interface{}{"agent": "1"})
+	if client.Pings != 1 || client.FSCalls != 0 {
+		t.Errorf("Expected the report left unasked after a failed ping, got %d pings and %d calls", client.Pings, client.FSCalls)
+	}
+	if !strings.Contains(ml.model.View(), "not responding") {
+		t.Error("Details should report the unresponsive agent")
+	}
+}
+
+func TestDetails_AgentNotResponding(t *testing.T) {
+	client := &MockClient{FSErr: errors.New("QEMU guest agent is not running")}
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client})
+	ml.model.height = 40
+	vm := &models.VMStatus{VMID: "100", Name: "web-1", Type: "qemu", Status: "running"}
+
+	openDetails(ml, vm, map[string]interface{}{"agent": "1"})
+	if !strings.Contains(ml.model.View(), "not responding") {
+		t.Error("Details should report the unresponsive agent")
+	}
+
+	// Failures are not cached
+	openDetails(ml, vm, map[string]interface{}{"agent": "1"})
+	if client.FSCalls != 2 {
+		t.Errorf("Failed report should be retried, got %d calls", client.FSCalls)
+	}
+}
+
+func TestDetails_NoAgentSkipsFilesystems(t *testing.T) {
+	client := &MockClient{}
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client})
+	vm := &models.VMStatus{VMID: "100", Name: "web-1", Type: "qemu", Status: "stopped"}
+
+	openDetails(ml, vm, map[string]interface{}{"agent": "1"})
+	if client.FSCalls != 0 || ml.model.detailsFS != nil {
+		t.Error("Stopped VMs should not query the agent")
+	}
+}
+
+func TestHandleFSInfoLoaded_DialogMovedOn(t *testing.T) {
+	ml := NewMainList(Config{Provider: &MockDataProvider{}})
+	ml.model.showDetails = true
+	ml.model.detailsVM = &models.VMStatus{VMID: "101"}
+
+	ml.model.Update(fsInfoLoadedMsg{key: "100", filesystems: []models.Filesystem{{Mountpoint: "/"}}})
+	if ml.model.detailsFS != nil {
+		t.Error("A late report for another guest should not be shown")
+	}
+	if _, ok := ml.fsCache["100"]; !ok {
+		t.Error("A late report should still be cached")
+	}
+}
@@ -0,0 +1,164 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/ui/hamenu"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// haGroupsMsg carries the HA groups listed for the group picker
+type haGroupsMsg struct {
+	key    string
+	groups []models.HAGroup
+	err    error
+}
+
+// haResultMsg reports the outcome of an HA change
+type haResultMsg struct {
+	key    string
+	change hamenu.Change
+	err    error
+}
+
+// haAvailable reports whether the HA submenu applies: the backend can
+// change HA, the probe of the HA source didn't find it missing, and there
+// is more than one node, which a standalone node doesn't have. Must be
+// called with refreshMutex held.
+func (ml *MainList) haAvailable() bool {
+	if ml.haManager == nil || len(ml.clusterNodes()) < 2 {
+		return false
+	}
+	if reporter, ok := ml.provider.(FeatureReporter); ok {
+		for _, c := range reporter.Capabilities() {
+			if c.Feature == proxmox.FeatureHA && !c.Enabled {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// haGroupOf returns the HA group of a guest from the last refresh, ""
+// when it has none. Must be called with refreshMutex held.
+func (ml *MainList) haGroupOf(vm *models.VMStatus) string {
+	if ml.cluster == nil {
+		return ""
+	}
+	for _, r := range ml.cluster.HA {
+		if r.VMID() == vm.VMID {
+			return r.Group
+		}
+	}
+	return ""
+}
+
+// handleHAKey opens the HA submenu of the selected guest, listing the HA
+// groups when it isn't managed yet. Without HA the key does nothing.
+func (m *listModel) handleHAKey() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	vm := m.parent.selectedGuest()
+	available := m.parent.haAvailable()
+	group := ""
+	if vm != nil {
+		group = m.parent.haGroupOf(vm)
+	}
+	m.parent.refreshMutex.Unlock()
+	if vm == nil || !available {
+		return true, m, nil
+	}
+
+	state := hamenu.New(fmt.Sprintf("%s (%s)", vm.Name, vm.VMID), vm.HAState != "", group, vm.HAState)
+	if !m.serverCaps.HasHAGroups() {
+		// HA rules replaced the groups, which are no longer listed
+		state.NoGroups, state.Loading = true, false
+	}
+	m.ha, m.haVM = &state, vm
+	if vm.NodeOffline {
+		state.Blocked, state.Loading = &nodeOfflineError{node: vm.Node}, false
+		return true, m, nil
+	}
+	if state.Managed || state.NoGroups {
+		return true, m, nil
+	}
+	client, key, timeout := m.parent.haManager, vm.Key(), m.parent.actionTimeout()
+	return true, m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		groups, err := client.GetHAGroups(ctx)
+		return haGroupsMsg{key: key, groups: groups, err: err}
+	}
+}
+
+// handleHAGroups fills the group picker
+func (m *listModel) handleHAGroups(msg haGroupsMsg) (tea.Model, tea.Cmd) {
+	if m.ha == nil || m.haVM.Key() != msg.key {
+		return m, nil
+	}
+	m.ha.Loading, m.ha.GroupsErr, m.ha.Groups = false, msg.err, nil
+	for _, g := range msg.groups {
+		m.ha.Groups = append(m.ha.Groups, g.Name)
+	}
+	return m, nil
+}
+
+// handleHAKeys handles keys while the HA submenu is open, sending the
+// change once confirmed
+func (m *listModel) handleHAKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	switch m.ha.HandleKey(msg.String()) {
+	case screen.Closed:
+		m.ha, m.haVM = nil, nil
+	case screen.Confirmed:
+		m.ha.Sending = true
+		client, vm, change, timeout := m.parent.haManager, m.haVM, m.ha.Chosen, m.parent.actionTimeout()
+		return true, m, func() tea.Msg {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			sid := models.HASID(vm)
+			var err error
+			switch change.Kind {
+			case hamenu.Add:
+				err = client.AddHAResource(ctx, sid, change.Group)
+			case hamenu.Remove:
+				err = client.RemoveHAResource(ctx, sid)
+			default:
+				err = client.SetHARequestedState(ctx, sid, change.State)
+			}
+			return haResultMsg{key: vm.Key(), change: change, err: err}
+		}
+	}
+	return true, m, nil
+}
+
+// handleHAResult shows the outcome in the submenu and, on success,
+// updates the guest's HA state in the list without waiting for a refresh
+func (m *listModel) handleHAResult(msg haResultMsg) (tea.Model, tea.Cmd) {
+	if m.ha != nil && m.haVM.Key() == msg.key {
+		m.ha.Sending, m.ha.Done, m.ha.Err = false, true, msg.err
+	}
+	if msg.err != nil {
+		return m, nil
+	}
+
+	haState := ""
+	switch msg.change.Kind {
+	case hamenu.Add:
+		haState = "started" // What HA keeps a new resource in
+	case hamenu.Request:
+		haState = msg.change.State
+	}
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+	if guest, ok := m.parent.guests.Get(msg.key); ok {
+		patched := *guest
+		patched.HAState = haState
+		m.parent.guests.Add(&patched)
+		m.parent.guestsDigest = 0
+		m.rearrange()
+	}
+	return m, nil
+}
@@ -0,0 +1,178 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// mockHA records the HA changes sent
+type mockHA struct {
+	calls  []string
+	groups []models.HAGroup
+	err    error
+}
+
+func (h *mockHA) GetHAGroups(ctx context.Context) ([]models.HAGroup, error) {
+	return h.groups, nil
+}
+
+func (h *mockHA) AddHAResource(ctx context.Context, sid, group string) error {
+	h.calls = append(h.calls, "add "+sid+" "+group)
+	return h.err
+}
+
+func (h *mockHA) RemoveHAResource(ctx context.Context, sid string) error {
+	h.calls = append(h.calls, "remove "+sid)
+	return h.err
+}
+
+func (h *mockHA) SetHARequestedState(ctx context.Context, sid, state string) error {
+	h.calls = append(h.calls, "request "+sid+" "+state)
+	return h.err
+}
+
+// newHADriver returns a driver on a two-node cluster where db (102) is
+// managed by HA in group prod
+func newHADriver(t *testing.T, ha *mockHA) *driver {
+	client := e2eClient()
+	for _, vm := range client.Nodes {
+		if vm.VMID == "102" {
+			vm.HAState = "started"
+		}
+	}
+	d := newDriver(t, client)
+	d.ml.haManager = ha
+	d.ml.cluster = &proxmox.RefreshSnapshot{
+		Nodes: []models.ClusterNode{{Name: "pve1", Online: true}, {Name: "pve2", Online: true}},
+		HA:    []models.HAResource{{SID: "vm:102", State: "started", Group: "prod"}},
+	}
+	return d
+}
+
+func TestHAMenu_Hidden(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.key("A")
+	if d.ml.model.ha != nil {
+		t.Error("The menu needs a backend that manages HA")
+	}
+
+	d = newHADriver(t, &mockHA{})
+	d.ml.cluster.Nodes = d.ml.cluster.Nodes[:1]
+	d.key("A")
+	if d.ml.model.ha != nil {
+		t.Error("A standalone node should not offer HA")
+	}
+
+	d = newHADriver(t, &mockHA{})
+	features := proxmox.NewFeatureSet(map[proxmox.Feature]bool{proxmox.FeatureHA: false})
+	d.ml.provider = &proxmox.Provider{Features: features}
+	d.key("A")
+	if d.ml.model.ha != nil {
+		t.Error("The menu should be hidden when the HA source is off")
+	}
+}
+
+func TestHAMenu_RequestState(t *testing.T) {
+	ha := &mockHA{}
+	d := newHADriver(t, ha)
+	d.key("down")
+	d.key("down")
+	d.key("A")
+	view := d.ml.model.View()
+	for _, want := range []string{"HA - db (102)", "requested started, group prod", "> Request stopped"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	d.key("enter")
+	if len(ha.calls) != 0 {
+		t.Fatal("Nothing should be sent before confirming")
+	}
+	d.key("y")
+	if got := strings.Join(ha.calls, "; "); got != "request vm:102 stopped" {
+		t.Errorf("Unexpected calls %q", got)
+	}
+	if g := sinceGuest(t, d.ml, "102"); g.HAState != "stopped" {
+		t.Errorf("The guest should show the new state at once, got %q", g.HAState)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "Request stopped: done") {
+		t.Errorf("Expected the outcome:\n%s", view)
+	}
+	d.key("x")
+	if d.ml.model.ha != nil {
+		t.Error("Any key should close the menu once done")
+	}
+}
+
+func TestHAMenu_AddAndRemove(t *testing.T) {
+	ha := &mockHA{groups: []models.HAGroup{{Name: "prod"}, {Name: "lab"}}}
+	d := newHADriver(t, ha)
+	for range 3 {
+		d.key("down")
+	}
+	d.key("A") // web-1 (100), not managed
+	d.key("enter")
+	if view := d.ml.model.View(); !strings.Contains(view, "prod") || !strings.Contains(view, "lab") {
+		t.Fatalf("Expected the groups to pick from:\n%s", view)
+	}
+	d.key("down")
+	d.key("enter")
+	d.key("y")
+	d.key("x")
+	if g := sinceGuest(t, d.ml, "100"); g.HAState != "started" {
+		t.Errorf("A guest put under HA is kept started, got %q", g.HAState)
+	}
+
+	d.key("up")
+	d.key("A") // db (102)
+	d.key("up")
+	d.key("down")
+	d.key("down")
+	d.key("enter")
+	d.key("y")
+	if got := strings.Join(ha.calls, "; "); got != "add vm:100 prod; remove vm:102" {
+		t.Errorf("Unexpected calls %q", got)
+	}
+	if g := sinceGuest(t, d.ml, "102"); g.HAState != "" {
+		t.Errorf("A guest out of HA has no HA state, got %q", g.HAState)
+	}
+}
+
+func TestHAMenu_Failure(t *testing.T) {
+	ha := &mockHA{err: errors.New("permission denied")}
+	d := newHADriver(t, ha)
+	d.key("down")
+	d.key("down")
+	d.key("A")
+	d.key("enter")
+	d.key("y")
+	if view := d.ml.model.View(); !strings.Contains(view, "Failed to request stopped: permission denied") {
+		t.Errorf("Expected the error:\n%s", view)
+	}
+	if g := sinceGuest(t, d.ml, "102"); g.HAState != "started" {
+		t.Errorf("A failed change should leave the guest alone, got %q", g.HAState)
+	}
+}
+
+func TestHAMenu_NodeOffline(t *testing.T) {
+	ha := &mockHA{}
+	d := newHADriver(t, ha)
+	guest, _ := d.ml.guests.Get("102")
+	guest.NodeOffline = true // As the refresh lists an offline node's guests
+	d.key("down")
+	d.key("down")
+	d.key("A")
+	if view := d.ml.model.View(); !strings.Contains(view, "Can't change HA: node pve2 is offline") {
+		t.Errorf("Expected the node offline:\n%s", view)
+	}
+	d.key("enter", "y")
+	if len(ha.calls) != 0 || d.ml.model.ha != nil {
+		t.Errorf("Nothing should be sent and any key should close the menu, got %v", ha.calls)
+	}
+}
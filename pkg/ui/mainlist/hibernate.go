@@ -0,0 +1,77 @@
+package mainlist
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// hibernateAsk asks before hibernating a VM: unlike a pause, it frees
+// the memory of the VM, which stays off until started again
+type hibernateAsk struct {
+	vm      *models.VMStatus
+	warning string // Active maintenance window, put before the question
+}
+
+// handleHibernateKey asks to hibernate the selection, when it is a
+// running VM; containers can't be hibernated
+func (m *listModel) handleHibernateKey() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	vm := m.parent.selectedGuest()
+	m.parent.refreshMutex.Unlock()
+	if vm == nil || vm.Type != models.TypeVM || !vm.IsRunning() {
+		return true, m, nil
+	}
+	m.hibernateAsk = &hibernateAsk{vm: vm, warning: m.windowWarning()}
+	return true, m, nil
+}
+
+// prompt is the question in the status bar
+func (a *hibernateAsk) prompt() string {
+	return fmt.Sprintf("%sHibernate %s (%s)? Its memory is saved to disk until F4 resumes it (y/n)",
+		a.warning, a.vm.Name, a.vm.Key())
+}
+
+// handleHibernateAskKeys hibernates the VM once confirmed
+func (m *listModel) handleHibernateAskKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "Y":
+		m.hibernateAsk = nil
+		model, cmd := m.executeAction("hibernate", false)
+		return true, model, cmd
+	case "n", "N", "esc":
+		m.hibernateAsk = nil
+	}
+	return true, m, nil
+}
+
+// noteVMState keeps whether the config of a guest holds the memory it
+// saved when hibernated, and shows a stopped VM whose config does as
+// hibernated at once. Must be called with refreshMutex held.
+func (ml *MainList) noteVMState(key string, saved bool) {
+	ml.vmState[key] = saved
+	guest, ok := ml.guests.Get(key)
+	if !ok || !saved || guest.Type != models.TypeVM || guest.Status != models.StateStopped {
+		return
+	}
+	patched := *guest
+	patched.Status = models.StateHibernated
+	ml.guests.Add(&patched)
+	ml.guestsDigest = 0
+}
+
+// markHibernated shows as hibernated the stopped VMs whose config was
+// last read holding saved memory, as when the API leaves out the
+// suspended lock; a guest seen running again forgets it. Must be called
+// with refreshMutex held.
+func (ml *MainList) markHibernated(guests []*models.VMStatus) {
+	for _, g := range guests {
+		switch {
+		case g.Status != models.StateStopped && g.Status != models.StateHibernated:
+			delete(ml.vmState, g.Key())
+		case g.Type == models.TypeVM && g.Status == models.StateStopped && ml.vmState[g.Key()]:
+			g.Status = models.StateHibernated
+		}
+	}
+}
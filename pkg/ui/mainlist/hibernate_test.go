@@ -0,0 +1,69 @@
+package mainlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestHibernate_Confirmed(t *testing.T) {
+	client := e2eClient()
+	d := newDriver(t, client)
+	selectGuest(t, d, "100")
+
+	d.key("H")
+	if bar := statusBar(d); !strings.Contains(bar, "Hibernate web-1 (100)?") {
+		t.Fatalf("H should ask before hibernating:\n%s", bar)
+	}
+	d.key("n")
+	if d.ml.model.hibernateAsk != nil || len(client.Hibernated) != 0 {
+		t.Errorf("n should cancel, hibernated %v", client.Hibernated)
+	}
+
+	d.key("H", "y")
+	if got := strings.Join(client.Hibernated, ","); got != "100" {
+		t.Errorf("y should hibernate web-1, hibernated %q", got)
+	}
+	if !strings.Contains(statusBar(d), "Succeeded in hibernate 100") {
+		t.Errorf("The outcome should show:\n%s", statusBar(d))
+	}
+}
+
+func TestHibernate_OnlyRunningVMs(t *testing.T) {
+	client := e2eClient()
+	d := newDriver(t, client)
+
+	for _, key := range []string{"101", "200"} {
+		selectGuest(t, d, key)
+		d.key("H")
+		if d.ml.model.hibernateAsk != nil {
+			t.Errorf("Guest %s is not a running VM and can't be hibernated", key)
+			d.key("esc")
+		}
+	}
+}
+
+func TestHibernate_VMStateFromConfig(t *testing.T) {
+	client := e2eClient()
+	d := newDriver(t, client)
+
+	d.send(configLoadedMsg{key: "101", config: map[string]interface{}{"vmstate": "local-lvm:vm-101-state-suspend"}})
+	if !strings.Contains(rowOf(t, d, "101"), "hibern.") {
+		t.Errorf("A stopped VM with saved memory should show as hibernated:\n%s", rowOf(t, d, "101"))
+	}
+	d.send(d.ml.fetchNodes(context.Background()))
+	if guest, _ := d.ml.guests.Get("101"); guest.Status != models.StateHibernated {
+		t.Errorf("The next refresh should keep it hibernated, got %s", guest.Status)
+	}
+
+	// Resumed, then shut down the usual way
+	client.Nodes[1].Status = models.StateRunning
+	d.send(d.ml.fetchNodes(context.Background()))
+	client.Nodes[1].Status = models.StateStopped
+	d.send(d.ml.fetchNodes(context.Background()))
+	if guest, _ := d.ml.guests.Get("101"); guest.Status != models.StateStopped {
+		t.Errorf("A VM seen running since should be stopped, got %s", guest.Status)
+	}
+}
@@ -0,0 +1,66 @@
+package mainlist
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// hostnameLoadedMsg carries the hostname a VM's guest agent reported
+type hostnameLoadedMsg struct {
+	key      string
+	hostname string
+	err      error
+}
+
+// loadHostname asks the guest agent of the VM in the details dialog for
+// its hostname; containers give theirs in their config, so it does
+// nothing for them or for VMs without a live agent
+func (m *listModel) loadHostname() tea.Cmd {
+	vm, reader := m.detailsVM, m.parent.reader
+	if m.detailsError != nil || reader == nil || !hasRunningAgent(vm, m.detailsConfig) {
+		return nil
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), agentFSTimeout)
+		defer cancel()
+
+		hostname, err := reader.GetAgentHostName(ctx, vm.Node, vm.Key())
+		return hostnameLoadedMsg{key: vm.Key(), hostname: hostname, err: err}
+	}
+}
+
+// handleHostnameLoaded caches the hostname, which the text filter may now
+// match, and shows it if the dialog is still open on the same guest
+func (m *listModel) handleHostnameLoaded(msg hostnameLoadedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil || msg.hostname == "" {
+		return m, nil
+	}
+	m.parent.refreshMutex.Lock()
+	m.parent.hostnames[msg.key] = msg.hostname
+	m.rearrange()
+	m.parent.refreshMutex.Unlock()
+
+	if m.showDetails && m.detailsVM != nil && m.detailsVM.Key() == msg.key {
+		m.detailsVM = withHostname(m.detailsVM, msg.hostname)
+	}
+	return m, nil
+}
+
+// withHostname returns node with its own hostname, as a copy so the
+// listed guest is left alone
+func withHostname(node *models.VMStatus, hostname string) *models.VMStatus {
+	if hostname == "" || node.Hostname == hostname {
+		return node
+	}
+	copied := *node
+	copied.Hostname = hostname
+	return &copied
+}
+
+// hostnameDiffers reports whether the last hostname known of a guest
+// differs from its name. Must be called with refreshMutex held.
+func (ml *MainList) hostnameDiffers(node *models.VMStatus) bool {
+	return models.HostnameDiffers(node.Name, ml.hostnames[node.Key()])
+}
@@ -0,0 +1,96 @@
+package mainlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func newHostnameClient() *MockClient {
+	client := &MockClient{
+		Configs: map[string]map[string]interface{}{
+			"100": {"agent": "1"},
+			"101": {},
+			"200": {"hostname": "ct-template"},
+		},
+		Hostnames: map[string]string{"100": "debian-template", "101": "db"},
+	}
+	client.Nodes = []*models.VMStatus{
+		{VMID: "100", Name: "web-1", Type: "qemu", Status: "running", Node: "pve1"},
+		{VMID: "101", Name: "db", Type: "qemu", Status: "running", Node: "pve1"},
+		{VMID: "200", Name: "cache", Type: "lxc", Status: "running", Node: "pve1"},
+	}
+	return client
+}
+
+func TestHostname_Sweep(t *testing.T) {
+	client := newHostnameClient()
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, ConfigSweep: true})
+
+	_, cmd := ml.model.Update(ml.fetchNodes(context.Background()))
+	runSweep(t, ml, cmd)
+
+	if client.HostCalls != 1 {
+		t.Errorf("Expected the agent asked only for the VM running one, got %d calls", client.HostCalls)
+	}
+	if got := ml.hostnames["100"]; got != "debian-template" {
+		t.Errorf("Expected the agent's hostname for 100, got %q", got)
+	}
+	if got := ml.hostnames["200"]; got != "ct-template" {
+		t.Errorf("Expected the configured hostname for 200, got %q", got)
+	}
+	view := ml.model.View()
+	for _, want := range []string{"≠web-1", "≠cache"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q marked:\n%s", want, view)
+		}
+	}
+
+	// The text filter matches the hostname as well as the name
+	ml.filter = listFilter{Filter: Filter{Text: "template"}}
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	var names []string
+	for _, node := range ml.sortedNodes {
+		names = append(names, node.Name)
+	}
+	if got := strings.Join(names, ","); got != "cache,web-1" {
+		t.Errorf("Expected the guests whose hostname matches, got %s", got)
+	}
+}
+
+func TestHostname_Details(t *testing.T) {
+	client := newHostnameClient()
+	client.Hostnames["100"] = "web-1.example.com"
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	ml.model.height = 40
+
+	openDetails(ml, client.Nodes[0], client.Configs["100"])
+	if view := ml.model.View(); !strings.Contains(view, "Hostname           : web-1.example.com") {
+		t.Errorf("Expected the agent's hostname in the details:\n%s", view)
+	}
+	if client.Nodes[0].Hostname != "" {
+		t.Error("The listed guest should be left alone")
+	}
+	if got := ml.hostnames["100"]; got != "web-1.example.com" {
+		t.Errorf("Expected the hostname cached, got %q", got)
+	}
+	if strings.Contains(ml.model.renderView(), "≠") {
+		t.Error("A fully qualified hostname of the same name is no mismatch")
+	}
+
+	// A late answer for another guest isn't shown
+	ml.model.Update(hostnameLoadedMsg{key: "101", hostname: "other"})
+	if ml.model.detailsVM.Hostname != "web-1.example.com" {
+		t.Errorf("Expected the dialog's hostname kept, got %q", ml.model.detailsVM.Hostname)
+	}
+
+	// Without an agent nothing is asked
+	client.HostCalls = 0
+	openDetails(ml, client.Nodes[1], client.Configs["101"])
+	if client.HostCalls != 0 {
+		t.Errorf("A VM without an agent shouldn't be asked, got %d calls", client.HostCalls)
+	}
+}
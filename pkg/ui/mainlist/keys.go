@@ -0,0 +1,155 @@
+package mainlist
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// listAction is a command of the list shown in the status bar. The key
+// handling and the status bar both read listActions, so they can't drift.
+type listAction struct {
+	keys    []string // Keys that trigger it
+	label   string   // Status bar label, e.g. "F4 Start"
+	handle  func(m *listModel) (bool, tea.Model, tea.Cmd)
+	applies func(vm *models.VMStatus) bool   // nil when it always applies; vm is nil without a selection
+	relabel func(vm *models.VMStatus) string // Label for the selection when it isn't label; nil or "" keeps label
+}
+
+// listActions are the status bar commands, in display order
+var listActions = []listAction{
+	{keys: []string{"f1", "h"}, label: "F1 Help", handle: (*listModel).handleHelpKey},
+	{keys: []string{"f2", "c"}, label: "F2 Conf", handle: (*listModel).handleConfigKey},
+	{keys: []string{"f3", "enter"}, label: "F3 Info", handle: (*listModel).handleDetailsKey, applies: isSelected},
+	{keys: []string{"f4", "s"}, label: "F4 Start", handle: startOrResume, applies: canStartOrResume, relabel: resumeLabel},
+	{keys: []string{"f5", "d"}, label: "F5 shutDown", handle: guestAction("shutdown"), applies: isRunning},
+	{keys: []string{"f6", "r"}, label: "F6 Reboot", handle: guestAction("reboot"), applies: isRunning},
+	{keys: []string{"f7", "t"}, label: "F7 sTop", handle: guestAction("stop"), applies: canStop},
+	{keys: []string{"f10", "q", "ctrl+c"}, label: "F10 Quit", handle: quit},
+}
+
+// guestAction returns the handler running a power action on the selection
+func guestAction(action string) func(m *listModel) (bool, tea.Model, tea.Cmd) {
+	return func(m *listModel) (bool, tea.Model, tea.Cmd) {
+		return m.handleActionKey(action)
+	}
+}
+
+// startOrResume resumes a paused selection, resumes a hibernated one from
+// disk and starts any other, so F4 is always the key that brings a guest
+// back
+func startOrResume(m *listModel) (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	vm := m.parent.selectedGuest()
+	m.parent.refreshMutex.Unlock()
+	switch {
+	case vm != nil && vm.CanResume():
+		return m.handleActionKey("resume")
+	case vm != nil && vm.Status == models.StateHibernated:
+		return m.handleActionKey("resume from disk")
+	}
+	return m.handleActionKey("start")
+}
+
+// resumeLabel names F4 after what it does to a paused or hibernated VM;
+// the action of a hibernated one says it resumes from disk
+func resumeLabel(vm *models.VMStatus) string {
+	if vm != nil && (vm.CanResume() || vm.Status == models.StateHibernated) {
+		return "F4 Resume"
+	}
+	return ""
+}
+
+func quit(m *listModel) (bool, tea.Model, tea.Cmd) {
+	return true, m, tea.Quit
+}
+
+func isSelected(vm *models.VMStatus) bool { return vm != nil }
+func isRunning(vm *models.VMStatus) bool  { return vm != nil && vm.IsRunning() }
+func canStop(vm *models.VMStatus) bool    { return vm != nil && vm.CanStop() }
+
+func canStartOrResume(vm *models.VMStatus) bool {
+	return vm != nil && (vm.CanStart() || vm.CanResume())
+}
+
+// findListAction returns the action bound to key, nil when there is none
+func findListAction(key string) *listAction {
+	for i := range listActions {
+		for _, k := range listActions[i].keys {
+			if k == key {
+				return &listActions[i]
+			}
+		}
+	}
+	return nil
+}
+
+// selectedGuest returns the guest under the cursor, nil when the list is
+// empty. Must be called with refreshMutex held.
+func (ml *MainList) selectedGuest() *models.VMStatus {
+	if ml.selectedIdx < 0 || ml.selectedIdx >= len(ml.sortedNodes) {
+		return nil
+	}
+	return ml.sortedNodes[ml.selectedIdx]
+}
+
+// listStatusText builds the status bar of the list: the commands, with
+// the ones that don't apply to the selected guest dimmed (or left out
+// without color), then the selected guest's VMID and name on the right
+// as far as they fit. Must be called with refreshMutex held.
+func (m *listModel) listStatusText() string {
+	vm := m.parent.selectedGuest()
+	dimStyle := lipgloss.NewStyle().Faint(true)
+
+	var labels []string
+	for _, a := range listActions {
+		label := a.label
+		if a.relabel != nil {
+			if l := a.relabel(vm); l != "" {
+				label = l
+			}
+		}
+		switch {
+		case a.applies == nil || a.applies(vm):
+			labels = append(labels, label)
+		case format.Color():
+			labels = append(labels, dimStyle.Render(label))
+		}
+	}
+	left := strings.Join(labels, " ")
+	if recent := countRecentlyStarted(m.parent.guests.All()); recent > 0 {
+		left += fmt.Sprintf("  | %d up <15m", recent)
+	}
+	if scheduled := m.scheduleText(); scheduled != "" {
+		left += "  | " + scheduled
+	}
+	if window := m.windowText(); window != "" {
+		left += "  | " + window
+	}
+	if pace := m.parent.paceText(); pace != "" {
+		left += "  | " + pace
+	}
+	if ambiguous := m.ambiguousFilterText(); ambiguous != "" {
+		left += "  | " + ambiguous
+	}
+	if vm == nil {
+		return left
+	}
+
+	room := m.width - lipgloss.Width(left) - 2
+	rights := []string{vm.Key() + " " + vm.Name, vm.Key()}
+	// The node is what tells apart guests sharing a name
+	if m.parent.isDuplicate(vm) {
+		rights = []string{vm.Key() + " " + vm.Name + " on " + vm.Node, vm.Key() + " on " + vm.Node, vm.Key()}
+	}
+	for _, right := range rights {
+		if w := lipgloss.Width(right); w <= room {
+			return left + format.Repeat(" ", m.width-lipgloss.Width(left)-w) + right
+		}
+	}
+	return left
+}
@@ -0,0 +1,137 @@
+package mainlist
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// statusBar returns the last line of the view
+func statusBar(d *driver) string {
+	lines := strings.Split(d.ml.model.View(), "\n")
+	return lines[len(lines)-1]
+}
+
+func TestStatusBar_SelectedGuest(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.send(tea.WindowSizeMsg{Width: 120, Height: 24})
+
+	// backup (201) is stopped
+	bar := statusBar(d)
+	if !strings.Contains(bar, "F4 Start") || strings.Contains(bar, "F5 shutDown") || strings.Contains(bar, "F7 sTop") {
+		t.Errorf("A stopped guest can only be started:\n%s", bar)
+	}
+	if !strings.HasSuffix(bar, "201 backup") || len(bar) != 120 {
+		t.Errorf("The guest should be right-aligned:\n%q", bar)
+	}
+
+	d.key("down") // cache (200) is running
+	bar = statusBar(d)
+	if strings.Contains(bar, "F4 Start") || !strings.Contains(bar, "F5 shutDown F6 Reboot F7 sTop") {
+		t.Errorf("A running guest can't be started:\n%s", bar)
+	}
+	if !strings.HasSuffix(bar, "200 cache") {
+		t.Errorf("The guest should follow the cursor:\n%s", bar)
+	}
+
+	// Only the VMID fits at 80 columns
+	d.send(tea.WindowSizeMsg{Width: 80, Height: 24})
+	if bar := statusBar(d); !strings.HasSuffix(bar, " 200") {
+		t.Errorf("Expected the VMID alone:\n%s", bar)
+	}
+}
+
+func TestStatusBar_Dimmed(t *testing.T) {
+	client := e2eClient()
+	client.Nodes = []*models.VMStatus{{VMID: "100", Name: "web-1", Type: "qemu", Status: "paused", Node: "pve1"}}
+	d := newDriver(t, client)
+	format.SetColor(true)
+
+	bar := statusBar(d)
+	for _, label := range []string{"F5 shutDown", "F6 Reboot"} {
+		if !strings.Contains(bar, label) {
+			t.Errorf("With color %s should be dimmed rather than hidden:\n%s", label, bar)
+		}
+	}
+}
+
+func TestStatusBar_PausedAndHibernated(t *testing.T) {
+	client := e2eClient()
+	client.Nodes = []*models.VMStatus{
+		{VMID: "100", Name: "web-1", Type: "qemu", Status: models.StatePaused, Node: "pve1"},
+		{VMID: "101", Name: "web-2", Type: "qemu", Status: models.StateHibernated, Node: "pve1"},
+	}
+	d := newDriver(t, client)
+
+	bar := statusBar(d)
+	if !strings.Contains(bar, "F4 Resume") || strings.Contains(bar, "F5 shutDown") || !strings.Contains(bar, "F7 sTop") {
+		t.Errorf("A paused guest should offer Resume and Stop:\n%s", bar)
+	}
+	view := d.ml.model.View()
+	if !strings.Contains(view, "❚❚      100") || !strings.Contains(view, "hibern. 101") {
+		t.Errorf("Paused and hibernated guests should stand out:\n%s", view)
+	}
+
+	d.key("f4")
+	if got := strings.Join(client.Resumed, ","); got != "100" || len(client.Started) != 0 {
+		t.Errorf("F4 should resume a paused guest, resumed %q, started %v", got, client.Started)
+	}
+
+	d.key("esc", "down")
+	if bar := statusBar(d); !strings.Contains(bar, "F4 Resume") || strings.Contains(bar, "F7 sTop") {
+		t.Errorf("A hibernated guest should offer Resume, not Stop:\n%s", bar)
+	}
+	d.key("s")
+	if got := strings.Join(client.Started, ","); got != "101" {
+		t.Errorf("Expected the hibernated guest to be started, got %q", got)
+	}
+	if d.ml.model.actionName != "resume from disk" {
+		t.Errorf("Starting a hibernated guest should resume it from disk, got %q", d.ml.model.actionName)
+	}
+}
+
+func TestStatusText_ASCII(t *testing.T) {
+	format.SetUnicode(false)
+	defer format.SetUnicode(true)
+
+	if got := statusText(&models.VMStatus{Status: models.StatePaused}); got != "||" {
+		t.Errorf("Expected an ASCII pause sign, got %q", got)
+	}
+}
+
+func TestStatusBar_NoSelection(t *testing.T) {
+	d := newDriver(t, &MockClient{})
+	if bar := statusBar(d); bar != "F1 Help F2 Conf F10 Quit" {
+		t.Errorf("Without a guest only the global commands apply, got %q", bar)
+	}
+}
+
+func TestListActions_Keys(t *testing.T) {
+	seen := map[string]string{}
+	for _, a := range listActions {
+		if len(a.keys) == 0 || a.handle == nil {
+			t.Errorf("%s needs keys and a handler", a.label)
+		}
+		for _, key := range a.keys {
+			if other, ok := seen[key]; ok {
+				t.Errorf("%s is bound to both %s and %s", key, other, a.label)
+			}
+			seen[key] = a.label
+			if findListAction(key) == nil {
+				t.Errorf("%s should find %s", key, a.label)
+			}
+		}
+	}
+	if findListAction("x") != nil {
+		t.Error("Unbound keys have no action")
+	}
+
+	d := newDriver(t, e2eClient())
+	d.key("f10")
+	if !d.quit {
+		t.Error("F10 should quit through the registry")
+	}
+}
@@ -0,0 +1,139 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/redact"
+)
+
+// guestLockedError blocks an action on a locked guest. It is kept short
+// to fit the status bar; the details say more about the lock.
+type guestLockedError struct {
+	lock string
+}
+
+func (e *guestLockedError) Error() string {
+	return fmt.Sprintf("locked (%s); U in details clears it", e.lock)
+}
+
+// unlockState is the clearing of the lock of the guest shown in the
+// details dialog. It only goes ahead once the VMID has been typed.
+type unlockState struct {
+	vm      *models.VMStatus
+	lock    string
+	typed   string
+	running bool
+}
+
+// unlockMsg reports the outcome of clearing a lock
+type unlockMsg struct {
+	key string
+	err error
+}
+
+// handleUnlockKey asks to clear the lock of the guest whose details are
+// open. Without allow_clear_lock it only explains how to enable it.
+func (m *listModel) handleUnlockKey() (bool, tea.Model, tea.Cmd) {
+	vm := *m.detailsVM
+	if vm.Lock == "" {
+		vm.Lock, _ = m.detailsConfig["lock"].(string)
+	}
+	switch {
+	case !vm.Locked():
+		m.detailsState.Notice = "This guest is not locked"
+	case m.parent.appConfig == nil || !m.parent.appConfig.AllowClearLock:
+		m.detailsState.Notice = "Clearing locks is disabled; set allow_clear_lock in the config file"
+	case m.parent.lockManager == nil:
+		m.detailsState.Notice = "Clearing locks is not available"
+	default:
+		m.unlock = &unlockState{vm: m.detailsVM, lock: vm.Lock}
+		m.detailsState.Notice = m.unlock.prompt()
+	}
+	return true, m, nil
+}
+
+// prompt asks for the VMID, warning about a task that may still hold the lock
+func (u *unlockState) prompt() string {
+	return fmt.Sprintf("Clear the %s lock only if no task holds it. Type %s to confirm: %s_",
+		u.lock, u.vm.VMID, u.typed)
+}
+
+// handleUnlockKeys collects the typed VMID, clearing the lock on Enter
+// once it matches; ESC cancels and keys are ignored while it runs
+func (m *listModel) handleUnlockKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	u := m.unlock
+	if u.running {
+		return true, m, nil
+	}
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.unlock = nil
+		m.detailsState.Notice = ""
+		return true, m, nil
+	case tea.KeyBackspace:
+		if u.typed != "" {
+			u.typed = u.typed[:len(u.typed)-1]
+		}
+	case tea.KeyRunes:
+		u.typed += string(msg.Runes)
+	case tea.KeyEnter:
+		if u.typed == u.vm.VMID {
+			return m.clearLock()
+		}
+	}
+	m.detailsState.Notice = u.prompt()
+	return true, m, nil
+}
+
+// clearLock sends the confirmed request
+func (m *listModel) clearLock() (bool, tea.Model, tea.Cmd) {
+	m.unlock.running = true
+	m.detailsState.Notice = "Clearing the lock..."
+	client, vm, timeout := m.parent.lockManager, m.unlock.vm, m.parent.actionTimeout()
+	return true, m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		err := client.ClearLock(ctx, vm.Node, vm.TypeString(), vm.Key())
+		return unlockMsg{key: vm.Key(), err: err}
+	}
+}
+
+// handleUnlockResult shows the outcome in the details status bar and,
+// on success, unlocks the guest in the list without waiting for a refresh
+func (m *listModel) handleUnlockResult(msg unlockMsg) (tea.Model, tea.Cmd) {
+	if m.unlock == nil || m.unlock.vm.Key() != msg.key {
+		return m, nil
+	}
+	m.unlock = nil
+	switch {
+	case proxmox.PermissionHint(msg.err) != "":
+		m.detailsState.Notice = fmt.Sprintf("Failed to clear the lock: the token %s", proxmox.PermissionHint(redact.Error(msg.err)))
+		return m, nil
+	case msg.err != nil:
+		m.detailsState.Notice = fmt.Sprintf("Failed to clear the lock: %v", redact.Error(msg.err))
+		return m, nil
+	}
+
+	m.detailsState.Notice = "Lock cleared"
+	unlocked := *m.detailsVM
+	unlocked.Lock = ""
+	m.detailsVM = &unlocked
+	m.detailsConfig = maps.Clone(m.detailsConfig) // The reader may have handed out its own map
+	delete(m.detailsConfig, "lock")
+
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+	if guest, ok := m.parent.guests.Get(msg.key); ok {
+		patched := *guest
+		patched.Lock = ""
+		m.parent.guests.Add(&patched)
+		m.parent.guestsDigest = 0
+		m.rearrange()
+	}
+	return m, nil
+}
@@ -0,0 +1,181 @@
+package mainlist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// lockedClient has web-1 (100, running) and web-2 (101, stopped) held by
+// a backup that died, and db (102) paused under a migration
+func lockedClient() *MockClient {
+	client := e2eClient()
+	client.Nodes[0].Lock = "backup"
+	client.Nodes[1].Lock = "backup"
+	client.Nodes[2].Lock = "migrate"
+	client.Nodes[2].Status = models.StatePaused
+	return client
+}
+
+// selectGuest moves the cursor onto the guest with the given VMID
+func selectGuest(t *testing.T, d *driver, key string) {
+	t.Helper()
+	d.key("g")
+	for i := 0; i < len(d.ml.sortedNodes); i++ {
+		if d.ml.sortedNodes[d.ml.selectedIdx].Key() == key {
+			return
+		}
+		d.key("down")
+	}
+	t.Fatalf("Guest %s is not listed", key)
+}
+
+func TestLock_BlocksActions(t *testing.T) {
+	tests := []struct {
+		vmid   string
+		key    string
+		action string
+		lock   string
+	}{
+		{"101", "f4", "start", "backup"},
+		{"100", "f5", "shutdown", "backup"},
+		{"100", "f6", "reboot", "backup"},
+		{"100", "f7", "stop", "backup"},
+		{"102", "f4", "resume", "migrate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			client := lockedClient()
+			client.ActionErr = errors.New("the request should not be sent")
+			d := newDriver(t, client)
+			selectGuest(t, d, tt.vmid)
+
+			d.key(tt.key)
+			want := "Cannot " + tt.action + " " + tt.vmid + ": locked (" + tt.lock + "); U in details clears it. - Press any key"
+			if bar := statusBar(d); !strings.Contains(bar, want) {
+				t.Errorf("Expected the lock to be explained:\n%s", bar)
+			}
+			if len(client.Started) != 0 || len(client.Resumed) != 0 {
+				t.Errorf("Nothing should be sent, started %v, resumed %v", client.Started, client.Resumed)
+			}
+
+			d.key("x")
+			if strings.Contains(statusBar(d), "Cannot") {
+				t.Error("Any key should dismiss the explanation")
+			}
+		})
+	}
+}
+
+func TestLock_HibernatedStarts(t *testing.T) {
+	client := e2eClient()
+	client.Nodes[1].Status = models.StateHibernated
+	client.Nodes[1].Lock = "suspended"
+	d := newDriver(t, client)
+	selectGuest(t, d, "101")
+
+	d.key("f4")
+	if got := strings.Join(client.Started, ","); got != "101" {
+		t.Errorf("The lock of a hibernated VM should not block its start, started %q", got)
+	}
+}
+
+func TestLock_Marker(t *testing.T) {
+	d := newDriver(t, lockedClient())
+	view := d.ml.model.View()
+	if !strings.Contains(view, "🔒web-1") || !strings.Contains(view, "🔒db") || strings.Contains(view, "🔒cache") {
+		t.Errorf("Locked guests should be marked:\n%s", view)
+	}
+
+	format.SetUnicode(false)
+	defer format.SetUnicode(true)
+	if view := d.ml.model.View(); !strings.Contains(view, "#web-1") {
+		t.Errorf("Expected the ASCII marker:\n%s", view)
+	}
+}
+
+func newUnlockDriver(t *testing.T, allow bool) (*driver, *MockClient) {
+	client := lockedClient()
+	d := newDriver(t, client)
+	d.ml.appConfig = &config.Config{AllowClearLock: allow}
+	d.ml.lockManager = client
+	return d, client
+}
+
+func TestLock_Clear(t *testing.T) {
+	d, client := newUnlockDriver(t, true)
+	d.send(tea.WindowSizeMsg{Width: 120, Height: 24})
+	openDetailsOf(t, d, "101")
+	view := d.ml.model.View()
+	if !strings.Contains(view, "backup (actions are blocked until the task ends or the lock is cleared)") || !strings.Contains(view, "U=Unlock") {
+		t.Fatalf("Expected the lock in the details:\n%s", view)
+	}
+
+	d.key("U")
+	if bar := statusBar(d); !strings.Contains(bar, "Clear the backup lock only if no task holds it. Type 101 to confirm: _") {
+		t.Fatalf("Expected the typed confirmation:\n%s", bar)
+	}
+	d.key("1", "0", "enter")
+	if len(client.Unlocked) != 0 {
+		t.Fatal("A partial VMID must not confirm")
+	}
+	d.key("1", "enter")
+	if got := strings.Join(client.Unlocked, ","); got != "101" {
+		t.Fatalf("Expected the lock of 101 to be cleared, got %q", got)
+	}
+	if bar := statusBar(d); !strings.Contains(bar, "Lock cleared") {
+		t.Errorf("Expected the outcome:\n%s", bar)
+	}
+	if guest, _ := d.ml.guests.Get("101"); guest.Locked() {
+		t.Error("The guest should be unlocked in the list at once")
+	}
+
+	d.key("esc")
+	selectGuest(t, d, "101")
+	d.key("f4")
+	if got := strings.Join(client.Started, ","); got != "101" {
+		t.Errorf("Once cleared the guest starts, started %q", got)
+	}
+}
+
+func TestLock_ClearCancelAndFailure(t *testing.T) {
+	d, client := newUnlockDriver(t, true)
+	openDetailsOf(t, d, "100")
+
+	d.key("U", "esc")
+	if d.ml.model.unlock != nil || !d.ml.model.showDetails {
+		t.Fatal("ESC should cancel the confirmation and keep the details open")
+	}
+
+	client.ActionErr = errors.New("Only root may use this option.")
+	d.key("U", "1", "0", "0", "enter")
+	if bar := statusBar(d); !strings.Contains(bar, "Failed to clear the lock: Only root may use this option.") {
+		t.Errorf("Expected the refusal:\n%s", bar)
+	}
+}
+
+func TestLock_ClearDisabled(t *testing.T) {
+	d, client := newUnlockDriver(t, false)
+	openDetailsOf(t, d, "100")
+
+	d.key("U")
+	if bar := statusBar(d); !strings.Contains(bar, "Clearing locks is disabled; set allow_clear_lock in the config file") {
+		t.Errorf("Expected the flag to be named:\n%s", bar)
+	}
+	if d.ml.model.unlock != nil || len(client.Unlocked) != 0 {
+		t.Error("Nothing should be asked without the flag")
+	}
+
+	d.key("esc")
+	openDetailsOf(t, d, "200")
+	d.key("U")
+	if bar := statusBar(d); !strings.Contains(bar, "This guest is not locked") {
+		t.Errorf("Expected the guest to be unlocked:\n%s", bar)
+	}
+}
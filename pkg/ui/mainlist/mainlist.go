@@ -2,109 +2,402 @@ package mainlist
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"regexp"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/clock"
 	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/crash"
 	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/probe"
 	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/state"
+	"github.com/tsupplis/pvec/pkg/ui/batch"
+	"github.com/tsupplis/pvec/pkg/ui/clonepresets"
 	"github.com/tsupplis/pvec/pkg/ui/configpanel"
 	"github.com/tsupplis/pvec/pkg/ui/detailsdialog"
+	"github.com/tsupplis/pvec/pkg/ui/eventlog"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/hamenu"
 	"github.com/tsupplis/pvec/pkg/ui/helpdialog"
+	"github.com/tsupplis/pvec/pkg/ui/nodepower"
+	"github.com/tsupplis/pvec/pkg/ui/nodesummary"
+	"github.com/tsupplis/pvec/pkg/ui/permissions"
+	"github.com/tsupplis/pvec/pkg/ui/pools"
+	"github.com/tsupplis/pvec/pkg/ui/requestlog"
+	"github.com/tsupplis/pvec/pkg/ui/schedule"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+	"github.com/tsupplis/pvec/pkg/ui/serialconsole"
+	"github.com/tsupplis/pvec/pkg/ui/storage"
+	"github.com/tsupplis/pvec/pkg/ui/tasks"
+	"github.com/tsupplis/pvec/pkg/ui/undolist"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
 
+// changeHighlightDuration is how long a row stays highlighted after its status changed
+const changeHighlightDuration = 10 * time.Second
+
+// DefaultActionTimeout bounds a power action when the configuration doesn't
+const DefaultActionTimeout = 60 * time.Second
+
+// DefaultRefreshTimeout bounds a refresh of the guest list when the
+// component configuration doesn't
+const DefaultRefreshTimeout = 10 * time.Second
+
+// errActionCancelled is the result of an action the user gave up on
+var errActionCancelled = errors.New("cancelled by user")
+
 // DataProvider is the interface for fetching node data
 type DataProvider interface {
 	GetNodes(ctx context.Context) ([]*models.VMStatus, error)
 }
 
+// SnapshotProvider reads the guests together with the other sections of
+// a refresh. A DataProvider that also implements it is refreshed through
+// Snapshot.
+type SnapshotProvider interface {
+	Snapshot(ctx context.Context) *proxmox.RefreshSnapshot
+}
+
+// FeatureReporter tells which optional sections a provider reads, for
+// the debug screen
+type FeatureReporter interface {
+	Capabilities() []proxmox.Capability
+}
+
 // MainList is the main scrolling list component
 type MainList struct {
-	program        *tea.Program
-	model          *listModel
-	nodes          []*models.VMStatus
-	sortedNodes    []*models.VMStatus
-	selectedIdx    int
-	provider       DataProvider
-	client         proxmox.Client
-	refreshTicker  *time.Ticker
-	stopRefresh    chan bool
+	program          *tea.Program
+	model            *listModel
+	guests           models.NodeList          // Guests of the last refresh, shared with executor
+	cluster          *proxmox.RefreshSnapshot // Last refresh, every section
+	sortedNodes      []*models.VMStatus
+	selectedIdx      int
+	provider         DataProvider
+	reader           proxmox.Reader
+	power            proxmox.PowerController
+	executor         actions.Executor // Runs power actions on the guests listed in guests
+	nodePower        proxmox.NodePowerController
+	taskManager      proxmox.TaskManager
+	migrations       map[string]*migration // Guests being migrated, by key; see migration.go
+	storageManager   proxmox.StorageManager
+	permissionReader proxmox.PermissionReader
+	cloudInitManager proxmox.CloudInitManager
+	lockManager      proxmox.LockManager
+	haManager        proxmox.HAManager
+	serverInspector  proxmox.ServerInspector
+	serialConsole    proxmox.SerialConsole
+	snapshots        actions.Snapshotter   // Snapshots before the actions in snapshot_before; nil if unsupported
+	cloner           actions.Cloner        // Clones from the clone_presets; nil if unsupported
+	tagger           actions.Tagger        // Batch tag edits; nil if unsupported
+	poolMover        actions.PoolMover     // Batch moves to a pool; nil if unsupported
+	clusterHealth    proxmox.ClusterHealth // Set when several clusters are listed
+	prober           *probe.Prober         // Node network probes run with each refresh; nil when none are configured
+	probes           []probe.Result        // Last round of probes
+	requests         *proxmox.RequestLog   // Last API requests; nil when not kept
+	refreshTicker    clock.Ticker
+	stopRefresh      chan bool
+	ctx              context.Context // Root of every refresh; cancelled by Stop
+	cancel           context.CancelFunc
+	refreshTimeout   time.Duration
+	refreshInterval  time.Duration
+	fetchedAt        time.Time            // When the last successful refresh read the nodes
+	confirmedAt      time.Time            // When a refresh last found them unchanged since
+	guestsDigest     uint64               // Digest of the guests of the last refresh applied; 0 when none
+	unchanged        int                  // Refreshes not applied as their guests hadn't changed
+	guestFetchedAt   map[string]time.Time // Guest key -> time of a single guest update since then
+	clock            clock.Clock          // Clock of the refreshes, displayed uptimes and ages
+	failFast         bool                 // Quit when the first refresh fails
+	loaded           bool                 // A refresh succeeded at least once
+	runErr           error                // Why a fail-fast run quit, returned by Run
+	// refreshMutex guards what the event loop shares with the exported
+	// getters and the background goroutines: the guests and their order
+	// (guests, cluster, sortedNodes, selectedIdx, follow), the caches the
+	// sweep fills, the events, lastError, and provider, which
+	// reinitializeClient swaps while a refresh may be reading it. The
+	// other fields belong to the event loop: only Update, View and what
+	// they call touch them, and commands capture what they use when they
+	// are created. What the auto-refresh goroutine reads is atomic.
 	refreshMutex   sync.Mutex
-	refreshEnabled bool
+	refreshEnabled atomic.Bool // Cleared by SetRefreshEnabled(false)
+	refreshPaused  atomic.Bool // Set while the API rejects our credentials
+	backoff        backoff     // Stretches the refresh interval on failures
+	pace           refreshPace // Shortens the refresh interval after activity
+	suspended      atomic.Bool // Set while the process is stopped with Ctrl+Z
 	onNodesUpdated func([]*models.VMStatus)
+	onStateChanges func([]models.StateChange)
+	eventSink      chan<- Event // Events for an embedding program; nil sends none
+	selectedKey    string       // Key of the selection last reported in events
+	follow         string       // Key of the guest the selection is pinned to, if any
+	running        atomic.Bool  // Set while Run runs the program
+	crashDir       string       // Directory of the crash logs; "" writes none
+	fatal          atomic.Pointer[fatalPanic]
 	lastError      error
 	appConfig      *config.Config
-	configLoader   config.Loader
+	configSaver    config.Saver
+	snapshot       []*models.VMStatus   // Last successfully fetched nodes
+	changedAt      map[string]time.Time // Guest key -> time of last status change
+	events         []models.StateChange // Session state change log
+	sortOrder      sortOrder
+	filter         listFilter
+	showDiskAlloc  bool                          // Show the allocated disk size column
+	diskAlloc      map[string]int64              // Guest key -> allocated disk bytes
+	showNet        bool                          // Show the bridge/VLAN column
+	nics           map[string][]configparse.NIC  // Guest key -> network interfaces, net0 first
+	showOwner      bool                          // Show the Owner column
+	owners         map[string]string             // Guest key -> owner found in its description, "" if none
+	ownerPattern   *regexp.Regexp                // Finds the owner in a description
+	showFlags      bool                          // Show the Flags column
+	flags          map[string]configparse.Flags  // Guest key -> agent, onboot and protection settings
+	vmState        map[string]bool               // Guest key -> whether its config holds saved memory; see hibernate.go
+	configReadAt   map[string]time.Time          // Guest key -> when the sweep last read its config
+	configSweep    bool                          // Sweep every guest's config after each refresh
+	qemuHealth     map[string]*models.QEMUHealth // Guest key -> QEMU state of a running VM, nil if unread
+	healthReadAt   map[string]time.Time          // Guest key -> when the sweep last read its QEMU state
+	sweep          *configSweep                  // Config sweep in progress, nil when idle
+	fsCache        map[string]fsCacheEntry       // Guest key -> last agent filesystem report
+	hostnames      map[string]string             // Guest key -> own hostname, from a container's config or a VM's guest agent
+	updateCheck    ReleaseCheck
+	stateFile      *state.File                   // Scheduled actions and state observations, kept between runs
+	showSince      bool                          // Show the Since column
+	since          map[string]state.Observation  // Guest key -> state it was last observed in, and since when
+	duplicates     map[string][]*models.VMStatus // Lowercase name -> guests sharing it
+	undo           *actions.UndoBuffer           // Guests stopped from pvec, to start again
 }
 
 type listModel struct {
-	parent         *MainList
-	width          int
-	height         int
-	scrollOffset   int
-	cursorPosition int
-	showHelp       bool
-	showDetails    bool
-	detailsVM      *models.VMStatus
-	detailsConfig  map[string]interface{}
-	detailsLoading bool
-	detailsError   error
-	detailsScroll  int
-	showAction     bool
-	actionVM       *models.VMStatus
-	actionName     string
-	actionDone     bool
-	actionError    error
-	showConfig     bool
-	configModel    *configpanel.Model
+	parent          *MainList
+	width           int
+	height          int
+	scrollOffset    int
+	cursorPosition  int
+	showHelp        bool
+	showCheatSheet  bool
+	cheatSheetSeq   int // Tells the timeout of the cheat-sheet shown last
+	showDetails     bool
+	detailsVM       *models.VMStatus
+	detailsConfig   map[string]interface{}
+	detailsLoading  bool
+	detailsError    error
+	detailsState    detailsdialog.State
+	detailsFS       *detailsdialog.FilesystemInfo // Guest agent report, nil if not applicable
+	cloudInit       *cloudInitState               // Cloud-init regeneration being confirmed or run
+	unlock          *unlockState                  // Lock clearing being confirmed or run
+	snapshotAsk     *snapshotAsk                  // Asking whether to snapshot before an action
+	hibernateAsk    *hibernateAsk                 // Asking whether to hibernate a VM
+	windowAsk       *windowAsk                    // Asking whether to act during a maintenance window
+	showAction      bool
+	actionVM        *models.VMStatus
+	actionName      string
+	actionBefore    models.NodeState // The guest's status when the action was sent
+	actionSnapshot  bool             // A snapshot is taken before the action
+	actionNote      string           // What became of the snapshot, once done
+	actionDone      bool
+	actionError     error
+	actionStarted   time.Time
+	actionTimeout   time.Duration
+	actionCancel    context.CancelFunc // Cancels the in-flight request
+	actionSeq       int                // Tells the current action's result from a cancelled one's
+	group           *startGroup        // Ordered start in progress or just finished
+	groupSeq        int
+	restart         *restartState // Restart in progress or just finished
+	restartSeq      int
+	shutdownWatches []*shutdownWatch // Guests followed after a shutdown, oldest first
+	watchSeq        int
+	shutdownNote    string               // Outcome of the last force stop from the toast, until the next key
+	nodePower       *nodepower.State     // Node reboot/shutdown dialog, nil when closed
+	wake            *wakeState           // Wake-on-LAN request in progress or just finished
+	undo            *undoState           // Undo of a stop in progress or just finished
+	undoList        *undolist.State      // Screen of the guests pvec stopped, nil when closed
+	clone           *cloneState          // Clone from a preset in progress or just finished
+	clonePicker     *clonepresets.State  // Screen of the clone presets, nil when closed
+	tasks           *tasks.State         // Running task screen, nil when closed
+	tasksSeq        int                  // Tells the open screen's polls from a closed one's
+	serial          *serialconsole.State // Serial console screen, nil when closed
+	serialSeq       int                  // Tells the open console's output from a closed one's
+	serialCancel    context.CancelFunc   // Cancels the connection in flight
+	serialStream    io.ReadCloser        // Console output, once connected
+	storage         *storage.State       // ISO and template screen, nil when closed
+	storageSeq      int                  // Tells the open screen's replies from a closed one's
+	permissions     *permissions.State   // Token permission screen, nil when closed
+	permissionsSeq  int
+	nodeSummary     *nodesummary.State // Node summary screen, nil when closed
+	pools           *pools.State       // Pool rollup screen, nil when closed
+	batch           *batch.State       // Batch tag or pool change screen, nil when closed
+	batchSeq        int                // Tells the open screen's replies from a closed one's
+	ha              *hamenu.State      // HA submenu, nil when closed
+	haVM            *models.VMStatus   // Guest of the HA submenu
+	requestLog      *requestlog.State  // API request debug screen, nil when closed
+	showConfig      bool
+	configModel     *configpanel.Model
+	showEvents      bool
+	eventsScroll    int
+	updateVersion   string                     // Newer release announced in the status bar, "" once dismissed
+	serverCaps      proxmox.ServerCapabilities // What the server's release offers; unknown until read
+	serverNotice    bool                       // The server is newer than pvec knows, until dismissed
+	schedulePrompt  *schedule.Prompt           // Scheduling an action on a guest, nil when closed
+	scheduleList    *schedule.List             // Scheduled action screen, nil when closed
+	scheduleNotice  string                     // Overdue or failed scheduled actions, until reviewed
+	scheduleBusy    map[int]bool               // IDs of the scheduled actions in flight
 }
 
 type refreshMsg struct {
-	nodes []*models.VMStatus
-	err   error
+	nodes    []*models.VMStatus
+	err      error
+	snapshot *proxmox.RefreshSnapshot // Everything the refresh read, nodes included
+	probes   []probe.Result           // Node probes run alongside; nil without a prober
+	took     time.Duration
 }
 
 type configLoadedMsg struct {
+	key    string
 	config map[string]interface{}
 	err    error
 }
 
 type actionResultMsg struct {
+	seq  int
+	vm   *models.VMStatus
+	note string // What became of the snapshot taken first
+	err  error
+}
+
+// nodePowerResultMsg reports the outcome of a node reboot or shutdown
+type nodePowerResultMsg struct {
 	err error
 }
 
+// guestUpdateMsg carries a freshly fetched status for a single guest
+type guestUpdateMsg struct {
+	guest *models.VMStatus
+	err   error
+}
+
 type tickMsg time.Time
 
 // Config holds the configuration for the main list
 type Config struct {
 	RefreshInterval time.Duration
 	Provider        DataProvider
-	Client          proxmox.Client           // For fetching detailed config
-	OnNodesUpdated  func([]*models.VMStatus) // Callback when nodes are refreshed
-	AppConfig       *config.Config           // Application configuration
-	ConfigLoader    config.Loader            // Configuration loader
+	Reader          proxmox.Reader              // Guest details; nil disables them
+	Power           proxmox.PowerController     // Power actions; nil for a read-only list
+	NodePower       proxmox.NodePowerController // Node reboot/shutdown, if allow_node_power_actions is set
+	Tasks           proxmox.TaskManager         // Running task screen; nil disables it
+	Storage         proxmox.StorageManager      // ISO and template screen; nil disables it
+	Permissions     proxmox.PermissionReader    // Token permission screen; nil disables it
+	CloudInit       proxmox.CloudInitManager    // Cloud-init regeneration; nil disables it
+	Locks           proxmox.LockManager         // Lock clearing, if allow_clear_lock is set
+	HA              proxmox.HAManager           // HA submenu; nil hides it
+	Server          proxmox.ServerInspector     // Server release checks; nil assumes it offers everything
+	Serial          proxmox.SerialConsole       // Serial console screen; nil disables it
+	Snapshots       proxmox.SnapshotManager     // Snapshots before the actions in snapshot_before; nil disables them
+	Clones          proxmox.CloneManager        // Clones from the clone_presets; nil disables them
+	Organizer       proxmox.GuestOrganizer      // Batch tag and pool changes; nil disables them
+	ClusterHealth   proxmox.ClusterHealth       // Cluster column and errors when several clusters are listed; nil for one
+	Prober          *probe.Prober               // Node network probes run with each refresh; nil disables them
+	ConfigSweep     bool                        // Read every guest's config and running VM's QEMU state in the background after each refresh
+	UpdateCheck     ReleaseCheck                // Startup check for a newer release, if update_check is set; nil disables it
+	Requests        *proxmox.RequestLog         // Last API requests, for the debug screen; nil disables it
+	State           *state.File                 // Scheduled actions; nil disables scheduling
+	Filter          Filter                      // Filter applied at startup
+	Context         context.Context             // Root context of refreshes; defaults to context.Background
+	RefreshTimeout  time.Duration               // Bound of each refresh (default DefaultRefreshTimeout)
+	RefreshActive   time.Duration               // Refresh interval after activity; 0 keeps RefreshInterval
+	ActiveWindow    time.Duration               // How long activity keeps RefreshActive (default config.DefaultRefreshActiveWindow)
+	FailFast        bool                        // Quit, and return the error from Run, if the first refresh fails
+	OnNodesUpdated  func([]*models.VMStatus)    // Callback when nodes are refreshed
+	OnStateChanges  func([]models.StateChange)  // Callback when guests change state
+	Events          chan<- Event                // Refresh, action and selection events; nil sends none
+	CrashDir        string                      // Where recovered panics are logged with their stack; "" logs none
+	AppConfig       *config.Config              // Application configuration
+	ConfigSaver     config.Saver                // Persists changes made in the config panel
+	Clock           clock.Clock                 // Clock of the refreshes and displayed times (default clock.Real)
 }
 
 // NewMainList creates a new main list component
 func NewMainList(cfg Config) *MainList {
+	root := cfg.Context
+	if root == nil {
+		root = context.Background()
+	}
+	ctx, cancel := context.WithCancel(root)
+	timeout := cfg.RefreshTimeout
+	if timeout <= 0 {
+		timeout = DefaultRefreshTimeout
+	}
+	guests := models.NewNodeList()
+
 	ml := &MainList{
-		nodes:          make([]*models.VMStatus, 0),
-		selectedIdx:    0,
-		provider:       cfg.Provider,
-		client:         cfg.Client,
-		stopRefresh:    make(chan bool),
-		refreshEnabled: true,
-		onNodesUpdated: cfg.OnNodesUpdated,
-		appConfig:      cfg.AppConfig,
-		configLoader:   cfg.ConfigLoader,
+		guests:           guests,
+		executor:         newExecutor(cfg.Power, guests),
+		selectedIdx:      0,
+		provider:         cfg.Provider,
+		reader:           cfg.Reader,
+		power:            cfg.Power,
+		nodePower:        cfg.NodePower,
+		taskManager:      cfg.Tasks,
+		storageManager:   cfg.Storage,
+		permissionReader: cfg.Permissions,
+		cloudInitManager: cfg.CloudInit,
+		lockManager:      cfg.Locks,
+		haManager:        cfg.HA,
+		serverInspector:  cfg.Server,
+		serialConsole:    cfg.Serial,
+		snapshots:        newSnapshotter(cfg.Snapshots, guests),
+		cloner:           newCloner(cfg.Clones, guests),
+		clusterHealth:    cfg.ClusterHealth,
+		prober:           cfg.Prober,
+		requests:         cfg.Requests,
+		filter:           listFilter{Filter: cfg.Filter},
+		stopRefresh:      make(chan bool),
+		ctx:              ctx,
+		cancel:           cancel,
+		refreshTimeout:   timeout,
+		refreshInterval:  cfg.RefreshInterval,
+		pace:             newRefreshPace(cfg.RefreshInterval, cfg.RefreshActive, cfg.ActiveWindow),
+		guestFetchedAt:   make(map[string]time.Time),
+		clock:            clock.OrReal(cfg.Clock),
+		failFast:         cfg.FailFast,
+		onNodesUpdated:   cfg.OnNodesUpdated,
+		onStateChanges:   cfg.OnStateChanges,
+		eventSink:        cfg.Events,
+		crashDir:         cfg.CrashDir,
+		appConfig:        cfg.AppConfig,
+		configSaver:      cfg.ConfigSaver,
+		sortOrder:        defaultSort,
+		changedAt:        make(map[string]time.Time),
+		diskAlloc:        make(map[string]int64),
+		nics:             make(map[string][]configparse.NIC),
+		owners:           make(map[string]string),
+		ownerPattern:     cfg.AppConfig.OwnerPattern(),
+		flags:            make(map[string]configparse.Flags),
+		vmState:          make(map[string]bool),
+		configReadAt:     make(map[string]time.Time),
+		configSweep:      cfg.ConfigSweep,
+		qemuHealth:       make(map[string]*models.QEMUHealth),
+		healthReadAt:     make(map[string]time.Time),
+		fsCache:          make(map[string]fsCacheEntry),
+		hostnames:        make(map[string]string),
+		updateCheck:      cfg.UpdateCheck,
+		stateFile:        cfg.State,
+		undo:             actions.NewUndoBuffer(),
 	}
+	ml.tagger, ml.poolMover = newOrganizer(cfg.Organizer, cfg.Reader, guests)
+
+	ml.refreshEnabled.Store(true)
 
 	model := &listModel{
 		parent:         ml,
@@ -115,26 +408,56 @@ func NewMainList(cfg Config) *MainList {
 	}
 
 	ml.model = model
+	model.rearmScheduled(ml.now())
 	ml.program = tea.NewProgram(model, tea.WithAltScreen())
 
 	// Start auto-refresh
 	if cfg.RefreshInterval > 0 {
-		ml.refreshTicker = time.NewTicker(cfg.RefreshInterval)
+		ml.refreshTicker = ml.clock.NewTicker(cfg.RefreshInterval)
 		go ml.autoRefresh()
 	}
 
 	return ml
 }
 
+// now reads the list's clock
+func (ml *MainList) now() time.Time {
+	return ml.clock.Now()
+}
+
 // Init implements tea.Model
 func (m *listModel) Init() tea.Cmd {
-	// Trigger initial refresh
-	go m.parent.performRefresh()
-	return tickCmd()
+	return tea.Batch(m.parent.refreshCmd(), tickCmd(), m.parent.updateCheckCmd(), m.parent.serverCapsCmd())
 }
 
 // Update implements tea.Model
-func (m *listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m *listModel) Update(msg tea.Msg) (model tea.Model, cmd tea.Cmd) {
+	// A panic here leaves the list half updated: quit cleanly rather than
+	// go on with it
+	defer func() {
+		if r := recover(); r != nil {
+			m.parent.crashed(crash.New(r))
+			model, cmd = m, tea.Quit
+		}
+	}()
+	model, cmd = m.update(msg)
+	m.parent.emitSelection()
+	return model, guard(cmd)
+}
+
+// update handles a message; Update then reports any change of selection
+func (m *listModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Suspending works from any screen, the config panel included
+	if key, ok := msg.(tea.KeyMsg); ok && key.String() == "ctrl+z" && suspendSupported {
+		m.parent.suspended.Store(true)
+		return m, tea.Suspend
+	}
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.parent.refreshMutex.Lock()
+		m.parent.noteActivity(m.parent.now())
+		m.parent.refreshMutex.Unlock()
+	}
+
 	// Handle config panel messages
 	if handled, model, cmd := m.handleConfigPanelMsg(msg); handled {
 		return model, cmd
@@ -151,8 +474,102 @@ func (m *listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleConfigLoaded(msg)
 	case actionResultMsg:
 		return m.handleActionResult(msg)
+	case panicMsg:
+		return m.handlePanic(msg)
+	case guestUpdateMsg:
+		return m.handleGuestUpdate(msg)
+	case configSweepMsg:
+		return m.handleConfigSweep(msg)
+	case fsInfoLoadedMsg:
+		return m.handleFSInfoLoaded(msg)
+	case hostnameLoadedMsg:
+		return m.handleHostnameLoaded(msg)
+	case startPlanMsg:
+		return m.handleStartPlan(msg)
+	case startStepMsg:
+		return m.handleStartStep(msg)
+	case startWaitMsg:
+		return m.handleStartWait(msg)
+	case restartStepMsg:
+		return m.handleRestartStep(msg)
+	case restartPollMsg:
+		return m.handleRestartPoll(msg)
+	case tasksListedMsg:
+		return m.handleTasksListed(msg)
+	case taskLogMsg:
+		return m.handleTaskLog(msg)
+	case migrationLogMsg:
+		return m.handleMigrationLog(msg)
+	case taskStopMsg:
+		return m.handleTaskStop(msg)
+	case tasksTickMsg:
+		return m.handleTasksTick(msg)
+	case storageListedMsg:
+		return m.handleStorageListed(msg)
+	case volumeDeleteMsg:
+		return m.handleVolumeDelete(msg)
+	case downloadStartedMsg:
+		return m.handleDownloadStarted(msg)
+	case downloadTickMsg:
+		return m.handleDownloadTick(msg)
+	case downloadProgressMsg:
+		return m.handleDownloadProgress(msg)
+	case permissionsMsg:
+		return m.handlePermissions(msg)
+	case cloudInitMsg:
+		return m.handleCloudInitResult(msg)
+	case unlockMsg:
+		return m.handleUnlockResult(msg)
+	case haGroupsMsg:
+		return m.handleHAGroups(msg)
+	case haResultMsg:
+		return m.handleHAResult(msg)
+	case wakeResultMsg:
+		return m.handleWakeResult(msg)
+	case undoResultMsg:
+		return m.handleUndoResult(msg)
+	case cloneResultMsg:
+		return m.handleCloneResult(msg)
+	case batchPreviewMsg:
+		return m.handleBatchPreview(msg)
+	case batchAppliedMsg:
+		return m.handleBatchApplied(msg)
+	case shutdownWatchPollMsg:
+		return m.handleShutdownWatchPoll(msg)
+	case shutdownWatchCheckMsg:
+		return m.handleShutdownWatchCheck(msg)
+	case shutdownEscalateMsg:
+		return m.handleShutdownEscalate(msg)
+	case cheatSheetHideMsg:
+		return m.handleCheatSheetHide(msg)
+	case serialPortsMsg:
+		return m.handleSerialPorts(msg)
+	case serialOpenedMsg:
+		return m.handleSerialOpened(msg)
+	case serialOutputMsg:
+		return m.handleSerialOutput(msg)
+	case updateAvailableMsg:
+		return m.handleUpdateAvailable(msg)
+	case serverCapsMsg:
+		return m.handleServerCaps(msg)
+	case scheduledResultMsg:
+		return m.handleScheduledResult(msg)
+	case nodePowerResultMsg:
+		if m.nodePower != nil {
+			m.nodePower.Sending = false
+			m.nodePower.Done = true
+			m.nodePower.Err = msg.err
+		}
+		return m, nil
+	case tea.ResumeMsg:
+		return m, m.parent.resumeCmd()
 	case tickMsg:
-		return m, tickCmd()
+		return m, tea.Batch(tickCmd(), m.runDueCmd())
+	case changeExpiredMsg:
+		m.parent.refreshMutex.Lock()
+		m.parent.expireChanges(m.parent.now())
+		m.parent.refreshMutex.Unlock()
+		return m, nil
 	}
 
 	return m, nil
@@ -162,6 +579,10 @@ func (m *listModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m *listModel) handleConfigPanelMsg(msg tea.Msg) (bool, tea.Model, tea.Cmd) {
 	// First, let the config panel handle the message if it's showing
 	if m.showConfig && m.configModel != nil {
+		// The list is drawn again once the panel closes
+		if size, ok := msg.(tea.WindowSizeMsg); ok {
+			m.handleWindowSize(size)
+		}
 		updatedModel, cmd := m.configModel.Update(msg)
 		if updated, ok := updatedModel.(configpanel.Model); ok {
 			m.configModel = &updated
@@ -177,7 +598,7 @@ func (m *listModel) handleConfigPanelMsg(msg tea.Msg) (bool, tea.Model, tea.Cmd)
 		if saveMsg, ok := msg.(configpanel.SaveResultMsg); ok {
 			// Clear the node list immediately (config might be invalid)
 			m.parent.refreshMutex.Lock()
-			m.parent.nodes = nil
+			m.parent.guests.Clear()
 			m.parent.sortedNodes = nil
 			m.parent.selectedIdx = 0
 			m.cursorPosition = 0
@@ -185,12 +606,21 @@ func (m *listModel) handleConfigPanelMsg(msg tea.Msg) (bool, tea.Model, tea.Cmd)
 			m.parent.refreshMutex.Unlock()
 
 			if saveMsg.Err() == nil {
-				// Close the config panel on successful save
+				// Close the config panel on successful save, and read the
+				// saved config from now on
 				m.showConfig = false
+				m.parent.appConfig = saveMsg.Config()
 				// Reinitialize the Proxmox client with updated configuration
-				m.parent.reinitializeClient()
-				// Trigger immediate refresh with new client
-				return true, m, m.parent.refreshCmd()
+				if err := m.parent.reinitializeClient(); err != nil {
+					m.parent.refreshMutex.Lock()
+					m.parent.lastError = err
+					m.parent.refreshMutex.Unlock()
+					return true, m, nil
+				}
+				// Trigger immediate refresh with new client, which may
+				// reach another server
+				m.serverCaps, m.serverNotice = proxmox.ServerCapabilities{}, false
+				return true, m, tea.Batch(m.parent.refreshCmd(), m.parent.serverCapsCmd())
 			}
 			// Keep panel open on error
 			return true, m, cmd
@@ -202,27 +632,101 @@ func (m *listModel) handleConfigPanelMsg(msg tea.Msg) (bool, tea.Model, tea.Cmd)
 	return false, m, nil
 }
 
-// handleWindowSize updates dimensions
+// handleWindowSize updates dimensions, and the scroll offsets of the list
+// and of the open screens so that their cursor stays in view
 func (m *listModel) handleWindowSize(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
+	// SelectVMID scrolls by the height from another goroutine
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
 	m.width = msg.Width
 	m.height = msg.Height
+
+	rows := max(m.height-4, 1)
+	m.scrollOffset = format.ClampOffset(format.OffsetFor(m.cursorPosition, m.scrollOffset, rows), len(m.parent.sortedNodes), rows)
+	m.eventsScroll = eventlog.ClampScroll(m.eventsScroll, len(m.parent.events), m.height)
+	if m.showDetails && m.detailsVM != nil {
+		m.detailsState.NodeLoad = m.parent.nodeLoadDetails(m.detailsVM)
+		m.detailsState.Resize(m.detailsVM, m.detailsConfig, m.detailsFS, m.height)
+	}
+	if m.tasks != nil {
+		m.tasks.Resize(m.height)
+	}
+	if m.serial != nil {
+		m.serial.Resize(m.height)
+	}
+	if m.permissions != nil {
+		m.permissions.Resize(m.height)
+	}
+	if m.requestLog != nil {
+		m.requestLog.Resize(m.width, m.height)
+	}
 	return m, nil
 }
 
 // handleRefresh processes node list refresh
 func (m *listModel) handleRefresh(msg refreshMsg) (tea.Model, tea.Cmd) {
+	msg.err = m.parent.logPanic(msg.err)
+	var changes []models.StateChange
+	var cmd tea.Cmd
 	m.parent.refreshMutex.Lock()
-	m.parent.nodes = msg.nodes
+	// A fail-fast run gives up on a first load that fails; otherwise the
+	// error shows in the banner and the ticker keeps retrying
+	if msg.err != nil && m.parent.failFast && !m.parent.loaded {
+		m.parent.runErr = msg.err
+		m.parent.refreshMutex.Unlock()
+		m.parent.emit(RefreshFailed{Err: msg.err})
+		return m, tea.Quit
+	}
+	m.parent.loaded = m.parent.loaded || msg.err == nil
+	// A guest being migrated is listed once, however the refresh caught it
+	m.parent.trackMigrations(msg.snapshot)
+	msg.nodes = m.parent.settleMigrations(msg.nodes)
+	// With low_bandwidth, guests as they were last applied are left
+	// alone, sparing the sorting, the state file and the callbacks
+	unchanged := m.parent.skipUnchanged(msg)
+	if !unchanged {
+		m.parent.keepLastKnown(msg.nodes)
+		m.parent.markHibernated(msg.nodes)
+		m.parent.guests.ReplaceAll(msg.nodes)
+	}
 	m.parent.lastError = msg.err
+	if msg.snapshot != nil {
+		m.parent.cluster = msg.snapshot
+	}
+	if msg.nodes != nil && !unchanged {
+		m.parent.markFetched(m.parent.now())
+		m.parent.observeStates(msg.nodes, m.parent.now())
+		m.arrange(msg.nodes)
+		changes = m.parent.recordStateChanges(msg.nodes, m.parent.now())
+		m.parent.noteHealth(msg.nodes, m.parent.now())
+	}
 	if msg.nodes != nil {
-		m.parent.sortedNodes = sortNodes(msg.nodes)
+		// Configs expire whether the guests changed or not
+		cmd = tea.Batch(m.parent.fillConfigsCmd(), m.parent.readMigrationLogsCmd(), changeExpiryCmd(changes))
+	}
+	// The probes don't depend on the API, so they count even when it failed
+	changes = append(changes, m.parent.recordProbes(msg.probes, m.parent.now())...)
+	if len(changes) > 0 {
+		m.parent.noteActivity(m.parent.now())
 	}
+	// Stop hammering the API with a token it already rejected;
+	// refresh resumes once the configuration is saved again.
+	m.parent.refreshPaused.Store(proxmox.IsUnauthorized(msg.err))
+	m.parent.recordRefresh(msg.err, m.parent.refreshPaused.Load())
 	m.parent.refreshMutex.Unlock()
 
-	if m.parent.onNodesUpdated != nil && msg.nodes != nil {
+	if msg.err != nil {
+		m.parent.emit(RefreshFailed{Err: msg.err})
+	} else {
+		m.parent.emit(RefreshSucceeded{Count: len(msg.nodes), Duration: msg.took})
+	}
+	if m.parent.onNodesUpdated != nil && msg.nodes != nil && !unchanged {
 		m.parent.onNodesUpdated(msg.nodes)
 	}
-	return m, nil
+	if m.parent.onStateChanges != nil && len(changes) > 0 {
+		m.parent.onStateChanges(changes)
+	}
+	return m, cmd
 }
 
 // handleConfigLoaded processes loaded VM config
@@ -230,14 +734,73 @@ func (m *listModel) handleConfigLoaded(msg configLoadedMsg) (tea.Model, tea.Cmd)
 	m.detailsLoading = false
 	m.detailsConfig = msg.config
 	m.detailsError = msg.err
-	return m, nil
+
+	// Opening details is a cheap chance to refresh the cached allocation
+	if msg.err == nil && msg.config != nil {
+		m.parent.refreshMutex.Lock()
+		m.parent.storeConfig(msg.key, msg.config, m.parent.now())
+		m.rearrange() // Saved memory shows a stopped VM as hibernated
+		m.parent.refreshMutex.Unlock()
+	}
+	return m, tea.Batch(m.loadFilesystems(), m.loadHostname())
 }
 
 // handleActionResult processes action execution result
 func (m *listModel) handleActionResult(msg actionResultMsg) (tea.Model, tea.Cmd) {
+	// A cancelled action already has its result
+	if msg.seq != m.actionSeq || m.actionDone {
+		return m, nil
+	}
+	m.releaseAction()
 	m.actionDone = true
+	m.parent.refreshMutex.Lock()
+	m.parent.noteActivity(m.parent.now())
+	m.parent.refreshMutex.Unlock()
+	msg.err = m.parent.logPanic(msg.err)
 	m.actionError = msg.err
-	return m, nil
+	m.actionNote = msg.note
+	m.parent.emit(ActionCompleted{Action: m.actionName, VMID: m.actionVM.Key(), Err: msg.err})
+	if msg.err != nil || msg.vm == nil {
+		return m, nil
+	}
+	m.recordStop(m.actionVM, m.actionName, m.actionBefore)
+	// Refresh just the affected guest rather than waiting for the next cycle
+	if m.actionName == "shutdown" {
+		return m, tea.Batch(m.parent.fetchGuestCmd(msg.vm), m.watchShutdown(m.actionVM))
+	}
+	return m, m.parent.fetchGuestCmd(msg.vm)
+}
+
+// handleGuestUpdate patches a single refreshed guest into the node list
+func (m *listModel) handleGuestUpdate(msg guestUpdateMsg) (tea.Model, tea.Cmd) {
+	// A failed lookup isn't worth surfacing; the next full refresh catches up
+	if msg.err != nil || msg.guest == nil {
+		return m, nil
+	}
+
+	m.parent.refreshMutex.Lock()
+	m.parent.markHibernated([]*models.VMStatus{msg.guest})
+	nodes, changes, ok := m.parent.patchGuest(msg.guest, m.parent.now())
+	if len(changes) > 0 {
+		m.parent.noteActivity(m.parent.now())
+	}
+	if ok {
+		m.parent.guestFetchedAt[msg.guest.Key()] = m.parent.now()
+		m.parent.noteHealth([]*models.VMStatus{msg.guest}, m.parent.now())
+		m.rearrange()
+	}
+	m.parent.refreshMutex.Unlock()
+
+	if !ok {
+		return m, nil
+	}
+	if m.parent.onNodesUpdated != nil {
+		m.parent.onNodesUpdated(nodes)
+	}
+	if m.parent.onStateChanges != nil && len(changes) > 0 {
+		m.parent.onStateChanges(changes)
+	}
+	return m, changeExpiryCmd(changes)
 }
 
 func (m *listModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
@@ -246,6 +809,16 @@ func (m *listModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return model, cmd
 	}
 
+	// The shutdown toast takes y and n, leaving the list usable
+	if handled, model, cmd := m.handleShutdownToastKeys(msg); handled {
+		return model, cmd
+	}
+
+	// The cheat-sheet stays over the list while moving around it
+	if handled, model, cmd := m.handleCheatSheetKeys(msg); handled {
+		return model, cmd
+	}
+
 	// Handle function keys (F1-F10)
 	if handled, model, cmd := m.handleFunctionKeys(msg); handled {
 		return model, cmd
@@ -264,11 +837,102 @@ func (m *listModel) handleDialogKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd)
 		return m.handleDetailsDialogKeys(msg)
 	}
 	if m.showAction {
-		return m.handleActionDialogKeys()
+		return m.handleActionDialogKeys(msg)
+	}
+	if m.group != nil {
+		return m.handleStartGroupKeys(msg)
+	}
+	if m.restart != nil {
+		return m.handleRestartKeys(msg)
+	}
+	if m.windowAsk != nil {
+		return m.handleWindowAskKeys(msg)
+	}
+	if m.snapshotAsk != nil {
+		return m.handleSnapshotAskKeys(msg)
+	}
+	if m.hibernateAsk != nil {
+		return m.handleHibernateAskKeys(msg)
+	}
+	if m.nodePower != nil {
+		return m.handleNodePowerKeys(msg)
+	}
+	if m.ha != nil {
+		return m.handleHAKeys(msg)
+	}
+	if m.schedulePrompt != nil {
+		return m.handleSchedulePromptKeys(msg)
+	}
+	if m.scheduleList != nil {
+		return m.handleScheduleListKeys(msg)
+	}
+	if m.wake != nil {
+		return m.handleWakeKeys()
+	}
+	if m.undo != nil {
+		return m.handleUndoKeys()
+	}
+	if m.undoList != nil {
+		return m.handleUndoListKeys(msg)
+	}
+	if m.clone != nil {
+		return m.handleCloneKeys()
+	}
+	if m.clonePicker != nil {
+		return m.handleClonePickerKeys(msg)
+	}
+	if m.tasks != nil {
+		return m.handleTasksKeys(msg)
+	}
+	if m.serial != nil {
+		return m.handleSerialKeys(msg)
+	}
+	if m.storage != nil {
+		return m.handleStorageKeys(msg)
+	}
+	if m.permissions != nil {
+		return m.handlePermissionsKeys(msg)
+	}
+	if m.nodeSummary != nil {
+		return m.handleNodeSummaryKeys(msg)
+	}
+	if m.pools != nil {
+		return m.handlePoolsKeys(msg)
+	}
+	if m.batch != nil {
+		return m.handleBatchKeys(msg)
+	}
+	if m.requestLog != nil {
+		return m.handleRequestLogKeys(msg)
+	}
+	if m.showEvents {
+		return m.handleEventsDialogKeys(msg)
 	}
 	return false, m, nil
 }
 
+// handleEventsDialogKeys handles keys when the event list is open
+func (m *listModel) handleEventsDialogKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+
+	switch msg.String() {
+	case "esc", "enter":
+		m.showEvents = false
+		m.eventsScroll = 0
+	case "up", "k":
+		if m.eventsScroll > 0 {
+			m.eventsScroll--
+		}
+	case "down", "j":
+		m.eventsScroll = eventlog.ClampScroll(m.eventsScroll+1, len(m.parent.events), m.height)
+	case "x":
+		m.parent.events = nil
+		m.eventsScroll = 0
+	}
+	return true, m, nil
+}
+
 // handleHelpDialogKeys handles keys when help dialog is open
 func (m *listModel) handleHelpDialogKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
 	switch msg.String() {
@@ -281,57 +945,183 @@ func (m *listModel) handleHelpDialogKeys(msg tea.KeyMsg) (bool, tea.Model, tea.C
 
 // handleDetailsDialogKeys handles keys when details dialog is open
 func (m *listModel) handleDetailsDialogKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "esc", "enter":
-		m.showDetails = false
-		m.detailsScroll = 0
-		return true, m, nil
-	case "up", "k":
-		if m.detailsScroll > 0 {
-			m.detailsScroll--
+	// Loading and error screens only offer closing
+	if m.detailsLoading || m.detailsError != nil {
+		switch msg.String() {
+		case "esc", "enter":
+			m.closeDetails()
 		}
 		return true, m, nil
-	case "down", "j":
-		m.detailsScroll++
-		return true, m, nil
+	}
+
+	if m.cloudInit != nil {
+		return m.handleCloudInitKeys(msg)
+	}
+	if m.unlock != nil {
+		return m.handleUnlockKeys(msg)
+	}
+	m.detailsState.Notice = ""
+	if !m.detailsState.Searching {
+		switch msg.String() {
+		case "C":
+			return m.handleCloudInitKey()
+		case "U":
+			return m.handleUnlockKey()
+		}
+	}
+	m.parent.refreshMutex.Lock()
+	m.detailsState.NodeLoad = m.parent.nodeLoadDetails(m.detailsVM)
+	m.parent.refreshMutex.Unlock()
+	if m.detailsState.HandleKey(msg.String(), m.detailsVM, m.detailsConfig, m.detailsFS, m.height) {
+		m.closeDetails()
 	}
 	return true, m, nil
 }
 
-// handleActionDialogKeys handles keys when action dialog is open
-func (m *listModel) handleActionDialogKeys() (bool, tea.Model, tea.Cmd) {
+// closeDetails hides the details dialog and resets its search and folding
+func (m *listModel) closeDetails() {
+	m.showDetails = false
+	m.detailsState = detailsdialog.State{}
+	m.cloudInit = nil
+	m.unlock = nil
+}
+
+// handleActionDialogKeys handles keys when action dialog is open: ESC
+// cancels an action in flight, any key dismisses a finished one, z undoing
+// a stop at once
+func (m *listModel) handleActionDialogKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
 	if m.actionDone {
 		m.showAction = false
 		m.actionDone = false
 		m.actionError = nil
+		if msg.String() == "z" {
+			_, _, cmd := m.handleUndoKey()
+			return true, m, cmd
+		}
 		return true, m, nil
 	}
+	if msg.String() == "esc" {
+		m.releaseAction()
+		m.actionDone = true
+		m.actionError = errActionCancelled
+		m.parent.emit(ActionCompleted{Action: m.actionName, VMID: m.actionVM.Key(), Err: errActionCancelled})
+	}
 	return true, m, nil
 }
 
+// releaseAction cancels the context of the action in flight, if any
+func (m *listModel) releaseAction() {
+	if m.actionCancel != nil {
+		m.actionCancel()
+		m.actionCancel = nil
+	}
+}
+
 // handleFunctionKeys processes function key presses
 func (m *listModel) handleFunctionKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	if action := findListAction(msg.String()); action != nil {
+		return action.handle(m)
+	}
+
 	switch msg.String() {
-	case "f1", "h":
-		return m.handleHelpKey()
-	case "f2", "c":
-		return m.handleConfigKey()
-	case "f3", "enter":
-		return m.handleDetailsKey()
-	case "f4", "s":
-		return m.handleActionKey("start")
-	case "f5", "d":
-		return m.handleActionKey("shutdown")
-	case "f6", "r":
-		return m.handleActionKey("reboot")
-	case "f7", "t":
-		return m.handleActionKey("stop")
-	case "f10", "q", "ctrl+c":
-		return true, m, tea.Quit
+	case "O":
+		return m.handleStartGroupKey()
+	case "B":
+		return m.handleRestartKey()
+	case "H":
+		return m.handleHibernateKey()
+	case "@":
+		return m.handleScheduleKey()
+	case "L":
+		return m.handleScheduleListKey()
+	case "N":
+		return m.handleNodePowerKey()
+	case "p":
+		return m.handlePoolsKey()
+	case "#":
+		return m.handleBatchKey(batch.Tags)
+	case "M":
+		return m.handleBatchKey(batch.Pool)
+	case "W":
+		return m.handleWakeKey()
+	case "A":
+		return m.handleHAKey()
+	case "z":
+		return m.handleUndoKey()
+	case "Z":
+		return m.handleUndoListKey()
+	case "+":
+		return m.handleCloneKey()
+	case "T":
+		return m.handleTasksKey()
+	case "C":
+		return m.handleSerialKey()
+	case "I":
+		return m.handleStorageKey()
+	case "P":
+		return m.handlePermissionsKey()
+	case "ctrl+d":
+		return m.handleRequestLogKey()
+	case "n":
+		return m.handleNodeSummaryKey()
+	case "R":
+		return m.handleRetryKey()
+	case "f8", "S":
+		m.parent.refreshMutex.Lock()
+		m.parent.sortOrder = m.parent.activeSort().next()
+		m.rearrange()
+		m.parent.refreshMutex.Unlock()
+		return true, m, nil
+	case "u":
+		m.parent.refreshMutex.Lock()
+		if m.parent.filter.preset == filterRecent {
+			m.parent.filter.preset = filterNone
+		} else {
+			m.parent.filter.preset = filterRecent
+			m.parent.sortOrder = uptimeSort
+		}
+		m.rearrange()
+		m.parent.refreshMutex.Unlock()
+		return true, m, nil
+	case "a":
+		return true, m, m.toggleDiskAlloc()
+	case "v":
+		return true, m, m.toggleNet()
+	case "<", ">":
+		return m.handleSortColumnKey(msg.String())
+	case "o":
+		return m.handleSortDirectionKey()
+	case "w":
+		return true, m, m.toggleOwner()
+	case "F":
+		return true, m, m.toggleFlags()
+	case "D":
+		m.toggleSince()
+		return true, m, nil
+	case "esc":
+		return m.clearFilter()
+	case "e":
+		m.showEvents = true
+		m.eventsScroll = 0
+		return true, m, nil
+	case "x":
+		return m.handleDismissUpdate()
 	}
 	return false, m, nil
 }
 
+// clearFilter drops every filter, including those set at startup
+func (m *listModel) clearFilter() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+	if !m.parent.filter.active() {
+		return false, m, nil
+	}
+	m.parent.filter = listFilter{}
+	m.rearrange()
+	return true, m, nil
+}
+
 // handleHelpKey shows the help dialog
 func (m *listModel) handleHelpKey() (bool, tea.Model, tea.Cmd) {
 	m.showHelp = true
@@ -342,8 +1132,8 @@ func (m *listModel) handleHelpKey() (bool, tea.Model, tea.Cmd) {
 func (m *listModel) handleConfigKey() (bool, tea.Model, tea.Cmd) {
 	m.showConfig = true
 	// Always recreate the config model to reset any unsaved changes
-	if m.parent.appConfig != nil && m.parent.configLoader != nil {
-		model := configpanel.NewModel(m.parent.appConfig, m.parent.configLoader)
+	if m.parent.appConfig != nil && m.parent.configSaver != nil {
+		model := configpanel.NewModel(m.parent.appConfig, m.parent.configSaver)
 		updatedModel, _ := model.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
 		if updated, ok := updatedModel.(configpanel.Model); ok {
 			m.configModel = &updated
@@ -356,23 +1146,40 @@ func (m *listModel) handleConfigKey() (bool, tea.Model, tea.Cmd) {
 func (m *listModel) handleDetailsKey() (bool, tea.Model, tea.Cmd) {
 	m.parent.refreshMutex.Lock()
 	if m.parent.selectedIdx >= 0 && m.parent.selectedIdx < len(m.parent.sortedNodes) {
-		vm := m.parent.sortedNodes[m.parent.selectedIdx]
+		vm := m.parent.withHealth(m.parent.sortedNodes[m.parent.selectedIdx])
+		vm = withHostname(vm, m.parent.hostnames[vm.Key()])
 		m.parent.refreshMutex.Unlock()
 		m.showDetails = true
 		m.detailsVM = vm
 		m.detailsLoading = true
 		m.detailsConfig = nil
 		m.detailsError = nil
-		m.detailsScroll = 0
+		m.detailsFS = nil
+		m.detailsState = detailsdialog.State{}
 		return true, m, m.loadConfig(vm)
 	}
 	m.parent.refreshMutex.Unlock()
 	return true, m, nil
 }
 
-// handleActionKey executes an action on selected VM
+// handleActionKey executes an action on selected VM, first asking
+// whether to snapshot it when snapshot_before lists the action
 func (m *listModel) handleActionKey(action string) (bool, tea.Model, tea.Cmd) {
-	model, cmd := m.executeAction(action)
+	if ask := m.windowCandidate(action); ask != nil {
+		m.windowAsk = ask
+		return true, m, nil
+	}
+	return m.runAction(action)
+}
+
+// runAction runs a power action on the selection, asking first whether to
+// snapshot it when snapshot_before lists the action
+func (m *listModel) runAction(action string) (bool, tea.Model, tea.Cmd) {
+	if vm := m.snapshotCandidate(action); vm != nil {
+		m.snapshotAsk = &snapshotAsk{vm: vm, action: action, unavailable: m.parent.snapshots == nil}
+		return true, m, nil
+	}
+	model, cmd := m.executeAction(action, false)
 	return true, model, cmd
 }
 
@@ -386,6 +1193,11 @@ func (m *listModel) handleNavigationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	switch msg.String() {
+	case "up", "k", "down", "j", "home", "g", "end", "G", "pgup", "pgdown":
+		// Moving the cursor by hand ends FollowVMID
+		m.parent.follow = ""
+	}
 	switch msg.String() {
 	case "up", "k":
 		m.moveCursorUp()
@@ -404,6 +1216,28 @@ func (m *listModel) handleNavigationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// rearrange re-applies the sort mode and filter to the current nodes.
+// Must be called with refreshMutex held.
+func (m *listModel) rearrange() {
+	m.arrange(m.parent.guests.All())
+}
+
+// clampCursor keeps the cursor and scroll offset inside the sorted list.
+// Must be called with refreshMutex held.
+func (m *listModel) clampCursor() {
+	maxIdx := len(m.parent.sortedNodes) - 1
+	if m.cursorPosition > maxIdx {
+		m.cursorPosition = maxIdx
+	}
+	if m.cursorPosition < 0 {
+		m.cursorPosition = 0
+	}
+	m.parent.selectedIdx = m.cursorPosition
+	if m.scrollOffset > m.cursorPosition {
+		m.scrollOffset = m.cursorPosition
+	}
+}
+
 // moveCursorUp moves cursor up one position
 func (m *listModel) moveCursorUp() {
 	if m.cursorPosition > 0 {
@@ -470,43 +1304,32 @@ func (m *listModel) moveCursorPageDown(maxIdx int) {
 
 // loadConfig fetches VM config in background
 func (m *listModel) loadConfig(vm *models.VMStatus) tea.Cmd {
+	reader := m.parent.reader
 	return func() tea.Msg {
-		if m.parent.client == nil {
+		if reader == nil {
 			return configLoadedMsg{config: nil, err: fmt.Errorf("client not available")}
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		config, err := m.parent.client.GetVMConfig(ctx, vm.Node, vm.Type, vm.VMID)
-		return configLoadedMsg{config: config, err: err}
+		config, err := reader.GetVMConfig(ctx, vm.Node, vm.TypeString(), vm.Key())
+		return configLoadedMsg{key: vm.Key(), config: config, err: err}
 	}
 }
 
-// executorAdapter wraps proxmox.Client to match actions.Executor interface
-type executorAdapter struct {
-	client proxmox.Client
-	node   string
-	vmType string
-}
-
-func (e *executorAdapter) Start(ctx context.Context, vmid string) error {
-	return e.client.Start(ctx, e.node, e.vmType, vmid)
-}
-
-func (e *executorAdapter) Shutdown(ctx context.Context, vmid string) error {
-	return e.client.Shutdown(ctx, e.node, e.vmType, vmid)
-}
-
-func (e *executorAdapter) Reboot(ctx context.Context, vmid string) error {
-	return e.client.Reboot(ctx, e.node, e.vmType, vmid)
-}
-
-func (e *executorAdapter) Stop(ctx context.Context, vmid string) error {
-	return e.client.Stop(ctx, e.node, e.vmType, vmid)
+// newExecutor returns the executor running power actions through client
+// on the guests of the list, nil without a client
+func newExecutor(client proxmox.PowerController, guests models.NodeList) actions.Executor {
+	if client == nil {
+		return nil
+	}
+	return proxmox.NewActionExecutor(client, guests)
 }
 
-func (m *listModel) executeAction(actionName string) (tea.Model, tea.Cmd) {
+// executeAction runs an action on the selected guest, after snapshotting
+// it if snapshot is set
+func (m *listModel) executeAction(actionName string, snapshot bool) (tea.Model, tea.Cmd) {
 	m.parent.refreshMutex.Lock()
 	if m.parent.selectedIdx < 0 || m.parent.selectedIdx >= len(m.parent.sortedNodes) {
 		m.parent.refreshMutex.Unlock()
@@ -519,51 +1342,250 @@ func (m *listModel) executeAction(actionName string) (tea.Model, tea.Cmd) {
 	m.showAction = true
 	m.actionVM = vm
 	m.actionName = actionName
+	m.actionBefore = vm.Status
+	m.actionSnapshot = snapshot
+	m.actionNote = ""
 	m.actionDone = false
 	m.actionError = nil
 
-	// Execute action asynchronously
+	// Proxmox refuses every action on a locked guest, with a message that
+	// doesn't say why; explain it without sending the request
+	if vm.Locked() {
+		m.actionDone = true
+		m.actionError = &guestLockedError{lock: vm.Lock}
+		return m, nil
+	}
+	if vm.NodeOffline {
+		m.actionDone = true
+		m.actionError = &nodeOfflineError{node: vm.Node}
+		return m, nil
+	}
+	if vm.IsUnknown() {
+		m.actionDone = true
+		m.actionError = &guestUnknownError{node: vm.Node}
+		return m, nil
+	}
+	if err := m.actionLimit(vm, actionName); err != nil {
+		m.actionDone = true
+		m.actionError = err
+		return m, nil
+	}
+	m.actionStarted = m.parent.now()
+	m.actionTimeout = m.parent.actionTimeout()
+	m.cancelShutdownWatch(vm.Key())
+	m.actionSeq++
+	seq := m.actionSeq
+	m.parent.emit(ActionStarted{Action: actionName, VMID: vm.Key()})
+
+	// The context is created here rather than in the command so ESC can
+	// cancel it while the request is in flight
+	ctx, cancel := context.WithTimeout(context.Background(), m.actionTimeout)
+	m.actionCancel = cancel
+
+	// The action is built here, on the clients of the moment, and
+	// executed asynchronously
+	action, err := newAction(actionName, m.parent.executor, vm)
+	var first *actions.SnapshotFirstAction
+	if err == nil && snapshot {
+		first = m.parent.snapshotFirst(action, vm)
+		action = first
+	}
 	return m, func() tea.Msg {
-		if m.parent.client == nil {
-			return actionResultMsg{err: fmt.Errorf("client not available")}
+		defer cancel()
+		if err != nil {
+			return actionResultMsg{seq: seq, err: err}
 		}
+		err := guarded(func() error { return action.Execute(ctx) })
+		return actionResultMsg{seq: seq, vm: vm, note: snapshotNote(first), err: err}
+	}
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
+// newAction returns the power action called name on vm
+func newAction(name string, executor actions.Executor, vm *models.VMStatus) (actions.Action, error) {
+	if executor == nil {
+		return nil, fmt.Errorf("client not available")
+	}
+	switch name {
+	case "start", "resume from disk":
+		return actions.NewStartAction(executor, vm), nil
+	case "shutdown":
+		return actions.NewShutdownAction(executor, vm), nil
+	case "reboot":
+		return actions.NewRebootAction(executor, vm), nil
+	case "stop":
+		return actions.NewStopAction(executor, vm), nil
+	case "resume":
+		return actions.NewResumeAction(executor, vm), nil
+	case "hibernate":
+		return actions.NewHibernateAction(executor, vm), nil
+	}
+	return nil, fmt.Errorf("unknown action: %s", name)
+}
 
-		// Create adapter to match actions.Executor interface
-		executor := &executorAdapter{
-			client: m.parent.client,
-			node:   vm.Node,
-			vmType: vm.Type,
+// handleNodePowerKey opens the node power dialog for the selected guest's
+// node. Without allow_node_power_actions it only explains how to enable it.
+func (m *listModel) handleNodePowerKey() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	if m.parent.selectedIdx < 0 || m.parent.selectedIdx >= len(m.parent.sortedNodes) {
+		m.parent.refreshMutex.Unlock()
+		return true, m, nil
+	}
+	node := m.parent.sortedNodes[m.parent.selectedIdx].Node
+	running := 0
+	for _, vm := range m.parent.guests.All() {
+		if vm.Node == node && vm.IsRunning() {
+			running++
 		}
+	}
+	m.parent.refreshMutex.Unlock()
+
+	state := nodepower.New(node, running)
+	switch {
+	case m.parent.appConfig == nil || !m.parent.appConfig.AllowNodePowerActions:
+		state.Done = true
+		state.Err = fmt.Errorf("node power actions are disabled; set allow_node_power_actions in the config file")
+	case m.parent.nodePower == nil:
+		state.Done = true
+		state.Err = fmt.Errorf("client not available")
+	}
+	m.nodePower = &state
+	return true, m, nil
+}
 
-		var action actions.Action
-		switch actionName {
-		case "start":
-			action = actions.NewStartAction(executor, vm)
-		case "shutdown":
-			action = actions.NewShutdownAction(executor, vm)
-		case "reboot":
-			action = actions.NewRebootAction(executor, vm)
-		case "stop":
-			action = actions.NewStopAction(executor, vm)
-		default:
-			return actionResultMsg{err: fmt.Errorf("unknown action: %s", actionName)}
+// handleNodePowerKeys handles keys while the node power dialog is open
+func (m *listModel) handleNodePowerKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	switch m.nodePower.HandleKey(msg) {
+	case screen.Closed:
+		m.nodePower = nil
+	case screen.Confirmed:
+		m.nodePower.Sending = true
+		client, node, action := m.parent.nodePower, m.nodePower.Node, m.nodePower.Action
+		timeout := m.parent.actionTimeout()
+		return true, m, func() tea.Msg {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if action == nodepower.Shutdown {
+				return nodePowerResultMsg{err: client.NodeShutdown(ctx, node)}
+			}
+			return nodePowerResultMsg{err: client.NodeReboot(ctx, node)}
 		}
+	}
+	return true, m, nil
+}
 
-		err := action.Execute(ctx)
-		return actionResultMsg{err: err}
+// actionTimeout returns the configured power action timeout
+func (ml *MainList) actionTimeout() time.Duration {
+	if ml.appConfig != nil && ml.appConfig.ActionTimeout > 0 {
+		return ml.appConfig.ActionTimeout
 	}
+	return DefaultActionTimeout
+}
+
+// View implements tea.Model. The result is trimmed to the terminal height
+// so a view that overflows never pushes the title off screen.
+func (m *listModel) View() (view string) {
+	// After a panic the list may hold its lock or bad data: draw nothing
+	// until the program quits
+	if m.parent.fatal.Load() != nil {
+		return ""
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			m.parent.crashed(crash.New(r))
+			view = ""
+		}
+	}()
+	// Errors and dialogs may quote a server or a file; mask any secret
+	// that slipped through rather than show it
+	return format.FitWidth(format.FitHeight(redact.String(m.renderView()), m.height), m.width)
 }
 
-// View implements tea.Model
-func (m *listModel) View() string {
+// renderView renders whichever screen is active
+func (m *listModel) renderView() string {
+	if format.IsTooSmall(m.width, m.height) {
+		return format.TooSmall(m.width, m.height)
+	}
+
 	// Show help dialog if requested (full screen)
 	if m.showHelp {
 		return helpdialog.GetHelpText(m.width, m.height)
 	}
 
+	// Show state change events if requested (full screen)
+	if m.showEvents {
+		m.parent.refreshMutex.Lock()
+		defer m.parent.refreshMutex.Unlock()
+		return eventlog.GetEventsText(m.parent.events, m.width, m.height, m.eventsScroll)
+	}
+
+	// Show the running tasks if requested (full screen)
+	if m.tasks != nil {
+		return tasks.GetText(*m.tasks, m.width, m.height, m.parent.now())
+	}
+
+	// Show the serial console if requested (full screen)
+	if m.serial != nil {
+		return serialconsole.GetText(*m.serial, m.width, m.height)
+	}
+
+	// Show the ISO images and templates if requested (full screen)
+	if m.storage != nil {
+		return storage.GetText(*m.storage, m.width, m.height)
+	}
+
+	// Show the token permissions if requested (full screen)
+	if m.permissions != nil {
+		return permissions.GetText(*m.permissions, m.width, m.height)
+	}
+
+	// Show the API requests if requested (full screen)
+	if m.requestLog != nil {
+		return requestlog.GetText(*m.requestLog, m.width, m.height)
+	}
+
+	// Show the guests pvec stopped if requested (full screen)
+	if m.undoList != nil {
+		return undolist.GetText(*m.undoList, m.parent.undo.Entries(), m.width, m.height)
+	}
+
+	// Show the clone presets if requested (full screen)
+	if m.clonePicker != nil {
+		return clonepresets.GetText(*m.clonePicker, m.cloneEntries(), m.width, m.height)
+	}
+
+	// Show the node summary if requested (full screen)
+	if m.nodeSummary != nil {
+		return m.renderNodeSummary()
+	}
+
+	// Show the pool rollup if requested (full screen)
+	if m.pools != nil {
+		return m.renderPools()
+	}
+
+	// Show the batch tag or pool change (full screen)
+	if m.batch != nil {
+		return batch.GetText(*m.batch, m.width, m.height)
+	}
+
+	// Show the node power confirmation (full screen)
+	if m.nodePower != nil {
+		return nodepower.GetConfirmText(*m.nodePower, m.width, m.height)
+	}
+
+	// Show the HA submenu (full screen)
+	if m.ha != nil {
+		return hamenu.GetText(*m.ha, m.width, m.height)
+	}
+
+	// Show the schedule prompt or the scheduled actions (full screen)
+	if m.schedulePrompt != nil {
+		return schedule.GetPromptText(*m.schedulePrompt, m.width, m.height, m.parent.now())
+	}
+	if m.scheduleList != nil {
+		return schedule.GetListText(*m.scheduleList, m.parent.stateFile.Scheduled(), m.width, m.height, m.parent.now())
+	}
+
 	// Show config panel if requested (full screen)
 	if m.showConfig && m.configModel != nil {
 		return m.configModel.View()
@@ -576,12 +1598,15 @@ func (m *listModel) View() string {
 		} else if m.detailsError != nil {
 			return detailsdialog.GetErrorText(m.detailsVM, m.detailsError, m.width, m.height)
 		} else {
-			return detailsdialog.GetDetailsText(m.detailsVM, m.detailsConfig, m.width, m.height, m.detailsScroll)
+			m.parent.refreshMutex.Lock()
+			m.detailsState.NodeLoad = m.parent.nodeLoadDetails(m.detailsVM)
+			m.parent.refreshMutex.Unlock()
+			return detailsdialog.GetDetailsText(m.detailsVM, m.detailsConfig, m.detailsFS, m.width, m.height, m.detailsState)
 		}
 	}
 
-	// Render main list
-	return m.renderMainList()
+	// Render main list, with the cheat-sheet over it if requested
+	return m.withCheatSheet(m.renderMainList())
 }
 
 // renderMainList renders the main VM/Container list
@@ -590,138 +1615,433 @@ func (m *listModel) renderMainList() string {
 	m.parent.refreshMutex.Lock()
 	defer m.parent.refreshMutex.Unlock()
 
-	var b strings.Builder
-
-	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-
 	// Title
 	title := "Proxmox VMs & Containers "
 	if m.parent.lastError != nil {
 		title = "Proxmox VMs & Containers (Error Connecting) "
 	}
-	b.WriteString(titleStyle.Render(title))
-	b.WriteString("\n")
+	if order := m.parent.activeSort(); order != defaultSort {
+		title += fmt.Sprintf("[sort: %s] ", order)
+	}
+	for _, label := range m.parent.filter.labels() {
+		title += fmt.Sprintf("[%s] ", label)
+	}
+	title += m.parent.sweepText()
+	if updated := m.parent.updatedText(m.parent.now()); updated != "" {
+		title += "- " + updated + " "
+	}
+	lines := []string{format.TitleStyle().Render(title)}
 
-	headerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
-	separatorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000"))
+	// Authentication/authorization notice, and when the next try runs
+	notice := errorNotice(m.parent.lastError)
+	if retry := m.parent.retryText(); retry != "" {
+		if notice == "" {
+			notice = "Refresh failed"
+		}
+		notice += format.Text(" — " + retry)
+	}
+	if notice != "" {
+		noticeStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFFFFF")).
+			Background(lipgloss.Color("#FF0000")).
+			Bold(true).
+			Width(m.width)
+		// Long notices wrap on narrow terminals
+		lines = append(lines, strings.Split(noticeStyle.Render(notice), "\n")...)
+	}
+	clusterStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#000000")).
+		Background(lipgloss.Color("#FFD700")).
+		Width(m.width)
+	for _, notice := range []string{m.parent.clusterNotice(), m.parent.probeNotice(), m.shutdownToast()} {
+		if notice != "" {
+			lines = append(lines, strings.Split(clusterStyle.Render(notice), "\n")...)
+		}
+	}
 
 	// Header
-	header := fmt.Sprintf("%-6s %-6s %-20s %-4s %-10s %8s %8s %10s",
-		"Status", "VMID", "Name", "Type", "Node", "CPU%", "Memory%", "Uptime")
-	b.WriteString(headerStyle.Render(header))
-	b.WriteString("\n")
-
-	// Separator
-	separator := strings.Repeat("─", m.width)
-	b.WriteString(separatorStyle.Render(separator))
-	b.WriteString("\n")
+	header := m.parent.headerText()
+	if !format.Color() {
+		header = format.Repeat(" ", markerWidth) + header
+	}
+	lines = append(lines,
+		format.TitleStyle().Render(header),
+		format.SeparatorStyle().Render(format.Separator(m.width)))
 
 	// Rows
-	visibleRows := m.height - 4 // Title, header, separator, and status bar
+	visibleRows := m.height - len(lines) - 1 // Status bar
 	endIdx := m.scrollOffset + visibleRows
 	if endIdx > len(m.parent.sortedNodes) {
 		endIdx = len(m.parent.sortedNodes)
 	}
 
+	var rows []string
 	for i := m.scrollOffset; i < endIdx; i++ {
 		node := m.parent.sortedNodes[i]
-		b.WriteString(m.renderRow(node, i == m.cursorPosition))
-		b.WriteString("\n")
+		rows = append(rows, m.renderRow(node, i == m.cursorPosition))
 	}
-
-	// Fill remaining space
-	for i := endIdx - m.scrollOffset; i < visibleRows; i++ {
-		b.WriteString("\n")
+	if len(rows) == 0 && m.parent.guests.Count() > 0 && m.parent.filter.active() {
+		rows = append(rows, fmt.Sprintf("  No guests match the filter (%d hidden) - ESC to clear it", m.parent.guests.Count()))
 	}
 
 	// Status bar
-	statusStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")).Bold(true)
+	statusStyle := format.StatusStyle()
 	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Bold(true)
 
 	var statusText string
-	if m.showAction && m.actionVM != nil {
-		actionCap := cases.Title(language.English).String(m.actionName)
+	if m.wake != nil {
+		statusText = m.wake.statusText()
+		if m.wake.done {
+			statusText = errorStyle.Render(statusText)
+		} else {
+			statusText = statusStyle.Render(statusText)
+		}
+	} else if m.undo != nil {
+		statusText = m.undo.statusText()
+		if m.undo.done {
+			statusText = errorStyle.Render(statusText)
+		} else {
+			statusText = statusStyle.Render(statusText)
+		}
+	} else if m.clone != nil {
+		statusText = m.clone.statusText()
+		if m.clone.done {
+			statusText = errorStyle.Render(statusText)
+		} else {
+			statusText = statusStyle.Render(statusText)
+		}
+	} else if m.group != nil {
+		statusText = m.group.statusText(m.parent.now())
+		if m.group.done {
+			statusText = errorStyle.Render(statusText)
+		} else {
+			statusText = statusStyle.Render(statusText)
+		}
+	} else if m.restart != nil {
+		statusText = m.restart.statusText(m.parent.now())
+		if m.restart.done() {
+			statusText = errorStyle.Render(statusText)
+		} else {
+			statusText = statusStyle.Render(statusText)
+		}
+	} else if m.snapshotAsk != nil {
+		statusText = statusStyle.Render(m.snapshotAsk.prompt())
+	} else if m.windowAsk != nil {
+		statusText = statusStyle.Render(m.windowAsk.prompt())
+	} else if m.hibernateAsk != nil {
+		statusText = statusStyle.Render(m.hibernateAsk.prompt())
+	} else if m.showAction && m.actionVM != nil {
 		if m.actionDone {
-			if m.actionError != nil {
-				statusText = errorStyle.Render(fmt.Sprintf("Failed to %s %s. - Press any key", m.actionName, m.actionVM.VMID))
-			} else {
-				statusText = errorStyle.Render(fmt.Sprintf("Succeeded in %s %s. - Press any key", m.actionName, m.actionVM.VMID))
-			}
+			statusText = errorStyle.Render(m.actionResultText())
 		} else {
-			statusText = statusStyle.Render(fmt.Sprintf("%s on %s...", actionCap, m.actionVM.Name))
+			actionCap := cases.Title(language.English).String(m.actionName)
+			if m.actionSnapshot {
+				actionCap = "Snapshot and " + m.actionName
+			}
+			elapsed := int(m.parent.now().Sub(m.actionStarted).Seconds())
+			statusText = statusStyle.Render(fmt.Sprintf("%s on %s... %ds - ESC to cancel", actionCap, m.actionVM.Name, elapsed))
 		}
+	} else if offer := m.undoOfferText(); offer != "" {
+		statusText = offer
+	} else if m.updateVersion != "" {
+		statusText = m.updateText()
+	} else if m.serverNotice {
+		statusText = m.serverNoticeText()
 	} else {
-		statusText = "F1 Help  F2 Conf  F3 Info F4 Start  F5 shutDown  F6 Reboot  F7 sTop  F10 Quit"
+		statusText = m.listStatusText()
 	}
-	b.WriteString(statusStyle.Render(statusText))
-	return b.String()
+	return format.Screen(lines, rows, statusStyle.Render(statusText), m.height)
 }
 
-func (m *listModel) renderRow(node *models.VMStatus, selected bool) string {
-	// Status indicator
-	statusSymbol := node.Status
-
-	// Type
-	typeText := "VM"
-	if models.NodeType(node.Type) == models.TypeContainer {
-		typeText = "CT"
+// actionResultText describes how the last action ended. Cancelling only
+// abandons the request: the server may still carry the action out.
+func (m *listModel) actionResultText() string {
+	vmid := m.actionVM.Key()
+	switch {
+	case m.actionError == nil && m.actionNote != "":
+		return fmt.Sprintf("Succeeded in %s %s (%s). - Press any key", m.actionName, vmid, m.actionNote)
+	case m.actionError == nil && m.undoOffered(m.actionVM):
+		return fmt.Sprintf("Succeeded in %s %s. - z to undo, any other key to close", m.actionName, vmid)
+	case m.actionError == nil:
+		return fmt.Sprintf("Succeeded in %s %s. - Press any key", m.actionName, vmid)
+	case errors.Is(m.actionError, errActionCancelled):
+		return fmt.Sprintf("Cancelled %s %s by user; it may still complete. - Press any key", m.actionName, vmid)
+	case errors.Is(m.actionError, context.DeadlineExceeded):
+		return fmt.Sprintf("Timed out after %s to %s %s. - Press any key", m.actionTimeout, m.actionName, vmid)
+	case errors.As(m.actionError, new(*guestLockedError)), errors.As(m.actionError, new(*nodeOfflineError)),
+		errors.As(m.actionError, new(*guestUnknownError)), errors.As(m.actionError, new(*serverLimitError)):
+		return fmt.Sprintf("Cannot %s %s: %v. - Press any key", m.actionName, vmid, m.actionError)
+	case errors.As(m.actionError, new(*actions.SnapshotError)):
+		return fmt.Sprintf("Cannot %s %s: %v. - Press any key", m.actionName, vmid, redact.Error(m.actionError))
+	case errors.As(m.actionError, new(*crash.Panic)):
+		return fmt.Sprintf("Failed to %s %s: %v. - Press any key", m.actionName, vmid, redact.Error(m.actionError))
 	}
-
-	// CPU and Memory
-	cpuUsage := node.CPUUsage
-	if cpuUsage < 0 {
-		cpuUsage = 0
+	if hint := proxmox.PermissionHint(m.actionError); hint != "" {
+		return fmt.Sprintf("Failed to %s %s: the token %s. - Press any key", m.actionName, vmid, hint)
 	}
-	cpuText := fmt.Sprintf("%.1f%%", cpuUsage)
+	return fmt.Sprintf("Failed to %s %s. - Press any key", m.actionName, vmid)
+}
 
-	memUsage := node.MemoryUsage
-	if memUsage < 0 {
-		memUsage = 0
+func (m *listModel) renderRow(node *models.VMStatus, selected bool) string {
+	// Every cell is fitted to its column, so the row lines up with the
+	// header; cells are kept apart so they can be colored on their own
+	duplicate := m.parent.isDuplicate(node)
+	visible := m.parent.visibleColumns()
+	cells := make([]string, len(visible))
+	for i, c := range visible {
+		cells[i] = c.align(m.cellText(c.id, node, duplicate))
 	}
-	memText := fmt.Sprintf("%.1f%%", memUsage)
+	row := strings.Join(cells, " ")
 
-	// Uptime
-	uptimeText := formatUptime(node.Uptime)
+	changed, ok := m.parent.changedAt[node.Key()]
+	recentlyChanged := ok && m.parent.now().Sub(changed) < changeHighlightDuration
 
-	// Build row
-	row := fmt.Sprintf("%-6s %-6s %-20s %-4s %-10s %8s %8s %10s",
-		statusSymbol,
-		node.VMID,
-		truncate(node.Name, 20),
-		typeText,
-		truncate(node.Node, 10),
-		cpuText,
-		memText,
-		uptimeText)
+	// Without color, a text gutter carries the selection and alerts
+	if !format.Color() {
+		alert := recentlyChanged || (node.HasDiskUsage() && node.DiskUsage() >= format.UsageWarning) ||
+			(m.parent.showSince && m.parent.downAlert(node, m.parent.now())) || m.parent.cpuPressure(node)
+		return rowMarker(selected, alert) + row
+	}
 
 	// Apply selection style first
 	if selected {
+		// Pad to the terminal width, but never wrap a row that is wider,
+		// as when the width isn't known yet
 		rowStyle := lipgloss.NewStyle().
 			Reverse(true).
-			Width(m.width)
+			Width(max(m.width, lipgloss.Width(row)))
 		return rowStyle.Render(row)
 	}
 
-	// Apply color to status symbol after selection (only for non-selected rows)
-	runningStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#008000"))
-	stoppedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
+	// Guests of a cluster that didn't answer, or of a node that is
+	// offline, show their last known state; guests in an unknown state
+	// have none to show
+	if node.Unreachable || node.NodeOffline || node.IsUnknown() {
+		return unreachableStyle.Render(row)
+	}
+
+	// A busy guest on a busy node is likely starved by its host
+	if m.parent.cpuPressure(node) {
+		return cpuPressureStyle.Render(row)
+	}
 
-	if node.Status == string(models.StateRunning) {
-		// Replace the status symbol with colored version
-		row = runningStyle.Render(statusSymbol) + row[len(statusSymbol):]
-	} else if node.Status == string(models.StateStopped) {
-		// Replace the status symbol with colored version
-		row = stoppedStyle.Render(statusSymbol) + row[len(statusSymbol):]
+	// Recently changed rows are highlighted as a whole
+	if recentlyChanged {
+		changedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFA500"))
+		return changedStyle.Render(row)
 	}
 
-	return row
+	for i, c := range visible {
+		if style, ok := cellStyle(c.id, node, duplicate); ok {
+			cells[i] = style.Render(cells[i])
+		}
+		if c.id == colSince && m.parent.downAlert(node, m.parent.now()) {
+			cells[i] = downAlertStyle.Render(cells[i])
+		}
+		if c.id == colFlags {
+			cells[i] = c.align(m.parent.flagsStyled(node))
+		}
+		if suffix := m.parent.nodeLoadText(node); c.id == colCPU && suffix != "" {
+			cells[i] = c.align(format.Percent(node.CPUUsage, 1) + nodeLoadStyle.Render(suffix))
+		}
+	}
+	return strings.Join(cells, " ")
+}
+
+// cellStyle returns the color of a guest's cell in a column, if it has
+// one: the status by state, the node when it tells apart guests sharing a
+// name, and the disk by usage
+func cellStyle(id columnID, node *models.VMStatus, duplicate bool) (lipgloss.Style, bool) {
+	switch id {
+	case colStatus:
+		switch node.Status {
+		case models.StateRunning:
+			return lipgloss.NewStyle().Foreground(lipgloss.Color("#008000")), true
+		case models.StateStopped, models.StateHibernated:
+			return lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")), true
+		case models.StatePaused:
+			return lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD700")), true
+		}
+	case colNode:
+		if duplicate {
+			return duplicateNodeStyle, true
+		}
+	case colDisk:
+		if node.HasDiskUsage() {
+			return usageStyle(node.DiskUsage())
+		}
+	}
+	return lipgloss.Style{}, false
 }
 
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+// statusText returns the Status cell of a guest: a pause sign for a
+// paused VM, so it can't be mistaken for a running one, hibernated
+// shortened to fit the column, stale for a guest of an offline node and ?
+// for a guest in a state the API doesn't tell
+func statusText(node *models.VMStatus) string {
+	if node.NodeOffline {
+		return "stale"
+	}
+	switch node.Status {
+	case models.StateUnknown:
+		return "?"
+	case models.StatePaused:
+		return format.Text("❚❚")
+	case models.StateHibernated:
+		return "hibern."
 	}
-	return s[:maxLen-3] + "..."
+	return format.Truncate(node.StatusString(), 7)
+}
+
+// nameText returns the Name cell, led by ! when QEMU doesn't run the
+// guest the VM is listed as running, by ≠ when the guest's own hostname
+// is another, by a lock sign when a lock blocks actions on the guest and
+// by ≡ when another guest has the same name
+func nameText(node *models.VMStatus, duplicate, wedged, renamed bool) string {
+	var signs string
+	if wedged {
+		signs += "!"
+	}
+	if renamed {
+		signs += format.Text("≠")
+	}
+	if node.Locked() {
+		signs += format.Text("🔒")
+	}
+	if duplicate {
+		signs += format.Text("≡")
+	}
+	return signs + format.Truncate(node.Name, nameWidth()-lipgloss.Width(signs))
+}
+
+// duplicateNodeStyle draws the node of guests sharing a name
+var duplicateNodeStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FFD700"))
+
+// isDuplicate reports whether another listed guest has the guest's name
+func (ml *MainList) isDuplicate(node *models.VMStatus) bool {
+	return node.Name != "" && len(ml.duplicates[strings.ToLower(node.Name)]) > 1
+}
+
+// nodeWidth is the width of the Node column
+const nodeWidth = 9
+
+// markerWidth is the width of the row gutter drawn when color is off
+const markerWidth = 4
+
+// nameWidth returns the Name column width; without color it gives up room
+// for the marker gutter so rows still fit in 80 columns
+func nameWidth() int {
+	if format.Color() {
+		return 20
+	}
+	return 20 - markerWidth
+}
+
+// rowMarker returns the gutter that stands in for reverse video and alert
+// colors when color is off: ">" marks the selection, "[!]" a recently
+// changed guest or one whose disk usage crosses the warning threshold
+func rowMarker(selected, alert bool) string {
+	marker := " "
+	if selected {
+		marker = ">"
+	}
+	if alert {
+		return marker + "[!]"
+	}
+	return marker + "   "
+}
+
+// usageStyle returns the warning style for a usage percentage, if it crosses a threshold
+func usageStyle(percent float64) (lipgloss.Style, bool) {
+	switch {
+	case percent >= format.UsageCritical:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Bold(true), true
+	case percent >= format.UsageWarning:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("#FFA500")), true
+	}
+	return lipgloss.Style{}, false
+}
+
+// recordStateChanges diffs nodes against the previous snapshot, marks
+// changed rows for highlighting and appends the changes to the event log.
+// Must be called with refreshMutex held.
+func (ml *MainList) recordStateChanges(nodes []*models.VMStatus, now time.Time) []models.StateChange {
+	var changes []models.StateChange
+	if ml.snapshot != nil {
+		changes = models.DetectStateChanges(ml.snapshot, nodes, now)
+		for _, change := range changes {
+			ml.changedAt[change.Key()] = now
+		}
+		ml.events = eventlog.Append(ml.events, changes...)
+	}
+	ml.snapshot = nodes
+	ml.expireChanges(now)
+	return changes
+}
+
+// expireChanges forgets the highlights older than changeHighlightDuration.
+// Must be called with refreshMutex held.
+func (ml *MainList) expireChanges(now time.Time) {
+	for key, changed := range ml.changedAt {
+		if now.Sub(changed) >= changeHighlightDuration {
+			delete(ml.changedAt, key)
+		}
+	}
+}
+
+// changeExpiredMsg is sent once the highlights of status changes expire
+type changeExpiredMsg struct{}
+
+// changeExpiryCmd clears the highlights of changes once they expire, so
+// the rows lose them on time rather than on the next refresh; nil without
+// status changes
+func changeExpiryCmd(changes []models.StateChange) tea.Cmd {
+	if len(changes) == 0 {
+		return nil
+	}
+	return tea.Tick(changeHighlightDuration, func(time.Time) tea.Msg { return changeExpiredMsg{} })
+}
+
+// patchGuest replaces the guest with the same VMID in the guest list and
+// records any resulting state change. It reports false when the guest is
+// no longer listed. Must be called with refreshMutex held.
+func (ml *MainList) patchGuest(guest *models.VMStatus, now time.Time) ([]*models.VMStatus, []models.StateChange, bool) {
+	prev, found := ml.guests.Get(guest.Key())
+	if !found {
+		return nil, nil, false
+	}
+	if guest.Pool == "" {
+		guest.Pool = prev.Pool // status/current doesn't tell the pool
+	}
+
+	ml.guests.Add(guest)
+	ml.guestsDigest = 0 // The next refresh must be applied, even if the same
+	nodes := ml.guests.All()
+	return nodes, ml.recordStateChanges(nodes, now), true
+}
+
+// errorNotice returns the full-width notice for authentication and
+// authorization failures and for panics recovered in the background, or
+// an empty string for any other error
+func errorNotice(err error) string {
+	switch {
+	case proxmox.IsUnauthorized(err):
+		return format.Text("Authentication failed — press F2 to update your token")
+	case proxmox.IsForbidden(err):
+		path := proxmox.DeniedPath(err)
+		if path == "" {
+			path = "the requested endpoint"
+		}
+		if hint := proxmox.PermissionHint(err); hint != "" {
+			return format.Text(fmt.Sprintf("Permission denied: the token %s — press P for details", hint))
+		}
+		return format.Text(fmt.Sprintf("Permission denied on %s — check the token privileges (VM.Audit, Sys.Audit)", path))
+	case errors.As(err, new(*proxmox.FingerprintMismatchError)):
+		return format.Text("The server certificate changed and no longer matches tls_fingerprint — check it before updating the setting")
+	case errors.As(err, new(*crash.Panic)):
+		return format.Text(fmt.Sprintf("%v — please report this bug", redact.Error(err)))
+	}
+	return ""
 }
 
 // tickCmd generates tick messages for the event loop
@@ -735,9 +2055,9 @@ func tickCmd() tea.Cmd {
 func (ml *MainList) autoRefresh() {
 	for {
 		select {
-		case <-ml.refreshTicker.C:
-			if ml.refreshEnabled {
-				ml.performRefresh()
+		case <-ml.refreshTicker.C():
+			if ml.autoRefreshDue() {
+				_ = ml.refresh(ml.ctx)
 			}
 		case <-ml.stopRefresh:
 			return
@@ -745,25 +2065,95 @@ func (ml *MainList) autoRefresh() {
 	}
 }
 
-// performRefresh executes a single refresh cycle
-func (ml *MainList) performRefresh() {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// suspendSupported mirrors Bubble Tea, which ignores tea.Suspend on
+// Windows; no tea.ResumeMsg would ever clear the suspended flag there
+var suspendSupported = runtime.GOOS != "windows"
+
+// autoRefreshDue reports whether a ticker refresh should go ahead. While
+// suspended, ticks are skipped rather than queued behind the stopped UI.
+func (ml *MainList) autoRefreshDue() bool {
+	return ml.refreshEnabled.Load() && !ml.refreshPaused.Load() && !ml.suspended.Load()
+}
+
+// resumeCmd catches up after the process was continued with fg: the
+// terminal may have been resized and the data is stale by an unknown time
+func (ml *MainList) resumeCmd() tea.Cmd {
+	ml.suspended.Store(false)
+	return tea.Batch(tea.ClearScreen, tea.WindowSize(), ml.refreshCmd())
+}
+
+// refresh runs one refresh cycle and hands the outcome to the program
+func (ml *MainList) refresh(ctx context.Context) error {
+	msg, ok := ml.timedFetch(ctx, ml.refreshTimeout)
+	if !ok {
+		return ctx.Err()
+	}
+	ml.program.Send(msg)
+	return msg.err
+}
+
+// timedFetch fetches the nodes within timeout. It reports false when ctx
+// was cancelled: the program is shutting down or the caller gave up, and
+// the error is not worth showing.
+func (ml *MainList) timedFetch(ctx context.Context, timeout time.Duration) (refreshMsg, bool) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	nodes, err := ml.provider.GetNodes(ctx)
+	probes := ml.startProbes(ctx)
+	msg := ml.fetchNodes(ctx)
+	msg.probes = probes()
+	return msg, !errors.Is(ctx.Err(), context.Canceled)
+}
 
-	ml.program.Send(refreshMsg{nodes: nodes, err: err})
+// fetchNodes queries the provider and wraps the outcome in a refreshMsg.
+// The guests decide whether the refresh failed; the other sections of a
+// snapshot may fail on their own.
+func (ml *MainList) fetchNodes(ctx context.Context) (msg refreshMsg) {
+	start := time.Now()
+	// A provider that panics fails the refresh, like one that errs
+	defer func() {
+		if r := recover(); r != nil {
+			msg = refreshMsg{err: crash.New(r), took: time.Since(start)}
+		}
+	}()
+	ml.refreshMutex.Lock()
+	current := ml.provider
+	ml.refreshMutex.Unlock()
+	provider, ok := current.(SnapshotProvider)
+	if !ok {
+		nodes, err := current.GetNodes(ctx)
+		snap := &proxmox.RefreshSnapshot{Guests: nodes, TakenAt: time.Now()}
+		if err != nil {
+			snap.Errors = map[proxmox.Section]error{proxmox.SectionGuests: err}
+		}
+		return refreshMsg{nodes: nodes, err: err, snapshot: snap, took: time.Since(start)}
+	}
+	snap := provider.Snapshot(ctx)
+	return refreshMsg{nodes: snap.Guests, err: snap.Err(proxmox.SectionGuests), snapshot: snap, took: time.Since(start)}
 }
 
-// GetSelectedNode returns the currently selected VM/CT
+// fetchGuestCmd queries the status of a single guest in the background
+func (ml *MainList) fetchGuestCmd(vm *models.VMStatus) tea.Cmd {
+	reader := ml.reader
+	if reader == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		guest, err := reader.GetGuestStatus(ctx, vm.Node, vm.TypeString(), vm.Key())
+		return guestUpdateMsg{guest: guest, err: err}
+	}
+}
+
+// GetSelectedNode returns the currently selected VM/CT. Like the other
+// exported methods, it may be called from any goroutine.
 func (ml *MainList) GetSelectedNode() *models.VMStatus {
 	ml.refreshMutex.Lock()
 	defer ml.refreshMutex.Unlock()
 
-	if ml.selectedIdx < 0 || ml.selectedIdx >= len(ml.sortedNodes) {
-		return nil
-	}
-	return ml.sortedNodes[ml.selectedIdx]
+	return ml.selectedGuest()
 }
 
 // GetAllNodes returns all nodes
@@ -771,51 +2161,85 @@ func (ml *MainList) GetAllNodes() []*models.VMStatus {
 	ml.refreshMutex.Lock()
 	defer ml.refreshMutex.Unlock()
 
-	result := make([]*models.VMStatus, len(ml.nodes))
-	copy(result, ml.nodes)
-	return result
+	return ml.guests.All()
 }
 
-// Refresh fetches and updates the data
+// Refresh fetches and updates the data, within ctx and the refresh
+// timeout. It must be called while Run is active.
 func (ml *MainList) Refresh(ctx context.Context) error {
-	nodes, err := ml.provider.GetNodes(ctx)
-	if err != nil {
-		ml.program.Send(refreshMsg{nodes: nil, err: err})
-		return err
-	}
-
-	ml.program.Send(refreshMsg{nodes: nodes, err: nil})
-	return nil
+	return ml.refresh(ctx)
 }
 
-// SetRefreshEnabled enables or disables auto-refresh
+// SetRefreshEnabled enables or disables auto-refresh. It may be called
+// from any goroutine.
 func (ml *MainList) SetRefreshEnabled(enabled bool) {
-	ml.refreshEnabled = enabled
+	ml.refreshEnabled.Store(enabled)
 }
 
-// reinitializeClient creates a new Proxmox client with updated configuration
-func (ml *MainList) reinitializeClient() {
+// reinitializeClient creates a new Proxmox client with updated
+// configuration. The current client is kept if the new one can't be
+// created.
+func (ml *MainList) reinitializeClient() error {
 	// Create new client with updated config
-	newClient := proxmox.NewClient(
-		ml.appConfig.APIUrl,
-		ml.appConfig.GetAuthToken(),
-		ml.appConfig.SkipTLSVerify,
-	)
+	var observer proxmox.RequestObserver
+	if ml.requests != nil {
+		observer = ml.requests
+	}
+	newClient, err := NewConfiguredClient(ml.appConfig, observer)
+	if err != nil {
+		return err
+	}
 
-	// Update the provider and client
-	ml.client = newClient
-	ml.provider = newClient
+	// Update the clients; commands in flight keep those they captured
+	ml.reader = newClient
+	ml.power = newClient
+	ml.executor = newExecutor(newClient, ml.guests)
+	ml.nodePower, _ = newClient.(proxmox.NodePowerController)
+	ml.taskManager, _ = newClient.(proxmox.TaskManager)
+	ml.storageManager, _ = newClient.(proxmox.StorageManager)
+	ml.permissionReader, _ = newClient.(proxmox.PermissionReader)
+	ml.cloudInitManager, _ = newClient.(proxmox.CloudInitManager)
+	ml.lockManager, _ = newClient.(proxmox.LockManager)
+	ml.haManager, _ = newClient.(proxmox.HAManager)
+	ml.serverInspector, _ = newClient.(proxmox.ServerInspector)
+	ml.serialConsole, _ = newClient.(proxmox.SerialConsole)
+	snapshots, _ := newClient.(proxmox.SnapshotManager)
+	ml.snapshots = newSnapshotter(snapshots, ml.guests)
+	clones, _ := newClient.(proxmox.CloneManager)
+	ml.cloner = newCloner(clones, ml.guests)
+	organizer, _ := newClient.(proxmox.GuestOrganizer)
+	ml.tagger, ml.poolMover = newOrganizer(organizer, newClient, ml.guests)
+	ml.clusterHealth, _ = newClient.(proxmox.ClusterHealth)
+	ml.refreshPaused.Store(false)
+	ml.backoff.reset()
+	ml.refreshMutex.Lock()
+	ml.provider = NewConfiguredProvider(ml.appConfig, newClient) // A refresh in flight keeps the one it read
+	ml.cancelSweep()
+	ml.configReadAt = make(map[string]time.Time) // Another server may answer now
+	ml.healthReadAt = make(map[string]time.Time)
+	ml.refreshMutex.Unlock()
 
 	// Update refresh interval if it changed
-	if ml.refreshTicker != nil && ml.appConfig.RefreshInterval > 0 {
-		ml.refreshTicker.Reset(ml.appConfig.RefreshInterval)
+	if ml.refreshTicker != nil && ml.appConfig.IdleRefreshInterval() > 0 {
+		ml.refreshMutex.Lock()
+		activeAt := ml.pace.activeAt
+		ml.pace = newRefreshPace(ml.appConfig.IdleRefreshInterval(), ml.appConfig.RefreshIntervalActive, ml.appConfig.RefreshActiveWindow)
+		ml.pace.activeAt = activeAt
+		ml.refreshInterval = ml.pace.interval(ml.now())
+		ml.refreshTicker.Reset(ml.refreshInterval)
+		ml.refreshMutex.Unlock()
 	}
+	return nil
 }
 
-// refreshCmd returns a command that triggers a refresh
+// refreshCmd returns a command that refreshes the list under the root
+// context
 func (ml *MainList) refreshCmd() tea.Cmd {
+	ctx, timeout := ml.ctx, ml.refreshTimeout
 	return func() tea.Msg {
-		go ml.performRefresh()
+		if msg, ok := ml.timedFetch(ctx, timeout); ok {
+			return msg
+		}
 		return nil
 	}
 }
@@ -826,61 +2250,25 @@ func (ml *MainList) Stop() {
 		ml.refreshTicker.Stop()
 	}
 	close(ml.stopRefresh)
+	ml.cancel()
 	if ml.program != nil {
 		ml.program.Quit()
 	}
 }
 
-// Run starts the program
+// Run starts the program. With FailFast set, it returns the error of a
+// failed first refresh.
 func (ml *MainList) Run() error {
+	ml.running.Store(true)
+	defer ml.running.Store(false)
 	_, err := ml.program.Run()
-	return err
-}
-
-// sortNodes sorts nodes by type (CT first, then VM) and then by name
-func sortNodes(nodes []*models.VMStatus) []*models.VMStatus {
-	sorted := make([]*models.VMStatus, len(nodes))
-	copy(sorted, nodes)
-
-	// Bubble sort: CT < VM, then alphabetically by name
-	for i := 0; i < len(sorted)-1; i++ {
-		for j := i + 1; j < len(sorted); j++ {
-			if shouldSwap(sorted[i], sorted[j]) {
-				sorted[i], sorted[j] = sorted[j], sorted[i]
-			}
-		}
+	if fatal := ml.fatal.Load(); fatal != nil {
+		return fatal.err
 	}
-	return sorted
-}
-
-// shouldSwap determines if two nodes should be swapped in sort order
-func shouldSwap(a, b *models.VMStatus) bool {
-	// CT (container) should come before VM
-	if a.Type == string(models.TypeVM) && b.Type == string(models.TypeContainer) {
-		return true
-	}
-	// Within same type, sort alphabetically by name
-	if a.Type == b.Type && a.Name > b.Name {
-		return true
-	}
-	return false
-}
-
-// formatUptime converts seconds to human-readable format
-func formatUptime(seconds int64) string {
-	if seconds == 0 {
-		return "-"
-	}
-
-	days := seconds / 86400
-	hours := (seconds % 86400) / 3600
-	minutes := (seconds % 3600) / 60
-
-	if days > 0 {
-		return fmt.Sprintf("%dd %dh", days, hours)
-	}
-	if hours > 0 {
-		return fmt.Sprintf("%dh %dm", hours, minutes)
+	if err != nil {
+		return err
 	}
-	return fmt.Sprintf("%dm", minutes)
+	ml.refreshMutex.Lock()
+	defer ml.refreshMutex.Unlock()
+	return ml.runErr
 }
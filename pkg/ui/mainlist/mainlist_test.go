@@ -2,11 +2,25 @@ package mainlist
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/tsupplis/pvec/pkg/config"
 	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/configpanel"
+	"github.com/tsupplis/pvec/pkg/ui/format"
 )
 
 // MockDataProvider implements DataProvider for testing
@@ -19,123 +33,202 @@ func (m *MockDataProvider) GetNodes(ctx context.Context) ([]*models.VMStatus, er
 	return m.Nodes, m.Err
 }
 
-func TestSortNodes(t *testing.T) {
-	nodes := []*models.VMStatus{
-		{VMID: "101", Name: "vm-zebra", Type: "qemu"},
-		{VMID: "200", Name: "ct-alpha", Type: "lxc"},
-		{VMID: "102", Name: "vm-alpha", Type: "qemu"},
-		{VMID: "201", Name: "ct-beta", Type: "lxc"},
+// MockClient implements proxmox.Client for testing
+type MockClient struct {
+	MockDataProvider
+	Guest       *models.VMStatus
+	GuestCalls  int        // Calls to GetGuestStatus, which the sweep makes concurrently
+	HostCalls   int        // Calls to GetAgentHostName, which the sweep makes concurrently too
+	guestMu     sync.Mutex // Guards GuestCalls and HostCalls
+	ActionErr   error
+	Filesystems []models.Filesystem
+	FSErr       error
+	FSCalls     int
+	AgentDown   bool                              // PingAgent fails, as when the agent doesn't run in the guest
+	Pings       int                               // Calls to PingAgent
+	Hostnames   map[string]string                 // VMID -> hostname from the guest agent, which fails without one
+	Configs     map[string]map[string]interface{} // VMID -> config
+	Started     []string                          // VMIDs passed to Start, in order
+	NodeCalls   []string                          // "command node" for node power calls
+	Running     map[string][]models.Task          // Node -> running tasks
+	TaskLog     []models.TaskLogLine              // Log returned for every task
+	TaskErr     error                             // Returned by GetRunningTasks for pve2
+	Stopped     []string                          // UPIDs passed to StopTask
+	TaskState   models.TaskStatus                 // Returned by GetTaskStatus
+	Storages    map[string][]models.Storage       // Node -> storages
+	Volumes     map[string][]models.StorageVolume // "node/storage/content" -> volumes
+	Deleted     []string                          // Volume IDs passed to DeleteVolume
+	Downloads   []string                          // "storage content url filename" passed to DownloadURL
+	Perms       models.Permissions                // Returned by GetPermissions
+	Regenerated []string                          // "vmid drive storage" passed to RegenerateCloudInit
+	Resumed     []string                          // VMIDs passed to Resume
+	Unlocked    []string                          // VMIDs passed to ClearLock
+	ShutDown    []string                          // VMIDs passed to Shutdown
+	Killed      []string                          // VMIDs passed to Stop
+	Hibernated  []string                          // VMIDs passed to Hibernate
+	Snapshots   []string                          // "vmid name" passed to CreateSnapshot
+	NoSnapshot  bool                              // SnapshotSupported answers false
+	SnapshotErr error                             // Returned by CreateSnapshot
+}
+
+func (m *MockClient) GetGuestStatus(ctx context.Context, node, vmType, vmid string) (*models.VMStatus, error) {
+	m.guestMu.Lock()
+	m.GuestCalls++
+	m.guestMu.Unlock()
+	if m.Guest == nil {
+		return nil, proxmox.ErrNodeNotFound
 	}
+	return m.Guest, nil
+}
 
-	sorted := sortNodes(nodes)
+func (m *MockClient) GetVMConfig(ctx context.Context, node, vmType, vmid string) (map[string]interface{}, error) {
+	return m.Configs[vmid], nil
+}
 
-	// Containers should come first (lxc), then VMs (qemu)
-	// Within each type, sorted alphabetically by name
-	expected := []string{
-		"ct-alpha", // lxc comes first
-		"ct-beta",  // lxc, alphabetically
-		"vm-alpha", // qemu after lxc
-		"vm-zebra", // qemu, alphabetically
-	}
+func (m *MockClient) GetAgentFSInfo(ctx context.Context, node, vmid string) ([]models.Filesystem, error) {
+	m.FSCalls++
+	return m.Filesystems, m.FSErr
+}
 
-	if len(sorted) != len(expected) {
-		t.Fatalf("Expected %d nodes, got %d", len(expected), len(sorted))
+func (m *MockClient) PingAgent(ctx context.Context, node, vmid string) error {
+	m.Pings++
+	if m.AgentDown {
+		return fmt.Errorf("QEMU guest agent is not running")
 	}
+	return nil
+}
 
-	for i, expectedName := range expected {
-		if sorted[i].Name != expectedName {
-			t.Errorf("Position %d: expected %s, got %s", i, expectedName, sorted[i].Name)
-		}
+func (m *MockClient) GetAgentHostName(ctx context.Context, node, vmid string) (string, error) {
+	m.guestMu.Lock()
+	m.HostCalls++
+	m.guestMu.Unlock()
+	hostname, ok := m.Hostnames[vmid]
+	if !ok {
+		return "", fmt.Errorf("QEMU guest agent is not running")
 	}
+	return hostname, nil
 }
 
-func TestShouldSwap(t *testing.T) {
-	tests := []struct {
-		name     string
-		a        *models.VMStatus
-		b        *models.VMStatus
-		expected bool
-	}{
-		{
-			name:     "VM before CT",
-			a:        &models.VMStatus{Type: "qemu", Name: "vm-a"},
-			b:        &models.VMStatus{Type: "lxc", Name: "ct-a"},
-			expected: true, // Should swap (CT should come first)
-		},
-		{
-			name:     "CT before VM",
-			a:        &models.VMStatus{Type: "lxc", Name: "ct-a"},
-			b:        &models.VMStatus{Type: "qemu", Name: "vm-a"},
-			expected: false, // No swap needed
-		},
-		{
-			name:     "Same type, alphabetical swap needed",
-			a:        &models.VMStatus{Type: "qemu", Name: "vm-zebra"},
-			b:        &models.VMStatus{Type: "qemu", Name: "vm-alpha"},
-			expected: true, // Should swap (alphabetically)
-		},
-		{
-			name:     "Same type, no swap needed",
-			a:        &models.VMStatus{Type: "qemu", Name: "vm-alpha"},
-			b:        &models.VMStatus{Type: "qemu", Name: "vm-zebra"},
-			expected: false, // Already in order
-		},
-	}
+func (m *MockClient) Start(ctx context.Context, node, vmType, vmid string) error {
+	m.Started = append(m.Started, vmid)
+	return m.ActionErr
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := shouldSwap(tt.a, tt.b)
-			if result != tt.expected {
-				t.Errorf("Expected %v, got %v", tt.expected, result)
-			}
-		})
-	}
+func (m *MockClient) Shutdown(ctx context.Context, node, vmType, vmid string) error {
+	m.ShutDown = append(m.ShutDown, vmid)
+	return m.ActionErr
 }
 
-func TestTruncate(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		maxLen   int
-		expected string
-	}{
-		{"Short string", "hello", 10, "hello"},
-		{"Exact length", "hello", 5, "hello"},
-		{"Long string", "hello world", 8, "hello..."},
-		{"Very short max", "hello", 3, "..."},
+func (m *MockClient) Reboot(ctx context.Context, node, vmType, vmid string) error {
+	return m.ActionErr
+}
+
+func (m *MockClient) Stop(ctx context.Context, node, vmType, vmid string) error {
+	m.Killed = append(m.Killed, vmid)
+	return m.ActionErr
+}
+
+func (m *MockClient) Resume(ctx context.Context, node, vmType, vmid string) error {
+	m.Resumed = append(m.Resumed, vmid)
+	return m.ActionErr
+}
+
+func (m *MockClient) Hibernate(ctx context.Context, node, vmid string) error {
+	m.Hibernated = append(m.Hibernated, vmid)
+	return m.ActionErr
+}
+
+func (m *MockClient) ClearLock(ctx context.Context, node, vmType, vmid string) error {
+	m.Unlocked = append(m.Unlocked, vmid)
+	return m.ActionErr
+}
+
+func (m *MockClient) SnapshotSupported(ctx context.Context, node, vmType, vmid string) (bool, error) {
+	return !m.NoSnapshot, nil
+}
+
+func (m *MockClient) ListSnapshots(ctx context.Context, node, vmType, vmid string) ([]models.Snapshot, error) {
+	return nil, nil
+}
+
+func (m *MockClient) CreateSnapshot(ctx context.Context, node, vmType, vmid, name, description string) (string, error) {
+	m.Snapshots = append(m.Snapshots, vmid+" "+name)
+	return "UPID:" + node + ":qmsnapshot", m.SnapshotErr
+}
+
+func (m *MockClient) DeleteSnapshot(ctx context.Context, node, vmType, vmid, name string) (string, error) {
+	return "UPID:" + node + ":qmdelsnapshot", nil
+}
+
+func (m *MockClient) NodeReboot(ctx context.Context, node string) error {
+	m.NodeCalls = append(m.NodeCalls, "reboot "+node)
+	return m.ActionErr
+}
+
+func (m *MockClient) NodeShutdown(ctx context.Context, node string) error {
+	m.NodeCalls = append(m.NodeCalls, "shutdown "+node)
+	return m.ActionErr
+}
+
+func (m *MockClient) GetRunningTasks(ctx context.Context, node string) ([]models.Task, error) {
+	if node == "pve2" && m.TaskErr != nil {
+		return nil, m.TaskErr
 	}
+	return m.Running[node], nil
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := truncate(tt.input, tt.maxLen)
-			if result != tt.expected {
-				t.Errorf("Expected '%s', got '%s'", tt.expected, result)
-			}
-		})
+func (m *MockClient) GetTaskLog(ctx context.Context, node, upid string, start int) ([]models.TaskLogLine, error) {
+	if start >= len(m.TaskLog) {
+		return nil, nil
 	}
+	return m.TaskLog[start:], nil
 }
 
-func TestFormatUptime(t *testing.T) {
-	tests := []struct {
-		name     string
-		seconds  int64
-		expected string
-	}{
-		{"Zero uptime", 0, "-"},
-		{"Less than hour", 45 * 60, "45m"},
-		{"Hours and minutes", 3*3600 + 30*60, "3h 30m"},
-		{"Days and hours", 2*86400 + 5*3600, "2d 5h"},
-		{"Only days", 3 * 86400, "3d 0h"},
+func (m *MockClient) StopTask(ctx context.Context, node, upid string) error {
+	m.Stopped = append(m.Stopped, upid)
+	return m.ActionErr
+}
+
+func (m *MockClient) GetTaskStatus(ctx context.Context, node, upid string) (models.TaskStatus, error) {
+	return m.TaskState, nil
+}
+
+func (m *MockClient) ListStorages(ctx context.Context, node string) ([]models.Storage, error) {
+	if node == "pve2" && m.TaskErr != nil {
+		return nil, m.TaskErr
 	}
+	return m.Storages[node], nil
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := formatUptime(tt.seconds)
-			if result != tt.expected {
-				t.Errorf("Expected '%s', got '%s'", tt.expected, result)
-			}
-		})
+func (m *MockClient) GetStorageContent(ctx context.Context, node, storage, contentType string) ([]models.StorageVolume, error) {
+	return m.Volumes[node+"/"+storage+"/"+contentType], nil
+}
+
+func (m *MockClient) DeleteVolume(ctx context.Context, node, storage, volid string) error {
+	m.Deleted = append(m.Deleted, volid)
+	return m.ActionErr
+}
+
+func (m *MockClient) DownloadURL(ctx context.Context, node, storage, contentType, fileURL, filename string) (string, error) {
+	m.Downloads = append(m.Downloads, strings.Join([]string{storage, contentType, fileURL, filename}, " "))
+	return "UPID:" + node + ":download", m.ActionErr
+}
+
+func (m *MockClient) GetPermissions(ctx context.Context) (models.Permissions, error) {
+	return m.Perms, m.TaskErr
+}
+
+func (m *MockClient) RegenerateCloudInit(ctx context.Context, node, vmid, drive, storage string) error {
+	m.Regenerated = append(m.Regenerated, strings.Join([]string{vmid, drive, storage}, " "))
+	return m.ActionErr
+}
+
+func (m *MockClient) WakeNode(ctx context.Context, node string) (string, error) {
+	m.NodeCalls = append(m.NodeCalls, "wake "+node)
+	if m.ActionErr != nil {
+		return "", m.ActionErr
 	}
+	return "bc:24:11:7f:3a:02", nil
 }
 
 func TestNewMainList(t *testing.T) {
@@ -148,7 +241,6 @@ func TestNewMainList(t *testing.T) {
 	cfg := Config{
 		RefreshInterval: 5 * time.Second,
 		Provider:        provider,
-		Client:          nil,
 		OnNodesUpdated:  nil,
 	}
 
@@ -163,7 +255,7 @@ func TestNewMainList(t *testing.T) {
 	if ml.selectedIdx != 0 {
 		t.Error("Initial selectedIdx should be 0")
 	}
-	if !ml.refreshEnabled {
+	if !ml.refreshEnabled.Load() {
 		t.Error("Refresh should be enabled by default")
 	}
 	if ml.model == nil {
@@ -211,6 +303,289 @@ func TestListModel_RenderRow(t *testing.T) {
 	// depending on whether ANSI codes are rendered, so we just check it's not empty
 }
 
+func TestListModel_RenderMainList_Separator(t *testing.T) {
+	defer format.SetUnicode(true)
+
+	for _, tt := range []struct {
+		unicode bool
+		want    rune
+	}{
+		{true, format.SeparatorRune},
+		{false, format.ASCIISeparatorRune},
+	} {
+		format.SetUnicode(tt.unicode)
+		ml := NewMainList(Config{Provider: &MockDataProvider{}})
+		ml.model.width = 80
+		ml.model.height = 24
+
+		lines := strings.Split(ml.model.renderMainList(), "\n")
+		if len(lines) < 3 {
+			t.Fatalf("renderMainList() returned %d lines", len(lines))
+		}
+		separator := lines[2]
+		if got := utf8.RuneCountInString(separator); got != 80 {
+			t.Errorf("unicode=%v: separator has %d runes, want 80", tt.unicode, got)
+		}
+		for _, r := range separator {
+			if r != tt.want {
+				t.Errorf("unicode=%v: separator contains %q, want only %q", tt.unicode, r, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// viewScreen puts a list model on one of its screens
+type viewScreen struct {
+	name  string
+	setup func(m *listModel)
+}
+
+// newViewTestList returns a list with a couple of guests and every screen
+// that View can show
+func newViewTestList(t *testing.T) (*MainList, []viewScreen) {
+	nodes := []*models.VMStatus{
+		{VMID: "100", Name: "web", Type: "qemu", Status: "running", Node: "pve1", CPUUsage: 12.5, MemoryUsage: 40, Uptime: 3600},
+		{VMID: "200", Name: "db", Type: "lxc", Status: "stopped", Node: "pve1", Disk: 1 << 30, MaxDisk: 8 << 30},
+	}
+	appConfig := &config.Config{APIUrl: "https://pve:8006", TokenID: "root@pam!t", TokenSecret: "s", RefreshInterval: 5 * time.Second}
+
+	ml := NewMainList(Config{
+		Provider:    &MockDataProvider{Nodes: nodes},
+		AppConfig:   appConfig,
+		ConfigSaver: config.NewLoader(t.TempDir() + "/pvecrc"),
+	})
+	ml.guests.ReplaceAll(nodes)
+	ml.sortedNodes = nodes
+
+	screens := []viewScreen{
+		{"main list", func(m *listModel) {}},
+		{"auth notice", func(m *listModel) {
+			m.parent.lastError = &proxmox.APIError{StatusCode: 403, Method: "GET", Path: "/cluster/resources"}
+		}},
+		{"action status", func(m *listModel) {
+			m.showAction, m.actionVM, m.actionName = true, nodes[0], "start"
+		}},
+		{"help", func(m *listModel) { m.showHelp = true }},
+		{"events", func(m *listModel) { m.showEvents = true }},
+		{"config", func(m *listModel) { m.handleConfigKey() }},
+		{"details loading", func(m *listModel) {
+			m.showDetails, m.detailsVM, m.detailsLoading = true, nodes[0], true
+		}},
+		{"details error", func(m *listModel) {
+			m.showDetails, m.detailsVM, m.detailsError = true, nodes[0], fmt.Errorf("boom")
+		}},
+		{"details", func(m *listModel) {
+			m.showDetails, m.detailsVM = true, nodes[0]
+			m.detailsConfig = map[string]interface{}{"cores": 2.0, "scsi0": "local-lvm:vm-100-disk-0,size=32G", "net0": "virtio=AA:BB:CC:DD:EE:FF,bridge=vmbr0"}
+		}},
+	}
+	return ml, screens
+}
+
+// TestListModel_View_Smoke renders every screen at the classic 80x24 and
+// at a few awkward sizes; no view may panic or exceed the terminal height
+func TestListModel_View_Smoke(t *testing.T) {
+	for _, size := range []struct{ width, height int }{{80, 24}, {40, 10}, {0, 0}} {
+		_, screens := newViewTestList(t)
+		for _, screen := range screens {
+			t.Run(fmt.Sprintf("%s %dx%d", screen.name, size.width, size.height), func(t *testing.T) {
+				ml, _ := newViewTestList(t)
+				ml.model.width, ml.model.height = size.width, size.height
+				screen.setup(ml.model)
+
+				view := ml.model.View()
+				if view == "" {
+					t.Fatal("View() returned an empty string")
+				}
+				if size.height > 0 && strings.Count(view, "\n")+1 > size.height {
+					t.Errorf("View() has %d lines, want at most %d", strings.Count(view, "\n")+1, size.height)
+				}
+			})
+		}
+	}
+}
+
+// checkFits fails the test when view is taller or wider than the screen,
+// or when its rules don't span the screen, telling a screen laid out for
+// another width
+func checkFits(t *testing.T, view string, width, height int) {
+	t.Helper()
+	lines := strings.Split(view, "\n")
+	if len(lines) > height {
+		t.Errorf("View() has %d lines, want at most %d", len(lines), height)
+	}
+	for _, line := range lines {
+		if lipgloss.Width(line) > width {
+			t.Errorf("View() has a line of %d cells on a screen %d wide: %q", lipgloss.Width(line), width, line)
+		}
+		if line != "" && strings.Trim(line, "─-") == "" && lipgloss.Width(line) != width && !format.IsTooSmall(width, height) {
+			t.Errorf("View() has a rule of %d cells on a screen %d wide", lipgloss.Width(line), width)
+		}
+	}
+}
+
+// TestListModel_Resize opens every screen, then shrinks the terminal
+// through Update: each must be drawn again for the new size
+func TestListModel_Resize(t *testing.T) {
+	defer format.SetColor(true)
+	format.SetColor(false) // Plain rules
+	_, screens := newViewTestList(t)
+	for _, size := range []struct{ width, height int }{{40, 10}, {20, 4}, {1, 1}} {
+		for _, screen := range screens {
+			t.Run(fmt.Sprintf("%s %dx%d", screen.name, size.width, size.height), func(t *testing.T) {
+				ml, _ := newViewTestList(t)
+				ml.model.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+				screen.setup(ml.model)
+				ml.model.View()
+
+				ml.model.Update(tea.WindowSizeMsg{Width: size.width, Height: size.height})
+				checkFits(t, ml.model.View(), size.width, size.height)
+			})
+		}
+	}
+}
+
+// TestListModel_View_TinyTerminals opens every screen from its key at
+// sizes from the smallest terminal up: none may panic, end pvec or draw
+// outside the terminal
+func TestListModel_View_TinyTerminals(t *testing.T) {
+	defer format.SetColor(true)
+	format.SetColor(false)
+	keys := []string{"", "f1", "c", "enter", "e", "T", "I", "P", "n", "N", "@", "L", "s", "d", "O", "B", "W", "/"}
+	sizes := []struct{ width, height int }{{1, 1}, {5, 2}, {20, 5}, {39, 9}, {40, 10}, {80, 24}, {200, 50}}
+	for _, key := range keys {
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("%q %dx%d", key, size.width, size.height), func(t *testing.T) {
+				d := newDriver(t, e2eClient())
+				d.send(tea.WindowSizeMsg{Width: size.width, Height: size.height})
+				if key != "" {
+					d.key(key)
+				}
+				if fatal := d.ml.fatal.Load(); fatal != nil {
+					t.Fatalf("Expected pvec to keep running, got %v", fatal.err)
+				}
+				view := d.ml.model.View()
+				checkFits(t, view, size.width, size.height)
+				tooSmall := format.TooSmall(size.width, size.height)
+				if format.IsTooSmall(size.width, size.height) != (view == tooSmall) {
+					t.Errorf("Expected the too-small screen only below %dx%d:\n%s", format.MinWidth, format.MinHeight, view)
+				}
+			})
+		}
+	}
+}
+
+func TestListModel_Resize_KeepsCursorInView(t *testing.T) {
+	var nodes []*models.VMStatus
+	for i := 0; i < 30; i++ {
+		nodes = append(nodes, &models.VMStatus{VMID: fmt.Sprint(100 + i), Name: fmt.Sprintf("vm-%02d", i), Type: "qemu", Status: "running", Node: "pve1"})
+	}
+	ml := NewMainList(Config{Provider: &MockDataProvider{Nodes: nodes}})
+	ml.model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	for i := 0; i < 20; i++ {
+		ml.model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	}
+
+	ml.model.Update(tea.WindowSizeMsg{Width: 80, Height: 10})
+	if view := ml.model.View(); !strings.Contains(view, "vm-20") {
+		t.Errorf("Expected the selected guest still shown after shrinking:\n%s", view)
+	}
+	ml.model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	if ml.model.scrollOffset != 0 {
+		t.Errorf("Expected the list to scroll back once everything fits, got offset %d", ml.model.scrollOffset)
+	}
+
+	// The details cursor too
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	ml.model.Update(configLoadedMsg{key: "120", config: map[string]interface{}{"cores": 2.0, "memory": 2048.0, "scsi0": "local-lvm:vm-120-disk-0,size=32G"}})
+	for i := 0; i < 12; i++ {
+		ml.model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	}
+	cursor := ml.model.detailsState.Cursor
+	ml.model.Update(tea.WindowSizeMsg{Width: 80, Height: 6})
+	if state := ml.model.detailsState; cursor != state.Cursor || state.Cursor < state.Scroll || state.Cursor >= state.Scroll+format.FrameRows(6) {
+		t.Errorf("Expected the details cursor kept in view, got %+v", state)
+	}
+}
+
+func TestListModel_Resize_UnderConfigPanel(t *testing.T) {
+	defer format.SetColor(true)
+	format.SetColor(false)
+	ml, _ := newViewTestList(t)
+	ml.model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	ml.model.handleConfigKey()
+
+	ml.model.Update(tea.WindowSizeMsg{Width: 50, Height: 12})
+	checkFits(t, ml.model.View(), 50, 12)
+	ml.model.Update(configpanel.CloseMsg{})
+	if ml.model.width != 50 || ml.model.height != 12 {
+		t.Errorf("Expected the list to follow the resize made over the config panel, got %dx%d", ml.model.width, ml.model.height)
+	}
+}
+
+func TestListModel_View_NoColor(t *testing.T) {
+	original := lipgloss.ColorProfile()
+	defer lipgloss.SetColorProfile(original)
+	defer format.SetColor(true)
+
+	// Force a color terminal so the test can tell styling is really removed
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	ml, _ := newViewTestList(t)
+	ml.model.width, ml.model.height = 80, 24
+	if !strings.Contains(ml.model.View(), "\x1b[") {
+		t.Fatal("expected ANSI sequences with a true color profile")
+	}
+
+	format.SetColor(false)
+	_, screens := newViewTestList(t)
+	for _, screen := range screens {
+		t.Run(screen.name, func(t *testing.T) {
+			ml, _ := newViewTestList(t)
+			ml.model.width, ml.model.height = 80, 24
+			screen.setup(ml.model)
+
+			if view := ml.model.View(); strings.Contains(view, "\x1b") {
+				t.Errorf("View() contains ANSI escape sequences: %q", view)
+			}
+		})
+	}
+}
+
+func TestListModel_RenderRow_NoColorMarkers(t *testing.T) {
+	defer format.SetColor(true)
+	format.SetColor(false)
+
+	ml, _ := newViewTestList(t)
+	ml.model.width = 80
+	full := &models.VMStatus{VMID: "300", Name: "full", Type: "lxc", Status: "running", Disk: 97, MaxDisk: 100}
+	ml.changedAt["200"] = time.Now()
+
+	tests := []struct {
+		name     string
+		node     *models.VMStatus
+		selected bool
+		prefix   string
+	}{
+		{"plain", ml.guests.All()[0], false, "    running"},
+		{"selected", ml.guests.All()[0], true, ">   running"},
+		{"changed", ml.guests.All()[1], false, " [!]stopped"},
+		{"selected disk alert", full, true, ">[!]running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := ml.model.renderRow(tt.node, tt.selected)
+			if !strings.HasPrefix(row, tt.prefix) {
+				t.Errorf("renderRow() = %q, want prefix %q", row, tt.prefix)
+			}
+			if width := utf8.RuneCountInString(row); width > 81 {
+				t.Errorf("renderRow() is %d columns wide", width)
+			}
+		})
+	}
+}
+
 func TestListModel_RenderRow_NegativeValues(t *testing.T) {
 	provider := &MockDataProvider{}
 	ml := NewMainList(Config{Provider: provider})
@@ -242,8 +617,8 @@ func TestGetSelectedNode(t *testing.T) {
 	ml := NewMainList(Config{Provider: provider})
 
 	// Simulate having nodes
-	ml.nodes = nodes
-	ml.sortedNodes = sortNodes(nodes)
+	ml.guests.ReplaceAll(nodes)
+	ml.sortedNodes = nodes
 	ml.selectedIdx = 1
 
 	selected := ml.GetSelectedNode()
@@ -273,7 +648,7 @@ func TestGetAllNodes(t *testing.T) {
 	}
 	provider := &MockDataProvider{Nodes: nodes}
 	ml := NewMainList(Config{Provider: provider})
-	ml.nodes = nodes
+	ml.guests.ReplaceAll(nodes)
 
 	allNodes := ml.GetAllNodes()
 	if len(allNodes) != len(nodes) {
@@ -290,17 +665,961 @@ func TestSetRefreshEnabled(t *testing.T) {
 	provider := &MockDataProvider{}
 	ml := NewMainList(Config{Provider: provider})
 
-	if !ml.refreshEnabled {
+	if !ml.refreshEnabled.Load() {
 		t.Error("Refresh should be enabled by default")
 	}
 
 	ml.SetRefreshEnabled(false)
-	if ml.refreshEnabled {
+	if ml.refreshEnabled.Load() {
 		t.Error("Refresh should be disabled")
 	}
 
 	ml.SetRefreshEnabled(true)
-	if !ml.refreshEnabled {
+	if !ml.refreshEnabled.Load() {
 		t.Error("Refresh should be enabled")
 	}
 }
+
+func TestUpdate_RefreshUnauthorized(t *testing.T) {
+	provider := &MockDataProvider{
+		Err: fmt.Errorf("failed to get cluster resources: %w",
+			&proxmox.APIError{StatusCode: 401, Method: "GET", Path: "/cluster/resources"}),
+	}
+	ml := NewMainList(Config{Provider: provider})
+
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if !ml.refreshPaused.Load() {
+		t.Error("Auto-refresh should be paused after a 401")
+	}
+	view := ml.model.View()
+	if !strings.Contains(view, "Authentication failed — press F2 to update your token") {
+		t.Error("View should show the reauthentication notice")
+	}
+}
+
+func TestUpdate_RefreshForbidden(t *testing.T) {
+	provider := &MockDataProvider{
+		Err: fmt.Errorf("failed to get cluster resources: %w",
+			&proxmox.APIError{StatusCode: 403, Method: "GET", Path: "/cluster/resources"}),
+	}
+	ml := NewMainList(Config{Provider: provider})
+
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if ml.refreshPaused.Load() {
+		t.Error("Auto-refresh should keep running after a 403")
+	}
+	view := ml.model.View()
+	if !strings.Contains(view, "Permission denied on /cluster/resources") {
+		t.Error("View should name the denied endpoint")
+	}
+	if strings.Contains(view, "Authentication failed") {
+		t.Error("403 should not show the reauthentication notice")
+	}
+}
+
+func TestUpdate_RefreshResumesAfterSuccess(t *testing.T) {
+	provider := &MockDataProvider{
+		Err: &proxmox.APIError{StatusCode: 401, Path: "/cluster/resources"},
+	}
+	ml := NewMainList(Config{Provider: provider})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	provider.Err = nil
+	provider.Nodes = []*models.VMStatus{{VMID: "100", Name: "vm1", Type: "qemu"}}
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if ml.refreshPaused.Load() {
+		t.Error("Auto-refresh should resume after a successful refresh")
+	}
+	if strings.Contains(ml.model.View(), "Authentication failed") {
+		t.Error("Notice should disappear after a successful refresh")
+	}
+}
+
+// snapshotProvider hands out a fixed snapshot
+type snapshotProvider struct {
+	MockDataProvider
+	snap *proxmox.RefreshSnapshot
+}
+
+func (s *snapshotProvider) Snapshot(ctx context.Context) *proxmox.RefreshSnapshot {
+	return s.snap
+}
+
+func TestUpdate_RefreshSnapshot(t *testing.T) {
+	provider := &snapshotProvider{snap: &proxmox.RefreshSnapshot{
+		Guests: []*models.VMStatus{{VMID: "100", Name: "vm1", Type: "qemu", Node: "pve1"}},
+		Nodes:  []models.ClusterNode{{Name: "pve1", Online: true}},
+		Errors: map[proxmox.Section]error{
+			proxmox.SectionStorage: &proxmox.APIError{StatusCode: 403, Path: "/cluster/resources"},
+		},
+	}}
+	ml := NewMainList(Config{Provider: provider})
+
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if ml.cluster != provider.snap {
+		t.Error("The whole snapshot should be kept")
+	}
+	if ml.guests.Count() != 1 || ml.guests.All()[0].VMID != "100" {
+		t.Errorf("Guests should come from the snapshot, got %v", ml.guests.All())
+	}
+	if ml.lastError != nil {
+		t.Errorf("A failed storage section should not fail the refresh, got %v", ml.lastError)
+	}
+
+	provider.snap = &proxmox.RefreshSnapshot{Errors: map[proxmox.Section]error{
+		proxmox.SectionGuests: &proxmox.APIError{StatusCode: 401, Path: "/cluster/resources"},
+	}}
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if !ml.refreshPaused.Load() {
+		t.Error("A rejected guest read should pause the refresh")
+	}
+}
+
+func TestFetchNodes_PlainProvider(t *testing.T) {
+	provider := &MockDataProvider{Err: errors.New("boom")}
+	ml := NewMainList(Config{Provider: provider})
+
+	msg := ml.fetchNodes(context.Background())
+
+	if msg.snapshot == nil || msg.snapshot.Err(proxmox.SectionGuests) != msg.err {
+		t.Errorf("A plain provider should still yield a guests-only snapshot, got %+v", msg.snapshot)
+	}
+}
+
+func TestReinitializeClient_ClearsPause(t *testing.T) {
+	ml := NewMainList(Config{
+		Provider:  &MockDataProvider{},
+		AppConfig: &config.Config{APIUrl: "https://pve.local:8006", TokenID: "u@pam!t", TokenSecret: "s"},
+	})
+	ml.refreshPaused.Store(true)
+
+	ml.reinitializeClient()
+
+	if ml.refreshPaused.Load() {
+		t.Error("Saving the configuration should resume auto-refresh")
+	}
+}
+
+func TestUpdate_StateChangeDetection(t *testing.T) {
+	provider := &MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "104", Name: "web-1", Type: "qemu", Status: "running"},
+		{VMID: "105", Name: "db-1", Type: "qemu", Status: "running"},
+	}}
+	ml := NewMainList(Config{Provider: provider})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if len(ml.events) != 0 {
+		t.Fatal("First refresh should not produce events")
+	}
+
+	provider.Nodes = []*models.VMStatus{
+		{VMID: "104", Name: "web-1", Type: "qemu", Status: "stopped"},
+		{VMID: "106", Name: "new-1", Type: "qemu", Status: "running"},
+	}
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if len(ml.events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(ml.events))
+	}
+	if ml.events[0].VMID != "104" || ml.events[0].NewState != "stopped" {
+		t.Errorf("Unexpected event: %v", ml.events[0])
+	}
+	if _, ok := ml.changedAt["104"]; !ok {
+		t.Error("Changed guest should be highlighted")
+	}
+	if _, ok := ml.changedAt["106"]; ok {
+		t.Error("New guest should not be highlighted")
+	}
+}
+
+func TestRecordStateChanges_HighlightExpires(t *testing.T) {
+	ml := NewMainList(Config{Provider: &MockDataProvider{}})
+	start := time.Now()
+
+	ml.recordStateChanges([]*models.VMStatus{{VMID: "100", Status: "running"}}, start)
+	ml.recordStateChanges([]*models.VMStatus{{VMID: "100", Status: "stopped"}}, start)
+	ml.recordStateChanges([]*models.VMStatus{{VMID: "100", Status: "stopped"}}, start.Add(changeHighlightDuration))
+
+	if _, ok := ml.changedAt["100"]; ok {
+		t.Error("Highlight should expire")
+	}
+	if len(ml.events) != 1 {
+		t.Errorf("Events should persist after the highlight expires, got %d", len(ml.events))
+	}
+}
+
+func TestRecordStateChanges_HighlightClearedOnTime(t *testing.T) {
+	client := e2eClient()
+	d := newDriver(t, client)
+	stopped := *client.Nodes[0]
+	stopped.Status = "stopped"
+	client.Nodes = append([]*models.VMStatus{&stopped}, client.Nodes[1:]...)
+	_, cmd := d.ml.model.Update(d.ml.fetchNodes(context.Background()))
+	if cmd == nil {
+		t.Fatal("Expected the refresh to schedule the end of the highlight")
+	}
+	if _, ok := d.ml.changedAt["100"]; !ok {
+		t.Fatal("Expected web-1 to be highlighted")
+	}
+
+	d.clock.Advance(changeHighlightDuration)
+	d.send(changeExpiredMsg{})
+	if _, ok := d.ml.changedAt["100"]; ok {
+		t.Error("Expected the highlight cleared without waiting for a refresh")
+	}
+}
+
+func TestUpdate_EventsDialog(t *testing.T) {
+	ml := NewMainList(Config{Provider: &MockDataProvider{}})
+	ml.events = []models.StateChange{{VMID: "104", Name: "web-1", Type: "qemu", OldState: "running", NewState: "stopped"}}
+
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	if !ml.model.showEvents {
+		t.Fatal("'e' should open the event list")
+	}
+	if !strings.Contains(ml.model.View(), "web-1 running → stopped") {
+		t.Error("Event list should show the recorded change")
+	}
+
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if len(ml.events) != 0 {
+		t.Error("'x' should clear the event list")
+	}
+
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if ml.model.showEvents {
+		t.Error("ESC should close the event list")
+	}
+}
+
+func TestUpdate_StateChangeCallback(t *testing.T) {
+	provider := &MockDataProvider{Nodes: []*models.VMStatus{{VMID: "104", Status: "running"}}}
+	var received []models.StateChange
+	ml := NewMainList(Config{
+		Provider:       provider,
+		OnStateChanges: func(changes []models.StateChange) { received = append(received, changes...) },
+	})
+
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	if len(received) != 0 {
+		t.Fatal("Callback should not fire without a transition")
+	}
+
+	provider.Nodes = []*models.VMStatus{{VMID: "104", Status: "stopped"}}
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	if len(received) != 1 || received[0].NewState != "stopped" {
+		t.Errorf("Expected one transition to stopped, got %v", received)
+	}
+}
+
+func TestUpdate_RecentlyRestartedView(t *testing.T) {
+	provider := &MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "100", Name: "old", Type: "qemu", Status: "running", Uptime: 86400},
+		{VMID: "101", Name: "fresh", Type: "qemu", Status: "running", Uptime: 120},
+		{VMID: "102", Name: "down", Type: "qemu", Status: "stopped"},
+	}}
+	ml := NewMainList(Config{Provider: provider})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	ml.model.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+
+	if !strings.Contains(ml.model.View(), "1 up <15m") {
+		t.Error("Status bar should count recently started guests")
+	}
+
+	ml.model.cursorPosition = 2
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	if len(ml.sortedNodes) != 1 || ml.sortedNodes[0].Name != "fresh" {
+		t.Fatalf("Preset should only show recently started guests, got %d", len(ml.sortedNodes))
+	}
+	if ml.model.cursorPosition != 0 {
+		t.Error("Cursor should be clamped to the filtered list")
+	}
+	if !strings.Contains(ml.model.View(), "[recently restarted]") {
+		t.Error("Title should show the active preset")
+	}
+
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	if len(ml.sortedNodes) != 3 || ml.sortedNodes[2].Name != "down" {
+		t.Error("Clearing the preset should keep uptime order with stopped guests last")
+	}
+
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("S")})
+	if ml.sortOrder != defaultSort {
+		t.Error("'S' should cycle the sort order")
+	}
+}
+
+func TestListModel_RenderRow_UnknownMetrics(t *testing.T) {
+	ml := NewMainList(Config{Provider: &MockDataProvider{}})
+
+	node := &models.VMStatus{
+		VMID:    "101",
+		Name:    "legacy-dns",
+		Type:    "lxc",
+		Status:  "stopped",
+		Node:    "pve6a",
+		MaxMem:  536870912,
+		Missing: models.MetricMem | models.MetricUptime,
+	}
+
+	row := ml.model.renderRow(node, true)
+	// Memory%, Disk% and Uptime are the last three columns
+	fields := strings.Fields(row)
+	if fields[len(fields)-1] != "-" || fields[len(fields)-3] != "-" {
+		t.Errorf("Unknown memory and uptime should render as '-', got %q", row)
+	}
+}
+
+func TestUpdate_ActionRefreshesSingleGuest(t *testing.T) {
+	client := &MockClient{MockDataProvider: MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "100", Name: "web-1", Type: "qemu", Status: "stopped", Node: "pve1"},
+		{VMID: "101", Name: "db-1", Type: "qemu", Status: "running", Node: "pve1"},
+	}}}
+	client.Guest = &models.VMStatus{VMID: "100", Name: "web-1", Type: "qemu", Status: "running", Node: "pve1", Uptime: 2}
+
+	var changes []models.StateChange
+	ml := NewMainList(Config{
+		Provider:       client,
+		Reader:         client,
+		Power:          client,
+		OnStateChanges: func(c []models.StateChange) { changes = append(changes, c...) },
+	})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	_, cmd := ml.model.executeAction("start", false)
+	_, cmd = ml.model.Update(cmd())
+	if cmd == nil {
+		t.Fatal("Successful action should fetch the affected guest")
+	}
+	ml.model.Update(cmd())
+
+	if client.GuestCalls != 1 {
+		t.Errorf("Expected 1 status lookup, got %d", client.GuestCalls)
+	}
+	nodes := ml.GetAllNodes()
+	if nodes[0].Status != "running" || nodes[1].Status != "running" {
+		t.Errorf("Guest should be patched in place, got %v", nodes)
+	}
+	if ml.sortedNodes[1].VMID != "100" || ml.sortedNodes[1].Status != "running" {
+		t.Error("Sorted nodes should reflect the patched guest")
+	}
+	if len(changes) != 1 || changes[0].VMID != "100" || changes[0].NewState != "running" {
+		t.Errorf("Expected a single state change for 100, got %v", changes)
+	}
+
+	// The next full refresh must not report the same change again
+	client.Nodes = []*models.VMStatus{client.Guest, nodes[1]}
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	if len(changes) != 1 {
+		t.Errorf("Change should not be reported twice, got %v", changes)
+	}
+}
+
+func TestUpdate_FailedActionSkipsGuestRefresh(t *testing.T) {
+	client := &MockClient{
+		MockDataProvider: MockDataProvider{Nodes: []*models.VMStatus{
+			{VMID: "100", Name: "web-1", Type: "qemu", Status: "stopped", Node: "pve1"},
+		}},
+		ActionErr: fmt.Errorf("boom"),
+	}
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	_, cmd := ml.model.executeAction("start", false)
+	if _, cmd = ml.model.Update(cmd()); cmd != nil {
+		t.Error("Failed action should not trigger a guest refresh")
+	}
+}
+
+// hangingPower is a power controller whose requests only end with their context
+type hangingPower struct{}
+
+func (hangingPower) wait(ctx context.Context) error {
+	<-ctx.Done()
+	return fmt.Errorf("request failed: %w", ctx.Err())
+}
+
+func (h hangingPower) Start(ctx context.Context, node, vmType, vmid string) error {
+	return h.wait(ctx)
+}
+
+func (h hangingPower) Shutdown(ctx context.Context, node, vmType, vmid string) error {
+	return h.wait(ctx)
+}
+
+func (h hangingPower) Reboot(ctx context.Context, node, vmType, vmid string) error {
+	return h.wait(ctx)
+}
+
+func (h hangingPower) Stop(ctx context.Context, node, vmType, vmid string) error {
+	return h.wait(ctx)
+}
+
+func (h hangingPower) Resume(ctx context.Context, node, vmType, vmid string) error {
+	return h.wait(ctx)
+}
+
+func TestUpdate_ActionCancel(t *testing.T) {
+	provider := &MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "100", Name: "web-1", Type: "qemu", Status: "running", Node: "pve1"},
+	}}
+	ml := NewMainList(Config{Provider: provider, Power: hangingPower{}})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	_, cmd := ml.model.executeAction("shutdown", false)
+	ml.model.actionStarted = time.Now().Add(-37 * time.Second)
+	if view := ml.model.View(); !strings.Contains(view, "Shutdown on web-1... 37s") {
+		t.Errorf("In-flight action should show its elapsed time:\n%s", view)
+	}
+
+	// Other keys don't interrupt the action
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if ml.model.actionDone {
+		t.Fatal("Only ESC should cancel an action")
+	}
+
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if view := ml.model.View(); !strings.Contains(view, "Cancelled shutdown 100 by user") {
+		t.Errorf("Expected a cancellation status:\n%s", view)
+	}
+
+	// The request sees the cancellation; its late result changes nothing
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+	select {
+	case msg := <-done:
+		if _, cmd := ml.model.Update(msg); cmd != nil {
+			t.Error("A cancelled action should not trigger a guest refresh")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ESC should cancel the request context")
+	}
+	if !errors.Is(ml.model.actionError, errActionCancelled) {
+		t.Errorf("Cancellation should not be reported as a failure, got %v", ml.model.actionError)
+	}
+}
+
+func TestUpdate_ActionTimeout(t *testing.T) {
+	provider := &MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "100", Name: "web-1", Type: "qemu", Status: "running", Node: "pve1"},
+	}}
+	ml := NewMainList(Config{
+		Provider:  provider,
+		Power:     hangingPower{},
+		AppConfig: &config.Config{ActionTimeout: 10 * time.Millisecond},
+	})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	_, cmd := ml.model.executeAction("shutdown", false)
+	ml.model.Update(cmd())
+	if view := ml.model.View(); !strings.Contains(view, "Timed out after 10ms to shutdown 100") {
+		t.Errorf("Expected a timeout status:\n%s", view)
+	}
+
+	// Without a configured timeout the default applies
+	ml.appConfig = nil
+	if got := ml.actionTimeout(); got != DefaultActionTimeout {
+		t.Errorf("Expected the default timeout, got %v", got)
+	}
+}
+
+func TestUpdate_NodePower(t *testing.T) {
+	client := e2eClient()
+	ml := NewMainList(Config{
+		Provider:  client,
+		Reader:    client,
+		Power:     client,
+		NodePower: client,
+		AppConfig: &config.Config{},
+	})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	ml.model.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+	keys := func(keys ...tea.KeyMsg) tea.Cmd {
+		var cmd tea.Cmd
+		for _, k := range keys {
+			_, cmd = ml.model.Update(k)
+		}
+		return cmd
+	}
+	typed := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	// Disabled unless the config allows it
+	keys(typed("N"))
+	if view := ml.model.View(); !strings.Contains(view, "allow_node_power_actions") {
+		t.Errorf("Disabled node power should say how to enable it:\n%s", view)
+	}
+	keys(typed("x"))
+	if ml.model.nodePower != nil {
+		t.Fatal("Any key should close the disabled dialog")
+	}
+
+	// The first row is on pve1, which runs web-1 only
+	ml.appConfig.AllowNodePowerActions = true
+	keys(typed("N"))
+	if view := ml.model.View(); !strings.Contains(view, "Node pve1 has 1 running guest.") {
+		t.Errorf("Expected the running guest count:\n%s", view)
+	}
+	if cmd := keys(typed("pve"), tea.KeyMsg{Type: tea.KeyEnter}); cmd != nil || len(client.NodeCalls) != 0 {
+		t.Fatal("A partial node name must not confirm")
+	}
+
+	cmd := keys(tea.KeyMsg{Type: tea.KeyTab}, typed("1"), tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("The full node name should confirm")
+	}
+	ml.model.Update(cmd())
+	if len(client.NodeCalls) != 1 || client.NodeCalls[0] != "shutdown pve1" {
+		t.Errorf("Expected a shutdown of pve1, got %v", client.NodeCalls)
+	}
+	if view := ml.model.View(); !strings.Contains(view, "Shutdown of pve1 requested.") {
+		t.Errorf("Expected a confirmation:\n%s", view)
+	}
+}
+
+// readOnlyBackend implements proxmox.Reader but no power actions
+type readOnlyBackend struct {
+	MockDataProvider
+}
+
+func (r *readOnlyBackend) GetGuestStatus(ctx context.Context, node, vmType, vmid string) (*models.VMStatus, error) {
+	return nil, proxmox.ErrNodeNotFound
+}
+
+func (r *readOnlyBackend) GetVMConfig(ctx context.Context, node, vmType, vmid string) (map[string]interface{}, error) {
+	return map[string]interface{}{"cores": 2}, nil
+}
+
+func (r *readOnlyBackend) GetAgentFSInfo(ctx context.Context, node, vmid string) ([]models.Filesystem, error) {
+	return nil, nil
+}
+
+func (r *readOnlyBackend) GetAgentHostName(ctx context.Context, node, vmid string) (string, error) {
+	return "", nil
+}
+
+func (r *readOnlyBackend) PingAgent(ctx context.Context, node, vmid string) error {
+	return nil
+}
+
+func TestUpdate_ReadOnlyBackend(t *testing.T) {
+	vm := &models.VMStatus{VMID: "100", Name: "web-1", Type: "qemu", Status: "stopped", Node: "pve1"}
+	backend := &readOnlyBackend{MockDataProvider{Nodes: []*models.VMStatus{vm}}}
+	ml := NewMainList(Config{Provider: backend, Reader: backend})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	msg := ml.model.loadConfig(vm)().(configLoadedMsg)
+	if msg.err != nil || msg.config["cores"] != 2 {
+		t.Errorf("Details should load from a read-only backend, got %v, %v", msg.config, msg.err)
+	}
+
+	_, cmd := ml.model.executeAction("start", false)
+	result := cmd().(actionResultMsg)
+	if result.err == nil {
+		t.Error("Actions should fail without a power controller")
+	}
+}
+
+func TestExecuteAction_SharedGuestList(t *testing.T) {
+	client := &MockClient{MockDataProvider: MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "100", VMIDNum: 100, Name: "web-1", Type: "qemu", Status: "stopped", Node: "pve1"},
+	}}}
+	ml := NewMainList(Config{Provider: client, Power: client})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	_, cmd := ml.model.executeAction("start", false)
+	if result := cmd().(actionResultMsg); result.err != nil || len(client.Started) != 1 {
+		t.Fatalf("The executor should find the refreshed guest, got %v", result.err)
+	}
+
+	// The guest is gone by the time the action runs
+	_, cmd = ml.model.executeAction("start", false)
+	ml.guests.Clear()
+	if result := cmd().(actionResultMsg); !errors.Is(result.err, proxmox.ErrNodeNotFound) {
+		t.Errorf("Expected the guest to be reported missing, got %v", result.err)
+	}
+}
+
+func TestUpdate_GuestUpdateUnknownGuest(t *testing.T) {
+	provider := &MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "100", Name: "web-1", Type: "qemu", Status: "stopped"},
+	}}
+	ml := NewMainList(Config{Provider: provider})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	ml.model.Update(guestUpdateMsg{guest: &models.VMStatus{VMID: "999", Status: "running"}})
+
+	nodes := ml.GetAllNodes()
+	if len(nodes) != 1 || nodes[0].VMID != "100" {
+		t.Errorf("Unknown guest should not be added, got %v", nodes)
+	}
+}
+
+func TestUpdate_DetailsSearchResetsOnClose(t *testing.T) {
+	ml := NewMainList(Config{Provider: &MockDataProvider{}})
+	m := ml.model
+	m.showDetails = true
+	m.detailsVM = &models.VMStatus{VMID: "100", Name: "web-1", Type: "qemu"}
+	m.detailsConfig = map[string]interface{}{"net0": "virtio,bridge=vmbr0"}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if !m.showDetails || m.detailsState.Query != "n" {
+		t.Fatalf("Typing a search should keep the dialog open, got query %q", m.detailsState.Query)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc}) // Cancel search
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc}) // Close dialog
+	if m.showDetails {
+		t.Fatal("Second ESC should close the dialog")
+	}
+	if m.detailsState.Query != "" || m.detailsState.Cursor != 0 {
+		t.Error("Dialog state should reset on close")
+	}
+}
+
+func TestListModel_RenderRow_DiskUsage(t *testing.T) {
+	ml := NewMainList(Config{Provider: &MockDataProvider{}})
+
+	ct := &models.VMStatus{VMID: "200", Name: "ct-1", Type: "lxc", Status: "running", Disk: 97, MaxDisk: 100}
+	if row := ml.model.renderRow(ct, true); !strings.Contains(row, "97%") {
+		t.Errorf("Container row should show disk usage, got %q", row)
+	}
+	if row := ml.model.renderRow(ct, false); !strings.Contains(row, "97%") {
+		t.Errorf("Colored container row should still show disk usage, got %q", row)
+	}
+
+	vm := &models.VMStatus{VMID: "100", Name: "vm-1", Type: "qemu", Status: "running", MaxDisk: 100, MaxMem: 1}
+	fields := strings.Fields(ml.model.renderRow(vm, true))
+	if fields[len(fields)-2] != "-" {
+		t.Errorf("VM disk usage should render as '-', got %v", fields)
+	}
+}
+
+func TestUsageStyle(t *testing.T) {
+	if _, ok := usageStyle(50); ok {
+		t.Error("50% should not be highlighted")
+	}
+	if _, ok := usageStyle(format.UsageWarning); !ok {
+		t.Error("Warning threshold should be highlighted")
+	}
+	if style, _ := usageStyle(97); !style.GetBold() {
+		t.Error("97% should use the critical style")
+	}
+}
+
+func TestUpdate_SuspendResume(t *testing.T) {
+	if !suspendSupported {
+		t.Skip("Bubble Tea can't suspend on Windows")
+	}
+	client := e2eClient()
+	ml := NewMainList(Config{Provider: client, Reader: client})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	ml.model.showHelp = true // Works from any screen
+
+	_, cmd := ml.model.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if cmd == nil {
+		t.Fatal("Ctrl+Z should suspend")
+	}
+	if _, ok := cmd().(tea.SuspendMsg); !ok {
+		t.Error("Ctrl+Z should hand the terminal back with tea.Suspend")
+	}
+	if ml.autoRefreshDue() {
+		t.Error("Ticker refreshes should be skipped while suspended")
+	}
+
+	_, cmd = ml.model.Update(tea.ResumeMsg{})
+	if ml.suspended.Load() || !ml.autoRefreshDue() {
+		t.Error("Refreshes should resume with the process")
+	}
+	if cmd == nil {
+		t.Fatal("Resuming should redraw and refresh")
+	}
+	// Clear the screen, re-query its size and refresh the data
+	if batch, ok := cmd().(tea.BatchMsg); !ok || len(batch) != 3 {
+		t.Errorf("Expected three commands on resume, got %#v", batch)
+	}
+}
+
+// deadlineProvider records the deadline of the context it is called with
+type deadlineProvider struct {
+	MockDataProvider
+	deadline time.Time
+	hasLimit bool
+}
+
+func (p *deadlineProvider) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	p.deadline, p.hasLimit = ctx.Deadline()
+	return p.MockDataProvider.GetNodes(ctx)
+}
+
+func TestRefreshCmd_ContextAndTimeout(t *testing.T) {
+	provider := &deadlineProvider{MockDataProvider: MockDataProvider{Nodes: []*models.VMStatus{{VMID: "100"}}}}
+	ml := NewMainList(Config{Provider: provider, RefreshTimeout: time.Minute})
+
+	msg, ok := ml.refreshCmd()().(refreshMsg)
+	if !ok || len(msg.nodes) != 1 {
+		t.Fatalf("Expected the fetched nodes, got %#v", msg)
+	}
+	if left := time.Until(provider.deadline); !provider.hasLimit || left < 50*time.Second {
+		t.Errorf("The refresh should be bounded by RefreshTimeout, %v left", left)
+	}
+
+	// Stop cancels the root context; a refresh cut short shows no error
+	ml.cancel()
+	provider.Err = context.Canceled
+	if msg := ml.refreshCmd()(); msg != nil {
+		t.Errorf("A cancelled refresh should deliver nothing, got %#v", msg)
+	}
+}
+
+func TestHandleRefresh_InitialFailure(t *testing.T) {
+	failure := errors.New("connection refused")
+
+	// By default the error is shown and the list keeps running
+	ml := NewMainList(Config{Provider: &MockDataProvider{}})
+	if _, cmd := ml.model.Update(refreshMsg{err: failure}); cmd != nil {
+		if _, quit := cmd().(tea.QuitMsg); quit {
+			t.Error("A failed first load should not quit by default")
+		}
+	}
+	if ml.lastError != failure {
+		t.Errorf("The error should be kept for the banner, got %v", ml.lastError)
+	}
+
+	// Fail-fast quits and reports the error from Run
+	ml = NewMainList(Config{Provider: &MockDataProvider{}, FailFast: true})
+	_, cmd := ml.model.Update(refreshMsg{err: failure})
+	if cmd == nil {
+		t.Fatal("Fail-fast should quit on a failed first load")
+	}
+	if _, quit := cmd().(tea.QuitMsg); !quit {
+		t.Error("Fail-fast should quit on a failed first load")
+	}
+	if ml.runErr != failure {
+		t.Errorf("Run should return the refresh error, got %v", ml.runErr)
+	}
+
+	// Once loaded, later failures only show in the banner
+	ml = NewMainList(Config{Provider: &MockDataProvider{}, FailFast: true})
+	ml.model.Update(refreshMsg{nodes: []*models.VMStatus{{VMID: "100"}}})
+	if _, cmd := ml.model.Update(refreshMsg{err: failure}); cmd != nil {
+		if _, quit := cmd().(tea.QuitMsg); quit {
+			t.Error("Fail-fast only applies to the first load")
+		}
+	}
+}
+
+func TestListModel_RenderRow_AbsurdValues(t *testing.T) {
+	defer format.SetColor(true)
+
+	extremes := []*models.VMStatus{
+		{VMID: "100", Name: "agent-bug", Type: "qemu", Status: "running", Node: "pve1", Uptime: 2147483647},
+		{VMID: "101", Name: "overload", Type: "qemu", Status: "running", Node: "pve1",
+			CPUUsage: 400, MemoryUsage: 1e9, MaxMem: 1},
+		{VMID: "102", Name: "negative", Type: "lxc", Status: "running", Node: "pve1",
+			CPUUsage: -50, MemoryUsage: -1e9, MaxMem: -1, Disk: -5, MaxDisk: 10, Uptime: -1},
+		{VMID: "103", Name: "not-a-number", Type: "lxc", Status: "running", Node: "pve1",
+			CPUUsage: math.NaN(), MemoryUsage: math.Inf(1), MaxMem: 1, Disk: math.MaxInt64, MaxDisk: 1},
+		{VMID: "999999999", Name: strings.Repeat("long-name-", 10), Type: "qemu", Status: "hibernating",
+			Node: "a-very-long-node-name", Uptime: math.MaxInt64},
+	}
+
+	// Random garbage on top of the hand-picked cases
+	rng := rand.New(rand.NewSource(1))
+	values := []float64{0, -1, 100, 100.05, 1e300, -1e300, math.NaN(), math.Inf(-1)}
+	ints := []int64{0, -1, 1, math.MaxInt64, math.MinInt64, 2147483647}
+	for i := 0; i < 200; i++ {
+		extremes = append(extremes, &models.VMStatus{
+			VMID: fmt.Sprint(rng.Int63()), Name: "fuzz", Type: "lxc", Status: "running", Node: "pve1",
+			CPUUsage:    values[rng.Intn(len(values))] * rng.Float64(),
+			MemoryUsage: values[rng.Intn(len(values))],
+			MaxMem:      ints[rng.Intn(len(ints))],
+			Disk:        ints[rng.Intn(len(ints))],
+			MaxDisk:     ints[rng.Intn(len(ints))],
+			Uptime:      ints[rng.Intn(len(ints))],
+		})
+	}
+
+	for _, color := range []bool{true, false} {
+		format.SetColor(color)
+		for _, alloc := range []bool{false, true} {
+			ml := NewMainList(Config{Provider: &MockDataProvider{}})
+			ml.model.width = 80
+			ml.showDiskAlloc = alloc
+			for i, node := range extremes {
+				ml.diskAlloc[node.VMID] = ints[i%len(ints)]
+				for _, selected := range []bool{false, true} {
+					row := ml.model.renderRow(node, selected)
+					if strings.Contains(row, "\n") || lipgloss.Width(row) > 80 {
+						t.Errorf("color=%v alloc=%v: row for %s is %d columns wide:\n%s",
+							color, alloc, node.VMID, lipgloss.Width(row), row)
+					}
+				}
+			}
+		}
+	}
+
+	// The broken values are flagged rather than hidden
+	format.SetColor(false)
+	ml := NewMainList(Config{Provider: &MockDataProvider{}})
+	if row := ml.model.renderRow(extremes[0], false); !strings.Contains(row, ">20y") {
+		t.Errorf("An absurd uptime should render as >20y: %q", row)
+	}
+	if row := ml.model.renderRow(extremes[1], false); !strings.Contains(row, "100%?") {
+		t.Errorf("400%% CPU should be clamped and flagged: %q", row)
+	}
+}
+
+func TestView_MasksSecrets(t *testing.T) {
+	const secret = "0f6c1e2a-7b3d-4c5e-9f8a-1b2c3d4e5f60"
+	redact.AddSecret(secret)
+
+	// A failure echoing the Authorization header
+	d, client := newCloudInitDriver(t)
+	openDetailsOf(t, d, "100")
+	client.ActionErr = fmt.Errorf("status 500: echoed PVEAPIToken=ci@pve!pvec=%s", secret)
+	d.key("C", "y")
+	view := d.ml.model.View()
+	if strings.Contains(view, secret) || strings.Contains(view, secret[:12]) {
+		t.Errorf("The secret should not show:\n%s", view)
+	}
+	if !strings.Contains(view, "Failed to regenerate cloud-init: status 500: echoed PVEAPIToken=ci@pve!pvec=***") {
+		t.Errorf("Expected the masked error:\n%s", view)
+	}
+
+	// A secret reaching the screen any other way is masked too
+	client = e2eClient()
+	client.Nodes[0].Name = secret
+	d = newDriver(t, client)
+	openDetailsOf(t, d, "100")
+	if view := d.ml.model.View(); strings.Contains(view, secret) || !strings.Contains(view, "Name               : "+strings.Repeat("*", len(secret))) {
+		t.Errorf("The secret should be masked:\n%s", view)
+	}
+}
+
+func TestE2E_DuplicateNames(t *testing.T) {
+	client := e2eClient()
+	client.Nodes[2].Name = "WEB-1" // 102 on pve2 shares the name of 100 on pve1
+	d := newDriver(t, client)
+	d.send(tea.WindowSizeMsg{Width: 120, Height: 24})
+
+	view := d.ml.model.View()
+	for _, line := range strings.Split(view, "\n") {
+		switch {
+		case strings.Contains(line, " 100 "), strings.Contains(line, " 102 "):
+			if !strings.Contains(line, "≡") {
+				t.Errorf("Expected guests sharing a name to be marked:\n%s", line)
+			}
+		case strings.Contains(line, " 101 "):
+			if strings.Contains(line, "≡") {
+				t.Errorf("Expected a unique name to be left unmarked:\n%s", line)
+			}
+		}
+	}
+
+	selectGuest(t, d, "102")
+	if bar := statusBar(d); !strings.Contains(bar, "102 WEB-1 on pve2") {
+		t.Errorf("Expected the node of the selected duplicate in the status bar:\n%s", bar)
+	}
+	selectGuest(t, d, "101")
+	if bar := statusBar(d); strings.Contains(bar, " on pve1") {
+		t.Errorf("A unique name needs no node:\n%s", bar)
+	}
+}
+
+// TestMainList_ConcurrentAccess refreshes in the background and calls the
+// exported getters from other goroutines while the list is navigated and
+// a config change saved, which swaps the client. Run it with -race.
+func TestMainList_ConcurrentAccess(t *testing.T) {
+	var nodes []*models.VMStatus
+	for i := 0; i < 20; i++ {
+		nodes = append(nodes, &models.VMStatus{VMID: fmt.Sprint(100 + i), Name: fmt.Sprintf("vm-%d", i), Type: "qemu", Status: "running", Node: "pve1"})
+	}
+	// Nothing listens there: the client swapped in fails its refreshes at once
+	appConfig := &config.Config{APIUrl: "https://127.0.0.1:1", TokenID: "root@pam!t", TokenSecret: "s", RefreshInterval: 5 * time.Second}
+	ml := NewMainList(Config{
+		Provider:    &MockDataProvider{Nodes: nodes},
+		AppConfig:   appConfig,
+		ConfigSaver: config.NewLoader(t.TempDir() + "/pvecrc"),
+	})
+	ml.model.width, ml.model.height = 80, 24
+
+	refreshes := make(chan tea.Msg, 16)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				msg := ml.fetchNodes(ctx)
+				cancel()
+				select {
+				case refreshes <- msg:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			ml.GetSelectedNode()
+			ml.GetAllNodes()
+			ml.SelectedVMID()
+			ml.SelectVMID(fmt.Sprint(100 + i%20))
+			ml.SetRefreshEnabled(i%2 == 0)
+			ml.autoRefreshDue()
+		}
+	}()
+
+	keys := []tea.KeyMsg{{Type: tea.KeyDown}, {Type: tea.KeyDown}, {Type: tea.KeyUp}, {Type: tea.KeyPgDown}, {Type: tea.KeyPgUp}}
+	for i := 0; i < 200; i++ {
+		select {
+		case msg := <-refreshes:
+			ml.model.Update(msg)
+		default:
+		}
+		ml.model.Update(keys[i%len(keys)])
+		ml.model.View()
+
+		if i%50 == 25 {
+			// Open the panel and save it as it is
+			ml.model.handleConfigKey()
+			ml.model.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+			ml.model.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+			_, cmd := ml.model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+			if cmd == nil {
+				t.Fatal("Expected a save command")
+			}
+			ml.model.Update(cmd())
+			if ml.model.showConfig {
+				t.Fatalf("Expected the panel closed after saving, last error %v", ml.lastError)
+			}
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if appConfig.APIUrl != "https://127.0.0.1:1" {
+		t.Error("The config the panel was opened on should be left alone")
+	}
+}
@@ -0,0 +1,85 @@
+package mainlist
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/maintenance"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// windowAsk asks whether to go ahead with an action asked for during a
+// maintenance window, in which the guest may be locked. It warns rather
+// than blocks: the window may not touch this guest.
+type windowAsk struct {
+	vm      *models.VMStatus
+	action  string
+	warning string
+}
+
+// activeWindow returns the maintenance window active now, and when it
+// ends
+func (ml *MainList) activeWindow() (maintenance.Window, time.Time, bool) {
+	if ml.appConfig == nil || len(ml.appConfig.MaintenanceWindows) == 0 {
+		return maintenance.Window{}, time.Time{}, false
+	}
+	return ml.appConfig.Windows().Active(ml.now())
+}
+
+// windowCandidate returns the question to put before action when a
+// maintenance window is active, nil when the action goes ahead. A locked
+// guest is left to executeAction, which explains the lock.
+func (m *listModel) windowCandidate(action string) *windowAsk {
+	warning := m.windowWarning()
+	if warning == "" {
+		return nil
+	}
+	m.parent.refreshMutex.Lock()
+	vm := m.parent.selectedGuest()
+	m.parent.refreshMutex.Unlock()
+	if vm == nil || vm.Locked() {
+		return nil
+	}
+	return &windowAsk{vm: vm, action: action, warning: warning}
+}
+
+// windowWarning is the warning put before the questions of actions while
+// a maintenance window is active, "" outside of one
+func (m *listModel) windowWarning() string {
+	window, until, ok := m.parent.activeWindow()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s window active until %s - guest may be locked. ", window.Name, until.Format("15:04"))
+}
+
+// prompt is the question in the status bar
+func (a *windowAsk) prompt() string {
+	return fmt.Sprintf("%s%s%s %s anyway? (y/n)", a.warning, strings.ToUpper(a.action[:1]), a.action[1:], a.vm.Key())
+}
+
+// handleWindowAskKeys goes on with the action once confirmed, asking
+// next whether to snapshot first when snapshot_before lists it
+func (m *listModel) handleWindowAskKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	a := m.windowAsk
+	switch msg.String() {
+	case "y", "Y":
+		m.windowAsk = nil
+		return m.runAction(a.action)
+	case "n", "N", "esc":
+		m.windowAsk = nil
+	}
+	return true, m, nil
+}
+
+// windowText notes the active maintenance window in the status bar, so a
+// locked guest doesn't come as a surprise
+func (m *listModel) windowText() string {
+	window, until, ok := m.parent.activeWindow()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s until %s", window.Name, until.Format("15:04"))
+}
@@ -0,0 +1,76 @@
+package mainlist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/config"
+)
+
+// windowDriver selects web-1 (100, running) with a backup window from
+// 11:00 to 13:00, around the driver's clock
+func windowDriver(t *testing.T, client *MockClient) *driver {
+	t.Helper()
+	d := newDriver(t, client)
+	d.ml.appConfig = &config.Config{MaintenanceWindows: []string{"backup 11:00-13:00 daily"}}
+	selectGuest(t, d, "100")
+	return d
+}
+
+func TestMaintenanceWindow_AsksBeforeActions(t *testing.T) {
+	client := e2eClient()
+	d := windowDriver(t, client)
+
+	d.key("t")
+	want := "backup window active until 13:00 - guest may be locked. Stop 100 anyway? (y/n)"
+	if bar := statusBar(d); !strings.Contains(bar, want) {
+		t.Fatalf("Expected %q:\n%s", want, bar)
+	}
+	d.key("n")
+	if len(client.Killed) != 0 {
+		t.Fatalf("n should not stop the guest, got %v", client.Killed)
+	}
+
+	d.key("t", "y")
+	if len(client.Killed) != 1 || client.Killed[0] != "100" {
+		t.Errorf("y should stop the guest, got %v", client.Killed)
+	}
+}
+
+func TestMaintenanceWindow_ThenSnapshotQuestion(t *testing.T) {
+	d := windowDriver(t, e2eClient())
+	d.ml.appConfig.SnapshotBefore = []string{"stop"}
+	d.ml.snapshots = nil
+
+	d.key("t", "y")
+	if d.ml.model.snapshotAsk == nil {
+		t.Error("The snapshot question should follow the warning")
+	}
+}
+
+func TestMaintenanceWindow_Hibernate(t *testing.T) {
+	d := windowDriver(t, e2eClient())
+	d.key("H")
+	if bar := statusBar(d); !strings.HasPrefix(strings.TrimSpace(bar), "backup window active until 13:00 - guest may be locked. Hibernate") {
+		t.Errorf("Expected the warning before the question:\n%s", bar)
+	}
+}
+
+func TestMaintenanceWindow_StatusBar(t *testing.T) {
+	d := windowDriver(t, e2eClient())
+	d.send(tea.WindowSizeMsg{Width: 160, Height: 24})
+	if bar := statusBar(d); !strings.Contains(bar, "| backup until 13:00") {
+		t.Errorf("Expected the window in the status bar:\n%s", bar)
+	}
+
+	d.clock.Advance(time.Hour)
+	if bar := statusBar(d); strings.Contains(bar, "backup") {
+		t.Errorf("The window has ended:\n%s", bar)
+	}
+	d.key("t")
+	if d.ml.model.windowAsk != nil {
+		t.Error("Outside the window the action should run at once")
+	}
+}
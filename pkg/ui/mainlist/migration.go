@@ -0,0 +1,221 @@
+package mainlist
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// migrationWidth fits "migrating → pve-node2 (100%)" but for long node
+// names
+const migrationWidth = 24
+
+// migration is a guest being moved to another node, as the running tasks
+// of the cluster tell
+type migration struct {
+	task     models.Task // The qmigrate or vzmigrate task; its node is the source
+	progress models.MigrationProgress
+	nextLine int  // First line of the task log not read yet
+	reading  bool // A read of the log is in flight
+	// ended is set once the task is gone from the running tasks: the
+	// guest may still be listed on the source for one more refresh
+	ended bool
+}
+
+// migrationLogMsg carries lines of a migration log read from line start on
+type migrationLogMsg struct {
+	key   string
+	upid  string
+	start int
+	lines []models.TaskLogLine
+	err   error
+}
+
+// trackMigrations follows the migrations among the running tasks of a
+// refresh. A migration whose task is gone is kept, ended, for one more
+// refresh. A refresh whose tasks failed leaves them as they were. Must be
+// called with refreshMutex held.
+func (ml *MainList) trackMigrations(snap *proxmox.RefreshSnapshot) {
+	if snap == nil || snap.Err(proxmox.SectionTasks) != nil {
+		return
+	}
+	running := make(map[string]models.Task)
+	for _, t := range snap.Tasks {
+		if t.IsMigration() && t.ID != "" {
+			running[t.ID] = t
+		}
+	}
+	for key, mg := range ml.migrations {
+		if t, ok := running[key]; ok && t.UPID == mg.task.UPID {
+			continue
+		}
+		if mg.ended {
+			delete(ml.migrations, key)
+		} else {
+			mg.ended = true
+		}
+	}
+	for key, t := range running {
+		if mg, ok := ml.migrations[key]; ok && mg.task.UPID == t.UPID {
+			continue
+		}
+		if ml.migrations == nil {
+			ml.migrations = make(map[string]*migration)
+		}
+		ml.migrations[key] = &migration{task: t, progress: models.NewMigrationProgress()}
+	}
+}
+
+// settleMigrations keeps the guests being migrated from flapping: while
+// cluster/resources lists one on both nodes, the source is kept, and the
+// target once the task ended; while it lists it on neither, the guest
+// stays as last listed. A guest still on the source the refresh after its
+// migration ended is shown on the target. Must be called with
+// refreshMutex held.
+func (ml *MainList) settleMigrations(nodes []*models.VMStatus) []*models.VMStatus {
+	if len(ml.migrations) == 0 || nodes == nil {
+		return nodes
+	}
+	listed := make(map[string][]*models.VMStatus)
+	settled := make([]*models.VMStatus, 0, len(nodes))
+	for _, n := range nodes {
+		if _, ok := ml.migrations[n.Key()]; ok {
+			listed[n.Key()] = append(listed[n.Key()], n)
+			continue
+		}
+		settled = append(settled, n)
+	}
+	for key, mg := range ml.migrations {
+		if guest := mg.settle(listed[key], ml.guests); guest != nil {
+			settled = append(settled, guest)
+		}
+	}
+	return settled
+}
+
+// settle picks the entry of the migrated guest to list among those of a
+// refresh, or the last listed one without any
+func (mg *migration) settle(entries []*models.VMStatus, previous models.NodeList) *models.VMStatus {
+	target := mg.progress.Target
+	switch {
+	case len(entries) == 0:
+		prev, ok := previous.Get(mg.task.ID)
+		if !ok {
+			return nil
+		}
+		return prev
+	case len(entries) > 1:
+		want := mg.task.Node
+		if mg.ended && target != "" {
+			want = target
+		}
+		for _, e := range entries {
+			if e.Node == want {
+				return e
+			}
+		}
+		return entries[0]
+	case mg.ended && target != "" && entries[0].Node == mg.task.Node:
+		moved := *entries[0]
+		moved.Node = target
+		return &moved
+	}
+	return entries[0]
+}
+
+// readMigrationLogsCmd reads the logs of the running migrations past the
+// lines already read. Must be called with refreshMutex held.
+func (ml *MainList) readMigrationLogsCmd() tea.Cmd {
+	if ml.taskManager == nil {
+		return nil
+	}
+	var cmds []tea.Cmd
+	for key, mg := range ml.migrations {
+		if mg.ended || mg.reading {
+			continue
+		}
+		mg.reading = true
+		client, task, start := ml.taskManager, mg.task, mg.nextLine
+		cmds = append(cmds, func() tea.Msg {
+			ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+			defer cancel()
+			lines, err := client.GetTaskLog(ctx, task.Node, task.UPID, start)
+			return migrationLogMsg{key: key, upid: task.UPID, start: start, lines: lines, err: err}
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// handleMigrationLog updates the progress of a migration from its log. A
+// failed read is tried again on the next refresh.
+func (m *listModel) handleMigrationLog(msg migrationLogMsg) (tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+	mg, ok := m.parent.migrations[msg.key]
+	if !ok || mg.task.UPID != msg.upid {
+		return m, nil
+	}
+	mg.reading = false
+	if msg.err == nil && msg.start == mg.nextLine {
+		mg.progress.Update(msg.lines)
+		mg.nextLine += len(msg.lines)
+	}
+	return m, nil
+}
+
+// migrating returns the running migration of a guest
+func (ml *MainList) migrating(node *models.VMStatus) (*migration, bool) {
+	mg, ok := ml.migrations[node.Key()]
+	if !ok || mg.ended {
+		return nil, false
+	}
+	return mg, true
+}
+
+// showMigration reports whether a guest is being migrated, for the
+// Migration column to show
+func (ml *MainList) showMigration() bool {
+	for _, mg := range ml.migrations {
+		if !mg.ended {
+			return true
+		}
+	}
+	return false
+}
+
+// migrationText returns the Migration cell of a guest: where it moves to
+// and how far along, as far as the log of its task tells
+func (ml *MainList) migrationText(node *models.VMStatus) string {
+	mg, ok := ml.migrating(node)
+	if !ok {
+		return ""
+	}
+	text := "migrating"
+	if mg.progress.Target != "" {
+		text += format.Text(" → ") + mg.progress.Target
+	}
+	if mg.progress.Percent >= 0 {
+		text += fmt.Sprintf(" (%.0f%%)", mg.progress.Percent)
+	}
+	return text
+}
+
+// migrationSorter orders the guests being migrated by progress
+func (ml *MainList) migrationSorter() sorter {
+	return sorter{
+		compare: func(a, b *models.VMStatus) int {
+			ma, _ := ml.migrating(a)
+			mb, _ := ml.migrating(b)
+			return cmp.Compare(ma.progress.Percent, mb.progress.Percent)
+		},
+		known: func(node *models.VMStatus) bool {
+			_, ok := ml.migrating(node)
+			return ok
+		},
+	}
+}
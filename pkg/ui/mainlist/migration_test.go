@@ -0,0 +1,150 @@
+package mainlist
+
+import (
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// migrationTask migrates web-2 from pve1
+var migrationTask = models.Task{UPID: "UPID:pve1:0001:qmigrate:101", Node: "pve1", Type: "qmigrate", ID: "101"}
+
+// migrationRefresh returns a refresh reading the e2e guests, with web-2
+// listed on each of nodes, and the running tasks
+func migrationRefresh(nodes []string, tasks ...models.Task) refreshMsg {
+	var guests []*models.VMStatus
+	for _, g := range e2eClient().Nodes {
+		if g.VMID != "101" {
+			guests = append(guests, g)
+			continue
+		}
+		for _, node := range nodes {
+			moved := *g
+			moved.Node = node
+			guests = append(guests, &moved)
+		}
+	}
+	return refreshMsg{nodes: guests, snapshot: &proxmox.RefreshSnapshot{Guests: guests, Tasks: tasks}}
+}
+
+func newMigrationDriver(t *testing.T, client *MockClient) *driver {
+	format.SetColor(false)
+	t.Cleanup(func() { format.SetColor(true) })
+	ml := NewMainList(Config{Provider: client, Tasks: client})
+	d := &driver{t: t, ml: ml}
+	d.send(tea.WindowSizeMsg{Width: 120, Height: 24})
+	return d
+}
+
+// listedOn returns the nodes web-2 is listed on
+func listedOn(ml *MainList) []string {
+	var nodes []string
+	for _, g := range ml.sortedNodes {
+		if g.VMID == "101" {
+			nodes = append(nodes, g.Node)
+		}
+	}
+	return nodes
+}
+
+func TestMigration_SettlesOnTarget(t *testing.T) {
+	client := e2eClient()
+	client.TaskLog = []models.TaskLogLine{
+		{N: 1, Text: "starting migration of VM 101 to node 'pve2' (10.0.0.2)"},
+		{N: 2, Text: "drive-scsi0: transferred 13.8 GiB of 32.0 GiB (43.12%) in 1m"},
+	}
+	d := newMigrationDriver(t, client)
+
+	d.send(migrationRefresh([]string{"pve1"}, migrationTask))
+	if got := listedOn(d.ml); len(got) != 1 || got[0] != "pve1" {
+		t.Errorf("web-2 should be listed on pve1, got %v", got)
+	}
+	web2, _ := d.ml.guests.Get("101")
+	if got, want := d.ml.migrationText(web2), "migrating"+format.Text(" → ")+"pve2 (43%)"; got != want {
+		t.Errorf("Migration cell = %q, want %q", got, want)
+	}
+	if !d.ml.showMigration() {
+		t.Error("The Migration column should show while web-2 migrates")
+	}
+
+	d.send(migrationRefresh([]string{"pve1", "pve2"}, migrationTask))
+	if got := listedOn(d.ml); len(got) != 1 || got[0] != "pve1" {
+		t.Errorf("web-2 listed on both nodes should stay on the source, got %v", got)
+	}
+
+	d.send(migrationRefresh(nil, migrationTask))
+	if got := listedOn(d.ml); len(got) != 1 || got[0] != "pve1" {
+		t.Errorf("web-2 listed on neither node should stay as it was, got %v", got)
+	}
+
+	// The task ended before cluster/resources caught up
+	d.send(migrationRefresh([]string{"pve1"}))
+	if got := listedOn(d.ml); len(got) != 1 || got[0] != "pve2" {
+		t.Errorf("web-2 should be shown on the target once the task ended, got %v", got)
+	}
+	if d.ml.showMigration() {
+		t.Error("The Migration column should hide once the task ended")
+	}
+
+	d.send(migrationRefresh([]string{"pve2"}))
+	if got := listedOn(d.ml); len(got) != 1 || got[0] != "pve2" {
+		t.Errorf("web-2 should be listed on pve2, got %v", got)
+	}
+	if len(d.ml.migrations) != 0 {
+		t.Errorf("The ended migration should be forgotten, got %v", d.ml.migrations)
+	}
+}
+
+func TestMigration_BothNodesAfterEnd(t *testing.T) {
+	client := e2eClient()
+	client.TaskLog = []models.TaskLogLine{{N: 1, Text: "starting migration of VM 101 to node 'pve2' (10.0.0.2)"}}
+	d := newMigrationDriver(t, client)
+
+	d.send(migrationRefresh([]string{"pve1"}, migrationTask))
+	d.send(migrationRefresh([]string{"pve1", "pve2"}))
+	if got := listedOn(d.ml); len(got) != 1 || got[0] != "pve2" {
+		t.Errorf("web-2 listed on both nodes after the task ended should be on the target, got %v", got)
+	}
+}
+
+func TestMigration_TasksFailed(t *testing.T) {
+	d := newMigrationDriver(t, e2eClient())
+
+	d.send(migrationRefresh([]string{"pve1"}, migrationTask))
+	failed := migrationRefresh([]string{"pve1"})
+	failed.snapshot.Errors = map[proxmox.Section]error{proxmox.SectionTasks: errors.New("timeout")}
+	d.send(failed)
+
+	if !d.ml.showMigration() {
+		t.Error("A refresh whose tasks failed should not end the migration")
+	}
+	web2, _ := d.ml.guests.Get("101")
+	if got := d.ml.migrationText(web2); got != "migrating" {
+		t.Errorf("Without a log the cell should only say migrating, got %q", got)
+	}
+}
+
+func TestMigration_Column(t *testing.T) {
+	d := newMigrationDriver(t, e2eClient())
+	hasColumn := func() bool {
+		for _, c := range d.ml.visibleColumns() {
+			if c.id == colMigration {
+				return true
+			}
+		}
+		return false
+	}
+
+	d.send(migrationRefresh([]string{"pve1"}))
+	if hasColumn() {
+		t.Error("The Migration column should hide without migrations")
+	}
+	d.send(migrationRefresh([]string{"pve1"}, migrationTask))
+	if !hasColumn() {
+		t.Error("The Migration column should show during a migration")
+	}
+}
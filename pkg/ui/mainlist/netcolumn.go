@@ -0,0 +1,35 @@
+package mainlist
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// netWidth is the width of the Net column, enough for vmbr10.4094
+const netWidth = 11
+
+// toggleNet shows or hides the Net column, filling in the guests whose
+// network isn't known yet
+func (m *listModel) toggleNet() tea.Cmd {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+
+	m.parent.showNet = !m.parent.showNet
+	if !m.parent.showNet {
+		return nil
+	}
+	return m.parent.fillConfigsCmd()
+}
+
+// netText returns the Net column value for a guest: the bridge and VLAN
+// of its first NIC, "-" without one, or an ellipsis until it is known
+func (ml *MainList) netText(key string) string {
+	nics, ok := ml.nics[key]
+	switch {
+	case !ok:
+		return format.Text("…")
+	case len(nics) == 0:
+		return "-"
+	}
+	return nics[0].Summary()
+}
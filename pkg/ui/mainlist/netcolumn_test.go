@@ -0,0 +1,24 @@
+package mainlist
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTextFilter_FindsVLAN(t *testing.T) {
+	client := newConfigClient(netConfigs())
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, Filter: Filter{Text: "tag:30"}})
+
+	_, cmd := ml.model.Update(ml.fetchNodes(context.Background()))
+	if len(ml.sortedNodes) != 0 {
+		t.Errorf("Nothing matches before the networks are known, got %d guests", len(ml.sortedNodes))
+	}
+	if cmd == nil {
+		t.Fatal("A text filter should start a sweep even with the column hidden")
+	}
+	ml.model.Update(cmd())
+
+	if len(ml.sortedNodes) != 1 || ml.sortedNodes[0].VMID != "100" {
+		t.Errorf("Expected only 100 on VLAN 30, got %v", ml.sortedNodes)
+	}
+}
@@ -0,0 +1,82 @@
+package mainlist
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/detailsdialog"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// nodeLoadWidth is the room the CPU% column makes for the node load, enough
+// for " (node 100%)"
+const nodeLoadWidth = 12
+
+// nodeLoadStyle dims the node load next to a guest's CPU usage
+var nodeLoadStyle = lipgloss.NewStyle().Faint(true)
+
+// cpuPressureStyle colors the row of a busy guest on a busy node
+var cpuPressureStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
+
+// showNodeLoad reports whether the CPU% column carries the node load
+func (ml *MainList) showNodeLoad() bool {
+	return ml.appConfig != nil && ml.appConfig.ShowNodeLoad
+}
+
+// cpuPressureThreshold returns the configured CPU usage past which a guest
+// and its node are both busy, or the default without a config
+func (ml *MainList) cpuPressureThreshold() float64 {
+	if ml.appConfig != nil && ml.appConfig.CPUPressureWarning > 0 {
+		return ml.appConfig.CPUPressureWarning
+	}
+	return config.DefaultCPUPressureWarning
+}
+
+// nodeLoad returns the node a running guest is on, if the last refresh
+// listed it online. Must be called with refreshMutex held.
+func (ml *MainList) nodeLoad(node *models.VMStatus) (models.ClusterNode, bool) {
+	if !node.IsRunning() || !cpuKnown(node) {
+		return models.ClusterNode{}, false
+	}
+	for _, n := range ml.clusterNodes() {
+		if n.Name == node.Node && n.Online {
+			return n, true
+		}
+	}
+	return models.ClusterNode{}, false
+}
+
+// cpuPressure reports whether a guest and its node both run past the
+// threshold: the guest is then likely starved by its host rather than
+// merely busy. Must be called with refreshMutex held.
+func (ml *MainList) cpuPressure(node *models.VMStatus) bool {
+	host, ok := ml.nodeLoad(node)
+	threshold := ml.cpuPressureThreshold()
+	return ok && node.CPUUsage >= threshold && host.CPUUsage >= threshold
+}
+
+// nodeLoadText returns the suffix of the CPU% cell, e.g. " (node 96%)",
+// or "" when it isn't shown or the node is unknown. Must be called with
+// refreshMutex held.
+func (ml *MainList) nodeLoadText(node *models.VMStatus) string {
+	if !ml.showNodeLoad() {
+		return ""
+	}
+	host, ok := ml.nodeLoad(node)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(" (node %s)", format.Percent(host.CPUUsage, 0))
+}
+
+// nodeLoadDetails returns the node load shown by the details dialog, nil
+// when the node is unknown. Must be called with refreshMutex held.
+func (ml *MainList) nodeLoadDetails(node *models.VMStatus) *detailsdialog.NodeLoad {
+	host, ok := ml.nodeLoad(node)
+	if !ok {
+		return nil
+	}
+	return &detailsdialog.NodeLoad{CPU: host.CPUUsage, Cores: host.MaxCPU, Pressure: ml.cpuPressure(node)}
+}
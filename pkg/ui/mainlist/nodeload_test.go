@@ -0,0 +1,101 @@
+package mainlist
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// newNodeLoadDriver returns a driver whose pve1 is idle and pve2 busy, so
+// that db (55% on pve2) is under pressure past a 50% threshold
+func newNodeLoadDriver(t *testing.T, show bool) *driver {
+	d := newDriver(t, e2eClient())
+	d.ml.appConfig = &config.Config{ShowNodeLoad: show, CPUPressureWarning: 50}
+	d.ml.cluster = &proxmox.RefreshSnapshot{Nodes: []models.ClusterNode{
+		{Name: "pve1", Online: true, CPUUsage: 30, MaxCPU: 4},
+		{Name: "pve2", Online: true, CPUUsage: 96, MaxCPU: 16},
+	}}
+	return d
+}
+
+func TestNodeLoad_Cell(t *testing.T) {
+	d := newNodeLoadDriver(t, true)
+	for vmid, want := range map[string]string{"100": "12.5% (node 30%)", "102": "55.0% (node 96%)", "101": "0.0%"} {
+		if got := d.ml.model.cellText(colCPU, sinceGuest(t, d.ml, vmid), false); got != want {
+			t.Errorf("%s: expected %q, got %q", vmid, want, got)
+		}
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "55.0% (node 96%)") {
+		t.Errorf("Expected the node load in the list:\n%s", view)
+	}
+
+	d = newNodeLoadDriver(t, false)
+	if got := d.ml.model.cellText(colCPU, sinceGuest(t, d.ml, "102"), false); got != "55.0%" {
+		t.Errorf("The node load should be hidden by default, got %q", got)
+	}
+}
+
+func TestNodeLoad_Pressure(t *testing.T) {
+	d := newNodeLoadDriver(t, false)
+	for vmid, want := range map[string]bool{"100": false, "101": false, "102": true, "200": false} {
+		if got := d.ml.cpuPressure(sinceGuest(t, d.ml, vmid)); got != want {
+			t.Errorf("%s: expected pressure %v, got %v", vmid, want, got)
+		}
+	}
+
+	if row := d.ml.model.renderRow(sinceGuest(t, d.ml, "102"), false); !strings.HasPrefix(row, " [!]") {
+		t.Errorf("Without color the gutter should flag pressure, got %q", row)
+	}
+
+	d.ml.cluster.Nodes[1].Online = false
+	if d.ml.cpuPressure(sinceGuest(t, d.ml, "102")) {
+		t.Error("An offline node should not count")
+	}
+
+	d.ml.appConfig = nil
+	d.ml.cluster.Nodes[1].Online = true
+	if d.ml.cpuPressure(sinceGuest(t, d.ml, "102")) {
+		t.Errorf("Without a config the %v%% default applies", config.DefaultCPUPressureWarning)
+	}
+}
+
+func TestNodeLoad_RowStyle(t *testing.T) {
+	original := lipgloss.ColorProfile()
+	defer lipgloss.SetColorProfile(original)
+	lipgloss.SetColorProfile(termenv.TrueColor)
+
+	d := newNodeLoadDriver(t, true)
+	busy, idle := sinceGuest(t, d.ml, "102"), sinceGuest(t, d.ml, "100")
+
+	format.SetColor(true)
+	alert := strings.TrimSuffix(cpuPressureStyle.Render("x"), "x\x1b[0m")
+	if got := d.ml.model.renderRow(busy, false); !strings.HasPrefix(got, alert) || !strings.Contains(got, "55.0% (node 96%)") {
+		t.Errorf("A guest under pressure should take the alert color as a whole, got %q", got)
+	}
+	if got := d.ml.model.renderRow(idle, false); !strings.Contains(got, nodeLoadStyle.Render(" (node 30%)")) {
+		t.Errorf("The node load should be dimmed, got %q", got)
+	}
+}
+
+func TestNodeLoad_Details(t *testing.T) {
+	d := newNodeLoadDriver(t, false)
+	if load := d.ml.nodeLoadDetails(sinceGuest(t, d.ml, "101")); load != nil {
+		t.Errorf("A stopped guest has no node load, got %+v", load)
+	}
+
+	d.key("down")
+	d.key("down")
+	d.key("enter")
+	view := d.ml.model.View()
+	for _, want := range []string{"Node Load", "96.00% of 16 cores", "55.00% (node busy too)"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q in the details:\n%s", want, view)
+		}
+	}
+}
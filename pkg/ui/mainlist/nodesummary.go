@@ -0,0 +1,59 @@
+package mainlist
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/nodesummary"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// handleNodeSummaryKey opens the node summary screen
+func (m *listModel) handleNodeSummaryKey() (bool, tea.Model, tea.Cmd) {
+	state := nodesummary.New(m.parent.overcommitThresholds())
+	m.nodeSummary = &state
+	return true, m, nil
+}
+
+// handleNodeSummaryKeys handles keys while the node summary is open
+func (m *listModel) handleNodeSummaryKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	count := len(nodesummary.Rows(m.parent.clusterNodes(), m.parent.guests.All(), m.parent.probes, m.nodeSummary.Thresholds))
+	m.parent.refreshMutex.Unlock()
+
+	if m.nodeSummary.HandleKey(msg.String(), count, m.height) == screen.Closed {
+		m.nodeSummary = nil
+	}
+	return true, m, nil
+}
+
+// renderNodeSummary renders the node summary from the latest refresh
+func (m *listModel) renderNodeSummary() string {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+	return nodesummary.GetText(*m.nodeSummary, m.parent.clusterNodes(), m.parent.guests.All(), m.parent.probes, m.width, m.height)
+}
+
+// clusterNodes returns the nodes read by the last refresh, nil when the
+// provider doesn't list them. Must be called with refreshMutex held.
+func (ml *MainList) clusterNodes() []models.ClusterNode {
+	if ml.cluster == nil {
+		return nil
+	}
+	return ml.cluster.Nodes
+}
+
+// overcommitThresholds returns the configured overcommit highlights, or
+// the defaults without a config
+func (ml *MainList) overcommitThresholds() nodesummary.Thresholds {
+	th := nodesummary.Thresholds{CPU: config.DefaultOvercommitCPUWarning, Mem: config.DefaultOvercommitMemWarning}
+	if ml.appConfig != nil {
+		if ml.appConfig.OvercommitCPUWarning > 0 {
+			th.CPU = ml.appConfig.OvercommitCPUWarning
+		}
+		if ml.appConfig.OvercommitMemWarning > 0 {
+			th.Mem = ml.appConfig.OvercommitMemWarning
+		}
+	}
+	return th
+}
@@ -0,0 +1,49 @@
+package mainlist
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+func TestNodeSummary_Screen(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.ml.cluster = &proxmox.RefreshSnapshot{Nodes: []models.ClusterNode{
+		{Name: "pve1", Online: true, MaxCPU: 4, MaxMem: 8 << 30},
+		{Name: "pve2", Online: true, MaxCPU: 16, MaxMem: 32 << 30},
+	}}
+
+	d.key("n")
+	view := d.ml.model.View()
+	for _, want := range []string{
+		"Node Summary",
+		"pve1  CPU 0.0% of 4 cores, Mem 0.0% of 8 GiB",
+		"defined  alloc 5/4 cores (125%), 9/8 GiB (112%) [!]",
+		"running  alloc 2/4 cores (50%), 4/8 GiB (50%)",
+		"defined  alloc 9/16 cores (56%), 17/32 GiB (53%)",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	d.key("esc")
+	if d.ml.model.nodeSummary != nil {
+		t.Fatal("ESC should close the screen")
+	}
+}
+
+func TestNodeSummary_Thresholds(t *testing.T) {
+	ml := NewMainList(Config{Provider: &MockDataProvider{}})
+	if th := ml.overcommitThresholds(); th.CPU != config.DefaultOvercommitCPUWarning || th.Mem != config.DefaultOvercommitMemWarning {
+		t.Errorf("Without a config the defaults apply, got %+v", th)
+	}
+
+	ml = NewMainList(Config{Provider: &MockDataProvider{}, AppConfig: &config.Config{OvercommitCPUWarning: 300}})
+	if th := ml.overcommitThresholds(); th.CPU != 300 || th.Mem != config.DefaultOvercommitMemWarning {
+		t.Errorf("Expected the configured CPU threshold, got %+v", th)
+	}
+}
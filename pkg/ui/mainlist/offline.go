@@ -0,0 +1,41 @@
+package mainlist
+
+import (
+	"fmt"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// nodeOfflineError blocks an action on a guest of a node that isn't
+// online: the request would only time out
+type nodeOfflineError struct {
+	node string
+}
+
+func (e *nodeOfflineError) Error() string {
+	return fmt.Sprintf("node %s is offline or in maintenance; its guests show their last known state", e.node)
+}
+
+// guestUnknownError blocks an action on a guest whose state the API
+// doesn't tell: whatever it would do can't be foreseen
+type guestUnknownError struct {
+	node string
+}
+
+func (e *guestUnknownError) Error() string {
+	return fmt.Sprintf("the state of the guest is unknown; check node %s (pvestatd, storage, maintenance)", e.node)
+}
+
+// keepLastKnown gives the guests of offline nodes the state they had in
+// the list, rather than an unknown status and zeros. Must be called with
+// refreshMutex held, before the guests are replaced.
+func (ml *MainList) keepLastKnown(guests []*models.VMStatus) {
+	for _, g := range guests {
+		if !g.NodeOffline {
+			continue
+		}
+		if prev, ok := ml.guests.Get(g.Key()); ok {
+			g.KeepLastKnown(prev)
+		}
+	}
+}
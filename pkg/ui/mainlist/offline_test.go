@@ -0,0 +1,145 @@
+package mainlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// takeNodeOffline lists the guests of pve2 as a node in maintenance
+// does: unknown, zeroed and marked NodeOffline
+func takeNodeOffline(client *MockClient) {
+	for i, vm := range client.Nodes {
+		if vm.Node != "pve2" {
+			continue
+		}
+		client.Nodes[i] = &models.VMStatus{VMID: vm.VMID, VMIDNum: vm.VMIDNum, Name: vm.Name, Type: vm.Type,
+			Status: models.StateUnknown, Node: vm.Node, MaxMem: vm.MaxMem, MaxCPU: vm.MaxCPU, MaxDisk: vm.MaxDisk,
+			NodeOffline: true, Missing: models.MetricMem | models.MetricUptime | models.MetricDisk}
+	}
+}
+
+// rowOf returns the list row of the guest vmid
+func rowOf(t *testing.T, d *driver, vmid string) string {
+	t.Helper()
+	for _, line := range strings.Split(d.ml.model.View(), "\n") {
+		if strings.Contains(line, " "+vmid+" ") {
+			return line
+		}
+	}
+	t.Fatalf("No row for %s:\n%s", vmid, d.ml.model.View())
+	return ""
+}
+
+func TestNodeOffline_KeepsLastKnown(t *testing.T) {
+	client := e2eClient()
+	d := newDriver(t, client)
+	takeNodeOffline(client)
+	d.send(d.ml.fetchNodes(context.Background()))
+
+	row := rowOf(t, d, "102")
+	for _, want := range []string{"stale", "55.0%", "81.0%"} {
+		if !strings.Contains(row, want) {
+			t.Errorf("Expected %q in the row of a guest of an offline node:\n%s", want, row)
+		}
+	}
+	if vm, _ := d.ml.guests.Get("102"); vm.Status != models.StateRunning {
+		t.Errorf("Expected the last known status, got %s", vm.Status)
+	}
+
+	// Still known on the next refresh
+	takeNodeOffline(client)
+	d.send(d.ml.fetchNodes(context.Background()))
+	if row := rowOf(t, d, "102"); !strings.Contains(row, "55.0%") {
+		t.Errorf("Expected the last known usage kept:\n%s", row)
+	}
+}
+
+func TestNodeOffline_NeverSeen(t *testing.T) {
+	client := e2eClient()
+	takeNodeOffline(client)
+	d := newDriver(t, client)
+
+	row := rowOf(t, d, "102")
+	if !strings.Contains(row, "stale") || strings.Contains(row, "0.0%") {
+		t.Errorf("Expected a stale row without zeros:\n%s", row)
+	}
+}
+
+func TestNodeOffline_BlocksActions(t *testing.T) {
+	client := e2eClient()
+	d := newDriver(t, client)
+	takeNodeOffline(client)
+	d.send(d.ml.fetchNodes(context.Background()))
+	selectGuest(t, d, "102")
+
+	d.key("t")
+	want := "Cannot stop 102: node pve2 is offline or in maintenance"
+	if bar := statusBar(d); !strings.Contains(bar, want) {
+		t.Errorf("Expected %q:\n%s", want, bar)
+	}
+	if len(client.Killed) != 0 {
+		t.Errorf("No request should be sent to an offline node, got %v", client.Killed)
+	}
+
+	d.key("x", "B")
+	if len(client.ShutDown) != 0 || d.ml.model.restart == nil || d.ml.model.restart.err == nil {
+		t.Errorf("A restart on an offline node should be refused, got %v", client.ShutDown)
+	}
+}
+
+// makeUnknown lists the guest vmid in a state the API doesn't tell, its
+// usage zeroed
+func makeUnknown(client *MockClient, vmid string) {
+	for i, vm := range client.Nodes {
+		if vm.VMID == vmid {
+			client.Nodes[i] = &models.VMStatus{VMID: vm.VMID, VMIDNum: vm.VMIDNum, Name: vm.Name, Type: vm.Type,
+				Status: models.StateUnknown, Node: vm.Node, MaxMem: vm.MaxMem, MaxCPU: vm.MaxCPU, MaxDisk: vm.MaxDisk,
+				Missing: models.StaleMetrics}
+		}
+	}
+}
+
+func TestUnknownState_Row(t *testing.T) {
+	client := e2eClient()
+	makeUnknown(client, "101")
+	d := newDriver(t, client)
+
+	row := rowOf(t, d, "101")
+	if strings.Contains(row, "0.0%") || strings.Contains(row, "unknown") {
+		t.Errorf("Expected a ? status without zeros:\n%s", row)
+	}
+	if got := statusText(d.ml.sortedNodes[0]); got != "?" {
+		t.Errorf("Expected the unknown guest first with a ? status, got %q", got)
+	}
+}
+
+func TestUnknownState_SortsFirst(t *testing.T) {
+	ml := &MainList{}
+	unknown := &models.VMStatus{VMID: "300", Name: "zz", Status: models.StateUnknown}
+	running := &models.VMStatus{VMID: "100", Name: "aa", Status: models.StateRunning, CPUUsage: 90}
+	for _, order := range []sortOrder{{column: colName}, {column: colCPU, desc: true}, {column: colVMID, desc: true}} {
+		less := ml.less(order)
+		if !less(unknown, running) || less(running, unknown) {
+			t.Errorf("%+v: a guest in an unknown state should lead the list", order)
+		}
+	}
+}
+
+func TestUnknownState_BlocksActions(t *testing.T) {
+	client := e2eClient()
+	makeUnknown(client, "101")
+	d := newDriver(t, client)
+	selectGuest(t, d, "101")
+
+	d.key("t")
+	want := "Cannot stop 101: the state of the guest is unknown; check node"
+	if bar := statusBar(d); !strings.Contains(bar, want) {
+		t.Errorf("Expected %q:\n%s", want, bar)
+	}
+	if len(client.Killed) != 0 {
+		t.Errorf("No request should be sent for a guest in an unknown state, got %v", client.Killed)
+	}
+}
@@ -0,0 +1,35 @@
+package mainlist
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// ownerWidth is the width of the Owner column
+const ownerWidth = 10
+
+// toggleOwner shows or hides the Owner column, filling in the guests
+// whose description hasn't been read yet
+func (m *listModel) toggleOwner() tea.Cmd {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+
+	m.parent.showOwner = !m.parent.showOwner
+	if !m.parent.showOwner {
+		return nil
+	}
+	return m.parent.fillConfigsCmd()
+}
+
+// ownerText returns the Owner column value for a guest: the owner found
+// in its description, "-" without one, or an ellipsis until it is known
+func (ml *MainList) ownerText(key string) string {
+	owner, ok := ml.owners[key]
+	switch {
+	case !ok:
+		return format.Text("…")
+	case owner == "":
+		return "-"
+	}
+	return owner
+}
@@ -0,0 +1,38 @@
+package mainlist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/config"
+)
+
+func TestOwnerColumn_Regex(t *testing.T) {
+	client := newConfigClient(ownerConfigs())
+	client.configs["101"] = map[string]interface{}{"description": "team=storage"}
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client,
+		AppConfig: &config.Config{OwnerRegex: `team=(\w+)`}})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	ml.model.Update(ml.model.toggleOwner()())
+
+	if got := ml.ownerText("101"); got != "storage" {
+		t.Errorf("Expected the owner_regex group, got %q", got)
+	}
+	if got := ml.ownerText("100"); got != "-" {
+		t.Errorf("The default rule should no longer apply, got %q", got)
+	}
+}
+
+func TestOwnerFilter(t *testing.T) {
+	client := newConfigClient(ownerConfigs())
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, Filter: Filter{Text: "owner:Alice"}})
+	_, cmd := ml.model.Update(ml.fetchNodes(context.Background()))
+	if cmd == nil {
+		t.Fatal("The text filter should read the configs")
+	}
+	ml.model.Update(cmd())
+
+	if len(ml.sortedNodes) != 1 || ml.sortedNodes[0].VMID != "100" {
+		t.Errorf("Expected only alice's guest, got %v", ml.sortedNodes)
+	}
+}
@@ -0,0 +1,99 @@
+package mainlist
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/crash"
+)
+
+// panicMsg reports a panic recovered in a command; the list shows it
+// and keeps running
+type panicMsg struct {
+	err *crash.Panic
+}
+
+// guard returns cmd recovering from its panics, and from those of the
+// commands it batches, as a panicMsg rather than letting Bubble Tea kill
+// the program
+func guard(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				msg = panicMsg{err: crash.New(r)}
+			}
+		}()
+		msg = cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			wrapped := make(tea.BatchMsg, len(batch))
+			for i, c := range batch {
+				wrapped[i] = guard(c)
+			}
+			return wrapped
+		}
+		return msg
+	}
+}
+
+// recoverTo stores a panic of the calling function in *err. It must be
+// deferred itself, as recover only works there.
+func recoverTo(err *error) {
+	if r := recover(); r != nil {
+		*err = crash.New(r)
+	}
+}
+
+// guarded runs f, returning its panic as an error
+func guarded(f func() error) (err error) {
+	defer recoverTo(&err)
+	return f()
+}
+
+// logPanic writes the stack of a recovered panic to a crash log and
+// returns err naming the file; other errors are returned as they are
+func (ml *MainList) logPanic(err error) error {
+	var p *crash.Panic
+	if !errors.As(err, &p) || ml.crashDir == "" {
+		return err
+	}
+	path, werr := crash.WriteLog(ml.crashDir, p, time.Now())
+	if werr != nil {
+		log.Printf("%v: cannot write the crash log: %v\n%s", p, werr, p.Stack)
+		return err
+	}
+	return fmt.Errorf("%w (stack trace in %s)", err, path)
+}
+
+// handlePanic shows a panic recovered in a command in the banner, as a
+// failed refresh would be
+func (m *listModel) handlePanic(msg panicMsg) (tea.Model, tea.Cmd) {
+	err := m.parent.logPanic(msg.err)
+	m.parent.refreshMutex.Lock()
+	m.parent.lastError = err
+	m.parent.refreshMutex.Unlock()
+	return m, nil
+}
+
+// crashed ends the program after a panic in Update or View, which leaves
+// the list in an unknown state. The terminal is restored as on any quit,
+// and Run returns the panic with the crash log.
+func (ml *MainList) crashed(p *crash.Panic) {
+	if ml.fatal.Load() != nil {
+		return
+	}
+	ml.fatal.Store(&fatalPanic{err: ml.logPanic(p)})
+	if ml.running.Load() {
+		go ml.program.Send(tea.Quit())
+	}
+}
+
+// fatalPanic is the panic that ended the program
+type fatalPanic struct {
+	err error
+}
@@ -0,0 +1,147 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/internal/testutil"
+	"github.com/tsupplis/pvec/pkg/crash"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// panickyClient panics where asked, as a bug in the padding math or a
+// provider would
+type panickyClient struct {
+	*MockClient
+	refresh  bool
+	shutdown bool
+}
+
+func (c *panickyClient) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	if c.refresh {
+		var rows []*models.VMStatus
+		return rows[:1], nil
+	}
+	return c.MockClient.GetNodes(ctx)
+}
+
+func (c *panickyClient) Shutdown(ctx context.Context, node, vmType, vmid string) error {
+	if c.shutdown {
+		panic("shutdown went wrong")
+	}
+	return c.MockClient.Shutdown(ctx, node, vmType, vmid)
+}
+
+// panicDriver is newDriver over a panicky client, logging crashes to a
+// temporary directory
+func panicDriver(t *testing.T, client *panickyClient, onNodes func([]*models.VMStatus)) (*driver, string) {
+	t.Helper()
+	dir := t.TempDir()
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, CrashDir: dir, OnNodesUpdated: onNodes})
+	ml.clock = testutil.NewFakeClock(e2eNow)
+	d := &driver{t: t, ml: ml}
+	d.send(tea.WindowSizeMsg{Width: 160, Height: 24})
+	d.send(ml.fetchNodes(context.Background()))
+	return d, dir
+}
+
+// crashLogs returns the crash logs written to dir
+func crashLogs(t *testing.T, dir string) []string {
+	t.Helper()
+	logs, err := filepath.Glob(filepath.Join(dir, "crash-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logs
+}
+
+func TestPanic_Provider(t *testing.T) {
+	client := &panickyClient{MockClient: e2eClient()}
+	d, dir := panicDriver(t, client, nil)
+
+	client.refresh = true
+	d.send(d.ml.fetchNodes(context.Background()))
+	if d.quit {
+		t.Fatal("A panicking refresh must not end the program")
+	}
+	logs := crashLogs(t, dir)
+	if len(logs) != 1 {
+		t.Fatalf("Expected a crash log, got %v", logs)
+	}
+	view := d.ml.model.View()
+	if !strings.Contains(view, "internal error: runtime error: slice bounds out of range") || !strings.Contains(view, "crash-") {
+		t.Errorf("Expected the panic and its log in the banner:\n%s", view)
+	}
+	if data, _ := os.ReadFile(logs[0]); !strings.Contains(string(data), "panickyClient).GetNodes") {
+		t.Errorf("Expected the stack in the log, got:\n%s", data)
+	}
+
+	client.refresh = false
+	d.send(d.ml.fetchNodes(context.Background()))
+	if view := d.ml.model.View(); strings.Contains(view, "internal error") || !strings.Contains(view, "web-1") {
+		t.Errorf("Expected the next refresh to recover:\n%s", view)
+	}
+}
+
+func TestPanic_Action(t *testing.T) {
+	client := &panickyClient{MockClient: e2eClient(), shutdown: true}
+	d, dir := panicDriver(t, client, nil)
+	selectGuest(t, d, "100")
+
+	d.key("d")
+	if d.quit {
+		t.Fatal("A panicking action must not end the program")
+	}
+	if bar := statusBar(d); !strings.Contains(bar, "Failed to shutdown 100: internal error: shutdown went wrong") {
+		t.Errorf("Expected the action to fail with the panic:\n%s", bar)
+	}
+	if logs := crashLogs(t, dir); len(logs) != 1 {
+		t.Errorf("Expected a crash log, got %v", logs)
+	}
+}
+
+func TestPanic_Update(t *testing.T) {
+	client := &panickyClient{MockClient: e2eClient()}
+	panics := false
+	d, dir := panicDriver(t, client, func([]*models.VMStatus) {
+		if panics {
+			panic("hook went wrong")
+		}
+	})
+
+	panics = true
+	d.send(d.ml.fetchNodes(context.Background()))
+	if !d.quit {
+		t.Fatal("A panic in Update should quit, restoring the terminal")
+	}
+	fatal := d.ml.fatal.Load()
+	if fatal == nil || !errors.As(fatal.err, new(*crash.Panic)) || !strings.Contains(fatal.err.Error(), "stack trace in "+dir) {
+		t.Fatalf("Expected Run to return the panic and its log, got %+v", fatal)
+	}
+	if view := d.ml.model.View(); view != "" {
+		t.Errorf("Nothing should be drawn after a panic, got:\n%s", view)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	boom := func() tea.Msg { panic("boom") }
+	if msg, ok := guard(boom)().(panicMsg); !ok || msg.err.Value != "boom" {
+		t.Errorf("Expected a panicMsg, got %v", msg)
+	}
+
+	batch, ok := guard(tea.Batch(boom, func() tea.Msg { return tickMsg{} }))().(tea.BatchMsg)
+	if !ok || len(batch) != 2 {
+		t.Fatalf("Expected the batch to be kept, got %v", batch)
+	}
+	if _, ok := batch[0]().(panicMsg); !ok {
+		t.Error("Expected the batched commands to be guarded too")
+	}
+	if guard(nil) != nil {
+		t.Error("A nil command stays nil")
+	}
+}
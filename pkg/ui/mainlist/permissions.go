@@ -0,0 +1,67 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/permissions"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// permissionsMsg carries the token's privileges
+type permissionsMsg struct {
+	seq   int
+	perms models.Permissions
+	err   error
+}
+
+// handlePermissionsKey opens the token permission screen
+func (m *listModel) handlePermissionsKey() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	state := permissions.New(m.parent.guests.All())
+	m.parent.refreshMutex.Unlock()
+
+	m.permissions = &state
+	m.permissionsSeq++
+	if m.parent.permissionReader == nil {
+		m.permissions.SetPermissions(nil, fmt.Errorf("client not available"))
+		return true, m, nil
+	}
+	return true, m, m.readPermissionsCmd()
+}
+
+// readPermissionsCmd reads the token's privileges
+func (m *listModel) readPermissionsCmd() tea.Cmd {
+	client, seq := m.parent.permissionReader, m.permissionsSeq
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+		defer cancel()
+		perms, err := client.GetPermissions(ctx)
+		return permissionsMsg{seq: seq, perms: perms, err: err}
+	}
+}
+
+// handlePermissions shows the privileges read
+func (m *listModel) handlePermissions(msg permissionsMsg) (tea.Model, tea.Cmd) {
+	if m.permissions != nil && msg.seq == m.permissionsSeq {
+		m.permissions.SetPermissions(msg.perms, msg.err)
+	}
+	return m, nil
+}
+
+// handlePermissionsKeys handles keys while the permission screen is open
+func (m *listModel) handlePermissionsKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	switch m.permissions.HandleKey(msg.String(), m.height) {
+	case screen.Closed:
+		m.permissions = nil
+		m.permissionsSeq++
+	case screen.Reload:
+		if m.parent.permissionReader != nil {
+			return true, m, m.readPermissionsCmd()
+		}
+		m.permissions.SetPermissions(nil, fmt.Errorf("client not available"))
+	}
+	return true, m, nil
+}
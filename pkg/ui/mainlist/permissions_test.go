@@ -0,0 +1,91 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// deniedStart is the 403 Proxmox returns for a start without VM.PowerMgmt
+var deniedStart = &proxmox.APIError{
+	StatusCode: http.StatusForbidden,
+	Method:     "POST",
+	Path:       "/nodes/pve1/qemu/101/status/start",
+	Body:       `{"data":null,"message":"Permission check failed (/vms/101, VM.PowerMgmt)\n"}`,
+}
+
+func TestPermissions_Screen(t *testing.T) {
+	client := e2eClient()
+	client.Perms = models.Permissions{
+		"/vms":     {"VM.Audit": true, "VM.PowerMgmt": true},
+		"/vms/201": {"VM.Audit": true},
+	}
+	d := newDriver(t, client)
+	d.ml.permissionReader = client
+
+	d.key("P")
+	view := d.ml.model.View()
+	for _, want := range []string{
+		"Token Permissions",
+		"ok      VM.Audit",
+		"MISSING Sys.PowerMgmt              /nodes   node power and wake-on-LAN",
+		"/vms/100   web-1            VM.Audit VM.PowerMgmt",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	d.key("end")
+	if view := d.ml.model.View(); !strings.Contains(view, "/vms/201   backup           MISSING VM.PowerMgmt") {
+		t.Errorf("End should scroll to the last guest:\n%s", view)
+	}
+
+	d.key("esc")
+	if d.ml.model.permissions != nil {
+		t.Fatal("ESC should close the screen")
+	}
+
+	// Without VM.Audit the list stays empty; the status bar says why
+	client.Perms = models.Permissions{"/": {"Sys.Audit": true}}
+	d.key("P")
+	if view := d.ml.model.View(); !strings.Contains(view, "Without VM.Audit on /vms pvec lists nothing") {
+		t.Errorf("Expected the missing essential privilege:\n%s", view)
+	}
+}
+
+func TestPermissions_NotAvailable(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.key("P")
+	if view := d.ml.model.View(); !strings.Contains(view, "Failed to read permissions: client not available") {
+		t.Errorf("Expected the screen to explain it can't read:\n%s", view)
+	}
+}
+
+func TestPermissions_ActionHint(t *testing.T) {
+	client := e2eClient()
+	client.ActionErr = fmt.Errorf("failed to start: %w", deniedStart)
+	d := newDriver(t, client)
+
+	d.key("G", "s")
+	if view := d.ml.model.View(); !strings.Contains(view, "Failed to start 101: the token needs VM.PowerMgmt on /vms/101.") {
+		t.Errorf("Expected the missing privilege in the status bar:\n%s", view)
+	}
+}
+
+func TestPermissions_RefreshHint(t *testing.T) {
+	denied := *deniedStart
+	denied.Method, denied.Path = "GET", "/cluster/resources"
+	denied.Body = `{"data":null,"message":"Permission check failed (/, Sys.Audit)\n"}`
+	ml := NewMainList(Config{Provider: &MockDataProvider{Err: &denied}})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if view := ml.model.View(); !strings.Contains(view, "Permission denied: the token needs Sys.Audit on / — press P for details") {
+		t.Errorf("Expected the missing privilege in the notice:\n%s", view)
+	}
+}
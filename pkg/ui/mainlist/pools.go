@@ -0,0 +1,52 @@
+package mainlist
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/pools"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// handlePoolsKey opens the pool rollup screen
+func (m *listModel) handlePoolsKey() (bool, tea.Model, tea.Cmd) {
+	m.pools = &pools.State{}
+	return true, m, nil
+}
+
+// handlePoolsKeys handles keys while the pool rollup is open: Enter
+// filters the list on the selected pool and goes back to it
+func (m *listModel) handlePoolsKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+	rollup := m.parent.poolRollup()
+	m.pools.Clamp(len(rollup))
+
+	switch m.pools.HandleKey(msg.String(), len(rollup)) {
+	case screen.Closed:
+		m.pools = nil
+	case pools.Filter:
+		m.parent.filter.Pool = rollup[m.pools.Selected].ID
+		m.pools = nil
+		m.rearrange()
+	}
+	return true, m, nil
+}
+
+// renderPools renders the pool rollup from the latest refresh
+func (m *listModel) renderPools() string {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+	rollup := m.parent.poolRollup()
+	m.pools.Clamp(len(rollup))
+	return pools.GetText(*m.pools, rollup, m.width, m.height)
+}
+
+// poolRollup sums the guests by pool, with the comments of the pools read
+// by the last refresh. Must be called with refreshMutex held.
+func (ml *MainList) poolRollup() []models.PoolStats {
+	var list []models.Pool
+	if ml.cluster != nil {
+		list = ml.cluster.Pools
+	}
+	return models.PoolRollup(ml.guests.All(), list)
+}
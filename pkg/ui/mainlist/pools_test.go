@@ -0,0 +1,82 @@
+package mainlist
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+func TestPools_FilterOnPool(t *testing.T) {
+	client := e2eClient()
+	for _, g := range client.Nodes {
+		switch g.VMID {
+		case "100", "102":
+			g.Pool = "prod"
+		case "200":
+			g.Pool = "dev"
+		}
+	}
+	d := newDriver(t, client)
+	d.ml.cluster = &proxmox.RefreshSnapshot{Pools: []models.Pool{
+		{ID: "dev", Comment: "Development"},
+		{ID: "prod", Comment: "Production"},
+		{ID: "spare"},
+	}}
+
+	d.key("p")
+	view := d.ml.model.View()
+	for _, want := range []string{"Pools (4)", "> dev", "Development", "prod", "spare", "(none)"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	d.key("down")
+	d.key("enter")
+	if d.ml.model.pools != nil {
+		t.Fatal("Enter should close the screen")
+	}
+	if d.ml.filter.Pool != "prod" {
+		t.Errorf("Expected the list filtered on prod, got %q", d.ml.filter.Pool)
+	}
+	var listed []string
+	for _, g := range d.ml.sortedNodes {
+		listed = append(listed, g.VMID)
+	}
+	if len(listed) != 2 || !strings.Contains(strings.Join(listed, ","), "100") || !strings.Contains(strings.Join(listed, ","), "102") {
+		t.Errorf("Expected only the guests of prod, got %v", listed)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "pool: prod") {
+		t.Errorf("Expected the title to name the pool:\n%s", view)
+	}
+
+	d.key("p")
+	d.key("end")
+	d.key("enter")
+	if d.ml.filter.Pool != models.NoPool || len(d.ml.sortedNodes) != 2 {
+		t.Errorf("Expected the two guests in no pool, got %q and %d guests", d.ml.filter.Pool, len(d.ml.sortedNodes))
+	}
+
+	d.key("esc")
+	if d.ml.filter.Pool != "" || len(d.ml.sortedNodes) != 5 {
+		t.Errorf("ESC should clear the pool filter, got %q", d.ml.filter.Pool)
+	}
+}
+
+func TestPatchGuest_KeepsPool(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	web1, _ := d.ml.guests.Get("100")
+	web1.Pool = "prod"
+
+	update := *web1
+	update.Pool = ""
+	d.ml.refreshMutex.Lock()
+	d.ml.patchGuest(&update, d.ml.now())
+	d.ml.refreshMutex.Unlock()
+
+	if got, _ := d.ml.guests.Get("100"); got.Pool != "prod" {
+		t.Errorf("A status read without the pool should keep it, got %q", got.Pool)
+	}
+}
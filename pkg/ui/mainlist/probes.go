@@ -0,0 +1,54 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/probe"
+	"github.com/tsupplis/pvec/pkg/ui/eventlog"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// startProbes probes the nodes in the background while a refresh reads
+// the API, and returns a function waiting for the results. Without a
+// prober it returns nil results at once.
+func (ml *MainList) startProbes(ctx context.Context) func() []probe.Result {
+	if ml.prober == nil {
+		return func() []probe.Result { return nil }
+	}
+	done := make(chan []probe.Result, 1)
+	go func() { done <- ml.prober.Run(ctx) }()
+	return func() []probe.Result { return <-done }
+}
+
+// recordProbes keeps the results of a round of probes and logs the nodes
+// that became reachable or unreachable since the last one, returning
+// those changes. Must be called with refreshMutex held.
+func (ml *MainList) recordProbes(results []probe.Result, now time.Time) []models.StateChange {
+	if results == nil {
+		return nil
+	}
+	changes := probe.Changes(ml.probes, results, now)
+	ml.probes = results
+	ml.events = eventlog.Append(ml.events, changes...)
+	return changes
+}
+
+// probeNotice names the nodes whose probe failed in the last round, or
+// returns "" when all answered or none are probed. Must be called with
+// refreshMutex held.
+func (ml *MainList) probeNotice() string {
+	var parts []string
+	for _, r := range ml.probes {
+		if !r.OK() {
+			parts = append(parts, fmt.Sprintf("Node %s (%s) %s", r.Node, r.Addr, probe.Text(r)))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, "; ") + format.Text(" — its network may be degraded even if the API lists it")
+}
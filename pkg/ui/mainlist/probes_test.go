@@ -0,0 +1,84 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/probe"
+)
+
+// fakeDial answers for the addresses in up and refuses the others
+func fakeDial(up map[string]bool) probe.Dialer {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if !up[addr] {
+			return nil, errors.New("dial tcp " + addr + ": connect: connection refused")
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+}
+
+func TestProbes_Refresh(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	up := map[string]bool{"10.0.0.1:22": true, "10.0.0.2:22": true}
+	d.ml.prober = &probe.Prober{
+		Targets: map[string]string{"pve1": "10.0.0.1:22", "pve2": "10.0.0.2:22"},
+		Dial:    fakeDial(up),
+	}
+	var notified []models.StateChange
+	d.ml.onStateChanges = func(changes []models.StateChange) { notified = append(notified, changes...) }
+	refresh := func() {
+		msg, ok := d.ml.timedFetch(context.Background(), time.Second)
+		if !ok {
+			t.Fatal("Expected the refresh to complete")
+		}
+		d.send(msg)
+	}
+
+	refresh()
+	if len(d.ml.probes) != 2 || len(notified) != 0 {
+		t.Fatalf("Expected a first round without changes, got %v and %v", d.ml.probes, notified)
+	}
+	if view := d.ml.model.View(); strings.Contains(view, "unreachable") {
+		t.Errorf("Expected no notice while every node answers:\n%s", view)
+	}
+
+	up["10.0.0.2:22"] = false
+	refresh()
+	if len(notified) != 1 || notified[0].Node != "pve2" || notified[0].NewState != models.StateUnreachable {
+		t.Fatalf("Expected pve2 to become unreachable, got %v", notified)
+	}
+	if last := d.ml.events[len(d.ml.events)-1]; last.Type != models.TypeNode {
+		t.Errorf("Expected the change in the event log, got %v", last)
+	}
+	view := strings.Join(strings.Fields(d.ml.model.View()), " ")
+	if !strings.Contains(view, "Node pve2 (10.0.0.2:22) unreachable: connection refused") {
+		t.Errorf("Expected the failed probe in a notice:\n%s", d.ml.model.View())
+	}
+
+	d.key("n")
+	view = d.ml.model.View()
+	for _, want := range []string{"pve1  size unknown, probe <1ms", "pve2  size unknown, probe unreachable: connection refused [!]"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q in the node summary:\n%s", want, view)
+		}
+	}
+}
+
+func TestProbes_Disabled(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	msg, _ := d.ml.timedFetch(context.Background(), time.Second)
+	if msg.probes != nil {
+		t.Errorf("Expected no probes without a prober, got %v", msg.probes)
+	}
+	d.send(msg)
+	if d.ml.probeNotice() != "" {
+		t.Error("Expected no notice without a prober")
+	}
+}
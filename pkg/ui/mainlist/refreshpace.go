@@ -0,0 +1,81 @@
+package mainlist
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/config"
+)
+
+// refreshPace picks the auto-refresh interval: the active one for a
+// while after an action, a state change or a key press, so an incident is
+// followed closely, and the idle one otherwise
+type refreshPace struct {
+	idle     time.Duration // refresh_interval_idle, or refresh_interval
+	active   time.Duration // refresh_interval_active; 0 keeps the idle interval
+	window   time.Duration // How long activity keeps the active interval
+	activeAt time.Time     // Last activity
+}
+
+// newRefreshPace returns the pace of the intervals; a zero window is the
+// default one
+func newRefreshPace(idle, active, window time.Duration) refreshPace {
+	if window <= 0 {
+		window = config.DefaultRefreshActiveWindow
+	}
+	return refreshPace{idle: idle, active: active, window: window}
+}
+
+// adaptive reports whether the pace ever leaves the idle interval
+func (p refreshPace) adaptive() bool {
+	return p.idle > 0 && p.active > 0 && p.active != p.idle
+}
+
+// isActive reports whether there was activity within the window at now
+func (p refreshPace) isActive(now time.Time) bool {
+	return p.adaptive() && !p.activeAt.IsZero() && now.Sub(p.activeAt) < p.window
+}
+
+// interval returns the refresh interval at now
+func (p refreshPace) interval(now time.Time) time.Duration {
+	if p.isActive(now) {
+		return p.active
+	}
+	return p.idle
+}
+
+// noteActivity records activity at now, switching the auto-refresh to the
+// active interval. Must be called with refreshMutex held.
+func (ml *MainList) noteActivity(now time.Time) {
+	ml.pace.activeAt = now
+	ml.retimeRefresh(now)
+}
+
+// retimeRefresh switches the auto-refresh between the idle and active
+// intervals when due. While backing off the ticker keeps the backoff's
+// interval, which starts from the new one. Must be called with
+// refreshMutex held.
+func (ml *MainList) retimeRefresh(now time.Time) {
+	d := ml.pace.interval(now)
+	if d == ml.refreshInterval || d <= 0 {
+		return
+	}
+	ml.refreshInterval = d
+	ml.backoff.base = d
+	if ml.backoff.failures == 0 {
+		ml.resetTicker(d)
+	}
+}
+
+// paceText tells the auto-refresh interval in effect, when it adapts to
+// activity, or returns an empty string. Must be called with refreshMutex
+// held.
+func (ml *MainList) paceText() string {
+	if ml.refreshTicker == nil || !ml.pace.adaptive() {
+		return ""
+	}
+	if ml.pace.isActive(ml.now()) {
+		return fmt.Sprintf("refresh %s active", ml.refreshInterval)
+	}
+	return fmt.Sprintf("refresh %s", ml.refreshInterval)
+}
@@ -0,0 +1,129 @@
+package mainlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/internal/testutil"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestRefreshPace(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := newRefreshPace(30*time.Second, 2*time.Second, 5*time.Minute)
+	if got := p.interval(now); got != 30*time.Second {
+		t.Errorf("Without activity the idle interval applies, got %v", got)
+	}
+
+	p.activeAt = now
+	for _, tt := range []struct {
+		after time.Duration
+		want  time.Duration
+	}{
+		{0, 2 * time.Second},
+		{4*time.Minute + 59*time.Second, 2 * time.Second},
+		{5 * time.Minute, 30 * time.Second},
+	} {
+		if got := p.interval(now.Add(tt.after)); got != tt.want {
+			t.Errorf("%v after activity: expected %v, got %v", tt.after, tt.want, got)
+		}
+	}
+
+	if p := newRefreshPace(30*time.Second, 0, 0); p.adaptive() || p.window != config.DefaultRefreshActiveWindow {
+		t.Errorf("Without an active interval the pace shouldn't adapt, got %+v", p)
+	}
+	if p := newRefreshPace(0, 2*time.Second, time.Minute); p.adaptive() {
+		t.Error("Without auto-refresh the pace shouldn't adapt")
+	}
+}
+
+// pacedProvider lists the same guests and signals each call
+type pacedProvider struct {
+	calls chan struct{}
+}
+
+func (p *pacedProvider) GetNodes(ctx context.Context) ([]*models.VMStatus, error) {
+	p.calls <- struct{}{}
+	return []*models.VMStatus{{VMID: "100", Name: "web", Type: "qemu", Status: "running", Node: "pve1"}}, nil
+}
+
+// newPacedList returns a list refreshing every 30s, and every 2s for a
+// minute after activity, on a fake clock
+func newPacedList(t *testing.T) (*MainList, *pacedProvider, *testutil.FakeClock) {
+	t.Helper()
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	provider := &pacedProvider{calls: make(chan struct{}, 10)}
+	ml := NewMainList(Config{Provider: provider, RefreshInterval: 30 * time.Second,
+		RefreshActive: 2 * time.Second, ActiveWindow: time.Minute, Clock: clock})
+	t.Cleanup(func() {
+		ml.program.Kill() // Unblocks the refresh handing its result to the program that never ran
+		ml.Stop()
+	})
+	clock.BlockUntil(1)
+	ml.model.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	<-provider.calls
+	return ml, provider, clock
+}
+
+func TestRefreshPace_KeyPress(t *testing.T) {
+	ml, provider, clock := newPacedList(t)
+	if view := ml.model.View(); !strings.Contains(view, "refresh 30s") {
+		t.Errorf("Expected the idle interval in the status bar:\n%s", view)
+	}
+
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if ml.refreshInterval != 2*time.Second {
+		t.Fatalf("A key press should switch to the active interval, got %v", ml.refreshInterval)
+	}
+	if view := ml.model.View(); !strings.Contains(view, "refresh 2s active") {
+		t.Errorf("Expected the active interval in the status bar:\n%s", view)
+	}
+	clock.Advance(2 * time.Second)
+	select {
+	case <-provider.calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a refresh on the active interval")
+	}
+
+	// The first refresh past the window goes back to the idle interval
+	clock.Advance(time.Minute)
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	<-provider.calls
+	if ml.refreshInterval != 30*time.Second {
+		t.Errorf("Expected the idle interval once activity is over, got %v", ml.refreshInterval)
+	}
+}
+
+func TestRefreshPace_StateChange(t *testing.T) {
+	ml, _, clock := newPacedList(t)
+	clock.Advance(10 * time.Minute)
+
+	stopped := &models.VMStatus{VMID: "100", Name: "web", Type: "qemu", Status: "stopped", Node: "pve1"}
+	ml.model.Update(refreshMsg{nodes: []*models.VMStatus{stopped}})
+	if ml.refreshInterval != 2*time.Second {
+		t.Errorf("A state change should switch to the active interval, got %v", ml.refreshInterval)
+	}
+}
+
+func TestRefreshPace_NotAdaptive(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	ml := NewMainList(Config{Provider: &MockDataProvider{}, RefreshInterval: 5 * time.Second, Clock: clock})
+	t.Cleanup(func() {
+		ml.program.Kill()
+		ml.Stop()
+	})
+	ml.model.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyDown})
+
+	if ml.refreshInterval != 5*time.Second {
+		t.Errorf("Without refresh_interval_active the interval shouldn't change, got %v", ml.refreshInterval)
+	}
+	if strings.Contains(ml.model.View(), "refresh 5s") {
+		t.Error("The status bar should only tell an interval that adapts")
+	}
+}
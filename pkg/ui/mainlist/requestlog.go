@@ -0,0 +1,46 @@
+package mainlist
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/ui/requestlog"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// handleRequestLogKey opens the screen of the last API requests. It is a
+// debugging aid, left out of the status bar and the help.
+func (m *listModel) handleRequestLogKey() (bool, tea.Model, tea.Cmd) {
+	if m.parent.requests == nil {
+		return true, m, nil
+	}
+	state := requestlog.New(m.parent.requests.Entries())
+	state.Sources = m.parent.sources()
+	state.Traffic = m.parent.traffic()
+	m.requestLog = &state
+	return true, m, nil
+}
+
+// handleRequestLogKeys handles keys while the request screen is open
+func (m *listModel) handleRequestLogKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	switch m.requestLog.HandleKey(msg.String(), m.width, m.height) {
+	case screen.Closed:
+		m.requestLog = nil
+	case screen.Reload:
+		m.requestLog.SetRequests(m.parent.requests.Entries())
+		m.requestLog.Sources = m.parent.sources()
+		m.requestLog.Traffic = m.parent.traffic()
+	}
+	return true, m, nil
+}
+
+// sources returns what the provider knows of the optional sections, nil
+// when it doesn't say
+func (ml *MainList) sources() []proxmox.Capability {
+	ml.refreshMutex.Lock()
+	current := ml.provider
+	ml.refreshMutex.Unlock()
+	if reporter, ok := current.(FeatureReporter); ok {
+		return reporter.Capabilities()
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package mainlist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+func TestRequestLog_Screen(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	ctrlD := tea.KeyMsg{Type: tea.KeyCtrlD}
+
+	// Without a log the key does nothing
+	d.send(ctrlD)
+	if d.ml.model.requestLog != nil {
+		t.Fatal("The screen needs a request log")
+	}
+
+	d.ml.requests = proxmox.NewRequestLog(10)
+	d.ml.requests.ObserveRequest(proxmox.RequestInfo{Time: time.Now(), Method: "GET", Path: "/cluster/resources?type=vm", Status: 200})
+	d.send(ctrlD)
+	if view := d.ml.model.View(); !strings.Contains(view, "API Requests (1)") || !strings.Contains(view, "/cluster/resources?type=vm") {
+		t.Errorf("Expected the logged request:\n%s", view)
+	}
+
+	// Requests sent since show once reloaded
+	d.ml.requests.ObserveRequest(proxmox.RequestInfo{Time: time.Now(), Method: "POST", Path: "/nodes/pve1/qemu/100/status/start", Err: "request failed"})
+	d.key("r")
+	if view := d.ml.model.View(); !strings.Contains(view, "API Requests (2)") || !strings.Contains(view, "status/start  request failed") {
+		t.Errorf("Expected the new request after reloading:\n%s", view)
+	}
+
+	d.key("enter")
+	if view := d.ml.model.View(); !strings.Contains(view, "Status   : no response") {
+		t.Errorf("Expected the newest request's details:\n%s", view)
+	}
+	d.key("esc", "esc")
+	if d.ml.model.requestLog != nil {
+		t.Error("Esc should go back to the list, then close the screen")
+	}
+}
+
+func TestRequestLog_Sources(t *testing.T) {
+	client := e2eClient()
+	d := newDriver(t, client)
+	d.ml.requests = proxmox.NewRequestLog(10)
+	d.ml.provider = NewConfiguredProvider(&config.Config{Features: map[string]bool{"ha": false}}, client)
+
+	d.send(tea.KeyMsg{Type: tea.KeyCtrlD})
+	if view := d.ml.model.View(); !strings.Contains(view, "Sources: ") || !strings.Contains(view, "off: ha (set in") {
+		t.Errorf("Expected the sources read above the requests:\n%s", view)
+	}
+}
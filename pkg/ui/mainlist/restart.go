@@ -0,0 +1,182 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/redact"
+)
+
+// restartPoll is how often a restart checks whether the guest stopped
+const restartPoll = 2 * time.Second
+
+// restartState is a restart of the selected guest: shutdown, wait until
+// stopped, start. The phases run one command at a time, so ESC cancels
+// between them.
+type restartState struct {
+	seq     int
+	vm      *models.VMStatus
+	action  *actions.RestartAction
+	started time.Time
+	busy    bool  // A request is in flight
+	err     error // Set when the restart couldn't begin
+}
+
+// restartStepMsg reports that a phase of the restart was carried out
+type restartStepMsg struct {
+	seq int
+}
+
+// restartPollMsg ends the wait before the next status check
+type restartPollMsg struct {
+	seq int
+}
+
+// handleRestartKey restarts the selected running guest
+func (m *listModel) handleRestartKey() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	vm := m.parent.selectedGuest()
+	m.parent.refreshMutex.Unlock()
+	if vm == nil || !vm.IsRunning() {
+		return true, m, nil
+	}
+
+	m.restartSeq++
+	r := &restartState{seq: m.restartSeq, vm: vm, started: m.parent.now()}
+	m.restart = r
+	switch {
+	case vm.Locked():
+		r.err = &guestLockedError{lock: vm.Lock}
+		return true, m, nil
+	case vm.NodeOffline:
+		r.err = &nodeOfflineError{node: vm.Node}
+		return true, m, nil
+	case vm.IsUnknown():
+		r.err = &guestUnknownError{node: vm.Node}
+		return true, m, nil
+	case m.parent.executor == nil || m.parent.reader == nil:
+		r.err = fmt.Errorf("client not available")
+		return true, m, nil
+	}
+
+	reader := m.parent.reader
+	status := func(ctx context.Context, key string) (models.NodeState, error) {
+		guest, err := reader.GetGuestStatus(ctx, vm.Node, vm.TypeString(), key)
+		if err != nil {
+			return "", err
+		}
+		return guest.Status, nil
+	}
+	r.action = actions.NewRestartAction(m.parent.executor, status, vm)
+	r.action.Timeout = m.parent.restartTimeout()
+	r.action.Clock = m.parent.clock
+	m.parent.emit(ActionStarted{Action: "restart", VMID: vm.Key()})
+	return true, m, m.restartStepCmd((*actions.RestartAction).Step)
+}
+
+// restartStepCmd runs one phase of the restart, bounded like an action
+func (m *listModel) restartStepCmd(step func(*actions.RestartAction, context.Context) actions.RestartPhase) tea.Cmd {
+	r := m.restart
+	r.busy = true
+	action, seq, timeout := r.action, r.seq, m.parent.actionTimeout()
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		step(action, ctx)
+		return restartStepMsg{seq: seq}
+	}
+}
+
+// handleRestartStep moves on to the next phase: checking again after a
+// pause while the guest shuts down, or starting it once stopped
+func (m *listModel) handleRestartStep(msg restartStepMsg) (tea.Model, tea.Cmd) {
+	r := m.restart
+	if r == nil || r.seq != msg.seq {
+		return m, nil
+	}
+	r.busy = false
+	switch r.action.Phase() {
+	case actions.RestartWaiting:
+		seq := r.seq
+		return m, tea.Tick(restartPoll, func(time.Time) tea.Msg {
+			return restartPollMsg{seq: seq}
+		})
+	case actions.RestartStarting:
+		return m, tea.Batch(m.parent.fetchGuestCmd(r.vm), m.restartStepCmd((*actions.RestartAction).Step))
+	case actions.RestartDone, actions.RestartFailed, actions.RestartCancelled:
+		m.parent.emit(ActionCompleted{Action: "restart", VMID: r.vm.Key(), Err: r.action.Err()})
+		return m, m.parent.fetchGuestCmd(r.vm)
+	}
+	return m, nil
+}
+
+// handleRestartPoll checks again whether the guest stopped
+func (m *listModel) handleRestartPoll(msg restartPollMsg) (tea.Model, tea.Cmd) {
+	r := m.restart
+	if r == nil || r.seq != msg.seq || r.busy || r.action.Phase() != actions.RestartWaiting {
+		return m, nil
+	}
+	return m, m.restartStepCmd((*actions.RestartAction).Step)
+}
+
+// handleRestartKeys handles keys while a restart is shown. ESC cancels
+// before the next phase; when the shutdown timed out, y forces the guest
+// off and n waits longer. Any key dismisses a finished restart.
+func (m *listModel) handleRestartKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	r := m.restart
+	if r.done() {
+		m.restart = nil
+		return true, m, nil
+	}
+	switch msg.String() {
+	case "esc":
+		r.action.Cancel()
+	case "y":
+		if !r.busy && r.action.Phase() == actions.RestartTimedOut {
+			return true, m, m.restartStepCmd((*actions.RestartAction).Escalate)
+		}
+	case "n":
+		if !r.busy && r.action.Phase() == actions.RestartTimedOut {
+			r.action.KeepWaiting()
+			return true, m, m.restartStepCmd((*actions.RestartAction).Step)
+		}
+	}
+	return true, m, nil
+}
+
+// statusText describes the restart's phase for the status bar
+func (r *restartState) statusText(now time.Time) string {
+	if r.err != nil {
+		return fmt.Sprintf("Cannot restart %s: %v. - Press any key", r.vm.Key(), redact.Error(r.err))
+	}
+	elapsed := int(now.Sub(r.started).Seconds())
+	switch r.action.Phase() {
+	case actions.RestartTimedOut:
+		return r.action.Description() + " - force stop? (y/n, ESC to cancel)"
+	case actions.RestartDone, actions.RestartFailed:
+		return redact.String(r.action.Description()) + ". - Press any key"
+	case actions.RestartCancelled:
+		if r.busy {
+			return fmt.Sprintf("Cancelling restart of %s after the current request... %ds", r.vm.Name, elapsed)
+		}
+		return r.action.Description() + ". - Press any key"
+	}
+	return fmt.Sprintf("%s... %ds - ESC to cancel", r.action.Description(), elapsed)
+}
+
+// done reports whether the restart is over and waits to be dismissed
+func (r *restartState) done() bool {
+	return r.err != nil || r.action.Finished() && !r.busy
+}
+
+// restartTimeout returns how long a restart waits for the guest to stop
+func (ml *MainList) restartTimeout() time.Duration {
+	if ml.appConfig != nil && ml.appConfig.RestartTimeout > 0 {
+		return ml.appConfig.RestartTimeout
+	}
+	return actions.DefaultRestartTimeout
+}
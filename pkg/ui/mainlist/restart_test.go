@@ -0,0 +1,152 @@
+package mainlist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// restartDriver selects web-1 (100, running) with a status bar wide
+// enough for the restart's messages
+func restartDriver(t *testing.T, client *MockClient) *driver {
+	t.Helper()
+	d := newDriver(t, client)
+	d.send(tea.WindowSizeMsg{Width: 160, Height: 24})
+	selectGuest(t, d, "100")
+	guest := *client.Nodes[0]
+	client.Guest = &guest
+	return d
+}
+
+// poll delivers the end of the wait between two status checks
+func poll(d *driver) {
+	d.send(restartPollMsg{seq: d.ml.model.restartSeq})
+}
+
+func TestRestart_Phases(t *testing.T) {
+	client := e2eClient()
+	d := restartDriver(t, client)
+
+	d.key("B")
+	if bar := statusBar(d); !strings.Contains(bar, "Waiting for web-1 (100) to shut down... 0s - ESC to cancel") {
+		t.Errorf("Expected the wait after the shutdown:\n%s", bar)
+	}
+	if len(client.ShutDown) != 1 || len(client.Started) != 0 {
+		t.Fatalf("Expected one shutdown so far, got shutdown %v start %v", client.ShutDown, client.Started)
+	}
+
+	poll(d)
+	if bar := statusBar(d); !strings.Contains(bar, "Waiting for web-1 (100)") {
+		t.Errorf("A running guest should be waited for:\n%s", bar)
+	}
+
+	client.Guest.Status = models.StateStopped
+	poll(d)
+	if bar := statusBar(d); !strings.Contains(bar, "Restarted web-1 (100). - Press any key") {
+		t.Errorf("Expected the restart to finish once stopped:\n%s", bar)
+	}
+	if len(client.Started) != 1 || client.Started[0] != "100" {
+		t.Errorf("Expected 100 to be started again, got %v", client.Started)
+	}
+	if len(client.Killed) != 0 {
+		t.Errorf("A clean shutdown needs no force stop, got %v", client.Killed)
+	}
+
+	d.key("z")
+	if bar := statusBar(d); !strings.Contains(bar, "F1 Help") {
+		t.Errorf("Any key should dismiss a finished restart:\n%s", bar)
+	}
+}
+
+func TestRestart_Cancel(t *testing.T) {
+	client := e2eClient()
+	d := restartDriver(t, client)
+
+	d.key("B", "esc")
+	if bar := statusBar(d); !strings.Contains(bar, "Restart of web-1 (100) cancelled. - Press any key") {
+		t.Errorf("ESC should cancel the wait:\n%s", bar)
+	}
+
+	client.Guest.Status = models.StateStopped
+	poll(d)
+	if len(client.Started) != 0 {
+		t.Errorf("A cancelled restart must not start the guest, got %v", client.Started)
+	}
+}
+
+func TestRestart_TimedOut(t *testing.T) {
+	for _, answer := range []string{"y", "n"} {
+		t.Run(answer, func(t *testing.T) {
+			client := e2eClient()
+			d := restartDriver(t, client)
+			d.ml.appConfig = &config.Config{RestartTimeout: time.Minute}
+
+			d.key("B")
+			d.clock.Advance(time.Minute)
+			poll(d)
+			if bar := statusBar(d); !strings.Contains(bar, "web-1 (100) still running after 1m0s - force stop? (y/n, ESC to cancel)") {
+				t.Fatalf("Expected the force stop prompt:\n%s", bar)
+			}
+
+			d.key("x") // Other keys leave the question open
+			d.ml.model.restart.action.Timeout = time.Hour
+			d.key(answer)
+			if answer == "y" {
+				if len(client.Killed) != 1 {
+					t.Errorf("y should force the guest off, got %v", client.Killed)
+				}
+			} else if len(client.Killed) != 0 {
+				t.Errorf("n should keep waiting, got %v", client.Killed)
+			}
+
+			client.Guest.Status = models.StateStopped
+			poll(d)
+			if len(client.Started) != 1 {
+				t.Errorf("Expected the guest to be started once stopped, got %v", client.Started)
+			}
+		})
+	}
+}
+
+func TestRestart_Refused(t *testing.T) {
+	t.Run("stopped", func(t *testing.T) {
+		client := e2eClient()
+		d := newDriver(t, client)
+		selectGuest(t, d, "101")
+
+		d.key("B")
+		if d.ml.model.restart != nil || len(client.ShutDown) != 0 {
+			t.Error("A stopped guest has nothing to restart")
+		}
+	})
+
+	t.Run("locked", func(t *testing.T) {
+		client := lockedClient()
+		d := restartDriver(t, client)
+
+		d.key("B")
+		want := "Cannot restart 100: locked (backup); U in details clears it. - Press any key"
+		if bar := statusBar(d); !strings.Contains(bar, want) {
+			t.Errorf("Expected %q:\n%s", want, bar)
+		}
+		if len(client.ShutDown) != 0 {
+			t.Errorf("A locked guest must not be shut down, got %v", client.ShutDown)
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		client := e2eClient()
+		client.ActionErr = errors.New("permission denied")
+		d := restartDriver(t, client)
+
+		d.key("B")
+		if bar := statusBar(d); !strings.Contains(bar, "Restart of web-1 (100) failed: shutdown: permission denied. - Press any key") {
+			t.Errorf("Expected the failure:\n%s", bar)
+		}
+	})
+}
@@ -0,0 +1,245 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/state"
+	"github.com/tsupplis/pvec/pkg/ui/schedule"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// scheduledResultMsg reports how a scheduled action went
+type scheduledResultMsg struct {
+	id  int
+	vm  *models.VMStatus
+	err error
+}
+
+// rearmScheduled warns about the actions that fell due while pvec was
+// closed. They run after the first refresh, like any due action.
+func (m *listModel) rearmScheduled(now time.Time) {
+	if m.parent.stateFile == nil {
+		return
+	}
+	switch late := len(m.parent.stateFile.Rearm(now)); {
+	case late == 1:
+		m.scheduleNotice = "1 scheduled action overdue, running now - L to review"
+	case late > 1:
+		m.scheduleNotice = fmt.Sprintf("%d scheduled actions overdue, running now - L to review", late)
+	}
+}
+
+// handleScheduleKey opens the schedule prompt for the selected guest
+func (m *listModel) handleScheduleKey() (bool, tea.Model, tea.Cmd) {
+	if m.parent.stateFile == nil {
+		return false, m, nil
+	}
+	m.parent.refreshMutex.Lock()
+	vm := m.parent.selectedGuest()
+	m.parent.refreshMutex.Unlock()
+	if vm == nil {
+		return true, m, nil
+	}
+	prompt := schedule.NewPrompt(vm)
+	m.schedulePrompt = &prompt
+	return true, m, nil
+}
+
+// handleSchedulePromptKeys handles keys while the schedule prompt is
+// open. Once scheduled, the list of scheduled actions shows the new one.
+func (m *listModel) handleSchedulePromptKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	p := m.schedulePrompt
+	now := m.parent.now()
+	switch p.HandleKey(msg, now) {
+	case screen.Closed:
+		m.schedulePrompt = nil
+	case screen.Confirmed:
+		vm := p.Guest
+		scheduled, err := m.parent.stateFile.Schedule(state.ScheduledAction{
+			Action:  p.Action,
+			VMID:    vm.VMID,
+			Cluster: vm.Cluster,
+			Name:    vm.Name,
+			Node:    vm.Node,
+			Type:    vm.Type,
+			At:      p.At,
+			Created: now,
+		})
+		m.schedulePrompt = nil
+		m.openScheduleList()
+		for i, a := range m.parent.stateFile.Scheduled() {
+			if a.ID == scheduled.ID {
+				m.scheduleList.Selected = i
+			}
+		}
+		m.scheduleList.Notice = fmt.Sprintf("The %s of %s (%s) runs at %s",
+			p.Action, vm.Name, vm.Key(), schedule.When(p.At, now))
+		if err != nil {
+			m.scheduleList.Notice = fmt.Sprintf("Scheduled for this session only: %v", redact.Error(err))
+		}
+	}
+	return true, m, nil
+}
+
+// handleScheduleListKey opens the list of scheduled actions
+func (m *listModel) handleScheduleListKey() (bool, tea.Model, tea.Cmd) {
+	if m.parent.stateFile == nil {
+		return false, m, nil
+	}
+	m.openScheduleList()
+	return true, m, nil
+}
+
+// openScheduleList shows the scheduled actions, which reviews any notice
+// about them
+func (m *listModel) openScheduleList() {
+	m.scheduleList = &schedule.List{}
+	m.scheduleNotice = ""
+}
+
+// handleScheduleListKeys handles keys while the scheduled actions are
+// listed; x cancels the selected pending action after confirmation
+func (m *listModel) handleScheduleListKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	l := m.scheduleList
+	switch l.HandleKey(msg.String(), m.parent.stateFile.Scheduled()) {
+	case screen.Closed:
+		m.scheduleList = nil
+	case screen.Confirmed:
+		target := l.Target
+		l.Target = nil
+		switch err := m.unschedule(target.ID); {
+		case err != nil:
+			l.Notice = fmt.Sprintf("Cannot cancel the %s of %s: %v", target.Action, target.Key(), redact.Error(err))
+		default:
+			l.Notice = fmt.Sprintf("Cancelled the %s of %s (%s)", target.Action, target.Name, target.Key())
+		}
+		l.Clamp(m.parent.stateFile.Scheduled())
+	}
+	return true, m, nil
+}
+
+// unschedule cancels a pending action, unless it is already running
+func (m *listModel) unschedule(id int) error {
+	if m.scheduleBusy[id] {
+		return fmt.Errorf("it is running")
+	}
+	return m.parent.stateFile.Unschedule(id)
+}
+
+// runDueCmd runs the scheduled actions whose time has come. It waits for
+// the first refresh, which lists the guests the actions run on.
+func (m *listModel) runDueCmd() tea.Cmd {
+	ml := m.parent
+	ml.refreshMutex.Lock()
+	loaded := ml.loaded
+	ml.refreshMutex.Unlock()
+	if ml.stateFile == nil || !loaded {
+		return nil
+	}
+
+	var cmds []tea.Cmd
+	for _, a := range ml.stateFile.Due(ml.now()) {
+		if m.scheduleBusy[a.ID] {
+			continue
+		}
+		if m.scheduleBusy == nil {
+			m.scheduleBusy = make(map[int]bool)
+		}
+		m.scheduleBusy[a.ID] = true
+		ml.emit(ActionStarted{Action: a.Action, VMID: a.Key(), Scheduled: true})
+		cmds = append(cmds, ml.scheduledCmd(a))
+	}
+	return tea.Batch(cmds...)
+}
+
+// scheduledCmd runs a scheduled action through the executor, like the
+// action keys do, on the guest as last refreshed
+func (ml *MainList) scheduledCmd(a state.ScheduledAction) tea.Cmd {
+	ml.refreshMutex.Lock()
+	vm, listed := ml.guests.Get(a.Key())
+	ml.refreshMutex.Unlock()
+	timeout := ml.actionTimeout()
+	// Nobody is there to answer, so snapshot_before applies as set
+	snapshot := ml.snapshots != nil && ml.appConfig != nil && ml.appConfig.SnapshotsBefore(a.Action)
+
+	// The action is built here, on the clients of the moment, and
+	// executed by the command
+	var action actions.Action
+	var err error
+	switch {
+	case !listed:
+		err = fmt.Errorf("guest %s is not listed", a.Key())
+	case ml.executor == nil:
+		err = fmt.Errorf("client not available")
+	case vm.Locked():
+		err = &guestLockedError{lock: vm.Lock}
+	case vm.NodeOffline:
+		err = &nodeOfflineError{node: vm.Node}
+	case vm.IsUnknown():
+		err = &guestUnknownError{node: vm.Node}
+	default:
+		action, err = newAction(a.Action, ml.executor, vm)
+	}
+	if err == nil && snapshot {
+		action = ml.snapshotFirst(action, vm)
+	}
+
+	return func() tea.Msg {
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			err = guarded(func() error { return action.Execute(ctx) })
+		}
+		return scheduledResultMsg{id: a.ID, vm: vm, err: err}
+	}
+}
+
+// handleScheduledResult records how a scheduled action went in the state
+// file. A failure stays in the status bar until reviewed with L.
+func (m *listModel) handleScheduledResult(msg scheduledResultMsg) (tea.Model, tea.Cmd) {
+	delete(m.scheduleBusy, msg.id)
+	msg.err = m.parent.logPanic(msg.err)
+	m.parent.refreshMutex.Lock()
+	m.parent.noteActivity(m.parent.now())
+	m.parent.refreshMutex.Unlock()
+	var ran state.ScheduledAction
+	for _, a := range m.parent.stateFile.Scheduled() {
+		if a.ID == msg.id {
+			ran = a
+		}
+	}
+
+	m.parent.emit(ActionCompleted{Action: ran.Action, VMID: ran.Key(), Scheduled: true, Err: msg.err})
+	if err := m.parent.stateFile.Finish(msg.id, m.parent.now(), msg.err); err != nil {
+		m.scheduleNotice = fmt.Sprintf("Failed to save the scheduled actions: %v", redact.Error(err))
+	} else if msg.err != nil {
+		m.scheduleNotice = fmt.Sprintf("Scheduled %s of %s failed - L to review", ran.Action, ran.Key())
+	}
+	if msg.err != nil || msg.vm == nil {
+		return m, nil
+	}
+	return m, m.parent.fetchGuestCmd(msg.vm)
+}
+
+// scheduleText is the status bar's note on the scheduled actions: a
+// notice until reviewed, or the time left before the next one
+func (m *listModel) scheduleText() string {
+	if m.scheduleNotice != "" || m.parent.stateFile == nil {
+		return m.scheduleNotice
+	}
+	next, ok := m.parent.stateFile.Next()
+	if !ok {
+		return ""
+	}
+	left := next.At.Sub(m.parent.now())
+	if left <= 0 {
+		return fmt.Sprintf("%s %s due", next.Action, next.Key())
+	}
+	return fmt.Sprintf("%s %s in %s", next.Action, next.Key(), schedule.Countdown(left))
+}
@@ -0,0 +1,178 @@
+package mainlist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/state"
+)
+
+// scheduleDriver boots a list with an in-memory state file and a status
+// bar wide enough for the countdown
+func scheduleDriver(t *testing.T, client *MockClient) *driver {
+	t.Helper()
+	d := newDriver(t, client)
+	f, err := state.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.ml.stateFile = f
+	d.send(tea.WindowSizeMsg{Width: 160, Height: 24})
+	return d
+}
+
+// at moves the driver's clock to e2eNow plus d
+func at(d *driver, offset time.Duration) {
+	d.clock.Set(e2eNow.Add(offset))
+}
+
+// typeKeys types text into whatever has the focus
+func typeKeys(d *driver, text string) {
+	for _, r := range text {
+		d.key(string(r))
+	}
+}
+
+func TestSchedule_RunsWhenDue(t *testing.T) {
+	client := e2eClient()
+	d := scheduleDriver(t, client)
+	selectGuest(t, d, "100")
+
+	d.key("@")
+	if view := d.ml.model.View(); !strings.Contains(view, "Schedule Action - web-1 (100)") {
+		t.Fatalf("@ should open the schedule prompt:\n%s", view)
+	}
+	typeKeys(d, "in 1h")
+	d.key("enter")
+	if view := d.ml.model.View(); !strings.Contains(view, "The shutdown of web-1 (100) runs at 13:00") {
+		t.Errorf("Expected the new action in the list:\n%s", view)
+	}
+
+	d.key("esc")
+	if bar := statusBar(d); !strings.Contains(bar, "| shutdown 100 in 1h 00m") {
+		t.Errorf("Expected the countdown in the status bar:\n%s", bar)
+	}
+
+	at(d, 59*time.Minute)
+	d.send(tickMsg{})
+	if len(client.ShutDown) != 0 {
+		t.Fatalf("Nothing should run before its time, got %v", client.ShutDown)
+	}
+
+	at(d, time.Hour)
+	d.send(tickMsg{})
+	if len(client.ShutDown) != 1 || client.ShutDown[0] != "100" {
+		t.Fatalf("Expected 100 to be shut down, got %v", client.ShutDown)
+	}
+	d.send(tickMsg{})
+	if len(client.ShutDown) != 1 {
+		t.Errorf("A scheduled action runs once, got %v", client.ShutDown)
+	}
+
+	ran := d.ml.stateFile.Scheduled()[0]
+	if ran.Pending() || ran.Error != "" {
+		t.Errorf("Expected the outcome to be recorded, got %+v", ran)
+	}
+	if bar := statusBar(d); strings.Contains(bar, "shutdown 100") {
+		t.Errorf("No countdown once nothing is pending:\n%s", bar)
+	}
+}
+
+func TestSchedule_Failure(t *testing.T) {
+	client := e2eClient()
+	client.ActionErr = errors.New("permission denied")
+	d := scheduleDriver(t, client)
+	selectGuest(t, d, "100")
+
+	d.key("@")
+	typeKeys(d, "5m")
+	d.key("enter", "esc")
+	at(d, 5*time.Minute)
+	d.send(tickMsg{})
+
+	if bar := statusBar(d); !strings.Contains(bar, "| Scheduled shutdown of 100 failed - L to review") {
+		t.Errorf("Expected the failure in the status bar:\n%s", bar)
+	}
+	d.key("L")
+	if view := d.ml.model.View(); !strings.Contains(view, "failed at 12:05: permission denied") {
+		t.Errorf("Expected the failure in the list:\n%s", view)
+	}
+	d.key("esc")
+	if bar := statusBar(d); strings.Contains(bar, "failed") {
+		t.Errorf("Reviewing the list should clear the notice:\n%s", bar)
+	}
+}
+
+func TestSchedule_Cancel(t *testing.T) {
+	client := e2eClient()
+	d := scheduleDriver(t, client)
+	selectGuest(t, d, "101")
+
+	d.key("@")
+	if !strings.Contains(d.ml.model.View(), "[Start]") {
+		t.Errorf("A stopped guest should default to a start:\n%s", d.ml.model.View())
+	}
+	typeKeys(d, "13:00")
+	d.key("enter", "x")
+	if bar := statusBar(d); !strings.Contains(bar, "Cancel the start of web-2 (101) at 13:00? (y/n)") {
+		t.Errorf("Expected the cancel to be confirmed first:\n%s", bar)
+	}
+	d.key("y")
+	if bar := statusBar(d); !strings.Contains(bar, "Cancelled the start of web-2 (101)") {
+		t.Errorf("Expected the cancel to be reported:\n%s", bar)
+	}
+
+	at(d, 2*time.Hour)
+	d.send(tickMsg{})
+	if len(client.Started) != 0 || len(d.ml.stateFile.Scheduled()) != 0 {
+		t.Errorf("A cancelled action must not run, got %v", client.Started)
+	}
+}
+
+func TestSchedule_Overdue(t *testing.T) {
+	client := e2eClient()
+	d := scheduleDriver(t, client)
+	_, err := d.ml.stateFile.Schedule(state.ScheduledAction{Action: "start", VMID: "101", Name: "web-2", At: e2eNow.Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.ml.model.rearmScheduled(e2eNow)
+	if bar := statusBar(d); !strings.Contains(bar, "| 1 scheduled action overdue, running now - L to review") {
+		t.Errorf("Expected a warning about the overdue action:\n%s", bar)
+	}
+
+	d.send(tickMsg{})
+	if len(client.Started) != 1 || client.Started[0] != "101" {
+		t.Errorf("An overdue action should run at once, got %v", client.Started)
+	}
+	d.key("L")
+	if view := d.ml.model.View(); !strings.Contains(view, "ran at 12:00 (late)") {
+		t.Errorf("Expected the action to be marked late:\n%s", view)
+	}
+}
+
+func TestSchedule_WaitsForGuests(t *testing.T) {
+	client := e2eClient()
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client})
+	ml.stateFile, _ = state.Open("")
+	if _, err := ml.stateFile.Schedule(state.ScheduledAction{Action: "start", VMID: "101", At: e2eNow}); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd := ml.model.runDueCmd(); cmd != nil {
+		t.Error("Nothing should run before the first refresh lists the guests")
+	}
+}
+
+func TestSchedule_Disabled(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.key("@")
+	d.key("L")
+	if d.ml.model.schedulePrompt != nil || d.ml.model.scheduleList != nil {
+		t.Error("Without a state file there is nothing to schedule")
+	}
+}
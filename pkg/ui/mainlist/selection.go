@@ -0,0 +1,101 @@
+package mainlist
+
+import "github.com/tsupplis/pvec/pkg/models"
+
+// selectionMsg repaints the list after the selection was changed from
+// outside the update loop
+type selectionMsg struct{}
+
+// SelectVMID selects the listed guest whose Key is key (its VMID, or
+// cluster/vmid when several clusters are listed) and scrolls it into
+// view. It returns false, leaving the selection alone, when no guest
+// shown has that key, filtered out ones included. It can be called from
+// any goroutine.
+func (ml *MainList) SelectVMID(key string) bool {
+	ml.refreshMutex.Lock()
+	ok := ml.model.selectKey(key)
+	if ok {
+		ml.follow = ""
+	}
+	ml.refreshMutex.Unlock()
+	if ok {
+		ml.repaint()
+	}
+	return ok
+}
+
+// FollowVMID pins the selection to the guest whose Key is key: the cursor
+// stays on it when refreshes, sorting or filtering move it, and goes back
+// to it when it is listed again, until the user moves the cursor. An
+// empty key unpins the selection. It reports whether the guest is listed
+// now, and can be called from any goroutine.
+func (ml *MainList) FollowVMID(key string) bool {
+	ml.refreshMutex.Lock()
+	ml.follow = key
+	ok := key != "" && ml.model.selectKey(key)
+	ml.refreshMutex.Unlock()
+	if ok {
+		ml.repaint()
+	}
+	return ok
+}
+
+// SelectedVMID returns the Key of the selected guest, "" when the list is
+// empty. Refreshes keep the selection on the same guest while it is
+// listed, whatever its new position.
+func (ml *MainList) SelectedVMID() string {
+	ml.refreshMutex.Lock()
+	defer ml.refreshMutex.Unlock()
+	if vm := ml.selectedGuest(); vm != nil {
+		return vm.Key()
+	}
+	return ""
+}
+
+// repaint has the running program draw the new selection; the selection
+// event is sent from the update loop as for any other change
+func (ml *MainList) repaint() {
+	if ml.running.Load() {
+		go ml.program.Send(selectionMsg{})
+	}
+}
+
+// selectKey moves the cursor to the guest whose Key is key, scrolling it
+// into view, and reports whether it is listed. Must be called with
+// refreshMutex held.
+func (m *listModel) selectKey(key string) bool {
+	for i, node := range m.parent.sortedNodes {
+		if node.Key() != key {
+			continue
+		}
+		m.cursorPosition = i
+		m.parent.selectedIdx = i
+		visibleRows := max(m.height-4, 1)
+		if i < m.scrollOffset {
+			m.scrollOffset = i
+		} else if i >= m.scrollOffset+visibleRows {
+			m.scrollOffset = i - visibleRows + 1
+		}
+		return true
+	}
+	return false
+}
+
+// arrange re-sorts and filters nodes into the list, keeping the cursor on
+// the followed guest, else on the guest it was on, else at the same
+// position. Must be called with refreshMutex held.
+func (m *listModel) arrange(nodes []*models.VMStatus) {
+	ml := m.parent
+	var selected string
+	if vm := ml.selectedGuest(); vm != nil {
+		selected = vm.Key()
+	}
+	ml.sortedNodes = arrangeNodes(nodes, ml.less(ml.activeSort()), ml.filter, ml.nics, ml.hostnames, ml.owners, ml.flags)
+	ml.duplicates = models.DuplicateNames(nodes)
+	for _, key := range []string{ml.follow, selected} {
+		if key != "" && m.selectKey(key) {
+			return
+		}
+	}
+	m.clampCursor()
+}
@@ -0,0 +1,126 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestSelection_KeptThroughReorderingRefresh(t *testing.T) {
+	client := e2eClient()
+	d := newDriver(t, client)
+	selectGuest(t, d, "100")
+	before := d.ml.selectedIdx
+
+	// Renamed, web-1 sorts first among the VMs, after the two CTs
+	client.Nodes[0].Name = "alpha"
+	d.send(d.ml.fetchNodes(context.Background()))
+	if got := d.ml.SelectedVMID(); got != "100" {
+		t.Errorf("Expected the selection to stay on 100, got %s", got)
+	}
+	if d.ml.selectedIdx == before || d.ml.selectedIdx != 2 {
+		t.Errorf("Expected the cursor to move with 100 to row 2, got row %d", d.ml.selectedIdx)
+	}
+
+	// Gone: the cursor keeps its row
+	client.Nodes = client.Nodes[1:]
+	d.send(d.ml.fetchNodes(context.Background()))
+	if got := d.ml.SelectedVMID(); got == "" || got == "100" {
+		t.Errorf("Expected another guest selected once 100 is gone, got %q", got)
+	}
+}
+
+func TestSelectVMID(t *testing.T) {
+	client := e2eClient()
+	for i := 1; i <= 4; i++ {
+		client.Nodes = append(client.Nodes, &models.VMStatus{VMID: fmt.Sprintf("30%d", i), VMIDNum: 300 + i,
+			Name: fmt.Sprintf("app-%d", i), Type: "qemu", Status: "stopped", Node: "pve1"})
+	}
+	d := newDriver(t, client)
+	d.send(tea.WindowSizeMsg{Width: 80, Height: 10}) // Six of nine rows visible
+
+	if !d.ml.SelectVMID("101") {
+		t.Fatal("Expected 101 to be selected")
+	}
+	if got := d.ml.SelectedVMID(); got != "101" {
+		t.Errorf("Expected 101, got %s", got)
+	}
+	// web-2 is the last row, so the list scrolls to show it
+	if view := d.ml.model.View(); !strings.Contains(view, "web-2") || strings.Contains(view, "backup") {
+		t.Errorf("Expected 101 scrolled into view:\n%s", view)
+	}
+
+	if d.ml.SelectVMID("999") {
+		t.Error("An unknown guest can't be selected")
+	}
+	d.ml.filter = listFilter{Filter: Filter{Node: "pve1"}}
+	d.send(d.ml.fetchNodes(context.Background()))
+	if d.ml.SelectVMID("102") {
+		t.Error("A filtered out guest can't be selected")
+	}
+	if got := d.ml.SelectedVMID(); got != "101" {
+		t.Errorf("A failed selection leaves it alone, got %s", got)
+	}
+}
+
+func TestFollowVMID(t *testing.T) {
+	client := e2eClient()
+	d := newDriver(t, client)
+
+	if !d.ml.FollowVMID("102") {
+		t.Fatal("Expected 102 to be followed")
+	}
+
+	// Filtered out, then shown again: the cursor goes back to it
+	d.ml.filter = listFilter{Filter: Filter{Node: "pve1"}}
+	d.send(d.ml.fetchNodes(context.Background()))
+	if got := d.ml.SelectedVMID(); got == "102" {
+		t.Fatalf("102 is filtered out, yet selected")
+	}
+	d.key("esc")
+	if got := d.ml.SelectedVMID(); got != "102" {
+		t.Errorf("Expected the cursor back on the followed 102, got %s", got)
+	}
+
+	// Moving by hand ends the follow
+	d.key("down")
+	moved := d.ml.SelectedVMID()
+	d.ml.filter = listFilter{Filter: Filter{Node: "pve1"}}
+	d.send(d.ml.fetchNodes(context.Background()))
+	d.key("esc")
+	if got := d.ml.SelectedVMID(); got == "102" || d.ml.follow != "" {
+		t.Errorf("Expected the follow to end on a cursor move (then on %s), got %s", moved, got)
+	}
+
+	if d.ml.FollowVMID("999") {
+		t.Error("An unlisted guest is not selected, though followed")
+	}
+	d.ml.FollowVMID("")
+	if d.ml.follow != "" {
+		t.Error("An empty key should unpin the selection")
+	}
+}
+
+func TestSelection_Concurrent(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			d.ml.SelectVMID("200")
+			d.ml.FollowVMID("100")
+			_ = d.ml.SelectedVMID()
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		d.send(d.ml.fetchNodes(context.Background()))
+		d.key("down")
+	}
+	wg.Wait()
+}
@@ -0,0 +1,168 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+	"github.com/tsupplis/pvec/pkg/ui/serialconsole"
+)
+
+// serialReadSize is the most console output read at once
+const serialReadSize = 4096
+
+// serialPortsMsg carries the serial ports of the VM whose console opens
+type serialPortsMsg struct {
+	seq   int
+	vm    *models.VMStatus
+	ports []string
+	err   error
+}
+
+// serialOpenedMsg carries the console stream once connected
+type serialOpenedMsg struct {
+	seq    int
+	stream io.ReadCloser
+	err    error
+}
+
+// serialOutputMsg carries console output, or why there is no more
+type serialOutputMsg struct {
+	seq  int
+	data []byte
+	err  error
+}
+
+// handleSerialKey opens the serial console of the selected VM. Its config
+// tells whether it has a serial port to follow.
+func (m *listModel) handleSerialKey() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	vm := m.parent.selectedGuest()
+	m.parent.refreshMutex.Unlock()
+	if vm == nil {
+		return true, m, nil
+	}
+	state := serialconsole.New(fmt.Sprintf("%s (%s)", vm.Name, vm.Key()), vm.VMID)
+	m.serial = &state
+	m.serialSeq++
+
+	reader, client := m.parent.reader, m.parent.serialConsole
+	switch {
+	case vm.Type != models.TypeVM:
+		m.serial.NotVM = true
+	case reader == nil || client == nil:
+		m.serial.Err = fmt.Errorf("client not available")
+	case vm.NodeOffline:
+		m.serial.Err = &nodeOfflineError{node: vm.Node}
+	}
+	if m.serial.NotVM || m.serial.Err != nil {
+		m.serial.Connecting = false
+		return true, m, nil
+	}
+
+	seq := m.serialSeq
+	return true, m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+		defer cancel()
+		config, err := reader.GetVMConfig(ctx, vm.Node, vm.TypeString(), vm.Key())
+		return serialPortsMsg{seq: seq, vm: vm, ports: configparse.SerialPorts(config), err: err}
+	}
+}
+
+// handleSerialPorts connects to the first serial port, or shows how to add
+// one to a VM without
+func (m *listModel) handleSerialPorts(msg serialPortsMsg) (tea.Model, tea.Cmd) {
+	if m.serial == nil || msg.seq != m.serialSeq {
+		return m, nil
+	}
+	switch {
+	case msg.err != nil:
+		m.serial.Connecting = false
+		m.serial.Err = msg.err
+		return m, nil
+	case len(msg.ports) == 0:
+		m.serial.Connecting = false
+		m.serial.NoSerial = true
+		return m, nil
+	}
+
+	m.serial.Port = msg.ports[0]
+	client, seq, port, vm := m.parent.serialConsole, m.serialSeq, m.serial.Port, msg.vm
+	ctx, cancel := context.WithCancel(context.Background())
+	m.serialCancel = cancel
+	return m, func() tea.Msg {
+		stream, err := client.OpenSerialConsole(ctx, vm.Node, vm.Key(), port)
+		return serialOpenedMsg{seq: seq, stream: stream, err: err}
+	}
+}
+
+// handleSerialOpened starts reading the console
+func (m *listModel) handleSerialOpened(msg serialOpenedMsg) (tea.Model, tea.Cmd) {
+	if m.serial == nil || msg.seq != m.serialSeq {
+		if msg.stream != nil {
+			msg.stream.Close() // Closed while connecting
+		}
+		return m, nil
+	}
+	m.serial.Connecting = false
+	m.serial.Err = msg.err
+	if msg.err != nil {
+		return m, nil
+	}
+	m.serialStream = msg.stream
+	return m, readSerialCmd(msg.stream, msg.seq)
+}
+
+// readSerialCmd waits for the next console output
+func readSerialCmd(stream io.Reader, seq int) tea.Cmd {
+	return func() tea.Msg {
+		buf := make([]byte, serialReadSize)
+		n, err := stream.Read(buf)
+		return serialOutputMsg{seq: seq, data: buf[:n], err: err}
+	}
+}
+
+// handleSerialOutput shows console output and reads on until the
+// connection ends
+func (m *listModel) handleSerialOutput(msg serialOutputMsg) (tea.Model, tea.Cmd) {
+	if m.serial == nil || msg.seq != m.serialSeq {
+		return m, nil
+	}
+	m.serial.Append(msg.data, m.height)
+	switch {
+	case errors.Is(msg.err, io.EOF):
+		m.serial.Ended = true
+		return m, nil
+	case msg.err != nil:
+		m.serial.Err = msg.err
+		return m, nil
+	}
+	return m, readSerialCmd(m.serialStream, msg.seq)
+}
+
+// handleSerialKeys handles keys while the serial console is open
+func (m *listModel) handleSerialKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	if m.serial.HandleKey(msg.String(), m.height) == screen.Closed {
+		m.closeSerial()
+	}
+	return true, m, nil
+}
+
+// closeSerial closes the console screen and disconnects
+func (m *listModel) closeSerial() {
+	m.serial = nil
+	m.serialSeq++ // Drops the replies still in flight
+	if m.serialCancel != nil {
+		m.serialCancel()
+		m.serialCancel = nil
+	}
+	if m.serialStream != nil {
+		m.serialStream.Close()
+		m.serialStream = nil
+	}
+}
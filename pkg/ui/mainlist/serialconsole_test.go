@@ -0,0 +1,94 @@
+package mainlist
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSerial replays console output, once per connection
+type fakeSerial struct {
+	output string
+	ports  []string // Serial ports asked for
+}
+
+func (f *fakeSerial) OpenSerialConsole(ctx context.Context, node, vmid, serial string) (io.ReadCloser, error) {
+	f.ports = append(f.ports, vmid+"/"+serial)
+	return io.NopCloser(strings.NewReader(f.output)), nil
+}
+
+func TestSerialConsole(t *testing.T) {
+	client := e2eClient()
+	client.Configs = map[string]map[string]interface{}{"100": {"serial0": "socket"}}
+	serial := &fakeSerial{output: "Booting from disk...\r\nLoading kernel\r\n"}
+	d := restartDriver(t, client)
+	d.ml.serialConsole = serial
+
+	d.key("C")
+	if len(serial.ports) != 1 || serial.ports[0] != "100/serial0" {
+		t.Fatalf("Expected the console of serial0 followed, got %v", serial.ports)
+	}
+	view := d.ml.model.View()
+	for _, want := range []string{"Serial Console - web-1 (100) serial0", " Booting from disk...", " Loading kernel", "Console closed by the server"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	d.key("esc")
+	if d.ml.model.serial != nil {
+		t.Error("ESC should close the console")
+	}
+}
+
+func TestSerialConsole_NoSerialPort(t *testing.T) {
+	client := e2eClient()
+	serial := &fakeSerial{}
+	d := restartDriver(t, client)
+	d.ml.serialConsole = serial
+
+	d.key("C")
+	if len(serial.ports) != 0 {
+		t.Errorf("A VM without a serial port has no console to open, got %v", serial.ports)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "qm set 100 -serial0 socket") {
+		t.Errorf("Expected setup instructions:\n%s", view)
+	}
+}
+
+func TestSerialConsole_Container(t *testing.T) {
+	d := restartDriver(t, e2eClient())
+	d.ml.serialConsole = &fakeSerial{}
+	selectGuest(t, d, "200")
+
+	d.key("C")
+	if view := d.ml.model.View(); !strings.Contains(view, "Containers have no serial console") {
+		t.Errorf("Expected containers to be told apart:\n%s", view)
+	}
+}
+
+func TestSerialConsole_WaitsForRefresh(t *testing.T) {
+	d := restartDriver(t, e2eClient())
+	d.ml.serialConsole = &fakeSerial{}
+
+	// A refresh holding the list must be waited out before the selection
+	// is read
+	d.ml.refreshMutex.Lock()
+	opened := make(chan struct{})
+	go func() {
+		d.ml.model.handleSerialKey()
+		close(opened)
+	}()
+	select {
+	case <-opened:
+		t.Error("Expected the key to wait for the refresh to release the list")
+	case <-time.After(50 * time.Millisecond):
+	}
+	d.ml.refreshMutex.Unlock()
+	<-opened
+	if d.ml.model.serial == nil {
+		t.Error("Expected the console to open once the refresh is done")
+	}
+}
@@ -0,0 +1,67 @@
+package mainlist
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// serverCapsMsg carries what the server's release offers
+type serverCapsMsg struct {
+	caps proxmox.ServerCapabilities
+}
+
+// serverCapsCmd reads the server version once per client. A failed read
+// leaves the capabilities unknown, which refuses nothing.
+func (ml *MainList) serverCapsCmd() tea.Cmd {
+	inspector := ml.serverInspector
+	if inspector == nil {
+		return nil
+	}
+	ctx := ml.ctx
+	return func() tea.Msg {
+		caps, err := inspector.ServerCapabilities(ctx)
+		if err != nil || !caps.Version.Known() {
+			return nil
+		}
+		return serverCapsMsg{caps: caps}
+	}
+}
+
+// handleServerCaps keeps the capabilities, announcing a release newer
+// than pvec knows until dismissed with x
+func (m *listModel) handleServerCaps(msg serverCapsMsg) (tea.Model, tea.Cmd) {
+	m.serverCaps = msg.caps
+	m.serverNotice = msg.caps.NewerThanKnown()
+	return m, nil
+}
+
+// serverNoticeText is the status bar while the server is newer than pvec
+// knows
+func (m *listModel) serverNoticeText() string {
+	return fmt.Sprintf("PVE %s is newer than this pvec knows; some actions may fail - x to dismiss", m.serverCaps.Version)
+}
+
+// serverLimitError blocks an action the server's release doesn't offer
+type serverLimitError struct {
+	requirement string
+	version     proxmox.ServerVersion
+}
+
+func (e *serverLimitError) Error() string {
+	return fmt.Sprintf("%s (the server runs %s)", e.requirement, e.version)
+}
+
+// actionLimit returns why the server can't run an action on a guest, nil
+// when it can
+func (m *listModel) actionLimit(vm *models.VMStatus, action string) error {
+	if action != "reboot" || vm.Type != models.TypeContainer {
+		return nil
+	}
+	if r := m.serverCaps.Requirement(proxmox.ServerLXCReboot); r != "" {
+		return &serverLimitError{requirement: r, version: m.serverCaps.Version}
+	}
+	return nil
+}
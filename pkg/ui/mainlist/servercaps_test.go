@@ -0,0 +1,91 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// stubInspector reports the capabilities of a fixed version
+type stubInspector struct {
+	version string
+	err     error
+}
+
+func (s stubInspector) ServerCapabilities(ctx context.Context) (proxmox.ServerCapabilities, error) {
+	return proxmox.CapabilitiesFor(s.version), s.err
+}
+
+// withServer makes the driver's server report version
+func withServer(d *driver, version string) {
+	d.ml.serverInspector = stubInspector{version: version}
+	d.send(d.ml.serverCapsCmd()())
+}
+
+func TestServerCaps_RefusesContainerReboot(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	withServer(d, "5.4-3")
+	selectGuest(t, d, "200")
+
+	d.key("r")
+	want := "Cannot reboot 200: rebooting containers requires PVE ≥ 6.0 (the server runs 5.4"
+	if bar := statusBar(d); !strings.Contains(bar, want) {
+		t.Errorf("Expected %q:\n%s", want, bar)
+	}
+
+	d.key("x")
+	selectGuest(t, d, "100")
+	d.key("r")
+	if bar := statusBar(d); !strings.Contains(bar, "Succeeded in reboot 100") {
+		t.Errorf("VMs reboot on any release:\n%s", bar)
+	}
+}
+
+func TestServerCaps_UnknownRefusesNothing(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.ml.serverInspector = stubInspector{err: errors.New("403")}
+	if msg := d.ml.serverCapsCmd()(); msg != nil {
+		t.Errorf("A failed read should send nothing, got %#v", msg)
+	}
+	selectGuest(t, d, "200")
+	d.key("r")
+	if bar := statusBar(d); !strings.Contains(bar, "Succeeded in reboot 200") {
+		t.Errorf("An unknown server should not be refused:\n%s", bar)
+	}
+}
+
+func TestServerCaps_NewerNotice(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	withServer(d, "8.2.4")
+	if bar := statusBar(d); !strings.Contains(bar, "F1 Help") {
+		t.Errorf("A known release should keep the usual status bar:\n%s", bar)
+	}
+
+	withServer(d, "10.0.1")
+	want := "PVE 10.0.1 is newer than this pvec knows"
+	if bar := statusBar(d); !strings.Contains(bar, want) {
+		t.Errorf("Expected %q:\n%s", want, bar)
+	}
+	d.key("x")
+	if bar := statusBar(d); strings.Contains(bar, "newer") {
+		t.Errorf("x should dismiss the notice:\n%s", bar)
+	}
+}
+
+func TestServerCaps_HAWithoutGroups(t *testing.T) {
+	ha := &mockHA{}
+	d := newHADriver(t, ha)
+	withServer(d, "9.0.3")
+	for range 3 {
+		d.key("down")
+	}
+	d.key("A") // web-1 (100), not managed
+	d.key("enter")
+	d.key("y")
+	if got := strings.Join(ha.calls, "; "); got != "add vm:100 " {
+		t.Errorf("Expected the guest added without listing groups, got %q", got)
+	}
+}
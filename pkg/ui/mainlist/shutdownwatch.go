@@ -0,0 +1,266 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// shutdownWatchPoll is how often a guest shutting down is checked
+const shutdownWatchPoll = 5 * time.Second
+
+// shutdownWatch follows a guest after a shutdown sent from the list. It
+// lives apart from the guests, so refreshes leave it alone, until the
+// guest stops, the user answers its toast or acts on the guest again.
+type shutdownWatch struct {
+	seq      int
+	vm       *models.VMStatus
+	watch    *actions.ShutdownWatch
+	overdue  bool // Still running past the timeout: the toast offers to force it off
+	checking bool // A status read is in flight
+	forcing  bool // The force stop is in flight
+}
+
+// shutdownWatchPollMsg ends the wait before the next status check
+type shutdownWatchPollMsg struct {
+	seq int
+}
+
+// shutdownWatchCheckMsg reports a status check
+type shutdownWatchCheckMsg struct {
+	seq   int
+	state actions.WatchState
+	err   error
+}
+
+// shutdownEscalateMsg reports the outcome of a force stop
+type shutdownEscalateMsg struct {
+	seq int
+	err error
+}
+
+// shutdownEscalateAfter returns how long a shutdown may take before the
+// toast offers to force the guest off, 0 to never offer it
+func (ml *MainList) shutdownEscalateAfter() time.Duration {
+	if ml.appConfig == nil {
+		return config.DefaultShutdownEscalateAfter
+	}
+	return ml.appConfig.ShutdownEscalateAfter
+}
+
+// watchShutdown starts following vm after its shutdown was sent. A watch
+// of the same guest is replaced.
+func (m *listModel) watchShutdown(vm *models.VMStatus) tea.Cmd {
+	ml := m.parent
+	timeout := ml.shutdownEscalateAfter()
+	executor, reader := ml.executor, ml.reader
+	if timeout <= 0 || executor == nil || reader == nil {
+		return nil
+	}
+	m.cancelShutdownWatch(vm.Key())
+	guest := func(ctx context.Context, key string) (*models.VMStatus, error) {
+		return reader.GetGuestStatus(ctx, vm.Node, vm.TypeString(), key)
+	}
+	m.watchSeq++
+	m.shutdownWatches = append(m.shutdownWatches, &shutdownWatch{
+		seq:   m.watchSeq,
+		vm:    vm,
+		watch: actions.NewShutdownWatch(executor, guest, vm, timeout, ml.clock),
+	})
+	return shutdownWatchPollCmd(m.watchSeq)
+}
+
+// shutdownWatchPollCmd waits before the next check of watch seq
+func shutdownWatchPollCmd(seq int) tea.Cmd {
+	return tea.Tick(shutdownWatchPoll, func(time.Time) tea.Msg {
+		return shutdownWatchPollMsg{seq: seq}
+	})
+}
+
+// findShutdownWatch returns the watch seq, nil once it ended
+func (m *listModel) findShutdownWatch(seq int) *shutdownWatch {
+	for _, w := range m.shutdownWatches {
+		if w.seq == seq {
+			return w
+		}
+	}
+	return nil
+}
+
+// endShutdownWatch drops the watch seq
+func (m *listModel) endShutdownWatch(seq int) {
+	for i, w := range m.shutdownWatches {
+		if w.seq == seq {
+			m.shutdownWatches = append(m.shutdownWatches[:i], m.shutdownWatches[i+1:]...)
+			return
+		}
+	}
+}
+
+// cancelShutdownWatch stops following the guest key, as when another
+// action is sent to it
+func (m *listModel) cancelShutdownWatch(key string) {
+	for _, w := range m.shutdownWatches {
+		if w.watch.Key == key && !w.forcing {
+			m.endShutdownWatch(w.seq)
+			return
+		}
+	}
+}
+
+// handleShutdownWatchPoll reads the status of the watched guest
+func (m *listModel) handleShutdownWatchPoll(msg shutdownWatchPollMsg) (tea.Model, tea.Cmd) {
+	w := m.findShutdownWatch(msg.seq)
+	if w == nil || w.checking || w.forcing {
+		return m, nil
+	}
+	w.checking = true
+	watch, root, timeout := w.watch, m.parent.ctx, m.parent.actionTimeout()
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(root, timeout)
+		defer cancel()
+		var state actions.WatchState
+		err := guarded(func() (err error) {
+			state, err = watch.Check(ctx)
+			return err
+		})
+		return shutdownWatchCheckMsg{seq: msg.seq, state: state, err: err}
+	}
+}
+
+// handleShutdownWatchCheck ends the watch once the guest stopped, or was
+// started again since, and raises the toast once the timeout is over. A
+// failed read is tried again at the next poll.
+func (m *listModel) handleShutdownWatchCheck(msg shutdownWatchCheckMsg) (tea.Model, tea.Cmd) {
+	w := m.findShutdownWatch(msg.seq)
+	if w == nil {
+		return m, nil
+	}
+	w.checking = false
+	if w.forcing {
+		return m, nil
+	}
+	if msg.err == nil && msg.state != actions.WatchRunning {
+		m.endShutdownWatch(w.seq)
+		return m, nil
+	}
+	if msg.err == nil && w.watch.Overdue() {
+		w.overdue = true
+	}
+	return m, shutdownWatchPollCmd(w.seq)
+}
+
+// toastWatch returns the oldest watch the toast offers to force off, and
+// how many more are overdue
+func (m *listModel) toastWatch() (*shutdownWatch, int) {
+	var first *shutdownWatch
+	more := 0
+	for _, w := range m.shutdownWatches {
+		switch {
+		case !w.overdue && !w.forcing:
+		case first == nil:
+			first = w
+		default:
+			more++
+		}
+	}
+	return first, more
+}
+
+// handleShutdownToastKeys answers the toast: y forces the guest off and n
+// stops watching it. Other keys go on to the list, dismissing the outcome
+// of a force stop on the way.
+func (m *listModel) handleShutdownToastKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	m.shutdownNote = ""
+	w, _ := m.toastWatch()
+	if w == nil || w.forcing {
+		return false, m, nil
+	}
+	switch msg.String() {
+	case "y":
+		return true, m, m.escalateShutdown(w)
+	case "n":
+		m.endShutdownWatch(w.seq)
+		return true, m, nil
+	}
+	return false, m, nil
+}
+
+// escalateShutdown force-stops the guest of w, once its status confirms
+// it has been running since before the shutdown
+func (m *listModel) escalateShutdown(w *shutdownWatch) tea.Cmd {
+	w.forcing = true
+	watch, seq, root, timeout := w.watch, w.seq, m.parent.ctx, m.parent.actionTimeout()
+	m.parent.emit(ActionStarted{Action: "stop", VMID: watch.Key})
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(root, timeout)
+		defer cancel()
+		err := guarded(func() error { return watch.Escalate(ctx) })
+		return shutdownEscalateMsg{seq: seq, err: err}
+	}
+}
+
+// handleShutdownEscalate ends the watch with the outcome of the force
+// stop, shown in the toast until the next key
+func (m *listModel) handleShutdownEscalate(msg shutdownEscalateMsg) (tea.Model, tea.Cmd) {
+	w := m.findShutdownWatch(msg.seq)
+	if w == nil {
+		return m, nil
+	}
+	m.endShutdownWatch(w.seq)
+	err := m.parent.logPanic(msg.err)
+	name := fmt.Sprintf("%s (%s)", w.vm.Name, w.vm.Key())
+	switch {
+	case err == nil:
+		m.shutdownNote = fmt.Sprintf("Forced %s off", name)
+	case errors.Is(err, actions.ErrShutDown), errors.Is(err, actions.ErrStartedAgain):
+		m.shutdownNote = fmt.Sprintf("Left %s alone: %v", name, err)
+		err = nil
+	case proxmox.PermissionHint(err) != "":
+		m.shutdownNote = fmt.Sprintf("Failed to force %s off: the token %s", name, proxmox.PermissionHint(err))
+	default:
+		m.shutdownNote = fmt.Sprintf("Failed to force %s off: %v", name, redact.Error(err))
+	}
+	m.parent.emit(ActionCompleted{Action: "stop", VMID: w.vm.Key(), Err: err})
+	return m, m.parent.fetchGuestCmd(w.vm)
+}
+
+// shutdownToast is the notice offering to force off a guest that ignored
+// its shutdown, or telling how the force stop went; "" when there is none
+func (m *listModel) shutdownToast() string {
+	w, more := m.toastWatch()
+	switch {
+	case w == nil:
+		return m.shutdownNote
+	case w.forcing:
+		return fmt.Sprintf("Forcing %s (%s) off...", w.vm.Name, w.vm.Key())
+	}
+	toast := fmt.Sprintf("%s (%s) still running after %s", w.vm.Name, w.vm.Key(), durationText(w.watch.Timeout)) +
+		format.Text(" — ") + "force stop? (y/n)"
+	if more > 0 {
+		toast += fmt.Sprintf(", %d more", more)
+	}
+	return toast
+}
+
+// durationText writes d without its zero units, e.g. 2m rather than 2m0s
+func durationText(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
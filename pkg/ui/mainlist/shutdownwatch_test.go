@@ -0,0 +1,153 @@
+package mainlist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// shutDownWeb shuts web-1 (100) down, which ignores it, and dismisses
+// the action's result
+func shutDownWeb(t *testing.T, client *MockClient) *driver {
+	t.Helper()
+	d := restartDriver(t, client)
+	d.key("d")
+	if len(client.ShutDown) != 1 {
+		t.Fatalf("Expected web-1 to be shut down, got %v", client.ShutDown)
+	}
+	d.key("x")
+	if len(d.ml.model.shutdownWatches) != 1 {
+		t.Fatalf("Expected the shutdown to be watched, got %d watches", len(d.ml.model.shutdownWatches))
+	}
+	return d
+}
+
+// pollWatch moves the clock and delivers the next check of the first watch
+func pollWatch(d *driver, client *MockClient, elapsed time.Duration) {
+	d.clock.Advance(elapsed)
+	if client.Guest.IsRunning() {
+		client.Guest.Uptime += int64(elapsed / time.Second)
+	}
+	d.send(shutdownWatchPollMsg{seq: d.ml.model.shutdownWatches[0].seq})
+}
+
+func TestShutdownWatch_ForceStop(t *testing.T) {
+	client := e2eClient()
+	d := shutDownWeb(t, client)
+	pollWatch(d, client, time.Minute)
+	if view := d.ml.model.View(); strings.Contains(view, "still running") {
+		t.Errorf("No toast before the timeout:\n%s", view)
+	}
+
+	pollWatch(d, client, time.Minute)
+	if view := d.ml.model.View(); !strings.Contains(view, "web-1 (100) still running after 2m") || !strings.Contains(view, "force stop? (y/n)") {
+		t.Fatalf("Expected the toast:\n%s", view)
+	}
+	// The list still takes keys
+	d.key("down")
+	if got := d.ml.SelectedVMID(); got == "100" {
+		t.Error("The toast shouldn't keep the cursor from moving")
+	}
+
+	d.key("y")
+	if len(client.Killed) != 1 || client.Killed[0] != "100" {
+		t.Fatalf("y should force web-1 off, got %v", client.Killed)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "Forced web-1 (100) off") {
+		t.Errorf("Expected the outcome in the toast:\n%s", view)
+	}
+	d.key("up")
+	if view := d.ml.model.View(); strings.Contains(view, "Forced web-1") || len(d.ml.model.shutdownWatches) != 0 {
+		t.Errorf("The next key should dismiss the outcome:\n%s", view)
+	}
+}
+
+func TestShutdownWatch_StartedAgain(t *testing.T) {
+	client := e2eClient()
+	d := shutDownWeb(t, client)
+	pollWatch(d, client, 3*time.Minute)
+
+	// It shut down and someone started it again before the answer
+	client.Guest.Uptime = 5
+	d.key("y")
+	if len(client.Killed) != 0 {
+		t.Fatalf("A guest started again since the shutdown must be left alone, got %v", client.Killed)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "Left web-1 (100) alone: it was started again since the shutdown") {
+		t.Errorf("Expected the guest left alone:\n%s", view)
+	}
+}
+
+func TestShutdownWatch_Ends(t *testing.T) {
+	client := e2eClient()
+	d := shutDownWeb(t, client)
+	client.Guest.Status = models.StateStopped
+	pollWatch(d, client, 30*time.Second)
+	if len(d.ml.model.shutdownWatches) != 0 {
+		t.Error("The watch should end once the guest stopped")
+	}
+
+	client = e2eClient()
+	d = shutDownWeb(t, client)
+	pollWatch(d, client, 3*time.Minute)
+	d.key("n")
+	if len(d.ml.model.shutdownWatches) != 0 || len(client.Killed) != 0 {
+		t.Errorf("n should stop watching without stopping, got %v", client.Killed)
+	}
+	if view := d.ml.model.View(); strings.Contains(view, "still running") {
+		t.Errorf("n should close the toast:\n%s", view)
+	}
+
+	// Another action on the guest ends its watch
+	client = e2eClient()
+	d = shutDownWeb(t, client)
+	d.key("t", "x")
+	if len(d.ml.model.shutdownWatches) != 0 {
+		t.Error("Stopping the guest should end the watch")
+	}
+}
+
+func TestShutdownWatch_Clusters(t *testing.T) {
+	d, _, prod := newClustersDriver(t)
+	selectGuest(t, d, "prod/101")
+	guest := *prod.Nodes[1]
+	prod.Guest = &guest
+	d.key("d", "x")
+	if len(d.ml.model.shutdownWatches) != 1 {
+		t.Fatalf("Expected the shutdown to be watched, got %d watches", len(d.ml.model.shutdownWatches))
+	}
+
+	// The check reaches prod through the guest's key
+	pollWatch(d, prod, 3*time.Minute)
+	if view := d.ml.model.View(); !strings.Contains(view, "prod-db (prod/101) still running after 2m") {
+		t.Errorf("Expected the toast:\n%s", view)
+	}
+}
+
+func TestShutdownWatch_Disabled(t *testing.T) {
+	client := e2eClient()
+	d := restartDriver(t, client)
+	d.ml.appConfig = &config.Config{}
+	d.key("d", "x")
+	if len(client.ShutDown) != 1 || len(d.ml.model.shutdownWatches) != 0 {
+		t.Errorf("A zero shutdown_escalate_after should watch nothing, got %d watches", len(d.ml.model.shutdownWatches))
+	}
+}
+
+func TestDurationText(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		2 * time.Minute:            "2m",
+		90 * time.Second:           "1m30s",
+		45 * time.Second:           "45s",
+		time.Hour:                  "1h",
+		time.Hour + 30*time.Minute: "1h30m",
+		time.Hour + 30*time.Second: "1h0m30s",
+	} {
+		if got := durationText(d); got != want {
+			t.Errorf("%s: expected %q, got %q", d, want, got)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package mainlist
+
+import (
+	"cmp"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// sinceWidth is the width of the Since column, enough for "paused 12h"
+const sinceWidth = 10
+
+// downAlertStyle colors the Since cell of a guest down for too long
+var downAlertStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Bold(true)
+
+// toggleSince shows or hides the Since column
+func (m *listModel) toggleSince() {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+	m.parent.showSince = !m.parent.showSince
+}
+
+// observeStates updates since when each guest entered its state, saving
+// it with the state file so a restart of pvec doesn't lose it. Must be
+// called with refreshMutex held.
+func (ml *MainList) observeStates(nodes []*models.VMStatus, now time.Time) {
+	if ml.stateFile == nil {
+		return
+	}
+	// Failing to save only loses the observations at exit; this session
+	// keeps them
+	ml.since, _ = ml.stateFile.Observe(nodes, now)
+}
+
+// stateAge returns how long a guest has been in its current state, if
+// pvec observed it
+func (ml *MainList) stateAge(node *models.VMStatus, now time.Time) (time.Duration, bool) {
+	o, ok := ml.since[node.Key()]
+	if !ok || o.State != node.Status {
+		return 0, false
+	}
+	return max(now.Sub(o.Since), 0), true
+}
+
+// sinceText returns the Since cell of a guest: its uptime when running,
+// how long it has been down or paused otherwise, and ! when it is down
+// for too long and there is no color to tell
+func (ml *MainList) sinceText(node *models.VMStatus, now time.Time) string {
+	if node.NodeOffline {
+		return "-"
+	}
+	var text string
+	switch node.Status {
+	case models.StateRunning:
+		if !node.IsKnown(models.MetricUptime) {
+			return "-"
+		}
+		return format.Uptime(ml.liveUptime(node, now))
+	case models.StateStopped, models.StateHibernated:
+		text = "down"
+	case models.StatePaused:
+		text = "paused"
+	default:
+		return "-"
+	}
+	if age, ok := ml.stateAge(node, now); ok {
+		text += " " + ageText(age)
+	}
+	if !format.Color() && ml.downAlert(node, now) {
+		text += "!"
+	}
+	return text
+}
+
+// ageText renders a duration in its most significant unit ("45m", "5h",
+// "3d", "2y"), as rounding down is precise enough to tell how long a
+// guest has been down
+func ageText(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%dy", int(d/(365*24*time.Hour)))
+}
+
+// downAlert reports whether a guest is down against HA's request to keep
+// it started, or has been down for longer than down_alert_after. A guest
+// HA was asked to stop, or that HA ignores, is down on purpose.
+func (ml *MainList) downAlert(node *models.VMStatus, now time.Time) bool {
+	if node.NodeOffline || !node.CanStart() {
+		return false
+	}
+	switch node.HAState {
+	case "stopped", "disabled", "ignored":
+		return false
+	case "started", "error", "fence", "recovery":
+		return true
+	}
+	limit := config.DefaultDownAlertAfter
+	if ml.appConfig != nil {
+		limit = ml.appConfig.DownAlertAfter
+	}
+	age, ok := ml.stateAge(node, now)
+	return ok && limit > 0 && age >= limit
+}
+
+// compareSince ranks guests by how long they have been in their state;
+// a running guest's uptime counts, so it doesn't need an observation
+func (ml *MainList) compareSince(a, b *models.VMStatus) int {
+	now := ml.now()
+	ageA, _ := ml.sinceAge(a, now)
+	ageB, _ := ml.sinceAge(b, now)
+	return cmp.Compare(ageA, ageB)
+}
+
+// sinceAge returns the age the Since column shows for a guest, if any
+func (ml *MainList) sinceAge(node *models.VMStatus, now time.Time) (time.Duration, bool) {
+	if node.NodeOffline {
+		return 0, false
+	}
+	if node.IsRunning() {
+		if !node.IsKnown(models.MetricUptime) || node.Uptime <= 0 {
+			return 0, false
+		}
+		return time.Duration(ml.liveUptime(node, now)) * time.Second, true
+	}
+	return ml.stateAge(node, now)
+}
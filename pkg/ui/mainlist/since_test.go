@@ -0,0 +1,136 @@
+package mainlist
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/internal/testutil"
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/state"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// newSinceList returns a list whose state file saw 101, 102 and 103
+// stopped three days before e2eNow
+func newSinceList(t *testing.T, appConfig *config.Config) *MainList {
+	t.Helper()
+	format.SetColor(false)
+	t.Cleanup(func() { format.SetColor(true) })
+
+	client := &MockClient{MockDataProvider: MockDataProvider{Nodes: []*models.VMStatus{
+		{VMID: "100", Name: "web", Type: "qemu", Status: "running", Node: "pve1", Uptime: 300},
+		{VMID: "101", Name: "old", Type: "qemu", Status: "stopped", Node: "pve1"},
+		{VMID: "102", Name: "ha-up", Type: "qemu", Status: "stopped", Node: "pve1", HAState: "started"},
+		{VMID: "103", Name: "ha-down", Type: "qemu", Status: "stopped", Node: "pve1", HAState: "stopped"},
+		{VMID: "104", Name: "new", Type: "qemu", Status: "paused", Node: "pve1"},
+	}}}
+	f, err := state.Open(filepath.Join(t.TempDir(), state.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Observe(client.Nodes[1:4], e2eNow.Add(-72*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	ml := NewMainList(Config{Provider: client, Reader: client, Power: client, State: f, AppConfig: appConfig})
+	ml.clock = testutil.NewFakeClock(e2eNow)
+	ml.model.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	return ml
+}
+
+// sinceGuest returns the listed guest vmid
+func sinceGuest(t *testing.T, ml *MainList, vmid string) *models.VMStatus {
+	t.Helper()
+	g, ok := ml.guests.Get(vmid)
+	if !ok {
+		t.Fatalf("Guest %s isn't listed", vmid)
+	}
+	return g
+}
+
+func TestSinceColumn(t *testing.T) {
+	ml := newSinceList(t, nil)
+	if strings.Contains(ml.model.View(), "Since") {
+		t.Error("The column should be hidden by default")
+	}
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("D")})
+	if !strings.Contains(ml.model.View(), "Since") {
+		t.Errorf("Expected the Since header:\n%s", ml.model.View())
+	}
+
+	for vmid, want := range map[string]string{
+		"100": "5m",       // Mirrors the uptime
+		"101": "down 3d!", // Past the default day
+		"102": "down 3d!",
+		"103": "down 3d", // HA was asked to stop it
+		"104": "paused 0m",
+	} {
+		if got := ml.sinceText(sinceGuest(t, ml, vmid), e2eNow); got != want {
+			t.Errorf("%s: expected %q, got %q", vmid, want, got)
+		}
+	}
+}
+
+func TestSinceColumn_Threshold(t *testing.T) {
+	ml := newSinceList(t, &config.Config{DownAlertAfter: 7 * 24 * time.Hour})
+	if got := ml.sinceText(sinceGuest(t, ml, "101"), e2eNow); got != "down 3d" {
+		t.Errorf("Expected no alert before down_alert_after, got %q", got)
+	}
+	if got := ml.sinceText(sinceGuest(t, ml, "102"), e2eNow); got != "down 3d!" {
+		t.Errorf("A guest HA should keep started alerts at once, got %q", got)
+	}
+
+	ml = newSinceList(t, &config.Config{})
+	if ml.downAlert(sinceGuest(t, ml, "101"), e2eNow.Add(365*24*time.Hour)) {
+		t.Error("A zero down_alert_after should never alert")
+	}
+}
+
+func TestSinceColumn_Restart(t *testing.T) {
+	ml := newSinceList(t, nil)
+	// pvec last saw 101 stopped; it has since been up for 10 minutes
+	guest := sinceGuest(t, ml, "101")
+	restarted := *guest
+	restarted.Status = models.StateRunning
+	restarted.Uptime = 600
+	ml.model.Update(refreshMsg{nodes: []*models.VMStatus{&restarted}})
+
+	if got := ml.sinceText(sinceGuest(t, ml, "101"), e2eNow); got != "10m" {
+		t.Errorf("Expected the uptime of the restarted guest, got %q", got)
+	}
+	if o := ml.since["101"]; o.State != models.StateRunning || !o.Since.Equal(e2eNow.Add(-10*time.Minute)) {
+		t.Errorf("Expected the start in the observations, got %+v", o)
+	}
+}
+
+func TestSinceColumn_Sort(t *testing.T) {
+	ml := newSinceList(t, nil)
+	ml.showSince = true
+	less := ml.less(sortOrder{column: colSince, desc: true})
+	if !less(sinceGuest(t, ml, "101"), sinceGuest(t, ml, "100")) {
+		t.Error("Down for 3 days should sort before up for 5 minutes")
+	}
+	if less(sinceGuest(t, ml, "104"), sinceGuest(t, ml, "100")) {
+		t.Error("A guest just seen paused should sort after one up for 5 minutes")
+	}
+}
+
+func TestAgeText(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                            "0m",
+		45 * time.Minute:             "45m",
+		5*time.Hour + time.Minute:    "5h",
+		3*24*time.Hour + 5*time.Hour: "3d",
+		800 * 24 * time.Hour:         "2y",
+	} {
+		if got := ageText(d); got != want {
+			t.Errorf("%s: expected %q, got %q", d, want, got)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package mainlist
+
+import (
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox/configparse"
+)
+
+// recentUptimeThreshold is the uptime below which a guest counts as recently (re)started
+const recentUptimeThreshold = 15 * time.Minute
+
+// sortOrder is the ordering of the main list: the column it is sorted on
+// and the direction
+type sortOrder struct {
+	column columnID
+	desc   bool
+}
+
+var (
+	defaultSort = sortOrder{column: colType}   // Containers first, then VMs, by name
+	uptimeSort  = sortOrder{column: colUptime} // Running guests by uptime ascending, stopped last
+)
+
+// String returns the label shown in the title
+func (o sortOrder) String() string {
+	if o.desc {
+		return columnLabel(o.column) + " desc"
+	}
+	return columnLabel(o.column)
+}
+
+// next returns the following order in the cycle of the sort key, which
+// goes between type/name and uptime
+func (o sortOrder) next() sortOrder {
+	if o == defaultSort {
+		return uptimeSort
+	}
+	return defaultSort
+}
+
+// shift returns the order on the visible column step places away from the
+// one sorted on, ascending; it stays put at either end
+func (o sortOrder) shift(visible []column, step int) sortOrder {
+	at := -1
+	for i, c := range visible {
+		if c.id == o.column {
+			at = i
+		}
+	}
+	if at < 0 {
+		return defaultSort
+	}
+	next := min(max(at+step, 0), len(visible)-1)
+	if next == at {
+		return o
+	}
+	return sortOrder{column: visible[next].id}
+}
+
+// activeSort returns the order the list is sorted in: the chosen one, or
+// type/name while the column it is on is hidden
+func (ml *MainList) activeSort() sortOrder {
+	for _, c := range ml.visibleColumns() {
+		if c.id == ml.sortOrder.column {
+			return ml.sortOrder
+		}
+	}
+	return defaultSort
+}
+
+// handleSortColumnKey sorts on the visible column left (<) or right (>)
+// of the one sorted on
+func (m *listModel) handleSortColumnKey(key string) (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+
+	step := 1
+	if key == "<" {
+		step = -1
+	}
+	m.parent.sortOrder = m.parent.activeSort().shift(m.parent.visibleColumns(), step)
+	m.rearrange()
+	return true, m, nil
+}
+
+// handleSortDirectionKey reverses the order of the column sorted on
+func (m *listModel) handleSortDirectionKey() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	defer m.parent.refreshMutex.Unlock()
+
+	order := m.parent.activeSort()
+	order.desc = !order.desc
+	m.parent.sortOrder = order
+	m.rearrange()
+	return true, m, nil
+}
+
+// filterPreset selects which guests are shown
+type filterPreset int
+
+const (
+	filterNone   filterPreset = iota
+	filterRecent              // Running guests with uptime under recentUptimeThreshold
+)
+
+// String returns the label shown in the title
+func (f filterPreset) String() string {
+	switch f {
+	case filterRecent:
+		return "recently restarted"
+	default:
+		return ""
+	}
+}
+
+// matches reports whether a node passes the preset
+func (f filterPreset) matches(node *models.VMStatus) bool {
+	switch f {
+	case filterRecent:
+		return isRecentlyStarted(node)
+	default:
+		return true
+	}
+}
+
+// isRecentlyStarted reports whether a running guest started within recentUptimeThreshold
+func isRecentlyStarted(node *models.VMStatus) bool {
+	return node.IsRunning() && node.Uptime > 0 &&
+		time.Duration(node.Uptime)*time.Second < recentUptimeThreshold
+}
+
+// countRecentlyStarted counts running guests started within recentUptimeThreshold
+func countRecentlyStarted(nodes []*models.VMStatus) int {
+	count := 0
+	for _, node := range nodes {
+		if isRecentlyStarted(node) {
+			count++
+		}
+	}
+	return count
+}
+
+// arrangeNodes filters nodes for display and sorts them with less; nics,
+// hostnames, owners and flags hold the known network interfaces, own
+// hostnames, owners and config flags by guest key
+func arrangeNodes(nodes []*models.VMStatus, less func(a, b *models.VMStatus) bool, filter listFilter, nics map[string][]configparse.NIC, hostnames, owners map[string]string, flags map[string]configparse.Flags) []*models.VMStatus {
+	filtered := make([]*models.VMStatus, 0, len(nodes))
+	for _, node := range nodes {
+		var guestFlags *configparse.Flags
+		if f, ok := flags[node.Key()]; ok {
+			guestFlags = &f
+		}
+		if filter.matches(node, nics[node.Key()], hostnames[node.Key()], owners[node.Key()], guestFlags) {
+			filtered = append(filtered, node)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return less(filtered[i], filtered[j])
+	})
+	return filtered
+}
+
+// lessVMID orders guests numerically by VMID, falling back to string
+// order, then by cluster
+func lessVMID(a, b *models.VMStatus) bool {
+	if a.VMIDNum != b.VMIDNum && a.VMIDNum > 0 && b.VMIDNum > 0 {
+		return a.VMIDNum < b.VMIDNum
+	}
+	if a.VMID != b.VMID {
+		return a.VMID < b.VMID
+	}
+	return a.Cluster < b.Cluster
+}
@@ -0,0 +1,192 @@
+package mainlist
+
+import (
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestSortNodes(t *testing.T) {
+	nodes := []*models.VMStatus{
+		{VMID: "101", Name: "vm-zebra", Type: "qemu"},
+		{VMID: "200", Name: "ct-alpha", Type: "lxc"},
+		{VMID: "102", Name: "vm-alpha", Type: "qemu"},
+		{VMID: "201", Name: "ct-beta", Type: "lxc"},
+	}
+
+	sorted := arrangeNodes(nodes, (&MainList{}).less(defaultSort), listFilter{}, nil, nil, nil, nil)
+
+	// Containers should come first (lxc), then VMs (qemu)
+	// Within each type, sorted alphabetically by name
+	expected := []string{
+		"ct-alpha", // lxc comes first
+		"ct-beta",  // lxc, alphabetically
+		"vm-alpha", // qemu after lxc
+		"vm-zebra", // qemu, alphabetically
+	}
+
+	if len(sorted) != len(expected) {
+		t.Fatalf("Expected %d nodes, got %d", len(expected), len(sorted))
+	}
+
+	for i, expectedName := range expected {
+		if sorted[i].Name != expectedName {
+			t.Errorf("Position %d: expected %s, got %s", i, expectedName, sorted[i].Name)
+		}
+	}
+}
+
+func TestLess_TypeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        *models.VMStatus
+		b        *models.VMStatus
+		expected bool
+	}{
+		{
+			name:     "VM before CT",
+			a:        &models.VMStatus{Type: "qemu", Name: "vm-a"},
+			b:        &models.VMStatus{Type: "lxc", Name: "ct-a"},
+			expected: true, // Should swap (CT should come first)
+		},
+		{
+			name:     "CT before VM",
+			a:        &models.VMStatus{Type: "lxc", Name: "ct-a"},
+			b:        &models.VMStatus{Type: "qemu", Name: "vm-a"},
+			expected: false, // No swap needed
+		},
+		{
+			name:     "Same type, alphabetical swap needed",
+			a:        &models.VMStatus{Type: "qemu", Name: "vm-zebra"},
+			b:        &models.VMStatus{Type: "qemu", Name: "vm-alpha"},
+			expected: true, // Should swap (alphabetically)
+		},
+		{
+			name:     "Same type, no swap needed",
+			a:        &models.VMStatus{Type: "qemu", Name: "vm-alpha"},
+			b:        &models.VMStatus{Type: "qemu", Name: "vm-zebra"},
+			expected: false, // Already in order
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := (&MainList{}).less(defaultSort)(tt.b, tt.a)
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLess_UptimeStoppedAfterRunning(t *testing.T) {
+	nodes := []*models.VMStatus{
+		{VMID: "100", Name: "stopped-a", Type: "qemu", Status: "stopped", Uptime: 0},
+		{VMID: "101", Name: "old", Type: "qemu", Status: "running", Uptime: 86400},
+		{VMID: "102", Name: "fresh", Type: "lxc", Status: "running", Uptime: 60},
+		{VMID: "103", Name: "stopped-b", Type: "lxc", Status: "stopped", Uptime: 0},
+		{VMID: "104", Name: "mid", Type: "qemu", Status: "running", Uptime: 3600},
+	}
+
+	sorted := arrangeNodes(nodes, (&MainList{}).less(uptimeSort), listFilter{}, nil, nil, nil, nil)
+
+	expected := []string{"fresh", "mid", "old", "stopped-a", "stopped-b"}
+	for i, name := range expected {
+		if sorted[i].Name != name {
+			t.Errorf("Position %d: expected %s, got %s", i, name, sorted[i].Name)
+		}
+	}
+}
+
+func TestArrangeNodes_RecentFilter(t *testing.T) {
+	nodes := []*models.VMStatus{
+		{VMID: "100", Name: "stopped", Status: "stopped", Uptime: 0},
+		{VMID: "101", Name: "old", Status: "running", Uptime: 16 * 60},
+		{VMID: "102", Name: "fresh", Status: "running", Uptime: 60},
+		{VMID: "103", Name: "recent", Status: "running", Uptime: 14 * 60},
+	}
+
+	filtered := arrangeNodes(nodes, (&MainList{}).less(uptimeSort), listFilter{preset: filterRecent}, nil, nil, nil, nil)
+
+	if len(filtered) != 2 {
+		t.Fatalf("Expected 2 recently started guests, got %d", len(filtered))
+	}
+	if filtered[0].Name != "fresh" || filtered[1].Name != "recent" {
+		t.Errorf("Unexpected order: %s, %s", filtered[0].Name, filtered[1].Name)
+	}
+	if got := countRecentlyStarted(nodes); got != 2 {
+		t.Errorf("Expected count 2, got %d", got)
+	}
+}
+
+func TestSortOrder_Next(t *testing.T) {
+	if defaultSort.next() != uptimeSort {
+		t.Error("Expected uptime after type/name")
+	}
+	if uptimeSort.next() != defaultSort {
+		t.Error("Sort orders should cycle")
+	}
+	if (sortOrder{column: colCPU, desc: true}).next() != defaultSort {
+		t.Error("Expected the cycle to start over from another column")
+	}
+}
+
+func TestSortOrder_Shift(t *testing.T) {
+	visible := (&MainList{}).visibleColumns()
+	if got := defaultSort.shift(visible, 1); got != (sortOrder{column: colNode}) {
+		t.Errorf("Expected > to sort on Node, got %v", got)
+	}
+	if got := (sortOrder{column: colStatus, desc: true}).shift(visible, -1); got != (sortOrder{column: colStatus, desc: true}) {
+		t.Errorf("Expected < to stay on the first column, got %v", got)
+	}
+	if got := (sortOrder{column: colOwner}).shift(visible, 1); got != defaultSort {
+		t.Errorf("Expected a hidden column to start over from type/name, got %v", got)
+	}
+}
+
+func TestLess_Descending(t *testing.T) {
+	nodes := []*models.VMStatus{
+		{VMID: "100", Name: "idle", Status: "running", CPUUsage: 1, MemoryUsage: 10, MaxMem: 1024},
+		{VMID: "101", Name: "busy", Status: "running", CPUUsage: 90, MemoryUsage: 20, MaxMem: 1024},
+		{VMID: "102", Name: "legacy", Status: "running", CPUUsage: 40},
+	}
+
+	sorted := arrangeNodes(nodes, (&MainList{}).less(sortOrder{column: colCPU, desc: true}), listFilter{}, nil, nil, nil, nil)
+	if sorted[0].Name != "busy" || sorted[2].Name != "idle" {
+		t.Errorf("Expected the busiest guest first, got %s, %s, %s", sorted[0].Name, sorted[1].Name, sorted[2].Name)
+	}
+
+	// A guest whose memory isn't known comes last either way
+	for _, desc := range []bool{false, true} {
+		sorted = arrangeNodes(nodes, (&MainList{}).less(sortOrder{column: colMem, desc: desc}), listFilter{}, nil, nil, nil, nil)
+		if sorted[2].Name != "legacy" {
+			t.Errorf("Expected legacy last (desc %v), got %s", desc, sorted[2].Name)
+		}
+	}
+}
+
+func TestLess_Owner(t *testing.T) {
+	ml := &MainList{owners: map[string]string{"100": "bob", "101": "Alice"}}
+	nodes := []*models.VMStatus{
+		{VMID: "102", Name: "c"},
+		{VMID: "100", Name: "a"},
+		{VMID: "101", Name: "b"},
+	}
+
+	sorted := arrangeNodes(nodes, ml.less(sortOrder{column: colOwner}), listFilter{}, nil, nil, nil, nil)
+	if sorted[0].VMID != "101" || sorted[1].VMID != "100" || sorted[2].VMID != "102" {
+		t.Errorf("Expected owners ignoring case, without one last, got %s, %s, %s", sorted[0].VMID, sorted[1].VMID, sorted[2].VMID)
+	}
+}
+
+func TestLessVMID_Numeric(t *testing.T) {
+	a := &models.VMStatus{VMID: "99", VMIDNum: 99}
+	b := &models.VMStatus{VMID: "100", VMIDNum: 100}
+
+	if !lessVMID(a, b) {
+		t.Error("99 should sort before 100 numerically")
+	}
+	if lessVMID(b, a) {
+		t.Error("100 should not sort before 99")
+	}
+}
@@ -0,0 +1,256 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/redact"
+)
+
+// startConfigTimeout bounds each config fetch made to plan an ordered start
+const startConfigTimeout = 10 * time.Second
+
+// startGroup is an ordered start of one node's stopped guests. Guests are
+// started one at a time, waiting out each one's up delay before the next.
+type startGroup struct {
+	seq       int
+	node      string
+	steps     []actions.StartStep
+	skipped   []*models.VMStatus // Locked guests left out of the sequence
+	current   int                // Index of the guest being started or waited on
+	waitUntil time.Time          // End of the current up delay; zero while starting
+	planning  bool               // Configs are still being fetched
+	aborting  bool               // ESC was pressed during a start
+	done      bool
+	started   int
+	err       error
+}
+
+// startPlanMsg carries the start sequence once configs are fetched
+type startPlanMsg struct {
+	seq   int
+	steps []actions.StartStep
+}
+
+// startStepMsg reports the start of the current guest
+type startStepMsg struct {
+	seq int
+	err error
+}
+
+// startWaitMsg ends the up delay of the current guest
+type startWaitMsg struct {
+	seq int
+}
+
+// handleStartGroupKey plans an ordered start of the stopped guests on the
+// selected guest's node. The guests of an offline node only show their
+// last known state, so none is started; locked guests are skipped, as
+// Proxmox would refuse them.
+func (m *listModel) handleStartGroupKey() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	if m.parent.selectedIdx < 0 || m.parent.selectedIdx >= len(m.parent.sortedNodes) {
+		m.parent.refreshMutex.Unlock()
+		return true, m, nil
+	}
+	node := m.parent.sortedNodes[m.parent.selectedIdx].Node
+	var stopped, skipped []*models.VMStatus
+	offline := false
+	for _, vm := range m.parent.guests.All() {
+		if vm.Node != node {
+			continue
+		}
+		offline = offline || vm.NodeOffline
+		switch {
+		case vm.Status != models.StateStopped:
+		case vm.Locked():
+			skipped = append(skipped, vm)
+		default:
+			stopped = append(stopped, vm)
+		}
+	}
+	m.parent.refreshMutex.Unlock()
+
+	m.groupSeq++
+	m.group = &startGroup{seq: m.groupSeq, node: node, skipped: skipped, planning: true}
+	switch {
+	case offline:
+		m.group.done = true
+		m.group.err = &nodeOfflineError{node: node}
+		return true, m, nil
+	case m.parent.power == nil:
+		m.group.done = true
+		m.group.err = fmt.Errorf("client not available")
+		return true, m, nil
+	}
+	return true, m, m.planStartCmd(m.group.seq, stopped)
+}
+
+// planStartCmd fetches the guests' configs and sorts them by startup order.
+// Guests whose config can't be read are started last, like those without
+// a startup order.
+func (m *listModel) planStartCmd(seq int, guests []*models.VMStatus) tea.Cmd {
+	reader := m.parent.reader
+	return func() tea.Msg {
+		configs := make(map[string]map[string]interface{})
+		for _, vm := range guests {
+			if reader == nil {
+				break
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), startConfigTimeout)
+			if cfg, err := reader.GetVMConfig(ctx, vm.Node, vm.TypeString(), vm.Key()); err == nil {
+				configs[vm.Key()] = cfg
+			}
+			cancel()
+		}
+		return startPlanMsg{seq: seq, steps: actions.PlanOrderedStart(guests, configs)}
+	}
+}
+
+// handleStartPlan begins the sequence once it is known
+func (m *listModel) handleStartPlan(msg startPlanMsg) (tea.Model, tea.Cmd) {
+	g := m.group
+	if g == nil || g.seq != msg.seq || g.done {
+		return m, nil
+	}
+	g.planning = false
+	g.steps = msg.steps
+	if g.aborting {
+		g.done = true
+		return m, nil
+	}
+	return m, m.startCurrentCmd()
+}
+
+// startCurrentCmd starts the current guest, or ends the sequence when
+// every guest has been started
+func (m *listModel) startCurrentCmd() tea.Cmd {
+	g := m.group
+	if g.current >= len(g.steps) {
+		g.done = true
+		return nil
+	}
+	vm := g.steps[g.current].VM
+	power, timeout, seq := m.parent.power, m.parent.actionTimeout(), g.seq
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return startStepMsg{seq: seq, err: power.Start(ctx, vm.Node, vm.TypeString(), vm.Key())}
+	}
+}
+
+// handleStartStep moves on after a guest was started: a failure ends the
+// sequence since later guests may depend on the one that didn't come up
+func (m *listModel) handleStartStep(msg startStepMsg) (tea.Model, tea.Cmd) {
+	g := m.group
+	if g == nil || g.seq != msg.seq || g.done {
+		return m, nil
+	}
+	step := g.steps[g.current]
+	if msg.err != nil {
+		g.done = true
+		g.err = msg.err
+		return m, nil
+	}
+	g.started++
+	refresh := m.parent.fetchGuestCmd(step.VM)
+
+	if g.aborting || g.current == len(g.steps)-1 {
+		g.current++
+		g.done = true
+		return m, refresh
+	}
+	if step.Up > 0 {
+		g.waitUntil = m.parent.now().Add(step.Up)
+		seq := g.seq
+		return m, tea.Batch(refresh, tea.Tick(step.Up, func(time.Time) tea.Msg {
+			return startWaitMsg{seq: seq}
+		}))
+	}
+	g.current++
+	return m, tea.Batch(refresh, m.startCurrentCmd())
+}
+
+// handleStartWait starts the next guest once the up delay is over
+func (m *listModel) handleStartWait(msg startWaitMsg) (tea.Model, tea.Cmd) {
+	g := m.group
+	if g == nil || g.seq != msg.seq || g.done {
+		return m, nil
+	}
+	g.waitUntil = time.Time{}
+	g.current++
+	return m, m.startCurrentCmd()
+}
+
+// handleStartGroupKeys handles keys while an ordered start is shown. ESC
+// aborts between guests: at once while planning or waiting, after the
+// guest being started otherwise. Any key dismisses a finished sequence.
+func (m *listModel) handleStartGroupKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	g := m.group
+	if g.done {
+		m.group = nil
+		return true, m, nil
+	}
+	if msg.String() == "esc" {
+		g.aborting = true
+		if !g.waitUntil.IsZero() {
+			g.current++
+			g.done = true
+		}
+	}
+	return true, m, nil
+}
+
+// statusText describes the sequence's progress for the status bar
+func (g *startGroup) statusText(now time.Time) string {
+	total := len(g.steps)
+	switch {
+	case g.done && g.err != nil:
+		if g.current < total {
+			return fmt.Sprintf("Ordered start on %s stopped: failed to start %s (%d/%d). - Press any key",
+				g.node, g.steps[g.current].VM.Key(), g.current+1, total)
+		}
+		return fmt.Sprintf("Ordered start on %s failed: %v. - Press any key", g.node, redact.Error(g.err))
+	case g.done && total == 0 && !g.aborting:
+		return fmt.Sprintf("No stopped guests to start on %s.%s - Press any key", g.node, g.skippedText())
+	case g.done && g.aborting:
+		return fmt.Sprintf("Ordered start on %s aborted: started %d of %d.%s - Press any key", g.node, g.started, total, g.skippedText())
+	case g.done:
+		return fmt.Sprintf("Ordered start on %s: started %d of %d.%s - Press any key", g.node, g.started, total, g.skippedText())
+	case g.planning:
+		return fmt.Sprintf("Ordered start on %s: reading startup order... - ESC to abort", g.node)
+	}
+
+	vm := g.steps[g.current].VM
+	progress := fmt.Sprintf("Ordered start on %s (%d/%d): ", g.node, g.current+1, total)
+	switch {
+	case !g.waitUntil.IsZero():
+		left := int(g.waitUntil.Sub(now).Seconds() + 0.5)
+		return progress + fmt.Sprintf("waiting %ds after %s - ESC to abort", left, vm.Name)
+	case g.aborting:
+		return progress + fmt.Sprintf("starting %s, then aborting...", vm.Name)
+	}
+	return progress + fmt.Sprintf("starting %s... - ESC to abort", vm.Name)
+}
+
+// skippedText lists the locked guests left out of the sequence, "" when
+// none was
+func (g *startGroup) skippedText() string {
+	switch len(g.skipped) {
+	case 0:
+		return ""
+	case 1:
+		vm := g.skipped[0]
+		return fmt.Sprintf(" Skipped %s: %v.", vm.Key(), &guestLockedError{lock: vm.Lock})
+	}
+	keys := make([]string, len(g.skipped))
+	for i, vm := range g.skipped {
+		keys[i] = vm.Key()
+	}
+	return fmt.Sprintf(" Skipped %d locked guests: %s.", len(keys), strings.Join(keys, ", "))
+}
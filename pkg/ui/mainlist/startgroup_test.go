@@ -0,0 +1,161 @@
+package mainlist
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// startGroupClient returns a cluster with three stopped guests on pve1,
+// one of which has no startup order, and one stopped guest on pve2
+func startGroupClient() *MockClient {
+	return &MockClient{
+		MockDataProvider: MockDataProvider{Nodes: []*models.VMStatus{
+			{VMID: "100", VMIDNum: 100, Name: "app", Type: "qemu", Status: "stopped", Node: "pve1"},
+			{VMID: "101", VMIDNum: 101, Name: "db", Type: "qemu", Status: "stopped", Node: "pve1"},
+			{VMID: "102", VMIDNum: 102, Name: "dns", Type: "qemu", Status: "running", Node: "pve1"},
+			{VMID: "103", VMIDNum: 103, Name: "misc", Type: "qemu", Status: "stopped", Node: "pve1"},
+			{VMID: "200", VMIDNum: 200, Name: "other", Type: "qemu", Status: "stopped", Node: "pve2"},
+		}},
+		Configs: map[string]map[string]interface{}{
+			"100": {"startup": "order=2"},
+			"101": {"startup": "order=1,up=30"},
+		},
+	}
+}
+
+func TestStartGroup_Ordered(t *testing.T) {
+	client := startGroupClient()
+	d := newDriver(t, client)
+
+	// "app" on pve1 is the first row; db's 30s up delay pauses the sequence
+	d.key("O")
+	if !reflect.DeepEqual(client.Started, []string{"101"}) {
+		t.Fatalf("The lowest order should start first and alone, got %v", client.Started)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "Ordered start on pve1 (1/3): waiting 30s after db") {
+		t.Errorf("Expected the up delay in the status bar:\n%s", view)
+	}
+
+	// Keys other than ESC don't interfere
+	d.key("down", "s")
+	if !reflect.DeepEqual(client.Started, []string{"101"}) {
+		t.Errorf("Keys should not start anything during the sequence, got %v", client.Started)
+	}
+
+	d.send(startWaitMsg{seq: d.ml.model.group.seq})
+	if want := []string{"101", "100", "103"}; !reflect.DeepEqual(client.Started, want) {
+		t.Errorf("Expected start order %v, got %v", want, client.Started)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "Ordered start on pve1: started 3 of 3.") {
+		t.Errorf("Expected a completion status:\n%s", view)
+	}
+
+	d.key("x")
+	if d.ml.model.group != nil {
+		t.Error("Any key should dismiss a finished sequence")
+	}
+}
+
+func TestStartGroup_AbortDuringWait(t *testing.T) {
+	client := startGroupClient()
+	d := newDriver(t, client)
+
+	d.key("O", "esc")
+	seq := d.ml.model.group.seq
+	if view := d.ml.model.View(); !strings.Contains(view, "Ordered start on pve1 aborted: started 1 of 3.") {
+		t.Errorf("Expected an abort status:\n%s", view)
+	}
+
+	// The pending up delay ends without starting anything
+	d.send(startWaitMsg{seq: seq})
+	if !reflect.DeepEqual(client.Started, []string{"101"}) {
+		t.Errorf("Nothing should start after an abort, got %v", client.Started)
+	}
+}
+
+func TestStartGroup_AbortDuringStart(t *testing.T) {
+	client := startGroupClient()
+	d := newDriver(t, client)
+	m := d.ml.model
+
+	_, _, cmd := m.handleStartGroupKey()
+	_, cmd = m.Update(cmd())
+
+	// ESC while db is starting lets that start finish, then stops
+	d.key("esc")
+	if view := m.View(); !strings.Contains(view, "starting db, then aborting") {
+		t.Errorf("Expected an aborting status:\n%s", view)
+	}
+	d.send(cmd())
+	if !reflect.DeepEqual(client.Started, []string{"101"}) {
+		t.Errorf("Only the guest in flight should start, got %v", client.Started)
+	}
+	if view := m.View(); !strings.Contains(view, "Ordered start on pve1 aborted: started 1 of 3.") {
+		t.Errorf("Expected an abort status:\n%s", view)
+	}
+}
+
+func TestStartGroup_NothingToStart(t *testing.T) {
+	client := startGroupClient()
+	for _, vm := range client.Nodes {
+		vm.Status = "running"
+	}
+	d := newDriver(t, client)
+
+	d.key("O")
+	if len(client.Started) != 0 {
+		t.Errorf("Nothing should start, got %v", client.Started)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "No stopped guests to start on pve1") {
+		t.Errorf("Expected a nothing-to-do status:\n%s", view)
+	}
+}
+
+func TestStartGroup_FailureStopsSequence(t *testing.T) {
+	client := startGroupClient()
+	client.ActionErr = errors.New("VM 101 is locked (backup)")
+	d := newDriver(t, client)
+
+	d.key("O")
+	if !reflect.DeepEqual(client.Started, []string{"101"}) {
+		t.Errorf("Later guests may depend on a failed one and should not start, got %v", client.Started)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "failed to start 101 (1/3)") {
+		t.Errorf("Expected a failure status:\n%s", view)
+	}
+}
+
+func TestStartGroup_NodeOffline(t *testing.T) {
+	client := startGroupClient()
+	// An offline node's guests keep their last known state
+	client.Nodes[4].NodeOffline = true
+	d := newDriver(t, client)
+	selectGuest(t, d, "200")
+
+	d.key("O")
+	if len(client.Started) != 0 {
+		t.Errorf("Nothing should be sent to an offline node, got %v", client.Started)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "Ordered start on pve2 failed: node pve2 is offline") {
+		t.Errorf("Expected the node offline:\n%s", view)
+	}
+}
+
+func TestStartGroup_SkipsLocked(t *testing.T) {
+	client := startGroupClient()
+	client.Nodes[3].Lock = "backup"
+	d := newDriver(t, client)
+
+	d.key("O")
+	d.send(startWaitMsg{seq: d.ml.model.group.seq})
+	if want := []string{"101", "100"}; !reflect.DeepEqual(client.Started, want) {
+		t.Errorf("A locked guest should be left out, expected %v, got %v", want, client.Started)
+	}
+	if view := d.ml.model.View(); !strings.Contains(view, "started 2 of 2. Skipped 103: locked (backup)") {
+		t.Errorf("Expected the locked guest listed as skipped:\n%s", view)
+	}
+}
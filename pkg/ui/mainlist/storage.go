@@ -0,0 +1,237 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+	"github.com/tsupplis/pvec/pkg/ui/storage"
+)
+
+// storageContent lists the content types shown on the storage screen
+var storageContent = []string{models.ContentISO, models.ContentTemplate}
+
+// storageListedMsg carries the ISO images and templates of every node
+type storageListedMsg struct {
+	seq     int
+	volumes []models.StorageVolume
+	targets []models.Storage
+	err     error
+}
+
+// volumeDeleteMsg reports the outcome of a delete request
+type volumeDeleteMsg struct {
+	seq int
+	err error
+}
+
+// downloadStartedMsg reports whether the node accepted a download
+type downloadStartedMsg struct {
+	seq  int
+	upid string
+	err  error
+}
+
+// downloadTickMsg triggers the next poll of the download task
+type downloadTickMsg struct {
+	seq int
+}
+
+// downloadProgressMsg carries a poll of the download task
+type downloadProgressMsg struct {
+	seq    int
+	upid   string
+	status models.TaskStatus
+	start  int
+	lines  []models.TaskLogLine
+	err    error
+}
+
+// handleStorageKey opens the ISO and template screen
+func (m *listModel) handleStorageKey() (bool, tea.Model, tea.Cmd) {
+	state := storage.New()
+	m.storage = &state
+	m.storageSeq++
+	if m.parent.storageManager == nil {
+		m.storage.SetContent(nil, nil, fmt.Errorf("client not available"))
+		return true, m, nil
+	}
+	return true, m, m.listStorageCmd()
+}
+
+// listStorageCmd reads the ISO images and templates of every storage of
+// the nodes that host a guest. A shared storage is listed once, from the
+// first node that sees it. A failing node or storage doesn't hide the
+// others.
+func (m *listModel) listStorageCmd() tea.Cmd {
+	m.parent.refreshMutex.Lock()
+	seen := make(map[string]bool)
+	var nodes []string
+	for _, vm := range m.parent.guests.All() {
+		if vm.Node != "" && !seen[vm.Node] {
+			seen[vm.Node] = true
+			nodes = append(nodes, vm.Node)
+		}
+	}
+	m.parent.refreshMutex.Unlock()
+	sort.Strings(nodes)
+
+	client, seq := m.parent.storageManager, m.storageSeq
+	return func() tea.Msg {
+		volumes := []models.StorageVolume{}
+		var targets []models.Storage
+		var firstErr error
+		keep := func(err error) {
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		shared := make(map[string]bool)
+
+		for _, node := range nodes {
+			ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+			storages, err := client.ListStorages(ctx, node)
+			cancel()
+			if err != nil {
+				keep(err)
+				continue
+			}
+			for _, st := range storages {
+				if st.Shared {
+					if shared[st.Name] {
+						continue
+					}
+					shared[st.Name] = true
+				}
+				accepts := false
+				for _, content := range storageContent {
+					if !st.Supports(content) {
+						continue
+					}
+					accepts = true
+					ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+					list, err := client.GetStorageContent(ctx, node, st.Name, content)
+					cancel()
+					if err != nil {
+						keep(err)
+						continue
+					}
+					volumes = append(volumes, list...)
+				}
+				if accepts {
+					targets = append(targets, st)
+				}
+			}
+		}
+		if firstErr != nil && len(volumes) == 0 {
+			volumes = nil
+		}
+		return storageListedMsg{seq: seq, volumes: volumes, targets: targets, err: firstErr}
+	}
+}
+
+// handleStorageListed updates the volume list
+func (m *listModel) handleStorageListed(msg storageListedMsg) (tea.Model, tea.Cmd) {
+	if m.storage != nil && msg.seq == m.storageSeq {
+		m.storage.SetContent(msg.volumes, msg.targets, msg.err)
+	}
+	return m, nil
+}
+
+// handleVolumeDelete records the outcome of a delete and re-reads the list
+func (m *listModel) handleVolumeDelete(msg volumeDeleteMsg) (tea.Model, tea.Cmd) {
+	if m.storage == nil || msg.seq != m.storageSeq {
+		return m, nil
+	}
+	m.storage.DeleteDone(msg.err)
+	return m, m.listStorageCmd()
+}
+
+// handleDownloadStarted starts polling an accepted download
+func (m *listModel) handleDownloadStarted(msg downloadStartedMsg) (tea.Model, tea.Cmd) {
+	if m.storage == nil || msg.seq != m.storageSeq {
+		return m, nil
+	}
+	m.storage.DownloadStarted(msg.upid, msg.err)
+	if !m.storage.Downloading() {
+		return m, nil
+	}
+	return m, m.downloadTickCmd()
+}
+
+// downloadTickCmd schedules the next poll of the download
+func (m *listModel) downloadTickCmd() tea.Cmd {
+	seq := m.storageSeq
+	return tea.Tick(taskPollInterval, func(time.Time) tea.Msg {
+		return downloadTickMsg{seq: seq}
+	})
+}
+
+// handleDownloadTick reads the status and the new log lines of the
+// download task
+func (m *listModel) handleDownloadTick(msg downloadTickMsg) (tea.Model, tea.Cmd) {
+	if m.storage == nil || msg.seq != m.storageSeq || !m.storage.Downloading() {
+		return m, nil
+	}
+	client, seq := m.parent.taskManager, m.storageSeq
+	d := *m.storage.Download
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+		defer cancel()
+		status, err := client.GetTaskStatus(ctx, d.Node, d.UPID)
+		if err != nil {
+			return downloadProgressMsg{seq: seq, upid: d.UPID, err: err, status: models.TaskStatus{Running: true}}
+		}
+		// The log only adds the progress; losing it doesn't stop the poll
+		lines, _ := client.GetTaskLog(ctx, d.Node, d.UPID, d.Lines)
+		return downloadProgressMsg{seq: seq, upid: d.UPID, status: status, start: d.Lines, lines: lines}
+	}
+}
+
+// handleDownloadProgress shows the progress, polling again until the task
+// ends and listing the volumes once it has
+func (m *listModel) handleDownloadProgress(msg downloadProgressMsg) (tea.Model, tea.Cmd) {
+	if m.storage == nil || msg.seq != m.storageSeq {
+		return m, nil
+	}
+	m.storage.DownloadProgress(msg.upid, msg.status, msg.start, msg.lines, msg.err)
+	if m.storage.Downloading() {
+		return m, m.downloadTickCmd()
+	}
+	return m, m.listStorageCmd()
+}
+
+// handleStorageKeys handles keys while the storage screen is open
+func (m *listModel) handleStorageKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	switch m.storage.HandleKey(msg) {
+	case screen.Closed:
+		m.storage = nil
+		m.storageSeq++ // Drops replies and stops the polling
+	case screen.Reload:
+		return true, m, m.listStorageCmd()
+	case storage.DeleteConfirmed:
+		client, seq, volume := m.parent.storageManager, m.storageSeq, *m.storage.Target
+		return true, m, func() tea.Msg {
+			ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+			defer cancel()
+			return volumeDeleteMsg{seq: seq, err: client.DeleteVolume(ctx, volume.Node, volume.Storage, volume.VolID)}
+		}
+	case storage.DownloadRequested:
+		if m.parent.taskManager == nil {
+			m.storage.DownloadStarted("", fmt.Errorf("task status not available"))
+			return true, m, nil
+		}
+		client, seq, d := m.parent.storageManager, m.storageSeq, *m.storage.Download
+		return true, m, func() tea.Msg {
+			ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+			defer cancel()
+			upid, err := client.DownloadURL(ctx, d.Node, d.Storage, d.Content, d.URL, d.Filename)
+			return downloadStartedMsg{seq: seq, upid: upid, err: err}
+		}
+	}
+	return true, m, nil
+}
@@ -0,0 +1,124 @@
+package mainlist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// storageClient returns the e2e cluster with an ISO store shared by both
+// nodes and a local store on each
+func storageClient() *MockClient {
+	client := e2eClient()
+	nfs := models.Storage{Name: "nfs-iso", Type: "nfs", Content: []string{"iso"}, Shared: true}
+	local := models.Storage{Name: "local", Type: "dir", Content: []string{"iso", "vztmpl", "backup"}}
+	client.Storages = map[string][]models.Storage{}
+	for _, node := range []string{"pve1", "pve2"} {
+		n, l := nfs, local
+		n.Node, l.Node = node, node
+		client.Storages[node] = []models.Storage{l, n}
+	}
+	client.Volumes = map[string][]models.StorageVolume{
+		"pve1/local/iso":    {{VolID: "local:iso/debian-12.iso", Node: "pve1", Storage: "local", Content: "iso", Size: 600 << 20}},
+		"pve1/local/vztmpl": {{VolID: "local:vztmpl/alpine.tar.xz", Node: "pve1", Storage: "local", Content: "vztmpl", Size: 3 << 20}},
+		"pve1/nfs-iso/iso":  {{VolID: "nfs-iso:iso/ubuntu.iso", Node: "pve1", Storage: "nfs-iso", Content: "iso", Size: 2 << 30}},
+		"pve2/nfs-iso/iso":  {{VolID: "nfs-iso:iso/ubuntu.iso", Node: "pve2", Storage: "nfs-iso", Content: "iso", Size: 2 << 30}},
+	}
+	return client
+}
+
+// newStorageDriver boots the e2e driver with the storage screen enabled
+func newStorageDriver(t *testing.T, client *MockClient) *driver {
+	d := newDriver(t, client)
+	d.ml.storageManager = client
+	d.ml.taskManager = client
+	return d
+}
+
+func TestStorage_ListAndDelete(t *testing.T) {
+	client := storageClient()
+	d := newStorageDriver(t, client)
+	ml := d.ml
+
+	d.key("I")
+	view := ml.model.View()
+	if !strings.Contains(view, "ISO Images & Templates (3)") {
+		t.Fatalf("The shared storage should be listed once:\n%s", view)
+	}
+	for _, want := range []string{"debian-12.iso", "alpine.tar.xz", "ubuntu.iso"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %s:\n%s", want, view)
+		}
+	}
+
+	d.key("x", "n")
+	if len(client.Deleted) != 0 {
+		t.Fatal("Any key but y should cancel")
+	}
+	d.key("x", "y")
+	if len(client.Deleted) != 1 || client.Deleted[0] != "local:iso/debian-12.iso" {
+		t.Fatalf("Expected the selected ISO to be deleted, got %v", client.Deleted)
+	}
+	if view := ml.model.View(); !strings.Contains(view, "Deleted debian-12.iso from local") {
+		t.Errorf("Expected the outcome:\n%s", view)
+	}
+
+	d.key("esc")
+	if ml.model.storage != nil {
+		t.Fatal("ESC should close the screen")
+	}
+}
+
+func TestStorage_PartialFailure(t *testing.T) {
+	client := storageClient()
+	client.TaskErr = errors.New("status 403")
+	client.Storages["pve1"] = client.Storages["pve1"][:1]
+	d := newStorageDriver(t, client)
+
+	d.key("I")
+	view := d.ml.model.View()
+	if !strings.Contains(view, "debian-12.iso") || !strings.Contains(view, "Some storages failed: status 403") {
+		t.Errorf("pve1's volumes should show alongside pve2's error:\n%s", view)
+	}
+}
+
+func TestStorage_Download(t *testing.T) {
+	client := storageClient()
+	client.TaskState = models.TaskStatus{Running: true}
+	client.TaskLog = []models.TaskLogLine{{N: 1, Text: "downloading https://example.com/alpine.iso"}}
+	d := newStorageDriver(t, client)
+	ml := d.ml
+
+	d.key("I", "a")
+	for _, r := range "https://example.com/alpine.iso" {
+		d.key(string(r))
+	}
+	d.key("enter")
+	if len(client.Downloads) != 1 || client.Downloads[0] != "local iso https://example.com/alpine.iso alpine.iso" {
+		t.Fatalf("Unexpected download request %v", client.Downloads)
+	}
+	if !ml.model.storage.Downloading() {
+		t.Fatal("The download should be polled")
+	}
+
+	d.send(downloadTickMsg{seq: ml.model.storageSeq})
+	if view := ml.model.View(); !strings.Contains(view, "Downloading alpine.iso: downloading https://example.com/alpine.iso") {
+		t.Errorf("Expected the progress:\n%s", view)
+	}
+
+	client.TaskState = models.TaskStatus{ExitStatus: "OK"}
+	d.send(downloadTickMsg{seq: ml.model.storageSeq})
+	if view := ml.model.View(); !strings.Contains(view, "Downloaded alpine.iso to local on pve1") {
+		t.Errorf("Expected the outcome:\n%s", view)
+	}
+}
+
+func TestStorage_NotAvailable(t *testing.T) {
+	d := newDriver(t, storageClient())
+	d.key("I")
+	if view := d.ml.model.View(); !strings.Contains(view, "client not available") {
+		t.Errorf("Expected the screen to explain it can't list:\n%s", view)
+	}
+}
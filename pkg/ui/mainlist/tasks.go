@@ -0,0 +1,180 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+	"github.com/tsupplis/pvec/pkg/ui/tasks"
+)
+
+// taskPollInterval is how often the task screen re-reads the running tasks
+// and the open log
+const taskPollInterval = 2 * time.Second
+
+// taskRequestTimeout bounds each task list, log or stop request
+const taskRequestTimeout = 10 * time.Second
+
+// tasksListedMsg carries the running tasks of every node
+type tasksListedMsg struct {
+	seq   int
+	tasks []models.Task
+	err   error
+}
+
+// taskLogMsg carries log lines read from line start on
+type taskLogMsg struct {
+	seq   int
+	upid  string
+	start int
+	lines []models.TaskLogLine
+	err   error
+}
+
+// taskStopMsg reports the outcome of a stop request
+type taskStopMsg struct {
+	seq int
+	err error
+}
+
+// tasksTickMsg triggers the next poll of the task screen
+type tasksTickMsg struct {
+	seq int
+}
+
+// handleTasksKey opens the running task screen
+func (m *listModel) handleTasksKey() (bool, tea.Model, tea.Cmd) {
+	state := tasks.New()
+	m.tasks = &state
+	m.tasksSeq++
+	if m.parent.taskManager == nil {
+		m.tasks.SetTasks(nil, fmt.Errorf("client not available"))
+		return true, m, nil
+	}
+	return true, m, tea.Batch(m.listTasksCmd(), m.tasksTickCmd())
+}
+
+// listTasksCmd reads the running tasks of every node of the cluster, with
+// or without guests, and of the nodes the guests were listed on before the
+// cluster was read. A node that fails doesn't hide the tasks of the others.
+func (m *listModel) listTasksCmd() tea.Cmd {
+	seen := make(map[string]bool)
+	var nodes []string
+	add := func(node string) {
+		if node != "" && !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	for _, n := range m.parent.clusterNodes() {
+		add(n.Name)
+	}
+	m.parent.refreshMutex.Lock()
+	for _, vm := range m.parent.guests.All() {
+		add(vm.Node)
+	}
+	m.parent.refreshMutex.Unlock()
+	sort.Strings(nodes)
+
+	client, seq := m.parent.taskManager, m.tasksSeq
+	return func() tea.Msg {
+		all := []models.Task{}
+		var firstErr error
+		for _, node := range nodes {
+			ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+			list, err := client.GetRunningTasks(ctx, node)
+			cancel()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			all = append(all, list...)
+		}
+		if firstErr != nil && len(all) == 0 {
+			all = nil
+		}
+		return tasksListedMsg{seq: seq, tasks: all, err: firstErr}
+	}
+}
+
+// readLogCmd reads the open task's log past the lines already shown
+func (m *listModel) readLogCmd() tea.Cmd {
+	if m.tasks == nil || m.tasks.Log == nil {
+		return nil
+	}
+	client, seq := m.parent.taskManager, m.tasksSeq
+	task, start := *m.tasks.Log, m.tasks.NextLine()
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+		defer cancel()
+		lines, err := client.GetTaskLog(ctx, task.Node, task.UPID, start)
+		return taskLogMsg{seq: seq, upid: task.UPID, start: start, lines: lines, err: err}
+	}
+}
+
+// tasksTickCmd schedules the next poll
+func (m *listModel) tasksTickCmd() tea.Cmd {
+	seq := m.tasksSeq
+	return tea.Tick(taskPollInterval, func(time.Time) tea.Msg {
+		return tasksTickMsg{seq: seq}
+	})
+}
+
+// handleTasksTick polls the task list and the open log while the screen
+// stays open
+func (m *listModel) handleTasksTick(msg tasksTickMsg) (tea.Model, tea.Cmd) {
+	if m.tasks == nil || msg.seq != m.tasksSeq {
+		return m, nil
+	}
+	return m, tea.Batch(m.listTasksCmd(), m.readLogCmd(), m.tasksTickCmd())
+}
+
+// handleTasksListed updates the task list
+func (m *listModel) handleTasksListed(msg tasksListedMsg) (tea.Model, tea.Cmd) {
+	if m.tasks != nil && msg.seq == m.tasksSeq {
+		m.tasks.SetTasks(msg.tasks, msg.err)
+	}
+	return m, nil
+}
+
+// handleTaskLog appends freshly read log lines
+func (m *listModel) handleTaskLog(msg taskLogMsg) (tea.Model, tea.Cmd) {
+	if m.tasks != nil && msg.seq == m.tasksSeq {
+		m.tasks.AppendLog(msg.upid, msg.start, msg.lines, msg.err, m.height)
+	}
+	return m, nil
+}
+
+// handleTaskStop records the outcome of a stop and re-reads the list
+func (m *listModel) handleTaskStop(msg taskStopMsg) (tea.Model, tea.Cmd) {
+	if m.tasks == nil || msg.seq != m.tasksSeq {
+		return m, nil
+	}
+	m.tasks.StopDone(msg.err)
+	return m, m.listTasksCmd()
+}
+
+// handleTasksKeys handles keys while the task screen is open
+func (m *listModel) handleTasksKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	switch m.tasks.HandleKey(msg.String(), m.height) {
+	case screen.Closed:
+		m.tasks = nil
+		m.tasksSeq++ // Stops the polling
+	case tasks.OpenLog:
+		return true, m, m.readLogCmd()
+	case tasks.StopConfirmed:
+		client, seq, task := m.parent.taskManager, m.tasksSeq, *m.tasks.Target
+		return true, m, func() tea.Msg {
+			ctx, cancel := context.WithTimeout(context.Background(), taskRequestTimeout)
+			defer cancel()
+			return taskStopMsg{seq: seq, err: client.StopTask(ctx, task.Node, task.UPID)}
+		}
+	}
+	return true, m, nil
+}
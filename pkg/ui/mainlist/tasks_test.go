@@ -0,0 +1,126 @@
+package mainlist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// tasksClient returns the e2e cluster with a backup running on pve1
+func tasksClient() *MockClient {
+	client := e2eClient()
+	client.Running = map[string][]models.Task{
+		"pve1": {{UPID: "UPID:pve1:1", Node: "pve1", Type: "vzdump", ID: "100", User: "root@pam", StartTime: time.Now().Add(-time.Minute)}},
+	}
+	client.TaskLog = []models.TaskLogLine{{N: 1, Text: "INFO: starting new backup job"}}
+	return client
+}
+
+// newTasksDriver boots the e2e driver with the task screen enabled
+func newTasksDriver(t *testing.T, client *MockClient) *driver {
+	d := newDriver(t, client)
+	d.ml.taskManager = client
+	return d
+}
+
+func TestTasks_ListAndTail(t *testing.T) {
+	client := tasksClient()
+	d := newTasksDriver(t, client)
+	ml := d.ml
+
+	d.key("T")
+	view := ml.model.View()
+	if !strings.Contains(view, "Running Tasks (1)") || !strings.Contains(view, "vzdump 100") {
+		t.Fatalf("Expected the backup in the task list:\n%s", view)
+	}
+
+	d.key("enter")
+	if view := ml.model.View(); !strings.Contains(view, "starting new backup job") {
+		t.Fatalf("Expected the task log:\n%s", view)
+	}
+
+	// A poll appends only the new lines
+	client.TaskLog = append(client.TaskLog, models.TaskLogLine{N: 2, Text: "INFO: status: 25%"})
+	d.send(tasksTickMsg{seq: ml.model.tasksSeq})
+	if got := len(ml.model.tasks.Lines); got != 2 {
+		t.Errorf("Expected 2 log lines after the poll, got %d", got)
+	}
+
+	// The backup finishes and leaves the running list
+	client.Running = nil
+	d.send(tasksTickMsg{seq: ml.model.tasksSeq})
+	if view := ml.model.View(); !strings.Contains(view, "Task finished") {
+		t.Errorf("Expected the task to show as finished:\n%s", view)
+	}
+
+	d.key("esc", "esc")
+	if ml.model.tasks != nil {
+		t.Fatal("ESC should go back to the list, then close the screen")
+	}
+	if _, cmd := ml.model.Update(tasksTickMsg{seq: ml.model.tasksSeq - 1}); cmd != nil {
+		t.Error("Polling should stop once the screen is closed")
+	}
+}
+
+func TestTasks_Stop(t *testing.T) {
+	client := tasksClient()
+	d := newTasksDriver(t, client)
+	ml := d.ml
+
+	d.key("T", "x")
+	if view := ml.model.View(); !strings.Contains(view, "Stop vzdump 100 on pve1? (y/n)") {
+		t.Fatalf("Expected a confirmation:\n%s", view)
+	}
+	d.key("n")
+	if len(client.Stopped) != 0 || ml.model.tasks.Confirm {
+		t.Fatal("Any key but y should cancel")
+	}
+
+	d.key("x", "y")
+	if len(client.Stopped) != 1 || client.Stopped[0] != "UPID:pve1:1" {
+		t.Errorf("Expected the backup to be stopped, got %v", client.Stopped)
+	}
+	if view := ml.model.View(); !strings.Contains(view, "Stop of vzdump 100 requested") {
+		t.Errorf("Expected the stop to be reported:\n%s", view)
+	}
+}
+
+func TestTasks_NodeFailure(t *testing.T) {
+	client := tasksClient()
+	client.TaskErr = errors.New("status 403")
+	d := newTasksDriver(t, client)
+
+	d.key("T")
+	view := d.ml.model.View()
+	if !strings.Contains(view, "vzdump 100") || !strings.Contains(view, "Some nodes failed: status 403") {
+		t.Errorf("A failing node should not hide the others' tasks:\n%s", view)
+	}
+
+	// Without a client the screen says so
+	ml := NewMainList(Config{Provider: client, Reader: client})
+	if _, cmd := ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("T")}); cmd != nil {
+		t.Error("Nothing should be fetched without a client")
+	}
+	if view := ml.model.View(); !strings.Contains(view, "client not available") {
+		t.Errorf("Expected the missing client to be reported:\n%s", view)
+	}
+}
+
+func TestTasks_NodeWithoutGuests(t *testing.T) {
+	client := tasksClient()
+	client.Running["pve3"] = []models.Task{{UPID: "UPID:pve3:1", Node: "pve3", Type: "aptupdate", User: "root@pam", StartTime: time.Now()}}
+	d := newTasksDriver(t, client)
+	d.ml.cluster = &proxmox.RefreshSnapshot{Nodes: []models.ClusterNode{
+		{Name: "pve1", Online: true}, {Name: "pve2", Online: true}, {Name: "pve3", Online: true},
+	}}
+
+	d.key("T")
+	if view := d.ml.model.View(); !strings.Contains(view, "Running Tasks (2)") || !strings.Contains(view, "aptupdate") {
+		t.Errorf("Expected the task of the node without guests:\n%s", view)
+	}
+}
@@ -0,0 +1,166 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+	"github.com/tsupplis/pvec/pkg/ui/undolist"
+)
+
+// undoState is the undo of a stop, starting the guest again, shown in the
+// status bar
+type undoState struct {
+	guest *models.VMStatus
+	done  bool
+	err   error
+}
+
+// undoResultMsg reports the outcome of an undo
+type undoResultMsg struct {
+	err error
+}
+
+// recordStop remembers a stop or shutdown that succeeded, so that it can
+// be undone. before is the guest's status when the action was sent.
+func (m *listModel) recordStop(vm *models.VMStatus, action string, before models.NodeState) {
+	m.parent.undo.Record(vm, action, before, m.parent.now())
+}
+
+// undoOffer returns the stop whose undo z offers, if any
+func (m *listModel) undoOffer() (actions.StoppedGuest, bool) {
+	return m.parent.undo.Offer(m.parent.now())
+}
+
+// undoOffered reports whether the undo of a stop of vm is offered
+func (m *listModel) undoOffered(vm *models.VMStatus) bool {
+	offer, ok := m.undoOffer()
+	return ok && offer.Guest.Key() == vm.Key()
+}
+
+// undoOfferText is the status bar notice offering to undo the last stop,
+// "" once its window is over
+func (m *listModel) undoOfferText() string {
+	offer, ok := m.undoOffer()
+	if !ok {
+		return ""
+	}
+	left := int((actions.UndoWindow - m.parent.now().Sub(offer.At)).Seconds())
+	past := "Stopped"
+	if offer.Action == "shutdown" {
+		past = "Shut down"
+	}
+	return fmt.Sprintf("%s %s (%s) - z to start it again (%ds), Z for all stops", past, offer.Guest.Name, offer.Guest.Key(), left)
+}
+
+// handleUndoKey undoes the stop offered in the status bar
+func (m *listModel) handleUndoKey() (bool, tea.Model, tea.Cmd) {
+	offer, ok := m.undoOffer()
+	if !ok {
+		return false, m, nil
+	}
+	return true, m, m.startUndo(offer.Guest)
+}
+
+// startUndo starts a guest pvec stopped again, once its status confirms
+// it is still stopped
+func (m *listModel) startUndo(vm *models.VMStatus) tea.Cmd {
+	m.undo = &undoState{guest: vm}
+	executor, reader := m.parent.executor, m.parent.reader
+	current, listed := m.parent.guests.Get(vm.Key())
+	switch {
+	case executor == nil || reader == nil:
+		m.undo.err = fmt.Errorf("client not available")
+	case listed && current.NodeOffline:
+		m.undo.err = &nodeOfflineError{node: current.Node}
+	}
+	if m.undo.err != nil {
+		m.undo.done = true
+		return nil
+	}
+	status := func(ctx context.Context, key string) (models.NodeState, error) {
+		guest, err := reader.GetGuestStatus(ctx, vm.Node, vm.TypeString(), key)
+		if err != nil {
+			return "", err
+		}
+		return guest.Status, nil
+	}
+	buffer, timeout := m.parent.undo, m.parent.actionTimeout()
+	m.parent.emit(ActionStarted{Action: "undo", VMID: vm.Key()})
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		err := guarded(func() error { return buffer.Undo(ctx, vm.Key(), status, executor) })
+		return undoResultMsg{err: err}
+	}
+}
+
+// handleUndoResult records the outcome of the undo and refreshes the guest
+func (m *listModel) handleUndoResult(msg undoResultMsg) (tea.Model, tea.Cmd) {
+	if m.undo == nil {
+		return m, nil
+	}
+	m.undo.done = true
+	m.undo.err = m.parent.logPanic(msg.err)
+	m.parent.emit(ActionCompleted{Action: "undo", VMID: m.undo.guest.Key(), Err: m.undo.err})
+	if m.undo.err != nil {
+		return m, nil
+	}
+	return m, m.parent.fetchGuestCmd(m.undo.guest)
+}
+
+// handleUndoKeys dismisses a finished undo with any key
+func (m *listModel) handleUndoKeys() (bool, tea.Model, tea.Cmd) {
+	if m.undo.done {
+		m.undo = nil
+	}
+	return true, m, nil
+}
+
+// statusText describes the undo for the status bar
+func (u *undoState) statusText() string {
+	name := fmt.Sprintf("%s (%s)", u.guest.Name, u.guest.Key())
+	switch {
+	case !u.done:
+		return fmt.Sprintf("Starting %s again...", name)
+	case u.err == nil:
+		return fmt.Sprintf("Started %s again. - Press any key", name)
+	case errors.Is(u.err, actions.ErrNotStopped):
+		return fmt.Sprintf("Left %s alone: %v. - Press any key", name, u.err)
+	}
+	if hint := proxmox.PermissionHint(u.err); hint != "" {
+		return fmt.Sprintf("Failed to start %s again: the token %s. - Press any key", name, hint)
+	}
+	return fmt.Sprintf("Failed to start %s again: %v. - Press any key", name, redact.Error(u.err))
+}
+
+// handleUndoListKey opens the screen of the guests pvec stopped
+func (m *listModel) handleUndoListKey() (bool, tea.Model, tea.Cmd) {
+	m.undoList = &undolist.State{}
+	return true, m, nil
+}
+
+// handleUndoListKeys handles keys while the screen of stops is open
+func (m *listModel) handleUndoListKeys(msg tea.KeyMsg) (bool, tea.Model, tea.Cmd) {
+	entries := m.parent.undo.Entries()
+	m.undoList.Clamp(len(entries))
+	switch m.undoList.HandleKey(msg.String(), len(entries)) {
+	case screen.Closed:
+		m.undoList = nil
+	case undolist.Undo:
+		entry := entries[m.undoList.Selected]
+		if !entry.CanUndo() {
+			return true, m, nil
+		}
+		// The outcome shows in the status bar of the list
+		m.undoList = nil
+		return true, m, m.startUndo(entry.Guest)
+	}
+	return true, m, nil
+}
@@ -0,0 +1,121 @@
+package mainlist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+// stopWeb stops web-1 (100) and dismisses the action's result, the guest
+// then reading as stopped
+func stopWeb(t *testing.T, client *MockClient) *driver {
+	t.Helper()
+	d := restartDriver(t, client)
+	client.Guest.Status = models.StateStopped
+	d.key("t")
+	if len(client.Killed) != 1 {
+		t.Fatalf("Expected web-1 to be stopped, got %v", client.Killed)
+	}
+	return d
+}
+
+func TestUndo_FromResult(t *testing.T) {
+	client := e2eClient()
+	d := stopWeb(t, client)
+	if bar := statusBar(d); !strings.Contains(bar, "Succeeded in stop 100. - z to undo, any other key to close") {
+		t.Errorf("Expected the undo offered with the result:\n%s", bar)
+	}
+
+	d.key("z")
+	if len(client.Started) != 1 || client.Started[0] != "100" {
+		t.Fatalf("z should start 100 again, got %v", client.Started)
+	}
+	if bar := statusBar(d); !strings.Contains(bar, "Started web-1 (100) again. - Press any key") {
+		t.Errorf("Expected the undo to be reported:\n%s", bar)
+	}
+
+	d.key("x")
+	d.key("z")
+	if len(client.Started) != 1 {
+		t.Errorf("A stop is undone once only, got %v", client.Started)
+	}
+}
+
+func TestUndo_Offer(t *testing.T) {
+	client := e2eClient()
+	d := stopWeb(t, client)
+	d.key("x")
+	if bar := statusBar(d); !strings.Contains(bar, "Stopped web-1 (100) - z to start it again (60s), Z for all stops") {
+		t.Errorf("Expected the undo offered in the status bar:\n%s", bar)
+	}
+
+	d.clock.Advance(actions.UndoWindow)
+	if bar := statusBar(d); strings.Contains(bar, "z to start it again") {
+		t.Errorf("The offer should end with its window:\n%s", bar)
+	}
+	d.key("z")
+	if len(client.Started) != 0 {
+		t.Errorf("z should do nothing once the offer is over, got %v", client.Started)
+	}
+
+	// The list of stops still undoes it
+	d.key("Z")
+	if view := d.ml.model.View(); !strings.Contains(view, "Stopped by pvec (1)") {
+		t.Fatalf("Expected the list of stops:\n%s", view)
+	}
+	d.key("enter")
+	if len(client.Started) != 1 || d.ml.model.undoList != nil {
+		t.Errorf("Enter should start the selected guest and close the list, got %v", client.Started)
+	}
+}
+
+func TestUndo_NotStopped(t *testing.T) {
+	client := e2eClient()
+	d := stopWeb(t, client)
+	d.key("x")
+
+	// Someone started it again in the meantime
+	client.Guest.Status = models.StateRunning
+	d.key("z")
+	if len(client.Started) != 0 {
+		t.Errorf("A running guest must be left alone, got %v", client.Started)
+	}
+	if bar := statusBar(d); !strings.Contains(bar, "Left web-1 (100) alone: web-1 is no longer stopped: it is running.") {
+		t.Errorf("Expected the undo to be skipped:\n%s", bar)
+	}
+}
+
+func TestUndo_Failed(t *testing.T) {
+	client := e2eClient()
+	d := stopWeb(t, client)
+	d.key("x")
+
+	client.ActionErr = errors.New("status 500")
+	d.key("z")
+	if bar := statusBar(d); !strings.Contains(bar, "Failed to start web-1 (100) again: status 500.") {
+		t.Errorf("Expected the failure:\n%s", bar)
+	}
+	d.key("x")
+	client.ActionErr = nil
+	d.key("z")
+	if bar := statusBar(d); !strings.Contains(bar, "Started web-1 (100) again.") {
+		t.Errorf("A failed undo should be tried again:\n%s", bar)
+	}
+}
+
+func TestUndo_OnlyStops(t *testing.T) {
+	client := e2eClient()
+	d := restartDriver(t, client)
+	d.key("t")
+	d.key("x")
+	selectGuest(t, d, "101") // Already stopped
+	d.key("t")
+	d.key("x")
+
+	if entries := d.ml.undo.Entries(); len(entries) != 1 || entries[0].Guest.VMID != "100" {
+		t.Errorf("Only the stop of a running guest can be undone, got %v", entries)
+	}
+}
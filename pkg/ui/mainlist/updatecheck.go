@@ -0,0 +1,58 @@
+package mainlist
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ReleaseCheck returns the version of a pvec release newer than the
+// running one, or "" when there is none
+type ReleaseCheck func(ctx context.Context) (string, error)
+
+// updateAvailableMsg carries the version of a newer pvec release
+type updateAvailableMsg struct {
+	version string
+}
+
+// updateCheckCmd looks for a newer release once, at startup. It never
+// delays the list, and a failed check shows nothing.
+func (ml *MainList) updateCheckCmd() tea.Cmd {
+	check := ml.updateCheck
+	if check == nil {
+		return nil
+	}
+	ctx := ml.ctx
+	return func() tea.Msg {
+		version, err := check(ctx)
+		if err != nil || version == "" {
+			return nil
+		}
+		return updateAvailableMsg{version: version}
+	}
+}
+
+// handleUpdateAvailable shows the notice until dismissed with x
+func (m *listModel) handleUpdateAvailable(msg updateAvailableMsg) (tea.Model, tea.Cmd) {
+	m.updateVersion = msg.version
+	return m, nil
+}
+
+// handleDismissUpdate hides the update notice, if shown, then the notice
+// of a server newer than pvec knows
+func (m *listModel) handleDismissUpdate() (bool, tea.Model, tea.Cmd) {
+	switch {
+	case m.updateVersion != "":
+		m.updateVersion = ""
+	case m.serverNotice:
+		m.serverNotice = false
+	default:
+		return false, m, nil
+	}
+	return true, m, nil
+}
+
+// updateText is the status bar while a newer release is announced
+func (m *listModel) updateText() string {
+	return "pvec " + m.updateVersion + " available - x to dismiss"
+}
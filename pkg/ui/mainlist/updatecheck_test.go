@@ -0,0 +1,52 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUpdateCheck_Notice(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.ml.updateCheck = func(ctx context.Context) (string, error) { return "1.5.0", nil }
+
+	d.send(d.ml.updateCheckCmd()())
+	if bar := statusBar(d); !strings.Contains(bar, "pvec 1.5.0 available - x to dismiss") {
+		t.Errorf("Expected the update notice in the status bar:\n%s", bar)
+	}
+
+	d.key("down")
+	if bar := statusBar(d); !strings.Contains(bar, "pvec 1.5.0 available") {
+		t.Errorf("The notice should stay until dismissed:\n%s", bar)
+	}
+
+	d.key("x")
+	if bar := statusBar(d); strings.Contains(bar, "available") || !strings.Contains(bar, "F1 Help") {
+		t.Errorf("x should bring the commands back:\n%s", bar)
+	}
+}
+
+func TestUpdateCheck_Silent(t *testing.T) {
+	for name, check := range map[string]ReleaseCheck{
+		"up to date":      func(ctx context.Context) (string, error) { return "", nil },
+		"network failure": func(ctx context.Context) (string, error) { return "", errors.New("dial tcp: i/o timeout") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := newDriver(t, e2eClient())
+			d.ml.updateCheck = check
+
+			d.send(d.ml.updateCheckCmd()())
+			if bar := statusBar(d); !strings.Contains(bar, "F1 Help") {
+				t.Errorf("Expected the usual status bar:\n%s", bar)
+			}
+		})
+	}
+}
+
+func TestUpdateCheck_Disabled(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	if cmd := d.ml.updateCheckCmd(); cmd != nil {
+		t.Error("Without update_check nothing should be checked")
+	}
+}
@@ -0,0 +1,67 @@
+package mainlist
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+)
+
+// defaultUptimeDrift caps how far uptimes run ahead of the data when the
+// list has no refresh interval
+const defaultUptimeDrift = time.Minute
+
+// markFetched records when the nodes of a full refresh were read; guest
+// updates since then are superseded
+func (ml *MainList) markFetched(now time.Time) {
+	ml.fetchedAt = now
+	ml.guestFetchedAt = make(map[string]time.Time)
+}
+
+// liveUptime returns a running guest's uptime as of now: the reported one
+// plus the time since it was read. The extra time stops growing at twice
+// the refresh interval, so a list that can't refresh doesn't pretend to
+// know better. Only the display uses it; the data stays as fetched.
+func (ml *MainList) liveUptime(node *models.VMStatus, now time.Time) int64 {
+	if !node.IsRunning() || node.Uptime <= 0 || node.Uptime > format.MaxUptime || !node.IsKnown(models.MetricUptime) {
+		return node.Uptime
+	}
+	at, ok := ml.guestFetchedAt[node.Key()]
+	if !ok {
+		at = ml.fetchedAt
+	}
+	if at.IsZero() {
+		return node.Uptime
+	}
+
+	limit := 2 * ml.refreshInterval
+	if limit <= 0 {
+		limit = defaultUptimeDrift
+	}
+	// Refreshes that found the guests unchanged count as reads
+	limit += max(ml.confirmedAt.Sub(at), 0)
+	elapsed := now.Sub(at)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	if elapsed > limit {
+		elapsed = limit
+	}
+	return node.Uptime + int64(elapsed/time.Second)
+}
+
+// updatedText tells how old the list is, for the title
+func (ml *MainList) updatedText(now time.Time) string {
+	if ml.fetchedAt.IsZero() {
+		return ""
+	}
+	age := now.Sub(ml.fetchedAt)
+	if age < 0 {
+		age = 0
+	}
+	if age < time.Minute {
+		return fmt.Sprintf("updated %ds ago", int(age/time.Second))
+	}
+	return fmt.Sprintf("updated %dm ago", int(age/time.Minute))
+}
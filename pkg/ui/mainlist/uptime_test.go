@@ -0,0 +1,86 @@
+package mainlist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+)
+
+func TestLiveUptime(t *testing.T) {
+	fetched := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ml := NewMainList(Config{Provider: &MockDataProvider{}, RefreshInterval: 30 * time.Second})
+	ml.markFetched(fetched)
+
+	running := &models.VMStatus{VMID: "100", Status: models.StateRunning, Uptime: 600}
+	stopped := &models.VMStatus{VMID: "101", Status: models.StateStopped}
+	unknown := &models.VMStatus{VMID: "102", Status: models.StateRunning, Uptime: 600, Missing: models.MetricUptime}
+
+	tests := []struct {
+		name  string
+		node  *models.VMStatus
+		after time.Duration
+		want  int64
+	}{
+		{"just fetched", running, 0, 600},
+		{"ticks", running, 25 * time.Second, 625},
+		{"clamped to twice the interval", running, 10 * time.Minute, 660},
+		{"clock went back", running, -time.Minute, 600},
+		{"stopped", stopped, time.Minute, 0},
+		{"unknown uptime", unknown, time.Minute, 600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ml.liveUptime(tt.node, fetched.Add(tt.after)); got != tt.want {
+				t.Errorf("liveUptime() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// A guest updated on its own counts from its own fetch
+	ml.guestFetchedAt["100"] = fetched.Add(20 * time.Second)
+	if got := ml.liveUptime(running, fetched.Add(25*time.Second)); got != 605 {
+		t.Errorf("A single guest update should restart the count, got %d", got)
+	}
+	ml.markFetched(fetched.Add(30 * time.Second))
+	if len(ml.guestFetchedAt) != 0 {
+		t.Error("A full refresh should supersede single guest updates")
+	}
+}
+
+func TestUpdatedText(t *testing.T) {
+	ml := NewMainList(Config{Provider: &MockDataProvider{}})
+	fetched := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if got := ml.updatedText(fetched); got != "" {
+		t.Errorf("Nothing was fetched yet, got %q", got)
+	}
+	ml.markFetched(fetched)
+	if got := ml.updatedText(fetched.Add(12 * time.Second)); got != "updated 12s ago" {
+		t.Errorf("Expected seconds, got %q", got)
+	}
+	if got := ml.updatedText(fetched.Add(3*time.Minute + 10*time.Second)); got != "updated 3m ago" {
+		t.Errorf("Expected minutes, got %q", got)
+	}
+}
+
+func TestE2E_UptimeTicks(t *testing.T) {
+	d := newDriver(t, e2eClient())
+	d.ml.refreshInterval = time.Minute
+
+	// Ninety seconds later with no refresh, the next tick redraws
+	later := e2eNow.Add(90 * time.Second)
+	d.clock.Set(later)
+	d.send(tickMsg(later))
+	d.snapshot("uptime_ticking")
+
+	view := d.ml.model.View()
+	for _, want := range []string{"updated 1m ago", "2h 1m", "11m"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q after 90s:\n%s", want, view)
+		}
+	}
+	if d.ml.guests.All()[2].Uptime != 7200 {
+		t.Errorf("The fetched data should not change, got uptime %d", d.ml.guests.All()[2].Uptime)
+	}
+}
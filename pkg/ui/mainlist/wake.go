@@ -0,0 +1,83 @@
+package mainlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// wakeState is a wake-on-LAN request for a node, shown in the status bar
+type wakeState struct {
+	node string
+	done bool
+	mac  string
+	err  error
+}
+
+// wakeResultMsg reports the outcome of a wake-on-LAN request
+type wakeResultMsg struct {
+	mac string
+	err error
+}
+
+// handleWakeKey wakes the selected guest's node. Guests of a powered-off
+// node are still listed, with an unknown status, so they stand in for the
+// node itself.
+func (m *listModel) handleWakeKey() (bool, tea.Model, tea.Cmd) {
+	m.parent.refreshMutex.Lock()
+	if m.parent.selectedIdx < 0 || m.parent.selectedIdx >= len(m.parent.sortedNodes) {
+		m.parent.refreshMutex.Unlock()
+		return true, m, nil
+	}
+	node := m.parent.sortedNodes[m.parent.selectedIdx].Node
+	m.parent.refreshMutex.Unlock()
+
+	m.wake = &wakeState{node: node}
+	client := m.parent.nodePower
+	if client == nil {
+		m.wake.done = true
+		m.wake.err = fmt.Errorf("client not available")
+		return true, m, nil
+	}
+	timeout := m.parent.actionTimeout()
+	return true, m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		mac, err := client.WakeNode(ctx, node)
+		return wakeResultMsg{mac: mac, err: err}
+	}
+}
+
+// handleWakeResult records the outcome of the request
+func (m *listModel) handleWakeResult(msg wakeResultMsg) (tea.Model, tea.Cmd) {
+	if m.wake != nil {
+		m.wake.done = true
+		m.wake.mac = msg.mac
+		m.wake.err = msg.err
+	}
+	return m, nil
+}
+
+// handleWakeKeys dismisses a finished request with any key
+func (m *listModel) handleWakeKeys() (bool, tea.Model, tea.Cmd) {
+	if m.wake.done {
+		m.wake = nil
+	}
+	return true, m, nil
+}
+
+// statusText describes the request for the status bar
+func (w *wakeState) statusText() string {
+	switch {
+	case !w.done:
+		return fmt.Sprintf("Waking %s...", w.node)
+	case errors.Is(w.err, proxmox.ErrNoWakeOnLAN):
+		return fmt.Sprintf("Cannot wake %s: %s. - Press any key", w.node, proxmox.WakeOnLANHint(w.node))
+	case w.err != nil:
+		return fmt.Sprintf("Failed to wake %s. - Press any key", w.node)
+	}
+	return fmt.Sprintf("Sent wake-on-LAN to %s (%s). - Press any key", w.node, w.mac)
+}
@@ -0,0 +1,58 @@
+package mainlist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+func TestWake(t *testing.T) {
+	client := e2eClient()
+	ml := NewMainList(Config{Provider: client, Reader: client, NodePower: client})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+	w := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("W")}
+
+	_, cmd := ml.model.Update(w)
+	if cmd == nil {
+		t.Fatal("W should send a wake request")
+	}
+	if view := ml.model.View(); !strings.Contains(view, "Waking pve1...") {
+		t.Errorf("Expected the request in progress:\n%s", view)
+	}
+	ml.model.Update(cmd())
+	if len(client.NodeCalls) != 1 || client.NodeCalls[0] != "wake pve1" {
+		t.Errorf("Expected a wake of pve1, got %v", client.NodeCalls)
+	}
+	if view := ml.model.View(); !strings.Contains(view, "Sent wake-on-LAN to pve1 (bc:24:11:7f:3a:02)") {
+		t.Errorf("Expected the targeted MAC:\n%s", view)
+	}
+	ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if ml.model.wake != nil {
+		t.Error("Any key should dismiss the result")
+	}
+
+	// No MAC configured: point at the node option rather than a bare error
+	client.ActionErr = fmt.Errorf("%w: status 501", proxmox.ErrNoWakeOnLAN)
+	_, cmd = ml.model.Update(w)
+	ml.model.Update(cmd())
+	if view := ml.model.View(); !strings.Contains(view, "pvenode config set --wakeonlan") {
+		t.Errorf("Expected a hint about the wakeonlan option:\n%s", view)
+	}
+}
+
+func TestWake_NoClient(t *testing.T) {
+	client := e2eClient()
+	ml := NewMainList(Config{Provider: client, Reader: client})
+	ml.model.Update(ml.fetchNodes(context.Background()))
+
+	if _, cmd := ml.model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("W")}); cmd != nil {
+		t.Error("No request should be sent without a client")
+	}
+	if view := ml.model.View(); !strings.Contains(view, "Failed to wake pve1") {
+		t.Errorf("Expected a failure:\n%s", view)
+	}
+}
@@ -0,0 +1,124 @@
+// Package nodepower is the confirmation dialog for rebooting or shutting
+// down a Proxmox node. The action only goes ahead once the node name has
+// been typed in full.
+package nodepower
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Action is the power command sent to the node
+type Action string
+
+const (
+	// Reboot restarts the node
+	Reboot Action = "reboot"
+	// Shutdown powers the node off
+	Shutdown Action = "shutdown"
+)
+
+// State is the dialog for one node
+type State struct {
+	Node    string
+	Running int // Guests running on the node, shown as a warning
+	Action  Action
+	Typed   string // Confirmation typed so far
+	Sending bool   // The command is in flight
+	Done    bool   // The command returned; any key closes the dialog
+	Err     error
+}
+
+// New opens the dialog for node, with reboot selected
+func New(node string, running int) State {
+	return State{Node: node, Running: running, Action: Reboot}
+}
+
+// HandleKey updates the dialog for a key press
+func (s *State) HandleKey(msg tea.KeyMsg) screen.Outcome {
+	if s.Done {
+		return screen.Closed
+	}
+	if s.Sending {
+		return screen.Pending
+	}
+
+	switch msg.Type {
+	case tea.KeyEsc:
+		return screen.Closed
+	case tea.KeyEnter:
+		if s.Typed == s.Node {
+			return screen.Confirmed
+		}
+	case tea.KeyTab, tea.KeyShiftTab, tea.KeyLeft, tea.KeyRight:
+		if s.Action == Reboot {
+			s.Action = Shutdown
+		} else {
+			s.Action = Reboot
+		}
+	case tea.KeyBackspace:
+		if s.Typed != "" {
+			runes := []rune(s.Typed)
+			s.Typed = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes:
+		s.Typed += string(msg.Runes)
+	}
+	return screen.Pending
+}
+
+// GetConfirmText renders the dialog
+func GetConfirmText(s State, width, height int) string {
+	guests := "no running guests"
+	switch {
+	case s.Running == 1:
+		guests = "1 running guest"
+	case s.Running > 1:
+		guests = fmt.Sprintf("%d running guests", s.Running)
+	}
+
+	choice := func(a Action, label string) string {
+		if s.Action == a {
+			return "[" + label + "]"
+		}
+		return " " + label + " "
+	}
+
+	body := []string{
+		"",
+		fmt.Sprintf("  Node %s has %s.", s.Node, guests),
+		"",
+		"  Action:  " + choice(Reboot, "Reboot") + "  " + choice(Shutdown, "Shutdown"),
+		"",
+		fmt.Sprintf("  Type the node name to confirm: %s_", s.Typed),
+	}
+
+	status := "Tab: Switch action | Enter: Confirm | ESC: Cancel"
+	switch {
+	case s.Done && s.Err != nil:
+		body = append(body, "", fmt.Sprintf("  Failed to %s %s: %v", s.Action, s.Node, redact.Error(s.Err)))
+		status = "Press any key to close"
+	case s.Done:
+		body = append(body, "", fmt.Sprintf("  %s of %s requested.", actionTitle(s.Action), s.Node))
+		status = "Press any key to close"
+	case s.Sending:
+		status = fmt.Sprintf("Sending %s to %s...", s.Action, s.Node)
+	case s.Typed != "" && s.Typed != s.Node:
+		status = fmt.Sprintf("Type %s exactly to confirm | ESC: Cancel", s.Node)
+	}
+
+	title := fmt.Sprintf("Node Power - %s", s.Node)
+	return format.Frame(title, body, status, width, height)
+}
+
+// actionTitle returns the action as a sentence start
+func actionTitle(a Action) string {
+	if a == Shutdown {
+		return "Shutdown"
+	}
+	return "Reboot"
+}
@@ -0,0 +1,92 @@
+package nodepower
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+func typeText(s *State, text string) {
+	for _, r := range text {
+		s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+func TestHandleKey_RequiresNodeName(t *testing.T) {
+	s := New("pve1", 3)
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	if got := s.HandleKey(enter); got != screen.Pending {
+		t.Errorf("Enter without a name should not confirm, got %v", got)
+	}
+	typeText(&s, "pve")
+	if got := s.HandleKey(enter); got != screen.Pending {
+		t.Errorf("A partial name should not confirm, got %v", got)
+	}
+	typeText(&s, "12")
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyBackspace})
+	if s.Typed != "pve1" {
+		t.Fatalf("Backspace should delete the last character, got %q", s.Typed)
+	}
+	if got := s.HandleKey(enter); got != screen.Confirmed {
+		t.Errorf("The exact name should confirm, got %v", got)
+	}
+}
+
+func TestHandleKey_ActionAndCancel(t *testing.T) {
+	s := New("pve1", 0)
+	if s.Action != Reboot {
+		t.Errorf("Reboot should be selected first, got %s", s.Action)
+	}
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyTab})
+	if s.Action != Shutdown {
+		t.Errorf("Tab should switch to shutdown, got %s", s.Action)
+	}
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyLeft})
+	if s.Action != Reboot {
+		t.Errorf("Arrows should switch back, got %s", s.Action)
+	}
+
+	if got := s.HandleKey(tea.KeyMsg{Type: tea.KeyEsc}); got != screen.Closed {
+		t.Errorf("ESC should cancel, got %v", got)
+	}
+
+	s.Sending = true
+	if got := s.HandleKey(tea.KeyMsg{Type: tea.KeyEsc}); got != screen.Pending {
+		t.Errorf("Keys are ignored while the command is in flight, got %v", got)
+	}
+	s.Sending, s.Done = false, true
+	if got := s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")}); got != screen.Closed {
+		t.Errorf("Any key should close a finished dialog, got %v", got)
+	}
+}
+
+func TestGetConfirmText(t *testing.T) {
+	s := New("pve1", 5)
+	view := GetConfirmText(s, 80, 24)
+	for _, want := range []string{"Node Power - pve1", "Node pve1 has 5 running guests.", "[Reboot]", "Type the node name"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Dialog should contain %q:\n%s", want, view)
+		}
+	}
+	if lines := strings.Split(view, "\n"); len(lines) != 24 {
+		t.Errorf("Dialog should fill 24 lines, got %d", len(lines))
+	}
+
+	if view := GetConfirmText(New("pve2", 1), 80, 24); !strings.Contains(view, "1 running guest.") {
+		t.Errorf("Expected the singular form:\n%s", view)
+	}
+
+	typeText(&s, "pve2")
+	if view := GetConfirmText(s, 80, 24); !strings.Contains(view, "Type pve1 exactly to confirm") {
+		t.Errorf("A wrong name should be pointed out:\n%s", view)
+	}
+
+	s.Done, s.Err = true, errors.New("status 403")
+	if view := GetConfirmText(s, 80, 24); !strings.Contains(view, "Failed to reboot pve1: status 403") {
+		t.Errorf("Expected the error:\n%s", view)
+	}
+}
@@ -0,0 +1,187 @@
+// Package nodesummary is the screen listing the nodes of the cluster with
+// their load and how many of their cores and how much of their memory the
+// guests are given, so overcommitted nodes stand out.
+package nodesummary
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/probe"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Thresholds are the overcommit ratios, in percent of the node's cores
+// and memory, from which a row is highlighted
+type Thresholds struct {
+	CPU float64
+	Mem float64
+}
+
+// Row is one line of the summary
+type Row struct {
+	Text string
+	Over bool // An allocation reaches a threshold
+}
+
+// State is the node summary screen
+type State struct {
+	Thresholds Thresholds
+	Scroll     int
+}
+
+// New opens the screen
+func New(thresholds Thresholds) State {
+	return State{Thresholds: thresholds}
+}
+
+// HandleKey updates the screen for a key press; count is the number of
+// rows shown
+func (s *State) HandleKey(key string, count, height int) screen.Outcome {
+	rows := format.FrameRows(height)
+	switch key {
+	case "esc", "q", "n":
+		return screen.Closed
+	case "up", "k":
+		s.Scroll = format.ClampOffset(s.Scroll-1, count, rows)
+	case "down", "j":
+		s.Scroll = format.ClampOffset(s.Scroll+1, count, rows)
+	case "pgup":
+		s.Scroll = format.ClampOffset(s.Scroll-rows, count, rows)
+	case "pgdown":
+		s.Scroll = format.ClampOffset(s.Scroll+rows, count, rows)
+	case "home", "g":
+		s.Scroll = 0
+	case "end", "G":
+		s.Scroll = format.ClampOffset(count, count, rows)
+	}
+	return screen.Pending
+}
+
+// Rows builds the summary: per node, its load and the outcome of its
+// network probe, if it has one, then what every defined guest is given
+// and what the running ones are given, leaving out the guests in an
+// unknown state. Nodes the cluster didn't report
+// but that host guests or are probed are listed without their size.
+func Rows(nodes []models.ClusterNode, guests []*models.VMStatus, probes []probe.Result, th Thresholds) []Row {
+	alloc := models.AllocationByNode(guests)
+	all := append([]models.ClusterNode(nil), nodes...)
+	for name := range alloc {
+		if !hasNode(all, name) {
+			all = append(all, models.ClusterNode{Name: name, Online: true})
+		}
+	}
+	for _, p := range probes {
+		if !hasNode(all, p.Node) {
+			all = append(all, models.ClusterNode{Name: p.Node, Online: true})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+
+	var rows []Row
+	for i, n := range all {
+		if i > 0 {
+			rows = append(rows, Row{})
+		}
+		line := Row{Text: nodeLine(n)}
+		if p, ok := probe.Find(probes, n.Name); ok {
+			line.Text += ", probe " + probe.Text(p)
+			line.Over = !p.OK()
+		}
+		rows = append(rows, line)
+		a := alloc[n.Name]
+		for _, fig := range []struct {
+			label string
+			alloc models.Allocation
+		}{{"defined", a.Defined}, {"running", a.Running}} {
+			over := fig.alloc.CPURatio(n) >= th.CPU || fig.alloc.MemRatio(n) >= th.Mem
+			rows = append(rows, Row{
+				Text: fmt.Sprintf("  %-8s alloc %s, %s", fig.label, coresText(fig.alloc, n), memText(fig.alloc, n)),
+				Over: over,
+			})
+		}
+		if a.Unknown > 0 {
+			rows = append(rows, Row{Text: fmt.Sprintf("  %d guest(s) in an unknown state not counted", a.Unknown)})
+		}
+	}
+	return rows
+}
+
+// hasNode reports whether nodes lists the named node, ignoring case as
+// probe names are
+func hasNode(nodes []models.ClusterNode, name string) bool {
+	for _, n := range nodes {
+		if strings.EqualFold(n.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeLine describes a node and its load
+func nodeLine(n models.ClusterNode) string {
+	switch {
+	case !n.Online:
+		return fmt.Sprintf("%s  offline", n.Name)
+	case n.MaxCPU <= 0 || n.MaxMem <= 0:
+		return fmt.Sprintf("%s  size unknown", n.Name)
+	}
+	return fmt.Sprintf("%s  CPU %s of %d cores, Mem %s of %s GiB",
+		n.Name, format.Percent(n.CPUUsage, 1), n.MaxCPU, format.Percent(n.MemoryPercent(), 1), gib(n.MaxMem))
+}
+
+// coresText renders "48/32 cores (150%)", or "48 cores" when the node's
+// cores are unknown
+func coresText(a models.Allocation, n models.ClusterNode) string {
+	if n.MaxCPU <= 0 {
+		return fmt.Sprintf("%d cores", a.Cores)
+	}
+	return fmt.Sprintf("%d/%d cores (%.0f%%)", a.Cores, n.MaxCPU, a.CPURatio(n))
+}
+
+// memText renders "96/64 GiB (150%)", or "96 GiB" when the node's memory
+// is unknown
+func memText(a models.Allocation, n models.ClusterNode) string {
+	if n.MaxMem <= 0 {
+		return fmt.Sprintf("%s GiB", gib(a.Mem))
+	}
+	return fmt.Sprintf("%s/%s GiB (%.0f%%)", gib(a.Mem), gib(n.MaxMem), a.MemRatio(n))
+}
+
+// gib renders bytes in GiB, without decimals when they are whole
+func gib(bytes int64) string {
+	v := float64(bytes) / (1 << 30)
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+// GetText renders the summary
+func GetText(s State, nodes []models.ClusterNode, guests []*models.VMStatus, probes []probe.Result, width, height int) string {
+	overStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFA500"))
+	var body []string
+	for _, row := range Rows(nodes, guests, probes, s.Thresholds) {
+		text := row.Text
+		switch {
+		case row.Over && format.Color():
+			text = overStyle.Render(format.Truncate(text, width))
+		case row.Over:
+			text = format.Truncate(text+" [!]", width)
+		default:
+			text = format.Truncate(text, width)
+		}
+		body = append(body, text)
+	}
+	if len(body) == 0 {
+		body = append(body, "  No nodes listed yet")
+	}
+
+	status := fmt.Sprintf("Highlighted from %.0f%% of the cores or %.0f%% of the memory  ESC=Close", s.Thresholds.CPU, s.Thresholds.Mem)
+	return format.FrameAt("Node Summary", body, format.Text(status), width, height, s.Scroll)
+}
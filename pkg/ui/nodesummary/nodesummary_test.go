@@ -0,0 +1,166 @@
+package nodesummary
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/probe"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+var thresholds = Thresholds{CPU: 200, Mem: 100}
+
+func sampleNodes() []models.ClusterNode {
+	return []models.ClusterNode{
+		{Name: "pve2", Online: true, CPUUsage: 12.5, MaxCPU: 32, Mem: 16 << 30, MaxMem: 64 << 30},
+		{Name: "pve1", Online: true, MaxCPU: 16, MaxMem: 32 << 30},
+		{Name: "pve3"},
+	}
+}
+
+func sampleGuests() []*models.VMStatus {
+	return []*models.VMStatus{
+		{VMID: "100", Node: "pve2", Status: models.StateRunning, MaxCPU: 24, MaxMem: 48 << 30},
+		{VMID: "101", Node: "pve2", Status: models.StateStopped, MaxCPU: 24, MaxMem: 48 << 30},
+		{VMID: "102", Node: "pve1", Status: models.StateRunning, MaxCPU: 40, MaxMem: 8 << 30},
+		{VMID: "103", Node: "pve4", Status: models.StateRunning, MaxCPU: 2, MaxMem: 3 << 29},
+	}
+}
+
+func TestRows(t *testing.T) {
+	rows := Rows(sampleNodes(), sampleGuests(), nil, thresholds)
+
+	var texts []string
+	over := map[string]bool{}
+	for _, row := range rows {
+		texts = append(texts, row.Text)
+		if row.Over {
+			over[row.Text] = true
+		}
+	}
+	got := strings.Join(texts, "\n")
+	want := strings.Join([]string{
+		"pve1  CPU 0.0% of 16 cores, Mem 0.0% of 32 GiB",
+		"  defined  alloc 40/16 cores (250%), 8/32 GiB (25%)",
+		"  running  alloc 40/16 cores (250%), 8/32 GiB (25%)",
+		"",
+		"pve2  CPU 12.5% of 32 cores, Mem 25.0% of 64 GiB",
+		"  defined  alloc 48/32 cores (150%), 96/64 GiB (150%)",
+		"  running  alloc 24/32 cores (75%), 48/64 GiB (75%)",
+		"",
+		"pve3  offline",
+		"  defined  alloc 0 cores, 0 GiB",
+		"  running  alloc 0 cores, 0 GiB",
+		"",
+		"pve4  size unknown",
+		"  defined  alloc 2 cores, 1.5 GiB",
+		"  running  alloc 2 cores, 1.5 GiB",
+	}, "\n")
+	if got != want {
+		t.Errorf("Unexpected rows:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, text := range []string{
+		"  defined  alloc 40/16 cores (250%), 8/32 GiB (25%)",
+		"  running  alloc 40/16 cores (250%), 8/32 GiB (25%)",
+		"  defined  alloc 48/32 cores (150%), 96/64 GiB (150%)",
+	} {
+		if !over[text] {
+			t.Errorf("%q should be highlighted", text)
+		}
+	}
+	if len(over) != 3 {
+		t.Errorf("Only the rows reaching a threshold should be highlighted, got %v", over)
+	}
+}
+
+func TestRows_Unknown(t *testing.T) {
+	guests := append(sampleGuests(), &models.VMStatus{VMID: "104", Node: "pve1", Status: models.StateUnknown, MaxCPU: 8, MaxMem: 8 << 30})
+	rows := Rows(sampleNodes()[1:2], guests, nil, thresholds)
+
+	var texts []string
+	for _, row := range rows {
+		texts = append(texts, row.Text)
+	}
+	got := strings.Join(texts, "\n")
+	want := strings.Join([]string{
+		"pve1  CPU 0.0% of 16 cores, Mem 0.0% of 32 GiB",
+		"  defined  alloc 40/16 cores (250%), 8/32 GiB (25%)",
+		"  running  alloc 40/16 cores (250%), 8/32 GiB (25%)",
+		"  1 guest(s) in an unknown state not counted",
+	}, "\n")
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("Unexpected rows:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRows_Probes(t *testing.T) {
+	probes := []probe.Result{
+		{Node: "pve1", Addr: "10.0.0.1:22", Latency: 3 * time.Millisecond},
+		{Node: "pve2", Addr: "10.0.0.2:22", Err: errors.New("dial tcp 10.0.0.2:22: i/o timeout")},
+		{Node: "pve5", Addr: "10.0.0.5:22", Latency: 7 * time.Millisecond},
+	}
+	rows := Rows(sampleNodes()[:2], nil, probes, thresholds)
+
+	var heads []string
+	for _, row := range rows {
+		if row.Text != "" && !strings.HasPrefix(row.Text, " ") {
+			heads = append(heads, row.Text)
+			if row.Over != strings.Contains(row.Text, "unreachable") {
+				t.Errorf("Only an unreachable node should be highlighted: %q", row.Text)
+			}
+		}
+	}
+	want := []string{
+		"pve1  CPU 0.0% of 16 cores, Mem 0.0% of 32 GiB, probe 3ms",
+		"pve2  CPU 12.5% of 32 cores, Mem 25.0% of 64 GiB, probe unreachable: i/o timeout",
+		"pve5  size unknown, probe 7ms",
+	}
+	if strings.Join(heads, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, heads)
+	}
+}
+
+func TestGetText_NoColor(t *testing.T) {
+	format.SetColor(false)
+	defer format.SetColor(true)
+
+	view := GetText(New(thresholds), sampleNodes(), sampleGuests(), nil, 80, 24)
+	for _, want := range []string{
+		"Node Summary",
+		"96/64 GiB (150%) [!]",
+		"Highlighted from 200% of the cores or 100% of the memory",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+	if strings.Contains(view, "48/64 GiB (75%) [!]") {
+		t.Errorf("The running figure is below the thresholds:\n%s", view)
+	}
+}
+
+func TestGetText_Empty(t *testing.T) {
+	if view := GetText(New(thresholds), nil, nil, nil, 80, 24); !strings.Contains(view, "No nodes listed yet") {
+		t.Errorf("Expected the empty notice:\n%s", view)
+	}
+}
+
+func TestHandleKey(t *testing.T) {
+	s := New(thresholds)
+	if s.HandleKey("end", 40, 10) != screen.Pending || s.Scroll == 0 {
+		t.Error("End should scroll to the last rows")
+	}
+	if s.HandleKey("g", 40, 10); s.Scroll != 0 {
+		t.Error("g should scroll to the top")
+	}
+	for _, key := range []string{"esc", "q", "n"} {
+		if s.HandleKey(key, 40, 10) != screen.Closed {
+			t.Errorf("%s should close the screen", key)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package permissions is the screen showing what the API token can do: the
+// privileges pvec uses, flagged when missing, and the privileges in effect
+// on the main ACL paths and on every listed guest.
+package permissions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Row is one line of the report
+type Row struct {
+	Text    string
+	Missing bool // Flags a privilege pvec uses that the token lacks
+}
+
+// basePaths are the ACL paths whose privileges are always listed
+var basePaths = []string{"/", "/nodes", "/storage", "/vms"}
+
+// guestPrivileges are the privileges each guest is checked for
+var guestPrivileges = []string{"VM.Audit", "VM.PowerMgmt"}
+
+// State is the permission screen
+type State struct {
+	Perms   models.Permissions
+	Guests  []*models.VMStatus
+	Loading bool
+	Err     error
+	Scroll  int
+}
+
+// New opens the screen for the listed guests while the permissions load
+func New(guests []*models.VMStatus) State {
+	sorted := append([]*models.VMStatus(nil), guests...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].VMIDNum < sorted[j].VMIDNum
+	})
+	return State{Guests: sorted, Loading: true}
+}
+
+// SetPermissions records the outcome of reading the permissions
+func (s *State) SetPermissions(perms models.Permissions, err error) {
+	s.Loading = false
+	s.Perms, s.Err = perms, err
+}
+
+// Resize keeps the offset valid once the screen is height lines tall
+func (s *State) Resize(height int) {
+	s.Scroll = format.ClampOffset(s.Scroll, len(Rows(s.Perms, s.Guests)), format.FrameRows(height))
+}
+
+// HandleKey updates the screen for a key press
+func (s *State) HandleKey(key string, height int) screen.Outcome {
+	count := len(Rows(s.Perms, s.Guests))
+	rows := format.FrameRows(height)
+	switch key {
+	case "esc", "q":
+		return screen.Closed
+	case "r":
+		s.Loading = true
+		return screen.Reload
+	case "up", "k":
+		s.Scroll = format.ClampOffset(s.Scroll-1, count, rows)
+	case "down", "j":
+		s.Scroll = format.ClampOffset(s.Scroll+1, count, rows)
+	case "pgup":
+		s.Scroll = format.ClampOffset(s.Scroll-rows, count, rows)
+	case "pgdown":
+		s.Scroll = format.ClampOffset(s.Scroll+rows, count, rows)
+	case "home", "g":
+		s.Scroll = 0
+	case "end", "G":
+		s.Scroll = format.ClampOffset(count, count, rows)
+	}
+	return screen.Pending
+}
+
+// Rows builds the report: the privileges pvec uses with what fails without
+// them, then the privileges in effect on the main paths and on each guest
+func Rows(perms models.Permissions, guests []*models.VMStatus) []Row {
+	rows := []Row{{Text: "Privileges used by pvec:"}}
+	for _, r := range proxmox.Requirements {
+		marker := "ok"
+		missing := !perms.Has(r.Path, r.Privilege)
+		if missing {
+			marker = "MISSING"
+		}
+		rows = append(rows, Row{
+			Text:    fmt.Sprintf("  %-7s %-26s %-8s %s", marker, r.Privilege, r.Path, r.Feature),
+			Missing: missing,
+		})
+	}
+
+	rows = append(rows, Row{}, Row{Text: "Effective privileges:"})
+	for _, path := range basePaths {
+		rows = append(rows, Row{Text: fmt.Sprintf("  %-10s %s", path, privilegeList(perms.Privileges(path)))})
+	}
+	for _, g := range guests {
+		path := "/vms/" + g.VMID
+		var lacking []string
+		for _, priv := range guestPrivileges {
+			if !perms.Has(path, priv) {
+				lacking = append(lacking, priv)
+			}
+		}
+		text := fmt.Sprintf("  %-10s %-16s %s", path, format.Truncate(g.Name, 16), privilegeList(perms.Privileges(path)))
+		if len(lacking) > 0 {
+			text = fmt.Sprintf("  %-10s %-16s MISSING %s", path, format.Truncate(g.Name, 16), strings.Join(lacking, " "))
+		}
+		rows = append(rows, Row{Text: text, Missing: len(lacking) > 0})
+	}
+	return rows
+}
+
+// privilegeList joins privileges, or says there are none
+func privilegeList(privs []string) string {
+	if len(privs) == 0 {
+		return "(none)"
+	}
+	return strings.Join(privs, " ")
+}
+
+// GetText renders the report
+func GetText(s State, width, height int) string {
+	var body []string
+	switch {
+	case s.Loading:
+		body = append(body, "  Reading token permissions...")
+	case s.Err != nil:
+		body = append(body, fmt.Sprintf("  Failed to read permissions: %v", redact.Error(s.Err)))
+	default:
+		missingStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Bold(true)
+		for _, row := range Rows(s.Perms, s.Guests) {
+			text := format.Truncate(row.Text, width)
+			if row.Missing && format.Color() {
+				text = missingStyle.Render(text)
+			}
+			body = append(body, text)
+		}
+	}
+
+	status := "↑↓=Scroll  r=Reload  ESC=Close"
+	if !s.Loading && s.Err == nil {
+		for _, r := range proxmox.MissingRequirements(s.Perms) {
+			if r.Essential {
+				status = fmt.Sprintf("Without %s on %s pvec lists nothing  r=Reload  ESC=Close", r.Privilege, r.Path)
+				break
+			}
+		}
+	}
+	return format.FrameAt("Token Permissions", body, format.Text(status), width, height, s.Scroll)
+}
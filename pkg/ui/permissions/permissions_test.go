@@ -0,0 +1,109 @@
+package permissions
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+func sampleGuests() []*models.VMStatus {
+	return []*models.VMStatus{
+		{VMID: "1000", VMIDNum: 1000, Name: "archive"},
+		{VMID: "103", VMIDNum: 103, Name: "locked-down"},
+		{VMID: "100", VMIDNum: 100, Name: "web"},
+	}
+}
+
+func samplePerms() models.Permissions {
+	return models.Permissions{
+		"/nodes":   {"Sys.Audit": true},
+		"/vms":     {"VM.Audit": true, "VM.PowerMgmt": true},
+		"/vms/103": {"VM.Audit": true},
+	}
+}
+
+func TestRows(t *testing.T) {
+	s := New(sampleGuests())
+	if s.Guests[0].VMID != "100" || s.Guests[2].VMID != "1000" {
+		t.Errorf("Guests should sort by VMID, got %s..%s", s.Guests[0].VMID, s.Guests[2].VMID)
+	}
+
+	missing := map[string]bool{}
+	for _, row := range Rows(samplePerms(), s.Guests) {
+		if row.Missing {
+			fields := strings.Fields(row.Text)
+			if strings.HasPrefix(fields[0], "/") {
+				missing[fields[0]] = true
+			} else {
+				missing[fields[1]] = true
+			}
+		}
+	}
+	for _, want := range []string{"VM.Monitor", "Sys.Modify", "Datastore.Audit", "/vms/103"} {
+		if !missing[want] {
+			t.Errorf("%s should be flagged as missing, got %v", want, missing)
+		}
+	}
+	for _, notWant := range []string{"VM.Audit", "Sys.Audit", "/vms/100"} {
+		if missing[notWant] {
+			t.Errorf("%s should not be flagged", notWant)
+		}
+	}
+}
+
+func TestHandleKey(t *testing.T) {
+	s := New(sampleGuests())
+	s.SetPermissions(samplePerms(), nil)
+
+	s.HandleKey("end", 10)
+	if s.Scroll == 0 {
+		t.Error("End should scroll a long report")
+	}
+	s.HandleKey("home", 10)
+	if s.Scroll != 0 {
+		t.Error("Home should scroll back to the top")
+	}
+	if got := s.HandleKey("r", 10); got != screen.Reload || !s.Loading {
+		t.Errorf("r should reload, got %v", got)
+	}
+	if got := s.HandleKey("esc", 10); got != screen.Closed {
+		t.Errorf("ESC should close, got %v", got)
+	}
+}
+
+func TestResize(t *testing.T) {
+	s := New(sampleGuests())
+	s.SetPermissions(samplePerms(), nil)
+	s.HandleKey("end", 10)
+
+	s.Resize(100)
+	if s.Scroll != 0 {
+		t.Errorf("The whole report fits a tall screen, got offset %d", s.Scroll)
+	}
+}
+
+func TestGetText(t *testing.T) {
+	if view := GetText(New(nil), 80, 24); !strings.Contains(view, "Reading token permissions...") {
+		t.Errorf("Expected the loading message:\n%s", view)
+	}
+
+	s := New(sampleGuests())
+	s.SetPermissions(nil, errors.New("status 401"))
+	if view := GetText(s, 80, 24); !strings.Contains(view, "Failed to read permissions: status 401") {
+		t.Errorf("Expected the error:\n%s", view)
+	}
+
+	s.SetPermissions(samplePerms(), nil)
+	view := GetText(s, 80, 40)
+	for _, want := range []string{"MISSING VM.Monitor", "/vms/103   locked-down      MISSING VM.PowerMgmt", "↑↓=Scroll"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+	if lines := strings.Split(view, "\n"); len(lines) != 40 {
+		t.Errorf("Screen should fill 40 lines, got %d", len(lines))
+	}
+}
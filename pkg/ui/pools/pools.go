@@ -0,0 +1,106 @@
+// Package pools is the screen rolling the guests up by resource pool:
+// how many of each pool's guests run, what they are given and what they
+// use, the per-environment view otherwise built by hand from the list.
+package pools
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Filter means the list must be narrowed to the selected pool
+const Filter = screen.Custom
+
+// poolWidth is the width of the pool name column
+const poolWidth = 14
+
+// State is the pools screen
+type State struct {
+	Selected int
+}
+
+// HandleKey updates the screen for a key press; count is the number of
+// pools listed
+func (s *State) HandleKey(key string, count int) screen.Outcome {
+	switch key {
+	case "esc", "q", "p":
+		return screen.Closed
+	case "up", "k":
+		if s.Selected > 0 {
+			s.Selected--
+		}
+	case "down", "j":
+		if s.Selected < count-1 {
+			s.Selected++
+		}
+	case "home", "g":
+		s.Selected = 0
+	case "end", "G":
+		s.Selected = max(count-1, 0)
+	case "enter":
+		if s.Selected < count {
+			return Filter
+		}
+	}
+	return screen.Pending
+}
+
+// Clamp keeps the selection on one of count pools
+func (s *State) Clamp(count int) {
+	s.Selected = max(min(s.Selected, count-1), 0)
+}
+
+// row renders the figures of a pool
+func row(p models.PoolStats) string {
+	guests := fmt.Sprintf("%d/%d", p.Running, p.Total)
+	if p.Unknown > 0 {
+		guests += "?"
+	}
+	return fmt.Sprintf("%s %-7s %5d %7s %7.1f %7s  %s", format.Pad(format.Truncate(p.ID, poolWidth), poolWidth),
+		guests, p.Allocated.Cores, gib(p.Allocated.Mem), p.CPU, gib(p.Mem), p.Comment)
+}
+
+// gib renders bytes in GiB, without decimals when they are whole
+func gib(bytes int64) string {
+	v := float64(bytes) / (1 << 30)
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0fG", v)
+	}
+	return fmt.Sprintf("%.1fG", v)
+}
+
+// GetText renders the pools, as models.PoolRollup orders them
+func GetText(s State, rollup []models.PoolStats, width, height int) string {
+	var rows []string
+	if len(rollup) == 0 {
+		rows = append(rows, "  No guests listed yet")
+	} else {
+		rows = append(rows, fmt.Sprintf("  %s %-7s %5s %7s %7s %7s  %s", format.Pad("POOL", poolWidth),
+			"RUN/ALL", "CORES", "MEMORY", "CPU USE", "MEM USE", "COMMENT"))
+	}
+	unknown := false
+	for i, p := range rollup {
+		marker := "  "
+		if i == s.Selected {
+			marker = "> "
+		}
+		text := format.Truncate(marker+row(p), width)
+		if i == s.Selected && format.Color() {
+			text = lipgloss.NewStyle().Reverse(true).Render(format.Pad(text, width))
+		}
+		rows = append(rows, text)
+		unknown = unknown || p.Unknown > 0
+	}
+	if unknown {
+		rows = append(rows, "", "  ? guests in an unknown state are counted, not summed")
+	}
+
+	title := fmt.Sprintf("Pools (%d)", len(rollup))
+	status := format.Text("↑↓=Select  Enter=Filter the list on the pool  ESC=Close")
+	return format.FrameAt(title, rows, status, width, height, format.OffsetFor(s.Selected+1, 0, format.FrameRows(height)))
+}
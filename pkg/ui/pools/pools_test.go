@@ -0,0 +1,76 @@
+package pools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+func sampleRollup() []models.PoolStats {
+	return []models.PoolStats{
+		{ID: "prod", Comment: "Production", Running: 2, Total: 3,
+			Allocated: models.Allocation{Cores: 7, Mem: 14 << 30}, CPU: 3, Mem: 3 << 29},
+		{ID: models.NoPool, Running: 0, Total: 2, Unknown: 1},
+	}
+}
+
+func TestGetText(t *testing.T) {
+	format.SetColor(false)
+	defer format.SetColor(true)
+
+	view := GetText(State{}, sampleRollup(), 100, 12)
+	for _, want := range []string{
+		"Pools (2)",
+		"  POOL           RUN/ALL CORES  MEMORY CPU USE MEM USE  COMMENT",
+		"> prod           2/3         7     14G     3.0    1.5G  Production",
+		"  (none)         0/2?        0      0G     0.0      0G",
+		"? guests in an unknown state are counted, not summed",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	if got := GetText(State{}, nil, 80, 10); !strings.Contains(got, "No guests listed yet") {
+		t.Errorf("Expected the empty screen:\n%s", got)
+	}
+}
+
+func TestHandleKey(t *testing.T) {
+	var s State
+	if s.HandleKey("down", 2) != screen.Pending || s.Selected != 1 {
+		t.Errorf("Expected down to select the second pool, got %d", s.Selected)
+	}
+	s.HandleKey("down", 2)
+	if s.Selected != 1 {
+		t.Errorf("Expected the selection to stop at the last pool, got %d", s.Selected)
+	}
+	if s.HandleKey("enter", 2) != Filter {
+		t.Error("Expected Enter to filter on the selected pool")
+	}
+	if s.HandleKey("enter", 0) != screen.Pending {
+		t.Error("Expected Enter to do nothing without pools")
+	}
+	s.HandleKey("home", 2)
+	if s.Selected != 0 {
+		t.Errorf("Expected home to select the first pool, got %d", s.Selected)
+	}
+	if s.HandleKey("esc", 2) != screen.Closed || s.HandleKey("p", 2) != screen.Closed {
+		t.Error("Expected ESC and p to close the screen")
+	}
+}
+
+func TestClamp(t *testing.T) {
+	s := State{Selected: 5}
+	s.Clamp(2)
+	if s.Selected != 1 {
+		t.Errorf("Expected the selection on the last pool, got %d", s.Selected)
+	}
+	s.Clamp(0)
+	if s.Selected != 0 {
+		t.Errorf("Expected the selection on the first row without pools, got %d", s.Selected)
+	}
+}
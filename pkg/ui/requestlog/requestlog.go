@@ -0,0 +1,275 @@
+// Package requestlog is the debug screen listing the last API requests
+// the client sent, newest first, with the masked details of each: what to
+// look at with support when the API behaves oddly, without restarting
+// with logging on.
+package requestlog
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Traffic is what the API sent back, so the effect of low_bandwidth can
+// be measured
+type Traffic struct {
+	LastMinute int64 // Bytes of response bodies read in the last minute
+	Total      int64 // Bytes read since pvec started
+	Unchanged  int   // Refreshes not applied as their guests hadn't changed
+}
+
+// State is the request screen
+type State struct {
+	Requests []proxmox.RequestInfo // Newest first
+	Sources  []proxmox.Capability  // Optional sources of the refresh, shown above the requests
+	Traffic  Traffic               // What the requests cost, shown above them
+	Selected int
+	Inspect  bool // The selected request's details are shown
+	Scroll   int  // Offset of the details
+}
+
+// New opens the screen on requests, given oldest first as a
+// proxmox.RequestLog returns them
+func New(requests []proxmox.RequestInfo) State {
+	var s State
+	s.SetRequests(requests)
+	return s
+}
+
+// SetRequests replaces the requests, given oldest first, and selects the
+// newest
+func (s *State) SetRequests(requests []proxmox.RequestInfo) {
+	s.Requests = make([]proxmox.RequestInfo, len(requests))
+	for i, r := range requests {
+		s.Requests[len(requests)-1-i] = r
+	}
+	s.Selected, s.Inspect, s.Scroll = 0, false, 0
+}
+
+// Resize keeps the details offset valid once the screen is width by
+// height
+func (s *State) Resize(width, height int) {
+	if s.Inspect {
+		s.Scroll = format.ClampOffset(s.Scroll, len(s.details(width)), format.FrameRows(height))
+	}
+}
+
+// HandleKey updates the screen for a key press
+func (s *State) HandleKey(key string, width, height int) screen.Outcome {
+	if s.Inspect {
+		rows := format.FrameRows(height)
+		count := len(s.details(width))
+		switch key {
+		case "esc", "q", "enter":
+			s.Inspect, s.Scroll = false, 0
+		case "up", "k":
+			s.Scroll = format.ClampOffset(s.Scroll-1, count, rows)
+		case "down", "j":
+			s.Scroll = format.ClampOffset(s.Scroll+1, count, rows)
+		case "pgup":
+			s.Scroll = format.ClampOffset(s.Scroll-rows, count, rows)
+		case "pgdown":
+			s.Scroll = format.ClampOffset(s.Scroll+rows, count, rows)
+		}
+		return screen.Pending
+	}
+
+	switch key {
+	case "esc", "q", "ctrl+d":
+		return screen.Closed
+	case "r":
+		return screen.Reload
+	case "up", "k":
+		if s.Selected > 0 {
+			s.Selected--
+		}
+	case "down", "j":
+		if s.Selected < len(s.Requests)-1 {
+			s.Selected++
+		}
+	case "home", "g":
+		s.Selected = 0
+	case "end", "G":
+		s.Selected = max(len(s.Requests)-1, 0)
+	case "enter":
+		if s.Selected < len(s.Requests) {
+			s.Inspect, s.Scroll = true, 0
+		}
+	}
+	return screen.Pending
+}
+
+// statusText returns the status code of a request, or dashes when no
+// response arrived
+func statusText(r proxmox.RequestInfo) string {
+	if r.Status == 0 {
+		return "---"
+	}
+	return fmt.Sprint(r.Status)
+}
+
+// durationText returns a request duration rounded for display
+func durationText(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
+
+// GetText renders the request list, or the details of the inspected one
+func GetText(s State, width, height int) string {
+	if s.Inspect && s.Selected < len(s.Requests) {
+		return detailsText(s, width, height)
+	}
+
+	var rows []string
+	if len(s.Sources) > 0 {
+		rows = append(rows, format.Truncate(sourcesText(s.Sources), width))
+	}
+	if s.Traffic != (Traffic{}) {
+		rows = append(rows, format.Truncate(trafficText(s.Traffic), width))
+	}
+	top := len(rows) + 1 // Rows above the first request
+	if len(s.Requests) == 0 {
+		rows = append(rows, "  No requests sent yet")
+	} else {
+		rows = append(rows, fmt.Sprintf("  %-8s %-6s %-3s %7s  %s", "TIME", "METHOD", "ST", "TOOK", "PATH"))
+	}
+	failedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
+	for i, r := range s.Requests {
+		marker := "  "
+		if i == s.Selected {
+			marker = "> "
+		}
+		path := r.Path
+		if r.Err != "" {
+			path += "  " + r.Err
+		}
+		row := format.Truncate(fmt.Sprintf("%s%-8s %-6s %-3s %7s  %s", marker,
+			r.Time.Format("15:04:05"), r.Method, statusText(r), durationText(r.Duration), path), width)
+		switch {
+		case i == s.Selected && format.Color():
+			row = lipgloss.NewStyle().Reverse(true).Render(format.Pad(row, width))
+		case r.Failed() && format.Color():
+			row = failedStyle.Render(row)
+		}
+		rows = append(rows, row)
+	}
+
+	title := fmt.Sprintf("API Requests (%d)", len(s.Requests))
+	status := format.Text("↑↓=Select  Enter=Details  r=Reload  ESC=Close")
+	return format.FrameAt(title, rows, status, width, height, format.OffsetFor(s.Selected+top, 0, format.FrameRows(height)))
+}
+
+// sourcesText lists the optional sources read, then those left out and
+// why
+func sourcesText(sources []proxmox.Capability) string {
+	var on, off []string
+	for _, c := range sources {
+		if c.Enabled {
+			on = append(on, string(c.Feature))
+		} else {
+			off = append(off, fmt.Sprintf("%s (%s)", c.Feature, c.Reason))
+		}
+	}
+	text := "  Sources: " + strings.Join(on, ", ")
+	if len(on) == 0 {
+		text += "guests only"
+	}
+	if len(off) > 0 {
+		text += "; off: " + strings.Join(off, ", ")
+	}
+	return text
+}
+
+// trafficText tells how much was received and how many refreshes were
+// found unchanged
+func trafficText(t Traffic) string {
+	return fmt.Sprintf("  Received: %s in the last minute, %s in all; %d unchanged refresh(es) skipped",
+		format.Bytes(t.LastMinute), format.Bytes(t.Total), t.Unchanged)
+}
+
+// details returns the lines describing the selected request, wrapped at
+// width
+func (s State) details(width int) []string {
+	if s.Selected >= len(s.Requests) {
+		return nil
+	}
+	r := s.Requests[s.Selected]
+	status := "no response"
+	if r.Status != 0 {
+		status = fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status))
+	}
+	lines := []string{
+		"  Time     : " + r.Time.Format("2006-01-02 15:04:05.000"),
+		"  Request  : " + r.Method + " " + r.URL,
+		"  Status   : " + status,
+		"  Duration : " + durationText(r.Duration),
+	}
+	if r.Err != "" {
+		lines = append(lines, "  Error    : "+r.Err)
+	}
+	lines = append(lines, "", "  Request headers:")
+	lines = append(lines, headerLines(r.Request)...)
+	if r.Response != nil {
+		lines = append(lines, "", "  Response headers:")
+		lines = append(lines, headerLines(r.Response)...)
+	}
+
+	var wrapped []string
+	for _, line := range lines {
+		wrapped = append(wrapped, wrap(line, width)...)
+	}
+	return wrapped
+}
+
+// headerLines lists headers sorted by name, one value a line
+func headerLines(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		for _, value := range h[name] {
+			lines = append(lines, fmt.Sprintf("    %s: %s", name, value))
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "    (none)")
+	}
+	return lines
+}
+
+// wrap splits line into pieces of at most width characters, indenting
+// the continuations, so long URLs and errors are shown whole
+func wrap(line string, width int) []string {
+	const indent = "    "
+	runes := []rune(line)
+	if width <= len(indent) || len(runes) <= width {
+		return []string{line}
+	}
+	lines := []string{string(runes[:width])}
+	for rest := runes[width:]; len(rest) > 0; {
+		n := min(len(rest), width-len(indent))
+		lines = append(lines, indent+string(rest[:n]))
+		rest = rest[n:]
+	}
+	return lines
+}
+
+// detailsText renders the details of the inspected request
+func detailsText(s State, width, height int) string {
+	r := s.Requests[s.Selected]
+	title := fmt.Sprintf("API Request - %s %s", r.Method, strings.SplitN(r.Path, "?", 2)[0])
+	status := format.Text("↑↓=Scroll  ESC=Back")
+	return format.FrameAt(title, s.details(width), status, width, height, s.Scroll)
+}
@@ -0,0 +1,125 @@
+package requestlog
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/proxmox"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+func sampleRequests() []proxmox.RequestInfo {
+	at := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	return []proxmox.RequestInfo{
+		{Time: at, Method: "GET", Path: "/cluster/resources?type=vm", URL: "https://pve:8006/api2/json/cluster/resources?type=vm",
+			Status: 200, Duration: 42 * time.Millisecond,
+			Request:  http.Header{"Authorization": {"***********"}, "User-Agent": {"pvec/dev"}},
+			Response: http.Header{"Content-Type": {"application/json"}}},
+		{Time: at.Add(time.Second), Method: "POST", Path: "/nodes/pve1/qemu/100/status/start", URL: "https://pve:8006/api2/json/nodes/pve1/qemu/100/status/start",
+			Duration: 1500 * time.Millisecond, Err: "request failed: connection refused"},
+	}
+}
+
+func TestNew_NewestFirst(t *testing.T) {
+	s := New(sampleRequests())
+	if s.Requests[0].Method != "POST" {
+		t.Errorf("Expected the newest request first, got %s", s.Requests[0].Method)
+	}
+	if !s.Requests[0].Failed() || s.Requests[1].Failed() {
+		t.Error("Only the request without a response failed")
+	}
+}
+
+func TestGetText(t *testing.T) {
+	format.SetColor(false)
+	s := New(sampleRequests())
+
+	view := GetText(s, 100, 10)
+	for _, want := range []string{
+		"API Requests (2)",
+		"> 09:30:01 POST   ---    1.5s  /nodes/pve1/qemu/100/status/start  request failed: connection refused",
+		"  09:30:00 GET    200    42ms  /cluster/resources?type=vm",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	if got := GetText(New(nil), 80, 10); !strings.Contains(got, "No requests sent yet") {
+		t.Errorf("Expected the empty screen:\n%s", got)
+	}
+}
+
+func TestGetText_Sources(t *testing.T) {
+	format.SetColor(false)
+	s := New(sampleRequests())
+	s.Sources = []proxmox.Capability{
+		{Feature: proxmox.FeatureNodeStatus, Enabled: true, Reason: "detected"},
+		{Feature: proxmox.FeaturePools, Reason: "not found on this server"},
+		{Feature: proxmox.FeatureHA, Configured: true, Reason: "set in the config"},
+	}
+
+	view := GetText(s, 120, 10)
+	want := "  Sources: node_status; off: pools (not found on this server), ha (set in the config)"
+	if !strings.Contains(view, want) {
+		t.Errorf("Expected %q:\n%s", want, view)
+	}
+	if !strings.Contains(view, "> 09:30:01 POST") {
+		t.Errorf("Expected the requests below the sources:\n%s", view)
+	}
+
+	s.Sources = s.Sources[1:]
+	if view := GetText(s, 120, 10); !strings.Contains(view, "Sources: guests only;") {
+		t.Errorf("Expected only the guests read:\n%s", view)
+	}
+}
+
+func TestGetText_Traffic(t *testing.T) {
+	format.SetColor(false)
+	s := New(sampleRequests())
+	if view := GetText(s, 120, 10); strings.Contains(view, "Received") {
+		t.Errorf("Expected no traffic line before anything was read:\n%s", view)
+	}
+
+	s.Traffic = Traffic{LastMinute: 3 << 10, Total: 5 << 20, Unchanged: 4}
+	want := "  Received: 3.0 KB in the last minute, 5.0 MB in all; 4 unchanged refresh(es) skipped"
+	if view := GetText(s, 120, 10); !strings.Contains(view, want) {
+		t.Errorf("Expected %q:\n%s", want, view)
+	}
+}
+
+func TestHandleKey(t *testing.T) {
+	format.SetColor(false)
+	s := New(sampleRequests())
+
+	s.HandleKey("down", 80, 24)
+	if s.HandleKey("enter", 80, 24) != screen.Pending || !s.Inspect {
+		t.Fatal("Enter should show the selected request")
+	}
+	view := GetText(s, 40, 24)
+	for _, want := range []string{
+		"Request  : GET https://pve:8006/api2/j",
+		"    son/cluster/resources?type=vm",
+		"Status   : 200 OK",
+		"    Authorization: ***********",
+		"    Content-Type: application/json",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q in the details:\n%s", want, view)
+		}
+	}
+
+	s.HandleKey("esc", 80, 24)
+	if s.Inspect {
+		t.Error("Esc should go back to the list")
+	}
+	if s.HandleKey("r", 80, 24) != screen.Reload {
+		t.Error("r should reload the requests")
+	}
+	if s.HandleKey("esc", 80, 24) != screen.Closed {
+		t.Error("Esc on the list should close the screen")
+	}
+}
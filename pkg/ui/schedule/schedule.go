@@ -0,0 +1,266 @@
+// Package schedule is the prompt that schedules a power action on a
+// guest for later, and the screen listing the scheduled actions with the
+// outcome of those that ran.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/state"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Prompt asks which action to run on a guest, and when
+type Prompt struct {
+	Guest  *models.VMStatus
+	Action string    // One of state.ScheduleActions
+	Typed  string    // When, as typed
+	At     time.Time // When the action runs, once confirmed
+	Err    error     // Why the time typed can't be used
+}
+
+// NewPrompt opens the prompt for guest, offering to shut it down when it
+// runs and to start it otherwise
+func NewPrompt(guest *models.VMStatus) Prompt {
+	action := "start"
+	if guest.IsRunning() {
+		action = "shutdown"
+	}
+	return Prompt{Guest: guest, Action: action}
+}
+
+// HandleKey updates the prompt for a key press. Enter confirms once the
+// time typed reads as one in the future.
+func (p *Prompt) HandleKey(msg tea.KeyMsg, now time.Time) screen.Outcome {
+	switch msg.Type {
+	case tea.KeyEsc:
+		return screen.Closed
+	case tea.KeyEnter:
+		at, err := state.ParseWhen(p.Typed, now)
+		if err != nil {
+			p.Err = err
+			return screen.Pending
+		}
+		p.At = at
+		return screen.Confirmed
+	case tea.KeyTab, tea.KeyRight:
+		p.Action = cycle(p.Action, 1)
+	case tea.KeyShiftTab, tea.KeyLeft:
+		p.Action = cycle(p.Action, -1)
+	case tea.KeyBackspace:
+		if p.Typed != "" {
+			runes := []rune(p.Typed)
+			p.Typed = string(runes[:len(runes)-1])
+		}
+		p.Err = nil
+	case tea.KeySpace:
+		p.Typed += " "
+		p.Err = nil
+	case tea.KeyRunes:
+		p.Typed += string(msg.Runes)
+		p.Err = nil
+	}
+	return screen.Pending
+}
+
+// cycle returns the action step places after action
+func cycle(action string, step int) string {
+	n := len(state.ScheduleActions)
+	for i, a := range state.ScheduleActions {
+		if a == action {
+			return state.ScheduleActions[((i+step)%n+n)%n]
+		}
+	}
+	return state.ScheduleActions[0]
+}
+
+// GetPromptText renders the prompt, with when the typed time falls
+func GetPromptText(p Prompt, width, height int, now time.Time) string {
+	var choices []string
+	for _, a := range state.ScheduleActions {
+		label := actionTitle(a)
+		if a == p.Action {
+			choices = append(choices, "["+label+"]")
+		} else {
+			choices = append(choices, " "+label+" ")
+		}
+	}
+
+	preview := ""
+	switch at, err := state.ParseWhen(p.Typed, now); {
+	case p.Err != nil:
+		preview = redact.Error(p.Err).Error()
+	case p.Typed != "" && err == nil:
+		preview = fmt.Sprintf("%s (in %s)", When(at, now), Countdown(at.Sub(now)))
+	}
+
+	g := p.Guest
+	body := []string{
+		"",
+		fmt.Sprintf("  Guest:   %s (%s) on %s, %s", g.Name, g.Key(), g.Node, g.Status),
+		"",
+		"  Action:  " + strings.Join(choices, " "),
+		"",
+		fmt.Sprintf("  When:    %s_", p.Typed),
+		"           " + preview,
+		"",
+		"  e.g. in 2h, in 1h30m, 22:00 or 2026-10-18 06:00",
+	}
+
+	status := "Tab: Switch action | Enter: Schedule | ESC: Cancel"
+	title := fmt.Sprintf("Schedule Action - %s (%s)", g.Name, g.Key())
+	return format.Frame(title, body, format.Text(status), width, height)
+}
+
+// List is the screen of scheduled actions
+type List struct {
+	Selected int
+	Confirm  bool                   // Asking whether to cancel Target
+	Target   *state.ScheduledAction // Pending action to cancel
+	Notice   string                 // Outcome of the last cancel, until the next key
+}
+
+// HandleKey updates the screen for a key press over actions, as listed
+func (l *List) HandleKey(key string, actions []state.ScheduledAction) screen.Outcome {
+	l.Notice = ""
+	if l.Confirm {
+		l.Confirm = false
+		if key == "y" || key == "Y" {
+			return screen.Confirmed
+		}
+		l.Target = nil
+		return screen.Pending
+	}
+
+	switch key {
+	case "esc", "q", "enter":
+		return screen.Closed
+	case "up", "k":
+		if l.Selected > 0 {
+			l.Selected--
+		}
+	case "down", "j":
+		if l.Selected < len(actions)-1 {
+			l.Selected++
+		}
+	case "x", "delete":
+		if l.Selected < len(actions) && actions[l.Selected].Pending() {
+			a := actions[l.Selected]
+			l.Confirm = true
+			l.Target = &a
+		}
+	}
+	return screen.Pending
+}
+
+// Clamp keeps the selection inside actions, which change as they run
+func (l *List) Clamp(actions []state.ScheduledAction) {
+	if l.Selected >= len(actions) {
+		l.Selected = len(actions) - 1
+	}
+	if l.Selected < 0 {
+		l.Selected = 0
+	}
+}
+
+// GetListText renders the scheduled actions, the pending ones first with
+// the time left, then those that ran with their outcome
+func GetListText(l List, actions []state.ScheduledAction, width, height int, now time.Time) string {
+	var rows []string
+	pending := 0
+	if len(actions) == 0 {
+		rows = append(rows, "  No scheduled actions - @ on a guest schedules one")
+	} else {
+		rows = append(rows, fmt.Sprintf("  %-16s %-9s %-22s %s", "WHEN", "ACTION", "GUEST", "STATUS"))
+	}
+	for i, a := range actions {
+		if a.Pending() {
+			pending++
+		}
+		marker := "  "
+		if i == l.Selected {
+			marker = "> "
+		}
+		row := fmt.Sprintf("%s%-16s %-9s %-22s %s", marker, When(a.At, now), a.Action,
+			format.Truncate(a.Key()+" "+a.Name, 22), Status(a, now))
+		row = format.Truncate(row, width)
+		if i == l.Selected && format.Color() {
+			row = lipgloss.NewStyle().Reverse(true).Render(format.Pad(row, width))
+		}
+		rows = append(rows, row)
+	}
+
+	status := "↑↓=Select  x=Cancel  ESC=Close"
+	switch {
+	case l.Confirm:
+		status = fmt.Sprintf("Cancel the %s of %s (%s) at %s? (y/n)",
+			l.Target.Action, l.Target.Name, l.Target.Key(), When(l.Target.At, now))
+	case l.Notice != "":
+		status = l.Notice
+	}
+
+	title := fmt.Sprintf("Scheduled Actions (%d pending)", pending)
+	return format.FrameAt(title, rows, format.Text(status), width, height,
+		format.OffsetFor(l.Selected+1, 0, format.FrameRows(height)))
+}
+
+// Status describes where a scheduled action stands: the time left, or how
+// it went once it ran
+func Status(a state.ScheduledAction, now time.Time) string {
+	late := ""
+	if a.Late {
+		late = " (late)"
+	}
+	switch {
+	case a.Pending() && !a.At.After(now):
+		return "due" + late
+	case a.Pending():
+		return "in " + Countdown(a.At.Sub(now))
+	case a.Error != "":
+		return fmt.Sprintf("failed at %s%s: %s", a.Ran.In(now.Location()).Format("15:04"), late, redact.String(a.Error))
+	}
+	return fmt.Sprintf("ran at %s%s", a.Ran.In(now.Location()).Format("15:04"), late)
+}
+
+// When renders the time of an action: the time of day within the next
+// 24 hours, the weekday too within the week, the date beyond
+func When(at, now time.Time) string {
+	at = at.In(now.Location())
+	d := at.Sub(now)
+	switch {
+	case d > -24*time.Hour && d < 24*time.Hour:
+		return at.Format("15:04")
+	case d > -7*24*time.Hour && d < 7*24*time.Hour:
+		return at.Format("Mon 15:04")
+	}
+	return at.Format("2006-01-02 15:04")
+}
+
+// Countdown renders the time left before an action in its two most
+// significant units ("2d 5h", "3h 05m", "4m 10s", "12s")
+func Countdown(d time.Duration) string {
+	s := int64(d.Round(time.Second).Seconds())
+	switch {
+	case s <= 0:
+		return "0s"
+	case s < 60:
+		return fmt.Sprintf("%ds", s)
+	case s < 3600:
+		return fmt.Sprintf("%dm %02ds", s/60, s%60)
+	case s < 86400:
+		return fmt.Sprintf("%dh %02dm", s/3600, s%3600/60)
+	}
+	return fmt.Sprintf("%dd %dh", s/86400, s%86400/3600)
+}
+
+// actionTitle returns the action as a sentence start
+func actionTitle(action string) string {
+	return strings.ToUpper(action[:1]) + action[1:]
+}
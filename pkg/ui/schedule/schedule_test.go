@@ -0,0 +1,186 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/state"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+var now = time.Date(2026, 10, 17, 18, 30, 0, 0, time.UTC)
+
+func typeText(p *Prompt, text string) {
+	for _, r := range text {
+		if r == ' ' {
+			p.HandleKey(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{r}}, now)
+			continue
+		}
+		p.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}, now)
+	}
+}
+
+func web() *models.VMStatus {
+	return &models.VMStatus{VMID: "100", Name: "web", Node: "pve1", Status: models.StateRunning}
+}
+
+func TestPrompt_Schedule(t *testing.T) {
+	p := NewPrompt(web())
+	if p.Action != "shutdown" {
+		t.Errorf("A running guest should default to a shutdown, got %s", p.Action)
+	}
+	if NewPrompt(&models.VMStatus{Status: models.StateStopped}).Action != "start" {
+		t.Error("A stopped guest should default to a start")
+	}
+
+	p.HandleKey(tea.KeyMsg{Type: tea.KeyTab}, now)
+	if p.Action != "stop" {
+		t.Errorf("Tab should move to the next action, got %s", p.Action)
+	}
+	p.HandleKey(tea.KeyMsg{Type: tea.KeyShiftTab}, now)
+	p.HandleKey(tea.KeyMsg{Type: tea.KeyShiftTab}, now)
+	if p.Action != "start" {
+		t.Errorf("Shift+Tab should wrap around to the last action, got %s", p.Action)
+	}
+
+	typeText(&p, "in 2x")
+	if got := p.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, now); got != screen.Pending || p.Err == nil {
+		t.Errorf("An unreadable time should not confirm, got %v, %v", got, p.Err)
+	}
+	p.HandleKey(tea.KeyMsg{Type: tea.KeyBackspace}, now)
+	typeText(&p, "h")
+	if p.Err != nil {
+		t.Errorf("Editing should clear the error, got %v", p.Err)
+	}
+	if got := p.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, now); got != screen.Confirmed {
+		t.Fatalf("A valid time should confirm, got %v", got)
+	}
+	if want := now.Add(2 * time.Hour); !p.At.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, p.At)
+	}
+
+	if got := p.HandleKey(tea.KeyMsg{Type: tea.KeyEsc}, now); got != screen.Closed {
+		t.Errorf("ESC should close the prompt, got %v", got)
+	}
+}
+
+func TestGetPromptText(t *testing.T) {
+	format.SetColor(false)
+	defer format.SetColor(true)
+
+	p := NewPrompt(web())
+	typeText(&p, "22:00")
+	text := GetPromptText(p, 80, 24, now)
+	for _, want := range []string{
+		"Schedule Action - web (100)",
+		"Guest:   web (100) on pve1, running",
+		"[Shutdown]  Stop ",
+		"When:    22:00_",
+		"22:00 (in 3h 30m)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in:\n%s", want, text)
+		}
+	}
+
+	p.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")}, now)
+	p.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, now)
+	if text := GetPromptText(p, 80, 24, now); !strings.Contains(text, `cannot read "22:00x" as a time`) {
+		t.Errorf("Expected the error in:\n%s", text)
+	}
+}
+
+func scheduled() []state.ScheduledAction {
+	return []state.ScheduledAction{
+		{ID: 2, Action: "shutdown", VMID: "100", Name: "web", At: now.Add(3*time.Hour + 30*time.Minute)},
+		{ID: 3, Action: "start", VMID: "100", Name: "web", At: now.Add(-time.Minute), Late: true},
+		{ID: 1, Action: "stop", VMID: "101", Name: "db", At: now.Add(-2 * time.Hour), Ran: now.Add(-2 * time.Hour), Error: "locked (backup)"},
+	}
+}
+
+func TestList_Cancel(t *testing.T) {
+	actions := scheduled()
+	var l List
+
+	l.HandleKey("down", actions)
+	l.HandleKey("down", actions)
+	l.HandleKey("x", actions)
+	if l.Confirm {
+		t.Error("An action that ran can't be cancelled")
+	}
+
+	l.HandleKey("up", actions)
+	l.HandleKey("x", actions)
+	if !l.Confirm || l.Target.ID != 3 {
+		t.Fatalf("x should ask to cancel the selected action, got %+v", l)
+	}
+	if got := l.HandleKey("n", actions); got != screen.Pending || l.Confirm || l.Target != nil {
+		t.Errorf("n should keep the action, got %v %+v", got, l)
+	}
+	l.HandleKey("x", actions)
+	if got := l.HandleKey("y", actions); got != screen.Confirmed || l.Target.ID != 3 {
+		t.Errorf("y should confirm the cancel, got %v %+v", got, l)
+	}
+
+	l.Clamp(actions[:1])
+	if l.Selected != 0 {
+		t.Errorf("The selection should stay in the list, got %d", l.Selected)
+	}
+	if got := l.HandleKey("esc", actions); got != screen.Closed {
+		t.Errorf("ESC should close the screen, got %v", got)
+	}
+}
+
+func TestGetListText(t *testing.T) {
+	format.SetColor(false)
+	defer format.SetColor(true)
+
+	text := GetListText(List{}, scheduled(), 100, 24, now)
+	for _, want := range []string{
+		"Scheduled Actions (2 pending)",
+		"> 22:00            shutdown  100 web                in 3h 30m",
+		"start     100 web                due (late)",
+		"stop      101 db                 failed at 16:30: locked (backup)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in:\n%s", want, text)
+		}
+	}
+
+	if text := GetListText(List{}, nil, 80, 24, now); !strings.Contains(text, "No scheduled actions") {
+		t.Errorf("Expected the empty list to say so:\n%s", text)
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	tests := map[time.Duration]string{
+		-time.Second:                   "0s",
+		12 * time.Second:               "12s",
+		4*time.Minute + 10*time.Second: "4m 10s",
+		3*time.Hour + 5*time.Minute:    "3h 05m",
+		53 * time.Hour:                 "2d 5h",
+	}
+	for d, want := range tests {
+		if got := Countdown(d); got != want {
+			t.Errorf("Countdown(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestWhen(t *testing.T) {
+	tests := map[time.Duration]string{
+		3 * time.Hour:       "21:30",
+		-2 * time.Hour:      "16:30",
+		30 * time.Hour:      "Mon 00:30",
+		10 * 24 * time.Hour: "2026-10-27 18:30",
+	}
+	for d, want := range tests {
+		if got := When(now.Add(d), now); got != want {
+			t.Errorf("When(now%+v) = %q, want %q", d, got, want)
+		}
+	}
+}
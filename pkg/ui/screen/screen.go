@@ -0,0 +1,20 @@
+// Package screen holds what the screens opened over the list share: the
+// outcome of a key press, which tells the list what to do next.
+package screen
+
+// Outcome tells the caller what a key press decided. A screen with
+// outcomes of its own numbers them from Custom.
+type Outcome int
+
+const (
+	// Pending means the screen stays as it is
+	Pending Outcome = iota
+	// Closed means the screen was closed without acting
+	Closed
+	// Confirmed means what the screen asked for was confirmed
+	Confirmed
+	// Reload means what the screen shows must be read again
+	Reload
+	// Custom is the first outcome free for a screen's own
+	Custom
+)
@@ -0,0 +1,169 @@
+// Package serialconsole is the screen following the serial console of a
+// VM, read-only, for guests that don't boot far enough to be reached
+// otherwise.
+package serialconsole
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// MaxLines is how many lines of output the screen keeps
+const MaxLines = 2000
+
+// State is the serial console screen
+type State struct {
+	Guest      string // Name and VMID, for the title
+	VMID       string
+	Port       string // Serial port followed, such as serial0
+	Connecting bool   // The config or the connection is still in flight
+	NoSerial   bool   // The VM has no serial port; setup instructions are shown
+	NotVM      bool   // Containers have no serial console
+	Err        error  // Why the console couldn't be read, or stopped being
+	Ended      bool   // The proxy closed the connection
+	Lines      []string
+	Partial    string // Last line, until its end arrives
+	Scroll     int
+	Follow     bool // Keep the newest line in view
+}
+
+// New opens the screen on a guest, connecting
+func New(guest, vmid string) State {
+	return State{Guest: guest, VMID: vmid, Connecting: true, Follow: true}
+}
+
+// controlSequence matches the terminal escape sequences a console writes,
+// such as colors and cursor moves, which are dropped
+var controlSequence = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[()][0-9A-Za-z]|[@-Z\\-_])`)
+
+// Append adds console output, kept as plain text lines. The view follows
+// the newest line unless scrolled up.
+func (s *State) Append(data []byte, height int) {
+	text := controlSequence.ReplaceAllString(string(data), "")
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case r < ' ' || r == 0x7f:
+			return -1 // Carriage returns, bells and other controls
+		}
+		return r
+	}, text)
+
+	parts := strings.Split(s.Partial+text, "\n")
+	s.Lines = append(s.Lines, parts[:len(parts)-1]...)
+	s.Partial = parts[len(parts)-1]
+	if extra := len(s.Lines) - MaxLines; extra > 0 {
+		s.Lines = s.Lines[extra:]
+		s.Scroll = max(s.Scroll-extra, 0)
+	}
+	if s.Follow {
+		s.Scroll = s.maxScroll(height)
+	}
+}
+
+// rows returns the lines shown, the partial one last
+func (s *State) rows() []string {
+	if s.Partial == "" {
+		return s.Lines
+	}
+	return append(s.Lines[:len(s.Lines):len(s.Lines)], s.Partial)
+}
+
+// Resize keeps the offset valid once the screen is height lines tall,
+// still on the newest line when following it
+func (s *State) Resize(height int) {
+	if s.Follow {
+		s.Scroll = s.maxScroll(height)
+		return
+	}
+	s.Scroll = format.ClampOffset(s.Scroll, len(s.rows()), format.FrameRows(height))
+}
+
+// HandleKey updates the screen for a key press. Scrolling up stops
+// following new output; reaching the bottom again resumes it.
+func (s *State) HandleKey(key string, height int) screen.Outcome {
+	count, page := len(s.rows()), format.FrameRows(height)
+	switch key {
+	case "esc", "q", "C":
+		return screen.Closed
+	case "up", "k":
+		s.Scroll = max(s.Scroll-1, 0)
+	case "down", "j":
+		s.Scroll = format.ClampOffset(s.Scroll+1, count, page)
+	case "pgup":
+		s.Scroll = format.ClampOffset(s.Scroll-page, count, page)
+	case "pgdown":
+		s.Scroll = format.ClampOffset(s.Scroll+page, count, page)
+	case "home", "g":
+		s.Scroll = 0
+	case "end", "G":
+		s.Scroll = s.maxScroll(height)
+	default:
+		return screen.Pending
+	}
+	s.Follow = s.Scroll >= s.maxScroll(height)
+	return screen.Pending
+}
+
+// maxScroll returns the offset that shows the last page
+func (s *State) maxScroll(height int) int {
+	count := len(s.rows())
+	return format.ClampOffset(count, count, format.FrameRows(height))
+}
+
+// setupText tells how to give a VM a serial console
+func setupText(vmid string) []string {
+	return []string{
+		"  This VM has no serial port, so there is no console output to follow.",
+		"",
+		"  To capture it:",
+		fmt.Sprintf("    1. Add a serial port on the node:  qm set %s -serial0 socket", vmid),
+		"    2. Have the guest write its console there, e.g. for Linux add",
+		"       console=tty0 console=ttyS0,115200 to the kernel command line",
+		"    3. Restart the VM, then open this screen again",
+	}
+}
+
+// GetText renders the console output, or why there is none
+func GetText(s State, width, height int) string {
+	var rows []string
+	switch {
+	case s.NotVM:
+		rows = []string{"  Containers have no serial console; use pct console on the node."}
+	case s.NoSerial:
+		rows = setupText(s.VMID)
+	default:
+		for _, line := range s.rows() {
+			rows = append(rows, " "+format.Truncate(line, width-1))
+		}
+		if len(rows) == 0 && !s.Connecting && s.Err == nil {
+			rows = append(rows, "  No output yet; the guest writes here as it boots")
+		}
+	}
+
+	status := "↑↓=Scroll  End=Follow  ESC=Close"
+	switch {
+	case s.NotVM, s.NoSerial:
+		status = "ESC=Close"
+	case s.Connecting:
+		status = "Connecting..."
+	case s.Err != nil:
+		status = fmt.Sprintf("Console unavailable: %v  ESC=Close", redact.Error(s.Err))
+	case s.Ended:
+		status = "Console closed by the server  ↑↓=Scroll  ESC=Close"
+	case !s.Follow:
+		status = "Paused - End=Follow  ↑↓=Scroll  ESC=Close"
+	}
+
+	title := fmt.Sprintf("Serial Console - %s", s.Guest)
+	if s.Port != "" {
+		title += " " + s.Port
+	}
+	return format.FrameAt(title, rows, format.Text(status), width, height, s.Scroll)
+}
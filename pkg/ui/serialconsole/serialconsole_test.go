@@ -0,0 +1,80 @@
+package serialconsole
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+func TestAppend(t *testing.T) {
+	s := New("web-1 (100)", "100")
+	s.Append([]byte("\x1b[2J\x1b[0;32mBooting\x1b[0m from disk...\r\nLoad"), 24)
+	s.Append([]byte("ing kernel\r\n\x07login: "), 24)
+
+	want := []string{"Booting from disk...", "Loading kernel", "login: "}
+	if got := s.rows(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestAppend_KeepsMaxLines(t *testing.T) {
+	s := New("web-1 (100)", "100")
+	s.Append([]byte(strings.Repeat("line\n", MaxLines+10)), 24)
+	if len(s.Lines) != MaxLines {
+		t.Errorf("Expected %d lines kept, got %d", MaxLines, len(s.Lines))
+	}
+	if s.Scroll != s.maxScroll(24) {
+		t.Errorf("Expected the view on the newest line, got offset %d", s.Scroll)
+	}
+}
+
+func TestHandleKey_Follow(t *testing.T) {
+	s := New("web-1 (100)", "100")
+	s.Append([]byte(strings.Repeat("line\n", 50)), 24)
+
+	s.HandleKey("up", 24)
+	if s.Follow {
+		t.Error("Scrolling up should stop following")
+	}
+	offset := s.Scroll
+	s.Append([]byte("more\n"), 24)
+	if s.Scroll != offset {
+		t.Errorf("New output should leave a paused view alone, got offset %d", s.Scroll)
+	}
+
+	s.HandleKey("end", 24)
+	if !s.Follow {
+		t.Error("End should follow again")
+	}
+	if s.HandleKey("esc", 24) != screen.Closed {
+		t.Error("ESC should close the screen")
+	}
+}
+
+func TestGetText(t *testing.T) {
+	format.SetColor(false)
+
+	s := New("web-1 (100)", "100")
+	s.NoSerial = true
+	view := GetText(s, 100, 24)
+	if !strings.Contains(view, "qm set 100 -serial0 socket") || !strings.Contains(view, "console=ttyS0") {
+		t.Errorf("Expected setup instructions:\n%s", view)
+	}
+
+	s = New("web-1 (100)", "100")
+	s.Port = "serial0"
+	s.Connecting = false
+	s.Append([]byte("Booting\n"), 24)
+	view = GetText(s, 100, 24)
+	if !strings.Contains(view, "Serial Console - web-1 (100) serial0") || !strings.Contains(view, " Booting") {
+		t.Errorf("Expected the output:\n%s", view)
+	}
+
+	s.Err = errors.New("status 403")
+	if view := GetText(s, 100, 24); !strings.Contains(view, "Console unavailable: status 403") {
+		t.Errorf("Expected the failure:\n%s", view)
+	}
+}
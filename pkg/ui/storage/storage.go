@@ -0,0 +1,465 @@
+// Package storage is the screen listing the ISO images and container
+// templates kept on the storages of the cluster. Volumes can be deleted,
+// and new ones downloaded onto a storage straight from a URL.
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Outcomes the storage screen adds to those of every screen
+const (
+	// DeleteConfirmed means the deletion of Target was confirmed
+	DeleteConfirmed = screen.Custom + iota
+	// DownloadRequested means the form was submitted; see Download
+	DownloadRequested
+)
+
+// Form fields, in Tab order
+const (
+	fieldURL = iota
+	fieldFilename
+	fieldStorage
+	fieldCount
+)
+
+// Form is the download form
+type Form struct {
+	URL      string
+	Filename string
+	Field    int    // Field being edited
+	Storage  int    // Index of the target in State.Targets
+	Err      string // Why the last submit was refused
+}
+
+// Download is a download task started from the screen
+type Download struct {
+	Node     string
+	Storage  string
+	Content  string
+	URL      string
+	Filename string
+	UPID     string // Empty until the node accepted the request
+	Progress string // Last line of the task log
+	Lines    int    // Log lines read so far
+	Done     bool
+	Err      error
+}
+
+// State is the storage screen
+type State struct {
+	Volumes  []models.StorageVolume // By node, storage, content and name
+	Targets  []models.Storage       // Storages that accept ISOs or templates
+	Selected int
+	Loading  bool
+	Err      error
+
+	Confirm  bool                  // Asking whether to delete Target
+	Target   *models.StorageVolume // Volume to delete
+	Deleting bool
+	Notice   string // Outcome of the last action, until the next key
+
+	Form     *Form     // Download form, nil when closed
+	Download *Download // Download in flight or just finished
+}
+
+// New opens the screen, waiting for the first listing
+func New() State {
+	return State{Loading: true}
+}
+
+// SetContent replaces the volumes and download targets, keeping the
+// selection on the same volume
+func (s *State) SetContent(volumes []models.StorageVolume, targets []models.Storage, err error) {
+	s.Loading = false
+	s.Err = err
+	if volumes == nil && err != nil {
+		return
+	}
+
+	var selected string
+	if s.Selected < len(s.Volumes) {
+		selected = s.Volumes[s.Selected].Node + "/" + s.Volumes[s.Selected].VolID
+	}
+	sort.SliceStable(volumes, func(i, j int) bool {
+		a, b := volumes[i], volumes[j]
+		if a.Node != b.Node {
+			return a.Node < b.Node
+		}
+		if a.Storage != b.Storage {
+			return a.Storage < b.Storage
+		}
+		if a.Content != b.Content {
+			return a.Content < b.Content
+		}
+		return a.Name() < b.Name()
+	})
+	s.Volumes = volumes
+	s.Targets = targets
+	s.Selected = 0
+	for i, v := range volumes {
+		if v.Node+"/"+v.VolID == selected {
+			s.Selected = i
+		}
+	}
+}
+
+// DeleteDone records the outcome of a delete request
+func (s *State) DeleteDone(err error) {
+	s.Deleting = false
+	if s.Target == nil {
+		return
+	}
+	if err != nil {
+		s.Notice = fmt.Sprintf("Failed to delete %s: %v", s.Target.Name(), redact.Error(err))
+	} else {
+		s.Notice = fmt.Sprintf("Deleted %s from %s", s.Target.Name(), s.Target.Storage)
+	}
+	s.Target = nil
+}
+
+// DownloadStarted records the task of an accepted download, or why it was
+// refused
+func (s *State) DownloadStarted(upid string, err error) {
+	if s.Download == nil {
+		return
+	}
+	s.Download.UPID = upid
+	if err != nil {
+		s.Download.Done = true
+		s.Download.Err = err
+	}
+}
+
+// DownloadProgress records a poll of the download task: its status and
+// the log lines read from line start on
+func (s *State) DownloadProgress(upid string, status models.TaskStatus, start int, lines []models.TaskLogLine, err error) {
+	d := s.Download
+	if d == nil || d.Done || d.UPID != upid {
+		return
+	}
+	if err != nil {
+		d.Progress = fmt.Sprintf("status unavailable: %v", redact.Error(err))
+		return
+	}
+	if start == d.Lines {
+		d.Lines += len(lines)
+		for i := len(lines) - 1; i >= 0; i-- {
+			if text := strings.TrimSpace(lines[i].Text); text != "" {
+				d.Progress = text
+				break
+			}
+		}
+	}
+	if !status.Running {
+		d.Done = true
+		if !status.OK() {
+			d.Err = fmt.Errorf("%s", status.ExitStatus)
+		}
+	}
+}
+
+// Downloading reports whether a download task is still in flight
+func (s *State) Downloading() bool {
+	return s.Download != nil && !s.Download.Done
+}
+
+// HandleKey updates the screen for a key press
+func (s *State) HandleKey(msg tea.KeyMsg) screen.Outcome {
+	if s.Form != nil {
+		return s.handleFormKey(msg)
+	}
+	s.Notice = ""
+	if s.Deleting {
+		return screen.Pending
+	}
+	key := msg.String()
+	if s.Confirm {
+		s.Confirm = false
+		if key == "y" || key == "Y" {
+			s.Deleting = true
+			return DeleteConfirmed
+		}
+		s.Target = nil
+		return screen.Pending
+	}
+	if s.Download != nil && s.Download.Done {
+		s.Download = nil // The outcome has been seen
+	}
+
+	switch key {
+	case "esc", "q":
+		return screen.Closed
+	case "up", "k":
+		if s.Selected > 0 {
+			s.Selected--
+		}
+	case "down", "j":
+		if s.Selected < len(s.Volumes)-1 {
+			s.Selected++
+		}
+	case "r":
+		s.Loading = true
+		return screen.Reload
+	case "x", "delete":
+		if s.Selected < len(s.Volumes) {
+			volume := s.Volumes[s.Selected]
+			s.Confirm = true
+			s.Target = &volume
+		}
+	case "a":
+		s.openForm()
+	}
+	return screen.Pending
+}
+
+// openForm opens the download form, targeting the storage of the
+// selected volume if it accepts downloads
+func (s *State) openForm() {
+	switch {
+	case s.Downloading():
+		s.Notice = "A download is already running"
+		return
+	case len(s.Targets) == 0:
+		s.Notice = "No storage accepts ISO images or templates"
+		return
+	}
+	form := &Form{}
+	if s.Selected < len(s.Volumes) {
+		selected := s.Volumes[s.Selected]
+		for i, t := range s.Targets {
+			if t.Node == selected.Node && t.Name == selected.Storage {
+				form.Storage = i
+			}
+		}
+	}
+	s.Form = form
+}
+
+// handleFormKey edits the download form
+func (s *State) handleFormKey(msg tea.KeyMsg) screen.Outcome {
+	f := s.Form
+	switch msg.Type {
+	case tea.KeyEsc:
+		s.Form = nil
+	case tea.KeyTab, tea.KeyDown:
+		f.leaveField()
+		f.Field = (f.Field + 1) % fieldCount
+	case tea.KeyShiftTab, tea.KeyUp:
+		f.leaveField()
+		f.Field = (f.Field + fieldCount - 1) % fieldCount
+	case tea.KeyLeft, tea.KeyRight:
+		if f.Field == fieldStorage {
+			step := 1
+			if msg.Type == tea.KeyLeft {
+				step = len(s.Targets) - 1
+			}
+			f.Storage = (f.Storage + step) % len(s.Targets)
+		}
+	case tea.KeyBackspace:
+		if text := f.text(); text != nil && *text != "" {
+			runes := []rune(*text)
+			*text = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		if text := f.text(); text != nil {
+			*text += string(msg.Runes)
+		}
+	case tea.KeyEnter:
+		f.leaveField()
+		download, err := s.validate()
+		if err != "" {
+			f.Err = err
+			return screen.Pending
+		}
+		s.Form = nil
+		s.Download = download
+		return DownloadRequested
+	}
+	return screen.Pending
+}
+
+// text returns the text field being edited, or nil on the storage field
+func (f *Form) text() *string {
+	switch f.Field {
+	case fieldURL:
+		return &f.URL
+	case fieldFilename:
+		return &f.Filename
+	}
+	return nil
+}
+
+// leaveField fills in the file name from the URL, which is usually right
+func (f *Form) leaveField() {
+	f.URL = strings.TrimSpace(f.URL)
+	if f.Field != fieldURL || f.Filename != "" {
+		return
+	}
+	if u, err := url.Parse(f.URL); err == nil {
+		if name := path.Base(u.Path); name != "." && name != "/" {
+			f.Filename = name
+		}
+	}
+}
+
+// validate checks the form and returns the download it describes
+func (s *State) validate() (*Download, string) {
+	f := s.Form
+	u, err := url.Parse(f.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "Enter an http or https URL"
+	}
+	filename := strings.TrimSpace(f.Filename)
+	if filename == "" || strings.ContainsAny(filename, `/\`) {
+		return nil, "Enter a file name without a path"
+	}
+	content := models.ContentForFile(filename)
+	if content == "" {
+		return nil, "The file name must end in .iso, .img or a template archive (.tar.zst, .tar.xz, .tar.gz)"
+	}
+	target := s.Targets[f.Storage]
+	if !target.Supports(content) {
+		return nil, fmt.Sprintf("%s on %s does not accept %s content", target.Name, target.Node, content)
+	}
+	return &Download{
+		Node:     target.Node,
+		Storage:  target.Name,
+		Content:  content,
+		URL:      f.URL,
+		Filename: filename,
+	}, ""
+}
+
+// GetText renders the screen
+func GetText(s State, width, height int) string {
+	if s.Form != nil {
+		return formText(s, width, height)
+	}
+
+	nameWidth := width - 58
+	if nameWidth < 10 {
+		nameWidth = 10
+	}
+	var rows []string
+	switch {
+	case s.Loading && len(s.Volumes) == 0:
+		rows = append(rows, "  Loading storage content...")
+	case s.Err != nil && len(s.Volumes) == 0:
+		rows = append(rows, fmt.Sprintf("  Failed to list storage content: %v", redact.Error(s.Err)))
+	case len(s.Volumes) == 0:
+		rows = append(rows, "  No ISO images or templates found")
+	default:
+		rows = append(rows, fmt.Sprintf("  %-10s %-12s %-4s %s %9s %-16s",
+			"NODE", "STORAGE", "TYPE", format.Pad("NAME", nameWidth), "SIZE", "CREATED"))
+		for i, v := range s.Volumes {
+			marker := "  "
+			if i == s.Selected {
+				marker = "> "
+			}
+			created := format.Unknown
+			if !v.Created.IsZero() {
+				created = v.Created.Format("2006-01-02 15:04")
+			}
+			row := fmt.Sprintf("%s%-10s %-12s %-4s %s %9s %-16s", marker,
+				format.Truncate(v.Node, 10), format.Truncate(v.Storage, 12), contentLabel(v.Content),
+				format.Pad(format.Truncate(v.Name(), nameWidth), nameWidth), format.Bytes(v.Size), created)
+			if i == s.Selected && format.Color() {
+				row = lipgloss.NewStyle().Reverse(true).Render(format.Pad(row, width))
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	title := fmt.Sprintf("ISO Images & Templates (%d)", len(s.Volumes))
+	return format.FrameAt(title, rows, format.Text(statusText(s)), width, height,
+		format.OffsetFor(s.Selected+1, 0, format.FrameRows(height)))
+}
+
+// statusText describes the pending question, the last outcome or the
+// download for the status bar
+func statusText(s State) string {
+	switch {
+	case s.Confirm:
+		return fmt.Sprintf("Delete %s from %s on %s? (y/n)", s.Target.Name(), s.Target.Storage, s.Target.Node)
+	case s.Deleting:
+		return fmt.Sprintf("Deleting %s...", s.Target.Name())
+	case s.Notice != "":
+		return s.Notice
+	case s.Download != nil:
+		return s.Download.statusText()
+	case s.Err != nil && len(s.Volumes) > 0:
+		return fmt.Sprintf("Some storages failed: %v", redact.Error(s.Err))
+	}
+	return "↑↓=Select  a=Download  x=Delete  r=Reload  ESC=Close"
+}
+
+// statusText describes the download
+func (d *Download) statusText() string {
+	switch {
+	case d.Done && d.Err != nil:
+		return fmt.Sprintf("Download of %s failed: %v", d.Filename, redact.Error(d.Err))
+	case d.Done:
+		return fmt.Sprintf("Downloaded %s to %s on %s", d.Filename, d.Storage, d.Node)
+	case d.Progress != "":
+		return fmt.Sprintf("Downloading %s: %s", d.Filename, d.Progress)
+	}
+	return fmt.Sprintf("Downloading %s to %s on %s...", d.Filename, d.Storage, d.Node)
+}
+
+// formText renders the download form
+func formText(s State, width, height int) string {
+	f := s.Form
+	cursor := func(field int) string {
+		if f.Field == field {
+			return "_"
+		}
+		return ""
+	}
+	label := func(field int, name string) string {
+		if f.Field == field {
+			return "> " + name
+		}
+		return "  " + name
+	}
+	target := s.Targets[f.Storage]
+	content := strings.Join(target.Content, ",")
+
+	body := []string{
+		"",
+		label(fieldURL, "URL:      ") + format.Truncate(f.URL+cursor(fieldURL), width-14),
+		label(fieldFilename, "File name:") + " " + f.Filename + cursor(fieldFilename),
+		label(fieldStorage, "Storage:  ") + fmt.Sprintf(" < %s on %s > (%s)", target.Name, target.Node, content),
+		"",
+		"  The node downloads the file itself; ISO images go to the iso",
+		"  folder of the storage and templates to its vztmpl folder.",
+	}
+	if f.Err != "" {
+		body = append(body, "", "  "+f.Err)
+	}
+
+	status := "Tab=Next field  ←→=Storage  Enter=Download  ESC=Cancel"
+	return format.Frame("Download to Storage", body, format.Text(status), width, height)
+}
+
+// contentLabel names a content type for the TYPE column
+func contentLabel(content string) string {
+	switch content {
+	case models.ContentISO:
+		return "ISO"
+	case models.ContentTemplate:
+		return "TMPL"
+	}
+	return content
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+func typeText(s *State, text string) {
+	for _, r := range text {
+		s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+func sampleState() State {
+	s := New()
+	s.SetContent([]models.StorageVolume{
+		{VolID: "nfs:iso/ubuntu.iso", Node: "pve1", Storage: "nfs", Content: models.ContentISO, Size: 2 << 30},
+		{VolID: "local:vztmpl/debian-12.tar.zst", Node: "pve1", Storage: "local", Content: models.ContentTemplate, Size: 120 << 20},
+		{VolID: "local:iso/debian-12.iso", Node: "pve1", Storage: "local", Content: models.ContentISO, Size: 600 << 20,
+			Created: time.Date(2024, 1, 2, 3, 4, 0, 0, time.Local)},
+	}, []models.Storage{
+		{Name: "local", Node: "pve1", Content: []string{"iso", "vztmpl"}},
+		{Name: "nfs", Node: "pve1", Content: []string{"iso"}, Shared: true},
+	}, nil)
+	return s
+}
+
+func TestSetContent_SortsAndKeepsSelection(t *testing.T) {
+	s := sampleState()
+	var names []string
+	for _, v := range s.Volumes {
+		names = append(names, v.Name())
+	}
+	if got := strings.Join(names, " "); got != "debian-12.iso debian-12.tar.zst ubuntu.iso" {
+		t.Errorf("Volumes should sort by node, storage, content and name, got %s", got)
+	}
+
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyDown})
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyDown})
+	s.SetContent(s.Volumes[1:], s.Targets, nil)
+	if s.Volumes[s.Selected].Name() != "ubuntu.iso" {
+		t.Errorf("The selection should stay on ubuntu.iso, got %s", s.Volumes[s.Selected].Name())
+	}
+
+	s.SetContent(nil, nil, errors.New("status 403"))
+	if len(s.Volumes) != 2 || s.Err == nil {
+		t.Error("A failed listing should keep the volumes and record the error")
+	}
+}
+
+func TestHandleKey_Delete(t *testing.T) {
+	s := sampleState()
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if !s.Confirm || s.Target.Name() != "debian-12.iso" {
+		t.Fatalf("x should ask to delete the selected volume, got %+v", s.Target)
+	}
+	if view := GetText(s, 80, 24); !strings.Contains(view, "Delete debian-12.iso from local on pve1? (y/n)") {
+		t.Errorf("Expected the question:\n%s", view)
+	}
+	if got := s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")}); got != DeleteConfirmed {
+		t.Fatalf("y should confirm, got %v", got)
+	}
+	s.DeleteDone(nil)
+	if s.Notice != "Deleted debian-12.iso from local" {
+		t.Errorf("Unexpected notice %q", s.Notice)
+	}
+
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if got := s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")}); got != screen.Pending || s.Target != nil {
+		t.Error("Any other key should cancel")
+	}
+}
+
+func TestHandleKey_DownloadForm(t *testing.T) {
+	s := sampleState()
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyDown})
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyDown})
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	if s.Form == nil || s.Targets[s.Form.Storage].Name != "nfs" {
+		t.Fatal("a should open the form on the selected volume's storage")
+	}
+
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	if s.HandleKey(enter) != screen.Pending || s.Form.Err == "" {
+		t.Error("An empty URL should be refused")
+	}
+
+	typeText(&s, "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/x86_64/alpine-virt-3.19.1-x86_64.iso")
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyTab})
+	if s.Form.Filename != "alpine-virt-3.19.1-x86_64.iso" {
+		t.Errorf("The file name should come from the URL, got %q", s.Form.Filename)
+	}
+
+	// nfs only takes ISOs: a template name is refused there
+	s.Form.Filename = "alpine.tar.xz"
+	if s.HandleKey(enter) != screen.Pending || !strings.Contains(s.Form.Err, "does not accept vztmpl") {
+		t.Errorf("A template should be refused on an ISO-only storage, got %q", s.Form.Err)
+	}
+	if view := GetText(s, 80, 24); !strings.Contains(view, "Download to Storage") || !strings.Contains(view, s.Form.Err) {
+		t.Errorf("Expected the form with its error:\n%s", view)
+	}
+
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyTab})
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyRight})
+	if got := s.HandleKey(enter); got != DownloadRequested {
+		t.Fatalf("The form should submit to local, got %v (%s)", got, s.Form.Err)
+	}
+	d := s.Download
+	if d.Storage != "local" || d.Content != models.ContentTemplate || d.Filename != "alpine.tar.xz" {
+		t.Errorf("Unexpected download %+v", d)
+	}
+
+	s.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	if s.Form != nil || s.Notice != "A download is already running" {
+		t.Error("Only one download should run at a time")
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	s := sampleState()
+	s.Download = &Download{Node: "pve1", Storage: "local", Filename: "alpine.iso"}
+	s.DownloadStarted("UPID:1", nil)
+
+	running := models.TaskStatus{Running: true}
+	s.DownloadProgress("UPID:1", running, 0, []models.TaskLogLine{{N: 1, Text: "downloading..."}, {N: 2, Text: "45.0% of 60 MiB"}}, nil)
+	if view := GetText(s, 80, 24); !strings.Contains(view, "Downloading alpine.iso: 45.0% of 60 MiB") {
+		t.Errorf("Expected the last log line as progress:\n%s", view)
+	}
+
+	// A stale poll is ignored
+	s.DownloadProgress("UPID:1", running, 0, []models.TaskLogLine{{N: 1, Text: "downloading..."}}, nil)
+	if s.Download.Lines != 2 {
+		t.Errorf("A stale poll should not count lines twice, got %d", s.Download.Lines)
+	}
+
+	s.DownloadProgress("UPID:1", models.TaskStatus{ExitStatus: "OK"}, 2, nil, nil)
+	if s.Downloading() || s.Download.Err != nil {
+		t.Fatal("The download should be done")
+	}
+	if view := GetText(s, 80, 24); !strings.Contains(view, "Downloaded alpine.iso to local on pve1") {
+		t.Errorf("Expected the outcome:\n%s", view)
+	}
+
+	s.Download = &Download{Filename: "bad.iso"}
+	s.DownloadStarted("UPID:2", nil)
+	s.DownloadProgress("UPID:2", models.TaskStatus{ExitStatus: "download failed: 404"}, 0, nil, nil)
+	if view := GetText(s, 80, 24); !strings.Contains(view, "Download of bad.iso failed: download failed: 404") {
+		t.Errorf("Expected the failure:\n%s", view)
+	}
+}
+
+func TestGetText(t *testing.T) {
+	s := sampleState()
+	view := GetText(s, 80, 24)
+	for _, want := range []string{"ISO Images & Templates (3)", "TMPL", "debian-12.iso", "600.0 MB", "2024-01-02 03:04"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+	for _, line := range strings.Split(view, "\n") {
+		if w := len([]rune(line)); w > 80 {
+			t.Errorf("Line is %d columns wide: %q", w, line)
+		}
+	}
+	if lines := strings.Split(view, "\n"); len(lines) != 24 {
+		t.Errorf("Screen should fill 24 lines, got %d", len(lines))
+	}
+
+	if view := GetText(New(), 80, 24); !strings.Contains(view, "Loading storage content...") {
+		t.Errorf("Expected the loading message:\n%s", view)
+	}
+}
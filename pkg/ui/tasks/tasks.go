@@ -0,0 +1,304 @@
+// Package tasks is the screen listing the worker tasks running across the
+// cluster, with a live tail of the selected task's log.
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Outcomes the task screen adds to those of every screen
+const (
+	// OpenLog means the selected task's log was opened and must be fetched
+	OpenLog = screen.Custom + iota
+	// StopConfirmed means the stop of Target was confirmed
+	StopConfirmed
+)
+
+// State is the task screen
+type State struct {
+	Tasks    []models.Task // Running tasks, oldest first
+	Selected int
+	Loading  bool // The first listing is still in flight
+	Err      error
+
+	Log      *models.Task // Task whose log is shown; nil on the list
+	Lines    []models.TaskLogLine
+	LogErr   error
+	Scroll   int
+	Follow   bool // Keep the newest log line in view
+	Finished bool // The task left the running list while its log was open
+
+	Confirm  bool         // Asking whether to stop Target
+	Target   *models.Task // Task to stop
+	Stopping bool
+	Notice   string // Outcome of the last stop, until the next key
+}
+
+// New opens the screen on the task list
+func New() State {
+	return State{Loading: true}
+}
+
+// SetTasks replaces the task list, keeping the selection on the same task
+func (s *State) SetTasks(tasks []models.Task, err error) {
+	s.Loading = false
+	s.Err = err
+	if tasks == nil && err != nil {
+		return
+	}
+
+	var selected string
+	if s.Selected < len(s.Tasks) {
+		selected = s.Tasks[s.Selected].UPID
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].StartTime.Before(tasks[j].StartTime)
+	})
+	s.Tasks = tasks
+	s.Selected = 0
+	for i, t := range tasks {
+		if t.UPID == selected {
+			s.Selected = i
+		}
+	}
+
+	if s.Log != nil {
+		s.Finished = true
+		for _, t := range tasks {
+			if t.UPID == s.Log.UPID {
+				s.Finished = false
+			}
+		}
+	}
+}
+
+// NextLine returns the log line to read from next, counting from 0
+func (s *State) NextLine() int {
+	return len(s.Lines)
+}
+
+// AppendLog adds log lines read from line start on. Lines for another task
+// or from a stale offset are dropped, so overlapping polls can't repeat
+// lines. The view follows the newest line unless scrolled up.
+func (s *State) AppendLog(upid string, start int, lines []models.TaskLogLine, err error, height int) {
+	if s.Log == nil || s.Log.UPID != upid || start != len(s.Lines) {
+		return
+	}
+	s.LogErr = err
+	s.Lines = append(s.Lines, lines...)
+	if s.Follow {
+		s.Scroll = s.maxScroll(height)
+	}
+}
+
+// Resize keeps the log offset valid once the screen is height lines
+// tall, still on the newest line when following it
+func (s *State) Resize(height int) {
+	if s.Follow {
+		s.Scroll = s.maxScroll(height)
+		return
+	}
+	s.Scroll = format.ClampOffset(s.Scroll, len(s.Lines), format.FrameRows(height))
+}
+
+// HandleKey updates the screen for a key press
+func (s *State) HandleKey(key string, height int) screen.Outcome {
+	s.Notice = ""
+	if s.Stopping {
+		return screen.Pending
+	}
+	if s.Confirm {
+		s.Confirm = false
+		if key == "y" || key == "Y" {
+			s.Stopping = true
+			return StopConfirmed
+		}
+		s.Target = nil
+		return screen.Pending
+	}
+	if s.Log != nil {
+		return s.handleLogKey(key, height)
+	}
+
+	switch key {
+	case "esc", "q":
+		return screen.Closed
+	case "up", "k":
+		if s.Selected > 0 {
+			s.Selected--
+		}
+	case "down", "j":
+		if s.Selected < len(s.Tasks)-1 {
+			s.Selected++
+		}
+	case "enter":
+		if s.Selected < len(s.Tasks) {
+			task := s.Tasks[s.Selected]
+			s.Log = &task
+			s.Lines = nil
+			s.LogErr = nil
+			s.Scroll = 0
+			s.Follow = true
+			s.Finished = false
+			return OpenLog
+		}
+	case "x", "delete":
+		if s.Selected < len(s.Tasks) {
+			task := s.Tasks[s.Selected]
+			s.askStop(task)
+		}
+	}
+	return screen.Pending
+}
+
+// handleLogKey scrolls the log. Scrolling up stops following new lines;
+// reaching the bottom again resumes it.
+func (s *State) handleLogKey(key string, height int) screen.Outcome {
+	switch key {
+	case "esc", "q":
+		s.Log = nil
+		s.Lines = nil
+	case "up", "k":
+		if s.Scroll > 0 {
+			s.Scroll--
+		}
+		s.Follow = s.Scroll >= s.maxScroll(height)
+	case "down", "j":
+		s.Scroll = format.ClampOffset(s.Scroll+1, len(s.Lines), format.FrameRows(height))
+		s.Follow = s.Scroll >= s.maxScroll(height)
+	case "pgup":
+		s.Scroll = format.ClampOffset(s.Scroll-format.FrameRows(height), len(s.Lines), format.FrameRows(height))
+		s.Follow = s.Scroll >= s.maxScroll(height)
+	case "pgdown":
+		s.Scroll = format.ClampOffset(s.Scroll+format.FrameRows(height), len(s.Lines), format.FrameRows(height))
+		s.Follow = s.Scroll >= s.maxScroll(height)
+	case "home", "g":
+		s.Scroll = 0
+		s.Follow = s.maxScroll(height) == 0
+	case "end", "G":
+		s.Scroll = s.maxScroll(height)
+		s.Follow = true
+	case "x", "delete":
+		if !s.Finished {
+			s.askStop(*s.Log)
+		}
+	}
+	return screen.Pending
+}
+
+// askStop asks for confirmation before stopping a task
+func (s *State) askStop(task models.Task) {
+	s.Confirm = true
+	s.Target = &task
+}
+
+// StopDone records the outcome of a stop request
+func (s *State) StopDone(err error) {
+	s.Stopping = false
+	if s.Target == nil {
+		return
+	}
+	if err != nil {
+		s.Notice = fmt.Sprintf("Failed to stop %s: %v", s.Target.Label(), redact.Error(err))
+	} else {
+		s.Notice = fmt.Sprintf("Stop of %s requested", s.Target.Label())
+	}
+	s.Target = nil
+}
+
+// maxScroll returns the offset that shows the last page of the log
+func (s *State) maxScroll(height int) int {
+	return format.ClampOffset(len(s.Lines), len(s.Lines), format.FrameRows(height))
+}
+
+// GetText renders the task list, or the log of the open task
+func GetText(s State, width, height int, now time.Time) string {
+	if s.Log != nil {
+		return logText(s, width, height)
+	}
+
+	var rows []string
+	switch {
+	case s.Loading:
+		rows = append(rows, "  Loading tasks...")
+	case s.Err != nil && len(s.Tasks) == 0:
+		rows = append(rows, fmt.Sprintf("  Failed to list tasks: %v", redact.Error(s.Err)))
+	case len(s.Tasks) == 0:
+		rows = append(rows, "  No tasks running")
+	default:
+		rows = append(rows, fmt.Sprintf("  %-10s %-22s %-16s %s", "NODE", "TASK", "USER", "RUNNING"))
+		for i, t := range s.Tasks {
+			marker := "  "
+			if i == s.Selected {
+				marker = "> "
+			}
+			elapsed := int64(now.Sub(t.StartTime).Seconds())
+			row := fmt.Sprintf("%s%-10s %-22s %-16s %s", marker,
+				format.Truncate(t.Node, 10), format.Truncate(t.Label(), 22),
+				format.Truncate(t.User, 16), format.Uptime(elapsed))
+			if i == s.Selected && format.Color() {
+				row = lipgloss.NewStyle().Reverse(true).Render(format.Pad(row, width))
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	status := "↑↓=Select  Enter=Log  x=Stop  ESC=Close"
+	switch {
+	case s.Confirm:
+		status = fmt.Sprintf("Stop %s on %s? (y/n)", s.Target.Label(), s.Target.Node)
+	case s.Stopping:
+		status = fmt.Sprintf("Stopping %s...", s.Target.Label())
+	case s.Notice != "":
+		status = s.Notice
+	case s.Err != nil && len(s.Tasks) > 0:
+		status = fmt.Sprintf("Some nodes failed: %v", redact.Error(s.Err))
+	}
+
+	title := fmt.Sprintf("Running Tasks (%d)", len(s.Tasks))
+	return format.FrameAt(title, rows, format.Text(status), width, height, scrollFor(s.Selected+1, height))
+}
+
+// logText renders the log of the open task
+func logText(s State, width, height int) string {
+	rows := make([]string, 0, len(s.Lines))
+	for _, line := range s.Lines {
+		rows = append(rows, " "+format.Truncate(line.Text, width-1))
+	}
+	if len(rows) == 0 && s.LogErr == nil {
+		rows = append(rows, "  No output yet")
+	}
+
+	status := "↑↓=Scroll  End=Follow  x=Stop  ESC=Back"
+	switch {
+	case s.Confirm:
+		status = fmt.Sprintf("Stop %s on %s? (y/n)", s.Target.Label(), s.Target.Node)
+	case s.Stopping:
+		status = fmt.Sprintf("Stopping %s...", s.Target.Label())
+	case s.Notice != "":
+		status = s.Notice
+	case s.LogErr != nil:
+		status = fmt.Sprintf("Failed to read the log: %v", redact.Error(s.LogErr))
+	case s.Finished:
+		status = "Task finished  ↑↓=Scroll  ESC=Back"
+	case !s.Follow:
+		status = "Paused - End=Follow  ↑↓=Scroll  x=Stop  ESC=Back"
+	}
+
+	title := fmt.Sprintf("Task Log - %s on %s", s.Log.Label(), s.Log.Node)
+	return format.FrameAt(title, rows, format.Text(status), width, height, s.Scroll)
+}
+
+// scrollFor returns the list offset that keeps row line in view, the
+// header taking row 0
+func scrollFor(line, height int) int {
+	return format.OffsetFor(line, 0, format.FrameRows(height))
+}
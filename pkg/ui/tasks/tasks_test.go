@@ -0,0 +1,160 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+var start = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+func task(upid, node, typ, id string, minutes int) models.Task {
+	return models.Task{UPID: upid, Node: node, Type: typ, ID: id, User: "root@pam", StartTime: start.Add(time.Duration(minutes) * time.Minute)}
+}
+
+func lines(from, to int) []models.TaskLogLine {
+	var out []models.TaskLogLine
+	for n := from; n <= to; n++ {
+		out = append(out, models.TaskLogLine{N: n, Text: fmt.Sprintf("line %d", n)})
+	}
+	return out
+}
+
+func TestSetTasks_KeepsSelection(t *testing.T) {
+	s := New()
+	s.SetTasks([]models.Task{task("b", "pve2", "qmigrate", "101", 5), task("a", "pve1", "vzdump", "100", 0)}, nil)
+	if s.Loading || s.Tasks[0].UPID != "a" {
+		t.Fatalf("Tasks should be sorted oldest first, got %v", s.Tasks)
+	}
+
+	s.HandleKey("down", 24)
+	s.SetTasks([]models.Task{task("c", "pve1", "vzstart", "102", -5), task("a", "pve1", "vzdump", "100", 0), task("b", "pve2", "qmigrate", "101", 5)}, nil)
+	if s.Tasks[s.Selected].UPID != "b" {
+		t.Errorf("The selection should stay on the same task, got %s", s.Tasks[s.Selected].UPID)
+	}
+
+	// A failed poll keeps the last known list
+	s.SetTasks(nil, errors.New("timeout"))
+	if len(s.Tasks) != 3 || s.Err == nil {
+		t.Errorf("Expected the previous tasks and the error, got %d tasks, err %v", len(s.Tasks), s.Err)
+	}
+}
+
+func TestAppendLog_FollowAndStale(t *testing.T) {
+	s := New()
+	s.SetTasks([]models.Task{task("a", "pve1", "vzdump", "100", 0)}, nil)
+	if got := s.HandleKey("enter", 24); got != OpenLog {
+		t.Fatalf("Enter should open the log, got %v", got)
+	}
+
+	s.AppendLog("a", 0, lines(1, 30), nil, 24)
+	if s.Scroll != 9 || !s.Follow {
+		t.Errorf("The view should follow the last line, got offset %d follow %v", s.Scroll, s.Follow)
+	}
+
+	// A poll that overlapped the previous one must not repeat lines
+	s.AppendLog("a", 0, lines(1, 30), nil, 24)
+	s.AppendLog("other", 30, lines(31, 31), nil, 24)
+	if len(s.Lines) != 30 {
+		t.Errorf("Stale and foreign lines should be dropped, got %d lines", len(s.Lines))
+	}
+
+	s.HandleKey("up", 24)
+	s.AppendLog("a", s.NextLine(), lines(31, 35), nil, 24)
+	if s.Follow || s.Scroll != 8 {
+		t.Errorf("Scrolling up should pause following, got offset %d follow %v", s.Scroll, s.Follow)
+	}
+	s.HandleKey("end", 24)
+	if !s.Follow || s.Scroll != 14 {
+		t.Errorf("End should resume following, got offset %d follow %v", s.Scroll, s.Follow)
+	}
+
+	s.SetTasks(nil, nil)
+	if !s.Finished {
+		t.Error("A task that left the running list should show as finished")
+	}
+	if s.HandleKey("x", 24); s.Confirm {
+		t.Error("A finished task can't be stopped")
+	}
+	if got := s.HandleKey("esc", 24); got != screen.Pending || s.Log != nil {
+		t.Error("ESC should go back to the list")
+	}
+	if got := s.HandleKey("esc", 24); got != screen.Closed {
+		t.Errorf("ESC on the list should close the screen, got %v", got)
+	}
+}
+
+func TestResize(t *testing.T) {
+	s := New()
+	s.SetTasks([]models.Task{task("a", "pve1", "vzdump", "100", 0)}, nil)
+	s.HandleKey("enter", 24)
+	s.AppendLog("a", 0, lines(1, 30), nil, 24)
+
+	s.Resize(10)
+	if s.Scroll != 23 || !s.Follow {
+		t.Errorf("A followed log should stay on its last line, got offset %d", s.Scroll)
+	}
+	s.HandleKey("up", 10)
+	s.Resize(40)
+	if s.Scroll != 0 || s.Follow {
+		t.Errorf("A log that fits should scroll to the top without following, got offset %d follow %v", s.Scroll, s.Follow)
+	}
+}
+
+func TestHandleKey_Stop(t *testing.T) {
+	s := New()
+	s.SetTasks([]models.Task{task("a", "pve1", "vzdump", "100", 0)}, nil)
+
+	s.HandleKey("x", 24)
+	if !s.Confirm || s.Target.UPID != "a" {
+		t.Fatal("x should ask before stopping")
+	}
+	if got := s.HandleKey("y", 24); got != StopConfirmed || !s.Stopping {
+		t.Fatalf("y should confirm, got %v", got)
+	}
+	if got := s.HandleKey("esc", 24); got != screen.Pending {
+		t.Errorf("Keys are ignored while stopping, got %v", got)
+	}
+	s.StopDone(errors.New("status 403"))
+	if s.Notice != "Failed to stop vzdump 100: status 403" {
+		t.Errorf("Unexpected notice %q", s.Notice)
+	}
+}
+
+func TestGetText(t *testing.T) {
+	s := New()
+	if view := GetText(s, 80, 24, start); !strings.Contains(view, "Loading tasks...") {
+		t.Errorf("Expected the loading state:\n%s", view)
+	}
+
+	s.SetTasks([]models.Task{}, nil)
+	if view := GetText(s, 80, 24, start); !strings.Contains(view, "No tasks running") {
+		t.Errorf("Expected the empty state:\n%s", view)
+	}
+
+	s.SetTasks([]models.Task{task("a", "pve1", "vzdump", "100", 0)}, nil)
+	view := GetText(s, 80, 24, start.Add(90*time.Second))
+	for _, want := range []string{"Running Tasks (1)", "NODE", "> pve1", "vzdump 100", "root@pam"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Task list should contain %q:\n%s", want, view)
+		}
+	}
+	if lines := strings.Split(view, "\n"); len(lines) != 24 {
+		t.Errorf("Screen should fill 24 lines, got %d", len(lines))
+	}
+
+	s.HandleKey("enter", 24)
+	if view := GetText(s, 80, 24, start); !strings.Contains(view, "Task Log - vzdump 100 on pve1") || !strings.Contains(view, "No output yet") {
+		t.Errorf("Expected the empty log:\n%s", view)
+	}
+	s.AppendLog("a", 0, lines(1, 2), nil, 24)
+	s.HandleKey("x", 24)
+	if view := GetText(s, 80, 24, start); !strings.Contains(view, "line 2") || !strings.Contains(view, "Stop vzdump 100 on pve1? (y/n)") {
+		t.Errorf("Expected the log and the stop confirmation:\n%s", view)
+	}
+}
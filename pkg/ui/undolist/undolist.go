@@ -0,0 +1,91 @@
+// Package undolist is the screen listing the guests pvec stopped or shut
+// down in the session, newest first, so that one stopped by mistake can be
+// started again after its undo is no longer offered in the status bar.
+package undolist
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/redact"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+// Undo means the selected stop must be undone
+const Undo = screen.Custom
+
+// State is the screen of stops
+type State struct {
+	Selected int
+}
+
+// HandleKey updates the screen for a key press; count is the number of
+// stops listed
+func (s *State) HandleKey(key string, count int) screen.Outcome {
+	switch key {
+	case "esc", "q", "Z":
+		return screen.Closed
+	case "up", "k":
+		if s.Selected > 0 {
+			s.Selected--
+		}
+	case "down", "j":
+		if s.Selected < count-1 {
+			s.Selected++
+		}
+	case "home", "g":
+		s.Selected = 0
+	case "end", "G":
+		s.Selected = max(count-1, 0)
+	case "enter", "z":
+		if s.Selected < count {
+			return Undo
+		}
+	}
+	return screen.Pending
+}
+
+// Clamp keeps the selection on one of count stops
+func (s *State) Clamp(count int) {
+	s.Selected = max(min(s.Selected, count-1), 0)
+}
+
+// stateText describes the undo of a stop, with why it failed
+func stateText(e actions.StoppedGuest) string {
+	if e.State == actions.UndoFailed && e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.State, redact.Error(e.Err))
+	}
+	return e.State.String()
+}
+
+// GetText renders the stops, given newest first
+func GetText(s State, entries []actions.StoppedGuest, width, height int) string {
+	var rows []string
+	if len(entries) == 0 {
+		rows = append(rows, "  pvec has stopped no guest yet")
+	} else {
+		rows = append(rows, fmt.Sprintf("  %-8s %-8s %-10s %-20s %s", "TIME", "ACTION", "VMID", "NAME", "UNDO"))
+	}
+	dimStyle := lipgloss.NewStyle().Faint(true)
+	for i, e := range entries {
+		marker := "  "
+		if i == s.Selected {
+			marker = "> "
+		}
+		row := format.Truncate(fmt.Sprintf("%s%-8s %-8s %-10s %s %s", marker, e.At.Format("15:04:05"),
+			e.Action, e.Guest.Key(), format.Pad(e.Guest.Name, 20), stateText(e)), width)
+		switch {
+		case i == s.Selected && format.Color():
+			row = lipgloss.NewStyle().Reverse(true).Render(format.Pad(row, width))
+		case !e.CanUndo() && format.Color():
+			row = dimStyle.Render(row)
+		}
+		rows = append(rows, row)
+	}
+
+	title := fmt.Sprintf("Stopped by pvec (%d)", len(entries))
+	status := format.Text("↑↓=Select  Enter/z=Start again  ESC=Close")
+	return format.FrameAt(title, rows, status, width, height, format.OffsetFor(s.Selected+1, 0, format.FrameRows(height)))
+}
@@ -0,0 +1,87 @@
+package undolist
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/actions"
+	"github.com/tsupplis/pvec/pkg/models"
+	"github.com/tsupplis/pvec/pkg/ui/format"
+	"github.com/tsupplis/pvec/pkg/ui/screen"
+)
+
+func sampleEntries() []actions.StoppedGuest {
+	at := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	return []actions.StoppedGuest{
+		{Guest: &models.VMStatus{VMID: "101", Name: "db"}, Action: "shutdown", At: at.Add(time.Minute)},
+		{Guest: &models.VMStatus{VMID: "100", Name: "web"}, Action: "stop", At: at, State: actions.UndoSkipped,
+			Err: fmt.Errorf("web is %w: it is running", actions.ErrNotStopped)},
+	}
+}
+
+func TestGetText(t *testing.T) {
+	format.SetColor(false)
+
+	view := GetText(State{}, sampleEntries(), 100, 10)
+	for _, want := range []string{
+		"Stopped by pvec (2)",
+		"> 09:31:00 shutdown 101        db                   can undo",
+		"  09:30:00 stop     100        web                  no longer stopped",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q:\n%s", want, view)
+		}
+	}
+
+	if got := GetText(State{}, nil, 80, 10); !strings.Contains(got, "pvec has stopped no guest yet") {
+		t.Errorf("Expected the empty screen:\n%s", got)
+	}
+}
+
+func TestHandleKey(t *testing.T) {
+	var s State
+	if s.HandleKey("down", 2) != screen.Pending || s.Selected != 1 {
+		t.Errorf("Expected down to select the second stop, got %d", s.Selected)
+	}
+	s.HandleKey("down", 2)
+	if s.Selected != 1 {
+		t.Errorf("Expected the selection to stop at the last stop, got %d", s.Selected)
+	}
+	if s.HandleKey("enter", 2) != Undo || s.HandleKey("z", 2) != Undo {
+		t.Error("Expected Enter and z to undo the selected stop")
+	}
+	s.HandleKey("home", 2)
+	if s.Selected != 0 {
+		t.Errorf("Expected home to select the first stop, got %d", s.Selected)
+	}
+	if s.HandleKey("esc", 2) != screen.Closed || s.HandleKey("Z", 2) != screen.Closed {
+		t.Error("Expected ESC and Z to close the screen")
+	}
+
+	var empty State
+	if empty.HandleKey("enter", 0) != screen.Pending {
+		t.Error("Expected nothing to undo without stops")
+	}
+}
+
+func TestClamp(t *testing.T) {
+	s := State{Selected: 5}
+	s.Clamp(2)
+	if s.Selected != 1 {
+		t.Errorf("Expected the last stop selected, got %d", s.Selected)
+	}
+	s.Clamp(0)
+	if s.Selected != 0 {
+		t.Errorf("Expected no selection past the start, got %d", s.Selected)
+	}
+}
+
+func TestStateText(t *testing.T) {
+	failed := actions.StoppedGuest{State: actions.UndoFailed, Err: errors.New("status 403")}
+	if got, want := stateText(failed), "undo failed: status 403"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
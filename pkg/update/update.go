@@ -0,0 +1,218 @@
+// Package update checks GitHub for a pvec release newer than the running
+// build. It is only used when update_check is set or pvec update --check
+// is run; nothing else in pvec contacts GitHub.
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/version"
+)
+
+// DefaultURL is the GitHub API endpoint of the latest pvec release
+const DefaultURL = "https://api.github.com/repos/tsupplis/pvec/releases/latest"
+
+// DefaultTimeout bounds the check, so a slow network never holds pvec up
+const DefaultTimeout = 2 * time.Second
+
+// Release is a published pvec release
+type Release struct {
+	Version string // Tag without the leading "v", e.g. "1.5.0"
+	URL     string // Release page
+}
+
+// Checker compares the running build with the latest release
+type Checker struct {
+	URL     string       // Latest release endpoint; DefaultURL when empty
+	Client  *http.Client // http.DefaultClient when nil
+	Timeout time.Duration
+	Current string // Version of the running build, e.g. "v1.4.0"
+}
+
+// NewChecker creates a checker against GitHub for the current version
+func NewChecker(current string) *Checker {
+	return &Checker{URL: DefaultURL, Timeout: DefaultTimeout, Current: current}
+}
+
+// releaseResponse is the part of a GitHub release pvec reads
+type releaseResponse struct {
+	TagName    string `json:"tag_name"`
+	HTMLURL    string `json:"html_url"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+// Latest fetches the latest release. A tag that isn't a semantic version
+// is an error.
+func (c *Checker) Latest(ctx context.Context) (*Release, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	url := c.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release check failed: %s", resp.Status)
+	}
+
+	var release releaseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to decode the latest release: %w", err)
+	}
+	if release.Draft || release.Prerelease {
+		return nil, fmt.Errorf("latest release %q is not final", release.TagName)
+	}
+	if _, err := parseSemver(release.TagName); err != nil {
+		return nil, err
+	}
+	return &Release{Version: strings.TrimPrefix(release.TagName, "v"), URL: release.HTMLURL}, nil
+}
+
+// Check returns the latest release when it is newer than Current, or nil
+// when Current is up to date or isn't a release (such as a "dev" build)
+func (c *Checker) Check(ctx context.Context) (*Release, error) {
+	latest, err := c.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := Newer(latest.Version, c.Current)
+	if err != nil || !newer {
+		return nil, nil
+	}
+	return latest, nil
+}
+
+// Newer reports whether version latest is newer than current, following
+// semantic versioning: a pre-release precedes its release. A current
+// version made by git describe, such as "v1.4.0-3-gabc1234", counts as
+// its tag.
+func Newer(latest, current string) (bool, error) {
+	l, err := parseSemver(latest)
+	if err != nil {
+		return false, err
+	}
+	c, err := parseSemver(describeSuffix.ReplaceAllString(current, ""))
+	if err != nil {
+		return false, err
+	}
+	return l.compare(c) > 0, nil
+}
+
+// describeSuffix matches what git describe adds after a tag: the commit
+// count and hash, and -dirty for uncommitted changes
+var describeSuffix = regexp.MustCompile(`-\d+-g[0-9a-f]+(-dirty)?$|-dirty$`)
+
+// semver is a parsed semantic version; build metadata is dropped
+type semver struct {
+	major, minor, patch int
+	pre                 []string // Pre-release identifiers, empty for a release
+}
+
+// parseSemver parses "1.2.3", with an optional "v", pre-release and
+// build metadata
+func parseSemver(s string) (semver, error) {
+	invalid := fmt.Errorf("invalid version %q", s)
+	v := strings.TrimPrefix(s, "v")
+	v, _, _ = strings.Cut(v, "+")
+	core, pre, hasPre := strings.Cut(v, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, invalid
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return semver{}, invalid
+		}
+		nums[i] = n
+	}
+	version := semver{major: nums[0], minor: nums[1], patch: nums[2]}
+	if hasPre {
+		version.pre = strings.Split(pre, ".")
+		for _, id := range version.pre {
+			if id == "" {
+				return semver{}, invalid
+			}
+		}
+	}
+	return version, nil
+}
+
+// compare returns -1, 0 or 1 as v precedes, equals or follows o
+func (v semver) compare(o semver) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		if d := compareIdentifier(v.pre[i], o.pre[i]); d != 0 {
+			return d
+		}
+	}
+	return sign(len(v.pre) - len(o.pre))
+}
+
+// compareIdentifier compares pre-release identifiers: numeric ones by
+// value and before alphanumeric ones, which compare as text
+func compareIdentifier(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return sign(an - bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveRelease answers the latest release endpoint with body
+func serveRelease(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("User-Agent"), "pvec/"), "GitHub rejects requests without a User-Agent")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChecker_Check(t *testing.T) {
+	server := serveRelease(t, http.StatusOK,
+		`{"tag_name":"v1.5.0","html_url":"https://github.com/tsupplis/pvec/releases/tag/v1.5.0"}`)
+
+	tests := []struct {
+		current string
+		want    string // Version reported, "" for none
+	}{
+		{"v1.4.0", "1.5.0"},
+		{"1.4.9", "1.5.0"},
+		{"v1.5.0-rc.1", "1.5.0"},
+		{"v1.4.0-3-gabc1234-dirty", "1.5.0"},
+		{"v1.5.0", ""},
+		{"v1.5.0-2-gabc1234", ""},
+		{"v1.6.0", ""},
+		{"dev", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.current, func(t *testing.T) {
+			checker := &Checker{URL: server.URL, Current: tt.current}
+			release, err := checker.Check(context.Background())
+			require.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, release)
+				return
+			}
+			require.NotNil(t, release)
+			assert.Equal(t, tt.want, release.Version)
+			assert.Equal(t, "https://github.com/tsupplis/pvec/releases/tag/v1.5.0", release.URL)
+		})
+	}
+}
+
+func TestChecker_Latest_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"malformed tag", http.StatusOK, `{"tag_name":"release-2"}`, `invalid version "release-2"`},
+		{"two-part tag", http.StatusOK, `{"tag_name":"v1.5"}`, `invalid version "v1.5"`},
+		{"leading zero", http.StatusOK, `{"tag_name":"v1.05.0"}`, `invalid version "v1.05.0"`},
+		{"empty pre-release", http.StatusOK, `{"tag_name":"v1.5.0-"}`, `invalid version "v1.5.0-"`},
+		{"pre-release", http.StatusOK, `{"tag_name":"v1.5.0-rc.1","prerelease":true}`, "not final"},
+		{"invalid JSON", http.StatusOK, `{`, "failed to decode"},
+		{"no releases", http.StatusNotFound, `{"message":"Not Found"}`, "404 Not Found"},
+		{"rate limited", http.StatusForbidden, `{}`, "403 Forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveRelease(t, tt.status, tt.body)
+			_, err := (&Checker{URL: server.URL, Current: "v1.4.0"}).Latest(context.Background())
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestChecker_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	start := time.Now()
+	_, err := (&Checker{URL: server.URL, Timeout: 50 * time.Millisecond, Current: "v1.4.0"}).Check(context.Background())
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"1.5.0", "1.4.0", true},
+		{"1.10.0", "1.9.0", true},
+		{"2.0.0", "1.99.99", true},
+		{"1.4.0", "1.4.0", false},
+		{"1.4.0", "1.4.1", false},
+		{"1.4.0", "1.4.0-rc.1", true},
+		{"1.4.0-rc.2", "1.4.0-rc.1", true},
+		{"1.4.0-rc.10", "1.4.0-rc.9", true},
+		{"1.4.0-rc.1", "1.4.0-1", true},
+		{"1.4.0-rc.1.1", "1.4.0-rc.1", true},
+		{"1.4.0+build.5", "1.4.0", false},
+	}
+	for _, tt := range tests {
+		got, err := Newer(tt.latest, tt.current)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%s newer than %s", tt.latest, tt.current)
+	}
+
+	_, err := Newer("1.4.0", "dev")
+	assert.Error(t, err)
+	_, err = Newer("latest", "1.4.0")
+	assert.Error(t, err)
+}
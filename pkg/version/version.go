@@ -0,0 +1,86 @@
+// Package version identifies the pvec build: the release, the commit and
+// date it was built from, and the Go runtime. The Makefile sets the
+// variables with -ldflags "-X github.com/tsupplis/pvec/pkg/version.Version=...".
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Set at build time; see the Makefile
+var (
+	Version   = "dev" // Release, e.g. "v1.4.0"
+	Commit    = ""    // Git commit the binary was built from
+	BuildDate = ""    // Build time, RFC 3339
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the running build. What -ldflags left unset is filled in
+// from the build information Go embeds, so a binary installed with
+// go install from a tag still reports its version and commit.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	for _, setting := range build.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.BuildDate == "":
+			info.BuildDate = setting.Value
+		}
+	}
+	return info
+}
+
+// String describes the build on one line, as pvec -v prints it
+func (i Info) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pvec version %s", i.Version)
+	var details []string
+	if i.Commit != "" {
+		details = append(details, "commit "+shortCommit(i.Commit))
+	}
+	if i.BuildDate != "" {
+		details = append(details, "built "+i.BuildDate)
+	}
+	details = append(details, i.GoVersion, i.Platform)
+	fmt.Fprintf(&b, " (%s)", strings.Join(details, ", "))
+	return b.String()
+}
+
+// shortCommit abbreviates a commit hash as git does
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// UserAgent returns the User-Agent header sent on API requests, e.g.
+// "pvec/1.4.0", so that Proxmox access logs identify the client
+func UserAgent() string {
+	return "pvec/" + strings.TrimPrefix(Get().Version, "v")
+}
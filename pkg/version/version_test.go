@@ -0,0 +1,47 @@
+package version
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setBuild sets the ldflags variables for a test
+func setBuild(t *testing.T, version, commit, date string) {
+	t.Helper()
+	savedVersion, savedCommit, savedDate := Version, Commit, BuildDate
+	t.Cleanup(func() { Version, Commit, BuildDate = savedVersion, savedCommit, savedDate })
+	Version, Commit, BuildDate = version, commit, date
+}
+
+func TestGet(t *testing.T) {
+	setBuild(t, "v1.4.0", "0123456789abcdef0123", "2026-10-01T12:00:00Z")
+
+	info := Get()
+
+	assert.Equal(t, "v1.4.0", info.Version)
+	assert.Equal(t, "0123456789abcdef0123", info.Commit)
+	assert.Equal(t, "2026-10-01T12:00:00Z", info.BuildDate)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, runtime.GOOS+"/"+runtime.GOARCH, info.Platform)
+}
+
+func TestInfo_String(t *testing.T) {
+	info := Info{Version: "v1.4.0", Commit: "0123456789abcdef0123", BuildDate: "2026-10-01T12:00:00Z",
+		GoVersion: "go1.24.1", Platform: "linux/amd64"}
+	assert.Equal(t, "pvec version v1.4.0 (commit 0123456789ab, built 2026-10-01T12:00:00Z, go1.24.1, linux/amd64)",
+		info.String())
+
+	info = Info{Version: "dev", GoVersion: "go1.24.1", Platform: "linux/amd64"}
+	assert.Equal(t, "pvec version dev (go1.24.1, linux/amd64)", info.String())
+}
+
+func TestUserAgent(t *testing.T) {
+	setBuild(t, "v1.4.0", "", "")
+	assert.Equal(t, "pvec/1.4.0", UserAgent())
+
+	setBuild(t, "dev", "", "")
+	assert.True(t, strings.HasPrefix(UserAgent(), "pvec/"))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// tlsCheckTimeout bounds the check of the server certificate made before
+// the first connection
+const tlsCheckTimeout = 5 * time.Second
+
+// errTLSAborted is returned when the user declines an untrusted
+// certificate
+var errTLSAborted = errors.New("aborted: the server certificate was not trusted")
+
+// tlsTrust decides, when the configuration set neither skip_tls_verify
+// nor tls_fingerprint, how to treat a server certificate the system
+// doesn't trust
+type tlsTrust struct {
+	in          io.Reader
+	out         io.Writer
+	interactive bool           // Whether the user can be asked; headless runs get an error
+	saver       config.Saver   // Keeps the decision; nil to apply it for the session only
+	path        string         // File the decision is saved to, for the messages
+	roots       *x509.CertPool // Trusted CAs; nil for the system's
+}
+
+// check connects to the server, or to each cluster listed, and when a
+// certificate isn't trusted asks whether to pin it, skip verification for
+// good or abort, and updates cfg. A trusted certificate, or a server that
+// can't be reached, is left to the first refresh. Each server is given
+// tlsCheckTimeout to answer.
+func (tt tlsTrust) check(ctx context.Context, cfg *config.Config) error {
+	answers := bufio.NewScanner(tt.in) // Shared, as it reads ahead
+	if len(cfg.Clusters) == 0 {
+		if !cfg.TLSTrustUnset {
+			return nil
+		}
+		fingerprint, skip, err := tt.decide(ctx, answers, cfg.APIUrl, tt.path)
+		if err != nil || (fingerprint == "" && !skip) {
+			return err
+		}
+		cfg.TLSFingerprint, cfg.SkipTLSVerify, cfg.TLSTrustUnset = fingerprint, skip, false
+		tt.save(cfg)
+		return nil
+	}
+
+	decided := false
+	for i := range cfg.Clusters {
+		c := &cfg.Clusters[i]
+		if !c.TLSTrustUnset {
+			continue
+		}
+		fingerprint, skip, err := tt.decide(ctx, answers, c.APIUrl, fmt.Sprintf("clusters[%d] (%s) of %s", i, c.Name, tt.path))
+		if err != nil {
+			return fmt.Errorf("cluster %s: %w", c.Name, err)
+		}
+		if fingerprint == "" && !skip {
+			continue
+		}
+		c.TLSFingerprint, c.SkipTLSVerify, c.TLSTrustUnset = fingerprint, skip, false
+		decided = true
+	}
+	if decided {
+		tt.save(cfg)
+	}
+	return nil
+}
+
+// decide checks the certificate of the server at url and, when it isn't
+// trusted, returns the fingerprint to pin or whether to skip verification,
+// as the user chose in answers; neither when there is nothing to decide.
+// where names the settings to change, for the error of a headless run.
+func (tt tlsTrust) decide(ctx context.Context, answers *bufio.Scanner, url, where string) (fingerprint string, skip bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, tlsCheckTimeout)
+	verr := proxmox.VerifyCertificate(ctx, url, tt.roots)
+	cancel()
+	cert := proxmox.UntrustedCertificate(verr)
+	if cert == nil {
+		return "", false, nil
+	}
+	fingerprint = proxmox.Fingerprint(cert)
+	if !tt.interactive {
+		return "", false, fmt.Errorf("the certificate of %s is not trusted (SHA-256 %s): set tls_fingerprint to this fingerprint to trust it, "+
+			"or skip_tls_verify to true, in %s", url, fingerprint, where)
+	}
+
+	fmt.Fprintf(tt.out, "The certificate of %s is not trusted by this system (%v).\n", url, verr)
+	fmt.Fprintf(tt.out, "  Subject: %s\n  Issuer:  %s\n  Expires: %s\n  SHA-256: %s\n",
+		cert.Subject, cert.Issuer, cert.NotAfter.Format(time.DateOnly), fingerprint)
+	fmt.Fprintln(tt.out, "Compare the fingerprint with the one the Proxmox UI shows under the node's System > Certificates.")
+	fmt.Fprint(tt.out, "  t  Trust this certificate (saves tls_fingerprint)\n"+
+		"  s  Skip verification for good (saves skip_tls_verify: true)\n"+
+		"  a  Abort\n")
+
+	answer := ""
+	for answer != "t" && answer != "s" && answer != "a" {
+		fmt.Fprint(tt.out, "Choice [t/s/a]: ")
+		if !answers.Scan() {
+			fmt.Fprintln(tt.out)
+			return "", false, errTLSAborted
+		}
+		answer = strings.ToLower(strings.TrimSpace(answers.Text()))
+	}
+	switch answer {
+	case "t":
+		return fingerprint, false, nil
+	case "s":
+		return "", true, nil
+	}
+	return "", false, errTLSAborted
+}
+
+// save keeps the decisions made in cfg; when it can't, they only hold
+// for the session
+func (tt tlsTrust) save(cfg *config.Config) {
+	if tt.saver == nil {
+		return
+	}
+	if err := tt.saver.Save(cfg); err != nil {
+		fmt.Fprintf(tt.out, "Failed to save to %s, for this session only: %v\n", tt.path, err)
+		return
+	}
+	fmt.Fprintf(tt.out, "Saved to %s.\n", tt.path)
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tsupplis/pvec/pkg/config"
+	"github.com/tsupplis/pvec/pkg/proxmox"
+)
+
+// recordingSaver keeps the configurations saved, or fails with err
+type recordingSaver struct {
+	saved []config.Config
+	err   error
+}
+
+func (s *recordingSaver) Save(cfg *config.Config) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.saved = append(s.saved, *cfg)
+	return nil
+}
+
+// untrustedServer returns a server whose self-signed certificate the
+// system doesn't trust, and an undecided configuration pointing at it
+func untrustedServer(t *testing.T) (*httptest.Server, *config.Config) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	return server, &config.Config{APIUrl: server.URL, TLSTrustUnset: true}
+}
+
+func TestTLSTrust_Trust(t *testing.T) {
+	server, cfg := untrustedServer(t)
+	saver := &recordingSaver{}
+	var out bytes.Buffer
+	tt := tlsTrust{in: strings.NewReader("x\nT\n"), out: &out, interactive: true, saver: saver, path: "/home/u/.pvecrc"}
+
+	if err := tt.check(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := proxmox.Fingerprint(server.Certificate())
+	if cfg.TLSFingerprint != fingerprint || cfg.SkipTLSVerify || cfg.TLSTrustUnset {
+		t.Errorf("Expected the certificate pinned, got %+v", cfg)
+	}
+	if len(saver.saved) != 1 || saver.saved[0].TLSFingerprint != fingerprint {
+		t.Errorf("Expected the fingerprint saved, got %v", saver.saved)
+	}
+	for _, want := range []string{"is not trusted", "Subject: O=Acme Co", "SHA-256: " + fingerprint, "Choice [t/s/a]: Choice [t/s/a]: ", "Saved to /home/u/.pvecrc"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestTLSTrust_Skip(t *testing.T) {
+	_, cfg := untrustedServer(t)
+	saver := &recordingSaver{}
+	tt := tlsTrust{in: strings.NewReader("s\n"), out: &bytes.Buffer{}, interactive: true, saver: saver}
+
+	if err := tt.check(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.SkipTLSVerify || cfg.TLSFingerprint != "" {
+		t.Errorf("Expected verification skipped, got %+v", cfg)
+	}
+	if len(saver.saved) != 1 || !saver.saved[0].SkipTLSVerify {
+		t.Errorf("Expected skip_tls_verify saved, got %v", saver.saved)
+	}
+}
+
+func TestTLSTrust_Abort(t *testing.T) {
+	for name, input := range map[string]string{"a": "a\n", "end of input": ""} {
+		t.Run(name, func(t *testing.T) {
+			_, cfg := untrustedServer(t)
+			saver := &recordingSaver{}
+			tt := tlsTrust{in: strings.NewReader(input), out: &bytes.Buffer{}, interactive: true, saver: saver}
+
+			if err := tt.check(context.Background(), cfg); !errors.Is(err, errTLSAborted) {
+				t.Errorf("Expected the run aborted, got %v", err)
+			}
+			if len(saver.saved) != 0 || cfg.SkipTLSVerify || cfg.TLSFingerprint != "" {
+				t.Errorf("Nothing should change on abort, got %+v and %v", cfg, saver.saved)
+			}
+		})
+	}
+}
+
+func TestTLSTrust_Headless(t *testing.T) {
+	server, cfg := untrustedServer(t)
+	var out bytes.Buffer
+	tt := tlsTrust{in: strings.NewReader("t\n"), out: &out, saver: &recordingSaver{}, path: "/etc/pvec/config"}
+
+	err := tt.check(context.Background(), cfg)
+	if err == nil {
+		t.Fatal("Expected an error without a user to ask")
+	}
+	for _, want := range []string{proxmox.Fingerprint(server.Certificate()), "tls_fingerprint", "skip_tls_verify", "/etc/pvec/config"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %q", want, err)
+		}
+	}
+	if out.Len() > 0 {
+		t.Errorf("Nothing should be asked, got %q", out.String())
+	}
+}
+
+func TestTLSTrust_SaveFails(t *testing.T) {
+	_, cfg := untrustedServer(t)
+	var out bytes.Buffer
+	tt := tlsTrust{in: strings.NewReader("t\n"), out: &out, interactive: true, saver: &recordingSaver{err: errors.New("read-only file system")}, path: "/etc/pvec/config"}
+
+	if err := tt.check(context.Background(), cfg); err != nil {
+		t.Fatalf("A failed save should still trust the certificate for the session: %v", err)
+	}
+	if cfg.TLSFingerprint == "" {
+		t.Error("Expected the certificate pinned for the session")
+	}
+	if !strings.Contains(out.String(), "for this session only: read-only file system") {
+		t.Errorf("Expected the failed save reported, got:\n%s", out.String())
+	}
+}
+
+func TestTLSTrust_NothingToDecide(t *testing.T) {
+	server, _ := untrustedServer(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	tests := map[string]struct {
+		cfg   config.Config
+		roots *x509.CertPool
+	}{
+		"trusted certificate": {config.Config{APIUrl: server.URL, TLSTrustUnset: true}, roots},
+		"decided":             {config.Config{APIUrl: server.URL}, nil},
+		"decided cluster":     {config.Config{TLSTrustUnset: true, Clusters: []config.ClusterConfig{{Name: "lab", APIUrl: server.URL}}}, nil},
+		"unreachable":         {config.Config{APIUrl: "https://127.0.0.1:1", TLSTrustUnset: true}, nil},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			tt := tlsTrust{in: strings.NewReader(""), out: &out, roots: tc.roots}
+			if err := tt.check(context.Background(), &tc.cfg); err != nil {
+				t.Errorf("Expected nothing to decide, got %v", err)
+			}
+			if out.Len() > 0 {
+				t.Errorf("Nothing should be asked, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestTLSTrust_Clusters(t *testing.T) {
+	lab, _ := untrustedServer(t)
+	prod, _ := untrustedServer(t)
+	cfg := &config.Config{TLSTrustUnset: true, Clusters: []config.ClusterConfig{
+		{Name: "lab", APIUrl: lab.URL, TLSTrustUnset: true},
+		{Name: "decided", APIUrl: lab.URL},
+		{Name: "prod", APIUrl: prod.URL, TLSTrustUnset: true},
+	}}
+	saver := &recordingSaver{}
+	var out bytes.Buffer
+	tt := tlsTrust{in: strings.NewReader("t\ns\n"), out: &out, interactive: true, saver: saver, path: "/home/u/.pvecrc"}
+
+	if err := tt.check(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if c := cfg.Clusters[0]; c.TLSFingerprint != proxmox.Fingerprint(lab.Certificate()) || c.SkipTLSVerify || c.TLSTrustUnset {
+		t.Errorf("Expected lab's certificate pinned, got %+v", c)
+	}
+	if c := cfg.Clusters[1]; c.TLSFingerprint != "" || c.SkipTLSVerify {
+		t.Errorf("A decided cluster should be left alone, got %+v", c)
+	}
+	if c := cfg.Clusters[2]; !c.SkipTLSVerify || c.TLSFingerprint != "" || c.TLSTrustUnset {
+		t.Errorf("Expected prod's verification skipped, got %+v", c)
+	}
+	if len(saver.saved) != 1 {
+		t.Errorf("Expected both decisions saved at once, got %d saves", len(saver.saved))
+	}
+	if got := strings.Count(out.String(), "is not trusted"); got != 2 {
+		t.Errorf("Expected a prompt per undecided cluster, got %d:\n%s", got, out.String())
+	}
+}
+
+func TestTLSTrust_ClustersHeadless(t *testing.T) {
+	server, _ := untrustedServer(t)
+	cfg := &config.Config{TLSTrustUnset: true, Clusters: []config.ClusterConfig{{Name: "lab", APIUrl: server.URL, TLSTrustUnset: true}}}
+	tt := tlsTrust{in: strings.NewReader("t\n"), out: &bytes.Buffer{}, path: "/etc/pvec/config"}
+
+	err := tt.check(context.Background(), cfg)
+	if err == nil {
+		t.Fatal("Expected an error without a user to ask")
+	}
+	for _, want := range []string{"cluster lab:", proxmox.Fingerprint(server.Certificate()), "in clusters[0] (lab) of /etc/pvec/config"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %q", want, err)
+		}
+	}
+}